@@ -17,7 +17,7 @@ var mountCmd = &cobra.Command{
 		devicePath := args[0]
 		mountPoint := args[1]
 
-		mountCmd := exec.Command("sudo", "mount", devicePath, mountPoint)
+		mountCmd := exec.Command("mount", devicePath, mountPoint)
 		multiWriter := io.MultiWriter(os.Stdout)
 		mountCmd.Stderr = multiWriter
 		mountCmd.Stdout = multiWriter
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+var nixCollectGarbageKeepGenerations int
+
+const systemProfile = "/nix/var/nix/profiles/system"
+
+func buildDeleteGenerationsArgs(keep int) []string {
+	// +N keeps the newest N generations, and always the current one.
+	return []string{"--profile", systemProfile, "--delete-generations", "+" + strconv.Itoa(keep)}
+}
+
+func runNixCommand(name string, args ...string) error {
+	execCmd := exec.Command(name, args...)
+	execCmd.Stdout = os.Stdout
+	execCmd.Stderr = os.Stderr
+	return execCmd.Run()
+}
+
+var collectGarbageCmd = &cobra.Command{
+	Use:   "collect-garbage",
+	Short: "Deletes old system generations and collects unreferenced store paths",
+	Long: `Delete all but the newest <keep-generations> generations of the system
+profile, then delete every store path nothing references any more. The
+boot entries are rewritten afterwards, so none point at a deleted
+generation.
+
+Example:
+  nix collect-garbage --keep-generations 5`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if nixCollectGarbageKeepGenerations < 1 {
+			fmt.Fprintln(os.Stderr, "Error: keep-generations must be at least 1")
+			os.Exit(1)
+		}
+
+		fmt.Printf("Deleting all but the newest %d system generations\n", nixCollectGarbageKeepGenerations)
+		if err := runNixCommand("nix-env", buildDeleteGenerationsArgs(nixCollectGarbageKeepGenerations)...); err != nil {
+			fmt.Fprintf(os.Stderr, "Error deleting generations: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Println("Collecting garbage")
+		if err := runNixCommand("nix-collect-garbage"); err != nil {
+			fmt.Fprintf(os.Stderr, "Error collecting garbage: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Println("Updating boot entries")
+		if err := runNixCommand(systemProfile+"/bin/switch-to-configuration", "boot"); err != nil {
+			fmt.Fprintf(os.Stderr, "Error updating boot entries: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	collectGarbageCmd.Flags().IntVar(&nixCollectGarbageKeepGenerations, "keep-generations", 0, "system generations to keep")
+	collectGarbageCmd.MarkFlagRequired("keep-generations")
+	nixCmd.AddCommand(collectGarbageCmd)
+}
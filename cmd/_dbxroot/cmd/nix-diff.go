@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Dogebox-WG/dogeboxd/cmd/_dbxroot/utils"
+	"github.com/spf13/cobra"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Builds the system and diffs it against the running one",
+	Long: `Build the system as it's currently configured, including any
+pending changes, without switching to it, and print how its closure
+differs from the running system's.
+
+Example:
+  nix diff`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := utils.RunNixOSBuildDiff(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error diffing the system closure: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	nixCmd.AddCommand(diffCmd)
+}
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Dogebox-WG/dogeboxd/cmd/_dbxroot/utils"
+	"github.com/spf13/cobra"
+)
+
+var nixDryBuildCustomNix string
+
+var dryBuildCmd = &cobra.Command{
+	Use:   "dry-build",
+	Short: "Executes nixos-rebuild dry-build with an unsaved custom.nix",
+	Long: `Evaluate the system configuration with <custom-nix> in place of the
+saved custom.nix, and work out what would be built, without building or
+switching anything.
+
+Example:
+  nix dry-build --custom-nix /tmp/custom-nix-dry-build-1234.nix`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !filepath.IsAbs(nixDryBuildCustomNix) || filepath.Clean(nixDryBuildCustomNix) != nixDryBuildCustomNix {
+			fmt.Fprintln(os.Stderr, "Error: custom-nix must be a clean absolute path")
+			os.Exit(1)
+		}
+
+		if err := utils.RunNixOSDryBuild(nixDryBuildCustomNix); err != nil {
+			fmt.Fprintf(os.Stderr, "Error executing nixos-rebuild dry-build: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	dryBuildCmd.Flags().StringVar(&nixDryBuildCustomNix, "custom-nix", "", "custom.nix to build with")
+	dryBuildCmd.MarkFlagRequired("custom-nix")
+	nixCmd.AddCommand(dryBuildCmd)
+}
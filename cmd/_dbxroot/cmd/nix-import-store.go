@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	nixImportStoreCacheDir    string
+	nixImportStoreRequireSigs bool
+)
+
+// Store paths are passed to nix copy this many at a time, to stay well
+// under the argv limit for big closures.
+const importStoreBatchSize = 500
+
+func readImportStorePaths(cacheDir string) ([]string, error) {
+	file, err := os.Open(filepath.Join(cacheDir, "store-paths"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open store path list: %w", err)
+	}
+	defer file.Close()
+
+	paths := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		path := strings.TrimSpace(scanner.Text())
+		if path == "" {
+			continue
+		}
+		if !strings.HasPrefix(path, "/nix/store/") || strings.Contains(path[len("/nix/store/"):], "/") {
+			return nil, fmt.Errorf("invalid store path %q", path)
+		}
+		paths = append(paths, path)
+	}
+	return paths, scanner.Err()
+}
+
+func buildImportStoreArgs(cacheDir string, paths []string, requireSigs bool) []string {
+	args := []string{"copy", "--from", "file://" + cacheDir}
+	if !requireSigs {
+		// dogeboxd has already checked every file in the cache against
+		// the bundle's signed manifest, and the bundle's key may not be
+		// one nix trusts for substitutes.
+		args = append(args, "--no-check-sigs")
+	}
+	return append(args, paths...)
+}
+
+var importStoreCmd = &cobra.Command{
+	Use:   "import-store",
+	Short: "Imports store paths from an offline update's or pup bundle's binary cache",
+	Long: `Copy the store paths listed in <cache-dir>/store-paths from the
+binary cache in <cache-dir> into the nix store.
+
+With --require-sigs, nix refuses paths that aren't signed by one of its
+trusted public keys, for caches dogeboxd hasn't verified itself, ie: a
+pup bundle.
+
+Example:
+  nix import-store --cache-dir /tmp/os-upgrade-v0.9.1-abc123-store
+  nix import-store --cache-dir /tmp/pup-bundle-123-store --require-sigs`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !filepath.IsAbs(nixImportStoreCacheDir) {
+			fmt.Fprintln(os.Stderr, "Error: cache-dir must be an absolute path")
+			os.Exit(1)
+		}
+
+		paths, err := readImportStorePaths(nixImportStoreCacheDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading store paths: %v\n", err)
+			os.Exit(1)
+		}
+
+		for start := 0; start < len(paths); start += importStoreBatchSize {
+			end := min(start+importStoreBatchSize, len(paths))
+			fmt.Printf("Importing store paths %d-%d of %d\n", start+1, end, len(paths))
+
+			execCmd := exec.Command("nix", buildImportStoreArgs(nixImportStoreCacheDir, paths[start:end], nixImportStoreRequireSigs)...)
+			execCmd.Stdout = os.Stdout
+			execCmd.Stderr = os.Stderr
+			if err := execCmd.Run(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error importing store paths: %v\n", err)
+				os.Exit(1)
+			}
+		}
+	},
+}
+
+func init() {
+	importStoreCmd.Flags().StringVar(&nixImportStoreCacheDir, "cache-dir", "", "unpacked binary cache to import from")
+	importStoreCmd.Flags().BoolVar(&nixImportStoreRequireSigs, "require-sigs", false, "only import paths signed by a key nix trusts")
+	importStoreCmd.MarkFlagRequired("cache-dir")
+	nixCmd.AddCommand(importStoreCmd)
+}
@@ -14,6 +14,7 @@ var nixRSFlakeDir string
 var nixRSSystemdRun bool
 var nixRSSystemdUnit string
 var nixRSCleanupFlakeDir bool
+var nixRSOffline bool
 
 func runCurrentSystemActivation() error {
 	execCmd := exec.Command("/nix/var/nix/profiles/system/bin/switch-to-configuration", "switch")
@@ -30,13 +31,13 @@ func runSystemdWrappedRS() error {
 
 	fmt.Fprintf(os.Stderr, "Running nixos-rebuild switch in transient unit %s; follow detailed logs with journalctl -u %s\n", unitName, unitName)
 
-	execCmd := exec.Command("/run/current-system/sw/bin/systemd-run", buildSystemdRunRSArgs(unitName, nixRSFlakeDir, nixRSSetRelease, nixRSCleanupFlakeDir)...)
+	execCmd := exec.Command("/run/current-system/sw/bin/systemd-run", buildSystemdRunRSArgs(unitName, nixRSFlakeDir, nixRSSetRelease, nixRSCleanupFlakeDir, nixRSOffline)...)
 	execCmd.Stdout = os.Stdout
 	execCmd.Stderr = os.Stderr
 	return execCmd.Run()
 }
 
-func buildSystemdRunRSArgs(unitName string, flakeDir string, setRelease string, cleanupFlakeDir bool) []string {
+func buildSystemdRunRSArgs(unitName string, flakeDir string, setRelease string, cleanupFlakeDir bool, offline bool) []string {
 	systemdArgs := []string{
 		"--unit", unitName,
 		"--collect",
@@ -57,6 +58,9 @@ func buildSystemdRunRSArgs(unitName string, flakeDir string, setRelease string,
 	if setRelease != "" {
 		systemdArgs = append(systemdArgs, "--set-release", setRelease)
 	}
+	if offline {
+		systemdArgs = append(systemdArgs, "--offline")
+	}
 
 	return systemdArgs
 }
@@ -73,7 +77,7 @@ var rsCmd = &cobra.Command{
 			return
 		}
 
-		if err := utils.RunNixOSRebuild("switch", nixRSSetRelease, nixRSFlakeDir); err != nil {
+		if err := utils.RunNixOSRebuild("switch", nixRSSetRelease, nixRSFlakeDir, nixRSOffline); err != nil {
 			fmt.Fprintf(os.Stderr, "Error executing nixos-rebuild switch: %v\n", err)
 			os.Exit(1)
 		}
@@ -99,5 +103,6 @@ func init() {
 	rsCmd.Flags().BoolVar(&nixRSSystemdRun, "systemd-run", false, "run rebuild inside a transient systemd unit")
 	rsCmd.Flags().StringVar(&nixRSSystemdUnit, "systemd-unit", "", "transient systemd unit name")
 	rsCmd.Flags().BoolVar(&nixRSCleanupFlakeDir, "cleanup-flake-dir", false, "remove the flake directory after a successful rebuild")
+	rsCmd.Flags().BoolVar(&nixRSOffline, "offline", false, "rebuild from the flake's own lock file without fetching anything (used for offline updates)")
 	nixCmd.AddCommand(rsCmd)
 }
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	nixSwitchGeneration int
+	nixSwitchSystemdRun bool
+)
+
+// The same unit system updates switch in, so a rollback and an update
+// can't run at once.
+const switchGenerationUnit = "dogebox-system-update"
+
+func buildSwitchGenerationArgs(generation int) []string {
+	return []string{"--profile", systemProfile, "--switch-generation", strconv.Itoa(generation)}
+}
+
+func buildSystemdRunSwitchGenerationArgs(generation int) []string {
+	return []string{
+		"--unit", switchGenerationUnit,
+		"--collect",
+		"--wait",
+		// No --pipe, dogeboxd may be restarted by the switch.
+		"--setenv=PATH=/run/current-system/sw/bin:/run/wrappers/bin",
+		"/run/wrappers/bin/_dbxroot",
+		"nix",
+		"switch-generation",
+		"--generation", strconv.Itoa(generation),
+	}
+}
+
+var switchGenerationCmd = &cobra.Command{
+	Use:   "switch-generation",
+	Short: "Switches the system to an earlier generation",
+	Long: `Point the system profile at <generation> and activate it, rolling the
+running system back without rebuilding. The boot entries are updated so
+the box boots into it too.
+
+With --systemd-run this runs in a transient unit, so the switch finishes
+even if it restarts dogeboxd.
+
+Example:
+  nix switch-generation --generation 41 --systemd-run`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if nixSwitchGeneration < 1 {
+			fmt.Fprintln(os.Stderr, "Error: generation must be at least 1")
+			os.Exit(1)
+		}
+
+		if nixSwitchSystemdRun {
+			execCmd := exec.Command("/run/current-system/sw/bin/systemd-run", buildSystemdRunSwitchGenerationArgs(nixSwitchGeneration)...)
+			execCmd.Stdout = os.Stdout
+			execCmd.Stderr = os.Stderr
+			if err := execCmd.Run(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error switching generation in transient unit: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		fmt.Printf("Switching system profile to generation %d\n", nixSwitchGeneration)
+		if err := runNixCommand("nix-env", buildSwitchGenerationArgs(nixSwitchGeneration)...); err != nil {
+			fmt.Fprintf(os.Stderr, "Error switching generation: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Println("Activating generation")
+		if err := runNixCommand(systemProfile+"/bin/switch-to-configuration", "switch"); err != nil {
+			fmt.Fprintf(os.Stderr, "Error activating generation: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	switchGenerationCmd.Flags().IntVar(&nixSwitchGeneration, "generation", 0, "system generation to switch to")
+	switchGenerationCmd.Flags().BoolVar(&nixSwitchSystemdRun, "systemd-run", false, "switch inside a transient systemd unit")
+	switchGenerationCmd.MarkFlagRequired("generation")
+	nixCmd.AddCommand(switchGenerationCmd)
+}
@@ -16,19 +16,27 @@ var prepareStorageDeviceCmd = &cobra.Command{
 	Short: "Prepare a storage device for use with Dogebox.",
 	Long: `Prepare a storage device for use with Dogebox.
 This command requires --disk and --dbx-secret flags.
+With --mirror-disk both disks are made into one btrfs raid1 volume.
 
 Example:
-  _dbxroot prepare-storage-device --disk /dev/sdb --dbx-secret ?`,
+  _dbxroot prepare-storage-device --disk /dev/sdb --dbx-secret ?
+  _dbxroot prepare-storage-device --disk /dev/sdb --mirror-disk /dev/sdc --dbx-secret ?`,
 	Run: func(cmd *cobra.Command, args []string) {
 		disk, _ := cmd.Flags().GetString("disk")
 		dbxSecret, _ := cmd.Flags().GetString("dbx-secret")
 		print, _ := cmd.Flags().GetBool("print")
+		mirrorDisk, _ := cmd.Flags().GetString("mirror-disk")
 
 		if dbxSecret != system.DBXRootSecret {
 			log.Printf("Invalid dbx secret")
 			os.Exit(1)
 		}
 
+		if mirrorDisk != "" && mirrorDisk == disk {
+			log.Printf("Mirror disk must be a different disk")
+			os.Exit(1)
+		}
+
 		defer func() {
 			if r := recover(); r != nil {
 				log.Printf("Failed to prepare storage device: %v", r)
@@ -36,45 +44,66 @@ Example:
 			}
 		}()
 
-		utils.RunParted(disk, "mklabel", "gpt")
-		utils.RunParted(disk, "mkpart", "root", "ext4", "0%", "100%")
+		partition := partitionStorageDisk(disk)
 
-		hasPartitionPrefix := strings.HasPrefix(disk, "/dev/nvme") || strings.HasPrefix(disk, "/dev/mmcblk")
-		partitionPrefix := ""
+		if mirrorDisk == "" {
+			utils.RunCommand("mkfs.ext4", "-L", "dogebox-storage", partition)
+		} else {
+			// Mirror data and metadata, so either disk can fail without losing anything.
+			mirrorPartition := partitionStorageDisk(mirrorDisk)
+			utils.RunCommand("mkfs.btrfs", "-f", "-L", "dogebox-storage", "-d", "raid1", "-m", "raid1", partition, mirrorPartition)
 
-		if strings.HasPrefix(disk, "/dev/loop") {
-			// Loop device. This is probably only used for development, but I guess support it anyway?
-			// We need to unmount, then remount it with partition scanning so it shows up again.
-			backingFile, err := utils.GetLoopDeviceBackingFile(disk)
-			if err != nil {
-				log.Printf("Error getting loop device backing file: %v", err)
-				os.Exit(1)
+			if print {
+				log.Printf("prepared mirror partition: %s", mirrorPartition)
 			}
-
-			// Unmount it.
-			utils.RunCommand("sudo", "losetup", "-d", disk)
-
-			// Remount it with partition scanning.
-			utils.RunCommand("sudo", "losetup", "-P", disk, backingFile)
-
-			hasPartitionPrefix = true
-		}
-
-		if hasPartitionPrefix {
-			partitionPrefix = "p"
 		}
 
-		partition := fmt.Sprintf("%s%s1", disk, partitionPrefix)
-		utils.RunCommand("mkfs.ext4", "-L", "dogebox-storage", partition)
+		// The volume is mounted by UUID, as disk names can change between boots.
+		uuid := strings.TrimSpace(utils.RunCommand("blkid", "-s", "UUID", "-o", "value", partition))
 
 		log.Println("Finished preparing storage device.")
 
 		if print {
+			log.Printf("prepared volume uuid: %s", uuid)
 			log.Printf("prepared partition: %s", partition)
 		}
 	},
 }
 
+// partitionStorageDisk wipes disk for a single partition filling it,
+// and returns the partition.
+func partitionStorageDisk(disk string) string {
+	utils.RunParted(disk, "mklabel", "gpt")
+	utils.RunParted(disk, "mkpart", "root", "ext4", "0%", "100%")
+
+	hasPartitionPrefix := strings.HasPrefix(disk, "/dev/nvme") || strings.HasPrefix(disk, "/dev/mmcblk")
+	partitionPrefix := ""
+
+	if strings.HasPrefix(disk, "/dev/loop") {
+		// Loop device. This is probably only used for development, but I guess support it anyway?
+		// We need to unmount, then remount it with partition scanning so it shows up again.
+		backingFile, err := utils.GetLoopDeviceBackingFile(disk)
+		if err != nil {
+			log.Printf("Error getting loop device backing file: %v", err)
+			os.Exit(1)
+		}
+
+		// Unmount it.
+		utils.RunCommand("sudo", "losetup", "-d", disk)
+
+		// Remount it with partition scanning.
+		utils.RunCommand("sudo", "losetup", "-P", disk, backingFile)
+
+		hasPartitionPrefix = true
+	}
+
+	if hasPartitionPrefix {
+		partitionPrefix = "p"
+	}
+
+	return fmt.Sprintf("%s%s1", disk, partitionPrefix)
+}
+
 func init() {
 	rootCmd.AddCommand(prepareStorageDeviceCmd)
 
@@ -85,4 +114,5 @@ func init() {
 	prepareStorageDeviceCmd.MarkFlagRequired("dbx-secret")
 
 	prepareStorageDeviceCmd.Flags().BoolP("print", "p", false, "Prints the resulting partition location")
+	prepareStorageDeviceCmd.Flags().String("mirror-disk", "", "Second disk to mirror the first onto (btrfs raid1)")
 }
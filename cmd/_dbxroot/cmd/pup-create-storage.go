@@ -22,11 +22,17 @@ var createStorageCmd = &cobra.Command{
 	Long: `Create storage for a pup by providing its ID and data directory.
 This command requires --pupId and --data-dir flags.
 
+With --quota-mb the storage is limited to that many MB using a filesystem
+project quota, which needs the data directory's filesystem mounted with
+prjquota. A quota of 0 removes any existing limit. Running it again on
+existing storage just updates the quota.
+
 Example:
-  pup create-storage --pupId mypup123 --data-dir /absolute/path/to/data`,
+  pup create-storage --pupId mypup123 --data-dir /absolute/path/to/data --quota-mb 20480`,
 	Run: func(cmd *cobra.Command, args []string) {
 		pupId, _ := cmd.Flags().GetString("pupId")
 		dataDir, _ := cmd.Flags().GetString("data-dir")
+		quotaMB, _ := cmd.Flags().GetInt("quota-mb")
 
 		if !utils.IsAlphanumeric(pupId) {
 			fmt.Println("Error: pupId must contain only alphanumeric characters")
@@ -38,6 +44,11 @@ Example:
 			os.Exit(1)
 		}
 
+		if quotaMB < 0 {
+			fmt.Println("Error: quota-mb can't be negative")
+			os.Exit(1)
+		}
+
 		fmt.Printf("Creating storage for pup with ID: %s at %s\n", pupId, dataDir)
 
 		storagePath := filepath.Join(dataDir, "pups", "storage", pupId)
@@ -53,6 +64,17 @@ Example:
 		}
 
 		fmt.Printf("Storage directory ownership changed to %d:%d\n", containerUserId, containerGroupId)
+
+		if quotaMB > 0 {
+			if err := utils.SetProjectQuota(pupId, storagePath, quotaMB); err != nil {
+				fmt.Printf("Error setting storage quota: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Storage quota set to %dMB\n", quotaMB)
+		} else if err := utils.ClearProjectQuota(pupId, storagePath); err != nil {
+			// Most likely quotas aren't enabled at all, so there's nothing to clear.
+			fmt.Printf("Warning: couldn't clear storage quota: %v\n", err)
+		}
 	},
 }
 
@@ -64,4 +86,6 @@ func init() {
 
 	createStorageCmd.Flags().StringP("data-dir", "d", "", "Absolute path to the data directory (required)")
 	createStorageCmd.MarkFlagRequired("data-dir")
+
+	createStorageCmd.Flags().Int("quota-mb", 0, "Limit the storage to this many MB, 0 for no limit")
 }
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Dogebox-WG/dogeboxd/cmd/_dbxroot/utils"
+	"github.com/spf13/cobra"
+)
+
+// Written into storage by dogeboxd for this box, and written again on the
+// box an export is imported on.
+var storageExportExcludes = map[string]bool{
+	"delegated.key":          true,
+	"delegated.extended.key": true,
+	".dbx/config.env":        true,
+}
+
+func pupExportStoragePath(dataDir string, exportId string) string {
+	return filepath.Join(dataDir, "pup-exports", exportId+".storage.tar.gz")
+}
+
+var exportStorageCmd = &cobra.Command{
+	Use:   "export-storage",
+	Short: "Archive a pup's storage for an export",
+	Long: `Archive a pup's storage directory for a pup export, to
+<data-dir>/pup-exports/<export-id>.storage.tar.gz.
+This command requires --pupId, --data-dir and --export-id flags.
+
+The pup's delegate keys and config.env are left out, they're written
+again when the export is imported.
+
+Example:
+  pup export-storage --pupId mypup123 --data-dir /absolute/path/to/data --export-id 0a1b2c3d`,
+	Run: func(cmd *cobra.Command, args []string) {
+		pupId, _ := cmd.Flags().GetString("pupId")
+		dataDir, _ := cmd.Flags().GetString("data-dir")
+		exportId, _ := cmd.Flags().GetString("export-id")
+
+		if !utils.IsAlphanumeric(pupId) {
+			fmt.Println("Error: pupId must contain only alphanumeric characters")
+			os.Exit(1)
+		}
+
+		if !utils.IsAbsolutePath(dataDir) {
+			fmt.Println("Error: data-dir must be an absolute path")
+			os.Exit(1)
+		}
+
+		if exportId == "" || !utils.IsAlphanumeric(exportId) {
+			fmt.Println("Error: export-id must contain only alphanumeric characters")
+			os.Exit(1)
+		}
+
+		storagePath := filepath.Join(dataDir, "pups", "storage", pupId)
+		archivePath := pupExportStoragePath(dataDir, exportId)
+
+		fmt.Printf("Archiving storage for pup %s to %s\n", pupId, archivePath)
+		if err := utils.WriteStorageArchive(storagePath, archivePath, storageExportExcludes); err != nil {
+			os.Remove(archivePath)
+			fmt.Printf("Error archiving storage: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Storage archived to %s\n", archivePath)
+	},
+}
+
+func init() {
+	pupCmd.AddCommand(exportStorageCmd)
+
+	exportStorageCmd.Flags().StringP("pupId", "p", "", "ID of the pup to archive storage for (required, alphanumeric only)")
+	exportStorageCmd.MarkFlagRequired("pupId")
+
+	exportStorageCmd.Flags().StringP("data-dir", "d", "", "Absolute path to the data directory (required)")
+	exportStorageCmd.MarkFlagRequired("data-dir")
+
+	exportStorageCmd.Flags().StringP("export-id", "e", "", "ID of the export the archive is for (required, alphanumeric only)")
+	exportStorageCmd.MarkFlagRequired("export-id")
+}
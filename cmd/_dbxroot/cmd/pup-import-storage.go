@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Dogebox-WG/dogeboxd/cmd/_dbxroot/utils"
+	"github.com/spf13/cobra"
+)
+
+var importStorageCmd = &cobra.Command{
+	Use:   "import-storage",
+	Short: "Restore a pup's storage from an export",
+	Long: `Replace the contents of a pup's storage directory with the archive
+export-storage wrote for a pup export, which may be from another Dogebox.
+This command requires --pupId, --data-dir and --export-id flags.
+
+The storage directory must already exist, see create-storage.
+
+Example:
+  pup import-storage --pupId mypup123 --data-dir /absolute/path/to/data --export-id 0a1b2c3d`,
+	Run: func(cmd *cobra.Command, args []string) {
+		pupId, _ := cmd.Flags().GetString("pupId")
+		dataDir, _ := cmd.Flags().GetString("data-dir")
+		exportId, _ := cmd.Flags().GetString("export-id")
+
+		if !utils.IsAlphanumeric(pupId) {
+			fmt.Println("Error: pupId must contain only alphanumeric characters")
+			os.Exit(1)
+		}
+
+		if !utils.IsAbsolutePath(dataDir) {
+			fmt.Println("Error: data-dir must be an absolute path")
+			os.Exit(1)
+		}
+
+		if exportId == "" || !utils.IsAlphanumeric(exportId) {
+			fmt.Println("Error: export-id must contain only alphanumeric characters")
+			os.Exit(1)
+		}
+
+		storagePath := filepath.Join(dataDir, "pups", "storage", pupId)
+		if _, err := os.Stat(storagePath); os.IsNotExist(err) {
+			fmt.Println("Error: Storage directory does not exist. Please create it first.")
+			os.Exit(1)
+		}
+
+		archivePath := pupExportStoragePath(dataDir, exportId)
+
+		fmt.Printf("Restoring storage for pup %s from %s\n", pupId, archivePath)
+		if err := utils.ExtractStorageArchive(archivePath, storagePath, containerUserId, containerGroupId); err != nil {
+			fmt.Printf("Error restoring storage: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Storage restored to %s\n", storagePath)
+	},
+}
+
+func init() {
+	pupCmd.AddCommand(importStorageCmd)
+
+	importStorageCmd.Flags().StringP("pupId", "p", "", "ID of the pup to restore storage for (required, alphanumeric only)")
+	importStorageCmd.MarkFlagRequired("pupId")
+
+	importStorageCmd.Flags().StringP("data-dir", "d", "", "Absolute path to the data directory (required)")
+	importStorageCmd.MarkFlagRequired("data-dir")
+
+	importStorageCmd.Flags().StringP("export-id", "e", "", "ID of the export to restore from (required, alphanumeric only)")
+	importStorageCmd.MarkFlagRequired("export-id")
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"time"
 
 	"github.com/Dogebox-WG/dogeboxd/cmd/_dbxroot/utils"
 	"github.com/spf13/cobra"
@@ -15,10 +16,16 @@ var stopCmd = &cobra.Command{
 	Long: `Stop a specific pup by providing its ID.
 This command requires a --pupId flag with an alphanumeric value.
 
+With --timeout, waits up to that many seconds for the container to
+shut down cleanly, then terminates it. dogeboxd passes the container's
+stop timeout, the pup's own plus time to stop everything else.
+
 Example:
-  pup stop --pupId mypup123`,
+  pup stop --pupId mypup123
+  pup stop --pupId mypup123 --timeout 600`,
 	Run: func(cmd *cobra.Command, args []string) {
 		pupId, _ := cmd.Flags().GetString("pupId")
+		timeout, _ := cmd.Flags().GetInt("timeout")
 		if !utils.IsAlphanumeric(pupId) {
 			fmt.Println("Error: pupId must contain only alphanumeric characters")
 			return
@@ -30,7 +37,7 @@ Example:
 		// can stop a non-pup container that is running on the system.
 		machineId := fmt.Sprintf("pup-%s", pupId)
 
-		machineCtlCmd := exec.Command("sudo", "machinectl", "stop", machineId)
+		machineCtlCmd := exec.Command("machinectl", "stop", machineId)
 		machineCtlCmd.Stdout = os.Stdout
 		machineCtlCmd.Stderr = os.Stderr
 
@@ -38,12 +45,45 @@ Example:
 			fmt.Fprintln(os.Stderr, "Error executing machinectl stop:", err)
 			os.Exit(1)
 		}
+
+		if timeout <= 0 {
+			return
+		}
+
+		// machinectl stop only asks the container to shut down.
+		deadline := time.Now().Add(time.Duration(timeout) * time.Second)
+		for time.Now().Before(deadline) {
+			if !machineRunning(machineId) {
+				fmt.Printf("Container %s stopped\n", machineId)
+				return
+			}
+			time.Sleep(time.Second)
+		}
+		if !machineRunning(machineId) {
+			return
+		}
+
+		fmt.Printf("Container %s still running after %ds, terminating\n", machineId, timeout)
+		terminateCmd := exec.Command("machinectl", "terminate", machineId)
+		terminateCmd.Stdout = os.Stdout
+		terminateCmd.Stderr = os.Stderr
+
+		if err := terminateCmd.Run(); err != nil {
+			fmt.Fprintln(os.Stderr, "Error executing machinectl terminate:", err)
+			os.Exit(1)
+		}
 	},
 }
 
+// machineRunning reports whether machined still knows the container.
+func machineRunning(machineId string) bool {
+	return exec.Command("machinectl", "show", machineId).Run() == nil
+}
+
 func init() {
 	pupCmd.AddCommand(stopCmd)
 
 	stopCmd.Flags().StringP("pupId", "p", "", "ID of the pup to stop (required, alphanumeric only)")
+	stopCmd.Flags().Int("timeout", 0, "Seconds to wait for a clean shutdown before terminating, 0 to not wait")
 	stopCmd.MarkFlagRequired("pupId")
 }
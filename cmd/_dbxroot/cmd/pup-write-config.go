@@ -3,10 +3,10 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
-	"sort"
-	"strings"
+	"syscall"
 
 	"github.com/Dogebox-WG/dogeboxd/cmd/_dbxroot/utils"
 	"github.com/spf13/cobra"
@@ -24,6 +24,7 @@ var writeConfigCmd = &cobra.Command{
 	Long: `Write configuration environment variables to a pup's storage directory.
 This command requires --pupId, --data-dir, and --config flags.
 The config should be a JSON object with string key-value pairs.
+Keys given with --keep that aren't in the config keep their existing value.
 
 Example:
   pup write-config --pupId 1234 --data-dir /absolute/path/to/data --config '{"RPC_USERNAME":"user","RPC_PASSWORD":"secret"}'`,
@@ -31,6 +32,7 @@ Example:
 		pupId, _ := cmd.Flags().GetString("pupId")
 		dataDir, _ := cmd.Flags().GetString("data-dir")
 		configJSON, _ := cmd.Flags().GetString("config")
+		keep, _ := cmd.Flags().GetStringSlice("keep")
 
 		if !utils.IsAlphanumeric(pupId) {
 			fmt.Println("Error: pupId must contain only alphanumeric characters")
@@ -69,36 +71,23 @@ Example:
 			os.Exit(1)
 		}
 
-		// Build the config.env content
-		// Sort keys for deterministic output
-		keys := make([]string, 0, len(config))
-		for k := range config {
-			keys = append(keys, k)
-		}
-		sort.Strings(keys)
-
-		var lines []string
-		for _, k := range keys {
-			// Escape any special characters in the value for shell/systemd
-			v := config[k]
-			// Values with spaces, quotes, or special chars should be quoted
-			// For systemd EnvironmentFile, we use simple quoting
-			if strings.ContainsAny(v, " \t\n\"'\\$`") {
-				// Escape backslashes and double quotes, then wrap in double quotes
-				v = strings.ReplaceAll(v, "\\", "\\\\")
-				v = strings.ReplaceAll(v, "\"", "\\\"")
-				v = fmt.Sprintf("\"%s\"", v)
+		configFilePath := filepath.Join(dbxDir, configFileName)
+		var existing []byte
+		if len(keep) > 0 {
+			// The pup owns .dbx, so don't follow a link it put there.
+			file, err := os.OpenFile(configFilePath, os.O_RDONLY|syscall.O_NOFOLLOW, 0)
+			if err == nil {
+				existing, err = io.ReadAll(file)
+				file.Close()
+			}
+			if err != nil && !os.IsNotExist(err) {
+				fmt.Printf("Error reading config file: %v\n", err)
+				os.Exit(1)
 			}
-			lines = append(lines, fmt.Sprintf("%s=%s", k, v))
-		}
-
-		content := strings.Join(lines, "\n")
-		if len(lines) > 0 {
-			content += "\n"
 		}
+		content := utils.RenderConfigEnv(config, string(existing), keep)
 
 		// Write the config file
-		configFilePath := filepath.Join(dbxDir, configFileName)
 		if err := os.WriteFile(configFilePath, []byte(content), configFilePerm); err != nil {
 			fmt.Printf("Error writing config file: %v\n", err)
 			os.Exit(1)
@@ -125,5 +114,6 @@ func init() {
 
 	writeConfigCmd.Flags().StringP("config", "c", "", "JSON object with config key-value pairs (required)")
 	writeConfigCmd.MarkFlagRequired("config")
-}
 
+	writeConfigCmd.Flags().StringSlice("keep", nil, "Keys to keep from the existing config.env when they're not in --config")
+}
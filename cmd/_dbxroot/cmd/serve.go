@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/Dogebox-WG/dogeboxd/pkg/rootd"
+	"github.com/spf13/cobra"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run rootd, serving privileged operations over a unix socket",
+	Long: `Run rootd, a small root-owned daemon that performs privileged
+operations for dogeboxd, in place of running _dbxroot via sudo.
+
+Only the typed operations rootd knows about can be requested, each is
+validated, and callers are identified by their socket credentials:
+--allow-uid may run any operation, --read-uid only status and log queries.
+Pup storage operations always work under --data-dir, whatever the
+caller asks for.
+
+Example:
+  serve --socket /run/dbx-rootd/rootd.sock --gid 1000 --allow-uid 1000 --data-dir /opt/dogebox`,
+	Run: func(cmd *cobra.Command, args []string) {
+		socket, _ := cmd.Flags().GetString("socket")
+		gid, _ := cmd.Flags().GetInt("gid")
+		allow, _ := cmd.Flags().GetUintSlice("allow-uid")
+		read, _ := cmd.Flags().GetUintSlice("read-uid")
+		dataDir, _ := cmd.Flags().GetString("data-dir")
+
+		policy := rootd.Policy{}
+		for _, uid := range allow {
+			policy.Full = append(policy.Full, uint32(uid))
+		}
+		for _, uid := range read {
+			policy.ReadOnly = append(policy.ReadOnly, uint32(uid))
+		}
+
+		l, err := rootd.Listen(socket, gid)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error listening on %s: %v\n", socket, err)
+			os.Exit(1)
+		}
+		defer os.Remove(socket)
+
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+
+		fmt.Printf("rootd listening on %s\n", socket)
+		if err := rootd.NewServer(policy, dataDir).Serve(ctx, l); err != nil {
+			fmt.Fprintf(os.Stderr, "Error serving: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	serveCmd.Flags().StringP("socket", "s", rootd.DefaultSocketPath, "Path of the unix socket to listen on")
+	serveCmd.Flags().IntP("gid", "g", 0, "Group allowed to connect to the socket")
+	serveCmd.Flags().UintSlice("allow-uid", nil, "uids that may run any operation")
+	serveCmd.Flags().UintSlice("read-uid", nil, "uids that may only query unit status and logs")
+	serveCmd.Flags().String("data-dir", "", "dogeboxd's data dir, pup storage operations are refused without it")
+}
@@ -16,7 +16,7 @@ var unmountCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		mountPoint := args[0]
 
-		unmountCmd := exec.Command("sudo", "umount", mountPoint)
+		unmountCmd := exec.Command("umount", mountPoint)
 		multiWriter := io.MultiWriter(os.Stdout)
 		unmountCmd.Stderr = multiWriter
 		unmountCmd.Stdout = multiWriter
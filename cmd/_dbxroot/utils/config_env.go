@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// RenderConfigEnv builds a pup's config.env. Keys in keep that aren't in
+// config keep their line from the existing file, which is how dogeboxd
+// leaves secrets alone while it can't decrypt them.
+func RenderConfigEnv(config map[string]string, existing string, keep []string) string {
+	lines := map[string]string{}
+	if len(keep) > 0 {
+		old := parseConfigEnvLines(existing)
+		for _, k := range keep {
+			if _, ok := config[k]; ok {
+				continue
+			}
+			if line, ok := old[k]; ok {
+				lines[k] = line
+			}
+		}
+	}
+
+	for k, v := range config {
+		// Values with spaces, quotes, or special chars should be quoted
+		// For systemd EnvironmentFile, we use simple quoting
+		if strings.ContainsAny(v, " \t\n\"'\\$`") {
+			// Escape backslashes and double quotes, then wrap in double quotes
+			v = strings.ReplaceAll(v, "\\", "\\\\")
+			v = strings.ReplaceAll(v, "\"", "\\\"")
+			v = fmt.Sprintf("\"%s\"", v)
+		}
+		lines[k] = fmt.Sprintf("%s=%s", k, v)
+	}
+
+	// Sort keys for deterministic output
+	keys := make([]string, 0, len(lines))
+	for k := range lines {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var out strings.Builder
+	for _, k := range keys {
+		out.WriteString(lines[k])
+		out.WriteString("\n")
+	}
+	return out.String()
+}
+
+// parseConfigEnvLines splits a config.env written by RenderConfigEnv into
+// its raw KEY=value lines by key. Quoted values can span lines.
+func parseConfigEnvLines(content string) map[string]string {
+	lines := map[string]string{}
+	for len(content) > 0 {
+		end := strings.IndexByte(content, '\n')
+		eq := strings.IndexByte(content, '=')
+		if eq > 0 && (end < 0 || eq < end) && strings.HasPrefix(content[eq+1:], "\"") {
+			// Find the closing quote, skipping escaped characters.
+			for i := eq + 2; i < len(content); i++ {
+				if content[i] == '\\' {
+					i++
+					continue
+				}
+				if content[i] == '"' {
+					end = strings.IndexByte(content[i:], '\n')
+					if end >= 0 {
+						end += i
+					}
+					break
+				}
+			}
+		}
+
+		line := content
+		if end >= 0 {
+			line, content = content[:end], content[end+1:]
+		} else {
+			content = ""
+		}
+		if k, _, ok := strings.Cut(line, "="); ok && k != "" {
+			lines[k] = line
+		}
+	}
+	return lines
+}
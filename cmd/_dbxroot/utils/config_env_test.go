@@ -0,0 +1,21 @@
+package utils
+
+import "testing"
+
+func TestRenderConfigEnvQuotesAndSorts(t *testing.T) {
+	got := RenderConfigEnv(map[string]string{"B": "two words", "A": "1"}, "", nil)
+	want := "A=1\nB=\"two words\"\n"
+	if got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+// Secrets dogeboxd can't decrypt yet are carried over from the old file.
+func TestRenderConfigEnvKeepsLines(t *testing.T) {
+	existing := "OLD=gone\nRPC_PASS=\"multi\nline \\\" pass\"\nTOKEN=abc\n"
+	got := RenderConfigEnv(map[string]string{"RPC_USER": "shibe", "TOKEN": "new"}, existing, []string{"RPC_PASS", "TOKEN", "MISSING"})
+	want := "RPC_PASS=\"multi\nline \\\" pass\"\nRPC_USER=shibe\nTOKEN=new\n"
+	if got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
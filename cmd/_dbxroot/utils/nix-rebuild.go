@@ -3,10 +3,11 @@ package utils
 import (
 	"os"
 	"os/exec"
+	"path/filepath"
 )
 
-func RunNixOSRebuild(action string, setRelease string, flakeDir string) error {
-	rebuildCommand, rebuildArgs, err := GetRebuildCommand(action, setRelease, flakeDir)
+func RunNixOSRebuild(action string, setRelease string, flakeDir string, offline bool) error {
+	rebuildCommand, rebuildArgs, err := GetRebuildCommand(action, setRelease, flakeDir, offline)
 	if err != nil {
 		return err
 	}
@@ -21,3 +22,51 @@ func RunNixOSRebuild(action string, setRelease string, flakeDir string) error {
 
 	return execCmd.Run()
 }
+
+// RunNixOSDryBuild evaluates and dry builds the system with customNixPath
+// in place of the saved custom.nix, see the dogebox.nix template.
+func RunNixOSDryBuild(customNixPath string) error {
+	rebuildCommand, rebuildArgs, err := GetRebuildCommand("dry-build", "", "", false)
+	if err != nil {
+		return err
+	}
+
+	execCmd := exec.Command(rebuildCommand, rebuildArgs...)
+	execCmd.Stdout = os.Stdout
+	execCmd.Stderr = os.Stderr
+	execCmd.Env = append(os.Environ(), "DBX_CUSTOM_NIX="+customNixPath)
+
+	return execCmd.Run()
+}
+
+/* RunNixOSBuildDiff builds the system as it's currently configured,
+ * without switching to it, and prints how its closure differs from the
+ * running system's, ie: which packages a rebuild would add, remove or
+ * change the version of.
+ */
+func RunNixOSBuildDiff() error {
+	rebuildCommand, rebuildArgs, err := GetRebuildCommand("build", "", "", false)
+	if err != nil {
+		return err
+	}
+
+	// nixos-rebuild build leaves a result link in the working directory.
+	dir, err := os.MkdirTemp("", "dbx-build-diff-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	execCmd := exec.Command(rebuildCommand, rebuildArgs...)
+	execCmd.Dir = dir
+	execCmd.Stdout = os.Stdout
+	execCmd.Stderr = os.Stderr
+	if err := execCmd.Run(); err != nil {
+		return err
+	}
+
+	diffCmd := exec.Command("nix", "--extra-experimental-features", "nix-command", "store", "diff-closures", "/run/current-system", filepath.Join(dir, "result"))
+	diffCmd.Stdout = os.Stdout
+	diffCmd.Stderr = os.Stderr
+	return diffCmd.Run()
+}
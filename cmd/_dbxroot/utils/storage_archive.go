@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/Dogebox-WG/dogeboxd/pkg/tarball"
+)
+
+/* WriteStorageArchive writes a gzipped tarball of a pup's storage at
+ * srcDir to destPath, skipping the slash separated paths in exclude.
+ * Ownership is kept, as pups may run services as several users.
+ */
+func WriteStorageArchive(srcDir string, destPath string, exclude map[string]bool) error {
+	out, err := os.OpenFile(destPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create storage archive: %w", err)
+	}
+	defer out.Close()
+
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+
+	err = filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(srcDir, path)
+		if err != nil || relPath == "." {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+
+		if exclude[relPath] {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		link := ""
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		case !info.IsDir() && !info.Mode().IsRegular():
+			// Sockets, fifos and devices are recreated by the pup.
+			return nil
+		}
+
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = relPath
+
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write storage archive: %w", err)
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to finalise storage archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to finalise storage archive: %w", err)
+	}
+	return out.Close()
+}
+
+/* ExtractStorageArchive replaces the contents of destDir with a tarball
+ * written by WriteStorageArchive, owned by uid and gid. It's unpacked
+ * beside destDir and only swapped in once all of it has been, so a bad
+ * archive leaves the pup's storage as it was.
+ */
+func ExtractStorageArchive(srcPath string, destDir string, uid int, gid int) error {
+	f, err := os.Open(srcPath)
+	if err != nil {
+		return fmt.Errorf("failed to open storage archive: %w", err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("failed to decompress storage archive: %w", err)
+	}
+	defer gz.Close()
+
+	destDir = filepath.Clean(destDir)
+	info, err := os.Stat(destDir)
+	if err != nil {
+		return err
+	}
+	tmpDir, err := os.MkdirTemp(filepath.Dir(destDir), "."+filepath.Base(destDir)+"-restore-*")
+	if err != nil {
+		return fmt.Errorf("failed to create storage restore dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	opts := tarball.Options{Symlinks: true, SkipOther: true, Chown: true, Uid: uid, Gid: gid}
+	if err := tarball.Extract(tar.NewReader(gz), tmpDir, opts); err != nil {
+		return fmt.Errorf("failed to restore storage archive: %w", err)
+	}
+	if err := os.Chmod(tmpDir, info.Mode().Perm()); err != nil {
+		return err
+	}
+	if err := os.Chown(tmpDir, uid, gid); err != nil {
+		return err
+	}
+
+	oldDir := tmpDir + "-old"
+	if err := os.Rename(destDir, oldDir); err != nil {
+		return fmt.Errorf("failed to replace storage: %w", err)
+	}
+	if err := os.Rename(tmpDir, destDir); err != nil {
+		_ = os.Rename(oldDir, destDir)
+		return fmt.Errorf("failed to replace storage: %w", err)
+	}
+	return os.RemoveAll(oldDir)
+}
@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorageArchiveRoundTrip(t *testing.T) {
+	src := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "chain", "blocks"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(src, ".dbx"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "chain", "blocks", "blk0.dat"), []byte("blocks"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "delegated.key"), []byte("secret"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(src, ".dbx", "config.env"), []byte("A=1"), 0600))
+	require.NoError(t, os.Symlink("chain/blocks", filepath.Join(src, "blocks")))
+
+	archive := filepath.Join(t.TempDir(), "storage.tar.gz")
+	require.NoError(t, WriteStorageArchive(src, archive, map[string]bool{"delegated.key": true, ".dbx/config.env": true}))
+
+	dest := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dest, "stale"), []byte("old"), 0644))
+	require.NoError(t, ExtractStorageArchive(archive, dest, os.Getuid(), os.Getgid()))
+
+	data, err := os.ReadFile(filepath.Join(dest, "blocks", "blk0.dat"))
+	require.NoError(t, err)
+	assert.Equal(t, "blocks", string(data))
+
+	link, err := os.Readlink(filepath.Join(dest, "blocks"))
+	require.NoError(t, err)
+	assert.Equal(t, "chain/blocks", link)
+
+	for _, missing := range []string{"stale", "delegated.key", ".dbx/config.env"} {
+		_, err := os.Stat(filepath.Join(dest, missing))
+		assert.True(t, os.IsNotExist(err), missing)
+	}
+	info, err := os.Stat(filepath.Join(dest, ".dbx"))
+	require.NoError(t, err)
+	assert.True(t, info.IsDir())
+}
+
+func TestExtractStorageArchiveKeepsStorageOnFailure(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "storage.tar.gz")
+	f, err := os.Create(archive)
+	require.NoError(t, err)
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "ok.txt", Typeflag: tar.TypeReg, Mode: 0644}))
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "../evil", Typeflag: tar.TypeReg, Mode: 0644}))
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	require.NoError(t, f.Close())
+
+	dest := filepath.Join(t.TempDir(), "storage")
+	require.NoError(t, os.Mkdir(dest, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dest, "blk0.dat"), []byte("blocks"), 0644))
+
+	assert.Error(t, ExtractStorageArchive(archive, dest, os.Getuid(), os.Getgid()))
+
+	data, err := os.ReadFile(filepath.Join(dest, "blk0.dat"))
+	require.NoError(t, err)
+	assert.Equal(t, "blocks", string(data))
+	entries, err := os.ReadDir(filepath.Dir(dest))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "the restore dir is cleaned up")
+}
@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"fmt"
+	"hash/fnv"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// PupProjectID is the filesystem project ID a pup's storage is accounted
+// to for quotas. It's derived from the pup ID so it never needs storing,
+// and kept clear of 0, which means no project.
+func PupProjectID(pupID string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(pupID))
+	return h.Sum32()%(1<<31-1) + 1
+}
+
+// storageMountPoint finds the mount point of the filesystem path is on,
+// which is what quotas are set against.
+func storageMountPoint(path string) (string, error) {
+	out, err := exec.Command("findmnt", "--noheadings", "--output", "TARGET", "--target", path).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to find mount point of %s: %w", path, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func runQuotaCommand(args ...string) error {
+	out, err := exec.Command(args[0], args[1:]...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s failed: %w: %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+/* SetProjectQuota caps the space path can use at quotaMB, using project
+ * quotas: everything under path is tagged with the pup's project ID (and
+ * inherits it, via +P), and a hard block limit set for that project. The
+ * filesystem must be mounted with project quotas enabled (prjquota),
+ * otherwise this fails and nothing is enforced.
+ */
+func SetProjectQuota(pupID string, path string, quotaMB int) error {
+	mount, err := storageMountPoint(path)
+	if err != nil {
+		return err
+	}
+	project := strconv.FormatUint(uint64(PupProjectID(pupID)), 10)
+
+	if err := runQuotaCommand("chattr", "-R", "+P", "-p", project, path); err != nil {
+		return err
+	}
+	// setquota takes limits in 1KiB blocks.
+	blocks := strconv.Itoa(quotaMB * 1024)
+	return runQuotaCommand("setquota", "-P", project, blocks, blocks, "0", "0", mount)
+}
+
+// ClearProjectQuota removes any limit on the pup's project, leaving its
+// files tagged so a quota can be set again later.
+func ClearProjectQuota(pupID string, path string) error {
+	mount, err := storageMountPoint(path)
+	if err != nil {
+		return err
+	}
+	project := strconv.FormatUint(uint64(PupProjectID(pupID)), 10)
+	return runQuotaCommand("setquota", "-P", project, "0", "0", "0", "0", mount)
+}
@@ -96,13 +96,20 @@ func GetFlakePath(baseDir string) (string, error) {
 	return buildFlakePath(baseDir, buildType, architecture), nil
 }
 
-func buildRebuildCommand(action string, setRelease string, flakePath string, versionInformation *version.DBXVersionInfo) (string, []string, error) {
-	// Action is allowed to be "boot" or "switch". Throw an error if it's not.
-	if action != "boot" && action != "switch" {
+func buildRebuildCommand(action string, setRelease string, flakePath string, offline bool, versionInformation *version.DBXVersionInfo) (string, []string, error) {
+	// Action is allowed to be "boot", "switch", "build" or "dry-build". Throw an error if it's not.
+	if action != "boot" && action != "switch" && action != "build" && action != "dry-build" {
 		return "", nil, fmt.Errorf("invalid action: %s", action)
 	}
 
-	commandArgs := []string{action, "--flake", flakePath, "--impure"}
+	// Print full build logs, so dogeboxd can split out each pup's build output.
+	commandArgs := []string{action, "--flake", flakePath, "--impure", "--print-build-logs"}
+
+	// Offline updates build from the bundle's flake.lock, which already
+	// pins each package, and everything it needs is in the store.
+	if offline {
+		return "nixos-rebuild", append(commandArgs, "--offline"), nil
+	}
 
 	for pkg, tuple := range versionInformation.Packages {
 		// Only support dogebox-wg thing for now.
@@ -117,14 +124,14 @@ func buildRebuildCommand(action string, setRelease string, flakePath string, ver
 	return "nixos-rebuild", commandArgs, nil
 }
 
-func GetRebuildCommand(action string, setRelease string, flakeDir string) (string, []string, error) {
+func GetRebuildCommand(action string, setRelease string, flakeDir string, offline bool) (string, []string, error) {
 	flakePath, err := GetFlakePath(flakeDir)
 	if err != nil {
 		return "", nil, fmt.Errorf("failed to get flake path: %w", err)
 	}
 
 	versionInformation := version.GetDBXRelease()
-	return buildRebuildCommand(action, setRelease, flakePath, versionInformation)
+	return buildRebuildCommand(action, setRelease, flakePath, offline, versionInformation)
 }
 
 func CopyFiles(source string, destination string) error {
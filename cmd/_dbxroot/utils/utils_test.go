@@ -27,7 +27,7 @@ func TestGetFlakePathUsesCustomBaseDir(t *testing.T) {
 }
 
 func TestGetRebuildCommandUsesStagedFlakeWithUpgradeOverrides(t *testing.T) {
-	command, args, err := buildRebuildCommand("switch", "v9.9.9", "/tmp/os-upgrade#dogeboxos-qemu-x86_64", testVersionInfo())
+	command, args, err := buildRebuildCommand("switch", "v9.9.9", "/tmp/os-upgrade#dogeboxos-qemu-x86_64", false, testVersionInfo())
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -41,6 +41,7 @@ func TestGetRebuildCommandUsesStagedFlakeWithUpgradeOverrides(t *testing.T) {
 		"--flake",
 		"/tmp/os-upgrade#dogeboxos-qemu-x86_64",
 		"--impure",
+		"--print-build-logs",
 	}
 	if len(args) < len(expectedPrefix) {
 		t.Fatalf("expected args to start with %v, got %v", expectedPrefix, args)
@@ -65,7 +66,7 @@ func TestGetRebuildCommandUsesStagedFlakeWithUpgradeOverrides(t *testing.T) {
 }
 
 func TestGetRebuildCommandUsesVersionOverridesWhenNoFlakeDirIsProvided(t *testing.T) {
-	_, args, err := buildRebuildCommand("boot", "v2.0.0", "/etc/nixos#dogeboxos-iso-x86_64", testVersionInfo())
+	_, args, err := buildRebuildCommand("boot", "v2.0.0", "/etc/nixos#dogeboxos-iso-x86_64", false, testVersionInfo())
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -82,3 +83,45 @@ func TestGetRebuildCommandUsesVersionOverridesWhenNoFlakeDirIsProvided(t *testin
 		}
 	}
 }
+
+func TestGetRebuildCommandOfflineUsesTheFlakeLock(t *testing.T) {
+	_, args, err := buildRebuildCommand("switch", "v9.9.9", "/tmp/os-upgrade#dogeboxos-qemu-x86_64", true, testVersionInfo())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	joinedArgs := strings.Join(args, " ")
+	if strings.Contains(joinedArgs, "--override-input") {
+		t.Fatalf("expected offline rebuild args to have no input overrides, got %q", joinedArgs)
+	}
+	if args[len(args)-1] != "--offline" {
+		t.Fatalf("expected offline rebuild args to end with --offline, got %q", joinedArgs)
+	}
+}
+
+func TestGetRebuildCommandAllowsDryBuild(t *testing.T) {
+	_, args, err := buildRebuildCommand("dry-build", "", "/etc/nixos#dogeboxos-qemu-x86_64", false, testVersionInfo())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if args[0] != "dry-build" {
+		t.Fatalf("expected dry-build action, got %q", strings.Join(args, " "))
+	}
+
+	if _, _, err := buildRebuildCommand("test", "", "/etc/nixos#dogeboxos-qemu-x86_64", false, testVersionInfo()); err == nil {
+		t.Fatalf("expected other actions to be refused")
+	}
+}
+
+func TestPupProjectIDIsStableAndNonZero(t *testing.T) {
+	a := PupProjectID("0f3a9c2b7d")
+	if a == 0 || a >= 1<<31 {
+		t.Fatalf("project id %d out of range", a)
+	}
+	if PupProjectID("0f3a9c2b7d") != a {
+		t.Fatal("project id should be stable for a pup")
+	}
+	if PupProjectID("8e21d4c6aa") == a {
+		t.Fatal("different pups should get different project ids")
+	}
+}
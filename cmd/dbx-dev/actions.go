@@ -1,9 +1,11 @@
 package dbxdev
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -13,6 +15,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/Dogebox-WG/dogeboxd/pkg/devtemplate"
 	tea "github.com/charmbracelet/bubbletea"
 )
 
@@ -32,47 +35,91 @@ func pupActionCmd(id, action string) tea.Cmd {
 	}
 }
 
-// templateFilesCmd walks through the pup directory and replaces pup_$template with the chosen pup name
-func templateFilesCmd(pupName, templateName string) tea.Cmd {
+// updateProviderCmd POSTs /providers/{id} to set the pup providing one of
+// a pup's dependencies
+func updateProviderCmd(pupID, iface, provider string) tea.Cmd {
 	return func() tea.Msg {
-		// Determine the dev directory
-		devDir, err := getDevDir()
+		client := getSocketClient()
+
+		body, _ := json.Marshal(map[string]string{iface: provider})
+		resp, err := client.Post("http://dogeboxd/providers/"+pupID, "application/json", bytes.NewReader(body))
 		if err != nil {
-			return templateCompleteMsg{err: err}
+			return providerUpdatedMsg{iface: iface, provider: provider, err: err}
 		}
+		defer resp.Body.Close()
 
-		pupDir := filepath.Join(devDir, pupName)
-		searchPattern := fmt.Sprintf("pup_%s", templateName)
+		if resp.StatusCode != http.StatusOK {
+			return providerUpdatedMsg{iface: iface, provider: provider, err: fmt.Errorf("failed to update provider: %d", resp.StatusCode)}
+		}
 
-		// Walk through all files in the directory
-		err = filepath.Walk(pupDir, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
+		var result map[string]string
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return providerUpdatedMsg{iface: iface, provider: provider, err: fmt.Errorf("failed to decode response: %w", err)}
+		}
+		return providerUpdatedMsg{iface: iface, provider: provider, jobID: result["id"]}
+	}
+}
 
-			// Skip directories and .git folder
-			if info.IsDir() || strings.Contains(path, ".git") {
-				return nil
-			}
+// bulkPupActionCmd POSTs /pups/bulk to enable, disable or upgrade several
+// pups as one job
+func bulkPupActionCmd(operation string, ids []string) tea.Cmd {
+	return func() tea.Msg {
+		client := getSocketClient()
+
+		body, _ := json.Marshal(map[string]interface{}{
+			"operation": operation,
+			"pupIds":    ids,
+		})
+		resp, err := client.Post("http://dogeboxd/pups/bulk", "application/json", bytes.NewReader(body))
+		if err != nil {
+			return bulkActionMsg{operation: operation, err: err}
+		}
+		defer resp.Body.Close()
 
-			// Read file content
-			content, err := os.ReadFile(path)
-			if err != nil {
-				return err
+		if resp.StatusCode != http.StatusOK {
+			var apiErr struct {
+				Error struct {
+					Message string `json:"message"`
+				} `json:"error"`
 			}
+			if json.NewDecoder(resp.Body).Decode(&apiErr) == nil && apiErr.Error.Message != "" {
+				return bulkActionMsg{operation: operation, err: errors.New(apiErr.Error.Message)}
+			}
+			return bulkActionMsg{operation: operation, err: fmt.Errorf("status %d", resp.StatusCode)}
+		}
 
-			// Replace all instances of pup_$template with pupName
-			newContent := strings.ReplaceAll(string(content), searchPattern, pupName)
+		var result map[string]string
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return bulkActionMsg{operation: operation, err: fmt.Errorf("failed to decode response: %w", err)}
+		}
+		return bulkActionMsg{operation: operation, count: len(ids), jobID: result["id"]}
+	}
+}
 
-			// Only write if content changed
-			if string(content) != newContent {
-				if err := os.WriteFile(path, []byte(newContent), info.Mode()); err != nil {
-					return err
-				}
-			}
+// loadTemplateVariablesCmd reads the variables the cloned template asks for
+func loadTemplateVariablesCmd(pupName string) tea.Cmd {
+	return func() tea.Msg {
+		devDir, err := getDevDir()
+		if err != nil {
+			return templateVariablesMsg{err: err}
+		}
 
-			return nil
-		})
+		tmpl, err := devtemplate.Load(filepath.Join(devDir, pupName))
+		return templateVariablesMsg{variables: tmpl.Variables, err: err}
+	}
+}
+
+// templateFilesCmd walks through the pup directory and replaces pup_$template with the chosen pup name,
+// and the template's variables with the values entered for them
+func templateFilesCmd(pupName, templateName string, values map[string]string) tea.Cmd {
+	return func() tea.Msg {
+		// Determine the dev directory
+		devDir, err := getDevDir()
+		if err != nil {
+			return templateCompleteMsg{err: err}
+		}
+
+		_, err = devtemplate.Apply(filepath.Join(devDir, pupName), templateName, pupName, values)
 
 		// Add synthetic delay
 		time.Sleep(1 * time.Second)
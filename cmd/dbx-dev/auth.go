@@ -35,6 +35,9 @@ func checkBootstrapCmd(socketPath string) tea.Cmd {
 			SetupFacts struct {
 				HasCompletedInitialConfiguration bool `json:"hasCompletedInitialConfiguration"`
 			} `json:"setupFacts"`
+			DisplayPreferences struct {
+				Units string `json:"units"`
+			} `json:"displayPreferences"`
 		}
 
 		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
@@ -50,7 +53,7 @@ func checkBootstrapCmd(socketPath string) tea.Cmd {
 			}
 		}
 
-		return bootstrapCheckMsg{socketPath: socketPath, err: nil, configurationComplete: true}
+		return bootstrapCheckMsg{socketPath: socketPath, err: nil, configurationComplete: true, units: result.DisplayPreferences.Units}
 	}
 }
 
@@ -202,42 +205,55 @@ func installPupCmd(sourceId, pupName, token string) tea.Cmd {
 			return pupInstalledMsg{err: err}
 		}
 
-		client := getSocketClient()
+		jobID, err := requestPupInstall(sourceId, pupName, version, token)
+		return pupInstalledMsg{jobID: jobID, err: err}
+	}
+}
 
-		payload := map[string]interface{}{
-			"pupName":                 pupName,
-			"pupVersion":              version,
-			"sourceId":                sourceId,
-			"autoInstallDependencies": false,
-		}
-		body, _ := json.Marshal(payload)
+// installSourcePupCmd installs a version of a pup picked from a source's detail screen
+func installSourcePupCmd(sourceId string, pup sourcePupVersion, token string) tea.Cmd {
+	return func() tea.Msg {
+		jobID, err := requestPupInstall(sourceId, pup.Name, pup.Version, token)
+		return sourcePupInstallMsg{pup: pup, jobID: jobID, err: err}
+	}
+}
 
-		req, err := http.NewRequest(http.MethodPut, "http://dogeboxd/pup", bytes.NewReader(body))
-		if err != nil {
-			return pupInstalledMsg{err: err}
-		}
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("Authorization", "Bearer "+token)
+// requestPupInstall asks dogeboxd to install a pup, returning the install job's ID
+func requestPupInstall(sourceId, pupName, version, token string) (string, error) {
+	client := getSocketClient()
 
-		resp, err := client.Do(req)
-		if err != nil {
-			return pupInstalledMsg{err: err}
-		}
-		defer resp.Body.Close()
+	payload := map[string]interface{}{
+		"pupName":                 pupName,
+		"pupVersion":              version,
+		"sourceId":                sourceId,
+		"autoInstallDependencies": false,
+	}
+	body, _ := json.Marshal(payload)
 
-		if resp.StatusCode != http.StatusOK {
-			return pupInstalledMsg{err: fmt.Errorf("failed to install pup: %d", resp.StatusCode)}
-		}
+	req, err := http.NewRequest(http.MethodPut, "http://dogeboxd/pup", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
 
-		// Read the response to get the job ID
-		var result map[string]string
-		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-			return pupInstalledMsg{err: fmt.Errorf("failed to decode response: %w", err)}
-		}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
 
-		jobID := result["id"]
-		return pupInstalledMsg{jobID: jobID, err: nil}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to install pup: %d", resp.StatusCode)
 	}
+
+	// Read the response to get the job ID
+	var result map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return result["id"], nil
 }
 
 // createSourceCmd creates a new source
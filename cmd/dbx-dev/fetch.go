@@ -2,8 +2,13 @@ package dbxdev
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/mod/semver"
 )
 
 // fetchPupsCmd retrieves pup information via the unix socket.
@@ -19,6 +24,15 @@ func fetchPupsCmd() tea.Cmd {
 
 		var payload struct {
 			States map[string]json.RawMessage `json:"states"`
+			Stats  map[string]struct {
+				Issues struct {
+					DepsNotRunning    []string `json:"depsNotRunning"`
+					DepsInMaintenance []string `json:"depsInMaintenance"`
+					HealthWarnings    []string `json:"healthWarnings"`
+					StorageWarnings   []string `json:"storageWarnings"`
+					UpgradeAvailable  bool     `json:"upgradeAvailable"`
+				} `json:"issues"`
+			} `json:"stats"`
 		}
 		if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
 			return pupsMsg{err: err}
@@ -31,7 +45,24 @@ func fetchPupsCmd() tea.Cmd {
 					Meta struct {
 						Name string `json:"name"`
 					} `json:"meta"`
+					Container struct {
+						Exposes []struct {
+							Name         string `json:"name"`
+							Type         string `json:"type"`
+							Port         int    `json:"port"`
+							ListenOnHost bool   `json:"listenOnHost"`
+						} `json:"exposes"`
+					} `json:"container"`
 				} `json:"manifest"`
+				Version string `json:"version"`
+				Source  struct {
+					ID   string `json:"id"`
+					Name string `json:"name"`
+				} `json:"source"`
+				IP               string   `json:"ip"`
+				NeedsConf        bool     `json:"needsConf"`
+				NeedsDeps        bool     `json:"needsDeps"`
+				NeedsDevices     bool     `json:"needsDevices"`
 				Installation     string   `json:"installation"`
 				Enabled          bool     `json:"enabled"`
 				BrokenReason     string   `json:"brokenReason"`
@@ -41,14 +72,48 @@ func fetchPupsCmd() tea.Cmd {
 			if err := json.Unmarshal(raw, &s); err != nil {
 				continue
 			}
+
+			ports := make([]pupPort, 0, len(s.Manifest.Container.Exposes))
+			for _, e := range s.Manifest.Container.Exposes {
+				ports = append(ports, pupPort{Name: e.Name, Type: e.Type, Port: e.Port, ListenOnHost: e.ListenOnHost})
+			}
+
+			issues := []string{}
+			if s.NeedsConf {
+				issues = append(issues, "Needs configuration")
+			}
+			if s.NeedsDeps {
+				issues = append(issues, "Needs dependencies")
+			}
+			if s.NeedsDevices {
+				issues = append(issues, "Needs device approval")
+			}
+			stats := payload.Stats[id]
+			for _, dep := range stats.Issues.DepsNotRunning {
+				issues = append(issues, "Dependency not running: "+dep)
+			}
+			for _, dep := range stats.Issues.DepsInMaintenance {
+				issues = append(issues, "Dependency in maintenance: "+dep)
+			}
+			issues = append(issues, stats.Issues.HealthWarnings...)
+			issues = append(issues, stats.Issues.StorageWarnings...)
+			if stats.Issues.UpgradeAvailable {
+				issues = append(issues, "Upgrade available")
+			}
 			out = append(out, pupInfo{
 				ID:           id,
 				Name:         s.Manifest.Meta.Name,
+				Version:      s.Version,
+				SourceID:     s.Source.ID,
+				SourceName:   s.Source.Name,
 				State:        s.Installation,
 				Enabled:      s.Enabled,
 				Error:        s.BrokenReason,
 				DevEnabled:   s.IsDevModeEnabled,
 				DevAvailable: len(s.DevModeServices) > 0,
+				IP:           s.IP,
+				Ports:        ports,
+				Issues:       issues,
 			})
 		}
 		return pupsMsg{list: out}
@@ -92,3 +157,171 @@ func fetchSourcesCmd() tea.Cmd {
 		return sourcesMsg{sources: sources}
 	}
 }
+
+// fetchSourcePupsCmd lists every version of every pup a source provides,
+// newest versions first.
+func fetchSourcePupsCmd(sourceID string) tea.Cmd {
+	return func() tea.Msg {
+		client := getSocketClient()
+
+		resp, err := client.Get("http://dogeboxd/sources/store")
+		if err != nil {
+			return sourcePupsMsg{sourceID: sourceID, err: err}
+		}
+		defer resp.Body.Close()
+
+		var payload map[string]struct {
+			Error string `json:"error"`
+			Pups  map[string]struct {
+				LatestVersion string                     `json:"latestVersion"`
+				Versions      map[string]json.RawMessage `json:"versions"`
+				Compatibility map[string]struct {
+					Compatible bool `json:"compatible"`
+				} `json:"compatibility"`
+			} `json:"pups"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+			return sourcePupsMsg{sourceID: sourceID, err: err}
+		}
+
+		entry, ok := payload[sourceID]
+		if !ok {
+			return sourcePupsMsg{sourceID: sourceID, err: fmt.Errorf("source %s not found", sourceID)}
+		}
+		if entry.Error != "" {
+			return sourcePupsMsg{sourceID: sourceID, err: errors.New(entry.Error)}
+		}
+
+		pups := []sourcePupVersion{}
+		for name, p := range entry.Pups {
+			for v := range p.Versions {
+				compat, checked := p.Compatibility[v]
+				pups = append(pups, sourcePupVersion{
+					Name:       name,
+					Version:    v,
+					Latest:     v == p.LatestVersion,
+					Compatible: !checked || compat.Compatible,
+				})
+			}
+		}
+		sort.Slice(pups, func(i, j int) bool {
+			if pups[i].Name != pups[j].Name {
+				return pups[i].Name < pups[j].Name
+			}
+			return semver.Compare("v"+pups[i].Version, "v"+pups[j].Version) > 0
+		})
+		return sourcePupsMsg{sourceID: sourceID, pups: pups}
+	}
+}
+
+// fetchPupDepsCmd retrieves a pup's dependencies and their providers.
+func fetchPupDepsCmd(pupID string) tea.Cmd {
+	return func() tea.Msg {
+		client := getSocketClient()
+
+		resp, err := client.Get("http://dogeboxd/providers/" + pupID)
+		if err != nil {
+			return pupDepsMsg{pupID: pupID, err: err}
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return pupDepsMsg{pupID: pupID, err: fmt.Errorf("failed to fetch dependencies: %d", resp.StatusCode)}
+		}
+
+		var deps []pupDependency
+		if err := json.NewDecoder(resp.Body).Decode(&deps); err != nil {
+			return pupDepsMsg{pupID: pupID, err: err}
+		}
+		return pupDepsMsg{pupID: pupID, deps: deps}
+	}
+}
+
+// fetchJobMetricsCmd counts the jobs in flight and fetches the last one to
+// finish, for the status bar.
+func fetchJobMetricsCmd() tea.Cmd {
+	return func() tea.Msg {
+		client := getSocketClient()
+
+		var active struct {
+			Jobs []struct {
+				Status string `json:"status"`
+			} `json:"jobs"`
+		}
+		if err := getJSON(client, "http://dogeboxd/jobs/active", &active); err != nil {
+			return jobMetricsMsg{err: err}
+		}
+
+		var recent struct {
+			Jobs []struct {
+				DisplayName string `json:"displayName"`
+				Status      string `json:"status"`
+			} `json:"jobs"`
+		}
+		if err := getJSON(client, "http://dogeboxd/jobs/recent?limit=1", &recent); err != nil {
+			return jobMetricsMsg{err: err}
+		}
+
+		msg := jobMetricsMsg{}
+		for _, j := range active.Jobs {
+			if j.Status == "queued" {
+				msg.queued++
+			} else {
+				msg.running++
+			}
+		}
+		if len(recent.Jobs) > 0 {
+			msg.lastJob = recent.Jobs[0].DisplayName
+			msg.lastJobStatus = recent.Jobs[0].Status
+		}
+		return msg
+	}
+}
+
+// fetchUpdatesCmd counts installed pups with an upgrade available, and
+// looks for a newer Dogebox release.
+func fetchUpdatesCmd() tea.Cmd {
+	return func() tea.Msg {
+		client := getSocketClient()
+
+		var pups map[string]struct {
+			UpdateAvailable bool `json:"updateAvailable"`
+		}
+		if err := getJSON(client, "http://dogeboxd/pup/updates", &pups); err != nil {
+			return updatesMsg{err: err}
+		}
+
+		msg := updatesMsg{}
+		for _, p := range pups {
+			if p.UpdateAvailable {
+				msg.pupUpdates++
+			}
+		}
+
+		// Not being able to reach the release feed isn't worth reporting,
+		// the pup count still stands.
+		var system struct {
+			Packages map[string]struct {
+				LatestUpdate string `json:"latestUpdate"`
+			} `json:"packages"`
+		}
+		if err := getJSON(client, "http://dogeboxd/system/updates", &system); err == nil {
+			msg.systemUpdate = system.Packages["dogebox"].LatestUpdate
+		}
+		return msg
+	}
+}
+
+// getJSON decodes the JSON response to a GET of url into out.
+func getJSON(client *http.Client, url string, out any) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -9,8 +9,11 @@ import (
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/Dogebox-WG/dogeboxd/pkg/devtemplate"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/shirou/gopsutil/v4/cpu"
 	"github.com/shirou/gopsutil/v4/mem"
@@ -22,14 +25,33 @@ type model struct {
 	cpuPercent    float64
 	memUsed       uint64
 	memTotal      uint64
+	units         string // "binary" or "decimal", from the user's display preferences
 	pups          []pupInfo
 
+	// Job and update state from dogeboxd, for the status bar
+	ticks         int
+	jobsRunning   int
+	jobsQueued    int
+	lastJob       string
+	lastJobStatus string
+	pupUpdates    int
+	systemUpdate  string
+	jobMetricsErr bool
+
 	selected int
 
 	view      viewState
 	detail    pupInfo
 	selDetail int // selected action index in detail view
 
+	// Dependencies of the pup in the detail view
+	pupDeps       []pupDependency
+	pupDepsErr    string
+	loadingDeps   bool
+	detailStatus  string
+	providerIface string // dependency whose provider is being picked
+	selProvider   int
+
 	logs      []string
 	logActive bool // generic tail active flag
 
@@ -38,6 +60,10 @@ type model struct {
 	searching   bool
 	searchQuery string
 
+	// Pups marked for a bulk action, by ID
+	marked     map[string]bool
+	bulkStatus string
+
 	// Create pup flow
 	templates    []templateInfo
 	selectedTpl  int // selected template index
@@ -70,8 +96,23 @@ type model struct {
 	creatingSource bool
 	deletingSource bool
 
+	// Pups provided by the source in the detail view
+	sourcePups        []sourcePupVersion
+	selectedSourcePup int
+	loadingSourcePups bool
+	sourcePupsErr     string
+	sourceStatus      string
+	pendingInstall    *sourcePupVersion // waiting on the password to install
+
 	// Store template name for use in templating
 	selectedTemplateName string
+
+	// Template variable prompts, one at a time
+	tplVariables []devtemplate.Variable
+	tplValues    map[string]string
+	tplVarIdx    int
+	tplVarInput  string
+	tplVarErr    string
 }
 
 // Init performs initial setup and returns a command to check dogeboxd connection
@@ -80,18 +121,26 @@ func (m model) Init() tea.Cmd {
 	return tea.Batch(
 		checkBootstrapCmd(m.socketPath),
 		fetchPupsCmd(),
+		fetchJobMetricsCmd(),
+		fetchUpdatesCmd(),
 		tea.Tick(time.Second, func(t time.Time) tea.Msg { return tickMsg(t) }),
 	)
 }
 
+const (
+	// How often the status bar's job metrics and update check are refreshed, in ticks.
+	jobMetricsInterval = 5
+	updatesInterval    = 600
+)
+
 // refreshMetrics fetches CPU & memory metrics immediately.
 func (m *model) refreshMetrics() {
 	if cpus, _ := cpu.PercentWithContext(context.Background(), 0, false); len(cpus) > 0 {
 		m.cpuPercent = cpus[0]
 	}
 	if v, _ := mem.VirtualMemory(); v != nil {
-		m.memUsed = v.Used / (1024 * 1024)
-		m.memTotal = v.Total / (1024 * 1024)
+		m.memUsed = v.Used
+		m.memTotal = v.Total
 	}
 }
 
@@ -107,7 +156,8 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		isInputMode := m.searching ||
 			(m.view == viewNameInput && !m.cloning) ||
 			(m.view == viewPasswordInput && !m.authenticating) ||
-			m.view == viewSourceCreate
+			m.view == viewSourceCreate ||
+			m.view == viewTemplateVariables
 
 		// Handle special keys that work in all modes
 		switch msg.String() {
@@ -122,6 +172,12 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				} else {
 					m.view = viewLanding
 				}
+			} else if m.view == viewProviderSelect {
+				m.view = viewPupDetail
+			} else if m.view == viewPasswordInput && m.pendingInstall != nil && !m.authenticating {
+				// Back to the source the install was picked from
+				m.pendingInstall = nil
+				m.view = viewSourceDetail
 			} else if m.view == viewPupDetail || m.view == viewCreatePup || m.view == viewTemplateSelect || m.view == viewNameInput || m.view == viewPasswordInput {
 				// Clear auth token if canceling create flow
 				if m.view == viewTemplateSelect || m.view == viewNameInput || m.view == viewPasswordInput {
@@ -134,6 +190,13 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.view = viewSourceList
 			} else if m.view == viewSourceDetail && !m.deletingSource {
 				m.view = viewSourceList
+			} else if m.view == viewTemplateVariables {
+				// Give up on templating, leaving the clone for the user to look at
+				m.tasks[1].Status = taskFailed
+				m.tasks[1].Error = "Cancelled"
+				m.allTasksDone = true
+				m.view = viewTaskProgress
+				return m, nil
 			} else if m.view == viewTaskProgress && m.allTasksDone {
 				// Only allow escape when all tasks are done
 				m.view = viewLanding
@@ -155,6 +218,14 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			} else if m.searching {
 				m.searching = false
 				m.searchQuery = ""
+				m.selected = 0
+			} else if m.view == viewLanding && m.searchQuery != "" {
+				// Clear a filter kept after searching
+				m.searchQuery = ""
+				m.selected = 0
+			} else if m.view == viewLanding && len(m.marked) > 0 {
+				m.marked = nil
+				m.bulkStatus = ""
 			}
 		}
 
@@ -199,7 +270,8 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			switch msg.String() {
 			case "enter":
 				if m.searching {
-					// Search mode stays active after enter
+					// Stop typing but keep the filter, so the matches can be marked
+					m.searching = false
 				} else if m.view == viewNameInput && m.pupName != "" {
 					// Validate name with new stricter rules
 					if len(m.pupName) < 3 {
@@ -220,6 +292,27 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					// Create source with the URL
 					m.creatingSource = true
 					return m, createSourceCmd(m.sourceInput)
+				} else if m.view == viewTemplateVariables {
+					v := m.tplVariables[m.tplVarIdx]
+					value := m.tplVarInput
+					if value == "" {
+						value = v.Default
+					}
+					if value == "" {
+						m.tplVarErr = "A value is required"
+					} else if err := v.Check(value); err != nil {
+						m.tplVarErr = err.Error()
+					} else {
+						m.tplValues[v.Name] = value
+						m.tplVarIdx++
+						m.tplVarInput = ""
+						m.tplVarErr = ""
+						if m.tplVarIdx == len(m.tplVariables) {
+							// All answered, back to templating
+							m.view = viewTaskProgress
+							return m, templateFilesCmd(m.pupName, m.selectedTemplateName, m.tplValues)
+						}
+					}
 				}
 			default:
 				// Handle text input for each mode
@@ -232,6 +325,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					case tea.KeyRunes:
 						m.searchQuery += msg.String()
 					}
+					m.selected = 0
 				} else if m.view == viewNameInput && !m.cloning {
 					switch msg.Type {
 					case tea.KeyBackspace, tea.KeyDelete:
@@ -263,6 +357,17 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					case tea.KeyRunes:
 						m.sourceInput += msg.String()
 					}
+				} else if m.view == viewTemplateVariables {
+					switch msg.Type {
+					case tea.KeyBackspace, tea.KeyDelete:
+						if len(m.tplVarInput) > 0 {
+							m.tplVarInput = m.tplVarInput[:len(m.tplVarInput)-1]
+							m.tplVarErr = ""
+						}
+					case tea.KeyRunes, tea.KeySpace:
+						m.tplVarInput += msg.String()
+						m.tplVarErr = ""
+					}
 				}
 			}
 			// Don't process action keys when in input mode
@@ -272,32 +377,52 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// Now handle action keys (only when NOT in input mode)
 		switch msg.String() {
 		case "up", "k":
-			if m.view == viewLanding && len(m.pups) > 0 {
-				m.selected = (m.selected - 1 + len(m.pups)) % len(m.pups)
+			if pups := m.filteredPups(); m.view == viewLanding && len(pups) > 0 {
+				m.selected = (m.selected - 1 + len(pups)) % len(pups)
 			} else if m.view == viewPupDetail {
-				m.selDetail = (m.selDetail - 1 + detailActionsCount) % detailActionsCount
+				count := detailFixedActions + len(m.pupDeps)
+				m.selDetail = (m.selDetail - 1 + count) % count
 			} else if m.view == viewTemplateSelect && len(m.templates) > 0 {
 				m.selectedTpl = (m.selectedTpl - 1 + len(m.templates)) % len(m.templates)
 			} else if m.view == viewSourceList && len(m.sources) > 0 {
 				m.selectedSource = (m.selectedSource - 1 + len(m.sources)) % len(m.sources)
+			} else if m.view == viewSourceDetail && len(m.sourcePups) > 0 {
+				m.selectedSourcePup = (m.selectedSourcePup - 1 + len(m.sourcePups)) % len(m.sourcePups)
+			} else if providers := m.providerChoices(); m.view == viewProviderSelect && len(providers) > 0 {
+				m.selProvider = (m.selProvider - 1 + len(providers)) % len(providers)
 			}
 		case "down", "j":
-			if m.view == viewLanding && len(m.pups) > 0 {
-				m.selected = (m.selected + 1) % len(m.pups)
+			if pups := m.filteredPups(); m.view == viewLanding && len(pups) > 0 {
+				m.selected = (m.selected + 1) % len(pups)
 			} else if m.view == viewPupDetail {
-				m.selDetail = (m.selDetail + 1) % detailActionsCount
+				m.selDetail = (m.selDetail + 1) % (detailFixedActions + len(m.pupDeps))
 			} else if m.view == viewTemplateSelect && len(m.templates) > 0 {
 				m.selectedTpl = (m.selectedTpl + 1) % len(m.templates)
 			} else if m.view == viewSourceList && len(m.sources) > 0 {
 				m.selectedSource = (m.selectedSource + 1) % len(m.sources)
+			} else if m.view == viewSourceDetail && len(m.sourcePups) > 0 {
+				m.selectedSourcePup = (m.selectedSourcePup + 1) % len(m.sourcePups)
+			} else if providers := m.providerChoices(); m.view == viewProviderSelect && len(providers) > 0 {
+				m.selProvider = (m.selProvider + 1) % len(providers)
 			}
 		case "enter", "l":
-			if m.view == viewLanding && len(m.pups) > 0 {
+			if pups := m.filteredPups(); m.view == viewLanding && m.selected < len(pups) {
 				m.view = viewPupDetail
-				m.detail = m.pups[m.selected]
+				m.detail = pups[m.selected]
 				m.selDetail = 0
+				m.pupDeps = nil
+				m.pupDepsErr = ""
+				m.loadingDeps = true
+				m.detailStatus = ""
+				return m, fetchPupDepsCmd(m.detail.ID)
 			} else if m.view == viewSourceList && len(m.sources) > 0 {
 				m.view = viewSourceDetail
+				m.sourcePups = nil
+				m.selectedSourcePup = 0
+				m.loadingSourcePups = true
+				m.sourcePupsErr = ""
+				m.sourceStatus = ""
+				return m, tea.Batch(fetchSourcePupsCmd(m.sources[m.selectedSource].ID), fetchPupsCmd())
 			} else if m.view == viewPupDetail {
 				switch m.selDetail {
 				case 0:
@@ -313,7 +438,25 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 						act = "enable"
 					}
 					return m, tea.Batch(pupActionCmd(m.detail.ID, act), fetchPupsCmd())
+				default:
+					dep := m.pupDeps[m.selDetail-detailFixedActions]
+					if len(dep.InstalledProviders) == 0 {
+						m.detailStatus = fmt.Sprintf("No installed pup provides %s", dep.Interface)
+					} else {
+						m.view = viewProviderSelect
+						m.providerIface = dep.Interface
+						m.selProvider = 0
+						for i, id := range m.providerChoices() {
+							if id == dep.CurrentProvider {
+								m.selProvider = i
+							}
+						}
+					}
 				}
+			} else if providers := m.providerChoices(); m.view == viewProviderSelect && m.selProvider < len(providers) {
+				m.view = viewPupDetail
+				m.detailStatus = fmt.Sprintf("Setting provider of %s...", m.providerIface)
+				return m, updateProviderCmd(m.detail.ID, m.providerIface, providers[m.selProvider])
 			} else if m.view == viewTemplateSelect && len(m.templates) > 0 {
 				// Move to name input
 				m.view = viewNameInput
@@ -351,18 +494,80 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m, fetchSourcesCmd()
 			}
 		case "d":
-			if m.view == viewLanding && len(m.pups) > 0 && m.pups[m.selected].DevAvailable {
+			if pups := m.filteredPups(); m.view == viewLanding && m.selected < len(pups) && pups[m.selected].DevAvailable {
 				mode := "enable"
-				if m.pups[m.selected].DevEnabled {
+				if pups[m.selected].DevEnabled {
 					mode = "disable"
 				}
-				return m, tea.Batch(pupActionCmd(m.pups[m.selected].ID, "dev-mode-"+mode), fetchPupsCmd())
+				return m, tea.Batch(pupActionCmd(pups[m.selected].ID, "dev-mode-"+mode), fetchPupsCmd())
 			} else if m.view == viewSourceDetail && m.selectedSource < len(m.sources) && !m.deletingSource {
 				// Delete the selected source
 				source := m.sources[m.selectedSource]
 				m.deletingSource = true
 				return m, deleteSourceCmd(source.ID)
 			}
+		case "i":
+			if m.view == viewSourceDetail && m.selectedSourcePup < len(m.sourcePups) && !m.deletingSource {
+				pup := m.sourcePups[m.selectedSourcePup]
+				if m.sourcePupInstallState(pup) != "" {
+					m.sourceStatus = fmt.Sprintf("%s %s is already installed", pup.Name, pup.Version)
+				} else if !pup.Compatible {
+					m.sourceStatus = fmt.Sprintf("%s %s can't be installed on this Dogebox", pup.Name, pup.Version)
+				} else if m.authToken == "" {
+					// Installing needs a session, ask for the password first
+					m.pendingInstall = &pup
+					m.password = ""
+					m.passwordErr = ""
+					m.view = viewPasswordInput
+				} else {
+					m.sourceStatus = fmt.Sprintf("Installing %s %s...", pup.Name, pup.Version)
+					return m, installSourcePupCmd(m.sources[m.selectedSource].ID, pup, m.authToken)
+				}
+			}
+		case " ":
+			if pups := m.filteredPups(); m.view == viewLanding && m.selected < len(pups) {
+				if m.marked == nil {
+					m.marked = map[string]bool{}
+				}
+				id := pups[m.selected].ID
+				if m.marked[id] {
+					delete(m.marked, id)
+				} else {
+					m.marked[id] = true
+				}
+			}
+		case "a":
+			if pups := m.filteredPups(); m.view == viewLanding && len(pups) > 0 {
+				// Mark every pup shown, or unmark them if they already all are
+				allMarked := true
+				for _, p := range pups {
+					if !m.marked[p.ID] {
+						allMarked = false
+						break
+					}
+				}
+				if m.marked == nil {
+					m.marked = map[string]bool{}
+				}
+				for _, p := range pups {
+					if allMarked {
+						delete(m.marked, p.ID)
+					} else {
+						m.marked[p.ID] = true
+					}
+				}
+			}
+		case "E", "D", "U":
+			if m.view == viewLanding && len(m.marked) > 0 {
+				operation := map[string]string{"E": "enable", "D": "disable", "U": "upgrade"}[msg.String()]
+				ids := make([]string, 0, len(m.marked))
+				for id := range m.marked {
+					ids = append(ids, id)
+				}
+				sort.Strings(ids)
+				m.bulkStatus = fmt.Sprintf("Submitting %s of %d pups...", operation, len(ids))
+				return m, bulkPupActionCmd(operation, ids)
+			}
 		case "/":
 			if m.view == viewLanding {
 				m.searching = true
@@ -401,6 +606,14 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			tea.Tick(time.Second, func(t time.Time) tea.Msg { return tickMsg(t) }),
 		}
 
+		m.ticks++
+		if m.ticks%jobMetricsInterval == 0 {
+			cmds = append(cmds, fetchJobMetricsCmd())
+		}
+		if m.ticks%updatesInterval == 0 {
+			cmds = append(cmds, fetchUpdatesCmd())
+		}
+
 		// Add a faster tick for spinner animation when tasks are running
 		if hasRunningTasks {
 			cmds = append(cmds, tea.Tick(100*time.Millisecond, func(t time.Time) tea.Msg {
@@ -410,11 +623,76 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		return m, tea.Batch(cmds...)
 
+	case jobMetricsMsg:
+		m.jobMetricsErr = msg.err != nil
+		if msg.err == nil {
+			m.jobsRunning = msg.running
+			m.jobsQueued = msg.queued
+			m.lastJob = msg.lastJob
+			m.lastJobStatus = msg.lastJobStatus
+		}
+		return m, nil
+	case updatesMsg:
+		if msg.err == nil {
+			m.pupUpdates = msg.pupUpdates
+			m.systemUpdate = msg.systemUpdate
+		}
+		return m, nil
 	case pupsMsg:
 		if msg.err == nil {
 			m.pups = msg.list
+			// Keep the detail view current
+			for _, p := range m.pups {
+				if p.ID == m.detail.ID {
+					m.detail = p
+				}
+			}
+			// Drop marks on pups that have gone
+			present := map[string]bool{}
+			for _, p := range m.pups {
+				present[p.ID] = true
+			}
+			for id := range m.marked {
+				if !present[id] {
+					delete(m.marked, id)
+				}
+			}
 		}
 		return m, nil
+	case pupDepsMsg:
+		if msg.pupID != m.detail.ID {
+			return m, nil
+		}
+		m.loadingDeps = false
+		if msg.err != nil {
+			m.pupDepsErr = msg.err.Error()
+		} else {
+			m.pupDeps = msg.deps
+		}
+		if m.selDetail >= detailFixedActions+len(m.pupDeps) {
+			m.selDetail = 0
+		}
+		return m, nil
+	case providerUpdatedMsg:
+		if msg.err != nil {
+			m.detailStatus = fmt.Sprintf("Failed to set provider of %s: %v", msg.iface, msg.err)
+			return m, nil
+		}
+		m.detailStatus = fmt.Sprintf("Provider of %s set to %s (job %s)", msg.iface, m.installedPupName(msg.provider), msg.jobID)
+		for i := range m.pupDeps {
+			if m.pupDeps[i].Interface == msg.iface {
+				m.pupDeps[i].CurrentProvider = msg.provider
+			}
+		}
+		return m, fetchPupsCmd()
+	case bulkActionMsg:
+		if msg.err != nil {
+			m.bulkStatus = fmt.Sprintf("Bulk %s failed: %v", msg.operation, msg.err)
+			return m, nil
+		}
+		m.bulkStatus = fmt.Sprintf("Bulk %s of %d pups queued (job %s)", msg.operation, msg.count, msg.jobID)
+		m.marked = nil
+		return m, fetchPupsCmd()
 	case sourcesMsg:
 		if msg.err == nil {
 			m.sources = msg.sources
@@ -423,6 +701,28 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 		return m, nil
+	case sourcePupsMsg:
+		// Ignore listings for a source we've since left
+		if m.view != viewSourceDetail || m.selectedSource >= len(m.sources) || m.sources[m.selectedSource].ID != msg.sourceID {
+			return m, nil
+		}
+		m.loadingSourcePups = false
+		if msg.err != nil {
+			m.sourcePupsErr = msg.err.Error()
+		} else {
+			m.sourcePups = msg.pups
+			if m.selectedSourcePup >= len(m.sourcePups) {
+				m.selectedSourcePup = 0
+			}
+		}
+		return m, nil
+	case sourcePupInstallMsg:
+		if msg.err != nil {
+			m.sourceStatus = fmt.Sprintf("Failed to install %s %s: %v", msg.pup.Name, msg.pup.Version, msg.err)
+			return m, nil
+		}
+		m.sourceStatus = fmt.Sprintf("Install of %s %s queued (job %s)", msg.pup.Name, msg.pup.Version, msg.jobID)
+		return m, fetchPupsCmd()
 	case sourceCreatedMsg:
 		m.creatingSource = false
 		if msg.err == nil {
@@ -478,10 +778,10 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.tasks[0].Status = taskSuccess
 				m.taskLogs = append(m.taskLogs, "Template cloned successfully")
 
-				// Start templating task
+				// Start templating task, asking for any variables the template has first
 				if len(m.tasks) > 1 {
 					m.tasks[1].Status = taskRunning
-					return m, templateFilesCmd(m.pupName, m.selectedTemplateName)
+					return m, loadTemplateVariablesCmd(m.pupName)
 				}
 			}
 		}
@@ -491,6 +791,14 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.passwordErr = msg.err.Error()
 		} else {
 			m.authToken = msg.token
+			// If we were asked for the password to install a pup from a source
+			if m.pendingInstall != nil && m.selectedSource < len(m.sources) {
+				pup := *m.pendingInstall
+				m.pendingInstall = nil
+				m.view = viewSourceDetail
+				m.sourceStatus = fmt.Sprintf("Installing %s %s...", pup.Name, pup.Version)
+				return m, installSourcePupCmd(m.sources[m.selectedSource].ID, pup, m.authToken)
+			}
 			// If we're in the create pup flow (haven't selected a template yet)
 			if m.templates == nil && m.pupName == "" {
 				// Move to template selection
@@ -571,6 +879,23 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 			}
 		}
+	case templateVariablesMsg:
+		if m.view == viewTaskProgress && len(m.tasks) > 1 {
+			if msg.err != nil {
+				m.tasks[1].Status = taskFailed
+				m.tasks[1].Error = msg.err.Error()
+				m.allTasksDone = true
+			} else if len(msg.variables) == 0 {
+				return m, templateFilesCmd(m.pupName, m.selectedTemplateName, nil)
+			} else {
+				m.tplVariables = msg.variables
+				m.tplValues = map[string]string{}
+				m.tplVarIdx = 0
+				m.tplVarInput = ""
+				m.tplVarErr = ""
+				m.view = viewTemplateVariables
+			}
+		}
 	case templateCompleteMsg:
 		if m.view == viewTaskProgress && len(m.tasks) > 1 {
 			if msg.err != nil {
@@ -676,6 +1001,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.view = viewSetupRequired
 		} else {
 			// Connection successful and configuration complete
+			m.units = msg.units
 			if m.view == viewConnectionError || m.view == viewSetupRequired {
 				m.view = viewLanding
 			}
@@ -806,3 +1132,65 @@ func startRebuildCmd() tea.Cmd {
 		return nil
 	}
 }
+
+// sourcePupInstallState describes how a pup version from the selected
+// source is installed, empty if it isn't.
+func (m model) sourcePupInstallState(pup sourcePupVersion) string {
+	if m.selectedSource >= len(m.sources) {
+		return ""
+	}
+	sourceID := m.sources[m.selectedSource].ID
+	for _, p := range m.pups {
+		if p.Name == pup.Name && p.SourceID == sourceID && p.Version == pup.Version {
+			return p.State
+		}
+	}
+	return ""
+}
+
+// sourcePupOtherVersion is the version of a pup from the selected source
+// that's installed when it isn't this one, empty if none is.
+func (m model) sourcePupOtherVersion(pup sourcePupVersion) string {
+	if m.selectedSource >= len(m.sources) {
+		return ""
+	}
+	sourceID := m.sources[m.selectedSource].ID
+	for _, p := range m.pups {
+		if p.Name == pup.Name && p.SourceID == sourceID && p.Version != pup.Version {
+			return p.Version
+		}
+	}
+	return ""
+}
+
+// filteredPups is the pups the landing list shows, those matching the search.
+func (m model) filteredPups() []pupInfo {
+	filtered := []pupInfo{}
+	for _, p := range m.pups {
+		if m.searchQuery == "" || strings.Contains(strings.ToLower(p.Name), strings.ToLower(m.searchQuery)) {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered
+}
+
+// providerChoices is the installed pups that can provide the dependency
+// being picked in the provider select view.
+func (m model) providerChoices() []string {
+	for _, dep := range m.pupDeps {
+		if dep.Interface == m.providerIface {
+			return dep.InstalledProviders
+		}
+	}
+	return nil
+}
+
+// installedPupName is the name of an installed pup, or its ID if it isn't known.
+func (m model) installedPupName(id string) string {
+	for _, p := range m.pups {
+		if p.ID == id {
+			return p.Name
+		}
+	}
+	return id
+}
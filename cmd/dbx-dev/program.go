@@ -1,6 +1,8 @@
 package dbxdev
 
 import (
+	"fmt"
+
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/ssh"
 )
@@ -31,3 +33,19 @@ func WishHandler(s ssh.Session) (tea.Model, []tea.ProgramOption) {
 	// This is typically done in the main function that calls Start()
 	return NewModel(), ProgramOptions()
 }
+
+// CheckSSHAllowed asks dogeboxd whether the box's profile lets dbx-ssh
+// in, production boxes don't.
+func CheckSSHAllowed() error {
+	var profile struct {
+		Profile     string `json:"profile"`
+		AllowDbxSSH bool   `json:"allowDbxSSH"`
+	}
+	if err := getJSON(getSocketClient(), "http://dogeboxd/system/profile", &profile); err != nil {
+		return fmt.Errorf("couldn't check the box's profile: %w", err)
+	}
+	if !profile.AllowDbxSSH {
+		return fmt.Errorf("dbx-ssh is disabled on %s boxes", profile.Profile)
+	}
+	return nil
+}
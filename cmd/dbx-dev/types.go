@@ -1,6 +1,10 @@
 package dbxdev
 
-import "time"
+import (
+	"time"
+
+	"github.com/Dogebox-WG/dogeboxd/pkg/devtemplate"
+)
 
 // tickMsg is emitted every second to refresh metrics.
 type tickMsg time.Time
@@ -9,11 +13,65 @@ type tickMsg time.Time
 type pupInfo struct {
 	ID           string
 	Name         string
+	Version      string
+	SourceID     string
+	SourceName   string
 	State        string
 	Enabled      bool
 	Error        string
 	DevEnabled   bool
 	DevAvailable bool
+	IP           string
+	Ports        []pupPort
+	Issues       []string // outstanding PupIssues, as lines to show
+}
+
+// pupPort is a port a pup exposes
+type pupPort struct {
+	Name         string
+	Type         string
+	Port         int
+	ListenOnHost bool
+}
+
+// pupDependency is an interface a pup depends on, and what provides it
+type pupDependency struct {
+	Interface          string   `json:"interface"`
+	Version            string   `json:"version"`
+	Optional           bool     `json:"optional"`
+	CurrentProvider    string   `json:"currentProvider"`
+	InstalledProviders []string `json:"installedProviders"`
+}
+
+// pupDepsMsg is returned by fetchPupDepsCmd
+type pupDepsMsg struct {
+	pupID string
+	deps  []pupDependency
+	err   error
+}
+
+// providerUpdatedMsg is returned by updateProviderCmd
+type providerUpdatedMsg struct {
+	iface    string
+	provider string
+	jobID    string
+	err      error
+}
+
+// jobMetricsMsg is returned by fetchJobMetricsCmd
+type jobMetricsMsg struct {
+	running       int
+	queued        int
+	lastJob       string
+	lastJobStatus string
+	err           error
+}
+
+// updatesMsg is returned by fetchUpdatesCmd
+type updatesMsg struct {
+	pupUpdates   int
+	systemUpdate string // the newest Dogebox release, if there's one
+	err          error
 }
 
 // pupsMsg is returned by fetchPupsCmd.
@@ -22,6 +80,14 @@ type pupsMsg struct {
 	err  error
 }
 
+// bulkActionMsg is returned by bulkPupActionCmd
+type bulkActionMsg struct {
+	operation string
+	count     int
+	jobID     string
+	err       error
+}
+
 // logLineMsg carries a single log line.
 type logLineMsg string
 
@@ -43,12 +109,16 @@ const (
 	viewSourceCreate
 	viewSourceDetail
 	viewSetupRequired
+	viewTemplateVariables
+	viewProviderSelect
 )
 
 // rebuildFinishedMsg signals when rebuild completes
 type rebuildFinishedMsg struct{}
 
-const detailActionsCount = 2 // currently View Logs and Enable/Disable
+// detailFixedActions are View Logs and Enable/Disable, followed by a
+// change provider action for each dependency
+const detailFixedActions = 2
 
 // templateInfo describes a pup template from the repository
 type templateInfo struct {
@@ -142,6 +212,7 @@ type bootstrapCheckMsg struct {
 	socketPath            string
 	err                   error
 	configurationComplete bool
+	units                 string // the user's preferred size units
 }
 
 // sourceInfo holds information about a single source
@@ -159,6 +230,28 @@ type sourcesMsg struct {
 	err     error
 }
 
+// sourcePupVersion is one version of a pup provided by a source
+type sourcePupVersion struct {
+	Name       string
+	Version    string
+	Latest     bool
+	Compatible bool
+}
+
+// sourcePupsMsg is returned by fetchSourcePupsCmd
+type sourcePupsMsg struct {
+	sourceID string
+	pups     []sourcePupVersion
+	err      error
+}
+
+// sourcePupInstallMsg is returned by installSourcePupCmd
+type sourcePupInstallMsg struct {
+	pup   sourcePupVersion
+	jobID string
+	err   error
+}
+
 // sourceCreatedMsg is returned when a source is created
 type sourceCreatedMsg struct {
 	err error
@@ -174,6 +267,12 @@ type templateCompleteMsg struct {
 	err error
 }
 
+// templateVariablesMsg is returned by loadTemplateVariablesCmd
+type templateVariablesMsg struct {
+	variables []devtemplate.Variable
+	err       error
+}
+
 // manifestUpdateMsg signals when manifest hash update is done
 type manifestUpdateMsg struct {
 	err error
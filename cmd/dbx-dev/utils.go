@@ -2,6 +2,7 @@ package dbxdev
 
 import (
 	"context"
+	"fmt"
 	"net"
 	"net/http"
 	"os"
@@ -46,3 +47,25 @@ func getSocketConn() (net.Conn, error) {
 	socketPath := getSocketPath()
 	return net.Dial("unix", socketPath)
 }
+
+// formatBytes formats bytes in the user's preferred units, "decimal"
+// meaning powers of 1000 and anything else powers of 1024.
+func formatBytes(bytes uint64, units string) string {
+	unit, suffix := uint64(1024), "iB"
+	if units == "decimal" {
+		unit, suffix = 1000, "B"
+	}
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := unit, 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	prefix := string("KMGTPE"[exp])
+	if unit == 1000 && exp == 0 {
+		prefix = "k"
+	}
+	return fmt.Sprintf("%.1f %s%s", float64(bytes)/float64(div), prefix, suffix)
+}
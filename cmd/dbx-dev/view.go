@@ -120,6 +120,8 @@ func (m model) View() string {
 		return m.renderTemplateSelectView()
 	case viewNameInput:
 		return m.renderNameInputView()
+	case viewTemplateVariables:
+		return m.renderTemplateVariablesView()
 	case viewPasswordInput:
 		return m.renderPasswordInputView()
 	case viewTaskProgress:
@@ -130,24 +132,82 @@ func (m model) View() string {
 		return m.renderSourceCreateView()
 	case viewSourceDetail:
 		return m.renderSourceDetailView()
+	case viewProviderSelect:
+		return m.renderProviderSelectView()
 	default:
 		return m.renderLandingView()
 	}
 }
 
+// metrics is the CPU, memory, job and update line shown in each view's
+// status bar.
+func (m model) metrics() string {
+	parts := []string{
+		fmt.Sprintf("CPU %.0f%%  Mem %s/%s", m.cpuPercent, formatBytes(m.memUsed, m.units), formatBytes(m.memTotal, m.units)),
+	}
+
+	if m.jobMetricsErr {
+		parts = append(parts, "Jobs ?")
+	} else {
+		parts = append(parts, fmt.Sprintf("Jobs %d running, %d queued", m.jobsRunning, m.jobsQueued))
+	}
+	if m.lastJob != "" {
+		parts = append(parts, fmt.Sprintf("Last %s %s", lastJobSymbol(m.lastJobStatus), m.lastJob))
+	}
+
+	updates := []string{}
+	if m.systemUpdate != "" {
+		updates = append(updates, "Dogebox "+m.systemUpdate)
+	}
+	if m.pupUpdates == 1 {
+		updates = append(updates, "1 pup")
+	} else if m.pupUpdates > 1 {
+		updates = append(updates, fmt.Sprintf("%d pups", m.pupUpdates))
+	}
+	if len(updates) > 0 {
+		parts = append(parts, "⬆ "+strings.Join(updates, ", "))
+	}
+
+	return strings.Join(parts, "  |  ")
+}
+
+// lastJobSymbol marks how the last job to finish went.
+func lastJobSymbol(status string) string {
+	switch status {
+	case "completed":
+		return "✓"
+	case "failed", "orphaned":
+		return "✗"
+	default:
+		return "-"
+	}
+}
+
 // renderLandingView composes the main landing page.
 func (m model) renderLandingView() string {
 	headerLine := headerStyle.Render("Available Actions:")
-	actions := []string{"c: create pup", "s: search pups", "r: rebuild system", "u: sources"}
+	actions := []string{"c: create pup", "s: search pups", "r: rebuild system", "u: sources", "space: mark pup   a: mark all shown"}
+	if len(m.marked) > 0 {
+		actions = append(actions, fmt.Sprintf("E/D/U: enable/disable/upgrade %d marked", len(m.marked)))
+	}
 	actionsLine := strings.Join(actions, "\n")
+	extraLines := 0
 	if m.searching {
 		actionsLine += "\nSearch: " + m.searchQuery
+		extraLines++
+	} else if m.searchQuery != "" {
+		actionsLine += "\nFilter: " + m.searchQuery + dimStyle.Render("   (esc to clear)")
+		extraLines++
+	}
+	if m.bulkStatus != "" {
+		actionsLine += "\n" + m.bulkStatus
+		extraLines++
 	}
 
 	body := m.renderPups()
 
-	metrics := fmt.Sprintf("CPU %.0f%%  Mem %d/%dMB", m.cpuPercent, m.memUsed, m.memTotal)
-	helpText := "q: quit   c: create   s: search   r: rebuild   u: sources   ↑/↓: select   enter: details"
+	metrics := m.metrics()
+	helpText := "q: quit   c: create   s: search   r: rebuild   u: sources   ↑/↓: select   space: mark   enter: details"
 	if m.searching {
 		helpText = "esc: cancel   type to search"
 	}
@@ -155,10 +215,7 @@ func (m model) renderLandingView() string {
 
 	banner, bannerLines := buildBannerWithVersion()
 
-	headLines := bannerLines + 2 + 1 + len(actions) + 2
-	if m.searching {
-		headLines++
-	}
+	headLines := bannerLines + 2 + 1 + len(actions) + extraLines + 2
 	bodyLines := strings.Count(body, "\n") + 1
 	totalLines := headLines + bodyLines + 1
 
@@ -182,6 +239,9 @@ func (m model) renderPupDetailView() string {
 	} else {
 		actions = append(actions, "Enable pup")
 	}
+	for _, dep := range m.pupDeps {
+		actions = append(actions, "Change provider of "+dep.Interface)
+	}
 
 	// Render actions with selection markers
 	actLines := make([]string, len(actions))
@@ -195,9 +255,12 @@ func (m model) renderPupDetailView() string {
 	actionsBlock := strings.Join(actLines, "\n")
 
 	body := detailText + "\n\n" + actionsBlock
+	if m.detailStatus != "" {
+		body += "\n\n" + m.detailStatus
+	}
 
-	metrics := fmt.Sprintf("CPU %.0f%%  Mem %d/%dMB", m.cpuPercent, m.memUsed, m.memTotal)
-	help := statusBarStyle.Width(m.width - 1).Render(metrics + "  |  esc: back   q: quit")
+	metrics := m.metrics()
+	help := statusBarStyle.Width(m.width - 1).Render(metrics + "  |  ↑/↓: select   enter: run   esc: back   q: quit")
 
 	banner, bannerLines := buildBannerWithVersion()
 
@@ -218,7 +281,7 @@ func (m model) renderPupDetailView() string {
 func (m model) renderCreatePupView() string {
 	body := "Create Pup (coming soon...)"
 
-	metrics := fmt.Sprintf("CPU %.0f%%  Mem %d/%dMB", m.cpuPercent, m.memUsed, m.memTotal)
+	metrics := m.metrics()
 	help := statusBarStyle.Width(m.width - 1).Render(metrics + "  |  esc: back   q: quit")
 
 	banner, bannerLines := buildBannerWithVersion()
@@ -261,7 +324,7 @@ func (m model) renderLogsView() string {
 
 	logsBox := borderStyle.Width(m.width - 4).Render(bodyContent)
 
-	metrics := fmt.Sprintf("CPU %.0f%%  Mem %d/%dMB", m.cpuPercent, m.memUsed, m.memTotal)
+	metrics := m.metrics()
 	help := statusBarStyle.Width(m.width - 1).Render(metrics + "  |  esc: back   q: quit")
 
 	// recompute padding
@@ -304,7 +367,7 @@ func (m model) renderRebuildView() string {
 
 	logsBox := borderStyle.Width(m.width - 4).Render(bodyContent)
 
-	metrics := fmt.Sprintf("CPU %.0f%%  Mem %d/%dMB", m.cpuPercent, m.memUsed, m.memTotal)
+	metrics := m.metrics()
 	help := statusBarStyle.Width(m.width - 1).Render(metrics + "  |  esc: back   q: quit")
 
 	// recompute padding
@@ -320,12 +383,7 @@ func (m model) renderRebuildView() string {
 
 // renderPups creates the list view for pups.
 func (m model) renderPups() string {
-	filtered := []pupInfo{}
-	for _, p := range m.pups {
-		if m.searchQuery == "" || strings.Contains(strings.ToLower(p.Name), strings.ToLower(m.searchQuery)) {
-			filtered = append(filtered, p)
-		}
-	}
+	filtered := m.filteredPups()
 
 	if len(filtered) == 0 {
 		if m.searchQuery != "" {
@@ -368,7 +426,7 @@ func (m model) renderPups() string {
 		left := nameStyle.Render(p.Name)
 		right := statusStyled
 		checkbox := "[ ]"
-		if idx == m.selected {
+		if m.marked[p.ID] {
 			checkbox = "[x]"
 		}
 
@@ -396,7 +454,11 @@ func (m model) renderPups() string {
 		detailLine := lipgloss.NewStyle().Foreground(lipgloss.Color("244")).Render(detailLeft+strings.Repeat(" ", gap2)) + devLabel
 
 		boxContent := header + "\n" + detailLine
-		boxes = append(boxes, pupBoxStyle.Width(cardWidth).Render(boxContent))
+		boxStyle := pupBoxStyle
+		if idx == m.selected {
+			boxStyle = boxStyle.BorderForeground(lipgloss.Color("10"))
+		}
+		boxes = append(boxes, boxStyle.Width(cardWidth).Render(boxContent))
 	}
 
 	return strings.Join(boxes, "\n")
@@ -417,9 +479,84 @@ func (m model) renderDetail() string {
 	if p.Error != "" {
 		lines = append(lines, "Error: "+p.Error)
 	}
+	if p.Version != "" {
+		lines = append(lines, "Version: "+p.Version)
+	}
+	if p.SourceName != "" {
+		lines = append(lines, "Source: "+p.SourceName)
+	}
+	if p.IP != "" {
+		lines = append(lines, "IP: "+p.IP)
+	}
+	for _, port := range p.Ports {
+		line := fmt.Sprintf("Port: %d %s (%s)", port.Port, port.Type, port.Name)
+		if port.ListenOnHost {
+			line += " on host"
+		}
+		lines = append(lines, line)
+	}
+
+	lines = append(lines, "", headerStyle.Render("Dependencies:"))
+	if m.loadingDeps {
+		lines = append(lines, dimStyle.Render("Loading..."))
+	} else if m.pupDepsErr != "" {
+		lines = append(lines, lipgloss.NewStyle().Foreground(lipgloss.Color("9")).Render("Error: "+m.pupDepsErr))
+	} else if len(m.pupDeps) == 0 {
+		lines = append(lines, dimStyle.Render("None"))
+	}
+	for _, dep := range m.pupDeps {
+		provider := lipgloss.NewStyle().Foreground(lipgloss.Color("9")).Render("no provider")
+		if dep.CurrentProvider != "" {
+			provider = m.installedPupName(dep.CurrentProvider)
+		} else if dep.Optional {
+			provider = dimStyle.Render("none (optional)")
+		}
+		lines = append(lines, fmt.Sprintf("%s %s → %s", dep.Interface, dimStyle.Render(dep.Version), provider))
+	}
+
+	if len(p.Issues) > 0 {
+		lines = append(lines, "", headerStyle.Render("Issues:"))
+		for _, issue := range p.Issues {
+			lines = append(lines, lipgloss.NewStyle().Foreground(lipgloss.Color("11")).Render("• "+issue))
+		}
+	}
 	return strings.Join(lines, "\n")
 }
 
+// renderProviderSelectView lists the installed pups that can provide a dependency
+func (m model) renderProviderSelectView() string {
+	banner, bannerLines := buildBannerWithVersion()
+
+	title := headerStyle.Render(fmt.Sprintf("Select a provider of %s for %s:", m.providerIface, m.detail.Name))
+	var items []string
+	for i, id := range m.providerChoices() {
+		prefix := "  "
+		if i == m.selProvider {
+			prefix = "> "
+		}
+
+		name := prefix + m.installedPupName(id)
+		if i == m.selProvider {
+			name = lipgloss.NewStyle().Foreground(lipgloss.Color("10")).Render(name)
+		}
+		items = append(items, name+" "+dimStyle.Render(id))
+	}
+	body := title + "\n\n" + strings.Join(items, "\n")
+
+	metrics := m.metrics()
+	help := statusBarStyle.Width(m.width - 1).Render(metrics + "  |  ↑/↓: select   enter: confirm   esc: cancel")
+
+	// Calculate padding
+	bodyLines := strings.Count(body, "\n") + 1
+	totalLines := bannerLines + 2 + bodyLines + 1
+	padding := ""
+	if totalLines < m.height {
+		padding = strings.Repeat("\n"+leftIndent, m.height-totalLines)
+	}
+
+	return indentLines(banner) + "\n\n" + indentLines(body) + padding + "\n" + indentLines(help)
+}
+
 // renderTemplateSelectView shows the list of available pup templates
 func (m model) renderTemplateSelectView() string {
 	banner, bannerLines := buildBannerWithVersion()
@@ -451,7 +588,7 @@ func (m model) renderTemplateSelectView() string {
 		body = title + "\n\n" + list
 	}
 
-	metrics := fmt.Sprintf("CPU %.0f%%  Mem %d/%dMB", m.cpuPercent, m.memUsed, m.memTotal)
+	metrics := m.metrics()
 	help := statusBarStyle.Width(m.width - 1).Render(metrics + "  |  ↑/↓: select   enter: confirm   esc: cancel")
 
 	// Calculate padding
@@ -496,7 +633,7 @@ func (m model) renderNameInputView() string {
 
 	body := title + "\n\n" + location + "\n\n" + prompt + errLine
 
-	metrics := fmt.Sprintf("CPU %.0f%%  Mem %d/%dMB", m.cpuPercent, m.memUsed, m.memTotal)
+	metrics := m.metrics()
 	helpText := "type name   enter: create   esc: cancel"
 	if m.cloning {
 		helpText = "cloning..."
@@ -514,12 +651,61 @@ func (m model) renderNameInputView() string {
 	return indentLines(banner) + "\n\n" + indentLines(body) + padding + "\n" + indentLines(help)
 }
 
+// renderTemplateVariablesView prompts for the template's variables, one at a time
+func (m model) renderTemplateVariablesView() string {
+	banner, bannerLines := buildBannerWithVersion()
+
+	v := m.tplVariables[m.tplVarIdx]
+	title := headerStyle.Render(fmt.Sprintf("Configuring %s (%d of %d)", m.pupName, m.tplVarIdx+1, len(m.tplVariables)))
+
+	label := v.Label
+	if label == "" {
+		label = v.Name
+	}
+	prompt := label + ": " + m.tplVarInput
+
+	dim := lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+	var details []string
+	if v.Description != "" {
+		details = append(details, v.Description)
+	}
+	if v.Default != "" {
+		details = append(details, fmt.Sprintf("Default: %s (enter to accept)", v.Default))
+	}
+
+	body := title + "\n\n"
+	if len(details) > 0 {
+		body += dim.Render(strings.Join(details, "\n")) + "\n\n"
+	}
+	body += prompt
+	if m.tplVarErr != "" {
+		body += "\n" + lipgloss.NewStyle().Foreground(lipgloss.Color("9")).Render("Error: "+m.tplVarErr)
+	}
+
+	metrics := m.metrics()
+	help := statusBarStyle.Width(m.width - 1).Render(metrics + "  |  type value   enter: next   esc: cancel")
+
+	// Calculate padding
+	bodyLines := strings.Count(body, "\n") + 1
+	totalLines := bannerLines + 2 + bodyLines + 1
+	padding := ""
+	if totalLines < m.height {
+		padding = strings.Repeat("\n"+leftIndent, m.height-totalLines)
+	}
+
+	return indentLines(banner) + "\n\n" + indentLines(body) + padding + "\n" + indentLines(help)
+}
+
 // renderPasswordInputView shows the password input screen
 func (m model) renderPasswordInputView() string {
 	banner, bannerLines := buildBannerWithVersion()
 
 	var title, subtitle string
-	if m.templates == nil && m.pupName == "" {
+	if m.pendingInstall != nil {
+		// Installing a pup picked from a source
+		title = headerStyle.Render("Install Pup")
+		subtitle = fmt.Sprintf("Enter your Dogebox password to install %s %s", m.pendingInstall.Name, m.pendingInstall.Version)
+	} else if m.templates == nil && m.pupName == "" {
 		// Initial authentication for create pup flow
 		title = headerStyle.Render("Create New Pup")
 		subtitle = "Enter your Dogebox password to continue"
@@ -547,7 +733,7 @@ func (m model) renderPasswordInputView() string {
 
 	body := title + "\n\n" + subtitle + "\n\n" + prompt + errLine
 
-	metrics := fmt.Sprintf("CPU %.0f%%  Mem %d/%dMB", m.cpuPercent, m.memUsed, m.memTotal)
+	metrics := m.metrics()
 	helpText := "type password   enter: authenticate   esc: cancel"
 	if m.authenticating {
 		helpText = "authenticating..."
@@ -638,7 +824,7 @@ func (m model) renderTaskProgressView() string {
 
 	logSection := logsTitle + "\n" + strings.Repeat("─", m.width-2) + "\n" + strings.Join(logsContent, "\n")
 
-	metrics := fmt.Sprintf("CPU %.0f%%  Mem %d/%dMB", m.cpuPercent, m.memUsed, m.memTotal)
+	metrics := m.metrics()
 	helpText := "please wait..."
 	if m.allTasksDone {
 		helpText = "esc: back to main"
@@ -847,9 +1033,48 @@ func (m model) renderSourceDetailView() string {
 		content.WriteString(leftIndent + "Type: " + source.Type + "\n")
 		content.WriteString(leftIndent + "Location: " + source.Location + "\n")
 		content.WriteString(leftIndent + "ID: " + dimStyle.Render(source.ID) + "\n")
+
+		content.WriteString("\n" + leftIndent + headerStyle.Render("Pups:") + "\n")
+		if m.loadingSourcePups {
+			content.WriteString(leftIndent + dimStyle.Render(" Loading pups...") + "\n")
+		} else if m.sourcePupsErr != "" {
+			content.WriteString(leftIndent + lipgloss.NewStyle().Foreground(lipgloss.Color("9")).Render(" Error: "+m.sourcePupsErr) + "\n")
+		} else if len(m.sourcePups) == 0 {
+			content.WriteString(leftIndent + dimStyle.Render(" No pups in this source") + "\n")
+		}
+		for i, pup := range m.sourcePups {
+			cursor := "  "
+			style := lipgloss.NewStyle()
+			if i == m.selectedSourcePup {
+				cursor = "→ "
+				style = lipgloss.NewStyle().Foreground(lipgloss.Color("10"))
+			}
+
+			line := style.Render(fmt.Sprintf("%s%s %s %s", leftIndent, cursor, pup.Name, pup.Version))
+			var badges []string
+			if pup.Latest {
+				badges = append(badges, dimStyle.Render("[latest]"))
+			}
+			if state := m.sourcePupInstallState(pup); state != "" {
+				badges = append(badges, lipgloss.NewStyle().Foreground(lipgloss.Color("10")).Render("["+state+"]"))
+			} else if other := m.sourcePupOtherVersion(pup); other != "" {
+				badges = append(badges, lipgloss.NewStyle().Foreground(lipgloss.Color("11")).Render("[v"+other+" installed]"))
+			}
+			if !pup.Compatible {
+				badges = append(badges, lipgloss.NewStyle().Foreground(lipgloss.Color("9")).Render("[incompatible]"))
+			}
+			if len(badges) > 0 {
+				line += " " + strings.Join(badges, " ")
+			}
+			content.WriteString(line + "\n")
+		}
+
+		if m.sourceStatus != "" {
+			content.WriteString("\n" + leftIndent + m.sourceStatus + "\n")
+		}
 	}
 
-	helpText := "d: delete   esc: back   q: quit"
+	helpText := "↑/↓: select pup   i: install   d: delete   esc: back   q: quit"
 	if m.deletingSource {
 		helpText = "deleting..."
 	}
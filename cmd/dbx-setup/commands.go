@@ -370,6 +370,9 @@ func finalizeSetupCmd(m setupModel) tea.Cmd {
 		// Step 4: Set storage device (if selected)
 		if m.storageDevice != "" {
 			payload := map[string]string{"storageDevice": m.storageDevice}
+			if m.storageMirror != "" {
+				payload["mirrorDevice"] = m.storageMirror
+			}
 			body, _ := json.Marshal(payload)
 
 			req, err := http.NewRequest(http.MethodPost, "http://dogeboxd/system/storage", bytes.NewReader(body))
@@ -402,7 +405,7 @@ func finalizeSetupCmd(m setupModel) tea.Cmd {
 
 		// Step 7: Set network (if selected)
 		if m.networkType != "" {
-			var networkPayload interface{}
+			var networkPayload map[string]interface{}
 
 			if m.networkType == "wifi" {
 				networkPayload = map[string]interface{}{
@@ -419,6 +422,21 @@ func finalizeSetupCmd(m setupModel) tea.Cmd {
 				}
 			}
 
+			if m.networkStatic {
+				networkPayload["static"] = map[string]interface{}{
+					"address": strings.TrimSpace(m.networkAddress),
+					"gateway": strings.TrimSpace(m.networkGateway),
+					"dns":     splitNetworkList(m.networkDNS),
+				}
+			}
+			if m.networkHTTPProxy != "" || m.networkHTTPSProxy != "" {
+				networkPayload["proxy"] = map[string]interface{}{
+					"http":    m.networkHTTPProxy,
+					"https":   m.networkHTTPSProxy,
+					"noProxy": strings.Join(splitNetworkList(m.networkNoProxy), ","),
+				}
+			}
+
 			body, _ := json.Marshal(networkPayload)
 
 			req, err := http.NewRequest(http.MethodPut, "http://dogeboxd/system/network/set-pending", bytes.NewReader(body))
@@ -431,10 +449,11 @@ func finalizeSetupCmd(m setupModel) tea.Cmd {
 			if err != nil {
 				return setupCompleteMsg{err: fmt.Errorf("failed to set network: %w", err)}
 			}
+			respBody, _ := io.ReadAll(resp.Body)
 			resp.Body.Close()
 
 			if resp.StatusCode != http.StatusOK {
-				return setupCompleteMsg{err: fmt.Errorf("failed to set network: status %d", resp.StatusCode)}
+				return setupCompleteMsg{err: fmt.Errorf("failed to set network: status %d: %s", resp.StatusCode, respBody)}
 			}
 		}
 
@@ -448,6 +467,7 @@ func finalizeSetupCmd(m setupModel) tea.Cmd {
 			"initialSSHKey":               "", // Empty for now
 			"useFoundationOSBinaryCache":  m.binaryCacheOS,
 			"useFoundationPupBinaryCache": m.binaryCachePups,
+			"initialPupCollection":        m.pupCollection,
 		}
 
 		body, err = json.Marshal(bootstrapPayload)
@@ -3,6 +3,7 @@ package dbxsetup
 import (
 	"fmt"
 	"math/rand"
+	"net"
 	"os"
 	"strconv"
 	"strings"
@@ -94,6 +95,10 @@ func (m setupModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m.handleNetworkInput(msg)
 		case stepNetworkPassword:
 			return m.handleNetworkPasswordInput(msg)
+		case stepNetworkOptions:
+			return m.handleNetworkOptionsInput(msg)
+		case stepPupCollection:
+			return m.handlePupCollectionInput(msg)
 		case stepComplete:
 			if msg.String() == "enter" || msg.String() == "q" {
 				return m, tea.Quit
@@ -266,6 +271,10 @@ func (m setupModel) View() string {
 		content = m.renderNetworkSelectStep()
 	case stepNetworkPassword:
 		content = m.renderNetworkPasswordStep()
+	case stepNetworkOptions:
+		content = m.renderNetworkOptionsStep()
+	case stepPupCollection:
+		content = m.renderPupCollectionStep()
 	case stepFinalizing:
 		content = m.renderFinalizingStep()
 	case stepComplete:
@@ -403,6 +412,7 @@ func (m setupModel) handleStorageDeviceInput(msg tea.KeyMsg) (tea.Model, tea.Cmd
 				}
 			}
 		}
+		m.dropInvalidStorageMirror()
 	case "down", "j":
 		if len(m.storageDevices) > 0 {
 			for i, device := range m.storageDevices {
@@ -412,12 +422,56 @@ func (m setupModel) handleStorageDeviceInput(msg tea.KeyMsg) (tea.Model, tea.Cmd
 				}
 			}
 		}
+		m.dropInvalidStorageMirror()
+	case "m":
+		// Cycle through the disks the storage device can be mirrored onto, then none.
+		candidates := m.storageMirrorCandidates()
+		next := ""
+		if m.storageMirror == "" {
+			if len(candidates) > 0 {
+				next = candidates[0].Name
+			}
+		} else {
+			for i, device := range candidates {
+				if device.Name == m.storageMirror && i < len(candidates)-1 {
+					next = candidates[i+1].Name
+					break
+				}
+			}
+		}
+		m.storageMirror = next
 	case "left", "esc":
 		m.currentStep = stepTimezone
 	}
 	return m, nil
 }
 
+// storageMirrorCandidates lists the disks the selected storage device can
+// be mirrored onto. The boot media can't be part of a mirror.
+func (m setupModel) storageMirrorCandidates() []storageDevice {
+	candidates := []storageDevice{}
+	for _, device := range m.storageDevices {
+		if device.Name == m.storageDevice && device.BootMedia {
+			return []storageDevice{}
+		}
+	}
+	for _, device := range m.storageDevices {
+		if device.Name != m.storageDevice && !device.BootMedia {
+			candidates = append(candidates, device)
+		}
+	}
+	return candidates
+}
+
+func (m *setupModel) dropInvalidStorageMirror() {
+	for _, device := range m.storageMirrorCandidates() {
+		if device.Name == m.storageMirror {
+			return
+		}
+	}
+	m.storageMirror = ""
+}
+
 func (m setupModel) handleBinaryCacheInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
 	case "enter":
@@ -561,15 +615,10 @@ func (m setupModel) handleNetworkInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 				m.networkPassword = ""
 				m.err = nil
 			} else {
-				// Ethernet or open WiFi - proceed to finalization
-				m.currentStep = stepFinalizing
-				m.isProcessing = true
-				m.setupStepsComplete = make([]bool, 8) // 8 steps in finalization
+				// Ethernet or open WiFi - proceed to the optional settings
+				m.currentStep = stepNetworkOptions
+				m.networkOptionIdx = 0
 				m.err = nil
-				return m, tea.Batch(
-					finalizeSetupCmd(m),
-					tea.Tick(100*time.Millisecond, func(t time.Time) tea.Msg { return tickMsg(t) }),
-				)
 			}
 		}
 	case "up", "k":
@@ -584,13 +633,8 @@ func (m setupModel) handleNetworkInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		// Skip network selection
 		m.selectedNetwork = ""
 		m.networkType = ""
-		m.currentStep = stepFinalizing
-		m.isProcessing = true
-		m.setupStepsComplete = make([]bool, 8) // 8 steps in finalization
-		return m, tea.Batch(
-			finalizeSetupCmd(m),
-			tea.Tick(100*time.Millisecond, func(t time.Time) tea.Msg { return tickMsg(t) }),
-		)
+		m.currentStep = stepPupCollection
+		m.err = nil
 	case "left", "esc":
 		m.currentStep = stepConfirmSeed
 	}
@@ -601,15 +645,10 @@ func (m setupModel) handleNetworkPasswordInput(msg tea.KeyMsg) (tea.Model, tea.C
 	switch msg.String() {
 	case "enter":
 		if m.networkPassword != "" {
-			// Proceed to finalization with password
-			m.currentStep = stepFinalizing
-			m.isProcessing = true
-			m.setupStepsComplete = make([]bool, 8) // 8 steps in finalization
+			// Proceed to the optional settings with password
+			m.currentStep = stepNetworkOptions
+			m.networkOptionIdx = 0
 			m.err = nil
-			return m, tea.Batch(
-				finalizeSetupCmd(m),
-				tea.Tick(100*time.Millisecond, func(t time.Time) tea.Msg { return tickMsg(t) }),
-			)
 		} else {
 			m.err = fmt.Errorf("password cannot be empty")
 		}
@@ -628,6 +667,166 @@ func (m setupModel) handleNetworkPasswordInput(msg tea.KeyMsg) (tea.Model, tea.C
 	return m, nil
 }
 
+func (m setupModel) handleNetworkOptionsInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		if err := m.validateNetworkOptions(); err != nil {
+			m.err = err
+			return m, nil
+		}
+		m.currentStep = stepPupCollection
+		m.err = nil
+	case "up", "shift+tab":
+		m.moveNetworkOption(-1)
+	case "down", "tab":
+		m.moveNetworkOption(1)
+	case "backspace":
+		if field := m.networkOptionField(networkOption(m.networkOptionIdx)); field != nil && len(*field) > 0 {
+			*field = (*field)[:len(*field)-1]
+		}
+	case "left", "esc":
+		if m.networkType == "wifi" && m.networkEncryption != "" && m.networkEncryption != "open" {
+			m.currentStep = stepNetworkPassword
+		} else {
+			m.currentStep = stepSelectNetwork
+		}
+		m.err = nil
+	default:
+		if networkOption(m.networkOptionIdx) == networkOptionStatic {
+			if msg.String() == " " {
+				m.networkStatic = !m.networkStatic
+			}
+		} else if field := m.networkOptionField(networkOption(m.networkOptionIdx)); field != nil && len(msg.String()) == 1 && msg.String() != " " {
+			*field += msg.String()
+		}
+	}
+	return m, nil
+}
+
+func (m setupModel) handlePupCollectionInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	idx := 0
+	for i, collection := range pupCollections {
+		if collection.Name == m.pupCollection {
+			idx = i
+		}
+	}
+
+	switch msg.String() {
+	case "enter":
+		m.currentStep = stepFinalizing
+		m.isProcessing = true
+		m.setupStepsComplete = make([]bool, 8) // 8 steps in finalization
+		m.err = nil
+		return m, tea.Batch(
+			finalizeSetupCmd(m),
+			tea.Tick(100*time.Millisecond, func(t time.Time) tea.Msg { return tickMsg(t) }),
+		)
+	case "up", "k":
+		if idx > 0 {
+			m.pupCollection = pupCollections[idx-1].Name
+		}
+	case "down", "j":
+		if idx < len(pupCollections)-1 {
+			m.pupCollection = pupCollections[idx+1].Name
+		}
+	case "left", "esc":
+		if m.networkType == "" {
+			m.currentStep = stepSelectNetwork
+		} else {
+			m.currentStep = stepNetworkOptions
+		}
+		m.err = nil
+	}
+	return m, nil
+}
+
+// networkOptionField returns the text field backing a network option, or
+// nil for the static IP toggle.
+func (m *setupModel) networkOptionField(option networkOption) *string {
+	switch option {
+	case networkOptionAddress:
+		return &m.networkAddress
+	case networkOptionGateway:
+		return &m.networkGateway
+	case networkOptionDNS:
+		return &m.networkDNS
+	case networkOptionHTTPProxy:
+		return &m.networkHTTPProxy
+	case networkOptionHTTPSProxy:
+		return &m.networkHTTPSProxy
+	case networkOptionNoProxy:
+		return &m.networkNoProxy
+	}
+	return nil
+}
+
+// networkOptionEnabled reports whether an option can be edited, the static
+// IP fields only apply when static IP is switched on.
+func (m setupModel) networkOptionEnabled(option networkOption) bool {
+	switch option {
+	case networkOptionAddress, networkOptionGateway, networkOptionDNS:
+		return m.networkStatic
+	}
+	return true
+}
+
+// moveNetworkOption moves the selection by delta, skipping disabled options.
+func (m *setupModel) moveNetworkOption(delta int) {
+	idx := m.networkOptionIdx
+	for {
+		idx += delta
+		if idx < 0 || idx >= int(networkOptionCount) {
+			return
+		}
+		if m.networkOptionEnabled(networkOption(idx)) {
+			m.networkOptionIdx = idx
+			return
+		}
+	}
+}
+
+// validateNetworkOptions catches obvious mistakes before setup is
+// finalised, dogeboxd validates the settings properly.
+func (m setupModel) validateNetworkOptions() error {
+	if m.networkStatic {
+		if _, _, err := net.ParseCIDR(strings.TrimSpace(m.networkAddress)); err != nil {
+			return fmt.Errorf("address must include a prefix length, ie. 192.168.1.20/24")
+		}
+		if net.ParseIP(strings.TrimSpace(m.networkGateway)) == nil {
+			return fmt.Errorf("gateway must be an IP address")
+		}
+		dns := splitNetworkList(m.networkDNS)
+		if len(dns) == 0 {
+			return fmt.Errorf("at least one DNS server is required")
+		}
+		for _, server := range dns {
+			if net.ParseIP(server) == nil {
+				return fmt.Errorf("DNS server %s must be an IP address", server)
+			}
+		}
+	}
+	for _, proxy := range []string{m.networkHTTPProxy, m.networkHTTPSProxy} {
+		if proxy != "" && !strings.HasPrefix(proxy, "http://") && !strings.HasPrefix(proxy, "https://") {
+			return fmt.Errorf("proxy %s must start with http:// or https://", proxy)
+		}
+	}
+	if m.networkNoProxy != "" && m.networkHTTPProxy == "" && m.networkHTTPSProxy == "" {
+		return fmt.Errorf("no proxy hosts need an HTTP or HTTPS proxy")
+	}
+	return nil
+}
+
+// splitNetworkList splits a comma separated list, dropping empty entries.
+func splitNetworkList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 // refreshKeyboardViewport rebuilds the keyboard list view and keeps the
 // selected layout in view.
 func (m *setupModel) refreshKeyboardViewport() {
@@ -25,6 +25,7 @@ func NewModel() tea.Model {
 		currentStep:     stepCheckingStatus,
 		binaryCacheOS:   true, // Default to using OS binary cache
 		binaryCachePups: true, // Default to using Pups binary cache
+		pupCollection:   "core",
 		keyboardVP:      viewport.New(0, 0),
 		timezoneVP:      viewport.New(0, 0),
 	}
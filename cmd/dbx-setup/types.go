@@ -25,6 +25,8 @@ const (
 	stepConfirmSeed
 	stepSelectNetwork
 	stepNetworkPassword
+	stepNetworkOptions
+	stepPupCollection
 	stepFinalizing
 	stepComplete
 )
@@ -36,6 +38,7 @@ type setupModel struct {
 	keyboardLayout     string
 	timezone           string
 	storageDevice      string
+	storageMirror      string // second disk to mirror storageDevice onto, if any
 	binaryCacheOS      bool
 	binaryCachePups    bool
 	password           string
@@ -49,6 +52,15 @@ type setupModel struct {
 	networkInterface   string
 	networkPassword    string
 	networkEncryption  string
+	networkStatic      bool // static IP instead of DHCP
+	networkAddress     string
+	networkGateway     string
+	networkDNS         string // comma separated
+	networkHTTPProxy   string
+	networkHTTPSProxy  string
+	networkNoProxy     string
+	networkOptionIdx   int
+	pupCollection      string // installed once bootstrapped, see pupCollections
 
 	// Available options
 	keyboardLayouts   []keyboardLayout
@@ -71,6 +83,34 @@ type setupModel struct {
 	authToken  string
 }
 
+// networkOption is a field on the network options step
+type networkOption int
+
+const (
+	networkOptionStatic networkOption = iota
+	networkOptionAddress
+	networkOptionGateway
+	networkOptionDNS
+	networkOptionHTTPProxy
+	networkOptionHTTPSProxy
+	networkOptionNoProxy
+	networkOptionCount
+)
+
+// pupCollection is an option on the pup collection step, matching the
+// collections dogeboxd accepts as initialPupCollection when bootstrapping.
+type pupCollection struct {
+	Name        string
+	Label       string
+	Description string
+}
+
+var pupCollections = []pupCollection{
+	{"core", "Core Node", "Dogecoin Core, a full node for the Dogecoin network"},
+	{"core-explorer", "Core Node + Explorer", "Dogecoin Core with a block explorer to browse the chain"},
+	{"minimal", "Minimal", "No pups, install what you want later"},
+}
+
 // keyboardLayout represents a keyboard layout option
 type keyboardLayout struct {
 	Code        string `json:"id"`
@@ -223,6 +223,10 @@ func (m setupModel) renderStorageDeviceStep() string {
 			displayName += device.Name
 		}
 
+		if device.Name == m.storageMirror {
+			displayName = "[MIRROR] " + displayName
+		}
+
 		line := fmt.Sprintf("  %s - %s", displayName, device.SizePretty)
 		if device.Name == m.storageDevice {
 			line = selectedStyle.Render("▸ " + line[2:])
@@ -236,7 +240,14 @@ func (m setupModel) renderStorageDeviceStep() string {
 		options = append(options, normalStyle.Render("  No storage devices found"))
 	}
 
-	help := helpStyle.Render("↑/↓: Navigate • Enter: Continue • Esc: Back • Ctrl+C: Quit")
+	mirror := normalStyle.Render("Mirror: none")
+	if m.storageMirror != "" {
+		mirror = selectedStyle.Render(fmt.Sprintf("Mirror: %s (RAID1, both disks are erased, capacity is the smaller disk's)", m.storageMirror))
+	} else if len(m.storageMirrorCandidates()) == 0 {
+		mirror = normalStyle.Render("Mirror: needs two disks other than the boot media")
+	}
+
+	help := helpStyle.Render("↑/↓: Navigate • M: Mirror onto another disk • Enter: Continue • Esc: Back • Ctrl+C: Quit")
 
 	content := lipgloss.JoinVertical(lipgloss.Left,
 		title,
@@ -244,6 +255,8 @@ func (m setupModel) renderStorageDeviceStep() string {
 		"",
 		strings.Join(options, "\n"),
 		"",
+		mirror,
+		"",
 		help,
 	)
 
@@ -596,6 +609,115 @@ func (m setupModel) getActiveStep() int {
 	return len(m.setupStepsComplete) - 1
 }
 
+func (m setupModel) renderNetworkOptionsStep() string {
+	title := titleStyle.Render("Network Options")
+	subtitle := subtitleStyle.Render("Optional: set a static IP or a proxy if DHCP or direct internet access isn't available")
+
+	staticBox := normalStyle.Render("[ ]")
+	if m.networkStatic {
+		staticBox = successStyle.Render("[✓]")
+	}
+
+	labels := map[networkOption]string{
+		networkOptionAddress:    "Address (CIDR)",
+		networkOptionGateway:    "Gateway",
+		networkOptionDNS:        "DNS Servers",
+		networkOptionHTTPProxy:  "HTTP Proxy",
+		networkOptionHTTPSProxy: "HTTPS Proxy",
+		networkOptionNoProxy:    "No Proxy For",
+	}
+	placeholders := map[networkOption]string{
+		networkOptionAddress:    "192.168.1.20/24",
+		networkOptionGateway:    "192.168.1.1",
+		networkOptionDNS:        "1.1.1.1, 8.8.8.8",
+		networkOptionHTTPProxy:  "http://proxy.example:3128",
+		networkOptionHTTPSProxy: "http://proxy.example:3128",
+		networkOptionNoProxy:    "example.local, 192.168.0.0/16",
+	}
+
+	var lines []string
+	for option := networkOptionStatic; option < networkOptionCount; option++ {
+		var line string
+		if option == networkOptionStatic {
+			line = fmt.Sprintf("  %s Use a static IP address", staticBox)
+		} else {
+			value := *m.networkOptionField(option)
+			if value == "" {
+				value = subtitleStyle.Render(placeholders[option])
+			}
+			line = fmt.Sprintf("  %-15s %s", labels[option]+":", value)
+		}
+
+		switch {
+		case option == networkOption(m.networkOptionIdx):
+			line = selectedStyle.Render("▸ " + line[2:])
+		case !m.networkOptionEnabled(option):
+			line = subtitleStyle.Render(line)
+		default:
+			line = normalStyle.Render(line)
+		}
+		lines = append(lines, line)
+	}
+
+	help := helpStyle.Render("↑/↓: Navigate • Space: Toggle • Enter: Continue • Esc: Back")
+
+	content := lipgloss.JoinVertical(lipgloss.Left,
+		title,
+		subtitle,
+		"",
+		strings.Join(lines, "\n"),
+		"",
+		normalStyle.Render("Leave everything blank to use DHCP with no proxy."),
+		"",
+		help,
+	)
+
+	return " " + strings.ReplaceAll(content, "\n", "\n ")
+}
+
+func (m setupModel) renderPupCollectionStep() string {
+	title := titleStyle.Render("Initial Pups")
+	subtitle := subtitleStyle.Render("Choose the pups to install once your Dogebox has rebooted")
+
+	var options []string
+	for _, collection := range pupCollections {
+		option := fmt.Sprintf("  %s", collection.Label)
+		if collection.Name == m.pupCollection {
+			option = selectedStyle.Render("▸ " + collection.Label)
+		} else {
+			option = normalStyle.Render(option)
+		}
+		options = append(options, option, subtitleStyle.Render("    "+collection.Description))
+	}
+
+	explanation := normalStyle.Render(
+		"\nInstalling starts when you first sign in after setup.\n" +
+			"You can add or remove pups at any time.")
+
+	help := helpStyle.Render("↑/↓: Navigate • Enter: Continue • Esc: Back")
+
+	content := lipgloss.JoinVertical(lipgloss.Left,
+		title,
+		subtitle,
+		"",
+		strings.Join(options, "\n"),
+		explanation,
+		"",
+		help,
+	)
+
+	return " " + strings.ReplaceAll(content, "\n", "\n ")
+}
+
+func pupCollectionLabel(name string) string {
+	for _, collection := range pupCollections {
+		if collection.Name == name {
+			return collection.Label
+		}
+	}
+	return name
+}
+
 func (m setupModel) renderCompleteStep() string {
 	title := successStyle.Render("✓ Setup Complete!")
 	subtitle := subtitleStyle.Render("Your Dogebox has been configured successfully")
@@ -608,22 +730,32 @@ func (m setupModel) renderCompleteStep() string {
 	} else {
 		networkDisplay = "None (Manual configuration)"
 	}
+	if m.networkType != "" && m.networkStatic {
+		networkDisplay += fmt.Sprintf(", static %s via %s", m.networkAddress, m.networkGateway)
+	}
+	if m.networkType != "" && (m.networkHTTPProxy != "" || m.networkHTTPSProxy != "") {
+		networkDisplay += ", via proxy"
+	}
 
 	summary := normalStyle.Render(fmt.Sprintf(
 		"Device Name: %s\n"+
 			"Keyboard Layout: %s\n"+
 			"Timezone: %s\n"+
 			"Storage Device: %s\n"+
+			"Storage Mirror: %s\n"+
 			"System Binary Cache: %s\n"+
 			"Pups Binary Cache: %s\n"+
-			"Network: %s",
+			"Network: %s\n"+
+			"Initial Pups: %s",
 		m.deviceName,
 		m.keyboardLayout,
 		m.timezone,
 		m.storageDevice,
+		map[bool]string{true: m.storageMirror, false: "None"}[m.storageMirror != ""],
 		map[bool]string{true: "Enabled", false: "Disabled"}[m.binaryCacheOS],
 		map[bool]string{true: "Enabled", false: "Disabled"}[m.binaryCachePups],
 		networkDisplay,
+		pupCollectionLabel(m.pupCollection),
 	))
 
 	help := helpStyle.Render("Enter: Exit • Q: Quit")
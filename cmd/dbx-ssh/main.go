@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/charmbracelet/ssh"
 	"github.com/charmbracelet/wish"
 	wishbubble "github.com/charmbracelet/wish/bubbletea"
 	"github.com/charmbracelet/wish/logging"
@@ -42,6 +43,7 @@ func main() {
 		wish.WithHostKeyPath(hostKeyPath),
 		wish.WithMiddleware(
 			wishbubble.Middleware(dbxdev.WishHandler),
+			profileMiddleware(),
 			logging.Middleware(),
 		),
 	)
@@ -54,3 +56,19 @@ func main() {
 		log.Fatalf("error starting SSH server: %v", err)
 	}
 }
+
+// profileMiddleware turns sessions away unless the box's profile allows
+// dbx-ssh, checked per session so switching profile takes effect without
+// a restart.
+func profileMiddleware() wish.Middleware {
+	return func(next ssh.Handler) ssh.Handler {
+		return func(s ssh.Session) {
+			if err := dbxdev.CheckSSHAllowed(); err != nil {
+				log.Printf("Refusing session from %s: %v", s.RemoteAddr(), err)
+				wish.Fatalln(s, err)
+				return
+			}
+			next(s)
+		}
+	}
+}
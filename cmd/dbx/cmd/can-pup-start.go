@@ -54,6 +54,12 @@ var canPupStartCmd = &cobra.Command{
 			return
 		}
 
+		if system.IsSafeMode(sm) {
+			log.Println("Can start: false (safe mode is enabled)")
+			utils.ExitBad(systemd)
+			return
+		}
+
 		config := dogeboxd.ServerConfig{
 			DataDir: dataDir,
 			TmpDir:  "/tmp",
@@ -69,7 +75,7 @@ var canPupStartCmd = &cobra.Command{
 			return
 		}
 
-		sourceManager := source.NewSourceManager(config, sm, pupManager)
+		sourceManager := source.NewSourceManager(config, sm, pupManager, nil)
 		pupManager.SetSourceManager(sourceManager)
 
 		canStart, err := pupManager.CanPupStart(pupId)
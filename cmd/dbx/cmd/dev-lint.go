@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/spf13/cobra"
+)
+
+var devLintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Check your pup manifest for problems before adding it as a source",
+	Long: `Check a pup's manifest.json and nix file for schema errors, missing
+fields, a stale nixFileSha256 and port conflicts. With --dataDir, ports are
+also checked against the pups installed on this Dogebox.
+
+Exits non-zero if there are any errors.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		pupDir, err := cmd.Flags().GetString("pupDir")
+		if err != nil {
+			log.Fatalf("Error getting pupDir flag: %v", err)
+		}
+		if pupDir == "" {
+			pupDir, err = os.Getwd()
+			if err != nil {
+				log.Fatalf("Error getting current working directory: %v", err)
+			}
+		}
+		dataDir, _ := cmd.Flags().GetString("dataDir")
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		manifestFile, err := os.ReadFile(filepath.Join(pupDir, "manifest.json"))
+		if err != nil {
+			log.Fatalf("Error reading manifest file: %v", err)
+		}
+
+		in := dogeboxd.PupManifestLintInput{Manifest: manifestFile}
+
+		var manifest dogeboxd.PupManifest
+		if json.Unmarshal(manifestFile, &manifest) == nil {
+			if manifest.Container.Build.NixFile != "" {
+				// Missing nix files are reported by the linter.
+				if nixFile, err := os.ReadFile(filepath.Join(pupDir, manifest.Container.Build.NixFile)); err == nil {
+					in.NixFile = nixFile
+				}
+			}
+			if dataDir != "" {
+				in.HostPorts = dogeboxd.PupHostPorts(loadInstalledPupStates(dataDir), manifest.Meta.Name)
+			}
+		}
+
+		result := dogeboxd.LintPupManifest(in)
+
+		if asJSON {
+			out, err := json.MarshalIndent(result, "", "  ")
+			if err != nil {
+				log.Fatalf("Error marshalling lint result: %v", err)
+			}
+			fmt.Println(string(out))
+		} else {
+			printLintResult(result)
+		}
+
+		if !result.Valid {
+			os.Exit(1)
+		}
+	},
+}
+
+func printLintResult(result dogeboxd.PupManifestLintResult) {
+	if len(result.Diagnostics) == 0 {
+		fmt.Println("manifest.json looks good")
+		return
+	}
+
+	errs, warnings := 0, 0
+	for _, d := range result.Diagnostics {
+		where := "manifest.json"
+		if d.Line > 0 {
+			where = fmt.Sprintf("%s:%d", where, d.Line)
+		}
+		if d.Path != "" {
+			where = fmt.Sprintf("%s (%s)", where, d.Path)
+		}
+		fmt.Printf("%-7s %s: %s [%s]\n", strings.ToUpper(d.Severity), where, d.Message, d.Code)
+
+		if d.Severity == dogeboxd.LINT_ERROR {
+			errs++
+		} else {
+			warnings++
+		}
+	}
+	fmt.Printf("\n%d error(s), %d warning(s)\n", errs, warnings)
+}
+
+// loadInstalledPupStates reads what pups are installed, skipping any we
+// can't read, as the lint can go on without them.
+func loadInstalledPupStates(dataDir string) map[string]dogeboxd.PupState {
+	states := map[string]dogeboxd.PupState{}
+	paths, _ := filepath.Glob(filepath.Join(dataDir, "pups", "*.gob"))
+	for _, path := range paths {
+		state, err := loadPupState(path)
+		if err != nil {
+			continue
+		}
+		states[state.ID] = state
+	}
+	return states
+}
+
+func init() {
+	devLintCmd.Flags().StringP("pupDir", "p", "", "Directory of the pup you want to lint")
+	devLintCmd.Flags().String("dataDir", "", "Path to a dogeboxd data directory, to check for port conflicts with installed pups")
+	devLintCmd.Flags().Bool("json", false, "Print diagnostics as JSON")
+	devCmd.AddCommand(devLintCmd)
+}
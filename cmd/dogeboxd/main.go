@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 
 	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/Dogebox-WG/dogeboxd/pkg/rootd"
 	"github.com/Dogebox-WG/dogeboxd/pkg/system"
 )
 
@@ -26,8 +27,14 @@ func main() {
 	var help bool
 	var forcedRecovery bool
 	var dangerousDevMode bool
+	var devDir string
 	var disableReflector bool
+	var checkInterfaceContracts bool
 	var unixSocket string
+	var rootdSocket string
+	var vaultAddr string
+	var vaultTokenFile string
+	var sopsFile string
 
 	flag.IntVar(&port, "port", 8080, "REST API Port")
 	flag.StringVar(&bind, "addr", "127.0.0.1", "Address to bind to")
@@ -39,8 +46,14 @@ func main() {
 	flag.IntVar(&internalPort, "internal-port", 80, "Internal Router Port")
 	flag.BoolVar(&forcedRecovery, "force-recovery", false, "Force recovery mode")
 	flag.BoolVar(&dangerousDevMode, "danger-dev", false, "Enable dangerous development mode")
+	flag.StringVar(&devDir, "dev-dir", "/opt/dev", "Directory dev pups are created in, as dbx-dev's DEV_DIR")
 	flag.BoolVar(&disableReflector, "disable-reflector", false, "Disable submitting to reflector")
+	flag.BoolVar(&checkInterfaceContracts, "check-interface-contracts", false, "Mark pups unhealthy if they don't serve the interfaces they provide")
 	flag.StringVar(&unixSocket, "unix-socket", "/tmp/dbx-socket", "Path to unix socket for local API access (default /tmp/dbx-socket)")
+	flag.StringVar(&rootdSocket, "rootd-socket", rootd.DefaultSocketPath, "Path to the rootd socket for privileged operations, sudo is used if empty or rootd isn't running")
+	flag.StringVar(&vaultAddr, "vault-addr", "", "Address of a Vault server to fetch vault: secrets from")
+	flag.StringVar(&vaultTokenFile, "vault-token-file", "", "File holding the Vault token, VAULT_TOKEN is used if unset")
+	flag.StringVar(&sopsFile, "sops-file", "", "SOPS encrypted file to fetch sops: secrets from")
 	flag.BoolVar(&verbose, "v", false, "Be verbose")
 	flag.BoolVar(&help, "h", false, "Get help")
 	flag.Parse()
@@ -112,20 +125,26 @@ func main() {
 	}
 
 	config := dogeboxd.ServerConfig{
-		Port:             port,
-		Bind:             bind,
-		DataDir:          dataDir,
-		TmpDir:           tmpDir,
-		NixDir:           nixDir,
-		ContainerLogDir:  containerLogDir,
-		Verbose:          verbose,
-		Recovery:         recoveryMode,
-		UiDir:            uiDir,
-		UiPort:           uiPort,
-		InternalPort:     internalPort,
-		DevMode:          dangerousDevMode,
-		DisableReflector: disableReflector,
-		UnixSocketPath:   unixSocket,
+		Port:                    port,
+		Bind:                    bind,
+		DataDir:                 dataDir,
+		TmpDir:                  tmpDir,
+		NixDir:                  nixDir,
+		ContainerLogDir:         containerLogDir,
+		Verbose:                 verbose,
+		Recovery:                recoveryMode,
+		UiDir:                   uiDir,
+		UiPort:                  uiPort,
+		InternalPort:            internalPort,
+		DevMode:                 dangerousDevMode,
+		DevDir:                  devDir,
+		DisableReflector:        disableReflector,
+		CheckInterfaceContracts: checkInterfaceContracts,
+		UnixSocketPath:          unixSocket,
+		RootdSocketPath:         rootdSocket,
+		VaultAddr:               vaultAddr,
+		VaultTokenFile:          vaultTokenFile,
+		SopsFile:                sopsFile,
 	}
 
 	srv := Server(stateManager, store, config)
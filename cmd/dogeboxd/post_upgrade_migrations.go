@@ -9,8 +9,10 @@ import (
 
 func (t server) checkAndPerformPostUpgradeMigrations(dbx dogeboxd.Dogeboxd) bool {
 	_, queued, err := migrations.RunPostUpgradeMigrations(migrations.Context{
-		Config:     t.config,
-		Enqueue:    dbx.AddAction,
+		Config: t.config,
+		Enqueue: func(a dogeboxd.Action) string {
+			return dbx.AddActionAs(a, dogeboxd.JOB_ACTOR_SYSTEM)
+		},
 		ActiveJobs: dbx.JobManager.GetActiveJobs,
 	})
 	if err != nil {
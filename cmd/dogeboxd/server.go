@@ -10,6 +10,7 @@ import (
 	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
 	"github.com/Dogebox-WG/dogeboxd/pkg/conductor"
 	"github.com/Dogebox-WG/dogeboxd/pkg/pup"
+	"github.com/Dogebox-WG/dogeboxd/pkg/secrets"
 	source "github.com/Dogebox-WG/dogeboxd/pkg/sources"
 	"github.com/Dogebox-WG/dogeboxd/pkg/system"
 	"github.com/Dogebox-WG/dogeboxd/pkg/system/lifecycle"
@@ -46,13 +47,28 @@ func (t server) Start() {
 	// Set up a doge key manager connection
 	dkm := dogeboxd.NewDKMManager()
 
-	sourceManager := source.NewSourceManager(t.config, t.sm, pups)
+	// External secret stores that source credentials and webhook secrets
+	// can be kept in, rather than in our own state.
+	secretResolver := secrets.NewResolver(t.config)
+
+	// Privileged operations go via rootd, or sudo until it's running.
+	runner := system.NewCommandRunner(t.config)
+	dogeboxd.SetPupConfigRunner(runner)
+
+	sourceManager := source.NewSourceManager(t.config, t.sm, pups, secretResolver)
 	pups.SetSourceManager(sourceManager)
+	pups.SetHealthCommandRunner(system.PupHealthCommandRunner(runner))
+	if t.config.CheckInterfaceContracts {
+		pups.SetInterfaceContracts(dogeboxd.InterfaceContracts)
+	}
 
 	// Add hook to post nix rebuild
 	var dbxReady uint32
 	var dbx dogeboxd.Dogeboxd
 	postRebuild := func() {
+		if err := system.ResetRebuildFailures(t.sm); err != nil {
+			log.Printf("Failed to reset rebuild failure count: %v", err)
+		}
 		if atomic.LoadUint32(&dbxReady) == 0 {
 			return
 		}
@@ -60,16 +76,52 @@ func (t server) Start() {
 			log.Printf("Skipping post-rebuild nix cache update because initial bootstrap will reboot shortly and would interrupt the cache warm") // Instead, we'll warm the cache on setup.
 			return
 		}
-		go dbx.AddAction(dogeboxd.UpdateNixCache{})
+		go dbx.AddActionAs(dogeboxd.UpdateNixCache{}, dogeboxd.JOB_ACTOR_SYSTEM)
 	}
 
-	nixManager := nix.NewNixManager(t.config, pups, postRebuild)
+	// Too many failed rebuilds in a row, drop into safe mode so the
+	// user can get back into the UI and fix whichever pup is at fault.
+	rebuildFailed := func(err error) {
+		shouldEnter, recordErr := system.RecordRebuildFailure(t.sm)
+		if recordErr != nil {
+			log.Printf("Failed to record rebuild failure: %v", recordErr)
+			return
+		}
+		if !shouldEnter || atomic.LoadUint32(&dbxReady) == 0 {
+			return
+		}
+		log.Printf("%d consecutive nix rebuilds have failed, entering safe mode", system.SafeModeRebuildFailureThreshold)
+		go dbx.AddActionAs(dogeboxd.SetSafeMode{
+			Enabled: true,
+			Reason:  fmt.Sprintf("%d consecutive system rebuilds failed, last error: %v", system.SafeModeRebuildFailureThreshold, err),
+		}, dogeboxd.JOB_ACTOR_SYSTEM)
+	}
+
+	// Any switch builds every pending change written out before it, not
+	// just ApplyPendingChanges, so they're no longer pending.
+	switched := func(started time.Time) {
+		cleared, err := system.ClearAppliedPendingChanges(t.sm, started)
+		if err != nil {
+			log.Printf("Failed to clear applied pending changes: %v", err)
+			return
+		}
+		if cleared && atomic.LoadUint32(&dbxReady) != 0 {
+			dbx.SendChange(dogeboxd.Change{ID: "internal", Type: "pending-changes", Update: t.sm.Get().Dogebox.PendingChanges})
+		}
+	}
+
+	nixManager := nix.NewNixManager(t.config, pups, runner, postRebuild, rebuildFailed, switched)
 
 	// Set up our system interfaces so we can talk to the host OS
-	networkManager := network.NewNetworkManager(nixManager, t.sm)
+	networkManager := network.NewNetworkManager(nixManager, t.sm, runner)
 	lifecycleManager := lifecycle.NewLifecycleManager(t.config)
 
 	systemUpdater := system.NewSystemUpdater(t.config, networkManager, nixManager, sourceManager, pups, t.sm, lifecycleManager, dkm)
+	stateSnapshotter := dogeboxd.NewStateSnapshotter(t.store, t.config)
+	systemUpdater.SetStateSnapshotter(stateSnapshotter)
+	// Secret pup config, unlocked when the user signs in.
+	configSecrets := dogeboxd.NewConfigSecretStore(dkm)
+	systemUpdater.SetConfigSecrets(configSecrets)
 	journalReader := system.NewJournalReader(t.config)
 	logtailer := system.NewLogTailer()
 
@@ -91,6 +143,13 @@ func (t server) Start() {
 		log.Printf("Error checking and submitting reflector data: %v", err)
 	}
 
+	// A mirror that came up degraded still works, so only shout about it.
+	if dbxState := t.sm.Get().Dogebox; dbxState.StorageMirrorDevice != "" {
+		if health := system.CheckStorageHealth(dogeboxd.StorageStatusDir, true); !health.Healthy {
+			log.Printf("Data volume mirror is unhealthy: degraded=%v missingDevices=%v deviceErrors=%v", health.Degraded, health.MissingDevices, health.DeviceErrors)
+		}
+	}
+
 	/* ----------------------------------------------------------------------- */
 	// Set up Dogeboxd, the beating heart of the beast
 
@@ -100,6 +159,52 @@ func (t server) Start() {
 	// Create JobManager
 	jobManager := dogeboxd.NewJobManager(t.store, &dbx)
 	dbx.SetJobManager(jobManager)
+
+	addSystemAction := func(a dogeboxd.Action) string { return dbx.AddActionAs(a, dogeboxd.JOB_ACTOR_SYSTEM) }
+
+	// Create JobScheduler for recurring jobs
+	jobScheduler := dogeboxd.NewJobScheduler(t.store, func(a dogeboxd.Action) string {
+		return dbx.AddActionAs(a, dogeboxd.JOB_ACTOR_SCHEDULER)
+	})
+	dbx.SetJobScheduler(jobScheduler)
+
+	// Let pups with an auto-update policy queue their own upgrades
+	pups.SetAutoUpgrader(addSystemAction)
+
+	// Create WebhookNotifier to tell user configured URLs about finished jobs
+	dbx.SetWebhookNotifier(dogeboxd.NewWebhookNotifier(t.store, secretResolver))
+
+	// Create UsageReporter to summarise each week's pup resource usage
+	usageReporter := dogeboxd.NewUsageReporter(t.store, pups, jobManager, dbx.Webhooks)
+	dbx.SetUsageReporter(usageReporter)
+
+	// Create SourceRefresher to keep source listings fresh between store visits
+	sourceRefresher := dogeboxd.NewSourceRefresher(sourceManager)
+	dbx.SetSourceRefresher(sourceRefresher)
+
+	// Create SourceWatcher to tell pup developers when disk sources change
+	sourceWatcher := dogeboxd.NewSourceWatcher(t.config, t.sm, sourceManager, pups)
+	dbx.SetSourceWatcher(sourceWatcher)
+
+	// Create DelegateReconciler to revoke DKM delegates of pups that are gone
+	delegateReconciler := dogeboxd.NewDelegateReconciler(dkm, pups)
+
+	// Create PupLogRotator to keep pup logs in ContainerLogDir in check
+	pupLogRotator := dogeboxd.NewPupLogRotator(t.config, t.sm, pups)
+	pupLogRotator.SetLogReopener(system.PupLogReopener(runner))
+	dbx.SetPupLogRotator(pupLogRotator)
+
+	// Create BackupCatalog to index backups for browsing through the API
+	dbx.SetBackupCatalog(dogeboxd.NewBackupCatalog(t.store, nixManager, pups))
+	dbx.SetStateSnapshotter(stateSnapshotter)
+
+	// Create WebUISSO to sign the dPanel user into pup WebUIs that opt in
+	webUISSO, err := dogeboxd.NewWebUISSO()
+	if err != nil {
+		log.Fatalf("Failed to set up WebUI single sign-on: %v", err)
+	}
+	dbx.SetWebUISSO(webUISSO)
+	dbx.SetConfigSecrets(configSecrets)
 	atomic.StoreUint32(&dbxReady, 1)
 
 	if reconciled, err := jobManager.ReconcileCompletedSystemUpdateJobs(); err == nil && reconciled > 0 {
@@ -116,13 +221,20 @@ func (t server) Start() {
 		log.Printf("Cleaned up %d orphaned jobs from previous run", cleared)
 	}
 
+	// Put back anything still queued when we last stopped.
+	if resumed, skipped, err := dbx.ResumePersistedJobs(); err != nil {
+		log.Printf("Failed to resume persisted job queue: %v", err)
+	} else if resumed > 0 || skipped > 0 {
+		log.Printf("Resumed %d queued jobs from previous run, %d could not be resumed", resumed, skipped)
+	}
+
 	if t.sm.Get().Dogebox.InitialState.HasFullyConfigured {
 		go func() {
 			if t.checkAndPerformPostUpgradeMigrations(dbx) {
 				return
 			}
 
-			jobID := dbx.AddAction(dogeboxd.UpdateNixCache{})
+			jobID := addSystemAction(dogeboxd.UpdateNixCache{})
 			log.Printf("Queued startup nix cache update job: %s", jobID)
 		}()
 	}
@@ -138,7 +250,7 @@ func (t server) Start() {
 	// Setup our external APIs. REST, Websockets
 
 	wsh := web.NewWSRelay(t.config, dbx.Changes)
-	adminRouter := web.NewAdminRouter(t.config, pups)
+	adminRouter := web.NewAdminRouter(t.config, dbx, pups)
 	rest := web.RESTAPI(t.config, t.sm, dbx, pups, sourceManager, lifecycleManager, nixManager, dkm, wsh)
 	internalRouter := web.NewInternalRouter(t.config, dbx, pups, dkm)
 	ui := dogeboxd.ServeUI(t.config)
@@ -164,12 +276,19 @@ func (t server) Start() {
 	c.Service("UI Server", ui)
 	c.Service("System Updater", systemUpdater)
 	c.Service("WSock Relay", wsh)
+	c.Service("AP Mode Watchdog", system.NewAPModeWatchdog(t.sm, networkManager, addSystemAction))
 
 	if !t.config.Recovery {
 		c.Service("System Monitor", systemMonitor)
 		c.Service("Pup Manager", pups)
 		c.Service("Internal Router", internalRouter)
 		c.Service("Admin Router", adminRouter)
+		c.Service("Job Scheduler", jobScheduler)
+		c.Service("Usage Reporter", usageReporter)
+		c.Service("Source Refresher", sourceRefresher)
+		c.Service("Source Watcher", sourceWatcher)
+		c.Service("Delegate Reconciler", delegateReconciler)
+		c.Service("Pup Log Rotator", pupLogRotator)
 	}
 
 	// c.Service("Watcher", NewWatcher(t.state, t.config.PupDir))
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"os/exec"
+	"sync"
 	"time"
 
 	"gopkg.in/natefinch/lumberjack.v2"
@@ -23,6 +24,9 @@ type SubLogger interface {
 	Errf(msg string, a ...any)
 	Progress(p int) SubLogger
 	LogCmd(cmd *exec.Cmd)
+	// OnKill registers kill to stop something running for the job if it
+	// times out, returning a func to call once it has finished.
+	OnKill(kill func()) func()
 }
 
 type actionLogger struct {
@@ -31,6 +35,9 @@ type actionLogger struct {
 	dbx      Dogeboxd
 	Steps    map[string]*stepLogger
 	progress int
+	cmds     *jobCommands
+	// guards Steps, a timed out job keeps logging while it's failed
+	mu sync.Mutex
 }
 
 func NewActionLogger(j Job, pupID string, dbx Dogeboxd) *actionLogger {
@@ -39,6 +46,7 @@ func NewActionLogger(j Job, pupID string, dbx Dogeboxd) *actionLogger {
 		PupID: pupID,
 		dbx:   dbx,
 		Steps: map[string]*stepLogger{},
+		cmds:  newJobCommands(),
 	}
 	return &l
 }
@@ -48,10 +56,18 @@ func (t *actionLogger) Progress(p int) *actionLogger {
 	return t
 }
 
+// KillCommands terminates every command this job has running, see
+// RunCmd, returning how many there were.
+func (t *actionLogger) KillCommands() int {
+	return t.cmds.kill()
+}
+
 func (t *actionLogger) Step(step string) *stepLogger {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 	s, ok := t.Steps[step]
 	if !ok {
-		t.Steps[step] = &stepLogger{t, step, 0, time.Now()}
+		t.Steps[step] = &stepLogger{l: t, step: step, start: time.Now()}
 		s = t.Steps[step]
 	}
 	return s
@@ -62,6 +78,15 @@ type stepLogger struct {
 	step     string
 	progress int
 	start    time.Time
+	eta      *time.Time
+}
+
+func (t *stepLogger) setETA(eta *time.Time) {
+	t.eta = eta
+}
+
+func (t *stepLogger) job() Job {
+	return t.l.Job
 }
 
 func (t *stepLogger) log(msg string, err bool) {
@@ -73,6 +98,7 @@ func (t *stepLogger) log(msg string, err bool) {
 		Msg:       msg,
 		Error:     err,
 		StepTaken: time.Since(t.start),
+		ETA:       t.eta,
 	}
 	symbol := "✔️"
 	if p.Error {
@@ -149,6 +175,10 @@ func (t *stepLogger) LogCmd(cmd *exec.Cmd) {
 	})
 }
 
+func (t *stepLogger) OnKill(kill func()) func() {
+	return t.l.cmds.track(kill)
+}
+
 type ConsoleSubLogger struct {
 	PupID    string
 	step     string
@@ -206,6 +236,10 @@ func (t *ConsoleSubLogger) LogCmd(cmd *exec.Cmd) {
 	})
 }
 
+func (t *ConsoleSubLogger) OnKill(kill func()) func() {
+	return func() {}
+}
+
 type LineWriter struct {
 	receiver func(string)
 	buf      bytes.Buffer
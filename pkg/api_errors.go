@@ -0,0 +1,139 @@
+package dogeboxd
+
+import (
+	"net/http"
+)
+
+// ErrorCode is a stable identifier for an error, which clients can
+// use to pick a (localised) message and remediation to show, rather
+// than parsing our freeform error messages.
+type ErrorCode string
+
+const (
+	ERROR_BAD_REQUEST       ErrorCode = "bad_request"
+	ERROR_UNAUTHORIZED      ErrorCode = "unauthorized"
+	ERROR_FORBIDDEN         ErrorCode = "forbidden"
+	ERROR_NOT_FOUND         ErrorCode = "not_found"
+	ERROR_CONFLICT          ErrorCode = "conflict"
+	ERROR_UNAVAILABLE       ErrorCode = "unavailable"
+	ERROR_INTERNAL          ErrorCode = "internal"
+	ERROR_PUP_NOT_FOUND     ErrorCode = "pup_not_found"
+	ERROR_JOB_NOT_FOUND     ErrorCode = "job_not_found"
+	ERROR_INVALID_DEEP_LINK ErrorCode = "invalid_deep_link"
+	ERROR_JOB_FAILED        ErrorCode = "job_failed"
+	ERROR_JOB_ORPHANED      ErrorCode = "job_orphaned"
+	ERROR_JOB_INTERRUPTED   ErrorCode = "job_interrupted"
+	ERROR_JOB_TIMED_OUT     ErrorCode = "job_timed_out"
+	ERROR_INVALID_CONFIG    ErrorCode = "invalid_config"
+)
+
+/* APIError is how errors are reported to clients, whether in a REST
+ * response, a websocket Change or a job record.
+ */
+type APIError struct {
+	Code        ErrorCode `json:"code"`
+	Message     string    `json:"message"`
+	Remediation string    `json:"remediation,omitempty"` // what the user can do about it, if anything
+	JobID       string    `json:"jobId,omitempty"`
+	PupID       string    `json:"pupId,omitempty"`
+	// Why each refused field was refused, by name, see ConfigFieldErrors.
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+func NewAPIError(code ErrorCode, message string) *APIError {
+	return &APIError{Code: code, Message: message}
+}
+
+func (e *APIError) WithRemediation(remediation string) *APIError {
+	e.Remediation = remediation
+	return e
+}
+
+func (e *APIError) ForPup(pupID string) *APIError {
+	e.PupID = pupID
+	return e
+}
+
+func (e *APIError) WithFields(fields map[string]string) *APIError {
+	e.Fields = fields
+	return e
+}
+
+func (e *APIError) ForJob(jobID string) *APIError {
+	e.JobID = jobID
+	return e
+}
+
+// ErrorCodeForStatus is the general error code for an HTTP status,
+// used when a handler has nothing more specific to say.
+func ErrorCodeForStatus(status int) ErrorCode {
+	switch status {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return ERROR_BAD_REQUEST
+	case http.StatusUnauthorized:
+		return ERROR_UNAUTHORIZED
+	case http.StatusForbidden:
+		return ERROR_FORBIDDEN
+	case http.StatusNotFound:
+		return ERROR_NOT_FOUND
+	case http.StatusConflict:
+		return ERROR_CONFLICT
+	case http.StatusServiceUnavailable:
+		return ERROR_UNAVAILABLE
+	default:
+		return ERROR_INTERNAL
+	}
+}
+
+// What a user can do when a pup is left broken for each reason.
+var brokenReasonRemediations = map[string]string{
+	BROKEN_REASON_STATE_UPDATE_FAILED:          "Check the disk isn't full, then try again.",
+	BROKEN_REASON_DOWNLOAD_FAILED:              "Check your internet connection and that the pup's source is reachable, then try again.",
+	BROKEN_REASON_NIX_FILE_MISSING:             "The pup's source is missing its nix file. Contact the pup's developer.",
+	BROKEN_REASON_NIX_HASH_MISMATCH:            "The pup's files don't match its manifest. Refresh the source and try again, or contact the pup's developer.",
+	BROKEN_REASON_STORAGE_CREATION_FAILED:      "Check the storage disk is attached and has free space, then try again.",
+	BROKEN_REASON_DELEGATE_KEY_CREATION_FAILED: "Check your password and try again.",
+	BROKEN_REASON_DELEGATE_KEY_WRITE_FAILED:    "Check the storage disk is attached and has free space, then try again.",
+	BROKEN_REASON_ENABLE_FAILED:                "Try enabling the pup again.",
+	BROKEN_REASON_NIX_APPLY_FAILED:             "Check the job log for the build error. Uninstall the pup if it keeps failing.",
+	BROKEN_REASON_MIGRATION_FAILED:             "Check the job log, then roll back to the previous version.",
+	BROKEN_REASON_STORAGE_IMPORT_FAILED:        "Check the storage disk has room for the export's storage, then purge the pup and import it again.",
+}
+
+// ErrorForBrokenPup describes why a pup was left broken, with a code of
+// "pup_<broken reason>", eg: pup_download_failed.
+func ErrorForBrokenPup(pup PupState, message string) *APIError {
+	return &APIError{
+		Code:        ErrorCode("pup_" + pup.BrokenReason),
+		Message:     message,
+		Remediation: brokenReasonRemediations[pup.BrokenReason],
+		PupID:       pup.ID,
+	}
+}
+
+// jobError describes why a job failed, or returns nil if it didn't.
+func (t Dogeboxd) jobError(j Job) *APIError {
+	if j.Err == "" {
+		return nil
+	}
+
+	if j.State != nil && t.Pups != nil {
+		if pup, _, err := t.Pups.GetPup(j.State.ID); err == nil && pup.Installation == STATE_BROKEN && pup.BrokenReason != "" {
+			return ErrorForBrokenPup(pup, j.Err).ForJob(j.ID)
+		}
+	}
+
+	e := NewAPIError(ERROR_JOB_FAILED, j.Err).ForJob(j.ID)
+	if j.TimedOut {
+		e = NewAPIError(ERROR_JOB_TIMED_OUT, j.Err).ForJob(j.ID).
+			WithRemediation("Check the job log for where it got stuck, then try again.")
+	}
+	if j.State != nil {
+		e.PupID = j.State.ID
+	}
+	return e
+}
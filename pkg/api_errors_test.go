@@ -0,0 +1,59 @@
+package dogeboxd
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorCodeForStatus(t *testing.T) {
+	assert.Equal(t, ERROR_BAD_REQUEST, ErrorCodeForStatus(http.StatusBadRequest))
+	assert.Equal(t, ERROR_NOT_FOUND, ErrorCodeForStatus(http.StatusNotFound))
+	assert.Equal(t, ERROR_UNAUTHORIZED, ErrorCodeForStatus(http.StatusUnauthorized))
+	assert.Equal(t, ERROR_INTERNAL, ErrorCodeForStatus(http.StatusBadGateway))
+}
+
+func TestErrorForBrokenPup(t *testing.T) {
+	pup := PupState{ID: "abc", BrokenReason: BROKEN_REASON_DOWNLOAD_FAILED}
+
+	e := ErrorForBrokenPup(pup, "Failed to install pup").ForJob("job-1")
+	assert.Equal(t, ErrorCode("pup_download_failed"), e.Code)
+	assert.Equal(t, "Failed to install pup", e.Message)
+	assert.NotEmpty(t, e.Remediation)
+	assert.Equal(t, "abc", e.PupID)
+	assert.Equal(t, "job-1", e.JobID)
+}
+
+func TestEveryBrokenReasonHasRemediation(t *testing.T) {
+	for _, reason := range []string{
+		BROKEN_REASON_STATE_UPDATE_FAILED,
+		BROKEN_REASON_DOWNLOAD_FAILED,
+		BROKEN_REASON_NIX_FILE_MISSING,
+		BROKEN_REASON_NIX_HASH_MISMATCH,
+		BROKEN_REASON_STORAGE_CREATION_FAILED,
+		BROKEN_REASON_DELEGATE_KEY_CREATION_FAILED,
+		BROKEN_REASON_DELEGATE_KEY_WRITE_FAILED,
+		BROKEN_REASON_ENABLE_FAILED,
+		BROKEN_REASON_NIX_APPLY_FAILED,
+		BROKEN_REASON_MIGRATION_FAILED,
+		BROKEN_REASON_STORAGE_IMPORT_FAILED,
+	} {
+		assert.NotEmpty(t, brokenReasonRemediations[reason], reason)
+	}
+}
+
+func TestJobErrorWithoutPup(t *testing.T) {
+	dbx := Dogeboxd{}
+
+	assert.Nil(t, dbx.jobError(Job{ID: "job-1"}))
+
+	e := dbx.jobError(Job{ID: "job-1", Err: "Failed to update timezone"})
+	assert.Equal(t, ERROR_JOB_FAILED, e.Code)
+	assert.Equal(t, "job-1", e.JobID)
+	assert.Empty(t, e.PupID)
+
+	e = dbx.jobError(Job{ID: "job-1", Err: "Timed out after 1h0m0s", TimedOut: true})
+	assert.Equal(t, ERROR_JOB_TIMED_OUT, e.Code)
+	assert.NotEmpty(t, e.Remediation)
+}
@@ -0,0 +1,207 @@
+package dogeboxd
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrBackupNotFound = errors.New("backup not found")
+	ErrBackupInUse    = errors.New("backup is in use")
+)
+
+// Where a backup is kept. Only local backups exist for now, removable and
+// remote locations slot in here as they're added.
+const BACKUP_LOCATION_LOCAL = "local"
+
+// What a backup is of.
+const BACKUP_TYPE_NIX_CONFIG = "nix-config"
+
+// A BackupCatalogEntry is what we know about a backup, indexed from its
+// manifest so it can be browsed without opening the archive.
+type BackupCatalogEntry struct {
+	ID       string    `json:"id"`
+	Location string    `json:"location"`
+	Type     string    `json:"type"`
+	Kind     string    `json:"kind"`
+	Parent   string    `json:"parent,omitempty"`
+	PatchID  string    `json:"patchId,omitempty"`
+	Created  time.Time `json:"created"`
+	// Size is what the backup takes up on disk, ContentSize is the total
+	// of the files restoring it puts back.
+	Size        int64                 `json:"size"`
+	ContentSize int64                 `json:"contentSize"`
+	FileCount   int                   `json:"fileCount"`
+	Pups        []BackupCatalogPup    `json:"pups"`
+	Files       []NixConfigBackupFile `json:"files,omitempty"`
+	IndexedAt   time.Time             `json:"indexedAt"`
+}
+
+// A pup whose config is in a backup. The name is recorded when the backup
+// is indexed, so it's still known after the pup is uninstalled.
+type BackupCatalogPup struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+}
+
+/* BackupCatalog indexes the backups we know about in the database, so
+ * they can be listed, inspected and cleaned up through the API rather
+ * than by poking at tarballs. The backups themselves stay the source of
+ * truth, the catalog is synced against them whenever it's read.
+ */
+type BackupCatalog struct {
+	store *TypeStore[BackupCatalogEntry]
+	nix   NixManager
+	pups  PupManager
+	mu    sync.Mutex
+	now   func() time.Time
+}
+
+func NewBackupCatalog(sm *StoreManager, nix NixManager, pups PupManager) *BackupCatalog {
+	return &BackupCatalog{
+		store: GetTypeStore[BackupCatalogEntry](sm),
+		nix:   nix,
+		pups:  pups,
+		now:   time.Now,
+	}
+}
+
+// Sync indexes backups that aren't in the catalog yet, and drops entries
+// for backups that no longer exist.
+func (c *BackupCatalog) Sync() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	backups, err := c.nix.ListConfigBackups()
+	if err != nil {
+		return fmt.Errorf("failed to list nix config backups: %w", err)
+	}
+
+	indexed, err := c.all()
+	if err != nil {
+		return err
+	}
+	known := map[string]bool{}
+	for _, entry := range indexed {
+		known[entry.ID] = true
+	}
+
+	onDisk := map[string]bool{}
+	for _, backup := range backups {
+		onDisk[backup.ID] = true
+		if known[backup.ID] {
+			continue
+		}
+		entry, err := c.index(backup)
+		if err != nil {
+			fmt.Printf("BackupCatalog: failed to index backup %s: %v\n", backup.ID, err)
+			continue
+		}
+		if err := c.store.Set(entry.ID, entry); err != nil {
+			return err
+		}
+	}
+
+	for _, entry := range indexed {
+		if entry.Location == BACKUP_LOCATION_LOCAL && !onDisk[entry.ID] {
+			if err := c.store.Del(entry.ID); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *BackupCatalog) index(backup NixConfigBackup) (BackupCatalogEntry, error) {
+	files, err := c.nix.ListConfigBackupFiles(backup.ID)
+	if err != nil {
+		return BackupCatalogEntry{}, err
+	}
+
+	entry := BackupCatalogEntry{
+		ID:        backup.ID,
+		Location:  BACKUP_LOCATION_LOCAL,
+		Type:      BACKUP_TYPE_NIX_CONFIG,
+		Kind:      backup.Kind,
+		Parent:    backup.Parent,
+		PatchID:   backup.PatchID,
+		Created:   backup.Created,
+		Size:      backup.Size,
+		FileCount: len(files),
+		Pups:      []BackupCatalogPup{},
+		Files:     files,
+		IndexedAt: c.now(),
+	}
+
+	for _, f := range files {
+		entry.ContentSize += f.Size
+		// Each installed pup has its own pup_<id>.nix in the nix dir.
+		name := path.Base(f.Path)
+		if !strings.HasPrefix(name, "pup_") || !strings.HasSuffix(name, ".nix") {
+			continue
+		}
+		pup := BackupCatalogPup{ID: strings.TrimSuffix(strings.TrimPrefix(name, "pup_"), ".nix")}
+		if state, _, err := c.pups.GetPup(pup.ID); err == nil {
+			pup.Name = state.DisplayName()
+		}
+		entry.Pups = append(entry.Pups, pup)
+	}
+
+	return entry, nil
+}
+
+func (c *BackupCatalog) all() ([]BackupCatalogEntry, error) {
+	query := fmt.Sprintf("SELECT value FROM %s ORDER BY json_extract(value, '$.created') DESC", c.store.Table)
+	return c.store.Exec(query)
+}
+
+// List returns every backup, newest first, without their file lists.
+func (c *BackupCatalog) List() ([]BackupCatalogEntry, error) {
+	if err := c.Sync(); err != nil {
+		return nil, err
+	}
+
+	entries, err := c.all()
+	if err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		entries[i].Files = nil
+	}
+	return entries, nil
+}
+
+// Get returns a backup along with the files it contains.
+func (c *BackupCatalog) Get(id string) (BackupCatalogEntry, error) {
+	if err := c.Sync(); err != nil {
+		return BackupCatalogEntry{}, err
+	}
+
+	entry, err := c.store.Get(id)
+	if err != nil {
+		return BackupCatalogEntry{}, ErrBackupNotFound
+	}
+	return entry, nil
+}
+
+// Delete removes a backup and its catalog entry. Backups that others
+// build on can't be deleted, see ErrBackupInUse.
+func (c *BackupCatalog) Delete(id string) error {
+	entry, err := c.Get(id)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.nix.DeleteConfigBackup(entry.ID); err != nil {
+		return err
+	}
+	return c.store.Del(entry.ID)
+}
@@ -0,0 +1,118 @@
+package dogeboxd
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type catalogNixManager struct {
+	NixManager
+	backups []NixConfigBackup
+	files   map[string][]NixConfigBackupFile
+	deleted []string
+}
+
+func (m *catalogNixManager) ListConfigBackups() ([]NixConfigBackup, error) {
+	return m.backups, nil
+}
+
+func (m *catalogNixManager) ListConfigBackupFiles(backupID string) ([]NixConfigBackupFile, error) {
+	return m.files[backupID], nil
+}
+
+func (m *catalogNixManager) DeleteConfigBackup(backupID string) error {
+	for _, b := range m.backups {
+		if b.Parent == backupID {
+			return fmt.Errorf("%w: backup %s builds on it", ErrBackupInUse, b.ID)
+		}
+	}
+	remaining := []NixConfigBackup{}
+	for _, b := range m.backups {
+		if b.ID != backupID {
+			remaining = append(remaining, b)
+		}
+	}
+	m.backups = remaining
+	m.deleted = append(m.deleted, backupID)
+	return nil
+}
+
+func newTestBackupCatalog(t *testing.T) (*BackupCatalog, *catalogNixManager) {
+	sm, err := NewStoreManager(":memory:")
+	require.NoError(t, err)
+
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	nix := &catalogNixManager{
+		backups: []NixConfigBackup{
+			{ID: "2-b", PatchID: "b", Created: created.Add(time.Hour), Size: 10, Kind: NIX_CONFIG_BACKUP_INCREMENTAL, Parent: "1-a"},
+			{ID: "1-a", PatchID: "a", Created: created, Size: 100, Kind: NIX_CONFIG_BACKUP_FULL},
+		},
+		files: map[string][]NixConfigBackupFile{
+			"1-a": {{Path: "system.nix", Size: 40}, {Path: "pup_abc.nix", Size: 2}},
+			"2-b": {{Path: "system.nix", Size: 40}, {Path: "pup_abc.nix", Size: 2}, {Path: "pup_gone.nix", Size: 3}},
+		},
+	}
+
+	pup := PupState{ID: "abc", InstanceName: "testnet"}
+	pup.Manifest.Meta.Name = "Dogecoin Core"
+	pups := queueTestPupManager{pups: map[string]PupState{"abc": pup}}
+
+	return NewBackupCatalog(sm, nix, pups), nix
+}
+
+func TestBackupCatalogIndexesBackups(t *testing.T) {
+	catalog, _ := newTestBackupCatalog(t)
+
+	backups, err := catalog.List()
+	require.NoError(t, err)
+	require.Len(t, backups, 2)
+	assert.Equal(t, "2-b", backups[0].ID, "newest first")
+	assert.Equal(t, BACKUP_LOCATION_LOCAL, backups[0].Location)
+	assert.Equal(t, "1-a", backups[0].Parent)
+	assert.Equal(t, int64(45), backups[0].ContentSize)
+	assert.Equal(t, 3, backups[0].FileCount)
+	assert.Nil(t, backups[0].Files, "lists shouldn't include every file")
+	assert.Equal(t, []BackupCatalogPup{
+		{ID: "abc", Name: "Dogecoin Core (testnet)"},
+		{ID: "gone"},
+	}, backups[0].Pups)
+
+	backup, err := catalog.Get("1-a")
+	require.NoError(t, err)
+	assert.Len(t, backup.Files, 2)
+
+	_, err = catalog.Get("nope")
+	assert.ErrorIs(t, err, ErrBackupNotFound)
+}
+
+func TestBackupCatalogDropsBackupsThatNoLongerExist(t *testing.T) {
+	catalog, nix := newTestBackupCatalog(t)
+	require.NoError(t, catalog.Sync())
+
+	nix.backups = nix.backups[1:]
+
+	backups, err := catalog.List()
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+	assert.Equal(t, "1-a", backups[0].ID)
+}
+
+func TestBackupCatalogDelete(t *testing.T) {
+	catalog, nix := newTestBackupCatalog(t)
+
+	err := catalog.Delete("1-a")
+	assert.ErrorIs(t, err, ErrBackupInUse)
+
+	require.NoError(t, catalog.Delete("2-b"))
+	assert.Equal(t, []string{"2-b"}, nix.deleted)
+
+	backups, err := catalog.List()
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+
+	assert.ErrorIs(t, catalog.Delete("2-b"), ErrBackupNotFound)
+}
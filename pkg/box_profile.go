@@ -0,0 +1,109 @@
+package dogeboxd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+/* A box's profile sets how careful it is with what it runs. Production
+ * boxes only install pups signed by a trusted key, never ignore a pup's
+ * nix hash, don't let dbx-ssh in and hide development mode from the API.
+ * Development boxes install anything, warning about unsigned pups and
+ * hash mismatches instead of refusing them.
+ *
+ * Boxes that haven't picked a profile keep behaving as they always have:
+ * signatures are checked when a pup has one, and hash mismatches are
+ * only ignored for pups installed in development mode.
+ */
+type BoxProfile string
+
+const (
+	BOX_PROFILE_PRODUCTION  BoxProfile = "production"
+	BOX_PROFILE_DEVELOPMENT BoxProfile = "development"
+)
+
+// A pup's signature, in nix's "name:base64" format, over its manifest.json.
+// The manifest has the sha256 of the pup's nix file, so with the hash
+// checked this covers what the pup runs.
+const PUP_MANIFEST_SIGNATURE_NAME = "manifest.json.sig"
+
+var ErrPupUnsigned = errors.New("pup isn't signed")
+
+func ValidateBoxProfile(profile BoxProfile) error {
+	switch profile {
+	case BOX_PROFILE_PRODUCTION, BOX_PROFILE_DEVELOPMENT:
+		return nil
+	}
+	return fmt.Errorf("profile must be %q or %q", BOX_PROFILE_PRODUCTION, BOX_PROFILE_DEVELOPMENT)
+}
+
+func (p BoxProfile) IsProduction() bool {
+	return p == BOX_PROFILE_PRODUCTION
+}
+
+func (p BoxProfile) IsDevelopment() bool {
+	return p == BOX_PROFILE_DEVELOPMENT
+}
+
+// RequiresPupSignatures is whether unsigned, or badly signed, pups are
+// refused rather than warned about.
+func (p BoxProfile) RequiresPupSignatures() bool {
+	return p.IsProduction()
+}
+
+// AllowsHashMismatch is whether a pup whose nix file doesn't match its
+// manifest is installed anyway.
+func (p BoxProfile) AllowsHashMismatch(pupDevMode bool) bool {
+	if p.IsProduction() {
+		return false
+	}
+	return p.IsDevelopment() || pupDevMode
+}
+
+// AllowsDbxSSH is whether dbx-ssh accepts sessions.
+func (p BoxProfile) AllowsDbxSSH() bool {
+	return !p.IsProduction()
+}
+
+// AllowsDevModeActions is whether pups can be installed in development
+// mode, and development endpoints used.
+func (p BoxProfile) AllowsDevModeActions() bool {
+	return !p.IsProduction()
+}
+
+// TrustedPupKeys are the keys pups may be signed with: the foundation's,
+// and those of any binary cache the user has added, which nix already
+// trusts to provide pups' store paths.
+func TrustedPupKeys(dbxState DogeboxState) []string {
+	keys := []string{FOUNDATION_OS_BINARY_CACHE_KEY}
+	for _, cache := range dbxState.BinaryCaches {
+		if cache.Key != "" {
+			keys = append(keys, cache.Key)
+		}
+	}
+	return keys
+}
+
+// VerifyPupSignature checks the manifest.json in pupPath was signed by
+// one of trustedKeys, returning the signing key's name, or ErrPupUnsigned
+// when it has no signature.
+func VerifyPupSignature(pupPath string, trustedKeys []string) (string, error) {
+	signature, err := os.ReadFile(filepath.Join(pupPath, PUP_MANIFEST_SIGNATURE_NAME))
+	if os.IsNotExist(err) {
+		return "", ErrPupUnsigned
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read signature: %w", err)
+	}
+	manifest, err := os.ReadFile(filepath.Join(pupPath, "manifest.json"))
+	if err != nil {
+		return "", fmt.Errorf("failed to read manifest: %w", err)
+	}
+	keyName, err := VerifyNixSignature(manifest, string(signature), trustedKeys)
+	if err != nil {
+		return "", fmt.Errorf("pup manifest %w", err)
+	}
+	return keyName, nil
+}
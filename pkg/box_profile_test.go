@@ -0,0 +1,68 @@
+package dogeboxd
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateBoxProfile(t *testing.T) {
+	assert.NoError(t, ValidateBoxProfile(BOX_PROFILE_PRODUCTION))
+	assert.NoError(t, ValidateBoxProfile(BOX_PROFILE_DEVELOPMENT))
+	assert.Error(t, ValidateBoxProfile(""))
+	assert.Error(t, ValidateBoxProfile("staging"))
+}
+
+func TestBoxProfileDefaults(t *testing.T) {
+	tests := map[BoxProfile]struct {
+		signatures, mismatch, devPupMismatch, ssh, devActions bool
+	}{
+		BOX_PROFILE_PRODUCTION:  {signatures: true},
+		BOX_PROFILE_DEVELOPMENT: {mismatch: true, devPupMismatch: true, ssh: true, devActions: true},
+		"":                      {devPupMismatch: true, ssh: true, devActions: true},
+	}
+
+	for profile, want := range tests {
+		t.Run(string(profile), func(t *testing.T) {
+			assert.Equal(t, want.signatures, profile.RequiresPupSignatures())
+			assert.Equal(t, want.mismatch, profile.AllowsHashMismatch(false))
+			assert.Equal(t, want.devPupMismatch, profile.AllowsHashMismatch(true))
+			assert.Equal(t, want.ssh, profile.AllowsDbxSSH())
+			assert.Equal(t, want.devActions, profile.AllowsDevModeActions())
+		})
+	}
+}
+
+func TestVerifyPupSignature(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	key := "test-1:" + base64.StdEncoding.EncodeToString(pub)
+
+	dir := t.TempDir()
+	manifest := []byte(`{"meta":{"name":"test","version":"1.0.0"}}`)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "manifest.json"), manifest, 0644))
+
+	_, err = VerifyPupSignature(dir, []string{key})
+	assert.ErrorIs(t, err, ErrPupUnsigned)
+
+	signature := "test-1:" + base64.StdEncoding.EncodeToString(ed25519.Sign(priv, manifest))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, PUP_MANIFEST_SIGNATURE_NAME), []byte(signature+"\n"), 0644))
+
+	signedBy, err := VerifyPupSignature(dir, []string{FOUNDATION_OS_BINARY_CACHE_KEY, key})
+	require.NoError(t, err)
+	assert.Equal(t, "test-1", signedBy)
+
+	_, err = VerifyPupSignature(dir, []string{FOUNDATION_OS_BINARY_CACHE_KEY})
+	assert.ErrorContains(t, err, "trusted key")
+
+	// Changing the manifest breaks the signature.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "manifest.json"), []byte(`{}`), 0644))
+	_, err = VerifyPupSignature(dir, []string{key})
+	assert.ErrorContains(t, err, "trusted key")
+}
@@ -0,0 +1,103 @@
+package dogeboxd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+)
+
+func (a BulkPupAction) Validate() error {
+	switch a.Operation {
+	case BULK_PUP_ENABLE, BULK_PUP_DISABLE, BULK_PUP_UPGRADE:
+	default:
+		return fmt.Errorf("unknown bulk operation %q, expected %s, %s or %s", a.Operation, BULK_PUP_ENABLE, BULK_PUP_DISABLE, BULK_PUP_UPGRADE)
+	}
+	if len(a.PupIDs) == 0 {
+		return errors.New("no pups given")
+	}
+	seen := map[string]bool{}
+	for _, id := range a.PupIDs {
+		if seen[id] {
+			return fmt.Errorf("pup %s is given twice", id)
+		}
+		seen[id] = true
+		if a.Operation == BULK_PUP_UPGRADE && a.TargetVersions[id] == "" {
+			return fmt.Errorf("no target version for pup %s", id)
+		}
+	}
+	return nil
+}
+
+// Restore returns the updates that put pup id back as it was before a
+// bulk enable or disable, false if we don't know how it was.
+func (a BulkPupAction) Restore(id string) ([]func(*PupState, *[]Pupdate), bool) {
+	b, ok := a.Before[id]
+	if !ok {
+		return nil, false
+	}
+	return []func(*PupState, *[]Pupdate){PupEnabled(b.Enabled), PupMaintenanceMode(b.Maintenance)}, true
+}
+
+/* dispatchBulkPupAction checks every pup in a BulkPupAction before any
+ * are touched, so the job either runs for all of them or none. Like
+ * EnablePup and DisablePup, the enabled flags are flipped straight away
+ * so clients show the intended state while the job waits in the queue.
+ * If flipping one fails, those already flipped are put back.
+ */
+func (t Dogeboxd) dispatchBulkPupAction(j Job, a BulkPupAction) {
+	if err := a.Validate(); err != nil {
+		j.Err = err.Error()
+		t.sendFinishedJob("action", j)
+		return
+	}
+	if a.Operation == BULK_PUP_ENABLE && t.refuseInSafeMode(j, "enable pups") {
+		return
+	}
+
+	before := map[string]BulkPupBefore{}
+	for _, id := range a.PupIDs {
+		p, _, err := t.Pups.GetPup(id)
+		if err != nil {
+			j.Err = fmt.Sprintf("Pup %s not found", id)
+			t.sendFinishedJob("action", j)
+			return
+		}
+		before[id] = BulkPupBefore{Enabled: p.Enabled, Maintenance: p.Maintenance}
+		if a.Operation == BULK_PUP_UPGRADE && p.SystemManaged {
+			j.Err = fmt.Sprintf("Can't upgrade %s: %s", p.DisplayName(), ErrSystemPup)
+			t.sendFinishedJob("action", j)
+			return
+		}
+		if a.Operation == BULK_PUP_UPGRADE && p.InMaintenance() {
+			j.Err = fmt.Sprintf("Can't upgrade %s: %s", p.DisplayName(), ErrPupInMaintenance)
+			t.sendFinishedJob("action", j)
+			return
+		}
+	}
+
+	if a.Operation != BULK_PUP_UPGRADE {
+		a.Before = before
+		j.A = a
+
+		enabled := a.Operation == BULK_PUP_ENABLE
+		for i, id := range a.PupIDs {
+			updates := []func(*PupState, *[]Pupdate){PupEnabled(enabled)}
+			if !enabled {
+				updates = append(updates, PupMaintenanceMode(nil))
+			}
+			if _, err := t.Pups.UpdatePup(id, append(updates, WithPupdateReason(PupdateReasonFor(j)))...); err != nil {
+				for _, flipped := range a.PupIDs[:i] {
+					restore, _ := a.Restore(flipped)
+					if _, err := t.Pups.UpdatePup(flipped, append(restore, WithPupdateReason(PupdateReasonFor(j)))...); err != nil {
+						log.Printf("Failed to restore pup %s after a failed bulk %s: %v", flipped, a.Operation, err)
+					}
+				}
+				j.Err = fmt.Sprintf("Failed to set enabled=%t for %s: %v", enabled, id, err)
+				t.sendFinishedJob("action", j)
+				return
+			}
+		}
+	}
+
+	t.enqueue(j)
+}
@@ -0,0 +1,17 @@
+package dogeboxd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBulkPupActionValidate(t *testing.T) {
+	assert.NoError(t, BulkPupAction{Operation: BULK_PUP_ENABLE, PupIDs: []string{"a", "b"}}.Validate())
+	assert.NoError(t, BulkPupAction{Operation: BULK_PUP_UPGRADE, PupIDs: []string{"a"}, TargetVersions: map[string]string{"a": "2.0.0"}}.Validate())
+
+	assert.Error(t, BulkPupAction{Operation: "restart", PupIDs: []string{"a"}}.Validate())
+	assert.Error(t, BulkPupAction{Operation: BULK_PUP_DISABLE}.Validate())
+	assert.Error(t, BulkPupAction{Operation: BULK_PUP_DISABLE, PupIDs: []string{"a", "a"}}.Validate())
+	assert.Error(t, BulkPupAction{Operation: BULK_PUP_UPGRADE, PupIDs: []string{"a", "b"}, TargetVersions: map[string]string{"a": "2.0.0"}}.Validate())
+}
@@ -13,13 +13,23 @@ type ServerConfig struct {
 	UiDir            string
 	UiPort           int
 	DevMode          bool
+	DevDir           string // where dev pups are created from templates, see devtemplate
 	DisableReflector bool
-	UnixSocketPath   string
+	// Probe pups for the interfaces they provide, see InterfaceContract.
+	CheckInterfaceContracts bool
+	UnixSocketPath          string
+	RootdSocketPath         string // privileged operations go via rootd when set, otherwise sudo
+	// External secret stores, see SecretProvider. Each is only enabled
+	// when configured.
+	VaultAddr      string
+	VaultTokenFile string
+	SopsFile       string
 }
 
 func GetSystemEnvironmentVariablesForContainer() map[string]string {
 	return map[string]string{
-		"DBX_HOST": "10.69.0.1",
-		"DBX_PORT": "80",
+		"DBX_HOST":         "10.69.0.1",
+		"DBX_PORT":         "80",
+		"DBX_METADATA_URL": "http://10.69.0.1/dbx/metadata",
 	}
 }
@@ -2,7 +2,12 @@ package dogeboxd
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -22,6 +27,9 @@ var supportedConfigTypes = map[string]struct{}{
 	"date":     {}, // date picker
 	"range":    {}, // slider input
 	"color":    {}, // color picker
+	"int":      {}, // whole number input
+	"port":     {}, // port number, 1 to 65535
+	"url":      {}, // http(s) URL input
 }
 
 // ManifestConfigFieldIndex returns a map of field name to field definition.
@@ -109,7 +117,7 @@ func stringifyConfigValue(fieldType string, raw any) (string, error) {
 	}
 
 	switch fieldType {
-	case "text", "password", "email", "textarea", "date", "color", "select", "radio":
+	case "text", "password", "email", "textarea", "date", "color", "select", "radio", "url":
 		switch v := raw.(type) {
 		case string:
 			return v, nil
@@ -117,7 +125,7 @@ func stringifyConfigValue(fieldType string, raw any) (string, error) {
 			return fmt.Sprintf("%v", raw), nil
 		}
 
-	case "number", "range":
+	case "number", "range", "int", "port":
 		switch v := raw.(type) {
 		case float64:
 			return strconv.FormatFloat(v, 'f', -1, 64), nil
@@ -167,3 +175,138 @@ func stringifyConfigValue(fieldType string, raw any) (string, error) {
 		return fmt.Sprintf("%v", raw), nil
 	}
 }
+
+// ConfigFieldErrors is why each refused config value was refused, by field name.
+type ConfigFieldErrors map[string]string
+
+func (e ConfigFieldErrors) Error() string {
+	names := make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	msgs := make([]string, 0, len(names))
+	for _, name := range names {
+		msgs = append(msgs, fmt.Sprintf("%s %s", name, e[name]))
+	}
+	return "invalid config: " + strings.Join(msgs, "; ")
+}
+
+/* ValidateConfigValues checks values, as stored by CoerceConfigPayload,
+ * against their fields' types, ranges, patterns and options. It returns
+ * ConfigFieldErrors for any that don't suit, or nil. Values for fields
+ * the manifest doesn't have are left alone, as CoerceConfigPayload drops
+ * them.
+ */
+func ValidateConfigValues(cfg PupManifestConfigFields, values map[string]string) error {
+	index := ManifestConfigFieldIndex(cfg)
+	errs := ConfigFieldErrors{}
+	for name, value := range values {
+		field, ok := index[name]
+		if !ok {
+			continue
+		}
+		if err := ValidateConfigValue(field, value); err != nil {
+			errs[name] = err.Error()
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// ValidateConfigValue checks a single value against its field.
+func ValidateConfigValue(field PupManifestConfigField, value string) error {
+	if strings.TrimSpace(value) == "" {
+		if field.Required {
+			return errors.New("is required")
+		}
+		return nil
+	}
+
+	switch field.Type {
+	case "number", "range":
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return errors.New("must be a number")
+		}
+		return checkConfigRange(field, n)
+
+	case "int":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return errors.New("must be a whole number")
+		}
+		return checkConfigRange(field, float64(n))
+
+	case "port":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > 65535 {
+			return errors.New("must be a port between 1 and 65535")
+		}
+		return checkConfigRange(field, float64(n))
+
+	case "toggle", "checkbox":
+		if value != "true" && value != "false" {
+			return errors.New("must be true or false")
+		}
+		return nil
+
+	case "url":
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("must be an http or https URL")
+		}
+
+	case "email":
+		if _, err := mail.ParseAddress(value); err != nil {
+			return errors.New("must be an email address")
+		}
+
+	case "select", "radio":
+		if len(field.Options) > 0 {
+			found := false
+			for _, option := range field.Options {
+				if option.Value == value {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return errors.New("must be one of the field's options")
+			}
+		}
+	}
+
+	if field.Pattern != "" {
+		pattern, err := compileConfigPattern(field.Pattern)
+		if err != nil {
+			return fmt.Errorf("can't be checked, the manifest's pattern is invalid: %w", err)
+		}
+		if !pattern.MatchString(value) {
+			return fmt.Errorf("must match %s", field.Pattern)
+		}
+	}
+	return nil
+}
+
+func checkConfigRange(field PupManifestConfigField, n float64) error {
+	if field.Min != nil && n < *field.Min {
+		return fmt.Errorf("must be at least %s", strconv.FormatFloat(*field.Min, 'f', -1, 64))
+	}
+	if field.Max != nil && n > *field.Max {
+		return fmt.Errorf("must be at most %s", strconv.FormatFloat(*field.Max, 'f', -1, 64))
+	}
+	return nil
+}
+
+func isNumericConfigType(fieldType string) bool {
+	return fieldType == "number" || fieldType == "range" || fieldType == "int" || fieldType == "port"
+}
+
+// compileConfigPattern anchors a field's pattern, so it has to match the whole value.
+func compileConfigPattern(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + pattern + ")$")
+}
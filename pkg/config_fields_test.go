@@ -2,6 +2,7 @@ package dogeboxd
 
 import (
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -97,3 +98,78 @@ func TestCoerceConfigPayload(t *testing.T) {
 		t.Fatalf("expected %v, got %v", expected, result)
 	}
 }
+
+func TestValidateConfigValues(t *testing.T) {
+	min, max := 1000.0, 2000.0
+	manifest := PupManifestConfigFields{
+		Sections: []PupManifestConfigSection{
+			{
+				Name: "general",
+				Fields: []PupManifestConfigField{
+					{Name: "THREADS", Type: "int", Min: &min, Max: &max},
+					{Name: "RPC_PORT", Type: "port"},
+					{Name: "ENABLED", Type: "toggle"},
+					{Name: "NETWORK", Type: "select", Options: []PupManifestConfigOption{{Label: "Mainnet", Value: "main"}, {Label: "Testnet", Value: "test"}}},
+					{Name: "PEER", Type: "url"},
+					{Name: "NAME", Type: "text", Pattern: "[a-z]+", Required: true},
+				},
+			},
+		},
+	}
+
+	valid := map[string]string{
+		"THREADS":  "1500",
+		"RPC_PORT": "22555",
+		"ENABLED":  "false",
+		"NETWORK":  "test",
+		"PEER":     "https://example.com:8080/x",
+		"NAME":     "dogebox",
+		"UNKNOWN":  "anything",
+	}
+	if err := ValidateConfigValues(manifest, valid); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	invalid := map[string]string{
+		"THREADS":  "2500",
+		"RPC_PORT": "70000",
+		"ENABLED":  "maybe",
+		"NETWORK":  "regtest",
+		"PEER":     "example.com",
+		"NAME":     "doge box",
+	}
+	err := ValidateConfigValues(manifest, invalid)
+	fields, ok := err.(ConfigFieldErrors)
+	if !ok {
+		t.Fatalf("expected ConfigFieldErrors, got %v", err)
+	}
+	for name := range invalid {
+		if _, ok := fields[name]; !ok {
+			t.Errorf("expected %s to be refused", name)
+		}
+	}
+	if fields["THREADS"] != "must be at most 2000" {
+		t.Errorf("unexpected THREADS error %q", fields["THREADS"])
+	}
+
+	// Optional fields can be cleared, required ones can't.
+	err = ValidateConfigValues(manifest, map[string]string{"THREADS": "", "NAME": " "})
+	fields, _ = err.(ConfigFieldErrors)
+	if len(fields) != 1 || fields["NAME"] != "is required" {
+		t.Fatalf("expected only NAME to be refused, got %v", err)
+	}
+}
+
+func TestManifestValidateRejectsBadConfigPattern(t *testing.T) {
+	field := PupManifestConfigField{Name: "NAME", Label: "Name", Type: "text", Pattern: "[a-z"}
+	m := PupManifest{
+		ManifestVersion: 1,
+		Meta:            PupManifestMeta{Name: "test", Version: "1.0.0"},
+		Config:          PupManifestConfigFields{Sections: []PupManifestConfigSection{{Name: "general", Fields: []PupManifestConfigField{field}}}},
+	}
+	m.Container.Build.NixFile = "pup.nix"
+	m.Container.Build.NixFileSha256 = "abc"
+	if err := m.Validate(); err == nil || !strings.Contains(err.Error(), "invalid pattern") {
+		t.Fatalf("expected invalid pattern error, got %v", err)
+	}
+}
@@ -0,0 +1,130 @@
+package dogeboxd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// PupConfigMergeReport describes how a pup's config was carried across an
+// upgrade to a manifest with different config fields.
+type PupConfigMergeReport struct {
+	FromVersion string                 `json:"fromVersion"`
+	ToVersion   string                 `json:"toVersion"`
+	Added       []PupConfigFieldChange `json:"added"`     // Fields that are new in this version
+	Removed     []PupConfigFieldChange `json:"removed"`   // Fields that no longer exist
+	Changed     []PupConfigFieldChange `json:"changed"`   // Fields whose type or default changed
+	Conflicts   []PupConfigFieldChange `json:"conflicts"` // Removed fields that had a custom value
+}
+
+type PupConfigFieldChange struct {
+	Name       string `json:"name"`
+	OldType    string `json:"oldType,omitempty"`
+	NewType    string `json:"newType,omitempty"`
+	OldDefault string `json:"oldDefault,omitempty"`
+	NewDefault string `json:"newDefault,omitempty"`
+	Value      string `json:"value,omitempty"`   // The value the user had set, if any
+	Applied    bool   `json:"applied,omitempty"` // The new default was applied
+}
+
+func (r PupConfigMergeReport) HasChanges() bool {
+	return len(r.Added)+len(r.Removed)+len(r.Changed) > 0
+}
+
+/* MergePupConfigForUpgrade carries a pup's config from oldCfg to newCfg.
+ * New defaults are only applied to keys without a value, any value the
+ * user has set is kept. Keys no longer in newCfg are dropped, and flagged
+ * as conflicts in the report if they had been changed from their default.
+ */
+func MergePupConfigForUpgrade(oldCfg PupManifestConfigFields, newCfg PupManifestConfigFields, values map[string]string) (map[string]string, PupConfigMergeReport, error) {
+	report := PupConfigMergeReport{
+		Added:     []PupConfigFieldChange{},
+		Removed:   []PupConfigFieldChange{},
+		Changed:   []PupConfigFieldChange{},
+		Conflicts: []PupConfigFieldChange{},
+	}
+
+	oldIndex := ManifestConfigFieldIndex(oldCfg)
+	newIndex := ManifestConfigFieldIndex(newCfg)
+
+	oldDefaults, err := ExtractManifestConfigDefaults(oldCfg)
+	if err != nil {
+		return nil, report, fmt.Errorf("old manifest: %w", err)
+	}
+	newDefaults, err := ExtractManifestConfigDefaults(newCfg)
+	if err != nil {
+		return nil, report, fmt.Errorf("new manifest: %w", err)
+	}
+
+	merged := make(map[string]string, len(values))
+
+	for _, name := range sortedFieldNames(newIndex) {
+		field := newIndex[name]
+		value, hasValue := values[name]
+		hasValue = hasValue && strings.TrimSpace(value) != ""
+		newDefault, hasNewDefault := newDefaults[name]
+
+		change := PupConfigFieldChange{
+			Name:       name,
+			NewType:    field.Type,
+			NewDefault: newDefault,
+			Value:      reportedConfigValue(field.Type, value),
+		}
+
+		if hasValue {
+			merged[name] = value
+		} else if hasNewDefault {
+			merged[name] = newDefault
+			change.Applied = true
+		}
+
+		oldField, existed := oldIndex[name]
+		if !existed {
+			report.Added = append(report.Added, change)
+			continue
+		}
+
+		change.OldType = oldField.Type
+		change.OldDefault = oldDefaults[name]
+		if change.OldType != change.NewType || change.OldDefault != change.NewDefault {
+			report.Changed = append(report.Changed, change)
+		}
+	}
+
+	for _, name := range sortedFieldNames(oldIndex) {
+		if _, stillExists := newIndex[name]; stillExists {
+			continue
+		}
+
+		change := PupConfigFieldChange{
+			Name:       name,
+			OldType:    oldIndex[name].Type,
+			OldDefault: oldDefaults[name],
+			Value:      reportedConfigValue(oldIndex[name].Type, values[name]),
+		}
+		report.Removed = append(report.Removed, change)
+
+		if value, ok := values[name]; ok && strings.TrimSpace(value) != "" && value != change.OldDefault {
+			report.Conflicts = append(report.Conflicts, change)
+		}
+	}
+
+	return merged, report, nil
+}
+
+// Don't leak secrets into job logs or the UI.
+func reportedConfigValue(fieldType string, value string) string {
+	if fieldType == "password" && value != "" {
+		return "********"
+	}
+	return value
+}
+
+func sortedFieldNames(index map[string]PupManifestConfigField) []string {
+	names := make([]string, 0, len(index))
+	for name := range index {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,82 @@
+package dogeboxd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func configFields(fields ...PupManifestConfigField) PupManifestConfigFields {
+	return PupManifestConfigFields{
+		Sections: []PupManifestConfigSection{{Name: "main", Fields: fields}},
+	}
+}
+
+func TestMergePupConfigForUpgrade(t *testing.T) {
+	oldCfg := configFields(
+		PupManifestConfigField{Name: "port", Type: "number", Default: 8080},
+		PupManifestConfigField{Name: "mode", Type: "select", Default: "fast"},
+		PupManifestConfigField{Name: "legacy", Type: "text", Default: "x"},
+		PupManifestConfigField{Name: "unused", Type: "text", Default: "y"},
+	)
+	newCfg := configFields(
+		PupManifestConfigField{Name: "port", Type: "number", Default: 9090},
+		PupManifestConfigField{Name: "mode", Type: "select", Default: "safe"},
+		PupManifestConfigField{Name: "peers", Type: "number", Default: 8},
+	)
+
+	values := map[string]string{
+		"port":   "1234",
+		"legacy": "custom",
+		"unused": "y",
+	}
+
+	merged, report, err := MergePupConfigForUpgrade(oldCfg, newCfg, values)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{
+		"port":  "1234", // user value kept
+		"mode":  "safe", // unset, new default applied
+		"peers": "8",    // new field, default applied
+	}, merged)
+
+	require.Len(t, report.Added, 1)
+	assert.Equal(t, "peers", report.Added[0].Name)
+	assert.True(t, report.Added[0].Applied)
+
+	require.Len(t, report.Changed, 2)
+	assert.Equal(t, "mode", report.Changed[0].Name)
+	assert.True(t, report.Changed[0].Applied)
+	assert.Equal(t, "port", report.Changed[1].Name)
+	assert.False(t, report.Changed[1].Applied)
+	assert.Equal(t, "8080", report.Changed[1].OldDefault)
+	assert.Equal(t, "9090", report.Changed[1].NewDefault)
+
+	require.Len(t, report.Removed, 2)
+
+	// Only the removed key with a non-default value is a conflict.
+	require.Len(t, report.Conflicts, 1)
+	assert.Equal(t, "legacy", report.Conflicts[0].Name)
+	assert.Equal(t, "custom", report.Conflicts[0].Value)
+}
+
+func TestMergePupConfigForUpgradeNoChanges(t *testing.T) {
+	cfg := configFields(PupManifestConfigField{Name: "port", Type: "number", Default: 8080})
+
+	merged, report, err := MergePupConfigForUpgrade(cfg, cfg, map[string]string{"port": "1"})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{"port": "1"}, merged)
+	assert.False(t, report.HasChanges())
+}
+
+func TestMergePupConfigForUpgradeHidesPasswords(t *testing.T) {
+	oldCfg := configFields(PupManifestConfigField{Name: "secret", Type: "password"})
+
+	_, report, err := MergePupConfigForUpgrade(oldCfg, configFields(), map[string]string{"secret": "hunter2"})
+	require.NoError(t, err)
+
+	require.Len(t, report.Conflicts, 1)
+	assert.NotContains(t, report.Conflicts[0].Value, "hunter2")
+}
@@ -0,0 +1,214 @@
+package dogeboxd
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var ErrConfigSecretsLocked = errors.New("config secrets are locked, sign in to the dPanel to unlock them")
+
+// The DKM delegate the config secrets key is derived from.
+const CONFIG_SECRETS_DELEGATE_ID = "dbx-config-secrets"
+
+/* Config fields marked secret in a pup's manifest are kept out of its
+ * Config, so they're never sent to clients. They're sealed into
+ * PupState.SecretConfig instead, with a key derived from a DKM delegate,
+ * and only opened to write the pup's config.env, see PupConfig.
+ *
+ * DKM only makes delegates for a signed in session, so the store starts
+ * locked and is unlocked when the user signs in. Until then setting a
+ * secret fails with ErrConfigSecretsLocked, and rewriting config.env
+ * keeps the secrets already in it. Exports carry Config only, so
+ * secrets have to be entered again on import.
+ */
+type ConfigSecretStore struct {
+	dkm  DKMManager
+	lock sync.Mutex
+	aead cipher.AEAD
+}
+
+func NewConfigSecretStore(dkm DKMManager) *ConfigSecretStore {
+	return &ConfigSecretStore{dkm: dkm}
+}
+
+// Unlock derives the secrets key using a session's DKM token. It's a
+// no-op once unlocked.
+func (s *ConfigSecretStore) Unlock(dkmToken string) error {
+	if s == nil {
+		return ErrConfigSecretsLocked
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.aead != nil {
+		return nil
+	}
+
+	delegate, err := s.dkm.MakeDelegate(CONFIG_SECRETS_DELEGATE_ID, dkmToken)
+	if err != nil {
+		return fmt.Errorf("failed to get config secrets key: %w", err)
+	}
+	if delegate.Priv == "" {
+		return errors.New("failed to get config secrets key: DKM returned no key")
+	}
+
+	key := sha256.Sum256([]byte("dogebox config secrets\x00" + delegate.Priv))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+	s.aead = aead
+	return nil
+}
+
+func (s *ConfigSecretStore) Unlocked() bool {
+	if s == nil {
+		return false
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.aead != nil
+}
+
+func (s *ConfigSecretStore) cipher() (cipher.AEAD, error) {
+	if s == nil {
+		return nil, ErrConfigSecretsLocked
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.aead == nil {
+		return nil, ErrConfigSecretsLocked
+	}
+	return s.aead, nil
+}
+
+// Values are bound to their pup and field, so a sealed value can't be
+// moved to another.
+func configSecretAD(pupID, name string) []byte {
+	return []byte(pupID + "\x00" + name)
+}
+
+// Seal encrypts a pup's secret config values. Empty values are kept
+// empty, they clear the secret.
+func (s *ConfigSecretStore) Seal(pupID string, values map[string]string) (map[string]string, error) {
+	sealed := make(map[string]string, len(values))
+	for name, value := range values {
+		if value == "" {
+			sealed[name] = ""
+			continue
+		}
+
+		aead, err := s.cipher()
+		if err != nil {
+			return nil, err
+		}
+		nonce := make([]byte, aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, err
+		}
+		out := aead.Seal(nonce, nonce, []byte(value), configSecretAD(pupID, name))
+		sealed[name] = base64.StdEncoding.EncodeToString(out)
+	}
+	return sealed, nil
+}
+
+// Open decrypts a pup's sealed secret config values.
+func (s *ConfigSecretStore) Open(pupID string, sealed map[string]string) (map[string]string, error) {
+	values := make(map[string]string, len(sealed))
+	for name, value := range sealed {
+		aead, err := s.cipher()
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := base64.StdEncoding.DecodeString(value)
+		if err != nil || len(data) < aead.NonceSize() {
+			return nil, fmt.Errorf("config secret %s is corrupt", name)
+		}
+		plain, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], configSecretAD(pupID, name))
+		if err != nil {
+			return nil, fmt.Errorf("config secret %s can't be decrypted: %w", name, err)
+		}
+		values[name] = string(plain)
+	}
+	return values, nil
+}
+
+/* PupConfig is the config written to a pup's config.env: its Config with
+ * its secrets opened. Pups without secrets don't need the store unlocked.
+ * While it's locked the secrets are left out, and keep names them, so
+ * they're carried over from the config.env already written.
+ */
+func (s *ConfigSecretStore) PupConfig(p PupState) (config map[string]string, keep []string, err error) {
+	if len(p.SecretConfig) == 0 {
+		return p.Config, nil, nil
+	}
+
+	secrets, err := s.Open(p.ID, p.SecretConfig)
+	if errors.Is(err, ErrConfigSecretsLocked) {
+		return p.Config, secretConfigNames(p.SecretConfig), nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	config = make(map[string]string, len(p.Config)+len(secrets))
+	for k, v := range p.Config {
+		config[k] = v
+	}
+	for k, v := range secrets {
+		config[k] = v
+	}
+	return config, nil, nil
+}
+
+// SplitSecretConfig separates the values of fields cfg marks secret from
+// the rest.
+func SplitSecretConfig(cfg PupManifestConfigFields, values map[string]string) (map[string]string, map[string]string) {
+	index := ManifestConfigFieldIndex(cfg)
+	plain := make(map[string]string, len(values))
+	secret := map[string]string{}
+	for name, value := range values {
+		if index[name].Secret {
+			secret[name] = value
+		} else {
+			plain[name] = value
+		}
+	}
+	return plain, secret
+}
+
+// PupConfigNeedsValues is ManifestConfigNeedsValues counting the pup's
+// secrets as set.
+func PupConfigNeedsValues(p PupState) bool {
+	if len(p.SecretConfig) == 0 {
+		return ManifestConfigNeedsValues(p.Manifest.Config, p.Config)
+	}
+	values := make(map[string]string, len(p.Config)+len(p.SecretConfig))
+	for k, v := range p.Config {
+		values[k] = v
+	}
+	for k := range p.SecretConfig {
+		values[k] = "(secret)"
+	}
+	return ManifestConfigNeedsValues(p.Manifest.Config, values)
+}
+
+func secretConfigNames(sealed map[string]string) []string {
+	names := make([]string, 0, len(sealed))
+	for name := range sealed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,132 @@
+package dogeboxd
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubSecretsDKM struct {
+	DKMManager
+	priv string
+	err  error
+}
+
+func (d stubSecretsDKM) MakeDelegate(id string, token string) (DKMResponseMakeDelegate, error) {
+	return DKMResponseMakeDelegate{Priv: d.priv}, d.err
+}
+
+func secretTestPup() PupState {
+	p := PupState{ID: "abc", Config: map[string]string{"RPC_USER": "doge"}}
+	p.Manifest.Config.Sections = []PupManifestConfigSection{{Name: "rpc", Fields: []PupManifestConfigField{
+		{Name: "RPC_USER", Label: "User", Type: "text", Required: true},
+		{Name: "RPC_PASS", Label: "Password", Type: "password", Required: true, Secret: true},
+	}}}
+	return p
+}
+
+func TestConfigSecretStoreSealsAndOpens(t *testing.T) {
+	s := NewConfigSecretStore(stubSecretsDKM{priv: "delegate-key"})
+
+	_, err := s.Seal("abc", map[string]string{"RPC_PASS": "hunter2"})
+	assert.ErrorIs(t, err, ErrConfigSecretsLocked)
+
+	require.NoError(t, s.Unlock("token"))
+	assert.True(t, s.Unlocked())
+
+	sealed, err := s.Seal("abc", map[string]string{"RPC_PASS": "hunter2", "CLEARED": ""})
+	require.NoError(t, err)
+	assert.NotContains(t, sealed["RPC_PASS"], "hunter2")
+	assert.Empty(t, sealed["CLEARED"])
+
+	opened, err := s.Open("abc", map[string]string{"RPC_PASS": sealed["RPC_PASS"]})
+	require.NoError(t, err)
+	assert.Equal(t, "hunter2", opened["RPC_PASS"])
+
+	// Sealed values are bound to their pup and field.
+	_, err = s.Open("xyz", map[string]string{"RPC_PASS": sealed["RPC_PASS"]})
+	assert.Error(t, err)
+	_, err = s.Open("abc", map[string]string{"OTHER": sealed["RPC_PASS"]})
+	assert.Error(t, err)
+}
+
+func TestConfigSecretStoreUnlockFailure(t *testing.T) {
+	s := NewConfigSecretStore(stubSecretsDKM{err: errors.New("bad token")})
+	assert.ErrorContains(t, s.Unlock("token"), "bad token")
+	assert.False(t, s.Unlocked())
+
+	var nilStore *ConfigSecretStore
+	assert.False(t, nilStore.Unlocked())
+	config, keep, err := nilStore.PupConfig(secretTestPup())
+	require.NoError(t, err, "pups without secrets don't need the store")
+	assert.Equal(t, "doge", config["RPC_USER"])
+	assert.Empty(t, keep)
+}
+
+// A locked store doesn't stop config.env being rewritten, the secrets
+// already in it are kept instead.
+func TestConfigSecretStoreLockedKeepsSecrets(t *testing.T) {
+	p := secretTestPup()
+	p.SecretConfig = map[string]string{"RPC_PASS": "sealed"}
+
+	s := NewConfigSecretStore(stubSecretsDKM{priv: "delegate-key"})
+	config, keep, err := s.PupConfig(p)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"RPC_USER": "doge"}, config)
+	assert.Equal(t, []string{"RPC_PASS"}, keep)
+
+	// Once unlocked, secrets that can't be opened are an error.
+	require.NoError(t, s.Unlock("token"))
+	_, _, err = s.PupConfig(p)
+	assert.ErrorContains(t, err, "corrupt")
+}
+
+func TestSecretConfigIsKeptOutOfConfig(t *testing.T) {
+	s := NewConfigSecretStore(stubSecretsDKM{priv: "delegate-key"})
+	require.NoError(t, s.Unlock("token"))
+	p := secretTestPup()
+	assert.True(t, PupConfigNeedsValues(p))
+
+	plain, secrets := SplitSecretConfig(p.Manifest.Config, map[string]string{"RPC_USER": "shibe", "RPC_PASS": "hunter2"})
+	assert.Equal(t, map[string]string{"RPC_USER": "shibe"}, plain)
+	sealed, err := s.Seal(p.ID, secrets)
+	require.NoError(t, err)
+
+	pupdates := []Pupdate{}
+	// Even if a secret is passed in as plain config it isn't stored there.
+	SetPupConfig(map[string]string{"RPC_USER": "shibe", "RPC_PASS": "hunter2"})(&p, &pupdates)
+	SetPupSecretConfig(sealed)(&p, &pupdates)
+	assert.NotContains(t, p.Config, "RPC_PASS")
+	assert.Equal(t, []string{"RPC_PASS"}, p.SecretsSet)
+	assert.False(t, p.NeedsConf)
+
+	config, keep, err := s.PupConfig(p)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"RPC_USER": "shibe", "RPC_PASS": "hunter2"}, config)
+	assert.Empty(t, keep)
+
+	SetPupSecretConfig(map[string]string{"RPC_PASS": ""})(&p, &pupdates)
+	assert.Nil(t, p.SecretConfig)
+	assert.Empty(t, p.SecretsSet)
+	assert.True(t, p.NeedsConf)
+}
+
+func TestManifestValidateRejectsSecretDefaults(t *testing.T) {
+	field := PupManifestConfigField{Name: "RPC_PASS", Label: "Password", Type: "password", Secret: true, Default: "hunter2"}
+	m := PupManifest{
+		ManifestVersion: 1,
+		Meta:            PupManifestMeta{Name: "test", Version: "1.0.0"},
+		Config:          PupManifestConfigFields{Sections: []PupManifestConfigSection{{Name: "rpc", Fields: []PupManifestConfigField{field}}}},
+	}
+	m.Container.Build.NixFile = "pup.nix"
+	m.Container.Build.NixFileSha256 = "abc"
+	assert.ErrorContains(t, m.Validate(), "can't have a default")
+
+	m.Config.Sections[0].Fields[0].Default = nil
+	assert.NoError(t, m.Validate())
+
+	m.Config.LogLevel = &PupManifestLogLevel{Env: "RPC_PASS", Debug: "debug"}
+	assert.ErrorContains(t, m.Validate(), "can't be a secret field")
+}
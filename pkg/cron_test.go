@@ -0,0 +1,47 @@
+package dogeboxd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCronScheduleNext(t *testing.T) {
+	// Wednesday
+	from := time.Date(2026, 3, 4, 10, 17, 30, 0, time.UTC)
+
+	cases := map[string]time.Time{
+		"* * * * *":        time.Date(2026, 3, 4, 10, 18, 0, 0, time.UTC),
+		"*/15 * * * *":     time.Date(2026, 3, 4, 10, 30, 0, 0, time.UTC),
+		"0 3 * * *":        time.Date(2026, 3, 5, 3, 0, 0, 0, time.UTC),
+		"@daily":           time.Date(2026, 3, 5, 0, 0, 0, 0, time.UTC),
+		"@weekly":          time.Date(2026, 3, 8, 0, 0, 0, 0, time.UTC),
+		"0 4 * * 7":        time.Date(2026, 3, 8, 4, 0, 0, 0, time.UTC),
+		"30 2 1 * *":       time.Date(2026, 4, 1, 2, 30, 0, 0, time.UTC),
+		"0 9 * * 1-5":      time.Date(2026, 3, 5, 9, 0, 0, 0, time.UTC),
+		"0 0 1,15 6 *":     time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC),
+		"0 12 13 * 5":      time.Date(2026, 3, 6, 12, 0, 0, 0, time.UTC), // Friday beats the 13th
+		"10-20/5 10 * * *": time.Date(2026, 3, 4, 10, 20, 0, 0, time.UTC),
+	}
+
+	for expr, want := range cases {
+		s, err := ParseCronSchedule(expr)
+		require.NoError(t, err, expr)
+		assert.Equal(t, want, s.Next(from), expr)
+	}
+}
+
+func TestCronScheduleNeverMatches(t *testing.T) {
+	s, err := ParseCronSchedule("0 0 31 2 *")
+	require.NoError(t, err)
+	assert.True(t, s.Next(time.Now()).IsZero())
+}
+
+func TestParseCronScheduleInvalid(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "5-1 * * * *", "*/0 * * * *", "@yearly", "a * * * *"} {
+		_, err := ParseCronSchedule(expr)
+		assert.Error(t, err, expr)
+	}
+}
@@ -0,0 +1,26 @@
+package dogeboxd
+
+/* SaveCustomNix only checks custom.nix parses before switching to it, so
+ * a typo'd option or missing package isn't found until the rebuild
+ * fails. ValidateCustomNix dry builds the whole system with the unsaved
+ * content as custom.nix instead, which evaluates every module without
+ * building or switching anything, so the editor can check as often as
+ * it likes.
+ */
+
+// A CustomNixIssue is an error or warning from validating custom.nix.
+type CustomNixIssue struct {
+	Message string `json:"message"`
+	// Where nix says the problem is, "custom.nix" when it's in the
+	// content being validated.
+	File   string `json:"file,omitempty"`
+	Line   int    `json:"line,omitempty"`
+	Column int    `json:"column,omitempty"`
+}
+
+// CustomNixValidation is what a ValidateCustomNix job succeeds with.
+type CustomNixValidation struct {
+	Valid    bool             `json:"valid"`
+	Errors   []CustomNixIssue `json:"errors"`
+	Warnings []CustomNixIssue `json:"warnings"`
+}
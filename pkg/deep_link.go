@@ -0,0 +1,205 @@
+package dogeboxd
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+/* Deep links let community sites offer "Install on my Dogebox" buttons:
+ *
+ *   dogebox://install?source=<git url>&pup=<name>&version=<version>
+ *
+ * version is optional, the latest is used when it's left out. A link only
+ * ever resolves to an install payload for the user to confirm, it never
+ * installs anything or adds a source on its own.
+ */
+const DEEP_LINK_SCHEME = "dogebox"
+
+type PupDeepLink struct {
+	SourceLocation string `json:"sourceLocation"`
+	PupName        string `json:"pupName"`
+	PupVersion     string `json:"pupVersion"` // empty for the latest version
+}
+
+func ParsePupDeepLink(raw string) (PupDeepLink, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return PupDeepLink{}, fmt.Errorf("invalid link: %w", err)
+	}
+
+	if u.Scheme != DEEP_LINK_SCHEME {
+		return PupDeepLink{}, fmt.Errorf("not a %s:// link", DEEP_LINK_SCHEME)
+	}
+
+	// dogebox://install parses with install as the host, dogebox:install as opaque.
+	action := u.Host
+	if action == "" {
+		action = u.Opaque
+	}
+	if action != "install" {
+		return PupDeepLink{}, fmt.Errorf("unsupported link action %q", action)
+	}
+
+	q := u.Query()
+	link := PupDeepLink{
+		SourceLocation: q.Get("source"),
+		PupName:        q.Get("pup"),
+		PupVersion:     q.Get("version"),
+	}
+
+	if err := validateDeepLinkSource(link.SourceLocation); err != nil {
+		return PupDeepLink{}, err
+	}
+
+	if link.PupName == "" {
+		return PupDeepLink{}, fmt.Errorf("link is missing a pup name")
+	}
+
+	if link.PupVersion != "" {
+		if _, err := semver.NewVersion(link.PupVersion); err != nil {
+			return PupDeepLink{}, fmt.Errorf("invalid pup version %q", link.PupVersion)
+		}
+	}
+
+	return link, nil
+}
+
+// Links come from outside the box, so they may only point at public git
+// sources, never at a path on disk or anything needing local credentials.
+func validateDeepLinkSource(location string) error {
+	if location == "" {
+		return fmt.Errorf("link is missing a source")
+	}
+
+	u, err := url.Parse(location)
+	if err != nil || u.Scheme != "https" || u.Host == "" || u.User != nil {
+		return fmt.Errorf("link source must be a public https git repository")
+	}
+
+	if !strings.HasSuffix(u.Path, ".git") {
+		return fmt.Errorf("link source must be a git repository ending in .git")
+	}
+
+	return nil
+}
+
+/* ResolvedPupDeepLink is everything a client needs to ask the user
+ * to confirm an install from a deep link.
+ */
+type ResolvedPupDeepLink struct {
+	Link PupDeepLink `json:"link"`
+	// Whether the source has already been added to this dogebox. If it
+	// hasn't, it needs adding (with the user's consent) before installing.
+	SourceKnown bool                        `json:"sourceKnown"`
+	Source      ManifestSourceConfiguration `json:"source"`
+	PupVersion  string                      `json:"pupVersion"`
+	Manifest    PupManifest                 `json:"manifest"`
+	// Set if this pup is already installed from the same source.
+	InstalledPupID   string `json:"installedPupId,omitempty"`
+	InstalledVersion string `json:"installedVersion,omitempty"`
+	// Things the user should know before confirming.
+	Warnings []string `json:"warnings"`
+}
+
+// ResolvePupDeepLink looks up the pup a deep link points at, without
+// adding its source or installing anything.
+func ResolvePupDeepLink(link PupDeepLink, sources SourceManager, pups PupManager) (ResolvedPupDeepLink, error) {
+	resolved := ResolvedPupDeepLink{Link: link, Warnings: []string{}}
+
+	var list ManifestSourceList
+	for _, config := range sources.GetAllSourceConfigurations() {
+		if config.Location != link.SourceLocation {
+			continue
+		}
+
+		all, err := sources.GetAll(false)
+		if err != nil {
+			return resolved, fmt.Errorf("failed to list source: %w", err)
+		}
+
+		list = all[config.ID]
+		resolved.SourceKnown = true
+		break
+	}
+
+	if !resolved.SourceKnown {
+		preview, err := sources.PreviewSource(link.SourceLocation)
+		if err != nil {
+			return resolved, fmt.Errorf("failed to fetch source: %w", err)
+		}
+		list = preview
+		resolved.Warnings = append(resolved.Warnings, fmt.Sprintf("%s is not a source on this Dogebox yet, it will be added", link.SourceLocation))
+	}
+
+	if list.Error != "" {
+		return resolved, fmt.Errorf("failed to list source: %s", list.Error)
+	}
+	resolved.Source = list.Config
+
+	pup, ok := findDeepLinkPup(list.Pups, link.PupName, link.PupVersion)
+	if !ok {
+		if link.PupVersion != "" {
+			return resolved, fmt.Errorf("%s %s not found in source", link.PupName, link.PupVersion)
+		}
+		return resolved, fmt.Errorf("%s not found in source", link.PupName)
+	}
+
+	resolved.PupVersion = pup.Version
+	resolved.Manifest = pup.Manifest
+
+	if pup.Manifest.Container.RequiresInternet {
+		resolved.Warnings = append(resolved.Warnings, "This pup requires internet access")
+	}
+	if len(pup.Manifest.Dependencies) > 0 {
+		resolved.Warnings = append(resolved.Warnings, fmt.Sprintf("This pup depends on %d other pup(s)", len(pup.Manifest.Dependencies)))
+	}
+
+	for id, state := range pups.GetStateMap() {
+		if state.Manifest.Meta.Name == link.PupName && state.Source.Location == link.SourceLocation {
+			resolved.InstalledPupID = id
+			resolved.InstalledVersion = state.Version
+			resolved.Warnings = append(resolved.Warnings, fmt.Sprintf("%s %s is already installed", state.Manifest.Meta.Name, state.Version))
+			break
+		}
+	}
+
+	return resolved, nil
+}
+
+// findDeepLinkPup finds the named pup, at version or the latest if version is empty.
+func findDeepLinkPup(pups []ManifestSourcePup, name string, version string) (ManifestSourcePup, bool) {
+	var best ManifestSourcePup
+	var bestVersion *semver.Version
+	found := false
+
+	for _, p := range pups {
+		if p.Name != name {
+			continue
+		}
+
+		v, err := semver.NewVersion(p.Version)
+		if err != nil {
+			continue
+		}
+
+		if version != "" {
+			if want, _ := semver.NewVersion(version); want != nil && v.Equal(want) {
+				return p, true
+			}
+			continue
+		}
+
+		if v.Prerelease() != "" {
+			continue
+		}
+
+		if !found || v.GreaterThan(bestVersion) {
+			best, bestVersion, found = p, v, true
+		}
+	}
+
+	return best, found
+}
@@ -0,0 +1,225 @@
+/*
+Package devtemplate fills in the pup templates from Dogebox-WG/pup-templates
+that dev pups are created from. Every template has its pup name, written
+as pup_<template>, replaced with the new pup's, and a template can ask for
+more with a template.json declaring Variables, which dbx-dev and the
+dPanel prompt for.
+*/
+package devtemplate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The file a template declares its variables in, removed once it's applied.
+const TEMPLATE_FILE = "template.json"
+
+const (
+	TYPE_STRING    = "string"
+	TYPE_NUMBER    = "number"
+	TYPE_PORT      = "port"
+	TYPE_INTERFACE = "interface"
+)
+
+var (
+	variableName  = regexp.MustCompile(`^[a-z][a-z0-9_]{0,31}$`)
+	interfaceName = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,15}$`)
+)
+
+// Variable is a value a template asks for when a pup is created from it.
+type Variable struct {
+	Name        string `json:"name"`
+	Label       string `json:"label"`
+	Description string `json:"description,omitempty"`
+	// One of the TYPE_ constants, TYPE_STRING if empty.
+	Type string `json:"type,omitempty"`
+	// Used when no value is given, a variable without one must be given.
+	Default string `json:"default,omitempty"`
+	// What's replaced in the template's files, __<NAME>__ if empty.
+	Placeholder string `json:"placeholder,omitempty"`
+}
+
+type Template struct {
+	Variables []Variable `json:"variables"`
+}
+
+// Load reads the template in dir, one without a TEMPLATE_FILE has no
+// Variables.
+func Load(dir string) (Template, error) {
+	data, err := os.ReadFile(filepath.Join(dir, TEMPLATE_FILE))
+	if errors.Is(err, fs.ErrNotExist) {
+		return Template{Variables: []Variable{}}, nil
+	}
+	if err != nil {
+		return Template{}, err
+	}
+
+	var t Template
+	if err := json.Unmarshal(data, &t); err != nil {
+		return Template{}, fmt.Errorf("invalid %s: %w", TEMPLATE_FILE, err)
+	}
+	if t.Variables == nil {
+		t.Variables = []Variable{}
+	}
+	return t, t.Validate()
+}
+
+func (t Template) Validate() error {
+	seen := map[string]bool{}
+	for _, v := range t.Variables {
+		if !variableName.MatchString(v.Name) {
+			return fmt.Errorf("invalid variable name %q", v.Name)
+		}
+		if seen[v.Name] {
+			return fmt.Errorf("variable %s is declared twice", v.Name)
+		}
+		seen[v.Name] = true
+
+		switch v.Type {
+		case "", TYPE_STRING, TYPE_NUMBER, TYPE_PORT, TYPE_INTERFACE:
+		default:
+			return fmt.Errorf("variable %s has unknown type %q", v.Name, v.Type)
+		}
+		if v.Default != "" {
+			if err := v.Check(v.Default); err != nil {
+				return fmt.Errorf("default for %s: %w", v.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Token is what's replaced by the variable's value.
+func (v Variable) Token() string {
+	if v.Placeholder != "" {
+		return v.Placeholder
+	}
+	return "__" + strings.ToUpper(v.Name) + "__"
+}
+
+// Check reports whether value suits the variable's type. Values end up in
+// JSON and nix strings, so strings can't have quotes, backslashes or
+// control characters in them.
+func (v Variable) Check(value string) error {
+	switch v.Type {
+	case TYPE_NUMBER:
+		if _, err := strconv.Atoi(value); err != nil {
+			return errors.New("must be a whole number")
+		}
+	case TYPE_PORT:
+		port, err := strconv.Atoi(value)
+		if err != nil || port < 1 || port > 65535 {
+			return errors.New("must be a port between 1 and 65535")
+		}
+	case TYPE_INTERFACE:
+		if !interfaceName.MatchString(value) {
+			return errors.New("must be a network interface name, ie: eth0")
+		}
+	default:
+		for _, r := range value {
+			if r == '"' || r == '\\' || r < ' ' || r == 0x7f {
+				return errors.New("can't contain quotes, backslashes or control characters")
+			}
+		}
+		// Values end up in nix strings, where ${ starts an expression.
+		if strings.Contains(value, "${") {
+			return errors.New("can't contain ${")
+		}
+	}
+	return nil
+}
+
+// Resolve checks values against the template's Variables, filling in
+// defaults for any not given.
+func (t Template) Resolve(values map[string]string) (map[string]string, error) {
+	declared := map[string]bool{}
+	resolved := map[string]string{}
+	for _, v := range t.Variables {
+		declared[v.Name] = true
+		value, ok := values[v.Name]
+		if !ok || value == "" {
+			value = v.Default
+		}
+		if value == "" {
+			return nil, fmt.Errorf("%s is required", v.Name)
+		}
+		if err := v.Check(value); err != nil {
+			return nil, fmt.Errorf("%s %w", v.Name, err)
+		}
+		resolved[v.Name] = value
+	}
+	for name := range values {
+		if !declared[name] {
+			return nil, fmt.Errorf("template has no variable %s", name)
+		}
+	}
+	return resolved, nil
+}
+
+/* Apply fills in the template cloned to dir, templateName being the
+ * pup-templates directory it came from, and returns the values it used.
+ * Everything is replaced in one pass, so a value is never itself replaced.
+ */
+func Apply(dir, templateName, pupName string, values map[string]string) (map[string]string, error) {
+	t, err := Load(dir)
+	if err != nil {
+		return nil, err
+	}
+	resolved, err := t.Resolve(values)
+	if err != nil {
+		return nil, err
+	}
+
+	pairs := []string{}
+	for _, v := range t.Variables {
+		pairs = append(pairs, v.Token(), resolved[v.Name])
+	}
+	pairs = append(pairs, fmt.Sprintf("pup_%s", templateName), pupName)
+	replacer := strings.NewReplacer(pairs...)
+
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if path == filepath.Join(dir, TEMPLATE_FILE) {
+			return nil
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		newContent := replacer.Replace(string(content))
+		if newContent == string(content) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(path, []byte(newContent), info.Mode())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Remove(filepath.Join(dir, TEMPLATE_FILE)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return resolved, nil
+}
@@ -0,0 +1,89 @@
+package devtemplate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTemplate(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	return dir
+}
+
+func TestApplySubstitutesVariablesAcrossFiles(t *testing.T) {
+	dir := writeTemplate(t, map[string]string{
+		TEMPLATE_FILE: `{"variables": [
+			{"name": "port", "label": "Port", "type": "port", "default": "8080"},
+			{"name": "description", "label": "Description"},
+			{"name": "iface", "label": "Interface", "type": "interface", "placeholder": "@IFACE@"}
+		]}`,
+		"manifest.json": `{"name": "pup_basic", "description": "__DESCRIPTION__", "port": __PORT__}`,
+		"pup.nix":       `{ iface = "@IFACE@"; name = "pup_basic"; }`,
+		".git/HEAD":     "pup_basic",
+	})
+
+	resolved, err := Apply(dir, "basic", "mypup", map[string]string{"description": "My pup", "iface": "eth0"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"port": "8080", "description": "My pup", "iface": "eth0"}, resolved)
+
+	manifest, _ := os.ReadFile(filepath.Join(dir, "manifest.json"))
+	assert.Equal(t, `{"name": "mypup", "description": "My pup", "port": 8080}`, string(manifest))
+	nix, _ := os.ReadFile(filepath.Join(dir, "pup.nix"))
+	assert.Equal(t, `{ iface = "eth0"; name = "mypup"; }`, string(nix))
+
+	head, _ := os.ReadFile(filepath.Join(dir, ".git/HEAD"))
+	assert.Equal(t, "pup_basic", string(head))
+	assert.NoFileExists(t, filepath.Join(dir, TEMPLATE_FILE))
+}
+
+func TestApplyWithoutTemplateFileOnlyReplacesName(t *testing.T) {
+	dir := writeTemplate(t, map[string]string{"pup.nix": "pup_basic __PORT__"})
+
+	_, err := Apply(dir, "basic", "mypup", nil)
+	require.NoError(t, err)
+
+	nix, _ := os.ReadFile(filepath.Join(dir, "pup.nix"))
+	assert.Equal(t, "mypup __PORT__", string(nix))
+
+	_, err = Apply(dir, "basic", "mypup", map[string]string{"port": "80"})
+	assert.ErrorContains(t, err, "no variable port")
+}
+
+func TestResolveChecksValues(t *testing.T) {
+	tmpl := Template{Variables: []Variable{
+		{Name: "port", Type: TYPE_PORT},
+		{Name: "description", Default: "A pup"},
+	}}
+
+	_, err := tmpl.Resolve(map[string]string{})
+	assert.ErrorContains(t, err, "port is required")
+
+	_, err = tmpl.Resolve(map[string]string{"port": "70000"})
+	assert.ErrorContains(t, err, "between 1 and 65535")
+
+	_, err = tmpl.Resolve(map[string]string{"port": "80", "description": `say "hi"`})
+	assert.ErrorContains(t, err, "quotes")
+
+	_, err = tmpl.Resolve(map[string]string{"port": "80", "description": "${builtins.readFile /etc/shadow}"})
+	assert.ErrorContains(t, err, "${")
+
+	resolved, err := tmpl.Resolve(map[string]string{"port": "80"})
+	require.NoError(t, err)
+	assert.Equal(t, "A pup", resolved["description"])
+}
+
+func TestValidateRejectsBadDeclarations(t *testing.T) {
+	assert.Error(t, Template{Variables: []Variable{{Name: "Port"}}}.Validate())
+	assert.Error(t, Template{Variables: []Variable{{Name: "a"}, {Name: "a"}}}.Validate())
+	assert.Error(t, Template{Variables: []Variable{{Name: "a", Type: "colour"}}}.Validate())
+	assert.Error(t, Template{Variables: []Variable{{Name: "a", Type: TYPE_NUMBER, Default: "x"}}}.Validate())
+}
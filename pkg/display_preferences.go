@@ -0,0 +1,234 @@
+package dogeboxd
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	CLOCK_FORMAT_12H = "12h"
+	CLOCK_FORMAT_24H = "24h"
+
+	// Sizes in powers of 1024, ie: 1.5 GiB, or of 1000, ie: 1.6 GB.
+	UNITS_BINARY  = "binary"
+	UNITS_DECIMAL = "decimal"
+)
+
+var localePattern = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8})*$`)
+
+// Regions that write the month before the day.
+var monthFirstRegions = map[string]bool{"US": true, "PH": true}
+
+// Languages that write dates with dots, ie: 17.10.2026.
+var dottedDateLanguages = map[string]bool{
+	"cs": true, "da": true, "de": true, "fi": true, "nb": true, "no": true,
+	"pl": true, "ru": true, "sk": true, "tr": true, "uk": true,
+}
+
+// Languages that use a decimal comma, ie: 1,5 GiB.
+var decimalCommaLanguages = map[string]bool{
+	"cs": true, "da": true, "de": true, "es": true, "fi": true, "fr": true,
+	"id": true, "it": true, "nb": true, "nl": true, "no": true, "pl": true,
+	"pt": true, "ru": true, "sk": true, "sv": true, "tr": true, "uk": true,
+	"vi": true,
+}
+
+/* DisplayPreferences are how the user likes times, dates and sizes
+ * written, honoured by the text dogeboxd generates itself, ie: job
+ * summaries, usage summaries and webhook messages, and passed on to the
+ * dpanel and TUIs in the bootstrap. Zero values mean our defaults.
+ */
+type DisplayPreferences struct {
+	// CLOCK_FORMAT_12H or CLOCK_FORMAT_24H.
+	ClockFormat string `json:"clockFormat"`
+	// UNITS_BINARY or UNITS_DECIMAL.
+	Units string `json:"units"`
+	// The lowercase English name of a day, ie: monday.
+	FirstDayOfWeek string `json:"firstDayOfWeek"`
+	// A BCP 47 language tag, ie: en-US, deciding the order of dates and
+	// the decimal separator. Empty means ISO 8601 dates.
+	Locale string `json:"locale"`
+}
+
+func (p DisplayPreferences) Validate() error {
+	switch p.ClockFormat {
+	case "", CLOCK_FORMAT_12H, CLOCK_FORMAT_24H:
+	default:
+		return fmt.Errorf("clockFormat must be %q or %q", CLOCK_FORMAT_12H, CLOCK_FORMAT_24H)
+	}
+	switch p.Units {
+	case "", UNITS_BINARY, UNITS_DECIMAL:
+	default:
+		return fmt.Errorf("units must be %q or %q", UNITS_BINARY, UNITS_DECIMAL)
+	}
+	if p.FirstDayOfWeek != "" {
+		if _, ok := parseWeekday(p.FirstDayOfWeek); !ok {
+			return fmt.Errorf("firstDayOfWeek %q isn't a day of the week", p.FirstDayOfWeek)
+		}
+	}
+	if p.Locale != "" && !localePattern.MatchString(p.Locale) {
+		return fmt.Errorf("locale %q isn't a language tag, ie: en-US", p.Locale)
+	}
+	return nil
+}
+
+// WithDefaults fills in any unset preferences.
+func (p DisplayPreferences) WithDefaults() DisplayPreferences {
+	if p.ClockFormat == "" {
+		p.ClockFormat = CLOCK_FORMAT_24H
+	}
+	if p.Units == "" {
+		p.Units = UNITS_BINARY
+	}
+	if p.FirstDayOfWeek == "" {
+		p.FirstDayOfWeek = "monday"
+	}
+	return p
+}
+
+func parseWeekday(name string) (time.Weekday, bool) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(d.String(), name) {
+			return d, true
+		}
+	}
+	return time.Sunday, false
+}
+
+/* A DisplayFormat writes things out following DisplayPreferences, in
+ * the box's timezone.
+ */
+type DisplayFormat struct {
+	Preferences DisplayPreferences
+	Location    *time.Location
+}
+
+// NewDisplayFormat returns the DisplayFormat for the box's state, times
+// are in its configured timezone, or the system's if it has none.
+func NewDisplayFormat(s DogeboxState) DisplayFormat {
+	loc := time.Local
+	if s.Timezone != "" {
+		if l, err := time.LoadLocation(s.Timezone); err == nil {
+			loc = l
+		}
+	}
+	return DisplayFormat{Preferences: s.DisplayPreferences.WithDefaults(), Location: loc}
+}
+
+func DefaultDisplayFormat() DisplayFormat {
+	return DisplayFormat{Preferences: DisplayPreferences{}.WithDefaults(), Location: time.Local}
+}
+
+// DisplayFormatSource returns the current DisplayFormat, for things that
+// outlive a change of preferences. A nil source gives the defaults.
+type DisplayFormatSource func() DisplayFormat
+
+func (s DisplayFormatSource) Get() DisplayFormat {
+	if s == nil {
+		return DefaultDisplayFormat()
+	}
+	return s()
+}
+
+func (f DisplayFormat) in(t time.Time) time.Time {
+	if f.Location == nil {
+		return t
+	}
+	return t.In(f.Location)
+}
+
+func (f DisplayFormat) language() (lang string, region string) {
+	parts := strings.Split(f.Preferences.Locale, "-")
+	lang = strings.ToLower(parts[0])
+	for _, p := range parts[1:] {
+		if len(p) == 2 {
+			region = strings.ToUpper(p)
+		}
+	}
+	return lang, region
+}
+
+// Time writes the time of day, ie: 15:04 or 3:04 PM.
+func (f DisplayFormat) Time(t time.Time) string {
+	if f.Preferences.ClockFormat == CLOCK_FORMAT_12H {
+		return f.in(t).Format("3:04 PM")
+	}
+	return f.in(t).Format("15:04")
+}
+
+// Date writes the date in the locale's order, ie: 10/17/2026 for en-US.
+func (f DisplayFormat) Date(t time.Time) string {
+	t = f.in(t)
+	if f.Preferences.Locale == "" {
+		return t.Format("2006-01-02")
+	}
+	lang, region := f.language()
+	switch {
+	case monthFirstRegions[region]:
+		return t.Format("01/02/2006")
+	case dottedDateLanguages[lang]:
+		return t.Format("02.01.2006")
+	default:
+		return t.Format("02/01/2006")
+	}
+}
+
+func (f DisplayFormat) DateTime(t time.Time) string {
+	return f.Date(t) + " " + f.Time(t)
+}
+
+// Number writes v with the locale's decimal separator.
+func (f DisplayFormat) Number(v float64, decimals int) string {
+	s := strconv.FormatFloat(v, 'f', decimals, 64)
+	if lang, _ := f.language(); decimalCommaLanguages[lang] {
+		s = strings.Replace(s, ".", ",", 1)
+	}
+	return s
+}
+
+// Bytes writes a size in the preferred units, ie: 1.5 GiB or 1.6 GB.
+func (f DisplayFormat) Bytes(n int64) string {
+	unit, suffix := int64(1024), "iB"
+	if f.Preferences.Units == UNITS_DECIMAL {
+		unit, suffix = 1000, "B"
+	}
+
+	sign := ""
+	if n < 0 {
+		sign, n = "-", -n
+	}
+	if n < unit {
+		return fmt.Sprintf("%s%d B", sign, n)
+	}
+	div, exp := unit, 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	prefix := string("KMGTPE"[exp])
+	if unit == 1000 && exp == 0 {
+		prefix = "k"
+	}
+	return sign + f.Number(float64(n)/float64(div), 1) + " " + prefix + suffix
+}
+
+// WeekStart returns the midnight that starts t's week, in the box's
+// timezone, weeks starting on the preferred day.
+func (f DisplayFormat) WeekStart(t time.Time) time.Time {
+	first, ok := parseWeekday(f.Preferences.FirstDayOfWeek)
+	if !ok {
+		first = time.Monday
+	}
+	return WeekStart(f.in(t), first)
+}
+
+// WeekStart returns the midnight that starts t's week, in t's location,
+// weeks starting on first.
+func WeekStart(t time.Time, first time.Weekday) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	offset := (int(day.Weekday()) - int(first) + 7) % 7
+	return day.AddDate(0, 0, -offset)
+}
@@ -0,0 +1,62 @@
+package dogeboxd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDisplayPreferencesValidate(t *testing.T) {
+	assert.NoError(t, DisplayPreferences{}.Validate())
+	assert.NoError(t, DisplayPreferences{ClockFormat: "12h", Units: "decimal", FirstDayOfWeek: "Sunday", Locale: "en-US"}.Validate())
+
+	assert.Error(t, DisplayPreferences{ClockFormat: "13h"}.Validate())
+	assert.Error(t, DisplayPreferences{Units: "imperial"}.Validate())
+	assert.Error(t, DisplayPreferences{FirstDayOfWeek: "someday"}.Validate())
+	assert.Error(t, DisplayPreferences{Locale: "en_US.UTF-8"}.Validate())
+}
+
+func TestDisplayFormat(t *testing.T) {
+	loc := time.FixedZone("test", 10*60*60)
+	at := time.Date(2026, 10, 17, 5, 4, 0, 0, time.UTC)
+
+	f := NewDisplayFormat(DogeboxState{})
+	f.Location = loc
+	assert.Equal(t, "15:04", f.Time(at))
+	assert.Equal(t, "2026-10-17 15:04", f.DateTime(at))
+	assert.Equal(t, "1.5 KiB", f.Bytes(1536))
+	assert.Equal(t, "512 B", f.Bytes(512))
+
+	us := DisplayFormat{Preferences: DisplayPreferences{ClockFormat: "12h", Units: "decimal", Locale: "en-US"}.WithDefaults(), Location: loc}
+	assert.Equal(t, "3:04 PM", us.Time(at))
+	assert.Equal(t, "10/17/2026", us.Date(at))
+	assert.Equal(t, "1.5 kB", us.Bytes(1536))
+	assert.Equal(t, "2.0 GB", us.Bytes(2_000_000_000))
+
+	de := DisplayFormat{Preferences: DisplayPreferences{Locale: "de-DE"}.WithDefaults(), Location: loc}
+	assert.Equal(t, "17.10.2026", de.Date(at))
+	assert.Equal(t, "1,5 KiB", de.Bytes(1536))
+
+	gb := DisplayFormat{Preferences: DisplayPreferences{Locale: "en-GB"}.WithDefaults(), Location: loc}
+	assert.Equal(t, "17/10/2026", gb.Date(at))
+}
+
+func TestDisplayFormatWeekStart(t *testing.T) {
+	loc := time.FixedZone("test", -5*60*60)
+	// Monday 02:00 UTC is still Sunday evening in loc.
+	at := time.Date(2026, 10, 19, 2, 0, 0, 0, time.UTC)
+
+	monday := DisplayFormat{Preferences: DisplayPreferences{}.WithDefaults(), Location: loc}
+	assert.Equal(t, time.Date(2026, 10, 12, 0, 0, 0, 0, loc), monday.WeekStart(at))
+
+	sunday := DisplayFormat{Preferences: DisplayPreferences{FirstDayOfWeek: "sunday"}.WithDefaults(), Location: loc}
+	assert.Equal(t, time.Date(2026, 10, 18, 0, 0, 0, 0, loc), sunday.WeekStart(at))
+}
+
+func TestNewDisplayFormatUsesTimezone(t *testing.T) {
+	f := NewDisplayFormat(DogeboxState{Timezone: "UTC", DisplayPreferences: DisplayPreferences{ClockFormat: "12h"}})
+	assert.Equal(t, time.UTC, f.Location)
+	assert.Equal(t, CLOCK_FORMAT_12H, f.Preferences.ClockFormat)
+	assert.Equal(t, UNITS_BINARY, f.Preferences.Units)
+}
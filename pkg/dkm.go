@@ -14,6 +14,11 @@ type DKMManager interface {
 	RefreshToken(old string) (string, bool, error)
 	InvalidateToken(token string) (bool, error)
 	MakeDelegate(id string, token string) (DKMResponseMakeDelegate, error)
+	// RevokeDelegate forgets the delegate made for id, so its key can't be
+	// used again. Revoking one that doesn't exist isn't an error.
+	RevokeDelegate(id string) error
+	// ListDelegates returns the id of every delegate DKM has made.
+	ListDelegates() ([]string, error)
 	// ChangePassword changes the master key password. Requires either current_password or seedphrase, and new_password.
 	ChangePassword(currentPassword string, seedphrase string, newPassword string) error
 }
@@ -48,6 +53,18 @@ type DKMErrorResponseMakeDelegate struct {
 	Reason string `json:"reason"`
 }
 
+type DKMRequestRevokeDelegate struct {
+	ID string `json:"id"`
+}
+
+type DKMResponseRevokeDelegate struct {
+	Revoked bool `json:"revoked"`
+}
+
+type DKMResponseListDelegates struct {
+	Delegates []string `json:"delegates"`
+}
+
 type DKMResponseInvalidateToken struct{}
 
 type DKMResponseChangePassword struct {
@@ -166,6 +183,42 @@ func (t dkmManager) MakeDelegate(id string, token string) (DKMResponseMakeDelega
 	return result, nil
 }
 
+func (t dkmManager) RevokeDelegate(id string) error {
+	var result DKMResponseRevokeDelegate
+	var errorResponse DKMErrorResponse
+
+	_, err := t.client.R().SetBody(DKMRequestRevokeDelegate{ID: id}).SetResult(&result).SetError(&errorResponse).Post("/revoke-delegate")
+	if err != nil {
+		log.Printf("Failed to contact DKM revoking delegate: %v", err)
+		return err
+	}
+
+	if errorResponse.Error != "" {
+		log.Printf("Error from DKM RevokeDelegate: [%s] %s", errorResponse.Error, errorResponse.Reason)
+		return errors.New(errorResponse.Reason)
+	}
+
+	return nil
+}
+
+func (t dkmManager) ListDelegates() ([]string, error) {
+	var result DKMResponseListDelegates
+	var errorResponse DKMErrorResponse
+
+	_, err := t.client.R().SetResult(&result).SetError(&errorResponse).Get("/delegates")
+	if err != nil {
+		log.Printf("Failed to contact DKM listing delegates: %v", err)
+		return nil, err
+	}
+
+	if errorResponse.Error != "" {
+		log.Printf("Error from DKM ListDelegates: [%s] %s", errorResponse.Error, errorResponse.Reason)
+		return nil, errors.New(errorResponse.Reason)
+	}
+
+	return result.Delegates, nil
+}
+
 func (t dkmManager) ChangePassword(currentPassword string, seedphrase string, newPassword string) error {
 	var result DKMResponseChangePassword
 	var errorResponse DKMErrorResponse
@@ -0,0 +1,87 @@
+package dogeboxd
+
+import (
+	"context"
+	"log"
+	"slices"
+	"sort"
+	"time"
+)
+
+// How often installed pups are checked against DKM's delegates. The first
+// check waits a full interval, so pups have long since been loaded.
+const delegateReconcileInterval = time.Hour
+
+// Delegates DKM holds for dogeboxd itself rather than for a pup.
+var reservedDelegateIDs = []string{CONFIG_SECRETS_DELEGATE_ID}
+
+/* DelegateReconciler revokes DKM delegates whose pup is no longer
+ * installed. Purging a pup revokes its delegate, this catches those that
+ * couldn't be, ie: when DKM was down, and any from before we did.
+ */
+type DelegateReconciler struct {
+	dkm  DKMManager
+	pups PupManager
+}
+
+func NewDelegateReconciler(dkm DKMManager, pups PupManager) *DelegateReconciler {
+	return &DelegateReconciler{dkm: dkm, pups: pups}
+}
+
+func (r *DelegateReconciler) Run(started, stopped chan bool, stop chan context.Context) error {
+	go func() {
+		go func() {
+			ticker := time.NewTicker(delegateReconcileInterval)
+			defer ticker.Stop()
+		mainloop:
+			for {
+				select {
+				case <-stop:
+					break mainloop
+				case <-ticker.C:
+					if _, err := r.Reconcile(); err != nil {
+						log.Printf("Failed to reconcile DKM delegates: %v", err)
+					}
+				}
+			}
+		}()
+
+		started <- true
+		<-stop
+		stopped <- true
+	}()
+	return nil
+}
+
+// Reconcile revokes every stale delegate, returning how many it revoked.
+func (r *DelegateReconciler) Reconcile() (int, error) {
+	delegates, err := r.dkm.ListDelegates()
+	if err != nil {
+		return 0, err
+	}
+
+	revoked := 0
+	for _, id := range StaleDelegates(delegates, r.pups.GetStateMap()) {
+		if err := r.dkm.RevokeDelegate(id); err != nil {
+			log.Printf("Failed to revoke stale delegate %s: %v", id, err)
+			continue
+		}
+		log.Printf("Revoked delegate %s, its pup is no longer installed", id)
+		revoked++
+	}
+	return revoked, nil
+}
+
+// StaleDelegates returns the delegates that aren't for a pup in pups, or
+// reserved for dogeboxd, sorted.
+func StaleDelegates(delegates []string, pups map[string]PupState) []string {
+	stale := []string{}
+	for _, id := range delegates {
+		if _, ok := pups[id]; ok || slices.Contains(reservedDelegateIDs, id) {
+			continue
+		}
+		stale = append(stale, id)
+	}
+	sort.Strings(stale)
+	return stale
+}
@@ -0,0 +1,55 @@
+package dogeboxd
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubDelegatesDKM struct {
+	DKMManager
+	delegates []string
+	revoked   []string
+	failOn    string
+}
+
+func (d *stubDelegatesDKM) ListDelegates() ([]string, error) {
+	return d.delegates, nil
+}
+
+func (d *stubDelegatesDKM) RevokeDelegate(id string) error {
+	if id == d.failOn {
+		return errors.New("dkm said no")
+	}
+	d.revoked = append(d.revoked, id)
+	return nil
+}
+
+type delegatesPupManager struct {
+	PupManager
+	pups map[string]PupState
+}
+
+func (m delegatesPupManager) GetStateMap() map[string]PupState {
+	return m.pups
+}
+
+func TestStaleDelegates(t *testing.T) {
+	pups := map[string]PupState{"pup-a": {ID: "pup-a"}}
+	delegates := []string{"pup-c", "pup-a", CONFIG_SECRETS_DELEGATE_ID, "pup-b"}
+
+	assert.Equal(t, []string{"pup-b", "pup-c"}, StaleDelegates(delegates, pups))
+	assert.Empty(t, StaleDelegates(nil, pups))
+}
+
+func TestDelegateReconcilerRevokesStaleDelegates(t *testing.T) {
+	dkm := &stubDelegatesDKM{delegates: []string{"pup-a", "pup-b", "pup-c"}, failOn: "pup-c"}
+	pups := delegatesPupManager{pups: map[string]PupState{"pup-a": {ID: "pup-a"}}}
+
+	revoked, err := NewDelegateReconciler(dkm, pups).Reconcile()
+	require.NoError(t, err)
+	assert.Equal(t, 1, revoked)
+	assert.Equal(t, []string{"pup-b"}, dkm.revoked)
+}
@@ -42,23 +42,27 @@ import (
 	"crypto/rand"
 	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os/exec"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/Dogebox-WG/dogeboxd/pkg/rootd"
 	"github.com/golanglibs/gocollections/set/hashset"
 )
 
 type syncQueue struct {
-	jobQueue            []Job               // pending jobs waiting to be handed to SystemUpdater
-	nonQueuedActiveJobs hashset.Set[string] // runtime-active jobs that are not currently in jobQueue
-	currentSystemJobID  string              // the single job currently handed to SystemUpdater
-	jobQLock            sync.Mutex
-	jobInProgress       sync.Mutex
-	jobTimer            time.Time
+	jobQueue               []Job               // pending jobs waiting to be handed to SystemUpdater
+	nonQueuedActiveJobs    hashset.Set[string] // runtime-active jobs that are not currently in jobQueue
+	currentSystemJobID     string              // the single job currently handed to SystemUpdater
+	currentSystemJob       *Job                // the job for currentSystemJobID, persisted with the queue
+	currentSystemJobAction string              // ActionName of currentSystemJobID, for metrics
+	jobQLock               sync.Mutex
+	jobInProgress          sync.Mutex
+	jobTimer               time.Time
 }
 
 type Dogeboxd struct {
@@ -73,9 +77,19 @@ type Dogeboxd struct {
 	nix              NixManager
 	logtailer        LogTailer
 	queue            *syncQueue
+	pendingRestarts  *dependentRestarts // waiting on an upgraded provider, see restartDependents
 	jobs             chan Job
 	Changes          chan Change
 	JobManager       *JobManager
+	JobScheduler     *JobScheduler
+	Webhooks         *WebhookNotifier
+	Backups          *BackupCatalog
+	StateSnapshots   *StateSnapshotter
+	UsageReports     *UsageReporter
+	WebUISSO         *WebUISSO
+	SourceRefresher  *SourceRefresher
+	PupLogRotator    *PupLogRotator
+	ConfigSecrets    *ConfigSecretStore
 	config           *ServerConfig
 }
 
@@ -115,6 +129,7 @@ func NewDogeboxd(
 		nix:              nixManager,
 		logtailer:        logtailer,
 		queue:            &q,
+		pendingRestarts:  &dependentRestarts{},
 		jobs:             make(chan Job, 256),
 		Changes:          make(chan Change, 256),
 		config:           config,
@@ -130,6 +145,71 @@ func (t *Dogeboxd) SetJobManager(jm *JobManager) {
 	t.JobManager = jm
 }
 
+func (t *Dogeboxd) SetJobScheduler(js *JobScheduler) {
+	t.JobScheduler = js
+}
+
+// SetWebhookNotifier sets where finished jobs are sent, see WebhookNotifier.
+func (t *Dogeboxd) SetWebhookNotifier(n *WebhookNotifier) {
+	n.format = t.DisplayFormat
+	t.Webhooks = n
+}
+
+// SetBackupCatalog sets the index of backups browsed through the API.
+func (t *Dogeboxd) SetBackupCatalog(c *BackupCatalog) {
+	t.Backups = c
+}
+
+// SetStateSnapshotter sets where the automatic state snapshots taken
+// before risky jobs are listed from.
+func (t *Dogeboxd) SetStateSnapshotter(s *StateSnapshotter) {
+	t.StateSnapshots = s
+}
+
+// SetUsageReporter sets where weekly usage summaries are kept, see UsageReporter.
+func (t *Dogeboxd) SetUsageReporter(r *UsageReporter) {
+	r.format = t.DisplayFormat
+	t.UsageReports = r
+}
+
+// SetSourceRefresher sets what keeps source listings fresh, see
+// SourceRefresher.
+func (t *Dogeboxd) SetSourceRefresher(r *SourceRefresher) {
+	r.sendChange = t.SendChange
+	t.SourceRefresher = r
+}
+
+// SetSourceWatcher sets what watches disk sources for edits, see
+// SourceWatcher.
+func (t *Dogeboxd) SetSourceWatcher(w *SourceWatcher) {
+	w.sendChange = t.SendChange
+	w.addAction = func(a Action) string { return t.AddActionAs(a, JOB_ACTOR_SYSTEM) }
+}
+
+// SetPupLogRotator sets what keeps pup logs within their retention, see
+// PupLogRotator.
+func (t *Dogeboxd) SetPupLogRotator(r *PupLogRotator) {
+	t.PupLogRotator = r
+}
+
+// SetConfigSecrets sets what keeps secret config fields, see ConfigSecretStore.
+func (t *Dogeboxd) SetConfigSecrets(s *ConfigSecretStore) {
+	t.ConfigSecrets = s
+}
+
+// SetWebUISSO sets what signs the dPanel user into pup WebUIs, see WebUISSO.
+func (t *Dogeboxd) SetWebUISSO(s *WebUISSO) {
+	t.WebUISSO = s
+}
+
+// DisplayFormat follows the user's current DisplayPreferences.
+func (t Dogeboxd) DisplayFormat() DisplayFormat {
+	if t.sm == nil {
+		return DefaultDisplayFormat()
+	}
+	return NewDisplayFormat(t.sm.Get().Dogebox)
+}
+
 // Main Dogeboxd goroutine, handles routing messages in
 // and out of the system via job and change channels,
 // handles messages from subsystems ie: SystemUpdater,
@@ -143,8 +223,10 @@ func (t Dogeboxd) Run(started, stopped chan bool, stop chan context.Context) err
 		go func() {
 			queueTicker := time.NewTicker(100 * time.Millisecond)
 			orphanTicker := time.NewTicker(60 * time.Second)
+			readyTicker := time.NewTicker(5 * time.Second)
 			defer queueTicker.Stop()
 			defer orphanTicker.Stop()
+			defer readyTicker.Stop()
 
 			// Create channels once outside the loop
 			pupdateChannel := t.Pups.GetUpdateChannel()
@@ -160,7 +242,7 @@ func (t Dogeboxd) Run(started, stopped chan bool, stop chan context.Context) err
 				// Handle shutdown
 				case <-stop:
 					// Stop the update checker
-					updateCheckerStop <- true
+					close(updateCheckerStop)
 					break mainloop
 
 				// Hand incoming jobs to the Job Dispatcher
@@ -193,7 +275,7 @@ func (t Dogeboxd) Run(started, stopped chan bool, stop chan context.Context) err
 					if p.Event == PUP_PURGED {
 						t.SendChange(Change{ID: "internal", Type: "pup_purged", Update: map[string]string{"pupId": p.State.ID}})
 					} else {
-						t.SendChange(Change{ID: "internal", Type: "pup", Update: p.State})
+						t.SendChange(Change{ID: "internal", Type: "pup", Update: p.State, Reason: p.Reason})
 					}
 
 				// Handle stats from PupManager
@@ -216,31 +298,64 @@ func (t Dogeboxd) Run(started, stopped chan bool, stop chan context.Context) err
 					if !ok {
 						break dance
 					}
+					observeJobRun(j, time.Since(t.queue.jobTimer))
 					j.Logger.Step("queue").Progress(100).Log(fmt.Sprintf("finished in %.2fs, queued %.2fs", time.Since(t.queue.jobTimer).Seconds(), time.Since(j.Start).Seconds()))
 
+					// Transient failures of retryable jobs go back on the
+					// queue rather than completing.
+					if t.retryJob(j) {
+						t.clearCurrentSystemJobID(j.ID)
+						t.queue.jobInProgress.Unlock()
+						break dance
+					}
+
 					// if this job was successful, AND it was a
 					// job that results in the stop/start of a pup,
 					// tell the PupManager to poll for state changes
 					switch j.A.(type) {
-					case InstallPup:
+					case InstallPup, ImportPup, InstallPupBundle:
 						t.Pups.FastPollPup(j.State.ID)
 						// Check for updates at the new version (will overwrite stale cache entry)
 						if j.State != nil {
 							go t.PupUpdateChecker.CheckForUpdates(j.State.ID)
 						}
+						if a, ok := j.A.(InstallPup); ok && a.ProvideFor != "" && j.Err == "" && j.State != nil {
+							t.setInstalledProvider(j, a)
+						}
 					case EnablePup:
 						t.Pups.FastPollPup(j.State.ID)
 					case DisablePup:
 						t.Pups.FastPollPup(j.State.ID)
+					case StartPup:
+						t.Pups.FastPollPup(j.State.ID)
 					case UpdatePupProviders:
 						t.Pups.FastPollPup(j.State.ID)
 					case UpgradePup:
+						t.Pups.ResetPupHealth(j.State.ID)
 						t.Pups.FastPollPup(j.State.ID)
 						// Check for updates at the new version (will overwrite stale cache entry)
 						if j.Err == "" && j.State != nil {
 							go t.PupUpdateChecker.CheckForUpdates(j.State.ID)
 						}
+						if a := j.A.(UpgradePup); a.RestartDependents && j.Err == "" && j.State != nil {
+							t.restartDependents(j)
+						}
+						if a := j.A.(UpgradePup); a.CanaryMinutes > 0 && j.Err == "" && j.State != nil {
+							t.startPupCanary(j, a)
+						}
+					case BulkPupAction:
+						a := j.A.(BulkPupAction)
+						for _, id := range a.PupIDs {
+							t.Pups.FastPollPup(id)
+							if a.Operation == BULK_PUP_UPGRADE {
+								go t.PupUpdateChecker.CheckForUpdates(id)
+							}
+						}
+					case RestartPup, RebuildDevPup:
+						t.Pups.ResetPupHealth(j.State.ID)
+						t.Pups.FastPollPup(j.State.ID)
 					case RollbackPupUpgrade:
+						t.Pups.ResetPupHealth(j.State.ID)
 						t.Pups.FastPollPup(j.State.ID)
 						// Check for updates at the rolled-back version (will overwrite stale cache entry)
 						if j.Err == "" && j.State != nil {
@@ -249,13 +364,17 @@ func (t Dogeboxd) Run(started, stopped chan bool, stop chan context.Context) err
 					case UninstallPup:
 						t.Pups.FastPollPup(j.State.ID)
 						t.PupUpdateChecker.ClearCacheEntry(j.State.ID)
+						if a := j.A.(UninstallPup); len(a.RemoveProviders) > 0 && j.Err == "" && j.State != nil {
+							t.uninstallOrphanedProviders(j, a)
+						}
 					case PurgePup:
 						t.Pups.FastPollPup(j.State.ID)
 						t.PupUpdateChecker.ClearCacheEntry(j.State.ID)
 					}
 
 					// TODO: explain why we I this
-					if j.Err == "" && j.State != nil {
+					// Exports keep the export they wrote as their Success.
+					if _, isExport := j.A.(ExportPup); j.Err == "" && j.State != nil && !isExport {
 						state, _, err := t.Pups.GetPup(j.State.ID)
 						if err == nil {
 							j.Success = state
@@ -264,7 +383,7 @@ func (t Dogeboxd) Run(started, stopped chan bool, stop chan context.Context) err
 
 					// Update job record as completed/failed
 					if t.JobManager != nil {
-						err := t.JobManager.CompleteJob(j.ID, j.Err)
+						err := t.JobManager.CompleteJobWithError(j.ID, t.jobError(j))
 						if err == nil {
 							jobRecord, getErr := t.JobManager.GetJob(j.ID)
 							if getErr == nil {
@@ -274,6 +393,9 @@ func (t Dogeboxd) Run(started, stopped chan bool, stop chan context.Context) err
 					}
 
 					t.sendFinishedJob("action", j)
+					if IsPendingChangeAction(j.A) && t.sm != nil {
+						t.SendChange(Change{ID: "internal", Type: "pending-changes", Update: t.sm.Get().Dogebox.PendingChanges})
+					}
 					// Only clear this after completion so the orphaned job monitor
 					// doesn't mistakenly pick it up as missing from runtime state.
 					t.clearCurrentSystemJobID(j.ID)
@@ -285,6 +407,11 @@ func (t Dogeboxd) Run(started, stopped chan bool, stop chan context.Context) err
 					if _, err := t.DetectAndMarkOrphanedJobs(); err != nil {
 						fmt.Printf("Warning: failed to detect orphaned jobs: %v\n", err)
 					}
+					t.revertExpiredPupLogLevels(time.Now())
+					t.disableExpiredSSH(time.Now())
+					t.checkPupCanaries(time.Now())
+				case <-readyTicker.C:
+					t.checkDependentRestarts(time.Now())
 				}
 			}
 		}()
@@ -309,11 +436,16 @@ func (t *Dogeboxd) pumpQueue() {
 			job := t.queue.jobQueue[0]
 			t.queue.jobQueue = t.queue.jobQueue[1:]
 			t.queue.currentSystemJobID = job.ID
+			t.queue.currentSystemJob = &job
+			t.queue.currentSystemJobAction = jobActionName(job)
+			t.queue.jobTimer = time.Now()
+			metricJobQueueLength.Set(float64(len(t.queue.jobQueue)))
+			t.persistQueueLocked()
 			t.queue.jobQLock.Unlock()
 
+			observeJobQueueWait(job, time.Since(job.Start))
 			job.Logger.Step("queue").Log(fmt.Sprintf("Queued, position %d\n", len(t.queue.jobQueue)))
 			t.SystemUpdater.AddJob(job)
-			t.queue.jobTimer = time.Now()
 		} else {
 			t.queue.jobQLock.Unlock()
 			t.queue.jobInProgress.Unlock()
@@ -327,6 +459,8 @@ func (t *Dogeboxd) enqueue(j Job) {
 	defer t.queue.jobQLock.Unlock()
 	t.queue.nonQueuedActiveJobs.Remove(j.ID)
 	t.queue.jobQueue = append(t.queue.jobQueue, j)
+	metricJobQueueLength.Set(float64(len(t.queue.jobQueue)))
+	t.persistQueueLocked()
 }
 
 func (t *Dogeboxd) markNonQueuedActiveJob(jobID string) {
@@ -346,6 +480,9 @@ func (t *Dogeboxd) clearCurrentSystemJobID(jobID string) {
 	defer t.queue.jobQLock.Unlock()
 	if t.queue.currentSystemJobID == jobID {
 		t.queue.currentSystemJobID = ""
+		t.queue.currentSystemJob = nil
+		t.queue.currentSystemJobAction = ""
+		t.persistQueueLocked()
 	}
 }
 
@@ -394,6 +531,8 @@ func (t *Dogeboxd) RemoveFromQueue(jobID string) bool {
 
 		t.queue.jobQueue = append(t.queue.jobQueue[:i], t.queue.jobQueue[i+1:]...)
 		t.queue.nonQueuedActiveJobs.Remove(jobID)
+		metricJobQueueLength.Set(float64(len(t.queue.jobQueue)))
+		t.persistQueueLocked()
 		return true
 	}
 
@@ -440,6 +579,197 @@ func (t *Dogeboxd) DetectAndMarkOrphanedJobs() ([]string, error) {
 	return orphaned, nil
 }
 
+// revertExpiredPupLogLevels queues turning debug logging back off for pups
+// whose override has expired. This runs on a ticker rather than a timer per
+// pup so overrides that expired while we were stopped are reverted too.
+func (t *Dogeboxd) revertExpiredPupLogLevels(now time.Time) {
+	for _, id := range ExpiredPupLogLevelOverrides(t.Pups.GetStateMap(), now) {
+		if t.hasQueuedPupLogLevel(id) {
+			continue
+		}
+		t.AddActionAs(SetPupLogLevel{PupID: id, Debug: false}, JOB_ACTOR_SYSTEM)
+	}
+}
+
+// disableExpiredSSH queues disabling SSH once a temporary enable expires,
+// on the same ticker as revertExpiredPupLogLevels for the same reason.
+func (t *Dogeboxd) disableExpiredSSH(now time.Time) {
+	if t.sm == nil || !t.sm.Get().Dogebox.SSH.TemporaryExpired(now) {
+		return
+	}
+	if t.hasQueuedSSHChange() {
+		return
+	}
+	t.AddActionAs(DisableSSH{}, JOB_ACTOR_SYSTEM)
+}
+
+// startPupCanary starts watching a pup upgraded by j, so it can be rolled
+// back if it isn't healthy, see PupCanary.
+func (t *Dogeboxd) startPupCanary(j Job, a UpgradePup) {
+	log := j.Logger.Step("canary")
+	snapshot, err := t.Pups.GetSnapshot(j.State.ID)
+	if err != nil || snapshot == nil {
+		log.Errf("No snapshot to roll back to, not watching the upgrade")
+		return
+	}
+	pup, _, err := t.Pups.GetPup(j.State.ID)
+	if err != nil {
+		log.Errf("Failed to find pup, not watching the upgrade: %v", err)
+		return
+	}
+
+	canary := &PupCanary{
+		JobID:       j.ID,
+		FromVersion: snapshot.Version,
+		ToVersion:   pup.Version,
+		Minutes:     a.CanaryMinutes,
+		Started:     time.Now(),
+		Status:      PUP_CANARY_WATCHING,
+	}
+	if a.UpgradeInstances {
+		states := t.Pups.GetStateMap()
+		for _, id := range FindOtherPupInstances(states, pup.ID) {
+			if states[id].Version != pup.Version {
+				canary.ThenUpgrade = append(canary.ThenUpgrade, id)
+			}
+		}
+	}
+	if _, err := t.Pups.UpdatePup(pup.ID, PupCanaryWatch(canary), WithPupdateReason(PupdateReasonFor(j))); err != nil {
+		log.Errf("Failed to start watching the upgrade: %v", err)
+		return
+	}
+	log.Logf("Watching %s for %d minutes, it'll be rolled back to %s if it isn't healthy", pup.DisplayName(), a.CanaryMinutes, snapshot.Version)
+	if len(canary.ThenUpgrade) > 0 {
+		log.Logf("%d other instance(s) will be upgraded once it passes", len(canary.ThenUpgrade))
+	}
+}
+
+// checkPupCanaries moves each watched canary upgrade along, queueing a
+// rollback for any that have failed.
+func (t *Dogeboxd) checkPupCanaries(now time.Time) {
+	for id, p := range t.Pups.GetStateMap() {
+		if p.Canary == nil {
+			continue
+		}
+		next, changed := p.Canary.Next(p, t.Pups.IsPupReady(id), now)
+		if !changed {
+			continue
+		}
+		if _, err := t.Pups.UpdatePup(id, PupCanaryWatch(&next), WithPupdateReason(PupdateReason{Actor: JOB_ACTOR_SYSTEM})); err != nil {
+			fmt.Printf("Failed to update canary for %s: %v\n", p.DisplayName(), err)
+			continue
+		}
+
+		switch next.Status {
+		case PUP_CANARY_PASSED:
+			fmt.Printf("Canary upgrade of %s to %s passed\n", p.DisplayName(), next.ToVersion)
+			for _, other := range next.ThenUpgrade {
+				t.AddActionAs(UpgradePup{
+					PupID:         other,
+					TargetVersion: next.ToVersion,
+					SourceId:      p.Source.ID,
+					CanaryMinutes: next.Minutes,
+				}, JOB_ACTOR_SYSTEM)
+			}
+		case PUP_CANARY_ABANDONED:
+			fmt.Printf("Stopped watching canary upgrade of %s: %s\n", p.DisplayName(), next.Reason)
+		case PUP_CANARY_ROLLED_BACK:
+			fmt.Printf("Canary upgrade of %s to %s failed, rolling back to %s: %s\n", p.DisplayName(), next.ToVersion, next.FromVersion, next.Reason)
+			t.AddActionAs(RollbackPupUpgrade{
+				PupID:  id,
+				Reason: fmt.Sprintf("canary upgrade to %s %s", next.ToVersion, next.Reason),
+			}, JOB_ACTOR_SYSTEM)
+		}
+	}
+}
+
+// uninstallOrphanedProviders queues an UninstallPup for each of the
+// RemoveProviders the uninstall left providing for nothing.
+func (t *Dogeboxd) uninstallOrphanedProviders(j Job, a UninstallPup) {
+	orphans := map[string]bool{}
+	for _, id := range FindOrphanedProviders(t.Pups.GetStateMap(), j.State.ID) {
+		orphans[id] = true
+	}
+	log := j.Logger.Step("uninstall")
+	for _, id := range a.RemoveProviders {
+		if !orphans[id] {
+			log.Logf("Keeping provider %s, another pup now uses it", id)
+			continue
+		}
+		log.Logf("Queueing uninstall of unused provider %s", id)
+		t.AddActionAs(UninstallPup{PupID: id, ParentJobID: j.ID}, j.Actor)
+	}
+}
+
+// restartDependents queues a RestartPup for each pup depending on the
+// one just upgraded by j. The queue runs them one at a time, each waiting
+// for the provider to be ready before restarting.
+func (t *Dogeboxd) restartDependents(j Job) {
+	dependents := FindDependentPups(t.Pups.GetStateMap(), j.State.ID)
+	if len(dependents) == 0 || t.pendingRestarts == nil {
+		return
+	}
+	j.Logger.Step("upgrade").Logf("%d dependent pup(s) will be restarted once %s is ready", len(dependents), j.State.DisplayName())
+	t.pendingRestarts.add(pendingDependentRestart{
+		providerID: j.State.ID,
+		dependents: dependents,
+		jobID:      j.ID,
+		actor:      j.Actor,
+		deadline:   time.Now().Add(DependentRestartReadyTimeout),
+	})
+}
+
+// checkDependentRestarts queues the restarts of dependents whose
+// upgraded provider is now ready.
+func (t *Dogeboxd) checkDependentRestarts(now time.Time) {
+	if t.pendingRestarts == nil {
+		return
+	}
+	for _, p := range t.pendingRestarts.due(t.Pups.GetStateMap(), t.Pups.IsPupReady, now) {
+		for _, id := range p.dependents {
+			t.AddActionAs(RestartPup{PupID: id, ParentJobID: p.jobID}, p.actor)
+		}
+	}
+}
+
+// hasQueuedPupLogLevel reports whether a SetPupLogLevel for the pup is
+// already queued or running, so a revert isn't queued every tick while
+// a long job holds up the queue.
+func (t *Dogeboxd) hasQueuedPupLogLevel(pupID string) bool {
+	t.queue.jobQLock.Lock()
+	defer t.queue.jobQLock.Unlock()
+
+	jobs := t.queue.jobQueue
+	if t.queue.currentSystemJob != nil {
+		jobs = append([]Job{*t.queue.currentSystemJob}, jobs...)
+	}
+	for _, j := range jobs {
+		if a, ok := j.A.(SetPupLogLevel); ok && a.PupID == pupID {
+			return true
+		}
+	}
+	return false
+}
+
+// hasQueuedSSHChange reports whether SSH is already being enabled or
+// disabled, so an expired temporary enable isn't disabled twice.
+func (t *Dogeboxd) hasQueuedSSHChange() bool {
+	t.queue.jobQLock.Lock()
+	defer t.queue.jobQLock.Unlock()
+
+	jobs := t.queue.jobQueue
+	if t.queue.currentSystemJob != nil {
+		jobs = append([]Job{*t.queue.currentSystemJob}, jobs...)
+	}
+	for _, j := range jobs {
+		switch j.A.(type) {
+		case EnableSSH, EnableSSHTemporary, DisableSSH:
+			return true
+		}
+	}
+	return false
+}
+
 func (t Dogeboxd) shouldSkipQueuedNixCacheJob() bool {
 	t.queue.jobQLock.Lock()
 	defer t.queue.jobQLock.Unlock()
@@ -461,13 +791,19 @@ func (t Dogeboxd) shouldSkipQueuedNixCacheJob() bool {
 // Add an Action to the Action queue, returns a unique ID
 // which can be used to match the outcome in the Event queue
 func (t Dogeboxd) AddAction(a Action) string {
+	return t.AddActionAs(a, JOB_ACTOR_USER)
+}
+
+// AddActionAs is AddAction for Actions not queued by the user, actor
+// being one of the JOB_ACTOR_* consts.
+func (t Dogeboxd) AddActionAs(a Action, actor string) string {
 	b := make([]byte, 16)
 	_, err := rand.Read(b)
 	if err != nil {
 		fmt.Println(">> AddAction: Entropic Failure, add more Overminds.")
 	}
 	id := fmt.Sprintf("%x", b)
-	j := Job{A: a, ID: id}
+	j := Job{A: a, ID: id, Attempt: 1, Actor: actor}
 	j.Logger = NewActionLogger(j, "", t)
 	t.jobs <- j
 	return id
@@ -483,7 +819,7 @@ func (t Dogeboxd) jobDispatcher(j Job) {
 
 	// System actions
 	case InstallPup:
-		t.createPupFromManifest(j, a.PupName, a.PupVersion, a.SourceId, a.Options)
+		t.createPupFromManifest(j, a)
 	case InstallPups:
 		for i, pup := range a {
 			pupJobID := fmt.Sprintf("%s-%d", j.ID, i+1)
@@ -494,36 +830,147 @@ func (t Dogeboxd) jobDispatcher(j Job) {
 				Err:     j.Err,
 				Success: j.Success,
 				Start:   j.Start,
-				Logger:  NewActionLogger(Job{ID: pupJobID}, "", t),
+				Logger:  NewActionLogger(Job{ID: pupJobID, A: pup, Actor: j.Actor}, "", t),
 				State:   j.State,
+				Actor:   j.Actor,
 			}
 			// Create a separate tracked job for each pup in the batch.
 			if record, err := t.createTrackedJobRecord(pupJob); err == nil && record != nil {
 				t.SendChange(Change{ID: "internal", Type: "job:created", Update: record})
 			}
 
-			t.createPupFromManifest(pupJob, pup.PupName, pup.PupVersion, pup.SourceId, pup.Options)
+			t.createPupFromManifest(pupJob, pup)
 		}
 	case UninstallPup:
+		if t.refuseSystemPup(j, a.PupID) {
+			return
+		}
 		t.sendSystemJobWithPupDetails(j, a.PupID)
 	case PurgePup:
+		if t.refuseSystemPup(j, a.PupID) {
+			return
+		}
 		t.sendSystemJobWithPupDetails(j, a.PupID)
 	case EnablePup:
+		if t.refuseInSafeMode(j, "enable pups") {
+			return
+		}
 		// Flip Enabled=true immediately (before job executes) so frontend refreshes mid-job show intended state
-		if _, err := t.Pups.UpdatePup(a.PupID, PupEnabled(true)); err != nil {
+		if _, err := t.Pups.UpdatePup(a.PupID, PupEnabled(true), WithPupdateReason(PupdateReasonFor(j))); err != nil {
 			j.Err = fmt.Sprintf("Failed to set enabled=true: %v", err)
 			t.sendFinishedJob("action", j)
 			return
 		}
 		t.sendSystemJobWithPupDetails(j, a.PupID)
 	case DisablePup:
-		// Flip Enabled=false immediately (before job executes) so frontend refreshes mid-job show intended state
-		if _, err := t.Pups.UpdatePup(a.PupID, PupEnabled(false)); err != nil {
+		// Flip Enabled=false immediately (before job executes) so frontend refreshes mid-job show intended state,
+		// a disabled pup is no longer in maintenance
+		if _, err := t.Pups.UpdatePup(a.PupID, PupEnabled(false), PupMaintenanceMode(nil), WithPupdateReason(PupdateReasonFor(j))); err != nil {
 			j.Err = fmt.Sprintf("Failed to set enabled=false: %v", err)
 			t.sendFinishedJob("action", j)
 			return
 		}
 		t.sendSystemJobWithPupDetails(j, a.PupID)
+	case SetPupAutoStart:
+		if _, err := t.Pups.UpdatePup(a.PupID, PupAutoStart(a.AutoStart), WithPupdateReason(PupdateReasonFor(j))); err != nil {
+			j.Err = fmt.Sprintf("Failed to set autoStart=%t: %v", a.AutoStart, err)
+			t.sendFinishedJob("action", j)
+			return
+		}
+		t.sendSystemJobWithPupDetails(j, a.PupID)
+	case SetPupMaintenance:
+		t.dispatchSetPupMaintenance(j, a)
+	case SetPupRestartSchedule:
+		if err := ValidateRestartSchedule(a.Schedule); err != nil {
+			j.Err = err.Error()
+			t.sendFinishedJob("action", j)
+			return
+		}
+		if _, err := t.Pups.UpdatePup(a.PupID, PupRestartSchedule(a.Schedule), WithPupdateReason(PupdateReasonFor(j))); err != nil {
+			j.Err = fmt.Sprintf("Failed to set restart schedule: %v", err)
+			t.sendFinishedJob("action", j)
+			return
+		}
+		t.sendSystemJobWithPupDetails(j, a.PupID)
+	case SetPupResourceLimits:
+		if a.Limits != nil {
+			if err := a.Limits.Validate(); err != nil {
+				j.Err = err.Error()
+				t.sendFinishedJob("action", j)
+				return
+			}
+		}
+		if _, err := t.Pups.UpdatePup(a.PupID, PupResourceLimitsOverride(a.Limits), WithPupdateReason(PupdateReasonFor(j))); err != nil {
+			j.Err = fmt.Sprintf("Failed to set resource limits: %v", err)
+			t.sendFinishedJob("action", j)
+			return
+		}
+		t.sendSystemJobWithPupDetails(j, a.PupID)
+	case SetPupDevices:
+		pup, _, err := t.Pups.GetPup(a.PupID)
+		if err != nil {
+			j.Err = fmt.Sprintf("Couldn't find pup %s: %v", a.PupID, err)
+			t.sendFinishedJob("action", j)
+			return
+		}
+		if err := ValidateApprovedDevices(pup.Manifest, a.Paths); err != nil {
+			j.Err = err.Error()
+			t.sendFinishedJob("action", j)
+			return
+		}
+		if _, err := t.Pups.UpdatePup(a.PupID, PupApprovedDevices(DeviceApprovals(pup.Manifest, a.Paths)), WithPupdateReason(PupdateReasonFor(j))); err != nil {
+			j.Err = fmt.Sprintf("Failed to set approved devices: %v", err)
+			t.sendFinishedJob("action", j)
+			return
+		}
+		t.sendSystemJobWithPupDetails(j, a.PupID)
+	case SetPupTrustedCAs:
+		dbxState := t.sm.Get().Dogebox
+		for _, id := range a.CAIDs {
+			if _, ok := dbxState.TrustedCA(id); !ok {
+				j.Err = fmt.Sprintf("Trusted CA %s not found", id)
+				t.sendFinishedJob("action", j)
+				return
+			}
+		}
+		if _, err := t.Pups.UpdatePup(a.PupID, PupTrustedCAs(a.CAIDs), WithPupdateReason(PupdateReasonFor(j))); err != nil {
+			j.Err = fmt.Sprintf("Failed to set trusted CAs: %v", err)
+			t.sendFinishedJob("action", j)
+			return
+		}
+		t.sendSystemJobWithPupDetails(j, a.PupID)
+	case SetPupStorageQuota:
+		if err := ValidatePupStorageQuota(a.QuotaMB); err != nil {
+			j.Err = err.Error()
+			t.sendFinishedJob("action", j)
+			return
+		}
+		// Saved by the system updater once the quota is applied.
+		t.sendSystemJobWithPupDetails(j, a.PupID)
+	case SetPupAutoUpdate:
+		t.setPupAutoUpdate(j, a)
+	case SetPupLogRetention:
+		t.setPupLogRetention(j, a)
+	case SetPupLogLevel:
+		if err := ValidatePupDebugLogDuration(a.Duration); err != nil {
+			j.Err = err.Error()
+			t.sendFinishedJob("action", j)
+			return
+		}
+		t.sendSystemJobWithPupDetails(j, a.PupID)
+	case SetPupEnvOverrides:
+		pup, _, err := t.Pups.GetPup(a.PupID)
+		if err != nil {
+			j.Err = fmt.Sprintf("Couldn't find pup %s: %v", a.PupID, err)
+			t.sendFinishedJob("action", j)
+			return
+		}
+		if err := ValidatePupEnvOverrides(pup.Manifest, a.Env); err != nil {
+			j.Err = err.Error()
+			t.sendFinishedJob("action", j)
+			return
+		}
+		t.sendSystemJobWithPupDetails(j, a.PupID)
 
 	// Dogebox actions
 	case UpdatePupConfig:
@@ -539,12 +986,43 @@ func (t Dogeboxd) jobDispatcher(j Job) {
 	case CheckPupUpdates:
 		t.checkPupUpdates(j, a)
 
+	case RefreshSource:
+		// Listing a git source can take a while, don't hold up other jobs.
+		go t.refreshSource(j, a)
+
 	case UpgradePup:
+		if t.refuseSystemPup(j, a.PupID) || t.refusePupInMaintenance(j, a.PupID) {
+			return
+		}
 		t.sendSystemJobWithPupDetails(j, a.PupID)
 
+	case BulkPupAction:
+		t.dispatchBulkPupAction(j, a)
+
 	case RollbackPupUpgrade:
+		if t.refuseSystemPup(j, a.PupID) {
+			return
+		}
+		t.sendSystemJobWithPupDetails(j, a.PupID)
+
+	case RestartPup:
+		t.sendSystemJobWithPupDetails(j, a.PupID)
+
+	case StartPup:
+		t.sendSystemJobWithPupDetails(j, a.PupID)
+
+	case RebuildDevPup:
 		t.sendSystemJobWithPupDetails(j, a.PupID)
 
+	case ExportPup:
+		t.sendSystemJobWithPupDetails(j, a.PupID)
+
+	case ImportPup:
+		t.importPupFromExport(j, a)
+
+	case InstallPupBundle:
+		t.installPupFromBundle(j, a)
+
 	case ImportBlockchainData:
 		t.enqueue(j)
 
@@ -558,6 +1036,9 @@ func (t Dogeboxd) jobDispatcher(j Job) {
 	case EnableSSH:
 		t.enqueue(j)
 
+	case EnableSSHTemporary:
+		t.enqueue(j)
+
 	case DisableSSH:
 		t.enqueue(j)
 
@@ -570,15 +1051,42 @@ func (t Dogeboxd) jobDispatcher(j Job) {
 	case SaveCustomNix:
 		t.enqueue(j)
 
+	case ValidateCustomNix:
+		t.enqueue(j)
+
+	case RestoreNixConfigBackup:
+		t.enqueue(j)
+
+	case BackupNixConfig:
+		t.enqueue(j)
+
 	case AddBinaryCache:
 		t.enqueue(j)
 
 	case RemoveBinaryCache:
 		t.enqueue(j)
 
+	case RemoveTrustedCA:
+		t.enqueue(j)
+
 	case SystemUpdate:
 		t.enqueue(j)
 
+	case SystemUpdateFromFile:
+		t.enqueue(j)
+
+	case ReapplySystemVersion:
+		t.enqueue(j)
+
+	case ApplyPendingChanges:
+		t.enqueue(j)
+
+	case DiscardPendingChanges:
+		t.enqueue(j)
+
+	case DiffPendingChanges:
+		t.enqueue(j)
+
 	case UpdateTimezone:
 		t.enqueue(j)
 
@@ -588,10 +1096,30 @@ func (t Dogeboxd) jobDispatcher(j Job) {
 	case UpdateNixCache:
 		t.enqueue(j)
 
+	case CollectNixGarbage:
+		t.enqueue(j)
+
+	case RollbackSystem:
+		t.enqueue(j)
+
+	case SetSafeMode:
+		t.enqueue(j)
+
+	case SetAPMode:
+		t.enqueue(j)
+
+	case UpdateWifiRegulatoryDomain:
+		t.enqueue(j)
+
 	// Pup router actions
 	case UpdateMetrics:
 		t.Pups.UpdateMetrics(a)
 
+	case UpdatePupStatus:
+		if err := t.Pups.UpdatePupStatus(a); err != nil {
+			fmt.Printf("Failed to update pup status for %s: %v\n", a.PupID, err)
+		}
+
 	default:
 		fmt.Printf("Unknown action type: %v\n", a)
 	}
@@ -600,17 +1128,54 @@ func (t Dogeboxd) jobDispatcher(j Job) {
 /* This is where we create a 'PupState' from a ManifestID
 * and set it to be installed by the SystemUpdater. After
 * this point the Pup has entered a managed state and will
-* only be installable again after this one has been purged.
-*
-* Future: support multiple pup instances per manifest
+* only be installable again after this one has been purged,
+* unless installed as another instance, see AdoptPupOptions.
  */
-func (t *Dogeboxd) createPupFromManifest(j Job, pupName, pupVersion, sourceId string, pupOptions AdoptPupOptions) {
-	// Fetch the correct manifest from the source manager
-	manifest, source, err := t.sources.GetSourceManifest(sourceId, pupName, pupVersion)
+func (t *Dogeboxd) createPupFromManifest(j Job, a InstallPup) {
+	pupID, ok := t.adoptPupFromManifest(j, a.PupName, a.PupVersion, a.SourceId, a.Commit, a.Options)
+	if !ok {
+		return
+	}
+
+	t.installMissingDependencies(j, pupID, a)
+
+	// send the job off to the SystemUpdater to install
+	t.sendSystemJobWithPupDetails(j, pupID)
+}
+
+// adoptPupFromManifest creates the PupState for createPupFromManifest,
+// finishing the job if it can't.
+func (t *Dogeboxd) adoptPupFromManifest(j Job, pupName, pupVersion, sourceId, commit string, pupOptions AdoptPupOptions) (string, bool) {
+	if t.refuseInSafeMode(j, "install "+pupName) {
+		return "", false
+	}
+
+	if pupOptions.DevMode && !t.sm.Get().Dogebox.Profile.AllowsDevModeActions() {
+		j.Err = "Couldn't create pup, development mode isn't available on production boxes"
+		t.sendFinishedJob("action", j)
+		return "", false
+	}
+
+	// Fetch the correct manifest from the source manager, when pinned to
+	// a commit the source we adopt from carries it into PupState.Source.
+	manifest, source, err := t.sources.GetSourceManifestAt(sourceId, commit, pupName, pupVersion)
 	if err != nil {
 		j.Err = fmt.Sprintf("Couldn't create pup, no manifest: %s", err)
 		t.sendFinishedJob("action", j)
-		return
+		return "", false
+	}
+
+	// Pre-flight: refuse pups this hardware can't run, before we get
+	// deep into a nix build that can only fail.
+	preflight := j.Logger.Step("preflight")
+	report := CheckCompatibility(manifest, GetPlatformInfo(t.config.DataDir))
+	for _, warning := range report.Warnings {
+		preflight.Errf("Warning: %s", warning)
+	}
+	if !report.Compatible {
+		j.Err = fmt.Sprintf("Couldn't install pup, unsupported platform: %s", strings.Join(report.Errors, "; "))
+		t.sendFinishedJob("action", j)
+		return "", false
 	}
 
 	// create a new pup for the manifest
@@ -618,10 +1183,116 @@ func (t *Dogeboxd) createPupFromManifest(j Job, pupName, pupVersion, sourceId st
 	if err != nil {
 		j.Err = fmt.Sprintf("Couldn't create pup: %s", err)
 		t.sendFinishedJob("action", j)
+		return "", false
+	}
+
+	return pupID, true
+}
+
+/* importPupFromExport creates a pup from an uploaded export, see
+ * PupExport, then has the SystemUpdater install it and restore its
+ * storage. The export's source has to have been added to this box
+ * already, as we never export source credentials.
+ */
+func (t *Dogeboxd) importPupFromExport(j Job, a ImportPup) {
+	info, err := GetPupExport(*t.config, a.ExportID)
+	if err != nil {
+		j.Err = fmt.Sprintf("Couldn't import pup: %s", err)
+		t.sendFinishedJob("action", j)
+		return
+	}
+	export := info.Export
+
+	sourceID := ""
+	for _, source := range t.sources.GetAllSourceConfigurations() {
+		if source.Location == export.SourceLocation {
+			sourceID = source.ID
+			break
+		}
+	}
+	if sourceID == "" {
+		j.Err = fmt.Sprintf("Couldn't import %s, add its source %s first", export.PupName, export.SourceLocation)
+		t.sendFinishedJob("action", j)
+		return
+	}
+
+	// Resolve providers before adopting, so the new pup can't provide for itself.
+	providers := export.ResolveProviders(t.Pups.GetStateMap())
+
+	pupID, ok := t.adoptPupFromManifest(j, export.PupName, export.PupVersion, sourceID, "", AdoptPupOptions{InstanceName: export.InstanceName})
+	if !ok {
+		return
+	}
+
+	log := j.Logger.Step("import")
+	for iface := range export.Providers {
+		if _, ok := providers[iface]; !ok {
+			log.Errf("Warning: no installed pup matches the provider of %s, pick one once imported", iface)
+		}
+	}
+
+	if _, err := t.Pups.UpdatePup(pupID, SetPupConfig(export.Config), SetPupProviders(providers), PupStorageQuota(export.StorageQuotaMB), WithPupdateReason(PupdateReasonFor(j))); err != nil {
+		j.Err = fmt.Sprintf("Couldn't restore exported settings: %s", err)
+		t.sendFinishedJob("action", j)
+		return
+	}
+
+	t.sendSystemJobWithPupDetails(j, pupID)
+}
+
+/* installPupFromBundle creates a pup from an uploaded bundle, see
+ * PupBundle, then has the SystemUpdater install it from the bundle's
+ * files. If the bundle's source has been added to this box the pup is
+ * installed as from that, otherwise from a stand-in until it is.
+ */
+func (t *Dogeboxd) installPupFromBundle(j Job, a InstallPupBundle) {
+	info, err := GetPupBundle(*t.config, a.BundleID)
+	if err != nil {
+		j.Err = fmt.Sprintf("Couldn't install pup bundle: %s", err)
+		t.sendFinishedJob("action", j)
+		return
+	}
+	bundle := info.Bundle
+
+	var source ManifestSource = bundle.StandInSource()
+	for _, s := range t.sources.GetAllSourceConfigurations() {
+		if s.Location != bundle.Provenance.SourceLocation {
+			continue
+		}
+		if source, err = t.sources.GetSource(s.ID); err != nil {
+			j.Err = fmt.Sprintf("Couldn't install pup bundle: %s", err)
+			t.sendFinishedJob("action", j)
+			return
+		}
+		break
+	}
+
+	preflight := j.Logger.Step("preflight")
+	report := CheckCompatibility(info.Manifest, GetPlatformInfo(t.config.DataDir))
+	for _, warning := range report.Warnings {
+		preflight.Errf("Warning: %s", warning)
+	}
+	if !report.Compatible {
+		j.Err = fmt.Sprintf("Couldn't install pup, unsupported platform: %s", strings.Join(report.Errors, "; "))
+		t.sendFinishedJob("action", j)
+		return
+	}
+
+	pupID, err := t.Pups.AdoptPup(info.Manifest, source, AdoptPupOptions{})
+	if err != nil {
+		j.Err = fmt.Sprintf("Couldn't create pup: %s", err)
+		t.sendFinishedJob("action", j)
+		return
+	}
+
+	provenance := bundle.Provenance
+	provenance.Installed = time.Now()
+	if _, err := t.Pups.UpdatePup(pupID, SetPupProvenance(&provenance), WithPupdateReason(PupdateReasonFor(j))); err != nil {
+		j.Err = fmt.Sprintf("Couldn't record where the pup came from: %s", err)
+		t.sendFinishedJob("action", j)
 		return
 	}
 
-	// send the job off to the SystemUpdater to install
 	t.sendSystemJobWithPupDetails(j, pupID)
 }
 
@@ -633,15 +1304,36 @@ func (t *Dogeboxd) updatePupConfig(j Job, u UpdatePupConfig) {
 	oldState, _, _ := t.Pups.GetPup(u.PupID)
 	wasNeedingConfig := oldState.NeedsConf
 
-	newState, err := t.Pups.UpdatePup(u.PupID, SetPupConfig(u.Payload))
+	if err := ValidateConfigValues(oldState.Manifest.Config, u.Payload); err != nil {
+		j.Err = err.Error()
+		t.sendFinishedJob("action", j)
+		return
+	}
+
+	plain, secrets := SplitSecretConfig(oldState.Manifest.Config, u.Payload)
+	sealed, err := t.ConfigSecrets.Seal(u.PupID, secrets)
+	if err != nil {
+		j.Err = fmt.Sprintf("couldn't save secret config for %s: %v", u.PupID, err)
+		t.sendFinishedJob("action", j)
+		return
+	}
+
+	newState, err := t.Pups.UpdatePup(u.PupID, SetPupConfig(plain), SetPupSecretConfig(sealed), WithPupdateReason(PupdateReasonFor(j)))
 	if err != nil {
 		j.Err = fmt.Sprintf("couldn't update config for %s: %v", u.PupID, err)
 		t.sendFinishedJob("action", j)
 		return
 	}
 
+	config, keep, err := t.ConfigSecrets.PupConfig(newState)
+	if err != nil {
+		j.Err = fmt.Sprintf("failed to read secret config: %v", err)
+		t.sendFinishedJob("action", j)
+		return
+	}
+
 	// Write config to secure storage (inside pup container, not exposed on host)
-	if err := WritePupConfigToStorage(t.config.DataDir, u.PupID, newState.Config, log); err != nil {
+	if err := WritePupConfigToStorage(t.config.DataDir, u.PupID, config, keep, newState.EnvOverrides, log); err != nil {
 		j.Err = fmt.Sprintf("failed to write config to storage: %v", err)
 		t.sendFinishedJob("action", j)
 		return
@@ -649,12 +1341,12 @@ func (t *Dogeboxd) updatePupConfig(j Job, u UpdatePupConfig) {
 
 	// Check if config requirements are now satisfied
 	healthReport := t.Pups.GetPupHealthState(&newState)
-	configNowSatisfied := wasNeedingConfig && !healthReport.NeedsConf && !healthReport.NeedsDeps
+	configNowSatisfied := wasNeedingConfig && !healthReport.NeedsConf && !healthReport.NeedsDeps && !healthReport.NeedsDevices
 
 	// If config is now satisfied and pup isn't enabled, enable it
 	if configNowSatisfied && !newState.Enabled {
 		log.Logf("Config requirements satisfied, enabling pup")
-		newState, err = t.Pups.UpdatePup(u.PupID, PupEnabled(true))
+		newState, err = t.Pups.UpdatePup(u.PupID, PupEnabled(true), WithPupdateReason(PupdateReasonFor(j)))
 		if err != nil {
 			j.Err = fmt.Sprintf("failed to enable pup after config: %v", err)
 			t.sendFinishedJob("action", j)
@@ -673,6 +1365,16 @@ func (t *Dogeboxd) updatePupConfig(j Job, u UpdatePupConfig) {
 		return
 	}
 
+	// A pup that was already running, and can reload its config, is told
+	// to rather than waiting for its next start.
+	if oldState.Enabled && newState.Enabled && newState.SupportsConfigReload() {
+		if err := t.SystemUpdater.ReloadPupConfig(newState, log); err != nil {
+			j.Err = fmt.Sprintf("failed to reload config: %v", err)
+			t.sendFinishedJob("action", j)
+			return
+		}
+	}
+
 	j.Success = newState
 	t.sendFinishedJob("action", j)
 }
@@ -680,7 +1382,7 @@ func (t *Dogeboxd) updatePupConfig(j Job, u UpdatePupConfig) {
 // Handle an UpdatePupProviders action
 func (t *Dogeboxd) updatePupProviders(j Job, u UpdatePupProviders) {
 	log := j.Logger.Step("update providers")
-	_, err := t.Pups.UpdatePup(u.PupID, SetPupProviders(u.Payload))
+	_, err := t.Pups.UpdatePup(u.PupID, SetPupProviders(u.Payload), WithPupdateReason(PupdateReasonFor(j)))
 	if err != nil {
 		j.Err = fmt.Sprintf("Couldnt update: %s", u.PupID)
 		t.sendFinishedJob("action", j)
@@ -720,9 +1422,51 @@ func (t *Dogeboxd) updatePupProviders(j Job, u UpdatePupProviders) {
 	t.sendFinishedJob("action", j)
 }
 
+// Handle a SetPupAutoUpdate action. This is only state, UpdateChecker
+// picks the new policy up on its next tick.
+func (t *Dogeboxd) setPupAutoUpdate(j Job, a SetPupAutoUpdate) {
+	if a.AutoUpdate != nil {
+		if err := a.AutoUpdate.Validate(); err != nil {
+			j.Err = err.Error()
+			t.sendFinishedJob("action", j)
+			return
+		}
+	}
+
+	state, err := t.Pups.UpdatePup(a.PupID, PupAutoUpdateSetting(a.AutoUpdate), WithPupdateReason(PupdateReasonFor(j)))
+	if err != nil {
+		j.Err = fmt.Sprintf("Failed to set auto-update policy: %v", err)
+		t.sendFinishedJob("action", j)
+		return
+	}
+	j.Success = state
+	t.sendFinishedJob("action", j)
+}
+
+// Handle a SetPupLogRetention action. This is only state, PupLogRotator
+// picks the new retention up on its next pass.
+func (t *Dogeboxd) setPupLogRetention(j Job, a SetPupLogRetention) {
+	if a.Retention != nil {
+		if err := a.Retention.Validate(); err != nil {
+			j.Err = err.Error()
+			t.sendFinishedJob("action", j)
+			return
+		}
+	}
+
+	state, err := t.Pups.UpdatePup(a.PupID, PupLogRetentionSetting(a.Retention), WithPupdateReason(PupdateReasonFor(j)))
+	if err != nil {
+		j.Err = fmt.Sprintf("Failed to set log retention: %v", err)
+		t.sendFinishedJob("action", j)
+		return
+	}
+	j.Success = state
+	t.sendFinishedJob("action", j)
+}
+
 // Handle an UpdatePupHooks action
 func (t *Dogeboxd) updatePupHooks(j Job, u UpdatePupHooks) {
-	_, err := t.Pups.UpdatePup(u.PupID, SetPupHooks(u.Payload))
+	_, err := t.Pups.UpdatePup(u.PupID, SetPupHooks(u.Payload), WithPupdateReason(PupdateReasonFor(j)))
 	if err != nil {
 		j.Err = fmt.Sprintf("Couldnt update: %s", u.PupID)
 		t.sendFinishedJob("action", j)
@@ -738,6 +1482,26 @@ func (t *Dogeboxd) updatePupHooks(j Job, u UpdatePupHooks) {
 	t.sendFinishedJob("action", j)
 }
 
+// Handle a RefreshSource action
+func (t *Dogeboxd) refreshSource(j Job, a RefreshSource) {
+	defer func() { t.sendFinishedJob("action", j) }()
+	log := j.Logger.Step("refresh-source")
+
+	if t.SourceRefresher == nil {
+		j.Err = "source refresh isn't available"
+		return
+	}
+
+	log.Logf("Refreshing source %s", a.SourceID)
+	status, err := t.SourceRefresher.Refresh(a.SourceID)
+	if err != nil {
+		log.Errf("Failed to refresh source %s: %v", a.SourceID, err)
+		j.Err = err.Error()
+		return
+	}
+	j.Success = status
+}
+
 // Handle a CheckPupUpdates action
 func (t *Dogeboxd) checkPupUpdates(j Job, c CheckPupUpdates) {
 	log := j.Logger.Step("check-pup-updates")
@@ -820,16 +1584,31 @@ func (t *Dogeboxd) checkPupUpdates(j Job, c CheckPupUpdates) {
 	}
 }
 
+// How long SendChange waits on the websocket relay before giving up. The
+// relay hands changes straight to per-subscriber queues, so it only stops
+// taking them if it isn't running.
+const (
+	changeSendTimeout    = 200 * time.Millisecond
+	jobChangeSendTimeout = 5 * time.Second
+)
+
 // SendChange sends a change to the websocket relay without blocking if the channel is full.
 func (t Dogeboxd) SendChange(c Change) {
 	// Attach ordering metadata for client-side staleness protection.
 	c.Seq = atomic.AddUint64(&globalChangeSeq, 1)
 	c.TS = time.Now().UnixMilli()
 
-	timer := time.After(200 * time.Millisecond)
+	timeout := changeSendTimeout
+	if IsJobChange(c) {
+		timeout = jobChangeSendTimeout
+	}
+
+	timer := time.After(timeout)
 	select {
 	case t.Changes <- c:
+		observeChangeSent(c, len(t.Changes))
 	case <-timer:
+		observeChangeDropped(c)
 		fmt.Println("Can't sent change, no receiver", c)
 	}
 }
@@ -846,7 +1625,7 @@ func (t Dogeboxd) sendFinishedJob(changeType string, j Job) {
 	jobWasActive := false
 	if t.JobManager != nil && t.shouldTrackJob(j) && t.JobManager.IsJobActive(j.ID) {
 		jobWasActive = true
-		err := t.JobManager.CompleteJob(j.ID, j.Err)
+		err := t.JobManager.CompleteJobWithError(j.ID, t.jobError(j))
 		if err == nil {
 			jobRecord, getErr := t.JobManager.GetJob(j.ID)
 			if getErr == nil {
@@ -858,11 +1637,16 @@ func (t Dogeboxd) sendFinishedJob(changeType string, j Job) {
 	// active states, so concurrent orphan scans cannot observe a false orphan.
 	t.clearNonQueuedActiveJob(j.ID)
 
+	// Nothing else is written to the job log after this point, so archive it.
+	if t.JobManager != nil && t.shouldTrackJob(j) {
+		go t.JobManager.ArchiveJobLog(j.ID)
+	}
+
 	// Only send "action" event for jobs that were NOT already completed by JobManager
 	// Jobs completed by SystemUpdater (like upgrade) already send job:completed events
 	// and don't need a redundant "action" event
 	if t.JobManager == nil || !t.shouldTrackJob(j) || jobWasActive {
-		t.SendChange(Change{ID: j.ID, Error: j.Err, Type: changeType, Update: j.Success})
+		t.SendChange(Change{ID: j.ID, Error: j.Err, ErrorDetail: t.jobError(j), Type: changeType, Update: j.Success})
 	}
 }
 
@@ -872,8 +1656,8 @@ func (t Dogeboxd) shouldTrackJob(j Job) bool {
 	switch j.A.(type) {
 	case UpdateMetrics:
 		return false // Metrics updates happen every 10s, don't track
-	case UpdatePupConfig:
-		return false // Config updates are instantaneous, don't need tracking
+	case UpdatePupStatus:
+		return false // Status reports are frequent, don't track
 	case UpdatePupHooks:
 		return false // Hook updates are instantaneous
 	case InstallPups:
@@ -900,6 +1684,30 @@ func (t Dogeboxd) sendProgress(p ActionProgress) {
 }
 
 // helper to attach PupState to a job and send it to the SystemUpdater
+// refuseSystemPup finishes a job that would uninstall or change the
+// version of a system pup, which only OS updates may do, see SystemPupPin.
+func (t Dogeboxd) refuseSystemPup(j Job, pupID string) bool {
+	p, _, err := t.Pups.GetPup(pupID)
+	if err != nil || !p.SystemManaged {
+		return false
+	}
+	j.Err = fmt.Sprintf("Can't %s %s: %s", j.A.ActionName(), p.DisplayName(), ErrSystemPup)
+	t.sendFinishedJob("action", j)
+	return true
+}
+
+// refuseInSafeMode finishes a job that would install or enable a pup in
+// safe mode. Every pup is left out of the system config until it's turned
+// off, so the pup wouldn't run and the job would report a false success.
+func (t Dogeboxd) refuseInSafeMode(j Job, what string) bool {
+	if t.sm == nil || !t.sm.Get().Dogebox.Flags.IsSafeMode {
+		return false
+	}
+	j.Err = fmt.Sprintf("Can't %s in safe mode, pups don't run until safe mode is turned off", what)
+	t.sendFinishedJob("action", j)
+	return true
+}
+
 func (t Dogeboxd) sendSystemJobWithPupDetails(j Job, PupID string) {
 	p, _, err := t.Pups.GetPup(PupID)
 	if err != nil {
@@ -921,12 +1729,27 @@ func (t Dogeboxd) sendSystemJobWithPupDetails(j Job, PupID string) {
 	t.enqueue(j)
 }
 
+// RootOpRunner runs privileged ops, see system.CommandRunner.
+type RootOpRunner interface {
+	Run(log SubLogger, op rootd.Op) error
+	CombinedOutput(log SubLogger, op rootd.Op) ([]byte, error)
+}
+
+var pupConfigRunner RootOpRunner
+
+// SetPupConfigRunner sets how WritePupConfigToStorage gets root, set up
+// in cmd/dogeboxd/server.go.
+func SetPupConfigRunner(r RootOpRunner) {
+	pupConfigRunner = r
+}
+
 // WritePupConfigToStorage writes the pup's user configuration to a secure file
 // in the pup's storage directory. This file is loaded by systemd via EnvironmentFile
 // directive, keeping sensitive config values (like passwords) out of the nix files.
-func WritePupConfigToStorage(dataDir string, pupID string, config map[string]string, log SubLogger) error {
+// The pup's environment overrides are written alongside, see PupEnvironment.
+func WritePupConfigToStorage(dataDir string, pupID string, config map[string]string, keep []string, envOverrides map[string]string, log SubLogger) error {
 	// Convert config map to JSON
-	configJSON, err := configToJSON(config)
+	configJSON, err := configToJSON(PupEnvironment(config, envOverrides))
 	if err != nil {
 		if log != nil {
 			log.Errf("Failed to serialize config to JSON: %v", err)
@@ -934,18 +1757,21 @@ func WritePupConfigToStorage(dataDir string, pupID string, config map[string]str
 		return fmt.Errorf("failed to serialize config: %w", err)
 	}
 
-	cmd := exec.Command("sudo", "_dbxroot", "pup", "write-config",
-		"--data-dir", dataDir,
-		"--pupId", pupID,
-		"--config", configJSON,
-	)
+	if pupConfigRunner == nil {
+		return errors.New("failed to write pup config: no runner for privileged operations")
+	}
+	op := rootd.PupWriteConfig{PupID: pupID, DataDir: dataDir, Config: configJSON, Keep: keep}
 
+	run := func() error {
+		_, err := pupConfigRunner.CombinedOutput(nil, op)
+		return err
+	}
 	if log != nil {
 		log.Logf("Writing pup config to storage")
-		log.LogCmd(cmd)
+		run = func() error { return pupConfigRunner.Run(log, op) }
 	}
 
-	if err := cmd.Run(); err != nil {
+	if err := run(); err != nil {
 		if log != nil {
 			log.Errf("Failed to write pup config: %v", err)
 		}
@@ -969,11 +1795,6 @@ func configToJSON(config map[string]string) (string, error) {
 	return string(bytes), nil
 }
 
-var allowedJournalServices = map[string]string{
-	"dbx": "dogeboxd.service",
-	"dkm": "dkm.service",
-}
-
 type logSource struct {
 	journalService string
 	filePath       string
@@ -984,11 +1805,22 @@ func (s logSource) usesJournal() bool {
 }
 
 func (t Dogeboxd) resolvePupLogSource(PupID string) (logSource, error) {
-	// We read dogeboxd and dkm from the host systemd journal,
-	// and read everything else (pups) from the container logs we export.
-	service, ok := allowedJournalServices[PupID]
-	if ok {
-		return logSource{journalService: service}, nil
+	// We read dogeboxd's units, and any others allowed, from the host
+	// systemd journal, and read everything else (pups) from the container
+	// logs we export.
+	var dbxState DogeboxState
+	if t.sm != nil {
+		dbxState = t.sm.Get().Dogebox
+	}
+	if unit, ok := dbxState.JournalUnit(PupID); ok {
+		return logSource{journalService: unit.Unit}, nil
+	}
+	if pupID, ok := strings.CutPrefix(PupID, pupContainerJournalUnitPrefix); ok {
+		pup, _, err := t.Pups.GetPup(pupID)
+		if err != nil {
+			return logSource{}, err
+		}
+		return logSource{journalService: pupContainerJournalUnit(pup).Unit}, nil
 	}
 
 	// Check that we've actually got a valid pup id.
@@ -1075,6 +1907,20 @@ func (t Dogeboxd) GetLogPage(PupID string, before *string, limit int) (LogPage,
 	return t.getLogPage(source, before, limit)
 }
 
+// QueryLogs searches a pup's exported container log, see LogQuery.
+// Journal units aren't exported, so can't be queried this way.
+func (t Dogeboxd) QueryLogs(PupID string, q LogQuery) (LogQueryPage, error) {
+	source, err := t.resolvePupLogSource(PupID)
+	if err != nil {
+		return LogQueryPage{}, err
+	}
+	if source.usesJournal() {
+		return LogQueryPage{}, fmt.Errorf("Journal logs cannot be queried, use the tail instead")
+	}
+
+	return t.logtailer.Query(source.filePath, q)
+}
+
 // GetJobLogChannel returns a log channel for a specific job
 // Streams logs from the job's ActionLogger in real-time (same system as pup logs)
 func (t Dogeboxd) GetJobLogChannel(JobID string, resumeToken *string) (context.CancelFunc, chan string, error) {
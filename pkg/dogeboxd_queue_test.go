@@ -38,3 +38,35 @@ func TestQueueManagementIgnoresRunningNixCacheJob(t *testing.T) {
 
 	assert.False(t, dbx.shouldSkipJob(Job{ID: "cache-1", A: UpdateNixCache{}}))
 }
+
+func TestQueueManagementFindsQueuedPupLogLevelRevert(t *testing.T) {
+	running := Job{ID: "revert-1", A: SetPupLogLevel{PupID: "abc"}}
+	dbx := Dogeboxd{
+		queue: &syncQueue{
+			currentSystemJob: &running,
+			jobQueue: []Job{
+				{ID: "job-2", A: SetPupLogLevel{PupID: "def", Debug: true}},
+			},
+		},
+	}
+
+	assert.True(t, dbx.hasQueuedPupLogLevel("abc"))
+	assert.True(t, dbx.hasQueuedPupLogLevel("def"))
+	assert.False(t, dbx.hasQueuedPupLogLevel("ghi"))
+}
+
+func TestQueueManagementFindsRunningDependencyInstall(t *testing.T) {
+	running := Job{ID: "install-1", A: InstallPup{PupName: "Dogecoin Core", SourceId: "src"}}
+	dbx := Dogeboxd{
+		queue: &syncQueue{
+			currentSystemJob: &running,
+			jobQueue: []Job{
+				{ID: "install-2", A: InstallPup{PupName: "Dogenet", SourceId: "src"}},
+			},
+		},
+	}
+
+	assert.True(t, dbx.hasQueuedInstall("Dogecoin Core", "src"))
+	assert.True(t, dbx.hasQueuedInstall("Dogenet", "src"))
+	assert.False(t, dbx.hasQueuedInstall("Dogecoin Core", "other"))
+}
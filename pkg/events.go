@@ -1,6 +1,9 @@
 package dogeboxd
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 // A Job is created when an Action is recieved by the system.
 // Jobs are passed through the Dogeboxd service and result in
@@ -13,8 +16,28 @@ type Job struct {
 	Start   time.Time // set when the job is first created, for calculating duration
 	Logger  *actionLogger
 	State   *PupState // nilable, check before use!
+
+	// Attempt is which run of this job this is, starting at 1, see RetryPolicy.
+	Attempt int
+	// Set alongside Err when the failure is worth retrying.
+	Transient bool
+	// Set alongside Err when the job was killed for running too long,
+	// see GetJobTimeout.
+	TimedOut bool
+	// Who queued the job, one of the JOB_ACTOR_* consts.
+	Actor string
 }
 
+const (
+	// Queued through the API.
+	JOB_ACTOR_USER = "user"
+	// Queued by a JobSchedule.
+	JOB_ACTOR_SCHEDULER = "scheduler"
+	// Queued by dogeboxd itself, ie: auto updates, expiring overrides
+	// and watchdogs.
+	JOB_ACTOR_SYSTEM = "system"
+)
+
 // A Change can be the result of a Job (same ID) or
 // represent an internal system change originating
 // from elsewhere.
@@ -32,17 +55,30 @@ type Change struct {
 	Error  string `json:"error"`
 	Type   string `json:"type"`
 	Update Update `json:"update"`
+	// ErrorDetail is the structured version of Error, when there is one.
+	ErrorDetail *APIError `json:"errorDetail,omitempty"`
+	// Why a "pup" change happened, when it's known.
+	Reason *PupdateReason `json:"reason,omitempty"`
+}
+
+// IsJobChange reports whether c tells the frontend a job was created,
+// updated or finished. These must never be dropped, or the UI is left
+// showing jobs that will never complete. Progress lines are not
+// included, they are also kept in the job's log.
+func IsJobChange(c Change) bool {
+	return c.Type == "action" || c.Type == "job_completed" || strings.HasPrefix(c.Type, "job:")
 }
 
 // Represents some information about an action underway
 type ActionProgress struct {
 	ActionID  string        `json:"actionID"`
-	PupID     string        `json:"pupID"`      // optional, only if a pup action
-	Progress  int           `json:"progress"`   // 0-100
-	Step      string        `json:"step"`       // a unique name for the step we're up to, ie: installing
-	Msg       string        `json:"msg"`        // the message line
-	Error     bool          `json:"error"`      // if this represents an error or not
-	StepTaken time.Duration `json:"step_taken"` // time taken from previous step
+	PupID     string        `json:"pupID"`         // optional, only if a pup action
+	Progress  int           `json:"progress"`      // 0-100
+	Step      string        `json:"step"`          // a unique name for the step we're up to, ie: installing
+	Msg       string        `json:"msg"`           // the message line
+	Error     bool          `json:"error"`         // if this represents an error or not
+	StepTaken time.Duration `json:"step_taken"`    // time taken from previous step
+	ETA       *time.Time    `json:"eta,omitempty"` // when the step should finish, if known
 }
 
 /* Actions are passed to the dogeboxd service via its
@@ -62,9 +98,20 @@ type InstallPup struct {
 	PupName    string
 	PupVersion string
 	SourceId   string
-	Options    AdoptPupOptions
+	// Install from this git commit rather than PupVersion's tag, see
+	// ValidateGitCommit. The pup's PupVersion must still match.
+	Commit  string
+	Options AdoptPupOptions
 
 	SessionToken string
+
+	// Set when this pup is being installed as a dependency of ProvideFor,
+	// see AdoptPupOptions.InstallDependencies, to provide its
+	// ProvideInterfaces once installed. ParentJobID is the job that
+	// installed ProvideFor.
+	ProvideFor        string
+	ProvideInterfaces []string
+	ParentJobID       string
 }
 
 func (InstallPup) ActionName() string { return "install" }
@@ -78,6 +125,11 @@ func (InstallPups) ActionName() string { return "install" }
 // configuration, but keep storage.
 type UninstallPup struct {
 	PupID string
+	// Providers to uninstall once this is, those the user confirmed from
+	// FindOrphanedProviders. Any no longer orphaned by then are kept.
+	RemoveProviders []string
+	// Set on the uninstalls of RemoveProviders, the job that queued them.
+	ParentJobID string
 }
 
 func (UninstallPup) ActionName() string { return "uninstall" }
@@ -103,18 +155,211 @@ type DisablePup struct {
 
 func (DisablePup) ActionName() string { return "disable" }
 
+// Start an enabled pup that isn't running, ie: one that doesn't start on
+// boot, see SetPupAutoStart.
+type StartPup struct {
+	PupID string
+}
+
+func (StartPup) ActionName() string { return "start" }
+
+// Choose whether an enabled pup starts on boot, or only when started by hand
+type SetPupAutoStart struct {
+	PupID     string
+	AutoStart bool
+}
+
+func (SetPupAutoStart) ActionName() string { return "set-pup-autostart" }
+
+// Put an enabled pup in maintenance mode, or take it out, see PupMaintenance.
+type SetPupMaintenance struct {
+	PupID   string
+	Enabled bool
+	Reason  string
+}
+
+func (SetPupMaintenance) ActionName() string { return "set-pup-maintenance" }
+
+// Set (or clear, with an empty Schedule) a pup's periodic restart schedule
+type SetPupRestartSchedule struct {
+	PupID    string
+	Schedule string
+}
+
+func (SetPupRestartSchedule) ActionName() string { return "set-pup-restart-schedule" }
+
+// Override a pup's CPU and memory limits, or go back to its manifest's
+// with nil Limits.
+type SetPupResourceLimits struct {
+	PupID  string
+	Limits *PupResourceLimits
+}
+
+func (SetPupResourceLimits) ActionName() string { return "set-pup-resource-limits" }
+
+// Set which TrustedCAs a pup trusts, replacing what it trusted before.
+type SetPupTrustedCAs struct {
+	PupID string
+	CAIDs []string
+}
+
+func (SetPupTrustedCAs) ActionName() string { return "set-pup-trusted-cas" }
+
+// Set which of the devices a pup's manifest requests the user approves
+// passing through, replacing what was approved before.
+type SetPupDevices struct {
+	PupID string
+	Paths []string
+}
+
+func (SetPupDevices) ActionName() string { return "set-pup-devices" }
+
+// Set a pup's storage quota in MB, 0 for no quota.
+type SetPupStorageQuota struct {
+	PupID   string
+	QuotaMB int
+}
+
+func (SetPupStorageQuota) ActionName() string { return "set-pup-storage-quota" }
+
+// Set (or clear, with nil) a pup's automatic upgrade policy.
+type SetPupAutoUpdate struct {
+	PupID      string
+	AutoUpdate *PupAutoUpdate
+}
+
+func (SetPupAutoUpdate) ActionName() string { return "set-pup-auto-update" }
+
+// Set (or clear, with nil) how a pup's logs are rotated and pruned,
+// overriding the global PupLogRetention.
+type SetPupLogRetention struct {
+	PupID     string
+	Retention *PupLogRetention
+}
+
+func (SetPupLogRetention) ActionName() string { return "set-pup-log-retention" }
+
+// Turn a pup's debug logging on for Duration (see PupManifestLogLevel), or
+// back off. Zero Duration means DefaultPupDebugLogDuration.
+type SetPupLogLevel struct {
+	PupID    string
+	Debug    bool
+	Duration time.Duration
+}
+
+func (SetPupLogLevel) ActionName() string { return "set-pup-log-level" }
+
+// Replace a pup's environment overrides, see ValidatePupEnvOverrides.
+// Empty Env clears them.
+type SetPupEnvOverrides struct {
+	PupID string
+	Env   map[string]string
+}
+
+func (SetPupEnvOverrides) ActionName() string { return "set-pup-env-overrides" }
+
 // UpgradePup upgrades a pup to a new version while preserving config and data
 type UpgradePup struct {
 	PupID         string
 	TargetVersion string
 	SourceId      string // Source to download new version from
+	Automatic     bool   // Queued by the pup's auto-update policy rather than the user
+	// Pin the pup to this git commit, which TargetVersion must be at. A
+	// pinned pup can only be upgraded to another commit.
+	Commit string
+	// Restart the pups that depend on this one once it's ready again, so
+	// they don't hang on to stale connections, see RestartPup.
+	RestartDependents bool
+	// Watch the pup's health for this long once upgraded, rolling it back
+	// if it fails, see PupCanary.
+	CanaryMinutes int
+	// With CanaryMinutes, upgrade the pup's other instances too once it
+	// passes, see FindOtherPupInstances.
+	UpgradeInstances bool
 }
 
 func (UpgradePup) ActionName() string { return "upgrade" }
 
+const (
+	BULK_PUP_ENABLE  = "enable"
+	BULK_PUP_DISABLE = "disable"
+	BULK_PUP_UPGRADE = "upgrade"
+)
+
+/* BulkPupAction enables, disables or upgrades a set of pups as one job,
+ * with their nix changes batched so the system rebuilds once rather
+ * than for every pup. Each pup gets its own step in the job's log.
+ */
+type BulkPupAction struct {
+	Operation string
+	PupIDs    []string
+	// For upgrades, the version to take each pup to, by pup ID.
+	TargetVersions map[string]string
+	// For enables and disables, how each pup was before, by pup ID. Set
+	// by the dispatcher, so a job that fails partway can put them back.
+	Before map[string]BulkPupBefore
+}
+
+func (BulkPupAction) ActionName() string { return "bulk-pup-action" }
+
+// BulkPupBefore is what a bulk enable or disable changes about a pup.
+type BulkPupBefore struct {
+	Enabled     bool
+	Maintenance *PupMaintenance
+}
+
+// Restart a pup's container, ie: a dependent of a provider that has just
+// been upgraded, see UpgradePup.RestartDependents. ParentJobID links the
+// restart to the job that queued it.
+type RestartPup struct {
+	PupID       string
+	ParentJobID string
+}
+
+func (RestartPup) ActionName() string { return "restart" }
+
+// Rebuild a dev mode pup's container from its disk source, restarting it
+// if it's running, see SourceWatcher.
+type RebuildDevPup struct {
+	PupID string
+}
+
+func (RebuildDevPup) ActionName() string { return "rebuild-dev-pup" }
+
+// Export a pup's source, version, config and providers, and optionally
+// its storage, to a tarball another Dogebox can ImportPup, see PupExport.
+type ExportPup struct {
+	PupID          string
+	IncludeStorage bool
+}
+
+func (ExportPup) ActionName() string { return "export" }
+
+// Install a pup from an export uploaded to this box, restoring its config,
+// providers and any storage it was exported with.
+type ImportPup struct {
+	ExportID string
+
+	SessionToken string
+}
+
+func (ImportPup) ActionName() string { return "import" }
+
+// Install a pup from a bundle uploaded to this box, without fetching
+// anything from its source, see PupBundle.
+type InstallPupBundle struct {
+	BundleID string
+
+	SessionToken string
+}
+
+func (InstallPupBundle) ActionName() string { return "install-bundle" }
+
 // RollbackPupUpgrade rolls back a pup to its previous version after a failed upgrade
 type RollbackPupUpgrade struct {
 	PupID string
+	// Why, when it wasn't asked for by the user, ie: a failed PupCanary.
+	Reason string
 }
 
 func (RollbackPupUpgrade) ActionName() string { return "rollback" }
@@ -155,6 +400,37 @@ type PupMetric struct {
 	Value any `json:"value"`
 }
 
+// updates the pup-declared status for a pup
+type UpdatePupStatus struct {
+	PupID    string
+	Message  string
+	Progress *float64
+}
+
+func (UpdatePupStatus) ActionName() string { return "pup-status" }
+
+// Enables or disables safe mode, where all pups are excluded from the system config
+type SetSafeMode struct {
+	Enabled bool
+	Reason  string
+}
+
+func (SetSafeMode) ActionName() string { return "set-safe-mode" }
+
+// Brings the recovery access point up or down
+type SetAPMode struct {
+	Enabled bool
+	Reason  string
+}
+
+func (SetAPMode) ActionName() string { return "set-ap-mode" }
+
+type UpdateWifiRegulatoryDomain struct {
+	Country string
+}
+
+func (UpdateWifiRegulatoryDomain) ActionName() string { return "update-wifi-regulatory-domain" }
+
 type UpdatePendingSystemNetwork struct {
 	Network SelectedNetwork
 }
@@ -167,6 +443,8 @@ type InitialBootstrap struct {
 	InitialSSHKey               string
 	UseFoundationOSBinaryCache  bool
 	UseFoundationPupBinaryCache bool
+	// Saved as DogeboxStateInitialSetup.PendingPupCollection.
+	InitialPupCollection string
 }
 
 func (InitialBootstrap) ActionName() string { return "initial-bootstrap" }
@@ -179,6 +457,14 @@ type (
 func (EnableSSH) ActionName() string  { return "enable-ssh" }
 func (DisableSSH) ActionName() string { return "disable-ssh" }
 
+// EnableSSHTemporary enables SSH for Hours, after which a DisableSSH job
+// is queued, see DogeboxStateSSHConfig.TemporaryExpired.
+type EnableSSHTemporary struct {
+	Hours int
+}
+
+func (EnableSSHTemporary) ActionName() string { return "enable-ssh-temporary" }
+
 type AddSSHKey struct {
 	Key string
 }
@@ -197,6 +483,27 @@ type SaveCustomNix struct {
 
 func (SaveCustomNix) ActionName() string { return "save-custom-nix" }
 
+// Dry build the system with Content as custom.nix, without saving or
+// switching to it, succeeding with a CustomNixValidation.
+type ValidateCustomNix struct {
+	Content string `json:"content"`
+}
+
+func (ValidateCustomNix) ActionName() string { return "validate-custom-nix" }
+
+// Restores the nix directory from a backup taken before a previous patch
+type RestoreNixConfigBackup struct {
+	BackupID string
+}
+
+func (RestoreNixConfigBackup) ActionName() string { return "restore-nix-backup" }
+
+// Back up the nix directory as it is now, outside of a patch, eg: nightly
+// from a schedule.
+type BackupNixConfig struct{}
+
+func (BackupNixConfig) ActionName() string { return "backup-nix-config" }
+
 // Import blockchain data to the system (not tied to a specific pup)
 type ImportBlockchainData struct{}
 
@@ -217,15 +524,71 @@ func (UpdateKeymap) ActionName() string { return "update-keymap" }
 type SystemUpdate struct {
 	Package string
 	Version string
+	// Needed to install any new system pups, see SystemPupPin. Without
+	// one they're left for the next update.
+	SessionToken string
 }
 
 func (SystemUpdate) ActionName() string { return "system-update" }
 
+/* SystemUpdateFromFile applies an offline update bundle, uploaded or on
+ * a USB drive, see SystemUpdateBundle. Nothing is fetched: the bundle's
+ * store export is imported and the release built from its flake.lock.
+ * System pups are left for the next online update.
+ */
+type SystemUpdateFromFile struct {
+	Path string
+	// Set for uploaded bundles, which are removed once they're unpacked.
+	BundleID string
+}
+
+func (SystemUpdateFromFile) ActionName() string { return "system-update-from-file" }
+
+// Rebuild with every pending change, see DogeboxState.PendingChanges.
+type ApplyPendingChanges struct{}
+
+func (ApplyPendingChanges) ActionName() string { return "apply-pending-changes" }
+
+// Revert every pending change without rebuilding.
+type DiscardPendingChanges struct{}
+
+func (DiscardPendingChanges) ActionName() string { return "discard-pending-changes" }
+
+// Build the system with every pending change, without switching, and
+// log how it differs from the running system, so the changes can be
+// reviewed before they're applied.
+type DiffPendingChanges struct{}
+
+func (DiffPendingChanges) ActionName() string { return "diff-pending-changes" }
+
+// Re-apply the installed release after drift between the version pins
+// and running binaries, see system.CheckVersionDrift.
+type ReapplySystemVersion struct{}
+
+func (ReapplySystemVersion) ActionName() string { return "reapply-system-version" }
+
 type UpdateNixCache struct {
 }
 
 func (UpdateNixCache) ActionName() string { return "update-nix-cache" }
 
+// Delete old system generations and collect unreferenced store paths,
+// see NixGCSettings.
+type CollectNixGarbage struct {
+	// Generations to keep, 0 for the box's setting.
+	KeepGenerations int
+}
+
+func (CollectNixGarbage) ActionName() string { return "collect-nix-garbage" }
+
+// Switch to an earlier generation of the system profile, see
+// ValidateSystemRollback.
+type RollbackSystem struct {
+	Generation int
+}
+
+func (RollbackSystem) ActionName() string { return "rollback-system" }
+
 type AddBinaryCache struct {
 	Host string
 	Key  string
@@ -239,6 +602,20 @@ type RemoveBinaryCache struct {
 
 func (RemoveBinaryCache) ActionName() string { return "remove-binary-cache" }
 
+// Remove a TrustedCA, and from the trust store of every pup trusting it.
+type RemoveTrustedCA struct {
+	ID string
+}
+
+func (RemoveTrustedCA) ActionName() string { return "remove-trusted-ca" }
+
+// Relist a source now, rather than waiting for the SourceRefresher.
+type RefreshSource struct {
+	SourceID string
+}
+
+func (RefreshSource) ActionName() string { return "refresh-source" }
+
 /* Updates are responses to Actions or simply
 * internal state changes that the frontend needs,
 * these are wrapped in a 'change' and sent via
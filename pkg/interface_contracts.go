@@ -0,0 +1,149 @@
+package dogeboxd
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+const (
+	CONTRACT_PROBE_HTTP = "http"
+	CONTRACT_PROBE_TCP  = "tcp"
+)
+
+/* An InterfaceContract is how we check a pup really serves an interface
+ * it says it provides, ie: that a pup providing core-rpc answers
+ * JSON-RPC on the port it exposes core-rpc on. Contracts live in the
+ * shared InterfaceContracts registry rather than in manifests, so a pup
+ * can't vouch for itself.
+ *
+ * Pups are probed after they start, when the box runs with contract
+ * checks on, see ServerConfig.CheckInterfaceContracts.
+ */
+type InterfaceContract struct {
+	// The interface versions this applies to, MinVersion inclusive and
+	// MaxVersion exclusive, either may be empty.
+	MinVersion string
+	MaxVersion string
+	Probe      InterfaceContractProbe
+}
+
+type InterfaceContractProbe struct {
+	Type string `json:"type"`
+	// For http probes, the request to send.
+	Method      string `json:"method,omitempty"`
+	Path        string `json:"path,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	Body        string `json:"body,omitempty"`
+	// Any of these statuses pass. An unauthenticated request that's
+	// refused can still show the right server is listening.
+	ExpectStatus []int `json:"expectStatus,omitempty"`
+	// If set, the response must have this header, ie: a JSON-RPC realm.
+	ExpectHeader      string `json:"expectHeader,omitempty"`
+	ExpectHeaderValue string `json:"expectHeaderValue,omitempty"`
+}
+
+func (p InterfaceContractProbe) Validate() error {
+	switch p.Type {
+	case CONTRACT_PROBE_TCP:
+		return nil
+	case CONTRACT_PROBE_HTTP:
+		switch p.Method {
+		case "", "GET", "HEAD", "POST":
+		default:
+			return fmt.Errorf("probe method must be GET, HEAD or POST")
+		}
+		if !strings.HasPrefix(p.Path, "/") {
+			return fmt.Errorf("probe path must start with /")
+		}
+		for _, status := range p.ExpectStatus {
+			if status < 100 || status > 599 {
+				return fmt.Errorf("probe status %d isn't an HTTP status", status)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("probe type must be one of: %s, %s", CONTRACT_PROBE_HTTP, CONTRACT_PROBE_TCP)
+	}
+}
+
+// InterfaceContracts is the shared registry of contracts, by interface name.
+var InterfaceContracts = map[string][]InterfaceContract{
+	"core-rpc": {{
+		Probe: InterfaceContractProbe{
+			Type:        CONTRACT_PROBE_HTTP,
+			Method:      "POST",
+			Path:        "/",
+			ContentType: "application/json",
+			Body:        `{"jsonrpc":"1.0","id":"dogebox","method":"getblockcount","params":[]}`,
+			// Without credentials Dogecoin Core refuses with a
+			// Basic "jsonrpc" realm, which is enough to know it's there.
+			ExpectStatus:      []int{200, 401},
+			ExpectHeader:      "WWW-Authenticate",
+			ExpectHeaderValue: `Basic realm="jsonrpc"`,
+		},
+	}},
+	"core-zmq": {{
+		Probe: InterfaceContractProbe{Type: CONTRACT_PROBE_TCP},
+	}},
+}
+
+// Matches reports whether the contract applies to version of its interface.
+func (c InterfaceContract) Matches(version string) bool {
+	v := semverWithV(version)
+	if !semver.IsValid(v) {
+		return false
+	}
+	if c.MinVersion != "" && semver.Compare(v, semverWithV(c.MinVersion)) < 0 {
+		return false
+	}
+	if c.MaxVersion != "" && semver.Compare(v, semverWithV(c.MaxVersion)) >= 0 {
+		return false
+	}
+	return true
+}
+
+func semverWithV(version string) string {
+	if len(version) > 0 && version[0] != 'v' {
+		return "v" + version
+	}
+	return version
+}
+
+// A ContractCheck is one probe of one port for an interface a pup provides.
+type ContractCheck struct {
+	Interface string
+	Version   string
+	Port      int
+	Probe     InterfaceContractProbe
+}
+
+/* ContractChecks lists the probes to run against a pup, from registry,
+ * for the interfaces its manifest provides. An interface is only probed
+ * on the ports exposing it, those it doesn't expose can't be reached.
+ */
+func ContractChecks(m PupManifest, registry map[string][]InterfaceContract) []ContractCheck {
+	checks := []ContractCheck{}
+	for _, iface := range m.Interfaces {
+		var contract *InterfaceContract
+		for i, c := range registry[iface.Name] {
+			if c.Matches(iface.Version) {
+				contract = &registry[iface.Name][i]
+				break
+			}
+		}
+		if contract == nil {
+			continue
+		}
+
+		for _, expose := range m.Container.Exposes {
+			for _, name := range expose.Interfaces {
+				if name == iface.Name {
+					checks = append(checks, ContractCheck{Interface: iface.Name, Version: iface.Version, Port: expose.Port, Probe: contract.Probe})
+				}
+			}
+		}
+	}
+	return checks
+}
@@ -0,0 +1,40 @@
+package dogeboxd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterfaceContractMatches(t *testing.T) {
+	c := InterfaceContract{MinVersion: "1.0.0", MaxVersion: "2.0.0"}
+	assert.True(t, c.Matches("1.0.0"))
+	assert.True(t, c.Matches("1.9.3"))
+	assert.False(t, c.Matches("0.9.0"))
+	assert.False(t, c.Matches("2.0.0"))
+	assert.False(t, c.Matches("not-a-version"))
+	assert.True(t, InterfaceContract{}.Matches("0.0.1"))
+}
+
+func TestContractChecks(t *testing.T) {
+	probe := InterfaceContractProbe{Type: CONTRACT_PROBE_TCP}
+	registry := map[string][]InterfaceContract{
+		"core-zmq": {{Probe: probe}},
+		"core-rpc": {{MaxVersion: "1.0.0", Probe: probe}},
+	}
+	m := PupManifest{
+		Interfaces: []PupManifestInterface{
+			{Name: "core-zmq", Version: "0.0.1"},
+			{Name: "core-rpc", Version: "1.2.0"},
+			{Name: "unregistered", Version: "0.0.1"},
+		},
+		Container: PupManifestContainer{Exposes: []PupManifestExposeConfig{
+			{Name: "zmq", Port: 28332, Interfaces: []string{"core-zmq"}},
+			{Name: "rpc", Port: 22555, Interfaces: []string{"core-rpc", "unregistered"}},
+		}},
+	}
+
+	// core-rpc 1.2.0 is past its contract's versions, and nothing is
+	// registered for unregistered.
+	assert.Equal(t, []ContractCheck{{Interface: "core-zmq", Version: "0.0.1", Port: 28332, Probe: probe}}, ContractChecks(m, registry))
+}
@@ -0,0 +1,136 @@
+package dogeboxd
+
+import (
+	"fmt"
+	"sort"
+
+	mmsemver "github.com/Masterminds/semver/v3"
+)
+
+/* An InterfaceDefinition describes a version of an interface pups can
+ * provide and depend on, published by an interfaces source so interface
+ * names mean the same thing to everyone using that source:
+ *
+ *	{
+ *	  "id": "dogebox-interfaces",
+ *	  "name": "Dogebox Interfaces",
+ *	  "interfaces": [{
+ *	    "name": "core-rpc",
+ *	    "version": "1.0.0",
+ *	    "description": "Dogecoin Core JSON-RPC",
+ *	    "probe": { "type": "http", "method": "POST", "path": "/", ... },
+ *	    "defaultProvider": { "sourceLocation": "...", "pupName": "Dogecoin Core" }
+ *	  }]
+ *	}
+ *
+ * A definition's probe becomes the interface contract for its major
+ * version, ahead of the built-in InterfaceContracts, and its default
+ * provider is offered to pups whose dependency doesn't name one.
+ */
+type InterfaceDefinition struct {
+	Name            string                       `json:"name"`
+	Version         string                       `json:"version"`
+	Description     string                       `json:"description,omitempty"`
+	Probe           *InterfaceContractProbe      `json:"probe,omitempty"`
+	DefaultProvider *PupManifestDependencySource `json:"defaultProvider,omitempty"`
+}
+
+func (d InterfaceDefinition) Validate() error {
+	if d.Name == "" {
+		return fmt.Errorf("missing field: name")
+	}
+	if _, err := mmsemver.StrictNewVersion(d.Version); err != nil {
+		return fmt.Errorf("version %q must be semver, ie: 1.0.0", d.Version)
+	}
+	if d.Probe != nil {
+		if err := d.Probe.Validate(); err != nil {
+			return err
+		}
+	}
+	if d.DefaultProvider != nil && (d.DefaultProvider.SourceLocation == "" || d.DefaultProvider.PupName == "") {
+		return fmt.Errorf("default provider needs a sourceLocation and pupName")
+	}
+	return nil
+}
+
+// sortedDefinitions returns defs newest version first, dropping any that
+// aren't valid semver.
+func sortedDefinitions(defs []InterfaceDefinition) ([]InterfaceDefinition, []*mmsemver.Version) {
+	sorted := []InterfaceDefinition{}
+	versions := []*mmsemver.Version{}
+	for _, d := range defs {
+		v, err := mmsemver.NewVersion(d.Version)
+		if err != nil {
+			continue
+		}
+		sorted = append(sorted, d)
+		versions = append(versions, v)
+	}
+	sort.Sort(&definitionsByVersion{sorted, versions})
+	return sorted, versions
+}
+
+type definitionsByVersion struct {
+	defs     []InterfaceDefinition
+	versions []*mmsemver.Version
+}
+
+func (s *definitionsByVersion) Len() int           { return len(s.defs) }
+func (s *definitionsByVersion) Less(i, j int) bool { return s.versions[i].GreaterThan(s.versions[j]) }
+func (s *definitionsByVersion) Swap(i, j int) {
+	s.defs[i], s.defs[j] = s.defs[j], s.defs[i]
+	s.versions[i], s.versions[j] = s.versions[j], s.versions[i]
+}
+
+/* InterfaceContractsWith returns registry with the probes from defs in
+ * front, each applying to its definition's major version. When a source
+ * defines several versions of the same major, the newest wins, and the
+ * built-in contracts still cover anything the sources don't.
+ */
+func InterfaceContractsWith(defs []InterfaceDefinition, registry map[string][]InterfaceContract) map[string][]InterfaceContract {
+	merged := map[string][]InterfaceContract{}
+	sorted, versions := sortedDefinitions(defs)
+	for i, d := range sorted {
+		if d.Probe == nil {
+			continue
+		}
+		major := versions[i].Major()
+		merged[d.Name] = append(merged[d.Name], InterfaceContract{
+			MinVersion: fmt.Sprintf("%d.0.0", major),
+			MaxVersion: fmt.Sprintf("%d.0.0", major+1),
+			Probe:      *d.Probe,
+		})
+	}
+	for name, contracts := range registry {
+		merged[name] = append(merged[name], contracts...)
+	}
+	return merged
+}
+
+// DefaultInterfaceProvider returns the default provider from the newest
+// definition of name that satisfies constraint, if any has one.
+func DefaultInterfaceProvider(defs []InterfaceDefinition, name string, constraint string) (PupManifestDependencySource, bool) {
+	c, err := mmsemver.NewConstraint(constraint)
+	if err != nil {
+		return PupManifestDependencySource{}, false
+	}
+	sorted, versions := sortedDefinitions(defs)
+	for i, d := range sorted {
+		if d.Name == name && d.DefaultProvider != nil && c.Check(versions[i]) {
+			return *d.DefaultProvider, true
+		}
+	}
+	return PupManifestDependencySource{}, false
+}
+
+// DescribeInterface returns the description of the newest definition of
+// name, or "" if no source defines it.
+func DescribeInterface(defs []InterfaceDefinition, name string) string {
+	sorted, _ := sortedDefinitions(defs)
+	for _, d := range sorted {
+		if d.Name == name {
+			return d.Description
+		}
+	}
+	return ""
+}
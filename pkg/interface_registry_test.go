@@ -0,0 +1,78 @@
+package dogeboxd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterfaceDefinitionValidate(t *testing.T) {
+	tests := map[string]struct {
+		def     InterfaceDefinition
+		wantErr bool
+	}{
+		"valid":            {def: InterfaceDefinition{Name: "core-rpc", Version: "1.0.0"}},
+		"missing name":     {def: InterfaceDefinition{Version: "1.0.0"}, wantErr: true},
+		"bad version":      {def: InterfaceDefinition{Name: "core-rpc", Version: "1"}, wantErr: true},
+		"bad probe":        {def: InterfaceDefinition{Name: "core-rpc", Version: "1.0.0", Probe: &InterfaceContractProbe{Type: "udp"}}, wantErr: true},
+		"probe no path":    {def: InterfaceDefinition{Name: "core-rpc", Version: "1.0.0", Probe: &InterfaceContractProbe{Type: CONTRACT_PROBE_HTTP}}, wantErr: true},
+		"partial provider": {def: InterfaceDefinition{Name: "core-rpc", Version: "1.0.0", DefaultProvider: &PupManifestDependencySource{PupName: "Core"}}, wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := tt.def.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestInterfaceContractsWith(t *testing.T) {
+	tcp := InterfaceContractProbe{Type: CONTRACT_PROBE_TCP}
+	http := InterfaceContractProbe{Type: CONTRACT_PROBE_HTTP, Path: "/v2"}
+	defs := []InterfaceDefinition{
+		{Name: "core-rpc", Version: "1.0.0", Probe: &tcp},
+		{Name: "core-rpc", Version: "2.1.0", Probe: &http},
+		{Name: "no-probe", Version: "1.0.0"},
+	}
+	registry := InterfaceContractsWith(defs, InterfaceContracts)
+
+	m := PupManifest{
+		Interfaces: []PupManifestInterface{{Name: "core-rpc", Version: "2.0.3"}},
+		Container:  PupManifestContainer{Exposes: []PupManifestExposeConfig{{Port: 22555, Interfaces: []string{"core-rpc"}}}},
+	}
+	checks := ContractChecks(m, registry)
+	assert.Len(t, checks, 1)
+	assert.Equal(t, http, checks[0].Probe)
+
+	// Versions no source defines fall back to the built-in contract.
+	m.Interfaces[0].Version = "3.0.0"
+	checks = ContractChecks(m, registry)
+	assert.Len(t, checks, 1)
+	assert.Equal(t, InterfaceContracts["core-rpc"][0].Probe, checks[0].Probe)
+
+	assert.NotContains(t, registry, "no-probe")
+}
+
+func TestDefaultInterfaceProvider(t *testing.T) {
+	defs := []InterfaceDefinition{
+		{Name: "core-rpc", Version: "1.0.0", DefaultProvider: &PupManifestDependencySource{SourceLocation: "a", PupName: "Core 1"}},
+		{Name: "core-rpc", Version: "1.2.0", Description: "newer", DefaultProvider: &PupManifestDependencySource{SourceLocation: "a", PupName: "Core 1.2"}},
+		{Name: "core-rpc", Version: "2.0.0"},
+	}
+
+	provider, ok := DefaultInterfaceProvider(defs, "core-rpc", "^1.0.0")
+	assert.True(t, ok)
+	assert.Equal(t, "Core 1.2", provider.PupName)
+
+	_, ok = DefaultInterfaceProvider(defs, "core-rpc", "^2.0.0")
+	assert.False(t, ok)
+	_, ok = DefaultInterfaceProvider(defs, "core-zmq", "*")
+	assert.False(t, ok)
+
+	assert.Equal(t, "", DescribeInterface(defs, "core-rpc"))
+	assert.Equal(t, "newer", DescribeInterface(defs[:2], "core-rpc"))
+}
@@ -0,0 +1,249 @@
+package dogeboxd
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+/* Metrics about dogeboxd itself (as opposed to pup metrics), for working
+ * out why a box is slow. These are exposed in the Prometheus text format
+ * and summarised by GetInternalMetrics for the debug API.
+ */
+var InternalMetricsRegistry = prometheus.NewRegistry()
+
+var (
+	metricJobQueueLength = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "dogeboxd_job_queue_length",
+		Help: "Jobs waiting for the system updater.",
+	})
+	metricJobQueueWait = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "dogeboxd_job_queue_wait_seconds",
+		Help:    "Time jobs spend queued before the system updater picks them up.",
+		Buckets: []float64{0.1, 0.5, 1, 5, 15, 60, 300, 900, 3600},
+	}, []string{"action"})
+	metricJobRun = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "dogeboxd_job_run_seconds",
+		Help:    "Time the system updater spends running a job.",
+		Buckets: []float64{0.1, 0.5, 1, 5, 15, 60, 300, 900, 3600},
+	}, []string{"action", "result"})
+	metricChangesSent = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "dogeboxd_changes_sent_total",
+		Help: "Changes handed to the websocket relay.",
+	}, []string{"type"})
+	metricChangesDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "dogeboxd_changes_dropped_total",
+		Help: "Changes dropped because the websocket relay didn't take them in time.",
+	}, []string{"type"})
+	metricChangesBuffered = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "dogeboxd_changes_buffered",
+		Help: "Changes waiting in the channel to the websocket relay.",
+	})
+	metricWebsocketChangesDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "dogeboxd_websocket_changes_dropped_total",
+		Help: "Changes dropped or coalesced because a websocket client's queue was full.",
+	}, []string{"type", "reason"})
+	metricWebsocketClients = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "dogeboxd_websocket_clients",
+		Help: "Connected update websocket clients.",
+	})
+	metricNixPatchApply = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "dogeboxd_nix_patch_apply_seconds",
+		Help:    "Time taken to apply a nix patch, including the rebuild.",
+		Buckets: []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600},
+	}, []string{"result"})
+	metricSourceDownloadBytes = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "dogeboxd_source_download_bytes_total",
+		Help: "Bytes downloaded from pup sources.",
+	})
+	metricSourceDownload = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "dogeboxd_source_download_seconds",
+		Help:    "Time taken to download a pup from its source.",
+		Buckets: []float64{0.5, 1, 5, 15, 30, 60, 120, 300},
+	})
+)
+
+func init() {
+	InternalMetricsRegistry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		metricJobQueueLength,
+		metricJobQueueWait,
+		metricJobRun,
+		metricChangesSent,
+		metricChangesDropped,
+		metricChangesBuffered,
+		metricWebsocketChangesDropped,
+		metricWebsocketClients,
+		metricNixPatchApply,
+		metricSourceDownloadBytes,
+		metricSourceDownload,
+	)
+}
+
+// Totals we also keep ourselves, so the debug API doesn't need to pick
+// apart prometheus collectors.
+var (
+	changesSentTotal    atomic.Uint64
+	changesDroppedTotal atomic.Uint64
+	websocketClients    atomic.Int64
+	websocketDropped    atomic.Uint64
+
+	lastTimingsLock    sync.Mutex
+	lastNixPatchApply  *InternalNixPatchTiming
+	lastSourceDownload *InternalSourceDownloadTiming
+)
+
+type InternalMetrics struct {
+	Goroutines       int                           `json:"goroutines"`
+	JobQueueLength   int                           `json:"jobQueueLength"`
+	CurrentJob       *InternalJobTiming            `json:"currentJob"`
+	QueuedJobs       []InternalJobTiming           `json:"queuedJobs"`
+	ChangesBuffered  int                           `json:"changesBuffered"`
+	ChangesCapacity  int                           `json:"changesCapacity"`
+	ChangesSent      uint64                        `json:"changesSent"`
+	ChangesDropped   uint64                        `json:"changesDropped"`
+	WebsocketClients int64                         `json:"websocketClients"`
+	WebsocketDropped uint64                        `json:"websocketChangesDropped"`
+	LastNixPatch     *InternalNixPatchTiming       `json:"lastNixPatch"`
+	LastDownload     *InternalSourceDownloadTiming `json:"lastSourceDownload"`
+}
+
+type InternalJobTiming struct {
+	ID      string  `json:"id"`
+	Action  string  `json:"action"`
+	Seconds float64 `json:"seconds"` // how long it has been waiting, or running
+}
+
+type InternalNixPatchTiming struct {
+	At      time.Time `json:"at"`
+	Seconds float64   `json:"seconds"`
+	Error   string    `json:"error,omitempty"`
+}
+
+type InternalSourceDownloadTiming struct {
+	At             time.Time `json:"at"`
+	Bytes          int64     `json:"bytes"`
+	Seconds        float64   `json:"seconds"`
+	BytesPerSecond float64   `json:"bytesPerSecond"`
+}
+
+func metricResult(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
+
+// ObserveNixPatchApply records how long applying a nix patch took.
+func ObserveNixPatchApply(d time.Duration, err error) {
+	metricNixPatchApply.WithLabelValues(metricResult(err)).Observe(d.Seconds())
+
+	timing := InternalNixPatchTiming{At: time.Now(), Seconds: d.Seconds()}
+	if err != nil {
+		timing.Error = err.Error()
+	}
+
+	lastTimingsLock.Lock()
+	defer lastTimingsLock.Unlock()
+	lastNixPatchApply = &timing
+}
+
+// ObserveSourceDownload records the size and duration of a pup download.
+func ObserveSourceDownload(bytes int64, d time.Duration) {
+	metricSourceDownloadBytes.Add(float64(bytes))
+	metricSourceDownload.Observe(d.Seconds())
+
+	timing := InternalSourceDownloadTiming{At: time.Now(), Bytes: bytes, Seconds: d.Seconds()}
+	if d > 0 {
+		timing.BytesPerSecond = float64(bytes) / d.Seconds()
+	}
+
+	lastTimingsLock.Lock()
+	defer lastTimingsLock.Unlock()
+	lastSourceDownload = &timing
+}
+
+// WebsocketClientConnected and WebsocketClientDisconnected track
+// how many clients are listening for updates.
+func WebsocketClientConnected() {
+	metricWebsocketClients.Set(float64(websocketClients.Add(1)))
+}
+
+func WebsocketClientDisconnected() {
+	metricWebsocketClients.Set(float64(websocketClients.Add(-1)))
+}
+
+// ObserveWebsocketChangeDropped records a change a websocket client will
+// never see, either "dropped" outright or "coalesced" into a newer one.
+func ObserveWebsocketChangeDropped(changeType string, reason string) {
+	websocketDropped.Add(1)
+	metricWebsocketChangesDropped.WithLabelValues(changeType, reason).Inc()
+}
+
+func observeChangeSent(c Change, buffered int) {
+	changesSentTotal.Add(1)
+	metricChangesSent.WithLabelValues(c.Type).Inc()
+	metricChangesBuffered.Set(float64(buffered))
+}
+
+func observeChangeDropped(c Change) {
+	changesDroppedTotal.Add(1)
+	metricChangesDropped.WithLabelValues(c.Type).Inc()
+}
+
+func observeJobQueueWait(j Job, d time.Duration) {
+	metricJobQueueWait.WithLabelValues(jobActionName(j)).Observe(d.Seconds())
+}
+
+func observeJobRun(j Job, d time.Duration) {
+	var err error
+	if j.Err != "" {
+		err = errors.New(j.Err)
+	}
+	metricJobRun.WithLabelValues(jobActionName(j), metricResult(err)).Observe(d.Seconds())
+}
+
+func jobActionName(j Job) string {
+	if j.A == nil {
+		return "unknown"
+	}
+	return j.A.ActionName()
+}
+
+// GetInternalMetrics summarises dogeboxd's own health for the debug API.
+func (t Dogeboxd) GetInternalMetrics() InternalMetrics {
+	now := time.Now()
+	m := InternalMetrics{
+		Goroutines:       runtime.NumGoroutine(),
+		QueuedJobs:       []InternalJobTiming{},
+		ChangesBuffered:  len(t.Changes),
+		ChangesCapacity:  cap(t.Changes),
+		ChangesSent:      changesSentTotal.Load(),
+		ChangesDropped:   changesDroppedTotal.Load(),
+		WebsocketClients: websocketClients.Load(),
+		WebsocketDropped: websocketDropped.Load(),
+	}
+
+	t.queue.jobQLock.Lock()
+	for _, j := range t.queue.jobQueue {
+		m.QueuedJobs = append(m.QueuedJobs, InternalJobTiming{ID: j.ID, Action: jobActionName(j), Seconds: now.Sub(j.Start).Seconds()})
+	}
+	if t.queue.currentSystemJobID != "" {
+		m.CurrentJob = &InternalJobTiming{ID: t.queue.currentSystemJobID, Action: t.queue.currentSystemJobAction, Seconds: now.Sub(t.queue.jobTimer).Seconds()}
+	}
+	t.queue.jobQLock.Unlock()
+	m.JobQueueLength = len(m.QueuedJobs)
+
+	lastTimingsLock.Lock()
+	m.LastNixPatch = lastNixPatchApply
+	m.LastDownload = lastSourceDownload
+	lastTimingsLock.Unlock()
+
+	return m
+}
@@ -0,0 +1,79 @@
+package dogeboxd
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInternalMetricsCountsDroppedChanges(t *testing.T) {
+	dbx := Dogeboxd{
+		Changes: make(chan Change, 1),
+		queue:   &syncQueue{},
+	}
+
+	before := dbx.GetInternalMetrics()
+
+	dbx.SendChange(Change{ID: "internal", Type: "stats"})
+	// Nobody is reading, so this one can't be delivered.
+	dbx.SendChange(Change{ID: "internal", Type: "stats"})
+
+	after := dbx.GetInternalMetrics()
+	assert.Equal(t, before.ChangesSent+1, after.ChangesSent)
+	assert.Equal(t, before.ChangesDropped+1, after.ChangesDropped)
+	assert.Equal(t, 1, after.ChangesBuffered)
+	assert.Equal(t, 1, after.ChangesCapacity)
+}
+
+func TestInternalMetricsReportsQueuedAndRunningJobs(t *testing.T) {
+	now := time.Now()
+	dbx := Dogeboxd{
+		queue: &syncQueue{
+			jobQueue: []Job{
+				{ID: "job-2", A: UpdateTimezone{Timezone: "UTC"}, Start: now.Add(-time.Minute)},
+			},
+			currentSystemJobID:     "job-1",
+			currentSystemJobAction: "update-nix-cache",
+			jobTimer:               now.Add(-time.Hour),
+		},
+	}
+
+	m := dbx.GetInternalMetrics()
+	assert.Equal(t, 1, m.JobQueueLength)
+	require.Len(t, m.QueuedJobs, 1)
+	assert.Equal(t, "job-2", m.QueuedJobs[0].ID)
+	assert.Equal(t, UpdateTimezone{}.ActionName(), m.QueuedJobs[0].Action)
+	assert.GreaterOrEqual(t, m.QueuedJobs[0].Seconds, 60.0)
+
+	require.NotNil(t, m.CurrentJob)
+	assert.Equal(t, "job-1", m.CurrentJob.ID)
+	assert.GreaterOrEqual(t, m.CurrentJob.Seconds, 3600.0)
+}
+
+func TestInternalMetricsRecordsTimings(t *testing.T) {
+	ObserveNixPatchApply(2*time.Second, errors.New("rebuild failed"))
+	ObserveSourceDownload(4096, 2*time.Second)
+
+	m := Dogeboxd{queue: &syncQueue{}}.GetInternalMetrics()
+
+	require.NotNil(t, m.LastNixPatch)
+	assert.Equal(t, 2.0, m.LastNixPatch.Seconds)
+	assert.Equal(t, "rebuild failed", m.LastNixPatch.Error)
+
+	require.NotNil(t, m.LastDownload)
+	assert.Equal(t, int64(4096), m.LastDownload.Bytes)
+	assert.Equal(t, 2048.0, m.LastDownload.BytesPerSecond)
+
+	families, err := InternalMetricsRegistry.Gather()
+	require.NoError(t, err)
+
+	names := map[string]bool{}
+	for _, f := range families {
+		names[f.GetName()] = true
+	}
+	assert.True(t, names["dogeboxd_nix_patch_apply_seconds"])
+	assert.True(t, names["dogeboxd_source_download_bytes_total"])
+}
@@ -0,0 +1,247 @@
+package dogeboxd
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+ * Job logs written by the ActionLogger live in the ContainerLogDir,
+ * which is rotated and may be cleaned up underneath us. Once a job
+ * finishes we copy the full log (including any rotated backups) into
+ * a compressed archive under DataDir so it can be downloaded later.
+ */
+
+const (
+	jobLogArchiveMaxAgeDays = 30
+	jobLogArchiveMaxFiles   = 500
+
+	maxJobLogRetentionDays  = 3650
+	maxJobLogRetentionFiles = 100000
+)
+
+// JobLogRetention is how many archived job logs are kept, and for how
+// long. Zero values mean our defaults.
+type JobLogRetention struct {
+	MaxAgeDays int `json:"maxAgeDays"`
+	MaxFiles   int `json:"maxFiles"`
+}
+
+func (r JobLogRetention) Validate() error {
+	if r.MaxAgeDays < 0 || r.MaxAgeDays > maxJobLogRetentionDays {
+		return fmt.Errorf("maxAgeDays must be between 0 and %d", maxJobLogRetentionDays)
+	}
+	if r.MaxFiles < 0 || r.MaxFiles > maxJobLogRetentionFiles {
+		return fmt.Errorf("maxFiles must be between 0 and %d", maxJobLogRetentionFiles)
+	}
+	return nil
+}
+
+// WithDefaults fills in any unset limits.
+func (r JobLogRetention) WithDefaults() JobLogRetention {
+	if r.MaxAgeDays <= 0 {
+		r.MaxAgeDays = jobLogArchiveMaxAgeDays
+	}
+	if r.MaxFiles <= 0 {
+		r.MaxFiles = jobLogArchiveMaxFiles
+	}
+	return r
+}
+
+// ArchiveJobLog compresses the full log for a job into the job log archive
+// and prunes any archives that fall outside the retention window.
+func ArchiveJobLog(config ServerConfig, retention JobLogRetention, jobID string) error {
+	sources, err := jobLogSourceFiles(config, jobID)
+	if err != nil {
+		return err
+	}
+
+	if len(sources) == 0 {
+		return nil
+	}
+
+	if err := os.MkdirAll(config.JobLogArchiveDir(), 0750); err != nil {
+		return fmt.Errorf("failed to create job log archive directory: %w", err)
+	}
+
+	archivePath := config.JobLogArchivePath(jobID)
+	tmpPath := archivePath + ".tmp"
+
+	if err := writeJobLogArchive(tmpPath, sources); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Rename(tmpPath, archivePath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to move job log archive into place: %w", err)
+	}
+
+	if _, err := PruneJobLogArchive(config, retention, time.Now()); err != nil {
+		return fmt.Errorf("failed to prune job log archive: %w", err)
+	}
+
+	return nil
+}
+
+// OpenJobLogArchive returns a reader over the uncompressed, archived log for a job.
+// Returns an error satisfying os.IsNotExist if the job has not been archived.
+func OpenJobLogArchive(config ServerConfig, jobID string) (io.ReadCloser, error) {
+	f, err := os.Open(config.JobLogArchivePath(jobID))
+	if err != nil {
+		return nil, err
+	}
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to read job log archive: %w", err)
+	}
+
+	return &gzipFileReader{Reader: gz, file: f}, nil
+}
+
+// RemoveJobLogArchive deletes the archived log for a job, if there is one.
+func RemoveJobLogArchive(config ServerConfig, jobID string) error {
+	err := os.Remove(config.JobLogArchivePath(jobID))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// PruneJobLogArchive removes archives older than the retention max age, and
+// the oldest archives beyond its max file count. Returns the number of
+// archives removed.
+func PruneJobLogArchive(config ServerConfig, retention JobLogRetention, now time.Time) (int, error) {
+	retention = retention.WithDefaults()
+	maxAge := time.Duration(retention.MaxAgeDays) * 24 * time.Hour
+
+	entries, err := os.ReadDir(config.JobLogArchiveDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	type archive struct {
+		path    string
+		modTime time.Time
+	}
+
+	archives := []archive{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".log.gz") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		archives = append(archives, archive{path: filepath.Join(config.JobLogArchiveDir(), entry.Name()), modTime: info.ModTime()})
+	}
+
+	// Newest first, so anything past our max count is the oldest.
+	sort.Slice(archives, func(i, j int) bool {
+		return archives[i].modTime.After(archives[j].modTime)
+	})
+
+	removed := 0
+	for i, a := range archives {
+		if i < retention.MaxFiles && now.Sub(a.modTime) <= maxAge {
+			continue
+		}
+		if err := os.Remove(a.path); err != nil && !os.IsNotExist(err) {
+			return removed, err
+		}
+		removed++
+	}
+
+	return removed, nil
+}
+
+// Returns the rotated backups for a job log (oldest first), followed by the live log.
+func jobLogSourceFiles(config ServerConfig, jobID string) ([]string, error) {
+	if config.ContainerLogDir == "" {
+		return nil, nil
+	}
+
+	logPath := config.JobLogPath(jobID)
+
+	// lumberjack names backups <name>-<timestamp>[.gz], which sort chronologically.
+	backups, err := filepath.Glob(logPath + "-*")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(backups)
+
+	sources := backups
+	if _, err := os.Stat(logPath); err == nil {
+		sources = append(sources, logPath)
+	}
+
+	return sources, nil
+}
+
+func writeJobLogArchive(path string, sources []string) error {
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0640)
+	if err != nil {
+		return fmt.Errorf("failed to create job log archive: %w", err)
+	}
+	defer out.Close()
+
+	gz := gzip.NewWriter(out)
+
+	for _, source := range sources {
+		if err := copyJobLogSource(gz, source); err != nil {
+			return err
+		}
+	}
+
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to finalise job log archive: %w", err)
+	}
+
+	return out.Close()
+}
+
+func copyJobLogSource(w io.Writer, source string) error {
+	f, err := os.Open(source)
+	if err != nil {
+		return fmt.Errorf("failed to open job log %s: %w", source, err)
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(source, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("failed to decompress job log %s: %w", source, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	if _, err := io.Copy(w, r); err != nil {
+		return fmt.Errorf("failed to copy job log %s: %w", source, err)
+	}
+
+	return nil
+}
+
+type gzipFileReader struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (r *gzipFileReader) Close() error {
+	r.Reader.Close()
+	return r.file.Close()
+}
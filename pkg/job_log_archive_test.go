@@ -0,0 +1,96 @@
+package dogeboxd
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchiveJobLogIncludesRotatedBackups(t *testing.T) {
+	config := ServerConfig{DataDir: t.TempDir(), ContainerLogDir: t.TempDir()}
+	jobID := "abc123"
+
+	// A compressed lumberjack backup, followed by the live log.
+	backup, err := os.Create(config.JobLogPath(jobID) + "-2026-01-01T00-00-00.000.gz")
+	require.NoError(t, err)
+	gz := gzip.NewWriter(backup)
+	_, err = gz.Write([]byte("line 1\n"))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	require.NoError(t, backup.Close())
+	require.NoError(t, os.WriteFile(config.JobLogPath(jobID), []byte("line 2\n"), 0644))
+
+	require.NoError(t, ArchiveJobLog(config, JobLogRetention{}, jobID))
+
+	archive, err := OpenJobLogArchive(config, jobID)
+	require.NoError(t, err)
+	defer archive.Close()
+
+	contents, err := io.ReadAll(archive)
+	require.NoError(t, err)
+	assert.Equal(t, "line 1\nline 2\n", string(contents))
+}
+
+func TestArchiveJobLogWithoutLogIsNoop(t *testing.T) {
+	config := ServerConfig{DataDir: t.TempDir(), ContainerLogDir: t.TempDir()}
+
+	require.NoError(t, ArchiveJobLog(config, JobLogRetention{}, "missing"))
+
+	_, err := OpenJobLogArchive(config, "missing")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestPruneJobLogArchiveRemovesExpiredArchives(t *testing.T) {
+	config := ServerConfig{DataDir: t.TempDir()}
+	require.NoError(t, os.MkdirAll(config.JobLogArchiveDir(), 0750))
+
+	now := time.Now()
+	fresh := config.JobLogArchivePath("fresh")
+	stale := config.JobLogArchivePath("stale")
+	require.NoError(t, os.WriteFile(fresh, nil, 0640))
+	require.NoError(t, os.WriteFile(stale, nil, 0640))
+	require.NoError(t, os.Chtimes(stale, now, now.Add(-jobLogArchiveMaxAgeDays*24*time.Hour-time.Hour)))
+
+	removed, err := PruneJobLogArchive(config, JobLogRetention{}, now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+
+	_, err = os.Stat(fresh)
+	assert.NoError(t, err)
+	_, err = os.Stat(stale)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestPruneJobLogArchiveUsesConfiguredRetention(t *testing.T) {
+	config := ServerConfig{DataDir: t.TempDir()}
+	require.NoError(t, os.MkdirAll(config.JobLogArchiveDir(), 0750))
+
+	now := time.Now()
+	for i, id := range []string{"newest", "middle", "oldest"} {
+		path := config.JobLogArchivePath(id)
+		require.NoError(t, os.WriteFile(path, nil, 0640))
+		require.NoError(t, os.Chtimes(path, now, now.Add(-time.Duration(i)*time.Hour)))
+	}
+
+	removed, err := PruneJobLogArchive(config, JobLogRetention{MaxFiles: 2}, now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+
+	_, err = os.Stat(config.JobLogArchivePath("oldest"))
+	assert.True(t, os.IsNotExist(err))
+
+	assert.Error(t, JobLogRetention{MaxAgeDays: -1}.Validate())
+	assert.Equal(t, JobLogRetention{MaxAgeDays: 7, MaxFiles: jobLogArchiveMaxFiles}, JobLogRetention{MaxAgeDays: 7}.WithDefaults())
+}
+
+func TestServerConfigJobLogArchivePath(t *testing.T) {
+	config := ServerConfig{DataDir: "/tmp/data"}
+
+	assert.Equal(t, filepath.Join("/tmp/data", "job-logs", "job-demo.log.gz"), config.JobLogArchivePath("demo"))
+}
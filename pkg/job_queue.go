@@ -0,0 +1,299 @@
+package dogeboxd
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// A PersistedJob is a job waiting in, or running from, the SystemUpdater
+// queue, saved so the queue survives a dogeboxd restart.
+type PersistedJob struct {
+	ID       string          `json:"id"`
+	Position int             `json:"position"`
+	Action   string          `json:"action"` // ActionName of the job's Action
+	Params   json.RawMessage `json:"params,omitempty"`
+	PupID    string          `json:"pupId,omitempty"`
+	Start    time.Time       `json:"start"`
+	Attempt  int             `json:"attempt"`
+	// Running is set for the job that had been handed to SystemUpdater.
+	Running bool `json:"running"`
+	// Resumable is false for jobs that are unsafe to run again after a
+	// restart, they are failed as interrupted instead, see resumableActions.
+	Resumable bool   `json:"resumable"`
+	Actor     string `json:"actor,omitempty"`
+}
+
+/* resumableActions are the queued Actions that can be safely run from
+ * scratch after a restart, keyed by ActionName. Anything else is saved
+ * without its params (they may hold secrets such as session tokens or
+ * wifi passwords) and failed on startup, ie:
+ *
+ * - InstallPup, ImportPup and InstallPupBundle carry a DKM session token
+ *   that won't outlive us
+ * - SystemUpdate and SystemUpdateFromFile are reconciled by
+ *   ClearInterruptedSystemJobs
+ * - SetAPMode/SetSafeMode reflect conditions the watchdog re-checks
+ * - ReapplySystemVersion and RollbackSystem may be what restarted us
+ * - ValidateCustomNix and DiffPendingChanges only matter to whoever
+ *   asked for them
+ */
+var resumableActions = actionTypes(
+	UninstallPup{},
+	PurgePup{},
+	EnablePup{},
+	DisablePup{},
+	StartPup{},
+	SetPupAutoStart{},
+	SetPupMaintenance{},
+	SetPupRestartSchedule{},
+	SetPupResourceLimits{},
+	SetPupTrustedCAs{},
+	SetPupStorageQuota{},
+	SetPupAutoUpdate{},
+	SetPupLogRetention{},
+	SetPupLogLevel{},
+	SetPupEnvOverrides{},
+	SetPupDevices{},
+	UpgradePup{},
+	BulkPupAction{},
+	RollbackPupUpgrade{},
+	RestartPup{},
+	RebuildDevPup{},
+	ExportPup{},
+	ImportBlockchainData{},
+	EnableSSH{},
+	EnableSSHTemporary{},
+	DisableSSH{},
+	AddSSHKey{},
+	RemoveSSHKey{},
+	SaveCustomNix{},
+	RestoreNixConfigBackup{},
+	BackupNixConfig{},
+	AddBinaryCache{},
+	RemoveBinaryCache{},
+	RemoveTrustedCA{},
+	UpdateTimezone{},
+	UpdateKeymap{},
+	UpdateNixCache{},
+	CollectNixGarbage{},
+	UpdateWifiRegulatoryDomain{},
+	ApplyPendingChanges{},
+	DiscardPendingChanges{},
+)
+
+func actionTypes(actions ...Action) map[string]reflect.Type {
+	types := make(map[string]reflect.Type, len(actions))
+	for _, a := range actions {
+		types[a.ActionName()] = reflect.TypeOf(a)
+	}
+	return types
+}
+
+// IsResumableAction reports whether a queued job for this Action can be
+// re-run after dogeboxd restarts.
+func IsResumableAction(a Action) bool {
+	t, ok := resumableActions[a.ActionName()]
+	return ok && t == reflect.TypeOf(a)
+}
+
+func newPersistedJob(j Job, position int, running bool) PersistedJob {
+	p := PersistedJob{
+		ID:        j.ID,
+		Position:  position,
+		Action:    jobActionName(j),
+		Start:     j.Start,
+		Attempt:   j.Attempt,
+		Running:   running,
+		Resumable: !running && IsResumableAction(j.A),
+		Actor:     j.Actor,
+	}
+	if j.State != nil {
+		p.PupID = j.State.ID
+	}
+	if p.Resumable {
+		params, err := json.Marshal(j.A)
+		if err != nil {
+			p.Resumable = false
+		} else {
+			p.Params = params
+		}
+	}
+	return p
+}
+
+// action rebuilds the Action of a resumable PersistedJob.
+func (p PersistedJob) action() (Action, error) {
+	t, ok := resumableActions[p.Action]
+	if !ok || !p.Resumable {
+		return nil, fmt.Errorf("action %q is not resumable", p.Action)
+	}
+	v := reflect.New(t)
+	if len(p.Params) > 0 {
+		if err := json.Unmarshal(p.Params, v.Interface()); err != nil {
+			return nil, fmt.Errorf("failed to decode %q params: %w", p.Action, err)
+		}
+	}
+	return v.Elem().Interface().(Action), nil
+}
+
+// SaveQueue replaces the persisted queue with jobs. If it fails the
+// previous queue is kept.
+func (jm *JobManager) SaveQueue(jobs []PersistedJob) error {
+	byID := make(map[string]PersistedJob, len(jobs))
+	for _, p := range jobs {
+		byID[p.ID] = p
+	}
+	return jm.queue.Replace(byID)
+}
+
+// LoadQueue returns the persisted queue, running job first.
+func (jm *JobManager) LoadQueue() ([]PersistedJob, error) {
+	query := fmt.Sprintf("SELECT value FROM %s ORDER BY json_extract(value, '$.position') ASC", jm.queue.Table)
+	return jm.queue.Exec(query)
+}
+
+// RequeueJob puts the record of a resumed job back into the queued state,
+// undoing any startup cleanup that already failed it.
+func (jm *JobManager) RequeueJob(jobID string) error {
+	jm.jobsMutex.Lock()
+	defer jm.jobsMutex.Unlock()
+
+	record, err := jm.store.Get(jobID)
+	if err != nil {
+		return fmt.Errorf("job not found: %s", jobID)
+	}
+
+	record.Status = JobStatusQueued
+	record.Finished = nil
+	record.Progress = 0
+	record.ErrorMessage = ""
+	record.Error = nil
+	record.SummaryMessage = "Job queued (resumed after restart)"
+
+	if err := jm.store.Set(record.ID, record); err != nil {
+		return err
+	}
+	jm.activeJobs[jobID] = &record
+	return nil
+}
+
+// MarkJobInterrupted fails an active job that was lost to a restart.
+func (jm *JobManager) MarkJobInterrupted(jobID string) error {
+	jm.jobsMutex.Lock()
+	defer jm.jobsMutex.Unlock()
+
+	record, err := jm.store.Get(jobID)
+	if err != nil {
+		return fmt.Errorf("job not found: %s", jobID)
+	}
+	delete(jm.activeJobs, jobID)
+
+	if record.Status != JobStatusQueued && record.Status != JobStatusInProgress {
+		return nil
+	}
+
+	now := time.Now()
+	record.Status = JobStatusFailed
+	record.Finished = &now
+	record.ErrorMessage = interruptedSystemJobMessage(record)
+	record.Error = NewAPIError(ERROR_JOB_INTERRUPTED, record.ErrorMessage).
+		ForJob(record.ID).
+		ForPup(record.PupID).
+		WithRemediation("Check the job log, then try again.")
+	record.SummaryMessage = "Job failed"
+	return jm.store.Set(record.ID, record)
+}
+
+// persistQueueLocked saves the running job and the pending queue, the
+// caller must hold jobQLock.
+func (t *Dogeboxd) persistQueueLocked() {
+	if t.JobManager == nil {
+		return
+	}
+
+	jobs := make([]PersistedJob, 0, len(t.queue.jobQueue)+1)
+	if t.queue.currentSystemJob != nil {
+		jobs = append(jobs, newPersistedJob(*t.queue.currentSystemJob, 0, true))
+	}
+	for i, j := range t.queue.jobQueue {
+		jobs = append(jobs, newPersistedJob(j, i+1, false))
+	}
+
+	if err := t.JobManager.SaveQueue(jobs); err != nil {
+		fmt.Printf("Warning: failed to persist job queue: %v\n", err)
+	}
+}
+
+/* ResumePersistedJobs re-enqueues the jobs that were waiting in the queue
+ * when dogeboxd last stopped. Jobs go straight back to the queue rather
+ * than through jobDispatcher, as any dispatcher side effects (eg: pup
+ * state changes) already happened. The job that was running, and any
+ * that aren't resumable, are failed as interrupted.
+ *
+ * Call this once at startup, after the JobManager's own cleanup.
+ */
+func (t *Dogeboxd) ResumePersistedJobs() (resumed int, skipped int, err error) {
+	if t.JobManager == nil {
+		return 0, 0, nil
+	}
+
+	persisted, err := t.JobManager.LoadQueue()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, p := range persisted {
+		j, err := t.resumeJob(p)
+		if err != nil {
+			fmt.Printf("Not resuming job %s (%s): %v\n", p.ID, p.Action, err)
+			if err := t.JobManager.MarkJobInterrupted(p.ID); err != nil {
+				fmt.Printf("Warning: failed to mark job %s interrupted: %v\n", p.ID, err)
+			}
+			skipped++
+			continue
+		}
+
+		// Log first, logging progress marks the record in progress.
+		j.Logger.Step("queue").Log("Resumed after dogeboxd restart")
+		if err := t.JobManager.RequeueJob(p.ID); err != nil {
+			fmt.Printf("Warning: failed to requeue job record %s: %v\n", p.ID, err)
+		}
+		t.enqueue(j)
+		resumed++
+	}
+
+	// Drop anything skipped, even if nothing was enqueued.
+	t.queue.jobQLock.Lock()
+	t.persistQueueLocked()
+	t.queue.jobQLock.Unlock()
+
+	return resumed, skipped, nil
+}
+
+func (t *Dogeboxd) resumeJob(p PersistedJob) (Job, error) {
+	if p.Running {
+		return Job{}, fmt.Errorf("job was running when dogeboxd stopped")
+	}
+
+	a, err := p.action()
+	if err != nil {
+		return Job{}, err
+	}
+
+	j := Job{A: a, ID: p.ID, Start: p.Start, Attempt: p.Attempt, Actor: p.Actor}
+	if j.Attempt < 1 {
+		j.Attempt = 1
+	}
+	j.Logger = NewActionLogger(j, p.PupID, *t)
+
+	if p.PupID != "" {
+		state, _, err := t.Pups.GetPup(p.PupID)
+		if err != nil {
+			return Job{}, fmt.Errorf("pup %s no longer exists: %w", p.PupID, err)
+		}
+		j.State = &state
+	}
+	return j, nil
+}
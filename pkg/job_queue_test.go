@@ -0,0 +1,183 @@
+package dogeboxd
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golanglibs/gocollections/set/hashset"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type queueTestSystemUpdater struct {
+	SystemUpdater
+	added []Job
+}
+
+func (s *queueTestSystemUpdater) AddJob(j Job) { s.added = append(s.added, j) }
+
+type queueTestPupManager struct {
+	PupManager
+	pups map[string]PupState
+}
+
+func (m queueTestPupManager) GetPup(id string) (PupState, PupStats, error) {
+	p, ok := m.pups[id]
+	if !ok {
+		return PupState{}, PupStats{}, errors.New("pup not found")
+	}
+	return p, PupStats{}, nil
+}
+
+func newQueueTestDogeboxd(t *testing.T, jm *JobManager, pups map[string]PupState) (*Dogeboxd, *queueTestSystemUpdater) {
+	updater := &queueTestSystemUpdater{}
+	dbx := &Dogeboxd{
+		Changes:       make(chan Change, 100),
+		config:        &ServerConfig{ContainerLogDir: t.TempDir()},
+		JobManager:    jm,
+		SystemUpdater: updater,
+		Pups:          queueTestPupManager{pups: pups},
+		queue: &syncQueue{
+			jobQueue:            []Job{},
+			nonQueuedActiveJobs: hashset.New[string](),
+		},
+	}
+	jm.SetDogeboxd(dbx)
+	return dbx, updater
+}
+
+func queueTestJob(t *testing.T, dbx *Dogeboxd, id string, a Action, state *PupState) Job {
+	j := Job{ID: id, A: a, Start: time.Now(), Attempt: 1, State: state}
+	j.Logger = NewActionLogger(j, "", *dbx)
+	_, err := dbx.JobManager.CreateJobRecord(j)
+	require.NoError(t, err)
+	return j
+}
+
+func TestIsResumableAction(t *testing.T) {
+	assert.True(t, IsResumableAction(EnablePup{PupID: "abc"}))
+	assert.True(t, IsResumableAction(UpdateTimezone{Timezone: "UTC"}))
+	assert.False(t, IsResumableAction(InstallPup{}))
+	assert.False(t, IsResumableAction(SystemUpdate{}))
+	assert.False(t, IsResumableAction(UpdatePendingSystemNetwork{}))
+	assert.False(t, IsResumableAction(UnknownAction{}))
+}
+
+func TestPersistedJobOmitsParamsOfUnresumableActions(t *testing.T) {
+	p := newPersistedJob(Job{ID: "1", A: InstallPup{PupName: "core", SessionToken: "secret"}}, 1, false)
+	assert.False(t, p.Resumable)
+	assert.Empty(t, p.Params)
+
+	p = newPersistedJob(Job{ID: "2", A: AddSSHKey{Key: "ssh-ed25519 AAAA"}}, 2, false)
+	require.True(t, p.Resumable)
+	a, err := p.action()
+	require.NoError(t, err)
+	assert.Equal(t, AddSSHKey{Key: "ssh-ed25519 AAAA"}, a)
+
+	p = newPersistedJob(Job{ID: "3", A: AddSSHKey{Key: "ssh-ed25519 AAAA"}}, 0, true)
+	assert.False(t, p.Resumable, "a running job is never resumable")
+}
+
+func TestResumePersistedJobsAfterRestart(t *testing.T) {
+	jm, err := setupTestJobManager()
+	require.NoError(t, err)
+
+	pup := PupState{ID: "pup1"}
+	dbx, updater := newQueueTestDogeboxd(t, jm, map[string]PupState{"pup1": pup})
+
+	running := queueTestJob(t, dbx, "running", UpdateKeymap{Keymap: "us"}, nil)
+	install := queueTestJob(t, dbx, "install", InstallPup{PupName: "core", SessionToken: "secret"}, &pup)
+	enable := queueTestJob(t, dbx, "enable", EnablePup{PupID: "pup1"}, &pup)
+	gone := queueTestJob(t, dbx, "gone", DisablePup{PupID: "pup2"}, &PupState{ID: "pup2"})
+	timezone := queueTestJob(t, dbx, "timezone", UpdateTimezone{Timezone: "Australia/Sydney"}, nil)
+
+	for _, j := range []Job{running, install, enable, gone, timezone} {
+		dbx.enqueue(j)
+	}
+	dbx.pumpQueue()
+	require.Len(t, updater.added, 1)
+
+	persisted, err := jm.LoadQueue()
+	require.NoError(t, err)
+	require.Len(t, persisted, 5)
+	assert.Equal(t, "running", persisted[0].ID)
+	assert.True(t, persisted[0].Running)
+
+	// Restart: a fresh Dogeboxd against the same store.
+	restarted, _ := newQueueTestDogeboxd(t, NewJobManager(jm.store.sm, nil), map[string]PupState{"pup1": pup})
+	resumed, skipped, err := restarted.ResumePersistedJobs()
+	require.NoError(t, err)
+	assert.Equal(t, 2, resumed)
+	assert.Equal(t, 3, skipped)
+
+	require.Len(t, restarted.queue.jobQueue, 2)
+	assert.Equal(t, "enable", restarted.queue.jobQueue[0].ID)
+	assert.Equal(t, EnablePup{PupID: "pup1"}, restarted.queue.jobQueue[0].A)
+	require.NotNil(t, restarted.queue.jobQueue[0].State)
+	assert.Equal(t, "pup1", restarted.queue.jobQueue[0].State.ID)
+	assert.Equal(t, "timezone", restarted.queue.jobQueue[1].ID)
+	assert.Equal(t, UpdateTimezone{Timezone: "Australia/Sydney"}, restarted.queue.jobQueue[1].A)
+
+	for _, id := range []string{"running", "install", "gone"} {
+		record, err := restarted.JobManager.GetJob(id)
+		require.NoError(t, err)
+		assert.Equal(t, JobStatusFailed, record.Status, id)
+		require.NotNil(t, record.Error, id)
+		assert.Equal(t, ERROR_JOB_INTERRUPTED, record.Error.Code, id)
+	}
+	record, err := restarted.JobManager.GetJob("enable")
+	require.NoError(t, err)
+	assert.Equal(t, JobStatusQueued, record.Status)
+	assert.True(t, restarted.JobManager.IsJobActive("enable"))
+
+	persisted, err = restarted.JobManager.LoadQueue()
+	require.NoError(t, err)
+	require.Len(t, persisted, 2)
+	assert.Equal(t, "enable", persisted[0].ID)
+	assert.Equal(t, "timezone", persisted[1].ID)
+}
+
+func TestFailedQueueSaveKeepsPreviousQueue(t *testing.T) {
+	jm, err := setupTestJobManager()
+	require.NoError(t, err)
+
+	previous := []PersistedJob{
+		{ID: "first", Position: 1, Action: "update-nix-cache"},
+		{ID: "second", Position: 2, Action: "enable-ssh"},
+	}
+	require.NoError(t, jm.SaveQueue(previous))
+
+	// Params that can't be encoded fail the save part way through.
+	err = jm.SaveQueue([]PersistedJob{
+		{ID: "third", Position: 1, Action: "update-nix-cache"},
+		{ID: "broken", Position: 2, Action: "enable-pup", Resumable: true, Params: json.RawMessage("{")},
+	})
+	require.Error(t, err)
+
+	persisted, err := jm.LoadQueue()
+	require.NoError(t, err)
+	require.Len(t, persisted, 2)
+	assert.Equal(t, "first", persisted[0].ID)
+	assert.Equal(t, "second", persisted[1].ID)
+}
+
+func TestPersistedQueueTracksRemovalAndCompletion(t *testing.T) {
+	jm, err := setupTestJobManager()
+	require.NoError(t, err)
+	dbx, _ := newQueueTestDogeboxd(t, jm, nil)
+
+	first := queueTestJob(t, dbx, "first", UpdateNixCache{}, nil)
+	second := queueTestJob(t, dbx, "second", EnableSSH{}, nil)
+	dbx.enqueue(first)
+	dbx.enqueue(second)
+
+	assert.True(t, dbx.RemoveFromQueue("second"))
+	dbx.pumpQueue()
+	dbx.clearCurrentSystemJobID("first")
+
+	persisted, err := jm.LoadQueue()
+	require.NoError(t, err)
+	assert.Empty(t, persisted)
+}
@@ -0,0 +1,160 @@
+package dogeboxd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// RetryPolicy describes how a job is retried after a transient failure.
+// Delays double after each attempt, starting at InitialDelay and capped
+// at MaxDelay.
+type RetryPolicy struct {
+	MaxAttempts  int
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+}
+
+// Delay returns how long to wait after the given (1-based) attempt fails
+// before starting the next one.
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	delay := p.InitialDelay
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= p.MaxDelay {
+			return p.MaxDelay
+		}
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return delay
+}
+
+// RetryableAction is implemented by Actions that are safe to run again
+// after a transient failure.
+type RetryableAction interface {
+	Action
+	RetryPolicy() RetryPolicy
+}
+
+func (InstallPup) RetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 3, InitialDelay: 10 * time.Second, MaxDelay: 2 * time.Minute}
+}
+
+func (SystemUpdate) RetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 3, InitialDelay: 30 * time.Second, MaxDelay: 5 * time.Minute}
+}
+
+// GetRetryPolicy returns the retry policy for an Action, if it has one.
+func GetRetryPolicy(a Action) (RetryPolicy, bool) {
+	r, ok := a.(RetryableAction)
+	if !ok {
+		return RetryPolicy{}, false
+	}
+	p := r.RetryPolicy()
+	return p, p.MaxAttempts > 1
+}
+
+// TransientError marks an error as worth retrying, eg: a network timeout.
+type TransientError struct {
+	Err error
+}
+
+func (e *TransientError) Error() string { return e.Err.Error() }
+func (e *TransientError) Unwrap() error { return e.Err }
+
+// Messages from git, nix and net/http that indicate a failure that may
+// well succeed if tried again.
+var transientErrorMessages = []string{
+	"timeout",
+	"timed out",
+	"connection reset",
+	"connection refused",
+	"no route to host",
+	"network is unreachable",
+	"temporary failure in name resolution",
+	"could not resolve host",
+	"unexpected eof",
+	"unable to download",
+	"http error 502",
+	"http error 503",
+	"http error 504",
+}
+
+// IsTransientError reports whether err looks like a transient failure,
+// either because it was marked as one or from what it says.
+func IsTransientError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var transient *TransientError
+	if errors.As(err, &transient) {
+		return true
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	msg := strings.ToLower(err.Error())
+	for _, m := range transientErrorMessages {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// retryJob puts a failed job back on the queue after its backoff, if its
+// Action has a retry policy, the failure was transient and it has attempts
+// left. It returns false when the job should be completed as normal.
+func (t *Dogeboxd) retryJob(j Job) bool {
+	if j.Err == "" || !j.Transient {
+		return false
+	}
+	policy, ok := GetRetryPolicy(j.A)
+	if !ok {
+		return false
+	}
+	attempt := j.Attempt
+	if attempt < 1 {
+		attempt = 1
+	}
+	if attempt >= policy.MaxAttempts {
+		return false
+	}
+
+	delay := policy.Delay(attempt)
+	now := time.Now()
+	j.Logger.Step("retry").Logf("Attempt %d of %d failed: %s. Retrying in %s", attempt, policy.MaxAttempts, j.Err, delay)
+
+	if t.JobManager != nil {
+		if err := t.JobManager.RecordJobRetry(j.ID, JobAttempt{
+			Attempt:  attempt,
+			Finished: now,
+			Error:    j.Err,
+			RetryAt:  now.Add(delay),
+		}); err != nil {
+			fmt.Printf("Warning: failed to record retry of job %s: %v\n", j.ID, err)
+		}
+	}
+
+	// Keep the job in runtime state while it waits, so it isn't orphaned.
+	t.markNonQueuedActiveJob(j.ID)
+
+	retry := j
+	retry.Attempt = attempt + 1
+	retry.Err = ""
+	retry.Transient = false
+	retry.Success = nil
+	time.AfterFunc(delay, func() { t.enqueue(retry) })
+	return true
+}
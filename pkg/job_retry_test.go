@@ -0,0 +1,102 @@
+package dogeboxd
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golanglibs/gocollections/set/hashset"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicyDelayBacksOffExponentially(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 5, InitialDelay: 10 * time.Second, MaxDelay: time.Minute}
+
+	assert.Equal(t, 10*time.Second, p.Delay(1))
+	assert.Equal(t, 20*time.Second, p.Delay(2))
+	assert.Equal(t, 40*time.Second, p.Delay(3))
+	assert.Equal(t, time.Minute, p.Delay(4))
+	assert.Equal(t, time.Minute, p.Delay(10))
+}
+
+func TestGetRetryPolicy(t *testing.T) {
+	_, ok := GetRetryPolicy(InstallPup{})
+	assert.True(t, ok)
+	_, ok = GetRetryPolicy(SystemUpdate{})
+	assert.True(t, ok)
+	_, ok = GetRetryPolicy(UninstallPup{})
+	assert.False(t, ok)
+}
+
+func TestIsTransientError(t *testing.T) {
+	assert.True(t, IsTransientError(&TransientError{Err: errors.New("cache hiccup")}))
+	assert.True(t, IsTransientError(fmt.Errorf("clone: %w", &TransientError{Err: errors.New("x")})))
+	assert.True(t, IsTransientError(errors.New("fatal: unable to access 'https://github.com/x/y.git/': Could not resolve host: github.com")))
+	assert.True(t, IsTransientError(errors.New("dial tcp 1.2.3.4:443: i/o timeout")))
+
+	assert.False(t, IsTransientError(nil))
+	assert.False(t, IsTransientError(errors.New("nix file hash mismatch")))
+}
+
+func setupRetryTestDogeboxd(t *testing.T) *Dogeboxd {
+	jm, err := setupTestJobManager()
+	require.NoError(t, err)
+
+	dbx := &Dogeboxd{
+		Changes:    make(chan Change, 100),
+		config:     &ServerConfig{ContainerLogDir: t.TempDir()},
+		JobManager: jm,
+		queue: &syncQueue{
+			jobQueue:            []Job{},
+			nonQueuedActiveJobs: hashset.New[string](),
+		},
+	}
+	jm.SetDogeboxd(dbx)
+	return dbx
+}
+
+func TestRetryJobRequeuesTransientFailure(t *testing.T) {
+	dbx := setupRetryTestDogeboxd(t)
+
+	job := createTestJob("InstallPup")
+	job.Attempt = 1
+	job.Logger = NewActionLogger(job, "", *dbx)
+	_, err := dbx.JobManager.CreateJobRecord(job)
+	require.NoError(t, err)
+
+	job.Err = "Failed to install pup"
+	job.Transient = true
+	assert.True(t, dbx.retryJob(job))
+
+	record, err := dbx.JobManager.GetJob(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, JobStatusQueued, record.Status)
+	assert.Equal(t, 2, record.Attempt)
+	assert.Equal(t, 3, record.MaxAttempts)
+	require.Len(t, record.Attempts, 1)
+	assert.Equal(t, "Failed to install pup", record.Attempts[0].Error)
+
+	// Still counted as running while it waits, so it won't be orphaned.
+	assert.Contains(t, dbx.GetRuntimeJobIDs(), job.ID)
+}
+
+func TestRetryJobCompletesPermanentOrExhaustedFailures(t *testing.T) {
+	dbx := setupRetryTestDogeboxd(t)
+
+	job := createTestJob("InstallPup")
+	job.Logger = NewActionLogger(job, "", *dbx)
+	job.Err = "nix file hash mismatch"
+	assert.False(t, dbx.retryJob(job), "permanent failure")
+
+	job.Transient = true
+	job.Attempt = 3
+	assert.False(t, dbx.retryJob(job), "out of attempts")
+
+	other := createTestJob("UninstallPup")
+	other.Logger = NewActionLogger(other, "", *dbx)
+	other.Err = "timed out"
+	other.Transient = true
+	assert.False(t, dbx.retryJob(other), "no retry policy")
+}
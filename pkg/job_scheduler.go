@@ -0,0 +1,297 @@
+package dogeboxd
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrScheduleNotFound = errors.New("schedule not found")
+
+// A ScheduledJob is a persisted, recurring Action that the JobScheduler
+// queues via AddAction whenever its cron schedule comes around.
+type ScheduledJob struct {
+	ID        string            `json:"id"`
+	Name      string            `json:"name"`
+	Schedule  string            `json:"schedule"` // cron expression, see CronSchedule
+	Action    string            `json:"action"`   // one of ScheduledActionKinds
+	Params    map[string]string `json:"params,omitempty"`
+	Enabled   bool              `json:"enabled"`
+	CreatedAt time.Time         `json:"createdAt"`
+	LastRunAt *time.Time        `json:"lastRunAt,omitempty"`
+	LastJobID string            `json:"lastJobId,omitempty"`
+}
+
+// ScheduledJobView is a ScheduledJob as returned by the API
+type ScheduledJobView struct {
+	ScheduledJob
+	NextRunAt *time.Time `json:"nextRunAt"`
+}
+
+// ScheduledActionKinds maps the action names a schedule can use to the
+// Action they queue. Only actions that make sense to repeat unattended
+// belong here.
+var ScheduledActionKinds = map[string]func(params map[string]string) (Action, error){
+	"check-pup-updates": func(params map[string]string) (Action, error) {
+		return CheckPupUpdates{PupID: params["pupId"]}, nil
+	},
+	"update-nix-cache": func(params map[string]string) (Action, error) {
+		return UpdateNixCache{}, nil
+	},
+	"backup-nix-config": func(params map[string]string) (Action, error) {
+		return BackupNixConfig{}, nil
+	},
+	"collect-nix-garbage": func(params map[string]string) (Action, error) {
+		a := CollectNixGarbage{}
+		if params["keepGenerations"] == "" {
+			return a, nil
+		}
+		keep, err := strconv.Atoi(params["keepGenerations"])
+		if err != nil {
+			return nil, errors.New("collect-nix-garbage keepGenerations must be a number")
+		}
+		if err := ValidateNixGCKeepGenerations(keep); err != nil {
+			return nil, err
+		}
+		a.KeepGenerations = keep
+		return a, nil
+	},
+	"enable-pup": func(params map[string]string) (Action, error) {
+		if params["pupId"] == "" {
+			return nil, errors.New("enable-pup requires a pupId param")
+		}
+		return EnablePup{PupID: params["pupId"]}, nil
+	},
+	"disable-pup": func(params map[string]string) (Action, error) {
+		if params["pupId"] == "" {
+			return nil, errors.New("disable-pup requires a pupId param")
+		}
+		return DisablePup{PupID: params["pupId"]}, nil
+	},
+}
+
+// Validate checks the schedule parses and its action can be built.
+func (s ScheduledJob) Validate() error {
+	if strings.TrimSpace(s.Name) == "" {
+		return errors.New("schedule name is required")
+	}
+	if _, err := ParseCronSchedule(s.Schedule); err != nil {
+		return err
+	}
+	build, ok := ScheduledActionKinds[s.Action]
+	if !ok {
+		kinds := make([]string, 0, len(ScheduledActionKinds))
+		for k := range ScheduledActionKinds {
+			kinds = append(kinds, k)
+		}
+		sort.Strings(kinds)
+		return fmt.Errorf("unknown scheduled action %q, expected one of: %s", s.Action, strings.Join(kinds, ", "))
+	}
+	_, err := build(s.Params)
+	return err
+}
+
+// NextRun returns when this schedule will next fire after `after`,
+// or nil if it is disabled or never fires.
+func (s ScheduledJob) NextRun(after time.Time) *time.Time {
+	if !s.Enabled {
+		return nil
+	}
+	cron, err := ParseCronSchedule(s.Schedule)
+	if err != nil {
+		return nil
+	}
+	next := cron.Next(after)
+	if next.IsZero() {
+		return nil
+	}
+	return &next
+}
+
+/* JobScheduler keeps a set of ScheduledJobs and queues their
+ * Actions at the right time. Schedules are checked once a minute,
+ * runs missed while dogeboxd was down are skipped rather than
+ * replayed.
+ */
+type JobScheduler struct {
+	store     *TypeStore[ScheduledJob]
+	addAction func(Action) string
+	mu        sync.Mutex
+	now       func() time.Time
+	lastTick  time.Time
+}
+
+func NewJobScheduler(sm *StoreManager, addAction func(Action) string) *JobScheduler {
+	return &JobScheduler{
+		store:     GetTypeStore[ScheduledJob](sm),
+		addAction: addAction,
+		now:       time.Now,
+	}
+}
+
+func (js *JobScheduler) Run(started, stopped chan bool, stop chan context.Context) error {
+	go func() {
+		js.mu.Lock()
+		js.lastTick = js.now()
+		js.mu.Unlock()
+
+		go func() {
+			timer := time.NewTimer(js.untilNextMinute())
+			defer timer.Stop()
+		mainloop:
+			for {
+				select {
+				case <-stop:
+					break mainloop
+				case <-timer.C:
+					js.tick()
+					timer.Reset(js.untilNextMinute())
+				}
+			}
+		}()
+
+		started <- true
+		<-stop
+		stopped <- true
+	}()
+	return nil
+}
+
+func (js *JobScheduler) untilNextMinute() time.Duration {
+	now := js.now()
+	return now.Truncate(time.Minute).Add(time.Minute).Sub(now)
+}
+
+// tick queues every enabled schedule that was due between the last
+// tick and now.
+func (js *JobScheduler) tick() {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	now := js.now()
+	since := js.lastTick
+	js.lastTick = now
+
+	schedules, err := js.list()
+	if err != nil {
+		fmt.Println("JobScheduler: failed to load schedules:", err)
+		return
+	}
+
+	for _, s := range schedules {
+		next := s.NextRun(since)
+		if next == nil || next.After(now) {
+			continue
+		}
+
+		build, ok := ScheduledActionKinds[s.Action]
+		if !ok {
+			fmt.Printf("JobScheduler: skipping schedule %s with unknown action %q\n", s.ID, s.Action)
+			continue
+		}
+		a, err := build(s.Params)
+		if err != nil {
+			fmt.Printf("JobScheduler: skipping schedule %s: %v\n", s.ID, err)
+			continue
+		}
+
+		s.LastJobID = js.addAction(a)
+		s.LastRunAt = &now
+		if err := js.store.Set(s.ID, s); err != nil {
+			fmt.Printf("JobScheduler: failed to record run of schedule %s: %v\n", s.ID, err)
+		}
+	}
+}
+
+func (js *JobScheduler) list() ([]ScheduledJob, error) {
+	query := fmt.Sprintf("SELECT value FROM %s ORDER BY json_extract(value, '$.createdAt') ASC", js.store.Table)
+	return js.store.Exec(query)
+}
+
+func (js *JobScheduler) view(s ScheduledJob) ScheduledJobView {
+	return ScheduledJobView{ScheduledJob: s, NextRunAt: s.NextRun(js.now())}
+}
+
+// List returns all schedules along with their next run time.
+func (js *JobScheduler) List() ([]ScheduledJobView, error) {
+	schedules, err := js.list()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ScheduledJobView, 0, len(schedules))
+	for _, s := range schedules {
+		out = append(out, js.view(s))
+	}
+	return out, nil
+}
+
+func (js *JobScheduler) Get(id string) (ScheduledJobView, error) {
+	s, err := js.store.Get(id)
+	if err != nil {
+		return ScheduledJobView{}, ErrScheduleNotFound
+	}
+	return js.view(s), nil
+}
+
+// Create validates and stores a new schedule, assigning its ID.
+func (js *JobScheduler) Create(s ScheduledJob) (ScheduledJobView, error) {
+	if err := s.Validate(); err != nil {
+		return ScheduledJobView{}, err
+	}
+
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return ScheduledJobView{}, err
+	}
+	s.ID = fmt.Sprintf("%x", b)
+	s.CreatedAt = js.now()
+	s.LastRunAt = nil
+	s.LastJobID = ""
+
+	if err := js.store.Set(s.ID, s); err != nil {
+		return ScheduledJobView{}, err
+	}
+	return js.view(s), nil
+}
+
+// Update replaces a schedule's name, cron expression, action, params and
+// enabled flag, keeping its run history.
+func (js *JobScheduler) Update(id string, s ScheduledJob) (ScheduledJobView, error) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	existing, err := js.store.Get(id)
+	if err != nil {
+		return ScheduledJobView{}, ErrScheduleNotFound
+	}
+	if err := s.Validate(); err != nil {
+		return ScheduledJobView{}, err
+	}
+
+	existing.Name = s.Name
+	existing.Schedule = s.Schedule
+	existing.Action = s.Action
+	existing.Params = s.Params
+	existing.Enabled = s.Enabled
+
+	if err := js.store.Set(id, existing); err != nil {
+		return ScheduledJobView{}, err
+	}
+	return js.view(existing), nil
+}
+
+func (js *JobScheduler) Delete(id string) error {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	if _, err := js.store.Get(id); err != nil {
+		return ErrScheduleNotFound
+	}
+	return js.store.Del(id)
+}
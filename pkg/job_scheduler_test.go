@@ -0,0 +1,110 @@
+package dogeboxd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTestJobScheduler(t *testing.T, now *time.Time) (*JobScheduler, *[]Action) {
+	sm, err := NewStoreManager(":memory:")
+	require.NoError(t, err)
+
+	queued := []Action{}
+	js := NewJobScheduler(sm, func(a Action) string {
+		queued = append(queued, a)
+		return "job-id"
+	})
+	js.now = func() time.Time { return *now }
+	js.lastTick = *now
+	return js, &queued
+}
+
+func TestJobSchedulerCreateValidates(t *testing.T) {
+	now := time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC)
+	js, _ := setupTestJobScheduler(t, &now)
+
+	_, err := js.Create(ScheduledJob{Name: "bad cron", Schedule: "nope", Action: "update-nix-cache"})
+	assert.Error(t, err)
+
+	_, err = js.Create(ScheduledJob{Name: "bad action", Schedule: "@daily", Action: "format-disk"})
+	assert.Error(t, err)
+
+	_, err = js.Create(ScheduledJob{Name: "missing pup", Schedule: "@daily", Action: "disable-pup"})
+	assert.Error(t, err)
+
+	view, err := js.Create(ScheduledJob{Name: "nightly updates", Schedule: "0 3 * * *", Action: "check-pup-updates", Enabled: true})
+	require.NoError(t, err)
+	assert.NotEmpty(t, view.ID)
+	require.NotNil(t, view.NextRunAt)
+	assert.Equal(t, time.Date(2026, 3, 5, 3, 0, 0, 0, time.UTC), *view.NextRunAt)
+}
+
+func TestJobSchedulerSchedulesNightlyBackups(t *testing.T) {
+	now := time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC)
+	js, _ := setupTestJobScheduler(t, &now)
+
+	view, err := js.Create(ScheduledJob{Name: "nightly backup", Schedule: "0 2 * * *", Action: "backup-nix-config", Enabled: true})
+	require.NoError(t, err)
+	require.NotNil(t, view.NextRunAt)
+	assert.Equal(t, time.Date(2026, 3, 5, 2, 0, 0, 0, time.UTC), *view.NextRunAt)
+
+	a, err := ScheduledActionKinds["backup-nix-config"](nil)
+	require.NoError(t, err)
+	assert.Equal(t, BackupNixConfig{}, a)
+}
+
+func TestJobSchedulerTickQueuesDueActions(t *testing.T) {
+	now := time.Date(2026, 3, 4, 2, 59, 0, 0, time.UTC)
+	js, queued := setupTestJobScheduler(t, &now)
+
+	due, err := js.Create(ScheduledJob{Name: "nightly updates", Schedule: "0 3 * * *", Action: "check-pup-updates", Enabled: true})
+	require.NoError(t, err)
+	_, err = js.Create(ScheduledJob{Name: "disabled", Schedule: "0 3 * * *", Action: "update-nix-cache", Enabled: false})
+	require.NoError(t, err)
+	_, err = js.Create(ScheduledJob{Name: "later", Schedule: "0 4 * * *", Action: "update-nix-cache", Enabled: true})
+	require.NoError(t, err)
+
+	now = now.Add(time.Minute)
+	js.tick()
+
+	require.Len(t, *queued, 1)
+	assert.Equal(t, CheckPupUpdates{}, (*queued)[0])
+
+	view, err := js.Get(due.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "job-id", view.LastJobID)
+	require.NotNil(t, view.LastRunAt)
+	assert.Equal(t, now, *view.LastRunAt)
+	assert.Equal(t, time.Date(2026, 3, 5, 3, 0, 0, 0, time.UTC), *view.NextRunAt)
+
+	// Nothing more is due within the same minute.
+	js.tick()
+	assert.Len(t, *queued, 1)
+}
+
+func TestJobSchedulerUpdateAndDelete(t *testing.T) {
+	now := time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC)
+	js, _ := setupTestJobScheduler(t, &now)
+
+	view, err := js.Create(ScheduledJob{Name: "cache", Schedule: "@daily", Action: "update-nix-cache", Enabled: true})
+	require.NoError(t, err)
+
+	updated, err := js.Update(view.ID, ScheduledJob{Name: "cache", Schedule: "@weekly", Action: "update-nix-cache", Enabled: false})
+	require.NoError(t, err)
+	assert.Equal(t, "@weekly", updated.Schedule)
+	assert.Nil(t, updated.NextRunAt)
+	assert.Equal(t, view.CreatedAt, updated.CreatedAt)
+
+	_, err = js.Update("missing", ScheduledJob{Name: "x", Schedule: "@daily", Action: "update-nix-cache"})
+	assert.ErrorIs(t, err, ErrScheduleNotFound)
+
+	require.NoError(t, js.Delete(view.ID))
+	assert.ErrorIs(t, js.Delete(view.ID), ErrScheduleNotFound)
+
+	all, err := js.List()
+	require.NoError(t, err)
+	assert.Empty(t, all)
+}
@@ -0,0 +1,141 @@
+package dogeboxd
+
+import (
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultJobTimeout is how long a job may run before it's killed, for
+// Actions without their own Timeout. Most jobs end in a nix rebuild,
+// which can be slow on small boards but shouldn't take an hour.
+const DefaultJobTimeout = time.Hour
+
+// TimeoutAction is implemented by Actions that need longer (or shorter)
+// than DefaultJobTimeout.
+type TimeoutAction interface {
+	Action
+	Timeout() time.Duration
+}
+
+// Pup installs and upgrades may build from source.
+func (InstallPup) Timeout() time.Duration         { return 2 * time.Hour }
+func (UpgradePup) Timeout() time.Duration         { return 2 * time.Hour }
+func (RollbackPupUpgrade) Timeout() time.Duration { return 2 * time.Hour }
+func (RebuildDevPup) Timeout() time.Duration      { return 2 * time.Hour }
+func (InitialBootstrap) Timeout() time.Duration   { return 2 * time.Hour }
+func (SystemUpdate) Timeout() time.Duration       { return 4 * time.Hour }
+
+// Importing a bundle's store export from a slow USB drive takes a while.
+func (SystemUpdateFromFile) Timeout() time.Duration { return 6 * time.Hour }
+
+// A bulk upgrade may build every pup it upgrades.
+func (BulkPupAction) Timeout() time.Duration { return 6 * time.Hour }
+
+// Copying a pup's storage can take a while for the big ones, ie: a chain.
+func (ExportPup) Timeout() time.Duration { return 12 * time.Hour }
+func (ImportPup) Timeout() time.Duration { return 12 * time.Hour }
+
+// Builds from the pup's nix file, after importing any store export.
+func (InstallPupBundle) Timeout() time.Duration { return 6 * time.Hour }
+
+// Deleting paths from a big store on an SD card is slow.
+func (CollectNixGarbage) Timeout() time.Duration { return 2 * time.Hour }
+
+// Switching only activates what's already built.
+func (RollbackSystem) Timeout() time.Duration { return 30 * time.Minute }
+
+// Only evaluates the system, though that's slow on small boards.
+func (ValidateCustomNix) Timeout() time.Duration { return 20 * time.Minute }
+
+// Builds everything pending, which can mean whole pups.
+func (DiffPendingChanges) Timeout() time.Duration { return 2 * time.Hour }
+
+// Copies the whole chain, which can be hundreds of GB from a slow disk.
+func (ImportBlockchainData) Timeout() time.Duration { return 24 * time.Hour }
+
+// GetJobTimeout returns how long a job for this Action may run.
+func GetJobTimeout(a Action) time.Duration {
+	if t, ok := a.(TimeoutAction); ok && t.Timeout() > 0 {
+		return t.Timeout()
+	}
+	return DefaultJobTimeout
+}
+
+// How long commands get to exit after SIGTERM before being killed.
+var jobCommandKillGrace = 10 * time.Second
+
+/* jobCommands tracks what a job has running that has to be stopped if
+ * it times out: the commands it started with RunCmd, and its rootd ops,
+ * which rootd stops when their connection is closed.
+ */
+type jobCommands struct {
+	mu    sync.Mutex
+	next  int
+	kills map[int]func()
+}
+
+func newJobCommands() *jobCommands {
+	return &jobCommands{kills: map[int]func(){}}
+}
+
+// track registers kill to stop something the job is running, returning
+// a func to call once it has finished.
+func (c *jobCommands) track(kill func()) func() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id := c.next
+	c.next++
+	c.kills[id] = kill
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		delete(c.kills, id)
+	}
+}
+
+// kill stops everything tracked, returning how many there were.
+func (c *jobCommands) kill() int {
+	c.mu.Lock()
+	kills := make([]func(), 0, len(c.kills))
+	for _, kill := range c.kills {
+		kills = append(kills, kill)
+	}
+	c.kills = map[int]func(){}
+	c.mu.Unlock()
+
+	for _, kill := range kills {
+		kill()
+	}
+	return len(kills)
+}
+
+/* RunCmd runs cmd for log's job (after log.LogCmd, for its output) in
+ * its own process group, which is tracked from when it starts so the
+ * whole command tree (ie: sudo, _dbxroot and nixos-rebuild) is killed if
+ * the job times out. sudo relays SIGTERM to what it runs as root, which
+ * we can't signal directly, so that goes first.
+ */
+func RunCmd(log SubLogger, cmd *exec.Cmd) error {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	pgid := cmd.Process.Pid
+	untrack := log.OnKill(func() { killProcessGroup(pgid) })
+	defer untrack()
+	return cmd.Wait()
+}
+
+func killProcessGroup(pgid int) {
+	syscall.Kill(-pgid, syscall.SIGTERM)
+	go func() {
+		time.Sleep(jobCommandKillGrace)
+		syscall.Kill(-pgid, syscall.SIGKILL)
+	}()
+}
@@ -0,0 +1,47 @@
+package dogeboxd
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetJobTimeout(t *testing.T) {
+	assert.Equal(t, DefaultJobTimeout, GetJobTimeout(UninstallPup{}))
+	assert.Equal(t, 2*time.Hour, GetJobTimeout(InstallPup{}))
+	assert.Equal(t, 24*time.Hour, GetJobTimeout(ImportBlockchainData{}))
+}
+
+func TestKillCommandsStopsCommandTree(t *testing.T) {
+	job := Job{ID: "job-timeout"}
+	job.Logger = NewActionLogger(job, "", Dogeboxd{Changes: make(chan Change, 64)})
+
+	// The backgrounded sleep is in the same process group, so is
+	// killed along with the shell. It's tracked from when it starts,
+	// before it has printed anything.
+	cmd := exec.Command("sh", "-c", "sleep 30 & wait")
+	log := job.Logger.Step("test")
+	log.LogCmd(cmd)
+
+	finished := make(chan error, 1)
+	go func() { finished <- RunCmd(log, cmd) }()
+
+	require.Eventually(t, func() bool {
+		job.Logger.cmds.mu.Lock()
+		defer job.Logger.cmds.mu.Unlock()
+		return len(job.Logger.cmds.kills) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, 1, job.Logger.KillCommands())
+
+	select {
+	case err := <-finished:
+		assert.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("command wasn't killed")
+	}
+	assert.Zero(t, job.Logger.KillCommands())
+}
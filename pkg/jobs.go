@@ -40,6 +40,27 @@ type JobRecord struct {
 	SummaryMessage string     `json:"summaryMessage"`
 	ErrorMessage   string     `json:"errorMessage"`
 	PupID          string     `json:"pupID"` // Associated pup if applicable
+	// When the current step should finish, for steps that can tell, ie: backups.
+	ETA *time.Time `json:"eta,omitempty"`
+	// Structured version of ErrorMessage, for failed jobs.
+	Error *APIError `json:"error,omitempty"`
+	// Which attempt is current, and how many are allowed, for jobs with a RetryPolicy.
+	Attempt     int `json:"attempt"`
+	MaxAttempts int `json:"maxAttempts"`
+	// Earlier attempts that failed and were retried.
+	Attempts []JobAttempt `json:"attempts,omitempty"`
+	// The job this one was queued by, ie: the upgrade a RestartPup follows.
+	ParentJobID string `json:"parentJobId,omitempty"`
+	// Who queued the job, see JOB_ACTOR_USER.
+	Actor string `json:"actor,omitempty"`
+}
+
+// A failed attempt of a job that was retried
+type JobAttempt struct {
+	Attempt  int       `json:"attempt"`
+	Finished time.Time `json:"finished"`
+	Error    string    `json:"error"`
+	RetryAt  time.Time `json:"retryAt"`
 }
 
 var reconciledInstalledOSFlakePath = "/etc/nixos/flake.nix"
@@ -52,7 +73,8 @@ var reconciledCurrentDBXRelease = func() string {
 // JobManager handles job persistence and state management
 type JobManager struct {
 	store      *TypeStore[JobRecord]
-	activeJobs map[string]*JobRecord // in-memory cache of active jobs
+	queue      *TypeStore[PersistedJob] // see Dogeboxd.ResumePersistedJobs
+	activeJobs map[string]*JobRecord    // in-memory cache of active jobs
 	jobsMutex  sync.RWMutex
 	dbx        *Dogeboxd
 }
@@ -60,6 +82,7 @@ type JobManager struct {
 func NewJobManager(sm *StoreManager, dbx *Dogeboxd) *JobManager {
 	return &JobManager{
 		store:      GetTypeStore[JobRecord](sm),
+		queue:      GetTypeStore[PersistedJob](sm),
 		activeJobs: make(map[string]*JobRecord),
 		dbx:        dbx,
 	}
@@ -92,10 +115,25 @@ func (jm *JobManager) CreateJobRecord(j Job) (*JobRecord, error) {
 		SummaryMessage: "Job queued",
 		ErrorMessage:   "",
 		PupID:          pupID,
+		Attempt:        1,
+		MaxAttempts:    1,
+		Actor:          j.Actor,
+	}
+	if policy, ok := GetRetryPolicy(j.A); ok {
+		record.MaxAttempts = policy.MaxAttempts
 	}
 	if action, ok := j.A.(SystemUpdate); ok {
 		record.TargetVersion = action.Version
 	}
+	if action, ok := j.A.(RestartPup); ok {
+		record.ParentJobID = action.ParentJobID
+	}
+	if action, ok := j.A.(InstallPup); ok {
+		record.ParentJobID = action.ParentJobID
+	}
+	if action, ok := j.A.(UninstallPup); ok {
+		record.ParentJobID = action.ParentJobID
+	}
 
 	if j.State != nil {
 		record.PupID = j.State.ID
@@ -169,6 +207,7 @@ func (jm *JobManager) UpdateJobProgress(ap ActionProgress) error {
 
 	// Update summary message
 	record.SummaryMessage = ap.Msg
+	record.ETA = ap.ETA
 
 	// Handle errors
 	if ap.Error {
@@ -181,6 +220,20 @@ func (jm *JobManager) UpdateJobProgress(ap ActionProgress) error {
 
 // CompleteJob marks a job as completed
 func (jm *JobManager) CompleteJob(jobID string, err string) error {
+	var jobErr *APIError
+	if err != "" {
+		jobErr = NewAPIError(ERROR_JOB_FAILED, err).ForJob(jobID)
+	}
+	return jm.CompleteJobWithError(jobID, jobErr)
+}
+
+// CompleteJobWithError marks a job finished, failed if jobErr is set.
+func (jm *JobManager) CompleteJobWithError(jobID string, jobErr *APIError) error {
+	err := ""
+	if jobErr != nil {
+		err = jobErr.Message
+	}
+
 	jm.jobsMutex.Lock()
 	defer jm.jobsMutex.Unlock()
 
@@ -196,10 +249,12 @@ func (jm *JobManager) CompleteJob(jobID string, err string) error {
 
 	now := time.Now()
 	record.Finished = &now
+	record.ETA = nil
 
 	if err != "" {
 		record.Status = JobStatusFailed
 		record.ErrorMessage = err
+		record.Error = jobErr
 		// Progress stays at current value
 		record.SummaryMessage = "Job failed"
 	} else {
@@ -224,6 +279,47 @@ func (jm *JobManager) CompleteJob(jobID string, err string) error {
 			eventType = "job:failed"
 		}
 		jm.dbx.SendChange(Change{ID: "internal", Type: eventType, Update: record})
+		if jm.dbx.Webhooks != nil {
+			jm.dbx.Webhooks.JobFinished(*record)
+		}
+	}
+
+	return nil
+}
+
+// RecordJobRetry records a failed attempt of a job and puts it back into
+// the queued state to wait for its next attempt.
+func (jm *JobManager) RecordJobRetry(jobID string, attempt JobAttempt) error {
+	jm.jobsMutex.Lock()
+	defer jm.jobsMutex.Unlock()
+
+	record, ok := jm.activeJobs[jobID]
+	if !ok {
+		recordValue, loadErr := jm.store.Get(jobID)
+		if loadErr != nil {
+			return fmt.Errorf("job not found: %s", jobID)
+		}
+		record = &recordValue
+		jm.activeJobs[jobID] = record
+	}
+
+	record.Attempts = append(record.Attempts, attempt)
+	record.Attempt = attempt.Attempt + 1
+	record.Status = JobStatusQueued
+	record.Progress = 0
+	record.ErrorMessage = attempt.Error
+	format := DefaultDisplayFormat()
+	if jm.dbx != nil {
+		format = jm.dbx.DisplayFormat()
+	}
+	record.SummaryMessage = fmt.Sprintf("Attempt %d of %d failed, retrying at %s", attempt.Attempt, record.MaxAttempts, format.Time(attempt.RetryAt))
+
+	if err := jm.store.Set(record.ID, *record); err != nil {
+		return err
+	}
+
+	if jm.dbx != nil {
+		jm.dbx.SendChange(Change{ID: "internal", Type: "job:retrying", Update: record})
 	}
 
 	return nil
@@ -252,6 +348,10 @@ func (jm *JobManager) MarkJobOrphaned(jobID string) error {
 	record.Status = JobStatusOrphaned
 	record.SummaryMessage = "Job marked as orphaned"
 	record.ErrorMessage = "Job is no longer being processed"
+	record.Error = NewAPIError(ERROR_JOB_ORPHANED, record.ErrorMessage).
+		ForJob(record.ID).
+		ForPup(record.PupID).
+		WithRemediation("Check the job log, then try again.")
 
 	delete(jm.activeJobs, jobID)
 
@@ -263,6 +363,8 @@ func (jm *JobManager) MarkJobOrphaned(jobID string) error {
 		jm.dbx.SendChange(Change{ID: "internal", Type: "job:orphaned", Update: record})
 	}
 
+	go jm.ArchiveJobLog(jobID)
+
 	return nil
 }
 
@@ -311,16 +413,75 @@ func (jm *JobManager) GetRecentJobs(limit int) ([]JobRecord, error) {
 	return jm.store.Exec(query)
 }
 
-// ClearCompletedJobs removes completed/failed jobs older than the specified duration
+// PupJobsQuery filters and pages a pup's job history.
+type PupJobsQuery struct {
+	Statuses []JobStatus // empty means any status
+	Limit    int
+	Offset   int
+}
+
+// GetJobsForPup returns a page of the jobs run against a pup, newest first,
+// along with how many jobs match in total.
+func (jm *JobManager) GetJobsForPup(pupID string, q PupJobsQuery) ([]JobRecord, int, error) {
+	query := fmt.Sprintf("SELECT value FROM %s WHERE json_extract(value, '$.pupID') = ?", jm.store.Table)
+	args := []interface{}{pupID}
+	if len(q.Statuses) > 0 {
+		placeholders := make([]string, len(q.Statuses))
+		for i, status := range q.Statuses {
+			placeholders[i] = "?"
+			args = append(args, string(status))
+		}
+		query += fmt.Sprintf(" AND json_extract(value, '$.status') IN (%s)", strings.Join(placeholders, ", "))
+	}
+	query += " ORDER BY json_extract(value, '$.started') DESC"
+
+	jobs, err := jm.store.Exec(query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total := len(jobs)
+	if q.Offset >= total {
+		return []JobRecord{}, total, nil
+	}
+	jobs = jobs[q.Offset:]
+	if q.Limit > 0 && q.Limit < len(jobs) {
+		jobs = jobs[:q.Limit]
+	}
+	return jobs, total, nil
+}
+
+// ClearCompletedJobs removes completed/failed jobs older than the specified
+// duration, along with their archived logs, then prunes the rest of the
+// log archive to the configured JobLogRetention.
 func (jm *JobManager) ClearCompletedJobs(olderThan time.Duration) (int, error) {
 	cutoff := time.Now().Add(-olderThan).Format(time.RFC3339Nano)
-	query := fmt.Sprintf(`DELETE FROM %s 
-		WHERE json_extract(value, '$.status') IN ('completed', 'failed', 'cancelled', 'orphaned')
+	where := `WHERE json_extract(value, '$.status') IN ('completed', 'failed', 'cancelled', 'orphaned')
 		  AND json_extract(value, '$.finished') IS NOT NULL
-		  AND json_extract(value, '$.finished') < ?`, jm.store.Table)
+		  AND json_extract(value, '$.finished') < ?`
 
-	count, err := jm.store.ExecWrite(query, cutoff)
-	return int(count), err
+	cleared, err := jm.store.Exec(fmt.Sprintf("SELECT value FROM %s %s", jm.store.Table, where), cutoff)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := jm.store.ExecWrite(fmt.Sprintf("DELETE FROM %s %s", jm.store.Table, where), cutoff)
+	if err != nil {
+		return int(count), err
+	}
+
+	if jm.dbx != nil && jm.dbx.config != nil && jm.dbx.config.DataDir != "" {
+		for _, job := range cleared {
+			if err := RemoveJobLogArchive(*jm.dbx.config, job.ID); err != nil {
+				fmt.Printf("Warning: failed to remove archived log for job %s: %v\n", job.ID, err)
+			}
+		}
+		if _, err := PruneJobLogArchive(*jm.dbx.config, jm.JobLogRetention(), time.Now()); err != nil {
+			fmt.Printf("Warning: failed to prune job log archive: %v\n", err)
+		}
+	}
+
+	return int(count), nil
 }
 
 // ClearAllJobs removes ALL jobs (for development/cleanup)
@@ -337,6 +498,12 @@ func (jm *JobManager) ClearAllJobs() (int, error) {
 	// Clear active jobs cache
 	jm.activeJobs = make(map[string]*JobRecord)
 
+	if jm.dbx != nil && jm.dbx.config != nil && jm.dbx.config.DataDir != "" {
+		if err := os.RemoveAll(jm.dbx.config.JobLogArchiveDir()); err != nil {
+			fmt.Printf("Warning: failed to clear job log archive: %v\n", err)
+		}
+	}
+
 	return int(count), nil
 }
 
@@ -346,6 +513,13 @@ func (jm *JobManager) DeleteJob(jobID string) error {
 	defer jm.jobsMutex.Unlock()
 
 	delete(jm.activeJobs, jobID)
+
+	if jm.dbx != nil && jm.dbx.config != nil && jm.dbx.config.DataDir != "" {
+		if err := RemoveJobLogArchive(*jm.dbx.config, jobID); err != nil {
+			fmt.Printf("Warning: failed to remove archived log for job %s: %v\n", jobID, err)
+		}
+	}
+
 	return jm.store.Del(jobID)
 }
 
@@ -472,6 +646,7 @@ func (jm *JobManager) markInterruptedSystemJobAsFailedLocked(job JobRecord, fini
 	job.Status = JobStatusFailed
 	job.Finished = &finished
 	job.ErrorMessage = interruptedSystemJobMessage(job)
+	job.Error = NewAPIError(ERROR_JOB_INTERRUPTED, job.ErrorMessage).ForJob(job.ID)
 	job.SummaryMessage = "Job failed"
 	if err := jm.store.Set(job.ID, job); err != nil {
 		return err
@@ -489,7 +664,7 @@ func isInterruptedSystemJob(job *JobRecord) bool {
 	if job == nil {
 		return false
 	}
-	if job.Action == (SystemUpdate{}).ActionName() {
+	if job.Action == (SystemUpdate{}).ActionName() || job.Action == (SystemUpdateFromFile{}).ActionName() {
 		return true
 	}
 
@@ -527,6 +702,12 @@ func (jm *JobManager) resolveSystemUpdateTargetVersion(job JobRecord) string {
 	return strings.TrimSpace(jm.readSystemUpdateTargetVersionFromLog(job.ID))
 }
 
+// InstalledOSFlakeVersion is the dbxRelease pinned by the OS flake in
+// /etc/nixos, ie: the release the next rebuild will apply.
+func InstalledOSFlakeVersion() (string, error) {
+	return getReconciledInstalledOSFlakeVersion()
+}
+
 func getReconciledInstalledOSFlakeVersion() (string, error) {
 	contents, err := reconciledReadFile(reconciledInstalledOSFlakePath)
 	if err != nil {
@@ -576,64 +757,183 @@ func (jm *JobManager) appendJobRecoveryLog(jobID string, msg string) {
 	_, _ = fmt.Fprintf(f, "[%s] %s\n", timestamp, msg)
 }
 
+// ArchiveJobLog persists the full log for a finished job into the job log archive
+func (jm *JobManager) ArchiveJobLog(jobID string) {
+	if jm.dbx == nil || jm.dbx.config == nil || jm.dbx.config.DataDir == "" {
+		return
+	}
+
+	if err := ArchiveJobLog(*jm.dbx.config, jm.JobLogRetention(), jobID); err != nil {
+		fmt.Printf("Warning: failed to archive log for job %s: %v\n", jobID, err)
+	}
+}
+
+// JobLogRetention is the configured retention for archived job logs.
+func (jm *JobManager) JobLogRetention() JobLogRetention {
+	if jm.dbx == nil || jm.dbx.sm == nil {
+		return JobLogRetention{}.WithDefaults()
+	}
+	return jm.dbx.sm.Get().Dogebox.JobLogRetention.WithDefaults()
+}
+
 // getDisplayName returns a human-readable name for the job
 func (jm *JobManager) getDisplayName(j Job) string {
 	switch a := j.A.(type) {
 	case InstallPup:
+		if a.Options.InstanceName != "" {
+			return fmt.Sprintf("Install %s (%s)", a.PupName, a.Options.InstanceName)
+		}
 		return fmt.Sprintf("Install %s", a.PupName)
 	case InstallPups:
 		if len(a) == 1 {
 			return fmt.Sprintf("Install %s", a[0].PupName)
 		}
 		return fmt.Sprintf("Install %d Pups", len(a))
+	case BulkPupAction:
+		verb := strings.ToUpper(a.Operation[:1]) + a.Operation[1:]
+		if len(a.PupIDs) == 1 && jm.dbx != nil {
+			if pup, _, err := jm.dbx.Pups.GetPup(a.PupIDs[0]); err == nil {
+				return fmt.Sprintf("%s %s", verb, pup.DisplayName())
+			}
+		}
+		return fmt.Sprintf("%s %d Pups", verb, len(a.PupIDs))
 	case UninstallPup:
 		// Try to get pup name from state first
 		if j.State != nil && j.State.Manifest.Meta.Name != "" {
-			return fmt.Sprintf("Uninstall %s", j.State.Manifest.Meta.Name)
+			return fmt.Sprintf("Uninstall %s", j.State.DisplayName())
 		}
 		// Fallback: look up pup by ID if we have access to dbx
 		if jm.dbx != nil {
 			if pup, _, err := jm.dbx.Pups.GetPup(a.PupID); err == nil {
-				return fmt.Sprintf("Uninstall %s", pup.Manifest.Meta.Name)
+				return fmt.Sprintf("Uninstall %s", pup.DisplayName())
 			}
 		}
 		return "Uninstall Pup"
 	case PurgePup:
 		// Try to get pup name from state first
 		if j.State != nil && j.State.Manifest.Meta.Name != "" {
-			return fmt.Sprintf("Purge %s", j.State.Manifest.Meta.Name)
+			return fmt.Sprintf("Purge %s", j.State.DisplayName())
 		}
 		// Fallback: look up pup by ID if we have access to dbx
 		if jm.dbx != nil {
 			if pup, _, err := jm.dbx.Pups.GetPup(a.PupID); err == nil {
-				return fmt.Sprintf("Purge %s", pup.Manifest.Meta.Name)
+				return fmt.Sprintf("Purge %s", pup.DisplayName())
 			}
 		}
 		return "Purge Pup"
 	case EnablePup:
 		// Try to get pup name from state first
 		if j.State != nil && j.State.Manifest.Meta.Name != "" {
-			return fmt.Sprintf("Enable %s", j.State.Manifest.Meta.Name)
+			return fmt.Sprintf("Enable %s", j.State.DisplayName())
 		}
 		// Fallback: look up pup by ID if we have access to dbx
 		if jm.dbx != nil {
 			if pup, _, err := jm.dbx.Pups.GetPup(a.PupID); err == nil {
-				return fmt.Sprintf("Enable %s", pup.Manifest.Meta.Name)
+				return fmt.Sprintf("Enable %s", pup.DisplayName())
 			}
 		}
 		return "Enable Pup"
 	case DisablePup:
 		// Try to get pup name from state first
 		if j.State != nil && j.State.Manifest.Meta.Name != "" {
-			return fmt.Sprintf("Disable %s", j.State.Manifest.Meta.Name)
+			return fmt.Sprintf("Disable %s", j.State.DisplayName())
 		}
 		// Fallback: look up pup by ID if we have access to dbx
 		if jm.dbx != nil {
 			if pup, _, err := jm.dbx.Pups.GetPup(a.PupID); err == nil {
-				return fmt.Sprintf("Disable %s", pup.Manifest.Meta.Name)
+				return fmt.Sprintf("Disable %s", pup.DisplayName())
 			}
 		}
 		return "Disable Pup"
+	case SetPupAutoStart:
+		verb := "Disable"
+		if a.AutoStart {
+			verb = "Enable"
+		}
+		if jm.dbx != nil {
+			if pup, _, err := jm.dbx.Pups.GetPup(a.PupID); err == nil {
+				return fmt.Sprintf("%s Auto-start for %s", verb, pup.DisplayName())
+			}
+		}
+		return fmt.Sprintf("%s Pup Auto-start", verb)
+	case SetPupMaintenance:
+		verb := "End"
+		if a.Enabled {
+			verb = "Start"
+		}
+		if jm.dbx != nil {
+			if pup, _, err := jm.dbx.Pups.GetPup(a.PupID); err == nil {
+				return fmt.Sprintf("%s Maintenance for %s", verb, pup.DisplayName())
+			}
+		}
+		return fmt.Sprintf("%s Pup Maintenance", verb)
+	case SetPupRestartSchedule:
+		if jm.dbx != nil {
+			if pup, _, err := jm.dbx.Pups.GetPup(a.PupID); err == nil {
+				return fmt.Sprintf("Update Restart Schedule for %s", pup.DisplayName())
+			}
+		}
+		return "Update Pup Restart Schedule"
+	case SetPupResourceLimits:
+		if jm.dbx != nil {
+			if pup, _, err := jm.dbx.Pups.GetPup(a.PupID); err == nil {
+				return fmt.Sprintf("Update Resource Limits for %s", pup.DisplayName())
+			}
+		}
+		return "Update Pup Resource Limits"
+	case SetPupTrustedCAs:
+		if jm.dbx != nil {
+			if pup, _, err := jm.dbx.Pups.GetPup(a.PupID); err == nil {
+				return fmt.Sprintf("Update Trusted CAs for %s", pup.DisplayName())
+			}
+		}
+		return "Update Pup Trusted CAs"
+	case SetPupDevices:
+		if jm.dbx != nil {
+			if pup, _, err := jm.dbx.Pups.GetPup(a.PupID); err == nil {
+				return fmt.Sprintf("Update Devices for %s", pup.DisplayName())
+			}
+		}
+		return "Update Pup Devices"
+	case SetPupStorageQuota:
+		if jm.dbx != nil {
+			if pup, _, err := jm.dbx.Pups.GetPup(a.PupID); err == nil {
+				return fmt.Sprintf("Update Storage Quota for %s", pup.DisplayName())
+			}
+		}
+		return "Update Pup Storage Quota"
+	case SetPupAutoUpdate:
+		if jm.dbx != nil {
+			if pup, _, err := jm.dbx.Pups.GetPup(a.PupID); err == nil {
+				return fmt.Sprintf("Update Auto-update Policy for %s", pup.DisplayName())
+			}
+		}
+		return "Update Pup Auto-update Policy"
+	case SetPupLogRetention:
+		if jm.dbx != nil {
+			if pup, _, err := jm.dbx.Pups.GetPup(a.PupID); err == nil {
+				return fmt.Sprintf("Update Log Retention for %s", pup.DisplayName())
+			}
+		}
+		return "Update Pup Log Retention"
+	case SetPupLogLevel:
+		verb := "Disable"
+		if a.Debug {
+			verb = "Enable"
+		}
+		if jm.dbx != nil {
+			if pup, _, err := jm.dbx.Pups.GetPup(a.PupID); err == nil {
+				return fmt.Sprintf("%s Debug Logging for %s", verb, pup.DisplayName())
+			}
+		}
+		return fmt.Sprintf("%s Pup Debug Logging", verb)
+	case SetPupEnvOverrides:
+		if jm.dbx != nil {
+			if pup, _, err := jm.dbx.Pups.GetPup(a.PupID); err == nil {
+				return fmt.Sprintf("Update Environment Overrides for %s", pup.DisplayName())
+			}
+		}
+		return "Update Pup Environment Overrides"
 	case UpdatePupConfig:
 		return "Update Pup Configuration"
 	case UpdatePupProviders:
@@ -646,6 +946,8 @@ func (jm *JobManager) getDisplayName(j Job) string {
 		return "Initial Setup"
 	case EnableSSH:
 		return "Enable SSH"
+	case EnableSSHTemporary:
+		return "Enable SSH Temporarily"
 	case DisableSSH:
 		return "Disable SSH"
 	case AddSSHKey:
@@ -654,52 +956,139 @@ func (jm *JobManager) getDisplayName(j Job) string {
 		return "Remove SSH Key"
 	case SaveCustomNix:
 		return "Save Custom OS Configuration"
+	case ValidateCustomNix:
+		return "Validate Custom OS Configuration"
+	case RestoreNixConfigBackup:
+		return "Restore OS Configuration Backup"
+	case BackupNixConfig:
+		return "Back Up OS Configuration"
 	case AddBinaryCache:
 		return "Add Binary Cache"
 	case RemoveBinaryCache:
 		return "Remove Binary Cache"
+	case RemoveTrustedCA:
+		return "Remove Trusted CA"
+	case RefreshSource:
+		return fmt.Sprintf("Refresh Source %s", a.SourceID)
 	case SystemUpdate:
 		return "System Update"
+	case SystemUpdateFromFile:
+		return "Offline System Update"
+	case ReapplySystemVersion:
+		return "Re-apply System Version"
+	case ApplyPendingChanges:
+		return "Apply Pending Changes"
+	case DiscardPendingChanges:
+		return "Discard Pending Changes"
+	case DiffPendingChanges:
+		return "Diff Pending Changes"
 	case UpdateMetrics:
 		return "Update Metrics"
+	case UpdatePupStatus:
+		return "Update Pup Status"
 	case UpdateTimezone:
 		return "Update Timezone"
 	case UpdateKeymap:
 		return "Update Keyboard Layout"
 	case UpdateNixCache:
 		return "Update Nix Cache"
+	case CollectNixGarbage:
+		return "Collect Nix Garbage"
+	case RollbackSystem:
+		return fmt.Sprintf("Roll Back to Generation %d", a.Generation)
+	case SetSafeMode:
+		if a.Enabled {
+			return "Enable Safe Mode"
+		}
+		return "Disable Safe Mode"
+	case SetAPMode:
+		if a.Enabled {
+			return "Enable Setup Access Point"
+		}
+		return "Disable Setup Access Point"
+	case UpdateWifiRegulatoryDomain:
+		return "Update WiFi Region"
 	case CheckPupUpdates:
 		if a.PupID != "" {
 			// Checking specific pup
 			if jm.dbx != nil {
 				if pup, _, err := jm.dbx.Pups.GetPup(a.PupID); err == nil {
-					return fmt.Sprintf("Check Updates for %s", pup.Manifest.Meta.Name)
+					return fmt.Sprintf("Check Updates for %s", pup.DisplayName())
 				}
 			}
 			return "Check Pup Updates"
 		}
 		return "Check All Pup Updates"
 	case UpgradePup:
+		verb := "Upgrade"
+		if a.Automatic {
+			verb = "Auto-upgrade"
+		}
 		// Try to get pup name from state first
 		if j.State != nil && j.State.Manifest.Meta.Name != "" {
-			return fmt.Sprintf("Upgrade %s to %s", j.State.Manifest.Meta.Name, a.TargetVersion)
+			return fmt.Sprintf("%s %s to %s", verb, j.State.DisplayName(), a.TargetVersion)
 		}
 		// Fallback: look up pup by ID if we have access to dbx
 		if jm.dbx != nil {
 			if pup, _, err := jm.dbx.Pups.GetPup(a.PupID); err == nil {
-				return fmt.Sprintf("Upgrade %s to %s", pup.Manifest.Meta.Name, a.TargetVersion)
+				return fmt.Sprintf("%s %s to %s", verb, pup.DisplayName(), a.TargetVersion)
+			}
+		}
+		return verb + " Pup"
+	case StartPup:
+		if jm.dbx != nil {
+			if pup, _, err := jm.dbx.Pups.GetPup(a.PupID); err == nil {
+				return fmt.Sprintf("Start %s", pup.DisplayName())
+			}
+		}
+		return "Start Pup"
+	case RestartPup:
+		if jm.dbx != nil {
+			if pup, _, err := jm.dbx.Pups.GetPup(a.PupID); err == nil {
+				return fmt.Sprintf("Restart %s", pup.DisplayName())
+			}
+		}
+		return "Restart Pup"
+	case RebuildDevPup:
+		if jm.dbx != nil {
+			if pup, _, err := jm.dbx.Pups.GetPup(a.PupID); err == nil {
+				return fmt.Sprintf("Rebuild %s", pup.DisplayName())
+			}
+		}
+		return "Rebuild Dev Pup"
+	case ExportPup:
+		if j.State != nil && j.State.Manifest.Meta.Name != "" {
+			return fmt.Sprintf("Export %s", j.State.DisplayName())
+		}
+		if jm.dbx != nil {
+			if pup, _, err := jm.dbx.Pups.GetPup(a.PupID); err == nil {
+				return fmt.Sprintf("Export %s", pup.DisplayName())
+			}
+		}
+		return "Export Pup"
+	case ImportPup:
+		if jm.dbx != nil && jm.dbx.config != nil {
+			if info, err := GetPupExport(*jm.dbx.config, a.ExportID); err == nil {
+				return fmt.Sprintf("Import %s", info.Export.PupName)
+			}
+		}
+		return "Import Pup"
+	case InstallPupBundle:
+		if jm.dbx != nil && jm.dbx.config != nil {
+			if info, err := GetPupBundle(*jm.dbx.config, a.BundleID); err == nil {
+				return fmt.Sprintf("Install %s from bundle", info.Bundle.PupName)
 			}
 		}
-		return "Upgrade Pup"
+		return "Install Pup Bundle"
 	case RollbackPupUpgrade:
 		// Try to get pup name from state first
 		if j.State != nil && j.State.Manifest.Meta.Name != "" {
-			return fmt.Sprintf("Rollback %s", j.State.Manifest.Meta.Name)
+			return fmt.Sprintf("Rollback %s", j.State.DisplayName())
 		}
 		// Fallback: look up pup by ID if we have access to dbx
 		if jm.dbx != nil {
 			if pup, _, err := jm.dbx.Pups.GetPup(a.PupID); err == nil {
-				return fmt.Sprintf("Rollback %s", pup.Manifest.Meta.Name)
+				return fmt.Sprintf("Rollback %s", pup.DisplayName())
 			}
 		}
 		return "Rollback Pup"
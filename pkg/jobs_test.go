@@ -91,6 +91,20 @@ func TestJobCreationDisplayName(t *testing.T) {
 	assert.Equal(t, "Install test-app", record.DisplayName)
 }
 
+func TestDisplayNameInstallPupInstance(t *testing.T) {
+	jm, err := setupTestJobManager()
+	require.NoError(t, err)
+
+	job := createTestJob("InstallPup")
+	install := job.A.(InstallPup)
+	install.Options.InstanceName = "testnet"
+	job.A = install
+	record, err := jm.CreateJobRecord(job)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Install test-app (testnet)", record.DisplayName)
+}
+
 func TestJobCreationStoresSystemUpdateTargetVersion(t *testing.T) {
 	jm, err := setupTestJobManager()
 	require.NoError(t, err)
@@ -130,6 +144,30 @@ func TestJobProgressUpdate(t *testing.T) {
 	assert.Equal(t, 50, updated.Progress)
 }
 
+func TestJobProgressUpdateRecordsETA(t *testing.T) {
+	jm, err := setupTestJobManager()
+	require.NoError(t, err)
+
+	job := createTestJob("InstallPup")
+	_, err = jm.CreateJobRecord(job)
+	require.NoError(t, err)
+
+	eta := time.Now().Add(time.Minute).Truncate(time.Second)
+	progress := createTestActionProgress(job.ID, 20, "restore", "Restoring nix config: 1.0 MiB of 4.0 MiB (25%)")
+	progress.ETA = &eta
+	require.NoError(t, jm.UpdateJobProgress(progress))
+
+	updated, err := jm.GetJob(job.ID)
+	require.NoError(t, err)
+	require.NotNil(t, updated.ETA)
+	assert.True(t, eta.Equal(*updated.ETA))
+
+	require.NoError(t, jm.CompleteJob(job.ID, ""))
+	updated, err = jm.GetJob(job.ID)
+	require.NoError(t, err)
+	assert.Nil(t, updated.ETA)
+}
+
 func TestJobProgressTransitionToInProgress(t *testing.T) {
 	jm, err := setupTestJobManager()
 	require.NoError(t, err)
@@ -280,6 +318,9 @@ func TestJobCompletionWithError(t *testing.T) {
 	assert.Equal(t, JobStatusFailed, failed.Status)
 	assert.Equal(t, errMsg, failed.ErrorMessage)
 	assert.NotNil(t, failed.Finished)
+	require.NotNil(t, failed.Error)
+	assert.Equal(t, ERROR_JOB_FAILED, failed.Error.Code)
+	assert.Equal(t, job.ID, failed.Error.JobID)
 }
 
 func TestJobCompletionRemovedFromActiveCache(t *testing.T) {
@@ -497,6 +538,28 @@ func TestClearCompletedJobs(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestClearCompletedJobsRemovesArchivedLogs(t *testing.T) {
+	sm, err := NewStoreManager(":memory:")
+	require.NoError(t, err)
+	config := &ServerConfig{DataDir: t.TempDir()}
+	jm := NewJobManager(sm, &Dogeboxd{Changes: make(chan Change, 100), config: config})
+
+	completedJob := createTestJob("InstallPup")
+	_, err = jm.CreateJobRecord(completedJob)
+	require.NoError(t, err)
+	require.NoError(t, jm.CompleteJob(completedJob.ID, ""))
+
+	require.NoError(t, os.MkdirAll(config.JobLogArchiveDir(), 0750))
+	require.NoError(t, os.WriteFile(config.JobLogArchivePath(completedJob.ID), nil, 0640))
+
+	count, err := jm.ClearCompletedJobs(0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	_, err = os.Stat(config.JobLogArchivePath(completedJob.ID))
+	assert.True(t, os.IsNotExist(err))
+}
+
 func TestClearAllJobs(t *testing.T) {
 	jm, err := setupTestJobManager()
 	require.NoError(t, err)
@@ -720,6 +783,166 @@ func TestDisplayNameDisablePup(t *testing.T) {
 	assert.Equal(t, "Disable Pup", record.DisplayName)
 }
 
+func TestDisplayNameStartPup(t *testing.T) {
+	jm, err := setupTestJobManager()
+	require.NoError(t, err)
+
+	job := createTestJob("StartPup")
+	record, err := jm.CreateJobRecord(job)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Start Pup", record.DisplayName)
+}
+
+func TestDisplayNameSetPupAutoStart(t *testing.T) {
+	jm, err := setupTestJobManager()
+	require.NoError(t, err)
+
+	job := createTestJob("SetPupAutoStart")
+	record, err := jm.CreateJobRecord(job)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Disable Pup Auto-start", record.DisplayName)
+}
+
+func TestDisplayNameSetPupMaintenance(t *testing.T) {
+	jm, err := setupTestJobManager()
+	require.NoError(t, err)
+
+	job := createTestJob("SetPupMaintenance")
+	record, err := jm.CreateJobRecord(job)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Start Pup Maintenance", record.DisplayName)
+}
+
+func TestDisplayNameSetPupRestartSchedule(t *testing.T) {
+	jm, err := setupTestJobManager()
+	require.NoError(t, err)
+
+	job := createTestJob("SetPupRestartSchedule")
+	record, err := jm.CreateJobRecord(job)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Update Pup Restart Schedule", record.DisplayName)
+}
+
+func TestDisplayNameSetPupResourceLimits(t *testing.T) {
+	jm, err := setupTestJobManager()
+	require.NoError(t, err)
+
+	job := createTestJob("SetPupResourceLimits")
+	record, err := jm.CreateJobRecord(job)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Update Pup Resource Limits", record.DisplayName)
+}
+
+func TestDisplayNameSetPupStorageQuota(t *testing.T) {
+	jm, err := setupTestJobManager()
+	require.NoError(t, err)
+
+	job := createTestJob("SetPupStorageQuota")
+	record, err := jm.CreateJobRecord(job)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Update Pup Storage Quota", record.DisplayName)
+}
+
+func TestDisplayNameSetPupAutoUpdate(t *testing.T) {
+	jm, err := setupTestJobManager()
+	require.NoError(t, err)
+
+	job := createTestJob("SetPupAutoUpdate")
+	record, err := jm.CreateJobRecord(job)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Update Pup Auto-update Policy", record.DisplayName)
+}
+
+func TestDisplayNameAutomaticUpgradePup(t *testing.T) {
+	jm, err := setupTestJobManager()
+	require.NoError(t, err)
+
+	job := Job{
+		ID:    "test-auto-upgrade",
+		Start: time.Now(),
+		A:     UpgradePup{PupID: "test-pup-id", TargetVersion: "1.2.4", Automatic: true},
+		State: &PupState{ID: "test-pup-id", Manifest: PupManifest{Meta: PupManifestMeta{Name: "Dogecoin Core"}}},
+	}
+	record, err := jm.CreateJobRecord(job)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Auto-upgrade Dogecoin Core to 1.2.4", record.DisplayName)
+	assert.Equal(t, "test-pup-id", record.PupID)
+}
+
+func TestRestartPupRecordLinksToParentJob(t *testing.T) {
+	jm, err := setupTestJobManager()
+	require.NoError(t, err)
+
+	job := createTestJob("RestartPup")
+	record, err := jm.CreateJobRecord(job)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Restart Pup", record.DisplayName)
+	assert.Equal(t, "test-pup-id", record.PupID)
+	assert.Equal(t, "test-upgrade-job", record.ParentJobID)
+}
+
+func TestDependencyInstallRecordLinksToParentJob(t *testing.T) {
+	jm, err := setupTestJobManager()
+	require.NoError(t, err)
+
+	job := Job{
+		ID: "test-dependency-job",
+		A:  InstallPup{PupName: "Dogecoin Core", ProvideFor: "test-pup-id", ProvideInterfaces: []string{"core-rpc"}, ParentJobID: "test-install-job"},
+	}
+	record, err := jm.CreateJobRecord(job)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Install Dogecoin Core", record.DisplayName)
+	assert.Equal(t, "test-install-job", record.ParentJobID)
+}
+
+func TestOrphanedProviderUninstallRecordLinksToParentJob(t *testing.T) {
+	jm, err := setupTestJobManager()
+	require.NoError(t, err)
+
+	job := Job{ID: "test-provider-job", A: UninstallPup{PupID: "test-provider-id", ParentJobID: "test-uninstall-job"}}
+	record, err := jm.CreateJobRecord(job)
+	require.NoError(t, err)
+
+	assert.Equal(t, "test-uninstall-job", record.ParentJobID)
+}
+
+func TestDisplayNameExportPup(t *testing.T) {
+	jm, err := setupTestJobManager()
+	require.NoError(t, err)
+
+	job := Job{
+		ID:    "test-export",
+		Start: time.Now(),
+		A:     ExportPup{PupID: "test-pup-id", IncludeStorage: true},
+		State: &PupState{ID: "test-pup-id", Manifest: PupManifest{Meta: PupManifestMeta{Name: "Dogecoin Core"}}},
+	}
+	record, err := jm.CreateJobRecord(job)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Export Dogecoin Core", record.DisplayName)
+	assert.Equal(t, "test-pup-id", record.PupID)
+}
+
+func TestStartsOnBoot(t *testing.T) {
+	off := false
+	on := true
+
+	assert.True(t, PupState{Enabled: true}.StartsOnBoot())
+	assert.True(t, PupState{Enabled: true, AutoStart: &on}.StartsOnBoot())
+	assert.False(t, PupState{Enabled: true, AutoStart: &off}.StartsOnBoot())
+	assert.False(t, PupState{Enabled: false, AutoStart: &on}.StartsOnBoot())
+}
+
 func TestDisplayNameUpdatePupConfig(t *testing.T) {
 	jm, err := setupTestJobManager()
 	require.NoError(t, err)
@@ -841,6 +1064,50 @@ func TestDisplayNameUpdateMetrics(t *testing.T) {
 	assert.Equal(t, "Update Metrics", record.DisplayName)
 }
 
+func TestDisplayNameReapplySystemVersion(t *testing.T) {
+	jm, err := setupTestJobManager()
+	require.NoError(t, err)
+
+	job := createTestJob("ReapplySystemVersion")
+	record, err := jm.CreateJobRecord(job)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Re-apply System Version", record.DisplayName)
+}
+
+func TestDisplayNameApplyPendingChanges(t *testing.T) {
+	jm, err := setupTestJobManager()
+	require.NoError(t, err)
+
+	job := createTestJob("ApplyPendingChanges")
+	record, err := jm.CreateJobRecord(job)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Apply Pending Changes", record.DisplayName)
+}
+
+func TestDisplayNameDiffPendingChanges(t *testing.T) {
+	jm, err := setupTestJobManager()
+	require.NoError(t, err)
+
+	job := createTestJob("DiffPendingChanges")
+	record, err := jm.CreateJobRecord(job)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Diff Pending Changes", record.DisplayName)
+}
+
+func TestDisplayNameSetPupLogLevel(t *testing.T) {
+	jm, err := setupTestJobManager()
+	require.NoError(t, err)
+
+	job := createTestJob("SetPupLogLevel")
+	record, err := jm.CreateJobRecord(job)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Enable Pup Debug Logging", record.DisplayName)
+}
+
 func TestDisplayNameUnknownAction(t *testing.T) {
 	jm, err := setupTestJobManager()
 	require.NoError(t, err)
@@ -1122,3 +1389,47 @@ func TestSendFinishedJobCompletesTrackedJobAndClearsRuntimeTracking(t *testing.T
 	assert.Equal(t, JobStatusCompleted, completedJob.Status)
 	assert.Empty(t, dbx.GetRuntimeJobIDs())
 }
+
+func TestDisplayNameEnableSSHTemporary(t *testing.T) {
+	jm, err := setupTestJobManager()
+	require.NoError(t, err)
+
+	job := createTestJob("EnableSSHTemporary")
+	record, err := jm.CreateJobRecord(job)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Enable SSH Temporarily", record.DisplayName)
+}
+
+func TestDisplayNameCollectNixGarbage(t *testing.T) {
+	jm, err := setupTestJobManager()
+	require.NoError(t, err)
+
+	job := createTestJob("CollectNixGarbage")
+	record, err := jm.CreateJobRecord(job)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Collect Nix Garbage", record.DisplayName)
+}
+
+func TestDisplayNameRollbackSystem(t *testing.T) {
+	jm, err := setupTestJobManager()
+	require.NoError(t, err)
+
+	job := createTestJob("RollbackSystem")
+	record, err := jm.CreateJobRecord(job)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Roll Back to Generation 41", record.DisplayName)
+}
+
+func TestDisplayNameValidateCustomNix(t *testing.T) {
+	jm, err := setupTestJobManager()
+	require.NoError(t, err)
+
+	job := createTestJob("ValidateCustomNix")
+	record, err := jm.CreateJobRecord(job)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Validate Custom OS Configuration", record.DisplayName)
+}
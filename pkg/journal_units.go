@@ -0,0 +1,108 @@
+package dogeboxd
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+/* A JournalUnit is a systemd unit whose journal can be streamed like a
+ * pup's log, by ID, through the /log/pup endpoints. dogeboxd's own units
+ * are always streamable, as is each installed pup's container unit (as
+ * "container-<pup id>"), and more can be added, ie: for units brought in
+ * by custom nix.
+ */
+type JournalUnit struct {
+	ID          string `json:"id"`
+	Unit        string `json:"unit"`
+	Description string `json:"description"`
+	// Built in units can't be removed.
+	BuiltIn bool `json:"builtIn"`
+	// Set for a pup's container unit.
+	PupID string `json:"pupId,omitempty"`
+}
+
+// The units dogeboxd runs or renders into the system config.
+var builtInJournalUnits = []JournalUnit{
+	{ID: "dbx", Unit: "dogeboxd.service", Description: "Dogebox daemon"},
+	{ID: "dkm", Unit: "dkm.service", Description: "Dogebox key manager"},
+	{ID: "sshd", Unit: "sshd.service", Description: "SSH server"},
+	{ID: "recovery-ap", Unit: "create_ap.service", Description: "Recovery access point"},
+	{ID: "storage-overlay", Unit: "mount-data-overlay.service", Description: "Storage overlay mount"},
+}
+
+const pupContainerJournalUnitPrefix = "container-"
+
+var (
+	journalUnitIDPattern   = regexp.MustCompile(`^[a-z][a-z0-9-]{0,31}$`)
+	journalUnitNamePattern = regexp.MustCompile(`^[A-Za-z0-9:_.@-]+\.(service|socket|timer|mount|path)$`)
+)
+
+// ValidateJournalUnit checks u is a unit that can be added, its ID not
+// clashing with a built in one or a pup container.
+func ValidateJournalUnit(u JournalUnit) error {
+	if !journalUnitIDPattern.MatchString(u.ID) {
+		return fmt.Errorf("ID must be lowercase letters, numbers and dashes, starting with a letter")
+	}
+	if strings.HasPrefix(u.ID, pupContainerJournalUnitPrefix) {
+		return fmt.Errorf("IDs starting with %q are kept for pup containers", pupContainerJournalUnitPrefix)
+	}
+	for _, b := range builtInJournalUnits {
+		if b.ID == u.ID {
+			return fmt.Errorf("%s is a built in unit", u.ID)
+		}
+	}
+	if !journalUnitNamePattern.MatchString(u.Unit) {
+		return fmt.Errorf("unit must be a systemd unit name, ie: tor.service")
+	}
+	return nil
+}
+
+// JournalUnits returns every streamable unit: the built in ones, those
+// added to dbxState, then a container unit for each of pups.
+func JournalUnits(dbxState DogeboxState, pups map[string]PupState) []JournalUnit {
+	units := []JournalUnit{}
+	for _, u := range builtInJournalUnits {
+		u.BuiltIn = true
+		units = append(units, u)
+	}
+	units = append(units, dbxState.JournalUnits...)
+
+	ids := make([]string, 0, len(pups))
+	for id := range pups {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		units = append(units, pupContainerJournalUnit(pups[id]))
+	}
+	return units
+}
+
+func pupContainerJournalUnit(p PupState) JournalUnit {
+	return JournalUnit{
+		ID:          pupContainerJournalUnitPrefix + p.ID,
+		Unit:        fmt.Sprintf("container@pup-%s.service", p.ID),
+		Description: p.Manifest.Meta.Name + " container",
+		BuiltIn:     true,
+		PupID:       p.ID,
+	}
+}
+
+// JournalUnit returns the unit with id that isn't a pup container, see
+// Dogeboxd.resolvePupLogSource for those.
+func (s DogeboxState) JournalUnit(id string) (JournalUnit, bool) {
+	for _, u := range builtInJournalUnits {
+		if u.ID == id {
+			u.BuiltIn = true
+			return u, true
+		}
+	}
+	for _, u := range s.JournalUnits {
+		if u.ID == id {
+			return u, true
+		}
+	}
+	return JournalUnit{}, false
+}
@@ -0,0 +1,86 @@
+package dogeboxd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateJournalUnit(t *testing.T) {
+	tests := map[string]struct {
+		unit    JournalUnit
+		wantErr bool
+	}{
+		"service":             {unit: JournalUnit{ID: "tor", Unit: "tor.service"}},
+		"templated service":   {unit: JournalUnit{ID: "wg", Unit: "wireguard-wg0@peer.service"}},
+		"timer":               {unit: JournalUnit{ID: "backup-timer", Unit: "backup.timer"}},
+		"uppercase id":        {unit: JournalUnit{ID: "Tor", Unit: "tor.service"}, wantErr: true},
+		"built in id":         {unit: JournalUnit{ID: "dbx", Unit: "tor.service"}, wantErr: true},
+		"pup container id":    {unit: JournalUnit{ID: "container-abc", Unit: "tor.service"}, wantErr: true},
+		"no unit suffix":      {unit: JournalUnit{ID: "tor", Unit: "tor"}, wantErr: true},
+		"unit with a space":   {unit: JournalUnit{ID: "tor", Unit: "tor .service"}, wantErr: true},
+		"unit with a newline": {unit: JournalUnit{ID: "tor", Unit: "tor.service\n_PID=1"}, wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := ValidateJournalUnit(tc.unit)
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestJournalUnitsListsBuiltInCustomAndPupUnits(t *testing.T) {
+	dbxState := DogeboxState{JournalUnits: []JournalUnit{{ID: "tor", Unit: "tor.service"}}}
+	pups := map[string]PupState{
+		"b": {ID: "b", Manifest: PupManifest{Meta: PupManifestMeta{Name: "Core"}}},
+		"a": {ID: "a", Manifest: PupManifest{Meta: PupManifestMeta{Name: "Shibe"}}},
+	}
+
+	units := JournalUnits(dbxState, pups)
+	ids := []string{}
+	for _, u := range units {
+		ids = append(ids, u.ID)
+	}
+	assert.Equal(t, []string{"dbx", "dkm", "sshd", "recovery-ap", "storage-overlay", "tor", "container-a", "container-b"}, ids)
+
+	last := units[len(units)-1]
+	assert.Equal(t, "container@pup-b.service", last.Unit)
+	assert.Equal(t, "Core container", last.Description)
+	assert.Equal(t, "b", last.PupID)
+	assert.True(t, last.BuiltIn)
+	assert.False(t, units[5].BuiltIn)
+}
+
+func TestResolvePupLogSourceUsesJournalUnits(t *testing.T) {
+	dbx := Dogeboxd{
+		sm:     pupLogStateManager{dbx: DogeboxState{JournalUnits: []JournalUnit{{ID: "tor", Unit: "tor.service"}}}},
+		Pups:   queueTestPupManager{pups: map[string]PupState{"abc": {ID: "abc"}}},
+		config: &ServerConfig{ContainerLogDir: t.TempDir()},
+	}
+
+	tests := map[string]logSource{
+		"dbx":           {journalService: "dogeboxd.service"},
+		"recovery-ap":   {journalService: "create_ap.service"},
+		"tor":           {journalService: "tor.service"},
+		"container-abc": {journalService: "container@pup-abc.service"},
+		"abc":           {filePath: dbx.config.PupLogPath("abc")},
+	}
+	for id, want := range tests {
+		t.Run(id, func(t *testing.T) {
+			source, err := dbx.resolvePupLogSource(id)
+			require.NoError(t, err)
+			assert.Equal(t, want, source)
+		})
+	}
+
+	_, err := dbx.resolvePupLogSource("container-missing")
+	assert.Error(t, err)
+	_, err = dbx.resolvePupLogSource("nginx")
+	assert.Error(t, err)
+}
@@ -1,6 +1,7 @@
 package dogeboxd
 
 import (
+	"fmt"
 	"path/filepath"
 )
 
@@ -17,6 +18,11 @@ func (c ServerConfig) PupLogPath(pupID string) string {
 	return filepath.Join(c.ContainerLogDir, c.PupLogFileName(pupID))
 }
 
+// Kept alongside the pup's state, as the pup directory is replaced on upgrade.
+func (c ServerConfig) PupBuildLogPath(pupID string) string {
+	return filepath.Join(c.DataDir, "pups", fmt.Sprintf("pup_%s.build.log", pupID))
+}
+
 func (c ServerConfig) JobLogFileName(jobID string) string {
 	return jobLogPrefix + jobID
 }
@@ -24,3 +30,11 @@ func (c ServerConfig) JobLogFileName(jobID string) string {
 func (c ServerConfig) JobLogPath(jobID string) string {
 	return filepath.Join(c.ContainerLogDir, c.JobLogFileName(jobID))
 }
+
+func (c ServerConfig) JobLogArchiveDir() string {
+	return filepath.Join(c.DataDir, "job-logs")
+}
+
+func (c ServerConfig) JobLogArchivePath(jobID string) string {
+	return filepath.Join(c.JobLogArchiveDir(), c.JobLogFileName(jobID)+".log.gz")
+}
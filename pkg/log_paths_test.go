@@ -48,6 +48,12 @@ func (t *stubLogTailer) GetPage(path string, before *int64, limit int) (LogPage,
 	}, nil
 }
 
+func (t *stubLogTailer) Query(path string, q LogQuery) (LogQueryPage, error) {
+	t.lastPagePath = path
+	t.lastPageLimit = q.Limit
+	return LogQueryPage{Entries: []LogEntry{{Line: filepath.Base(path)}}}, nil
+}
+
 type stubJournalReader struct {
 	lastChannelService string
 	lastChannelCursor  string
@@ -208,3 +214,16 @@ func TestServerConfigLogFileNames(t *testing.T) {
 	assert.Equal(t, "job-demo", config.JobLogFileName("demo"))
 	assert.Equal(t, filepath.Join("/tmp/logs", "job-demo"), config.JobLogPath("demo"))
 }
+
+func TestDogeboxdQueryLogsRefusesJournalSources(t *testing.T) {
+	logtailer := &stubLogTailer{}
+	dbx := Dogeboxd{
+		JournalReader: &stubJournalReader{},
+		logtailer:     logtailer,
+		config:        &ServerConfig{ContainerLogDir: t.TempDir()},
+	}
+
+	_, err := dbx.QueryLogs("dbx", LogQuery{Limit: 10})
+	require.Error(t, err)
+	assert.Equal(t, "", logtailer.lastPagePath)
+}
@@ -0,0 +1,124 @@
+package dogeboxd
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	LOG_SEVERITY_DEBUG   = "debug"
+	LOG_SEVERITY_INFO    = "info"
+	LOG_SEVERITY_WARNING = "warning"
+	LOG_SEVERITY_ERROR   = "error"
+)
+
+var logSeverityRank = map[string]int{
+	LOG_SEVERITY_DEBUG:   0,
+	LOG_SEVERITY_INFO:    1,
+	LOG_SEVERITY_WARNING: 2,
+	LOG_SEVERITY_ERROR:   3,
+}
+
+const MAX_LOG_QUERY_LIMIT = 1000
+
+/* A LogQuery fetches a page of a pup's exported container log, oldest
+ * first, from Since (or the start of the log) up to Until. MinSeverity
+ * drops anything less severe. Cursor continues from where the previous
+ * page's NextCursor left off.
+ */
+type LogQuery struct {
+	Since       *time.Time
+	Until       *time.Time
+	MinSeverity string
+	Cursor      *string
+	Limit       int
+}
+
+func (q LogQuery) Validate() error {
+	if q.Limit <= 0 || q.Limit > MAX_LOG_QUERY_LIMIT {
+		return fmt.Errorf("log query limit must be between 1 and %d", MAX_LOG_QUERY_LIMIT)
+	}
+	if q.Since != nil && q.Until != nil && q.Until.Before(*q.Since) {
+		return fmt.Errorf("log query until must not be before since")
+	}
+	if _, ok := logSeverityRank[q.MinSeverity]; q.MinSeverity != "" && !ok {
+		return fmt.Errorf("unknown log severity %q, expected one of: debug, info, warning, error", q.MinSeverity)
+	}
+	return nil
+}
+
+// Matches reports whether an entry falls within the query's time range
+// and severity. Entries without a time only match queries without a range.
+func (q LogQuery) Matches(e LogEntry) bool {
+	if q.Since != nil || q.Until != nil {
+		if e.Time == nil {
+			return false
+		}
+		if q.Since != nil && e.Time.Before(*q.Since) {
+			return false
+		}
+		if q.Until != nil && e.Time.After(*q.Until) {
+			return false
+		}
+	}
+	if q.MinSeverity != "" && logSeverityRank[e.Severity] < logSeverityRank[q.MinSeverity] {
+		return false
+	}
+	return true
+}
+
+type LogEntry struct {
+	Time     *time.Time `json:"time,omitempty"`
+	Severity string     `json:"severity"`
+	Line     string     `json:"line"`
+}
+
+type LogQueryPage struct {
+	Entries    []LogEntry `json:"entries"`
+	NextCursor *string    `json:"nextCursor,omitempty"` // nil once the range is exhausted
+}
+
+// How journalctl -o short-iso timestamps lines, see pup_container.nix.
+const containerLogTimeLayout = "2006-01-02T15:04:05-0700"
+
+var (
+	logSeverityErrorRegex   = regexp.MustCompile(`(?i)\b(error|err|fatal|crit|critical|panic|emerg|alert)\b`)
+	logSeverityWarningRegex = regexp.MustCompile(`(?i)\b(warn|warning)\b`)
+	logSeverityDebugRegex   = regexp.MustCompile(`(?i)\b(debug|trace)\b`)
+)
+
+/* ParseContainerLogLine splits a line of an exported container log into
+ * its timestamp and message. The export uses journalctl's short-iso
+ * output, which drops the journal priority, so severity is guessed from
+ * the usual level markers in the message (ERROR, level=warn, [debug] and
+ * so on), defaulting to info. Lines without a timestamp, ie: journalctl's
+ * "-- Boot" markers, come back with a nil Time.
+ */
+func ParseContainerLogLine(line string) LogEntry {
+	entry := LogEntry{Line: line, Severity: LOG_SEVERITY_INFO}
+
+	message := line
+	if stamp, rest, ok := strings.Cut(line, " "); ok {
+		if t, err := time.Parse(containerLogTimeLayout, stamp); err == nil {
+			entry.Time = &t
+			message = rest
+		}
+	}
+
+	// Skip the "unit[pid]:" prefix so unit names don't count as markers.
+	if _, rest, ok := strings.Cut(message, ": "); ok {
+		message = rest
+	}
+
+	switch {
+	case logSeverityErrorRegex.MatchString(message):
+		entry.Severity = LOG_SEVERITY_ERROR
+	case logSeverityWarningRegex.MatchString(message):
+		entry.Severity = LOG_SEVERITY_WARNING
+	case logSeverityDebugRegex.MatchString(message):
+		entry.Severity = LOG_SEVERITY_DEBUG
+	}
+	return entry
+}
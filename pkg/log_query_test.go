@@ -0,0 +1,53 @@
+package dogeboxd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseContainerLogLine(t *testing.T) {
+	e := ParseContainerLogLine("2026-10-17T03:00:01+0000 dogecoind[42]: UpdateTip: new best=abc height=5000000")
+	require.NotNil(t, e.Time)
+	assert.True(t, e.Time.Equal(time.Date(2026, 10, 17, 3, 0, 1, 0, time.UTC)))
+	assert.Equal(t, LOG_SEVERITY_INFO, e.Severity)
+
+	assert.Equal(t, LOG_SEVERITY_ERROR, ParseContainerLogLine("2026-10-17T03:00:02+0000 dogecoind[42]: ERROR: ReadBlockFromDisk failed").Severity)
+	assert.Equal(t, LOG_SEVERITY_WARNING, ParseContainerLogLine("2026-10-17T03:00:03+0000 app[7]: level=warn msg=\"peer slow\"").Severity)
+	assert.Equal(t, LOG_SEVERITY_DEBUG, ParseContainerLogLine("2026-10-17T03:00:04+0000 app[7]: [debug] polling").Severity)
+
+	// A unit name that looks like a level isn't a level.
+	assert.Equal(t, LOG_SEVERITY_INFO, ParseContainerLogLine("2026-10-17T03:00:05+0000 error-reporter[9]: started").Severity)
+
+	boot := ParseContainerLogLine("-- Boot 0f3a9c2b7d --")
+	assert.Nil(t, boot.Time)
+	assert.Equal(t, "-- Boot 0f3a9c2b7d --", boot.Line)
+}
+
+func TestLogQueryMatches(t *testing.T) {
+	since := time.Date(2026, 10, 17, 3, 0, 0, 0, time.UTC)
+	until := since.Add(time.Hour)
+	at := func(d time.Duration) *time.Time { t := since.Add(d); return &t }
+
+	q := LogQuery{Since: &since, Until: &until, MinSeverity: LOG_SEVERITY_WARNING, Limit: 10}
+	require.NoError(t, q.Validate())
+
+	assert.True(t, q.Matches(LogEntry{Time: at(time.Minute), Severity: LOG_SEVERITY_ERROR}))
+	assert.False(t, q.Matches(LogEntry{Time: at(time.Minute), Severity: LOG_SEVERITY_INFO}))
+	assert.False(t, q.Matches(LogEntry{Time: at(-time.Minute), Severity: LOG_SEVERITY_ERROR}))
+	assert.False(t, q.Matches(LogEntry{Time: at(2 * time.Hour), Severity: LOG_SEVERITY_ERROR}))
+	assert.False(t, q.Matches(LogEntry{Severity: LOG_SEVERITY_ERROR}))
+	assert.True(t, LogQuery{Limit: 10}.Matches(LogEntry{Severity: LOG_SEVERITY_DEBUG}))
+}
+
+func TestLogQueryValidate(t *testing.T) {
+	since := time.Now()
+	until := since.Add(-time.Hour)
+
+	assert.Error(t, LogQuery{}.Validate())
+	assert.Error(t, LogQuery{Limit: MAX_LOG_QUERY_LIMIT + 1}.Validate())
+	assert.Error(t, LogQuery{Limit: 10, MinSeverity: "loud"}.Validate())
+	assert.Error(t, LogQuery{Limit: 10, Since: &since, Until: &until}.Validate())
+}
@@ -0,0 +1,112 @@
+package dogeboxd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+)
+
+/* A pup's manifest can list patterns for things that turn up in its logs
+ * but shouldn't leave the Dogebox, ie: API keys or wallet addresses.
+ * They're blanked out of any log we hand over to be shared, unless the
+ * user asks for the raw log.
+ */
+type PupManifestLogRedaction struct {
+	// What's being hidden, ie: "rpc-password". Shown in place of it.
+	Name string `json:"name"`
+	// A regular expression matching the secret.
+	Pattern string `json:"pattern"`
+}
+
+const MAX_LOG_REDACTIONS = 20
+
+var logRedactionNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+func (r PupManifestLogRedaction) Validate() error {
+	if !logRedactionNameRegex.MatchString(r.Name) {
+		return fmt.Errorf("log redaction name %q must be lowercase letters, numbers and -", r.Name)
+	}
+	re, err := regexp.Compile(r.Pattern)
+	if err != nil {
+		return fmt.Errorf("log redaction %s has an invalid pattern: %w", r.Name, err)
+	}
+	// A pattern matching nothing at all would redact between every character.
+	if re.MatchString("") {
+		return fmt.Errorf("log redaction %s pattern must not match an empty string", r.Name)
+	}
+	return nil
+}
+
+func validateLogRedactions(redactions []PupManifestLogRedaction) error {
+	if len(redactions) > MAX_LOG_REDACTIONS {
+		return fmt.Errorf("at most %d log redactions are allowed", MAX_LOG_REDACTIONS)
+	}
+	seen := map[string]bool{}
+	for _, r := range redactions {
+		if err := r.Validate(); err != nil {
+			return err
+		}
+		if seen[r.Name] {
+			return fmt.Errorf("duplicate log redaction name: %s", r.Name)
+		}
+		seen[r.Name] = true
+	}
+	return nil
+}
+
+type logRedactionRule struct {
+	re          *regexp.Regexp
+	replacement string
+}
+
+type LogRedactor struct {
+	rules []logRedactionRule
+}
+
+// NewLogRedactor compiles a manifest's redactions. Manifests are
+// validated on install, so an error here means a bad manifest slipped by.
+func NewLogRedactor(redactions []PupManifestLogRedaction) (*LogRedactor, error) {
+	if err := validateLogRedactions(redactions); err != nil {
+		return nil, err
+	}
+	r := &LogRedactor{}
+	for _, redaction := range redactions {
+		r.rules = append(r.rules, logRedactionRule{
+			re:          regexp.MustCompile(redaction.Pattern),
+			replacement: fmt.Sprintf("[redacted %s]", redaction.Name),
+		})
+	}
+	return r, nil
+}
+
+func (r *LogRedactor) Redact(line string) string {
+	for _, rule := range r.rules {
+		line = rule.re.ReplaceAllLiteralString(line, rule.replacement)
+	}
+	return line
+}
+
+// Copy writes src to w a line at a time, redacted.
+func (r *LogRedactor) Copy(w io.Writer, src io.Reader) error {
+	if len(r.rules) == 0 {
+		_, err := io.Copy(w, src)
+		return err
+	}
+
+	reader := bufio.NewReader(src)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			if _, werr := io.WriteString(w, r.Redact(line)); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package dogeboxd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPupManifestLogRedactionValidate(t *testing.T) {
+	assert.NoError(t, PupManifestLogRedaction{Name: "api-key", Pattern: `key=[A-Za-z0-9]+`}.Validate())
+
+	tests := map[string]PupManifestLogRedaction{
+		"bad name":      {Name: "API Key", Pattern: `key=\w+`},
+		"bad pattern":   {Name: "api-key", Pattern: `key=(`},
+		"matches empty": {Name: "api-key", Pattern: `\w*`},
+	}
+	for name, r := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Error(t, r.Validate())
+		})
+	}
+
+	dupes := []PupManifestLogRedaction{{Name: "key", Pattern: "a+"}, {Name: "key", Pattern: "b+"}}
+	assert.ErrorContains(t, validateLogRedactions(dupes), "duplicate")
+}
+
+func TestLogRedactorCopy(t *testing.T) {
+	r, err := NewLogRedactor([]PupManifestLogRedaction{
+		{Name: "rpc-password", Pattern: `rpcpassword=\S+`},
+		{Name: "address", Pattern: `D[1-9A-HJ-NP-Za-km-z]{33}`},
+	})
+	require.NoError(t, err)
+
+	in := strings.Join([]string{
+		"starting with rpcpassword=hunter2 on port 22555",
+		"sent 10 DOGE to DH5yaieqoZN36fDVciNyRueRGvGLR3mr7L",
+		"nothing to hide",
+		"no trailing newline rpcpassword=x",
+	}, "\n")
+
+	var out bytes.Buffer
+	require.NoError(t, r.Copy(&out, strings.NewReader(in)))
+	assert.Equal(t, strings.Join([]string{
+		"starting with [redacted rpc-password] on port 22555",
+		"sent 10 DOGE to [redacted address]",
+		"nothing to hide",
+		"no trailing newline [redacted rpc-password]",
+	}, "\n"), out.String())
+}
+
+func TestLogRedactorWithoutRulesCopiesAsIs(t *testing.T) {
+	r, err := NewLogRedactor(nil)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, r.Copy(&out, strings.NewReader("a\nb\n")))
+	assert.Equal(t, "a\nb\n", out.String())
+}
+
+func TestPupManifestValidateChecksLogRedactions(t *testing.T) {
+	m := PupManifest{
+		ManifestVersion: 1,
+		Meta:            PupManifestMeta{Name: "test", Version: "1.0.0"},
+		Container:       PupManifestContainer{Build: PupManifestBuild{NixFile: "pup.nix", NixFileSha256: "abc"}},
+		Config: PupManifestConfigFields{
+			LogRedactions: []PupManifestLogRedaction{{Name: "token", Pattern: "("}},
+		},
+	}
+	assert.ErrorContains(t, m.Validate(), "log redaction token")
+
+	m.Config.LogRedactions[0].Pattern = `token=\S+`
+	assert.NoError(t, m.Validate())
+}
@@ -22,6 +22,8 @@ type PupManifest struct {
 	Interfaces      []PupManifestInterface  `json:"interfaces"`
 	Dependencies    []PupManifestDependency `json:"dependencies"`
 	Metrics         []PupManifestMetric     `json:"metrics"`
+	Migrations      []PupManifestMigration  `json:"migrations"`
+	Requirements    PupManifestRequirements `json:"requirements"`
 }
 
 func (m *PupManifest) Validate() error {
@@ -53,6 +55,18 @@ func (m *PupManifest) Validate() error {
 		if service.Command.Exec == "" {
 			return fmt.Errorf("service %s must have a non-empty exec command", service.Name)
 		}
+
+		if service.Readiness != nil {
+			if err := service.Readiness.Validate(); err != nil {
+				return fmt.Errorf("service %s: %w", service.Name, err)
+			}
+		}
+	}
+
+	for i, closure := range m.Container.Build.Closures {
+		if err := closure.Validate(m.Container.Services); err != nil {
+			return fmt.Errorf("closure %d: %w", i, err)
+		}
 	}
 
 	for _, expose := range m.Container.Exposes {
@@ -69,22 +83,33 @@ func (m *PupManifest) Validate() error {
 		}
 	}
 
-	// Validate configuration schema
-	validFieldTypes := map[string]struct{}{
-		"text":     {},
-		"password": {},
-		"number":   {},
-		"toggle":   {},
-		"email":    {},
-		"textarea": {},
-		"select":   {},
-		"checkbox": {},
-		"radio":    {},
-		"date":     {},
-		"range":    {},
-		"color":    {},
+	if err := m.Meta.Links.Validate(); err != nil {
+		return fmt.Errorf("meta links: %w", err)
+	}
+
+	if m.Container.HealthCheck != nil {
+		if err := m.Container.HealthCheck.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if m.Container.ResourceLimits != nil {
+		if err := m.Container.ResourceLimits.Validate(); err != nil {
+			return fmt.Errorf("container resourceLimits: %w", err)
+		}
+	}
+
+	if m.Container.Stop != nil {
+		if err := m.Container.Stop.Validate(); err != nil {
+			return fmt.Errorf("container stop: %w", err)
+		}
+	}
+
+	if err := validateManifestDevices(m.Container.Devices); err != nil {
+		return fmt.Errorf("container devices: %w", err)
 	}
 
+	// Validate configuration schema
 	seenFieldNames := map[string]struct{}{}
 	for _, section := range m.Config.Sections {
 		if section.Name == "" {
@@ -97,14 +122,14 @@ func (m *PupManifest) Validate() error {
 			if field.Label == "" {
 				return fmt.Errorf("config field %s in section %s must have a label", field.Name, section.Name)
 			}
-			if _, ok := validFieldTypes[field.Type]; !ok {
+			if _, ok := supportedConfigTypes[field.Type]; !ok {
 				return fmt.Errorf("config field %s in section %s has invalid type %s", field.Name, section.Name, field.Type)
 			}
 			if _, exists := seenFieldNames[field.Name]; exists {
 				return fmt.Errorf("duplicate config field name: %s", field.Name)
 			}
 			seenFieldNames[field.Name] = struct{}{}
-			if field.Type == "number" {
+			if isNumericConfigType(field.Type) {
 				if field.Step != nil && *field.Step <= 0 {
 					return fmt.Errorf("config field %s step must be greater than zero", field.Name)
 				}
@@ -112,9 +137,51 @@ func (m *PupManifest) Validate() error {
 					return fmt.Errorf("config field %s min cannot be greater than max", field.Name)
 				}
 			}
+			if field.Pattern != "" {
+				if _, err := compileConfigPattern(field.Pattern); err != nil {
+					return fmt.Errorf("config field %s has an invalid pattern: %w", field.Name, err)
+				}
+			}
+			for _, option := range field.Options {
+				if option.Value == "" {
+					return fmt.Errorf("config field %s has an option without a value", field.Name)
+				}
+			}
+			if field.Secret && field.Default != nil {
+				return fmt.Errorf("config field %s is secret and can't have a default", field.Name)
+			}
 		}
 	}
 
+	if m.Config.LogLevel != nil {
+		if err := m.Config.LogLevel.Validate(); err != nil {
+			return err
+		}
+		if ManifestConfigFieldIndex(m.Config)[m.Config.LogLevel.Env].Secret {
+			return fmt.Errorf("config logLevel env %s can't be a secret field", m.Config.LogLevel.Env)
+		}
+	}
+
+	if m.Config.Reload != nil {
+		if err := m.Config.Reload.Validate(m.Container.Services); err != nil {
+			return err
+		}
+	}
+
+	if err := validateLogRedactions(m.Config.LogRedactions); err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+
+	for i, migration := range m.Migrations {
+		if err := migration.Validate(); err != nil {
+			return fmt.Errorf("migration %d: %w", i, err)
+		}
+	}
+
+	if err := m.Requirements.Validate(); err != nil {
+		return fmt.Errorf("requirements: %w", err)
+	}
+
 	return nil
 }
 
@@ -156,6 +223,12 @@ type PupManifestMeta struct {
 	LongDescription string `json:"longDescription"`
 	// A key value pair of upstream versions that this pup ships with.
 	UpstreamVersions map[string]string `json:"upstreamVersions"`
+	// Optional. Lets OS releases ship this pup as a system pup, see SystemPupPin.
+	System bool `json:"system,omitempty"`
+	// Optional. Docs, support and donation links, see PupManifestLinks.
+	Links PupManifestLinks `json:"links,omitempty"`
+	// Optional. Keywords the store search matches on, ie: "wallet", "mining".
+	Tags []string `json:"tags,omitempty"`
 }
 
 /* PupManfiestV1Container contains information about the
@@ -171,6 +244,14 @@ type PupManifestContainer struct {
 	Exposes  []PupManifestExposeConfig `json:"exposes"`
 	// This pup requires internet access to function.
 	RequiresInternet bool `json:"requiresInternet"`
+	// Optional. How to tell the pup is working, beyond it running.
+	HealthCheck *PupManifestHealthCheck `json:"healthCheck,omitempty"`
+	// Optional. Suggested CPU and memory limits, the user can override these.
+	ResourceLimits *PupResourceLimits `json:"resourceLimits,omitempty"`
+	// Optional. How to stop the pup's services gracefully, see PupManifestStop.
+	Stop *PupManifestStop `json:"stop,omitempty"`
+	// Optional. Host devices to pass through, once the user approves them.
+	Devices []PupManifestDevice `json:"devices,omitempty"`
 }
 
 /* PupManifestBuild holds information about the target nix
@@ -181,11 +262,31 @@ type PupManifestBuild struct {
 	NixFile string `json:"nixFile"`
 	// The SHA256 hash of the nix file.
 	NixFileSha256 string `json:"nixFileSha256"`
+	// Optional prebuilt, content-addressed closures for this pup's
+	// services. Where one is available for the running system it is
+	// fetched instead of building that service from NixFile.
+	Closures []PupManifestClosure `json:"closures,omitempty"`
+}
+
+/* PupManifestClosure points at a prebuilt store path for one of
+ * this pup's services on a given system.
+ */
+type PupManifestClosure struct {
+	// The nix system this closure was built for, eg: x86_64-linux
+	System string `json:"system"`
+	// The service (from container.services) this closure provides.
+	Service string `json:"service"`
+	// The content-addressed store path, eg: /nix/store/<hash>-<name>
+	StorePath string `json:"storePath"`
+	// The binary cache to fetch the closure from, eg: https://cache.example.org
+	FromStore string `json:"fromStore"`
 }
 
 type PupManifestService struct {
 	Name    string             `json:"name"`
 	Command PupManifestCommand `json:"command"`
+	// Optional. How the service reports it has started, see PupManifestServiceReadiness.
+	Readiness *PupManifestServiceReadiness `json:"readiness,omitempty"`
 }
 
 /* Represents the command to run inside this PUP
@@ -208,6 +309,7 @@ type PupManifestExposeConfig struct {
 	Interfaces   []string `json:"interfaces"`   // Designates that certain interfaces can be accessed on this port
 	ListenOnHost bool     `json:"listenOnHost"` // If true, the port will be accessible on the host network, otherwise it will listen on a private internal network interface.
 	WebUI        bool     `json:"webUI"`        // If true, will be proxied from an available port to the dPanel user
+	SSO          bool     `json:"sso"`          // If true, and a WebUI, the proxy passes the signed-in dPanel user through in the X-Dogebox-SSO header, see WebUISSO
 }
 
 type PupManifestInterface struct {
@@ -258,6 +360,13 @@ type PupManifestDependencySource struct {
 type PupManifestConfigFields struct {
 	ShowOnInstall bool                       `json:"showOnInstall"`
 	Sections      []PupManifestConfigSection `json:"sections"`
+	// Optional knob for temporarily turning on debug logging.
+	LogLevel *PupManifestLogLevel `json:"logLevel,omitempty"`
+	// Optional. Secrets to blank out of shared logs, see PupManifestLogRedaction.
+	LogRedactions []PupManifestLogRedaction `json:"logRedactions,omitempty"`
+	// Optional. How to pick up config changes without a restart, see
+	// PupManifestConfigReload.
+	Reload *PupManifestConfigReload `json:"reload,omitempty"`
 }
 
 type PupManifestConfigSection struct {
@@ -277,6 +386,17 @@ type PupManifestConfigField struct {
 	Min         *float64 `json:"min,omitempty"`
 	Max         *float64 `json:"max,omitempty"`
 	Step        *float64 `json:"step,omitempty"`
+	// Optional. A regular expression text values must match in full.
+	Pattern string `json:"pattern,omitempty"`
+	// Optional. The values a select or radio field can have.
+	Options []PupManifestConfigOption `json:"options,omitempty"`
+	// Kept encrypted and never sent back to clients, see ConfigSecretStore.
+	Secret bool `json:"secret,omitempty"`
+}
+
+type PupManifestConfigOption struct {
+	Label string `json:"label"`
+	Value string `json:"value"`
 }
 
 type PupManifestMetric struct {
@@ -286,3 +406,23 @@ type PupManifestMetric struct {
 	HistorySize int    `json:"history"`
 	Description string `json:"description,omitempty"`
 }
+
+/* A migration step that needs to run when upgrading a pup
+ * across the version that introduced it, ie: renaming a
+ * config key, or running a command to migrate stored data.
+ */
+type PupManifestMigration struct {
+	// The pup version that introduced this migration. It runs when upgrading
+	// from a version below this, to this version or above.
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+	Type        string `json:"type"` // Must be one of: renameConfigKey, removeConfigKey, command
+	// renameConfigKey: the old and new config field names.
+	// removeConfigKey: only From is used.
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+	// command: the pup service package providing Exec, which is run
+	// inside the container before any of the pup's services start.
+	Service string `json:"service,omitempty"`
+	Exec    string `json:"exec,omitempty"`
+}
@@ -0,0 +1,71 @@
+package dogeboxd
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"runtime"
+)
+
+var storePathRegex = regexp.MustCompile(`^/nix/store/[0-9a-df-np-sv-z]{32}-[A-Za-z0-9+\-._?=]+$`)
+
+func (c PupManifestClosure) Validate(services []PupManifestService) error {
+	if c.System == "" {
+		return fmt.Errorf("system is required")
+	}
+
+	if !storePathRegex.MatchString(c.StorePath) {
+		return fmt.Errorf("invalid store path %q", c.StorePath)
+	}
+
+	from, err := url.Parse(c.FromStore)
+	if err != nil || from.Scheme != "https" || from.Host == "" {
+		return fmt.Errorf("fromStore must be an https url")
+	}
+
+	for _, service := range services {
+		if service.Name == c.Service {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown service %q", c.Service)
+}
+
+// ClosuresForSystem returns the prebuilt closures in this manifest
+// that were built for the given nix system.
+func (b PupManifestBuild) ClosuresForSystem(system string) []PupManifestClosure {
+	closures := []PupManifestClosure{}
+	seen := map[string]struct{}{}
+
+	for _, c := range b.Closures {
+		if c.System != system {
+			continue
+		}
+		// First closure listed for a service wins.
+		if _, ok := seen[c.Service]; ok {
+			continue
+		}
+		seen[c.Service] = struct{}{}
+		closures = append(closures, c)
+	}
+
+	return closures
+}
+
+// CurrentNixSystem returns the nix system string for the machine
+// we're running on, or "" if we don't know how to describe it.
+func CurrentNixSystem() string {
+	if runtime.GOOS != "linux" {
+		return ""
+	}
+
+	switch runtime.GOARCH {
+	case "amd64":
+		return "x86_64-linux"
+	case "arm64":
+		return "aarch64-linux"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,54 @@
+package dogeboxd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testStorePath = "/nix/store/0c2ipxydb7yb6rywrqyfa5k1rcpn6rl1-dogecoind-1.14.9"
+
+func TestPupManifestClosureValidate(t *testing.T) {
+	services := []PupManifestService{{Name: "dogecoind"}}
+	valid := PupManifestClosure{
+		System:    "x86_64-linux",
+		Service:   "dogecoind",
+		StorePath: testStorePath,
+		FromStore: "https://cache.example.org",
+	}
+	assert.NoError(t, valid.Validate(services))
+
+	tests := map[string]func(c *PupManifestClosure){
+		"missing system":    func(c *PupManifestClosure) { c.System = "" },
+		"unknown service":   func(c *PupManifestClosure) { c.Service = "other" },
+		"not a store path":  func(c *PupManifestClosure) { c.StorePath = "/usr/bin/dogecoind" },
+		"bad store hash":    func(c *PupManifestClosure) { c.StorePath = "/nix/store/eeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee-x" },
+		"nested store path": func(c *PupManifestClosure) { c.StorePath = testStorePath + "/bin" },
+		"plain http store":  func(c *PupManifestClosure) { c.FromStore = "http://cache.example.org" },
+		"missing store":     func(c *PupManifestClosure) { c.FromStore = "" },
+	}
+
+	for name, mutate := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := valid
+			mutate(&c)
+			assert.Error(t, c.Validate(services))
+		})
+	}
+}
+
+func TestClosuresForSystem(t *testing.T) {
+	build := PupManifestBuild{Closures: []PupManifestClosure{
+		{System: "x86_64-linux", Service: "a", StorePath: "/nix/store/a1"},
+		{System: "aarch64-linux", Service: "a", StorePath: "/nix/store/a2"},
+		{System: "x86_64-linux", Service: "a", StorePath: "/nix/store/a3"},
+		{System: "x86_64-linux", Service: "b", StorePath: "/nix/store/b1"},
+	}}
+
+	closures := build.ClosuresForSystem("x86_64-linux")
+	assert.Len(t, closures, 2)
+	assert.Equal(t, "/nix/store/a1", closures[0].StorePath)
+	assert.Equal(t, "/nix/store/b1", closures[1].StorePath)
+
+	assert.Empty(t, build.ClosuresForSystem("riscv64-linux"))
+}
@@ -0,0 +1,92 @@
+package dogeboxd
+
+import (
+	"fmt"
+	"net/url"
+)
+
+/* PupManifestLinks are where to send users for help with a pup, or to
+ * support its developers. All fields are optional.
+ */
+type PupManifestLinks struct {
+	Homepage string `json:"homepage,omitempty"`
+	// Documentation for using the pup.
+	Docs string `json:"docs,omitempty"`
+	// The pup's source code.
+	Source string `json:"source,omitempty"`
+	// Where to report bugs.
+	Issues string `json:"issues,omitempty"`
+	// Places to ask for help, ie: a Discord server or an email address.
+	Support []PupManifestLink `json:"support,omitempty"`
+	// Ways to donate to the pup's developers, these may be dogecoin: URIs.
+	Donate []PupManifestLink `json:"donate,omitempty"`
+}
+
+type PupManifestLink struct {
+	// What to show for the link, ie: "Discord"
+	Label string `json:"label"`
+	URL   string `json:"url"`
+}
+
+const MAX_PUP_MANIFEST_LINKS = 10
+
+func (l PupManifestLinks) Validate() error {
+	for name, u := range map[string]string{
+		"homepage": l.Homepage,
+		"docs":     l.Docs,
+		"source":   l.Source,
+		"issues":   l.Issues,
+	} {
+		if u == "" {
+			continue
+		}
+		if err := validateManifestLinkURL(u, "http", "https"); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	if err := validateManifestLinkList(l.Support, "http", "https", "mailto"); err != nil {
+		return fmt.Errorf("support: %w", err)
+	}
+	if err := validateManifestLinkList(l.Donate, "http", "https", "dogecoin"); err != nil {
+		return fmt.Errorf("donate: %w", err)
+	}
+	return nil
+}
+
+func validateManifestLinkList(links []PupManifestLink, schemes ...string) error {
+	if len(links) > MAX_PUP_MANIFEST_LINKS {
+		return fmt.Errorf("at most %d links are allowed", MAX_PUP_MANIFEST_LINKS)
+	}
+	for i, link := range links {
+		if link.Label == "" {
+			return fmt.Errorf("link %d must have a label", i)
+		}
+		if err := validateManifestLinkURL(link.URL, schemes...); err != nil {
+			return fmt.Errorf("%s: %w", link.Label, err)
+		}
+	}
+	return nil
+}
+
+// These end up as links in dpanel, so only let through schemes that are
+// safe to click.
+func validateManifestLinkURL(raw string, schemes ...string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid URL %q", raw)
+	}
+	for _, s := range schemes {
+		if u.Scheme != s {
+			continue
+		}
+		if (s == "http" || s == "https") && u.Host == "" {
+			return fmt.Errorf("invalid URL %q", raw)
+		}
+		if (s == "mailto" || s == "dogecoin") && u.Opaque == "" {
+			return fmt.Errorf("invalid URL %q", raw)
+		}
+		return nil
+	}
+	return fmt.Errorf("URL %q must use one of: %v", raw, schemes)
+}
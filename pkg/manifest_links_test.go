@@ -0,0 +1,50 @@
+package dogeboxd
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPupManifestLinksValidate(t *testing.T) {
+	assert.NoError(t, PupManifestLinks{}.Validate())
+	assert.NoError(t, PupManifestLinks{
+		Homepage: "https://dogecoin.com",
+		Docs:     "https://docs.example.org/pup",
+		Support: []PupManifestLink{
+			{Label: "Discord", URL: "https://discord.gg/dogecoin"},
+			{Label: "Email", URL: "mailto:help@example.org"},
+		},
+		Donate: []PupManifestLink{{Label: "Dogecoin", URL: "dogecoin:DH5yaieqoZN36fDVciNyRueRGvGLR3mr7L"}},
+	}.Validate())
+
+	tests := map[string]PupManifestLinks{
+		"javascript docs":  {Docs: "javascript:alert(1)"},
+		"no host":          {Homepage: "https://"},
+		"relative":         {Source: "/src"},
+		"mailto donate":    {Donate: []PupManifestLink{{Label: "Email", URL: "mailto:me@example.org"}}},
+		"dogecoin support": {Support: []PupManifestLink{{Label: "Tip", URL: "dogecoin:DH5yaieqoZN36fDVciNyRueRGvGLR3mr7L"}}},
+		"empty mailto":     {Support: []PupManifestLink{{Label: "Email", URL: "mailto:"}}},
+		"no label":         {Support: []PupManifestLink{{URL: "https://example.org"}}},
+	}
+	for name, links := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Error(t, links.Validate())
+		})
+	}
+}
+
+func TestPupManifestValidateChecksLinks(t *testing.T) {
+	var m PupManifest
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"manifestVersion": 1,
+		"meta": {"name": "test", "version": "1.0.0", "links": {"docs": "ftp://example.org"}},
+		"container": {"build": {"nixFile": "pup.nix", "nixFileSha256": "abc"}}
+	}`), &m))
+	assert.ErrorContains(t, m.Validate(), "meta links: docs")
+
+	m.Meta.Links.Docs = "https://example.org/docs"
+	assert.NoError(t, m.Validate())
+}
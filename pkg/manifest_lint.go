@@ -0,0 +1,274 @@
+package dogeboxd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+/*
+ * The manifest linter is for pup developers: rather than failing on the
+ * first problem like PupManifest.Validate, it reports everything it can
+ * find, with where to find it, so a pup can be fixed before its source is
+ * ever added to a Dogebox.
+ */
+
+const (
+	LINT_ERROR   = "error"
+	LINT_WARNING = "warning"
+)
+
+const (
+	LINT_INVALID_JSON   = "invalid-json"
+	LINT_SCHEMA         = "schema"
+	LINT_UNKNOWN_FIELD  = "unknown-field"
+	LINT_MISSING_FIELD  = "missing-field"
+	LINT_INVALID        = "invalid"
+	LINT_HASH_MISMATCH  = "hash-mismatch"
+	LINT_NIX_FILE       = "nix-file"
+	LINT_PORT_CONFLICT  = "port-conflict"
+	LINT_NO_DESCRIPTION = "no-description"
+)
+
+type PupManifestDiagnostic struct {
+	Severity string `json:"severity"`
+	Code     string `json:"code"`
+	// Where in the manifest, ie: container.exposes[1].port
+	Path string `json:"path,omitempty"`
+	// The line in manifest.json, where we know it.
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+}
+
+type PupManifestLintInput struct {
+	Manifest []byte
+	// The contents of container.build.nixFile, nil if we don't have it.
+	NixFile []byte
+	// Ports already in use on the host, and what's using them.
+	HostPorts map[int]string
+}
+
+type PupManifestLintResult struct {
+	// No errors, though there may be warnings.
+	Valid       bool                    `json:"valid"`
+	Diagnostics []PupManifestDiagnostic `json:"diagnostics"`
+}
+
+type manifestLinter struct {
+	diagnostics []PupManifestDiagnostic
+}
+
+func (l *manifestLinter) add(severity, code, path string, line int, format string, args ...any) {
+	l.diagnostics = append(l.diagnostics, PupManifestDiagnostic{
+		Severity: severity,
+		Code:     code,
+		Path:     path,
+		Line:     line,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (l *manifestLinter) errors() int {
+	n := 0
+	for _, d := range l.diagnostics {
+		if d.Severity == LINT_ERROR {
+			n++
+		}
+	}
+	return n
+}
+
+// LintPupManifest checks a manifest.json, and its nix file if given.
+func LintPupManifest(in PupManifestLintInput) PupManifestLintResult {
+	l := &manifestLinter{diagnostics: []PupManifestDiagnostic{}}
+	l.lint(in)
+	return PupManifestLintResult{Valid: l.errors() == 0, Diagnostics: l.diagnostics}
+}
+
+func (l *manifestLinter) lint(in PupManifestLintInput) {
+	var raw any
+	if err := json.Unmarshal(in.Manifest, &raw); err != nil {
+		var syntaxErr *json.SyntaxError
+		line := 0
+		if errors.As(err, &syntaxErr) {
+			line = lineAtOffset(in.Manifest, syntaxErr.Offset)
+		}
+		l.add(LINT_ERROR, LINT_INVALID_JSON, "", line, "manifest.json isn't valid JSON: %v", err)
+		return
+	}
+
+	var m PupManifest
+	if err := json.Unmarshal(in.Manifest, &m); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			l.add(LINT_ERROR, LINT_SCHEMA, typeErr.Field, lineAtOffset(in.Manifest, typeErr.Offset), "expected %s, got %s", typeErr.Type, typeErr.Value)
+		} else {
+			l.add(LINT_ERROR, LINT_SCHEMA, "", 0, "%v", err)
+		}
+		return
+	}
+
+	l.unknownFields(raw, reflect.TypeOf(m), "")
+
+	missing := l.errors()
+	l.requiredFields(m)
+	// Validate stops at the first missing field, which we've just
+	// reported, so only ask it about the rest once they're all there.
+	if l.errors() == missing {
+		if err := m.Validate(); err != nil {
+			l.add(LINT_ERROR, LINT_INVALID, "", 0, "%v", err)
+		}
+	}
+
+	if m.Meta.ShortDescription == "" {
+		l.add(LINT_WARNING, LINT_NO_DESCRIPTION, "meta.shortDescription", 0, "pups without a short description are hard to find in the store")
+	}
+
+	l.nixHash(m, in.NixFile)
+	l.ports(m, in.HostPorts)
+}
+
+func (l *manifestLinter) requiredFields(m PupManifest) {
+	required := []struct {
+		path  string
+		unset bool
+	}{
+		{"manifestVersion", m.ManifestVersion == 0},
+		{"meta.name", m.Meta.Name == ""},
+		{"meta.version", m.Meta.Version == ""},
+		{"container.build.nixFile", m.Container.Build.NixFile == ""},
+		{"container.build.nixFileSha256", m.Container.Build.NixFileSha256 == ""},
+	}
+	for _, r := range required {
+		if r.unset {
+			l.add(LINT_ERROR, LINT_MISSING_FIELD, r.path, 0, "%s is required", r.path)
+		}
+	}
+	for i, s := range m.Container.Services {
+		if s.Name == "" {
+			l.add(LINT_ERROR, LINT_MISSING_FIELD, fmt.Sprintf("container.services[%d].name", i), 0, "service name is required")
+		}
+		if s.Command.Exec == "" {
+			l.add(LINT_ERROR, LINT_MISSING_FIELD, fmt.Sprintf("container.services[%d].command.exec", i), 0, "service exec command is required")
+		}
+	}
+	for i, ex := range m.Container.Exposes {
+		if ex.Name == "" {
+			l.add(LINT_ERROR, LINT_MISSING_FIELD, fmt.Sprintf("container.exposes[%d].name", i), 0, "expose name is required")
+		}
+	}
+}
+
+func (l *manifestLinter) nixHash(m PupManifest, nixFile []byte) {
+	if m.Container.Build.NixFile == "" {
+		return
+	}
+	if nixFile == nil {
+		l.add(LINT_WARNING, LINT_NIX_FILE, "container.build.nixFile", 0, "%s wasn't given, so its hash wasn't checked", m.Container.Build.NixFile)
+		return
+	}
+	if m.Container.Build.NixFileSha256 == "" {
+		return
+	}
+
+	actual := fmt.Sprintf("%x", sha256.Sum256(nixFile))
+	if !strings.EqualFold(actual, m.Container.Build.NixFileSha256) {
+		l.add(LINT_ERROR, LINT_HASH_MISMATCH, "container.build.nixFileSha256", 0, "%s has sha256 %s, not %s", m.Container.Build.NixFile, actual, m.Container.Build.NixFileSha256)
+	}
+}
+
+func (l *manifestLinter) ports(m PupManifest, hostPorts map[int]string) {
+	seen := map[int]int{}
+	for i, ex := range m.Container.Exposes {
+		path := fmt.Sprintf("container.exposes[%d].port", i)
+		if first, ok := seen[ex.Port]; ok {
+			l.add(LINT_ERROR, LINT_PORT_CONFLICT, path, 0, "port %d is already exposed by container.exposes[%d]", ex.Port, first)
+			continue
+		}
+		seen[ex.Port] = i
+
+		if owner, ok := hostPorts[ex.Port]; ok && ex.ListenOnHost {
+			l.add(LINT_ERROR, LINT_PORT_CONFLICT, path, 0, "port %d is already used on the host by %s", ex.Port, owner)
+		}
+	}
+}
+
+// unknownFields warns about keys the manifest doesn't have, which are
+// usually typos that would otherwise be silently ignored.
+func (l *manifestLinter) unknownFields(raw any, t reflect.Type, path string) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch v := raw.(type) {
+	case map[string]any:
+		if t.Kind() != reflect.Struct {
+			return
+		}
+		fields := map[string]reflect.Type{}
+		for i := 0; i < t.NumField(); i++ {
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+			if name == "" || name == "-" {
+				continue
+			}
+			fields[name] = t.Field(i).Type
+		}
+
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fieldPath := k
+			if path != "" {
+				fieldPath = path + "." + k
+			}
+			ft, ok := fields[k]
+			if !ok {
+				l.add(LINT_WARNING, LINT_UNKNOWN_FIELD, fieldPath, 0, "%s isn't a manifest field", fieldPath)
+				continue
+			}
+			l.unknownFields(v[k], ft, fieldPath)
+		}
+	case []any:
+		if t.Kind() != reflect.Slice {
+			return
+		}
+		for i, item := range v {
+			l.unknownFields(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+}
+
+func lineAtOffset(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return bytes.Count(data[:offset], []byte{'\n'}) + 1
+}
+
+// PupHostPorts are the host ports installed pups are using, other than
+// those of the pup called except, ie: when linting its next version.
+func PupHostPorts(pups map[string]PupState, except string) map[int]string {
+	ports := map[int]string{}
+	for _, p := range pups {
+		if p.Manifest.Meta.Name == except {
+			continue
+		}
+		for _, ex := range p.Manifest.Container.Exposes {
+			if ex.ListenOnHost {
+				ports[ex.Port] = p.Manifest.Meta.Name
+			}
+		}
+		for _, ui := range p.WebUIs {
+			ports[ui.Port] = p.Manifest.Meta.Name
+		}
+	}
+	return ports
+}
@@ -0,0 +1,145 @@
+package dogeboxd
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testLintNix = "{ pkgs ? import <nixpkgs> {} }: {}"
+
+func testLintManifest(nixHash string, exposes string) []byte {
+	return []byte(fmt.Sprintf(`{
+  "manifestVersion": 1,
+  "meta": {"name": "test-pup", "version": "1.0.0", "shortDescription": "A test pup"},
+  "container": {
+    "build": {"nixFile": "pup.nix", "nixFileSha256": %q},
+    "services": [{"name": "server", "command": {"exec": "/bin/server"}}],
+    "exposes": [%s]
+  }
+}`, nixHash, exposes))
+}
+
+func testLintNixHash() string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(testLintNix)))
+}
+
+func lintCodes(result PupManifestLintResult) []string {
+	codes := []string{}
+	for _, d := range result.Diagnostics {
+		codes = append(codes, d.Code)
+	}
+	return codes
+}
+
+func TestLintPupManifestValid(t *testing.T) {
+	result := LintPupManifest(PupManifestLintInput{
+		Manifest: testLintManifest(testLintNixHash(), `{"name": "web", "type": "http", "port": 8080, "webUI": true}`),
+		NixFile:  []byte(testLintNix),
+	})
+	assert.True(t, result.Valid)
+	assert.Empty(t, result.Diagnostics)
+}
+
+func TestLintPupManifestInvalidJSON(t *testing.T) {
+	result := LintPupManifest(PupManifestLintInput{Manifest: []byte("{\n  \"manifestVersion\": 1,\n  \"meta\": {,}\n}")})
+	assert.False(t, result.Valid)
+	require.Len(t, result.Diagnostics, 1)
+	assert.Equal(t, LINT_INVALID_JSON, result.Diagnostics[0].Code)
+	assert.Equal(t, 3, result.Diagnostics[0].Line)
+}
+
+func TestLintPupManifestSchemaErrors(t *testing.T) {
+	result := LintPupManifest(PupManifestLintInput{Manifest: []byte("{\n  \"manifestVersion\": \"1\"\n}")})
+	assert.False(t, result.Valid)
+	require.Len(t, result.Diagnostics, 1)
+	assert.Equal(t, LINT_SCHEMA, result.Diagnostics[0].Code)
+	assert.Equal(t, "manifestVersion", result.Diagnostics[0].Path)
+	assert.Equal(t, 2, result.Diagnostics[0].Line)
+}
+
+func TestLintPupManifestReportsEverything(t *testing.T) {
+	result := LintPupManifest(PupManifestLintInput{Manifest: []byte(`{
+  "manifestVersion": 1,
+  "meta": {"verison": "1.0.0"},
+  "container": {
+    "build": {"nixFile": "pup.nix"},
+    "services": [{"name": "server", "command": {"exec": "", "evn": {}}}]
+  }
+}`)})
+	assert.False(t, result.Valid)
+
+	byPath := map[string]PupManifestDiagnostic{}
+	for _, d := range result.Diagnostics {
+		byPath[d.Path] = d
+	}
+	for _, path := range []string{"meta.name", "meta.version", "container.build.nixFileSha256", "container.services[0].command.exec"} {
+		assert.Equal(t, LINT_MISSING_FIELD, byPath[path].Code, path)
+		assert.Equal(t, LINT_ERROR, byPath[path].Severity, path)
+	}
+	for _, path := range []string{"meta.verison", "container.services[0].command.evn"} {
+		assert.Equal(t, LINT_UNKNOWN_FIELD, byPath[path].Code, path)
+		assert.Equal(t, LINT_WARNING, byPath[path].Severity, path)
+	}
+	assert.Equal(t, LINT_NO_DESCRIPTION, byPath["meta.shortDescription"].Code)
+	assert.Equal(t, LINT_NIX_FILE, byPath["container.build.nixFile"].Code)
+	// Validate would only repeat the first missing field.
+	assert.NotContains(t, lintCodes(result), LINT_INVALID)
+}
+
+func TestLintPupManifestRunsValidate(t *testing.T) {
+	result := LintPupManifest(PupManifestLintInput{
+		Manifest: testLintManifest(testLintNixHash(), `{"name": "web", "type": "udp", "port": 8080}`),
+		NixFile:  []byte(testLintNix),
+	})
+	assert.False(t, result.Valid)
+	assert.Equal(t, []string{LINT_INVALID}, lintCodes(result))
+	assert.Contains(t, result.Diagnostics[0].Message, "expose type")
+}
+
+func TestLintPupManifestHashMismatch(t *testing.T) {
+	result := LintPupManifest(PupManifestLintInput{
+		Manifest: testLintManifest("deadbeef", ""),
+		NixFile:  []byte(testLintNix),
+	})
+	assert.False(t, result.Valid)
+	require.Equal(t, []string{LINT_HASH_MISMATCH}, lintCodes(result))
+	assert.Contains(t, result.Diagnostics[0].Message, testLintNixHash())
+}
+
+func TestLintPupManifestPortConflicts(t *testing.T) {
+	result := LintPupManifest(PupManifestLintInput{
+		Manifest: testLintManifest(testLintNixHash(), `
+			{"name": "rpc", "type": "tcp", "port": 22555},
+			{"name": "p2p", "type": "tcp", "port": 22556, "listenOnHost": true},
+			{"name": "rpc2", "type": "tcp", "port": 22555}`),
+		NixFile:   []byte(testLintNix),
+		HostPorts: map[int]string{22556: "Dogecoin Core", 22555: "Other Pup"},
+	})
+	assert.False(t, result.Valid)
+	require.Equal(t, []string{LINT_PORT_CONFLICT, LINT_PORT_CONFLICT}, lintCodes(result))
+	assert.Equal(t, "container.exposes[1].port", result.Diagnostics[0].Path)
+	assert.Contains(t, result.Diagnostics[0].Message, "Dogecoin Core")
+	assert.Equal(t, "container.exposes[2].port", result.Diagnostics[1].Path)
+}
+
+func TestPupHostPorts(t *testing.T) {
+	pups := map[string]PupState{
+		"a": {
+			Manifest: PupManifest{Meta: PupManifestMeta{Name: "Core"}, Container: PupManifestContainer{Exposes: []PupManifestExposeConfig{
+				{Port: 22556, ListenOnHost: true},
+				{Port: 22555},
+			}}},
+			WebUIs: []PupWebUI{{Port: 10000}},
+		},
+		"b": {
+			Manifest: PupManifest{Meta: PupManifestMeta{Name: "test-pup"}, Container: PupManifestContainer{Exposes: []PupManifestExposeConfig{
+				{Port: 9000, ListenOnHost: true},
+			}}},
+		},
+	}
+	assert.Equal(t, map[int]string{22556: "Core", 10000: "Core"}, PupHostPorts(pups, "test-pup"))
+}
@@ -0,0 +1,124 @@
+package dogeboxd
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+const (
+	MIGRATION_TYPE_RENAME_CONFIG_KEY = "renameConfigKey"
+	MIGRATION_TYPE_REMOVE_CONFIG_KEY = "removeConfigKey"
+	MIGRATION_TYPE_COMMAND           = "command"
+)
+
+func (m PupManifestMigration) Validate() error {
+	if _, err := parseMigrationVersion(m.Version); err != nil {
+		return fmt.Errorf("invalid version %q: %w", m.Version, err)
+	}
+
+	switch m.Type {
+	case MIGRATION_TYPE_RENAME_CONFIG_KEY:
+		if m.From == "" || m.To == "" {
+			return fmt.Errorf("%s requires from and to", m.Type)
+		}
+	case MIGRATION_TYPE_REMOVE_CONFIG_KEY:
+		if m.From == "" {
+			return fmt.Errorf("%s requires from", m.Type)
+		}
+	case MIGRATION_TYPE_COMMAND:
+		if m.Service == "" || m.Exec == "" {
+			return fmt.Errorf("%s requires service and exec", m.Type)
+		}
+	default:
+		return fmt.Errorf("unknown migration type %q", m.Type)
+	}
+
+	return nil
+}
+
+// Name is a short human readable label for this migration, used in job logs.
+func (m PupManifestMigration) Name() string {
+	if m.Description != "" {
+		return fmt.Sprintf("%s (%s): %s", m.Version, m.Type, m.Description)
+	}
+
+	switch m.Type {
+	case MIGRATION_TYPE_RENAME_CONFIG_KEY:
+		return fmt.Sprintf("%s: rename config %s to %s", m.Version, m.From, m.To)
+	case MIGRATION_TYPE_REMOVE_CONFIG_KEY:
+		return fmt.Sprintf("%s: remove config %s", m.Version, m.From)
+	default:
+		return fmt.Sprintf("%s: run %s", m.Version, m.Exec)
+	}
+}
+
+// PupMigrationsBetween returns the migrations from manifest that apply when
+// upgrading from fromVersion to toVersion, in the order they should be run.
+func PupMigrationsBetween(manifest PupManifest, fromVersion string, toVersion string) ([]PupManifestMigration, error) {
+	from, err := parseMigrationVersion(fromVersion)
+	if err != nil {
+		return nil, fmt.Errorf("invalid current version %q: %w", fromVersion, err)
+	}
+
+	to, err := parseMigrationVersion(toVersion)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target version %q: %w", toVersion, err)
+	}
+
+	type versionedMigration struct {
+		version   *semver.Version
+		migration PupManifestMigration
+	}
+
+	applicable := []versionedMigration{}
+	for _, m := range manifest.Migrations {
+		v, err := parseMigrationVersion(m.Version)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version %q: %w", m.Version, err)
+		}
+
+		if v.GreaterThan(from) && !v.GreaterThan(to) {
+			applicable = append(applicable, versionedMigration{v, m})
+		}
+	}
+
+	// Manifest order is kept for migrations introduced in the same version.
+	sort.SliceStable(applicable, func(i, j int) bool {
+		return applicable[i].version.LessThan(applicable[j].version)
+	})
+
+	migrations := make([]PupManifestMigration, 0, len(applicable))
+	for _, a := range applicable {
+		migrations = append(migrations, a.migration)
+	}
+
+	return migrations, nil
+}
+
+// ApplyConfigMigration runs a config migration against config in place.
+func ApplyConfigMigration(config map[string]string, m PupManifestMigration) error {
+	switch m.Type {
+	case MIGRATION_TYPE_RENAME_CONFIG_KEY:
+		value, ok := config[m.From]
+		if !ok {
+			return nil
+		}
+		if existing, exists := config[m.To]; exists && existing != value {
+			return fmt.Errorf("cannot rename config %s to %s, %s is already set", m.From, m.To, m.To)
+		}
+		config[m.To] = value
+		delete(config, m.From)
+	case MIGRATION_TYPE_REMOVE_CONFIG_KEY:
+		delete(config, m.From)
+	default:
+		return fmt.Errorf("%s is not a config migration", m.Type)
+	}
+
+	return nil
+}
+
+func parseMigrationVersion(version string) (*semver.Version, error) {
+	return semver.NewVersion(version)
+}
@@ -0,0 +1,63 @@
+package dogeboxd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPupMigrationsBetweenSelectsAndOrders(t *testing.T) {
+	manifest := PupManifest{
+		Migrations: []PupManifestMigration{
+			{Version: "1.3.0", Type: MIGRATION_TYPE_REMOVE_CONFIG_KEY, From: "c"},
+			{Version: "1.1.0", Type: MIGRATION_TYPE_RENAME_CONFIG_KEY, From: "old", To: "a"},
+			{Version: "1.0.0", Type: MIGRATION_TYPE_REMOVE_CONFIG_KEY, From: "too-old"},
+			{Version: "1.2.0", Type: MIGRATION_TYPE_RENAME_CONFIG_KEY, From: "a", To: "b"},
+			{Version: "1.1.0", Type: MIGRATION_TYPE_COMMAND, Service: "pup", Exec: "/bin/migrate"},
+			{Version: "2.0.0", Type: MIGRATION_TYPE_REMOVE_CONFIG_KEY, From: "too-new"},
+		},
+	}
+
+	migrations, err := PupMigrationsBetween(manifest, "1.0.0", "v1.3.0")
+	require.NoError(t, err)
+	require.Len(t, migrations, 4)
+
+	assert.Equal(t, "old", migrations[0].From)
+	assert.Equal(t, MIGRATION_TYPE_COMMAND, migrations[1].Type)
+	assert.Equal(t, "a", migrations[2].From)
+	assert.Equal(t, "c", migrations[3].From)
+}
+
+func TestPupMigrationsBetweenRejectsBadVersions(t *testing.T) {
+	_, err := PupMigrationsBetween(PupManifest{}, "not-a-version", "1.0.0")
+	assert.Error(t, err)
+}
+
+func TestApplyConfigMigration(t *testing.T) {
+	config := map[string]string{"old": "value", "gone": "x"}
+
+	require.NoError(t, ApplyConfigMigration(config, PupManifestMigration{Type: MIGRATION_TYPE_RENAME_CONFIG_KEY, From: "old", To: "new"}))
+	require.NoError(t, ApplyConfigMigration(config, PupManifestMigration{Type: MIGRATION_TYPE_REMOVE_CONFIG_KEY, From: "gone"}))
+	assert.Equal(t, map[string]string{"new": "value"}, config)
+
+	// Renaming a key that isn't set is a no-op.
+	require.NoError(t, ApplyConfigMigration(config, PupManifestMigration{Type: MIGRATION_TYPE_RENAME_CONFIG_KEY, From: "missing", To: "other"}))
+	assert.Equal(t, map[string]string{"new": "value"}, config)
+}
+
+func TestApplyConfigMigrationRefusesToClobber(t *testing.T) {
+	config := map[string]string{"old": "a", "new": "b"}
+
+	err := ApplyConfigMigration(config, PupManifestMigration{Type: MIGRATION_TYPE_RENAME_CONFIG_KEY, From: "old", To: "new"})
+	assert.Error(t, err)
+	assert.Equal(t, map[string]string{"old": "a", "new": "b"}, config)
+}
+
+func TestPupManifestMigrationValidate(t *testing.T) {
+	assert.NoError(t, PupManifestMigration{Version: "1.0.0", Type: MIGRATION_TYPE_COMMAND, Service: "pup", Exec: "/bin/migrate"}.Validate())
+	assert.Error(t, PupManifestMigration{Version: "1.0.0", Type: MIGRATION_TYPE_COMMAND}.Validate())
+	assert.Error(t, PupManifestMigration{Version: "1.0.0", Type: MIGRATION_TYPE_RENAME_CONFIG_KEY, From: "a"}.Validate())
+	assert.Error(t, PupManifestMigration{Version: "1.0.0", Type: "dance"}.Validate())
+	assert.Error(t, PupManifestMigration{Version: "latest", Type: MIGRATION_TYPE_REMOVE_CONFIG_KEY, From: "a"}.Validate())
+}
@@ -0,0 +1,127 @@
+package dogeboxd
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+
+	"github.com/shirou/gopsutil/v4/disk"
+	"github.com/shirou/gopsutil/v4/mem"
+)
+
+/* PupManifestRequirements describes the hardware a pup needs.
+ * All fields are optional, an empty requirements block means the
+ * pup runs anywhere dogebox does.
+ */
+type PupManifestRequirements struct {
+	// CPU architectures this pup can be built for, using nix naming,
+	// ie: "x86_64", "aarch64". Empty means any.
+	Architectures []string `json:"architectures,omitempty"`
+	// Minimum total system memory, in MB.
+	MinMemoryMB uint64 `json:"minMemoryMB,omitempty"`
+	// Minimum free space on the data partition, in MB.
+	MinDiskMB uint64 `json:"minDiskMB,omitempty"`
+}
+
+/* Architectures we know of. Others are still allowed in a manifest, so
+ * pups can list architectures newer than this dogeboxd, but are warned
+ * about by CheckCompatibility in case they're a typo.
+ */
+var knownArchitectures = map[string]struct{}{
+	"x86_64":  {},
+	"aarch64": {},
+	"riscv64": {},
+}
+
+func (r PupManifestRequirements) Validate() error {
+	for _, arch := range r.Architectures {
+		if strings.TrimSpace(arch) == "" {
+			return fmt.Errorf("architecture can't be empty")
+		}
+	}
+	return nil
+}
+
+// PlatformInfo is what we know about the machine we're installing on.
+type PlatformInfo struct {
+	Architecture string `json:"architecture"`
+	MemoryMB     uint64 `json:"memoryMB"`
+	DiskFreeMB   uint64 `json:"diskFreeMB"`
+}
+
+// CurrentArchitecture returns the nix name of the CPU architecture
+// we're running on.
+func CurrentArchitecture() string {
+	switch runtime.GOARCH {
+	case "amd64":
+		return "x86_64"
+	case "arm64":
+		return "aarch64"
+	default:
+		return runtime.GOARCH
+	}
+}
+
+// GetPlatformInfo describes this machine, measuring free disk space on
+// the partition holding dataDir. Memory or disk are left as 0 if they
+// can't be read, which skips those checks.
+var GetPlatformInfo = func(dataDir string) PlatformInfo {
+	p := PlatformInfo{Architecture: CurrentArchitecture()}
+	if m, err := mem.VirtualMemory(); err == nil {
+		p.MemoryMB = m.Total / 1024 / 1024
+	}
+	if d, err := disk.Usage(dataDir); err == nil {
+		p.DiskFreeMB = d.Free / 1024 / 1024
+	}
+	return p
+}
+
+// CompatibilityReport is the result of checking a pup's requirements
+// against a platform. Errors block installation, warnings don't.
+type CompatibilityReport struct {
+	Compatible bool     `json:"compatible"`
+	Errors     []string `json:"errors,omitempty"`
+	Warnings   []string `json:"warnings,omitempty"`
+}
+
+/* CheckCompatibility checks a manifest can be installed on platform.
+ *
+ * An unsupported architecture is an error, as the nix build can only
+ * fail. Too little memory or disk are warnings, the pup may still run,
+ * just badly, and disk may be freed before it's needed. So are
+ * architectures we don't know of.
+ */
+func CheckCompatibility(m PupManifest, platform PlatformInfo) CompatibilityReport {
+	report := CompatibilityReport{Compatible: true}
+	req := m.Requirements
+
+	if len(req.Architectures) > 0 {
+		supported := false
+		unknown := []string{}
+		for _, arch := range req.Architectures {
+			if arch == platform.Architecture {
+				supported = true
+			}
+			if _, ok := knownArchitectures[arch]; !ok {
+				unknown = append(unknown, arch)
+			}
+		}
+		if !supported {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s supports %s, this dogebox is %s", m.Meta.Name, strings.Join(req.Architectures, ", "), platform.Architecture))
+		}
+		if len(unknown) > 0 {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("%s lists architectures this dogebox doesn't know: %s", m.Meta.Name, strings.Join(unknown, ", ")))
+		}
+	}
+
+	if req.MinMemoryMB > 0 && platform.MemoryMB > 0 && platform.MemoryMB < req.MinMemoryMB {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("%s needs at least %dMB of memory, this dogebox has %dMB", m.Meta.Name, req.MinMemoryMB, platform.MemoryMB))
+	}
+
+	if req.MinDiskMB > 0 && platform.DiskFreeMB > 0 && platform.DiskFreeMB < req.MinDiskMB {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("%s needs at least %dMB of free disk, this dogebox has %dMB", m.Meta.Name, req.MinDiskMB, platform.DiskFreeMB))
+	}
+
+	report.Compatible = len(report.Errors) == 0
+	return report
+}
@@ -0,0 +1,57 @@
+package dogeboxd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPupManifestRequirementsValidate(t *testing.T) {
+	assert.NoError(t, PupManifestRequirements{}.Validate())
+	assert.NoError(t, PupManifestRequirements{Architectures: []string{"x86_64", "aarch64"}}.Validate())
+	// Unknown architectures are only warned about, see CheckCompatibility.
+	assert.NoError(t, PupManifestRequirements{Architectures: []string{"x86_64", "loongarch64"}}.Validate())
+	assert.Error(t, PupManifestRequirements{Architectures: []string{""}}.Validate())
+}
+
+func TestCheckCompatibility(t *testing.T) {
+	manifest := PupManifest{
+		Meta: PupManifestMeta{Name: "Dogecoin Core"},
+		Requirements: PupManifestRequirements{
+			Architectures: []string{"x86_64", "aarch64"},
+			MinMemoryMB:   4096,
+			MinDiskMB:     200000,
+		},
+	}
+
+	tests := map[string]struct {
+		platform   PlatformInfo
+		compatible bool
+		errors     int
+		warnings   int
+	}{
+		"meets everything":  {PlatformInfo{Architecture: "aarch64", MemoryMB: 8192, DiskFreeMB: 500000}, true, 0, 0},
+		"unsupported arch":  {PlatformInfo{Architecture: "riscv64", MemoryMB: 8192, DiskFreeMB: 500000}, false, 1, 0},
+		"low memory":        {PlatformInfo{Architecture: "x86_64", MemoryMB: 2048, DiskFreeMB: 500000}, true, 0, 1},
+		"low disk":          {PlatformInfo{Architecture: "x86_64", MemoryMB: 8192, DiskFreeMB: 1000}, true, 0, 1},
+		"unknown resources": {PlatformInfo{Architecture: "x86_64"}, true, 0, 0},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			report := CheckCompatibility(manifest, tt.platform)
+			assert.Equal(t, tt.compatible, report.Compatible)
+			assert.Len(t, report.Errors, tt.errors)
+			assert.Len(t, report.Warnings, tt.warnings)
+		})
+	}
+
+	unknown := manifest
+	unknown.Requirements = PupManifestRequirements{Architectures: []string{"x86_64", "loongarch64"}}
+	report := CheckCompatibility(unknown, PlatformInfo{Architecture: "x86_64"})
+	assert.True(t, report.Compatible)
+	assert.Equal(t, []string{"Dogecoin Core lists architectures this dogebox doesn't know: loongarch64"}, report.Warnings)
+
+	// No requirements means anything goes.
+	assert.True(t, CheckCompatibility(PupManifest{}, PlatformInfo{Architecture: "riscv64", MemoryMB: 1}).Compatible)
+}
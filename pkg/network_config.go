@@ -0,0 +1,126 @@
+package dogeboxd
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"strings"
+)
+
+const MAX_NETWORK_DNS_SERVERS = 4
+
+// NetworkStaticIP replaces DHCP on the selected interface.
+type NetworkStaticIP struct {
+	// IPv4 address in CIDR form, ie. 192.168.1.20/24
+	Address string   `json:"address"`
+	Gateway string   `json:"gateway"`
+	DNS     []string `json:"dns"`
+}
+
+// NetworkProxy is the HTTP(S) proxy the system uses for egress.
+type NetworkProxy struct {
+	HTTP    string `json:"http,omitempty"`
+	HTTPS   string `json:"https,omitempty"`
+	NoProxy string `json:"noProxy,omitempty"`
+}
+
+// SelectedNetworkOptions returns the static IP and proxy settings of a
+// selected network, either of which may be nil.
+func SelectedNetworkOptions(network SelectedNetwork) (*NetworkStaticIP, *NetworkProxy) {
+	switch n := network.(type) {
+	case SelectedNetworkEthernet:
+		return n.Static, n.Proxy
+	case SelectedNetworkWifi:
+		return n.Static, n.Proxy
+	}
+	return nil, nil
+}
+
+func (s NetworkStaticIP) Validate() error {
+	prefix, err := netip.ParsePrefix(strings.TrimSpace(s.Address))
+	if err != nil || !prefix.Addr().Is4() {
+		return fmt.Errorf("static address %q must be an IPv4 address with a prefix length, ie. 192.168.1.20/24", s.Address)
+	}
+	if prefix.Bits() == 0 || prefix.Bits() > 31 {
+		return fmt.Errorf("static address %q has an unusable prefix length", s.Address)
+	}
+
+	gateway, err := netip.ParseAddr(strings.TrimSpace(s.Gateway))
+	if err != nil || !gateway.Is4() {
+		return fmt.Errorf("gateway %q must be an IPv4 address", s.Gateway)
+	}
+	if !prefix.Masked().Contains(gateway) {
+		return fmt.Errorf("gateway %s isn't on the %s network", gateway, prefix.Masked())
+	}
+	if gateway == prefix.Addr() {
+		return errors.New("gateway can't be the static address itself")
+	}
+
+	if len(s.DNS) == 0 {
+		return errors.New("at least one DNS server is required with a static address")
+	}
+	if len(s.DNS) > MAX_NETWORK_DNS_SERVERS {
+		return fmt.Errorf("at most %d DNS servers can be set", MAX_NETWORK_DNS_SERVERS)
+	}
+	for _, dns := range s.DNS {
+		if _, err := netip.ParseAddr(strings.TrimSpace(dns)); err != nil {
+			return fmt.Errorf("DNS server %q must be an IP address", dns)
+		}
+	}
+	return nil
+}
+
+func (p NetworkProxy) Validate() error {
+	if p.HTTP == "" && p.HTTPS == "" {
+		return errors.New("an HTTP or HTTPS proxy is required")
+	}
+	for _, proxy := range []string{p.HTTP, p.HTTPS} {
+		if proxy == "" {
+			continue
+		}
+		if err := validateProxyURL(proxy); err != nil {
+			return err
+		}
+	}
+
+	for _, host := range p.NoProxyHosts() {
+		if !isNixSafe(host) || strings.ContainsAny(host, " \t@") || (strings.Contains(host, "/") && !isNoProxyCIDR(host)) {
+			return fmt.Errorf("no proxy entry %q must be a host, domain or CIDR", host)
+		}
+	}
+	return nil
+}
+
+func validateProxyURL(proxy string) error {
+	u, err := url.Parse(proxy)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" || !isNixSafe(proxy) {
+		return fmt.Errorf("proxy %q must be an http:// or https:// URL", proxy)
+	}
+	if u.Path != "" && u.Path != "/" || u.RawQuery != "" || u.Fragment != "" {
+		return fmt.Errorf("proxy %q can't have a path", proxy)
+	}
+	return nil
+}
+
+func isNoProxyCIDR(host string) bool {
+	_, err := netip.ParsePrefix(host)
+	return err == nil
+}
+
+// Values are rendered into nix strings, so keep out anything that
+// could end one.
+func isNixSafe(s string) bool {
+	return !strings.ContainsAny(s, "\"\\$\r\n\x00")
+}
+
+// NoProxyHosts splits NoProxy into its trimmed, non-empty entries.
+func (p NetworkProxy) NoProxyHosts() []string {
+	hosts := []string{}
+	for _, host := range strings.Split(p.NoProxy, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
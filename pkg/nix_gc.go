@@ -0,0 +1,105 @@
+package dogeboxd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shirou/gopsutil/v4/disk"
+)
+
+/* Every rebuild adds a generation of the system profile, and the store
+ * paths it needs are kept until the generation is deleted. Left alone
+ * the nix store grows until the disk is full, so CollectNixGarbage
+ * deletes all but the newest few generations and collects whatever
+ * they no longer reference.
+ *
+ * It runs through the job queue, so never alongside another job's
+ * rebuild, and refuses to start while a system update is still
+ * switching in the background. It can be scheduled with the
+ * "collect-nix-garbage" scheduled action.
+ */
+
+// DEFAULT_NIX_GC_KEEP_GENERATIONS is how many system generations are
+// kept when neither the action nor the box's settings say.
+const DEFAULT_NIX_GC_KEEP_GENERATIONS = 5
+
+const MAX_NIX_GC_KEEP_GENERATIONS = 100
+
+// The unit a system update switches in, see _dbxroot nix rs.
+const NIX_SYSTEM_UPDATE_UNIT = "dogebox-system-update.service"
+
+const nixStorePath = "/nix/store"
+
+type NixGCSettings struct {
+	// System generations to keep, 0 for DEFAULT_NIX_GC_KEEP_GENERATIONS.
+	KeepGenerations int       `json:"keepGenerations"`
+	LastRun         *NixGCRun `json:"lastRun,omitempty"`
+}
+
+// NixStoreUsage is the usage of the filesystem holding the nix store.
+type NixStoreUsage struct {
+	TotalBytes uint64 `json:"totalBytes"`
+	UsedBytes  uint64 `json:"usedBytes"`
+	FreeBytes  uint64 `json:"freeBytes"`
+}
+
+// A NixGCRun is what the last CollectNixGarbage did.
+type NixGCRun struct {
+	JobID           string        `json:"jobId"`
+	StartedAt       time.Time     `json:"startedAt"`
+	FinishedAt      time.Time     `json:"finishedAt"`
+	KeepGenerations int           `json:"keepGenerations"`
+	Before          NixStoreUsage `json:"before"`
+	After           NixStoreUsage `json:"after"`
+	// Free space gained, 0 if something else filled the disk meanwhile,
+	// or the store couldn't be measured.
+	FreedBytes uint64 `json:"freedBytes"`
+	Error      string `json:"error,omitempty"`
+}
+
+func ValidateNixGCKeepGenerations(keep int) error {
+	if keep < 1 || keep > MAX_NIX_GC_KEEP_GENERATIONS {
+		return fmt.Errorf("generations to keep must be between 1 and %d", MAX_NIX_GC_KEEP_GENERATIONS)
+	}
+	return nil
+}
+
+// KeepGenerationsFor returns how many generations a, run on a box with
+// these settings, keeps.
+func (s NixGCSettings) KeepGenerationsFor(a CollectNixGarbage) int {
+	if a.KeepGenerations > 0 {
+		return a.KeepGenerations
+	}
+	if s.KeepGenerations > 0 {
+		return s.KeepGenerations
+	}
+	return DEFAULT_NIX_GC_KEEP_GENERATIONS
+}
+
+// NewNixGCRun records a run that went from before to after.
+func NewNixGCRun(jobID string, keep int, started time.Time, before NixStoreUsage, after NixStoreUsage, err error) NixGCRun {
+	run := NixGCRun{
+		JobID:           jobID,
+		StartedAt:       started,
+		FinishedAt:      time.Now(),
+		KeepGenerations: keep,
+		Before:          before,
+		After:           after,
+	}
+	if before.TotalBytes > 0 && after.FreeBytes > before.FreeBytes {
+		run.FreedBytes = after.FreeBytes - before.FreeBytes
+	}
+	if err != nil {
+		run.Error = err.Error()
+	}
+	return run
+}
+
+// GetNixStoreUsage measures the filesystem holding the nix store.
+var GetNixStoreUsage = func() (NixStoreUsage, error) {
+	d, err := disk.Usage(nixStorePath)
+	if err != nil {
+		return NixStoreUsage{}, err
+	}
+	return NixStoreUsage{TotalBytes: d.Total, UsedBytes: d.Used, FreeBytes: d.Free}, nil
+}
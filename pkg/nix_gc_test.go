@@ -0,0 +1,71 @@
+package dogeboxd
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNixGCKeepGenerationsFor(t *testing.T) {
+	tests := map[string]struct {
+		settings NixGCSettings
+		action   CollectNixGarbage
+		want     int
+	}{
+		"default":         {want: DEFAULT_NIX_GC_KEEP_GENERATIONS},
+		"box setting":     {settings: NixGCSettings{KeepGenerations: 3}, want: 3},
+		"action override": {settings: NixGCSettings{KeepGenerations: 3}, action: CollectNixGarbage{KeepGenerations: 10}, want: 10},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.settings.KeepGenerationsFor(tc.action))
+		})
+	}
+}
+
+func TestValidateNixGCKeepGenerations(t *testing.T) {
+	assert.NoError(t, ValidateNixGCKeepGenerations(1))
+	assert.NoError(t, ValidateNixGCKeepGenerations(MAX_NIX_GC_KEEP_GENERATIONS))
+	assert.Error(t, ValidateNixGCKeepGenerations(0))
+	assert.Error(t, ValidateNixGCKeepGenerations(MAX_NIX_GC_KEEP_GENERATIONS+1))
+}
+
+func TestNewNixGCRunFreedBytes(t *testing.T) {
+	started := time.Now()
+	before := NixStoreUsage{TotalBytes: 100, UsedBytes: 80, FreeBytes: 20}
+
+	run := NewNixGCRun("job", 5, started, before, NixStoreUsage{TotalBytes: 100, UsedBytes: 50, FreeBytes: 50}, nil)
+	assert.Equal(t, uint64(30), run.FreedBytes)
+	assert.Empty(t, run.Error)
+
+	// The disk filled up meanwhile.
+	run = NewNixGCRun("job", 5, started, before, NixStoreUsage{TotalBytes: 100, UsedBytes: 90, FreeBytes: 10}, nil)
+	assert.Equal(t, uint64(0), run.FreedBytes)
+
+	// The store couldn't be measured before.
+	run = NewNixGCRun("job", 5, started, NixStoreUsage{}, NixStoreUsage{TotalBytes: 100, FreeBytes: 50}, errors.New("boom"))
+	assert.Equal(t, uint64(0), run.FreedBytes)
+	assert.Equal(t, "boom", run.Error)
+}
+
+func TestScheduledCollectNixGarbage(t *testing.T) {
+	build := ScheduledActionKinds["collect-nix-garbage"]
+	require.NotNil(t, build)
+
+	a, err := build(nil)
+	require.NoError(t, err)
+	assert.Equal(t, CollectNixGarbage{}, a)
+
+	a, err = build(map[string]string{"keepGenerations": "7"})
+	require.NoError(t, err)
+	assert.Equal(t, CollectNixGarbage{KeepGenerations: 7}, a)
+
+	_, err = build(map[string]string{"keepGenerations": "0"})
+	assert.Error(t, err)
+	_, err = build(map[string]string{"keepGenerations": "lots"})
+	assert.Error(t, err)
+}
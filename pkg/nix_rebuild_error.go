@@ -0,0 +1,61 @@
+package dogeboxd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Identifies a pup or system component responsible for a failed nix rebuild.
+type NixRebuildAttribution struct {
+	PupID     string `json:"pupId,omitempty"`
+	PupName   string `json:"pupName,omitempty"`
+	Component string `json:"component"` // the failing systemd unit, derivation or nix file
+	Reason    string `json:"reason"`
+}
+
+func (a NixRebuildAttribution) String() string {
+	owner := "system"
+	if a.PupName != "" {
+		owner = fmt.Sprintf("pup %s", a.PupName)
+	} else if a.PupID != "" {
+		owner = fmt.Sprintf("pup %s", a.PupID)
+	}
+	return fmt.Sprintf("%s (%s: %s)", owner, a.Component, a.Reason)
+}
+
+// Returned by NixManager when a rebuild fails, carrying whatever
+// we could work out about who caused it from the rebuild output.
+type NixRebuildError struct {
+	Err          error
+	Attributions []NixRebuildAttribution
+}
+
+func (e *NixRebuildError) Error() string {
+	if len(e.Attributions) == 0 {
+		return fmt.Sprintf("nix rebuild failed: %v", e.Err)
+	}
+	return fmt.Sprintf("nix rebuild failed, caused by %s: %v", e.Summary(), e.Err)
+}
+
+func (e *NixRebuildError) Unwrap() error {
+	return e.Err
+}
+
+func (e *NixRebuildError) Summary() string {
+	parts := make([]string, 0, len(e.Attributions))
+	for _, a := range e.Attributions {
+		parts = append(parts, a.String())
+	}
+	return strings.Join(parts, ", ")
+}
+
+// DescribeJobError appends any nix rebuild attribution found in err
+// to a job error message, so failures point at the responsible pup.
+func DescribeJobError(msg string, err error) string {
+	var rebuildErr *NixRebuildError
+	if errors.As(err, &rebuildErr) && len(rebuildErr.Attributions) > 0 {
+		return fmt.Sprintf("%s: rebuild failed due to %s", msg, rebuildErr.Summary())
+	}
+	return msg
+}
@@ -0,0 +1,106 @@
+package dogeboxd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+/* Passkeys let dpanel be signed in to with a WebAuthn authenticator (a
+ * phone, security key or the OS's password manager) instead of the
+ * password, see pkg/webauthn.
+ *
+ * Only the password can unlock the DKM, so passkey sessions can't do
+ * anything needing keys until the password is entered.
+ *
+ * Requiring a passkey never applies over the local unix socket or in
+ * recovery mode, which is how it's turned off if they're all lost.
+ */
+
+const MAX_PASSKEYS = 10
+
+const MAX_PASSKEY_NAME_LENGTH = 64
+
+type Passkey struct {
+	// The credential ID, base64url encoded.
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	PublicKey []byte `json:"publicKey"`
+	Algorithm int    `json:"algorithm"`
+	SignCount uint32 `json:"signCount"`
+	// Whether it can be synced between devices, nil if registered before
+	// this was kept.
+	BackupEligible *bool `json:"backupEligible,omitempty"`
+	// The hostname dpanel was on when this was registered, passkeys
+	// only work on the site they were made for.
+	RPID     string     `json:"rpId"`
+	Created  time.Time  `json:"created"`
+	LastUsed *time.Time `json:"lastUsed,omitempty"`
+}
+
+// There's only the one (admin) user, so this covers every sign-in.
+type DogeboxStateAuth struct {
+	Passkeys []Passkey
+	// Signing in with the password also needs a passkey.
+	RequirePasskey bool
+}
+
+func ValidatePasskeyName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("passkey name is required")
+	}
+	if len(name) > MAX_PASSKEY_NAME_LENGTH {
+		return fmt.Errorf("passkey name must be at most %d characters", MAX_PASSKEY_NAME_LENGTH)
+	}
+	return nil
+}
+
+func (a DogeboxStateAuth) FindPasskey(id string) (int, bool) {
+	for i, p := range a.Passkeys {
+		if p.ID == id {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// PasskeysFor are the passkeys usable on rpID.
+func (a DogeboxStateAuth) PasskeysFor(rpID string) []Passkey {
+	keys := []Passkey{}
+	for _, p := range a.Passkeys {
+		if p.RPID == rpID {
+			keys = append(keys, p)
+		}
+	}
+	return keys
+}
+
+/* RemovePasskey drops a passkey. While passkeys are required it won't
+ * drop the last one, or the last one that works on rpID, the site dpanel
+ * is being used from, as either would lock the user out. rpID is empty
+ * when it isn't known.
+ */
+func (a *DogeboxStateAuth) RemovePasskey(id string, rpID string) error {
+	i, ok := a.FindPasskey(id)
+	if !ok {
+		return fmt.Errorf("no passkey with id %s", id)
+	}
+	if a.RequirePasskey && len(a.Passkeys) == 1 {
+		return fmt.Errorf("can't remove the last passkey while passkeys are required")
+	}
+	if a.RequirePasskey && a.Passkeys[i].RPID == rpID && len(a.PasskeysFor(rpID)) == 1 {
+		return fmt.Errorf("can't remove the last passkey for %s while passkeys are required", rpID)
+	}
+	a.Passkeys = append(a.Passkeys[:i], a.Passkeys[i+1:]...)
+	return nil
+}
+
+// SetRequirePasskey only requires passkeys once one works on rpID, the
+// site dpanel is being used from, so the user can still sign in.
+func (a *DogeboxStateAuth) SetRequirePasskey(required bool, rpID string) error {
+	if required && len(a.PasskeysFor(rpID)) == 0 {
+		return fmt.Errorf("register a passkey for %s before requiring one", rpID)
+	}
+	a.RequirePasskey = required
+	return nil
+}
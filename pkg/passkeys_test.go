@@ -0,0 +1,59 @@
+package dogeboxd
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDogeboxStateAuthPasskeys(t *testing.T) {
+	auth := DogeboxStateAuth{}
+	assert.Error(t, auth.SetRequirePasskey(true, "dogebox.local"), "needs a passkey first")
+
+	auth.Passkeys = []Passkey{
+		{ID: "a", RPID: "dogebox.local"},
+		{ID: "b", RPID: "box.example.org"},
+	}
+	// Not without one for the site dpanel is on, or it's a lock out.
+	assert.ErrorContains(t, auth.SetRequirePasskey(true, "192.168.1.2.nip.io"), "register a passkey")
+	assert.False(t, auth.RequirePasskey)
+	require.NoError(t, auth.SetRequirePasskey(true, "dogebox.local"))
+	assert.Len(t, auth.PasskeysFor("dogebox.local"), 1)
+
+	// The only key that works where the user signs in from stays.
+	assert.ErrorContains(t, auth.RemovePasskey("a", "dogebox.local"), "last passkey for dogebox.local")
+	require.NoError(t, auth.RemovePasskey("a", "box.example.org"))
+	assert.Error(t, auth.RemovePasskey("a", "box.example.org"))
+	assert.ErrorContains(t, auth.RemovePasskey("b", ""), "last passkey")
+
+	require.NoError(t, auth.SetRequirePasskey(false, ""))
+	require.NoError(t, auth.RemovePasskey("b", "box.example.org"))
+	assert.Empty(t, auth.Passkeys)
+}
+
+func TestValidatePasskeyName(t *testing.T) {
+	assert.NoError(t, ValidatePasskeyName("My phone"))
+	assert.Error(t, ValidatePasskeyName("  "))
+	assert.Error(t, ValidatePasskeyName(string(make([]byte, MAX_PASSKEY_NAME_LENGTH+1))))
+}
+
+// DogeboxState is stored as JSON, so everything needed to check a
+// sign-in has to survive it.
+func TestPasskeyStateRoundTrip(t *testing.T) {
+	state := DogeboxState{Auth: DogeboxStateAuth{
+		RequirePasskey: true,
+		Passkeys: []Passkey{{
+			ID: "abc", Name: "Phone", PublicKey: []byte{1, 2, 3}, Algorithm: -7,
+			SignCount: 42, BackupEligible: new(bool), RPID: "dogebox.local", Created: time.Unix(1700000000, 0).UTC(),
+		}},
+	}}
+	b, err := json.Marshal(state)
+	require.NoError(t, err)
+
+	var decoded DogeboxState
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	assert.Equal(t, state.Auth, decoded.Auth)
+}
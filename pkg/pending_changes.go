@@ -0,0 +1,130 @@
+package dogeboxd
+
+import (
+	"fmt"
+	"time"
+)
+
+/* Pending changes are config edits that have been written out but not
+ * rebuilt yet, made while DogeboxState.DeferRebuilds is on. Rather than
+ * a rebuild per toggle, the user reviews them and applies (one rebuild)
+ * or discards them as a group, see ApplyPendingChanges.
+ *
+ * There is at most one pending change per target, so toggling a pup
+ * back and forth, or editing custom.nix twice, collapses into a single
+ * change against what is actually running, or none at all.
+ */
+
+type PendingChangeKind string
+
+const (
+	PENDING_CHANGE_PUP_ENABLED  PendingChangeKind = "pup-enabled"
+	PENDING_CHANGE_PUP_DISABLED PendingChangeKind = "pup-disabled"
+	PENDING_CHANGE_CUSTOM_NIX   PendingChangeKind = "custom-nix"
+)
+
+const customNixPendingChangeID = "custom-nix"
+
+type PendingChange struct {
+	ID      string            `json:"id"`
+	Kind    PendingChangeKind `json:"kind"`
+	PupID   string            `json:"pupId,omitempty"`
+	Summary string            `json:"summary"`
+	Created time.Time         `json:"created"`
+	// What discarding restores, ie: the running config.
+	PreviousEnabled bool   `json:"previousEnabled,omitempty"`
+	PreviousContent string `json:"previousContent,omitempty"`
+	// The pending custom.nix, so it can be diffed against PreviousContent.
+	Content string `json:"content,omitempty"`
+}
+
+func pupPendingChangeID(pupID string) string {
+	return "pup-" + pupID
+}
+
+func (s *DogeboxState) findPendingChange(id string) int {
+	for i, c := range s.PendingChanges {
+		if c.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *DogeboxState) removePendingChange(i int) {
+	s.PendingChanges = append(s.PendingChanges[:i], s.PendingChanges[i+1:]...)
+}
+
+// RecordPupPendingChange notes a pup was enabled or disabled without a
+// rebuild.
+func (s *DogeboxState) RecordPupPendingChange(pup PupState, enabled bool) {
+	id := pupPendingChangeID(pup.ID)
+	previous := !enabled
+	if i := s.findPendingChange(id); i >= 0 {
+		previous = s.PendingChanges[i].PreviousEnabled
+		s.removePendingChange(i)
+	}
+	if previous == enabled {
+		return
+	}
+
+	change := PendingChange{
+		ID:              id,
+		Kind:            PENDING_CHANGE_PUP_DISABLED,
+		PupID:           pup.ID,
+		Summary:         fmt.Sprintf("Disable %s", pup.Manifest.Meta.Name),
+		Created:         time.Now(),
+		PreviousEnabled: previous,
+	}
+	if enabled {
+		change.Kind = PENDING_CHANGE_PUP_ENABLED
+		change.Summary = fmt.Sprintf("Enable %s", pup.Manifest.Meta.Name)
+	}
+	s.PendingChanges = append(s.PendingChanges, change)
+}
+
+// RecordCustomNixPendingChange notes custom.nix was saved without a
+// rebuild, previous being what it held before.
+func (s *DogeboxState) RecordCustomNixPendingChange(previous string, content string) {
+	if i := s.findPendingChange(customNixPendingChangeID); i >= 0 {
+		previous = s.PendingChanges[i].PreviousContent
+		s.removePendingChange(i)
+	}
+	if previous == content {
+		return
+	}
+
+	s.PendingChanges = append(s.PendingChanges, PendingChange{
+		ID:              customNixPendingChangeID,
+		Kind:            PENDING_CHANGE_CUSTOM_NIX,
+		Summary:         "Update custom OS configuration",
+		Created:         time.Now(),
+		PreviousContent: previous,
+		Content:         content,
+	})
+}
+
+// ClearPendingChangesBefore drops changes recorded before a rebuild that
+// has since succeeded, as it built whatever they had written out. Any
+// recorded while it ran are kept. Reports whether anything was dropped.
+func (s *DogeboxState) ClearPendingChangesBefore(started time.Time) bool {
+	remaining := []PendingChange{}
+	for _, c := range s.PendingChanges {
+		if !c.Created.Before(started) {
+			remaining = append(remaining, c)
+		}
+	}
+	cleared := len(remaining) != len(s.PendingChanges)
+	s.PendingChanges = remaining
+	return cleared
+}
+
+// IsPendingChangeAction reports whether a job for this Action may change
+// the pending set, so clients should be told about it when it finishes.
+func IsPendingChangeAction(a Action) bool {
+	switch a.(type) {
+	case EnablePup, DisablePup, BulkPupAction, SaveCustomNix, ApplyPendingChanges, DiscardPendingChanges:
+		return true
+	}
+	return false
+}
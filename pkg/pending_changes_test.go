@@ -0,0 +1,52 @@
+package dogeboxd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordPupPendingChangeCollapsesToggles(t *testing.T) {
+	pup := PupState{ID: "abc"}
+	pup.Manifest.Meta.Name = "Test Pup"
+	s := DogeboxState{}
+
+	s.RecordPupPendingChange(pup, true)
+	require.Len(t, s.PendingChanges, 1)
+	assert.Equal(t, PENDING_CHANGE_PUP_ENABLED, s.PendingChanges[0].Kind)
+	assert.Equal(t, "Enable Test Pup", s.PendingChanges[0].Summary)
+	assert.False(t, s.PendingChanges[0].PreviousEnabled)
+
+	// Disabling again is back to what's running, so nothing is pending.
+	s.RecordPupPendingChange(pup, false)
+	assert.Empty(t, s.PendingChanges)
+}
+
+func TestRecordCustomNixPendingChangeKeepsOriginalContent(t *testing.T) {
+	s := DogeboxState{}
+
+	s.RecordCustomNixPendingChange("original", "first edit")
+	s.RecordCustomNixPendingChange("first edit", "second edit")
+	require.Len(t, s.PendingChanges, 1)
+	assert.Equal(t, "original", s.PendingChanges[0].PreviousContent)
+	assert.Equal(t, "second edit", s.PendingChanges[0].Content)
+
+	s.RecordCustomNixPendingChange("second edit", "original")
+	assert.Empty(t, s.PendingChanges)
+}
+
+func TestClearPendingChangesBeforeKeepsLaterChanges(t *testing.T) {
+	started := time.Now()
+	s := DogeboxState{PendingChanges: []PendingChange{
+		{ID: "pup-a", Created: started.Add(-time.Minute)},
+		{ID: "pup-b", Created: started.Add(time.Second)},
+	}}
+
+	assert.True(t, s.ClearPendingChangesBefore(started))
+	require.Len(t, s.PendingChanges, 1)
+	assert.Equal(t, "pup-b", s.PendingChanges[0].ID)
+
+	assert.False(t, s.ClearPendingChangesBefore(started))
+}
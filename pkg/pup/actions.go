@@ -20,11 +20,16 @@ import (
 * Returns PupID, error
  */
 func (t PupManager) AdoptPup(m dogeboxd.PupManifest, source dogeboxd.ManifestSource, options dogeboxd.AdoptPupOptions) (string, error) {
-	// Firstly (for now), check if we already have this manifest installed
-	for _, p := range t.state {
-		if m.Meta.Name == p.Manifest.Meta.Name && m.Meta.Version == p.Manifest.Meta.Version && p.Source.ID == source.Config().ID {
-			return p.ID, dogeboxd.ErrPupAlreadyExists
-		}
+	// Firstly, check we don't already have this manifest (or instance) installed
+	if err := dogeboxd.ValidatePupInstanceName(options.InstanceName); err != nil {
+		return "", err
+	}
+	states := make(map[string]dogeboxd.PupState, len(t.state))
+	for id, p := range t.state {
+		states[id] = *p
+	}
+	if id, err := dogeboxd.FindConflictingPupInstance(states, m, source.Config().ID, options.InstanceName); err != nil {
+		return id, err
 	}
 
 	// Create a PupID for this new Pup
@@ -98,6 +103,7 @@ func (t PupManager) AdoptPup(m dogeboxd.PupManifest, source dogeboxd.ManifestSou
 	// Set up initial PupState and save it to disk
 	p := dogeboxd.PupState{
 		ID:           PupID,
+		InstanceName: options.InstanceName,
 		Source:       sourceConfig,
 		Manifest:     m,
 		Config:       defaultConfig,
@@ -172,6 +178,7 @@ func (t PupManager) PurgePup(pupId string) error {
 	// Remove our in-memory state
 	delete(t.state, pupId)
 	delete(t.stats, pupId)
+	t.usage.forget(pupId)
 
 	// Send a Pupdate announcing 'purged' after removal
 	if exists {
@@ -0,0 +1,114 @@
+package pup
+
+import (
+	"log"
+	"time"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/Masterminds/semver/v3"
+)
+
+// SetAutoUpgrader sets how auto-updating pups get their UpgradePup jobs
+// queued, normally Dogeboxd.AddAction. Until it's set no upgrades are
+// queued, though pups are still checked.
+func (uc *UpdateChecker) SetAutoUpgrader(addAction func(dogeboxd.Action) string) {
+	uc.autoUpdateMutex.Lock()
+	defer uc.autoUpdateMutex.Unlock()
+	uc.addAction = addAction
+}
+
+/* SelectAutoUpdateVersion picks the newest of the available versions that
+ * policy allows moving to from current, skipping anything at or below
+ * skipped (see PupState.SkippedVersion). It returns "" if there's none.
+ */
+func SelectAutoUpdateVersion(policy dogeboxd.PupAutoUpdatePolicy, current string, skipped string, available []dogeboxd.PupVersion) string {
+	if policy == dogeboxd.AUTO_UPDATE_OFF {
+		return ""
+	}
+	currentVer, err := ParseVersionLenient(current)
+	if err != nil {
+		return ""
+	}
+	var skippedVer *semver.Version
+	if skipped != "" {
+		skippedVer, _ = ParseVersionLenient(skipped)
+	}
+
+	best := ""
+	var bestVer *semver.Version
+	for _, v := range available {
+		ver, err := ParseVersionLenient(v.Version)
+		if err != nil || !ver.GreaterThan(currentVer) {
+			continue
+		}
+		if skippedVer != nil && !ver.GreaterThan(skippedVer) {
+			continue
+		}
+		switch policy {
+		case dogeboxd.AUTO_UPDATE_PATCH:
+			if ver.Major() != currentVer.Major() || ver.Minor() != currentVer.Minor() {
+				continue
+			}
+		case dogeboxd.AUTO_UPDATE_MINOR:
+			if ver.Major() != currentVer.Major() {
+				continue
+			}
+		}
+		if bestVer == nil || ver.GreaterThan(bestVer) {
+			best, bestVer = v.Version, ver
+		}
+	}
+	return best
+}
+
+// runAutoUpdates checks every auto-updating pup whose schedule came
+// around since the last run, and queues an upgrade for those with a
+// version their policy allows. Like JobScheduler, runs missed while
+// dogeboxd was down are skipped rather than replayed.
+func (uc *UpdateChecker) runAutoUpdates(now time.Time) {
+	uc.autoUpdateMutex.Lock()
+	since := uc.lastAutoUpdate
+	uc.lastAutoUpdate = now
+	addAction := uc.addAction
+	uc.autoUpdateMutex.Unlock()
+
+	if since.IsZero() {
+		return
+	}
+
+	for pupID, p := range uc.pupManager.GetStateMap() {
+		// Pups in maintenance are upgraded once they're back, by hand or on a later run.
+		if !p.AutoUpdates() || p.Installation != dogeboxd.STATE_READY || !p.ChecksForUpdates() || p.InMaintenance() {
+			continue
+		}
+		cron, err := p.AutoUpdate.CronSchedule()
+		if err != nil {
+			log.Printf("Skipping auto-update of %s: %v", p.DisplayName(), err)
+			continue
+		}
+		if next := cron.Next(since); next.IsZero() || next.After(now) {
+			continue
+		}
+
+		info, err := uc.CheckForUpdates(pupID)
+		if err != nil {
+			log.Printf("Auto-update check failed for %s: %v", p.DisplayName(), err)
+			continue
+		}
+		target := SelectAutoUpdateVersion(p.AutoUpdate.Policy, p.Version, p.SkippedVersion, info.AvailableVersions)
+		if target == "" || addAction == nil {
+			continue
+		}
+
+		log.Printf("Auto-updating %s from %s to %s (policy: %s)", p.DisplayName(), p.Version, target, p.AutoUpdate.Policy)
+		addAction(dogeboxd.UpgradePup{
+			PupID:         pupID,
+			TargetVersion: target,
+			SourceId:      p.Source.ID,
+			Automatic:     true,
+
+			RestartDependents: p.AutoUpdate.RestartDependents,
+			CanaryMinutes:     p.AutoUpdate.CanaryMinutes,
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package pup
+
+import (
+	"testing"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+func TestSelectAutoUpdateVersion(t *testing.T) {
+	available := []dogeboxd.PupVersion{
+		{Version: "1.2.4"},
+		{Version: "1.2.5"},
+		{Version: "1.3.0"},
+		{Version: "2.0.0"},
+		{Version: "not-a-version"},
+	}
+
+	cases := []struct {
+		policy  dogeboxd.PupAutoUpdatePolicy
+		skipped string
+		want    string
+	}{
+		{dogeboxd.AUTO_UPDATE_OFF, "", ""},
+		{dogeboxd.AUTO_UPDATE_PATCH, "", "1.2.5"},
+		{dogeboxd.AUTO_UPDATE_MINOR, "", "1.3.0"},
+		{dogeboxd.AUTO_UPDATE_ALL, "", "2.0.0"},
+		{dogeboxd.AUTO_UPDATE_ALL, "2.0.0", ""},
+		{dogeboxd.AUTO_UPDATE_MINOR, "1.2.5", "1.3.0"},
+		{dogeboxd.AUTO_UPDATE_PATCH, "1.2.5", ""},
+	}
+	for _, c := range cases {
+		got := SelectAutoUpdateVersion(c.policy, "1.2.3", c.skipped, available)
+		if got != c.want {
+			t.Errorf("policy %q skipped %q: expected %q, got %q", c.policy, c.skipped, c.want, got)
+		}
+	}
+
+	if got := SelectAutoUpdateVersion(dogeboxd.AUTO_UPDATE_ALL, "2.0.0", "", available); got != "" {
+		t.Errorf("expected nothing newer than 2.0.0, got %q", got)
+	}
+}
@@ -0,0 +1,185 @@
+package pup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+// A started pup is probed every contractCheckInterval until it serves
+// its interfaces, and marked unhealthy if it still doesn't after
+// contractCheckAttempts, giving a slow pup about 5 minutes to come up.
+const (
+	contractCheckInterval = 30 * time.Second
+	contractCheckTimeout  = 5 * time.Second
+	contractCheckAttempts = 10
+)
+
+// How a pup has done against its interface contracts since it started.
+type pupContracts struct {
+	version   string
+	lastCheck time.Time
+	inFlight  bool
+	attempts  int
+	done      bool // passed, or given up on
+	failed    bool
+	lastError string
+}
+
+// SetInterfaceContracts turns on probing pups for the interfaces they
+// provide, against registry, see dogeboxd.InterfaceContract.
+func (t *PupManager) SetInterfaceContracts(registry map[string][]dogeboxd.InterfaceContract) {
+	t.health.contractRegistry = registry
+}
+
+// runContractChecks probes started pups that haven't yet shown they
+// serve their interfaces, each in its own goroutine.
+func (t PupManager) runContractChecks() {
+	if t.health == nil || t.health.contractRegistry == nil {
+		return
+	}
+
+	// Probes defined by interfaces sources come before the built-in ones.
+	registry := t.health.contractRegistry
+	if t.sourceManager != nil {
+		registry = dogeboxd.InterfaceContractsWith(t.sourceManager.GetInterfaceDefinitions(), registry)
+	}
+
+	for id, p := range t.GetStateMap() {
+		s, ok := t.stats[id]
+		if !ok || !p.Enabled || p.InMaintenance() || (s.Status != dogeboxd.STATE_RUNNING && s.Status != dogeboxd.STATE_UNHEALTHY) {
+			t.health.resetContracts(id)
+			continue
+		}
+		checks := dogeboxd.ContractChecks(p.Manifest, registry)
+		if len(checks) == 0 || !t.health.startContracts(id, p.Version) {
+			continue
+		}
+
+		go func(p dogeboxd.PupState) {
+			err := t.health.checkContracts(p, checks)
+			if t.health.recordContracts(p.ID, err) {
+				t.applyHealth(p.ID)
+			}
+		}(p)
+	}
+}
+
+func (h *healthChecker) checkContracts(p dogeboxd.PupState, checks []dogeboxd.ContractCheck) error {
+	if p.IP == "" {
+		return errors.New("pup has no IP address")
+	}
+	for _, c := range checks {
+		if err := h.probeContract(p.IP, c); err != nil {
+			return fmt.Errorf("%s %s on port %d: %w", c.Interface, c.Version, c.Port, err)
+		}
+	}
+	return nil
+}
+
+func (h *healthChecker) probeContract(ip string, c dogeboxd.ContractCheck) error {
+	ctx, cancel := context.WithTimeout(context.Background(), contractCheckTimeout)
+	defer cancel()
+	addr := net.JoinHostPort(ip, fmt.Sprint(c.Port))
+
+	switch c.Probe.Type {
+	case dogeboxd.CONTRACT_PROBE_TCP:
+		conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+
+	case dogeboxd.CONTRACT_PROBE_HTTP:
+		req, err := http.NewRequestWithContext(ctx, c.Probe.Method, "http://"+addr+c.Probe.Path, strings.NewReader(c.Probe.Body))
+		if err != nil {
+			return err
+		}
+		if c.Probe.ContentType != "" {
+			req.Header.Set("Content-Type", c.Probe.ContentType)
+		}
+		resp, err := h.client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if len(c.Probe.ExpectStatus) > 0 && !slices.Contains(c.Probe.ExpectStatus, resp.StatusCode) {
+			return fmt.Errorf("unexpected response %s", resp.Status)
+		}
+		// A 200 means we got in, so there's nothing to challenge us.
+		if c.Probe.ExpectHeader != "" && resp.StatusCode != http.StatusOK && resp.Header.Get(c.Probe.ExpectHeader) != c.Probe.ExpectHeaderValue {
+			return fmt.Errorf("response has no %s: %s", c.Probe.ExpectHeader, c.Probe.ExpectHeaderValue)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown contract probe type %q", c.Probe.Type)
+}
+
+// startContracts reports whether a pup's contracts are due a probe, and
+// if so marks it as running. A new version starts over.
+func (h *healthChecker) startContracts(id string, version string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	pc, ok := h.contracts[id]
+	if !ok || pc.version != version {
+		pc = &pupContracts{version: version}
+		h.contracts[id] = pc
+	}
+	now := h.now()
+	if pc.done || pc.inFlight || (!pc.lastCheck.IsZero() && now.Sub(pc.lastCheck) < contractCheckInterval) {
+		return false
+	}
+	pc.inFlight = true
+	pc.lastCheck = now
+	return true
+}
+
+// recordContracts stores the result of a probe, reporting whether the
+// pup was given up on because of it.
+func (h *healthChecker) recordContracts(id string, err error) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	pc, ok := h.contracts[id]
+	if !ok {
+		return false
+	}
+	pc.inFlight = false
+	pc.attempts++
+	if err == nil {
+		pc.done = true
+		pc.lastError = ""
+		return false
+	}
+	pc.lastError = err.Error()
+	if pc.attempts >= contractCheckAttempts {
+		pc.done = true
+		pc.failed = true
+		return true
+	}
+	return false
+}
+
+func (h *healthChecker) resetContracts(id string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.contracts, id)
+}
+
+// failingContracts reports whether a pup was given up on, call with h.mu held.
+func (h *healthChecker) failingContracts(id string) (string, bool) {
+	pc, ok := h.contracts[id]
+	if !ok || !pc.failed {
+		return "", false
+	}
+	return pc.lastError, true
+}
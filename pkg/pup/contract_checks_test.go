@@ -0,0 +1,103 @@
+package pup
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+func TestProbeContractCoreRPC(t *testing.T) {
+	h := newHealthChecker()
+	probe := dogeboxd.InterfaceContracts["core-rpc"][0].Probe
+
+	cases := map[string]struct {
+		handler http.HandlerFunc
+		wantErr bool
+	}{
+		"refused with jsonrpc realm": {handler: func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="jsonrpc"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		}},
+		"answered": {handler: func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"result":100}`))
+		}},
+		"some other server": {handler: func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}, wantErr: true},
+		"not found": {handler: func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}, wantErr: true},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			p, check := httpHealthCheckTarget(t, tc.handler)
+			err := h.probeContract(p.IP, dogeboxd.ContractCheck{Interface: "core-rpc", Port: check.Port, Probe: probe})
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("unexpected result: %v", err)
+			}
+		})
+	}
+}
+
+func TestProbeContractTCP(t *testing.T) {
+	h := newHealthChecker()
+	p, check := httpHealthCheckTarget(t, func(w http.ResponseWriter, r *http.Request) {})
+	tcp := dogeboxd.ContractCheck{Interface: "core-zmq", Port: check.Port, Probe: dogeboxd.InterfaceContractProbe{Type: dogeboxd.CONTRACT_PROBE_TCP}}
+
+	if err := h.probeContract(p.IP, tcp); err != nil {
+		t.Fatalf("expected the listening port to pass, got %v", err)
+	}
+	tcp.Port = 1
+	if err := h.probeContract(p.IP, tcp); err == nil {
+		t.Fatal("expected a closed port to fail")
+	}
+}
+
+func TestContractsGiveUpAfterAttempts(t *testing.T) {
+	h := newHealthChecker()
+	now := time.Now()
+	h.now = func() time.Time { return now }
+
+	for i := 0; i < contractCheckAttempts; i++ {
+		if !h.startContracts("abc", "1.0.0") {
+			t.Fatalf("expected attempt %d to be due", i+1)
+		}
+		if h.startContracts("abc", "1.0.0") {
+			t.Fatal("expected no second probe while one is running")
+		}
+		gaveUp := h.recordContracts("abc", errors.New("connection refused"))
+		if gaveUp != (i == contractCheckAttempts-1) {
+			t.Fatalf("attempt %d: gave up = %v", i+1, gaveUp)
+		}
+		if i < contractCheckAttempts-1 && h.status("abc", dogeboxd.STATE_RUNNING) != dogeboxd.STATE_RUNNING {
+			t.Fatal("expected the pup to stay running while it has attempts left")
+		}
+		now = now.Add(contractCheckInterval)
+	}
+
+	if got := h.status("abc", dogeboxd.STATE_RUNNING); got != dogeboxd.STATE_UNHEALTHY {
+		t.Fatalf("expected unhealthy, got %s", got)
+	}
+	if w := h.warnings("abc"); len(w) != 1 || !strings.Contains(w[0], "connection refused") {
+		t.Fatalf("unexpected warnings %v", w)
+	}
+	if h.startContracts("abc", "1.0.0") {
+		t.Fatal("expected no more probes once given up on")
+	}
+
+	// An upgrade starts over.
+	if !h.startContracts("abc", "1.1.0") {
+		t.Fatal("expected a new version to be probed")
+	}
+	if h.recordContracts("abc", nil) {
+		t.Fatal("a passing probe shouldn't give up")
+	}
+	if got := h.status("abc", dogeboxd.STATE_RUNNING); got != dogeboxd.STATE_RUNNING {
+		t.Fatalf("expected running, got %s", got)
+	}
+}
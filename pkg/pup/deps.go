@@ -4,7 +4,6 @@ import (
 	"errors"
 	"fmt"
 	"sort"
-	"strings"
 
 	"github.com/Masterminds/semver/v3"
 	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
@@ -14,33 +13,34 @@ import (
 func (t PupManager) CalculateDeps(pupID string) ([]dogeboxd.PupDependencyReport, error) {
 	pup, ok := t.state[pupID]
 	if !ok {
-		sourceList, err := t.sourceManager.GetAll(false)
-		if err != nil {
+		if t.sourceManager == nil {
 			return []dogeboxd.PupDependencyReport{}, errors.New("no such pup and failed to check sources")
 		}
 
-		parts := strings.Split(pupID, "-")
-		if len(parts) != 2 {
-			return []dogeboxd.PupDependencyReport{}, errors.New("invalid pup ID format")
-		}
-		pupName := parts[0]
-		pupVersion := parts[1]
-
-		// Search through sources for this pup
-		for _, list := range sourceList {
-			for _, p := range list.Pups {
-				if p.Name == pupName && p.Version == pupVersion {
-					// Create a temporary state for this uninstalled pup
-					tempState := &dogeboxd.PupState{
-						Manifest:  p.Manifest,
-						Providers: make(map[string]string),
-					}
-					return t.calculateDeps(tempState), nil
-				}
+		// Not installed, so "pupName-version", or "sourceId/pupName-version"
+		// to say which source, see SourcePupRef. Names and versions can
+		// both contain a -, so try each.
+		var p dogeboxd.ManifestSourcePup
+		found := false
+		for i := range pupID {
+			if pupID[i] != '-' {
+				continue
+			}
+			if sp, _, err := t.sourceManager.FindSourcePup(pupID[:i], pupID[i+1:]); err == nil {
+				p, found = sp, true
+				break
 			}
 		}
+		if !found {
+			return []dogeboxd.PupDependencyReport{}, errors.New("no such pup")
+		}
 
-		return []dogeboxd.PupDependencyReport{}, errors.New("no such pup")
+		// Create a temporary state for this uninstalled pup
+		tempState := &dogeboxd.PupState{
+			Manifest:  p.Manifest,
+			Providers: make(map[string]string),
+		}
+		return t.calculateDeps(tempState), nil
 	}
 
 	return t.calculateDeps(pup), nil
@@ -94,7 +94,12 @@ func (t PupManager) calculateDeps(pupState *dogeboxd.PupState) []dogeboxd.PupDep
 			available := []dogeboxd.PupManifestDependencySource{}
 			sourceList, err := t.sourceManager.GetAll(false)
 			if err == nil {
-				for _, list := range sourceList {
+				// In priority order, so the preferred source's pup is offered first.
+				for _, c := range t.sourceManager.GetAllSourceConfigurations() {
+					list, ok := sourceList[c.ID]
+					if !ok {
+						continue
+					}
 					// search the interfaces and check against constraint
 					for _, p := range list.Pups {
 						for _, iface := range p.Manifest.Interfaces {
@@ -157,8 +162,14 @@ func (t PupManager) calculateDeps(pupState *dogeboxd.PupState) []dogeboxd.PupDep
 			}
 		}
 
-		// Is there a DefaultSourceProvider
+		// Is there a DefaultSourceProvider, from the manifest or else
+		// from an interfaces source that defines this interface.
 		report.DefaultSourceProvider = dep.DefaultSource
+		if dep.DefaultSource.PupName == "" && t.sourceManager != nil {
+			if provider, ok := dogeboxd.DefaultInterfaceProvider(t.sourceManager.GetInterfaceDefinitions(), dep.InterfaceName, dep.InterfaceVersion); ok {
+				report.DefaultSourceProvider = provider
+			}
+		}
 
 		deps = append(deps, report)
 	}
@@ -15,14 +15,14 @@ func (t PupManager) CanPupStart(pupId string) (bool, error) {
 	}
 
 	// If the pup is disabled, don't let it start under any circumstances.
-	if !pup.Enabled {
+	if !pup.Enabled || pup.InMaintenance() {
 		return false, nil
 	}
 
 	report := t.GetPupHealthState(pup)
 
-	// If we still need config or deps, don't start.
-	if report.NeedsConf || report.NeedsDeps {
+	// If we still need config, deps or devices approved, don't start.
+	if report.NeedsConf || report.NeedsDeps || report.NeedsDevices {
 		return false, nil
 	}
 
@@ -39,7 +39,7 @@ func (t PupManager) CanPupStart(pupId string) (bool, error) {
 
 func (t PupManager) GetPupHealthState(pup *dogeboxd.PupState) dogeboxd.PupHealthStateReport {
 	// are our required config fields set?
-	configSet := !dogeboxd.ManifestConfigNeedsValues(pup.Manifest.Config, pup.Config)
+	configSet := !dogeboxd.PupConfigNeedsValues(*pup)
 
 	// if showOnInstall is true and config hasn't been saved yet, config is not set
 	if pup.Manifest.Config.ShowOnInstall && !pup.ConfigSaved {
@@ -49,6 +49,7 @@ func (t PupManager) GetPupHealthState(pup *dogeboxd.PupState) dogeboxd.PupHealth
 	// are our deps met?
 	depsMet := true
 	depsNotRunning := []string{}
+	depsInMaintenance := []string{}
 	for _, d := range t.calculateDeps(pup) {
 		depMet := false
 		for iface, pupID := range pup.Providers {
@@ -58,10 +59,10 @@ func (t PupManager) GetPupHealthState(pup *dogeboxd.PupState) dogeboxd.PupHealth
 				if !ok {
 					depMet = false
 					fmt.Printf("pup %s missing, but provides %s to %s", pupID, iface, pup.ID)
-				} else {
-					if provPup.Status != dogeboxd.STATE_RUNNING {
-						depsNotRunning = append(depsNotRunning, iface)
-					}
+				} else if provPup.Status == dogeboxd.STATE_MAINTENANCE {
+					depsInMaintenance = append(depsInMaintenance, iface)
+				} else if provPup.Status != dogeboxd.STATE_RUNNING {
+					depsNotRunning = append(depsNotRunning, iface)
 				}
 			}
 		}
@@ -72,12 +73,15 @@ func (t PupManager) GetPupHealthState(pup *dogeboxd.PupState) dogeboxd.PupHealth
 
 	report := dogeboxd.PupHealthStateReport{
 		Issues: dogeboxd.PupIssues{
-			DepsNotRunning: depsNotRunning,
-			// TODO: HealthWarnings
+			DepsNotRunning:    depsNotRunning,
+			DepsInMaintenance: depsInMaintenance,
+			HealthWarnings:    t.health.warnings(pup.ID),
+			StorageWarnings:   t.storage.warnings(pup.ID, pup.StorageQuotaMB),
 			// TODO: UpdateAvailable
 		},
-		NeedsConf: !configSet,
-		NeedsDeps: !depsMet,
+		NeedsConf:    !configSet,
+		NeedsDeps:    !depsMet,
+		NeedsDevices: pup.DevicesNeedApproval(),
 	}
 
 	return report
@@ -89,5 +93,6 @@ func (t PupManager) healthCheckPupState(pup *dogeboxd.PupState) {
 
 	pup.NeedsConf = report.NeedsConf
 	pup.NeedsDeps = report.NeedsDeps
+	pup.NeedsDevices = report.NeedsDevices
 	t.stats[pup.ID].Issues = report.Issues
 }
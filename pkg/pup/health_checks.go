@@ -0,0 +1,289 @@
+package pup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+// How often we look for health checks that are due.
+const healthCheckTick = 5 * time.Second
+
+// HealthCommandFunc runs a health check command in a pup's container,
+// returning an error if it fails or doesn't finish within timeout.
+type HealthCommandFunc func(pupID string, command string, timeout time.Duration) error
+
+// The outcome of a pup's recent health checks.
+type pupHealth struct {
+	lastCheck time.Time
+	inFlight  bool
+	check     uint64 // the check in flight, see healthChecker.start
+	failures  int
+	threshold int
+	lastError string
+	passing   bool // the last check passed
+}
+
+func (h pupHealth) unhealthy() bool {
+	return h.threshold > 0 && h.failures >= h.threshold
+}
+
+/* healthChecker runs the health checks pups declare in their manifests,
+ * see dogeboxd.PupManifestHealthCheck. Checks only run while systemd
+ * has the pup running, and their results are forgotten once it stops so
+ * a restarted pup starts out healthy.
+ */
+type healthChecker struct {
+	mu      sync.Mutex
+	checks  uint64 // numbers each check, so a reset pup ignores older results
+	pups    map[string]*pupHealth
+	client  *http.Client
+	command HealthCommandFunc
+	now     func() time.Time
+
+	// Interface contract probes, off while contractRegistry is nil.
+	contracts        map[string]*pupContracts
+	contractRegistry map[string][]dogeboxd.InterfaceContract
+}
+
+func newHealthChecker() *healthChecker {
+	return &healthChecker{
+		pups:      map[string]*pupHealth{},
+		contracts: map[string]*pupContracts{},
+		client: &http.Client{
+			// A redirect means something answered, which is healthy enough.
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		now: time.Now,
+	}
+}
+
+// SetHealthCommandRunner sets how command health checks are run, without
+// it they're skipped.
+func (t *PupManager) SetHealthCommandRunner(f HealthCommandFunc) {
+	t.health.command = f
+}
+
+// runHealthChecks starts any health checks that are due, each in its own
+// goroutine so a slow pup doesn't hold up the stats loop.
+func (t PupManager) runHealthChecks() {
+	if t.health == nil {
+		return
+	}
+
+	for id, p := range t.GetStateMap() {
+		check := p.Manifest.Container.HealthCheck
+		s, ok := t.stats[id]
+		if check == nil || !ok || !p.Enabled || p.InMaintenance() || (s.Status != dogeboxd.STATE_RUNNING && s.Status != dogeboxd.STATE_UNHEALTHY) {
+			t.health.reset(id)
+			continue
+		}
+		if check.Type == dogeboxd.HEALTH_CHECK_COMMAND && t.health.command == nil {
+			continue
+		}
+		n, due := t.health.start(id, check.IntervalDuration())
+		if !due {
+			continue
+		}
+
+		go func(p dogeboxd.PupState, check dogeboxd.PupManifestHealthCheck) {
+			err := t.health.check(p, check)
+			if t.health.record(p.ID, n, check.FailureThreshold(), err) {
+				t.applyHealth(p.ID)
+			}
+		}(p, *check)
+	}
+}
+
+// applyHealth updates a pup's stats straight away when it becomes
+// unhealthy or recovers, rather than waiting for the next stats tick.
+func (t PupManager) applyHealth(id string) {
+	t.mu.Lock()
+	if s, ok := t.stats[id]; ok {
+		s.Status = t.health.status(id, s.Status)
+		s.Issues.HealthWarnings = t.health.warnings(id)
+	}
+	t.mu.Unlock()
+	t.sendStats()
+}
+
+func (h *healthChecker) check(p dogeboxd.PupState, check dogeboxd.PupManifestHealthCheck) error {
+	switch check.Type {
+	case dogeboxd.HEALTH_CHECK_HTTP:
+		return h.checkHTTP(p, check)
+	case dogeboxd.HEALTH_CHECK_COMMAND:
+		return h.command(p.ID, check.Command, check.TimeoutDuration())
+	}
+	return fmt.Errorf("unknown health check type %q", check.Type)
+}
+
+func (h *healthChecker) checkHTTP(p dogeboxd.PupState, check dogeboxd.PupManifestHealthCheck) error {
+	if p.IP == "" {
+		return errors.New("pup has no IP address")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), check.TimeoutDuration())
+	defer cancel()
+
+	path := check.Path
+	if path == "" {
+		path = "/"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("http://%s:%d%s", p.IP, check.Port, path), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 399 {
+		return fmt.Errorf("health check returned %s", resp.Status)
+	}
+	return nil
+}
+
+// start reports whether a pup's check is due, and if so marks it as
+// running and numbers it for record.
+func (h *healthChecker) start(id string, interval time.Duration) (uint64, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ph, ok := h.pups[id]
+	if !ok {
+		ph = &pupHealth{}
+		h.pups[id] = ph
+	}
+	now := h.now()
+	if ph.inFlight || (!ph.lastCheck.IsZero() && now.Sub(ph.lastCheck) < interval) {
+		return 0, false
+	}
+	h.checks++
+	ph.inFlight = true
+	ph.check = h.checks
+	ph.lastCheck = now
+	return ph.check, true
+}
+
+// record stores the result of check n, reporting whether the pup became
+// unhealthy or recovered because of it.
+func (h *healthChecker) record(id string, n uint64, threshold int, err error) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ph, ok := h.pups[id]
+	if !ok || ph.check != n {
+		// Reset while the check was running, ie: the pup stopped or was
+		// restarted, so the result is for the container that's gone.
+		return false
+	}
+
+	was := ph.unhealthy()
+	ph.inFlight = false
+	ph.threshold = threshold
+	ph.passing = err == nil
+	if err == nil {
+		ph.failures = 0
+		ph.lastError = ""
+	} else {
+		ph.failures++
+		ph.lastError = err.Error()
+	}
+	return was != ph.unhealthy()
+}
+
+func (h *healthChecker) reset(id string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.pups, id)
+}
+
+// status turns running into unhealthy for a pup that's failing its
+// health check, and back again once it passes. A pup that never served
+// its interface contracts stays unhealthy until it's restarted.
+func (h *healthChecker) status(id string, status string) string {
+	if h == nil || (status != dogeboxd.STATE_RUNNING && status != dogeboxd.STATE_UNHEALTHY) {
+		return status
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if ph, ok := h.pups[id]; ok && ph.unhealthy() {
+		return dogeboxd.STATE_UNHEALTHY
+	}
+	if _, failed := h.failingContracts(id); failed {
+		return dogeboxd.STATE_UNHEALTHY
+	}
+	return dogeboxd.STATE_RUNNING
+}
+
+// passing reports whether a pup's last health check, since it was last
+// started, passed. See PupManager.ResetPupHealth.
+func (h *healthChecker) passing(id string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ph, ok := h.pups[id]
+	return ok && ph.passing
+}
+
+/* ResetPupHealth forgets a pup's health check results once it's been
+ * restarted or upgraded. A quick restart can go unseen by the stats
+ * loop, which would otherwise leave the old container's passing check
+ * making the new one look ready.
+ */
+func (t PupManager) ResetPupHealth(pupID string) {
+	if t.health == nil {
+		return
+	}
+	t.health.reset(pupID)
+	t.health.resetContracts(pupID)
+}
+
+/* IsPupReady reports whether a pup is running and, if its manifest
+ * declares a health check, has passed it since it was last started. Pups
+ * without a health check are ready as soon as they're running, as are
+ * those with a command check when there's no way to run it.
+ */
+func (t PupManager) IsPupReady(pupID string) bool {
+	p, ok := t.GetStateMap()[pupID]
+	if !ok || !p.Enabled || p.InMaintenance() {
+		return false
+	}
+	s, ok := t.GetStatsMap()[pupID]
+	if !ok || s.Status != dogeboxd.STATE_RUNNING {
+		return false
+	}
+
+	check := p.Manifest.Container.HealthCheck
+	if check == nil || t.health == nil || (check.Type == dogeboxd.HEALTH_CHECK_COMMAND && t.health.command == nil) {
+		return true
+	}
+	return t.health.passing(pupID)
+}
+
+func (h *healthChecker) warnings(id string) []string {
+	if h == nil {
+		return []string{}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	warnings := []string{}
+	if ph, ok := h.pups[id]; ok && ph.unhealthy() {
+		warnings = append(warnings, fmt.Sprintf("Health check failed %d times in a row: %s", ph.failures, ph.lastError))
+	}
+	if err, failed := h.failingContracts(id); failed {
+		warnings = append(warnings, fmt.Sprintf("Doesn't serve an interface it provides: %s", err))
+	}
+	return warnings
+}
@@ -0,0 +1,229 @@
+package pup
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+func httpHealthCheckTarget(t *testing.T, handler http.HandlerFunc) (dogeboxd.PupState, dogeboxd.PupManifestHealthCheck) {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err := strconv.Atoi(u.Port())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dogeboxd.PupState{ID: "abc", IP: u.Hostname()},
+		dogeboxd.PupManifestHealthCheck{Type: dogeboxd.HEALTH_CHECK_HTTP, Port: port, Path: "/health"}
+}
+
+func TestHTTPHealthCheck(t *testing.T) {
+	h := newHealthChecker()
+
+	var gotPath string
+	p, check := httpHealthCheckTarget(t, func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		w.WriteHeader(http.StatusOK)
+	})
+	if err := h.check(p, check); err != nil {
+		t.Fatalf("expected a 200 to pass, got %v", err)
+	}
+	if gotPath != "/health" {
+		t.Fatalf("expected /health to be checked, got %q", gotPath)
+	}
+
+	p, check = httpHealthCheckTarget(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://example.invalid/", http.StatusFound)
+	})
+	if err := h.check(p, check); err != nil {
+		t.Fatalf("expected a redirect to pass without being followed, got %v", err)
+	}
+
+	p, check = httpHealthCheckTarget(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	if err := h.check(p, check); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Fatalf("expected a 503 to fail, got %v", err)
+	}
+}
+
+func TestHealthCheckFailuresMakePupUnhealthy(t *testing.T) {
+	h := newHealthChecker()
+	n, due := h.start("abc", time.Minute)
+	if !due {
+		t.Fatal("expected the first check to be due")
+	}
+
+	for i := 0; i < 2; i++ {
+		if h.record("abc", n, 3, errors.New("connection refused")) {
+			t.Fatalf("check %d shouldn't change health yet", i+1)
+		}
+	}
+	if status := h.status("abc", dogeboxd.STATE_RUNNING); status != dogeboxd.STATE_RUNNING {
+		t.Fatalf("expected running below the threshold, got %s", status)
+	}
+	if warnings := h.warnings("abc"); len(warnings) != 0 {
+		t.Fatalf("expected no warnings below the threshold, got %v", warnings)
+	}
+
+	if !h.record("abc", n, 3, errors.New("connection refused")) {
+		t.Fatal("expected the third failure to make the pup unhealthy")
+	}
+	if status := h.status("abc", dogeboxd.STATE_RUNNING); status != dogeboxd.STATE_UNHEALTHY {
+		t.Fatalf("expected unhealthy, got %s", status)
+	}
+	if status := h.status("abc", dogeboxd.STATE_STOPPING); status != dogeboxd.STATE_STOPPING {
+		t.Fatalf("only running pups should be unhealthy, got %s", status)
+	}
+	warnings := h.warnings("abc")
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "connection refused") {
+		t.Fatalf("unexpected warnings: %v", warnings)
+	}
+
+	if !h.record("abc", n, 3, nil) {
+		t.Fatal("expected a pass to recover the pup")
+	}
+	if status := h.status("abc", dogeboxd.STATE_UNHEALTHY); status != dogeboxd.STATE_RUNNING {
+		t.Fatalf("expected running after recovering, got %s", status)
+	}
+}
+
+func TestHealthChecksRunWhenDue(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	h := newHealthChecker()
+	h.now = func() time.Time { return now }
+
+	n, due := h.start("abc", time.Minute)
+	if !due {
+		t.Fatal("expected the first check to be due")
+	}
+	if _, due := h.start("abc", time.Minute); due {
+		t.Fatal("a check shouldn't start while one is in flight")
+	}
+	h.record("abc", n, 3, nil)
+
+	now = now.Add(30 * time.Second)
+	if _, due := h.start("abc", time.Minute); due {
+		t.Fatal("a check shouldn't start before its interval")
+	}
+	now = now.Add(30 * time.Second)
+	if _, due := h.start("abc", time.Minute); !due {
+		t.Fatal("expected the check to be due after its interval")
+	}
+}
+
+func TestRunHealthChecksOnlyChecksRunningPups(t *testing.T) {
+	check := &dogeboxd.PupManifestHealthCheck{Type: dogeboxd.HEALTH_CHECK_COMMAND, Command: "true"}
+	newPup := func(id string, enabled bool) *dogeboxd.PupState {
+		p := &dogeboxd.PupState{ID: id, Enabled: enabled}
+		p.Manifest.Container.HealthCheck = check
+		return p
+	}
+
+	manager := PupManager{
+		mu: &sync.Mutex{},
+		state: map[string]*dogeboxd.PupState{
+			"running":  newPup("running", true),
+			"stopped":  newPup("stopped", true),
+			"disabled": newPup("disabled", false),
+		},
+		stats: map[string]*dogeboxd.PupStats{
+			"running":  {ID: "running", Status: dogeboxd.STATE_RUNNING},
+			"stopped":  {ID: "stopped", Status: dogeboxd.STATE_STOPPED},
+			"disabled": {ID: "disabled", Status: dogeboxd.STATE_RUNNING},
+		},
+		statsSubscribers: map[chan []dogeboxd.PupStats]bool{},
+		health:           newHealthChecker(),
+	}
+
+	ran := make(chan string, 3)
+	manager.SetHealthCommandRunner(func(pupID string, command string, timeout time.Duration) error {
+		ran <- pupID
+		return nil
+	})
+	manager.runHealthChecks()
+
+	select {
+	case id := <-ran:
+		if id != "running" {
+			t.Fatalf("expected only the running pup to be checked, got %s", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the running pup to be checked")
+	}
+	select {
+	case id := <-ran:
+		t.Fatalf("unexpected check of %s", id)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestIsPupReadyWaitsForAPassingHealthCheck(t *testing.T) {
+	newPup := func(id string, check *dogeboxd.PupManifestHealthCheck) *dogeboxd.PupState {
+		p := &dogeboxd.PupState{ID: id, Enabled: true}
+		p.Manifest.Container.HealthCheck = check
+		return p
+	}
+
+	manager := PupManager{
+		mu: &sync.Mutex{},
+		state: map[string]*dogeboxd.PupState{
+			"checked":  newPup("checked", &dogeboxd.PupManifestHealthCheck{Type: dogeboxd.HEALTH_CHECK_HTTP, Port: 80}),
+			"plain":    newPup("plain", nil),
+			"starting": newPup("starting", nil),
+		},
+		stats: map[string]*dogeboxd.PupStats{
+			"checked":  {ID: "checked", Status: dogeboxd.STATE_RUNNING},
+			"plain":    {ID: "plain", Status: dogeboxd.STATE_RUNNING},
+			"starting": {ID: "starting", Status: dogeboxd.STATE_STARTING},
+		},
+		health: newHealthChecker(),
+	}
+
+	if !manager.IsPupReady("plain") {
+		t.Fatal("a running pup without a health check should be ready")
+	}
+	if manager.IsPupReady("starting") {
+		t.Fatal("a starting pup shouldn't be ready")
+	}
+	if manager.IsPupReady("checked") {
+		t.Fatal("a pup shouldn't be ready before its health check has passed")
+	}
+
+	n, _ := manager.health.start("checked", time.Minute)
+	manager.health.record("checked", n, 3, errors.New("connection refused"))
+	if manager.IsPupReady("checked") {
+		t.Fatal("a pup failing its health check shouldn't be ready")
+	}
+	manager.health.record("checked", n, 3, nil)
+	if !manager.IsPupReady("checked") {
+		t.Fatal("expected the pup to be ready once its health check passes")
+	}
+
+	// Restarted, so the old container's pass doesn't count, nor does a
+	// check of it that was still in flight.
+	stale, _ := manager.health.start("checked", 0)
+	manager.ResetPupHealth("checked")
+	manager.health.record("checked", stale, 3, nil)
+	if manager.IsPupReady("checked") {
+		t.Fatal("a restarted pup shouldn't be ready until its health check passes again")
+	}
+	n, _ = manager.health.start("checked", time.Minute)
+	manager.health.record("checked", n, 3, nil)
+	if !manager.IsPupReady("checked") {
+		t.Fatal("expected the restarted pup to be ready once its health check passes")
+	}
+}
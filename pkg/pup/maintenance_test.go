@@ -0,0 +1,45 @@
+package pup
+
+import (
+	"testing"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDerivePupStatusInMaintenance(t *testing.T) {
+	p := dogeboxd.PupState{ID: "abc", Enabled: true, Maintenance: &dogeboxd.PupMaintenance{Reason: "Reindexing"}}
+
+	assert.Equal(t, dogeboxd.STATE_STOPPING, derivePupStatusFromProc(p, dogeboxd.ProcStatus{ActiveState: "active", Running: true}))
+	assert.Equal(t, dogeboxd.STATE_MAINTENANCE, derivePupStatusFromProc(p, dogeboxd.ProcStatus{ActiveState: "inactive"}))
+
+	// Disabled pups are stopped, whatever they were marked.
+	p.Enabled = false
+	assert.Equal(t, dogeboxd.STATE_STOPPED, derivePupStatusFromProc(p, dogeboxd.ProcStatus{ActiveState: "inactive"}))
+}
+
+func TestPupsInMaintenanceCantStart(t *testing.T) {
+	p := dogeboxd.PupState{ID: "abc", Enabled: true, Maintenance: &dogeboxd.PupMaintenance{}}
+	manager := PupManager{state: map[string]*dogeboxd.PupState{"abc": &p}}
+
+	ok, err := manager.CanPupStart("abc")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.False(t, p.StartsOnBoot())
+}
+
+func TestDerivePupStatusWithNotifyServices(t *testing.T) {
+	p := dogeboxd.PupState{ID: "abc", Enabled: true}
+	p.Manifest.Container.Services = []dogeboxd.PupManifestService{{
+		Name:      "dogecoind",
+		Readiness: &dogeboxd.PupManifestServiceReadiness{Type: dogeboxd.PUP_SERVICE_READINESS_NOTIFY},
+	}}
+
+	// Its process being up isn't enough, systemd has to say it's ready.
+	assert.Equal(t, dogeboxd.STATE_STARTING, derivePupStatusFromProc(p, dogeboxd.ProcStatus{Running: true}))
+	assert.Equal(t, dogeboxd.STATE_STARTING, derivePupStatusFromProc(p, dogeboxd.ProcStatus{ActiveState: "activating", Running: true}))
+	assert.Equal(t, dogeboxd.STATE_RUNNING, derivePupStatusFromProc(p, dogeboxd.ProcStatus{ActiveState: "active", Running: true}))
+
+	p.Enabled = false
+	assert.Equal(t, dogeboxd.STATE_STOPPED, derivePupStatusFromProc(p, dogeboxd.ProcStatus{ActiveState: "inactive"}))
+}
@@ -41,7 +41,10 @@ type PupManager struct {
 	statsSubscribers  map[chan []dogeboxd.PupStats]bool // listeners for 'PupStats'
 	monitor           dogeboxd.SystemMonitor
 	sourceManager     dogeboxd.SourceManager
-	updateChecker     *UpdateChecker // Embedded update checker
+	updateChecker     *UpdateChecker  // Embedded update checker
+	health            *healthChecker  // Manifest-declared health checks
+	storage           *storageScanner // Storage usage against quotas
+	usage             *usageTracker   // Resource usage for weekly summaries
 }
 
 func NewPupManager(config dogeboxd.ServerConfig, monitor dogeboxd.SystemMonitor) (*PupManager, error) {
@@ -72,6 +75,9 @@ func NewPupManager(config dogeboxd.ServerConfig, monitor dogeboxd.SystemMonitor)
 		statsSubscribers:  map[chan []dogeboxd.PupStats]bool{},
 		mu:                &mu,
 		monitor:           monitor,
+		health:            newHealthChecker(),
+		storage:           newStorageScanner(),
+		usage:             newUsageTracker(config.DataDir),
 	}
 	// load pups from disk
 	err := p.loadPups()
@@ -106,12 +112,20 @@ func NewPupManager(config dogeboxd.ServerConfig, monitor dogeboxd.SystemMonitor)
 func (t PupManager) Run(started, stopped chan bool, stop chan context.Context) error {
 	go func() {
 		go func() {
+			healthTicker := time.NewTicker(healthCheckTick)
+			defer healthTicker.Stop()
 		mainloop:
 			for {
 				select {
 				case <-stop:
 					break mainloop
 
+				case <-healthTicker.C:
+					t.runHealthChecks()
+					t.runContractChecks()
+					t.runStorageScan()
+					t.usage.maybeSave()
+
 				case stats := <-t.monitor.GetStatChannel():
 					// turn ProcStatus into updates to t.state
 					for k, v := range stats {
@@ -137,8 +151,10 @@ func (t PupManager) Run(started, stopped chan bool, stop chan context.Context) e
 
 						// Calculate our status
 						p := t.state[id]
-						s.Status = derivePupStatusFromProc(*p, v)
+						s.Status = t.health.status(id, derivePupStatusFromProc(*p, v))
+						s.LastRestart = lastRestartFromProc(v)
 						t.healthCheckPupState(p)
+						t.usage.sample(id, p.IP, v)
 					}
 					t.sendStats()
 
@@ -156,7 +172,8 @@ func (t PupManager) Run(started, stopped chan bool, stop chan context.Context) e
 						}
 						// Calculate our status
 						p := t.state[id]
-						s.Status = derivePupStatusFromProc(*p, v)
+						s.Status = t.health.status(id, derivePupStatusFromProc(*p, v))
+						s.LastRestart = lastRestartFromProc(v)
 
 						t.healthCheckPupState(p)
 					}
@@ -172,7 +189,25 @@ func (t PupManager) Run(started, stopped chan bool, stop chan context.Context) e
 	return nil
 }
 
+// lastRestartFromProc returns when the container was last (re)started,
+// whether on boot, by hand or by the pup's restart schedule.
+func lastRestartFromProc(v dogeboxd.ProcStatus) *time.Time {
+	if v.ActiveSince.IsZero() {
+		return nil
+	}
+	t := v.ActiveSince
+	return &t
+}
+
 func derivePupStatusFromProc(p dogeboxd.PupState, v dogeboxd.ProcStatus) string {
+	// A pup in maintenance should be stopped, once it is that's all we say.
+	if p.Enabled && p.InMaintenance() {
+		if v.Running || v.ActiveState == "active" || v.ActiveState == "activating" || v.ActiveState == "deactivating" {
+			return dogeboxd.STATE_STOPPING
+		}
+		return dogeboxd.STATE_MAINTENANCE
+	}
+
 	// Prefer systemd’s view when available, because MainPID can be 0 during transitions.
 	switch v.ActiveState {
 	case "activating":
@@ -192,6 +227,12 @@ func derivePupStatusFromProc(p dogeboxd.PupState, v dogeboxd.ProcStatus) string
 		return dogeboxd.STATE_RUNNING
 	}
 
+	// A pup whose services send READY=1 is only running once systemd says
+	// so, its process being there just means it's still starting.
+	if p.NotifiesReadiness() && p.Enabled {
+		return dogeboxd.STATE_STARTING
+	}
+
 	// Fallback to process presence + desired enabled state.
 	if v.Running && p.Enabled {
 		return dogeboxd.STATE_RUNNING
@@ -256,6 +297,12 @@ func (t *PupManager) StartPeriodicCheck(stop chan bool) {
 	}
 }
 
+func (t *PupManager) SetAutoUpgrader(addAction func(dogeboxd.Action) string) {
+	if t.updateChecker != nil {
+		t.updateChecker.SetAutoUpgrader(addAction)
+	}
+}
+
 func (t *PupManager) GetEventChannel() <-chan dogeboxd.PupUpdatesCheckedEvent {
 	if t.updateChecker == nil {
 		ch := make(chan dogeboxd.PupUpdatesCheckedEvent)
@@ -287,7 +334,7 @@ func (t *PupManager) StopPup(pupID string, nixManager dogeboxd.NixManager, logge
 	}
 
 	// Disable the pup
-	_, err = t.UpdatePup(pupID, dogeboxd.PupEnabled(false))
+	_, err = t.UpdatePup(pupID, dogeboxd.PupEnabled(false), dogeboxd.WithPupdateReason(dogeboxd.PupdateReasonFromLog(logger)))
 	if err != nil {
 		return fmt.Errorf("failed to disable pup: %w", err)
 	}
@@ -317,7 +364,7 @@ func (t *PupManager) StartPup(pupID string, nixManager dogeboxd.NixManager, logg
 	}
 
 	// Enable the pup in memory
-	_, err = t.UpdatePup(pupID, dogeboxd.PupEnabled(true))
+	_, err = t.UpdatePup(pupID, dogeboxd.PupEnabled(true), dogeboxd.WithPupdateReason(dogeboxd.PupdateReasonFromLog(logger)))
 	if err != nil {
 		return fmt.Errorf("failed to enable pup: %w", err)
 	}
@@ -382,6 +429,7 @@ func (t PupManager) GetAssetsMap() map[string]dogeboxd.PupAsset {
 
 		out[k] = dogeboxd.PupAsset{
 			Logos: logos,
+			Links: v.Manifest.Meta.Links,
 		}
 	}
 	return out
@@ -404,11 +452,13 @@ func (t PupManager) FindPupByIP(ip string) (dogeboxd.PupState, dogeboxd.PupStats
 	return dogeboxd.PupState{}, dogeboxd.PupStats{}, dogeboxd.ErrPupNotFound
 }
 
+// sameSource reports whether a pup's source is source, the copy a pup
+// keeps can differ in settings, ie: the commit it's pinned to.
 func (t PupManager) GetAllFromSource(source dogeboxd.ManifestSourceConfiguration) []*dogeboxd.PupState {
 	pups := []*dogeboxd.PupState{}
 
 	for _, pup := range t.state {
-		if pup.Source == source {
+		if dogeboxd.SameSource(pup.Source, source) {
 			pups = append(pups, pup)
 		}
 	}
@@ -418,7 +468,7 @@ func (t PupManager) GetAllFromSource(source dogeboxd.ManifestSourceConfiguration
 
 func (t PupManager) GetPupFromSource(name string, source dogeboxd.ManifestSourceConfiguration) *dogeboxd.PupState {
 	for _, pup := range t.state {
-		if pup.Source == source && pup.Manifest.Meta.Name == name {
+		if dogeboxd.SameSource(pup.Source, source) && pup.Manifest.Meta.Name == name {
 			return pup
 		}
 	}
@@ -536,7 +586,7 @@ func (t PupManager) GetPupSpecificEnvironmentVariablesForContainer(pupID string)
 func (t *PupManager) recoverStuckPups() {
 	for id, pup := range t.state {
 		if pup.Installation == dogeboxd.STATE_INSTALLING {
-			_, err := t.UpdatePup(id, dogeboxd.SetPupInstallation(dogeboxd.STATE_BROKEN), dogeboxd.SetPupBrokenReason(dogeboxd.BROKEN_REASON_DOWNLOAD_FAILED))
+			_, err := t.UpdatePup(id, dogeboxd.SetPupInstallation(dogeboxd.STATE_BROKEN), dogeboxd.SetPupBrokenReason(dogeboxd.BROKEN_REASON_DOWNLOAD_FAILED), dogeboxd.WithPupdateReason(dogeboxd.PupdateReason{Actor: dogeboxd.JOB_ACTOR_SYSTEM}))
 			if err != nil {
 				log.Printf("Failed to mark pup %s as broken: %v", id, err)
 			}
@@ -560,11 +610,13 @@ func (t *PupManager) CreateSnapshot(pupState dogeboxd.PupState) error {
 		Version:        pupState.Version,
 		Manifest:       pupState.Manifest,
 		Config:         pupState.Config,
+		SecretConfig:   pupState.SecretConfig,
 		Providers:      pupState.Providers,
 		Enabled:        pupState.Enabled,
 		SnapshotDate:   time.Now(),
 		SourceID:       pupState.Source.ID,
 		SourceLocation: pupState.Source.Location,
+		SourceCommit:   pupState.Source.Commit,
 	}
 
 	data, err := json.MarshalIndent(snapshot, "", "  ")
@@ -0,0 +1,47 @@
+package pup
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+// Longest status message a pup is allowed to report
+const maxPupStatusMessageLength = 128
+
+// Stores a pup-declared status (eg: "syncing 42%") against the pup's
+// stats and broadcasts it to clients straight away.
+func (t PupManager) UpdatePupStatus(u dogeboxd.UpdatePupStatus) error {
+	message := strings.TrimSpace(u.Message)
+	if len(message) > maxPupStatusMessageLength {
+		return fmt.Errorf("status message exceeds %d characters", maxPupStatusMessageLength)
+	}
+
+	if u.Progress != nil && (*u.Progress < 0 || *u.Progress > 100) {
+		return fmt.Errorf("status progress must be between 0 and 100, got %v", *u.Progress)
+	}
+
+	t.mu.Lock()
+	s, ok := t.stats[u.PupID]
+	if !ok {
+		t.mu.Unlock()
+		return dogeboxd.ErrPupNotFound
+	}
+
+	// An empty report clears any previously declared status
+	if message == "" && u.Progress == nil {
+		s.PupStatus = nil
+	} else {
+		s.PupStatus = &dogeboxd.PupStatusReport{
+			Message:   message,
+			Progress:  u.Progress,
+			UpdatedAt: time.Now(),
+		}
+	}
+	t.mu.Unlock()
+
+	t.sendStats()
+	return nil
+}
@@ -0,0 +1,70 @@
+package pup
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+func newStatusTestManager() (PupManager, chan []dogeboxd.PupStats) {
+	sub := make(chan []dogeboxd.PupStats, 1)
+	manager := PupManager{
+		mu:               &sync.Mutex{},
+		stats:            map[string]*dogeboxd.PupStats{"pup-1": {ID: "pup-1"}},
+		statsSubscribers: map[chan []dogeboxd.PupStats]bool{sub: true},
+	}
+	return manager, sub
+}
+
+func TestUpdatePupStatusStoresAndBroadcastsStatus(t *testing.T) {
+	manager, sub := newStatusTestManager()
+	progress := 42.5
+
+	err := manager.UpdatePupStatus(dogeboxd.UpdatePupStatus{PupID: "pup-1", Message: " syncing ", Progress: &progress})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	status := manager.stats["pup-1"].PupStatus
+	if status == nil || status.Message != "syncing" || *status.Progress != 42.5 {
+		t.Fatalf("unexpected stored status: %+v", status)
+	}
+
+	select {
+	case stats := <-sub:
+		if len(stats) != 1 || stats[0].PupStatus == nil || stats[0].PupStatus.Message != "syncing" {
+			t.Fatalf("unexpected broadcast stats: %+v", stats)
+		}
+	default:
+		t.Fatal("expected stats to be broadcast")
+	}
+}
+
+func TestUpdatePupStatusEmptyReportClearsStatus(t *testing.T) {
+	manager, _ := newStatusTestManager()
+	manager.stats["pup-1"].PupStatus = &dogeboxd.PupStatusReport{Message: "indexing"}
+
+	if err := manager.UpdatePupStatus(dogeboxd.UpdatePupStatus{PupID: "pup-1"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if manager.stats["pup-1"].PupStatus != nil {
+		t.Fatalf("expected status to be cleared, got %+v", manager.stats["pup-1"].PupStatus)
+	}
+}
+
+func TestUpdatePupStatusRejectsInvalidReports(t *testing.T) {
+	manager, _ := newStatusTestManager()
+	progress := 101.0
+
+	if err := manager.UpdatePupStatus(dogeboxd.UpdatePupStatus{PupID: "pup-1", Progress: &progress}); err == nil {
+		t.Fatal("expected out of range progress to be rejected")
+	}
+
+	err := manager.UpdatePupStatus(dogeboxd.UpdatePupStatus{PupID: "missing", Message: "syncing"})
+	if !errors.Is(err, dogeboxd.ErrPupNotFound) {
+		t.Fatalf("expected ErrPupNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+package pup
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+// How often pup storage directories are measured. Walking a chain's data
+// directory isn't cheap, so this is much slower than the stats loop.
+const storageScanInterval = 5 * time.Minute
+
+/* storageScanner measures how much each pup's storage directory holds,
+ * for PupStats and the warnings raised as a pup nears its storage quota.
+ * Scans run in the background, one at a time.
+ */
+type storageScanner struct {
+	mu       sync.Mutex
+	used     map[string]dogeboxd.PupStorageUsage
+	inFlight bool
+	lastScan time.Time
+	measure  func(dir string) int64
+	now      func() time.Time
+}
+
+func newStorageScanner() *storageScanner {
+	return &storageScanner{
+		used:    map[string]dogeboxd.PupStorageUsage{},
+		measure: directoryUsage,
+		now:     time.Now,
+	}
+}
+
+/* directoryUsage totals the disk space allocated to everything under
+ * dir, as the quota counts it, so sparse files count what they really
+ * use and hard linked files count once. Anything we can't read is
+ * skipped rather than giving up.
+ */
+func directoryUsage(dir string) int64 {
+	type inode struct{ dev, ino uint64 }
+	var total int64
+	seen := map[inode]bool{}
+	filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if d != nil && d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		stat, ok := info.Sys().(*syscall.Stat_t)
+		if !ok {
+			total += info.Size()
+			return nil
+		}
+		if stat.Nlink > 1 && !d.IsDir() {
+			key := inode{uint64(stat.Dev), stat.Ino}
+			if seen[key] {
+				return nil
+			}
+			seen[key] = true
+		}
+		// Blocks are always 512 bytes, whatever the filesystem's block size.
+		total += stat.Blocks * 512
+		return nil
+	})
+	return total
+}
+
+// runStorageScan starts a scan of every pup's storage if one is due.
+func (t PupManager) runStorageScan() {
+	if t.storage == nil || !t.storage.start() {
+		return
+	}
+
+	ids := []string{}
+	for id := range t.GetStateMap() {
+		ids = append(ids, id)
+	}
+
+	go func() {
+		used := map[string]dogeboxd.PupStorageUsage{}
+		for _, id := range ids {
+			used[id] = dogeboxd.PupStorageUsage{
+				UsedBytes: t.storage.measure(filepath.Join(t.config.DataDir, "pups", "storage", id)),
+				CheckedAt: t.storage.now(),
+			}
+		}
+		t.storage.finish(used)
+		t.usage.disk(used)
+		t.applyStorageUsage()
+	}()
+}
+
+// applyStorageUsage puts the latest measurements into the pups' stats.
+func (t PupManager) applyStorageUsage() {
+	t.mu.Lock()
+	for id, s := range t.stats {
+		p, ok := t.state[id]
+		if !ok {
+			continue
+		}
+		s.Storage = t.storage.usage(id, p.StorageQuotaMB)
+		s.Issues.StorageWarnings = t.storage.warnings(id, p.StorageQuotaMB)
+	}
+	t.mu.Unlock()
+	t.sendStats()
+}
+
+func (s *storageScanner) start() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if s.inFlight || (!s.lastScan.IsZero() && now.Sub(s.lastScan) < storageScanInterval) {
+		return false
+	}
+	s.inFlight = true
+	s.lastScan = now
+	return true
+}
+
+func (s *storageScanner) finish(used map[string]dogeboxd.PupStorageUsage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.used = used
+	s.inFlight = false
+}
+
+// usage returns a pup's last measurement against its current quota, nil
+// if it hasn't been measured yet.
+func (s *storageScanner) usage(id string, quotaMB int) *dogeboxd.PupStorageUsage {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.used[id]
+	if !ok {
+		return nil
+	}
+	u.QuotaMB = quotaMB
+	return &u
+}
+
+func (s *storageScanner) warnings(id string, quotaMB int) []string {
+	u := s.usage(id, quotaMB)
+	if u == nil || u.Warning() == "" {
+		return []string{}
+	}
+	return []string{u.Warning()}
+}
@@ -0,0 +1,94 @@
+package pup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+func TestDirectoryUsage(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a"), make([]byte, 1000), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "chain", "blocks"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "chain", "blocks", "b"), make([]byte, 2500), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Sparse, so it's allocated next to nothing.
+	sparse, err := os.Create(filepath.Join(dir, "sparse"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sparse.Truncate(100 << 20); err != nil {
+		t.Fatal(err)
+	}
+	sparse.Close()
+	// Hard links count once.
+	if err := os.Link(filepath.Join(dir, "a"), filepath.Join(dir, "a-link")); err != nil {
+		t.Fatal(err)
+	}
+
+	got := directoryUsage(dir)
+	if got < 3500 || got > 1<<20 {
+		t.Fatalf("expected the allocated size of 3500 bytes of files, got %d", got)
+	}
+	if err := os.Remove(filepath.Join(dir, "a-link")); err != nil {
+		t.Fatal(err)
+	}
+	if withoutLink := directoryUsage(dir); withoutLink != got {
+		t.Fatalf("expected a hard link not to count again, got %d then %d", got, withoutLink)
+	}
+	if got := directoryUsage(filepath.Join(dir, "missing")); got != 0 {
+		t.Fatalf("expected a missing directory to be empty, got %d", got)
+	}
+}
+
+func TestStorageScannerThrottlesScans(t *testing.T) {
+	now := time.Now()
+	s := newStorageScanner()
+	s.now = func() time.Time { return now }
+
+	if !s.start() {
+		t.Fatal("expected the first scan to start")
+	}
+	if s.start() {
+		t.Fatal("expected no second scan while one is in flight")
+	}
+	s.finish(map[string]dogeboxd.PupStorageUsage{})
+
+	now = now.Add(time.Minute)
+	if s.start() {
+		t.Fatal("expected no scan before the interval is up")
+	}
+	now = now.Add(storageScanInterval)
+	if !s.start() {
+		t.Fatal("expected a scan once the interval is up")
+	}
+}
+
+func TestStorageScannerWarnsNearQuota(t *testing.T) {
+	s := newStorageScanner()
+	s.finish(map[string]dogeboxd.PupStorageUsage{
+		"abc": {UsedBytes: 95 * 1024 * 1024},
+	})
+
+	if w := s.warnings("missing", 100); len(w) != 0 {
+		t.Fatalf("expected no warnings for an unmeasured pup, got %v", w)
+	}
+	if w := s.warnings("abc", 0); len(w) != 0 {
+		t.Fatalf("expected no warnings without a quota, got %v", w)
+	}
+	if w := s.warnings("abc", 100); len(w) != 1 {
+		t.Fatalf("expected a warning at 95%% of the quota, got %v", w)
+	}
+	if u := s.usage("abc", 100); u == nil || u.QuotaMB != 100 {
+		t.Fatalf("expected usage against the current quota, got %+v", u)
+	}
+}
@@ -134,6 +134,11 @@ type UpdateChecker struct {
 	cacheMutex    sync.RWMutex
 	dataDir       string
 	eventChannel  chan dogeboxd.PupUpdatesCheckedEvent
+
+	// Automatic upgrades, see runAutoUpdates
+	autoUpdateMutex sync.Mutex
+	addAction       func(dogeboxd.Action) string
+	lastAutoUpdate  time.Time
 }
 
 // updateCacheFile represents the structure stored on disk
@@ -336,8 +341,8 @@ func (uc *UpdateChecker) checkForUpdatesWithMemo(pupID string, memo map[string]g
 		LastChecked:       time.Now(),
 	}
 
-	// Only check for updates from git sources
-	if pup.Source.Type != "git" {
+	// Only check for updates from git sources, and never for system or held pups
+	if !pup.ChecksForUpdates() {
 		return updateInfo, nil
 	}
 
@@ -347,6 +352,12 @@ func (uc *UpdateChecker) checkForUpdatesWithMemo(pupID string, memo map[string]g
 		return updateInfo, fmt.Errorf("failed to get source: %w", err)
 	}
 
+	// A source added again under the same ID somewhere else isn't where
+	// this pup came from, so it can't offer it updates.
+	if !dogeboxd.SameSource(source.Config(), pup.Source) {
+		return updateInfo, fmt.Errorf("source %s is no longer at %s", pup.Source.ID, pup.Source.Location)
+	}
+
 	// Force refresh to get latest versions from git tags
 	sourceList, err := source.List(true)
 	if err != nil {
@@ -545,6 +556,9 @@ func (uc *UpdateChecker) checkAllPupUpdatesInternal(isPeriodic bool) map[string]
 
 	updatesAvailable := 0
 	for pupID, pupState := range stateMap {
+		if pupState.UpdateHold {
+			continue
+		}
 		updateInfo, err := uc.checkForUpdatesWithMemo(pupID, memo)
 		if err != nil {
 			log.Printf("Error checking %s: %v", pupState.Manifest.Meta.Name, err)
@@ -678,6 +692,24 @@ func (uc *UpdateChecker) FindAffectedPups(interfaceName, oldVersion, newVersion
 
 // StartPeriodicCheck starts a background goroutine that checks for updates periodically
 func (uc *UpdateChecker) StartPeriodicCheck(stop chan bool) {
+	// Auto-update schedules are cron expressions, so look at them every
+	// minute, separately from the hourly check.
+	go func() {
+		uc.runAutoUpdates(time.Now())
+
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case now := <-ticker.C:
+				uc.runAutoUpdates(now)
+			case <-stop:
+				return
+			}
+		}
+	}()
+
 	go func() {
 		// Initial check after 30 seconds (to allow system to fully boot)
 		// But we already have cached data loaded from disk, so UI can show updates immediately
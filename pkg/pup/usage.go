@@ -0,0 +1,265 @@
+package pup
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+const usageFileName = "pup-usage.json"
+
+// How often the usage accumulated so far is saved, so a restart only
+// loses a little of the week.
+const usageSaveInterval = 5 * time.Minute
+
+/* usageTracker accumulates what each pup uses from its stats, for the
+ * weekly summaries made by dogeboxd.UsageReporter. The period in
+ * progress is saved to disk now and then so it survives restarts.
+ */
+type usageTracker struct {
+	mu          sync.Mutex
+	path        string
+	since       time.Time
+	pups        map[string]dogeboxd.PupUsage
+	lastSample  map[string]time.Time
+	lastSaved   time.Time
+	readNetwork func(ip string) (in, out uint64, ok bool)
+	now         func() time.Time
+}
+
+type usageFile struct {
+	Since time.Time                    `json:"since"`
+	Pups  map[string]dogeboxd.PupUsage `json:"pups"`
+}
+
+func newUsageTracker(dataDir string) *usageTracker {
+	u := &usageTracker{
+		path:        filepath.Join(dataDir, usageFileName),
+		pups:        map[string]dogeboxd.PupUsage{},
+		lastSample:  map[string]time.Time{},
+		readNetwork: readContainerNetwork,
+		now:         time.Now,
+	}
+	if err := u.load(); err != nil {
+		log.Printf("Failed to load pup usage, starting afresh: %v", err)
+	}
+	if u.since.IsZero() {
+		u.since = u.now()
+	}
+	return u
+}
+
+func (u *usageTracker) load() error {
+	data, err := os.ReadFile(u.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var f usageFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return err
+	}
+	u.since = f.Since
+	if f.Pups != nil {
+		u.pups = f.Pups
+	}
+	return nil
+}
+
+func (u *usageTracker) save() error {
+	u.mu.Lock()
+	data, err := json.Marshal(usageFile{Since: u.since, Pups: u.pups})
+	u.lastSaved = u.now()
+	u.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tmpPath := u.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, u.path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// maybeSave saves the usage if it hasn't been for a while.
+func (u *usageTracker) maybeSave() {
+	u.mu.Lock()
+	due := u.now().Sub(u.lastSaved) >= usageSaveInterval
+	u.mu.Unlock()
+	if !due {
+		return
+	}
+	if err := u.save(); err != nil {
+		log.Printf("Failed to save pup usage: %v", err)
+	}
+}
+
+// sample counts a stats sample of a pup, and its container's traffic.
+func (u *usageTracker) sample(id string, ip string, v dogeboxd.ProcStatus) {
+	if u == nil {
+		return
+	}
+	in, out, networkOK := uint64(0), uint64(0), false
+	if v.Running && ip != "" {
+		in, out, networkOK = u.readNetwork(ip)
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	now := u.now()
+	usage := u.pups[id]
+	if last, ok := u.lastSample[id]; ok && v.Running {
+		usage.AddProcSample(v.CPUPercent, v.MEMMb, now.Sub(last))
+	} else if v.Running {
+		usage.AddProcSample(0, v.MEMMb, 0)
+	}
+	if networkOK {
+		usage.AddNetworkCounters(in, out)
+	}
+	u.pups[id] = usage
+	u.lastSample[id] = now
+}
+
+func (u *usageTracker) disk(used map[string]dogeboxd.PupStorageUsage) {
+	if u == nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for id, s := range used {
+		usage := u.pups[id]
+		usage.AddDiskSample(s.UsedBytes)
+		u.pups[id] = usage
+	}
+}
+
+// forget drops a pup that's been purged.
+func (u *usageTracker) forget(id string) {
+	if u == nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	delete(u.pups, id)
+	delete(u.lastSample, id)
+}
+
+func (u *usageTracker) get() (map[string]dogeboxd.PupUsage, time.Time) {
+	if u == nil {
+		return map[string]dogeboxd.PupUsage{}, time.Time{}
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	usage := make(map[string]dogeboxd.PupUsage, len(u.pups))
+	for id, p := range u.pups {
+		usage[id] = p
+	}
+	return usage, u.since
+}
+
+func (u *usageTracker) roll(start time.Time) (map[string]dogeboxd.PupUsage, time.Time) {
+	if u == nil {
+		return map[string]dogeboxd.PupUsage{}, time.Time{}
+	}
+	u.mu.Lock()
+	usage, since := u.pups, u.since
+	u.pups = make(map[string]dogeboxd.PupUsage, len(usage))
+	for id, p := range usage {
+		u.pups[id] = p.Next()
+	}
+	u.since = start
+	u.mu.Unlock()
+
+	if err := u.save(); err != nil {
+		log.Printf("Failed to save pup usage: %v", err)
+	}
+	return usage, since
+}
+
+func (t PupManager) GetUsage() (map[string]dogeboxd.PupUsage, time.Time) {
+	return t.usage.get()
+}
+
+func (t PupManager) RollUsage(start time.Time) (map[string]dogeboxd.PupUsage, time.Time) {
+	return t.usage.roll(start)
+}
+
+/* readContainerNetwork reads the traffic counters of the host side of a
+ * pup container's veth, found by the route to the pup's IP. What the
+ * host receives is what the pup sent, and vice versa.
+ */
+func readContainerNetwork(ip string) (in, out uint64, ok bool) {
+	routes, err := os.Open("/proc/net/route")
+	if err != nil {
+		return 0, 0, false
+	}
+	defer routes.Close()
+
+	iface, found := routeInterface(routes, ip)
+	if !found {
+		return 0, 0, false
+	}
+
+	stats := filepath.Join("/sys/class/net", iface, "statistics")
+	rx, err := readCounter(filepath.Join(stats, "rx_bytes"))
+	if err != nil {
+		return 0, 0, false
+	}
+	tx, err := readCounter(filepath.Join(stats, "tx_bytes"))
+	if err != nil {
+		return 0, 0, false
+	}
+	return tx, rx, true
+}
+
+// routeInterface finds the interface of the host route to ip in the
+// kernel's route table, as formatted in /proc/net/route.
+func routeInterface(routes io.Reader, ip string) (string, bool) {
+	addr := net.ParseIP(ip).To4()
+	if addr == nil {
+		return "", false
+	}
+	// /proc/net/route has addresses in little endian hex.
+	want := fmt.Sprintf("%02X%02X%02X%02X", addr[3], addr[2], addr[1], addr[0])
+
+	scanner := bufio.NewScanner(routes)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// Iface Destination Gateway Flags RefCnt Use Metric Mask ...
+		if len(fields) < 8 {
+			continue
+		}
+		if strings.EqualFold(fields[1], want) && strings.EqualFold(fields[7], "FFFFFFFF") {
+			return fields[0], true
+		}
+	}
+	return "", false
+}
+
+func readCounter(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
@@ -0,0 +1,68 @@
+package pup
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testRoutes = `Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+eth0	00000000	0101A8C0	0003	0	0	100	00000000	0	0	0
+ve-pup-abc	0A00450A	00000000	0005	0	0	0	FFFFFFFF	0	0	0
+ve-pup-def	0B00450A	00000000	0005	0	0	0	FFFFFFFF	0	0	0
+`
+
+func TestRouteInterface(t *testing.T) {
+	iface, ok := routeInterface(strings.NewReader(testRoutes), "10.69.0.11")
+	require.True(t, ok)
+	assert.Equal(t, "ve-pup-def", iface)
+
+	_, ok = routeInterface(strings.NewReader(testRoutes), "10.69.0.12")
+	assert.False(t, ok)
+	_, ok = routeInterface(strings.NewReader(testRoutes), "not an ip")
+	assert.False(t, ok)
+}
+
+func TestUsageTrackerSamplesAndRolls(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	u := newUsageTracker(dir)
+	u.now = func() time.Time { return now }
+	counter := uint64(0)
+	u.readNetwork = func(ip string) (uint64, uint64, bool) {
+		counter += 100
+		return counter, counter / 2, true
+	}
+
+	running := dogeboxd.ProcStatus{Running: true, CPUPercent: 100, MEMMb: 256}
+	u.sample("core", "10.69.0.10", running)
+	now = now.Add(30 * time.Second)
+	u.sample("core", "10.69.0.10", running)
+	u.disk(map[string]dogeboxd.PupStorageUsage{"core": {UsedBytes: 2048}})
+
+	usage, _ := u.get()
+	assert.InDelta(t, 30.0, usage["core"].CPUSeconds, 0.001)
+	assert.Equal(t, 256.0, usage["core"].MemoryPeakMB)
+	assert.Equal(t, uint64(100), usage["core"].NetworkInBytes)
+
+	weekStart := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	rolled, _ := u.roll(weekStart)
+	assert.Equal(t, usage, rolled)
+
+	// The new period survives a restart, carrying on from the last disk measurement.
+	reloaded := newUsageTracker(dir)
+	usage, since := reloaded.get()
+	assert.Equal(t, weekStart, since.UTC())
+	assert.Zero(t, usage["core"].CPUSeconds)
+	assert.Equal(t, int64(2048), usage["core"].DiskStartBytes)
+
+	u.forget("core")
+	usage, _ = u.get()
+	assert.NotContains(t, usage, "core")
+	assert.FileExists(t, filepath.Join(dir, usageFileName))
+}
@@ -0,0 +1,58 @@
+package dogeboxd
+
+import "fmt"
+
+// How far an automatic upgrade may move a pup's version.
+type PupAutoUpdatePolicy string
+
+const (
+	AUTO_UPDATE_OFF   PupAutoUpdatePolicy = ""
+	AUTO_UPDATE_PATCH PupAutoUpdatePolicy = "patch" // same major.minor only
+	AUTO_UPDATE_MINOR PupAutoUpdatePolicy = "minor" // same major only
+	AUTO_UPDATE_ALL   PupAutoUpdatePolicy = "all"
+)
+
+// When pups opted into automatic upgrades look for one, unless they set
+// their own Schedule: daily at 4am.
+const DefaultPupAutoUpdateSchedule = "0 4 * * *"
+
+/* PupAutoUpdate opts a pup into automatic upgrades. Whenever Schedule
+ * comes around the pup is checked for updates, and if there's a newer
+ * version the Policy allows, an UpgradePup job is queued for it.
+ */
+type PupAutoUpdate struct {
+	Policy   PupAutoUpdatePolicy `json:"policy"`
+	Schedule string              `json:"schedule,omitempty"` // cron expression, see CronSchedule
+	// Restart dependent pups after an automatic upgrade, see UpgradePup.
+	RestartDependents bool `json:"restartDependents,omitempty"`
+	// Roll back automatic upgrades that aren't healthy for this long, see PupCanary.
+	CanaryMinutes int `json:"canaryMinutes,omitempty"`
+}
+
+func (a PupAutoUpdate) Validate() error {
+	switch a.Policy {
+	case AUTO_UPDATE_OFF, AUTO_UPDATE_PATCH, AUTO_UPDATE_MINOR, AUTO_UPDATE_ALL:
+	default:
+		return fmt.Errorf("unknown auto-update policy %q, expected one of: patch, minor, all", a.Policy)
+	}
+	if a.Schedule != "" {
+		if _, err := ParseCronSchedule(a.Schedule); err != nil {
+			return err
+		}
+	}
+	return ValidatePupCanaryMinutes(a.CanaryMinutes)
+}
+
+// CronSchedule returns when to look for upgrades, falling back to
+// DefaultPupAutoUpdateSchedule.
+func (a PupAutoUpdate) CronSchedule() (CronSchedule, error) {
+	if a.Schedule == "" {
+		return ParseCronSchedule(DefaultPupAutoUpdateSchedule)
+	}
+	return ParseCronSchedule(a.Schedule)
+}
+
+// AutoUpdates reports whether the pup has opted into automatic upgrades.
+func (p PupState) AutoUpdates() bool {
+	return p.AutoUpdate != nil && p.AutoUpdate.Policy != AUTO_UPDATE_OFF
+}
@@ -0,0 +1,22 @@
+package dogeboxd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPupAutoUpdateValidate(t *testing.T) {
+	assert.NoError(t, PupAutoUpdate{}.Validate())
+	assert.NoError(t, PupAutoUpdate{Policy: AUTO_UPDATE_PATCH}.Validate())
+	assert.NoError(t, PupAutoUpdate{Policy: AUTO_UPDATE_ALL, Schedule: "30 2 * * 0"}.Validate())
+
+	assert.Error(t, PupAutoUpdate{Policy: "major"}.Validate())
+	assert.Error(t, PupAutoUpdate{Policy: AUTO_UPDATE_MINOR, Schedule: "whenever"}.Validate())
+}
+
+func TestPupStateAutoUpdates(t *testing.T) {
+	assert.False(t, PupState{}.AutoUpdates())
+	assert.False(t, PupState{AutoUpdate: &PupAutoUpdate{}}.AutoUpdates())
+	assert.True(t, PupState{AutoUpdate: &PupAutoUpdate{Policy: AUTO_UPDATE_MINOR}}.AutoUpdates())
+}
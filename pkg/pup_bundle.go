@@ -0,0 +1,363 @@
+package dogeboxd
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Dogebox-WG/dogeboxd/pkg/tarball"
+)
+
+// Bumped when a bundle's layout changes in a way older boxes can't read.
+const PUP_BUNDLE_FORMAT_VERSION = 1
+
+// A ManifestSourceConfiguration.Type for pups installed from a bundle
+// whose source isn't on this box, see PupProvenance.
+const SOURCE_TYPE_BUNDLE = "bundle"
+
+// What's in a bundle's gzipped tarball.
+const (
+	pupBundleManifestName = "bundle.json"
+	// The pup's files, laid out as in its source.
+	pupBundlePupDir = "pup"
+	// An optional nix binary cache, ie: from nix copy --to file://...
+	pupBundleStoreDir = "store"
+)
+
+// Limits on what we read from a bundle into memory.
+const (
+	maxPupBundleManifestSize    = 1 << 20
+	maxPupBundlePupManifestSize = 4 << 20
+)
+
+var ErrPupBundleNotFound = errors.New("pup bundle not found")
+
+/* A PupBundle installs a pup without fetching anything, for boxes with
+ * no network, see InstallPupBundle. Its tarball has bundle.json at the
+ * top, the pup's files (manifest, nix file and assets) under pup/, and
+ * optionally a nix binary cache under store/ with the pup's closure.
+ *
+ * Store paths are only imported if they're signed by a key the box
+ * already trusts for substitutes, bundles themselves aren't signed.
+ */
+type PupBundle struct {
+	FormatVersion int       `json:"formatVersion"`
+	Created       time.Time `json:"created"`
+	PupName       string    `json:"pupName"`
+	PupVersion    string    `json:"pupVersion"`
+	// Where the pup was packed from, so it can follow its source's
+	// upgrades once that's reachable.
+	Provenance PupProvenance `json:"provenance"`
+	// Store paths to import from store/, with their closures.
+	StorePaths []string `json:"storePaths,omitempty"`
+}
+
+/* PupProvenance records the source a pup installed from a bundle came
+ * from. Until a source at the same location is added, the pup's source
+ * is a stand-in of type SOURCE_TYPE_BUNDLE and it isn't offered
+ * upgrades, see PupState.ReconnectsTo.
+ */
+type PupProvenance struct {
+	SourceLocation string `json:"sourceLocation"`
+	SourceName     string `json:"sourceName,omitempty"`
+	SourceType     string `json:"sourceType,omitempty"`
+	// The commit or tag the pup was packed from, if known.
+	Revision string `json:"revision,omitempty"`
+	// When the pup was installed from the bundle.
+	Installed time.Time `json:"installed"`
+}
+
+// PupBundleInfo is what we tell the frontend about an uploaded bundle.
+type PupBundleInfo struct {
+	ID       string      `json:"id"`
+	Size     int64       `json:"size"`
+	Bundle   PupBundle   `json:"bundle"`
+	Manifest PupManifest `json:"manifest"`
+}
+
+func (b PupBundle) Validate() error {
+	if b.FormatVersion < 1 || b.FormatVersion > PUP_BUNDLE_FORMAT_VERSION {
+		return fmt.Errorf("unsupported pup bundle format %d", b.FormatVersion)
+	}
+	if b.PupName == "" || b.PupVersion == "" {
+		return errors.New("pup bundle is missing the pup's name or version")
+	}
+	if b.Provenance.SourceLocation == "" {
+		return errors.New("pup bundle is missing the pup's source")
+	}
+	for _, p := range b.StorePaths {
+		if !strings.HasPrefix(p, "/nix/store/") || strings.Contains(p[len("/nix/store/"):], "/") {
+			return fmt.Errorf("invalid store path %q", p)
+		}
+	}
+	return nil
+}
+
+// StandInSource is the source given to a pup installed from the bundle
+// when no source at its location has been added. It has nothing to list
+// or download, the pup's files all come from the bundle.
+func (b PupBundle) StandInSource() ManifestSource {
+	return pupBundleSource{config: ManifestSourceConfiguration{
+		Name:     b.Provenance.SourceName,
+		Location: b.Provenance.SourceLocation,
+		Type:     SOURCE_TYPE_BUNDLE,
+	}}
+}
+
+type pupBundleSource struct {
+	config ManifestSourceConfiguration
+}
+
+var errPupBundleSource = errors.New("pups installed from a bundle can't be fetched until their source is added")
+
+func (s pupBundleSource) ValidateFromLocation(string) (ManifestSourceConfiguration, error) {
+	return ManifestSourceConfiguration{}, errPupBundleSource
+}
+func (s pupBundleSource) Config() ManifestSourceConfiguration { return s.config }
+func (s pupBundleSource) List(bool) (ManifestSourceList, error) {
+	return ManifestSourceList{}, errPupBundleSource
+}
+func (s pupBundleSource) Download(string, map[string]string) error { return errPupBundleSource }
+
+// ReconnectsTo reports whether source is the one a pup installed from a
+// bundle came from, and is waiting on.
+func (p PupState) ReconnectsTo(source ManifestSourceConfiguration) bool {
+	return p.Source.Type == SOURCE_TYPE_BUNDLE && p.Provenance != nil && p.Provenance.SourceLocation == source.Location
+}
+
+func SetPupProvenance(provenance *PupProvenance) func(*PupState, *[]Pupdate) {
+	return func(p *PupState, pu *[]Pupdate) {
+		p.Provenance = provenance
+	}
+}
+
+func SetPupSource(source ManifestSourceConfiguration) func(*PupState, *[]Pupdate) {
+	return func(p *PupState, pu *[]Pupdate) {
+		p.Source = source
+		*pu = append(*pu, Pupdate{
+			ID:    p.ID,
+			Event: PUP_CHANGED_INSTALLATION,
+			State: *p,
+		})
+	}
+}
+
+/* ReconnectBundledPups moves pups installed from a bundle onto source,
+ * if it's the one they came from, so they're offered its upgrades.
+ * Returns the IDs of the pups moved.
+ */
+func ReconnectBundledPups(pups PupManager, source ManifestSourceConfiguration) ([]string, error) {
+	reconnected := []string{}
+	for id, state := range pups.GetStateMap() {
+		if !state.ReconnectsTo(source) {
+			continue
+		}
+		if _, err := pups.UpdatePup(id, SetPupSource(source)); err != nil {
+			return reconnected, fmt.Errorf("failed to reconnect %s to %s: %w", state.DisplayName(), source.Name, err)
+		}
+		reconnected = append(reconnected, id)
+	}
+	return reconnected, nil
+}
+
+func ValidatePupBundleID(id string) error {
+	if !pupExportIDRegex.MatchString(id) {
+		return ErrPupBundleNotFound
+	}
+	return nil
+}
+
+func (c ServerConfig) PupBundleDir() string {
+	return filepath.Join(c.DataDir, "pup-bundles")
+}
+
+func (c ServerConfig) PupBundlePath(bundleID string) string {
+	return filepath.Join(c.PupBundleDir(), bundleID+".tar.gz")
+}
+
+// NewPupBundleID makes an ID for a bundle upload.
+func NewPupBundleID() (string, error) {
+	return newID(16)
+}
+
+// ReadPupBundle reads and checks the bundle at path, and the manifest of
+// the pup in it.
+func ReadPupBundle(path string) (PupBundle, PupManifest, error) {
+	var bundle PupBundle
+	var manifest PupManifest
+	foundBundle, foundManifest := false, false
+
+	err := walkPupBundle(path, func(name string, r io.Reader) (bool, error) {
+		switch name {
+		case pupBundleManifestName:
+			foundBundle = true
+			if err := json.NewDecoder(io.LimitReader(r, maxPupBundleManifestSize)).Decode(&bundle); err != nil {
+				return true, fmt.Errorf("invalid pup bundle manifest: %w", err)
+			}
+		case pupBundlePupDir + "/manifest.json":
+			foundManifest = true
+			if err := json.NewDecoder(io.LimitReader(r, maxPupBundlePupManifestSize)).Decode(&manifest); err != nil {
+				return true, fmt.Errorf("invalid pup manifest: %w", err)
+			}
+		}
+		return foundBundle && foundManifest, nil
+	})
+	if err != nil {
+		return PupBundle{}, PupManifest{}, err
+	}
+	if !foundBundle {
+		return PupBundle{}, PupManifest{}, errors.New("not a pup bundle, it has no bundle.json")
+	}
+	if !foundManifest {
+		return PupBundle{}, PupManifest{}, errors.New("pup bundle has no manifest.json")
+	}
+
+	if err := bundle.Validate(); err != nil {
+		return PupBundle{}, PupManifest{}, err
+	}
+	if err := manifest.Validate(); err != nil {
+		return PupBundle{}, PupManifest{}, fmt.Errorf("pup bundle manifest validation failed: %w", err)
+	}
+	if manifest.Meta.Name != bundle.PupName || manifest.Meta.Version != bundle.PupVersion {
+		return PupBundle{}, PupManifest{}, fmt.Errorf("pup bundle is for %s %s, but has %s %s", bundle.PupName, bundle.PupVersion, manifest.Meta.Name, manifest.Meta.Version)
+	}
+	return bundle, manifest, nil
+}
+
+/* ExtractPupBundle unpacks the pup's files from the bundle at path into
+ * pupDir, and its binary cache, if it has one, into storeDir. Only plain
+ * files and directories are unpacked, and nothing outside either.
+ */
+func ExtractPupBundle(bundlePath string, pupDir string, storeDir string) (hasStore bool, err error) {
+	if err := extractPupBundleDir(bundlePath, pupBundlePupDir, pupDir); err != nil {
+		return false, fmt.Errorf("failed to unpack pup bundle: %w", err)
+	}
+
+	err = walkPupBundleEntries(bundlePath, func(header *tar.Header, r io.Reader) error {
+		if strings.HasPrefix(path.Clean(header.Name), pupBundleStoreDir+"/") {
+			hasStore = true
+		}
+		return nil
+	})
+	if err != nil || !hasStore {
+		return false, err
+	}
+	if err := extractPupBundleDir(bundlePath, pupBundleStoreDir, storeDir); err != nil {
+		return false, fmt.Errorf("failed to unpack pup bundle: %w", err)
+	}
+	return true, nil
+}
+
+// Unpacks the bundle's dir into dest.
+func extractPupBundleDir(bundlePath string, dir string, dest string) error {
+	f, err := os.Open(bundlePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("pup bundle isn't a gzipped tarball: %w", err)
+	}
+	defer gz.Close()
+
+	return tarball.Extract(tar.NewReader(gz), dest, tarball.Options{
+		Name: func(header *tar.Header, name string) (string, error) {
+			if !strings.HasPrefix(name, dir+"/") {
+				return "", nil
+			}
+			return strings.TrimPrefix(name, dir+"/"), nil
+		},
+	})
+}
+
+// Calls fn with each file in the bundle at path until it returns done.
+func walkPupBundle(path string, fn func(name string, r io.Reader) (done bool, err error)) error {
+	errDone := errors.New("done")
+	err := walkPupBundleEntries(path, func(header *tar.Header, r io.Reader) error {
+		if header.Typeflag != tar.TypeReg {
+			return nil
+		}
+		done, err := fn(strings.TrimPrefix(header.Name, "./"), r)
+		if err == nil && done {
+			return errDone
+		}
+		return err
+	})
+	if err == errDone {
+		return nil
+	}
+	return err
+}
+
+func walkPupBundleEntries(path string, fn func(header *tar.Header, r io.Reader) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ErrPupBundleNotFound
+		}
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("pup bundle isn't a gzipped tarball: %w", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read pup bundle: %w", err)
+		}
+		if err := fn(header, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// GetPupBundle describes the uploaded bundle with bundleID.
+func GetPupBundle(config ServerConfig, bundleID string) (PupBundleInfo, error) {
+	if err := ValidatePupBundleID(bundleID); err != nil {
+		return PupBundleInfo{}, err
+	}
+
+	path := config.PupBundlePath(bundleID)
+	info, err := os.Stat(path)
+	if err != nil {
+		return PupBundleInfo{}, ErrPupBundleNotFound
+	}
+	bundle, manifest, err := ReadPupBundle(path)
+	if err != nil {
+		return PupBundleInfo{}, err
+	}
+
+	return PupBundleInfo{ID: bundleID, Size: info.Size(), Bundle: bundle, Manifest: manifest}, nil
+}
+
+func DeletePupBundle(config ServerConfig, bundleID string) error {
+	if err := ValidatePupBundleID(bundleID); err != nil {
+		return err
+	}
+	if err := os.Remove(config.PupBundlePath(bundleID)); err != nil {
+		if os.IsNotExist(err) {
+			return ErrPupBundleNotFound
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package dogeboxd
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type bundleTestEntry struct {
+	name    string
+	content string
+	flag    byte
+}
+
+func writeTestPupBundle(t *testing.T, path string, entries []bundleTestEntry) {
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		flag := e.flag
+		if flag == 0 {
+			flag = tar.TypeReg
+		}
+		header := &tar.Header{Name: e.name, Mode: 0644, Size: int64(len(e.content)), Typeflag: flag}
+		if flag != tar.TypeReg {
+			header.Size = 0
+			header.Linkname = e.content
+		}
+		require.NoError(t, tw.WriteHeader(header))
+		if flag == tar.TypeReg {
+			_, err := tw.Write([]byte(e.content))
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+}
+
+func testPupBundleFiles(t *testing.T, version string) []bundleTestEntry {
+	bundle, err := json.Marshal(PupBundle{
+		FormatVersion: PUP_BUNDLE_FORMAT_VERSION,
+		PupName:       "Test Pup",
+		PupVersion:    "1.0.0",
+		Provenance:    PupProvenance{SourceLocation: "https://github.com/dogeorg/pups.git", SourceName: "Official", SourceType: "git"},
+		StorePaths:    []string{"/nix/store/abc-test-pup"},
+	})
+	require.NoError(t, err)
+	manifest, err := json.Marshal(PupManifest{
+		ManifestVersion: 1,
+		Meta:            PupManifestMeta{Name: "Test Pup", Version: version},
+		Container:       PupManifestContainer{Build: PupManifestBuild{NixFile: "pup.nix", NixFileSha256: "abc"}},
+	})
+	require.NoError(t, err)
+
+	return []bundleTestEntry{
+		{name: "bundle.json", content: string(bundle)},
+		{name: "pup/", flag: tar.TypeDir},
+		{name: "pup/manifest.json", content: string(manifest)},
+		{name: "pup/pup.nix", content: "{ }"},
+		{name: "pup/assets/logo.png", content: "logo"},
+		{name: "store/nix-cache-info", content: "StoreDir: /nix/store"},
+	}
+}
+
+func TestReadAndExtractPupBundle(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "bundle.tar.gz")
+	writeTestPupBundle(t, path, testPupBundleFiles(t, "1.0.0"))
+
+	bundle, manifest, err := ReadPupBundle(path)
+	require.NoError(t, err)
+	assert.Equal(t, "Test Pup", bundle.PupName)
+	assert.Equal(t, "git", bundle.Provenance.SourceType)
+	assert.Equal(t, "1.0.0", manifest.Meta.Version)
+
+	pupDir, storeDir := filepath.Join(dir, "pup"), filepath.Join(dir, "store")
+	hasStore, err := ExtractPupBundle(path, pupDir, storeDir)
+	require.NoError(t, err)
+	assert.True(t, hasStore)
+	assert.FileExists(t, filepath.Join(pupDir, "manifest.json"))
+	assert.FileExists(t, filepath.Join(pupDir, "assets", "logo.png"))
+	assert.FileExists(t, filepath.Join(storeDir, "nix-cache-info"))
+	assert.NoFileExists(t, filepath.Join(pupDir, "bundle.json"))
+}
+
+func TestReadPupBundleRejects(t *testing.T) {
+	tests := map[string][]bundleTestEntry{
+		"version mismatch": testPupBundleFiles(t, "2.0.0"),
+		"no bundle.json":   testPupBundleFiles(t, "1.0.0")[1:],
+		"not a tarball":    nil,
+	}
+
+	for name, entries := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "bundle.tar.gz")
+			if entries == nil {
+				require.NoError(t, os.WriteFile(path, []byte("nope"), 0644))
+			} else {
+				writeTestPupBundle(t, path, entries)
+			}
+			_, _, err := ReadPupBundle(path)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestExtractPupBundleRejectsEscapes(t *testing.T) {
+	tests := map[string]bundleTestEntry{
+		"traversal": {name: "pup/../../evil", content: "x"},
+		"symlink":   {name: "pup/link", content: "/etc/passwd", flag: tar.TypeSymlink},
+	}
+
+	for name, entry := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "bundle.tar.gz")
+			writeTestPupBundle(t, path, append(testPupBundleFiles(t, "1.0.0"), entry))
+
+			_, err := ExtractPupBundle(path, filepath.Join(dir, "pup"), filepath.Join(dir, "store"))
+			assert.Error(t, err)
+			assert.NoFileExists(t, filepath.Join(dir, "..", "evil"))
+		})
+	}
+}
+
+func TestPupBundleValidate(t *testing.T) {
+	valid := PupBundle{FormatVersion: 1, PupName: "Test Pup", PupVersion: "1.0.0", Provenance: PupProvenance{SourceLocation: "https://example.com/pups.git"}}
+	require.NoError(t, valid.Validate())
+
+	noSource := valid
+	noSource.Provenance.SourceLocation = ""
+	assert.Error(t, noSource.Validate())
+
+	badPath := valid
+	badPath.StorePaths = []string{"/etc/passwd"}
+	assert.Error(t, badPath.Validate())
+
+	future := valid
+	future.FormatVersion = PUP_BUNDLE_FORMAT_VERSION + 1
+	assert.Error(t, future.Validate())
+}
+
+func TestReconnectBundledPups(t *testing.T) {
+	bundle := PupBundle{Provenance: PupProvenance{SourceLocation: "https://github.com/dogeorg/pups.git", SourceName: "Official"}}
+	standIn := bundle.StandInSource().Config()
+	assert.Equal(t, SOURCE_TYPE_BUNDLE, standIn.Type)
+
+	m := &canaryPupManager{states: map[string]PupState{
+		"bundled": {ID: "bundled", Source: standIn, Provenance: &bundle.Provenance},
+		"other":   {ID: "other", Source: ManifestSourceConfiguration{ID: "src2", Type: "git", Location: "https://example.com/other.git"}},
+	}}
+	assert.False(t, m.states["bundled"].ChecksForUpdates())
+
+	reconnected, err := ReconnectBundledPups(m, ManifestSourceConfiguration{ID: "src3", Type: "git", Location: "https://example.com/else.git"})
+	require.NoError(t, err)
+	assert.Empty(t, reconnected)
+
+	source := ManifestSourceConfiguration{ID: "src1", Name: "Official", Type: "git", Location: "https://github.com/dogeorg/pups.git"}
+	reconnected, err = ReconnectBundledPups(m, source)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bundled"}, reconnected)
+	assert.Equal(t, source, m.states["bundled"].Source)
+	assert.True(t, m.states["bundled"].ChecksForUpdates())
+	assert.NotNil(t, m.states["bundled"].Provenance)
+}
@@ -0,0 +1,113 @@
+package dogeboxd
+
+import (
+	"fmt"
+	"time"
+)
+
+/*
+ * A canary upgrade is an UpgradePup with CanaryMinutes set. Once the
+ * upgrade is done the pup's health is watched, and unless it stays ready
+ * (running and passing its health check) for that long, it's rolled back
+ * to the snapshot taken before the upgrade, see RollbackPupUpgrade.
+ *
+ * With UpgradeInstances set, a pup installed more than once is upgraded
+ * in stages: one instance first, then the rest once it passes.
+ */
+
+// The longest a canary upgrade can be watched for.
+const MaxPupCanaryMinutes = 24 * 60
+
+// How long an upgraded pup has to first become ready before its canary
+// fails, as some pups take a while to start.
+const PupCanaryStartupTimeout = DependentRestartReadyTimeout
+
+const (
+	PUP_CANARY_WATCHING    = "watching"
+	PUP_CANARY_PASSED      = "passed"
+	PUP_CANARY_ROLLED_BACK = "rolled-back"
+	// The pup was stopped, put in maintenance or changed version while
+	// being watched, so there's nothing left to judge.
+	PUP_CANARY_ABANDONED = "abandoned"
+)
+
+type PupCanary struct {
+	// The upgrade job being watched.
+	JobID       string    `json:"jobId"`
+	FromVersion string    `json:"fromVersion"`
+	ToVersion   string    `json:"toVersion"`
+	Minutes     int       `json:"minutes"`
+	Started     time.Time `json:"started"`
+	// When the pup last became ready, nil while it isn't.
+	HealthySince *time.Time `json:"healthySince,omitempty"`
+	// Other instances of the pup to upgrade once this one passes.
+	ThenUpgrade []string   `json:"thenUpgrade,omitempty"`
+	Status      string     `json:"status"`
+	Reason      string     `json:"reason,omitempty"`
+	Finished    *time.Time `json:"finished,omitempty"`
+}
+
+func ValidatePupCanaryMinutes(minutes int) error {
+	if minutes < 0 || minutes > MaxPupCanaryMinutes {
+		return fmt.Errorf("canaryMinutes must be between 0 and %d", MaxPupCanaryMinutes)
+	}
+	return nil
+}
+
+/* Next works out where a watched canary is now, given the pup's state and
+ * whether it's ready, returning false if nothing has changed. It fails as
+ * soon as a pup that had become ready stops being so, as the health
+ * checker already allows for the odd failed check.
+ */
+func (c PupCanary) Next(p PupState, ready bool, now time.Time) (PupCanary, bool) {
+	if c.Status != PUP_CANARY_WATCHING {
+		return c, false
+	}
+
+	finish := func(status string, reason string) (PupCanary, bool) {
+		c.Status = status
+		c.Reason = reason
+		c.Finished = &now
+		return c, true
+	}
+
+	switch {
+	case p.Version != c.ToVersion:
+		return finish(PUP_CANARY_ABANDONED, fmt.Sprintf("now at version %s", p.Version))
+	case !p.Enabled:
+		return finish(PUP_CANARY_ABANDONED, "stopped")
+	case p.InMaintenance():
+		return finish(PUP_CANARY_ABANDONED, "put in maintenance")
+	}
+
+	if !ready {
+		if c.HealthySince != nil {
+			return finish(PUP_CANARY_ROLLED_BACK, fmt.Sprintf("stopped passing its health checks after %s", now.Sub(*c.HealthySince).Round(time.Second)))
+		}
+		if now.Sub(c.Started) > PupCanaryStartupTimeout {
+			return finish(PUP_CANARY_ROLLED_BACK, fmt.Sprintf("didn't pass its health checks within %s of upgrading", PupCanaryStartupTimeout))
+		}
+		return c, false
+	}
+
+	if c.HealthySince == nil {
+		c.HealthySince = &now
+		return c, true
+	}
+	if now.Sub(*c.HealthySince) >= time.Duration(c.Minutes)*time.Minute {
+		return finish(PUP_CANARY_PASSED, "")
+	}
+	return c, false
+}
+
+// Sets (or clears, with nil) the canary watching a pup's last upgrade.
+func PupCanaryWatch(canary *PupCanary) func(*PupState, *[]Pupdate) {
+	return func(p *PupState, pu *[]Pupdate) {
+		p.Canary = canary
+		*pu = append(*pu, Pupdate{
+			ID:    p.ID,
+			Event: PUP_CHANGED_INSTALLATION,
+			State: *p,
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package dogeboxd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func canaryTestPup(version string) PupState {
+	return PupState{ID: "pup-1", Version: version, Enabled: true}
+}
+
+func watchingCanary(started time.Time) PupCanary {
+	return PupCanary{FromVersion: "1.0.0", ToVersion: "1.1.0", Minutes: 30, Started: started, Status: PUP_CANARY_WATCHING}
+}
+
+func TestPupCanaryPassesAfterStayingHealthy(t *testing.T) {
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	p := canaryTestPup("1.1.0")
+	c := watchingCanary(start)
+
+	// Not up yet, but still within the startup timeout.
+	c, changed := c.Next(p, false, start.Add(2*time.Minute))
+	assert.False(t, changed)
+
+	c, changed = c.Next(p, true, start.Add(3*time.Minute))
+	require.True(t, changed)
+	require.NotNil(t, c.HealthySince)
+	assert.Equal(t, start.Add(3*time.Minute), *c.HealthySince)
+
+	c, changed = c.Next(p, true, start.Add(32*time.Minute))
+	assert.False(t, changed)
+	assert.Equal(t, PUP_CANARY_WATCHING, c.Status)
+
+	c, changed = c.Next(p, true, start.Add(33*time.Minute))
+	require.True(t, changed)
+	assert.Equal(t, PUP_CANARY_PASSED, c.Status)
+	require.NotNil(t, c.Finished)
+
+	// Finished canaries stay finished.
+	_, changed = c.Next(p, false, start.Add(40*time.Minute))
+	assert.False(t, changed)
+}
+
+func TestPupCanaryFails(t *testing.T) {
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	p := canaryTestPup("1.1.0")
+
+	// Never became ready.
+	c, changed := watchingCanary(start).Next(p, false, start.Add(PupCanaryStartupTimeout+time.Minute))
+	require.True(t, changed)
+	assert.Equal(t, PUP_CANARY_ROLLED_BACK, c.Status)
+	assert.Contains(t, c.Reason, "within")
+
+	// Became ready, then stopped being so.
+	c, _ = watchingCanary(start).Next(p, true, start.Add(time.Minute))
+	c, changed = c.Next(p, false, start.Add(11*time.Minute))
+	require.True(t, changed)
+	assert.Equal(t, PUP_CANARY_ROLLED_BACK, c.Status)
+	assert.Contains(t, c.Reason, "after 10m0s")
+}
+
+func TestPupCanaryIsAbandoned(t *testing.T) {
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	stopped := canaryTestPup("1.1.0")
+	stopped.Enabled = false
+	maintenance := canaryTestPup("1.1.0")
+	maintenance.Maintenance = &PupMaintenance{}
+
+	for name, p := range map[string]PupState{
+		"stopped":     stopped,
+		"maintenance": maintenance,
+		"upgraded":    canaryTestPup("1.2.0"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			c, changed := watchingCanary(start).Next(p, false, start.Add(time.Minute))
+			require.True(t, changed)
+			assert.Equal(t, PUP_CANARY_ABANDONED, c.Status)
+		})
+	}
+}
+
+func TestValidatePupCanaryMinutes(t *testing.T) {
+	assert.NoError(t, ValidatePupCanaryMinutes(0))
+	assert.NoError(t, ValidatePupCanaryMinutes(60))
+	assert.Error(t, ValidatePupCanaryMinutes(-1))
+	assert.Error(t, ValidatePupCanaryMinutes(MaxPupCanaryMinutes+1))
+	assert.ErrorContains(t, PupAutoUpdate{Policy: AUTO_UPDATE_PATCH, CanaryMinutes: -5}.Validate(), "canaryMinutes")
+}
+
+type canaryPupManager struct {
+	PupManager
+	states map[string]PupState
+	ready  map[string]bool
+}
+
+func (m *canaryPupManager) GetStateMap() map[string]PupState { return m.states }
+func (m *canaryPupManager) IsPupReady(id string) bool        { return m.ready[id] }
+
+func (m *canaryPupManager) UpdatePup(id string, updates ...func(*PupState, *[]Pupdate)) (PupState, error) {
+	p := m.states[id]
+	for _, u := range updates {
+		u(&p, &[]Pupdate{})
+	}
+	m.states[id] = p
+	return p, nil
+}
+
+func TestCheckPupCanaries(t *testing.T) {
+	start := time.Now().Add(-time.Hour)
+	healthySince := start.Add(time.Minute)
+
+	passing := watchingCanary(start)
+	passing.HealthySince = &healthySince
+	passing.ThenUpgrade = []string{"instance-2"}
+	failing := watchingCanary(start)
+	failing.HealthySince = &healthySince
+
+	pups := &canaryPupManager{
+		states: map[string]PupState{
+			"passing": {ID: "passing", Version: "1.1.0", Enabled: true, Canary: &passing},
+			"failing": {ID: "failing", Version: "1.1.0", Enabled: true, Canary: &failing},
+			"other":   {ID: "other", Version: "1.0.0", Enabled: true},
+		},
+		ready: map[string]bool{"passing": true},
+	}
+	dbx := Dogeboxd{Pups: pups, jobs: make(chan Job, 10)}
+
+	dbx.checkPupCanaries(time.Now())
+
+	assert.Equal(t, PUP_CANARY_PASSED, pups.states["passing"].Canary.Status)
+	assert.Equal(t, PUP_CANARY_ROLLED_BACK, pups.states["failing"].Canary.Status)
+	assert.Nil(t, pups.states["other"].Canary)
+
+	queued := map[string]Action{}
+	for len(dbx.jobs) > 0 {
+		j := <-dbx.jobs
+		queued[j.A.ActionName()] = j.A
+	}
+	require.Len(t, queued, 2)
+	rollback := queued["rollback"].(RollbackPupUpgrade)
+	assert.Equal(t, "failing", rollback.PupID)
+	assert.Contains(t, rollback.Reason, "stopped passing its health checks")
+	assert.Equal(t, UpgradePup{PupID: "instance-2", TargetVersion: "1.1.0", CanaryMinutes: 30}, queued["upgrade"])
+}
+
+func TestFindOtherPupInstances(t *testing.T) {
+	pups := map[string]PupState{
+		"a": instanceTestPup("a", "Dogecoin Core", "1.0.0", "src", ""),
+		"b": instanceTestPup("b", "Dogecoin Core", "1.0.0", "src", "testnet"),
+		"c": instanceTestPup("c", "Dogecoin Core", "1.0.0", "other-src", "mine"),
+		"d": instanceTestPup("d", "Other Pup", "1.0.0", "src", ""),
+	}
+	assert.Equal(t, []string{"b"}, FindOtherPupInstances(pups, "a"))
+	assert.Empty(t, FindOtherPupInstances(pups, "missing"))
+}
@@ -0,0 +1,81 @@
+package dogeboxd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+/* A pup that can pick up config changes without restarting declares how
+ * to tell it about them in its manifest, either by signalling one of its
+ * services or by running a command in its container, ie:
+ *
+ *	"config": { "reload": { "service": "core", "signal": "SIGHUP" } }
+ *	"config": { "reload": { "command": "core-cli reload-config" } }
+ *
+ * Services only get config.env in their environment when they start, so
+ * the pup has to re-read /storage/.dbx/config.env itself when told to.
+ * A reload that fails falls back to restarting the container. Pups
+ * without a reload pick up config changes when they're next started.
+ */
+type PupManifestConfigReload struct {
+	// Signal the main process of Service.
+	Service string `json:"service,omitempty"`
+	Signal  string `json:"signal,omitempty"`
+	// Or run a shell command in the container, which must exit 0.
+	Command string `json:"command,omitempty"`
+	// Seconds the command may run for.
+	Timeout int `json:"timeout,omitempty"`
+}
+
+const (
+	DefaultConfigReloadTimeout = 30 * time.Second
+	MaxConfigReloadTimeout     = 5 * time.Minute
+)
+
+var configReloadSignals = map[string]bool{"SIGHUP": true, "SIGUSR1": true, "SIGUSR2": true}
+
+func (r PupManifestConfigReload) Validate(services []PupManifestService) error {
+	switch {
+	case r.Signal != "" && r.Command != "":
+		return fmt.Errorf("config reload takes a signal or a command, not both")
+	case r.Signal != "":
+		if !configReloadSignals[r.Signal] {
+			return fmt.Errorf("config reload signal %q isn't one of SIGHUP, SIGUSR1 or SIGUSR2", r.Signal)
+		}
+		found := false
+		for _, s := range services {
+			found = found || s.Name == r.Service
+		}
+		if !found {
+			return fmt.Errorf("config reload service %q isn't one of the pup's services", r.Service)
+		}
+	case r.Command != "":
+		if len(r.Command) > MaxHealthCheckCommandLen || strings.ContainsAny(r.Command, "\r\n\x00") {
+			return fmt.Errorf("config reload command must be a single line of at most %d characters", MaxHealthCheckCommandLen)
+		}
+		if r.Service != "" {
+			return fmt.Errorf("config reload service is only used with a signal")
+		}
+	default:
+		return fmt.Errorf("config reload needs a signal or a command")
+	}
+
+	if r.Timeout < 0 || r.TimeoutDuration() > MaxConfigReloadTimeout {
+		return fmt.Errorf("config reload timeout must be at most %s", MaxConfigReloadTimeout)
+	}
+	return nil
+}
+
+func (r PupManifestConfigReload) TimeoutDuration() time.Duration {
+	if r.Timeout == 0 {
+		return DefaultConfigReloadTimeout
+	}
+	return time.Duration(r.Timeout) * time.Second
+}
+
+// SupportsConfigReload is whether p can pick up a new config.env without
+// its container being restarted.
+func (p PupState) SupportsConfigReload() bool {
+	return p.Manifest.Config.Reload != nil
+}
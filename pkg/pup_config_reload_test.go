@@ -0,0 +1,28 @@
+package dogeboxd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPupManifestConfigReloadValidate(t *testing.T) {
+	services := []PupManifestService{{Name: "core"}}
+
+	assert.NoError(t, PupManifestConfigReload{Service: "core", Signal: "SIGHUP"}.Validate(services))
+	assert.NoError(t, PupManifestConfigReload{Command: "core-cli reload-config", Timeout: 60}.Validate(services))
+
+	assert.Error(t, PupManifestConfigReload{}.Validate(services))
+	assert.Error(t, PupManifestConfigReload{Service: "core", Signal: "SIGKILL"}.Validate(services))
+	assert.Error(t, PupManifestConfigReload{Service: "other", Signal: "SIGHUP"}.Validate(services))
+	assert.Error(t, PupManifestConfigReload{Service: "core", Signal: "SIGHUP", Command: "true"}.Validate(services))
+	assert.Error(t, PupManifestConfigReload{Service: "core", Command: "true"}.Validate(services))
+	assert.Error(t, PupManifestConfigReload{Command: "true\nreboot"}.Validate(services))
+	assert.Error(t, PupManifestConfigReload{Command: "true", Timeout: 3600}.Validate(services))
+}
+
+func TestPupManifestConfigReloadTimeout(t *testing.T) {
+	assert.Equal(t, DefaultConfigReloadTimeout, PupManifestConfigReload{Command: "true"}.TimeoutDuration())
+	assert.Equal(t, time.Minute, PupManifestConfigReload{Command: "true", Timeout: 60}.TimeoutDuration())
+}
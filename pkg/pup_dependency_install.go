@@ -0,0 +1,215 @@
+package dogeboxd
+
+import (
+	"maps"
+	"slices"
+	"strings"
+)
+
+// MissingPupDependency is a required interface that no installed pup
+// provides, and the pup from a source that would.
+type MissingPupDependency struct {
+	Interfaces []string                    `json:"interfaces"`
+	Provider   PupManifestDependencySource `json:"provider"`
+	SourceID   string                      `json:"sourceId"`
+}
+
+// MissingPupDependencies is sent as a "pup-dependencies-missing" Change
+// when a pup is installed without AdoptPupOptions.InstallDependencies and
+// needs providers installed, so the dPanel can offer to install them.
+type MissingPupDependencies struct {
+	PupID        string                 `json:"pupId"`
+	JobID        string                 `json:"jobId"`
+	Dependencies []MissingPupDependency `json:"dependencies"`
+}
+
+/* FindMissingDependencies picks a provider to install for each required
+ * dependency in reports that nothing installed provides, preferring the
+ * manifest's default source, then the newest installable provider.
+ * sourceIDs maps the location of each source on this box to its ID, as
+ * providers can only be installed from those. Interfaces the same pup
+ * provides are grouped, and dependencies nothing can provide are left
+ * out, leaving the pup needing deps as before.
+ */
+func FindMissingDependencies(reports []PupDependencyReport, sourceIDs map[string]string) []MissingPupDependency {
+	missing := []MissingPupDependency{}
+	for _, dep := range reports {
+		if dep.Optional || dep.CurrentProvider != "" || len(dep.InstalledProviders) > 0 {
+			continue
+		}
+		provider, ok := chooseDependencyProvider(dep, sourceIDs)
+		if !ok {
+			continue
+		}
+
+		grouped := false
+		for i, m := range missing {
+			if m.Provider.SourceLocation == provider.SourceLocation && m.Provider.PupName == provider.PupName {
+				missing[i].Interfaces = append(missing[i].Interfaces, dep.Interface)
+				grouped = true
+				break
+			}
+		}
+		if !grouped {
+			missing = append(missing, MissingPupDependency{
+				Interfaces: []string{dep.Interface},
+				Provider:   provider,
+				SourceID:   sourceIDs[provider.SourceLocation],
+			})
+		}
+	}
+	return missing
+}
+
+func chooseDependencyProvider(dep PupDependencyReport, sourceIDs map[string]string) (PupManifestDependencySource, bool) {
+	// InstallableProviders are sorted newest first.
+	def := dep.DefaultSourceProvider
+	if def.PupName != "" {
+		for _, p := range dep.InstallableProviders {
+			if p.SourceLocation == def.SourceLocation && p.PupName == def.PupName &&
+				(def.PupVersion == "" || p.PupVersion == def.PupVersion) {
+				if _, ok := sourceIDs[p.SourceLocation]; ok {
+					return p, true
+				}
+			}
+		}
+	}
+	for _, p := range dep.InstallableProviders {
+		if _, ok := sourceIDs[p.SourceLocation]; ok {
+			return p, true
+		}
+	}
+	return PupManifestDependencySource{}, false
+}
+
+/* installMissingDependencies looks for required interfaces of a newly
+ * adopted pup that nothing installed provides. With InstallDependencies
+ * set their providers are queued as InstallPup jobs of their own, which
+ * set themselves as the pup's provider once they're installed, otherwise
+ * they're only offered with a "pup-dependencies-missing" Change.
+ */
+func (t *Dogeboxd) installMissingDependencies(j Job, pupID string, a InstallPup) {
+	log := j.Logger.Step("dependencies")
+	reports, err := t.Pups.CalculateDeps(pupID)
+	if err != nil {
+		log.Errf("Couldn't check dependencies: %s", err)
+		return
+	}
+
+	sourceIDs := map[string]string{}
+	for _, c := range t.sources.GetAllSourceConfigurations() {
+		sourceIDs[c.Location] = c.ID
+	}
+
+	missing := FindMissingDependencies(reports, sourceIDs)
+	if len(missing) == 0 {
+		return
+	}
+
+	if !a.Options.InstallDependencies {
+		for _, m := range missing {
+			log.Logf("%s can be provided by installing %s %s", strings.Join(m.Interfaces, ", "), m.Provider.PupName, m.Provider.PupVersion)
+		}
+		t.SendChange(Change{ID: "internal", Type: "pup-dependencies-missing", Update: MissingPupDependencies{
+			PupID:        pupID,
+			JobID:        j.ID,
+			Dependencies: missing,
+		}})
+		return
+	}
+
+	for _, m := range missing {
+		if t.hasQueuedInstall(m.Provider.PupName, m.SourceID) {
+			log.Logf("%s is already being installed, it'll be set as the provider of %s once it is", m.Provider.PupName, strings.Join(m.Interfaces, ", "))
+			continue
+		}
+		log.Logf("Queueing install of %s %s to provide %s", m.Provider.PupName, m.Provider.PupVersion, strings.Join(m.Interfaces, ", "))
+		t.AddActionAs(InstallPup{
+			PupName:           m.Provider.PupName,
+			PupVersion:        m.Provider.PupVersion,
+			SourceId:          m.SourceID,
+			Options:           AdoptPupOptions{InstallDependencies: true},
+			SessionToken:      a.SessionToken,
+			ProvideFor:        pupID,
+			ProvideInterfaces: m.Interfaces,
+			ParentJobID:       j.ID,
+		}, j.Actor)
+	}
+}
+
+// hasQueuedInstall reports whether an InstallPup for the pup is already
+// queued or running, ie: two pups in an InstallPups batch needing the
+// same provider.
+func (t *Dogeboxd) hasQueuedInstall(pupName, sourceID string) bool {
+	t.queue.jobQLock.Lock()
+	defer t.queue.jobQLock.Unlock()
+
+	jobs := t.queue.jobQueue
+	if t.queue.currentSystemJob != nil {
+		jobs = append([]Job{*t.queue.currentSystemJob}, jobs...)
+	}
+	for _, j := range jobs {
+		if a, ok := j.A.(InstallPup); ok && a.PupName == pupName && a.SourceId == sourceID && a.Options.InstanceName == "" {
+			return true
+		}
+	}
+	return false
+}
+
+/* PupsMissingProvider finds the required interfaces providerID can
+ * provide that have no provider set, for each pup in reports. A
+ * provider installed as a dependency is only queued once for a batch,
+ * so this is how every pup that wanted it gets it, not just the first.
+ */
+func PupsMissingProvider(reports map[string][]PupDependencyReport, providerID string) map[string][]string {
+	missing := map[string][]string{}
+	for pupID, deps := range reports {
+		if pupID == providerID {
+			continue
+		}
+		for _, dep := range deps {
+			if dep.Optional || dep.CurrentProvider != "" {
+				continue
+			}
+			for _, id := range dep.InstalledProviders {
+				if id == providerID {
+					missing[pupID] = append(missing[pupID], dep.Interface)
+					break
+				}
+			}
+		}
+	}
+	return missing
+}
+
+// setInstalledProvider makes a just installed dependency the provider
+// of the pup it was installed for, and of any other pup still missing
+// one of the interfaces it provides.
+func (t *Dogeboxd) setInstalledProvider(j Job, a InstallPup) {
+	reports := map[string][]PupDependencyReport{}
+	for pupID := range t.Pups.GetStateMap() {
+		deps, err := t.Pups.CalculateDeps(pupID)
+		if err != nil {
+			continue
+		}
+		reports[pupID] = deps
+	}
+
+	missing := PupsMissingProvider(reports, j.State.ID)
+	for _, iface := range a.ProvideInterfaces {
+		if !slices.Contains(missing[a.ProvideFor], iface) {
+			missing[a.ProvideFor] = append(missing[a.ProvideFor], iface)
+		}
+	}
+
+	log := j.Logger.Step("dependencies")
+	for _, pupID := range slices.Sorted(maps.Keys(missing)) {
+		ifaces := missing[pupID]
+		providers := map[string]string{}
+		for _, iface := range ifaces {
+			providers[iface] = j.State.ID
+		}
+		log.Logf("Setting %s as the provider of %s for %s", j.State.ID, strings.Join(ifaces, ", "), pupID)
+		t.AddActionAs(UpdatePupProviders{PupID: pupID, Payload: providers}, j.Actor)
+	}
+}
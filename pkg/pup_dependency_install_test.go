@@ -0,0 +1,69 @@
+package dogeboxd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindMissingDependenciesPrefersDefaultSource(t *testing.T) {
+	sourceIDs := map[string]string{"https://example.com/pups": "main", "https://example.com/other": "other"}
+	reports := []PupDependencyReport{
+		{
+			Interface: "core-rpc",
+			InstallableProviders: []PupManifestDependencySource{
+				{SourceLocation: "https://example.com/other", PupName: "core-fork", PupVersion: "2.0.0"},
+				{SourceLocation: "https://example.com/pups", PupName: "core", PupVersion: "1.1.0"},
+				{SourceLocation: "https://example.com/pups", PupName: "core", PupVersion: "1.0.0"},
+			},
+			DefaultSourceProvider: PupManifestDependencySource{SourceLocation: "https://example.com/pups", PupName: "core"},
+		},
+		{
+			Interface: "core-zmq",
+			InstallableProviders: []PupManifestDependencySource{
+				{SourceLocation: "https://example.com/pups", PupName: "core", PupVersion: "1.1.0"},
+			},
+		},
+	}
+
+	missing := FindMissingDependencies(reports, sourceIDs)
+	assert.Equal(t, []MissingPupDependency{{
+		Interfaces: []string{"core-rpc", "core-zmq"},
+		Provider:   PupManifestDependencySource{SourceLocation: "https://example.com/pups", PupName: "core", PupVersion: "1.1.0"},
+		SourceID:   "main",
+	}}, missing)
+}
+
+func TestFindMissingDependenciesSkipsProvidedAndOptional(t *testing.T) {
+	sourceIDs := map[string]string{"https://example.com/pups": "main"}
+	installable := []PupManifestDependencySource{{SourceLocation: "https://example.com/pups", PupName: "core", PupVersion: "1.0.0"}}
+	reports := []PupDependencyReport{
+		{Interface: "set", CurrentProvider: "abc", InstallableProviders: installable},
+		{Interface: "installed", InstalledProviders: []string{"abc"}, InstallableProviders: installable},
+		{Interface: "optional", Optional: true, InstallableProviders: installable},
+		// Only installable from a source this box doesn't have.
+		{Interface: "elsewhere", InstallableProviders: []PupManifestDependencySource{{SourceLocation: "https://example.com/gone", PupName: "x", PupVersion: "1.0.0"}}},
+		{Interface: "unprovided"},
+	}
+
+	assert.Empty(t, FindMissingDependencies(reports, sourceIDs))
+}
+
+func TestPupsMissingProviderWiresEveryRequester(t *testing.T) {
+	reports := map[string][]PupDependencyReport{
+		"pup-a": {{Interface: "core-rpc", InstalledProviders: []string{"core"}}},
+		"pup-b": {
+			{Interface: "core-rpc", InstalledProviders: []string{"core"}},
+			{Interface: "core-zmq", InstalledProviders: []string{"core"}},
+		},
+		// Already has a provider, or doesn't need one.
+		"pup-c": {{Interface: "core-rpc", CurrentProvider: "other-core", InstalledProviders: []string{"core", "other-core"}}},
+		"pup-d": {{Interface: "core-rpc", Optional: true, InstalledProviders: []string{"core"}}},
+		"pup-e": {{Interface: "other-rpc", InstalledProviders: []string{"other"}}},
+	}
+
+	assert.Equal(t, map[string][]string{
+		"pup-a": {"core-rpc"},
+		"pup-b": {"core-rpc", "core-zmq"},
+	}, PupsMissingProvider(reports, "core"))
+}
@@ -0,0 +1,154 @@
+package dogeboxd
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// How long dependents wait for an upgraded provider to become ready
+// before their restarts are given up on, see PupManager.IsPupReady.
+const DependentRestartReadyTimeout = 10 * time.Minute
+
+// Dependents of an upgraded provider, to be restarted once it's ready.
+type pendingDependentRestart struct {
+	providerID string
+	dependents []string
+	jobID      string
+	actor      string
+	deadline   time.Time
+}
+
+/* dependentRestarts holds restarts waiting on their provider outside the
+ * job queue, so a provider that's slow to become ready doesn't hold up
+ * every other job. checkDependentRestarts queues them once it's ready.
+ */
+type dependentRestarts struct {
+	mu      sync.Mutex
+	pending []pendingDependentRestart
+}
+
+func (r *dependentRestarts) add(p pendingDependentRestart) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pending = append(r.pending, p)
+}
+
+/* due removes and returns the restarts whose provider is ready, and
+ * drops those that can't be: the provider went into maintenance, was
+ * removed, or didn't become ready in time.
+ */
+func (r *dependentRestarts) due(states map[string]PupState, ready func(string) bool, now time.Time) []pendingDependentRestart {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	due := []pendingDependentRestart{}
+	waiting := r.pending[:0]
+	for _, p := range r.pending {
+		provider, ok := states[p.providerID]
+		switch {
+		case !ok:
+			fmt.Printf("Provider %s is gone, not restarting its dependents\n", p.providerID)
+		case provider.InMaintenance():
+			fmt.Printf("%s is in maintenance, not restarting its dependents\n", provider.DisplayName())
+		case ready(p.providerID):
+			due = append(due, p)
+		case !now.Before(p.deadline):
+			fmt.Printf("%s didn't become ready within %v, not restarting its dependents\n", provider.DisplayName(), DependentRestartReadyTimeout)
+		default:
+			waiting = append(waiting, p)
+		}
+	}
+	r.pending = waiting
+	return due
+}
+
+/* FindDependentPups returns the enabled pups that use providerID for any
+ * of their interface dependencies, in display name order so the restarts
+ * queued for them after a provider upgrade run in a predictable order.
+ * Pups in maintenance are left out, they're meant to stay stopped.
+ */
+func FindDependentPups(states map[string]PupState, providerID string) []string {
+	dependents := []PupState{}
+	for id, p := range states {
+		if id == providerID || !p.Enabled || p.InMaintenance() {
+			continue
+		}
+		for _, provider := range p.Providers {
+			if provider == providerID {
+				dependents = append(dependents, p)
+				break
+			}
+		}
+	}
+
+	sort.Slice(dependents, func(i, j int) bool {
+		if dependents[i].DisplayName() != dependents[j].DisplayName() {
+			return dependents[i].DisplayName() < dependents[j].DisplayName()
+		}
+		return dependents[i].ID < dependents[j].ID
+	})
+
+	ids := make([]string, 0, len(dependents))
+	for _, p := range dependents {
+		ids = append(ids, p.ID)
+	}
+	return ids
+}
+
+/* FindOrphanedProviders returns the pups that would provide for nothing
+ * once pupID is uninstalled: its providers that no other installed pup
+ * uses, then their providers in turn, sorted by ID. System managed pups
+ * are never orphaned. Offered as UninstallPup.RemoveProviders, as a pup
+ * providing for nothing may still be one the user wants.
+ */
+func FindOrphanedProviders(states map[string]PupState, pupID string) []string {
+	removing := map[string]bool{pupID: true}
+	for {
+		found := false
+		for id := range removing {
+			for _, provider := range states[id].Providers {
+				if removing[provider] || !isOrphanedProvider(states, provider, removing) {
+					continue
+				}
+				removing[provider] = true
+				found = true
+			}
+		}
+		if !found {
+			break
+		}
+	}
+
+	delete(removing, pupID)
+	orphans := make([]string, 0, len(removing))
+	for id := range removing {
+		orphans = append(orphans, id)
+	}
+	sort.Strings(orphans)
+	return orphans
+}
+
+// isOrphanedProvider reports whether provider is only used by pups being removed.
+func isOrphanedProvider(states map[string]PupState, provider string, removing map[string]bool) bool {
+	p, ok := states[provider]
+	if !ok || p.SystemManaged || isUninstalled(p) {
+		return false
+	}
+	for id, s := range states {
+		if removing[id] || isUninstalled(s) {
+			continue
+		}
+		for _, used := range s.Providers {
+			if used == provider {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func isUninstalled(p PupState) bool {
+	return p.Installation == STATE_UNINSTALLING || p.Installation == STATE_UNINSTALLED || p.Installation == STATE_PURGING
+}
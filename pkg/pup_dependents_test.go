@@ -0,0 +1,86 @@
+package dogeboxd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindDependentPups(t *testing.T) {
+	named := func(id, name string, enabled bool, providers map[string]string) PupState {
+		return PupState{ID: id, Enabled: enabled, Providers: providers, Manifest: PupManifest{Meta: PupManifestMeta{Name: name}}}
+	}
+	states := map[string]PupState{
+		"core":     named("core", "Dogecoin Core", true, nil),
+		"explorer": named("explorer", "Explorer", true, map[string]string{"core-rpc": "core", "index": "other"}),
+		"dogenet":  named("dogenet", "DogeNet", true, map[string]string{"core-zmq": "core"}),
+		"stopped":  named("stopped", "Stopped", false, map[string]string{"core-rpc": "core"}),
+		"other":    named("other", "Other", true, map[string]string{"something": "elsewhere"}),
+	}
+	maintenance := named("maintenance", "Maintenance", true, map[string]string{"core-rpc": "core"})
+	maintenance.Maintenance = &PupMaintenance{}
+	states["maintenance"] = maintenance
+
+	assert.Equal(t, []string{"dogenet", "explorer"}, FindDependentPups(states, "core"))
+	assert.Empty(t, FindDependentPups(states, "explorer"))
+}
+
+func TestFindOrphanedProviders(t *testing.T) {
+	using := func(id string, providers map[string]string) PupState {
+		return PupState{ID: id, Installation: STATE_READY, Providers: providers}
+	}
+	states := map[string]PupState{
+		"explorer": using("explorer", map[string]string{"index": "indexer", "core-rpc": "core"}),
+		"indexer":  using("indexer", map[string]string{"core-rpc": "core", "db": "postgres"}),
+		"core":     using("core", nil),
+		"postgres": using("postgres", nil),
+		"wallet":   using("wallet", map[string]string{"core-rpc": "core"}),
+	}
+
+	// core is still used by wallet.
+	assert.Equal(t, []string{"indexer", "postgres"}, FindOrphanedProviders(states, "explorer"))
+
+	wallet := states["wallet"]
+	wallet.Installation = STATE_UNINSTALLED
+	states["wallet"] = wallet
+	assert.Equal(t, []string{"core", "indexer", "postgres"}, FindOrphanedProviders(states, "explorer"))
+
+	core := states["core"]
+	core.SystemManaged = true
+	states["core"] = core
+	assert.Equal(t, []string{"indexer", "postgres"}, FindOrphanedProviders(states, "explorer"))
+
+	assert.Empty(t, FindOrphanedProviders(states, "postgres"))
+}
+
+func TestDependentRestartsWaitForProvider(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	states := map[string]PupState{
+		"core":  {ID: "core", Enabled: true},
+		"maint": {ID: "maint", Enabled: true, Maintenance: &PupMaintenance{}},
+		"slow":  {ID: "slow", Enabled: true},
+	}
+	ready := map[string]bool{}
+	isReady := func(id string) bool { return ready[id] }
+
+	r := &dependentRestarts{}
+	r.add(pendingDependentRestart{providerID: "core", dependents: []string{"dogenet"}, deadline: now.Add(time.Minute)})
+	r.add(pendingDependentRestart{providerID: "maint", dependents: []string{"explorer"}, deadline: now.Add(time.Minute)})
+	r.add(pendingDependentRestart{providerID: "slow", dependents: []string{"wallet"}, deadline: now.Add(time.Minute)})
+	r.add(pendingDependentRestart{providerID: "gone", dependents: []string{"other"}, deadline: now.Add(time.Minute)})
+
+	// Providers in maintenance or removed are given up on straight away.
+	assert.Empty(t, r.due(states, isReady, now))
+	assert.Len(t, r.pending, 2)
+
+	ready["core"] = true
+	due := r.due(states, isReady, now)
+	require.Len(t, due, 1)
+	assert.Equal(t, []string{"dogenet"}, due[0].dependents)
+
+	// slow never becomes ready, so is dropped at its deadline.
+	assert.Empty(t, r.due(states, isReady, now.Add(time.Minute)))
+	assert.Empty(t, r.pending)
+}
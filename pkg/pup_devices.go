@@ -0,0 +1,194 @@
+package dogeboxd
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+)
+
+const MAX_PUP_DEVICES = 16
+
+var devicePathRegex = regexp.MustCompile(`^/dev/[A-Za-z0-9._:+/-]+$`)
+
+// A deviceKind is a kind of host device a pup may ask for. Anything else,
+// ie. disks or raw memory, can't be passed through, whatever the user
+// approves.
+type deviceKind struct {
+	path *regexp.Regexp
+	// The systemd device class a pup may ask for instead of the device,
+	// for devices that come and go, "" if it can't.
+	class string
+	// What the device is, shown to the user so a pup can't pass one off
+	// as another.
+	description string
+}
+
+var deviceKinds = []deviceKind{
+	{regexp.MustCompile(`^/dev/dri(/(card|renderD)[0-9]+)?$`), "char-drm", "Graphics card (GPU)"},
+	{regexp.MustCompile(`^/dev/ttyUSB[0-9]+$`), "char-ttyUSB", "USB serial adapter"},
+	{regexp.MustCompile(`^/dev/ttyACM[0-9]+$`), "char-ttyACM", "USB serial device, ie. a modem or microcontroller"},
+	{regexp.MustCompile(`^/dev/serial/by-id/[A-Za-z0-9._:+-]+$`), "", "USB serial device"},
+	{regexp.MustCompile(`^/dev/hidraw[0-9]+$`), "char-hidraw", "USB HID device, ie. a hardware wallet"},
+	{regexp.MustCompile(`^/dev/video[0-9]+$`), "char-video4linux", "Camera or video capture device"},
+}
+
+func findDeviceKind(path string) (deviceKind, bool) {
+	for _, kind := range deviceKinds {
+		if kind.path.MatchString(path) {
+			return kind, true
+		}
+	}
+	return deviceKind{}, false
+}
+
+/* PupManifestDevice is a host device a pup asks to have passed through to
+ * its container, ie. a GPU (/dev/dri) or a USB serial adapter, one of
+ * deviceKinds. Like its interface dependencies, the pup can't start until
+ * the user has approved every device it requires, see
+ * PupState.NeedsDevices.
+ */
+type PupManifestDevice struct {
+	// A device node or directory under /dev, bind mounted at the same path.
+	Path string `json:"path"`
+	// Optional. The systemd device class (ie. char-drm) of the device's
+	// kind, to allow any device of that kind as they come and go. Path by
+	// default.
+	Class string `json:"class,omitempty"`
+	// Optional. Only allow the device to be read.
+	ReadOnly bool `json:"readOnly,omitempty"`
+	// Optional. What the pup uses the device for, shown to the user
+	// beside what the device is, see Kind.
+	Description string `json:"description,omitempty"`
+	// Optional. The pup can run without it.
+	Optional bool `json:"optional,omitempty"`
+}
+
+func (d PupManifestDevice) Validate() error {
+	if !devicePathRegex.MatchString(d.Path) || path.Clean(d.Path) != d.Path {
+		return fmt.Errorf("device path %q must be a path under /dev", d.Path)
+	}
+	kind, ok := findDeviceKind(d.Path)
+	if !ok {
+		return fmt.Errorf("device %s can't be passed through to a pup", d.Path)
+	}
+	if d.Class != "" && d.Class != kind.class {
+		if kind.class == "" {
+			return fmt.Errorf("device %s can't be given a class", d.Path)
+		}
+		return fmt.Errorf("device %s can only be given the class %s", d.Path, kind.class)
+	}
+	return nil
+}
+
+// Kind is what dogeboxd describes the device as to the user.
+func (d PupManifestDevice) Kind() string {
+	kind, _ := findDeviceKind(d.Path)
+	return kind.description
+}
+
+func validateManifestDevices(devices []PupManifestDevice) error {
+	if len(devices) > MAX_PUP_DEVICES {
+		return fmt.Errorf("at most %d devices can be requested", MAX_PUP_DEVICES)
+	}
+	seen := map[string]bool{}
+	for _, d := range devices {
+		if err := d.Validate(); err != nil {
+			return err
+		}
+		if seen[d.Path] {
+			return fmt.Errorf("device %s is requested twice", d.Path)
+		}
+		seen[d.Path] = true
+	}
+	return nil
+}
+
+// DeviceNode is what the container is allowed to open for this device.
+func (d PupManifestDevice) DeviceNode() string {
+	if d.Class != "" {
+		return d.Class
+	}
+	return d.Path
+}
+
+// DeviceModifier is the device cgroup access for this device.
+func (d PupManifestDevice) DeviceModifier() string {
+	if d.ReadOnly {
+		return "r"
+	}
+	return "rw"
+}
+
+// ValidateApprovedDevices checks every path is a device the pup's manifest
+// requests, once.
+func ValidateApprovedDevices(m PupManifest, paths []string) error {
+	seen := map[string]bool{}
+	for _, p := range paths {
+		if !slices.ContainsFunc(m.Container.Devices, func(d PupManifestDevice) bool { return d.Path == p }) {
+			return fmt.Errorf("device %s isn't requested by %s", p, m.Meta.Name)
+		}
+		if seen[p] {
+			return fmt.Errorf("device %s is given twice", p)
+		}
+		seen[p] = true
+	}
+	return nil
+}
+
+/* ApprovedDevice is a device the user approved, with the access the pup
+ * asked for at the time. A newer manifest asking for more, ie. to write
+ * to a device it could only read, or a whole class rather than one
+ * device, needs approving again.
+ */
+type ApprovedDevice struct {
+	Path     string `json:"path"`
+	Class    string `json:"class,omitempty"`
+	ReadOnly bool   `json:"readOnly,omitempty"`
+}
+
+// Covers reports whether the approval allows everything d asks for.
+func (a ApprovedDevice) Covers(d PupManifestDevice) bool {
+	return a.Path == d.Path && (d.Class == "" || d.Class == a.Class) && (d.ReadOnly || !a.ReadOnly)
+}
+
+// DeviceApprovals approves the manifest's devices at paths, as they're
+// requested now.
+func DeviceApprovals(m PupManifest, paths []string) []ApprovedDevice {
+	approvals := []ApprovedDevice{}
+	for _, d := range m.Container.Devices {
+		if slices.Contains(paths, d.Path) {
+			approvals = append(approvals, ApprovedDevice{Path: d.Path, Class: d.Class, ReadOnly: d.ReadOnly})
+		}
+	}
+	return approvals
+}
+
+// DeviceApproved reports whether the user has approved everything d asks for.
+func (p PupState) DeviceApproved(d PupManifestDevice) bool {
+	return slices.ContainsFunc(p.ApprovedDevices, func(a ApprovedDevice) bool { return a.Covers(d) })
+}
+
+// PassedDevices are the devices the manifest requests that the user has
+// approved. Approvals for devices a newer manifest no longer asks for, or
+// asks more of, are ignored.
+func (p PupState) PassedDevices() []PupManifestDevice {
+	devices := []PupManifestDevice{}
+	for _, d := range p.Manifest.Container.Devices {
+		if p.DeviceApproved(d) {
+			devices = append(devices, d)
+		}
+	}
+	return devices
+}
+
+// DevicesNeedApproval reports whether a device the pup requires hasn't
+// been approved yet, or needs approving again.
+func (p PupState) DevicesNeedApproval() bool {
+	for _, d := range p.Manifest.Container.Devices {
+		if !d.Optional && !p.DeviceApproved(d) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package dogeboxd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPupManifestDeviceValidate(t *testing.T) {
+	assert.NoError(t, PupManifestDevice{Path: "/dev/dri", Class: "char-drm", Description: "GPU"}.Validate())
+	assert.NoError(t, PupManifestDevice{Path: "/dev/dri/renderD128"}.Validate())
+	assert.NoError(t, PupManifestDevice{Path: "/dev/ttyUSB0", Description: "Serial", ReadOnly: true}.Validate())
+	assert.NoError(t, PupManifestDevice{Path: "/dev/ttyACM1", Class: "char-ttyACM"}.Validate())
+	assert.NoError(t, PupManifestDevice{Path: "/dev/hidraw3"}.Validate())
+	assert.NoError(t, PupManifestDevice{Path: "/dev/serial/by-id/usb-FTDI_FT232R-if00-port0", Description: "Serial"}.Validate())
+
+	assert.ErrorContains(t, PupManifestDevice{Path: "/etc/shadow"}.Validate(), "under /dev")
+	assert.ErrorContains(t, PupManifestDevice{Path: "/dev/../etc"}.Validate(), "under /dev")
+	assert.ErrorContains(t, PupManifestDevice{Path: "/dev/dri/"}.Validate(), "under /dev")
+	// Only the kinds of device we know about.
+	for _, path := range []string{"/dev/mem", "/dev/nvme0n1", "/dev/sda", "/dev/kvm", "/dev/tty0", "/dev/ttyUSB", "/dev/input/event0"} {
+		assert.ErrorContains(t, PupManifestDevice{Path: path}.Validate(), "can't be passed through", path)
+	}
+	assert.ErrorContains(t, PupManifestDevice{Path: "/dev/dri", Class: "block-sd"}.Validate(), "only be given the class char-drm")
+	assert.ErrorContains(t, PupManifestDevice{Path: "/dev/ttyUSB0", Class: "char-drm"}.Validate(), "only be given the class char-ttyUSB")
+	assert.ErrorContains(t, PupManifestDevice{Path: "/dev/serial/by-id/usb-x", Class: "char-ttyUSB"}.Validate(), "can't be given a class")
+}
+
+func TestPupManifestDeviceKind(t *testing.T) {
+	// Whatever the pup says it is.
+	d := PupManifestDevice{Path: "/dev/hidraw0", Description: "A harmless GPU"}
+	assert.Equal(t, "USB HID device, ie. a hardware wallet", d.Kind())
+	assert.Equal(t, "Graphics card (GPU)", PupManifestDevice{Path: "/dev/dri"}.Kind())
+}
+
+func TestManifestValidateDevices(t *testing.T) {
+	m := PupManifest{
+		ManifestVersion: 1,
+		Meta:            PupManifestMeta{Name: "test", Version: "1.0.0"},
+	}
+	m.Container.Build.NixFile = "pup.nix"
+	m.Container.Build.NixFileSha256 = "abc"
+	m.Container.Devices = []PupManifestDevice{{Path: "/dev/dri", Description: "GPU"}}
+	assert.NoError(t, m.Validate())
+
+	m.Container.Devices = append(m.Container.Devices, PupManifestDevice{Path: "/dev/dri", Description: "GPU again"})
+	assert.ErrorContains(t, m.Validate(), "requested twice")
+}
+
+func TestPupDeviceApproval(t *testing.T) {
+	p := PupState{ID: "abc"}
+	p.Manifest.Meta.Name = "Miner"
+	p.Manifest.Container.Devices = []PupManifestDevice{
+		{Path: "/dev/dri", Class: "char-drm", Description: "GPU"},
+		{Path: "/dev/ttyUSB0", Description: "Serial", ReadOnly: true, Optional: true},
+	}
+	assert.True(t, p.DevicesNeedApproval())
+	assert.Empty(t, p.PassedDevices())
+
+	assert.NoError(t, ValidateApprovedDevices(p.Manifest, []string{"/dev/ttyUSB0"}))
+	assert.ErrorContains(t, ValidateApprovedDevices(p.Manifest, []string{"/dev/kvm"}), "isn't requested by Miner")
+	assert.ErrorContains(t, ValidateApprovedDevices(p.Manifest, []string{"/dev/dri", "/dev/dri"}), "given twice")
+
+	pupdates := []Pupdate{}
+	PupApprovedDevices(DeviceApprovals(p.Manifest, []string{"/dev/ttyUSB0"}))(&p, &pupdates)
+	assert.True(t, p.NeedsDevices, "the GPU is still required")
+	assert.Equal(t, []PupManifestDevice{p.Manifest.Container.Devices[1]}, p.PassedDevices())
+	assert.Equal(t, "r", p.PassedDevices()[0].DeviceModifier())
+	assert.Equal(t, "/dev/ttyUSB0", p.PassedDevices()[0].DeviceNode())
+
+	PupApprovedDevices(DeviceApprovals(p.Manifest, []string{"/dev/dri", "/dev/ttyUSB0"}))(&p, &pupdates)
+	assert.False(t, p.NeedsDevices)
+	assert.Len(t, pupdates, 2)
+	assert.Equal(t, "char-drm", p.PassedDevices()[0].DeviceNode())
+	assert.Equal(t, "rw", p.PassedDevices()[0].DeviceModifier())
+
+	// Approvals for devices a new manifest dropped don't pass anything through.
+	p.Manifest.Container.Devices = p.Manifest.Container.Devices[1:]
+	assert.Len(t, p.PassedDevices(), 1)
+
+	PupApprovedDevices(nil)(&p, &pupdates)
+	assert.Nil(t, p.ApprovedDevices)
+	assert.False(t, p.NeedsDevices, "only optional devices left")
+}
+
+func TestPupDeviceApprovalNeedsRenewingWhenAccessWidens(t *testing.T) {
+	p := PupState{ID: "abc"}
+	p.Manifest.Container.Devices = []PupManifestDevice{{Path: "/dev/ttyUSB0", ReadOnly: true}}
+	PupApprovedDevices(DeviceApprovals(p.Manifest, []string{"/dev/ttyUSB0"}))(&p, &[]Pupdate{})
+	assert.False(t, p.NeedsDevices)
+
+	// An upgrade asks to write to it.
+	upgraded := p.Manifest
+	upgraded.Container.Devices = []PupManifestDevice{{Path: "/dev/ttyUSB0"}}
+	SetPupManifest(upgraded)(&p, &[]Pupdate{})
+	assert.True(t, p.NeedsDevices)
+	assert.Empty(t, p.PassedDevices())
+
+	// Or for any USB serial adapter.
+	upgraded.Container.Devices = []PupManifestDevice{{Path: "/dev/ttyUSB0", Class: "char-ttyUSB", ReadOnly: true}}
+	SetPupManifest(upgraded)(&p, &[]Pupdate{})
+	assert.True(t, p.NeedsDevices)
+
+	// Asking for less is fine.
+	PupApprovedDevices([]ApprovedDevice{{Path: "/dev/ttyUSB0", Class: "char-ttyUSB"}})(&p, &[]Pupdate{})
+	upgraded.Container.Devices = []PupManifestDevice{{Path: "/dev/ttyUSB0", ReadOnly: true}}
+	SetPupManifest(upgraded)(&p, &[]Pupdate{})
+	assert.False(t, p.NeedsDevices)
+	assert.Equal(t, "r", p.PassedDevices()[0].DeviceModifier())
+}
@@ -0,0 +1,70 @@
+package dogeboxd
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+/* Environment overrides are extra variables an advanced user sets for a
+ * pup, beyond what its manifest declares as config. They're kept apart
+ * from Config in PupState.EnvOverrides, so they never show up as config
+ * fields or get merged on upgrade, and are only added to config.env when
+ * it's written, see WritePupConfigToStorage. They can't shadow the pup's
+ * declared config, nor the DBX_ variables dogeboxd sets itself.
+ */
+
+const (
+	MAX_PUP_ENV_OVERRIDES            = 64
+	MAX_PUP_ENV_OVERRIDE_VALUE_BYTES = 4096
+)
+
+var envOverrideNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,127}$`)
+
+/* ValidatePupEnvOverrides checks env against the pup's manifest. It
+ * returns ConfigFieldErrors keyed by variable name for any that can't be
+ * set, or nil.
+ */
+func ValidatePupEnvOverrides(m PupManifest, env map[string]string) error {
+	if len(env) > MAX_PUP_ENV_OVERRIDES {
+		return fmt.Errorf("at most %d environment overrides can be set", MAX_PUP_ENV_OVERRIDES)
+	}
+
+	declared := ManifestConfigFieldIndex(m.Config)
+	errs := ConfigFieldErrors{}
+	for name, value := range env {
+		switch {
+		case !envOverrideNameRegex.MatchString(name):
+			errs[name] = "isn't a valid environment variable name"
+		case strings.HasPrefix(strings.ToUpper(name), "DBX_"):
+			errs[name] = "is reserved for dogeboxd"
+		case declared[name].Name != "":
+			errs[name] = "is declared as config by the manifest, set it there instead"
+		case m.Config.LogLevel != nil && m.Config.LogLevel.Env == name:
+			errs[name] = "is the pup's log level, use debug logging instead"
+		case len(value) > MAX_PUP_ENV_OVERRIDE_VALUE_BYTES:
+			errs[name] = fmt.Sprintf("must be at most %d bytes", MAX_PUP_ENV_OVERRIDE_VALUE_BYTES)
+		case strings.ContainsAny(value, "\x00\r\n"):
+			errs[name] = "must be a single line"
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+/* PupEnvironment is what's written to a pup's config.env: its config with
+ * its environment overrides added. Config always wins, so an override
+ * that a newer manifest has since declared as config is left out.
+ */
+func PupEnvironment(config map[string]string, envOverrides map[string]string) map[string]string {
+	env := make(map[string]string, len(config)+len(envOverrides))
+	for k, v := range envOverrides {
+		env[k] = v
+	}
+	for k, v := range config {
+		env[k] = v
+	}
+	return env
+}
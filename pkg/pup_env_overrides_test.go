@@ -0,0 +1,52 @@
+package dogeboxd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func envOverridesTestManifest() PupManifest {
+	m := PupManifest{}
+	m.Config.Sections = []PupManifestConfigSection{{Name: "main", Fields: []PupManifestConfigField{{Name: "RPC_USER", Type: "text"}}}}
+	m.Config.LogLevel = &PupManifestLogLevel{Env: "LOG_LEVEL", Debug: "debug"}
+	return m
+}
+
+func TestValidatePupEnvOverrides(t *testing.T) {
+	m := envOverridesTestManifest()
+
+	assert.NoError(t, ValidatePupEnvOverrides(m, nil))
+	assert.NoError(t, ValidatePupEnvOverrides(m, map[string]string{"EXTRA_FLAGS": "-v --debug", "_private": ""}))
+
+	err := ValidatePupEnvOverrides(m, map[string]string{
+		"RPC_USER":   "doge",
+		"LOG_LEVEL":  "trace",
+		"DBX_PUP_IP": "10.0.0.1",
+		"1BAD":       "x",
+		"MULTI":      "a\nb",
+		"HUGE":       strings.Repeat("x", MAX_PUP_ENV_OVERRIDE_VALUE_BYTES+1),
+	})
+	var fields ConfigFieldErrors
+	require.ErrorAs(t, err, &fields)
+	assert.Len(t, fields, 6)
+	assert.Contains(t, fields["RPC_USER"], "declared as config")
+	assert.Contains(t, fields["DBX_PUP_IP"], "reserved")
+
+	tooMany := map[string]string{}
+	for i := 0; i <= MAX_PUP_ENV_OVERRIDES; i++ {
+		tooMany["VAR_"+strings.Repeat("X", i)] = "1"
+	}
+	assert.ErrorContains(t, ValidatePupEnvOverrides(m, tooMany), "at most")
+}
+
+func TestPupEnvironmentPrefersConfig(t *testing.T) {
+	config := map[string]string{"RPC_USER": "doge"}
+	env := PupEnvironment(config, map[string]string{"RPC_USER": "stale", "EXTRA_FLAGS": "-v"})
+
+	assert.Equal(t, map[string]string{"RPC_USER": "doge", "EXTRA_FLAGS": "-v"}, env)
+	assert.Len(t, config, 1, "the pup's config shouldn't be modified")
+	assert.Empty(t, PupEnvironment(nil, nil))
+}
@@ -0,0 +1,397 @@
+package dogeboxd
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Bumped when an export's layout changes in a way older boxes can't read.
+const PUP_EXPORT_FORMAT_VERSION = 1
+
+// What's in an export's gzipped tarball, the same format as our backups.
+const (
+	pupExportManifestName = "pup.json"
+	pupExportStorageName  = "storage.tar.gz"
+)
+
+const pupExportExt = ".tar.gz"
+
+var (
+	ErrPupExportNotFound = errors.New("pup export not found")
+	pupExportIDRegex     = regexp.MustCompile(`^[a-f0-9]+$`)
+)
+
+/* A PupExport describes a pup well enough to install it again on another
+ * Dogebox, see ExportPup and ImportPup. It's written as pup.json at the
+ * top of the export's tarball, alongside the pup's storage (itself a
+ * tarball, written by _dbxroot) if that was included.
+ */
+type PupExport struct {
+	FormatVersion int       `json:"formatVersion"`
+	Created       time.Time `json:"created"`
+	// The pup's ID on the box it was exported from.
+	PupID        string `json:"pupId"`
+	PupName      string `json:"pupName"`
+	PupVersion   string `json:"pupVersion"`
+	InstanceName string `json:"instanceName,omitempty"`
+	// Where to install the pup from, sources are matched by location as
+	// their IDs differ between boxes. Credentials are never exported.
+	SourceLocation string                       `json:"sourceLocation"`
+	SourceName     string                       `json:"sourceName,omitempty"`
+	Config         map[string]string            `json:"config"`
+	Providers      map[string]PupExportProvider `json:"providers"`
+	StorageQuotaMB int                          `json:"storageQuotaMb,omitempty"`
+	HasStorage     bool                         `json:"hasStorage"`
+}
+
+// A pup that provided an interface, pup IDs differ between boxes so it's
+// also known by name, see PupExport.ResolveProviders.
+type PupExportProvider struct {
+	PupID        string `json:"pupId"`
+	PupName      string `json:"pupName"`
+	InstanceName string `json:"instanceName,omitempty"`
+}
+
+// PupExportInfo is what we tell the frontend about an export on disk.
+type PupExportInfo struct {
+	ID      string    `json:"id"`
+	Size    int64     `json:"size"`
+	Export  PupExport `json:"export"`
+	Created time.Time `json:"created"`
+}
+
+// NewPupExport describes p, looking up its providers in states.
+func NewPupExport(p PupState, states map[string]PupState, withStorage bool, now time.Time) PupExport {
+	export := PupExport{
+		FormatVersion:  PUP_EXPORT_FORMAT_VERSION,
+		Created:        now,
+		PupID:          p.ID,
+		PupName:        p.Manifest.Meta.Name,
+		PupVersion:     p.Version,
+		InstanceName:   p.InstanceName,
+		SourceLocation: p.Source.Location,
+		SourceName:     p.Source.Name,
+		Config:         map[string]string{},
+		Providers:      map[string]PupExportProvider{},
+		StorageQuotaMB: p.StorageQuotaMB,
+		HasStorage:     withStorage,
+	}
+	for k, v := range p.Config {
+		export.Config[k] = v
+	}
+	for iface, providerID := range p.Providers {
+		provider := PupExportProvider{PupID: providerID}
+		if state, ok := states[providerID]; ok {
+			provider.PupName = state.Manifest.Meta.Name
+			provider.InstanceName = state.InstanceName
+		}
+		export.Providers[iface] = provider
+	}
+	return export
+}
+
+func (e PupExport) Validate() error {
+	if e.FormatVersion < 1 || e.FormatVersion > PUP_EXPORT_FORMAT_VERSION {
+		return fmt.Errorf("unsupported pup export format %d", e.FormatVersion)
+	}
+	if e.PupName == "" || e.PupVersion == "" {
+		return errors.New("pup export is missing the pup's name or version")
+	}
+	if e.SourceLocation == "" {
+		return errors.New("pup export is missing the pup's source")
+	}
+	return ValidatePupInstanceName(e.InstanceName)
+}
+
+/* ResolveProviders maps the exported providers onto the pups in states.
+ * A provider is kept if it's still installed under the same ID (ie: an
+ * export brought back to the same box), otherwise it's matched to the
+ * one installed pup with the same name and instance. Interfaces that
+ * can't be matched are left out, to be picked again by the user.
+ */
+func (e PupExport) ResolveProviders(states map[string]PupState) map[string]string {
+	providers := map[string]string{}
+	for iface, provider := range e.Providers {
+		if state, ok := states[provider.PupID]; ok && state.Manifest.Meta.Name == provider.PupName {
+			providers[iface] = provider.PupID
+			continue
+		}
+		if provider.PupName == "" {
+			continue
+		}
+
+		matches := []string{}
+		for id, state := range states {
+			if state.Manifest.Meta.Name == provider.PupName && state.InstanceName == provider.InstanceName {
+				matches = append(matches, id)
+			}
+		}
+		if len(matches) == 1 {
+			providers[iface] = matches[0]
+		}
+	}
+	return providers
+}
+
+func ValidatePupExportID(id string) error {
+	if !pupExportIDRegex.MatchString(id) {
+		return ErrPupExportNotFound
+	}
+	return nil
+}
+
+// Exports, and imports waiting to be installed, are both kept here.
+func (c ServerConfig) PupExportDir() string {
+	return filepath.Join(c.DataDir, "pup-exports")
+}
+
+func (c ServerConfig) PupExportPath(exportID string) string {
+	return filepath.Join(c.PupExportDir(), exportID+pupExportExt)
+}
+
+// Where _dbxroot reads and writes the storage tarball of an export.
+func (c ServerConfig) PupExportStoragePath(exportID string) string {
+	return filepath.Join(c.PupExportDir(), exportID+".storage"+pupExportExt)
+}
+
+// NewPupExportID makes an ID for a new export or upload.
+func NewPupExportID() (string, error) {
+	return newID(16)
+}
+
+/* WritePupExport writes export to a gzipped tarball at path, along with
+ * the storage tarball at storagePath if the export has storage.
+ */
+func WritePupExport(path string, export PupExport, storagePath string) error {
+	manifest, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to write pup export manifest: %w", err)
+	}
+
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0640)
+	if err != nil {
+		return fmt.Errorf("failed to create pup export: %w", err)
+	}
+	defer out.Close()
+
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+
+	header := &tar.Header{
+		Name:     pupExportManifestName,
+		Mode:     0640,
+		Size:     int64(len(manifest)),
+		ModTime:  export.Created,
+		Typeflag: tar.TypeReg,
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write pup export: %w", err)
+	}
+	if _, err := tw.Write(manifest); err != nil {
+		return fmt.Errorf("failed to write pup export: %w", err)
+	}
+
+	if export.HasStorage {
+		if err := writePupExportStorage(tw, storagePath); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to finalise pup export: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to finalise pup export: %w", err)
+	}
+	return out.Close()
+}
+
+func writePupExportStorage(tw *tar.Writer, storagePath string) error {
+	f, err := os.Open(storagePath)
+	if err != nil {
+		return fmt.Errorf("failed to open pup storage archive: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to open pup storage archive: %w", err)
+	}
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	header.Name = pupExportStorageName
+
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write pup export: %w", err)
+	}
+	if _, err := io.Copy(tw, f); err != nil {
+		return fmt.Errorf("failed to write pup storage to export: %w", err)
+	}
+	return nil
+}
+
+// ReadPupExport reads the manifest of the export at path.
+func ReadPupExport(path string) (PupExport, error) {
+	var export PupExport
+	found := false
+	err := walkPupExport(path, func(name string, r io.Reader) (bool, error) {
+		if name != pupExportManifestName {
+			return false, nil
+		}
+		found = true
+		if err := json.NewDecoder(io.LimitReader(r, 1<<20)).Decode(&export); err != nil {
+			return true, fmt.Errorf("invalid pup export manifest: %w", err)
+		}
+		return true, nil
+	})
+	if err != nil {
+		return PupExport{}, err
+	}
+	if !found {
+		return PupExport{}, errors.New("not a pup export, it has no manifest")
+	}
+	if err := export.Validate(); err != nil {
+		return PupExport{}, err
+	}
+	return export, nil
+}
+
+// ExtractPupExportStorage copies the storage tarball out of the export at
+// path to dest, for _dbxroot to unpack into the pup's storage.
+func ExtractPupExportStorage(path string, dest string) error {
+	found := false
+	err := walkPupExport(path, func(name string, r io.Reader) (bool, error) {
+		if name != pupExportStorageName {
+			return false, nil
+		}
+		found = true
+
+		out, err := os.OpenFile(dest, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0640)
+		if err != nil {
+			return true, err
+		}
+		if _, err := io.Copy(out, r); err != nil {
+			out.Close()
+			return true, err
+		}
+		return true, out.Close()
+	})
+	if err != nil {
+		return fmt.Errorf("failed to extract pup storage from export: %w", err)
+	}
+	if !found {
+		return errors.New("pup export has no storage")
+	}
+	return nil
+}
+
+// Calls fn with each file in the export at path until it returns done.
+func walkPupExport(path string, fn func(name string, r io.Reader) (done bool, err error)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ErrPupExportNotFound
+		}
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("failed to decompress pup export: %w", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read pup export: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if done, err := fn(header.Name, tr); done || err != nil {
+			return err
+		}
+	}
+}
+
+// GetPupExport describes the export with exportID.
+func GetPupExport(config ServerConfig, exportID string) (PupExportInfo, error) {
+	if err := ValidatePupExportID(exportID); err != nil {
+		return PupExportInfo{}, err
+	}
+
+	path := config.PupExportPath(exportID)
+	info, err := os.Stat(path)
+	if err != nil {
+		return PupExportInfo{}, ErrPupExportNotFound
+	}
+	export, err := ReadPupExport(path)
+	if err != nil {
+		return PupExportInfo{}, err
+	}
+
+	return PupExportInfo{ID: exportID, Size: info.Size(), Export: export, Created: info.ModTime()}, nil
+}
+
+// ListPupExports lists the exports on disk, newest first. Unreadable
+// files, ie: an upload that wasn't an export, are skipped.
+func ListPupExports(config ServerConfig) ([]PupExportInfo, error) {
+	entries, err := os.ReadDir(config.PupExportDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []PupExportInfo{}, nil
+		}
+		return nil, err
+	}
+
+	exports := []PupExportInfo{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), pupExportExt) {
+			continue
+		}
+		// Storage tarballs waiting on _dbxroot don't have a valid ID.
+		info, err := GetPupExport(config, strings.TrimSuffix(entry.Name(), pupExportExt))
+		if err != nil {
+			continue
+		}
+		exports = append(exports, info)
+	}
+
+	sort.Slice(exports, func(i, j int) bool { return exports[i].Created.After(exports[j].Created) })
+	return exports, nil
+}
+
+// DeletePupExport removes an export, and any storage left over from it.
+func DeletePupExport(config ServerConfig, exportID string) error {
+	if err := ValidatePupExportID(exportID); err != nil {
+		return err
+	}
+	if err := os.Remove(config.PupExportPath(exportID)); err != nil {
+		if os.IsNotExist(err) {
+			return ErrPupExportNotFound
+		}
+		return err
+	}
+	if err := os.Remove(config.PupExportStoragePath(exportID)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
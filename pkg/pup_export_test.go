@@ -0,0 +1,135 @@
+package dogeboxd
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func exportTestStates() map[string]PupState {
+	core := PupState{ID: "core1", Manifest: PupManifest{Meta: PupManifestMeta{Name: "Dogecoin Core"}}}
+	wallet := PupState{
+		ID:             "wallet1",
+		Version:        "1.2.0",
+		InstanceName:   "Savings",
+		Source:         ManifestSourceConfiguration{ID: "src1", Name: "Official", Location: "https://github.com/dogeorg/pups.git", Auth: &ManifestSourceAuth{}},
+		Manifest:       PupManifest{Meta: PupManifestMeta{Name: "Wallet"}},
+		Config:         map[string]string{"LABEL": "savings"},
+		Providers:      map[string]string{"core-rpc": "core1", "gone": "missing1"},
+		StorageQuotaMB: 512,
+	}
+	return map[string]PupState{core.ID: core, wallet.ID: wallet}
+}
+
+func TestNewPupExport(t *testing.T) {
+	states := exportTestStates()
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+
+	export := NewPupExport(states["wallet1"], states, true, now)
+	require.NoError(t, export.Validate())
+
+	assert.Equal(t, "Wallet", export.PupName)
+	assert.Equal(t, "1.2.0", export.PupVersion)
+	assert.Equal(t, "Savings", export.InstanceName)
+	assert.Equal(t, "https://github.com/dogeorg/pups.git", export.SourceLocation)
+	assert.Equal(t, map[string]string{"LABEL": "savings"}, export.Config)
+	assert.Equal(t, PupExportProvider{PupID: "core1", PupName: "Dogecoin Core"}, export.Providers["core-rpc"])
+	assert.Equal(t, PupExportProvider{PupID: "missing1"}, export.Providers["gone"])
+	assert.Equal(t, 512, export.StorageQuotaMB)
+	assert.True(t, export.HasStorage)
+}
+
+func TestPupExportValidate(t *testing.T) {
+	valid := PupExport{FormatVersion: PUP_EXPORT_FORMAT_VERSION, PupName: "Wallet", PupVersion: "1.0.0", SourceLocation: "https://example.com/pups.git"}
+	assert.NoError(t, valid.Validate())
+
+	newer := valid
+	newer.FormatVersion = PUP_EXPORT_FORMAT_VERSION + 1
+	assert.Error(t, newer.Validate())
+
+	noSource := valid
+	noSource.SourceLocation = ""
+	assert.Error(t, noSource.Validate())
+}
+
+func TestPupExportResolveProviders(t *testing.T) {
+	export := PupExport{Providers: map[string]PupExportProvider{
+		"same-box":  {PupID: "core1", PupName: "Dogecoin Core"},
+		"by-name":   {PupID: "elsewhere", PupName: "Dogecoin Core"},
+		"ambiguous": {PupID: "elsewhere", PupName: "Indexer"},
+		"unknown":   {PupID: "elsewhere"},
+	}}
+	states := map[string]PupState{
+		"core1": {ID: "core1", Manifest: PupManifest{Meta: PupManifestMeta{Name: "Dogecoin Core"}}},
+		"idx1":  {ID: "idx1", Manifest: PupManifest{Meta: PupManifestMeta{Name: "Indexer"}}},
+		"idx2":  {ID: "idx2", Manifest: PupManifest{Meta: PupManifestMeta{Name: "Indexer"}}},
+	}
+
+	assert.Equal(t, map[string]string{"same-box": "core1", "by-name": "core1"}, export.ResolveProviders(states))
+}
+
+func TestWriteAndReadPupExport(t *testing.T) {
+	config := ServerConfig{DataDir: t.TempDir()}
+	require.NoError(t, os.MkdirAll(config.PupExportDir(), 0750))
+
+	states := exportTestStates()
+	export := NewPupExport(states["wallet1"], states, true, time.Now())
+
+	exportID, err := NewPupExportID()
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(config.PupExportStoragePath(exportID), []byte("storage"), 0640))
+	require.NoError(t, WritePupExport(config.PupExportPath(exportID), export, config.PupExportStoragePath(exportID)))
+	require.NoError(t, os.Remove(config.PupExportStoragePath(exportID)))
+
+	info, err := GetPupExport(config, exportID)
+	require.NoError(t, err)
+	assert.Equal(t, export.PupName, info.Export.PupName)
+	assert.Equal(t, export.Providers, info.Export.Providers)
+	assert.True(t, info.Export.HasStorage)
+
+	dest := config.PupExportStoragePath(exportID)
+	require.NoError(t, ExtractPupExportStorage(config.PupExportPath(exportID), dest))
+	data, err := os.ReadFile(dest)
+	require.NoError(t, err)
+	assert.Equal(t, "storage", string(data))
+
+	exports, err := ListPupExports(config)
+	require.NoError(t, err)
+	require.Len(t, exports, 1)
+	assert.Equal(t, exportID, exports[0].ID)
+
+	require.NoError(t, DeletePupExport(config, exportID))
+	_, err = os.Stat(dest)
+	assert.True(t, os.IsNotExist(err))
+	assert.ErrorIs(t, DeletePupExport(config, exportID), ErrPupExportNotFound)
+}
+
+func TestPupExportWithoutStorage(t *testing.T) {
+	config := ServerConfig{DataDir: t.TempDir()}
+	require.NoError(t, os.MkdirAll(config.PupExportDir(), 0750))
+
+	states := exportTestStates()
+	export := NewPupExport(states["wallet1"], states, false, time.Now())
+	require.NoError(t, WritePupExport(config.PupExportPath("abc"), export, ""))
+
+	assert.Error(t, ExtractPupExportStorage(config.PupExportPath("abc"), config.PupExportStoragePath("abc")))
+}
+
+func TestGetPupExportRejectsBadIDsAndUploads(t *testing.T) {
+	config := ServerConfig{DataDir: t.TempDir()}
+	require.NoError(t, os.MkdirAll(config.PupExportDir(), 0750))
+
+	_, err := GetPupExport(config, "../../etc/passwd")
+	assert.ErrorIs(t, err, ErrPupExportNotFound)
+
+	require.NoError(t, os.WriteFile(config.PupExportPath("abc"), []byte("not a tarball"), 0640))
+	_, err = GetPupExport(config, "abc")
+	assert.Error(t, err)
+
+	exports, err := ListPupExports(config)
+	require.NoError(t, err)
+	assert.Empty(t, exports)
+}
@@ -0,0 +1,98 @@
+package dogeboxd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	HEALTH_CHECK_HTTP    = "http"
+	HEALTH_CHECK_COMMAND = "command"
+)
+
+const (
+	DefaultHealthCheckInterval = 30 * time.Second
+	DefaultHealthCheckTimeout  = 5 * time.Second
+	DefaultHealthCheckRetries  = 3
+	MinHealthCheckInterval     = 5 * time.Second
+	MaxHealthCheckCommandLen   = 1024
+)
+
+/* A pup can declare a health check in its manifest, which PupManager runs
+ * while the pup is running. A pup that fails it Retries times in a row is
+ * shown as unhealthy rather than running, ie:
+ *
+ *	"healthCheck": { "type": "http", "port": 8080, "path": "/health" }
+ *	"healthCheck": { "type": "command", "command": "dogecoin-cli getblockcount" }
+ *
+ * HTTP checks pass on any 2xx or 3xx response. Command checks run in the
+ * pup's container and pass when the command exits 0.
+ */
+type PupManifestHealthCheck struct {
+	Type string `json:"type"`
+	// For http checks, the port in the container and the path to GET.
+	Port int    `json:"port,omitempty"`
+	Path string `json:"path,omitempty"`
+	// For command checks, a shell command run in the container as the pup
+	// user, with the pup's config in its environment.
+	Command string `json:"command,omitempty"`
+	// Seconds between checks, and before a check is given up on.
+	Interval int `json:"interval,omitempty"`
+	Timeout  int `json:"timeout,omitempty"`
+	// How many checks in a row need to fail before the pup is unhealthy.
+	Retries int `json:"retries,omitempty"`
+}
+
+func (h PupManifestHealthCheck) Validate() error {
+	switch h.Type {
+	case HEALTH_CHECK_HTTP:
+		if h.Port <= 0 || h.Port > 65535 {
+			return fmt.Errorf("healthCheck port must be between 1 and 65535")
+		}
+		if h.Path != "" && !strings.HasPrefix(h.Path, "/") {
+			return fmt.Errorf("healthCheck path must start with /")
+		}
+	case HEALTH_CHECK_COMMAND:
+		if strings.TrimSpace(h.Command) == "" {
+			return fmt.Errorf("healthCheck command is required")
+		}
+		if len(h.Command) > MaxHealthCheckCommandLen || strings.ContainsAny(h.Command, "\r\n\x00") {
+			return fmt.Errorf("healthCheck command must be a single line of at most %d characters", MaxHealthCheckCommandLen)
+		}
+	default:
+		return fmt.Errorf("healthCheck type must be one of: %s, %s", HEALTH_CHECK_HTTP, HEALTH_CHECK_COMMAND)
+	}
+
+	if h.Interval < 0 || h.Timeout < 0 || h.Retries < 0 {
+		return fmt.Errorf("healthCheck interval, timeout and retries can't be negative")
+	}
+	if h.Interval != 0 && h.IntervalDuration() < MinHealthCheckInterval {
+		return fmt.Errorf("healthCheck interval must be at least %s", MinHealthCheckInterval)
+	}
+	if h.TimeoutDuration() >= h.IntervalDuration() {
+		return fmt.Errorf("healthCheck timeout must be shorter than its interval")
+	}
+	return nil
+}
+
+func (h PupManifestHealthCheck) IntervalDuration() time.Duration {
+	if h.Interval == 0 {
+		return DefaultHealthCheckInterval
+	}
+	return time.Duration(h.Interval) * time.Second
+}
+
+func (h PupManifestHealthCheck) TimeoutDuration() time.Duration {
+	if h.Timeout == 0 {
+		return DefaultHealthCheckTimeout
+	}
+	return time.Duration(h.Timeout) * time.Second
+}
+
+func (h PupManifestHealthCheck) FailureThreshold() int {
+	if h.Retries == 0 {
+		return DefaultHealthCheckRetries
+	}
+	return h.Retries
+}
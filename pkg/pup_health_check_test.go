@@ -0,0 +1,33 @@
+package dogeboxd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPupManifestHealthCheckValidate(t *testing.T) {
+	assert.NoError(t, PupManifestHealthCheck{Type: HEALTH_CHECK_HTTP, Port: 8080, Path: "/health"}.Validate())
+	assert.NoError(t, PupManifestHealthCheck{Type: HEALTH_CHECK_COMMAND, Command: "dogecoin-cli getblockcount"}.Validate())
+
+	assert.Error(t, PupManifestHealthCheck{Type: "tcp", Port: 8080}.Validate())
+	assert.Error(t, PupManifestHealthCheck{Type: HEALTH_CHECK_HTTP}.Validate(), "http checks need a port")
+	assert.Error(t, PupManifestHealthCheck{Type: HEALTH_CHECK_HTTP, Port: 8080, Path: "health"}.Validate())
+	assert.Error(t, PupManifestHealthCheck{Type: HEALTH_CHECK_COMMAND}.Validate())
+	assert.Error(t, PupManifestHealthCheck{Type: HEALTH_CHECK_COMMAND, Command: "true\nreboot"}.Validate())
+	assert.Error(t, PupManifestHealthCheck{Type: HEALTH_CHECK_HTTP, Port: 8080, Interval: 1}.Validate())
+	assert.Error(t, PupManifestHealthCheck{Type: HEALTH_CHECK_HTTP, Port: 8080, Interval: 10, Timeout: 10}.Validate())
+}
+
+func TestPupManifestHealthCheckDefaults(t *testing.T) {
+	h := PupManifestHealthCheck{Type: HEALTH_CHECK_HTTP, Port: 8080}
+	assert.Equal(t, DefaultHealthCheckInterval, h.IntervalDuration())
+	assert.Equal(t, DefaultHealthCheckTimeout, h.TimeoutDuration())
+	assert.Equal(t, DefaultHealthCheckRetries, h.FailureThreshold())
+
+	h = PupManifestHealthCheck{Type: HEALTH_CHECK_HTTP, Port: 8080, Interval: 60, Timeout: 2, Retries: 1}
+	assert.Equal(t, time.Minute, h.IntervalDuration())
+	assert.Equal(t, 2*time.Second, h.TimeoutDuration())
+	assert.Equal(t, 1, h.FailureThreshold())
+}
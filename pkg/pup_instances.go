@@ -0,0 +1,102 @@
+package dogeboxd
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+/* A manifest can be installed more than once, ie: two testnet nodes side
+ * by side, by giving each extra install an InstanceName. Every instance
+ * is its own pup, with its own ID, IP, storage and container.
+ *
+ * Ports exposed with ListenOnHost are the exception, they're forwarded
+ * from the same port on the host, which only one pup can have. So an
+ * instance can't be installed while another pup forwards the same host
+ * port, see FindConflictingPupInstance.
+ */
+
+var ErrPupInstanceNameTaken = errors.New("pup instance name already in use")
+var ErrPupHostPortTaken = errors.New("pup host port already forwarded by another pup")
+
+const MAX_PUP_INSTANCE_NAME_LENGTH = 32
+
+var pupInstanceNameRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9 _-]*$`)
+
+// ValidatePupInstanceName checks an instance name is safe to show and
+// store. An empty name is valid and means the default instance.
+func ValidatePupInstanceName(name string) error {
+	if name == "" {
+		return nil
+	}
+	if len(name) > MAX_PUP_INSTANCE_NAME_LENGTH {
+		return fmt.Errorf("instance name must be at most %d characters", MAX_PUP_INSTANCE_NAME_LENGTH)
+	}
+	if !pupInstanceNameRegex.MatchString(name) {
+		return fmt.Errorf("invalid instance name %q, use letters, numbers, spaces, - and _", name)
+	}
+	return nil
+}
+
+// DisplayName is the pup's name, followed by its instance name if it has
+// one, ie: "Dogecoin Core (testnet-2)".
+func (p PupState) DisplayName() string {
+	if p.InstanceName == "" {
+		return p.Manifest.Meta.Name
+	}
+	return fmt.Sprintf("%s (%s)", p.Manifest.Meta.Name, p.InstanceName)
+}
+
+/* FindConflictingPupInstance returns the ID of an installed pup that
+ * installing manifest m from sourceID as instanceName would clash with.
+ *
+ * Without an instance name this is the same manifest version from the
+ * same source, as it always was. A named instance clashes with any pup
+ * of the same name already using that instance name. Either clashes
+ * with a pup already forwarding one of m's host ports.
+ */
+func FindConflictingPupInstance(pups map[string]PupState, m PupManifest, sourceID string, instanceName string) (string, error) {
+	for _, p := range pups {
+		if p.Manifest.Meta.Name != m.Meta.Name || p.InstanceName != instanceName {
+			continue
+		}
+		if instanceName != "" {
+			return p.ID, ErrPupInstanceNameTaken
+		}
+		if p.Manifest.Meta.Version == m.Meta.Version && p.Source.ID == sourceID {
+			return p.ID, ErrPupAlreadyExists
+		}
+	}
+
+	for _, ex := range m.Container.Exposes {
+		if !ex.ListenOnHost {
+			continue
+		}
+		for _, p := range pups {
+			for _, other := range p.Manifest.Container.Exposes {
+				if other.ListenOnHost && other.Port == ex.Port {
+					return p.ID, fmt.Errorf("%w: %s already forwards port %d", ErrPupHostPortTaken, p.DisplayName(), ex.Port)
+				}
+			}
+		}
+	}
+	return "", nil
+}
+
+// FindOtherPupInstances returns the IDs of the other installed instances
+// of a pup, ie: the same manifest from the same source, sorted.
+func FindOtherPupInstances(pups map[string]PupState, pupID string) []string {
+	ids := []string{}
+	pup, ok := pups[pupID]
+	if !ok {
+		return ids
+	}
+	for id, p := range pups {
+		if id != pupID && p.Manifest.Meta.Name == pup.Manifest.Meta.Name && SameSource(p.Source, pup.Source) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,77 @@
+package dogeboxd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func instanceTestPup(id, name, version, source, instance string) PupState {
+	p := PupState{ID: id, InstanceName: instance}
+	p.Manifest.Meta.Name = name
+	p.Manifest.Meta.Version = version
+	p.Source.ID = source
+	return p
+}
+
+func TestValidatePupInstanceName(t *testing.T) {
+	assert.NoError(t, ValidatePupInstanceName(""))
+	assert.NoError(t, ValidatePupInstanceName("testnet-2"))
+	assert.NoError(t, ValidatePupInstanceName("My Node_1"))
+
+	assert.Error(t, ValidatePupInstanceName(" leading"))
+	assert.Error(t, ValidatePupInstanceName("-leading"))
+	assert.Error(t, ValidatePupInstanceName("no/slashes"))
+	assert.Error(t, ValidatePupInstanceName("line\nbreak"))
+	assert.Error(t, ValidatePupInstanceName(strings.Repeat("a", MAX_PUP_INSTANCE_NAME_LENGTH+1)))
+}
+
+func TestPupStateDisplayName(t *testing.T) {
+	assert.Equal(t, "Dogecoin Core", instanceTestPup("a", "Dogecoin Core", "1.0", "src", "").DisplayName())
+	assert.Equal(t, "Dogecoin Core (testnet)", instanceTestPup("a", "Dogecoin Core", "1.0", "src", "testnet").DisplayName())
+}
+
+func TestFindConflictingPupInstance(t *testing.T) {
+	pups := map[string]PupState{
+		"a": instanceTestPup("a", "Dogecoin Core", "1.0", "src", ""),
+		"b": instanceTestPup("b", "Dogecoin Core", "1.0", "src", "testnet"),
+	}
+	m := instanceTestPup("", "Dogecoin Core", "1.0", "src", "").Manifest
+
+	id, err := FindConflictingPupInstance(pups, m, "src", "")
+	assert.ErrorIs(t, err, ErrPupAlreadyExists)
+	assert.Equal(t, "a", id)
+
+	id, err = FindConflictingPupInstance(pups, m, "src", "testnet")
+	assert.ErrorIs(t, err, ErrPupInstanceNameTaken)
+	assert.Equal(t, "b", id)
+
+	_, err = FindConflictingPupInstance(pups, m, "src", "mainnet-2")
+	assert.NoError(t, err, "a new instance name should be installable")
+
+	_, err = FindConflictingPupInstance(pups, m, "other-src", "")
+	assert.NoError(t, err, "the same pup from another source isn't a conflict")
+
+	other := instanceTestPup("", "Dogenet", "1.0", "src", "").Manifest
+	_, err = FindConflictingPupInstance(pups, other, "src", "testnet")
+	assert.NoError(t, err, "instance names are per pup")
+}
+
+func TestFindConflictingPupInstanceHostPorts(t *testing.T) {
+	node := instanceTestPup("a", "Dogecoin Core", "1.0", "src", "")
+	node.Manifest.Container.Exposes = []PupManifestExposeConfig{
+		{Name: "p2p", Port: 22556, ListenOnHost: true},
+		{Name: "rpc", Port: 22555},
+	}
+	pups := map[string]PupState{"a": node}
+
+	id, err := FindConflictingPupInstance(pups, node.Manifest, "src", "testnet")
+	assert.ErrorIs(t, err, ErrPupHostPortTaken)
+	assert.Equal(t, "a", id)
+
+	m := node.Manifest
+	m.Container.Exposes = []PupManifestExposeConfig{{Name: "rpc", Port: 22555}}
+	_, err = FindConflictingPupInstance(pups, m, "src", "testnet")
+	assert.NoError(t, err, "ports that aren't forwarded from the host don't clash")
+}
@@ -0,0 +1,124 @@
+package dogeboxd
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+/* A pup can declare a log verbosity knob in its manifest, ie:
+ *
+ *	"config": { "logLevel": { "env": "LOG_LEVEL", "debug": "debug" } }
+ *
+ * which lets the user turn on debug logging for a while (to collect logs
+ * for a bug report) without editing config by hand. The knob is an entry
+ * in the pup's config.env, switched to Debug and reverted to whatever it
+ * was once the override expires, see SetPupLogLevel.
+ */
+type PupManifestLogLevel struct {
+	// The config.env key the pup reads its log level from.
+	Env string `json:"env"`
+	// The value that turns on debug logging.
+	Debug string `json:"debug"`
+}
+
+var logLevelEnvRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func (l PupManifestLogLevel) Validate() error {
+	if !logLevelEnvRegex.MatchString(l.Env) {
+		return fmt.Errorf("config logLevel env %q must be a valid environment variable name", l.Env)
+	}
+	if l.Debug == "" {
+		return fmt.Errorf("config logLevel debug value is required")
+	}
+	if strings.ContainsAny(l.Debug, "\r\n") {
+		return fmt.Errorf("config logLevel debug value must be a single line")
+	}
+	return nil
+}
+
+const (
+	DefaultPupDebugLogDuration = time.Hour
+	MaxPupDebugLogDuration     = 24 * time.Hour
+)
+
+var ErrNoLogLevelKnob = errors.New("pup doesn't declare a log level in its manifest")
+
+// PupLogLevelOverride records a temporary log level, and what to put
+// back when it expires.
+type PupLogLevelOverride struct {
+	Value string    `json:"value"`
+	Until time.Time `json:"until"`
+	// The config value before the override, HadPrevious is false if the
+	// key wasn't set at all.
+	Previous    string `json:"previous,omitempty"`
+	HadPrevious bool   `json:"hadPrevious,omitempty"`
+}
+
+// Expired reports whether the override should have been reverted by now.
+func (o PupLogLevelOverride) Expired(now time.Time) bool {
+	return !now.Before(o.Until)
+}
+
+// ValidatePupDebugLogDuration checks a requested debug logging duration,
+// zero meaning DefaultPupDebugLogDuration.
+func ValidatePupDebugLogDuration(d time.Duration) error {
+	if d < 0 || d > MaxPupDebugLogDuration {
+		return fmt.Errorf("debug logging duration must be between 0 and %s", MaxPupDebugLogDuration)
+	}
+	return nil
+}
+
+/* PupLogLevelConfig works out a pup's config with debug logging turned on
+ * until the given time, or turned back off. Extending an existing override
+ * keeps what it will revert to. Turning it off without an override leaves
+ * the config as it is.
+ */
+func PupLogLevelConfig(p PupState, debug bool, until time.Time) (map[string]string, *PupLogLevelOverride, error) {
+	knob := p.Manifest.Config.LogLevel
+	if knob == nil {
+		return nil, nil, ErrNoLogLevelKnob
+	}
+
+	config := make(map[string]string, len(p.Config)+1)
+	for k, v := range p.Config {
+		config[k] = v
+	}
+
+	if !debug {
+		if o := p.LogLevelOverride; o != nil {
+			if o.HadPrevious {
+				config[knob.Env] = o.Previous
+			} else {
+				delete(config, knob.Env)
+			}
+		}
+		return config, nil, nil
+	}
+
+	override := &PupLogLevelOverride{Value: knob.Debug, Until: until}
+	if p.LogLevelOverride != nil {
+		override.Previous = p.LogLevelOverride.Previous
+		override.HadPrevious = p.LogLevelOverride.HadPrevious
+	} else {
+		override.Previous, override.HadPrevious = p.Config[knob.Env]
+	}
+	config[knob.Env] = knob.Debug
+	return config, override, nil
+}
+
+// ExpiredPupLogLevelOverrides lists the pups whose debug logging should be
+// reverted.
+func ExpiredPupLogLevelOverrides(pups map[string]PupState, now time.Time) []string {
+	ids := []string{}
+	for _, p := range pups {
+		if p.LogLevelOverride != nil && p.LogLevelOverride.Expired(now) {
+			ids = append(ids, p.ID)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,79 @@
+package dogeboxd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func logLevelTestPup(config map[string]string) PupState {
+	p := PupState{ID: "abc", Config: config}
+	p.Manifest.Config.LogLevel = &PupManifestLogLevel{Env: "LOG_LEVEL", Debug: "debug"}
+	return p
+}
+
+func TestPupLogLevelConfigRevertsToPreviousValue(t *testing.T) {
+	p := logLevelTestPup(map[string]string{"LOG_LEVEL": "warn", "RPC_USER": "doge"})
+	until := time.Now().Add(time.Hour)
+
+	config, override, err := PupLogLevelConfig(p, true, until)
+	require.NoError(t, err)
+	assert.Equal(t, "debug", config["LOG_LEVEL"])
+	assert.Equal(t, "doge", config["RPC_USER"])
+	assert.Equal(t, "warn", p.Config["LOG_LEVEL"], "the pup's config shouldn't be modified")
+	require.NotNil(t, override)
+	assert.Equal(t, "warn", override.Previous)
+	assert.True(t, override.HadPrevious)
+
+	// Extending keeps what to revert to, not the debug value.
+	p.Config, p.LogLevelOverride = config, override
+	later := until.Add(time.Hour)
+	config, override, err = PupLogLevelConfig(p, true, later)
+	require.NoError(t, err)
+	assert.Equal(t, "warn", override.Previous)
+	assert.Equal(t, later, override.Until)
+
+	p.Config, p.LogLevelOverride = config, override
+	config, override, err = PupLogLevelConfig(p, false, time.Time{})
+	require.NoError(t, err)
+	assert.Nil(t, override)
+	assert.Equal(t, map[string]string{"LOG_LEVEL": "warn", "RPC_USER": "doge"}, config)
+}
+
+func TestPupLogLevelConfigRemovesUnsetKey(t *testing.T) {
+	p := logLevelTestPup(map[string]string{})
+
+	config, override, err := PupLogLevelConfig(p, true, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.False(t, override.HadPrevious)
+
+	p.Config, p.LogLevelOverride = config, override
+	config, _, err = PupLogLevelConfig(p, false, time.Time{})
+	require.NoError(t, err)
+	assert.NotContains(t, config, "LOG_LEVEL")
+}
+
+func TestPupLogLevelConfigRequiresManifestKnob(t *testing.T) {
+	_, _, err := PupLogLevelConfig(PupState{ID: "abc"}, true, time.Now())
+	assert.ErrorIs(t, err, ErrNoLogLevelKnob)
+}
+
+func TestExpiredPupLogLevelOverrides(t *testing.T) {
+	now := time.Now()
+	pups := map[string]PupState{
+		"expired": {ID: "expired", LogLevelOverride: &PupLogLevelOverride{Until: now.Add(-time.Minute)}},
+		"active":  {ID: "active", LogLevelOverride: &PupLogLevelOverride{Until: now.Add(time.Minute)}},
+		"none":    {ID: "none"},
+	}
+
+	assert.Equal(t, []string{"expired"}, ExpiredPupLogLevelOverrides(pups, now))
+}
+
+func TestPupManifestLogLevelValidate(t *testing.T) {
+	assert.NoError(t, PupManifestLogLevel{Env: "LOG_LEVEL", Debug: "debug"}.Validate())
+	assert.Error(t, PupManifestLogLevel{Env: "LOG LEVEL", Debug: "debug"}.Validate())
+	assert.Error(t, PupManifestLogLevel{Env: "LOG_LEVEL"}.Validate())
+	assert.Error(t, PupManifestLogLevel{Env: "LOG_LEVEL", Debug: "debug\nEVIL=1"}.Validate())
+}
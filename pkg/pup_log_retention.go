@@ -0,0 +1,470 @@
+package dogeboxd
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+ * Pup logs are appended to ContainerLogDir by each pup's journal
+ * follower (see pup_container.nix), which runs as root and never
+ * rotates them. The PupLogRotator renames a log that's grown past its
+ * limit and has the follower restarted, which picks up from its journal
+ * cursor in a new file, so no lines are lost. The renamed log is then
+ * compressed into a backup, and backups past their retention pruned.
+ */
+
+const (
+	pupLogDefaultMaxSizeMB  = 50
+	pupLogDefaultMaxAgeDays = 14
+	pupLogDefaultMaxBackups = 5
+
+	maxPupLogSizeMB     = 10240
+	maxPupLogAgeDays    = 3650
+	maxPupLogBackups    = 1000
+	pupLogRotateEvery   = 10 * time.Minute
+	pupLogBackupTimeFmt = "2006-01-02T15-04-05.000"
+	pupLogBackupExt     = ".gz"
+)
+
+// PupLogRetention is when a pup's log is rotated, and how many rotated
+// backups are kept, for how long. Zero values fall back to the global
+// retention, then our defaults.
+type PupLogRetention struct {
+	MaxSizeMB  int `json:"maxSizeMb"`
+	MaxAgeDays int `json:"maxAgeDays"`
+	MaxBackups int `json:"maxBackups"`
+}
+
+func (r PupLogRetention) Validate() error {
+	if r.MaxSizeMB < 0 || r.MaxSizeMB > maxPupLogSizeMB {
+		return fmt.Errorf("maxSizeMb must be between 0 and %d", maxPupLogSizeMB)
+	}
+	if r.MaxAgeDays < 0 || r.MaxAgeDays > maxPupLogAgeDays {
+		return fmt.Errorf("maxAgeDays must be between 0 and %d", maxPupLogAgeDays)
+	}
+	if r.MaxBackups < 0 || r.MaxBackups > maxPupLogBackups {
+		return fmt.Errorf("maxBackups must be between 0 and %d", maxPupLogBackups)
+	}
+	return nil
+}
+
+// WithDefaults fills in any unset limits.
+func (r PupLogRetention) WithDefaults() PupLogRetention {
+	if r.MaxSizeMB <= 0 {
+		r.MaxSizeMB = pupLogDefaultMaxSizeMB
+	}
+	if r.MaxAgeDays <= 0 {
+		r.MaxAgeDays = pupLogDefaultMaxAgeDays
+	}
+	if r.MaxBackups <= 0 {
+		r.MaxBackups = pupLogDefaultMaxBackups
+	}
+	return r
+}
+
+// EffectivePupLogRetention is the retention for a pup's log: anything it
+// sets itself, then the global retention, then our defaults.
+func EffectivePupLogRetention(global PupLogRetention, pup *PupLogRetention) PupLogRetention {
+	r := global
+	if pup != nil {
+		if pup.MaxSizeMB > 0 {
+			r.MaxSizeMB = pup.MaxSizeMB
+		}
+		if pup.MaxAgeDays > 0 {
+			r.MaxAgeDays = pup.MaxAgeDays
+		}
+		if pup.MaxBackups > 0 {
+			r.MaxBackups = pup.MaxBackups
+		}
+	}
+	return r.WithDefaults()
+}
+
+// PupLogUsage is how much disk a pup's logs are using.
+type PupLogUsage struct {
+	PupID       string          `json:"pupId"`
+	LiveBytes   int64           `json:"liveBytes"`
+	BackupBytes int64           `json:"backupBytes"`
+	Backups     int             `json:"backups"`
+	TotalBytes  int64           `json:"totalBytes"`
+	Retention   PupLogRetention `json:"retention"`
+}
+
+// A RotatedLog is a log the PupLogRotator has rotated out, compressed
+// unless it was interrupted before it got that far.
+type RotatedLog struct {
+	Path    string
+	Rotated time.Time
+	Size    int64
+}
+
+func (l RotatedLog) Compressed() bool { return strings.HasSuffix(l.Path, pupLogBackupExt) }
+
+/* RotatedLogs lists the logs rotated out of logPath, oldest first. They
+ * are named like lumberjack's backups, <log>-<timestamp>.gz, so sort by
+ * name.
+ */
+func RotatedLogs(logPath string) ([]RotatedLog, error) {
+	matches, err := filepath.Glob(logPath + "-*")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+
+	logs := []RotatedLog{}
+	for _, match := range matches {
+		stamp := strings.TrimSuffix(strings.TrimPrefix(match, logPath+"-"), pupLogBackupExt)
+		rotated, err := time.ParseInLocation(pupLogBackupTimeFmt, stamp, time.UTC)
+		if err != nil {
+			continue
+		}
+		info, err := os.Stat(match)
+		if err != nil {
+			continue
+		}
+		logs = append(logs, RotatedLog{Path: match, Rotated: rotated, Size: info.Size()})
+	}
+	return logs, nil
+}
+
+// OpenRotatedLog reads a rotated log, decompressing it if need be.
+func OpenRotatedLog(l RotatedLog) (io.ReadCloser, error) {
+	f, err := os.Open(l.Path)
+	if err != nil {
+		return nil, err
+	}
+	if !l.Compressed() {
+		return f, nil
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to decompress %s: %w", l.Path, err)
+	}
+	return readCloser{Reader: gz, close: func() error {
+		gz.Close()
+		return f.Close()
+	}}, nil
+}
+
+type readCloser struct {
+	io.Reader
+	close func() error
+}
+
+func (r readCloser) Close() error { return r.close() }
+
+/* OpenPupLog reads the whole of a pup's log, its rotated logs oldest
+ * first and then the live log, or fails with os.ErrNotExist if there's
+ * none of it. Each is only opened once the one before has been read.
+ */
+func OpenPupLog(config ServerConfig, pupID string) (io.ReadCloser, error) {
+	logPath := config.PupLogPath(pupID)
+	rotated, err := RotatedLogs(logPath)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(logPath); err != nil {
+		if !os.IsNotExist(err) || len(rotated) == 0 {
+			return nil, err
+		}
+	} else {
+		rotated = append(rotated, RotatedLog{Path: logPath})
+	}
+	return &pupLogReader{logs: rotated}, nil
+}
+
+type pupLogReader struct {
+	logs    []RotatedLog
+	current io.ReadCloser
+}
+
+func (r *pupLogReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if len(r.logs) == 0 {
+				return 0, io.EOF
+			}
+			l := r.logs[0]
+			r.logs = r.logs[1:]
+			current, err := OpenRotatedLog(l)
+			if os.IsNotExist(err) {
+				// Pruned since we listed it.
+				continue
+			}
+			if err != nil {
+				return 0, err
+			}
+			r.current = current
+		}
+
+		n, err := r.current.Read(p)
+		if err == io.EOF {
+			r.current.Close()
+			r.current = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (r *pupLogReader) Close() error {
+	if r.current == nil {
+		return nil
+	}
+	return r.current.Close()
+}
+
+// GetPupLogUsage sums up a pup's live log and its backups.
+func GetPupLogUsage(config ServerConfig, pupID string, retention PupLogRetention) (PupLogUsage, error) {
+	usage := PupLogUsage{PupID: pupID, Retention: retention}
+	if config.ContainerLogDir == "" {
+		return usage, nil
+	}
+
+	if info, err := os.Stat(config.PupLogPath(pupID)); err == nil {
+		usage.LiveBytes = info.Size()
+	} else if !os.IsNotExist(err) {
+		return usage, err
+	}
+
+	backups, err := RotatedLogs(config.PupLogPath(pupID))
+	if err != nil {
+		return usage, err
+	}
+	for _, b := range backups {
+		usage.BackupBytes += b.Size
+	}
+	usage.Backups = len(backups)
+	usage.TotalBytes = usage.LiveBytes + usage.BackupBytes
+	return usage, nil
+}
+
+/* RotatePupLog rotates a pup's log out if it's past retention.MaxSizeMB,
+ * returning whether it was. The log is renamed, then reopen has its
+ * follower start a new one, before it's compressed, so nothing the
+ * follower writes in between is lost. Rotated logs left uncompressed by
+ * an earlier failure are compressed first.
+ */
+func RotatePupLog(config ServerConfig, pupID string, retention PupLogRetention, now time.Time, reopen func() error) (bool, error) {
+	if config.ContainerLogDir == "" {
+		return false, nil
+	}
+
+	logPath := config.PupLogPath(pupID)
+	if err := compressRotatedPupLogs(logPath); err != nil {
+		return false, err
+	}
+
+	info, err := os.Stat(logPath)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if info.Size() < int64(retention.MaxSizeMB)<<20 {
+		return false, nil
+	}
+
+	rotatedPath := fmt.Sprintf("%s-%s", logPath, now.UTC().Format(pupLogBackupTimeFmt))
+	if err := os.Rename(logPath, rotatedPath); err != nil {
+		return false, fmt.Errorf("failed to rotate pup log: %w", err)
+	}
+	if err := reopen(); err != nil {
+		// The follower is still writing to it.
+		if _, statErr := os.Stat(logPath); os.IsNotExist(statErr) {
+			_ = os.Rename(rotatedPath, logPath)
+		}
+		return false, fmt.Errorf("failed to restart pup log follower: %w", err)
+	}
+
+	return true, compressRotatedPupLogs(logPath)
+}
+
+func compressRotatedPupLogs(logPath string) error {
+	rotated, err := RotatedLogs(logPath)
+	if err != nil {
+		return err
+	}
+	for _, l := range rotated {
+		if l.Compressed() {
+			continue
+		}
+		backupPath := l.Path + pupLogBackupExt
+		tmpPath := backupPath + ".tmp"
+		if err := writePupLogBackup(l.Path, tmpPath); err != nil {
+			os.Remove(tmpPath)
+			return err
+		}
+		if err := os.Rename(tmpPath, backupPath); err != nil {
+			os.Remove(tmpPath)
+			return fmt.Errorf("failed to save pup log backup: %w", err)
+		}
+		if err := os.Remove(l.Path); err != nil {
+			return fmt.Errorf("failed to remove rotated pup log: %w", err)
+		}
+	}
+	return nil
+}
+
+func writePupLogBackup(logPath string, backupPath string) error {
+	in, err := os.Open(logPath)
+	if err != nil {
+		return fmt.Errorf("failed to open pup log: %w", err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(backupPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0640)
+	if err != nil {
+		return fmt.Errorf("failed to create pup log backup: %w", err)
+	}
+	defer out.Close()
+
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		return fmt.Errorf("failed to write pup log backup: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to finalise pup log backup: %w", err)
+	}
+	return out.Close()
+}
+
+// PrunePupLogBackups removes a pup's backups older than
+// retention.MaxAgeDays, then the oldest past retention.MaxBackups.
+func PrunePupLogBackups(config ServerConfig, pupID string, retention PupLogRetention, now time.Time) (int, error) {
+	if config.ContainerLogDir == "" {
+		return 0, nil
+	}
+
+	backups, err := RotatedLogs(config.PupLogPath(pupID))
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := now.Add(-time.Duration(retention.MaxAgeDays) * 24 * time.Hour)
+	excess := len(backups) - retention.MaxBackups
+	removed := 0
+	for i, b := range backups {
+		if i >= excess && !b.Rotated.Before(cutoff) {
+			continue
+		}
+		if err := os.Remove(b.Path); err != nil && !os.IsNotExist(err) {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+/* PupLogRotator keeps every installed pup's logs within its
+ * PupLogRetention, checking them every pupLogRotateEvery.
+ */
+type PupLogRotator struct {
+	config ServerConfig
+	sm     StateManager
+	pups   PupManager
+	now    func() time.Time
+	// Restarts a pup's log follower, without it logs are only pruned.
+	reopen func(pupID string) error
+
+	// Only one pass over the logs at a time, ticks and API calls alike.
+	mu sync.Mutex
+}
+
+func NewPupLogRotator(config ServerConfig, sm StateManager, pups PupManager) *PupLogRotator {
+	return &PupLogRotator{
+		config: config,
+		sm:     sm,
+		pups:   pups,
+		now:    time.Now,
+	}
+}
+
+// SetLogReopener sets how a pup's log follower is restarted once its log
+// has been rotated, without going through rootd in tests.
+func (r *PupLogRotator) SetLogReopener(f func(pupID string) error) {
+	r.reopen = f
+}
+
+func (r *PupLogRotator) Run(started, stopped chan bool, stop chan context.Context) error {
+	go func() {
+		go func() {
+			r.Rotate()
+			ticker := time.NewTicker(pupLogRotateEvery)
+			defer ticker.Stop()
+		mainloop:
+			for {
+				select {
+				case <-stop:
+					break mainloop
+				case <-ticker.C:
+					r.Rotate()
+				}
+			}
+		}()
+
+		started <- true
+		<-stop
+		stopped <- true
+	}()
+	return nil
+}
+
+// Retention is the effective retention for a pup's log.
+func (r *PupLogRotator) Retention(p PupState) PupLogRetention {
+	return EffectivePupLogRetention(r.sm.Get().Dogebox.PupLogRetention, p.LogRetention)
+}
+
+// Rotate rotates and prunes every installed pup's logs, returning how
+// many backups were pruned.
+func (r *PupLogRotator) Rotate() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	pruned := 0
+	for id, p := range r.pups.GetStateMap() {
+		retention := r.Retention(p)
+		if r.reopen != nil {
+			reopen := func() error { return r.reopen(id) }
+			if _, err := RotatePupLog(r.config, id, retention, now, reopen); err != nil {
+				log.Printf("Failed to rotate log for pup %s: %v", id, err)
+			}
+		}
+		n, err := PrunePupLogBackups(r.config, id, retention, now)
+		if err != nil {
+			log.Printf("Failed to prune log backups for pup %s: %v", id, err)
+		}
+		pruned += n
+	}
+	return pruned
+}
+
+// Usage is the log disk usage of every installed pup.
+func (r *PupLogRotator) Usage() ([]PupLogUsage, error) {
+	usage := []PupLogUsage{}
+	for id, p := range r.pups.GetStateMap() {
+		u, err := GetPupLogUsage(r.config, id, r.Retention(p))
+		if err != nil {
+			return nil, err
+		}
+		usage = append(usage, u)
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].TotalBytes > usage[j].TotalBytes })
+	return usage, nil
+}
@@ -0,0 +1,202 @@
+package dogeboxd
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPupLogRetentionValidate(t *testing.T) {
+	assert.NoError(t, PupLogRetention{}.Validate())
+	assert.NoError(t, PupLogRetention{MaxSizeMB: 100, MaxAgeDays: 30, MaxBackups: 10}.Validate())
+	assert.ErrorContains(t, PupLogRetention{MaxSizeMB: -1}.Validate(), "maxSizeMb")
+	assert.ErrorContains(t, PupLogRetention{MaxAgeDays: maxPupLogAgeDays + 1}.Validate(), "maxAgeDays")
+	assert.ErrorContains(t, PupLogRetention{MaxBackups: maxPupLogBackups + 1}.Validate(), "maxBackups")
+}
+
+func TestEffectivePupLogRetention(t *testing.T) {
+	defaults := PupLogRetention{}.WithDefaults()
+	assert.Equal(t, defaults, EffectivePupLogRetention(PupLogRetention{}, nil))
+
+	global := PupLogRetention{MaxSizeMB: 10, MaxAgeDays: 7}
+	assert.Equal(t, PupLogRetention{MaxSizeMB: 10, MaxAgeDays: 7, MaxBackups: defaults.MaxBackups}, EffectivePupLogRetention(global, nil))
+	assert.Equal(t, PupLogRetention{MaxSizeMB: 200, MaxAgeDays: 7, MaxBackups: 2}, EffectivePupLogRetention(global, &PupLogRetention{MaxSizeMB: 200, MaxBackups: 2}))
+}
+
+func TestRotatePupLog(t *testing.T) {
+	config := ServerConfig{ContainerLogDir: t.TempDir()}
+	retention := PupLogRetention{MaxSizeMB: 1}.WithDefaults()
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	// The follower keeps writing to the renamed log until it's restarted.
+	reopened := 0
+	late := "written before the restart\n"
+	reopen := func() error {
+		reopened++
+		f, err := os.OpenFile(config.PupLogPath("core")+"-2026-10-01T12-00-00.000", os.O_APPEND|os.O_WRONLY, 0)
+		require.NoError(t, err)
+		defer f.Close()
+		_, err = f.WriteString(late)
+		return err
+	}
+
+	// Under the limit, left alone.
+	require.NoError(t, os.WriteFile(config.PupLogPath("core"), []byte("small\n"), 0644))
+	rotated, err := RotatePupLog(config, "core", retention, now, reopen)
+	require.NoError(t, err)
+	assert.False(t, rotated)
+	assert.Zero(t, reopened)
+
+	big := strings.Repeat("a log line\n", 100000)
+	require.NoError(t, os.WriteFile(config.PupLogPath("core"), []byte(big), 0644))
+	rotated, err = RotatePupLog(config, "core", retention, now, reopen)
+	require.NoError(t, err)
+	assert.True(t, rotated)
+	assert.Equal(t, 1, reopened)
+
+	// Renamed away, the follower starts a new one.
+	assert.NoFileExists(t, config.PupLogPath("core"))
+	assert.NoFileExists(t, config.PupLogPath("core")+"-2026-10-01T12-00-00.000")
+
+	backup, err := os.Open(config.PupLogPath("core") + "-2026-10-01T12-00-00.000.gz")
+	require.NoError(t, err)
+	defer backup.Close()
+	gz, err := gzip.NewReader(backup)
+	require.NoError(t, err)
+	contents, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	assert.Equal(t, big+late, string(contents))
+
+	// Nothing to rotate for a pup without a log.
+	rotated, err = RotatePupLog(config, "missing", retention, now, reopen)
+	require.NoError(t, err)
+	assert.False(t, rotated)
+}
+
+func TestRotatePupLogPutsItBackIfTheFollowerIsntRestarted(t *testing.T) {
+	config := ServerConfig{ContainerLogDir: t.TempDir()}
+	retention := PupLogRetention{MaxSizeMB: 1}.WithDefaults()
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	big := strings.Repeat("a log line\n", 100000)
+	require.NoError(t, os.WriteFile(config.PupLogPath("core"), []byte(big), 0644))
+	rotated, err := RotatePupLog(config, "core", retention, now, func() error { return errors.New("rootd is down") })
+	assert.ErrorContains(t, err, "rootd is down")
+	assert.False(t, rotated)
+
+	contents, err := os.ReadFile(config.PupLogPath("core"))
+	require.NoError(t, err)
+	assert.Equal(t, big, string(contents))
+	rotatedLogs, err := RotatedLogs(config.PupLogPath("core"))
+	require.NoError(t, err)
+	assert.Empty(t, rotatedLogs)
+}
+
+func TestOpenPupLog(t *testing.T) {
+	config := ServerConfig{ContainerLogDir: t.TempDir()}
+	_, err := OpenPupLog(config, "core")
+	assert.True(t, os.IsNotExist(err))
+
+	backup, err := os.Create(config.PupLogPath("core") + "-2026-10-01T12-00-00.000.gz")
+	require.NoError(t, err)
+	gz := gzip.NewWriter(backup)
+	_, err = gz.Write([]byte("first\n"))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	require.NoError(t, backup.Close())
+	// Left uncompressed by an interrupted rotation.
+	require.NoError(t, os.WriteFile(config.PupLogPath("core")+"-2026-10-02T12-00-00.000", []byte("second\n"), 0644))
+	require.NoError(t, os.WriteFile(config.PupLogPath("core"), []byte("live\n"), 0644))
+
+	r, err := OpenPupLog(config, "core")
+	require.NoError(t, err)
+	defer r.Close()
+	contents, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "first\nsecond\nlive\n", string(contents))
+}
+
+func writeTestPupLogBackups(t *testing.T, config ServerConfig, pupID string, created ...time.Time) {
+	for _, c := range created {
+		path := config.PupLogPath(pupID) + "-" + c.UTC().Format(pupLogBackupTimeFmt) + pupLogBackupExt
+		require.NoError(t, os.WriteFile(path, []byte("backup"), 0640))
+	}
+}
+
+func TestPrunePupLogBackups(t *testing.T) {
+	config := ServerConfig{ContainerLogDir: t.TempDir()}
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	writeTestPupLogBackups(t, config, "core", now.Add(-30*day), now.Add(-3*day), now.Add(-2*day), now.Add(-day))
+	// Another pup's backups, and anything else, are left alone.
+	writeTestPupLogBackups(t, config, "core-extra", now.Add(-30*day))
+	require.NoError(t, os.WriteFile(config.PupLogPath("core")+"-notes.gz", nil, 0640))
+
+	removed, err := PrunePupLogBackups(config, "core", PupLogRetention{MaxAgeDays: 14, MaxBackups: 2}, now)
+	require.NoError(t, err)
+	assert.Equal(t, 2, removed)
+
+	matches, err := filepath.Glob(filepath.Join(config.ContainerLogDir, "*.gz"))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{
+		config.PupLogPath("core") + "-" + now.Add(-2*day).Format(pupLogBackupTimeFmt) + ".gz",
+		config.PupLogPath("core") + "-" + now.Add(-day).Format(pupLogBackupTimeFmt) + ".gz",
+		config.PupLogPath("core-extra") + "-" + now.Add(-30*day).Format(pupLogBackupTimeFmt) + ".gz",
+		config.PupLogPath("core") + "-notes.gz",
+	}, matches)
+}
+
+type pupLogStateManager struct {
+	StateManager
+	dbx DogeboxState
+}
+
+func (m pupLogStateManager) Get() State { return State{Dogebox: m.dbx} }
+
+type pupLogPupManager struct {
+	PupManager
+	states map[string]PupState
+}
+
+func (m pupLogPupManager) GetStateMap() map[string]PupState { return m.states }
+
+func TestPupLogRotatorUsesPupRetention(t *testing.T) {
+	config := ServerConfig{ContainerLogDir: t.TempDir()}
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	sm := pupLogStateManager{dbx: DogeboxState{PupLogRetention: PupLogRetention{MaxBackups: 1}}}
+	pups := pupLogPupManager{states: map[string]PupState{
+		"core":  {ID: "core", LogRetention: &PupLogRetention{MaxBackups: 3}},
+		"other": {ID: "other"},
+	}}
+	r := NewPupLogRotator(config, sm, pups)
+	r.now = func() time.Time { return now }
+	r.SetLogReopener(func(pupID string) error { return nil })
+
+	for _, id := range []string{"core", "other"} {
+		writeTestPupLogBackups(t, config, id, now.Add(-3*time.Hour), now.Add(-2*time.Hour), now.Add(-time.Hour))
+		require.NoError(t, os.WriteFile(config.PupLogPath(id), []byte("live\n"), 0644))
+	}
+
+	assert.Equal(t, 2, r.Rotate())
+
+	usage, err := r.Usage()
+	require.NoError(t, err)
+	require.Len(t, usage, 2)
+	byID := map[string]PupLogUsage{}
+	for _, u := range usage {
+		byID[u.PupID] = u
+	}
+	assert.Equal(t, 3, byID["core"].Backups)
+	assert.Equal(t, 1, byID["other"].Backups)
+	assert.Equal(t, int64(5), byID["other"].LiveBytes)
+	assert.Equal(t, int64(5+6), byID["other"].TotalBytes)
+	assert.Equal(t, 3, byID["core"].Retention.MaxBackups)
+}
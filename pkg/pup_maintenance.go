@@ -0,0 +1,95 @@
+package dogeboxd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var ErrPupInMaintenance = errors.New("pup is in maintenance mode")
+
+/* PupMaintenance marks an enabled pup as intentionally offline. Its
+ * container stays in the nix config, so leaving maintenance needn't
+ * rebuild anything, but it's stopped and not started on boot. Unlike a
+ * broken or stopped pup, it isn't health checked, auto-updated or
+ * restarted after its providers upgrade, and pups depending on it report
+ * it as in maintenance rather than not running.
+ */
+type PupMaintenance struct {
+	Since  time.Time `json:"since"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// InMaintenance reports whether the pup has been put in maintenance mode.
+func (p PupState) InMaintenance() bool {
+	return p.Maintenance != nil
+}
+
+// Puts a pup in maintenance mode, or takes it out with nil.
+func PupMaintenanceMode(m *PupMaintenance) func(*PupState, *[]Pupdate) {
+	return func(p *PupState, pu *[]Pupdate) {
+		p.Maintenance = m
+		*pu = append(*pu, Pupdate{
+			ID:    p.ID,
+			Event: PUP_CHANGED_INSTALLATION,
+			State: *p,
+		})
+	}
+}
+
+// The longest reason a pup can be put in maintenance for.
+const MAX_PUP_MAINTENANCE_REASON = 200
+
+/* dispatchSetPupMaintenance marks the pup straight away, as EnablePup
+ * and DisablePup flip the enabled flag, so monitoring and update checks
+ * treat it as intentionally offline while the job waits in the queue.
+ */
+func (t Dogeboxd) dispatchSetPupMaintenance(j Job, a SetPupMaintenance) {
+	p, _, err := t.Pups.GetPup(a.PupID)
+	if err != nil {
+		j.Err = err.Error()
+		t.sendFinishedJob("action", j)
+		return
+	}
+
+	var m *PupMaintenance
+	if a.Enabled {
+		reason := strings.TrimSpace(a.Reason)
+		switch {
+		case !p.Enabled:
+			j.Err = fmt.Sprintf("Can't put %s in maintenance, it's disabled", p.DisplayName())
+		case p.Installation != STATE_READY:
+			j.Err = fmt.Sprintf("Can't put %s in maintenance while it's %s", p.DisplayName(), p.Installation)
+		case len(reason) > MAX_PUP_MAINTENANCE_REASON:
+			j.Err = fmt.Sprintf("Maintenance reason must be %d characters or less", MAX_PUP_MAINTENANCE_REASON)
+		}
+		if j.Err != "" {
+			t.sendFinishedJob("action", j)
+			return
+		}
+
+		m = &PupMaintenance{Since: time.Now(), Reason: reason}
+		if p.InMaintenance() {
+			m.Since = p.Maintenance.Since
+		}
+	}
+
+	if _, err := t.Pups.UpdatePup(a.PupID, PupMaintenanceMode(m), WithPupdateReason(PupdateReasonFor(j))); err != nil {
+		j.Err = fmt.Sprintf("Failed to set maintenance=%t: %v", a.Enabled, err)
+		t.sendFinishedJob("action", j)
+		return
+	}
+	t.sendSystemJobWithPupDetails(j, a.PupID)
+}
+
+// refusePupInMaintenance fails jobs that would start a pup in maintenance.
+func (t Dogeboxd) refusePupInMaintenance(j Job, pupID string) bool {
+	p, _, err := t.Pups.GetPup(pupID)
+	if err != nil || !p.InMaintenance() {
+		return false
+	}
+	j.Err = fmt.Sprintf("Can't %s %s: %s", j.A.ActionName(), p.DisplayName(), ErrPupInMaintenance)
+	t.sendFinishedJob("action", j)
+	return true
+}
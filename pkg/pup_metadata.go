@@ -0,0 +1,82 @@
+package dogeboxd
+
+/* PupMetadata is what a pup can find out about itself and the box it runs
+ * on from the internal router's metadata endpoint, GET /dbx/metadata at
+ * DBX_METADATA_URL, much like a cloud instance's metadata service. The
+ * router knows which pup is asking from its IP, so a pup only ever sees
+ * its own. Config values aren't included, only the declared schema, as
+ * pups are given their config in config.env.
+ */
+type PupMetadata struct {
+	PupID        string `json:"pupId"`
+	Name         string `json:"name"`
+	Version      string `json:"version"`
+	InstanceName string `json:"instanceName,omitempty"`
+	IP           string `json:"ip"`
+	// The interfaces this pup provides.
+	Interfaces []PupManifestInterface `json:"interfaces"`
+	// The pups providing the interfaces this pup depends on.
+	Providers []PupMetadataProvider `json:"providers"`
+	// The config this pup declares, without values.
+	ConfigSchema []PupManifestConfigSection `json:"configSchema"`
+	Box          PupMetadataBox             `json:"box"`
+}
+
+type PupMetadataProvider struct {
+	Interface string `json:"interface"`
+	PupID     string `json:"pupId"`
+	Name      string `json:"name"`
+	Host      string `json:"host"`
+	// 0 if the provider doesn't expose the interface on a port.
+	Port int `json:"port"`
+}
+
+type PupMetadataBox struct {
+	Architecture string `json:"architecture"`
+	Version      string `json:"version"`
+}
+
+// BuildPupMetadata returns p's metadata, looking up its providers in pups.
+func BuildPupMetadata(p PupState, pups map[string]PupState, box PupMetadataBox) PupMetadata {
+	m := PupMetadata{
+		PupID:        p.ID,
+		Name:         p.Manifest.Meta.Name,
+		Version:      p.Version,
+		InstanceName: p.InstanceName,
+		IP:           p.IP,
+		Interfaces:   p.Manifest.Interfaces,
+		Providers:    []PupMetadataProvider{},
+		ConfigSchema: p.Manifest.Config.Sections,
+		Box:          box,
+	}
+	if m.Interfaces == nil {
+		m.Interfaces = []PupManifestInterface{}
+	}
+	if m.ConfigSchema == nil {
+		m.ConfigSchema = []PupManifestConfigSection{}
+	}
+
+	for _, dep := range p.Manifest.Dependencies {
+		provider, ok := pups[p.Providers[dep.InterfaceName]]
+		if !ok {
+			continue
+		}
+		mp := PupMetadataProvider{
+			Interface: dep.InterfaceName,
+			PupID:     provider.ID,
+			Name:      provider.Manifest.Meta.Name,
+			Host:      provider.IP,
+		}
+	outer:
+		for _, expose := range provider.Manifest.Container.Exposes {
+			for _, iface := range expose.Interfaces {
+				if iface == dep.InterfaceName {
+					mp.Port = expose.Port
+					break outer
+				}
+			}
+		}
+		m.Providers = append(m.Providers, mp)
+	}
+	return m
+}
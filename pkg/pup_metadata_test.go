@@ -0,0 +1,54 @@
+package dogeboxd
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildPupMetadata(t *testing.T) {
+	core := PupState{
+		ID: "core",
+		IP: "10.69.0.2",
+		Manifest: PupManifest{
+			Meta: PupManifestMeta{Name: "Dogecoin Core"},
+			Container: PupManifestContainer{Exposes: []PupManifestExposeConfig{
+				{Port: 8080, WebUI: true},
+				{Port: 22555, Interfaces: []string{"core-rpc"}},
+			}},
+		},
+	}
+	p := PupState{
+		ID:        "app",
+		IP:        "10.69.0.3",
+		Version:   "1.2.0",
+		Config:    map[string]string{"apiKey": "secret"},
+		Providers: map[string]string{"core-rpc": "core", "core-zmq": "gone"},
+		Manifest: PupManifest{
+			Meta: PupManifestMeta{Name: "App"},
+			Config: PupManifestConfigFields{Sections: []PupManifestConfigSection{
+				{Name: "main", Fields: []PupManifestConfigField{{Name: "apiKey", Type: "password"}}},
+			}},
+			Dependencies: []PupManifestDependency{
+				{InterfaceName: "core-rpc"},
+				{InterfaceName: "core-zmq"},
+			},
+		},
+	}
+	pups := map[string]PupState{"core": core, "app": p}
+
+	m := BuildPupMetadata(p, pups, PupMetadataBox{Architecture: "x86_64", Version: "v0.9.0"})
+	assert.Equal(t, "app", m.PupID)
+	assert.Equal(t, "10.69.0.3", m.IP)
+	assert.Equal(t, []PupMetadataProvider{{Interface: "core-rpc", PupID: "core", Name: "Dogecoin Core", Host: "10.69.0.2", Port: 22555}}, m.Providers)
+	assert.Equal(t, "apiKey", m.ConfigSchema[0].Fields[0].Name)
+	assert.Empty(t, m.Interfaces)
+	assert.NotNil(t, m.Interfaces)
+	assert.Equal(t, "x86_64", m.Box.Architecture)
+
+	// Config values stay in config.env.
+	b, err := json.Marshal(m)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(b), "secret")
+}
@@ -0,0 +1,80 @@
+package dogeboxd
+
+import (
+	"fmt"
+	"strconv"
+)
+
+const (
+	PUP_SERVICE_READINESS_NOTIFY = "notify"
+	// systemd's own DefaultTimeoutStartSec.
+	DEFAULT_PUP_READY_TIMEOUT_SECONDS = 90
+	MAX_PUP_READY_TIMEOUT_SECONDS     = 3600
+)
+
+/* PupManifestServiceReadiness is how a service tells systemd it has
+ * started. A "notify" service implements sd_notify and sends READY=1 once
+ * it's ready to serve, rather than being taken as started as soon as its
+ * process is. The container only finishes starting once all of them have,
+ * so a pup with notify services is running when they're ready, not when
+ * they've been launched.
+ */
+type PupManifestServiceReadiness struct {
+	Type string `json:"type"`
+	// Optional. How long the service has to report ready before it's
+	// restarted, systemd's default 90 seconds otherwise.
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+}
+
+func (r PupManifestServiceReadiness) Validate() error {
+	if r.Type != PUP_SERVICE_READINESS_NOTIFY {
+		return fmt.Errorf("readiness type %q isn't supported, must be %q", r.Type, PUP_SERVICE_READINESS_NOTIFY)
+	}
+	if r.TimeoutSeconds < 0 || r.TimeoutSeconds > MAX_PUP_READY_TIMEOUT_SECONDS {
+		return fmt.Errorf("readiness timeoutSeconds must be between 1 and %d, or left out", MAX_PUP_READY_TIMEOUT_SECONDS)
+	}
+	return nil
+}
+
+// Notifies reports whether the service sends READY=1 itself.
+func (s PupManifestService) Notifies() bool {
+	return s.Readiness != nil && s.Readiness.Type == PUP_SERVICE_READINESS_NOTIFY
+}
+
+// ReadyTimeoutSeconds is how long a notify service has to report ready,
+// 0 for services that don't notify.
+func (s PupManifestService) ReadyTimeoutSeconds() int {
+	if !s.Notifies() {
+		return 0
+	}
+	if s.Readiness.TimeoutSeconds > 0 {
+		return s.Readiness.TimeoutSeconds
+	}
+	return DEFAULT_PUP_READY_TIMEOUT_SECONDS
+}
+
+// NotifiesReadiness reports whether any of the pup's services send
+// READY=1, making systemd's view of its container authoritative.
+func (p PupState) NotifiesReadiness() bool {
+	for _, s := range p.Manifest.Container.Services {
+		if s.Notifies() {
+			return true
+		}
+	}
+	return false
+}
+
+// ContainerStartTimeout is a systemd TimeoutStartSec for the pup's
+// container, long enough for its slowest notify service to report ready,
+// empty for the default.
+func (p PupState) ContainerStartTimeout() string {
+	timeout := 0
+	for _, s := range p.Manifest.Container.Services {
+		timeout = max(timeout, s.ReadyTimeoutSeconds())
+	}
+	if timeout == 0 {
+		return ""
+	}
+	// Leave the container time to boot everything else too.
+	return strconv.Itoa(timeout+30) + "s"
+}
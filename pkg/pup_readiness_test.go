@@ -0,0 +1,32 @@
+package dogeboxd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPupManifestServiceReadinessValidate(t *testing.T) {
+	assert.NoError(t, PupManifestServiceReadiness{Type: "notify"}.Validate())
+	assert.NoError(t, PupManifestServiceReadiness{Type: "notify", TimeoutSeconds: 600}.Validate())
+
+	assert.Error(t, PupManifestServiceReadiness{Type: "simple"}.Validate())
+	assert.Error(t, PupManifestServiceReadiness{Type: "notify", TimeoutSeconds: -1}.Validate())
+	assert.Error(t, PupManifestServiceReadiness{Type: "notify", TimeoutSeconds: MAX_PUP_READY_TIMEOUT_SECONDS + 1}.Validate())
+}
+
+func TestPupStateReadiness(t *testing.T) {
+	p := PupState{}
+	p.Manifest.Container.Services = []PupManifestService{{Name: "web"}}
+	assert.False(t, p.NotifiesReadiness())
+	assert.Equal(t, "", p.ContainerStartTimeout())
+
+	p.Manifest.Container.Services = append(p.Manifest.Container.Services,
+		PupManifestService{Name: "dogecoind", Readiness: &PupManifestServiceReadiness{Type: "notify"}})
+	assert.True(t, p.NotifiesReadiness())
+	assert.Equal(t, DEFAULT_PUP_READY_TIMEOUT_SECONDS, p.Manifest.Container.Services[1].ReadyTimeoutSeconds())
+	assert.Equal(t, "120s", p.ContainerStartTimeout())
+
+	p.Manifest.Container.Services[1].Readiness.TimeoutSeconds = 600
+	assert.Equal(t, "630s", p.ContainerStartTimeout())
+}
@@ -0,0 +1,62 @@
+package dogeboxd
+
+import (
+	"fmt"
+	"strconv"
+)
+
+const (
+	MIN_PUP_CPU_PERCENT = 5
+	MAX_PUP_CPU_PERCENT = 6400
+	MIN_PUP_MEMORY_MB   = 64
+	MAX_PUP_MEMORY_MB   = 1024 * 1024
+)
+
+/* PupResourceLimits caps the CPU and memory a pup's container can use,
+ * via systemd's cgroup settings on its container@ unit. A pup's manifest
+ * can suggest limits, and the user can override them per pup. Zero means
+ * no limit.
+ */
+type PupResourceLimits struct {
+	// CPU time as a percentage of one core, ie: 150 is one and a half cores.
+	CPUPercent int `json:"cpuPercent,omitempty"`
+	MemoryMB   int `json:"memoryMb,omitempty"`
+}
+
+func (l PupResourceLimits) Validate() error {
+	if l.CPUPercent != 0 && (l.CPUPercent < MIN_PUP_CPU_PERCENT || l.CPUPercent > MAX_PUP_CPU_PERCENT) {
+		return fmt.Errorf("cpuPercent must be between %d and %d, or 0 for no limit", MIN_PUP_CPU_PERCENT, MAX_PUP_CPU_PERCENT)
+	}
+	if l.MemoryMB != 0 && (l.MemoryMB < MIN_PUP_MEMORY_MB || l.MemoryMB > MAX_PUP_MEMORY_MB) {
+		return fmt.Errorf("memoryMb must be between %d and %d, or 0 for no limit", MIN_PUP_MEMORY_MB, MAX_PUP_MEMORY_MB)
+	}
+	return nil
+}
+
+// CPUQuota is the limit as a systemd CPUQuota, empty for no limit.
+func (l PupResourceLimits) CPUQuota() string {
+	if l.CPUPercent == 0 {
+		return ""
+	}
+	return strconv.Itoa(l.CPUPercent) + "%"
+}
+
+// MemoryMax is the limit as a systemd MemoryMax, empty for no limit.
+func (l PupResourceLimits) MemoryMax() string {
+	if l.MemoryMB == 0 {
+		return ""
+	}
+	return strconv.Itoa(l.MemoryMB) + "M"
+}
+
+// ResourceLimits returns the limits the pup runs with, the user's override
+// if there is one, otherwise whatever its manifest asks for.
+func (p PupState) ResourceLimits() PupResourceLimits {
+	if p.ResourceLimitsOverride != nil {
+		return *p.ResourceLimitsOverride
+	}
+	if p.Manifest.Container.ResourceLimits != nil {
+		return *p.Manifest.Container.ResourceLimits
+	}
+	return PupResourceLimits{}
+}
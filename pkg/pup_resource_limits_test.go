@@ -0,0 +1,36 @@
+package dogeboxd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPupResourceLimitsValidate(t *testing.T) {
+	assert.NoError(t, PupResourceLimits{}.Validate())
+	assert.NoError(t, PupResourceLimits{CPUPercent: 150, MemoryMB: 512}.Validate())
+
+	assert.Error(t, PupResourceLimits{CPUPercent: 1}.Validate())
+	assert.Error(t, PupResourceLimits{CPUPercent: MAX_PUP_CPU_PERCENT + 1}.Validate())
+	assert.Error(t, PupResourceLimits{MemoryMB: 16}.Validate())
+	assert.Error(t, PupResourceLimits{MemoryMB: -1}.Validate())
+}
+
+func TestPupResourceLimitsSystemdValues(t *testing.T) {
+	assert.Equal(t, "", PupResourceLimits{}.CPUQuota())
+	assert.Equal(t, "", PupResourceLimits{}.MemoryMax())
+	assert.Equal(t, "150%", PupResourceLimits{CPUPercent: 150}.CPUQuota())
+	assert.Equal(t, "512M", PupResourceLimits{MemoryMB: 512}.MemoryMax())
+}
+
+func TestPupStateResourceLimitsPrefersOverride(t *testing.T) {
+	p := PupState{}
+	assert.Equal(t, PupResourceLimits{}, p.ResourceLimits())
+
+	p.Manifest.Container.ResourceLimits = &PupResourceLimits{CPUPercent: 100, MemoryMB: 1024}
+	assert.Equal(t, PupResourceLimits{CPUPercent: 100, MemoryMB: 1024}, p.ResourceLimits())
+
+	// An override replaces the manifest's limits, so it can lift them too.
+	p.ResourceLimitsOverride = &PupResourceLimits{MemoryMB: 256}
+	assert.Equal(t, PupResourceLimits{MemoryMB: 256}, p.ResourceLimits())
+}
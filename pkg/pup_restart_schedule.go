@@ -0,0 +1,28 @@
+package dogeboxd
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// A restart schedule is a systemd OnCalendar expression, eg: "Sun *-*-* 04:00:00"
+// for weekly at 4am. We only allow the characters calendar expressions need, as
+// the schedule is written verbatim into the pup's nix config.
+var restartScheduleRegex = regexp.MustCompile(`^[A-Za-z0-9 *:,./~+-]+$`)
+
+const MAX_RESTART_SCHEDULE_LENGTH = 64
+
+// ValidateRestartSchedule checks a restart schedule is safe to write into
+// nix config. An empty schedule is valid and means "never restart".
+func ValidateRestartSchedule(schedule string) error {
+	if schedule == "" {
+		return nil
+	}
+	if len(schedule) > MAX_RESTART_SCHEDULE_LENGTH {
+		return fmt.Errorf("restart schedule must be at most %d characters", MAX_RESTART_SCHEDULE_LENGTH)
+	}
+	if !restartScheduleRegex.MatchString(schedule) {
+		return fmt.Errorf("invalid restart schedule %q, expected a systemd calendar expression", schedule)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package dogeboxd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateRestartSchedule(t *testing.T) {
+	valid := []string{
+		"",
+		"weekly",
+		"Sun *-*-* 04:00:00",
+		"Mon..Fri *-*-* 03:30",
+		"*-*-01 04:00:00",
+	}
+	for _, s := range valid {
+		assert.NoError(t, ValidateRestartSchedule(s), s)
+	}
+
+	invalid := []string{
+		`daily"; ExecStart = "rm -rf /`,
+		"${pkgs.bash}",
+		"daily\n",
+		strings.Repeat("a", MAX_RESTART_SCHEDULE_LENGTH+1),
+	}
+	for _, s := range invalid {
+		assert.Error(t, ValidateRestartSchedule(s), s)
+	}
+}
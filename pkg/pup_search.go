@@ -0,0 +1,147 @@
+package dogeboxd
+
+import (
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+/* SearchPups finds pups across every source in lists whose name, tags or
+ * descriptions match query, returning the latest version of each, best
+ * match first. Every word of the query has to match somewhere, and a pup
+ * scores higher the closer its name is to the query. Results that are
+ * already installed from the same source are marked as such.
+ */
+
+const (
+	searchScoreNameExact        = 100
+	searchScoreNamePrefix       = 50
+	searchScoreName             = 30
+	searchScoreTag              = 20
+	searchScoreShortDescription = 10
+	searchScoreLongDescription  = 5
+)
+
+type PupSearchResult struct {
+	SourceID         string   `json:"sourceId"`
+	SourceName       string   `json:"sourceName"`
+	Name             string   `json:"name"`
+	Version          string   `json:"version"`
+	ShortDescription string   `json:"shortDescription"`
+	Tags             []string `json:"tags"`
+	LogoBase64       string   `json:"logoBase64"`
+	Score            int      `json:"score"`
+	// The IDs of installed pups from this source with this name.
+	InstalledPupIDs []string `json:"installedPupIds"`
+}
+
+func SearchPups(lists map[string]ManifestSourceList, installed map[string]PupState, query string) []PupSearchResult {
+	terms := strings.Fields(strings.ToLower(query))
+	if len(terms) == 0 {
+		return []PupSearchResult{}
+	}
+
+	results := []PupSearchResult{}
+	for sourceID, list := range lists {
+		latest := map[string]ManifestSourcePup{}
+		for _, p := range list.Pups {
+			if l, ok := latest[p.Name]; ok && semver.Compare("v"+p.Version, "v"+l.Version) <= 0 {
+				continue
+			}
+			latest[p.Name] = p
+		}
+
+		for _, p := range latest {
+			score, ok := searchScore(p, terms)
+			if !ok {
+				continue
+			}
+			tags := p.Manifest.Meta.Tags
+			if tags == nil {
+				tags = []string{}
+			}
+			results = append(results, PupSearchResult{
+				SourceID:         sourceID,
+				SourceName:       list.Config.Name,
+				Name:             p.Name,
+				Version:          p.Version,
+				ShortDescription: p.Manifest.Meta.ShortDescription,
+				Tags:             tags,
+				LogoBase64:       p.LogoBase64,
+				Score:            score,
+				InstalledPupIDs:  installedFromSource(installed, list.Config, p.Name),
+			})
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		// The same pup from more than one source, see SortSourceConfigurations.
+		if pa, pb := lists[a.SourceID].Config.Priority, lists[b.SourceID].Config.Priority; pa != pb {
+			return pa < pb
+		}
+		return a.SourceID < b.SourceID
+	})
+	return results
+}
+
+// searchScore returns how well p matches terms, or false if any term
+// doesn't match at all.
+func searchScore(p ManifestSourcePup, terms []string) (int, bool) {
+	name := strings.ToLower(p.Name)
+	short := strings.ToLower(p.Manifest.Meta.ShortDescription)
+	long := strings.ToLower(p.Manifest.Meta.LongDescription)
+
+	total := 0
+	for _, term := range terms {
+		score := 0
+		switch {
+		case name == term:
+			score = searchScoreNameExact
+		case strings.HasPrefix(name, term):
+			score = searchScoreNamePrefix
+		case strings.Contains(name, term):
+			score = searchScoreName
+		}
+		for _, tag := range p.Manifest.Meta.Tags {
+			if strings.ToLower(tag) == term {
+				score += searchScoreTag
+				break
+			}
+		}
+		if strings.Contains(short, term) {
+			score += searchScoreShortDescription
+		}
+		if strings.Contains(long, term) {
+			score += searchScoreLongDescription
+		}
+		if score == 0 {
+			return 0, false
+		}
+		total += score
+	}
+
+	// A query naming the pup outright beats one that only matches its words.
+	if name == strings.Join(terms, " ") {
+		total += searchScoreNameExact
+	}
+	return total, true
+}
+
+func installedFromSource(installed map[string]PupState, source ManifestSourceConfiguration, name string) []string {
+	ids := []string{}
+	for id, s := range installed {
+		if s.Source.ID == source.ID && s.Source.Location == source.Location && s.Manifest.Meta.Name == name {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,70 @@
+package dogeboxd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func searchPup(name, version, short string, tags ...string) ManifestSourcePup {
+	return ManifestSourcePup{
+		Name:    name,
+		Version: version,
+		Manifest: PupManifest{Meta: PupManifestMeta{
+			Name:             name,
+			Version:          version,
+			ShortDescription: short,
+			Tags:             tags,
+		}},
+	}
+}
+
+func searchNames(results []PupSearchResult) []string {
+	names := []string{}
+	for _, r := range results {
+		names = append(names, r.SourceID+"/"+r.Name+"@"+r.Version)
+	}
+	return names
+}
+
+func TestSearchPups(t *testing.T) {
+	core := ManifestSourceConfiguration{ID: "core", Name: "Core", Location: "https://example.com/core.git"}
+	lists := map[string]ManifestSourceList{
+		"core": {Config: core, Pups: []ManifestSourcePup{
+			searchPup("Dogecoin Core", "1.0.0", "A full node"),
+			searchPup("Dogecoin Core", "1.2.0", "A full node"),
+			searchPup("Dogenet", "0.1.0", "Gossip network for dogecoin nodes", "network"),
+			searchPup("Identity", "0.1.0", "Manage your profile"),
+		}},
+		"extra": {Config: ManifestSourceConfiguration{ID: "extra", Name: "Extra"}, Pups: []ManifestSourcePup{
+			searchPup("Wallet", "2.0.0", "Send and receive doge", "wallet", "dogecoin"),
+		}},
+	}
+	installed := map[string]PupState{
+		"abc": {Source: core, Manifest: PupManifest{Meta: PupManifestMeta{Name: "Dogecoin Core"}}},
+		// Same name, different source, so it doesn't count.
+		"def": {Source: ManifestSourceConfiguration{ID: "other"}, Manifest: PupManifest{Meta: PupManifestMeta{Name: "Dogenet"}}},
+	}
+
+	tests := map[string]struct {
+		query string
+		want  []string
+	}{
+		"empty":           {query: "  ", want: []string{}},
+		"no match":        {query: "lightning", want: []string{}},
+		"exact name":      {query: "dogecoin core", want: []string{"core/Dogecoin Core@1.2.0"}},
+		"name before tag": {query: "doge", want: []string{"core/Dogenet@0.1.0", "core/Dogecoin Core@1.2.0", "extra/Wallet@2.0.0"}},
+		"tag":             {query: "NETWORK", want: []string{"core/Dogenet@0.1.0"}},
+		"every term":      {query: "dogecoin wallet", want: []string{"extra/Wallet@2.0.0"}},
+		"description":     {query: "profile", want: []string{"core/Identity@0.1.0"}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, searchNames(SearchPups(lists, installed, tt.query)))
+		})
+	}
+
+	results := SearchPups(lists, installed, "doge")
+	assert.Empty(t, results[0].InstalledPupIDs)
+	assert.Equal(t, []string{"abc"}, results[1].InstalledPupIDs)
+}
@@ -0,0 +1,78 @@
+package dogeboxd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/Dogebox-WG/dogeboxd/pkg/rootd"
+)
+
+const (
+	DEFAULT_PUP_STOP_SIGNAL      = "SIGTERM"
+	MAX_PUP_STOP_TIMEOUT_SECONDS = rootd.MaxPupStopTimeoutSeconds
+)
+
+// Signals a pup can ask to be stopped with.
+var pupStopSignals = map[string]bool{
+	"SIGTERM": true, "SIGINT": true, "SIGQUIT": true,
+	"SIGHUP": true, "SIGUSR1": true, "SIGUSR2": true,
+}
+
+/* PupManifestStop is how a pup wants its services stopped, for stateful
+ * pups (ie: Dogecoin Core) that need time to flush to disk. Its services
+ * are sent Signal, and given TimeoutSeconds to exit before they, and then
+ * the container, are killed.
+ */
+type PupManifestStop struct {
+	// Optional, SIGTERM by default.
+	Signal         string `json:"signal,omitempty"`
+	TimeoutSeconds int    `json:"timeoutSeconds"`
+}
+
+func (s PupManifestStop) Validate() error {
+	if s.Signal != "" && !pupStopSignals[s.Signal] {
+		return fmt.Errorf("signal %q isn't one of SIGTERM, SIGINT, SIGQUIT, SIGHUP, SIGUSR1 or SIGUSR2", s.Signal)
+	}
+	if s.TimeoutSeconds < 1 || s.TimeoutSeconds > MAX_PUP_STOP_TIMEOUT_SECONDS {
+		return fmt.Errorf("timeoutSeconds must be between 1 and %d", MAX_PUP_STOP_TIMEOUT_SECONDS)
+	}
+	return nil
+}
+
+// StopSignal is the signal the pup's services are stopped with.
+func (p PupState) StopSignal() string {
+	if s := p.Manifest.Container.Stop; s != nil && s.Signal != "" {
+		return s.Signal
+	}
+	return DEFAULT_PUP_STOP_SIGNAL
+}
+
+// StopTimeoutSeconds is how long the pup is given to stop before being
+// killed, 0 when its manifest doesn't say, leaving systemd's defaults.
+func (p PupState) StopTimeoutSeconds() int {
+	if s := p.Manifest.Container.Stop; s != nil {
+		return s.TimeoutSeconds
+	}
+	return 0
+}
+
+// ContainerStopTimeoutSeconds is how long the pup's container is given to
+// stop, long enough for its services' own timeout, 0 for the default.
+func (p PupState) ContainerStopTimeoutSeconds() int {
+	timeout := p.StopTimeoutSeconds()
+	if timeout == 0 {
+		return 0
+	}
+	// Leave the container time to stop everything else once the pup has.
+	return timeout + rootd.PupContainerStopGraceSeconds
+}
+
+// ContainerStopTimeout is a systemd TimeoutStopSec for the pup's container,
+// see ContainerStopTimeoutSeconds, empty for the default.
+func (p PupState) ContainerStopTimeout() string {
+	timeout := p.ContainerStopTimeoutSeconds()
+	if timeout == 0 {
+		return ""
+	}
+	return strconv.Itoa(timeout) + "s"
+}
@@ -0,0 +1,29 @@
+package dogeboxd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPupManifestStopValidate(t *testing.T) {
+	assert.NoError(t, PupManifestStop{TimeoutSeconds: 600}.Validate())
+	assert.NoError(t, PupManifestStop{Signal: "SIGINT", TimeoutSeconds: 600}.Validate())
+
+	assert.Error(t, PupManifestStop{Signal: "SIGKILL", TimeoutSeconds: 600}.Validate())
+	assert.Error(t, PupManifestStop{TimeoutSeconds: 0}.Validate())
+	assert.Error(t, PupManifestStop{TimeoutSeconds: MAX_PUP_STOP_TIMEOUT_SECONDS + 1}.Validate())
+}
+
+func TestPupStateStop(t *testing.T) {
+	p := PupState{}
+	assert.Equal(t, DEFAULT_PUP_STOP_SIGNAL, p.StopSignal())
+	assert.Equal(t, 0, p.StopTimeoutSeconds())
+	assert.Equal(t, "", p.ContainerStopTimeout())
+
+	p.Manifest.Container.Stop = &PupManifestStop{Signal: "SIGINT", TimeoutSeconds: 600}
+	assert.Equal(t, "SIGINT", p.StopSignal())
+	assert.Equal(t, 600, p.StopTimeoutSeconds())
+	assert.Equal(t, 630, p.ContainerStopTimeoutSeconds())
+	assert.Equal(t, "630s", p.ContainerStopTimeout())
+}
@@ -0,0 +1,52 @@
+package dogeboxd
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	MIN_PUP_STORAGE_QUOTA_MB = 100
+	MAX_PUP_STORAGE_QUOTA_MB = 64 * 1024 * 1024
+	// How full a pup's storage gets before we warn about it.
+	PupStorageQuotaWarnPercent = 90
+)
+
+// ValidatePupStorageQuota checks a storage quota in MB, 0 meaning no quota.
+func ValidatePupStorageQuota(quotaMB int) error {
+	if quotaMB != 0 && (quotaMB < MIN_PUP_STORAGE_QUOTA_MB || quotaMB > MAX_PUP_STORAGE_QUOTA_MB) {
+		return fmt.Errorf("storage quota must be between %dMB and %dMB, or 0 for no quota", MIN_PUP_STORAGE_QUOTA_MB, MAX_PUP_STORAGE_QUOTA_MB)
+	}
+	return nil
+}
+
+/* PupStorageUsage is how much of its storage directory a pup is using,
+ * measured periodically by PupManager. The quota itself is enforced by
+ * the filesystem (see _dbxroot pup create-storage), this is so the user
+ * hears about it before the pup starts failing writes.
+ */
+type PupStorageUsage struct {
+	UsedBytes int64     `json:"usedBytes"`
+	QuotaMB   int       `json:"quotaMb,omitempty"`
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// Percent is how much of the quota is used, 0 without a quota.
+func (u PupStorageUsage) Percent() float64 {
+	if u.QuotaMB <= 0 {
+		return 0
+	}
+	return float64(u.UsedBytes) / float64(int64(u.QuotaMB)*1024*1024) * 100
+}
+
+// Warning describes a pup nearing or over its quota, empty otherwise.
+func (u PupStorageUsage) Warning() string {
+	percent := u.Percent()
+	switch {
+	case percent >= 100:
+		return fmt.Sprintf("Storage is full, %dMB quota reached", u.QuotaMB)
+	case percent >= PupStorageQuotaWarnPercent:
+		return fmt.Sprintf("Storage is %.0f%% full, nearing its %dMB quota", percent, u.QuotaMB)
+	}
+	return ""
+}
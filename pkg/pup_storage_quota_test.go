@@ -0,0 +1,25 @@
+package dogeboxd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidatePupStorageQuota(t *testing.T) {
+	assert.NoError(t, ValidatePupStorageQuota(0))
+	assert.NoError(t, ValidatePupStorageQuota(20480))
+
+	assert.Error(t, ValidatePupStorageQuota(-1))
+	assert.Error(t, ValidatePupStorageQuota(MIN_PUP_STORAGE_QUOTA_MB-1))
+	assert.Error(t, ValidatePupStorageQuota(MAX_PUP_STORAGE_QUOTA_MB+1))
+}
+
+func TestPupStorageUsageWarning(t *testing.T) {
+	const mb = 1024 * 1024
+
+	assert.Equal(t, "", PupStorageUsage{UsedBytes: 500 * mb}.Warning(), "no quota, nothing to warn about")
+	assert.Equal(t, "", PupStorageUsage{UsedBytes: 500 * mb, QuotaMB: 1000}.Warning())
+	assert.Equal(t, "Storage is 95% full, nearing its 1000MB quota", PupStorageUsage{UsedBytes: 950 * mb, QuotaMB: 1000}.Warning())
+	assert.Equal(t, "Storage is full, 1000MB quota reached", PupStorageUsage{UsedBytes: 1000 * mb, QuotaMB: 1000}.Warning())
+}
@@ -0,0 +1,67 @@
+package dogeboxd
+
+/* A Pupdate only carries the pup's new state, so a timeline showing why
+ * a pup changed would have to diff it against the last one and guess.
+ * Updates made by a job say so with WithPupdateReason, and the reason is
+ * sent alongside the state in the "pup" Change.
+ */
+type PupdateReason struct {
+	// The job that changed the pup, and its ActionName.
+	JobID  string `json:"jobId,omitempty"`
+	Action string `json:"action,omitempty"`
+	// Who queued the job, see JOB_ACTOR_USER.
+	Actor string `json:"actor,omitempty"`
+	// Why the pup broke, one of the BROKEN_REASON_* consts, when it did.
+	ErrorCode string `json:"errorCode,omitempty"`
+}
+
+func (r PupdateReason) IsZero() bool {
+	return r == PupdateReason{}
+}
+
+// PupdateReasonFor is the reason for changes made by j.
+func PupdateReasonFor(j Job) PupdateReason {
+	r := PupdateReason{JobID: j.ID, Actor: j.Actor}
+	if j.A != nil {
+		r.Action = j.A.ActionName()
+	}
+	return r
+}
+
+// jobLogger is a SubLogger belonging to a job, see actionLogger.Step.
+type jobLogger interface {
+	job() Job
+}
+
+// PupdateReasonFromLog is the reason for changes made by the job log
+// belongs to, for code that's handed a job's log rather than the job.
+// It's zero for logs that don't belong to a job.
+func PupdateReasonFromLog(log SubLogger) PupdateReason {
+	if l, ok := log.(jobLogger); ok {
+		return PupdateReasonFor(l.job())
+	}
+	return PupdateReason{}
+}
+
+// WithPupdateReason attaches r to the pupdates sent by the updates
+// before it, so it should be passed last to UpdatePup, ie:
+//
+//	UpdatePup(id, PupEnabled(true), WithPupdateReason(PupdateReasonFor(j)))
+//
+// Pupdates for a pup left broken get its BrokenReason as their ErrorCode.
+func WithPupdateReason(r PupdateReason) func(*PupState, *[]Pupdate) {
+	return func(p *PupState, pu *[]Pupdate) {
+		if r.IsZero() {
+			return
+		}
+		if r.ErrorCode == "" && p.Installation == STATE_BROKEN {
+			r.ErrorCode = p.BrokenReason
+		}
+		for i := range *pu {
+			if (*pu)[i].Reason == nil {
+				reason := r
+				(*pu)[i].Reason = &reason
+			}
+		}
+	}
+}
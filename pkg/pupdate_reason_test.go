@@ -0,0 +1,63 @@
+package dogeboxd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithPupdateReasonStampsEarlierPupdates(t *testing.T) {
+	j := Job{ID: "job1", A: EnablePup{PupID: "pup1"}, Actor: JOB_ACTOR_SCHEDULER}
+	p := &PupState{ID: "pup1"}
+	pupdates := []Pupdate{}
+
+	PupEnabled(true)(p, &pupdates)
+	PupAutoStart(true)(p, &pupdates)
+	WithPupdateReason(PupdateReasonFor(j))(p, &pupdates)
+
+	require.Len(t, pupdates, 2)
+	for _, pu := range pupdates {
+		require.NotNil(t, pu.Reason)
+		assert.Equal(t, PupdateReason{JobID: "job1", Action: "enable", Actor: JOB_ACTOR_SCHEDULER}, *pu.Reason)
+	}
+}
+
+func TestWithPupdateReasonTakesTheErrorCodeOfBrokenPups(t *testing.T) {
+	p := &PupState{ID: "pup1"}
+	pupdates := []Pupdate{}
+
+	SetPupInstallation(STATE_BROKEN)(p, &pupdates)
+	SetPupBrokenReason(BROKEN_REASON_DOWNLOAD_FAILED)(p, &pupdates)
+	WithPupdateReason(PupdateReason{JobID: "job1"})(p, &pupdates)
+
+	require.Len(t, pupdates, 1)
+	assert.Equal(t, BROKEN_REASON_DOWNLOAD_FAILED, pupdates[0].Reason.ErrorCode)
+}
+
+func TestWithPupdateReasonKeepsExistingReasons(t *testing.T) {
+	p := &PupState{ID: "pup1"}
+	pupdates := []Pupdate{}
+
+	PupEnabled(true)(p, &pupdates)
+	WithPupdateReason(PupdateReason{JobID: "first"})(p, &pupdates)
+	WithPupdateReason(PupdateReason{JobID: "second"})(p, &pupdates)
+	WithPupdateReason(PupdateReason{})(p, &pupdates)
+
+	require.Len(t, pupdates, 1)
+	assert.Equal(t, "first", pupdates[0].Reason.JobID)
+}
+
+func TestPupdateReasonFromLog(t *testing.T) {
+	j := Job{ID: "job1", A: DisablePup{PupID: "pup1"}, Actor: JOB_ACTOR_USER}
+	dbx := Dogeboxd{Changes: make(chan Change, 100), config: &ServerConfig{}}
+	log := NewActionLogger(j, "pup1", dbx).Step("disable")
+
+	assert.Equal(t, PupdateReason{JobID: "job1", Action: "disable", Actor: JOB_ACTOR_USER}, PupdateReasonFromLog(log))
+	assert.True(t, PupdateReasonFromLog(NewConsoleSubLogger("pup1", "disable")).IsZero())
+}
+
+func TestPersistedJobKeepsItsActor(t *testing.T) {
+	p := newPersistedJob(Job{ID: "1", A: UpdateTimezone{Timezone: "UTC"}, Actor: JOB_ACTOR_SCHEDULER}, 1, false)
+	assert.Equal(t, JOB_ACTOR_SCHEDULER, p.Actor)
+}
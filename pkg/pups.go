@@ -22,7 +22,9 @@ const (
 	STATE_STOPPED      string = "stopped"
 	STATE_STARTING     string = "starting"
 	STATE_RUNNING      string = "running"
+	STATE_UNHEALTHY    string = "unhealthy" // running, but failing its health check
 	STATE_STOPPING     string = "stopping"
+	STATE_MAINTENANCE  string = "maintenance" // stopped on purpose, see PupMaintenance
 )
 
 // Pup broken reasons
@@ -31,11 +33,14 @@ const (
 	BROKEN_REASON_DOWNLOAD_FAILED              string = "download_failed"
 	BROKEN_REASON_NIX_FILE_MISSING             string = "nix_file_missing"
 	BROKEN_REASON_NIX_HASH_MISMATCH            string = "nix_hash_mismatch"
+	BROKEN_REASON_SIGNATURE_INVALID            string = "signature_invalid"
 	BROKEN_REASON_STORAGE_CREATION_FAILED      string = "storage_creation_failed"
 	BROKEN_REASON_DELEGATE_KEY_CREATION_FAILED string = "delegate_key_creation_failed"
 	BROKEN_REASON_DELEGATE_KEY_WRITE_FAILED    string = "delegate_key_write_failed"
 	BROKEN_REASON_ENABLE_FAILED                string = "enable_failed"
 	BROKEN_REASON_NIX_APPLY_FAILED             string = "nix_apply_failed"
+	BROKEN_REASON_MIGRATION_FAILED             string = "migration_failed"
+	BROKEN_REASON_STORAGE_IMPORT_FAILED        string = "storage_import_failed"
 )
 
 const (
@@ -58,6 +63,7 @@ var (
  * │installing                   │    stopped                    │
  * │ready                       ─┼─>  starting                   │
  * │unready                      │    running                    │
+ * │                             │    unhealthy                  │
  * │uninstalling                 │    stopping                   │
  * │uninstalled                  │                               │
  * │broken                       │                               │
@@ -69,6 +75,7 @@ var (
 // PupState is persisted to disk
 type PupState struct {
 	ID           string                      `json:"id"`
+	InstanceName string                      `json:"instanceName,omitempty"` // Tells apart installs of the same manifest
 	LogoBase64   string                      `json:"logoBase64"`
 	Source       ManifestSourceConfiguration `json:"source"`
 	Manifest     PupManifest                 `json:"manifest"`
@@ -79,8 +86,10 @@ type PupState struct {
 	Installation string                      `json:"installation"` // see table above and constants
 	BrokenReason string                      `json:"brokenReason"` // reason for being in a broken state
 	Enabled      bool                        `json:"enabled"`      // Is this pup supposed to be running?
+	AutoStart    *bool                       `json:"autoStart"`    // Start on boot? nil means yes, when enabled
 	NeedsConf    bool                        `json:"needsConf"`    // Has all required config been provided?
 	NeedsDeps    bool                        `json:"needsDeps"`    // Have all dependencies been met?
+	NeedsDevices bool                        `json:"needsDevices"` // Are required devices still to be approved?
 	IP           string                      `json:"ip"`           // Internal IP for this pup
 	Version      string                      `json:"version"`
 	WebUIs       []PupWebUI                  `json:"webUIs"`
@@ -90,6 +99,52 @@ type PupState struct {
 
 	// Update management
 	SkippedVersion string `json:"skippedVersion,omitempty"` // Version up to which updates are skipped
+	UpdateHold     bool   `json:"updateHold,omitempty"`     // Held at its version, never offered updates until released
+
+	// Command migrations from an upgrade, run inside the container before the pup's services start.
+	PendingMigrations []PupPendingMigration `json:"pendingMigrations,omitempty"`
+	// Prebuilt closures from the manifest that were verified as fetchable at install/upgrade time.
+	PrebuiltClosures []PupManifestClosure `json:"prebuiltClosures,omitempty"`
+	// How config was carried over during the last upgrade, if the config fields changed.
+	ConfigMergeReport *PupConfigMergeReport `json:"configMergeReport,omitempty"`
+	// Optional systemd calendar expression to periodically restart this pup on, see ValidateRestartSchedule.
+	RestartSchedule string `json:"restartSchedule,omitempty"`
+	// Temporary debug logging, reverted once it expires, see PupLogLevelConfig.
+	LogLevelOverride *PupLogLevelOverride `json:"logLevelOverride,omitempty"`
+	// The user's CPU and memory limits, replacing the manifest's, see ResourceLimits.
+	ResourceLimitsOverride *PupResourceLimits `json:"resourceLimitsOverride,omitempty"`
+	// The most the pup's storage directory can hold in MB, 0 for no quota.
+	StorageQuotaMB int `json:"storageQuotaMb,omitempty"`
+	// Opts the pup into automatic upgrades, see PupAutoUpdate.
+	AutoUpdate *PupAutoUpdate `json:"autoUpdate,omitempty"`
+	// Watches the pup's health after a canary upgrade, see PupCanary.
+	Canary *PupCanary `json:"canary,omitempty"`
+	// Overrides the global PupLogRetention for this pup's logs.
+	LogRetention *PupLogRetention `json:"logRetention,omitempty"`
+	// Installed and upgraded by OS updates, and can't be uninstalled, see SystemPupPin.
+	SystemManaged bool `json:"systemManaged,omitempty"`
+	// IDs of the TrustedCAs added to the container's trust store.
+	TrustedCAs []string `json:"trustedCAs,omitempty"`
+	// Set while the pup is intentionally offline, see PupMaintenance.
+	Maintenance *PupMaintenance `json:"maintenance,omitempty"`
+	// Extra environment variables set by the user, apart from Config, see ValidatePupEnvOverrides.
+	EnvOverrides map[string]string `json:"envOverrides,omitempty"`
+	// Values of config fields the manifest marks secret, sealed by the
+	// ConfigSecretStore and never sent to clients.
+	SecretConfig map[string]string `json:"-"`
+	// Names of the secret config fields that have been set.
+	SecretsSet []string `json:"secretsSet,omitempty"`
+	// The manifest's devices the user has approved, see PassedDevices.
+	ApprovedDevices []ApprovedDevice `json:"approvedDevices,omitempty"`
+	// Where a pup installed from a bundle came from, see PupProvenance.
+	Provenance *PupProvenance `json:"provenance,omitempty"`
+}
+
+type PupPendingMigration struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Service string `json:"service"`
+	Exec    string `json:"exec"`
 }
 
 // Represents a Web UI exposed port from the manifest
@@ -120,6 +175,16 @@ type PupStats struct {
 	SystemMetrics []PupMetrics[any] `json:"systemMetrics"`
 	Metrics       []PupMetrics[any] `json:"metrics"`
 	Issues        PupIssues         `json:"issues"`
+	PupStatus     *PupStatusReport  `json:"pupStatus"`
+	LastRestart   *time.Time        `json:"lastRestart,omitempty"` // When the container last (re)started
+	Storage       *PupStorageUsage  `json:"storage,omitempty"`
+}
+
+// A pup-declared status, eg: "syncing 42%", reported via the pup router
+type PupStatusReport struct {
+	Message   string    `json:"message"`
+	Progress  *float64  `json:"progress,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
 }
 
 type PupLogos struct {
@@ -128,12 +193,18 @@ type PupLogos struct {
 
 type PupAsset struct {
 	Logos PupLogos `json:"logos"`
+	// From the manifest, so dpanel can point users at help.
+	Links PupManifestLinks `json:"links"`
 }
 
 type PupIssues struct {
-	DepsNotRunning   []string `json:"depsNotRunning"`
-	HealthWarnings   []string `json:"healthWarnings"`
-	UpgradeAvaialble bool     `json:"upgradeAvailable"`
+	DepsNotRunning []string `json:"depsNotRunning"`
+	// Interfaces whose provider is in maintenance mode, and so isn't
+	// running on purpose. These aren't in DepsNotRunning.
+	DepsInMaintenance []string `json:"depsInMaintenance"`
+	HealthWarnings    []string `json:"healthWarnings"`
+	StorageWarnings   []string `json:"storageWarnings"`
+	UpgradeAvaialble  bool     `json:"upgradeAvailable"`
 }
 
 type PupDependencyReport struct {
@@ -147,9 +218,10 @@ type PupDependencyReport struct {
 }
 
 type PupHealthStateReport struct {
-	Issues    PupIssues
-	NeedsConf bool
-	NeedsDeps bool
+	Issues       PupIssues
+	NeedsConf    bool
+	NeedsDeps    bool
+	NeedsDevices bool
 }
 
 // Represents a change to pup state
@@ -157,6 +229,8 @@ type Pupdate struct {
 	ID    string
 	Event int // see consts above ^
 	State PupState
+	// Why, when the change was made by a job, see WithPupdateReason.
+	Reason *PupdateReason
 }
 
 type Buffer[T any] struct {
@@ -194,6 +268,10 @@ func (b *Buffer[T]) MarshalJSON() ([]byte, error) {
 type AdoptPupOptions struct {
 	/// Install pup with development features enabled
 	DevMode bool
+	/// Install another instance of an installed pup under this name, see ValidatePupInstanceName
+	InstanceName string
+	/// Install providers for any required interfaces nothing installed provides
+	InstallDependencies bool
 }
 
 /* The PupManager is responsible for all aspects of the pup lifecycle
@@ -215,7 +293,7 @@ type PupManager interface {
 	// GetStatsMap returns a map of all pup stats.
 	GetStatsMap() map[string]PupStats
 
-	// GetAssetsMap returns a map of pup assets like logos.
+	// GetAssetsMap returns a map of pup assets like logos and links.
 	GetAssetsMap() map[string]PupAsset
 
 	// AdoptPup adds a new pup from a manifest. It returns the PupID and an error if any.
@@ -245,6 +323,21 @@ type PupManager interface {
 	// UpdateMetrics updates the metrics for a pup based on provided data.
 	UpdateMetrics(u UpdateMetrics)
 
+	// UpdatePupStatus stores a pup-declared status message and progress.
+	UpdatePupStatus(u UpdatePupStatus) error
+
+	// GetUsage returns what each pup has used, and since when, see UsageReporter.
+	GetUsage() (map[string]PupUsage, time.Time)
+
+	// RollUsage returns what each pup has used and since when, starting a new usage period at start.
+	RollUsage(start time.Time) (map[string]PupUsage, time.Time)
+
+	// IsPupReady checks a pup is running and passing its health check, if it has one.
+	IsPupReady(pupId string) bool
+
+	// ResetPupHealth forgets a pup's health check results, ie: once it's been restarted or upgraded.
+	ResetPupHealth(pupId string)
+
 	// CanPupStart checks if a pup can start based on its current state and dependencies.
 	CanPupStart(pupId string) (bool, error)
 
@@ -306,7 +399,8 @@ func SetPupConfig(newFields map[string]string) func(*PupState, *[]Pupdate) {
 		fieldIndex := ManifestConfigFieldIndex(p.Manifest.Config)
 
 		for k, v := range newFields {
-			if _, ok := fieldIndex[k]; !ok {
+			// Secret fields go in SecretConfig, see SetPupSecretConfig.
+			if field, ok := fieldIndex[k]; !ok || field.Secret {
 				continue
 			}
 			p.Config[k] = v
@@ -315,7 +409,59 @@ func SetPupConfig(newFields map[string]string) func(*PupState, *[]Pupdate) {
 		// Mark config as saved (satisfies showOnInstall requirement)
 		p.ConfigSaved = true
 
-		p.NeedsConf = ManifestConfigNeedsValues(p.Manifest.Config, p.Config)
+		p.NeedsConf = PupConfigNeedsValues(*p)
+	}
+}
+
+// SetPupSecretConfig merges sealed secret config values into the pup's,
+// an empty value clearing the secret, see ConfigSecretStore.
+func SetPupSecretConfig(sealed map[string]string) func(*PupState, *[]Pupdate) {
+	return func(p *PupState, pu *[]Pupdate) {
+		if len(sealed) == 0 {
+			return
+		}
+		if p.SecretConfig == nil {
+			p.SecretConfig = map[string]string{}
+		}
+		for k, v := range sealed {
+			if v == "" {
+				delete(p.SecretConfig, k)
+			} else {
+				p.SecretConfig[k] = v
+			}
+		}
+		if len(p.SecretConfig) == 0 {
+			p.SecretConfig = nil
+		}
+		p.SecretsSet = secretConfigNames(p.SecretConfig)
+		p.NeedsConf = PupConfigNeedsValues(*p)
+	}
+}
+
+// ReplacePupConfig swaps the whole config out, unlike SetPupConfig which merges
+// into it. Used when migrations rename or remove config keys.
+func ReplacePupConfig(config map[string]string) func(*PupState, *[]Pupdate) {
+	return func(p *PupState, pu *[]Pupdate) {
+		p.Config = config
+		p.NeedsConf = PupConfigNeedsValues(*p)
+	}
+}
+
+func SetPupConfigMergeReport(report *PupConfigMergeReport) func(*PupState, *[]Pupdate) {
+	return func(p *PupState, pu *[]Pupdate) {
+		p.ConfigMergeReport = report
+	}
+}
+
+func SetPupPendingMigrations(migrations []PupPendingMigration) func(*PupState, *[]Pupdate) {
+	return func(p *PupState, pu *[]Pupdate) {
+		p.PendingMigrations = migrations
+	}
+}
+
+func SetPupPrebuiltClosures(closures []PupManifestClosure) func(*PupState, *[]Pupdate) {
+	return func(p *PupState, pu *[]Pupdate) {
+		p.PrebuiltClosures = closures
 	}
 }
 
@@ -343,11 +489,179 @@ func SetPupSkippedVersion(version string) func(*PupState, *[]Pupdate) {
 	}
 }
 
+func SetPupUpdateHold(b bool) func(*PupState, *[]Pupdate) {
+	return func(p *PupState, pu *[]Pupdate) {
+		p.UpdateHold = b
+		*pu = append(*pu, Pupdate{
+			ID:    p.ID,
+			Event: PUP_CHANGED_INSTALLATION,
+			State: *p,
+		})
+	}
+}
+
 func SetPupManifest(manifest PupManifest) func(*PupState, *[]Pupdate) {
 	return func(p *PupState, pu *[]Pupdate) {
 		p.Manifest = manifest
 		// Recalculate if config needs values based on new manifest
-		p.NeedsConf = ManifestConfigNeedsValues(p.Manifest.Config, p.Config)
+		p.NeedsConf = PupConfigNeedsValues(*p)
+		// A new version may ask for devices the user hasn't approved.
+		p.NeedsDevices = p.DevicesNeedApproval()
+	}
+}
+
+// ChecksForUpdates reports whether UpdateChecker looks for new versions
+// of this pup. Only git and registry sources have them, and system pups
+// and held pups are never offered one, unlike SkippedVersion a hold
+// outlasts new releases. Neither are pups pinned to a commit.
+func (p PupState) ChecksForUpdates() bool {
+	return (p.Source.Type == "git" || p.Source.Type == "registry") && !p.SystemManaged && !p.UpdateHold && p.Source.Commit == ""
+}
+
+// StartsOnBoot reports whether this pup's container should be started
+// on boot, rather than waiting to be started by hand.
+func (p PupState) StartsOnBoot() bool {
+	return p.Enabled && !p.InMaintenance() && (p.AutoStart == nil || *p.AutoStart)
+}
+
+func PupAutoStart(b bool) func(*PupState, *[]Pupdate) {
+	return func(p *PupState, pu *[]Pupdate) {
+		p.AutoStart = &b
+		*pu = append(*pu, Pupdate{
+			ID:    p.ID,
+			Event: PUP_CHANGED_INSTALLATION,
+			State: *p,
+		})
+	}
+}
+
+func PupRestartSchedule(schedule string) func(*PupState, *[]Pupdate) {
+	return func(p *PupState, pu *[]Pupdate) {
+		p.RestartSchedule = schedule
+		*pu = append(*pu, Pupdate{
+			ID:    p.ID,
+			Event: PUP_CHANGED_INSTALLATION,
+			State: *p,
+		})
+	}
+}
+
+// Sets which TrustedCAs a pup trusts.
+func PupTrustedCAs(ids []string) func(*PupState, *[]Pupdate) {
+	return func(p *PupState, pu *[]Pupdate) {
+		p.TrustedCAs = ids
+		*pu = append(*pu, Pupdate{
+			ID:    p.ID,
+			Event: PUP_CHANGED_INSTALLATION,
+			State: *p,
+		})
+	}
+}
+
+// Sets which of a pup's requested devices the user has approved.
+func PupApprovedDevices(approvals []ApprovedDevice) func(*PupState, *[]Pupdate) {
+	return func(p *PupState, pu *[]Pupdate) {
+		if len(approvals) == 0 {
+			approvals = nil
+		}
+		p.ApprovedDevices = approvals
+		p.NeedsDevices = p.DevicesNeedApproval()
+		*pu = append(*pu, Pupdate{
+			ID:    p.ID,
+			Event: PUP_CHANGED_INSTALLATION,
+			State: *p,
+		})
+	}
+}
+
+// Sets (or clears, with nil) the user's resource limits for a pup.
+func PupResourceLimitsOverride(limits *PupResourceLimits) func(*PupState, *[]Pupdate) {
+	return func(p *PupState, pu *[]Pupdate) {
+		p.ResourceLimitsOverride = limits
+		*pu = append(*pu, Pupdate{
+			ID:    p.ID,
+			Event: PUP_CHANGED_INSTALLATION,
+			State: *p,
+		})
+	}
+}
+
+// Marks a pup as a system pup, only ever done by SystemUpdate.
+func PupSystemManaged(b bool) func(*PupState, *[]Pupdate) {
+	return func(p *PupState, pu *[]Pupdate) {
+		p.SystemManaged = b
+		*pu = append(*pu, Pupdate{
+			ID:    p.ID,
+			Event: PUP_CHANGED_INSTALLATION,
+			State: *p,
+		})
+	}
+}
+
+// Sets a pup's storage quota, 0 removing it.
+func PupStorageQuota(quotaMB int) func(*PupState, *[]Pupdate) {
+	return func(p *PupState, pu *[]Pupdate) {
+		p.StorageQuotaMB = quotaMB
+		*pu = append(*pu, Pupdate{
+			ID:    p.ID,
+			Event: PUP_CHANGED_INSTALLATION,
+			State: *p,
+		})
+	}
+}
+
+// Sets (or clears, with nil) a pup's automatic upgrade policy.
+func PupAutoUpdateSetting(autoUpdate *PupAutoUpdate) func(*PupState, *[]Pupdate) {
+	return func(p *PupState, pu *[]Pupdate) {
+		p.AutoUpdate = autoUpdate
+		*pu = append(*pu, Pupdate{
+			ID:    p.ID,
+			Event: PUP_CHANGED_INSTALLATION,
+			State: *p,
+		})
+	}
+}
+
+// Sets (or clears, with nil) a pup's own log retention.
+func PupLogRetentionSetting(retention *PupLogRetention) func(*PupState, *[]Pupdate) {
+	return func(p *PupState, pu *[]Pupdate) {
+		p.LogRetention = retention
+		*pu = append(*pu, Pupdate{
+			ID:    p.ID,
+			Event: PUP_CHANGED_INSTALLATION,
+			State: *p,
+		})
+	}
+}
+
+// PupLogLevel replaces the pup's config and its log level override
+// together, nil clearing the override.
+func PupLogLevel(config map[string]string, override *PupLogLevelOverride) func(*PupState, *[]Pupdate) {
+	return func(p *PupState, pu *[]Pupdate) {
+		p.Config = config
+		p.LogLevelOverride = override
+		p.NeedsConf = PupConfigNeedsValues(*p)
+		*pu = append(*pu, Pupdate{
+			ID:    p.ID,
+			Event: PUP_CHANGED_INSTALLATION,
+			State: *p,
+		})
+	}
+}
+
+// PupEnvOverrides replaces the pup's environment overrides, empty
+// clearing them.
+func PupEnvOverrides(env map[string]string) func(*PupState, *[]Pupdate) {
+	return func(p *PupState, pu *[]Pupdate) {
+		if len(env) == 0 {
+			env = nil
+		}
+		p.EnvOverrides = env
+		*pu = append(*pu, Pupdate{
+			ID:    p.ID,
+			Event: PUP_CHANGED_INSTALLATION,
+			State: *p,
+		})
 	}
 }
 
@@ -437,14 +751,17 @@ type PupUpdatePreviousVersion struct {
 // PupVersionSnapshot stores data needed for rollback
 // Note: User data in storage directory is NOT snapshotted - only state/config
 type PupVersionSnapshot struct {
-	Version        string            `json:"version"`
-	Manifest       PupManifest       `json:"manifest"`
-	Config         map[string]string `json:"config"`
+	Version  string            `json:"version"`
+	Manifest PupManifest       `json:"manifest"`
+	Config   map[string]string `json:"config"`
+	// Sealed, as PupState.SecretConfig is never marshalled.
+	SecretConfig   map[string]string `json:"secretConfig,omitempty"`
 	Providers      map[string]string `json:"providers"`
 	Enabled        bool              `json:"enabled"`
 	SnapshotDate   time.Time         `json:"snapshotDate"`
 	SourceID       string            `json:"sourceId"`
 	SourceLocation string            `json:"sourceLocation"` // For re-downloading
+	SourceCommit   string            `json:"sourceCommit,omitempty"`
 }
 
 /* Pup update actions
@@ -0,0 +1,43 @@
+package dogeboxd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPupStateChecksForUpdates(t *testing.T) {
+	git := PupState{Source: ManifestSourceConfiguration{Type: "git"}}
+	assert.True(t, git.ChecksForUpdates())
+
+	registry := PupState{Source: ManifestSourceConfiguration{Type: "registry"}}
+	assert.True(t, registry.ChecksForUpdates())
+
+	local := PupState{Source: ManifestSourceConfiguration{Type: "local"}}
+	assert.False(t, local.ChecksForUpdates())
+
+	held := git
+	held.UpdateHold = true
+	assert.False(t, held.ChecksForUpdates())
+
+	system := git
+	system.SystemManaged = true
+	assert.False(t, system.ChecksForUpdates())
+
+	// Unlike a hold, a skipped version still lets newer releases through.
+	skipped := git
+	skipped.SkippedVersion = "1.2.0"
+	assert.True(t, skipped.ChecksForUpdates())
+}
+
+func TestSetPupUpdateHold(t *testing.T) {
+	p := PupState{ID: "pup1"}
+	var updates []Pupdate
+
+	SetPupUpdateHold(true)(&p, &updates)
+	assert.True(t, p.UpdateHold)
+	assert.Len(t, updates, 1)
+
+	SetPupUpdateHold(false)(&p, &updates)
+	assert.False(t, p.UpdateHold)
+}
@@ -0,0 +1,93 @@
+package rootd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// ErrDenied is wrapped by errors for ops rootd refused to run.
+var ErrDenied = errors.New("rootd refused operation")
+
+// Client sends Ops to rootd.
+type Client struct {
+	SocketPath  string
+	DialTimeout time.Duration
+}
+
+func NewClient(socketPath string) Client {
+	return Client{SocketPath: socketPath, DialTimeout: 5 * time.Second}
+}
+
+// Do runs op, calling output with each line it prints as it runs.
+func (c Client) Do(op Op, output func(line string)) error {
+	return c.DoContext(context.Background(), op, output)
+}
+
+// DoContext is Do, but hangs up if ctx is done, which has rootd stop op.
+func (c Client) DoContext(ctx context.Context, op Op, output func(line string)) error {
+	// Catch bad ops before they leave the process.
+	if err := op.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrDenied, err)
+	}
+	req, err := newRequest(op)
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.DialTimeout("unix", c.SocketPath, c.DialTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to rootd: %w", err)
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return fmt.Errorf("failed to send %s to rootd: %w", op.OpName(), err)
+	}
+
+	dec := json.NewDecoder(conn)
+	for {
+		var ev Event
+		if err := dec.Decode(&ev); err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("stopped %s: %w", op.OpName(), ctx.Err())
+			}
+			return fmt.Errorf("lost connection to rootd during %s: %w", op.OpName(), err)
+		}
+		if ev.Output != "" && output != nil {
+			output(ev.Output)
+		}
+		if !ev.Done {
+			continue
+		}
+		switch {
+		case ev.Denied:
+			return fmt.Errorf("%w: %s", ErrDenied, ev.Error)
+		case ev.Error != "":
+			return errors.New(ev.Error)
+		default:
+			return nil
+		}
+	}
+}
+
+// CombinedOutput runs op and returns everything it printed.
+func (c Client) CombinedOutput(op Op) ([]byte, error) {
+	return c.CombinedOutputContext(context.Background(), op)
+}
+
+// CombinedOutputContext is CombinedOutput, but hangs up if ctx is done.
+func (c Client) CombinedOutputContext(ctx context.Context, op Op) ([]byte, error) {
+	var out strings.Builder
+	err := c.DoContext(ctx, op, func(line string) {
+		out.WriteString(line)
+		out.WriteByte('\n')
+	})
+	return []byte(out.String()), err
+}
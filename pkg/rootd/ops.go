@@ -0,0 +1,746 @@
+package rootd
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+/* An Op is a single privileged operation dogeboxd can ask rootd to
+ * perform. Each Op is a typed request that validates its own fields
+ * and builds its own argv, so the only place a privileged command line
+ * is assembled is here, and rootd never runs anything it wasn't sent
+ * as a known Op.
+ *
+ * Argv is also what runs under sudo when there is no rootd, see
+ * system.SudoCommandRunner.
+ */
+type Op interface {
+	OpName() string
+	Validate() error
+	Argv() []string
+}
+
+/* DataDirOp is implemented by ops that work on pup storage under
+ * dogeboxd's data dir. rootd replaces whatever data dir the client sent
+ * with its own, see Server.DataDir, so a client can't point them at
+ * anything else on the box. Run via sudo, the client's is used as is.
+ */
+type DataDirOp interface {
+	SetDataDir(dir string)
+}
+
+// ReadOnlyOp is implemented by ops that only inspect system state, these
+// can be granted separately in a Policy.
+type ReadOnlyOp interface {
+	ReadOnly()
+}
+
+var (
+	pupIDRegex     = regexp.MustCompile(`^[A-Za-z0-9]+$`)
+	machineRegex   = regexp.MustCompile(`^pup-[A-Za-z0-9]+$`)
+	unitRegex      = regexp.MustCompile(`^[A-Za-z0-9@._-]+\.(service|timer|target)$`)
+	pupUnitRegex   = regexp.MustCompile(`^container@pup-[A-Za-z0-9]+\.service$`)
+	keyFileRegex   = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+	exportIDRegex  = regexp.MustCompile(`^[a-f0-9]+$`)
+	configKeyRegex = regexp.MustCompile(`^[^,=\s]+$`)
+	ifaceRegex     = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,14}$`)
+	maxUnitLogLine = 1000
+
+	maxNixGCKeepGenerations = 100
+)
+
+const (
+	// MaxPupStopTimeoutSeconds is the longest a pup's manifest can ask to
+	// be given to stop, see dogeboxd.PupManifestStop.
+	MaxPupStopTimeoutSeconds = 1800
+	// PupContainerStopGraceSeconds is the time a pup's container is given
+	// on top of the pup's own stop timeout, to stop everything else once
+	// the pup has.
+	PupContainerStopGraceSeconds = 30
+)
+
+func validatePupID(id string) error {
+	if !pupIDRegex.MatchString(id) {
+		return fmt.Errorf("invalid pup id %q", id)
+	}
+	return nil
+}
+
+func validateDataDir(dir string) error {
+	if !filepath.IsAbs(dir) || filepath.Clean(dir) != dir {
+		return fmt.Errorf("data dir %q must be a clean absolute path", dir)
+	}
+	return nil
+}
+
+func validateUnit(unit string) error {
+	if !unitRegex.MatchString(unit) {
+		return fmt.Errorf("invalid unit %q", unit)
+	}
+	return nil
+}
+
+// validateMachine allows the host (empty) or a pup container.
+func validateMachine(machine string) error {
+	if machine != "" && !machineRegex.MatchString(machine) {
+		return fmt.Errorf("invalid machine %q, expected a pup container", machine)
+	}
+	return nil
+}
+
+// PupStop stops a pup's container. With a TimeoutSeconds it waits that
+// long for the container to shut down cleanly, then terminates it.
+type PupStop struct {
+	PupID          string `json:"pupId"`
+	TimeoutSeconds int    `json:"timeoutSeconds,omitempty"`
+}
+
+func (PupStop) OpName() string { return "pup-stop" }
+func (o PupStop) Validate() error {
+	if o.TimeoutSeconds < 0 || o.TimeoutSeconds > MaxPupStopTimeoutSeconds+PupContainerStopGraceSeconds {
+		return fmt.Errorf("invalid stop timeout %d", o.TimeoutSeconds)
+	}
+	return validatePupID(o.PupID)
+}
+func (o PupStop) Argv() []string {
+	argv := []string{"_dbxroot", "pup", "stop", "--pupId", o.PupID}
+	if o.TimeoutSeconds > 0 {
+		argv = append(argv, "--timeout", strconv.Itoa(o.TimeoutSeconds))
+	}
+	return argv
+}
+
+// PupCreateStorage creates a pup's storage directory, and sets (or with
+// zero, clears) its quota. It's safe to run again on existing storage.
+type PupCreateStorage struct {
+	PupID   string `json:"pupId"`
+	DataDir string `json:"dataDir"`
+	QuotaMB int    `json:"quotaMb,omitempty"`
+}
+
+func (o *PupCreateStorage) SetDataDir(dir string) { o.DataDir = dir }
+func (PupCreateStorage) OpName() string           { return "pup-create-storage" }
+func (o PupCreateStorage) Validate() error {
+	if err := validatePupID(o.PupID); err != nil {
+		return err
+	}
+	if o.QuotaMB < 0 {
+		return fmt.Errorf("invalid storage quota %d", o.QuotaMB)
+	}
+	return validateDataDir(o.DataDir)
+}
+func (o PupCreateStorage) Argv() []string {
+	return []string{"_dbxroot", "pup", "create-storage", "--data-dir", o.DataDir, "--pupId", o.PupID, "--quota-mb", strconv.Itoa(o.QuotaMB)}
+}
+
+// PupDeleteStorage removes a pup's storage directory.
+type PupDeleteStorage struct {
+	PupID   string `json:"pupId"`
+	DataDir string `json:"dataDir"`
+}
+
+func (o *PupDeleteStorage) SetDataDir(dir string) { o.DataDir = dir }
+func (PupDeleteStorage) OpName() string           { return "pup-delete-storage" }
+func (o PupDeleteStorage) Validate() error {
+	if err := validatePupID(o.PupID); err != nil {
+		return err
+	}
+	return validateDataDir(o.DataDir)
+}
+func (o PupDeleteStorage) Argv() []string {
+	return []string{"_dbxroot", "pup", "delete-storage", "--pupId", o.PupID, "--data-dir", o.DataDir}
+}
+
+// PupWriteKey writes a key file into a pup's storage.
+type PupWriteKey struct {
+	PupID   string `json:"pupId"`
+	DataDir string `json:"dataDir"`
+	KeyFile string `json:"keyFile"`
+	Data    string `json:"data"`
+}
+
+func (o *PupWriteKey) SetDataDir(dir string) { o.DataDir = dir }
+func (PupWriteKey) OpName() string           { return "pup-write-key" }
+func (o PupWriteKey) Validate() error {
+	if err := validatePupID(o.PupID); err != nil {
+		return err
+	}
+	if err := validateDataDir(o.DataDir); err != nil {
+		return err
+	}
+	if !keyFileRegex.MatchString(o.KeyFile) || o.KeyFile == "." || o.KeyFile == ".." {
+		return fmt.Errorf("invalid key file %q", o.KeyFile)
+	}
+	return nil
+}
+func (o PupWriteKey) Argv() []string {
+	return []string{"_dbxroot", "pup", "write-key", "--data-dir", o.DataDir, "--pupId", o.PupID, "--key-file", o.KeyFile, "--data", o.Data}
+}
+
+// PupWriteConfig writes a pup's config.env into its storage, see
+// dogeboxd.WritePupConfigToStorage.
+type PupWriteConfig struct {
+	PupID   string `json:"pupId"`
+	DataDir string `json:"dataDir"`
+	// A JSON object of config keys to values.
+	Config string `json:"config"`
+	// Keys to keep from the existing config.env when they're not in Config.
+	Keep []string `json:"keep,omitempty"`
+}
+
+func (o *PupWriteConfig) SetDataDir(dir string) { o.DataDir = dir }
+func (PupWriteConfig) OpName() string           { return "pup-write-config" }
+func (o PupWriteConfig) Validate() error {
+	if err := validatePupID(o.PupID); err != nil {
+		return err
+	}
+	for _, key := range o.Keep {
+		if !configKeyRegex.MatchString(key) {
+			return fmt.Errorf("invalid config key %q", key)
+		}
+	}
+	return validateDataDir(o.DataDir)
+}
+func (o PupWriteConfig) Argv() []string {
+	argv := []string{"_dbxroot", "pup", "write-config", "--data-dir", o.DataDir, "--pupId", o.PupID, "--config", o.Config}
+	if len(o.Keep) > 0 {
+		argv = append(argv, "--keep", strings.Join(o.Keep, ","))
+	}
+	return argv
+}
+
+// validateExportID allows the IDs dogeboxd gives pup exports, see
+// dogeboxd.NewPupExportID.
+func validateExportID(id string) error {
+	if !exportIDRegex.MatchString(id) {
+		return fmt.Errorf("invalid export id %q", id)
+	}
+	return nil
+}
+
+// PupExportStorage archives a pup's storage for a pup export, to
+// <data dir>/pup-exports/<export id>.storage.tar.gz.
+type PupExportStorage struct {
+	PupID    string `json:"pupId"`
+	DataDir  string `json:"dataDir"`
+	ExportID string `json:"exportId"`
+}
+
+func (o *PupExportStorage) SetDataDir(dir string) { o.DataDir = dir }
+func (PupExportStorage) OpName() string           { return "pup-export-storage" }
+func (o PupExportStorage) Validate() error {
+	if err := validatePupID(o.PupID); err != nil {
+		return err
+	}
+	if err := validateExportID(o.ExportID); err != nil {
+		return err
+	}
+	return validateDataDir(o.DataDir)
+}
+func (o PupExportStorage) Argv() []string {
+	return []string{"_dbxroot", "pup", "export-storage", "--data-dir", o.DataDir, "--pupId", o.PupID, "--export-id", o.ExportID}
+}
+
+// PupImportStorage restores a pup's storage from the tarball a
+// PupExportStorage wrote, which may be from another box.
+type PupImportStorage struct {
+	PupID    string `json:"pupId"`
+	DataDir  string `json:"dataDir"`
+	ExportID string `json:"exportId"`
+}
+
+func (o *PupImportStorage) SetDataDir(dir string) { o.DataDir = dir }
+func (PupImportStorage) OpName() string           { return "pup-import-storage" }
+func (o PupImportStorage) Validate() error {
+	if err := validatePupID(o.PupID); err != nil {
+		return err
+	}
+	if err := validateExportID(o.ExportID); err != nil {
+		return err
+	}
+	return validateDataDir(o.DataDir)
+}
+func (o PupImportStorage) Argv() []string {
+	return []string{"_dbxroot", "pup", "import-storage", "--data-dir", o.DataDir, "--pupId", o.PupID, "--export-id", o.ExportID}
+}
+
+// ImportBlockchainData copies blockchain data from external storage into
+// the Dogecoin Core pup.
+type ImportBlockchainData struct {
+	DataDir string `json:"dataDir"`
+}
+
+func (o *ImportBlockchainData) SetDataDir(dir string) { o.DataDir = dir }
+func (ImportBlockchainData) OpName() string           { return "import-blockchain-data" }
+func (o ImportBlockchainData) Validate() error        { return validateDataDir(o.DataDir) }
+func (o ImportBlockchainData) Argv() []string {
+	return []string{"_dbxroot", "import-blockchain-data", "--data-dir", o.DataDir}
+}
+
+// ImportNixStore copies the store paths listed in an unpacked offline
+// update bundle's store export into the nix store, see
+// dogeboxd.SystemUpdateBundle and dogeboxd.PupBundle.
+type ImportNixStore struct {
+	CacheDir string `json:"cacheDir"`
+	// Have nix check the paths are signed by a key it trusts, for
+	// bundles we haven't verified ourselves.
+	RequireSigs bool `json:"requireSigs,omitempty"`
+}
+
+func (ImportNixStore) OpName() string    { return "import-nix-store" }
+func (o ImportNixStore) Validate() error { return validateDataDir(o.CacheDir) }
+func (o ImportNixStore) Argv() []string {
+	argv := []string{"_dbxroot", "nix", "import-store", "--cache-dir", o.CacheDir}
+	if o.RequireSigs {
+		argv = append(argv, "--require-sigs")
+	}
+	return argv
+}
+
+// CollectNixGarbage deletes all but the newest KeepGenerations system
+// generations, then garbage collects the nix store.
+type CollectNixGarbage struct {
+	KeepGenerations int `json:"keepGenerations"`
+}
+
+func (CollectNixGarbage) OpName() string { return "collect-nix-garbage" }
+func (o CollectNixGarbage) Validate() error {
+	if o.KeepGenerations < 1 || o.KeepGenerations > maxNixGCKeepGenerations {
+		return fmt.Errorf("keep generations must be between 1 and %d", maxNixGCKeepGenerations)
+	}
+	return nil
+}
+func (o CollectNixGarbage) Argv() []string {
+	return []string{"_dbxroot", "nix", "collect-garbage", "--keep-generations", strconv.Itoa(o.KeepGenerations)}
+}
+
+// SwitchSystemGeneration switches the system profile to an earlier
+// generation and activates it, see dogeboxd.RollbackSystem.
+type SwitchSystemGeneration struct {
+	Generation int `json:"generation"`
+}
+
+func (SwitchSystemGeneration) OpName() string { return "switch-system-generation" }
+func (o SwitchSystemGeneration) Validate() error {
+	if o.Generation < 1 {
+		return fmt.Errorf("invalid generation %d", o.Generation)
+	}
+	return nil
+}
+func (o SwitchSystemGeneration) Argv() []string {
+	return []string{"_dbxroot", "nix", "switch-generation", "--generation", strconv.Itoa(o.Generation), "--systemd-run"}
+}
+
+// DryBuildSystem evaluates and dry builds the system with an unsaved
+// custom.nix, see dogeboxd.ValidateCustomNix.
+type DryBuildSystem struct {
+	CustomNix string `json:"customNix"`
+}
+
+func (DryBuildSystem) OpName() string { return "dry-build-system" }
+func (o DryBuildSystem) Validate() error {
+	if filepath.Ext(o.CustomNix) != ".nix" {
+		return fmt.Errorf("custom nix %q must be a .nix file", o.CustomNix)
+	}
+	return validateDataDir(o.CustomNix)
+}
+func (o DryBuildSystem) Argv() []string {
+	return []string{"_dbxroot", "nix", "dry-build", "--custom-nix", o.CustomNix}
+}
+
+// RebuildSystem runs nixos-rebuild switch, or with Boot, boot, with the
+// system's current configuration.
+type RebuildSystem struct {
+	Boot bool `json:"boot,omitempty"`
+}
+
+func (RebuildSystem) OpName() string  { return "rebuild-system" }
+func (RebuildSystem) Validate() error { return nil }
+func (o RebuildSystem) Argv() []string {
+	if o.Boot {
+		return []string{"_dbxroot", "nix", "rb"}
+	}
+	return []string{"_dbxroot", "nix", "rs"}
+}
+
+// DiffSystem builds the system with any pending changes, without
+// switching, and diffs its closure against the running system's, see
+// dogeboxd.DiffPendingChanges.
+type DiffSystem struct{}
+
+func (DiffSystem) OpName() string  { return "diff-system" }
+func (DiffSystem) Validate() error { return nil }
+func (DiffSystem) Argv() []string  { return []string{"_dbxroot", "nix", "diff"} }
+
+// Reboot reboots the box.
+type Reboot struct{}
+
+func (Reboot) OpName() string  { return "reboot" }
+func (Reboot) Validate() error { return nil }
+func (Reboot) Argv() []string  { return []string{"_dbxroot", "reboot"} }
+
+// Shutdown powers the box off.
+type Shutdown struct{}
+
+func (Shutdown) OpName() string  { return "shutdown" }
+func (Shutdown) Validate() error { return nil }
+func (Shutdown) Argv() []string  { return []string{"_dbxroot", "shutdown"} }
+
+func validateInterface(iface string) error {
+	if !ifaceRegex.MatchString(iface) {
+		return fmt.Errorf("invalid network interface %q", iface)
+	}
+	return nil
+}
+
+// WifiScan lists the wifi networks an interface can see.
+type WifiScan struct {
+	Interface string `json:"interface"`
+}
+
+func (WifiScan) OpName() string    { return "wifi-scan" }
+func (o WifiScan) Validate() error { return validateInterface(o.Interface) }
+func (o WifiScan) Argv() []string {
+	return []string{"_dbxroot", "iwlist", o.Interface, "scan"}
+}
+
+// WifiTest checks a wifi network can be joined with a password, without
+// joining it for good.
+type WifiTest struct {
+	Interface string `json:"interface"`
+	SSID      string `json:"ssid"`
+	Password  string `json:"password"`
+}
+
+func (WifiTest) OpName() string { return "wifi-test" }
+func (o WifiTest) Validate() error {
+	if o.SSID == "" || len(o.SSID) > 32 {
+		return fmt.Errorf("invalid ssid %q", o.SSID)
+	}
+	return validateInterface(o.Interface)
+}
+func (o WifiTest) Argv() []string {
+	return []string{"_dbxroot", "wifi-test", "--interface", o.Interface, "--ssid", o.SSID, "--password", o.Password}
+}
+
+// StartPupUnit starts a pup's container unit, only pup containers may be
+// started this way.
+type StartPupUnit struct {
+	Unit string `json:"unit"`
+}
+
+func (StartPupUnit) OpName() string { return "start-pup-unit" }
+func (o StartPupUnit) Validate() error {
+	if !pupUnitRegex.MatchString(o.Unit) {
+		return fmt.Errorf("invalid unit %q, expected a pup container", o.Unit)
+	}
+	return nil
+}
+func (o StartPupUnit) Argv() []string { return []string{"systemctl", "start", o.Unit} }
+
+// RestartPupUnit restarts a pup's container unit if it's running, ie: so
+// the pup picks up a changed config.env. Stopped pups are left stopped.
+type RestartPupUnit struct {
+	Unit string `json:"unit"`
+}
+
+func (RestartPupUnit) OpName() string { return "restart-pup-unit" }
+func (o RestartPupUnit) Validate() error {
+	if !pupUnitRegex.MatchString(o.Unit) {
+		return fmt.Errorf("invalid unit %q, expected a pup container", o.Unit)
+	}
+	return nil
+}
+func (o RestartPupUnit) Argv() []string { return []string{"systemctl", "try-restart", o.Unit} }
+
+// RestartPupLogForwarder restarts the follower that exports a pup's
+// journal to its log, if it's running, so it starts a new log once the
+// old one has been rotated, see dogeboxd.RotatePupLog.
+type RestartPupLogForwarder struct {
+	PupID string `json:"pupId"`
+}
+
+func (RestartPupLogForwarder) OpName() string    { return "restart-pup-log-forwarder" }
+func (o RestartPupLogForwarder) Validate() error { return validatePupID(o.PupID) }
+func (o RestartPupLogForwarder) Argv() []string {
+	return []string{"systemctl", "try-restart", "container-log-forwarder@pup-" + o.PupID + ".service"}
+}
+
+const (
+	maxHealthCommandLen     = 1024
+	maxHealthCommandTimeout = 300
+)
+
+// PupHealthCommand runs a pup's manifest-declared health check command
+// inside its own container, see dogeboxd.PupManifestHealthCheck. It runs
+// as the pup user, like the pup's services, not as root, and is stopped
+// if it runs for longer than TimeoutSeconds.
+type PupHealthCommand struct {
+	PupID          string `json:"pupId"`
+	Command        string `json:"command"`
+	TimeoutSeconds int    `json:"timeoutSeconds"`
+}
+
+func (PupHealthCommand) OpName() string { return "pup-health-command" }
+func (o PupHealthCommand) Validate() error {
+	if err := validatePupID(o.PupID); err != nil {
+		return err
+	}
+	if strings.TrimSpace(o.Command) == "" || len(o.Command) > maxHealthCommandLen || strings.ContainsAny(o.Command, "\r\n\x00") {
+		return fmt.Errorf("health command must be a single line of at most %d characters", maxHealthCommandLen)
+	}
+	if o.TimeoutSeconds < 1 || o.TimeoutSeconds > maxHealthCommandTimeout {
+		return fmt.Errorf("timeout must be between 1 and %d seconds", maxHealthCommandTimeout)
+	}
+	return nil
+}
+func (o PupHealthCommand) Argv() []string {
+	return pupCommandArgv(o.PupID, o.Command, o.TimeoutSeconds,
+		"--uid=pup", "--gid=pup",
+		"--property=NoNewPrivileges=yes",
+		"--property=EnvironmentFile=-/storage/.dbx/config.env",
+	)
+}
+
+// pupCommandArgv runs command in the pup's container, with any extra
+// systemd-run options.
+func pupCommandArgv(pupID string, command string, timeoutSeconds int, options ...string) []string {
+	argv := []string{
+		"systemd-run", "--machine=pup-" + pupID, "--wait", "--pipe", "--quiet", "--collect",
+		"--property=RuntimeMaxSec=" + strconv.Itoa(timeoutSeconds),
+	}
+	argv = append(argv, options...)
+	return append(argv, "/bin/sh", "-c", command)
+}
+
+const maxReloadCommandTimeout = 300
+
+// PupReloadCommand runs a pup's manifest-declared config reload command
+// inside its own container, see dogeboxd.PupManifestConfigReload.
+type PupReloadCommand struct {
+	PupID          string `json:"pupId"`
+	Command        string `json:"command"`
+	TimeoutSeconds int    `json:"timeoutSeconds"`
+}
+
+func (PupReloadCommand) OpName() string { return "pup-reload-command" }
+func (o PupReloadCommand) Validate() error {
+	if err := validatePupID(o.PupID); err != nil {
+		return err
+	}
+	if strings.TrimSpace(o.Command) == "" || len(o.Command) > maxHealthCommandLen || strings.ContainsAny(o.Command, "\r\n\x00") {
+		return fmt.Errorf("reload command must be a single line of at most %d characters", maxHealthCommandLen)
+	}
+	if o.TimeoutSeconds < 1 || o.TimeoutSeconds > maxReloadCommandTimeout {
+		return fmt.Errorf("timeout must be between 1 and %d seconds", maxReloadCommandTimeout)
+	}
+	return nil
+}
+func (o PupReloadCommand) Argv() []string {
+	return pupCommandArgv(o.PupID, o.Command, o.TimeoutSeconds)
+}
+
+var (
+	reloadSignals    = map[string]bool{"SIGHUP": true, "SIGUSR1": true, "SIGUSR2": true}
+	serviceNameRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+)
+
+// PupSignalService sends a reload signal to the main process of one of a
+// pup's services, see dogeboxd.PupManifestConfigReload.
+type PupSignalService struct {
+	PupID   string `json:"pupId"`
+	Service string `json:"service"`
+	Signal  string `json:"signal"`
+}
+
+func (PupSignalService) OpName() string { return "pup-signal-service" }
+func (o PupSignalService) Validate() error {
+	if err := validatePupID(o.PupID); err != nil {
+		return err
+	}
+	if !serviceNameRegex.MatchString(o.Service) {
+		return fmt.Errorf("invalid service %q", o.Service)
+	}
+	if !reloadSignals[o.Signal] {
+		return fmt.Errorf("signal %q isn't one of SIGHUP, SIGUSR1 or SIGUSR2", o.Signal)
+	}
+	return nil
+}
+func (o PupSignalService) Argv() []string {
+	return []string{"systemctl", "-M", "pup-" + o.PupID, "kill", "--kill-whom=main", "--signal=" + o.Signal, o.Service + ".service"}
+}
+
+// restartableUnits are the host services dogeboxd may restart, ie: to
+// finish applying an update.
+var restartableUnits = map[string]struct{}{
+	"dogeboxd.service": {},
+	"dkm.service":      {},
+}
+
+const maxRestartDelay = 300
+
+// RestartUnit restarts a core host service. With DelaySeconds set the
+// restart is scheduled as a transient timer, so dogeboxd can restart
+// itself and still report the job that asked for it.
+type RestartUnit struct {
+	Unit         string `json:"unit"`
+	DelaySeconds int    `json:"delaySeconds,omitempty"`
+}
+
+func (RestartUnit) OpName() string { return "restart-unit" }
+func (o RestartUnit) Validate() error {
+	if _, ok := restartableUnits[o.Unit]; !ok {
+		return fmt.Errorf("unit %q may not be restarted", o.Unit)
+	}
+	if o.DelaySeconds < 0 || o.DelaySeconds > maxRestartDelay {
+		return fmt.Errorf("delay must be between 0 and %d seconds", maxRestartDelay)
+	}
+	return nil
+}
+func (o RestartUnit) Argv() []string {
+	if o.DelaySeconds > 0 {
+		return []string{"systemd-run", "--on-active=" + strconv.Itoa(o.DelaySeconds) + "s", "systemctl", "restart", o.Unit}
+	}
+	return []string{"systemctl", "restart", o.Unit}
+}
+
+// UnitIsActive reports a unit's active state, on the host or in a pup.
+type UnitIsActive struct {
+	Unit    string `json:"unit"`
+	Machine string `json:"machine,omitempty"`
+}
+
+func (UnitIsActive) OpName() string { return "unit-is-active" }
+func (UnitIsActive) ReadOnly()      {}
+func (o UnitIsActive) Validate() error {
+	if err := validateMachine(o.Machine); err != nil {
+		return err
+	}
+	return validateUnit(o.Unit)
+}
+func (o UnitIsActive) Argv() []string {
+	if o.Machine != "" {
+		return []string{"systemctl", "-M", o.Machine, "is-active", o.Unit}
+	}
+	return []string{"systemctl", "is-active", o.Unit}
+}
+
+// UnitSubState reports a unit's SubState, ie: running.
+type UnitSubState struct {
+	Unit string `json:"unit"`
+}
+
+func (UnitSubState) OpName() string    { return "unit-sub-state" }
+func (UnitSubState) ReadOnly()         {}
+func (o UnitSubState) Validate() error { return validateUnit(o.Unit) }
+func (o UnitSubState) Argv() []string {
+	return []string{"systemctl", "show", o.Unit, "--property=SubState"}
+}
+
+// UnitStatus is a unit's `systemctl status` summary, without log lines.
+type UnitStatus struct {
+	Unit string `json:"unit"`
+}
+
+func (UnitStatus) OpName() string    { return "unit-status" }
+func (UnitStatus) ReadOnly()         {}
+func (o UnitStatus) Validate() error { return validateUnit(o.Unit) }
+func (o UnitStatus) Argv() []string {
+	return []string{"systemctl", "status", o.Unit, "--no-pager", "--lines=0"}
+}
+
+// UnitLogs is the tail of a unit's journal, on the host or in a pup.
+type UnitLogs struct {
+	Unit    string `json:"unit"`
+	Machine string `json:"machine,omitempty"`
+	Lines   int    `json:"lines"`
+}
+
+func (UnitLogs) OpName() string { return "unit-logs" }
+func (UnitLogs) ReadOnly()      {}
+func (o UnitLogs) Validate() error {
+	if err := validateMachine(o.Machine); err != nil {
+		return err
+	}
+	if o.Lines < 1 || o.Lines > maxUnitLogLine {
+		return fmt.Errorf("lines must be between 1 and %d", maxUnitLogLine)
+	}
+	return validateUnit(o.Unit)
+}
+func (o UnitLogs) Argv() []string {
+	argv := []string{"journalctl"}
+	if o.Machine != "" {
+		argv = append(argv, "-M", o.Machine)
+	}
+	return append(argv, "-u", o.Unit, "-n", strconv.Itoa(o.Lines), "--no-pager")
+}
+
+// ops is every Op rootd will run, keyed by OpName.
+var ops = map[string]func() Op{}
+
+func register(newOp func() Op) {
+	ops[newOp().OpName()] = newOp
+}
+
+func init() {
+	register(func() Op { return &PupStop{} })
+	register(func() Op { return &PupCreateStorage{} })
+	register(func() Op { return &PupDeleteStorage{} })
+	register(func() Op { return &PupWriteKey{} })
+	register(func() Op { return &PupWriteConfig{} })
+	register(func() Op { return &PupExportStorage{} })
+	register(func() Op { return &PupImportStorage{} })
+	register(func() Op { return &ImportBlockchainData{} })
+	register(func() Op { return &ImportNixStore{} })
+	register(func() Op { return &CollectNixGarbage{} })
+	register(func() Op { return &SwitchSystemGeneration{} })
+	register(func() Op { return &DryBuildSystem{} })
+	register(func() Op { return &DiffSystem{} })
+	register(func() Op { return &RebuildSystem{} })
+	register(func() Op { return &Reboot{} })
+	register(func() Op { return &Shutdown{} })
+	register(func() Op { return &WifiScan{} })
+	register(func() Op { return &WifiTest{} })
+	register(func() Op { return &StartPupUnit{} })
+	register(func() Op { return &RestartPupUnit{} })
+	register(func() Op { return &RestartPupLogForwarder{} })
+	register(func() Op { return &PupHealthCommand{} })
+	register(func() Op { return &PupReloadCommand{} })
+	register(func() Op { return &PupSignalService{} })
+	register(func() Op { return &RestartUnit{} })
+	register(func() Op { return &UnitIsActive{} })
+	register(func() Op { return &UnitSubState{} })
+	register(func() Op { return &UnitStatus{} })
+	register(func() Op { return &UnitLogs{} })
+}
+
+// OpNames lists every registered op.
+func OpNames() []string {
+	names := make([]string, 0, len(ops))
+	for name := range ops {
+		names = append(names, name)
+	}
+	return names
+}
+
+// decodeOp builds and validates the Op named in a request.
+func decodeOp(name string, params json.RawMessage) (Op, error) {
+	newOp, ok := ops[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown op %q", name)
+	}
+	op := newOp()
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, op); err != nil {
+			return nil, fmt.Errorf("invalid params for %s: %w", name, err)
+		}
+	}
+	if err := op.Validate(); err != nil {
+		return nil, err
+	}
+	return op, nil
+}
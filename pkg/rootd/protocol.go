@@ -0,0 +1,38 @@
+package rootd
+
+import "encoding/json"
+
+/* rootd speaks newline delimited JSON over a unix socket, one request
+ * per connection:
+ *
+ *   -> {"op": "pup-stop", "params": {"pupId": "abc"}}
+ *   <- {"output": "Stopping container with ID: abc"}
+ *   <- {"done": true}
+ *
+ * Output lines are streamed as the operation runs, the final event has
+ * Done set, along with Error if the operation failed or was refused.
+ */
+
+// DefaultSocketPath is where rootd listens unless told otherwise.
+const DefaultSocketPath = "/run/dbx-rootd/rootd.sock"
+
+type Request struct {
+	Op     string          `json:"op"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+type Event struct {
+	Output string `json:"output,omitempty"`
+	Done   bool   `json:"done,omitempty"`
+	Error  string `json:"error,omitempty"`
+	// Set when Error is because the op was refused rather than failing.
+	Denied bool `json:"denied,omitempty"`
+}
+
+func newRequest(op Op) (Request, error) {
+	params, err := json.Marshal(op)
+	if err != nil {
+		return Request{}, err
+	}
+	return Request{Op: op.OpName(), Params: params}, nil
+}
@@ -0,0 +1,217 @@
+package rootd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpValidation(t *testing.T) {
+	assert.NoError(t, PupStop{PupID: "abc123"}.Validate())
+	assert.Error(t, PupStop{PupID: "abc; rm -rf /"}.Validate())
+	assert.NoError(t, PupStop{PupID: "abc", TimeoutSeconds: 600}.Validate())
+	assert.Error(t, PupStop{PupID: "abc", TimeoutSeconds: -1}.Validate())
+	assert.Error(t, PupCreateStorage{PupID: "abc", DataDir: "relative/dir"}.Validate())
+	assert.Error(t, PupCreateStorage{PupID: "abc", DataDir: "/opt/../etc"}.Validate())
+	assert.Error(t, PupWriteKey{PupID: "abc", DataDir: "/opt/dogebox", KeyFile: "../../etc/shadow"}.Validate())
+	assert.NoError(t, PupWriteKey{PupID: "abc", DataDir: "/opt/dogebox", KeyFile: "delegated.key"}.Validate())
+	assert.NoError(t, PupWriteConfig{PupID: "abc", DataDir: "/opt/dogebox", Config: "{}", Keep: []string{"RPC_PASSWORD"}}.Validate())
+	assert.Error(t, PupWriteConfig{PupID: "abc", DataDir: "/opt/dogebox", Config: "{}", Keep: []string{"A=B"}}.Validate())
+	assert.NoError(t, WifiTest{Interface: "wlan0", SSID: "Such Network", Password: "wow"}.Validate())
+	assert.Error(t, WifiTest{Interface: "wlan0", SSID: ""}.Validate())
+	assert.Error(t, WifiScan{Interface: "-v"}.Validate())
+	assert.NoError(t, PupExportStorage{PupID: "abc", DataDir: "/opt/dogebox", ExportID: "0a1b2c"}.Validate())
+	assert.Error(t, PupExportStorage{PupID: "abc", DataDir: "/opt/dogebox", ExportID: "../../etc"}.Validate())
+	assert.Error(t, PupImportStorage{PupID: "abc", DataDir: "/opt/dogebox", ExportID: ""}.Validate())
+	assert.NoError(t, ImportNixStore{CacheDir: "/tmp/os-upgrade-v1.0.0-abc-store"}.Validate())
+	assert.Error(t, ImportNixStore{CacheDir: "store"}.Validate())
+	assert.NoError(t, CollectNixGarbage{KeepGenerations: 5}.Validate())
+	assert.Error(t, CollectNixGarbage{KeepGenerations: 0}.Validate())
+	assert.NoError(t, SwitchSystemGeneration{Generation: 41}.Validate())
+	assert.Error(t, SwitchSystemGeneration{Generation: 0}.Validate())
+	assert.NoError(t, DryBuildSystem{CustomNix: "/tmp/custom-nix-dry-build-1.nix"}.Validate())
+	assert.Error(t, DryBuildSystem{CustomNix: "/tmp/../etc/passwd"}.Validate())
+	assert.Error(t, DryBuildSystem{CustomNix: "custom.nix"}.Validate())
+	assert.NoError(t, StartPupUnit{Unit: "container@pup-abc.service"}.Validate())
+	assert.Error(t, StartPupUnit{Unit: "sshd.service"}.Validate())
+	assert.NoError(t, RestartPupUnit{Unit: "container@pup-abc.service"}.Validate())
+	assert.Error(t, RestartPupUnit{Unit: "dkm.service"}.Validate())
+	assert.Error(t, RestartPupLogForwarder{PupID: "abc; reboot"}.Validate())
+	assert.NoError(t, PupHealthCommand{PupID: "abc", Command: "curl -f localhost", TimeoutSeconds: 5}.Validate())
+	assert.Error(t, PupHealthCommand{PupID: "abc", Command: "true\nreboot", TimeoutSeconds: 5}.Validate())
+	assert.Error(t, PupHealthCommand{PupID: "abc", Command: "true", TimeoutSeconds: 0}.Validate())
+	assert.NoError(t, PupReloadCommand{PupID: "abc", Command: "core-cli reload", TimeoutSeconds: 30}.Validate())
+	assert.Error(t, PupReloadCommand{PupID: "abc", Command: "true\nreboot", TimeoutSeconds: 30}.Validate())
+	assert.NoError(t, PupSignalService{PupID: "abc", Service: "core", Signal: "SIGHUP"}.Validate())
+	assert.Error(t, PupSignalService{PupID: "abc", Service: "core", Signal: "SIGKILL"}.Validate())
+	assert.Error(t, PupSignalService{PupID: "abc", Service: "../core", Signal: "SIGHUP"}.Validate())
+	assert.Error(t, UnitIsActive{Unit: "sshd.service", Machine: "host"}.Validate())
+	assert.Error(t, UnitLogs{Unit: "sshd.service", Lines: 0}.Validate())
+	assert.NoError(t, RestartUnit{Unit: "dkm.service"}.Validate())
+	assert.Error(t, RestartUnit{Unit: "sshd.service"}.Validate())
+	assert.Error(t, RestartUnit{Unit: "dogeboxd.service", DelaySeconds: 3600}.Validate())
+	assert.NoError(t, PupStop{PupID: "abc", TimeoutSeconds: MaxPupStopTimeoutSeconds + PupContainerStopGraceSeconds}.Validate())
+	assert.Error(t, PupStop{PupID: "abc", TimeoutSeconds: MaxPupStopTimeoutSeconds + PupContainerStopGraceSeconds + 1}.Validate())
+}
+
+func TestOpArgv(t *testing.T) {
+	assert.Equal(t, []string{"_dbxroot", "pup", "stop", "--pupId", "abc"}, PupStop{PupID: "abc"}.Argv())
+	assert.Equal(t, []string{"_dbxroot", "pup", "stop", "--pupId", "abc", "--timeout", "600"}, PupStop{PupID: "abc", TimeoutSeconds: 600}.Argv())
+	assert.Equal(t, []string{"_dbxroot", "nix", "import-store", "--cache-dir", "/tmp/store", "--require-sigs"}, ImportNixStore{CacheDir: "/tmp/store", RequireSigs: true}.Argv())
+	assert.Equal(t, []string{"_dbxroot", "nix", "collect-garbage", "--keep-generations", "5"}, CollectNixGarbage{KeepGenerations: 5}.Argv())
+	assert.Equal(t, []string{"_dbxroot", "nix", "switch-generation", "--generation", "41", "--systemd-run"}, SwitchSystemGeneration{Generation: 41}.Argv())
+	assert.Equal(t, []string{"_dbxroot", "nix", "dry-build", "--custom-nix", "/tmp/custom.nix"}, DryBuildSystem{CustomNix: "/tmp/custom.nix"}.Argv())
+	assert.Equal(t, []string{"_dbxroot", "nix", "diff"}, DiffSystem{}.Argv())
+	assert.Equal(t, []string{"_dbxroot", "nix", "rs"}, RebuildSystem{}.Argv())
+	assert.Equal(t, []string{"_dbxroot", "nix", "rb"}, RebuildSystem{Boot: true}.Argv())
+	assert.Equal(t, []string{"_dbxroot", "pup", "write-config", "--data-dir", "/opt/dogebox", "--pupId", "abc", "--config", "{}", "--keep", "A,B"},
+		PupWriteConfig{PupID: "abc", DataDir: "/opt/dogebox", Config: "{}", Keep: []string{"A", "B"}}.Argv())
+	assert.Equal(t, []string{"systemctl", "-M", "pup-abc", "is-active", "pup-migrations.service"},
+		UnitIsActive{Unit: "pup-migrations.service", Machine: "pup-abc"}.Argv())
+	assert.Equal(t, []string{"journalctl", "-u", "dogeboxd.service", "-n", "20", "--no-pager"},
+		UnitLogs{Unit: "dogeboxd.service", Lines: 20}.Argv())
+	assert.Equal(t, []string{"systemd-run", "--on-active=5s", "systemctl", "restart", "dogeboxd.service"},
+		RestartUnit{Unit: "dogeboxd.service", DelaySeconds: 5}.Argv())
+	assert.Equal(t, []string{"systemctl", "try-restart", "container@pup-abc.service"},
+		RestartPupUnit{Unit: "container@pup-abc.service"}.Argv())
+	assert.Equal(t, []string{"systemctl", "try-restart", "container-log-forwarder@pup-abc.service"},
+		RestartPupLogForwarder{PupID: "abc"}.Argv())
+	assert.Equal(t, []string{"systemd-run", "--machine=pup-abc", "--wait", "--pipe", "--quiet", "--collect",
+		"--property=RuntimeMaxSec=5", "--uid=pup", "--gid=pup", "--property=NoNewPrivileges=yes",
+		"--property=EnvironmentFile=-/storage/.dbx/config.env", "/bin/sh", "-c", "curl -f localhost"},
+		PupHealthCommand{PupID: "abc", Command: "curl -f localhost", TimeoutSeconds: 5}.Argv())
+	assert.Equal(t, []string{"systemctl", "-M", "pup-abc", "kill", "--kill-whom=main", "--signal=SIGHUP", "core.service"},
+		PupSignalService{PupID: "abc", Service: "core", Signal: "SIGHUP"}.Argv())
+}
+
+func TestDecodeOp(t *testing.T) {
+	op, err := decodeOp("pup-stop", json.RawMessage(`{"pupId":"abc"}`))
+	require.NoError(t, err)
+	assert.Equal(t, &PupStop{PupID: "abc"}, op)
+
+	_, err = decodeOp("rm", nil)
+	assert.Error(t, err)
+
+	_, err = decodeOp("pup-stop", json.RawMessage(`{"pupId":"../abc"}`))
+	assert.Error(t, err)
+}
+
+func TestPolicyAuthorize(t *testing.T) {
+	p := Policy{Full: []uint32{1000}, ReadOnly: []uint32{2000}}
+
+	assert.NoError(t, p.Authorize(Peer{UID: 0}, PupStop{PupID: "abc"}))
+	assert.NoError(t, p.Authorize(Peer{UID: 1000}, PupStop{PupID: "abc"}))
+	assert.NoError(t, p.Authorize(Peer{UID: 2000}, UnitStatus{Unit: "dogeboxd.service"}))
+	assert.Error(t, p.Authorize(Peer{UID: 2000}, PupStop{PupID: "abc"}))
+	assert.Error(t, p.Authorize(Peer{UID: 3000}, UnitStatus{Unit: "dogeboxd.service"}))
+}
+
+func startTestServer(t *testing.T, command func(argv []string) (*exec.Cmd, error)) Client {
+	socket := filepath.Join(t.TempDir(), "rootd.sock")
+	l, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+
+	s := &Server{Policy: Policy{Full: []uint32{uint32(os.Getuid())}}, Command: command, DataDir: "/opt/dogebox"}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Serve(ctx, l) }()
+	t.Cleanup(func() {
+		cancel()
+		require.NoError(t, <-done)
+	})
+
+	return NewClient(socket)
+}
+
+func TestServerStreamsOutput(t *testing.T) {
+	client := startTestServer(t, func(argv []string) (*exec.Cmd, error) {
+		return exec.Command("printf", append([]string{"%s\\n"}, argv...)...), nil
+	})
+
+	var lines []string
+	err := client.Do(PupStop{PupID: "abc"}, func(line string) { lines = append(lines, line) })
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"_dbxroot", "pup", "stop", "--pupId", "abc"}, lines)
+}
+
+// Storage ops run against rootd's own data dir, whatever the client sent.
+func TestServerUsesItsOwnDataDir(t *testing.T) {
+	client := startTestServer(t, func(argv []string) (*exec.Cmd, error) {
+		return exec.Command("printf", append([]string{"%s\\n"}, argv...)...), nil
+	})
+
+	var lines []string
+	err := client.Do(PupDeleteStorage{PupID: "abc", DataDir: "/etc"}, func(line string) { lines = append(lines, line) })
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"_dbxroot", "pup", "delete-storage", "--pupId", "abc", "--data-dir", "/opt/dogebox"}, lines)
+}
+
+// Hanging up, ie: when the job times out, stops the op's whole command tree.
+func TestServerStopsOpWhenClientHangsUp(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "sleep.pid")
+	client := startTestServer(t, func(argv []string) (*exec.Cmd, error) {
+		return exec.Command("sh", "-c", "sleep 30 & echo $! > "+pidFile+"; echo started; wait"), nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err := client.DoContext(ctx, PupStop{PupID: "abc"}, func(line string) { cancel() })
+	require.ErrorIs(t, err, context.Canceled)
+
+	pid, err := os.ReadFile(pidFile)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		// Gone, or a zombie waiting to be reaped.
+		stat, err := os.ReadFile("/proc/" + strings.TrimSpace(string(pid)) + "/stat")
+		return err != nil || strings.Contains(string(stat), ") Z ")
+	}, 5*time.Second, 20*time.Millisecond, "background sleep wasn't stopped")
+}
+
+func TestServerReportsFailure(t *testing.T) {
+	client := startTestServer(t, func(argv []string) (*exec.Cmd, error) {
+		return exec.Command("sh", "-c", "echo inactive; exit 3"), nil
+	})
+
+	out, err := client.CombinedOutput(UnitIsActive{Unit: "container@pup-abc.service"})
+
+	require.Error(t, err)
+	assert.False(t, errors.Is(err, ErrDenied))
+	assert.Equal(t, "inactive\n", string(out))
+}
+
+func TestServerRefusesUnknownOps(t *testing.T) {
+	client := startTestServer(t, func(argv []string) (*exec.Cmd, error) {
+		t.Fatalf("unexpectedly ran %v", argv)
+		return nil, nil
+	})
+
+	conn, err := net.Dial("unix", client.SocketPath)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, json.NewEncoder(conn).Encode(Request{Op: "shell", Params: json.RawMessage(`{"cmd":"id"}`)}))
+	var ev Event
+	require.NoError(t, json.NewDecoder(conn).Decode(&ev))
+	assert.True(t, ev.Done)
+	assert.True(t, ev.Denied)
+	assert.Contains(t, ev.Error, "unknown op")
+}
+
+func TestClientValidatesBeforeSending(t *testing.T) {
+	client := NewClient(filepath.Join(t.TempDir(), "missing.sock"))
+
+	err := client.Do(StartPupUnit{Unit: "sshd.service"}, nil)
+
+	assert.True(t, errors.Is(err, ErrDenied))
+}
@@ -0,0 +1,260 @@
+package rootd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Peer is the process on the other end of a rootd connection.
+type Peer struct {
+	PID int32
+	UID uint32
+	GID uint32
+}
+
+/* A Policy says which ops each uid may run. Root may run anything,
+ * anyone not listed may run nothing.
+ */
+type Policy struct {
+	// uids that may run any op
+	Full []uint32
+	// uids that may only run ReadOnlyOps, ie: status and logs
+	ReadOnly []uint32
+}
+
+func (p Policy) Authorize(peer Peer, op Op) error {
+	if peer.UID == 0 {
+		return nil
+	}
+	for _, uid := range p.Full {
+		if uid == peer.UID {
+			return nil
+		}
+	}
+	if _, ok := op.(ReadOnlyOp); ok {
+		for _, uid := range p.ReadOnly {
+			if uid == peer.UID {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("uid %d may not run %s", peer.UID, op.OpName())
+}
+
+// Server runs Ops for authorized peers on a unix socket.
+type Server struct {
+	Policy Policy
+	// Command builds the command for an op's argv, see defaultCommand.
+	Command func(argv []string) (*exec.Cmd, error)
+	// DataDir is dogeboxd's data dir, which DataDirOps are run against.
+	// They're refused if it isn't set.
+	DataDir string
+
+	wg sync.WaitGroup
+}
+
+func NewServer(policy Policy, dataDir string) *Server {
+	return &Server{Policy: policy, Command: defaultCommand, DataDir: dataDir}
+}
+
+// defaultCommand runs _dbxroot ops with this executable, which is expected
+// to be _dbxroot itself, and everything else from PATH.
+func defaultCommand(argv []string) (*exec.Cmd, error) {
+	if argv[0] == "_dbxroot" {
+		self, err := os.Executable()
+		if err != nil {
+			return nil, err
+		}
+		return exec.Command(self, argv[1:]...), nil
+	}
+	return exec.Command(argv[0], argv[1:]...), nil
+}
+
+// Listen creates the socket at path, replacing any stale one, readable and
+// writable by root and gid only.
+func Listen(path string, gid int) (net.Listener, error) {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chown(path, 0, gid); err != nil {
+		l.Close()
+		return nil, err
+	}
+	if err := os.Chmod(path, 0660); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// Serve handles connections until ctx is done, then waits for running
+// operations to finish.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				s.wg.Wait()
+				return nil
+			}
+			return err
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+			s.handle(conn)
+		}()
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	enc := json.NewEncoder(conn)
+
+	peer, err := peerCredentials(conn)
+	if err != nil {
+		enc.Encode(Event{Done: true, Denied: true, Error: fmt.Sprintf("could not identify peer: %v", err)})
+		return
+	}
+
+	var req Request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		enc.Encode(Event{Done: true, Error: fmt.Sprintf("invalid request: %v", err)})
+		return
+	}
+
+	op, err := decodeOp(req.Op, req.Params)
+	if err != nil {
+		log.Printf("rootd: refused %q from uid %d: %v", req.Op, peer.UID, err)
+		enc.Encode(Event{Done: true, Denied: true, Error: err.Error()})
+		return
+	}
+	if err := s.Policy.Authorize(peer, op); err != nil {
+		log.Printf("rootd: refused %s from uid %d: %v", op.OpName(), peer.UID, err)
+		enc.Encode(Event{Done: true, Denied: true, Error: err.Error()})
+		return
+	}
+	if o, ok := op.(DataDirOp); ok {
+		if s.DataDir == "" {
+			log.Printf("rootd: refused %s from uid %d: no data dir configured", op.OpName(), peer.UID)
+			enc.Encode(Event{Done: true, Denied: true, Error: "rootd has no data dir configured"})
+			return
+		}
+		o.SetDataDir(s.DataDir)
+	}
+
+	log.Printf("rootd: running %s for uid %d (pid %d)", op.OpName(), peer.UID, peer.PID)
+	if err := s.run(op, conn, enc); err != nil {
+		enc.Encode(Event{Done: true, Error: err.Error()})
+		return
+	}
+	enc.Encode(Event{Done: true})
+}
+
+// How long an op gets to exit after SIGTERM before being killed.
+var killGrace = 10 * time.Second
+
+/* run streams an op's combined stdout and stderr, line by line.
+ *
+ * Clients send nothing after their request, so a read on conn only
+ * returns once they've hung up, ie: their job timed out. The op's whole
+ * process group is stopped then, as nobody is waiting for it.
+ */
+func (s *Server) run(op Op, conn net.Conn, enc *json.Encoder) error {
+	cmd, err := s.Command(op.Argv())
+	if err != nil {
+		return err
+	}
+
+	r, w := io.Pipe()
+	cmd.Stdout = w
+	cmd.Stderr = w
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		conn.Read(make([]byte, 1))
+		select {
+		case <-finished:
+			return
+		default:
+		}
+		log.Printf("rootd: client hung up, stopping %s", op.OpName())
+		pgid := cmd.Process.Pid
+		syscall.Kill(-pgid, syscall.SIGTERM)
+		select {
+		case <-finished:
+		case <-time.After(killGrace):
+			syscall.Kill(-pgid, syscall.SIGKILL)
+		}
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			enc.Encode(Event{Output: scanner.Text()})
+		}
+		// Keep draining if the client went away or a line was too long,
+		// so the command never blocks on a full pipe.
+		io.Copy(io.Discard, r)
+	}()
+
+	err = cmd.Wait()
+	w.Close()
+	<-done
+	return err
+}
+
+func peerCredentials(conn net.Conn) (Peer, error) {
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return Peer{}, errors.New("not a unix socket")
+	}
+	raw, err := uc.SyscallConn()
+	if err != nil {
+		return Peer{}, err
+	}
+
+	var cred *syscall.Ucred
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	}); err != nil {
+		return Peer{}, err
+	}
+	if credErr != nil {
+		return Peer{}, credErr
+	}
+	return Peer{PID: cred.Pid, UID: cred.Uid, GID: cred.Gid}, nil
+}
@@ -0,0 +1,88 @@
+package dogeboxd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var ErrSecretProviderNotConfigured = errors.New("secret provider not configured")
+
+/* A SecretProvider fetches secrets from an external store, so credentials
+ * can be kept out of DogeboxState. Anything that takes a secret can
+ * instead be given a reference to one, "<provider>:<key>", which is
+ * resolved each time the secret is used, ie:
+ *
+ *	vault:secret/data/dogebox/sources#github-token
+ *	sops:sources.github.token
+ *
+ * What a key looks like is up to the provider.
+ */
+type SecretProvider interface {
+	// The prefix references to this provider use, ie: "vault".
+	Name() string
+	Get(key string) (string, error)
+}
+
+// SecretResolver resolves secret references against the providers that
+// have been configured. A nil SecretResolver has no providers.
+type SecretResolver struct {
+	providers map[string]SecretProvider
+}
+
+func NewSecretResolver(providers ...SecretProvider) *SecretResolver {
+	r := &SecretResolver{providers: map[string]SecretProvider{}}
+	for _, p := range providers {
+		r.providers[p.Name()] = p
+	}
+	return r
+}
+
+// Providers returns the names of the configured providers.
+func (r *SecretResolver) Providers() []string {
+	names := []string{}
+	if r == nil {
+		return names
+	}
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func parseSecretRef(ref string) (string, string, error) {
+	provider, key, ok := strings.Cut(ref, ":")
+	if !ok || provider == "" || strings.TrimSpace(key) == "" {
+		return "", "", fmt.Errorf("secret reference %q must look like <provider>:<key>", ref)
+	}
+	return provider, key, nil
+}
+
+// Validate checks a reference is well formed and its provider configured,
+// without fetching the secret.
+func (r *SecretResolver) Validate(ref string) error {
+	provider, _, err := parseSecretRef(ref)
+	if err != nil {
+		return err
+	}
+	if r == nil || r.providers[provider] == nil {
+		return fmt.Errorf("%w: %s", ErrSecretProviderNotConfigured, provider)
+	}
+	return nil
+}
+
+// Resolve fetches the secret a reference points at.
+func (r *SecretResolver) Resolve(ref string) (string, error) {
+	if err := r.Validate(ref); err != nil {
+		return "", err
+	}
+	provider, key, _ := parseSecretRef(ref)
+
+	secret, err := r.providers[provider].Get(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch secret %q: %w", ref, err)
+	}
+	return secret, nil
+}
@@ -0,0 +1,20 @@
+// Package secrets has the external secret stores dogeboxd can fetch
+// credentials from, see dogeboxd.SecretProvider.
+package secrets
+
+import (
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+// NewResolver returns a SecretResolver with whichever providers config
+// sets up.
+func NewResolver(config dogeboxd.ServerConfig) *dogeboxd.SecretResolver {
+	providers := []dogeboxd.SecretProvider{}
+	if config.VaultAddr != "" {
+		providers = append(providers, NewVaultProvider(config.VaultAddr, config.VaultTokenFile))
+	}
+	if config.SopsFile != "" {
+		providers = append(providers, NewSOPSProvider(config.SopsFile))
+	}
+	return dogeboxd.NewSecretResolver(providers...)
+}
@@ -0,0 +1,68 @@
+package secrets
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+var _ dogeboxd.SecretProvider = &SOPSProvider{}
+
+/* SOPSProvider reads secrets from a SOPS encrypted file, keys are a
+ * dotted path into it, ie: "sources.github.token". The file is decrypted
+ * with the sops binary on every fetch and never written out decrypted.
+ */
+type SOPSProvider struct {
+	file    string
+	decrypt func(file string) ([]byte, error)
+}
+
+func NewSOPSProvider(file string) *SOPSProvider {
+	return &SOPSProvider{file: file, decrypt: sopsDecrypt}
+}
+
+func sopsDecrypt(file string) ([]byte, error) {
+	out, err := exec.Command("sops", "--decrypt", "--output-type", "json", file).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("sops failed: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("failed to run sops: %w", err)
+	}
+	return out, nil
+}
+
+func (s *SOPSProvider) Name() string {
+	return "sops"
+}
+
+func (s *SOPSProvider) Get(key string) (string, error) {
+	out, err := s.decrypt(s.file)
+	if err != nil {
+		return "", err
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(out, &value); err != nil {
+		return "", fmt.Errorf("failed to parse decrypted %s: %w", s.file, err)
+	}
+
+	for _, part := range strings.Split(key, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return "", fmt.Errorf("sops file has no secret %s", key)
+		}
+		if value, ok = m[part]; !ok {
+			return "", fmt.Errorf("sops file has no secret %s", key)
+		}
+	}
+
+	secret, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("sops secret %s isn't a string", key)
+	}
+	return secret, nil
+}
@@ -0,0 +1,42 @@
+package secrets
+
+import (
+	"errors"
+	"testing"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSOPSProviderReadsDottedKeys(t *testing.T) {
+	s := NewSOPSProvider("/etc/dogebox/secrets.enc.yaml")
+	decrypted := []byte(`{"sources":{"github":{"token":"ghp_sops"}},"port":8080}`)
+	s.decrypt = func(file string) ([]byte, error) {
+		assert.Equal(t, "/etc/dogebox/secrets.enc.yaml", file)
+		return decrypted, nil
+	}
+
+	secret, err := s.Get("sources.github.token")
+	require.NoError(t, err)
+	assert.Equal(t, "ghp_sops", secret)
+
+	_, err = s.Get("sources.gitlab.token")
+	assert.Error(t, err)
+	_, err = s.Get("sources.github")
+	assert.Error(t, err, "maps aren't secrets")
+	_, err = s.Get("port")
+	assert.Error(t, err, "only strings are secrets")
+
+	s.decrypt = func(string) ([]byte, error) { return nil, errors.New("sops failed: no key") }
+	_, err = s.Get("sources.github.token")
+	assert.ErrorContains(t, err, "no key")
+}
+
+func TestNewResolverOnlyEnablesConfiguredProviders(t *testing.T) {
+	assert.Empty(t, NewResolver(dogeboxd.ServerConfig{}).Providers())
+	assert.Equal(t, []string{"sops", "vault"}, NewResolver(dogeboxd.ServerConfig{
+		VaultAddr: "https://vault.example.com",
+		SopsFile:  "/etc/dogebox/secrets.enc.yaml",
+	}).Providers())
+}
@@ -0,0 +1,108 @@
+package secrets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+var _ dogeboxd.SecretProvider = &VaultProvider{}
+
+/* VaultProvider reads secrets from a HashiCorp Vault KV engine, keys are
+ * "<path>#<field>", ie: "secret/data/dogebox#github-token". Both KV v1
+ * and v2 paths work.
+ *
+ * The token is read from tokenFile on every fetch so it can be rotated
+ * underneath us, falling back to VAULT_TOKEN.
+ */
+type VaultProvider struct {
+	addr      string
+	tokenFile string
+	client    *http.Client
+}
+
+func NewVaultProvider(addr string, tokenFile string) *VaultProvider {
+	return &VaultProvider{
+		addr:      strings.TrimSuffix(addr, "/"),
+		tokenFile: tokenFile,
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (v *VaultProvider) Name() string {
+	return "vault"
+}
+
+func (v *VaultProvider) token() (string, error) {
+	if v.tokenFile != "" {
+		b, err := os.ReadFile(v.tokenFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read vault token: %w", err)
+		}
+		return strings.TrimSpace(string(b)), nil
+	}
+	if token := os.Getenv("VAULT_TOKEN"); token != "" {
+		return token, nil
+	}
+	return "", errors.New("no vault token configured")
+}
+
+func (v *VaultProvider) Get(key string) (string, error) {
+	path, field, ok := strings.Cut(key, "#")
+	path = strings.Trim(path, "/")
+	if !ok || path == "" || field == "" {
+		return "", fmt.Errorf("vault key %q must look like <path>#<field>", key)
+	}
+
+	token, err := v.token()
+	if err != nil {
+		return "", err
+	}
+
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	req, err := http.NewRequest(http.MethodGet, v.addr+"/v1/"+strings.Join(segments, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault returned %s", resp.Status)
+	}
+
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to parse vault response: %w", err)
+	}
+
+	// KV v2 nests the secret's fields under data.data.
+	data := body.Data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, isMeta := data["metadata"]; isMeta {
+			data = nested
+		}
+	}
+
+	value, ok := data[field].(string)
+	if !ok {
+		return "", fmt.Errorf("vault secret %s has no field %s", path, field)
+	}
+	return value, nil
+}
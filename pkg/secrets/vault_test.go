@@ -0,0 +1,53 @@
+package secrets
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVaultProviderReadsKV(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/dogebox":
+			w.Write([]byte(`{"data":{"data":{"github":"ghp_v2"},"metadata":{"version":3}}}`))
+		case "/v1/kv/dogebox":
+			w.Write([]byte(`{"data":{"github":"ghp_v1"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("s.token\n"), 0600))
+	v := NewVaultProvider(srv.URL+"/", tokenFile)
+
+	secret, err := v.Get("secret/data/dogebox#github")
+	require.NoError(t, err)
+	assert.Equal(t, "ghp_v2", secret)
+
+	secret, err = v.Get("kv/dogebox#github")
+	require.NoError(t, err)
+	assert.Equal(t, "ghp_v1", secret)
+
+	_, err = v.Get("kv/dogebox#missing")
+	assert.Error(t, err)
+	_, err = v.Get("kv/elsewhere#github")
+	assert.ErrorContains(t, err, "404")
+	_, err = v.Get("kv/dogebox")
+	assert.Error(t, err)
+
+	require.NoError(t, os.WriteFile(tokenFile, []byte("s.revoked"), 0600))
+	_, err = v.Get("kv/dogebox#github")
+	assert.ErrorContains(t, err, "403")
+}
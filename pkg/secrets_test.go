@@ -0,0 +1,57 @@
+package dogeboxd
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubSecretProvider struct {
+	name    string
+	secrets map[string]string
+}
+
+func (s *stubSecretProvider) Name() string {
+	return s.name
+}
+
+func (s *stubSecretProvider) Get(key string) (string, error) {
+	secret, ok := s.secrets[key]
+	if !ok {
+		return "", fmt.Errorf("no secret %s", key)
+	}
+	return secret, nil
+}
+
+func TestSecretResolverResolves(t *testing.T) {
+	r := NewSecretResolver(
+		&stubSecretProvider{name: "vault", secrets: map[string]string{"secret/data/dogebox#github": "ghp_token"}},
+		&stubSecretProvider{name: "sops", secrets: map[string]string{}},
+	)
+	assert.Equal(t, []string{"sops", "vault"}, r.Providers())
+
+	secret, err := r.Resolve("vault:secret/data/dogebox#github")
+	require.NoError(t, err)
+	assert.Equal(t, "ghp_token", secret)
+
+	_, err = r.Resolve("sops:missing")
+	assert.ErrorContains(t, err, "sops:missing")
+}
+
+func TestSecretResolverValidates(t *testing.T) {
+	r := NewSecretResolver(&stubSecretProvider{name: "vault"})
+
+	assert.NoError(t, r.Validate("vault:secret/data/dogebox#github"))
+	for _, ref := range []string{"", "vault", "vault:", ":key", "ghp_plaintext_token"} {
+		assert.Error(t, r.Validate(ref), ref)
+	}
+	assert.ErrorIs(t, r.Validate("sops:key"), ErrSecretProviderNotConfigured)
+
+	var none *SecretResolver
+	assert.ErrorIs(t, none.Validate("vault:secret#key"), ErrSecretProviderNotConfigured)
+	_, err := none.Resolve("vault:secret#key")
+	assert.ErrorIs(t, err, ErrSecretProviderNotConfigured)
+	assert.Empty(t, none.Providers())
+}
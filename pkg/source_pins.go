@@ -0,0 +1,32 @@
+package dogeboxd
+
+import (
+	"fmt"
+	"regexp"
+)
+
+/* A git source, or a single pup installed from one, can be pinned to a
+ * commit rather than following its tags, so what's installed is exactly
+ * the code that was reviewed, however the tags move. A pup's pin is kept
+ * in PupState.Source.Commit, and reinstalls, upgrades and rollbacks
+ * fetch from it. Pinned pups aren't offered tagged updates, they're
+ * upgraded by pinning them to another commit.
+ */
+
+// Only full hashes, an abbreviated one could come to match another commit.
+var gitCommitRegex = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// ValidateGitCommit checks a commit to pin to, "" meaning unpinned.
+func ValidateGitCommit(commit string) error {
+	if commit != "" && !gitCommitRegex.MatchString(commit) {
+		return fmt.Errorf("commit must be a full 40 character git commit hash")
+	}
+	return nil
+}
+
+// SetPupSourceCommit pins a pup to commit, or unpins it given "".
+func SetPupSourceCommit(commit string) func(*PupState, *[]Pupdate) {
+	return func(p *PupState, pu *[]Pupdate) {
+		p.Source.Commit = commit
+	}
+}
@@ -0,0 +1,23 @@
+package dogeboxd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateGitCommit(t *testing.T) {
+	assert.NoError(t, ValidateGitCommit(""))
+	assert.NoError(t, ValidateGitCommit("0123456789abcdef0123456789abcdef01234567"))
+	assert.Error(t, ValidateGitCommit("0123456"))
+	assert.Error(t, ValidateGitCommit("v1.0.0"))
+	assert.Error(t, ValidateGitCommit("0123456789ABCDEF0123456789ABCDEF01234567"))
+}
+
+func TestPinnedPupsDontCheckForUpdates(t *testing.T) {
+	p := PupState{Source: ManifestSourceConfiguration{Type: "git"}}
+	assert.True(t, p.ChecksForUpdates())
+
+	p.Source.Commit = "0123456789abcdef0123456789abcdef01234567"
+	assert.False(t, p.ChecksForUpdates())
+}
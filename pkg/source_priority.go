@@ -0,0 +1,77 @@
+package dogeboxd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+/* More than one source can provide a pup with the same name, so a pup is
+ * named by its source as well, "sourceId/pupName", see SourcePupRef.
+ * Where only a name is given, the sources are tried in priority order,
+ * lowest Priority first and then by ID, so the same source always wins.
+ * An installed pup only ever comes from, and is upgraded from, the source
+ * it was installed from, see SameSource.
+ */
+
+const MAX_SOURCE_PRIORITY = 1000
+
+// ValidateSourcePriority checks a source's priority, 0 being the default.
+func ValidateSourcePriority(priority int) error {
+	if priority < 0 || priority > MAX_SOURCE_PRIORITY {
+		return fmt.Errorf("priority must be between 0 and %d", MAX_SOURCE_PRIORITY)
+	}
+	return nil
+}
+
+// ValidateSourceID checks the ID a source gives itself.
+func ValidateSourceID(id string) error {
+	if id == "" {
+		return fmt.Errorf("source id is required")
+	}
+	if strings.Contains(id, "/") {
+		return fmt.Errorf("source id %q can't contain a /", id)
+	}
+	return nil
+}
+
+// SortSourceConfigurations orders configs by priority, the source to
+// prefer first.
+func SortSourceConfigurations(configs []ManifestSourceConfiguration) {
+	sort.SliceStable(configs, func(i, j int) bool {
+		if configs[i].Priority != configs[j].Priority {
+			return configs[i].Priority < configs[j].Priority
+		}
+		return configs[i].ID < configs[j].ID
+	})
+}
+
+// SameSource reports whether a and b are the same source, a source added
+// again under the same ID but somewhere else isn't.
+func SameSource(a, b ManifestSourceConfiguration) bool {
+	return a.ID == b.ID && a.Location == b.Location
+}
+
+// A SourcePupRef names a pup within a source, SourceID is empty when
+// any source will do.
+type SourcePupRef struct {
+	SourceID string
+	PupName  string
+}
+
+// ParseSourcePupRef parses "sourceId/pupName", or a bare "pupName".
+// Source IDs can't contain a /, see ValidateSourceID, pup names can.
+func ParseSourcePupRef(ref string) SourcePupRef {
+	i := strings.Index(ref, "/")
+	if i < 0 {
+		return SourcePupRef{PupName: ref}
+	}
+	return SourcePupRef{SourceID: ref[:i], PupName: ref[i+1:]}
+}
+
+func (r SourcePupRef) String() string {
+	if r.SourceID == "" {
+		return r.PupName
+	}
+	return r.SourceID + "/" + r.PupName
+}
@@ -0,0 +1,52 @@
+package dogeboxd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSourcePupRef(t *testing.T) {
+	tests := map[string]SourcePupRef{
+		"Dogecoin Core":      {PupName: "Dogecoin Core"},
+		"core/Dogecoin Core": {SourceID: "core", PupName: "Dogecoin Core"},
+		"core/AC/DC":         {SourceID: "core", PupName: "AC/DC"},
+	}
+	for ref, want := range tests {
+		t.Run(ref, func(t *testing.T) {
+			got := ParseSourcePupRef(ref)
+			assert.Equal(t, want, got)
+			assert.Equal(t, ref, got.String())
+		})
+	}
+}
+
+func TestSortSourceConfigurations(t *testing.T) {
+	configs := []ManifestSourceConfiguration{
+		{ID: "b"}, {ID: "c", Priority: 10}, {ID: "a"}, {ID: "d", Priority: 5},
+	}
+	SortSourceConfigurations(configs)
+
+	ids := []string{}
+	for _, c := range configs {
+		ids = append(ids, c.ID)
+	}
+	assert.Equal(t, []string{"a", "b", "d", "c"}, ids)
+}
+
+func TestSourceValidation(t *testing.T) {
+	assert.NoError(t, ValidateSourcePriority(0))
+	assert.NoError(t, ValidateSourcePriority(MAX_SOURCE_PRIORITY))
+	assert.Error(t, ValidateSourcePriority(-1))
+	assert.Error(t, ValidateSourcePriority(MAX_SOURCE_PRIORITY+1))
+
+	assert.NoError(t, ValidateSourceID("core"))
+	assert.Error(t, ValidateSourceID(""))
+	assert.Error(t, ValidateSourceID("core/extra"))
+}
+
+func TestSameSource(t *testing.T) {
+	a := ManifestSourceConfiguration{ID: "core", Location: "https://example.com/a.git"}
+	assert.True(t, SameSource(a, ManifestSourceConfiguration{ID: "core", Location: "https://example.com/a.git", Commit: "abc"}))
+	assert.False(t, SameSource(a, ManifestSourceConfiguration{ID: "core", Location: "https://example.com/b.git"}))
+}
@@ -0,0 +1,204 @@
+package dogeboxd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_SOURCE_REFRESH_MINUTES = 6 * 60
+	MIN_SOURCE_REFRESH_MINUTES     = 15
+	MAX_SOURCE_REFRESH_MINUTES     = 7 * 24 * 60
+)
+
+// ValidateSourceRefreshInterval checks a source's refresh interval, 0
+// meaning DEFAULT_SOURCE_REFRESH_MINUTES.
+func ValidateSourceRefreshInterval(minutes int) error {
+	if minutes != 0 && (minutes < MIN_SOURCE_REFRESH_MINUTES || minutes > MAX_SOURCE_REFRESH_MINUTES) {
+		return fmt.Errorf("refreshIntervalMinutes must be between %d and %d, or 0 for the default", MIN_SOURCE_REFRESH_MINUTES, MAX_SOURCE_REFRESH_MINUTES)
+	}
+	return nil
+}
+
+// RefreshInterval is how often the source's listing is refreshed.
+func (c ManifestSourceConfiguration) RefreshInterval() time.Duration {
+	minutes := c.RefreshIntervalMinutes
+	if minutes == 0 {
+		minutes = DEFAULT_SOURCE_REFRESH_MINUTES
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// SourceRefreshStatus is how a source's last refresh went.
+type SourceRefreshStatus struct {
+	// When the source was last listed successfully, nil if it never has been.
+	LastRefreshed *time.Time `json:"lastRefreshed"`
+	LastAttempt   *time.Time `json:"lastAttempt"`
+	NextRefresh   time.Time  `json:"nextRefresh"`
+	// Why the last attempt failed, empty if it didn't.
+	Error string `json:"error,omitempty"`
+	// Pup versions in the catalogue, "name@version".
+	versions []string
+}
+
+// SourceCatalogueChange is sent as a "source-catalogue" Change when a
+// refresh finds pup versions added to or removed from a source.
+type SourceCatalogueChange struct {
+	SourceID string   `json:"sourceId"`
+	Added    []string `json:"added"`
+	Removed  []string `json:"removed"`
+}
+
+/* SourceRefresher keeps source listings fresh between visits to the
+ * store, relisting each source once its RefreshInterval has passed, and
+ * on demand with the RefreshSource action. Refreshing a source also
+ * refreshes its cached listing, so the store shows what was found.
+ */
+type SourceRefresher struct {
+	sources    SourceManager
+	sendChange func(Change)
+	now        func() time.Time
+
+	mu     sync.Mutex
+	status map[string]SourceRefreshStatus
+}
+
+func NewSourceRefresher(sources SourceManager) *SourceRefresher {
+	return &SourceRefresher{
+		sources: sources,
+		now:     time.Now,
+		status:  map[string]SourceRefreshStatus{},
+	}
+}
+
+func (r *SourceRefresher) Run(started, stopped chan bool, stop chan context.Context) error {
+	go func() {
+		go func() {
+			r.tick()
+			ticker := time.NewTicker(time.Minute)
+			defer ticker.Stop()
+		mainloop:
+			for {
+				select {
+				case <-stop:
+					break mainloop
+				case <-ticker.C:
+					r.tick()
+				}
+			}
+		}()
+
+		started <- true
+		<-stop
+		stopped <- true
+	}()
+	return nil
+}
+
+// tick refreshes every source that's due.
+func (r *SourceRefresher) tick() {
+	for _, c := range r.sources.GetAllSourceConfigurations() {
+		if r.due(c) {
+			r.Refresh(c.ID)
+		}
+	}
+}
+
+func (r *SourceRefresher) due(c ManifestSourceConfiguration) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.status[c.ID]
+	return !ok || s.LastAttempt == nil || !r.now().Before(s.LastAttempt.Add(c.RefreshInterval()))
+}
+
+// Refresh relists a source now, ignoring its cache.
+func (r *SourceRefresher) Refresh(id string) (SourceRefreshStatus, error) {
+	source, err := r.sources.GetSource(id)
+	if err != nil {
+		return SourceRefreshStatus{}, err
+	}
+	config := source.Config()
+
+	list, listErr := source.List(true)
+	now := r.now()
+
+	r.mu.Lock()
+	previous, known := r.status[id]
+	status := previous
+	status.LastAttempt = &now
+	status.NextRefresh = now.Add(config.RefreshInterval())
+	status.Error = ""
+	var change *SourceCatalogueChange
+	if listErr != nil {
+		status.Error = listErr.Error()
+	} else {
+		status.LastRefreshed = &now
+		status.versions = catalogueVersions(list)
+		if known && previous.LastRefreshed != nil {
+			change = diffCatalogue(id, previous.versions, status.versions)
+		}
+	}
+	r.status[id] = status
+	r.mu.Unlock()
+
+	if change != nil && r.sendChange != nil {
+		r.sendChange(Change{ID: "internal", Type: "source-catalogue", Update: *change})
+	}
+	return status, listErr
+}
+
+// Status returns the refresh status of every source that has been
+// refreshed, by source ID.
+func (r *SourceRefresher) Status() map[string]SourceRefreshStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	status := make(map[string]SourceRefreshStatus, len(r.status))
+	for id, s := range r.status {
+		status[id] = s
+	}
+	return status
+}
+
+// Forget drops a removed source's status.
+func (r *SourceRefresher) Forget(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.status, id)
+}
+
+func catalogueVersions(list ManifestSourceList) []string {
+	versions := make([]string, 0, len(list.Pups))
+	for _, p := range list.Pups {
+		versions = append(versions, p.Name+"@"+p.Version)
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// diffCatalogue returns nil when the catalogue is unchanged.
+func diffCatalogue(id string, before, after []string) *SourceCatalogueChange {
+	had := map[string]bool{}
+	for _, v := range before {
+		had[v] = true
+	}
+	has := map[string]bool{}
+	change := SourceCatalogueChange{SourceID: id, Added: []string{}, Removed: []string{}}
+	for _, v := range after {
+		has[v] = true
+		if !had[v] {
+			change.Added = append(change.Added, v)
+		}
+	}
+	for _, v := range before {
+		if !has[v] {
+			change.Removed = append(change.Removed, v)
+		}
+	}
+	if len(change.Added) == 0 && len(change.Removed) == 0 {
+		return nil
+	}
+	return &change
+}
@@ -0,0 +1,127 @@
+package dogeboxd
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubRefreshSource struct {
+	ManifestSource
+	config ManifestSourceConfiguration
+	list   ManifestSourceList
+	err    error
+	listed int
+}
+
+func (s *stubRefreshSource) Config() ManifestSourceConfiguration { return s.config }
+
+func (s *stubRefreshSource) List(ignoreCache bool) (ManifestSourceList, error) {
+	s.listed++
+	return s.list, s.err
+}
+
+type stubRefreshSources struct {
+	SourceManager
+	sources map[string]*stubRefreshSource
+}
+
+func (s stubRefreshSources) GetSource(id string) (ManifestSource, error) {
+	if src, ok := s.sources[id]; ok {
+		return src, nil
+	}
+	return nil, errors.New("no source")
+}
+
+func (s stubRefreshSources) GetAllSourceConfigurations() []ManifestSourceConfiguration {
+	configs := []ManifestSourceConfiguration{}
+	for _, src := range s.sources {
+		configs = append(configs, src.config)
+	}
+	return configs
+}
+
+func refreshPups(versions ...string) ManifestSourceList {
+	list := ManifestSourceList{}
+	for _, v := range versions {
+		list.Pups = append(list.Pups, ManifestSourcePup{Name: "core", Version: v})
+	}
+	return list
+}
+
+func TestSourceRefresherSendsCatalogueChanges(t *testing.T) {
+	src := &stubRefreshSource{config: ManifestSourceConfiguration{ID: "main"}, list: refreshPups("1.0.0")}
+	r := NewSourceRefresher(stubRefreshSources{sources: map[string]*stubRefreshSource{"main": src}})
+	changes := []Change{}
+	r.sendChange = func(c Change) { changes = append(changes, c) }
+
+	// The first listing is what later ones are compared with.
+	_, err := r.Refresh("main")
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+
+	_, err = r.Refresh("main")
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+
+	src.list = refreshPups("1.1.0")
+	_, err = r.Refresh("main")
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, "source-catalogue", changes[0].Type)
+	assert.Equal(t, SourceCatalogueChange{SourceID: "main", Added: []string{"core@1.1.0"}, Removed: []string{"core@1.0.0"}}, changes[0].Update)
+}
+
+func TestSourceRefresherKeepsLastGoodListingOnError(t *testing.T) {
+	src := &stubRefreshSource{config: ManifestSourceConfiguration{ID: "main"}, list: refreshPups("1.0.0")}
+	r := NewSourceRefresher(stubRefreshSources{sources: map[string]*stubRefreshSource{"main": src}})
+	changes := []Change{}
+	r.sendChange = func(c Change) { changes = append(changes, c) }
+
+	first, err := r.Refresh("main")
+	require.NoError(t, err)
+
+	src.err = errors.New("unreachable")
+	status, err := r.Refresh("main")
+	require.Error(t, err)
+	assert.Equal(t, "unreachable", status.Error)
+	assert.Equal(t, first.LastRefreshed, status.LastRefreshed)
+	assert.Equal(t, "unreachable", r.Status()["main"].Error)
+
+	// Recovering with the same pups isn't a change.
+	src.err = nil
+	status, err = r.Refresh("main")
+	require.NoError(t, err)
+	assert.Empty(t, status.Error)
+	assert.Empty(t, changes)
+}
+
+func TestSourceRefresherRefreshesWhenDue(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	src := &stubRefreshSource{config: ManifestSourceConfiguration{ID: "main", RefreshIntervalMinutes: 30}}
+	r := NewSourceRefresher(stubRefreshSources{sources: map[string]*stubRefreshSource{"main": src}})
+	r.now = func() time.Time { return now }
+
+	r.tick()
+	assert.Equal(t, 1, src.listed)
+	assert.Equal(t, now.Add(30*time.Minute), r.Status()["main"].NextRefresh)
+
+	now = now.Add(29 * time.Minute)
+	r.tick()
+	assert.Equal(t, 1, src.listed)
+
+	now = now.Add(time.Minute)
+	r.tick()
+	assert.Equal(t, 2, src.listed)
+}
+
+func TestValidateSourceRefreshInterval(t *testing.T) {
+	assert.NoError(t, ValidateSourceRefreshInterval(0))
+	assert.NoError(t, ValidateSourceRefreshInterval(MIN_SOURCE_REFRESH_MINUTES))
+	assert.Error(t, ValidateSourceRefreshInterval(5))
+	assert.Error(t, ValidateSourceRefreshInterval(MAX_SOURCE_REFRESH_MINUTES+1))
+	assert.Equal(t, DEFAULT_SOURCE_REFRESH_MINUTES*time.Minute, ManifestSourceConfiguration{}.RefreshInterval())
+}
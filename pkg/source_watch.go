@@ -0,0 +1,263 @@
+package dogeboxd
+
+import (
+	"context"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	// How long a pup's files must be left alone before a change is sent,
+	// editors write more than once when saving.
+	sourceWatchSettle = 500 * time.Millisecond
+	// How often the watched directories are matched against the sources,
+	// to pick up added and removed disk sources and pups.
+	sourceWatchResync = 30 * time.Second
+)
+
+// SourceFilesChange is sent as a "source-files" Change when a pup's
+// manifest or nix files change in a watched disk source.
+type SourceFilesChange struct {
+	SourceID string `json:"sourceId"`
+	// The pup whose files changed, empty for the source's dogebox.json.
+	PupName string   `json:"pupName,omitempty"`
+	Files   []string `json:"files"`
+	// Dev mode pups queued to be rebuilt, when the source has AutoRebuild.
+	RebuildJobs []string `json:"rebuildJobs,omitempty"`
+}
+
+// Where a watched directory's files come from.
+type watchedSourceDir struct {
+	sourceID string
+	pupName  string
+}
+
+/* SourceWatcher watches disk sources, as used to develop pups, for edits
+ * to pup manifests and nix files. Each change is sent to the frontend so
+ * the store can be refreshed, and for sources with AutoRebuild set, dev
+ * mode pups built from the changed files are rebuilt with RebuildDevPup.
+ *
+ * It only watches when dogeboxd is running in dev mode, or the box has
+ * the development profile, and never on production boxes.
+ */
+type SourceWatcher struct {
+	config     ServerConfig
+	sm         StateManager
+	sources    SourceManager
+	pups       PupManager
+	sendChange func(Change)
+	addAction  func(Action) string
+
+	mu      sync.Mutex
+	watcher *fsnotify.Watcher
+	dirs    map[string]watchedSourceDir
+	pending map[watchedSourceDir]map[string]bool
+}
+
+func NewSourceWatcher(config ServerConfig, sm StateManager, sources SourceManager, pups PupManager) *SourceWatcher {
+	return &SourceWatcher{
+		config:  config,
+		sm:      sm,
+		sources: sources,
+		pups:    pups,
+		dirs:    map[string]watchedSourceDir{},
+		pending: map[watchedSourceDir]map[string]bool{},
+	}
+}
+
+func (w *SourceWatcher) Run(started, stopped chan bool, stop chan context.Context) error {
+	go func() {
+		done := make(chan struct{})
+		// Not being able to watch shouldn't stop dogeboxd, ie: when
+		// we're out of inotify instances.
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			log.Printf("Not watching disk sources: %v", err)
+		} else {
+			w.watcher = watcher
+			go w.watch(done)
+		}
+
+		started <- true
+		<-stop
+		close(done)
+		stopped <- true
+	}()
+	return nil
+}
+
+func (w *SourceWatcher) watch(done chan struct{}) {
+	watcher := w.watcher
+	defer watcher.Close()
+	w.sync()
+	resync := time.NewTicker(sourceWatchResync)
+	defer resync.Stop()
+	settle := time.NewTimer(sourceWatchSettle)
+	settle.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if w.record(event.Name) {
+				settle.Reset(sourceWatchSettle)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("Source watcher error: %v", err)
+		case <-settle.C:
+			w.flush()
+		case <-resync.C:
+			w.sync()
+		}
+	}
+}
+
+func (w *SourceWatcher) enabled() bool {
+	profile := w.sm.Get().Dogebox.Profile
+	return !profile.IsProduction() && (w.config.DevMode || profile.IsDevelopment())
+}
+
+// sync watches the directories of every disk source and its pups, and
+// stops watching any that are gone.
+func (w *SourceWatcher) sync() {
+	want := map[string]watchedSourceDir{}
+	if w.enabled() {
+		for _, c := range w.sources.GetAllSourceConfigurations() {
+			if c.Type != "disk" {
+				continue
+			}
+			want[filepath.Clean(c.Location)] = watchedSourceDir{sourceID: c.ID}
+
+			source, err := w.sources.GetSource(c.ID)
+			if err != nil {
+				continue
+			}
+			list, err := source.List(true)
+			if err != nil {
+				log.Printf("Source watcher failed to list %s: %v", c.ID, err)
+				continue
+			}
+			for _, p := range list.Pups {
+				want[filepath.Clean(p.Location["path"])] = watchedSourceDir{sourceID: c.ID, pupName: p.Name}
+			}
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for dir := range w.dirs {
+		if _, ok := want[dir]; !ok {
+			_ = w.watcher.Remove(dir)
+			delete(w.dirs, dir)
+		}
+	}
+	for dir, d := range want {
+		if _, ok := w.dirs[dir]; !ok {
+			if err := w.watcher.Add(dir); err != nil {
+				log.Printf("Source watcher failed to watch %s: %v", dir, err)
+				continue
+			}
+		}
+		w.dirs[dir] = d
+	}
+}
+
+// isWatchedSourceFile is whether a change to the file is worth telling
+// anyone about: manifests, source indexes and nix files.
+func isWatchedSourceFile(name string) bool {
+	base := filepath.Base(name)
+	return base == "manifest.json" || base == "dogebox.json" || strings.HasSuffix(base, ".nix")
+}
+
+// record notes a changed file, returning false if it isn't one we watch.
+func (w *SourceWatcher) record(name string) bool {
+	if !isWatchedSourceFile(name) {
+		return false
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	d, ok := w.dirs[filepath.Dir(name)]
+	if !ok {
+		return false
+	}
+	if w.pending[d] == nil {
+		w.pending[d] = map[string]bool{}
+	}
+	w.pending[d][filepath.Base(name)] = true
+	return true
+}
+
+// flush sends a change for each pup whose files have settled, queueing
+// rebuilds where the source asks for them.
+func (w *SourceWatcher) flush() {
+	w.mu.Lock()
+	pending := w.pending
+	w.pending = map[watchedSourceDir]map[string]bool{}
+	w.mu.Unlock()
+
+	if len(pending) == 0 {
+		return
+	}
+
+	// A pup may have been added, or renamed in its manifest.
+	w.sync()
+
+	autoRebuild := map[string]bool{}
+	for _, c := range w.sources.GetAllSourceConfigurations() {
+		autoRebuild[c.ID] = c.AutoRebuild
+	}
+
+	dirs := make([]watchedSourceDir, 0, len(pending))
+	for d := range pending {
+		dirs = append(dirs, d)
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		if dirs[i].sourceID != dirs[j].sourceID {
+			return dirs[i].sourceID < dirs[j].sourceID
+		}
+		return dirs[i].pupName < dirs[j].pupName
+	})
+
+	for _, d := range dirs {
+		change := SourceFilesChange{SourceID: d.sourceID, PupName: d.pupName, Files: []string{}}
+		for f := range pending[d] {
+			change.Files = append(change.Files, f)
+		}
+		sort.Strings(change.Files)
+
+		if autoRebuild[d.sourceID] && d.pupName != "" && w.addAction != nil {
+			for _, id := range w.devPupsFor(d) {
+				change.RebuildJobs = append(change.RebuildJobs, w.addAction(RebuildDevPup{PupID: id}))
+			}
+		}
+
+		if w.sendChange != nil {
+			w.sendChange(Change{ID: "internal", Type: "source-files", Update: change})
+		}
+	}
+}
+
+// devPupsFor returns the installed dev mode pups built from d, by ID.
+func (w *SourceWatcher) devPupsFor(d watchedSourceDir) []string {
+	ids := []string{}
+	for id, p := range w.pups.GetStateMap() {
+		if p.IsDevModeEnabled && p.Installation == STATE_READY && p.Source.ID == d.sourceID && p.Manifest.Meta.Name == d.pupName {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
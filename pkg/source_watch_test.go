@@ -0,0 +1,118 @@
+package dogeboxd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type watchTestSource struct {
+	ManifestSource
+	list ManifestSourceList
+}
+
+func (s watchTestSource) List(bool) (ManifestSourceList, error) { return s.list, nil }
+
+type watchTestSourceManager struct {
+	SourceManager
+	sources map[string]watchTestSource
+}
+
+func (m watchTestSourceManager) GetAllSourceConfigurations() []ManifestSourceConfiguration {
+	configs := []ManifestSourceConfiguration{}
+	for _, s := range m.sources {
+		configs = append(configs, s.list.Config)
+	}
+	SortSourceConfigurations(configs)
+	return configs
+}
+
+func (m watchTestSourceManager) GetSource(id string) (ManifestSource, error) {
+	return m.sources[id], nil
+}
+
+func newTestSourceWatcher(t *testing.T, profile BoxProfile, autoRebuild bool, pups map[string]PupState) (*SourceWatcher, string, *[]Change, *[]Action) {
+	dir := t.TempDir()
+	config := ManifestSourceConfiguration{ID: "local", Type: "disk", Location: dir, AutoRebuild: autoRebuild}
+	sources := watchTestSourceManager{sources: map[string]watchTestSource{
+		"local": {list: ManifestSourceList{Config: config, Pups: []ManifestSourcePup{
+			{Name: "core", Location: map[string]string{"path": filepath.Join(dir, "core")}},
+		}}},
+		"remote": {list: ManifestSourceList{Config: ManifestSourceConfiguration{ID: "remote", Type: "git", Location: "https://example.com/pups.git"}}},
+	}}
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "core"), 0755))
+
+	w := NewSourceWatcher(ServerConfig{}, pupLogStateManager{dbx: DogeboxState{Profile: profile}}, sources, pupLogPupManager{states: pups})
+	watcher, err := fsnotify.NewWatcher()
+	require.NoError(t, err)
+	t.Cleanup(func() { watcher.Close() })
+	w.watcher = watcher
+
+	changes := []Change{}
+	actions := []Action{}
+	w.sendChange = func(c Change) { changes = append(changes, c) }
+	w.addAction = func(a Action) string {
+		actions = append(actions, a)
+		return "job"
+	}
+	return w, dir, &changes, &actions
+}
+
+func TestSourceWatcherSendsChangedFiles(t *testing.T) {
+	w, dir, changes, actions := newTestSourceWatcher(t, BOX_PROFILE_DEVELOPMENT, false, nil)
+	w.sync()
+	assert.Len(t, w.dirs, 2)
+
+	assert.True(t, w.record(filepath.Join(dir, "core", "pup.nix")))
+	assert.True(t, w.record(filepath.Join(dir, "core", "manifest.json")))
+	assert.True(t, w.record(filepath.Join(dir, "core", "pup.nix")))
+	assert.False(t, w.record(filepath.Join(dir, "core", "README.md")))
+	assert.False(t, w.record(filepath.Join(dir, "core", "src", "main.nix")))
+	w.flush()
+
+	require.Len(t, *changes, 1)
+	assert.Equal(t, "source-files", (*changes)[0].Type)
+	assert.Equal(t, SourceFilesChange{SourceID: "local", PupName: "core", Files: []string{"manifest.json", "pup.nix"}}, (*changes)[0].Update)
+	assert.Empty(t, *actions)
+
+	// Nothing more until something changes again.
+	w.flush()
+	assert.Len(t, *changes, 1)
+}
+
+func TestSourceWatcherRebuildsDevPups(t *testing.T) {
+	pups := map[string]PupState{
+		"dev":       {ID: "dev", IsDevModeEnabled: true, Installation: STATE_READY, Source: ManifestSourceConfiguration{ID: "local"}, Manifest: PupManifest{Meta: PupManifestMeta{Name: "core"}}},
+		"installed": {ID: "installed", Installation: STATE_READY, Source: ManifestSourceConfiguration{ID: "local"}, Manifest: PupManifest{Meta: PupManifestMeta{Name: "core"}}},
+		"other":     {ID: "other", IsDevModeEnabled: true, Installation: STATE_READY, Source: ManifestSourceConfiguration{ID: "local"}, Manifest: PupManifest{Meta: PupManifestMeta{Name: "map"}}},
+	}
+	w, dir, changes, actions := newTestSourceWatcher(t, BOX_PROFILE_DEVELOPMENT, true, pups)
+	w.sync()
+
+	w.record(filepath.Join(dir, "core", "pup.nix"))
+	w.record(filepath.Join(dir, "dogebox.json"))
+	w.flush()
+
+	assert.Equal(t, []Action{RebuildDevPup{PupID: "dev"}}, *actions)
+	require.Len(t, *changes, 2)
+	assert.Equal(t, SourceFilesChange{SourceID: "local", Files: []string{"dogebox.json"}}, (*changes)[0].Update)
+	assert.Equal(t, []string{"job"}, (*changes)[1].Update.(SourceFilesChange).RebuildJobs)
+}
+
+func TestSourceWatcherOffOnProductionBoxes(t *testing.T) {
+	w, dir, changes, _ := newTestSourceWatcher(t, BOX_PROFILE_DEVELOPMENT, false, nil)
+	w.sync()
+	require.NotEmpty(t, w.dirs)
+
+	w.sm = pupLogStateManager{dbx: DogeboxState{Profile: BOX_PROFILE_PRODUCTION}}
+	w.config.DevMode = true
+	w.sync()
+	assert.Empty(t, w.dirs)
+	assert.False(t, w.record(filepath.Join(dir, "core", "pup.nix")))
+	w.flush()
+	assert.Empty(t, *changes)
+}
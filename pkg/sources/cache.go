@@ -0,0 +1,162 @@
+package source
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/Dogebox-WG/dogeboxd/pkg/version"
+)
+
+/* The manifest cache keeps what's been read from git and registry sources
+ * on disk, so dogeboxd starting up or checking for updates only fetches
+ * what's changed. A git tag is only cloned again when the commit it points
+ * at does, and a registry's index and logos are only downloaded again
+ * when its ETag or Last-Modified does. Disk sources are read directly,
+ * they're for development and change under us.
+ *
+ * Each source's listing is a file in dir, thrown away when it was written
+ * by another release of dogeboxd, as manifests might be read differently.
+ */
+
+// Bump when what's cached changes shape.
+const manifestCacheVersion = 1
+
+type manifestCache struct {
+	dir     string
+	release string
+	// Serialises writes, a source can be listed from more than one place.
+	mu     sync.Mutex
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+type manifestCacheFile struct {
+	Version  int             `json:"version"`
+	Release  string          `json:"release"`
+	Location string          `json:"location"`
+	Data     json.RawMessage `json:"data"`
+}
+
+func newManifestCache(dir string) *manifestCache {
+	return &manifestCache{dir: dir, release: version.GetDBXRelease().Release}
+}
+
+func (c *manifestCache) path(kind, location string) string {
+	sum := sha256.Sum256([]byte(location))
+	return filepath.Join(c.dir, kind+"-"+hex.EncodeToString(sum[:8])+".json")
+}
+
+// load reads what's cached for location into v, false if nothing is. A
+// nil cache never has anything.
+func (c *manifestCache) load(kind, location string, v any) bool {
+	if c == nil {
+		return false
+	}
+
+	data, err := os.ReadFile(c.path(kind, location))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Failed to read source cache for %s: %v", location, err)
+		}
+		return false
+	}
+
+	var f manifestCacheFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		log.Printf("Ignoring unreadable source cache for %s: %v", location, err)
+		return false
+	}
+	if f.Version != manifestCacheVersion || f.Release != c.release || f.Location != location {
+		return false
+	}
+	return json.Unmarshal(f.Data, v) == nil
+}
+
+// save caches v for location, failing quietly as the cache only saves us
+// fetching things again.
+func (c *manifestCache) save(kind, location string, v any) {
+	if c == nil {
+		return
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("Failed to cache source %s: %v", location, err)
+		return
+	}
+	f, err := json.Marshal(manifestCacheFile{
+		Version:  manifestCacheVersion,
+		Release:  c.release,
+		Location: location,
+		Data:     data,
+	})
+	if err != nil {
+		log.Printf("Failed to cache source %s: %v", location, err)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		log.Printf("Failed to cache source %s: %v", location, err)
+		return
+	}
+	p := c.path(kind, location)
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, f, 0644); err != nil {
+		log.Printf("Failed to cache source %s: %v", location, err)
+		return
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		log.Printf("Failed to cache source %s: %v", location, err)
+	}
+}
+
+// record counts a lookup, hit if the cache saved us a fetch.
+func (c *manifestCache) record(hit bool) {
+	if c == nil {
+		return
+	}
+	if hit {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+}
+
+func (c *manifestCache) stats() dogeboxd.SourceCacheStats {
+	if c == nil {
+		return dogeboxd.SourceCacheStats{}
+	}
+
+	stats := dogeboxd.SourceCacheStats{
+		Dir:    c.dir,
+		Hits:   c.hits.Load(),
+		Misses: c.misses.Load(),
+	}
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return stats
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		stats.Sources++
+		stats.SizeBytes += info.Size()
+	}
+	return stats
+}
@@ -0,0 +1,133 @@
+package source
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManifestCacheRoundTrip(t *testing.T) {
+	cache := &manifestCache{dir: t.TempDir(), release: "v1.0.0"}
+
+	var got map[string]string
+	assert.False(t, cache.load("git", "https://example.com/a.git", &got))
+
+	cache.save("git", "https://example.com/a.git", map[string]string{"v1.0.0": "abc"})
+	require.True(t, cache.load("git", "https://example.com/a.git", &got))
+	assert.Equal(t, map[string]string{"v1.0.0": "abc"}, got)
+	assert.False(t, cache.load("git", "https://example.com/b.git", &got))
+
+	stats := cache.stats()
+	assert.Equal(t, 1, stats.Sources)
+	assert.Positive(t, stats.SizeBytes)
+
+	// Another release may read manifests differently.
+	upgraded := &manifestCache{dir: cache.dir, release: "v1.1.0"}
+	assert.False(t, upgraded.load("git", "https://example.com/a.git", &got))
+
+	var none *manifestCache
+	none.save("git", "https://example.com/a.git", got)
+	assert.False(t, none.load("git", "https://example.com/a.git", &got))
+	assert.Equal(t, dogeboxd.SourceCacheStats{}, none.stats())
+}
+
+func TestGitSourceListOnlyClonesChangedTags(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	require.NoError(t, err)
+	first := commitTestPup(t, repo, dir, "1.0.0")
+	_, err = repo.CreateTag("v1.0.0", plumbing.NewHash(first), nil)
+	require.NoError(t, err)
+
+	cache := &manifestCache{dir: t.TempDir()}
+	config := dogeboxd.ManifestSourceConfiguration{ID: "test", Location: dir, Type: "git"}
+
+	list, err := (&ManifestSourceGit{config: config, cache: cache}).List(true)
+	require.NoError(t, err)
+	require.Len(t, list.Pups, 1)
+	assert.Equal(t, int64(0), cache.hits.Load())
+	assert.Equal(t, int64(1), cache.misses.Load())
+
+	// As if dogeboxd had restarted.
+	list, err = (&ManifestSourceGit{config: config, cache: cache}).List(true)
+	require.NoError(t, err)
+	require.Len(t, list.Pups, 1)
+	assert.Equal(t, "1.0.0", list.Pups[0].Version)
+	assert.Equal(t, int64(1), cache.hits.Load())
+
+	// Moving the tag means cloning it again.
+	second := commitTestPup(t, repo, dir, "1.0.1")
+	require.NoError(t, repo.DeleteTag("v1.0.0"))
+	_, err = repo.CreateTag("v1.0.0", plumbing.NewHash(second), nil)
+	require.NoError(t, err)
+
+	list, err = (&ManifestSourceGit{config: config, cache: cache}).List(true)
+	require.NoError(t, err)
+	require.Len(t, list.Pups, 1)
+	assert.Equal(t, "1.0.1", list.Pups[0].Version)
+	assert.Equal(t, int64(2), cache.misses.Load())
+}
+
+func TestRegistrySourceListUsesETag(t *testing.T) {
+	index := RegistryIndex{ID: "test-registry", Name: "Test Registry"}
+	downloads := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		downloads++
+		json.NewEncoder(w).Encode(index)
+	}))
+	t.Cleanup(server.Close)
+
+	cache := &manifestCache{dir: t.TempDir()}
+	config := dogeboxd.ManifestSourceConfiguration{ID: "test-registry", Location: server.URL + "/index.json", Type: "registry"}
+
+	for i := 0; i < 2; i++ {
+		source := &ManifestSourceRegistry{config: config, client: server.Client(), cache: cache}
+		_, err := source.List(true)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, downloads)
+	assert.Equal(t, int64(1), cache.hits.Load())
+	assert.Equal(t, int64(1), cache.misses.Load())
+}
+
+func TestRegistrySourceKeepsLogosWhenIndexChanges(t *testing.T) {
+	version := RegistryIndexVersion{Version: "1.0.0", Manifest: registryTestManifest("1.0.0"), URL: "pup.tar.gz", SHA256: sha256Hex(nil), LogoURL: "logo.png"}
+	index := RegistryIndex{ID: "test-registry", Name: "Test Registry", Pups: []RegistryIndexPup{{Name: "Test Pup", Versions: []RegistryIndexVersion{version}}}}
+	indexes, logos := 0, 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/logo.png" {
+			logos++
+			w.Write([]byte("not really a png"))
+			return
+		}
+		// Changes every time.
+		indexes++
+		w.Header().Set("ETag", fmt.Sprintf(`"v%d"`, indexes))
+		json.NewEncoder(w).Encode(index)
+	}))
+	t.Cleanup(server.Close)
+
+	cache := &manifestCache{dir: t.TempDir()}
+	config := dogeboxd.ManifestSourceConfiguration{ID: "test-registry", Location: server.URL + "/index.json", Type: "registry"}
+
+	for i := 0; i < 2; i++ {
+		source := &ManifestSourceRegistry{config: config, client: server.Client(), cache: cache}
+		_, err := source.List(true)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 2, indexes)
+	assert.Equal(t, 1, logos)
+}
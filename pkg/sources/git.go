@@ -8,6 +8,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
@@ -17,6 +18,8 @@ import (
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/go-git/go-git/v5/storage/memory"
 	"golang.org/x/mod/semver"
 )
@@ -26,8 +29,22 @@ var _ dogeboxd.ManifestSource = &ManifestSourceGit{}
 type ManifestSourceGit struct {
 	serverConfig dogeboxd.ServerConfig
 	config       dogeboxd.ManifestSourceConfiguration
-	_cache       dogeboxd.ManifestSourceList
-	_isCached    bool
+	secrets      *dogeboxd.SecretResolver
+	// nil to always clone, see manifestCache.
+	cache     *manifestCache
+	_cache    dogeboxd.ManifestSourceList
+	_isCached bool
+}
+
+// gitListingCache is what's cached of a git source, the pups at each tag
+// and the commit it pointed at, or at the commit a pinned source is at.
+type gitListingCache struct {
+	Refs map[string]gitCachedRef `json:"refs"`
+}
+
+type gitCachedRef struct {
+	Hash    string        `json:"hash"`
+	Entries []GitPupEntry `json:"entries"`
 }
 
 func (r ManifestSourceGit) ValidateFromLocation(location string) (dogeboxd.ManifestSourceConfiguration, error) {
@@ -65,6 +82,8 @@ func (r ManifestSourceGit) ValidateFromLocation(location string) (dogeboxd.Manif
 			Description: details.Description,
 			Location:    location,
 			Type:        "git",
+			Auth:        r.config.Auth,
+			Mirrors:     validMirrors(details.ID, details.Mirrors, isGitLocation),
 		}, nil
 	}
 
@@ -107,10 +126,63 @@ func (r ManifestSourceGit) ValidateFromLocation(location string) (dogeboxd.Manif
 		Description: "",
 		Location:    location,
 		Type:        "git",
+		Auth:        r.config.Auth,
 	}, nil
 }
 
+func isGitLocation(location string) bool {
+	return (strings.HasPrefix(location, "https://") && strings.HasSuffix(location, ".git")) || strings.HasPrefix(location, "git@")
+}
+
+// downloadFromMirror clones the same tag or commit from mirror. The
+// source's credentials are only ever sent to its own location.
+func (r ManifestSourceGit) downloadFromMirror(mirror string, diskPath string, location map[string]string) error {
+	m := r
+	m.config.Location = mirror
+	m.config.Auth = nil
+	return m.Download(diskPath, location)
+}
+
+// auth fetches the source's credentials from the secret store, if it has
+// any. They're fetched every time so they're never held on to.
+func (r ManifestSourceGit) auth() (transport.AuthMethod, error) {
+	if r.config.Auth == nil {
+		return nil, nil
+	}
+
+	password, err := r.secrets.Resolve(r.config.Auth.PasswordRef)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get source credentials: %w", err)
+	}
+
+	// Token based logins, ie: GitHub, take any username.
+	username := r.config.Auth.Username
+	if username == "" {
+		username = "git"
+	}
+	return &githttp.BasicAuth{Username: username, Password: password}, nil
+}
+
 func (r ManifestSourceGit) GetAllGitTags(location string) ([]string, error) {
+	refs, err := r.getGitTagRefs(location)
+	if err != nil {
+		return []string{}, err
+	}
+
+	tags := []string{}
+	for tag := range refs {
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// getGitTagRefs returns the hash each tag points at, by tag.
+func (r ManifestSourceGit) getGitTagRefs(location string) (map[string]string, error) {
+	auth, err := r.auth()
+	if err != nil {
+		return nil, err
+	}
+
 	rem := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
 		Name: "origin",
 		URLs: []string{location},
@@ -118,16 +190,17 @@ func (r ManifestSourceGit) GetAllGitTags(location string) ([]string, error) {
 
 	refs, err := rem.List(&git.ListOptions{
 		PeelingOption: git.AppendPeeled,
+		Auth:          auth,
 	})
 	if err != nil {
-		return []string{}, err
+		return nil, err
 	}
 
 	// Filters the references list and only keeps tags
-	var tags []string
+	tags := map[string]string{}
 	for _, ref := range refs {
 		if ref.Name().IsTag() {
-			tags = append(tags, ref.Name().Short())
+			tags[ref.Name().Short()] = ref.Hash().String()
 		}
 	}
 
@@ -143,6 +216,11 @@ func (r ManifestSourceGit) Config() dogeboxd.ManifestSourceConfiguration {
 }
 
 func (r ManifestSourceGit) getShallowWorktree(location, tag string) (*git.Worktree, *git.Repository, error) {
+	auth, err := r.auth()
+	if err != nil {
+		return &git.Worktree{}, &git.Repository{}, err
+	}
+
 	storage := memory.NewStorage()
 	fs := memfs.New()
 
@@ -152,6 +230,7 @@ func (r ManifestSourceGit) getShallowWorktree(location, tag string) (*git.Worktr
 		ReferenceName: plumbing.ReferenceName(tag),
 		SingleBranch:  true,
 		Depth:         1,
+		Auth:          auth,
 	})
 	if err != nil {
 		return &git.Worktree{}, &git.Repository{}, fmt.Errorf("failed to clone repository: %w", err)
@@ -165,6 +244,45 @@ func (r ManifestSourceGit) getShallowWorktree(location, tag string) (*git.Worktr
 	return worktree, repo, nil
 }
 
+// getCommitWorktree checks out commit, which unlike a tag means cloning
+// the whole repository, as we can't ask for a commit by name.
+func (r ManifestSourceGit) getCommitWorktree(location, commit string) (*git.Worktree, error) {
+	auth, err := r.auth()
+	if err != nil {
+		return &git.Worktree{}, err
+	}
+
+	repo, err := git.Clone(memory.NewStorage(), memfs.New(), &git.CloneOptions{
+		URL:        location,
+		NoCheckout: true,
+		Auth:       auth,
+	})
+	if err != nil {
+		return &git.Worktree{}, fmt.Errorf("failed to clone repository: %w", err)
+	}
+
+	worktree, err := checkoutCommit(repo, commit)
+	if err != nil {
+		return &git.Worktree{}, err
+	}
+	return worktree, nil
+}
+
+func checkoutCommit(repo *git.Repository, commit string) (*git.Worktree, error) {
+	if _, err := repo.CommitObject(plumbing.NewHash(commit)); err != nil {
+		return nil, fmt.Errorf("commit %s not found: %w", commit, err)
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get worktree: %w", err)
+	}
+	if err := worktree.Checkout(&git.CheckoutOptions{Hash: plumbing.NewHash(commit), Force: true}); err != nil {
+		return nil, fmt.Errorf("failed to check out commit %s: %w", commit, err)
+	}
+	return worktree, nil
+}
+
 func (r ManifestSourceGit) getSourceDetails(location, tag string) (dogeboxd.SourceDetails, bool, error) {
 	worktree, _, err := r.getShallowWorktree(location, tag)
 	if err != nil {
@@ -211,13 +329,17 @@ type GitPupEntry struct {
 }
 
 func (r ManifestSourceGit) ensureTagValidAndGetPups(tag string) ([]GitPupEntry, error) {
-	entries := []GitPupEntry{}
-
 	worktree, _, err := r.getShallowWorktree(r.config.Location, tag)
 	if err != nil {
 		return []GitPupEntry{}, err
 	}
 
+	return r.getPupsFromWorktree(tag, worktree)
+}
+
+func (r ManifestSourceGit) getPupsFromWorktree(tag string, worktree *git.Worktree) ([]GitPupEntry, error) {
+	entries := []GitPupEntry{}
+
 	pupLocations := []string{}
 
 	tagDetails, foundDetails, err := r.getSourceDetailsFromWorktree(worktree)
@@ -310,7 +432,11 @@ func (r *ManifestSourceGit) List(ignoreCache bool) (dogeboxd.ManifestSourceList,
 		return r._cache, nil
 	}
 
-	tags, err := r.GetAllGitTags(r.config.Location)
+	if r.config.Commit != "" {
+		return r.listCommit()
+	}
+
+	tags, err := r.getGitTagRefs(r.config.Location)
 	if err != nil {
 		return dogeboxd.ManifestSourceList{}, err
 	}
@@ -322,16 +448,28 @@ func (r *ManifestSourceGit) List(ignoreCache bool) (dogeboxd.ManifestSourceList,
 	}
 
 	tagVersions := []string{}
-	for _, tagName := range tags {
+	for tagName := range tags {
 		if semver.IsValid(tagName) {
 			tagVersions = append(tagVersions, tagName)
 		}
 	}
 
+	// Only tags that have moved, or are new, need cloning.
+	cached := gitListingCache{}
+	r.cache.load("git", r.config.Location, &cached)
+	listing := gitListingCache{Refs: map[string]gitCachedRef{}}
+
 	resultChan := make(chan TagResult, len(tagVersions))
 	validationSlots := make(chan struct{}, 4)
 
 	for _, version := range tagVersions {
+		if ref, ok := cached.Refs[version]; ok && ref.Hash == tags[version] {
+			r.cache.record(true)
+			resultChan <- TagResult{version: version, entries: ref.Entries}
+			continue
+		}
+		r.cache.record(false)
+
 		go func(version string) {
 			validationSlots <- struct{}{}
 			defer func() {
@@ -357,6 +495,7 @@ func (r *ManifestSourceGit) List(ignoreCache bool) (dogeboxd.ManifestSourceList,
 			log.Printf("Error validating tag %s: %v", result.version, result.err)
 			continue
 		}
+		listing.Refs[result.version] = gitCachedRef{Hash: tags[result.version], Entries: result.entries}
 
 		for _, entry := range result.entries {
 			releaseURL := ""
@@ -377,6 +516,8 @@ func (r *ManifestSourceGit) List(ignoreCache bool) (dogeboxd.ManifestSourceList,
 		}
 	}
 
+	r.cache.save("git", r.config.Location, listing)
+
 	list := dogeboxd.ManifestSourceList{
 		Config:      r.config,
 		LastChecked: time.Now(),
@@ -389,23 +530,106 @@ func (r *ManifestSourceGit) List(ignoreCache bool) (dogeboxd.ManifestSourceList,
 	return r._cache, nil
 }
 
+// listCommit lists the pups at the commit the source is pinned to, a
+// single version of each, whatever the tags say.
+func (r *ManifestSourceGit) listCommit() (dogeboxd.ManifestSourceList, error) {
+	entries, err := r.getCommitPups()
+	if err != nil {
+		return dogeboxd.ManifestSourceList{}, err
+	}
+
+	pups := []dogeboxd.ManifestSourcePup{}
+	for _, entry := range entries {
+		pups = append(pups, dogeboxd.ManifestSourcePup{
+			Name: entry.Manifest.Meta.Name,
+			Location: map[string]string{
+				"commit":  r.config.Commit,
+				"subPath": entry.SubPath,
+			},
+			Version:    entry.Manifest.Meta.Version,
+			Manifest:   entry.Manifest,
+			LogoBase64: entry.LogoBase64,
+		})
+	}
+
+	r._cache = dogeboxd.ManifestSourceList{
+		Config:      r.config,
+		LastChecked: time.Now(),
+		Pups:        pups,
+	}
+	r._isCached = true
+
+	return r._cache, nil
+}
+
+// getCommitPups returns the pups at the commit the source is pinned to,
+// which never change, so once cloned they're kept in the cache.
+func (r *ManifestSourceGit) getCommitPups() ([]GitPupEntry, error) {
+	cached := gitListingCache{}
+	key := r.config.Location + "#" + r.config.Commit
+	if r.cache.load("git", key, &cached) {
+		if ref, ok := cached.Refs[r.config.Commit]; ok {
+			r.cache.record(true)
+			return ref.Entries, nil
+		}
+	}
+	r.cache.record(false)
+
+	worktree, err := r.getCommitWorktree(r.config.Location, r.config.Commit)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := r.getPupsFromWorktree(r.config.Commit, worktree)
+	if err != nil {
+		return nil, err
+	}
+
+	r.cache.save("git", key, gitListingCache{Refs: map[string]gitCachedRef{
+		r.config.Commit: {Hash: r.config.Commit, Entries: entries},
+	}})
+	return entries, nil
+}
+
 func (r ManifestSourceGit) Download(diskPath string, location map[string]string) error {
+	auth, err := r.auth()
+	if err != nil {
+		return err
+	}
+
 	tempDir, err := os.MkdirTemp(r.serverConfig.TmpDir, "pup-clone-")
 	if err != nil {
 		return fmt.Errorf("failed to create temp directory: %w", err)
 	}
 	defer os.RemoveAll(tempDir)
 
-	log.Printf("Cloning repository %s (tag: %s) to temporary directory", r.config.Location, location["tag"])
+	if commit := location["commit"]; commit != "" {
+		log.Printf("Cloning repository %s (commit: %s) to temporary directory", r.config.Location, commit)
 
-	_, err = git.PlainClone(tempDir, false, &git.CloneOptions{
-		URL:           r.config.Location,
-		ReferenceName: plumbing.ReferenceName("refs/tags/" + location["tag"]),
-		SingleBranch:  true,
-		Depth:         1,
-	})
-	if err != nil {
-		return fmt.Errorf("failed to clone repository: %w", err)
+		repo, err := git.PlainClone(tempDir, false, &git.CloneOptions{
+			URL:        r.config.Location,
+			NoCheckout: true,
+			Auth:       auth,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to clone repository: %w", err)
+		}
+		if _, err := checkoutCommit(repo, commit); err != nil {
+			return err
+		}
+	} else {
+		log.Printf("Cloning repository %s (tag: %s) to temporary directory", r.config.Location, location["tag"])
+
+		_, err = git.PlainClone(tempDir, false, &git.CloneOptions{
+			URL:           r.config.Location,
+			ReferenceName: plumbing.ReferenceName("refs/tags/" + location["tag"]),
+			SingleBranch:  true,
+			Depth:         1,
+			Auth:          auth,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to clone repository: %w", err)
+		}
 	}
 
 	// Construct the path to the subpath within the cloned repository
@@ -0,0 +1,62 @@
+package source
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// commitTestPup commits a root-level pup at version to the repo at dir,
+// returning the commit's hash.
+func commitTestPup(t *testing.T, repo *git.Repository, dir string, version string) string {
+	t.Helper()
+
+	manifest, err := json.Marshal(registryTestManifest(version))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "manifest.json"), manifest, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "pup.nix"), []byte("# "+version), 0644))
+
+	worktree, err := repo.Worktree()
+	require.NoError(t, err)
+	_, err = worktree.Add(".")
+	require.NoError(t, err)
+	hash, err := worktree.Commit("pup "+version, &git.CommitOptions{
+		Author: &object.Signature{Name: "Test User", Email: "test@example.com", When: time.Now()},
+	})
+	require.NoError(t, err)
+	return hash.String()
+}
+
+func TestGitSourcePinnedToCommit(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	require.NoError(t, err)
+
+	pinned := commitTestPup(t, repo, dir, "1.0.0")
+	commitTestPup(t, repo, dir, "2.0.0")
+
+	source := &ManifestSourceGit{config: dogeboxd.ManifestSourceConfiguration{ID: "test", Location: dir, Type: "git", Commit: pinned}}
+	list, err := source.List(true)
+	require.NoError(t, err)
+	require.Len(t, list.Pups, 1)
+	assert.Equal(t, "1.0.0", list.Pups[0].Version)
+	assert.Equal(t, pinned, list.Pups[0].Location["commit"])
+
+	dest := filepath.Join(t.TempDir(), "pup")
+	require.NoError(t, source.Download(dest, list.Pups[0].Location))
+	nix, err := os.ReadFile(filepath.Join(dest, "pup.nix"))
+	require.NoError(t, err)
+	assert.Equal(t, "# 1.0.0", string(nix))
+
+	missing := &ManifestSourceGit{config: dogeboxd.ManifestSourceConfiguration{ID: "test", Location: dir, Type: "git", Commit: "0123456789abcdef0123456789abcdef01234567"}}
+	_, err = missing.List(true)
+	assert.ErrorContains(t, err, "not found")
+}
@@ -0,0 +1,119 @@
+package source
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+/* An interfaces source is a JSON document served over HTTPS, at a path
+ * ending in /interfaces.json, that defines interfaces rather than pups,
+ * see dogeboxd.InterfaceDefinition. It lists no pups, so there's nothing
+ * to download from it.
+ */
+
+var _ dogeboxd.ManifestSource = &ManifestSourceInterfaces{}
+
+const maxInterfacesIndexSize = 1 << 20
+
+type InterfacesIndex struct {
+	ID          string                         `json:"id"`
+	Name        string                         `json:"name"`
+	Description string                         `json:"description"`
+	Interfaces  []dogeboxd.InterfaceDefinition `json:"interfaces"`
+}
+
+type ManifestSourceInterfaces struct {
+	config dogeboxd.ManifestSourceConfiguration
+	// nil for the default client.
+	client    *http.Client
+	_cache    dogeboxd.ManifestSourceList
+	_isCached bool
+}
+
+func isInterfacesLocation(location string) bool {
+	u, err := url.Parse(location)
+	return err == nil && u.Scheme == "https" && u.Host != "" && strings.HasSuffix(u.Path, "/interfaces.json")
+}
+
+func (r ManifestSourceInterfaces) ValidateFromLocation(location string) (dogeboxd.ManifestSourceConfiguration, error) {
+	if !isInterfacesLocation(location) {
+		return dogeboxd.ManifestSourceConfiguration{}, fmt.Errorf("interfaces location must be an https URL to an interfaces.json")
+	}
+
+	index, err := r.fetchIndex(location)
+	if err != nil {
+		return dogeboxd.ManifestSourceConfiguration{}, err
+	}
+
+	return dogeboxd.ManifestSourceConfiguration{
+		ID:          index.ID,
+		Name:        index.Name,
+		Description: index.Description,
+		Location:    location,
+		Type:        "interfaces",
+	}, nil
+}
+
+func (r ManifestSourceInterfaces) Config() dogeboxd.ManifestSourceConfiguration {
+	return r.config
+}
+
+func (r *ManifestSourceInterfaces) List(ignoreCache bool) (dogeboxd.ManifestSourceList, error) {
+	if !ignoreCache && r._isCached {
+		return r._cache, nil
+	}
+
+	index, err := r.fetchIndex(r.config.Location)
+	if err != nil {
+		return dogeboxd.ManifestSourceList{}, err
+	}
+
+	interfaces := []dogeboxd.InterfaceDefinition{}
+	for _, d := range index.Interfaces {
+		if err := d.Validate(); err != nil {
+			log.Printf("Skipping interface %s %s from %s: %v", d.Name, d.Version, r.config.ID, err)
+			continue
+		}
+		interfaces = append(interfaces, d)
+	}
+
+	r._cache = dogeboxd.ManifestSourceList{
+		Config:      r.config,
+		LastChecked: time.Now(),
+		Pups:        []dogeboxd.ManifestSourcePup{},
+		Interfaces:  interfaces,
+	}
+	r._isCached = true
+
+	return r._cache, nil
+}
+
+func (r ManifestSourceInterfaces) Download(diskPath string, location map[string]string) error {
+	return fmt.Errorf("interfaces source %s has no pups to download", r.config.ID)
+}
+
+func (r ManifestSourceInterfaces) fetchIndex(location string) (InterfacesIndex, error) {
+	data, err := ManifestSourceRegistry{client: r.client}.get(location, maxInterfacesIndexSize)
+	if err != nil {
+		return InterfacesIndex{}, fmt.Errorf("failed to fetch interfaces: %w", err)
+	}
+
+	var index InterfacesIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		return InterfacesIndex{}, fmt.Errorf("failed to parse interfaces: %w", err)
+	}
+	if index.ID == "" {
+		return InterfacesIndex{}, fmt.Errorf("missing field: id")
+	}
+	if index.Name == "" {
+		return InterfacesIndex{}, fmt.Errorf("missing field: name")
+	}
+	return index, nil
+}
@@ -0,0 +1,56 @@
+package source
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterfacesSourceList(t *testing.T) {
+	index := InterfacesIndex{
+		ID:   "test-interfaces",
+		Name: "Test Interfaces",
+		Interfaces: []dogeboxd.InterfaceDefinition{
+			{Name: "core-rpc", Version: "1.0.0", Description: "Dogecoin Core JSON-RPC", Probe: &dogeboxd.InterfaceContractProbe{Type: dogeboxd.CONTRACT_PROBE_TCP}},
+			{Name: "broken", Version: "one"},
+		},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/defs/interfaces.json", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(index)
+	})
+	server := httptest.NewTLSServer(mux)
+	t.Cleanup(server.Close)
+
+	location := server.URL + "/defs/interfaces.json"
+	source := &ManifestSourceInterfaces{client: server.Client()}
+	config, err := source.ValidateFromLocation(location)
+	require.NoError(t, err)
+	assert.Equal(t, "interfaces", config.Type)
+	assert.Equal(t, "test-interfaces", config.ID)
+
+	source.config = config
+	list, err := source.List(true)
+	require.NoError(t, err)
+	assert.Empty(t, list.Pups)
+	require.Len(t, list.Interfaces, 1)
+	assert.Equal(t, "core-rpc", list.Interfaces[0].Name)
+
+	assert.Error(t, source.Download(t.TempDir(), map[string]string{}))
+}
+
+func TestInterfacesSourceLocations(t *testing.T) {
+	assert.True(t, isInterfacesLocation("https://example.org/defs/interfaces.json"))
+	assert.False(t, isInterfacesLocation("https://example.org/defs/index.json"))
+	assert.False(t, isInterfacesLocation("http://example.org/interfaces.json"))
+
+	sm := &sourceManager{}
+	sourceType, err := sm.determineSourceType("https://example.org/interfaces.json")
+	require.NoError(t, err)
+	assert.Equal(t, "interfaces", sourceType)
+}
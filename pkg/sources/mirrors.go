@@ -0,0 +1,76 @@
+package source
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+// Mirrors a source declares beyond this are ignored.
+const maxSourceMirrors = 8
+
+// A mirroredSource can download a pup from one of its mirrors, see
+// dogeboxd.ManifestSourceConfiguration.Mirrors.
+type mirroredSource interface {
+	downloadFromMirror(mirror string, diskPath string, location map[string]string) error
+}
+
+// validMirrors returns the mirrors that pass valid, logging the rest.
+func validMirrors(sourceID string, mirrors []string, valid func(string) bool) []string {
+	ok := []string{}
+	for _, m := range mirrors {
+		if !valid(m) {
+			log.Printf("Ignoring mirror %q of source %s, it isn't a location of the same type", m, sourceID)
+			continue
+		}
+		if len(ok) == maxSourceMirrors {
+			log.Printf("Ignoring mirrors of source %s beyond the first %d", sourceID, maxSourceMirrors)
+			break
+		}
+		ok = append(ok, m)
+	}
+	return ok
+}
+
+/* downloadWithMirrors downloads a pup from r, trying its mirrors in order
+ * if that fails. Each attempt starts from an empty diskPath. Which
+ * location served the pup goes in jobLog, for the audit trail.
+ */
+func downloadWithMirrors(r dogeboxd.ManifestSource, diskPath string, location map[string]string, jobLog dogeboxd.SubLogger) error {
+	logf := func(msg string, a ...any) {
+		if jobLog != nil {
+			jobLog.Logf(msg, a...)
+		} else {
+			log.Printf(msg, a...)
+		}
+	}
+
+	config := r.Config()
+	err := r.Download(diskPath, location)
+	if err == nil {
+		logf("Downloaded pup from %s", config.Location)
+		return nil
+	}
+
+	m, ok := r.(mirroredSource)
+	if !ok || len(config.Mirrors) == 0 {
+		return err
+	}
+	logf("Failed to download pup from %s: %v", config.Location, err)
+
+	for _, mirror := range config.Mirrors {
+		if rmErr := os.RemoveAll(diskPath); rmErr != nil {
+			return fmt.Errorf("failed to clean pup directory for mirror: %w", rmErr)
+		}
+		if mErr := m.downloadFromMirror(mirror, diskPath, location); mErr != nil {
+			logf("Failed to download pup from mirror %s: %v", mirror, mErr)
+			continue
+		}
+		logf("Downloaded pup from mirror %s", mirror)
+		return nil
+	}
+
+	return fmt.Errorf("%w, and from all %d mirrors", err, len(config.Mirrors))
+}
@@ -0,0 +1,91 @@
+package source
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistrySourceFailsOverToMirrors(t *testing.T) {
+	manifest := registryTestManifest("1.0.0")
+	manifestJSON, err := json.Marshal(manifest)
+	require.NoError(t, err)
+	tarball := registryTestTarball(t, map[string]string{
+		"manifest.json": string(manifestJSON),
+		"pup.nix":       "{}",
+	})
+
+	mux := http.NewServeMux()
+	server := httptest.NewTLSServer(mux)
+	t.Cleanup(server.Close)
+
+	index := RegistryIndex{
+		ID:   "test-registry",
+		Name: "Test Registry",
+		Mirrors: []string{
+			server.URL + "/broken/index.json",
+			server.URL + "/mirror/index.json",
+			// Ignored, mirrors of a registry have to be registries.
+			"https://example.org/pups.git",
+		},
+		Pups: []RegistryIndexPup{{
+			Name:     "Test Pup",
+			Versions: []RegistryIndexVersion{{Version: "1.0.0", Manifest: manifest, URL: "test-pup.tar.gz", SHA256: sha256Hex(tarball)}},
+		}},
+	}
+	mux.HandleFunc("/pups/index.json", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(index)
+	})
+	// The primary has lost its tarball, the first mirror serves the wrong one.
+	mux.HandleFunc("/broken/test-pup.tar.gz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("not the tarball"))
+	})
+	mux.HandleFunc("/mirror/test-pup.tar.gz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(tarball)
+	})
+
+	registry := &ManifestSourceRegistry{client: server.Client()}
+	config, err := registry.ValidateFromLocation(server.URL + "/pups/index.json")
+	require.NoError(t, err)
+	assert.Equal(t, index.Mirrors[:2], config.Mirrors)
+
+	registry.config = config
+	list, err := registry.List(true)
+	require.NoError(t, err)
+	require.Len(t, list.Pups, 1)
+
+	log := &recordingLogger{}
+	dest := filepath.Join(t.TempDir(), "pup")
+	require.NoError(t, downloadWithMirrors(registry, dest, list.Pups[0].Location, log))
+	nix, err := os.ReadFile(filepath.Join(dest, "pup.nix"))
+	require.NoError(t, err)
+	assert.Equal(t, "{}", string(nix))
+	assert.Contains(t, log.lines[len(log.lines)-1], "Downloaded pup from mirror "+server.URL+"/mirror/index.json")
+
+	// Without a working mirror, the primary's error is kept.
+	registry.config.Mirrors = index.Mirrors[:1]
+	err = downloadWithMirrors(registry, dest, list.Pups[0].Location, nil)
+	assert.ErrorContains(t, err, "404")
+	assert.ErrorContains(t, err, "all 1 mirrors")
+}
+
+type recordingLogger struct {
+	lines []string
+}
+
+func (l *recordingLogger) Log(msg string)                    { l.lines = append(l.lines, msg) }
+func (l *recordingLogger) Logf(msg string, a ...any)         { l.Log(fmt.Sprintf(msg, a...)) }
+func (l *recordingLogger) Err(msg string)                    { l.Log(msg) }
+func (l *recordingLogger) Errf(msg string, a ...any)         { l.Logf(msg, a...) }
+func (l *recordingLogger) Progress(p int) dogeboxd.SubLogger { return l }
+func (l *recordingLogger) LogCmd(cmd *exec.Cmd)              {}
+func (l *recordingLogger) OnKill(kill func()) func()         { return func() {} }
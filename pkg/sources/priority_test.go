@@ -0,0 +1,55 @@
+package source
+
+import (
+	"testing"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type listedSource struct {
+	dogeboxd.ManifestSource
+	list dogeboxd.ManifestSourceList
+}
+
+func (s listedSource) Config() dogeboxd.ManifestSourceConfiguration { return s.list.Config }
+
+func (s listedSource) List(ignoreCache bool) (dogeboxd.ManifestSourceList, error) {
+	return s.list, nil
+}
+
+func newListedSource(id string, priority int, pups ...string) listedSource {
+	list := dogeboxd.ManifestSourceList{Config: dogeboxd.ManifestSourceConfiguration{ID: id, Priority: priority}}
+	for _, name := range pups {
+		list.Pups = append(list.Pups, dogeboxd.ManifestSourcePup{Name: name, Version: "1.0.0"})
+	}
+	return listedSource{list: list}
+}
+
+func TestFindSourcePupByPriority(t *testing.T) {
+	sm := &sourceManager{sources: []dogeboxd.ManifestSource{
+		newListedSource("community", 10, "Dogecoin Core", "Wallet"),
+		newListedSource("core", 0, "Dogecoin Core"),
+		newListedSource("zzz", 0, "Wallet"),
+	}}
+
+	ids := []string{}
+	for _, c := range sm.GetAllSourceConfigurations() {
+		ids = append(ids, c.ID)
+	}
+	assert.Equal(t, []string{"core", "zzz", "community"}, ids)
+
+	_, c, err := sm.FindSourcePup("Dogecoin Core", "1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, "core", c.ID)
+
+	_, c, err = sm.FindSourcePup("community/Dogecoin Core", "1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, "community", c.ID)
+
+	_, _, err = sm.FindSourcePup("zzz/Dogecoin Core", "1.0.0")
+	assert.Error(t, err)
+	_, _, err = sm.FindSourcePup("Dogecoin Core", "2.0.0")
+	assert.Error(t, err)
+}
@@ -0,0 +1,450 @@
+package source
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/Dogebox-WG/dogeboxd/pkg/tarball"
+	"github.com/Dogebox-WG/dogeboxd/pkg/utils"
+)
+
+/* A registry source is a JSON index served over HTTPS, so a collection of
+ * pups can be hosted on static object storage or a CDN instead of git:
+ *
+ *	{
+ *	  "id": "my-pups",
+ *	  "name": "My Pups",
+ *	  "description": "optional",
+ *	  "mirrors": ["https://mirror.example.org/pups/index.json"],
+ *	  "pups": [{
+ *	    "name": "Dogecoin Core",
+ *	    "versions": [{
+ *	      "version": "1.0.0",
+ *	      "manifest": { ...manifest.json... },
+ *	      "url": "dogecoin-core-1.0.0.tar.gz",
+ *	      "sha256": "<hex sha256 of the tarball>",
+ *	      "logoUrl": "optional",
+ *	      "releaseNotes": "optional",
+ *	      "releaseDate": "optional RFC 3339 time"
+ *	    }]
+ *	  }]
+ *	}
+ *
+ * url and logoUrl may be relative to the index. Logos are cached by URL,
+ * so a changed logo needs a new logoUrl. Each tarball is a .tar.gz
+ * of the pup's directory, with manifest.json at its root. mirrors are
+ * optional, other indexes with the same tarballs at the same relative
+ * urls, tried if a download fails. A mirror's tarball still has to
+ * match the sha256 from this index.
+ */
+
+var _ dogeboxd.ManifestSource = &ManifestSourceRegistry{}
+
+const (
+	maxRegistryIndexSize   = 16 << 20
+	maxRegistryLogoSize    = 1 << 20
+	maxRegistryTarballSize = 512 << 20
+)
+
+type RegistryIndex struct {
+	ID          string             `json:"id"`
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	Mirrors     []string           `json:"mirrors,omitempty"`
+	Pups        []RegistryIndexPup `json:"pups"`
+}
+
+type RegistryIndexPup struct {
+	Name     string                 `json:"name"`
+	Versions []RegistryIndexVersion `json:"versions"`
+}
+
+type RegistryIndexVersion struct {
+	Version      string               `json:"version"`
+	Manifest     dogeboxd.PupManifest `json:"manifest"`
+	URL          string               `json:"url"`
+	SHA256       string               `json:"sha256"`
+	LogoURL      string               `json:"logoUrl,omitempty"`
+	ReleaseNotes string               `json:"releaseNotes,omitempty"`
+	ReleaseDate  *time.Time           `json:"releaseDate,omitempty"`
+}
+
+type ManifestSourceRegistry struct {
+	config dogeboxd.ManifestSourceConfiguration
+	// nil for the default client.
+	client *http.Client
+	// nil to always download the index, see manifestCache.
+	cache     *manifestCache
+	_cache    dogeboxd.ManifestSourceList
+	_isCached bool
+}
+
+// registryListingCache is what's cached of a registry, its index as it
+// was last served, and the logos it points at, by logo URL.
+type registryListingCache struct {
+	ETag         string            `json:"etag,omitempty"`
+	LastModified string            `json:"lastModified,omitempty"`
+	Index        json.RawMessage   `json:"index"`
+	Logos        map[string]string `json:"logos"`
+}
+
+func isRegistryLocation(location string) bool {
+	u, err := url.Parse(location)
+	return err == nil && u.Scheme == "https" && u.Host != "" && strings.HasSuffix(u.Path, ".json")
+}
+
+func (r ManifestSourceRegistry) ValidateFromLocation(location string) (dogeboxd.ManifestSourceConfiguration, error) {
+	if !isRegistryLocation(location) {
+		return dogeboxd.ManifestSourceConfiguration{}, fmt.Errorf("registry location must be an https URL to a .json index")
+	}
+
+	index, err := r.fetchIndex(location)
+	if err != nil {
+		return dogeboxd.ManifestSourceConfiguration{}, err
+	}
+
+	return dogeboxd.ManifestSourceConfiguration{
+		ID:          index.ID,
+		Name:        index.Name,
+		Description: index.Description,
+		Location:    location,
+		Type:        "registry",
+		Mirrors:     validMirrors(index.ID, index.Mirrors, isRegistryLocation),
+	}, nil
+}
+
+func (r ManifestSourceRegistry) Config() dogeboxd.ManifestSourceConfiguration {
+	return r.config
+}
+
+func (r *ManifestSourceRegistry) List(ignoreCache bool) (dogeboxd.ManifestSourceList, error) {
+	if !ignoreCache && r._isCached {
+		return r._cache, nil
+	}
+
+	index, listing, err := r.fetchIndexCached(r.config.Location)
+	if err != nil {
+		return dogeboxd.ManifestSourceList{}, err
+	}
+	// The index may have changed its mirrors since the source was added.
+	r.config.Mirrors = validMirrors(r.config.ID, index.Mirrors, isRegistryLocation)
+
+	pups := []dogeboxd.ManifestSourcePup{}
+	usedLogos := map[string]bool{}
+	for _, p := range index.Pups {
+		for _, v := range p.Versions {
+			if err := v.validate(p.Name); err != nil {
+				log.Printf("Skipping %s %s from registry %s: %v", p.Name, v.Version, r.config.ID, err)
+				continue
+			}
+
+			tarballURL, err := resolveRegistryURL(r.config.Location, v.URL)
+			if err != nil {
+				log.Printf("Skipping %s %s from registry %s: %v", p.Name, v.Version, r.config.ID, err)
+				continue
+			}
+
+			usedLogos[v.LogoURL] = true
+			pups = append(pups, dogeboxd.ManifestSourcePup{
+				Name: p.Name,
+				Location: map[string]string{
+					"url":    tarballURL,
+					"sha256": strings.ToLower(v.SHA256),
+					// As in the index, to resolve against a mirror.
+					"ref": v.URL,
+				},
+				Version:      v.Version,
+				Manifest:     v.Manifest,
+				LogoBase64:   listing.logo(r, v),
+				ReleaseNotes: v.ReleaseNotes,
+				ReleaseDate:  v.ReleaseDate,
+			})
+		}
+	}
+
+	// Drop logos the index no longer points at.
+	for logoURL := range listing.Logos {
+		if !usedLogos[logoURL] {
+			delete(listing.Logos, logoURL)
+		}
+	}
+	r.cache.save("registry", r.config.Location, listing)
+
+	r._cache = dogeboxd.ManifestSourceList{
+		Config:      r.config,
+		LastChecked: time.Now(),
+		Pups:        pups,
+	}
+	r._isCached = true
+
+	return r._cache, nil
+}
+
+// logo returns v's logo, fetching it only if it isn't cached.
+func (c *registryListingCache) logo(r *ManifestSourceRegistry, v RegistryIndexVersion) string {
+	if logo, ok := c.Logos[v.LogoURL]; ok {
+		return logo
+	}
+	logo := r.fetchLogo(v)
+	c.Logos[v.LogoURL] = logo
+	return logo
+}
+
+func (v RegistryIndexVersion) validate(name string) error {
+	if v.Manifest.Meta.Name != name || v.Manifest.Meta.Version != v.Version {
+		return fmt.Errorf("manifest is for %s %s", v.Manifest.Meta.Name, v.Manifest.Meta.Version)
+	}
+	if err := v.Manifest.Validate(); err != nil {
+		return fmt.Errorf("manifest validation failed: %w", err)
+	}
+	if sum, err := hex.DecodeString(v.SHA256); err != nil || len(sum) != sha256.Size {
+		return fmt.Errorf("invalid sha256 %q", v.SHA256)
+	}
+	return nil
+}
+
+// Logos are nice to have, so failing to fetch one doesn't fail the list.
+func (r ManifestSourceRegistry) fetchLogo(v RegistryIndexVersion) string {
+	if v.LogoURL == "" {
+		return ""
+	}
+	logoURL, err := resolveRegistryURL(r.config.Location, v.LogoURL)
+	if err != nil {
+		log.Printf("failed to fetch logo for %s: %s", v.Manifest.Meta.Name, err)
+		return ""
+	}
+	data, err := r.get(logoURL, maxRegistryLogoSize)
+	if err != nil {
+		log.Printf("failed to fetch logo for %s: %s", v.Manifest.Meta.Name, err)
+		return ""
+	}
+	logoBase64, err := utils.ImageBytesToWebBase64(data, path.Base(logoURL))
+	if err != nil {
+		log.Printf("failed to read/convert logo for %s: %s", v.Manifest.Meta.Name, err)
+		return ""
+	}
+	return logoBase64
+}
+
+/* Download fetches the tarball to a temporary file beside diskPath, so
+ * it's on the same disk rather than in memory, and only extracts it once
+ * its sha256 matches the index's.
+ */
+func (r ManifestSourceRegistry) Download(diskPath string, location map[string]string) error {
+	log.Printf("Downloading %s", location["url"])
+
+	if err := os.MkdirAll(filepath.Dir(diskPath), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(diskPath), "."+filepath.Base(diskPath)+"-*.tar.gz")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	if err := r.getTo(location["url"], maxRegistryTarballSize, io.MultiWriter(tmp, hash)); err != nil {
+		return fmt.Errorf("failed to download pup: %w", err)
+	}
+
+	if actual := hex.EncodeToString(hash.Sum(nil)); actual != location["sha256"] {
+		return fmt.Errorf("pup tarball has sha256 %s, expected %s", actual, location["sha256"])
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := extractRegistryTarball(tmp, diskPath); err != nil {
+		return fmt.Errorf("failed to extract pup: %w", err)
+	}
+
+	log.Printf("Successfully downloaded and extracted %s to %s", location["url"], diskPath)
+	return nil
+}
+
+func (r ManifestSourceRegistry) downloadFromMirror(mirror string, diskPath string, location map[string]string) error {
+	tarballURL, err := resolveRegistryURL(mirror, location["ref"])
+	if err != nil {
+		return err
+	}
+	return r.Download(diskPath, map[string]string{"url": tarballURL, "sha256": location["sha256"]})
+}
+
+func (r ManifestSourceRegistry) fetchIndex(location string) (RegistryIndex, error) {
+	data, err := r.get(location, maxRegistryIndexSize)
+	if err != nil {
+		return RegistryIndex{}, fmt.Errorf("failed to fetch registry index: %w", err)
+	}
+	return parseRegistryIndex(data)
+}
+
+// fetchIndexCached is fetchIndex, using the cached index if the server
+// says it hasn't changed. Logos are kept either way, see List.
+func (r *ManifestSourceRegistry) fetchIndexCached(location string) (RegistryIndex, registryListingCache, error) {
+	cached := registryListingCache{}
+	if !r.cache.load("registry", location, &cached) {
+		cached = registryListingCache{}
+	}
+
+	data, listing, err := r.getIfChanged(location, maxRegistryIndexSize, cached)
+	if err != nil {
+		return RegistryIndex{}, listing, fmt.Errorf("failed to fetch registry index: %w", err)
+	}
+	r.cache.record(data == nil)
+	if data == nil {
+		data = cached.Index
+	} else {
+		listing.Index = data
+		listing.Logos = cached.Logos
+	}
+	if listing.Logos == nil {
+		listing.Logos = map[string]string{}
+	}
+
+	index, err := parseRegistryIndex(data)
+	return index, listing, err
+}
+
+func parseRegistryIndex(data []byte) (RegistryIndex, error) {
+	var index RegistryIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		return RegistryIndex{}, fmt.Errorf("failed to parse registry index: %w", err)
+	}
+	if index.ID == "" {
+		return RegistryIndex{}, fmt.Errorf("missing field: id")
+	}
+	if index.Name == "" {
+		return RegistryIndex{}, fmt.Errorf("missing field: name")
+	}
+	return index, nil
+}
+
+func (r ManifestSourceRegistry) get(u string, limit int64) ([]byte, error) {
+	data, _, err := r.getIfChanged(u, limit, registryListingCache{})
+	return data, err
+}
+
+// getTo is get, but streams u to w rather than holding it in memory. Big
+// downloads can take longer than get's timeout, so only the wait for the
+// server to answer is limited.
+func (r ManifestSourceRegistry) getTo(u string, limit int64, w io.Writer) error {
+	client := r.client
+	if client == nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.ResponseHeaderTimeout = 60 * time.Second
+		client = &http.Client{Transport: transport}
+	}
+
+	resp, err := client.Get(u)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %s", u, resp.Status)
+	}
+
+	n, err := io.Copy(w, io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return err
+	}
+	if n > limit {
+		return fmt.Errorf("%s is larger than %d bytes", u, limit)
+	}
+	return nil
+}
+
+func (r ManifestSourceRegistry) httpClient() *http.Client {
+	if r.client == nil {
+		return &http.Client{Timeout: 60 * time.Second}
+	}
+	return r.client
+}
+
+/* getIfChanged is get, but asks the server not to send u again if it
+ * hasn't changed since cached was, returning nil data if it hasn't. The
+ * returned cache has the validators for what was sent, or is cached if
+ * it wasn't.
+ */
+func (r ManifestSourceRegistry) getIfChanged(u string, limit int64, cached registryListingCache) ([]byte, registryListingCache, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, cached, err
+	}
+	if cached.ETag != "" {
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+	if cached.LastModified != "" {
+		req.Header.Set("If-Modified-Since", cached.LastModified)
+	}
+
+	resp, err := r.httpClient().Do(req)
+	if err != nil {
+		return nil, cached, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && (cached.ETag != "" || cached.LastModified != "") {
+		return nil, cached, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, cached, fmt.Errorf("GET %s returned %s", u, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, cached, err
+	}
+	if int64(len(data)) > limit {
+		return nil, cached, fmt.Errorf("%s is larger than %d bytes", u, limit)
+	}
+	return data, registryListingCache{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}, nil
+}
+
+// resolveRegistryURL resolves ref against the index's URL, only allowing
+// https so a registry can't point us at plain http or local files.
+func resolveRegistryURL(indexURL string, ref string) (string, error) {
+	base, err := url.Parse(indexURL)
+	if err != nil {
+		return "", err
+	}
+	u, err := base.Parse(ref)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL %q", ref)
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return "", fmt.Errorf("URL %q must be https", ref)
+	}
+	return u.String(), nil
+}
+
+// extractRegistryTarball unpacks a .tar.gz into dest, which it creates.
+// Only plain files and directories are allowed, none outside dest.
+func extractRegistryTarball(src io.Reader, dest string) error {
+	gz, err := gzip.NewReader(src)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	return tarball.Extract(tar.NewReader(gz), dest, tarball.Options{})
+}
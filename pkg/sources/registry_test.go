@@ -0,0 +1,149 @@
+package source
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func registryTestManifest(version string) dogeboxd.PupManifest {
+	return dogeboxd.PupManifest{
+		ManifestVersion: 1,
+		Meta:            dogeboxd.PupManifestMeta{Name: "Test Pup", Version: version},
+		Container: dogeboxd.PupManifestContainer{
+			Build: dogeboxd.PupManifestBuild{NixFile: "pup.nix", NixFileSha256: "abc"},
+		},
+	}
+}
+
+func registryTestTarball(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// serveRegistry serves index.json and any files given, returning the
+// index's URL and a source that trusts the test server.
+func serveRegistry(t *testing.T, index RegistryIndex, files map[string][]byte) (string, *ManifestSourceRegistry) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/pups/index.json", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(index)
+	})
+	for name, data := range files {
+		mux.HandleFunc("/pups/"+name, func(w http.ResponseWriter, r *http.Request) {
+			w.Write(data)
+		})
+	}
+	server := httptest.NewTLSServer(mux)
+	t.Cleanup(server.Close)
+
+	location := server.URL + "/pups/index.json"
+	return location, &ManifestSourceRegistry{client: server.Client()}
+}
+
+func TestRegistrySourceListAndDownload(t *testing.T) {
+	manifest := registryTestManifest("1.0.0")
+	manifestJSON, err := json.Marshal(manifest)
+	require.NoError(t, err)
+	tarball := registryTestTarball(t, map[string]string{
+		"manifest.json": string(manifestJSON),
+		"pup.nix":       "{}",
+	})
+
+	index := RegistryIndex{
+		ID:   "test-registry",
+		Name: "Test Registry",
+		Pups: []RegistryIndexPup{{
+			Name: "Test Pup",
+			Versions: []RegistryIndexVersion{
+				{Version: "1.0.0", Manifest: manifest, URL: "test-pup-1.0.0.tar.gz", SHA256: sha256Hex(tarball), ReleaseNotes: "First"},
+				// Skipped, the manifest is for another version.
+				{Version: "1.1.0", Manifest: manifest, URL: "test-pup-1.1.0.tar.gz", SHA256: sha256Hex(tarball)},
+				// Skipped, tarballs have to come over https.
+				{Version: "1.2.0", Manifest: registryTestManifest("1.2.0"), URL: "http://example.org/test-pup.tar.gz", SHA256: sha256Hex(tarball)},
+			},
+		}},
+	}
+	location, registry := serveRegistry(t, index, map[string][]byte{"test-pup-1.0.0.tar.gz": tarball})
+
+	config, err := registry.ValidateFromLocation(location)
+	require.NoError(t, err)
+	assert.Equal(t, "test-registry", config.ID)
+	assert.Equal(t, "registry", config.Type)
+
+	registry.config = config
+	list, err := registry.List(true)
+	require.NoError(t, err)
+	require.Len(t, list.Pups, 1)
+	pup := list.Pups[0]
+	assert.Equal(t, "1.0.0", pup.Version)
+	assert.Equal(t, "First", pup.ReleaseNotes)
+	assert.Equal(t, location[:len(location)-len("index.json")]+"test-pup-1.0.0.tar.gz", pup.Location["url"])
+
+	dest := filepath.Join(t.TempDir(), "pup")
+	require.NoError(t, registry.Download(dest, pup.Location))
+	nix, err := os.ReadFile(filepath.Join(dest, "pup.nix"))
+	require.NoError(t, err)
+	assert.Equal(t, "{}", string(nix))
+}
+
+func TestRegistrySourceDownloadChecksSHA256(t *testing.T) {
+	tarball := registryTestTarball(t, map[string]string{"manifest.json": "{}"})
+	location, registry := serveRegistry(t, RegistryIndex{ID: "r", Name: "r"}, map[string][]byte{"pup.tar.gz": tarball})
+
+	url := location[:len(location)-len("index.json")] + "pup.tar.gz"
+	err := registry.Download(t.TempDir(), map[string]string{"url": url, "sha256": sha256Hex([]byte("something else"))})
+	assert.ErrorContains(t, err, "sha256")
+}
+
+func TestRegistrySourceRejectsBadLocations(t *testing.T) {
+	for _, location := range []string{
+		"http://example.org/index.json",
+		"https://example.org/pups.git",
+		"/srv/pups/index.json",
+	} {
+		assert.False(t, isRegistryLocation(location), location)
+	}
+	assert.True(t, isRegistryLocation("https://cdn.example.org/pups/index.json"))
+}
+
+func TestExtractRegistryTarballRejectsEscapes(t *testing.T) {
+	for _, name := range []string{"../evil", "/etc/evil", "a/../../evil"} {
+		tarball := registryTestTarball(t, map[string]string{name: "x"})
+		err := extractRegistryTarball(bytes.NewReader(tarball), t.TempDir())
+		assert.ErrorContains(t, err, "outside", name)
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "link", Linkname: "/etc/passwd", Typeflag: tar.TypeSymlink}))
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	assert.ErrorContains(t, extractRegistryTarball(&buf, t.TempDir()), "isn't a file")
+}
@@ -15,8 +15,9 @@ import (
 var REQUIRED_FILES = []string{"pup.nix", "manifest.json"}
 
 // TODO: This should take storeManager and manage state internally not via Statemanager
-func NewSourceManager(config dogeboxd.ServerConfig, sm dogeboxd.StateManager, pm dogeboxd.PupManager) dogeboxd.SourceManager {
+func NewSourceManager(config dogeboxd.ServerConfig, sm dogeboxd.StateManager, pm dogeboxd.PupManager, secrets *dogeboxd.SecretResolver) dogeboxd.SourceManager {
 	state := sm.Get().Sources
+	cache := newManifestCache(filepath.Join(config.DataDir, "source-cache"))
 
 	sources := []dogeboxd.ManifestSource{}
 	for _, c := range state.SourceConfigs {
@@ -24,7 +25,11 @@ func NewSourceManager(config dogeboxd.ServerConfig, sm dogeboxd.StateManager, pm
 		case "disk":
 			sources = append(sources, ManifestSourceDisk{config: c})
 		case "git":
-			sources = append(sources, &ManifestSourceGit{serverConfig: config, config: c})
+			sources = append(sources, &ManifestSourceGit{serverConfig: config, config: c, secrets: secrets, cache: cache})
+		case "registry":
+			sources = append(sources, &ManifestSourceRegistry{config: c, cache: cache})
+		case "interfaces":
+			sources = append(sources, &ManifestSourceInterfaces{config: c})
 		}
 	}
 
@@ -33,7 +38,9 @@ func NewSourceManager(config dogeboxd.ServerConfig, sm dogeboxd.StateManager, pm
 	sourceManager := sourceManager{
 		sm:      sm,
 		pm:      pm,
+		secrets: secrets,
 		sources: sources,
+		cache:   cache,
 	}
 
 	return &sourceManager
@@ -44,7 +51,9 @@ var _ dogeboxd.SourceManager = &sourceManager{}
 type sourceManager struct {
 	sm      dogeboxd.StateManager
 	pm      dogeboxd.PupManager
+	secrets *dogeboxd.SecretResolver
 	sources []dogeboxd.ManifestSource
+	cache   *manifestCache
 }
 
 func (sourceManager *sourceManager) GetAll(ignoreCache bool) (map[string]dogeboxd.ManifestSourceList, error) {
@@ -131,14 +140,91 @@ func (sourceManager *sourceManager) GetSource(id string) (dogeboxd.ManifestSourc
 	return nil, fmt.Errorf("no source found with id %s", id)
 }
 
+// getSourceAt returns the source with id pinned to commit, a copy
+// unless it's already pinned there, "" for the source as it is.
+func (sourceManager *sourceManager) getSourceAt(id, commit string) (dogeboxd.ManifestSource, error) {
+	r, err := sourceManager.GetSource(id)
+	if err != nil || commit == "" || r.Config().Commit == commit {
+		return r, err
+	}
+	if err := dogeboxd.ValidateGitCommit(commit); err != nil {
+		return nil, err
+	}
+
+	g, ok := r.(*ManifestSourceGit)
+	if !ok {
+		return nil, fmt.Errorf("only git sources can be pinned to a commit")
+	}
+	config := g.config
+	config.Commit = commit
+	return &ManifestSourceGit{serverConfig: g.serverConfig, config: config, secrets: g.secrets, cache: g.cache}, nil
+}
+
+func findSourcePup(r dogeboxd.ManifestSource, pupName, pupVersion string) (dogeboxd.ManifestSourcePup, error) {
+	l, err := r.List(false)
+	if err != nil {
+		return dogeboxd.ManifestSourcePup{}, err
+	}
+	for _, pup := range l.Pups {
+		if pup.Name == pupName && pup.Version == pupVersion {
+			return pup, nil
+		}
+	}
+	return dogeboxd.ManifestSourcePup{}, fmt.Errorf("no pup found with name %s and version %s", pupName, pupVersion)
+}
+
+func (sourceManager *sourceManager) GetCacheStats() dogeboxd.SourceCacheStats {
+	return sourceManager.cache.stats()
+}
+
+func (sourceManager *sourceManager) FindSourcePup(ref, pupVersion string) (dogeboxd.ManifestSourcePup, dogeboxd.ManifestSourceConfiguration, error) {
+	pupRef := dogeboxd.ParseSourcePupRef(ref)
+	if pupRef.SourceID != "" {
+		r, err := sourceManager.GetSource(pupRef.SourceID)
+		if err != nil {
+			return dogeboxd.ManifestSourcePup{}, dogeboxd.ManifestSourceConfiguration{}, err
+		}
+		pup, err := findSourcePup(r, pupRef.PupName, pupVersion)
+		return pup, r.Config(), err
+	}
+
+	// Without a source, the first by priority to have it wins.
+	for _, c := range sourceManager.GetAllSourceConfigurations() {
+		r, err := sourceManager.GetSource(c.ID)
+		if err != nil {
+			continue
+		}
+		if pup, err := findSourcePup(r, pupRef.PupName, pupVersion); err == nil {
+			return pup, c, nil
+		}
+	}
+	return dogeboxd.ManifestSourcePup{}, dogeboxd.ManifestSourceConfiguration{}, fmt.Errorf("no source has %s %s", pupRef.PupName, pupVersion)
+}
+
+func (sourceManager *sourceManager) GetSourceManifestAt(sourceID, commit, pupName, pupVersion string) (dogeboxd.PupManifest, dogeboxd.ManifestSource, error) {
+	r, err := sourceManager.getSourceAt(sourceID, commit)
+	if err != nil {
+		return dogeboxd.PupManifest{}, nil, err
+	}
+	pup, err := findSourcePup(r, pupName, pupVersion)
+	if err != nil {
+		return dogeboxd.PupManifest{}, nil, err
+	}
+	return pup.Manifest, r, nil
+}
+
 // DownloadPup downloads a pup and returns the manifest
 func (sourceManager *sourceManager) DownloadPup(path, sourceId, pupName, pupVersion string) (dogeboxd.PupManifest, error) {
-	r, err := sourceManager.GetSource(sourceId)
+	return sourceManager.DownloadPupAt(path, sourceId, "", pupName, pupVersion, nil)
+}
+
+func (sourceManager *sourceManager) DownloadPupAt(path, sourceId, commit, pupName, pupVersion string, jobLog dogeboxd.SubLogger) (dogeboxd.PupManifest, error) {
+	r, err := sourceManager.getSourceAt(sourceId, commit)
 	if err != nil {
 		return dogeboxd.PupManifest{}, err
 	}
 
-	sourcePup, err := sourceManager.GetSourcePup(sourceId, pupName, pupVersion)
+	sourcePup, err := findSourcePup(r, pupName, pupVersion)
 	if err != nil {
 		return dogeboxd.PupManifest{}, err
 	}
@@ -164,9 +250,11 @@ func (sourceManager *sourceManager) DownloadPup(path, sourceId, pupName, pupVers
 		return dogeboxd.PupManifest{}, fmt.Errorf("failed to create parent directory: %w", err)
 	}
 
-	if err := r.Download(path, sourcePup.Location); err != nil {
+	downloadStart := time.Now()
+	if err := downloadWithMirrors(r, path, sourcePup.Location, jobLog); err != nil {
 		return dogeboxd.PupManifest{}, err
 	}
+	dogeboxd.ObserveSourceDownload(diskUsage(path), time.Since(downloadStart))
 
 	// Validate the manifest
 	manifestPath := filepath.Join(path, "manifest.json")
@@ -192,6 +280,21 @@ func (sourceManager *sourceManager) DownloadPup(path, sourceId, pupName, pupVers
 	return manifest, nil
 }
 
+// diskUsage totals the size of every file under path, for download metrics.
+func diskUsage(path string) int64 {
+	var total int64
+	filepath.WalkDir(path, func(_ string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			total += info.Size()
+		}
+		return nil
+	})
+	return total
+}
+
 func (sourceManager *sourceManager) validatePupFiles(path string) error {
 	manifestPath := filepath.Join(path, "manifest.json")
 	manifestData, err := os.ReadFile(manifestPath)
@@ -225,16 +328,22 @@ func (sourceManager *sourceManager) GetAllSourceConfigurations() []dogeboxd.Mani
 	for _, s := range sourceManager.sources {
 		configs = append(configs, s.Config())
 	}
+	dogeboxd.SortSourceConfigurations(configs)
 	return configs
 }
 
 func (sourceManager *sourceManager) determineSourceType(location string) (string, error) {
-	if strings.HasPrefix(location, "https://") && strings.HasSuffix(location, ".git") {
+	if isGitLocation(location) {
 		return "git", nil
 	}
 
-	if strings.HasPrefix(location, "git@") {
-		return "git", nil
+	// interfaces.json would also pass for a registry index.
+	if isInterfacesLocation(location) {
+		return "interfaces", nil
+	}
+
+	if isRegistryLocation(location) {
+		return "registry", nil
 	}
 
 	if strings.HasPrefix(location, "/") {
@@ -248,7 +357,40 @@ func (sourceManager *sourceManager) determineSourceType(location string) (string
 	return "", fmt.Errorf("unknown source type: %s", location)
 }
 
-func (sourceManager *sourceManager) AddSource(location string) (dogeboxd.ManifestSource, error) {
+func (sourceManager *sourceManager) PreviewSource(location string) (dogeboxd.ManifestSourceList, error) {
+	sourceType, err := sourceManager.determineSourceType(location)
+	if err != nil {
+		return dogeboxd.ManifestSourceList{}, err
+	}
+
+	switch sourceType {
+	case "git":
+		config, err := ManifestSourceGit{}.ValidateFromLocation(location)
+		if err != nil {
+			return dogeboxd.ManifestSourceList{}, err
+		}
+		s := &ManifestSourceGit{config: config, cache: sourceManager.cache}
+		return s.List(true)
+	case "registry":
+		config, err := ManifestSourceRegistry{}.ValidateFromLocation(location)
+		if err != nil {
+			return dogeboxd.ManifestSourceList{}, err
+		}
+		s := &ManifestSourceRegistry{config: config, cache: sourceManager.cache}
+		return s.List(true)
+	case "interfaces":
+		config, err := ManifestSourceInterfaces{}.ValidateFromLocation(location)
+		if err != nil {
+			return dogeboxd.ManifestSourceList{}, err
+		}
+		s := &ManifestSourceInterfaces{config: config}
+		return s.List(true)
+	default:
+		return dogeboxd.ManifestSourceList{}, fmt.Errorf("only git, registry and interfaces sources can be previewed")
+	}
+}
+
+func (sourceManager *sourceManager) AddSource(location string, auth *dogeboxd.ManifestSourceAuth) (dogeboxd.ManifestSource, error) {
 	var c dogeboxd.ManifestSourceConfiguration
 	var s dogeboxd.ManifestSource
 
@@ -257,6 +399,15 @@ func (sourceManager *sourceManager) AddSource(location string) (dogeboxd.Manifes
 		return nil, err
 	}
 
+	if auth != nil {
+		if sourceType != "git" || !strings.HasPrefix(location, "https://") {
+			return nil, fmt.Errorf("credentials are only supported for https git sources")
+		}
+		if err := sourceManager.secrets.Validate(auth.PasswordRef); err != nil {
+			return nil, err
+		}
+	}
+
 	switch sourceType {
 	case "disk":
 		{
@@ -269,12 +420,31 @@ func (sourceManager *sourceManager) AddSource(location string) (dogeboxd.Manifes
 		}
 	case "git":
 		{
-			config, err := ManifestSourceGit{}.ValidateFromLocation(location)
+			git := ManifestSourceGit{config: dogeboxd.ManifestSourceConfiguration{Auth: auth}, secrets: sourceManager.secrets}
+			config, err := git.ValidateFromLocation(location)
 			if err != nil {
 				return nil, err
 			}
 			c = config
-			s = &ManifestSourceGit{config: config}
+			s = &ManifestSourceGit{config: config, secrets: sourceManager.secrets, cache: sourceManager.cache}
+		}
+	case "registry":
+		{
+			config, err := ManifestSourceRegistry{}.ValidateFromLocation(location)
+			if err != nil {
+				return nil, err
+			}
+			c = config
+			s = &ManifestSourceRegistry{config: config, cache: sourceManager.cache}
+		}
+	case "interfaces":
+		{
+			config, err := ManifestSourceInterfaces{}.ValidateFromLocation(location)
+			if err != nil {
+				return nil, err
+			}
+			c = config
+			s = &ManifestSourceInterfaces{config: config}
 		}
 
 	default:
@@ -283,6 +453,10 @@ func (sourceManager *sourceManager) AddSource(location string) (dogeboxd.Manifes
 
 	log.Printf("generated config: %+v", c)
 
+	if err := dogeboxd.ValidateSourceID(c.ID); err != nil {
+		return nil, err
+	}
+
 	// Ensure no existing source has the same id
 	for _, _s := range sourceManager.sources {
 		_c := _s.Config()
@@ -337,6 +511,113 @@ func (sourceManager *sourceManager) RemoveSource(id string) error {
 	return nil
 }
 
+func (sourceManager *sourceManager) SetRefreshInterval(id string, minutes int) error {
+	if err := dogeboxd.ValidateSourceRefreshInterval(minutes); err != nil {
+		return err
+	}
+
+	return sourceManager.updateConfig(id, func(c *dogeboxd.ManifestSourceConfiguration) {
+		c.RefreshIntervalMinutes = minutes
+	})
+}
+
+func (sourceManager *sourceManager) SetPriority(id string, priority int) error {
+	if err := dogeboxd.ValidateSourcePriority(priority); err != nil {
+		return err
+	}
+
+	return sourceManager.updateConfig(id, func(c *dogeboxd.ManifestSourceConfiguration) {
+		c.Priority = priority
+	})
+}
+
+func (sourceManager *sourceManager) SetAutoRebuild(id string, enabled bool) error {
+	source, err := sourceManager.GetSource(id)
+	if err != nil {
+		return err
+	}
+	if source.Config().Type != "disk" {
+		return fmt.Errorf("only disk sources can be rebuilt automatically")
+	}
+
+	return sourceManager.updateConfig(id, func(c *dogeboxd.ManifestSourceConfiguration) {
+		c.AutoRebuild = enabled
+	})
+}
+
+// updateConfig applies update to the configuration of the source with id
+// and saves it.
+func (sourceManager *sourceManager) updateConfig(id string, update func(*dogeboxd.ManifestSourceConfiguration)) error {
+	for i, r := range sourceManager.sources {
+		if r.Config().ID != id {
+			continue
+		}
+		switch s := r.(type) {
+		case ManifestSourceDisk:
+			update(&s.config)
+			sourceManager.sources[i] = s
+		case *ManifestSourceDisk:
+			update(&s.config)
+		case *ManifestSourceGit:
+			update(&s.config)
+		case *ManifestSourceRegistry:
+			update(&s.config)
+		case *ManifestSourceInterfaces:
+			update(&s.config)
+		default:
+			return fmt.Errorf("unknown source type for %s", id)
+		}
+		return sourceManager.Save()
+	}
+
+	return fmt.Errorf("no existing source id: %s", id)
+}
+
+func (sourceManager *sourceManager) PinSource(id string, commit string) error {
+	if err := dogeboxd.ValidateGitCommit(commit); err != nil {
+		return err
+	}
+
+	for i, r := range sourceManager.sources {
+		if r.Config().ID != id {
+			continue
+		}
+		g, ok := r.(*ManifestSourceGit)
+		if !ok {
+			return fmt.Errorf("only git sources can be pinned to a commit")
+		}
+
+		config := g.config
+		config.Commit = commit
+		pinned := &ManifestSourceGit{serverConfig: g.serverConfig, config: config, secrets: g.secrets, cache: g.cache}
+		// List it now, so a commit that isn't there is refused.
+		if _, err := pinned.List(true); err != nil {
+			return err
+		}
+
+		sourceManager.sources[i] = pinned
+		return sourceManager.Save()
+	}
+
+	return fmt.Errorf("no existing source id: %s", id)
+}
+
+func (sourceManager *sourceManager) GetInterfaceDefinitions() []dogeboxd.InterfaceDefinition {
+	defs := []dogeboxd.InterfaceDefinition{}
+	for _, r := range sourceManager.sources {
+		if _, ok := r.(*ManifestSourceInterfaces); !ok {
+			continue
+		}
+		l, err := r.List(false)
+		if err != nil {
+			log.Printf("Warning: interfaces source '%s' failed to load: %v", r.Config().ID, err)
+			continue
+		}
+		defs = append(defs, l.Interfaces...)
+	}
+	return defs
+}
+
 func (sourceManager *sourceManager) Save() error {
 	state := sourceManager.sm.Get().Sources
 	state.SourceConfigs = sourceManager.GetAllSourceConfigurations()
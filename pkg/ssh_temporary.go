@@ -0,0 +1,27 @@
+package dogeboxd
+
+import (
+	"fmt"
+	"time"
+)
+
+/* SSH can be turned on for a while, ie: to give someone support access,
+ * and is turned off again by a DisableSSH job once EnabledUntil passes.
+ * The expiry is kept with the SSH config rather than as a timer, so a
+ * box that was off when it passed is locked again on startup.
+ */
+
+const MAX_TEMPORARY_SSH_HOURS = 72
+
+func ValidateTemporarySSHHours(hours int) error {
+	if hours < 1 || hours > MAX_TEMPORARY_SSH_HOURS {
+		return fmt.Errorf("hours must be between 1 and %d", MAX_TEMPORARY_SSH_HOURS)
+	}
+	return nil
+}
+
+// TemporaryExpired reports whether SSH was enabled temporarily and should
+// have been disabled by now.
+func (c DogeboxStateSSHConfig) TemporaryExpired(now time.Time) bool {
+	return c.Enabled && c.EnabledUntil != nil && !now.Before(*c.EnabledUntil)
+}
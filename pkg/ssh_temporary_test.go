@@ -0,0 +1,67 @@
+package dogeboxd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateTemporarySSHHours(t *testing.T) {
+	assert.NoError(t, ValidateTemporarySSHHours(1))
+	assert.NoError(t, ValidateTemporarySSHHours(MAX_TEMPORARY_SSH_HOURS))
+	assert.Error(t, ValidateTemporarySSHHours(0))
+	assert.Error(t, ValidateTemporarySSHHours(MAX_TEMPORARY_SSH_HOURS+1))
+}
+
+func TestTemporarySSHExpired(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Hour)
+
+	tests := map[string]struct {
+		config  DogeboxStateSSHConfig
+		expired bool
+	}{
+		"permanent":        {config: DogeboxStateSSHConfig{Enabled: true}},
+		"not yet":          {config: DogeboxStateSSHConfig{Enabled: true, EnabledUntil: &future}},
+		"expired":          {config: DogeboxStateSSHConfig{Enabled: true, EnabledUntil: &past}, expired: true},
+		"already disabled": {config: DogeboxStateSSHConfig{EnabledUntil: &past}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.expired, tt.config.TemporaryExpired(now))
+		})
+	}
+}
+
+type sshStateManager struct {
+	StateManager
+	dbx DogeboxState
+}
+
+func (m sshStateManager) Get() State { return State{Dogebox: m.dbx} }
+
+func TestDisableExpiredSSHQueuesDisableOnce(t *testing.T) {
+	jm, err := setupTestJobManager()
+	require.NoError(t, err)
+	dbx, _ := newQueueTestDogeboxd(t, jm, nil)
+	dbx.jobs = make(chan Job, 2)
+
+	until := time.Now().Add(time.Hour)
+	dbx.sm = sshStateManager{dbx: DogeboxState{SSH: DogeboxStateSSHConfig{Enabled: true, EnabledUntil: &until}}}
+
+	dbx.disableExpiredSSH(time.Now())
+	assert.Empty(t, dbx.jobs)
+
+	dbx.disableExpiredSSH(until)
+	require.Len(t, dbx.jobs, 1)
+	j := <-dbx.jobs
+	assert.Equal(t, DisableSSH{}, j.A)
+
+	// Not again while the disable is waiting in the queue.
+	dbx.enqueue(queueTestJob(t, dbx, j.ID, j.A, nil))
+	dbx.disableExpiredSSH(until)
+	assert.Empty(t, dbx.jobs)
+}
@@ -0,0 +1,249 @@
+package dogeboxd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// How many automatic state snapshots are kept before the oldest is
+// discarded.
+const StateSnapshotsKept = 5
+
+const stateSnapshotMetaFile = "snapshot.json"
+
+/* A StateSnapshot is a lightweight, state-only copy of the dogebox taken
+ * automatically before a risky job, so there's something to go back to
+ * even if the user never set up backups. Each is a directory under
+ * <dataDir>/state-snapshots holding:
+ *
+ *	dogebox.db   a consistent copy of the database
+ *	pups/        every pup's pup_<id>.gob state file
+ *	nix/         the nix config directory
+ *
+ * Pup storage isn't included, these are for undoing config and state
+ * changes, not data loss.
+ */
+type StateSnapshot struct {
+	ID string `json:"id"`
+	// The action the snapshot was taken before, and its job.
+	Reason  string    `json:"reason"`
+	JobID   string    `json:"jobId,omitempty"`
+	Created time.Time `json:"created"`
+	Size    int64     `json:"size"`
+}
+
+// TakesStateSnapshot reports whether a StateSnapshot should be taken before
+// running an action.
+func TakesStateSnapshot(a Action) bool {
+	switch a.(type) {
+	case UpgradePup, BulkPupAction, SaveCustomNix, SystemUpdate, SystemUpdateFromFile:
+		return true
+	}
+	return false
+}
+
+type StateSnapshotter struct {
+	store   *StoreManager
+	dataDir string
+	nixDir  string
+	keep    int
+	now     func() time.Time
+}
+
+func NewStateSnapshotter(store *StoreManager, config ServerConfig) *StateSnapshotter {
+	return &StateSnapshotter{
+		store:   store,
+		dataDir: config.DataDir,
+		nixDir:  config.NixDir,
+		keep:    StateSnapshotsKept,
+		now:     time.Now,
+	}
+}
+
+func (s *StateSnapshotter) dir() string {
+	return filepath.Join(s.dataDir, "state-snapshots")
+}
+
+// Create takes a snapshot, then prunes all but the newest few.
+func (s *StateSnapshotter) Create(reason string, jobID string, log SubLogger) (StateSnapshot, error) {
+	created := s.now()
+	snapshot := StateSnapshot{
+		ID:      fmt.Sprintf("%d-%s", created.UnixNano(), reason),
+		Reason:  reason,
+		JobID:   jobID,
+		Created: created,
+	}
+
+	if err := os.MkdirAll(s.dir(), 0750); err != nil {
+		return StateSnapshot{}, fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	// Build it somewhere else first, so a half-written snapshot is never
+	// mistaken for a good one.
+	tmp, err := os.MkdirTemp(s.dir(), ".tmp-")
+	if err != nil {
+		return StateSnapshot{}, fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	log.Logf("Snapshotting database")
+	if err := s.copyDatabase(filepath.Join(tmp, "dogebox.db")); err != nil {
+		return StateSnapshot{}, fmt.Errorf("failed to snapshot database: %w", err)
+	}
+
+	log.Logf("Snapshotting pup state")
+	if err := copyFiles(filepath.Join(s.dataDir, "pups"), filepath.Join(tmp, "pups"), func(rel string) bool {
+		return !strings.Contains(rel, string(os.PathSeparator)) && strings.HasSuffix(rel, ".gob")
+	}); err != nil {
+		return StateSnapshot{}, fmt.Errorf("failed to snapshot pup state: %w", err)
+	}
+
+	log.Logf("Snapshotting nix config")
+	if err := copyFiles(s.nixDir, filepath.Join(tmp, "nix"), func(string) bool { return true }); err != nil {
+		return StateSnapshot{}, fmt.Errorf("failed to snapshot nix config: %w", err)
+	}
+
+	if snapshot.Size, err = dirSize(tmp); err != nil {
+		return StateSnapshot{}, err
+	}
+	meta, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return StateSnapshot{}, err
+	}
+	if err := os.WriteFile(filepath.Join(tmp, stateSnapshotMetaFile), meta, 0640); err != nil {
+		return StateSnapshot{}, err
+	}
+
+	if err := os.Rename(tmp, filepath.Join(s.dir(), snapshot.ID)); err != nil {
+		return StateSnapshot{}, fmt.Errorf("failed to save snapshot: %w", err)
+	}
+
+	if err := s.prune(); err != nil {
+		log.Errf("Failed to prune old state snapshots: %v", err)
+	}
+
+	return snapshot, nil
+}
+
+// copyDatabase writes a consistent copy of the database to dest, without
+// blocking readers.
+func (s *StateSnapshotter) copyDatabase(dest string) error {
+	s.store.WriteMu.Lock()
+	defer s.store.WriteMu.Unlock()
+
+	_, err := s.store.DB.Exec("VACUUM INTO ?", dest)
+	return err
+}
+
+// List returns the snapshots we have, newest first.
+func (s *StateSnapshotter) List() ([]StateSnapshot, error) {
+	entries, err := os.ReadDir(s.dir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []StateSnapshot{}, nil
+		}
+		return nil, err
+	}
+
+	snapshots := []StateSnapshot{}
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.dir(), entry.Name(), stateSnapshotMetaFile))
+		if err != nil {
+			continue
+		}
+		var snapshot StateSnapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil || snapshot.ID != entry.Name() {
+			continue
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Created.After(snapshots[j].Created)
+	})
+	return snapshots, nil
+}
+
+func (s *StateSnapshotter) prune() error {
+	snapshots, err := s.List()
+	if err != nil {
+		return err
+	}
+	for i := s.keep; i < len(snapshots); i++ {
+		if err := os.RemoveAll(filepath.Join(s.dir(), snapshots[i].ID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyFiles copies the regular files under src that include returns true
+// for (given their path relative to src) into dest. A missing src copies
+// nothing.
+func copyFiles(src string, dest string, include func(rel string) bool) error {
+	if err := os.MkdirAll(dest, 0750); err != nil {
+		return err
+	}
+
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == src {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		if !info.Mode().IsRegular() || !include(rel) {
+			return nil
+		}
+		return copyFile(path, filepath.Join(dest, rel), info.Mode().Perm())
+	})
+}
+
+func copyFile(src string, dest string, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0750); err != nil {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func dirSize(dir string) (int64, error) {
+	var total int64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			total += info.Size()
+		}
+		return nil
+	})
+	return total, err
+}
@@ -0,0 +1,92 @@
+package dogeboxd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStateSnapshotter(t *testing.T) (*StateSnapshotter, ServerConfig) {
+	config := ServerConfig{DataDir: t.TempDir(), NixDir: t.TempDir()}
+
+	sm, err := NewStoreManager(filepath.Join(config.DataDir, "dogebox.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { sm.CloseDB() })
+	require.NoError(t, GetTypeStore[Webhook](sm).Set("hook", Webhook{ID: "hook", Name: "test"}))
+
+	require.NoError(t, os.MkdirAll(filepath.Join(config.DataDir, "pups", "abc"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(config.DataDir, "pups", "pup_abc.gob"), []byte("state"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(config.DataDir, "pups", "abc", "big.bin"), []byte("source"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(config.NixDir, "nested"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(config.NixDir, "nested", "pup_abc.nix"), []byte("nix"), 0644))
+
+	return NewStateSnapshotter(sm, config), config
+}
+
+func TestTakesStateSnapshot(t *testing.T) {
+	assert.True(t, TakesStateSnapshot(UpgradePup{}))
+	assert.True(t, TakesStateSnapshot(SaveCustomNix{}))
+	assert.True(t, TakesStateSnapshot(SystemUpdate{}))
+	assert.False(t, TakesStateSnapshot(InstallPup{}))
+}
+
+func TestStateSnapshotCopiesState(t *testing.T) {
+	s, config := newTestStateSnapshotter(t)
+
+	snapshot, err := s.Create("upgrade", "job-1", NewConsoleSubLogger("", "snapshot"))
+	require.NoError(t, err)
+	assert.Equal(t, "upgrade", snapshot.Reason)
+	assert.Equal(t, "job-1", snapshot.JobID)
+	assert.Positive(t, snapshot.Size)
+
+	dir := filepath.Join(config.DataDir, "state-snapshots", snapshot.ID)
+
+	gob, err := os.ReadFile(filepath.Join(dir, "pups", "pup_abc.gob"))
+	require.NoError(t, err)
+	assert.Equal(t, "state", string(gob))
+	_, err = os.Stat(filepath.Join(dir, "pups", "abc"))
+	assert.True(t, os.IsNotExist(err), "only pup state files should be copied")
+
+	nix, err := os.ReadFile(filepath.Join(dir, "nix", "nested", "pup_abc.nix"))
+	require.NoError(t, err)
+	assert.Equal(t, "nix", string(nix))
+
+	db, err := NewStoreManager(filepath.Join(dir, "dogebox.db"))
+	require.NoError(t, err)
+	defer db.CloseDB()
+	hook, err := GetTypeStore[Webhook](db).Get("hook")
+	require.NoError(t, err)
+	assert.Equal(t, "test", hook.Name)
+
+	snapshots, err := s.List()
+	require.NoError(t, err)
+	require.Len(t, snapshots, 1)
+	assert.Equal(t, snapshot.ID, snapshots[0].ID)
+}
+
+func TestStateSnapshotsKeepTheNewest(t *testing.T) {
+	s, _ := newTestStateSnapshotter(t)
+	s.keep = 2
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.now = func() time.Time {
+		now = now.Add(time.Minute)
+		return now
+	}
+
+	ids := []string{}
+	for i := 0; i < 4; i++ {
+		snapshot, err := s.Create("system-update", "", NewConsoleSubLogger("", "snapshot"))
+		require.NoError(t, err)
+		ids = append(ids, snapshot.ID)
+	}
+
+	snapshots, err := s.List()
+	require.NoError(t, err)
+	require.Len(t, snapshots, 2)
+	assert.Equal(t, ids[3], snapshots[0].ID)
+	assert.Equal(t, ids[2], snapshots[1].ID)
+}
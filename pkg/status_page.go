@@ -0,0 +1,154 @@
+package dogeboxd
+
+import (
+	"fmt"
+	"time"
+)
+
+/* The public status page is a read-only, unauthenticated view of the
+ * Dogebox, ie: to show off a node's sync height, without exposing
+ * dpanel. It only ever shows what the owner has picked here.
+ */
+
+const (
+	MAX_STATUS_PAGE_TITLE_LENGTH = 64
+	MAX_STATUS_PAGE_PUPS         = 10
+	MAX_STATUS_PAGE_PUP_METRICS  = 5
+)
+
+type PublicStatusPage struct {
+	Enabled     bool              `json:"enabled"`
+	Title       string            `json:"title"`
+	ShowVersion bool              `json:"showVersion"`
+	ShowUptime  bool              `json:"showUptime"`
+	Pups        []PublicStatusPup `json:"pups"`
+}
+
+type PublicStatusPup struct {
+	PupID string `json:"pupId"`
+	// Whether the pup is running.
+	ShowStatus bool `json:"showStatus"`
+	// Names of the manifest metrics to show, ie: the chain height.
+	Metrics []string `json:"metrics"`
+}
+
+func (p PublicStatusPage) Validate(pups map[string]PupState) error {
+	if len(p.Title) > MAX_STATUS_PAGE_TITLE_LENGTH {
+		return fmt.Errorf("status page title must be at most %d characters", MAX_STATUS_PAGE_TITLE_LENGTH)
+	}
+	if len(p.Pups) > MAX_STATUS_PAGE_PUPS {
+		return fmt.Errorf("at most %d pups can be shown on the status page", MAX_STATUS_PAGE_PUPS)
+	}
+
+	seen := map[string]bool{}
+	for _, sp := range p.Pups {
+		pup, ok := pups[sp.PupID]
+		if !ok {
+			return fmt.Errorf("pup %s not found", sp.PupID)
+		}
+		if seen[sp.PupID] {
+			return fmt.Errorf("pup %s is on the status page twice", sp.PupID)
+		}
+		seen[sp.PupID] = true
+
+		if len(sp.Metrics) > MAX_STATUS_PAGE_PUP_METRICS {
+			return fmt.Errorf("at most %d metrics can be shown for %s", MAX_STATUS_PAGE_PUP_METRICS, pup.DisplayName())
+		}
+		for _, name := range sp.Metrics {
+			if _, ok := manifestMetric(pup.Manifest, name); !ok {
+				return fmt.Errorf("%s has no metric %s", pup.DisplayName(), name)
+			}
+		}
+	}
+	return nil
+}
+
+func manifestMetric(m PupManifest, name string) (PupManifestMetric, bool) {
+	for _, metric := range m.Metrics {
+		if metric.Name == name {
+			return metric, true
+		}
+	}
+	return PupManifestMetric{}, false
+}
+
+type PublicStatus struct {
+	Title   string `json:"title"`
+	Version string `json:"version,omitempty"`
+	// Whole hours only, to keep things coarse.
+	UptimeHours *int64                `json:"uptimeHours,omitempty"`
+	Pups        []PublicStatusPupInfo `json:"pups"`
+	Updated     time.Time             `json:"updated"`
+}
+
+type PublicStatusPupInfo struct {
+	Name    string               `json:"name"`
+	Status  string               `json:"status,omitempty"`
+	Metrics []PublicStatusMetric `json:"metrics,omitempty"`
+}
+
+type PublicStatusMetric struct {
+	Label string `json:"label"`
+	Value any    `json:"value"`
+}
+
+// BuildPublicStatus gathers what the owner has chosen to show, in the
+// order they chose. Pups that have since been uninstalled are left out.
+func BuildPublicStatus(page PublicStatusPage, pups map[string]PupState, stats map[string]PupStats, version string, uptime time.Duration, now time.Time) PublicStatus {
+	status := PublicStatus{
+		Title:   page.Title,
+		Pups:    []PublicStatusPupInfo{},
+		Updated: now.Truncate(time.Minute),
+	}
+	if status.Title == "" {
+		status.Title = "Dogebox"
+	}
+	if page.ShowVersion {
+		status.Version = version
+	}
+	if page.ShowUptime {
+		hours := int64(uptime / time.Hour)
+		status.UptimeHours = &hours
+	}
+
+	for _, sp := range page.Pups {
+		pup, ok := pups[sp.PupID]
+		if !ok {
+			continue
+		}
+		info := PublicStatusPupInfo{Name: pup.DisplayName()}
+		pupStats := stats[sp.PupID]
+		if sp.ShowStatus {
+			info.Status = pupStats.Status
+		}
+		for _, name := range sp.Metrics {
+			metric, ok := manifestMetric(pup.Manifest, name)
+			if !ok {
+				continue
+			}
+			label := metric.Label
+			if label == "" {
+				label = metric.Name
+			}
+			info.Metrics = append(info.Metrics, PublicStatusMetric{Label: label, Value: latestMetricValue(pupStats, name)})
+		}
+		status.Pups = append(status.Pups, info)
+	}
+
+	return status
+}
+
+// The newest value the pup has reported for a metric, nil if none.
+func latestMetricValue(stats PupStats, name string) any {
+	for _, m := range stats.Metrics {
+		if m.Name != name || m.Values == nil {
+			continue
+		}
+		values := m.Values.GetValues()
+		if len(values) == 0 {
+			return nil
+		}
+		return values[len(values)-1]
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package dogeboxd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func statusPageTestPups() map[string]PupState {
+	return map[string]PupState{
+		"core": {
+			ID: "core",
+			Manifest: PupManifest{
+				Meta: PupManifestMeta{Name: "Dogecoin Core"},
+				Metrics: []PupManifestMetric{
+					{Name: "chain_height", Label: "Chain height", Type: "int"},
+					{Name: "peers", Type: "int"},
+				},
+			},
+		},
+	}
+}
+
+func TestPublicStatusPageValidate(t *testing.T) {
+	pups := statusPageTestPups()
+
+	tests := map[string]struct {
+		page    PublicStatusPage
+		wantErr string
+	}{
+		"empty":    {page: PublicStatusPage{}},
+		"ok":       {page: PublicStatusPage{Enabled: true, Pups: []PublicStatusPup{{PupID: "core", ShowStatus: true, Metrics: []string{"chain_height"}}}}},
+		"title":    {page: PublicStatusPage{Title: string(make([]byte, MAX_STATUS_PAGE_TITLE_LENGTH+1))}, wantErr: "title"},
+		"no pup":   {page: PublicStatusPage{Pups: []PublicStatusPup{{PupID: "gone"}}}, wantErr: "pup gone not found"},
+		"twice":    {page: PublicStatusPage{Pups: []PublicStatusPup{{PupID: "core"}, {PupID: "core"}}}, wantErr: "twice"},
+		"metric":   {page: PublicStatusPage{Pups: []PublicStatusPup{{PupID: "core", Metrics: []string{"wallet_balance"}}}}, wantErr: "no metric wallet_balance"},
+		"too many": {page: PublicStatusPage{Pups: []PublicStatusPup{{PupID: "core", Metrics: []string{"peers", "peers", "peers", "peers", "peers", "peers"}}}}, wantErr: "at most"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := tc.page.Validate(pups)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.wantErr)
+		})
+	}
+}
+
+func TestBuildPublicStatus(t *testing.T) {
+	pups := statusPageTestPups()
+	height := NewBuffer[any](3)
+	height.Add(100)
+	height.Add(101)
+	stats := map[string]PupStats{
+		"core": {
+			Status:  STATE_RUNNING,
+			Metrics: []PupMetrics[any]{{Name: "chain_height", Values: height}},
+		},
+	}
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	page := PublicStatusPage{
+		Enabled:     true,
+		ShowVersion: true,
+		ShowUptime:  true,
+		Pups: []PublicStatusPup{
+			{PupID: "gone", ShowStatus: true},
+			{PupID: "core", ShowStatus: true, Metrics: []string{"chain_height", "peers"}},
+		},
+	}
+	status := BuildPublicStatus(page, pups, stats, "v0.5.0", 50*time.Hour+20*time.Minute, now)
+
+	assert.Equal(t, "Dogebox", status.Title)
+	assert.Equal(t, "v0.5.0", status.Version)
+	require.NotNil(t, status.UptimeHours)
+	assert.Equal(t, int64(50), *status.UptimeHours)
+	assert.Equal(t, time.Date(2026, 1, 2, 3, 4, 0, 0, time.UTC), status.Updated)
+	require.Len(t, status.Pups, 1)
+	assert.Equal(t, PublicStatusPupInfo{
+		Name:   "Dogecoin Core",
+		Status: STATE_RUNNING,
+		Metrics: []PublicStatusMetric{
+			{Label: "Chain height", Value: 101},
+			{Label: "peers", Value: nil},
+		},
+	}, status.Pups[0])
+
+	// Nothing the owner hasn't picked.
+	status = BuildPublicStatus(PublicStatusPage{Enabled: true, Title: "My node", Pups: []PublicStatusPup{{PupID: "core"}}}, pups, stats, "v0.5.0", time.Hour, now)
+	assert.Equal(t, "My node", status.Title)
+	assert.Empty(t, status.Version)
+	assert.Nil(t, status.UptimeHours)
+	assert.Equal(t, []PublicStatusPupInfo{{Name: "Dogecoin Core"}}, status.Pups)
+}
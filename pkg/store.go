@@ -121,6 +121,40 @@ func (ts *TypeStore[T]) Del(key string) error {
 	return err
 }
 
+// Replace makes values the whole table in one transaction, so a failure
+// part way leaves the table as it was.
+func (ts *TypeStore[T]) Replace(values map[string]T) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	tx, err := ts.sm.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	keys := make([]interface{}, 0, len(values))
+	for key, value := range values {
+		valueBytes, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(fmt.Sprintf("INSERT OR REPLACE INTO %s (key, value) VALUES (?, ?)", ts.Table), key, valueBytes); err != nil {
+			return err
+		}
+		keys = append(keys, key)
+	}
+
+	del := fmt.Sprintf("DELETE FROM %s", ts.Table)
+	if len(keys) > 0 {
+		del += fmt.Sprintf(" WHERE key NOT IN (?%s)", strings.Repeat(", ?", len(keys)-1))
+	}
+	if _, err := tx.Exec(del, keys...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
 // This should not be used to update/insert, it doesn't lock
 func (ts *TypeStore[T]) Exec(query string, args ...interface{}) ([]T, error) {
 	rows, err := ts.sm.DB.Query(query, args...)
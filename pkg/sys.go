@@ -4,6 +4,8 @@ import (
 	"context"
 	"net"
 	"time"
+
+	"github.com/Dogebox-WG/dogeboxd/pkg/version"
 )
 
 // see ./system/ for implementations
@@ -18,10 +20,12 @@ type SystemUpdater interface {
 	// have a way to wait for a SystemUpdater event to finish.
 	AddSSHKey(key string, l SubLogger) error
 	EnableSSH(l SubLogger) error
+	EnableSSHTemporary(hours int, l SubLogger) error
 	ListSSHKeys() ([]DogeboxStateSSHKey, error)
 	AddBinaryCache(j AddBinaryCache, l SubLogger) error
 	UpdateSystemConfig(dbxState DogeboxState, log SubLogger) error
 	ValidateNix(content string) error
+	ReloadPupConfig(state PupState, l SubLogger) error
 
 	// Snapshot management for pup rollbacks
 	HasSnapshot(pupID string) bool
@@ -58,6 +62,7 @@ type LogTailer interface {
 	GetChannelFromOffset(string, int64) (context.CancelFunc, chan string, error)
 	GetTail(string, int) ([]string, int64, error)
 	GetPage(string, *int64, int) (LogPage, error)
+	Query(string, LogQuery) (LogQueryPage, error)
 }
 
 // SystemMonitor issues these for monitored PUPs
@@ -71,6 +76,9 @@ type ProcStatus struct {
 	// like activating/deactivating even when MainPID is not yet (or no longer) present.
 	ActiveState string `json:"activeState,omitempty"`
 	SubState    string `json:"subState,omitempty"`
+
+	// When the service last entered the active state, zero if unknown.
+	ActiveSince time.Time `json:"activeSince,omitempty"`
 }
 
 type DogeboxStateInitialSetup struct {
@@ -78,11 +86,15 @@ type DogeboxStateInitialSetup struct {
 	HasGeneratedKey    bool   `json:"hasGeneratedKey"`
 	HasSetNetwork      bool   `json:"hasSetNetwork"`
 	HasFullyConfigured bool   `json:"hasFullyConfigured"`
+	// The pup collection picked during setup, installed on the first
+	// sign in after bootstrapping and then cleared.
+	PendingPupCollection string `json:"pendingPupCollection,omitempty"`
 }
 
 type DogeboxFlags struct {
 	IsFirstTimeWelcomeComplete bool `json:"isFirstTimeWelcomeComplete"`
 	IsDeveloperMode            bool `json:"isDeveloperMode"`
+	IsSafeMode                 bool `json:"isSafeMode"`
 }
 
 type DogeboxStateSSHKey struct {
@@ -94,6 +106,8 @@ type DogeboxStateSSHKey struct {
 type DogeboxStateSSHConfig struct {
 	Enabled bool                 `json:"enabled"`
 	Keys    []DogeboxStateSSHKey `json:"keys"`
+	// Set when SSH was enabled temporarily, see EnableSSHTemporary.
+	EnabledUntil *time.Time `json:"enabledUntil,omitempty"`
 }
 
 type DogeboxStateBinaryCache struct {
@@ -109,9 +123,58 @@ type DogeboxState struct {
 	Timezone      string
 	SSH           DogeboxStateSSHConfig
 	StorageDevice string
-	Flags         DogeboxFlags
-	BinaryCaches  []DogeboxStateBinaryCache
-	SidebarPups   []string `json:"sidebarPups"` // Pup IDs pinned to dpanel sidebar
+	// A second disk to mirror StorageDevice onto, see InitStorageDevice.
+	StorageMirrorDevice string
+	Flags               DogeboxFlags
+	BinaryCaches        []DogeboxStateBinaryCache
+	SidebarPups         []string `json:"sidebarPups"` // Pup IDs pinned to dpanel sidebar
+	SafeMode            DogeboxStateSafeMode
+	APMode              DogeboxStateAPMode
+	// ISO 3166-1 alpha-2 country code used as the wifi regulatory domain.
+	WifiRegulatoryDomain string
+	// How long archived job logs are kept, see PruneJobLogArchive.
+	JobLogRetention JobLogRetention
+	// When pup logs are rotated and pruned, unless the pup sets its
+	// own, see PupLogRotator.
+	PupLogRetention PupLogRetention
+	// When set, pup enable/disable and custom nix edits are written
+	// without rebuilding, and collected in PendingChanges.
+	DeferRebuilds  bool
+	PendingChanges []PendingChange
+	// How times, dates and sizes are written, see DisplayFormat.
+	DisplayPreferences DisplayPreferences
+	// CA certificates pups can opt in to trusting.
+	TrustedCAs []TrustedCA
+	// Passkeys for signing in to dpanel, see Passkey.
+	Auth DogeboxStateAuth
+	// What, if anything, is shown on the public status page.
+	StatusPage PublicStatusPage
+	// Production or development, unset on boxes that haven't picked
+	// one, see BoxProfile.
+	Profile BoxProfile
+	// Units, besides the built in ones, whose journals can be streamed,
+	// see JournalUnit.
+	JournalUnits []JournalUnit
+	// How old system generations are garbage collected, and what the
+	// last collection did.
+	NixGC NixGCSettings
+}
+
+// While safe mode is enabled, no pup containers are included in the nix config.
+type DogeboxStateSafeMode struct {
+	Reason                     string
+	ConsecutiveRebuildFailures int
+}
+
+// While AP mode is enabled the box broadcasts its own wifi network, so the
+// user can connect to it and reconfigure networking without a monitor.
+type DogeboxStateAPMode struct {
+	Enabled bool
+	Reason  string
+	SSID    string
+	// The WPA2 passphrase for the access point, generated the first time
+	// it's enabled and kept when it's disabled so it doesn't change.
+	Passphrase string
 }
 
 type NetworkState struct {
@@ -135,6 +198,10 @@ type StateManager interface {
 	OpenDB() error
 	SetNetwork(s NetworkState) error
 	SetDogebox(s DogeboxState) error
+	// UpdateDogebox changes the DogeboxState in place, saving it unless
+	// update returns false. Use it rather than Get then SetDogebox from
+	// anywhere but a job, so a concurrent change isn't lost.
+	UpdateDogebox(update func(s *DogeboxState) bool) error
 	SetSources(s SourceState) error
 }
 
@@ -149,6 +216,11 @@ type NetworkManager interface {
 	TryConnect(nixPatch NixPatch) error
 	TestConnect() error
 	GetLocalIP() (net.IP, error)
+	HasConnectivity() bool
+	// Adds the recovery access point described by dbxState.APMode to
+	// nixPatch, returning the SSID it will broadcast.
+	EnableAPMode(nixPatch NixPatch, dbxState DogeboxState) (string, error)
+	DisableAPMode(nixPatch NixPatch)
 }
 
 type NetworkConnection interface {
@@ -184,16 +256,20 @@ type SelectedNetwork interface {
 
 type SelectedNetworkEthernet struct {
 	SelectedNetwork
-	Interface string `json:"interface"`
+	Interface string           `json:"interface"`
+	Static    *NetworkStaticIP `json:"static,omitempty"`
+	Proxy     *NetworkProxy    `json:"proxy,omitempty"`
 }
 
 type SelectedNetworkWifi struct {
 	SelectedNetwork
-	Interface  string `json:"interface"`
-	Ssid       string `json:"ssid"`
-	Password   string `json:"password"`
-	Encryption string `json:"encryption"`
-	IsHidden   bool   `json:"isHidden"`
+	Interface  string           `json:"interface"`
+	Ssid       string           `json:"ssid"`
+	Password   string           `json:"password"`
+	Encryption string           `json:"encryption"`
+	IsHidden   bool             `json:"isHidden"`
+	Static     *NetworkStaticIP `json:"static,omitempty"`
+	Proxy      *NetworkProxy    `json:"proxy,omitempty"`
 }
 
 func (sn SelectedNetworkEthernet) selectedNetworkMarker() {}
@@ -216,6 +292,9 @@ type SourceDetails struct {
 	Name        string             `json:"name"`
 	Description string             `json:"description"`
 	Pups        []SourceDetailsPup `json:"pups"`
+	// Other git locations with the same repository, see
+	// ManifestSourceConfiguration.Mirrors.
+	Mirrors []string `json:"mirrors,omitempty"`
 }
 
 type SourceManager interface {
@@ -223,10 +302,51 @@ type SourceManager interface {
 	GetSourceManifest(sourceId, pupName, pupVersion string) (PupManifest, ManifestSource, error)
 	GetSourcePup(sourceId, pupName, pupVersion string) (ManifestSourcePup, error)
 	GetSource(name string) (ManifestSource, error)
-	AddSource(location string) (ManifestSource, error)
+	// AddSource adds a source, auth is only needed for private git sources.
+	AddSource(location string, auth *ManifestSourceAuth) (ManifestSource, error)
+	// PreviewSource lists a git, registry or interfaces source without adding it.
+	PreviewSource(location string) (ManifestSourceList, error)
 	RemoveSource(id string) error
 	DownloadPup(diskPath, sourceId, pupName, pupVersion string) (PupManifest, error)
+	// GetSourceManifestAt and DownloadPupAt are GetSourceManifest and
+	// DownloadPup for a git source pinned to commit, "" for the source as
+	// it's configured. DownloadPupAt notes which of the source's
+	// locations, see Mirrors, served the pup in log if it's given.
+	GetSourceManifestAt(sourceId, commit, pupName, pupVersion string) (PupManifest, ManifestSource, error)
+	DownloadPupAt(diskPath, sourceId, commit, pupName, pupVersion string, log SubLogger) (PupManifest, error)
+	// PinSource pins a git source to commit, or unpins it given "".
+	PinSource(id string, commit string) error
+	// GetAllSourceConfigurations returns every source, in priority order.
 	GetAllSourceConfigurations() []ManifestSourceConfiguration
+	// SetRefreshInterval sets how often a source is refreshed, see
+	// ValidateSourceRefreshInterval.
+	SetRefreshInterval(id string, minutes int) error
+	// GetInterfaceDefinitions returns what every interfaces source defines.
+	GetInterfaceDefinitions() []InterfaceDefinition
+	// SetPriority sets which source wins when more than one provides a
+	// pup by the same name, see ValidateSourcePriority.
+	SetPriority(id string, priority int) error
+	// FindSourcePup finds a version of a pup named by ref, see
+	// SourcePupRef, returning the source it was found in.
+	FindSourcePup(ref, pupVersion string) (ManifestSourcePup, ManifestSourceConfiguration, error)
+	// GetCacheStats describes the on-disk cache of source listings.
+	GetCacheStats() SourceCacheStats
+	// SetAutoRebuild sets whether a disk source's dev mode pups are
+	// rebuilt when its files change.
+	SetAutoRebuild(id string, enabled bool) error
+}
+
+// SourceCacheStats describes the on-disk cache of what's been read from
+// git and registry sources, for debugging.
+type SourceCacheStats struct {
+	Dir string `json:"dir"`
+	// How many sources have a listing cached, and its size on disk.
+	Sources   int   `json:"sources"`
+	SizeBytes int64 `json:"sizeBytes"`
+	// Since dogeboxd started, how many git tags and registry indexes
+	// were read from the cache, and how many had to be fetched.
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
 }
 
 type ManifestSourcePup struct {
@@ -244,7 +364,9 @@ type ManifestSourceList struct {
 	Config      ManifestSourceConfiguration
 	LastChecked time.Time
 	Pups        []ManifestSourcePup
-	Error       string `json:"error,omitempty"`
+	// Only from interfaces sources, see InterfaceDefinition.
+	Interfaces []InterfaceDefinition `json:"interfaces,omitempty"`
+	Error      string                `json:"error,omitempty"`
 }
 
 type ManifestSource interface {
@@ -260,6 +382,30 @@ type ManifestSourceConfiguration struct {
 	Description string `json:"description"`
 	Location    string `json:"location"`
 	Type        string `json:"type"`
+	// Credentials for a private git source, if it needs them.
+	Auth *ManifestSourceAuth `json:"auth,omitempty"`
+	// How often the SourceRefresher relists the source, 0 for the default.
+	RefreshIntervalMinutes int `json:"refreshIntervalMinutes,omitempty"`
+	// The git commit the source is pinned to, see ValidateGitCommit. In a
+	// PupState's Source, the commit the pup was installed from.
+	Commit string `json:"commit,omitempty"`
+	// Other locations serving the same pups, as declared by the source,
+	// tried in order when a download from Location fails.
+	Mirrors []string `json:"mirrors,omitempty"`
+	// Sources with a lower priority win when more than one provides a pup
+	// by the same name, see SortSourceConfigurations.
+	Priority int `json:"priority,omitempty"`
+	// For disk sources, rebuild installed dev mode pups when their files
+	// change, see SourceWatcher.
+	AutoRebuild bool `json:"autoRebuild,omitempty"`
+}
+
+// ManifestSourceAuth is how we log in to a private https git source. Only
+// a reference to the password or token is kept, it's fetched from the
+// secret store each time the source is used, see SecretProvider.
+type ManifestSourceAuth struct {
+	Username    string `json:"username,omitempty"`
+	PasswordRef string `json:"passwordRef"`
 }
 
 type EnvEntry struct {
@@ -272,6 +418,9 @@ type NixPupContainerServiceValues struct {
 	EXEC string
 	CWD  string
 	ENV  []EnvEntry
+	// Whether the service sends READY=1, see PupManifestServiceReadiness.
+	NOTIFY        bool
+	READY_TIMEOUT int
 }
 
 type NixPupContainerTemplateValues struct {
@@ -279,6 +428,10 @@ type NixPupContainerTemplateValues struct {
 	CONTAINER_LOG_DIR string
 	PUP_ID            string
 	PUP_ENABLED       bool
+	PUP_AUTO_START    bool
+	RESTART_SCHEDULE  string
+	CPU_QUOTA         string
+	MEMORY_MAX        string
 	INTERNAL_IP       string
 	PUP_PORTS         []struct {
 		PORT   int
@@ -293,6 +446,39 @@ type NixPupContainerTemplateValues struct {
 
 	IS_DEV_MODE       bool
 	DEV_MODE_SERVICES []string
+
+	MIGRATIONS []NixPupContainerMigrationValues
+	CLOSURES   []NixPupContainerClosureValues
+	// PEM encoded certificates added to the container's trust store.
+	TRUSTED_CAS []string
+	// How the pup's services are stopped, see PupManifestStop. A zero
+	// STOP_TIMEOUT leaves systemd's default.
+	STOP_SIGNAL            string
+	STOP_TIMEOUT           int
+	CONTAINER_STOP_TIMEOUT string
+	// Empty unless the pup has notify services, see PupManifestServiceReadiness.
+	CONTAINER_START_TIMEOUT string
+	// Host devices the user approved passing through, see PupManifestDevice.
+	DEVICES []NixPupContainerDeviceValues
+}
+
+type NixPupContainerDeviceValues struct {
+	PATH      string
+	NODE      string
+	MODIFIER  string
+	READ_ONLY bool
+}
+
+type NixPupContainerClosureValues struct {
+	SERVICE    string
+	STORE_PATH string
+	FROM_STORE string
+}
+
+type NixPupContainerMigrationValues struct {
+	ID      string
+	SERVICE string
+	EXEC    string
 }
 
 type NixSystemContainerConfigTemplatePupRequiresInternet struct {
@@ -333,19 +519,26 @@ type NixFirewallTemplateValues struct {
 }
 
 type NixSystemTemplateValues struct {
-	SYSTEM_HOSTNAME   string
-	KEYMAP            string
-	TIMEZONE          string
-	SSH_ENABLED       bool
-	SSH_KEYS          []DogeboxStateSSHKey
-	BINARY_CACHE_SUBS []string
-	BINARY_CACHE_KEYS []string
+	SYSTEM_HOSTNAME        string
+	KEYMAP                 string
+	TIMEZONE               string
+	SSH_ENABLED            bool
+	SSH_KEYS               []DogeboxStateSSHKey
+	BINARY_CACHE_SUBS      []string
+	BINARY_CACHE_KEYS      []string
+	WIFI_REGULATORY_DOMAIN string
+	// Set by NixManager.UpdateSystem, rootd isn't set up without a socket.
+	ROOTD_SOCKET string
+	DATA_DIR     string
+	DBX_UID      int
+	DBX_GID      int
 }
 
 type NixIncludesFileTemplateValues struct {
-	NIX_DIR  string
-	DATA_DIR string
-	PUP_IDS  []string
+	NIX_DIR   string
+	DATA_DIR  string
+	PUP_IDS   []string
+	SAFE_MODE bool
 }
 
 type NixNetworkTemplateValues struct {
@@ -354,17 +547,64 @@ type NixNetworkTemplateValues struct {
 	INTERFACE     string
 	WIFI_SSID     string
 	WIFI_PASSWORD string
+
+	// Empty STATIC_ADDRESS means DHCP.
+	STATIC_ADDRESS       string
+	STATIC_PREFIX_LENGTH int
+	GATEWAY              string
+	DNS                  []string
+
+	HTTP_PROXY  string
+	HTTPS_PROXY string
+	NO_PROXY    string
+}
+
+type NixRecoveryAPTemplateValues struct {
+	INTERFACE string
+	SSID      string
+	GATEWAY   string
+	SUBNET    string
+	COUNTRY   string
+	UI_PORT   int
+	// The interface create_ap makes for the AP, as opposed to INTERFACE.
+	AP_INTERFACE string
+	PASSPHRASE   string
+}
+
+// StorageVolume is the data volume prepare-storage-device made. UUID is
+// the filesystem's, so the volume is mounted no matter how the disks
+// enumerate on boot.
+type StorageVolume struct {
+	Partition string
+	// Set when the data volume is a btrfs raid1 across two disks.
+	MirrorPartition string
+	UUID            string
 }
 
+// StorageStatusDir is where the data volume's mount and scrub services
+// leave their status, readable by dogeboxd.
+const StorageStatusDir = "/var/lib/dbx-storage"
+
 type NixStorageOverlayTemplateValues struct {
 	STORAGE_DEVICE string
-	DATA_DIR       string
-	DBX_UID        string
+	// The filesystem UUID to mount by, falling back to STORAGE_DEVICE.
+	STORAGE_UUID string
+	// Set when the data volume is a btrfs raid1 across two disks.
+	STORAGE_MIRROR_DEVICE string
+	// Where the mount and scrub services leave status for dogeboxd, see
+	// system.CheckStorageHealth.
+	STORAGE_STATUS_DIR string
+	DATA_DIR           string
+	DBX_UID            string
 }
 
 type NixPatchApplyOptions struct {
 	RebuildBoot        bool
 	DangerousNoRebuild bool
+	// Optional, the job progress to report backing up and restoring nix
+	// config within, see TransferProgress. Unset only logs how it's going.
+	ProgressFrom int
+	ProgressTo   int
 }
 
 type NixPatch interface {
@@ -382,19 +622,54 @@ type NixPatch interface {
 	WritePupFile(pupId string, values NixPupContainerTemplateValues)
 	RemovePupFile(pupId string)
 	UpdateStorageOverlay(values NixStorageOverlayTemplateValues)
+	UpdateRecoveryAP(values NixRecoveryAPTemplateValues)
+	RemoveRecoveryAP()
+	RestoreConfigBackup(backupID string)
+}
+
+const (
+	NIX_CONFIG_BACKUP_FULL        = "full"
+	NIX_CONFIG_BACKUP_INCREMENTAL = "incremental"
+)
+
+// A snapshot of the nix directory, taken before a NixPatch is applied.
+// Incremental backups only hold what changed since Parent, and restore
+// by layering over it.
+type NixConfigBackup struct {
+	ID      string    `json:"id"`
+	PatchID string    `json:"patchId"`
+	Created time.Time `json:"created"`
+	Size    int64     `json:"size"`
+	Kind    string    `json:"kind"`
+	Parent  string    `json:"parent,omitempty"`
+}
+
+// A file in the nix directory as of a NixConfigBackup.
+type NixConfigBackupFile struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
 }
 
 type NixManager interface {
 	// NixPatch passthrough helpers.
 	InitSystem(patch NixPatch, dbxState DogeboxState)
-	UpdateIncludesFile(patch NixPatch, pups PupManager)
+	UpdateIncludesFile(patch NixPatch, pups PupManager, dbxState DogeboxState)
 	WritePupFile(patch NixPatch, state PupState, dbxState DogeboxState)
 	RemovePupFile(patch NixPatch, pupId string)
 	UpdateSystemContainerConfiguration(patch NixPatch)
 	UpdateFirewallRules(patch NixPatch, dbxState DogeboxState)
 	UpdateNetwork(patch NixPatch, values NixNetworkTemplateValues)
 	UpdateSystem(patch NixPatch, values NixSystemTemplateValues)
-	UpdateStorageOverlay(patch NixPatch, partitionName string)
+	UpdateStorageOverlay(patch NixPatch, volume StorageVolume)
+	UpdateRecoveryAP(patch NixPatch, iface string, ssid string, dbxState DogeboxState)
+	RemoveRecoveryAP(patch NixPatch)
+	RestoreConfigBackup(patch NixPatch, backupID string) error
+
+	CreateConfigBackup(log SubLogger) (NixConfigBackup, error)
+	ListConfigBackups() ([]NixConfigBackup, error)
+	ListConfigBackupFiles(backupID string) ([]NixConfigBackupFile, error)
+	DeleteConfigBackup(backupID string) error
 
 	RebuildBoot(log SubLogger) error
 	Rebuild(log SubLogger) error
@@ -403,6 +678,103 @@ type NixManager interface {
 
 	GetConfigValueContext(ctx context.Context, configItem string) (string, error)
 	GetConfigValue(configItem string) (string, error)
+
+	// Packages installed into the running system profile.
+	ListSystemPackages() ([]SystemPackage, error)
+	// Generations of the system profile, oldest first.
+	ListSystemGenerations() ([]SystemGeneration, error)
+}
+
+// A package in the running system profile (environment.systemPackages)
+type SystemPackage struct {
+	Name      string `json:"name"`
+	Version   string `json:"version"`
+	StorePath string `json:"storePath"`
+}
+
+// A generation of the system profile, one for every rebuild not yet
+// garbage collected, see RollbackSystem.
+type SystemGeneration struct {
+	Number       int       `json:"number"`
+	Date         time.Time `json:"date"`
+	StorePath    string    `json:"storePath"`
+	NixOSVersion string    `json:"nixosVersion"`
+	// The profile's generation, what the box boots into next.
+	Current bool `json:"current"`
+	// What's running now, and what the box last booted. These differ
+	// from Current after a rebuild-boot, or a switch that failed.
+	Running bool `json:"running"`
+	Booted  bool `json:"booted"`
+}
+
+// Status of a host systemd unit, as reported in the system inventory
+type SystemServiceStatus struct {
+	Name        string     `json:"name"`
+	Unit        string     `json:"unit"`
+	Optional    bool       `json:"optional"` // only reported when installed
+	LoadState   string     `json:"loadState"`
+	ActiveState string     `json:"activeState"`
+	SubState    string     `json:"subState"`
+	ActiveSince *time.Time `json:"activeSince,omitempty"`
+}
+
+// SystemInventory describes what is installed on the host, for support
+// triage and the System Info page.
+type SystemInventory struct {
+	Release      string                                  `json:"release"`
+	NixOSVersion string                                  `json:"nixosVersion"`
+	Git          version.DBXVersionInfoGit               `json:"git"`
+	Pins         map[string]version.DBXVersionInputTuple `json:"pins"` // from /opt/versioning
+	Packages     []SystemPackage                         `json:"packages"`
+	Services     []SystemServiceStatus                   `json:"services"`
+	Errors       []string                                `json:"errors,omitempty"` // sections we failed to collect
+}
+
+// ComponentDrift compares what is pinned for a core component against
+// the binary actually running.
+type ComponentDrift struct {
+	Name          string   `json:"name"`
+	Unit          string   `json:"unit"`
+	PinnedRev     string   `json:"pinnedRev,omitempty"`     // from /opt/versioning
+	RunningRev    string   `json:"runningRev,omitempty"`    // only known for non-nix builds of dogeboxd
+	RunningPath   string   `json:"runningPath,omitempty"`   // store path of the running binary
+	InstalledPath string   `json:"installedPath,omitempty"` // store path the unit will run on next start
+	Drifted       bool     `json:"drifted"`
+	Reasons       []string `json:"reasons,omitempty"`
+}
+
+// VersionDriftReport flags a partially applied update, where the pins,
+// installed OS flake and running binaries disagree.
+type VersionDriftReport struct {
+	Release          string           `json:"release"`          // from /opt/versioning
+	InstalledRelease string           `json:"installedRelease"` // from the OS flake
+	ReleaseDrifted   bool             `json:"releaseDrifted"`
+	Drifted          bool             `json:"drifted"`
+	Components       []ComponentDrift `json:"components"`
+	Errors           []string         `json:"errors,omitempty"` // checks we couldn't run
+}
+
+// StorageHealthReport is what the data volume's mount, status and scrub
+// services last recorded, see system.CheckStorageHealth.
+type StorageHealthReport struct {
+	Mirrored bool `json:"mirrored"`
+	// The mirror was mounted with -o degraded, as a disk was missing.
+	Degraded   bool   `json:"degraded"`
+	DegradedAt string `json:"degradedAt,omitempty"`
+	// btrfs can't see every disk in the mirror.
+	MissingDevices bool `json:"missingDevices"`
+	// Non-zero btrfs device stats counters, eg. "[/dev/sdb1].read_io_errs": 3.
+	DeviceErrors map[string]int `json:"deviceErrors,omitempty"`
+	Scrub        *StorageScrub  `json:"scrub,omitempty"`
+	Healthy      bool           `json:"healthy"`
+	Errors       []string       `json:"errors,omitempty"` // status we couldn't read
+}
+
+type StorageScrub struct {
+	Started      string `json:"started"`
+	Status       string `json:"status"`
+	ErrorSummary string `json:"errorSummary"`
+	Uncorrected  int    `json:"uncorrected"`
 }
 
 type SystemDiskSuitabilityEntry struct {
@@ -0,0 +1,245 @@
+package system
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"time"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/Dogebox-WG/dogeboxd/pkg/system/network"
+	"github.com/Dogebox-WG/dogeboxd/pkg/utils"
+)
+
+const (
+	APModeCheckInterval = time.Minute
+	// How long we wait without connectivity before bringing up the recovery AP.
+	APModeConnectivityTimeout = 15 * time.Minute
+	// If no network has ever been configured we don't need to wait as long.
+	APModeUnconfiguredTimeout = 2 * time.Minute
+	// How many checks in a row must fail, as well as the timeout passing,
+	// so a blip between checks doesn't count as being offline throughout.
+	APModeRequiredFailedChecks = 5
+
+	APModeReasonNoNetwork        = "No network has been configured"
+	APModeReasonLostConnectivity = "Network connectivity was lost"
+)
+
+// ISO 3166-1 alpha-2, or "00" for the world regulatory domain.
+var wifiRegulatoryDomainPattern = regexp.MustCompile(`^([A-Z]{2}|00)$`)
+
+func IsValidWifiRegulatoryDomain(country string) bool {
+	return wifiRegulatoryDomainPattern.MatchString(country)
+}
+
+func (t SystemUpdater) setAPMode(a dogeboxd.SetAPMode, log dogeboxd.SubLogger) error {
+	dbxState := t.sm.Get().Dogebox
+	previous := dbxState.APMode
+
+	patch := t.nix.NewPatch(log)
+
+	passphrase := previous.Passphrase
+	if passphrase == "" {
+		var err error
+		if passphrase, err = network.NewRecoveryAPPassphrase(); err != nil {
+			log.Errf("Failed to generate access point passphrase: %v", err)
+			patch.Cancel()
+			return err
+		}
+	}
+
+	if a.Enabled {
+		dbxState.APMode = dogeboxd.DogeboxStateAPMode{
+			Enabled:    true,
+			Reason:     a.Reason,
+			Passphrase: passphrase,
+		}
+		ssid, err := t.network.EnableAPMode(patch, dbxState)
+		if err != nil {
+			log.Errf("Failed to prepare access point: %v", err)
+			patch.Cancel()
+			return err
+		}
+		log.Logf("Enabling setup access point %q: %s", ssid, a.Reason)
+		dbxState.APMode.SSID = ssid
+	} else {
+		log.Log("Disabling setup access point")
+		t.network.DisableAPMode(patch)
+		dbxState.APMode = dogeboxd.DogeboxStateAPMode{Passphrase: passphrase}
+	}
+
+	if err := t.sm.SetDogebox(dbxState); err != nil {
+		log.Errf("Failed to save AP mode state: %v", err)
+		patch.Cancel()
+		return err
+	}
+
+	log.Progress(20).Log("Applying system configuration...")
+
+	if err := patch.Apply(); err != nil {
+		log.Errf("Failed to apply nix patch: %v", err)
+
+		// Our nix config has been rolled back, so put our state back to match.
+		restored := t.sm.Get().Dogebox
+		restored.APMode = previous
+		if err := t.sm.SetDogebox(restored); err != nil {
+			log.Errf("Failed to restore AP mode state: %v", err)
+		}
+		return err
+	}
+
+	log.Progress(100).Logf("Setup access point enabled: %t", a.Enabled)
+	return nil
+}
+
+func (t SystemUpdater) updateWifiRegulatoryDomain(a dogeboxd.UpdateWifiRegulatoryDomain, log dogeboxd.SubLogger) error {
+	if !IsValidWifiRegulatoryDomain(a.Country) {
+		return fmt.Errorf("invalid wifi regulatory domain %q", a.Country)
+	}
+
+	log.Logf("Updating WiFi regulatory domain to %s", a.Country)
+
+	dbxState := t.sm.Get().Dogebox
+	dbxState.WifiRegulatoryDomain = a.Country
+
+	if err := t.sm.SetDogebox(dbxState); err != nil {
+		log.Errf("Failed to save WiFi regulatory domain: %v", err)
+		return err
+	}
+
+	log.Progress(20).Log("Applying system configuration...")
+
+	patch := t.nix.NewPatch(log)
+
+	values := utils.GetNixSystemTemplateValues(dbxState)
+	t.nix.UpdateSystem(patch, values)
+
+	// The access point needs to know our country too.
+	if dbxState.APMode.Enabled {
+		if _, err := t.network.EnableAPMode(patch, dbxState); err != nil {
+			log.Errf("Failed to update access point: %v", err)
+			patch.Cancel()
+			return err
+		}
+	}
+
+	if err := patch.Apply(); err != nil {
+		log.Errf("Failed to apply nix patch: %v", err)
+		return err
+	}
+
+	log.Progress(100).Logf("WiFi regulatory domain updated to %s", a.Country)
+	return nil
+}
+
+/* APModeWatchdog
+ *
+ * APModeWatchdog periodically checks that the box can reach
+ * the network. If no network has been configured, or we've
+ * lost connectivity for long enough, it brings up the recovery
+ * access point so the user can reconfigure networking without
+ * needing a monitor & keyboard.
+ *
+ * This only happens before initial setup has finished. Once a box
+ * is configured, the AP is only ever turned on by the user, as an
+ * outage upstream of us shouldn't put the admin UI on the air.
+ */
+
+func NewAPModeWatchdog(sm dogeboxd.StateManager, network dogeboxd.NetworkManager, addAction func(dogeboxd.Action) string) *APModeWatchdog {
+	return &APModeWatchdog{
+		sm:            sm,
+		network:       network,
+		addAction:     addAction,
+		lastConnected: time.Now(),
+	}
+}
+
+type APModeWatchdog struct {
+	sm            dogeboxd.StateManager
+	network       dogeboxd.NetworkManager
+	addAction     func(dogeboxd.Action) string
+	lastConnected time.Time
+	failedChecks  int
+	// The AP mode we've last asked for, so we don't queue duplicate jobs.
+	requested   *bool
+	requestedAt time.Time
+}
+
+func (t *APModeWatchdog) Run(started, stopped chan bool, stop chan context.Context) error {
+	go func() {
+		go func() {
+			ticker := time.NewTicker(APModeCheckInterval)
+			defer ticker.Stop()
+		mainloop:
+			for {
+				select {
+				case <-stop:
+					break mainloop
+				case <-ticker.C:
+					if a := t.check(time.Now(), t.network.HasConnectivity()); a != nil {
+						log.Printf("AP mode watchdog: queueing %s", a.ActionName())
+						t.addAction(a)
+					}
+				}
+			}
+		}()
+		started <- true
+		<-stop
+		stopped <- true
+	}()
+	return nil
+}
+
+// check returns a SetAPMode action if the recovery AP should change state, or nil.
+func (t *APModeWatchdog) check(now time.Time, connected bool) dogeboxd.Action {
+	dbxState := t.sm.Get().Dogebox
+	apMode := dbxState.APMode
+
+	// Give our last request time to go through, but try again if it never did.
+	if t.requested != nil && *t.requested != apMode.Enabled && now.Sub(t.requestedAt) < APModeConnectivityTimeout {
+		return nil
+	}
+	t.requested = nil
+
+	if connected {
+		t.lastConnected = now
+		t.failedChecks = 0
+
+		// Only take the AP down automatically if we put it up because we
+		// lost connectivity, otherwise leave it to the user to turn off.
+		if apMode.Enabled && apMode.Reason == APModeReasonLostConnectivity {
+			return t.request(now, dogeboxd.SetAPMode{Enabled: false})
+		}
+		return nil
+	}
+
+	t.failedChecks++
+
+	if apMode.Enabled || dbxState.InitialState.HasFullyConfigured {
+		return nil
+	}
+	if t.failedChecks < APModeRequiredFailedChecks {
+		return nil
+	}
+
+	if !dbxState.InitialState.HasSetNetwork {
+		if now.Sub(t.lastConnected) >= APModeUnconfiguredTimeout {
+			return t.request(now, dogeboxd.SetAPMode{Enabled: true, Reason: APModeReasonNoNetwork})
+		}
+		return nil
+	}
+
+	if now.Sub(t.lastConnected) >= APModeConnectivityTimeout {
+		return t.request(now, dogeboxd.SetAPMode{Enabled: true, Reason: APModeReasonLostConnectivity})
+	}
+
+	return nil
+}
+
+func (t *APModeWatchdog) request(now time.Time, a dogeboxd.SetAPMode) dogeboxd.Action {
+	enabled := a.Enabled
+	t.requested = &enabled
+	t.requestedAt = now
+	return a
+}
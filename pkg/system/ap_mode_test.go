@@ -0,0 +1,100 @@
+package system
+
+import (
+	"testing"
+	"time"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newAPModeTestWatchdog(t *testing.T, hasSetNetwork bool) (*APModeWatchdog, dogeboxd.StateManager, time.Time) {
+	sm := newSafeModeTestStateManager(t)
+
+	dbxState := sm.Get().Dogebox
+	dbxState.InitialState.HasSetNetwork = hasSetNetwork
+	require.NoError(t, sm.SetDogebox(dbxState))
+
+	w := NewAPModeWatchdog(sm, nil, nil)
+	return w, sm, w.lastConnected
+}
+
+// failChecks runs the failed checks the watchdog needs before it will act,
+// ending at now.
+func failChecks(w *APModeWatchdog, now time.Time) {
+	for i := APModeRequiredFailedChecks - 1; i > 0; i-- {
+		w.check(now.Add(-time.Duration(i)*time.Second), false)
+	}
+}
+
+func TestAPModeWatchdogEnablesAfterConnectivityTimeout(t *testing.T) {
+	w, _, start := newAPModeTestWatchdog(t, true)
+
+	failChecks(w, start.Add(APModeConnectivityTimeout-time.Second))
+	assert.Nil(t, w.check(start.Add(APModeConnectivityTimeout-time.Second), false))
+
+	a := w.check(start.Add(APModeConnectivityTimeout), false)
+	assert.Equal(t, dogeboxd.SetAPMode{Enabled: true, Reason: APModeReasonLostConnectivity}, a)
+
+	// Don't queue it again while the first request is still pending.
+	assert.Nil(t, w.check(start.Add(APModeConnectivityTimeout+APModeCheckInterval), false))
+}
+
+func TestAPModeWatchdogUsesShortTimeoutWithoutNetwork(t *testing.T) {
+	w, _, start := newAPModeTestWatchdog(t, false)
+
+	failChecks(w, start.Add(APModeUnconfiguredTimeout))
+	a := w.check(start.Add(APModeUnconfiguredTimeout), false)
+	assert.Equal(t, dogeboxd.SetAPMode{Enabled: true, Reason: APModeReasonNoNetwork}, a)
+}
+
+func TestAPModeWatchdogNeedsSeveralFailedChecks(t *testing.T) {
+	w, _, start := newAPModeTestWatchdog(t, true)
+
+	assert.Nil(t, w.check(start.Add(APModeConnectivityTimeout), false))
+	assert.Nil(t, w.check(start.Add(APModeConnectivityTimeout+time.Second), false))
+}
+
+func TestAPModeWatchdogConnectivityResetsTimer(t *testing.T) {
+	w, _, start := newAPModeTestWatchdog(t, true)
+
+	failChecks(w, start.Add(APModeConnectivityTimeout-2*time.Second))
+	assert.Nil(t, w.check(start.Add(APModeConnectivityTimeout-time.Second), true))
+	failChecks(w, start.Add(APModeConnectivityTimeout))
+	assert.Nil(t, w.check(start.Add(APModeConnectivityTimeout), false))
+}
+
+func TestAPModeWatchdogNeverEnablesOnConfiguredBoxes(t *testing.T) {
+	w, sm, start := newAPModeTestWatchdog(t, true)
+
+	dbxState := sm.Get().Dogebox
+	dbxState.InitialState.HasFullyConfigured = true
+	require.NoError(t, sm.SetDogebox(dbxState))
+
+	failChecks(w, start.Add(time.Hour))
+	assert.Nil(t, w.check(start.Add(time.Hour), false))
+}
+
+func TestAPModeWatchdogDisablesOnlyWhenItEnabledTheAP(t *testing.T) {
+	w, sm, start := newAPModeTestWatchdog(t, true)
+
+	dbxState := sm.Get().Dogebox
+	dbxState.APMode = dogeboxd.DogeboxStateAPMode{Enabled: true, Reason: "Enabled by user"}
+	require.NoError(t, sm.SetDogebox(dbxState))
+
+	assert.Nil(t, w.check(start, true))
+
+	dbxState.APMode.Reason = APModeReasonLostConnectivity
+	require.NoError(t, sm.SetDogebox(dbxState))
+
+	assert.Equal(t, dogeboxd.SetAPMode{Enabled: false}, w.check(start, true))
+}
+
+func TestIsValidWifiRegulatoryDomain(t *testing.T) {
+	assert.True(t, IsValidWifiRegulatoryDomain("AU"))
+	assert.True(t, IsValidWifiRegulatoryDomain("00"))
+	assert.False(t, IsValidWifiRegulatoryDomain("au"))
+	assert.False(t, IsValidWifiRegulatoryDomain("AUS"))
+	assert.False(t, IsValidWifiRegulatoryDomain(""))
+}
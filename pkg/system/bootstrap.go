@@ -44,6 +44,9 @@ func (t SystemUpdater) initialBootstrap(a dogeboxd.InitialBootstrap, j dogeboxd.
 	if dbxState.StorageDevice != "" {
 		storageLog := j.Logger.Step("bootstrap-storage").Progress(55)
 		storageLog.Logf("Initialising storage device: %s", dbxState.StorageDevice)
+		if dbxState.StorageMirrorDevice != "" {
+			storageLog.Logf("Mirroring storage onto: %s", dbxState.StorageMirrorDevice)
+		}
 
 		dbClosed := false
 		defer func() {
@@ -68,7 +71,7 @@ func (t SystemUpdater) initialBootstrap(a dogeboxd.InitialBootstrap, j dogeboxd.
 		}
 		storageLog.Logf("Created temporary directory: %s", tempDir)
 
-		partitionName, err := InitStorageDevice(dbxState)
+		volume, err := InitStorageDevice(dbxState)
 		if err != nil {
 			return fmt.Errorf("error initialising storage device: %w", err)
 		}
@@ -80,7 +83,7 @@ func (t SystemUpdater) initialBootstrap(a dogeboxd.InitialBootstrap, j dogeboxd.
 
 		// Apply our new overlay update.
 		overlayPatch := t.nix.NewPatch(storageLog.Progress(65))
-		t.nix.UpdateStorageOverlay(overlayPatch, partitionName)
+		t.nix.UpdateStorageOverlay(overlayPatch, volume)
 		if err := overlayPatch.Apply(); err != nil {
 			return fmt.Errorf("error applying overlay patch: %w", err)
 		}
@@ -94,7 +97,7 @@ func (t SystemUpdater) initialBootstrap(a dogeboxd.InitialBootstrap, j dogeboxd.
 		// we don't actually have that in the tempDir we backed up. So we have to re-save this
 		// file into the overlay we now have mounted, but we don't actually have to rebuild.
 		reoverlayPatch := t.nix.NewPatch(storageLog.Progress(75))
-		t.nix.UpdateStorageOverlay(reoverlayPatch, partitionName)
+		t.nix.UpdateStorageOverlay(reoverlayPatch, volume)
 		if err := reoverlayPatch.ApplyCustom(dogeboxd.NixPatchApplyOptions{
 			DangerousNoRebuild: true,
 		}); err != nil {
@@ -118,7 +121,7 @@ func (t SystemUpdater) initialBootstrap(a dogeboxd.InitialBootstrap, j dogeboxd.
 
 	// Add our DogeOrg source in by default, for people to test things with.
 	sourcesLog := j.Logger.Step("bootstrap-sources").Progress(86)
-	if _, err := t.sources.AddSource("https://github.com/Dogebox-WG/pups.git"); err != nil {
+	if _, err := t.sources.AddSource("https://github.com/Dogebox-WG/pups.git", nil); err != nil {
 		return fmt.Errorf("error adding dogeorg source: %w", err)
 	}
 	sourcesLog.Log("Added default pups source")
@@ -140,7 +143,7 @@ func (t SystemUpdater) initialBootstrap(a dogeboxd.InitialBootstrap, j dogeboxd.
 	if a.UseFoundationOSBinaryCache {
 		if err := t.AddBinaryCache(dogeboxd.AddBinaryCache{
 			Host: "https://dbx.nix.dogecoin.org",
-			Key:  "dbx.nix.dogecoin.org:ODXaHC+9DNqXQ8ZTijaCT4JpieqmOatZeZBbdN51Obc=",
+			Key:  dogeboxd.FOUNDATION_OS_BINARY_CACHE_KEY,
 		}, cacheLog); err != nil {
 			return fmt.Errorf("error adding foundation OS binary cache: %w", err)
 		}
@@ -158,6 +161,8 @@ func (t SystemUpdater) initialBootstrap(a dogeboxd.InitialBootstrap, j dogeboxd.
 	finalLog := j.Logger.Step("bootstrap-finish").Progress(100)
 	dbxs := t.sm.Get().Dogebox
 	dbxs.InitialState.HasFullyConfigured = true
+	// There's no DKM token to install pups with until the user signs in.
+	dbxs.InitialState.PendingPupCollection = a.InitialPupCollection
 	// Persist the final setup flags at the end so any failure is surfaced through
 	// the job result instead of being lost after the HTTP handler has returned.
 	if err := t.sm.SetDogebox(dbxs); err != nil {
@@ -0,0 +1,247 @@
+package system
+
+import (
+	"fmt"
+	"strings"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/Dogebox-WG/dogeboxd/pkg/rootd"
+)
+
+/* bulkPupAction runs a BulkPupAction, batching every pup's nix changes
+ * so the system rebuilds as if for one pup: once to enable or disable
+ * them, and the three times upgradePup does to upgrade them. Each pup
+ * logs to a step of its own, the rebuilds to the "rebuild" step.
+ */
+func (t SystemUpdater) bulkPupAction(a dogeboxd.BulkPupAction, j dogeboxd.Job) error {
+	states := make([]dogeboxd.PupState, 0, len(a.PupIDs))
+	for _, id := range a.PupIDs {
+		s, _, err := t.pupManager.GetPup(id)
+		if err != nil {
+			return err
+		}
+		states = append(states, s)
+	}
+
+	if a.Operation == dogeboxd.BULK_PUP_UPGRADE {
+		return t.bulkUpgradePups(a, states, j)
+	}
+	return t.bulkSetPupsEnabled(a, states, j)
+}
+
+func bulkPupStep(j dogeboxd.Job, operation string, s dogeboxd.PupState) dogeboxd.SubLogger {
+	return j.Logger.Step(fmt.Sprintf("%s %s", operation, s.DisplayName()))
+}
+
+/* bulkSetPupsEnabled enables or disables pups as enablePup and
+ * disablePup would, with one rebuild between them. If any pup fails
+ * before the rebuild is done, every pup is put back as it was, so none
+ * are left flipped without their config.
+ */
+func (t SystemUpdater) bulkSetPupsEnabled(a dogeboxd.BulkPupAction, states []dogeboxd.PupState, j dogeboxd.Job) error {
+	enabled := a.Operation == dogeboxd.BULK_PUP_ENABLE
+	log := j.Logger.Step("rebuild")
+	dbxState := t.sm.Get().Dogebox
+	nixPatch := t.nix.NewPatch(log)
+
+	stopped := []dogeboxd.PupState{}
+	fail := func(err error) error {
+		t.restoreBulkPups(a, stopped, j)
+		return err
+	}
+
+	updated := make([]dogeboxd.PupState, 0, len(states))
+	for _, s := range states {
+		pupLog := bulkPupStep(j, a.Operation, s)
+
+		// Enabled flag should already be set by dispatcher, but verify/set for idempotency
+		newState, err := t.pupManager.UpdatePup(s.ID, dogeboxd.PupEnabled(enabled), dogeboxd.WithPupdateReason(dogeboxd.PupdateReasonFor(j)))
+		if err != nil {
+			pupLog.Errf("Failed to update pup enabled state: %v", err)
+			return fail(err)
+		}
+
+		// Leave pups running until deferred changes are applied.
+		if !enabled && !dbxState.DeferRebuilds {
+			if err := t.runner.Run(pupLog, pupStopOp(s)); err != nil {
+				pupLog.Errf("Error executing _dbxroot pup stop: %v", err)
+				return fail(err)
+			}
+			stopped = append(stopped, s)
+		}
+
+		t.nix.WritePupFile(nixPatch, newState, dbxState)
+		updated = append(updated, newState)
+	}
+
+	if dbxState.DeferRebuilds {
+		if err := t.deferPupChanges(nixPatch, updated, log); err != nil {
+			return fail(err)
+		}
+		return nil
+	}
+
+	log.Logf("Rebuilding once for %d pups", len(updated))
+	if err := nixPatch.Apply(); err != nil {
+		log.Errf("Failed to apply nix patch: %v", err)
+		return fail(err)
+	}
+
+	for _, s := range updated {
+		if err := t.startManualPup(s, bulkPupStep(j, a.Operation, s)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreBulkPups puts pups back as they were before a failed bulk
+// enable or disable, starting any it had already stopped that ran before.
+// Their config is unchanged, as the nix patch wasn't applied.
+func (t SystemUpdater) restoreBulkPups(a dogeboxd.BulkPupAction, stopped []dogeboxd.PupState, j dogeboxd.Job) {
+	log := j.Logger.Step("rollback")
+	for _, id := range a.PupIDs {
+		restore, ok := a.Restore(id)
+		if !ok {
+			continue
+		}
+		if _, err := t.pupManager.UpdatePup(id, append(restore, dogeboxd.WithPupdateReason(dogeboxd.PupdateReasonFor(j)))...); err != nil {
+			log.Errf("Failed to restore %s: %v", id, err)
+		}
+	}
+
+	for _, s := range stopped {
+		if before := a.Before[s.ID]; !before.Enabled || before.Maintenance != nil {
+			continue
+		}
+		serviceName := fmt.Sprintf("container@pup-%s.service", s.ID)
+		if err := t.runner.Run(log, rootd.StartPupUnit{Unit: serviceName}); err != nil {
+			log.Errf("Failed to restart %s: %v", s.DisplayName(), err)
+		}
+	}
+}
+
+type bulkUpgrade struct {
+	before  dogeboxd.PupState
+	after   dogeboxd.PupState
+	upgrade dogeboxd.UpgradePup
+	log     dogeboxd.SubLogger
+}
+
+/* bulkUpgradePups upgrades pups the way upgradePup does, but a phase at
+ * a time across all of them, so each of its rebuilds happens once. A pup
+ * that fails before the rebuilds is marked broken and left out, the rest
+ * carry on, and the job fails naming every pup that didn't upgrade.
+ */
+func (t SystemUpdater) bulkUpgradePups(a dogeboxd.BulkPupAction, states []dogeboxd.PupState, j dogeboxd.Job) error {
+	log := j.Logger.Step("rebuild")
+	failed := []string{}
+
+	upgrades := []bulkUpgrade{}
+	for _, s := range states {
+		u := bulkUpgrade{
+			before: s,
+			upgrade: dogeboxd.UpgradePup{
+				PupID:         s.ID,
+				TargetVersion: a.TargetVersions[s.ID],
+				SourceId:      s.Source.ID,
+			},
+			log: bulkPupStep(j, a.Operation, s),
+		}
+		u.log.Logf("Upgrading pup %s (%s) from %s to %s", s.Manifest.Meta.Name, s.ID, s.Version, u.upgrade.TargetVersion)
+
+		updated, err := t.prepareUpgrade(u.upgrade, s, u.log)
+		if err != nil {
+			u.log.Errf("Upgrade failed: %v", err)
+			failed = append(failed, s.DisplayName())
+			continue
+		}
+		u.after = updated
+		upgrades = append(upgrades, u)
+	}
+	if len(upgrades) == 0 {
+		return bulkUpgradeError(failed)
+	}
+
+	dbxState := t.sm.Get().Dogebox
+	nixPatch := t.nix.NewPatch(log)
+	for _, u := range upgrades {
+		t.nix.WritePupFile(nixPatch, u.after, dbxState)
+	}
+	t.nix.UpdateIncludesFile(nixPatch, t.pupManager, dbxState)
+
+	log.Logf("Rebuilding once for %d pups", len(upgrades))
+	if err := nixPatch.Apply(); err != nil {
+		log.Errf("Failed to apply nix patch: %v", err)
+		return t.markBulkUpgradesBroken(upgrades, err)
+	}
+
+	// Enabled pups are removed and re-added so NixOS treats them as new
+	// containers, see upgradePup.
+	removeNixPatch := t.nix.NewPatch(log)
+	wereEnabled := 0
+	for _, u := range upgrades {
+		if u.before.Enabled {
+			removeNixPatch.RemovePupFile(u.before.ID)
+			wereEnabled++
+		}
+	}
+	if wereEnabled > 0 {
+		log.Logf("Removing %d enabled pups from NixOS config (will re-add as new)...", wereEnabled)
+		if err := removeNixPatch.Apply(); err != nil {
+			log.Errf("Failed to remove pups from config: %v", err)
+			return t.markBulkUpgradesBroken(upgrades, err)
+		}
+		for _, u := range upgrades {
+			if u.before.Enabled {
+				cleanContainerDir(u.before.ID, u.log)
+			}
+		}
+	}
+
+	ready := []bulkUpgrade{}
+	addNixPatch := t.nix.NewPatch(log)
+	for _, u := range upgrades {
+		newState, err := t.markUpgradeReady(u.before, u.log)
+		if err != nil {
+			failed = append(failed, u.before.DisplayName())
+			continue
+		}
+		u.after = newState
+		ready = append(ready, u)
+		if u.before.Enabled {
+			t.nix.WritePupFile(addNixPatch, newState, dbxState)
+		}
+	}
+	if wereEnabled > 0 {
+		log.Log("Adding pups back to NixOS config as new containers...")
+		t.nix.UpdateIncludesFile(addNixPatch, t.pupManager, dbxState)
+		if err := addNixPatch.Apply(); err != nil {
+			log.Errf("Failed to add pups back to config: %v", err)
+			return t.markBulkUpgradesBroken(ready, err)
+		}
+	}
+
+	for _, u := range ready {
+		if err := t.awaitUpgradedPup(u.before, u.after, u.upgrade, u.log); err != nil {
+			failed = append(failed, u.before.DisplayName())
+		}
+	}
+
+	if len(failed) > 0 {
+		return bulkUpgradeError(failed)
+	}
+	return nil
+}
+
+// markBulkUpgradesBroken marks every pup in a failed rebuild broken.
+func (t SystemUpdater) markBulkUpgradesBroken(upgrades []bulkUpgrade, err error) error {
+	for _, u := range upgrades {
+		t.markPupBroken(u.before, dogeboxd.BROKEN_REASON_NIX_APPLY_FAILED, err)
+	}
+	return err
+}
+
+func bulkUpgradeError(failed []string) error {
+	return fmt.Errorf("failed to upgrade %s", strings.Join(failed, ", "))
+}
@@ -0,0 +1,58 @@
+package system
+
+import (
+	"errors"
+	"testing"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBulkDisableDefersOneChangePerPup(t *testing.T) {
+	pups := map[string]dogeboxd.PupState{
+		"aaa": {ID: "aaa", Enabled: true},
+		"bbb": {ID: "bbb", Enabled: true},
+	}
+	updater, nix, runner := newPendingTestUpdater(t, pups, func(s *dogeboxd.DogeboxState) {})
+
+	a := dogeboxd.BulkPupAction{Operation: dogeboxd.BULK_PUP_DISABLE, PupIDs: []string{"aaa", "bbb"}}
+	job := testRunnerJob(pups["aaa"])
+	require.NoError(t, updater.bulkPupAction(a, job))
+
+	states := updater.pupManager.(*pendingPupManager).states
+	assert.False(t, states["aaa"].Enabled)
+	assert.False(t, states["bbb"].Enabled)
+	// Pups keep running until the deferred changes are applied.
+	assert.Empty(t, runner.Commands)
+	assert.Equal(t, []dogeboxd.NixPatchApplyOptions{{DangerousNoRebuild: true}}, nix.patch.applied)
+	assert.Len(t, updater.sm.Get().Dogebox.PendingChanges, 2)
+}
+
+func TestBulkDisableRollsBackWhenAStopFails(t *testing.T) {
+	pups := map[string]dogeboxd.PupState{
+		"aaa": {ID: "aaa", Enabled: false},
+		"bbb": {ID: "bbb", Enabled: false},
+	}
+	updater, nix, runner := newPendingTestUpdater(t, pups, func(s *dogeboxd.DogeboxState) {
+		s.DeferRebuilds = false
+	})
+	runner.Results["_dbxroot pup stop --pupId bbb"] = CommandResult{Err: errors.New("stop failed")}
+
+	a := dogeboxd.BulkPupAction{
+		Operation: dogeboxd.BULK_PUP_DISABLE,
+		PupIDs:    []string{"aaa", "bbb"},
+		Before: map[string]dogeboxd.BulkPupBefore{
+			"aaa": {Enabled: true},
+			"bbb": {Enabled: true},
+		},
+	}
+	job := testRunnerJob(pups["aaa"])
+	require.Error(t, updater.bulkPupAction(a, job))
+
+	states := updater.pupManager.(*pendingPupManager).states
+	assert.True(t, states["aaa"].Enabled)
+	assert.True(t, states["bbb"].Enabled)
+	assert.Empty(t, nix.patch.applied)
+	assert.Contains(t, runner.Commands, "systemctl start container@pup-aaa.service")
+}
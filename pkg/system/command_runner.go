@@ -0,0 +1,181 @@
+package system
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/Dogebox-WG/dogeboxd/pkg/rootd"
+)
+
+/* CommandRunner runs the privileged operations SystemUpdater needs,
+ * ie: _dbxroot subcommands and systemctl/journalctl on the host.
+ *
+ * SystemUpdater never builds these commands itself, each is a typed
+ * rootd.Op, so its flows can be exercised with a RecordingCommandRunner,
+ * and how privileges are obtained (sudo or rootd) can change without
+ * touching them.
+ */
+type CommandRunner interface {
+	// Run runs an op as root, streaming its output to log.
+	Run(log dogeboxd.SubLogger, op rootd.Op) error
+	// CombinedOutput runs an op as root, returning stdout and stderr. It's
+	// stopped if log's job times out, log may be nil for quick queries
+	// outside of jobs.
+	CombinedOutput(log dogeboxd.SubLogger, op rootd.Op) ([]byte, error)
+}
+
+/* NewCommandRunner uses rootd when dogeboxd is configured with its
+ * socket, falling back to sudo.
+ *
+ * rootd is set up by the system config dogeboxd writes, so until the
+ * first rebuild it isn't running, and ops go via sudo until its socket
+ * appears, see RootdCommandRunner.
+ */
+func NewCommandRunner(config dogeboxd.ServerConfig) CommandRunner {
+	if config.RootdSocketPath != "" {
+		return RootdCommandRunner{client: rootd.NewClient(config.RootdSocketPath)}
+	}
+	return SudoCommandRunner{}
+}
+
+// SudoCommandRunner runs ops via sudo, relying on the sudoers rules
+// set up for the dogeboxd user. This, and the installer, which runs
+// before the box's own config (and so rootd) exists, are the only
+// things that should run sudo.
+type SudoCommandRunner struct{}
+
+func (SudoCommandRunner) Run(log dogeboxd.SubLogger, op rootd.Op) error {
+	if err := op.Validate(); err != nil {
+		return err
+	}
+	cmd := exec.Command("sudo", op.Argv()...)
+	log.LogCmd(cmd)
+	return dogeboxd.RunCmd(log, cmd)
+}
+
+func (SudoCommandRunner) CombinedOutput(log dogeboxd.SubLogger, op rootd.Op) ([]byte, error) {
+	if err := op.Validate(); err != nil {
+		return nil, err
+	}
+	cmd := exec.Command("sudo", op.Argv()...)
+	if log == nil {
+		return cmd.CombinedOutput()
+	}
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := dogeboxd.RunCmd(log, cmd)
+	return out.Bytes(), err
+}
+
+// RootdCommandRunner sends ops to the rootd daemon, or runs them via sudo
+// while its socket doesn't exist.
+type RootdCommandRunner struct {
+	client rootd.Client
+}
+
+func (r RootdCommandRunner) listening() bool {
+	_, err := os.Stat(r.client.SocketPath)
+	return !errors.Is(err, os.ErrNotExist)
+}
+
+// Ops are stopped when their job times out by hanging up on rootd.
+func (r RootdCommandRunner) Run(log dogeboxd.SubLogger, op rootd.Op) error {
+	if !r.listening() {
+		return SudoCommandRunner{}.Run(log, op)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	defer log.OnKill(cancel)()
+	return r.client.DoContext(ctx, op, log.Log)
+}
+
+func (r RootdCommandRunner) CombinedOutput(log dogeboxd.SubLogger, op rootd.Op) ([]byte, error) {
+	if !r.listening() {
+		return SudoCommandRunner{}.CombinedOutput(log, op)
+	}
+	if log == nil {
+		return r.client.CombinedOutput(op)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	defer log.OnKill(cancel)()
+	return r.client.CombinedOutputContext(ctx, op)
+}
+
+// A CommandResult is what RecordingCommandRunner returns for a command.
+type CommandResult struct {
+	Output []byte
+	Err    error
+}
+
+// RecordingCommandRunner doesn't run anything, it records each command and
+// answers with canned results, for tests and simulating flows without root.
+type RecordingCommandRunner struct {
+	mu       sync.Mutex
+	Commands []string
+	// Results keyed by command prefix, eg: "_dbxroot pup stop". The
+	// longest matching prefix wins, anything unmatched succeeds silently.
+	Results map[string]CommandResult
+}
+
+func NewRecordingCommandRunner() *RecordingCommandRunner {
+	return &RecordingCommandRunner{Results: map[string]CommandResult{}}
+}
+
+func (r *RecordingCommandRunner) record(op rootd.Op) CommandResult {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	command := strings.Join(op.Argv(), " ")
+	r.Commands = append(r.Commands, command)
+
+	best := ""
+	for prefix := range r.Results {
+		if strings.HasPrefix(command, prefix) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	if best == "" {
+		return CommandResult{}
+	}
+	return r.Results[best]
+}
+
+func (r *RecordingCommandRunner) Run(log dogeboxd.SubLogger, op rootd.Op) error {
+	result := r.record(op)
+	if len(result.Output) > 0 {
+		log.Log(strings.TrimSpace(string(result.Output)))
+	}
+	return result.Err
+}
+
+func (r *RecordingCommandRunner) CombinedOutput(log dogeboxd.SubLogger, op rootd.Op) ([]byte, error) {
+	result := r.record(op)
+	return result.Output, result.Err
+}
+
+// Ran reports whether a command starting with prefix has been run.
+func (r *RecordingCommandRunner) Ran(prefix string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.Commands {
+		if strings.HasPrefix(c, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RecordingCommandRunner) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return fmt.Sprintf("ran: %q", r.Commands)
+}
@@ -0,0 +1,158 @@
+package system
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+	"time"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/Dogebox-WG/dogeboxd/pkg/rootd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubPupManager struct {
+	dogeboxd.PupManager
+	state dogeboxd.PupState
+}
+
+func (m *stubPupManager) UpdatePup(id string, updates ...func(*dogeboxd.PupState, *[]dogeboxd.Pupdate)) (dogeboxd.PupState, error) {
+	pupdates := []dogeboxd.Pupdate{}
+	for _, u := range updates {
+		u(&m.state, &pupdates)
+	}
+	return m.state, nil
+}
+
+func testRunnerJob(state dogeboxd.PupState) dogeboxd.Job {
+	job := dogeboxd.Job{
+		ID:    "job-runner",
+		A:     dogeboxd.DisablePup{PupID: state.ID},
+		State: &state,
+	}
+	job.Logger = dogeboxd.NewActionLogger(job, state.ID, dogeboxd.Dogeboxd{
+		Changes: make(chan dogeboxd.Change, 64),
+	})
+	return job
+}
+
+func TestRecordingCommandRunnerMatchesLongestPrefix(t *testing.T) {
+	runner := NewRecordingCommandRunner()
+	runner.Results["systemctl"] = CommandResult{Output: []byte("inactive")}
+	runner.Results["systemctl is-active container@pup-abc.service"] = CommandResult{Output: []byte("active")}
+
+	out, err := runner.CombinedOutput(nil, rootd.UnitIsActive{Unit: "container@pup-abc.service"})
+	require.NoError(t, err)
+	assert.Equal(t, "active", string(out))
+
+	out, _ = runner.CombinedOutput(nil, rootd.UnitIsActive{Unit: "container@pup-def.service"})
+	assert.Equal(t, "inactive", string(out))
+
+	out, err = runner.CombinedOutput(nil, rootd.UnitLogs{Unit: "foo.service", Lines: 20})
+	require.NoError(t, err)
+	assert.Empty(t, out)
+
+	assert.Len(t, runner.Commands, 3)
+	assert.True(t, runner.Ran("journalctl -u foo.service -n 20"))
+}
+
+func TestStartManualPup(t *testing.T) {
+	autoStart := false
+	tests := []struct {
+		name  string
+		state dogeboxd.PupState
+		runs  bool
+	}{
+		{"disabled", dogeboxd.PupState{ID: "abc", Enabled: false, AutoStart: &autoStart}, false},
+		{"starts on boot", dogeboxd.PupState{ID: "abc", Enabled: true}, false},
+		{"manual start", dogeboxd.PupState{ID: "abc", Enabled: true, AutoStart: &autoStart}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := NewRecordingCommandRunner()
+			updater := SystemUpdater{runner: runner}
+			job := testRunnerJob(tt.state)
+
+			require.NoError(t, updater.startManualPup(tt.state, job.Logger.Step("enable")))
+			assert.Equal(t, tt.runs, runner.Ran("systemctl start container@pup-abc.service"), runner.String())
+		})
+	}
+}
+
+func TestWaitForContainerRunning(t *testing.T) {
+	runner := NewRecordingCommandRunner()
+	runner.Results["systemctl is-active"] = CommandResult{Output: []byte("active\n")}
+	runner.Results["systemctl show"] = CommandResult{Output: []byte("SubState=running\n")}
+	updater := SystemUpdater{runner: runner}
+	job := testRunnerJob(dogeboxd.PupState{ID: "abc"})
+
+	err := updater.waitForContainerRunning("container@pup-abc.service", time.Minute, job.Logger.Step("upgrade"))
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"systemctl is-active container@pup-abc.service",
+		"systemctl show container@pup-abc.service --property=SubState",
+	}, runner.Commands)
+}
+
+func TestDisablePupStopsBeforeRewritingConfig(t *testing.T) {
+	runner := NewRecordingCommandRunner()
+	runner.Results["_dbxroot pup stop"] = CommandResult{Err: errors.New("exit status 1")}
+	state := dogeboxd.PupState{ID: "abc", Enabled: true}
+	pm := &stubPupManager{state: state}
+	updater := SystemUpdater{runner: runner, pupManager: pm, nix: &testNixManager{}}
+
+	err := updater.disablePup(testRunnerJob(state))
+
+	require.Error(t, err)
+	assert.False(t, pm.state.Enabled)
+	assert.Equal(t, []string{"_dbxroot pup stop --pupId abc"}, runner.Commands)
+}
+
+// hangingCommandRunner runs a real command that never finishes, as a
+// stuck rebuild would.
+type hangingCommandRunner struct {
+	finished chan error
+}
+
+func (r hangingCommandRunner) Run(log dogeboxd.SubLogger, op rootd.Op) error {
+	cmd := exec.Command("sh", "-c", "echo hanging; sleep 30")
+	log.LogCmd(cmd)
+	err := dogeboxd.RunCmd(log, cmd)
+	r.finished <- err
+	return err
+}
+
+func (r hangingCommandRunner) CombinedOutput(log dogeboxd.SubLogger, op rootd.Op) ([]byte, error) {
+	return nil, r.Run(dogeboxd.NewConsoleSubLogger("", "hang"), op)
+}
+
+func TestRunJobTimesOutHungCommand(t *testing.T) {
+	orig := getJobTimeout
+	defer func() { getJobTimeout = orig }()
+	getJobTimeout = func(dogeboxd.Action) time.Duration { return 300 * time.Millisecond }
+
+	runner := hangingCommandRunner{finished: make(chan error, 1)}
+	state := dogeboxd.PupState{ID: "abc", Enabled: true}
+	updater := SystemUpdater{
+		runner:     runner,
+		pupManager: &stubPupManager{state: state},
+		nix:        &testNixManager{},
+		done:       make(chan dogeboxd.Job, 1),
+	}
+
+	updater.runJob(testRunnerJob(state))
+
+	j := <-updater.done
+	assert.True(t, j.TimedOut)
+	assert.Contains(t, j.Err, "Timed out after 300ms")
+
+	select {
+	case err := <-runner.finished:
+		assert.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("hung command wasn't killed")
+	}
+}
@@ -3,6 +3,7 @@ package system
 import (
 	"crypto/sha256"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"os/exec"
@@ -126,9 +127,12 @@ func (t SystemUpdater) ValidateNix(content string) error {
 	}
 	tmpFile.Close()
 
-	// Run nix-instantiate --parse to validate syntax
-	cmd := exec.Command("nix-instantiate", "--parse", tmpFile.Name())
-	output, err := cmd.CombinedOutput()
+	return validateNixFile(tmpFile.Name())
+}
+
+// validateNixFile runs nix-instantiate --parse to validate syntax.
+func validateNixFile(path string) error {
+	output, err := exec.Command("nix-instantiate", "--parse", path).CombinedOutput()
 	if err != nil {
 		return &NixValidationError{Output: string(output)}
 	}
@@ -146,7 +150,7 @@ func (e *NixValidationError) Error() string {
 }
 
 // SaveCustomNix validates and saves the custom.nix content,
-// then triggers a system rebuild.
+// then triggers a system rebuild, unless rebuilds are deferred.
 func (t SystemUpdater) SaveCustomNix(content string, l dogeboxd.SubLogger) error {
 	l.Logf("Validating custom nix configuration...")
 
@@ -164,11 +168,25 @@ func (t SystemUpdater) SaveCustomNix(content string, l dogeboxd.SubLogger) error
 		l.Errf("Failed to create custom.nix directory: %v", err)
 		return err
 	}
+	previous, err := os.ReadFile(customNixPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		l.Errf("Failed to read custom.nix: %v", err)
+		return err
+	}
 	if err := os.WriteFile(customNixPath, []byte(content), 0644); err != nil {
 		l.Errf("Failed to write custom.nix: %v", err)
 		return err
 	}
 
+	if dbxState := t.sm.Get().Dogebox; dbxState.DeferRebuilds {
+		dbxState.RecordCustomNixPendingChange(string(previous), content)
+		if err := t.sm.SetDogebox(dbxState); err != nil {
+			return fmt.Errorf("failed to save pending change: %w", err)
+		}
+		l.Logf("Rebuilds are deferred, custom configuration will be applied with pending changes")
+		return nil
+	}
+
 	// dogebox.nix now imports the data-dir custom.nix directly via the template,
 	// so saving the file is enough before triggering a rebuild.
 	l.Logf("Triggering system rebuild...")
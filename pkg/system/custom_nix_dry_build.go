@@ -0,0 +1,169 @@
+package system
+
+import (
+	"errors"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/Dogebox-WG/dogeboxd/pkg/rootd"
+)
+
+var (
+	// eg: at /tmp/custom-nix-dry-build-123.nix:3:5:
+	nixLocationPattern = regexp.MustCompile(`,?\s*\bat (/[^\s:]+):(\d+):(\d+):?`)
+	// eg: warning: ..., trace: warning: ..., evaluation warning: ...
+	nixWarningPattern = regexp.MustCompile(`^(?:trace: |evaluation )?warning: (.+)$`)
+)
+
+/* validateCustomNix checks content parses, then dry builds the system
+ * with it as custom.nix. Neither step touches the saved custom.nix or
+ * the running system, so an invalid configuration is reported rather
+ * than failing the job.
+ */
+func (t SystemUpdater) validateCustomNix(content string, l dogeboxd.SubLogger) dogeboxd.CustomNixValidation {
+	tmpFile, err := os.CreateTemp(t.config.TmpDir, "custom-nix-dry-build-*.nix")
+	if err != nil {
+		l.Errf("Failed to create temporary custom.nix: %v", err)
+		return invalidCustomNix(err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	_, err = tmpFile.WriteString(content)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		l.Errf("Failed to write temporary custom.nix: %v", err)
+		return invalidCustomNix(err)
+	}
+
+	l.Logf("Checking custom nix configuration parses...")
+	if err := validateNixFile(tmpFile.Name()); err != nil {
+		l.Errf("Parse failed: %v", err)
+		var validationErr *NixValidationError
+		if !errors.As(err, &validationErr) {
+			return invalidCustomNix(err)
+		}
+		return parseNixDryBuildOutput(validationErr.Output, tmpFile.Name(), err)
+	}
+
+	l.Logf("Dry building the system with custom nix configuration...")
+	output, err := t.runner.CombinedOutput(l, rootd.DryBuildSystem{CustomNix: tmpFile.Name()})
+	for _, line := range strings.Split(strings.TrimRight(string(output), "\n"), "\n") {
+		l.Log(line)
+	}
+
+	validation := parseNixDryBuildOutput(string(output), tmpFile.Name(), err)
+	if validation.Valid {
+		l.Logf("Custom nix configuration is valid, with %d warnings", len(validation.Warnings))
+	} else {
+		l.Errf("Custom nix configuration has %d errors", len(validation.Errors))
+	}
+	return validation
+}
+
+func invalidCustomNix(err error) dogeboxd.CustomNixValidation {
+	return dogeboxd.CustomNixValidation{
+		Errors:   []dogeboxd.CustomNixIssue{{Message: err.Error()}},
+		Warnings: []dogeboxd.CustomNixIssue{},
+	}
+}
+
+/* parseNixDryBuildOutput picks the errors and warnings out of nix's
+ * output. Each error starts with an unindented "error:" line, followed
+ * by indented trace lines that can hold nested "error:" lines, the last
+ * being the actual problem, each followed by an "at file:line:col"
+ * location. Locations in customNixPath are reported as custom.nix, and
+ * preferred, as that's what the user can fix.
+ *
+ * runErr is the dry build's exit error, reported as is when nix failed
+ * without saying why in a way we recognise.
+ */
+func parseNixDryBuildOutput(output string, customNixPath string, runErr error) dogeboxd.CustomNixValidation {
+	validation := dogeboxd.CustomNixValidation{
+		Errors:   []dogeboxd.CustomNixIssue{},
+		Warnings: []dogeboxd.CustomNixIssue{},
+	}
+
+	var current *dogeboxd.CustomNixIssue
+	var located, inCustomNix bool
+	finish := func() {
+		if current != nil {
+			validation.Errors = append(validation.Errors, *current)
+			current = nil
+		}
+	}
+	locate := func(line string) {
+		m := nixLocationPattern.FindStringSubmatch(line)
+		if m == nil {
+			return
+		}
+		isCustomNix := customNixPath != "" && m[1] == customNixPath
+		if located && (inCustomNix || !isCustomNix) {
+			return
+		}
+		current.File = m[1]
+		if isCustomNix {
+			current.File = "custom.nix"
+		}
+		current.Line, _ = strconv.Atoi(m[2])
+		current.Column, _ = strconv.Atoi(m[3])
+		located, inCustomNix = true, isCustomNix
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		trimmed := strings.TrimSpace(line)
+		indented := strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")
+
+		if m := nixWarningPattern.FindStringSubmatch(trimmed); m != nil && !indented {
+			finish()
+			// --impure builds from /etc/nixos, which is often a dirty git tree.
+			if !strings.HasPrefix(m[1], "Git tree") {
+				validation.Warnings = append(validation.Warnings, dogeboxd.CustomNixIssue{Message: m[1]})
+			}
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "error:") {
+			// Older nix puts the location on the error line itself.
+			message := strings.TrimSpace(nixLocationPattern.ReplaceAllString(strings.TrimPrefix(trimmed, "error:"), ""))
+			if !indented {
+				finish()
+				current = &dogeboxd.CustomNixIssue{Message: message}
+				located, inCustomNix = false, false
+			} else if current != nil && message != "" {
+				// A nested error is closer to the actual problem.
+				current.Message = message
+				if !inCustomNix {
+					located = false
+				}
+			}
+			if current != nil {
+				locate(trimmed)
+			}
+			continue
+		}
+
+		if current == nil {
+			continue
+		}
+		if !indented && trimmed != "" {
+			finish()
+			continue
+		}
+		if current.Message == "" && trimmed != "" && !strings.HasPrefix(trimmed, "…") && !nixLocationPattern.MatchString(trimmed) {
+			current.Message = trimmed
+		}
+		locate(trimmed)
+	}
+	finish()
+
+	if runErr != nil && len(validation.Errors) == 0 {
+		validation.Errors = append(validation.Errors, dogeboxd.CustomNixIssue{Message: runErr.Error()})
+	}
+	validation.Valid = len(validation.Errors) == 0
+	return validation
+}
@@ -0,0 +1,75 @@
+package system
+
+import (
+	"errors"
+	"testing"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+const testCustomNixPath = "/tmp/custom-nix-dry-build-123.nix"
+
+func TestParseNixDryBuildOutputFindsTheErrorInCustomNix(t *testing.T) {
+	output := `warning: Git tree '/etc/nixos' is dirty
+trace: warning: The option ` + "`services.foo.enable'" + ` has been renamed
+error:
+       … while calling the 'head' builtin
+         at /nix/store/abc-source/lib/attrsets.nix:1575:11:
+       … while evaluating the attribute 'value'
+         at /nix/store/abc-source/lib/modules.nix:809:9:
+
+       error: undefined variable 'htop2'
+       at /tmp/custom-nix-dry-build-123.nix:3:5:
+            2|   environment.systemPackages = [
+            3|     htop2
+             |     ^
+Error executing nixos-rebuild dry-build: exit status 1
+`
+	validation := parseNixDryBuildOutput(output, testCustomNixPath, errors.New("exit status 1"))
+
+	assert.False(t, validation.Valid)
+	assert.Equal(t, []dogeboxd.CustomNixIssue{
+		{Message: "undefined variable 'htop2'", File: "custom.nix", Line: 3, Column: 5},
+	}, validation.Errors)
+	assert.Equal(t, []dogeboxd.CustomNixIssue{
+		{Message: "The option `services.foo.enable' has been renamed"},
+	}, validation.Warnings)
+}
+
+func TestParseNixDryBuildOutputHandlesLocationsOnTheErrorLine(t *testing.T) {
+	output := "error: syntax error, unexpected '}', at /tmp/custom-nix-dry-build-123.nix:7:1\n"
+	validation := parseNixDryBuildOutput(output, testCustomNixPath, errors.New("exit status 1"))
+
+	assert.Equal(t, []dogeboxd.CustomNixIssue{
+		{Message: "syntax error, unexpected '}'", File: "custom.nix", Line: 7, Column: 1},
+	}, validation.Errors)
+}
+
+func TestParseNixDryBuildOutputKeepsLocationsOutsideCustomNix(t *testing.T) {
+	output := `error: The option ` + "`services.foo'" + ` does not exist. Definition values:
+       - In ` + "`/tmp/custom-nix-dry-build-123.nix'" + `: true
+       at /nix/store/abc-source/lib/modules.nix:12:3:
+`
+	validation := parseNixDryBuildOutput(output, testCustomNixPath, errors.New("exit status 1"))
+
+	assert.Equal(t, []dogeboxd.CustomNixIssue{
+		{Message: "The option `services.foo' does not exist. Definition values:", File: "/nix/store/abc-source/lib/modules.nix", Line: 12, Column: 3},
+	}, validation.Errors)
+}
+
+func TestParseNixDryBuildOutputIsValidWhenTheBuildSucceeds(t *testing.T) {
+	output := "these 3 derivations will be built:\n  /nix/store/abc-etc.drv\nevaluation warning: foo is deprecated\n"
+	validation := parseNixDryBuildOutput(output, testCustomNixPath, nil)
+
+	assert.True(t, validation.Valid)
+	assert.Empty(t, validation.Errors)
+	assert.Equal(t, []dogeboxd.CustomNixIssue{{Message: "foo is deprecated"}}, validation.Warnings)
+}
+
+func TestParseNixDryBuildOutputReportsUnrecognisedFailures(t *testing.T) {
+	validation := parseNixDryBuildOutput("sudo: a password is required\n", testCustomNixPath, errors.New("exit status 1"))
+
+	assert.False(t, validation.Valid)
+	assert.Equal(t, []dogeboxd.CustomNixIssue{{Message: "exit status 1"}}, validation.Errors)
+}
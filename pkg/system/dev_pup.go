@@ -0,0 +1,44 @@
+package system
+
+import (
+	"fmt"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/Dogebox-WG/dogeboxd/pkg/rootd"
+)
+
+/* rebuildDevPup rebuilds a dev mode pup from its disk source, which its
+ * container config points straight at, then restarts it so the new build
+ * is what's running. Pending changes aren't deferred, a developer asking
+ * for a rebuild wants to see it.
+ */
+func (t SystemUpdater) rebuildDevPup(j dogeboxd.Job) error {
+	log := j.Logger.Step("rebuild")
+
+	state, _, err := t.pupManager.GetPup(j.State.ID)
+	if err != nil {
+		return err
+	}
+	if !state.IsDevModeEnabled {
+		return fmt.Errorf("%s isn't in development mode", state.DisplayName())
+	}
+
+	log.Logf("Rebuilding %s from %s", state.DisplayName(), state.Source.Location)
+	nixPatch := t.nix.NewPatch(log)
+	t.nix.WritePupFile(nixPatch, state, t.sm.Get().Dogebox)
+	if err := nixPatch.Apply(); err != nil {
+		log.Errf("Failed to apply nix patch: %v", err)
+		return err
+	}
+
+	if !state.Enabled || state.InMaintenance() {
+		log.Logf("%s isn't running, not restarting", state.DisplayName())
+		return nil
+	}
+	serviceName := fmt.Sprintf("container@pup-%s.service", state.ID)
+	if err := t.runner.Run(log, rootd.RestartPupUnit{Unit: serviceName}); err != nil {
+		log.Errf("Failed to restart container: %v", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+package system
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/Dogebox-WG/dogeboxd/pkg/rootd"
+	"github.com/Dogebox-WG/dogeboxd/pkg/version"
+	"github.com/carlmjohnson/versioninfo"
+	dbus "github.com/coreos/go-systemd/v22/dbus"
+)
+
+/* Drift is what a partially applied update leaves behind: the OS flake
+ * and /opt/versioning say one thing, but a service wasn't restarted (or
+ * failed to) and is still running the old binary from the store.
+ *
+ * We spot this by comparing the store path each unit will exec on its
+ * next start (ExecStart) with the one it was actually started from.
+ */
+
+type driftComponent struct {
+	name string
+	unit string
+}
+
+var driftComponents = []driftComponent{
+	{name: "dogeboxd", unit: "dogeboxd.service"},
+	{name: "dkm", unit: "dkm.service"},
+	// dpanel is served by dogeboxd from --uidir.
+	{name: "dpanel", unit: "dogeboxd.service"},
+}
+
+// How long dogeboxd waits before restarting itself, so the re-apply job
+// is recorded as finished first.
+const reapplySelfRestartDelay = 10
+
+// Swappable for tests.
+var (
+	getServiceProperties = func(unit string) (map[string]interface{}, error) {
+		conn, err := dbus.NewWithContext(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+		return conn.GetUnitTypePropertiesContext(context.Background(), unit, "Service")
+	}
+	// cmdline is world readable, unlike /proc/<pid>/exe for other users.
+	readProcCmdline = func(pid uint32) ([]string, error) {
+		b, err := os.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+		if err != nil {
+			return nil, err
+		}
+		return strings.Split(strings.TrimRight(string(b), "\x00"), "\x00"), nil
+	}
+	runningExecutable     = os.Executable
+	runningRevision       = func() string { return versioninfo.Revision }
+	getInstalledOSRelease = dogeboxd.InstalledOSFlakeVersion
+)
+
+// CheckVersionDrift compares the version pins, the installed OS flake and
+// the running dogeboxd, dkm and dpanel. Checks that can't be run are noted
+// in Errors rather than failing the report.
+func CheckVersionDrift(config dogeboxd.ServerConfig) dogeboxd.VersionDriftReport {
+	release := version.GetDBXRelease()
+	report := dogeboxd.VersionDriftReport{
+		Release:    release.Release,
+		Components: []dogeboxd.ComponentDrift{},
+	}
+
+	if installed, err := getInstalledOSRelease(); err == nil {
+		report.InstalledRelease = installed
+		if release.Release != "unknown" && release.Release != installed {
+			report.ReleaseDrifted = true
+		}
+	} else {
+		report.Errors = append(report.Errors, fmt.Sprintf("installed release: %v", err))
+	}
+
+	props := map[string]map[string]interface{}{}
+	for _, c := range driftComponents {
+		if _, ok := props[c.unit]; ok {
+			continue
+		}
+		p, err := getServiceProperties(c.unit)
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", c.unit, err))
+			p = map[string]interface{}{}
+		}
+		props[c.unit] = p
+	}
+
+	for _, c := range driftComponents {
+		component := dogeboxd.ComponentDrift{
+			Name:      c.name,
+			Unit:      c.unit,
+			PinnedRev: release.Packages[c.name].Rev,
+		}
+		argv := execStartArgv(props[c.unit])
+
+		switch c.name {
+		case "dogeboxd":
+			if exe, err := runningExecutable(); err == nil {
+				component.RunningPath = storePathOf(exe)
+			} else {
+				report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", c.name, err))
+			}
+			if len(argv) > 0 {
+				component.InstalledPath = storePathOf(argv[0])
+			}
+			if rev := runningRevision(); rev != "unknown" {
+				component.RunningRev = rev
+				if component.PinnedRev != "" && rev != component.PinnedRev {
+					component.Reasons = append(component.Reasons, fmt.Sprintf("running rev %s, pinned rev is %s", rev, component.PinnedRev))
+				}
+			}
+
+		case "dpanel":
+			component.RunningPath = storePathOf(config.UiDir)
+			component.InstalledPath = storePathOf(flagValue(argv, "uidir"))
+
+		default:
+			if pid, _ := props[c.unit]["MainPID"].(uint32); pid > 0 {
+				if cmdline, err := readProcCmdline(pid); err == nil && len(cmdline) > 0 {
+					component.RunningPath = storePathOf(cmdline[0])
+				} else if err != nil {
+					report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", c.name, err))
+				}
+			}
+			if len(argv) > 0 {
+				component.InstalledPath = storePathOf(argv[0])
+			}
+		}
+
+		if component.RunningPath != "" && component.InstalledPath != "" && component.RunningPath != component.InstalledPath {
+			component.Reasons = append(component.Reasons, fmt.Sprintf("running %s, %s will start %s", component.RunningPath, c.unit, component.InstalledPath))
+		}
+
+		component.Drifted = len(component.Reasons) > 0
+		report.Drifted = report.Drifted || component.Drifted
+		report.Components = append(report.Components, component)
+	}
+
+	report.Drifted = report.Drifted || report.ReleaseDrifted
+	return report
+}
+
+/* reapplySystemVersion finishes a partially applied update: rebuilding
+ * when the pinned release doesn't match the installed flake, then
+ * restarting anything still running an old binary. dogeboxd restarts
+ * itself last, after a delay, so this job completes first.
+ */
+func (t SystemUpdater) reapplySystemVersion(log dogeboxd.SubLogger) error {
+	report := CheckVersionDrift(t.config)
+	if !report.Drifted {
+		log.Log("No drift between version pins and running binaries, nothing to re-apply")
+		return nil
+	}
+
+	if report.ReleaseDrifted {
+		log.Logf("Pinned release %s doesn't match installed release %s, rebuilding", report.Release, report.InstalledRelease)
+		if err := t.nix.Rebuild(log); err != nil {
+			return fmt.Errorf("failed to rebuild: %w", err)
+		}
+		report = CheckVersionDrift(t.config)
+		if report.ReleaseDrifted {
+			log.Errf("Pinned release is still %s after rebuilding, installed release is %s", report.Release, report.InstalledRelease)
+		}
+	}
+
+	restartSelf := false
+	restarted := map[string]bool{}
+	for _, c := range report.Components {
+		if !c.Drifted {
+			continue
+		}
+		for _, reason := range c.Reasons {
+			log.Logf("%s: %s", c.Name, reason)
+		}
+		if c.Unit == "dogeboxd.service" {
+			restartSelf = true
+			continue
+		}
+		if restarted[c.Unit] {
+			continue
+		}
+		restarted[c.Unit] = true
+		log.Logf("Restarting %s", c.Unit)
+		if err := t.runner.Run(log, rootd.RestartUnit{Unit: c.Unit}); err != nil {
+			return fmt.Errorf("failed to restart %s: %w", c.Unit, err)
+		}
+	}
+
+	if restartSelf {
+		log.Logf("Restarting dogeboxd in %d seconds to finish re-applying", reapplySelfRestartDelay)
+		if err := t.runner.Run(log, rootd.RestartUnit{Unit: "dogeboxd.service", DelaySeconds: reapplySelfRestartDelay}); err != nil {
+			return fmt.Errorf("failed to schedule dogeboxd restart: %w", err)
+		}
+	}
+	return nil
+}
+
+// execStartArgv is the command line of a service's first ExecStart, as
+// reported by systemd: a list of (path, argv, ...) tuples.
+func execStartArgv(props map[string]interface{}) []string {
+	entries, ok := props["ExecStart"].([][]interface{})
+	if !ok || len(entries) == 0 || len(entries[0]) < 2 {
+		return nil
+	}
+	path, _ := entries[0][0].(string)
+	argv, _ := entries[0][1].([]string)
+	if len(argv) == 0 {
+		return []string{path}
+	}
+	return append([]string{path}, argv[1:]...)
+}
+
+// flagValue finds a Go style flag in argv, ie: -name value, --name=value.
+func flagValue(argv []string, name string) string {
+	for i, arg := range argv {
+		trimmed := strings.TrimLeft(arg, "-")
+		if trimmed == arg {
+			continue
+		}
+		if trimmed == name && i+1 < len(argv) {
+			return argv[i+1]
+		}
+		if v, ok := strings.CutPrefix(trimmed, name+"="); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+// storePathOf trims a path within the nix store to the store path itself,
+// ie: /nix/store/<hash>-dkm/bin/dkm becomes /nix/store/<hash>-dkm. Paths
+// outside the store are returned as is.
+func storePathOf(path string) string {
+	rest, ok := strings.CutPrefix(path, "/nix/store/")
+	if !ok {
+		return path
+	}
+	if i := strings.Index(rest, "/"); i >= 0 {
+		return path[:len("/nix/store/")+i]
+	}
+	return path
+}
@@ -0,0 +1,132 @@
+package system
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type driftNixManager struct {
+	dogeboxd.NixManager
+	rebuilds int
+}
+
+func (m *driftNixManager) Rebuild(log dogeboxd.SubLogger) error {
+	m.rebuilds++
+	return nil
+}
+
+// stubDrift fakes a box where dkm.service was updated but not restarted.
+func stubDrift(t *testing.T, release string, installedRelease string) dogeboxd.ServerConfig {
+	versionDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(versionDir, "dbx"), []byte(release+"\n"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(versionDir, "dogeboxd"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(versionDir, "dogeboxd", "rev"), []byte("abc123"), 0644))
+	t.Setenv("VERSION_PATH_OVERRIDE", versionDir)
+
+	origProps, origCmdline, origExe, origRev, origRelease := getServiceProperties, readProcCmdline, runningExecutable, runningRevision, getInstalledOSRelease
+	t.Cleanup(func() {
+		getServiceProperties, readProcCmdline, runningExecutable, runningRevision, getInstalledOSRelease = origProps, origCmdline, origExe, origRev, origRelease
+	})
+
+	getServiceProperties = func(unit string) (map[string]interface{}, error) {
+		switch unit {
+		case "dogeboxd.service":
+			return map[string]interface{}{
+				"MainPID": uint32(100),
+				"ExecStart": [][]interface{}{{
+					"/nix/store/aaa-dogeboxd/bin/dogeboxd",
+					[]string{"/nix/store/aaa-dogeboxd/bin/dogeboxd", "--uidir", "/nix/store/aaa-dogeboxd/dpanel"},
+				}},
+			}, nil
+		default:
+			return map[string]interface{}{
+				"MainPID":   uint32(200),
+				"ExecStart": [][]interface{}{{"/nix/store/new-dkm/bin/dkm", []string{"/nix/store/new-dkm/bin/dkm"}}},
+			}, nil
+		}
+	}
+	readProcCmdline = func(pid uint32) ([]string, error) {
+		return []string{"/nix/store/old-dkm/bin/dkm"}, nil
+	}
+	runningExecutable = func() (string, error) { return "/nix/store/aaa-dogeboxd/bin/dogeboxd", nil }
+	runningRevision = func() string { return "unknown" }
+	getInstalledOSRelease = func() (string, error) { return installedRelease, nil }
+
+	return dogeboxd.ServerConfig{UiDir: "/nix/store/aaa-dogeboxd/dpanel"}
+}
+
+func TestCheckVersionDriftFlagsStaleService(t *testing.T) {
+	config := stubDrift(t, "v0.9.0", "v0.9.0")
+
+	report := CheckVersionDrift(config)
+
+	assert.True(t, report.Drifted)
+	assert.False(t, report.ReleaseDrifted)
+	assert.Empty(t, report.Errors)
+	require.Len(t, report.Components, 3)
+
+	drifted := map[string]dogeboxd.ComponentDrift{}
+	for _, c := range report.Components {
+		drifted[c.Name] = c
+	}
+	assert.False(t, drifted["dogeboxd"].Drifted)
+	assert.Equal(t, "abc123", drifted["dogeboxd"].PinnedRev)
+	assert.False(t, drifted["dpanel"].Drifted)
+	assert.True(t, drifted["dkm"].Drifted)
+	assert.Equal(t, "/nix/store/old-dkm", drifted["dkm"].RunningPath)
+	assert.Equal(t, "/nix/store/new-dkm", drifted["dkm"].InstalledPath)
+}
+
+func TestCheckVersionDriftFlagsReleaseMismatch(t *testing.T) {
+	config := stubDrift(t, "v0.8.0", "v0.9.0")
+	readProcCmdline = func(pid uint32) ([]string, error) {
+		return []string{"/nix/store/new-dkm/bin/dkm"}, nil
+	}
+
+	report := CheckVersionDrift(config)
+
+	assert.True(t, report.Drifted)
+	assert.True(t, report.ReleaseDrifted)
+	assert.Equal(t, "v0.8.0", report.Release)
+	assert.Equal(t, "v0.9.0", report.InstalledRelease)
+}
+
+func TestReapplySystemVersionRestartsDriftedServices(t *testing.T) {
+	config := stubDrift(t, "v0.8.0", "v0.9.0")
+	runningExecutable = func() (string, error) { return "/nix/store/old-dogeboxd/bin/dogeboxd", nil }
+
+	nix := &driftNixManager{}
+	runner := NewRecordingCommandRunner()
+	updater := SystemUpdater{config: config, nix: nix, runner: runner}
+
+	job := testRunnerJob(dogeboxd.PupState{ID: "abc"})
+	require.NoError(t, updater.reapplySystemVersion(job.Logger.Step("test")))
+
+	assert.Equal(t, 1, nix.rebuilds)
+	assert.Equal(t, []string{
+		"systemctl restart dkm.service",
+		"systemd-run --on-active=10s systemctl restart dogeboxd.service",
+	}, runner.Commands, runner.String())
+}
+
+func TestReapplySystemVersionWithoutDrift(t *testing.T) {
+	config := stubDrift(t, "v0.9.0", "v0.9.0")
+	readProcCmdline = func(pid uint32) ([]string, error) {
+		return []string{"/nix/store/new-dkm/bin/dkm"}, nil
+	}
+
+	nix := &driftNixManager{}
+	runner := NewRecordingCommandRunner()
+	updater := SystemUpdater{config: config, nix: nix, runner: runner}
+
+	job := testRunnerJob(dogeboxd.PupState{ID: "abc"})
+	require.NoError(t, updater.reapplySystemVersion(job.Logger.Step("test")))
+
+	assert.Zero(t, nix.rebuilds)
+	assert.Empty(t, runner.Commands)
+}
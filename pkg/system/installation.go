@@ -104,7 +104,8 @@ func mountAndCheckDiskForFile(t dogeboxd.Dogeboxd, config dogeboxd.ServerConfig,
 	}
 	defer os.RemoveAll(mountPoint) // Clean up temp directory
 
-	// Mount the device
+	// Mount the device. This runs from the installer, before the box's own
+	// config (and so rootd) exists, so it still goes via sudo.
 	mountCmd := exec.Command("sudo", "_dbxroot", "mount-disk", devicePath, mountPoint)
 	logToWebSocket(t, fmt.Sprintf("Attempting to mount device %s to %s with command: %s", devicePath, mountPoint, mountCmd.String()))
 
@@ -339,12 +340,46 @@ func IsDiskNixRoot(disk dogeboxd.SystemDisk) bool {
 	return false
 }
 
-func InitStorageDevice(dbxState dogeboxd.DogeboxState) (string, error) {
+/* CheckStorageMirrorDisk checks mirror can be mirrored with the storage
+ * disk primary. Both have to be usable, separate disks, as the boot
+ * media can't be reformatted into a mirror.
+ */
+func CheckStorageMirrorDisk(disks []dogeboxd.SystemDisk, primary dogeboxd.SystemDisk, mirror string) error {
+	if primary.BootMedia {
+		return fmt.Errorf("can't mirror the boot media, pick a separate storage device")
+	}
+	if mirror == primary.Name {
+		return fmt.Errorf("mirror device must be a different disk")
+	}
+	for _, disk := range disks {
+		if disk.Name != mirror {
+			continue
+		}
+		if disk.BootMedia || !disk.Suitability.Storage.Usable {
+			return fmt.Errorf("invalid mirror device")
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid mirror device")
+}
+
+/* InitStorageDevice formats the StorageDevice picked during setup, and
+ * returns the volume to mount as the data volume. With a
+ * StorageMirrorDevice both disks are made into one btrfs raid1 volume,
+ * and the second disk's partition is returned too.
+ */
+func InitStorageDevice(dbxState dogeboxd.DogeboxState) (dogeboxd.StorageVolume, error) {
 	if dbxState.StorageDevice == "" || dbxState.InitialState.HasFullyConfigured {
-		return "", nil
+		return dogeboxd.StorageVolume{}, nil
 	}
 
-	cmd := exec.Command("sudo", "_dbxroot", "prepare-storage-device", "--print", "--disk", dbxState.StorageDevice, "--dbx-secret", DBXRootSecret)
+	args := []string{"_dbxroot", "prepare-storage-device", "--print", "--disk", dbxState.StorageDevice, "--dbx-secret", DBXRootSecret}
+	if dbxState.StorageMirrorDevice != "" {
+		args = append(args, "--mirror-disk", dbxState.StorageMirrorDevice)
+	}
+	// Setup runs before the first rebuild has started rootd, so this
+	// still goes via sudo.
+	cmd := exec.Command("sudo", args...)
 
 	var out bytes.Buffer
 	cmd.Stdout = io.MultiWriter(&out, os.Stdout)
@@ -353,28 +388,52 @@ func InitStorageDevice(dbxState dogeboxd.DogeboxState) (string, error) {
 	// Execute the command
 	err := cmd.Run()
 	if err != nil {
-		return "", fmt.Errorf("failed to execute _dbxroot prepare-storage-device: %w", err)
+		return dogeboxd.StorageVolume{}, fmt.Errorf("failed to execute _dbxroot prepare-storage-device: %w", err)
+	}
+
+	volume := parsePreparedVolume(out.String())
+
+	if volume.Partition == "" {
+		return dogeboxd.StorageVolume{}, fmt.Errorf("failed to get partition name")
+	}
+	if dbxState.StorageMirrorDevice != "" && volume.MirrorPartition == "" {
+		return dogeboxd.StorageVolume{}, fmt.Errorf("failed to get mirror partition name")
+	}
+	if volume.UUID == "" {
+		return dogeboxd.StorageVolume{}, fmt.Errorf("failed to get volume uuid")
 	}
 
-	output := out.String()
+	return volume, nil
+}
 
+var (
+	preparedPartitionRegex       = regexp.MustCompile(`prepared partition: (.+)`)
+	preparedMirrorPartitionRegex = regexp.MustCompile(`prepared mirror partition: (.+)`)
+	preparedUUIDRegex            = regexp.MustCompile(`prepared volume uuid: ([0-9a-fA-F-]+)`)
+)
+
+// parsePreparedVolume finds the partitions and filesystem UUID
+// prepare-storage-device printed, the last of each winning.
+func parsePreparedVolume(output string) dogeboxd.StorageVolume {
 	lines := strings.Split(strings.TrimSpace(output), "\n")
-	partitionName := ""
-	re := regexp.MustCompile(`prepared partition: (.+)`)
+	volume := dogeboxd.StorageVolume{}
 	for i := len(lines) - 1; i >= 0; i-- {
 		line := lines[i]
-		submatch := re.FindSubmatch([]byte(line))
-		if len(submatch) == 2 {
-			partitionName = string(submatch[1])
-			break
+		if submatch := preparedMirrorPartitionRegex.FindStringSubmatch(line); len(submatch) == 2 {
+			if volume.MirrorPartition == "" {
+				volume.MirrorPartition = submatch[1]
+			}
+		} else if submatch := preparedPartitionRegex.FindStringSubmatch(line); len(submatch) == 2 {
+			if volume.Partition == "" {
+				volume.Partition = submatch[1]
+			}
+		} else if submatch := preparedUUIDRegex.FindStringSubmatch(line); len(submatch) == 2 {
+			if volume.UUID == "" {
+				volume.UUID = submatch[1]
+			}
 		}
 	}
-
-	if partitionName == "" {
-		return "", fmt.Errorf("failed to get partition name")
-	}
-
-	return partitionName, nil
+	return volume
 }
 
 func GetBuildType() (string, error) {
@@ -475,6 +534,8 @@ func (w *lineStreamWriter) Write(p []byte) (n int, err error) {
 	return len(p), nil
 }
 
+// dbxrootInstallToDisk runs from the installer media, which never runs
+// rootd, so it calls _dbxroot via sudo directly.
 func dbxrootInstallToDisk(disk string, t dogeboxd.Dogeboxd, buildType string) error {
 	cmd := exec.Command("sudo", "_dbxroot", "install-to-disk", "--variant", buildType, "--disk", disk, "--dbx-secret", DBXRootSecret)
 	cmd.Stdout = newLineStreamWriter(t, "install-output")
@@ -75,3 +75,57 @@ func TestIsInstallTargetDisk(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckStorageMirrorDisk(t *testing.T) {
+	usable := dogeboxd.SystemDiskSuitability{Storage: dogeboxd.SystemDiskSuitabilityEntry{Usable: true}}
+	boot := dogeboxd.SystemDisk{Name: "/dev/mmcblk0", BootMedia: true, Suitability: usable}
+	sda := dogeboxd.SystemDisk{Name: "/dev/sda", Suitability: usable}
+	sdb := dogeboxd.SystemDisk{Name: "/dev/sdb", Suitability: usable}
+	small := dogeboxd.SystemDisk{Name: "/dev/sdc"}
+	disks := []dogeboxd.SystemDisk{boot, sda, sdb, small}
+
+	tests := []struct {
+		name    string
+		primary dogeboxd.SystemDisk
+		mirror  string
+		wantErr bool
+	}{
+		{name: "allows two usable disks", primary: sda, mirror: "/dev/sdb"},
+		{name: "rejects mirroring the boot media", primary: boot, mirror: "/dev/sda", wantErr: true},
+		{name: "rejects mirroring onto the boot media", primary: sda, mirror: "/dev/mmcblk0", wantErr: true},
+		{name: "rejects mirroring a disk onto itself", primary: sda, mirror: "/dev/sda", wantErr: true},
+		{name: "rejects unusable disks", primary: sda, mirror: "/dev/sdc", wantErr: true},
+		{name: "rejects unknown disks", primary: sda, mirror: "/dev/sdz", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckStorageMirrorDisk(disks, tt.primary, tt.mirror)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckStorageMirrorDisk() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParsePreparedVolume(t *testing.T) {
+	output := "Finished preparing storage device.\n" +
+		"2024/06/01 prepared mirror partition: /dev/sdc1\n" +
+		"2024/06/01 prepared volume uuid: 0b1c2d3e-4f50-6172-8394-a5b6c7d8e9f0\n" +
+		"2024/06/01 prepared partition: /dev/sdb1\n"
+
+	volume := parsePreparedVolume(output)
+	want := dogeboxd.StorageVolume{
+		Partition:       "/dev/sdb1",
+		MirrorPartition: "/dev/sdc1",
+		UUID:            "0b1c2d3e-4f50-6172-8394-a5b6c7d8e9f0",
+	}
+	if volume != want {
+		t.Fatalf("parsePreparedVolume() = %+v, want %+v", volume, want)
+	}
+
+	volume = parsePreparedVolume("prepared partition: /dev/nvme0n1p1")
+	if volume.Partition != "/dev/nvme0n1p1" || volume.MirrorPartition != "" || volume.UUID != "" {
+		t.Fatalf("parsePreparedVolume() = %+v", volume)
+	}
+}
@@ -0,0 +1,121 @@
+package system
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/Dogebox-WG/dogeboxd/pkg/version"
+	dbus "github.com/coreos/go-systemd/v22/dbus"
+)
+
+type inventoryService struct {
+	name     string
+	unit     string
+	optional bool
+}
+
+// Host services reported in the system inventory. Optional services are
+// only listed when they're installed on this box.
+var inventoryServices = []inventoryService{
+	{name: "dogeboxd", unit: "dogeboxd.service"},
+	{name: "dkm", unit: "dkm.service"},
+	// dpanel is served by dogeboxd itself, so shares its unit.
+	{name: "dpanel", unit: "dogeboxd.service"},
+	{name: "tor", unit: "tor.service", optional: true},
+	{name: "tailscale", unit: "tailscaled.service", optional: true},
+	{name: "sshd", unit: "sshd.service", optional: true},
+}
+
+const nixosVersionPath = "/run/current-system/nixos-version"
+
+// Swappable for tests.
+var getUnitProperties = func(units []string) (map[string]map[string]interface{}, error) {
+	conn, err := dbus.NewWithContext(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	out := map[string]map[string]interface{}{}
+	for _, unit := range units {
+		props, err := conn.GetUnitPropertiesContext(context.Background(), unit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get properties for %s: %w", unit, err)
+		}
+		out[unit] = props
+	}
+	return out, nil
+}
+
+// GetSystemInventory collects installed packages, version pins and core
+// service status. Sections that fail are left empty and noted in Errors,
+// as a partial inventory is still useful for triage.
+func GetSystemInventory(nix dogeboxd.NixManager) dogeboxd.SystemInventory {
+	release := version.GetDBXRelease()
+	inventory := dogeboxd.SystemInventory{
+		Release:  release.Release,
+		Git:      release.Git,
+		Pins:     release.Packages,
+		Packages: []dogeboxd.SystemPackage{},
+		Services: []dogeboxd.SystemServiceStatus{},
+	}
+
+	if b, err := os.ReadFile(nixosVersionPath); err == nil {
+		inventory.NixOSVersion = strings.TrimSpace(string(b))
+	} else {
+		inventory.Errors = append(inventory.Errors, fmt.Sprintf("nixos version: %v", err))
+	}
+
+	if packages, err := nix.ListSystemPackages(); err == nil {
+		inventory.Packages = packages
+	} else {
+		inventory.Errors = append(inventory.Errors, fmt.Sprintf("packages: %v", err))
+	}
+
+	services, err := getInventoryServiceStatus()
+	if err != nil {
+		inventory.Errors = append(inventory.Errors, fmt.Sprintf("services: %v", err))
+	}
+	inventory.Services = services
+
+	return inventory
+}
+
+func getInventoryServiceStatus() ([]dogeboxd.SystemServiceStatus, error) {
+	units := []string{}
+	for _, s := range inventoryServices {
+		units = append(units, s.unit)
+	}
+
+	props, err := getUnitProperties(units)
+	if err != nil {
+		return []dogeboxd.SystemServiceStatus{}, err
+	}
+
+	out := []dogeboxd.SystemServiceStatus{}
+	for _, s := range inventoryServices {
+		p := props[s.unit]
+		status := dogeboxd.SystemServiceStatus{
+			Name:     s.name,
+			Unit:     s.unit,
+			Optional: s.optional,
+		}
+		status.LoadState, _ = p["LoadState"].(string)
+		status.ActiveState, _ = p["ActiveState"].(string)
+		status.SubState, _ = p["SubState"].(string)
+		if ts, ok := p["ActiveEnterTimestamp"].(uint64); ok && ts > 0 {
+			since := time.UnixMicro(int64(ts))
+			status.ActiveSince = &since
+		}
+
+		if s.optional && status.LoadState != "loaded" {
+			continue
+		}
+		out = append(out, status)
+	}
+	return out, nil
+}
@@ -0,0 +1,46 @@
+package system
+
+import (
+	"testing"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type inventoryNixManager struct {
+	dogeboxd.NixManager
+}
+
+func (inventoryNixManager) ListSystemPackages() ([]dogeboxd.SystemPackage, error) {
+	return []dogeboxd.SystemPackage{{Name: "git", Version: "2.44.0"}}, nil
+}
+
+func TestGetSystemInventorySkipsMissingOptionalServices(t *testing.T) {
+	orig := getUnitProperties
+	defer func() { getUnitProperties = orig }()
+	getUnitProperties = func(units []string) (map[string]map[string]interface{}, error) {
+		out := map[string]map[string]interface{}{}
+		for _, u := range units {
+			out[u] = map[string]interface{}{"LoadState": "not-found", "ActiveState": "inactive", "SubState": "dead"}
+		}
+		out["dogeboxd.service"] = map[string]interface{}{"LoadState": "loaded", "ActiveState": "active", "SubState": "running", "ActiveEnterTimestamp": uint64(1700000000000000)}
+		out["tor.service"] = map[string]interface{}{"LoadState": "loaded", "ActiveState": "failed", "SubState": "failed"}
+		return out, nil
+	}
+
+	inventory := GetSystemInventory(inventoryNixManager{})
+
+	assert.Equal(t, []dogeboxd.SystemPackage{{Name: "git", Version: "2.44.0"}}, inventory.Packages)
+
+	names := []string{}
+	for _, s := range inventory.Services {
+		names = append(names, s.Name)
+	}
+	assert.Equal(t, []string{"dogeboxd", "dkm", "dpanel", "tor"}, names)
+
+	require.NotNil(t, inventory.Services[0].ActiveSince)
+	assert.Equal(t, "running", inventory.Services[0].SubState)
+	// dkm isn't optional, so is reported even when missing.
+	assert.Equal(t, "not-found", inventory.Services[1].LoadState)
+}
@@ -2,9 +2,10 @@ package lifecycle
 
 import (
 	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/Dogebox-WG/dogeboxd/pkg/system"
 )
 
 func NewLifecycleManager(config dogeboxd.ServerConfig) dogeboxd.LifecycleManager {
 	// TODO: Do some discovery
-	return LifecycleManagerLinux{config: config}
+	return LifecycleManagerLinux{config: config, runner: system.NewCommandRunner(config)}
 }
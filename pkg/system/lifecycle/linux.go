@@ -4,15 +4,17 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
 
 	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/Dogebox-WG/dogeboxd/pkg/rootd"
+	"github.com/Dogebox-WG/dogeboxd/pkg/system"
 )
 
 var _ dogeboxd.LifecycleManager = &LifecycleManagerLinux{}
 
 type LifecycleManagerLinux struct {
 	config dogeboxd.ServerConfig
+	runner system.CommandRunner
 }
 
 func (t LifecycleManagerLinux) Reboot() {
@@ -22,8 +24,7 @@ func (t LifecycleManagerLinux) Reboot() {
 		return
 	}
 
-	cmd := exec.Command("sudo", "_dbxroot", "reboot")
-	if err := cmd.Run(); err != nil {
+	if _, err := t.runner.CombinedOutput(nil, rootd.Reboot{}); err != nil {
 		fmt.Printf("Failed to execute reboot command: %v\n", err)
 	}
 }
@@ -35,8 +36,7 @@ func (t LifecycleManagerLinux) Shutdown() {
 		return
 	}
 
-	cmd := exec.Command("sudo", "_dbxroot", "shutdown")
-	if err := cmd.Run(); err != nil {
+	if _, err := t.runner.CombinedOutput(nil, rootd.Shutdown{}); err != nil {
 		fmt.Printf("Failed to execute reboot command: %v\n", err)
 	}
 }
@@ -0,0 +1,261 @@
+package system
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+// How much of the log a single query page reads before returning what
+// it has, so a sparse filter over a huge log can't hold a request up.
+// The page's NextCursor picks up from there.
+var logQueryMaxScanBytes int64 = 16 << 20
+
+// How close the search for Since gets before scanning line by line.
+const logQuerySeekWindow int64 = 64 << 10
+
+/* Query returns a page of logFile matching q, oldest first, starting in
+ * the logs rotated out of it, see dogeboxd.RotatedLogs. Exported
+ * container logs are appended in time order, so a Since without a
+ * cursor skips rotated logs from before it, and is found in the live
+ * log by bisecting the file rather than reading it all. Lines without a
+ * timestamp take the time of the line before them, which keeps
+ * multi-line messages together.
+ *
+ * Cursors are an offset into the live log, or a rotated log's timestamp
+ * and an offset into it once decompressed.
+ */
+func (t LogTailer) Query(logFile string, q dogeboxd.LogQuery) (dogeboxd.LogQueryPage, error) {
+	page := dogeboxd.LogQueryPage{Entries: []dogeboxd.LogEntry{}}
+	if err := q.Validate(); err != nil {
+		return page, err
+	}
+
+	rotated, err := dogeboxd.RotatedLogs(logFile)
+	if err != nil {
+		return page, err
+	}
+
+	// The rotated log to start in, len(rotated) for the live log.
+	first, offset := 0, int64(0)
+	switch {
+	case q.Cursor != nil:
+		first, offset, err = parseLogQueryCursor(logFile, *q.Cursor, rotated)
+		if err != nil {
+			return page, err
+		}
+	case q.Since != nil:
+		for first < len(rotated) && rotated[first].Rotated.Before(*q.Since) {
+			first++
+		}
+	}
+
+	scan := logQueryScan{q: q, page: &page}
+	for i := first; i < len(rotated); i++ {
+		done, err := scan.rotated(logFile, rotated[i], offset)
+		if err != nil || done {
+			return page, err
+		}
+		offset = 0
+	}
+
+	seek := q.Cursor == nil && q.Since != nil
+	_, err = scan.live(logFile, offset, seek)
+	return page, err
+}
+
+type logQueryScan struct {
+	q        dogeboxd.LogQuery
+	page     *dogeboxd.LogQueryPage
+	scanned  int64
+	lastTime *time.Time
+}
+
+// rotated scans a rotated log from offset, returning whether the page
+// is done.
+func (s *logQueryScan) rotated(logFile string, l dogeboxd.RotatedLog, offset int64) (bool, error) {
+	r, err := dogeboxd.OpenRotatedLog(l)
+	if os.IsNotExist(err) {
+		// Pruned since we listed it.
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer r.Close()
+
+	if _, err := io.CopyN(io.Discard, r, offset); err != nil {
+		return false, fmt.Errorf("invalid log query cursor")
+	}
+	stamp := rotatedLogStamp(logFile, l)
+	return s.lines(bufio.NewReader(r), offset, func(offset int64) *string {
+		// Always more to come, in the live log if nowhere else.
+		cursor := stamp + ":" + strconv.FormatInt(offset, 10)
+		return &cursor
+	})
+}
+
+// live scans the live log from offset, or from Since if seek is set.
+func (s *logQueryScan) live(logFile string, offset int64, seek bool) (bool, error) {
+	file, err := os.Open(logFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+	if offset > stat.Size() {
+		return false, fmt.Errorf("invalid log query cursor")
+	}
+	if seek {
+		offset, err = seekLogTime(file, stat.Size(), *s.q.Since)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return false, err
+	}
+	return s.lines(bufio.NewReader(file), offset, func(offset int64) *string {
+		if offset >= stat.Size() {
+			return nil
+		}
+		cursor := strconv.FormatInt(offset, 10)
+		return &cursor
+	})
+}
+
+// lines reads reader, which is at offset in its log, into the page until
+// it's full, past Until or has read logQueryMaxScanBytes, returning
+// whether it's done. cursor gives the NextCursor for an offset.
+func (s *logQueryScan) lines(reader *bufio.Reader, offset int64, cursor func(offset int64) *string) (bool, error) {
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return false, err
+		}
+		if len(line) == 0 || !strings.HasSuffix(line, "\n") {
+			// The end, or a line still being written.
+			return false, nil
+		}
+		offset += int64(len(line))
+		s.scanned += int64(len(line))
+
+		entry := dogeboxd.ParseContainerLogLine(strings.TrimRight(line, "\r\n"))
+		if entry.Time == nil {
+			entry.Time = s.lastTime
+		}
+		s.lastTime = entry.Time
+
+		if s.q.Until != nil && entry.Time != nil && entry.Time.After(*s.q.Until) {
+			return true, nil
+		}
+
+		if s.q.Matches(entry) {
+			s.page.Entries = append(s.page.Entries, entry)
+		}
+		if len(s.page.Entries) >= s.q.Limit || s.scanned >= logQueryMaxScanBytes {
+			s.page.NextCursor = cursor(offset)
+			return true, nil
+		}
+	}
+}
+
+func rotatedLogStamp(logFile string, l dogeboxd.RotatedLog) string {
+	return strings.TrimSuffix(strings.TrimPrefix(l.Path, logFile+"-"), ".gz")
+}
+
+// parseLogQueryCursor finds where a cursor left off. A rotated log that
+// has since been pruned continues from the next one.
+func parseLogQueryCursor(logFile string, cursor string, rotated []dogeboxd.RotatedLog) (int, int64, error) {
+	stamp, rawOffset, ok := strings.Cut(cursor, ":")
+	if !ok {
+		stamp, rawOffset = "", cursor
+	}
+	offset, err := strconv.ParseInt(rawOffset, 10, 64)
+	if err != nil || offset < 0 {
+		return 0, 0, fmt.Errorf("invalid log query cursor")
+	}
+	if stamp == "" {
+		return len(rotated), offset, nil
+	}
+
+	for i, l := range rotated {
+		switch s := rotatedLogStamp(logFile, l); {
+		case s == stamp:
+			return i, offset, nil
+		case s > stamp:
+			return i, 0, nil
+		}
+	}
+	return len(rotated), 0, nil
+}
+
+// seekLogTime finds an offset at or shortly before the first line logged
+// at or after since.
+func seekLogTime(file *os.File, size int64, since time.Time) (int64, error) {
+	lo, hi := int64(0), size
+	for hi-lo > logQuerySeekWindow {
+		mid := lo + (hi-lo)/2
+		lineStart, lineTime, err := firstLogTimeAfter(file, mid, hi)
+		if err != nil {
+			return 0, err
+		}
+		if lineTime == nil || !lineTime.Before(since) {
+			hi = mid
+		} else {
+			lo = lineStart
+		}
+	}
+	return lo, nil
+}
+
+// firstLogTimeAfter returns the start and time of the first timestamped
+// line beginning after offset and before end, or a nil time if there
+// isn't one within the seek window.
+func firstLogTimeAfter(file *os.File, offset int64, end int64) (int64, *time.Time, error) {
+	size := logQuerySeekWindow
+	if offset+size > end {
+		size = end - offset
+	}
+	buf := make([]byte, size)
+	n, err := file.ReadAt(buf, offset)
+	if err != nil && err != io.EOF {
+		return 0, nil, err
+	}
+	buf = buf[:n]
+
+	// Skip the rest of the line we landed in.
+	idx := bytes.IndexByte(buf, '\n')
+	if idx < 0 {
+		return 0, nil, nil
+	}
+	pos := idx + 1
+
+	for pos < len(buf) {
+		next := bytes.IndexByte(buf[pos:], '\n')
+		if next < 0 {
+			break
+		}
+		entry := dogeboxd.ParseContainerLogLine(string(buf[pos : pos+next]))
+		if entry.Time != nil {
+			return offset + int64(pos), entry.Time, nil
+		}
+		pos += next + 1
+	}
+	return 0, nil, nil
+}
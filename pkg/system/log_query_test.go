@@ -0,0 +1,124 @@
+package system
+
+import (
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeContainerLog writes a log with a line a minute from start, every
+// tenth one an error.
+func writeContainerLog(t *testing.T, start time.Time, count int) string {
+	lines := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		msg := fmt.Sprintf("block %d", i)
+		if i%10 == 0 {
+			msg = fmt.Sprintf("ERROR: block %d failed", i)
+		}
+		lines = append(lines, fmt.Sprintf("%s dogecoind[42]: %s", start.Add(time.Duration(i)*time.Minute).Format("2006-01-02T15:04:05-0700"), msg))
+	}
+	logPath := filepath.Join(t.TempDir(), "pup-abc")
+	require.NoError(t, os.WriteFile(logPath, []byte(strings.Join(lines, "\n")+"\n"), 0o644))
+	return logPath
+}
+
+func TestLogTailerQueryByTimeRange(t *testing.T) {
+	start := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+	logPath := writeContainerLog(t, start, 5000)
+
+	since := start.Add(3 * time.Hour)
+	until := since.Add(9 * time.Minute)
+	page, err := NewLogTailer().Query(logPath, dogeboxd.LogQuery{Since: &since, Until: &until, Limit: 100})
+	require.NoError(t, err)
+
+	require.Len(t, page.Entries, 10)
+	assert.Contains(t, page.Entries[0].Line, "block 180 failed")
+	assert.Equal(t, dogeboxd.LOG_SEVERITY_ERROR, page.Entries[0].Severity)
+	assert.Contains(t, page.Entries[9].Line, "block 189")
+	assert.Nil(t, page.NextCursor)
+}
+
+func TestLogTailerQueryPaginatesBySeverity(t *testing.T) {
+	start := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+	logPath := writeContainerLog(t, start, 100)
+	tailer := NewLogTailer()
+
+	q := dogeboxd.LogQuery{MinSeverity: dogeboxd.LOG_SEVERITY_ERROR, Limit: 4}
+	first, err := tailer.Query(logPath, q)
+	require.NoError(t, err)
+	require.Len(t, first.Entries, 4)
+	require.NotNil(t, first.NextCursor)
+	assert.Contains(t, first.Entries[3].Line, "block 30 failed")
+
+	q.Cursor = first.NextCursor
+	second, err := tailer.Query(logPath, q)
+	require.NoError(t, err)
+	require.Len(t, second.Entries, 4)
+	assert.Contains(t, second.Entries[0].Line, "block 40 failed")
+
+	q.Cursor = second.NextCursor
+	last, err := tailer.Query(logPath, q)
+	require.NoError(t, err)
+	require.Len(t, last.Entries, 2)
+	assert.Nil(t, last.NextCursor)
+}
+
+func TestLogTailerQueryMissingLog(t *testing.T) {
+	page, err := NewLogTailer().Query(filepath.Join(t.TempDir(), "missing"), dogeboxd.LogQuery{Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, page.Entries)
+}
+
+func TestLogTailerQueryReadsRotatedLogs(t *testing.T) {
+	start := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+	logPath := writeContainerLog(t, start, 100)
+	data, err := os.ReadFile(logPath)
+	require.NoError(t, err)
+	lines := strings.SplitAfter(string(data), "\n")
+
+	// The first 60 lines were rotated out into a backup at 01:00.
+	backup, err := os.Create(logPath + "-2026-10-17T01-00-00.000.gz")
+	require.NoError(t, err)
+	gz := gzip.NewWriter(backup)
+	_, err = gz.Write([]byte(strings.Join(lines[:60], "")))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	require.NoError(t, backup.Close())
+	require.NoError(t, os.WriteFile(logPath, []byte(strings.Join(lines[60:], "")), 0o644))
+
+	tailer := NewLogTailer()
+	q := dogeboxd.LogQuery{MinSeverity: dogeboxd.LOG_SEVERITY_ERROR, Limit: 4}
+	first, err := tailer.Query(logPath, q)
+	require.NoError(t, err)
+	require.Len(t, first.Entries, 4)
+	assert.Contains(t, first.Entries[0].Line, "block 0 failed")
+	require.NotNil(t, first.NextCursor)
+
+	q.Cursor = first.NextCursor
+	second, err := tailer.Query(logPath, q)
+	require.NoError(t, err)
+	require.Len(t, second.Entries, 4)
+	assert.Contains(t, second.Entries[0].Line, "block 40 failed")
+	assert.Contains(t, second.Entries[3].Line, "block 70 failed")
+
+	q.Cursor = second.NextCursor
+	last, err := tailer.Query(logPath, q)
+	require.NoError(t, err)
+	require.Len(t, last.Entries, 2)
+	assert.Nil(t, last.NextCursor)
+
+	// Since skips backups rotated before it.
+	since := start.Add(75 * time.Minute)
+	page, err := tailer.Query(logPath, dogeboxd.LogQuery{Since: &since, Limit: 100})
+	require.NoError(t, err)
+	require.Len(t, page.Entries, 25)
+	assert.Contains(t, page.Entries[0].Line, "block 75")
+}
@@ -60,6 +60,7 @@ func (t LogTailer) GetChannelFromOffset(logFile string, startOffset int64) (cont
 		}
 
 		reader := bufio.NewReader(file)
+		pos := offset
 
 		for {
 			select {
@@ -68,8 +69,20 @@ func (t LogTailer) GetChannelFromOffset(logFile string, startOffset int64) (cont
 				return
 			default:
 				line, err := reader.ReadString('\n')
+				pos += int64(len(line))
 				if err != nil {
 					if err == io.EOF {
+						// Pup logs are truncated in place when they're
+						// rotated, so start again from the top.
+						if truncated(file, pos) {
+							if _, err := file.Seek(0, io.SeekStart); err != nil {
+								close(out)
+								return
+							}
+							reader.Reset(file)
+							pos = 0
+							continue
+						}
 						time.Sleep(100 * time.Millisecond)
 						continue
 					}
@@ -163,6 +176,12 @@ func waitForLogFile(logFile string) (*os.File, error) {
 	return nil, err
 }
 
+// truncated is whether file has shrunk below what's already been read.
+func truncated(file *os.File, pos int64) bool {
+	stat, err := file.Stat()
+	return err == nil && stat.Size() < pos
+}
+
 func resolveStartOffset(file *os.File, requestedOffset int64) (int64, error) {
 	stat, err := file.Stat()
 	if err != nil {
@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -72,6 +73,37 @@ func TestLogTailerGetPageHandlesBeforeStartOfFile(t *testing.T) {
 	assert.False(t, page.HasMoreOlder)
 }
 
+func TestLogTailerFollowsTruncatedLog(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "pup-test-pup")
+	require.NoError(t, os.WriteFile(logPath, []byte("old-1\nold-2\n"), 0o644))
+
+	cancel, lines, err := NewLogTailer().GetChannelFromOffset(logPath, 0)
+	require.NoError(t, err)
+	defer cancel()
+
+	next := func() string {
+		select {
+		case line := <-lines:
+			return line
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a log line")
+			return ""
+		}
+	}
+	assert.Equal(t, "old-1\n", next())
+	assert.Equal(t, "old-2\n", next())
+
+	// As PupLogRotator does, truncate in place and keep appending.
+	require.NoError(t, os.Truncate(logPath, 0))
+	f, err := os.OpenFile(logPath, os.O_APPEND|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	_, err = f.WriteString("new\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	assert.Equal(t, "new\n", next())
+}
+
 func parseOffset(t *testing.T, offset *string) *int64 {
 	t.Helper()
 	require.NotNil(t, offset)
@@ -192,6 +192,13 @@ func getStatus(serviceNames []string) (map[string]dogeboxd.ProcStatus, error) {
 			}
 		}
 
+		var activeSince time.Time
+		if p, err := conn.GetServicePropertyContext(ctx, service, "ActiveEnterTimestamp"); err == nil {
+			if v, ok := p.Value.Value().(uint64); ok && v > 0 {
+				activeSince = time.UnixMicro(int64(v))
+			}
+		}
+
 		cpu := float64(0)
 		mem := float64(0)
 		rssM := float64(0)
@@ -225,6 +232,7 @@ func getStatus(serviceNames []string) (map[string]dogeboxd.ProcStatus, error) {
 			Running:     running,
 			ActiveState: activeState,
 			SubState:    subState,
+			ActiveSince: activeSince,
 		}
 	}
 
@@ -0,0 +1,148 @@
+package network
+
+import (
+	"crypto/rand"
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/mdlayher/wifi"
+)
+
+// SSIDs can be at most 32 bytes long.
+const maxSSIDLength = 32
+
+const recoveryAPSSIDSuffix = "-setup"
+
+// RecoveryAPSSID returns the SSID broadcast in AP mode, based on the box's
+// hostname so multiple dogeboxes on the same desk can be told apart.
+func RecoveryAPSSID(hostname string) string {
+	hostname = strings.TrimSpace(hostname)
+	if hostname == "" {
+		hostname = "dogebox"
+	}
+
+	if len(hostname)+len(recoveryAPSSIDSuffix) > maxSSIDLength {
+		hostname = hostname[:maxSSIDLength-len(recoveryAPSSIDSuffix)]
+	}
+
+	return hostname + recoveryAPSSIDSuffix
+}
+
+// Hosts we try to reach to decide whether we're online, besides our gateway.
+var connectivityCheckHosts = []string{"1.1.1.1:53", "8.8.8.8:53", "9.9.9.9:53"}
+
+const connectivityCheckTimeout = 5 * time.Second
+
+// HasConnectivity is true if our default gateway or any of the
+// connectivityCheckHosts answers, so a flaky upstream or DNS doesn't look
+// like we've been disconnected.
+func (t NetworkManagerLinux) HasConnectivity() bool {
+	if gateway, err := defaultGateway(); err == nil && isReachable(net.JoinHostPort(gateway.String(), "53")) {
+		return true
+	}
+	for _, host := range connectivityCheckHosts {
+		if isReachable(host) {
+			return true
+		}
+	}
+	return false
+}
+
+// isReachable is true if something answers at addr, even if only to
+// refuse the connection.
+func isReachable(addr string) bool {
+	conn, err := net.DialTimeout("tcp", addr, connectivityCheckTimeout)
+	if err == nil {
+		conn.Close()
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// defaultGateway reads the IPv4 default route from /proc/net/route.
+func defaultGateway() (net.IP, error) {
+	routes, err := os.ReadFile("/proc/net/route")
+	if err != nil {
+		return nil, err
+	}
+	return parseDefaultGateway(string(routes))
+}
+
+func parseDefaultGateway(routes string) (net.IP, error) {
+	for _, line := range strings.Split(routes, "\n")[1:] {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[1] != "00000000" {
+			continue
+		}
+		gateway, err := strconv.ParseUint(fields[2], 16, 32)
+		if err != nil || gateway == 0 {
+			continue
+		}
+		// The kernel writes the address in host (little endian) byte order.
+		return net.IPv4(byte(gateway), byte(gateway>>8), byte(gateway>>16), byte(gateway>>24)), nil
+	}
+	return nil, errors.New("no default route")
+}
+
+// NewRecoveryAPPassphrase generates a WPA2 passphrase for the recovery
+// access point, avoiding characters that are easy to misread.
+func NewRecoveryAPPassphrase() (string, error) {
+	const alphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = alphabet[int(b[i])%len(alphabet)]
+	}
+	return string(b), nil
+}
+
+func (t NetworkManagerLinux) EnableAPMode(nixPatch dogeboxd.NixPatch, dbxState dogeboxd.DogeboxState) (string, error) {
+	if len(dbxState.APMode.Passphrase) < 8 {
+		return "", errors.New("the access point needs a passphrase of at least 8 characters")
+	}
+
+	iface, err := findAPCapableInterface()
+	if err != nil {
+		return "", err
+	}
+
+	ssid := RecoveryAPSSID(dbxState.Hostname)
+
+	t.nix.UpdateRecoveryAP(nixPatch, iface, ssid, dbxState)
+	return ssid, nil
+}
+
+func (t NetworkManagerLinux) DisableAPMode(nixPatch dogeboxd.NixPatch) {
+	t.nix.RemoveRecoveryAP(nixPatch)
+}
+
+func findAPCapableInterface() (string, error) {
+	wifiClient, err := wifi.New()
+	if err != nil {
+		return "", err
+	}
+	defer wifiClient.Close()
+
+	wifiInterfaces, err := wifiClient.Interfaces()
+	if err != nil {
+		return "", err
+	}
+
+	for _, wifiInterface := range wifiInterfaces {
+		// Skip any AP interfaces create_ap has already made.
+		if wifiInterface.Name == "" || strings.HasPrefix(wifiInterface.Name, "ap") {
+			continue
+		}
+		return wifiInterface.Name, nil
+	}
+
+	return "", errors.New("no wifi interface available for AP mode")
+}
@@ -4,14 +4,20 @@ import (
 	"log"
 
 	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/Dogebox-WG/dogeboxd/pkg/rootd"
 )
 
-func NewNetworkConnector(network dogeboxd.SelectedNetwork) dogeboxd.NetworkConnector {
+// opRunner runs privileged ops, see system.CommandRunner.
+type opRunner interface {
+	CombinedOutput(log dogeboxd.SubLogger, op rootd.Op) ([]byte, error)
+}
+
+func NewNetworkConnector(network dogeboxd.SelectedNetwork, runner opRunner) dogeboxd.NetworkConnector {
 	switch network.(type) {
 	case dogeboxd.SelectedNetworkEthernet:
 		return NetworkConnectorEthernet{}
 	case dogeboxd.SelectedNetworkWifi:
-		return NetworkConnectorWPASupplicant{}
+		return NetworkConnectorWPASupplicant{runner: runner}
 	default:
 		log.Fatalf("No network connector specified for network: %+v", network)
 		return nil
@@ -1,16 +1,17 @@
 package network_connector
 
 import (
-	"bytes"
 	"errors"
-	"os/exec"
 
 	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/Dogebox-WG/dogeboxd/pkg/rootd"
 )
 
 var _ dogeboxd.NetworkConnector = &NetworkConnectorWPASupplicant{}
 
-type NetworkConnectorWPASupplicant struct{}
+type NetworkConnectorWPASupplicant struct {
+	runner opRunner
+}
 
 func (t NetworkConnectorWPASupplicant) Connect(network dogeboxd.SelectedNetwork) error {
 	switch network.(type) {
@@ -27,10 +28,7 @@ func (t NetworkConnectorWPASupplicant) Connect(network dogeboxd.SelectedNetwork)
 	password := n.Password
 
 	// Prepare wpa_supplicant command with network information
-	cmd := exec.Command("sudo", "_dbxroot", "wifi-test", "--interface", iface, "--ssid", ssid, "--password", password)
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	err := cmd.Run()
+	_, err := t.runner.CombinedOutput(nil, rootd.WifiTest{Interface: iface, SSID: ssid, Password: password})
 
 	return err
 }
@@ -22,6 +22,7 @@ type NetworkManagerLinux struct {
 	dogeboxd.NetworkManager
 
 	sm      dogeboxd.StateManager
+	runner  OpRunner
 	scanner network_wifi.WifiScanner
 	nix     dogeboxd.NixManager
 }
@@ -153,6 +154,14 @@ func (t NetworkManagerLinux) SetPendingNetwork(selectedNetwork dogeboxd.Selected
 		log.Errf("Unknown network type: %T", selectedNetwork)
 	}
 
+	static, proxy := dogeboxd.SelectedNetworkOptions(selectedNetwork)
+	if static != nil {
+		log.Logf("Using static address %s via %s, DNS: %s", static.Address, static.Gateway, strings.Join(static.DNS, ", "))
+	}
+	if proxy != nil {
+		log.Logf("Using an HTTP(S) proxy, bypassed for: %s", proxy.NoProxy)
+	}
+
 	allInterfaces, err := net.Interfaces()
 	if err != nil {
 		log.Errf("Failed to fetch system interfaces: %s", err)
@@ -183,7 +192,7 @@ func (t NetworkManagerLinux) TestConnect() error {
 		return errors.New("no pending network to connect to")
 	}
 
-	connector := network_connector.NewNetworkConnector(state.PendingNetwork)
+	connector := network_connector.NewNetworkConnector(state.PendingNetwork, t.runner)
 
 	return connector.Connect(state.PendingNetwork)
 }
@@ -195,7 +204,7 @@ func (t NetworkManagerLinux) TryConnect(nixPatch dogeboxd.NixPatch) error {
 		return errors.New("no pending network to connect to")
 	}
 
-	connector := network_connector.NewNetworkConnector(state.PendingNetwork)
+	connector := network_connector.NewNetworkConnector(state.PendingNetwork, t.runner)
 
 	err := connector.Connect(state.PendingNetwork)
 	if err != nil {
@@ -212,6 +221,16 @@ func (t NetworkManagerLinux) TryConnect(nixPatch dogeboxd.NixPatch) error {
 
 	persistor.Persist(nixPatch, state.PendingNetwork)
 
+	// We've got a working network again, so the recovery AP can go away.
+	dbxState := t.sm.Get().Dogebox
+	if dbxState.APMode.Enabled {
+		t.DisableAPMode(nixPatch)
+		dbxState.APMode = dogeboxd.DogeboxStateAPMode{}
+		if err := t.sm.SetDogebox(dbxState); err != nil {
+			return err
+		}
+	}
+
 	// Swap out pending for current.
 	state.CurrentNetwork = state.PendingNetwork
 	state.PendingNetwork = nil
@@ -2,14 +2,22 @@ package network
 
 import (
 	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/Dogebox-WG/dogeboxd/pkg/rootd"
 	network_wifi "github.com/Dogebox-WG/dogeboxd/pkg/system/network/wifi"
 )
 
-func NewNetworkManager(nix dogeboxd.NixManager, sm dogeboxd.StateManager) dogeboxd.NetworkManager {
+// OpRunner runs privileged ops, see system.CommandRunner, which imports
+// this package.
+type OpRunner interface {
+	CombinedOutput(log dogeboxd.SubLogger, op rootd.Op) ([]byte, error)
+}
+
+func NewNetworkManager(nix dogeboxd.NixManager, sm dogeboxd.StateManager, runner OpRunner) dogeboxd.NetworkManager {
 	// TODO: Do some system discovery and figure out how to init this properly.
 	return NetworkManagerLinux{
 		nix:     nix,
 		sm:      sm,
-		scanner: network_wifi.NewWifiScanner(),
+		runner:  runner,
+		scanner: network_wifi.NewWifiScanner(runner),
 	}
 }
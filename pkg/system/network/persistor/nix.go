@@ -2,17 +2,27 @@ package network_persistor
 
 import (
 	_ "embed"
+	"net/netip"
+	"slices"
+	"strings"
 
 	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
 )
 
 var _ dogeboxd.NetworkPersistor = &NetworkPersistorNix{}
 
+// Always bypass the proxy for the box itself and its pups.
+var defaultNoProxy = []string{"127.0.0.1", "localhost", "10.69.0.0/16"}
+
 type NetworkPersistorNix struct {
 	nix dogeboxd.NixManager
 }
 
 func (t NetworkPersistorNix) Persist(nixPatch dogeboxd.NixPatch, network dogeboxd.SelectedNetwork) {
+	t.nix.UpdateNetwork(nixPatch, networkTemplateValues(network))
+}
+
+func networkTemplateValues(network dogeboxd.SelectedNetwork) dogeboxd.NixNetworkTemplateValues {
 	values := dogeboxd.NixNetworkTemplateValues{}
 
 	switch network := network.(type) {
@@ -32,5 +42,31 @@ func (t NetworkPersistorNix) Persist(nixPatch dogeboxd.NixPatch, network dogebox
 		}
 	}
 
-	t.nix.UpdateNetwork(nixPatch, values)
+	static, proxy := dogeboxd.SelectedNetworkOptions(network)
+
+	// Validated when the network was set, so a bad address falls back to DHCP.
+	if static != nil {
+		if prefix, err := netip.ParsePrefix(strings.TrimSpace(static.Address)); err == nil {
+			values.STATIC_ADDRESS = prefix.Addr().String()
+			values.STATIC_PREFIX_LENGTH = prefix.Bits()
+			values.GATEWAY = strings.TrimSpace(static.Gateway)
+			for _, dns := range static.DNS {
+				values.DNS = append(values.DNS, strings.TrimSpace(dns))
+			}
+		}
+	}
+
+	if proxy != nil {
+		values.HTTP_PROXY = proxy.HTTP
+		values.HTTPS_PROXY = proxy.HTTPS
+		noProxy := append([]string{}, defaultNoProxy...)
+		for _, host := range proxy.NoProxyHosts() {
+			if !slices.Contains(noProxy, host) {
+				noProxy = append(noProxy, host)
+			}
+		}
+		values.NO_PROXY = strings.Join(noProxy, ",")
+	}
+
+	return values
 }
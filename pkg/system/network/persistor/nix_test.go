@@ -0,0 +1,43 @@
+package network_persistor
+
+import (
+	"testing"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNetworkTemplateValuesDHCP(t *testing.T) {
+	values := networkTemplateValues(dogeboxd.SelectedNetworkEthernet{Interface: "eth0"})
+	assert.True(t, values.USE_ETHERNET)
+	assert.Equal(t, "eth0", values.INTERFACE)
+	assert.Empty(t, values.STATIC_ADDRESS)
+	assert.Empty(t, values.HTTP_PROXY)
+	assert.Empty(t, values.NO_PROXY)
+}
+
+func TestNetworkTemplateValuesStaticAndProxy(t *testing.T) {
+	values := networkTemplateValues(dogeboxd.SelectedNetworkWifi{
+		Interface: "wlan0",
+		Ssid:      "home",
+		Password:  "hunter22",
+		Static: &dogeboxd.NetworkStaticIP{
+			Address: " 192.168.1.20/24 ",
+			Gateway: "192.168.1.1",
+			DNS:     []string{"1.1.1.1", " 8.8.8.8"},
+		},
+		Proxy: &dogeboxd.NetworkProxy{
+			HTTP:    "http://proxy.example:3128",
+			NoProxy: "localhost, example.local,",
+		},
+	})
+
+	assert.True(t, values.USE_WIRELESS)
+	assert.Equal(t, "192.168.1.20", values.STATIC_ADDRESS)
+	assert.Equal(t, 24, values.STATIC_PREFIX_LENGTH)
+	assert.Equal(t, "192.168.1.1", values.GATEWAY)
+	assert.Equal(t, []string{"1.1.1.1", "8.8.8.8"}, values.DNS)
+	assert.Equal(t, "http://proxy.example:3128", values.HTTP_PROXY)
+	assert.Empty(t, values.HTTPS_PROXY)
+	assert.Equal(t, "127.0.0.1,localhost,10.69.0.0/16,example.local", values.NO_PROXY)
+}
@@ -1,29 +1,28 @@
 package network_wifi
 
 import (
-	"bytes"
 	"maps"
-	"os/exec"
 	"regexp"
 	"slices"
 	"strconv"
 	"strings"
+
+	"github.com/Dogebox-WG/dogeboxd/pkg/rootd"
 )
 
 var _ WifiScanner = &IWListScanner{}
 
-type IWListScanner struct{}
+type IWListScanner struct {
+	runner opRunner
+}
 
 func (s IWListScanner) Scan(interfaceName string) ([]ScannedWifiNetwork, error) {
-	cmd := exec.Command("sudo", "_dbxroot", "iwlist", interfaceName, "scan")
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	err := cmd.Run()
+	out, err := s.runner.CombinedOutput(nil, rootd.WifiScan{Interface: interfaceName})
 	if err != nil {
 		return nil, err
 	}
 
-	return parseIWListOutput(out.String()), nil
+	return parseIWListOutput(string(out)), nil
 }
 
 func parseIWListCell(cell string)  *ScannedWifiNetwork {
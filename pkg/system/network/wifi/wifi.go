@@ -1,5 +1,15 @@
 package network_wifi
 
+import (
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/Dogebox-WG/dogeboxd/pkg/rootd"
+)
+
+// opRunner runs privileged ops, see system.CommandRunner.
+type opRunner interface {
+	CombinedOutput(log dogeboxd.SubLogger, op rootd.Op) ([]byte, error)
+}
+
 type ScannedWifiNetwork struct {
 	SSID       string
 	BSSID      string
@@ -12,7 +22,7 @@ type WifiScanner interface {
 	Scan(networkInterface string) ([]ScannedWifiNetwork, error)
 }
 
-func NewWifiScanner() WifiScanner {
+func NewWifiScanner(runner opRunner) WifiScanner {
 	// TODO: Do some system discovery and figure out how to init this properly.
-	return IWListScanner{}
+	return IWListScanner{runner: runner}
 }
@@ -0,0 +1,606 @@
+package nix
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/Dogebox-WG/dogeboxd/pkg/tarball"
+)
+
+// How many nix config backups we keep before discarding the oldest.
+const nixConfigBackupRingSize = 10
+
+const nixConfigBackupExt = ".tar.gz"
+
+// Each backup has a manifest of the files it covers next to it, which the
+// next backup diffs against to only archive what changed.
+const nixConfigBackupManifestExt = ".manifest.json"
+
+// How many incremental backups can be layered on a full one before the
+// next backup is full again.
+const nixConfigBackupMaxChain = 6
+
+var ErrNixConfigBackupNotFound = errors.New("nix config backup not found")
+
+func (nm nixManager) configBackupDir() string {
+	return filepath.Join(nm.config.DataDir, "nix-backups")
+}
+
+func (nm nixManager) configBackupPath(backupID string) string {
+	return filepath.Join(nm.configBackupDir(), backupID+nixConfigBackupExt)
+}
+
+func (nm nixManager) configBackupManifestPath(backupID string) string {
+	return filepath.Join(nm.configBackupDir(), backupID+nixConfigBackupManifestExt)
+}
+
+type configBackupManifest struct {
+	Kind   string `json:"kind"`
+	Parent string `json:"parent,omitempty"`
+	// How many incrementals deep this backup is, 0 for a full backup.
+	Depth int                         `json:"depth"`
+	Files map[string]configBackupFile `json:"files"`
+}
+
+// A file or directory in the nix dir when a backup was taken, keyed by
+// its slash separated path relative to the nix dir.
+type configBackupFile struct {
+	Dir     bool      `json:"dir,omitempty"`
+	Size    int64     `json:"size,omitempty"`
+	ModTime time.Time `json:"modTime"`
+	Hash    string    `json:"hash,omitempty"`
+}
+
+// Backups from before incrementals don't have a manifest, and are
+// treated as full backups that can't be diffed against.
+func (nm nixManager) readConfigBackupManifest(backupID string) (*configBackupManifest, error) {
+	data, err := os.ReadFile(nm.configBackupManifestPath(backupID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var manifest configBackupManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to read manifest for backup %s: %w", backupID, err)
+	}
+	return &manifest, nil
+}
+
+func (nm nixManager) RestoreConfigBackup(patch dogeboxd.NixPatch, backupID string) error {
+	if _, err := nm.getConfigBackup(backupID); err != nil {
+		return err
+	}
+
+	patch.RestoreConfigBackup(backupID)
+	return nil
+}
+
+// ListConfigBackups returns all nix config backups, newest first.
+func (nm nixManager) ListConfigBackups() ([]dogeboxd.NixConfigBackup, error) {
+	entries, err := os.ReadDir(nm.configBackupDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []dogeboxd.NixConfigBackup{}, nil
+		}
+		return nil, err
+	}
+
+	backups := []dogeboxd.NixConfigBackup{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), nixConfigBackupExt) {
+			continue
+		}
+
+		backup, err := nm.getConfigBackup(strings.TrimSuffix(entry.Name(), nixConfigBackupExt))
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup)
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Created.After(backups[j].Created)
+	})
+
+	return backups, nil
+}
+
+func (nm nixManager) getConfigBackup(backupID string) (dogeboxd.NixConfigBackup, error) {
+	// IDs are <unix nanos>-<patch id>
+	parts := strings.SplitN(backupID, "-", 2)
+	if len(parts) != 2 || strings.ContainsAny(backupID, `/\.`) {
+		return dogeboxd.NixConfigBackup{}, ErrNixConfigBackupNotFound
+	}
+
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return dogeboxd.NixConfigBackup{}, ErrNixConfigBackupNotFound
+	}
+
+	info, err := os.Stat(nm.configBackupPath(backupID))
+	if err != nil {
+		return dogeboxd.NixConfigBackup{}, ErrNixConfigBackupNotFound
+	}
+
+	backup := dogeboxd.NixConfigBackup{
+		ID:      backupID,
+		PatchID: parts[1],
+		Created: time.Unix(0, nanos),
+		Size:    info.Size(),
+		Kind:    dogeboxd.NIX_CONFIG_BACKUP_FULL,
+	}
+
+	manifest, err := nm.readConfigBackupManifest(backupID)
+	if err == nil && manifest != nil && manifest.Kind == dogeboxd.NIX_CONFIG_BACKUP_INCREMENTAL {
+		backup.Kind = manifest.Kind
+		backup.Parent = manifest.Parent
+	}
+
+	return backup, nil
+}
+
+// ListConfigBackupFiles lists what restoring a backup puts in the nix
+// directory, sorted by path.
+func (nm nixManager) ListConfigBackupFiles(backupID string) ([]dogeboxd.NixConfigBackupFile, error) {
+	if _, err := nm.getConfigBackup(backupID); err != nil {
+		return nil, err
+	}
+
+	manifest, err := nm.readConfigBackupManifest(backupID)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []dogeboxd.NixConfigBackupFile{}
+	if manifest != nil {
+		for path, f := range manifest.Files {
+			if !f.Dir {
+				files = append(files, dogeboxd.NixConfigBackupFile{Path: path, Size: f.Size, ModTime: f.ModTime})
+			}
+		}
+	} else if files, err = nm.readConfigBackupTarballFiles(backupID); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// Lists the files in a backup without a manifest from its tarball.
+func (nm nixManager) readConfigBackupTarballFiles(backupID string) ([]dogeboxd.NixConfigBackupFile, error) {
+	f, err := os.Open(nm.configBackupPath(backupID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open backup %s: %w", backupID, err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress backup %s: %w", backupID, err)
+	}
+	defer gz.Close()
+
+	files := []dogeboxd.NixConfigBackupFile{}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read backup %s: %w", backupID, err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			files = append(files, dogeboxd.NixConfigBackupFile{Path: header.Name, Size: header.Size, ModTime: header.ModTime})
+		}
+	}
+	return files, nil
+}
+
+// DeleteConfigBackup removes a backup, unless an incremental backup
+// builds on it.
+func (nm nixManager) DeleteConfigBackup(backupID string) error {
+	if _, err := nm.getConfigBackup(backupID); err != nil {
+		return err
+	}
+
+	backups, err := nm.ListConfigBackups()
+	if err != nil {
+		return err
+	}
+	for _, backup := range backups {
+		if backup.Parent == backupID {
+			return fmt.Errorf("%w: backup %s builds on it", dogeboxd.ErrBackupInUse, backup.ID)
+		}
+	}
+
+	if err := os.Remove(nm.configBackupPath(backupID)); err != nil {
+		return err
+	}
+	if err := os.Remove(nm.configBackupManifestPath(backupID)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// configBackupChain lists the backups that restoring backupID extracts,
+// its full backup first and backupID last.
+func (nm nixManager) configBackupChain(backupID string) ([]string, error) {
+	chain := []string{}
+	for id := backupID; id != ""; {
+		if len(chain) > nixConfigBackupMaxChain {
+			return nil, fmt.Errorf("backup %s has a chain longer than %d", backupID, nixConfigBackupMaxChain)
+		}
+		backup, err := nm.getConfigBackup(id)
+		if err != nil {
+			return nil, fmt.Errorf("backup %s is missing %s it builds on: %w", backupID, id, err)
+		}
+		chain = append([]string{id}, chain...)
+		id = backup.Parent
+	}
+	return chain, nil
+}
+
+// CreateConfigBackup backs up the nix directory outside of a patch, eg:
+// for a scheduled backup.
+func (nm nixManager) CreateConfigBackup(log dogeboxd.SubLogger) (dogeboxd.NixConfigBackup, error) {
+	return nm.createConfigBackup("scheduled", log, 0, 100)
+}
+
+/* Writes a tarball of the current nix directory, and prunes old backups.
+ * If the newest backup has a manifest and its chain isn't too long yet,
+ * only files that changed since then (by size and mtime, then hash) are
+ * archived. Progress is reported to log between from and to, see
+ * TransferProgress.
+ */
+func (nm nixManager) createConfigBackup(patchID string, log dogeboxd.SubLogger, from int, to int) (dogeboxd.NixConfigBackup, error) {
+	if err := os.MkdirAll(nm.configBackupDir(), 0750); err != nil {
+		return dogeboxd.NixConfigBackup{}, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	backupID := fmt.Sprintf("%d-%s", time.Now().UnixNano(), patchID)
+	backupPath := nm.configBackupPath(backupID)
+
+	parentID, parent := nm.incrementalConfigBackupParent()
+
+	var previous map[string]configBackupFile
+	if parent != nil {
+		previous = parent.Files
+	}
+	files, err := scanConfigBackupFiles(nm.config.NixDir, previous)
+	if err != nil {
+		return dogeboxd.NixConfigBackup{}, fmt.Errorf("failed to scan nixDir: %w", err)
+	}
+
+	manifest := configBackupManifest{Kind: dogeboxd.NIX_CONFIG_BACKUP_FULL, Files: files}
+	if parent != nil {
+		manifest.Kind = dogeboxd.NIX_CONFIG_BACKUP_INCREMENTAL
+		manifest.Parent = parentID
+		manifest.Depth = parent.Depth + 1
+	}
+
+	include := func(relPath string) bool {
+		if parent == nil {
+			return true
+		}
+		prev, ok := parent.Files[relPath]
+		return !ok || prev.Hash != files[relPath].Hash
+	}
+
+	var total int64
+	for relPath, f := range files {
+		if !f.Dir && include(relPath) {
+			total += f.Size
+		}
+	}
+	what := "Backing up nix config"
+	if parent != nil {
+		what = "Backing up nix config changes"
+	}
+	progress := dogeboxd.NewTransferProgress(log, what, total, from, to)
+
+	if err := writeDirectoryTarball(nm.config.NixDir, backupPath, include, progress); err != nil {
+		os.Remove(backupPath)
+		return dogeboxd.NixConfigBackup{}, err
+	}
+
+	data, err := json.Marshal(manifest)
+	if err == nil {
+		err = os.WriteFile(nm.configBackupManifestPath(backupID), data, 0640)
+	}
+	if err != nil {
+		os.Remove(backupPath)
+		return dogeboxd.NixConfigBackup{}, fmt.Errorf("failed to write backup manifest: %w", err)
+	}
+
+	if err := nm.pruneConfigBackups(); err != nil {
+		return dogeboxd.NixConfigBackup{}, fmt.Errorf("failed to prune old backups: %w", err)
+	}
+	progress.Done()
+
+	return nm.getConfigBackup(backupID)
+}
+
+// incrementalConfigBackupParent returns the newest backup if the next one
+// can be an incremental on top of it.
+func (nm nixManager) incrementalConfigBackupParent() (string, *configBackupManifest) {
+	backups, err := nm.ListConfigBackups()
+	if err != nil || len(backups) == 0 {
+		return "", nil
+	}
+
+	newest := backups[0].ID
+	manifest, err := nm.readConfigBackupManifest(newest)
+	if err != nil || manifest == nil || manifest.Depth >= nixConfigBackupMaxChain {
+		return "", nil
+	}
+	// Don't build on a chain that's already broken.
+	if _, err := nm.configBackupChain(newest); err != nil {
+		return "", nil
+	}
+	return newest, manifest
+}
+
+// scanConfigBackupFiles lists everything under dir. Hashes are reused from
+// previous for files whose size and mtime haven't changed.
+func scanConfigBackupFiles(dir string, previous map[string]configBackupFile) (map[string]configBackupFile, error) {
+	files := map[string]configBackupFile{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil || relPath == "." {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+
+		if info.IsDir() {
+			files[relPath] = configBackupFile{Dir: true, ModTime: info.ModTime()}
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f := configBackupFile{Size: info.Size(), ModTime: info.ModTime()}
+		if prev, ok := previous[relPath]; ok && !prev.Dir && prev.Size == f.Size && prev.ModTime.Equal(f.ModTime) {
+			f.Hash = prev.Hash
+		} else if f.Hash, err = hashFile(path); err != nil {
+			return err
+		}
+		files[relPath] = f
+		return nil
+	})
+	return files, err
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Keeps the newest nixConfigBackupRingSize backups, along with any older
+// ones that they build on.
+func (nm nixManager) pruneConfigBackups() error {
+	backups, err := nm.ListConfigBackups()
+	if err != nil {
+		return err
+	}
+
+	keep := map[string]bool{}
+	for i := 0; i < nixConfigBackupRingSize && i < len(backups); i++ {
+		chain, err := nm.configBackupChain(backups[i].ID)
+		if err != nil {
+			keep[backups[i].ID] = true
+			continue
+		}
+		for _, id := range chain {
+			keep[id] = true
+		}
+	}
+
+	for _, backup := range backups {
+		if keep[backup.ID] {
+			continue
+		}
+		if err := os.Remove(nm.configBackupPath(backup.ID)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err := os.Remove(nm.configBackupManifestPath(backup.ID)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/* Replaces the contents of the nix directory with those of a backup. An
+ * incremental backup is restored by extracting its full backup and each
+ * incremental after it in turn, then removing anything that wasn't there
+ * when it was taken. Progress is reported to log between from and to,
+ * see TransferProgress.
+ */
+func (nm nixManager) extractConfigBackup(backupID string, log dogeboxd.SubLogger, from int, to int) error {
+	chain, err := nm.configBackupChain(backupID)
+	if err != nil {
+		return err
+	}
+
+	// We can only know the compressed size up front, so count that.
+	var total int64
+	for _, id := range chain {
+		backup, err := nm.getConfigBackup(id)
+		if err != nil {
+			return fmt.Errorf("failed to open backup %s: %w", id, err)
+		}
+		total += backup.Size
+	}
+	progress := dogeboxd.NewTransferProgress(log, "Restoring nix config", total, from, to)
+
+	manifest, err := nm.readConfigBackupManifest(backupID)
+	if err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(nm.config.NixDir); err != nil {
+		return fmt.Errorf("failed to clear nixDir: %w", err)
+	}
+
+	if err := os.MkdirAll(nm.config.NixDir, 0755); err != nil {
+		return fmt.Errorf("failed to recreate nixDir: %w", err)
+	}
+
+	for _, id := range chain {
+		if err := nm.extractConfigBackupTarball(id, progress); err != nil {
+			return err
+		}
+	}
+
+	if manifest != nil && len(chain) > 1 {
+		if err := removeFilesNotInManifest(nm.config.NixDir, manifest.Files); err != nil {
+			return fmt.Errorf("failed to remove files deleted before backup %s: %w", backupID, err)
+		}
+	}
+
+	progress.Done()
+	return nil
+}
+
+// Extracts a single backup's tarball over the nix directory.
+func (nm nixManager) extractConfigBackupTarball(backupID string, progress *dogeboxd.TransferProgress) error {
+	f, err := os.Open(nm.configBackupPath(backupID))
+	if err != nil {
+		return fmt.Errorf("failed to open backup %s: %w", backupID, err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(progress.Reader(f))
+	if err != nil {
+		return fmt.Errorf("failed to decompress backup %s: %w", backupID, err)
+	}
+	defer gz.Close()
+
+	if err := tarball.Extract(tar.NewReader(gz), nm.config.NixDir, tarball.Options{}); err != nil {
+		return fmt.Errorf("failed to extract backup %s: %w", backupID, err)
+	}
+	return nil
+}
+
+// Removes anything under dir that isn't in files, ie: things an earlier
+// backup in a chain had that were deleted by the time of the last one.
+func removeFilesNotInManifest(dir string, files map[string]configBackupFile) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil || relPath == "." {
+			return err
+		}
+
+		if _, ok := files[filepath.ToSlash(relPath)]; ok {
+			return nil
+		}
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+}
+
+// Writes srcDir to a gzipped tarball at destPath. Every directory is
+// written, but only the files include returns true for.
+func writeDirectoryTarball(srcDir string, destPath string, include func(relPath string) bool, progress *dogeboxd.TransferProgress) error {
+	out, err := os.OpenFile(destPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0640)
+	if err != nil {
+		return fmt.Errorf("failed to create backup: %w", err)
+	}
+	defer out.Close()
+
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+
+	err = filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(srcDir, path)
+		if err != nil || relPath == "." {
+			return err
+		}
+
+		// We only care about regular files and directories in the nix dir.
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+		if !info.IsDir() && !include(filepath.ToSlash(relPath)) {
+			return nil
+		}
+
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(relPath)
+
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = io.Copy(tw, progress.Reader(f))
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to finalise backup: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to finalise backup: %w", err)
+	}
+
+	return out.Close()
+}
@@ -0,0 +1,253 @@
+package nix
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newBackupTestNixManager(t *testing.T) nixManager {
+	return nixManager{
+		config: dogeboxd.ServerConfig{
+			DataDir: t.TempDir(),
+			NixDir:  t.TempDir(),
+		},
+	}
+}
+
+func TestConfigBackupRoundTrip(t *testing.T) {
+	nm := newBackupTestNixManager(t)
+	require.NoError(t, os.WriteFile(filepath.Join(nm.config.NixDir, "system.nix"), []byte("original"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(nm.config.NixDir, "nested"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(nm.config.NixDir, "nested", "pup_abc.nix"), []byte("pup"), 0644))
+
+	backup, err := nm.createConfigBackup("patch1", dogeboxd.NewConsoleSubLogger("", "backup"), 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "patch1", backup.PatchID)
+
+	// Botch the config, then restore it.
+	require.NoError(t, os.WriteFile(filepath.Join(nm.config.NixDir, "system.nix"), []byte("botched"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(nm.config.NixDir, "extra.nix"), []byte("extra"), 0644))
+
+	require.NoError(t, nm.extractConfigBackup(backup.ID, dogeboxd.NewConsoleSubLogger("", "restore"), 0, 0))
+
+	content, err := os.ReadFile(filepath.Join(nm.config.NixDir, "system.nix"))
+	require.NoError(t, err)
+	assert.Equal(t, "original", string(content))
+
+	content, err = os.ReadFile(filepath.Join(nm.config.NixDir, "nested", "pup_abc.nix"))
+	require.NoError(t, err)
+	assert.Equal(t, "pup", string(content))
+
+	_, err = os.Stat(filepath.Join(nm.config.NixDir, "extra.nix"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestCreateConfigBackupOutsideAPatch(t *testing.T) {
+	nm := newBackupTestNixManager(t)
+	require.NoError(t, os.WriteFile(filepath.Join(nm.config.NixDir, "system.nix"), []byte("x"), 0644))
+
+	backup, err := nm.CreateConfigBackup(dogeboxd.NewConsoleSubLogger("", "backup"))
+	require.NoError(t, err)
+	assert.Equal(t, "scheduled", backup.PatchID)
+
+	backups, err := nm.ListConfigBackups()
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+	assert.Equal(t, backup.ID, backups[0].ID)
+}
+
+func TestConfigBackupsAreKeptInARing(t *testing.T) {
+	nm := newBackupTestNixManager(t)
+	require.NoError(t, os.WriteFile(filepath.Join(nm.config.NixDir, "system.nix"), []byte("x"), 0644))
+
+	var newest dogeboxd.NixConfigBackup
+	for i := 0; i < 3*nixConfigBackupRingSize; i++ {
+		backup, err := nm.createConfigBackup("patch", dogeboxd.NewConsoleSubLogger("", "backup"), 0, 0)
+		require.NoError(t, err)
+		newest = backup
+	}
+
+	backups, err := nm.ListConfigBackups()
+	require.NoError(t, err)
+	assert.Equal(t, newest.ID, backups[0].ID)
+
+	// Older backups are only kept while a newer one builds on them.
+	assert.GreaterOrEqual(t, len(backups), nixConfigBackupRingSize)
+	assert.LessOrEqual(t, len(backups), nixConfigBackupRingSize+nixConfigBackupMaxChain)
+	for _, backup := range backups {
+		_, err := nm.configBackupChain(backup.ID)
+		assert.NoError(t, err, backup.ID)
+	}
+}
+
+func TestIncrementalConfigBackupsOnlyArchiveChanges(t *testing.T) {
+	nm := newBackupTestNixManager(t)
+	log := dogeboxd.NewConsoleSubLogger("", "backup")
+	require.NoError(t, os.WriteFile(filepath.Join(nm.config.NixDir, "system.nix"), []byte("original"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(nm.config.NixDir, "pup_abc.nix"), []byte("abc"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(nm.config.NixDir, "pup_def.nix"), []byte("def"), 0644))
+
+	full, err := nm.createConfigBackup("patch1", log, 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, dogeboxd.NIX_CONFIG_BACKUP_FULL, full.Kind)
+
+	require.NoError(t, os.WriteFile(filepath.Join(nm.config.NixDir, "system.nix"), []byte("changed"), 0644))
+	require.NoError(t, os.Remove(filepath.Join(nm.config.NixDir, "pup_def.nix")))
+
+	first, err := nm.createConfigBackup("patch2", log, 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, dogeboxd.NIX_CONFIG_BACKUP_INCREMENTAL, first.Kind)
+	assert.Equal(t, full.ID, first.Parent)
+	assert.Equal(t, []string{"system.nix"}, configBackupTarballFiles(t, nm, first.ID))
+
+	require.NoError(t, os.WriteFile(filepath.Join(nm.config.NixDir, "pup_ghi.nix"), []byte("ghi"), 0644))
+
+	second, err := nm.createConfigBackup("patch3", log, 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, second.Parent)
+	assert.Equal(t, []string{"pup_ghi.nix"}, configBackupTarballFiles(t, nm, second.ID))
+
+	// Restoring the middle of the chain layers it over the full backup,
+	// without anything from later on, or anything deleted before it.
+	require.NoError(t, nm.extractConfigBackup(first.ID, dogeboxd.NewConsoleSubLogger("", "restore"), 0, 0))
+	assertConfigDir(t, nm, map[string]string{"system.nix": "changed", "pup_abc.nix": "abc"})
+
+	require.NoError(t, nm.extractConfigBackup(second.ID, dogeboxd.NewConsoleSubLogger("", "restore"), 0, 0))
+	assertConfigDir(t, nm, map[string]string{"system.nix": "changed", "pup_abc.nix": "abc", "pup_ghi.nix": "ghi"})
+
+	require.NoError(t, nm.extractConfigBackup(full.ID, dogeboxd.NewConsoleSubLogger("", "restore"), 0, 0))
+	assertConfigDir(t, nm, map[string]string{"system.nix": "original", "pup_abc.nix": "abc", "pup_def.nix": "def"})
+}
+
+func TestConfigBackupChainsStartOverWhenFull(t *testing.T) {
+	nm := newBackupTestNixManager(t)
+	require.NoError(t, os.WriteFile(filepath.Join(nm.config.NixDir, "system.nix"), []byte("x"), 0644))
+
+	kinds := []string{}
+	for i := 0; i < nixConfigBackupMaxChain+2; i++ {
+		backup, err := nm.createConfigBackup("patch", dogeboxd.NewConsoleSubLogger("", "backup"), 0, 0)
+		require.NoError(t, err)
+		kinds = append(kinds, backup.Kind)
+	}
+
+	assert.Equal(t, dogeboxd.NIX_CONFIG_BACKUP_FULL, kinds[0])
+	for _, kind := range kinds[1 : nixConfigBackupMaxChain+1] {
+		assert.Equal(t, dogeboxd.NIX_CONFIG_BACKUP_INCREMENTAL, kind)
+	}
+	assert.Equal(t, dogeboxd.NIX_CONFIG_BACKUP_FULL, kinds[nixConfigBackupMaxChain+1])
+}
+
+func TestRestoringABrokenConfigBackupChainFails(t *testing.T) {
+	nm := newBackupTestNixManager(t)
+	log := dogeboxd.NewConsoleSubLogger("", "backup")
+	require.NoError(t, os.WriteFile(filepath.Join(nm.config.NixDir, "system.nix"), []byte("original"), 0644))
+
+	full, err := nm.createConfigBackup("patch1", log, 0, 0)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(nm.config.NixDir, "system.nix"), []byte("changed"), 0644))
+	incremental, err := nm.createConfigBackup("patch2", log, 0, 0)
+	require.NoError(t, err)
+
+	require.NoError(t, os.Remove(nm.configBackupPath(full.ID)))
+
+	assert.Error(t, nm.extractConfigBackup(incremental.ID, log, 0, 0))
+	content, err := os.ReadFile(filepath.Join(nm.config.NixDir, "system.nix"))
+	require.NoError(t, err)
+	assert.Equal(t, "changed", string(content), "the nix dir shouldn't be touched")
+
+	// And the next backup doesn't build on it.
+	next, err := nm.createConfigBackup("patch3", log, 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, dogeboxd.NIX_CONFIG_BACKUP_FULL, next.Kind)
+}
+
+func configBackupTarballFiles(t *testing.T, nm nixManager, backupID string) []string {
+	f, err := os.Open(nm.configBackupPath(backupID))
+	require.NoError(t, err)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+
+	files := []string{}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		if header.Typeflag == tar.TypeReg {
+			files = append(files, header.Name)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+func assertConfigDir(t *testing.T, nm nixManager, expected map[string]string) {
+	entries, err := os.ReadDir(nm.config.NixDir)
+	require.NoError(t, err)
+	actual := map[string]string{}
+	for _, entry := range entries {
+		content, err := os.ReadFile(filepath.Join(nm.config.NixDir, entry.Name()))
+		require.NoError(t, err)
+		actual[entry.Name()] = string(content)
+	}
+	assert.Equal(t, expected, actual)
+}
+
+func TestGetConfigBackupRejectsInvalidIDs(t *testing.T) {
+	nm := newBackupTestNixManager(t)
+
+	for _, id := range []string{"", "nope", "123-../../etc", "abc-patch"} {
+		_, err := nm.getConfigBackup(id)
+		assert.ErrorIs(t, err, ErrNixConfigBackupNotFound, id)
+	}
+}
+
+func TestListAndDeleteConfigBackups(t *testing.T) {
+	nm := newBackupTestNixManager(t)
+	log := dogeboxd.NewConsoleSubLogger("", "backup")
+	require.NoError(t, os.WriteFile(filepath.Join(nm.config.NixDir, "system.nix"), []byte("original"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(nm.config.NixDir, "pup_abc.nix"), []byte("abc"), 0644))
+
+	full, err := nm.createConfigBackup("patch1", log, 0, 0)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(nm.config.NixDir, "system.nix"), []byte("changed!"), 0644))
+	incremental, err := nm.createConfigBackup("patch2", log, 0, 0)
+	require.NoError(t, err)
+
+	// An incremental lists everything it restores, not just what changed.
+	files, err := nm.ListConfigBackupFiles(incremental.ID)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	assert.Equal(t, "pup_abc.nix", files[0].Path)
+	assert.Equal(t, "system.nix", files[1].Path)
+	assert.Equal(t, int64(8), files[1].Size)
+
+	// Backups from before manifests are listed from the tarball.
+	require.NoError(t, os.Remove(nm.configBackupManifestPath(full.ID)))
+	files, err = nm.ListConfigBackupFiles(full.ID)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	assert.Equal(t, "system.nix", files[1].Path)
+	assert.Equal(t, int64(len("original")), files[1].Size)
+
+	assert.ErrorIs(t, nm.DeleteConfigBackup(full.ID), dogeboxd.ErrBackupInUse)
+	require.NoError(t, nm.DeleteConfigBackup(incremental.ID))
+	require.NoError(t, nm.DeleteConfigBackup(full.ID))
+	assert.ErrorIs(t, nm.DeleteConfigBackup(full.ID), ErrNixConfigBackupNotFound)
+
+	backups, err := nm.ListConfigBackups()
+	require.NoError(t, err)
+	assert.Empty(t, backups)
+}
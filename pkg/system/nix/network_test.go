@@ -0,0 +1,63 @@
+package nix
+
+import (
+	"bytes"
+	"testing"
+	"text/template"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func renderNetwork(t *testing.T, values dogeboxd.NixNetworkTemplateValues) string {
+	tmpl, err := template.New("network.nix").Funcs(tmplFuncs).Parse(string(rawNetworkTemplate))
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, tmpl.Execute(&out, values))
+	return out.String()
+}
+
+func TestNetworkDHCP(t *testing.T) {
+	out := renderNetwork(t, dogeboxd.NixNetworkTemplateValues{USE_ETHERNET: true, INTERFACE: "eth0"})
+	assert.Contains(t, out, "useDHCP = true;")
+	assert.NotContains(t, out, "ipv4.addresses")
+	assert.NotContains(t, out, "defaultGateway")
+	assert.NotContains(t, out, "proxy")
+
+	out = renderNetwork(t, dogeboxd.NixNetworkTemplateValues{USE_WIRELESS: true, INTERFACE: "wlan0", WIFI_SSID: "home", WIFI_PASSWORD: "hunter22"})
+	assert.NotContains(t, out, "useDHCP")
+	assert.Contains(t, out, `"home" = {`)
+}
+
+func TestNetworkStaticIP(t *testing.T) {
+	out := renderNetwork(t, dogeboxd.NixNetworkTemplateValues{
+		USE_WIRELESS:         true,
+		INTERFACE:            "wlan0",
+		WIFI_SSID:            "home",
+		STATIC_ADDRESS:       "192.168.1.20",
+		STATIC_PREFIX_LENGTH: 24,
+		GATEWAY:              "192.168.1.1",
+		DNS:                  []string{"1.1.1.1", "8.8.8.8"},
+	})
+	assert.Contains(t, out, "useDHCP = false;")
+	assert.Contains(t, out, `{ address = "192.168.1.20"; prefixLength = 24; }`)
+	assert.Contains(t, out, `address = "192.168.1.1";`)
+	assert.Contains(t, out, `interface = "wlan0";`)
+	assert.Contains(t, out, `nameservers = [ "1.1.1.1" "8.8.8.8" ];`)
+	assert.Contains(t, out, "iwd = {")
+}
+
+func TestNetworkProxy(t *testing.T) {
+	out := renderNetwork(t, dogeboxd.NixNetworkTemplateValues{
+		USE_ETHERNET: true,
+		INTERFACE:    "eth0",
+		HTTPS_PROXY:  "http://proxy.example:3128",
+		NO_PROXY:     "127.0.0.1,localhost",
+	})
+	assert.Contains(t, out, "proxy = {")
+	assert.NotContains(t, out, "httpProxy")
+	assert.Contains(t, out, `httpsProxy = "http://proxy.example:3128";`)
+	assert.Contains(t, out, `noProxy = "127.0.0.1,localhost";`)
+}
@@ -45,6 +45,9 @@ var rawNetworkTemplate []byte
 //go:embed templates/storage-overlay.nix
 var rawStorageOverlayTemplate []byte
 
+//go:embed templates/recovery_ap.nix
+var rawRecoveryAPTemplate []byte
+
 const (
 	NixPatchStatePending     string = "pending"
 	NixPatchStateCancelled   string = "cancelled"
@@ -69,6 +72,8 @@ type nixPatch struct {
 	operations  []PatchOperation
 	error       error
 	log         dogeboxd.SubLogger
+	// The options Apply was called with, for operations to report progress.
+	options dogeboxd.NixPatchApplyOptions
 }
 
 func NewNixPatch(nm nixManager, log dogeboxd.SubLogger) dogeboxd.NixPatch {
@@ -108,6 +113,13 @@ func (np *nixPatch) Apply() error {
 }
 
 func (np *nixPatch) ApplyCustom(options dogeboxd.NixPatchApplyOptions) error {
+	start := time.Now()
+	err := np.applyCustom(options)
+	dogeboxd.ObserveNixPatchApply(time.Since(start), err)
+	return err
+}
+
+func (np *nixPatch) applyCustom(options dogeboxd.NixPatchApplyOptions) error {
 	if np.state != NixPatchStatePending {
 		return errors.New("patch already applied or cancelled")
 	}
@@ -115,6 +127,7 @@ func (np *nixPatch) ApplyCustom(options dogeboxd.NixPatchApplyOptions) error {
 	np.log.Logf("[patch-%s] Applying nix patch with %d operations", np.id, len(np.operations))
 
 	np.state = NixPatchStateApplying
+	np.options = options
 
 	if err := np.snapshot(); err != nil {
 		np.state = NixPatchStateErrored
@@ -122,6 +135,14 @@ func (np *nixPatch) ApplyCustom(options dogeboxd.NixPatchApplyOptions) error {
 		return fmt.Errorf("failed to snapshot: %w", err)
 	}
 
+	// Keep a longer-lived backup too, so changes can be undone later on.
+	from, to := np.backupProgress()
+	if backup, err := np.nm.createConfigBackup(np.id, np.log, from, to); err != nil {
+		np.log.Errf("[patch-%s] Warning: Failed to back up nix config: %v", np.id, err)
+	} else {
+		np.log.Logf("[patch-%s] Backed up nix config as %s", np.id, backup.ID)
+	}
+
 	np.state = NixPatchStateApplying
 
 	for _, operation := range np.operations {
@@ -146,6 +167,9 @@ func (np *nixPatch) ApplyCustom(options dogeboxd.NixPatchApplyOptions) error {
 			// We failed.
 			// Roll back our changes.
 			np.log.Errf("[patch-%s] Failed to rebuild, rolling back.. %v", np.id, err)
+			if np.nm.rebuildFailed != nil {
+				go np.nm.rebuildFailed(err)
+			}
 			return np.triggerRollback(err)
 		} else {
 			if np.nm.postRebuild != nil {
@@ -268,6 +292,41 @@ func (np *nixPatch) UpdateStorageOverlay(values dogeboxd.NixStorageOverlayTempla
 	})
 }
 
+func (np *nixPatch) UpdateRecoveryAP(values dogeboxd.NixRecoveryAPTemplateValues) {
+	np.add("UpdateRecoveryAP", func() error {
+		return np.writeTemplate("recovery_ap.nix", rawRecoveryAPTemplate, values)
+	})
+}
+
+func (np *nixPatch) RemoveRecoveryAP() {
+	np.add("RemoveRecoveryAP", func() error {
+		err := os.Remove(filepath.Join(np.nm.config.NixDir, "recovery_ap.nix"))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove recovery_ap.nix: %w", err)
+		}
+		return nil
+	})
+}
+
+func (np *nixPatch) RestoreConfigBackup(backupID string) {
+	np.add("RestoreConfigBackup", func() error {
+		from, to := np.restoreProgress()
+		return np.nm.extractConfigBackup(backupID, np.log, from, to)
+	})
+}
+
+// backupProgress and restoreProgress split the job progress given to
+// ApplyCustom between backing up the current config and restoring one.
+func (np *nixPatch) backupProgress() (int, int) {
+	from, to := np.options.ProgressFrom, np.options.ProgressTo
+	return from, from + (to-from)/2
+}
+
+func (np *nixPatch) restoreProgress() (int, int) {
+	from, to := np.options.ProgressFrom, np.options.ProgressTo
+	return from + (to-from)/2, to
+}
+
 func (np *nixPatch) writeTemplate(filename string, _template []byte, values interface{}) error {
 	tmpl, err := template.New(filename).Funcs(tmplFuncs).Parse(string(_template))
 	if err != nil {
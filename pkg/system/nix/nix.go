@@ -9,59 +9,96 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/Dogebox-WG/dogeboxd/pkg/rootd"
 )
 
 var _ dogeboxd.NixManager = &nixManager{}
 
+// Addressing for the recovery access point, clients get a DHCP lease in RecoveryAPSubnet.
+const (
+	RecoveryAPGateway = "10.0.0.69"
+	RecoveryAPSubnet  = "10.0.0.0/24"
+	// The virtual interface create_ap brings the AP up on. Only this
+	// interface is opened up, never the one we're connected through.
+	RecoveryAPInterface = "ap0"
+)
+
+// opRunner runs privileged ops, see system.CommandRunner.
+type opRunner interface {
+	Run(log dogeboxd.SubLogger, op rootd.Op) error
+}
+
 type nixManager struct {
 	config dogeboxd.ServerConfig
 	pups   dogeboxd.PupManager
-	// Post nix rebuild callback. Hook added in cmd/dogeboxd/server.go
-	postRebuild func()
+	runner opRunner
+	// Post nix rebuild callbacks. Hooks added in cmd/dogeboxd/server.go
+	postRebuild   func()
+	rebuildFailed func(err error)
+	// Called once a switch succeeds, with when it started, as it built
+	// every pending change written out before then.
+	switched func(started time.Time)
 }
 
 func NewNixManager(
 	config dogeboxd.ServerConfig,
 	pups dogeboxd.PupManager,
+	runner opRunner,
 	postRebuild func(),
+	rebuildFailed func(err error),
+	switched func(started time.Time),
 ) dogeboxd.NixManager {
 	return nixManager{
-		config:      config,
-		pups:        pups,
-		postRebuild: postRebuild,
+		config:        config,
+		pups:          pups,
+		runner:        runner,
+		postRebuild:   postRebuild,
+		rebuildFailed: rebuildFailed,
+		switched:      switched,
 	}
 }
 
 func (nm nixManager) InitSystem(patch dogeboxd.NixPatch, dbxState dogeboxd.DogeboxState) {
-	nm.UpdateIncludesFile(patch, nm.pups)
+	nm.UpdateIncludesFile(patch, nm.pups, dbxState)
 
-	patch.UpdateSystem(dogeboxd.NixSystemTemplateValues{
+	nm.UpdateSystem(patch, dogeboxd.NixSystemTemplateValues{
 		SSH_ENABLED:     dbxState.SSH.Enabled,
 		SSH_KEYS:        dbxState.SSH.Keys,
 		SYSTEM_HOSTNAME: dbxState.Hostname,
 		KEYMAP:          dbxState.KeyMap,
 		TIMEZONE:        dbxState.Timezone,
+
+		WIFI_REGULATORY_DOMAIN: dbxState.WifiRegulatoryDomain,
 	})
 
 	nm.UpdateFirewallRules(patch, dbxState)
 	nm.UpdateSystemContainerConfiguration(patch)
 }
 
-func (nm nixManager) UpdateIncludesFile(patch dogeboxd.NixPatch, pups dogeboxd.PupManager) {
-	installed := pups.GetStateMap()
+func (nm nixManager) UpdateIncludesFile(patch dogeboxd.NixPatch, pups dogeboxd.PupManager, dbxState dogeboxd.DogeboxState) {
+	safeMode := dbxState.Flags.IsSafeMode
+
 	var pupIDs []string
-	for id, state := range installed {
-		if state.Installation == dogeboxd.STATE_INSTALLING || state.Installation == dogeboxd.STATE_READY || state.Installation == dogeboxd.STATE_RUNNING {
-			pupIDs = append(pupIDs, id)
+
+	// In safe mode we leave every pup out of the system config, so a
+	// broken pup can't stop the rest of the system from rebuilding.
+	if !safeMode {
+		installed := pups.GetStateMap()
+		for id, state := range installed {
+			if state.Installation == dogeboxd.STATE_INSTALLING || state.Installation == dogeboxd.STATE_READY || state.Installation == dogeboxd.STATE_RUNNING {
+				pupIDs = append(pupIDs, id)
+			}
 		}
 	}
 
 	values := dogeboxd.NixIncludesFileTemplateValues{
-		PUP_IDS:  pupIDs,
-		NIX_DIR:  nm.config.NixDir,
-		DATA_DIR: nm.config.DataDir,
+		PUP_IDS:   pupIDs,
+		NIX_DIR:   nm.config.NixDir,
+		DATA_DIR:  nm.config.DataDir,
+		SAFE_MODE: safeMode,
 	}
 
 	patch.UpdateIncludesFile(values)
@@ -82,6 +119,9 @@ func (nm nixManager) WritePupFile(
 			EXEC: service.Command.Exec,
 			CWD:  cwd,
 			ENV:  toEnv(service.Command.ENV),
+
+			NOTIFY:        service.Notifies(),
+			READY_TIMEOUT: service.ReadyTimeoutSeconds(),
 		})
 	}
 
@@ -101,6 +141,10 @@ func (nm nixManager) WritePupFile(
 		CONTAINER_LOG_DIR: nm.config.ContainerLogDir,
 		PUP_ID:            state.ID,
 		PUP_ENABLED:       state.Enabled,
+		PUP_AUTO_START:    state.StartsOnBoot(),
+		RESTART_SCHEDULE:  state.RestartSchedule,
+		CPU_QUOTA:         state.ResourceLimits().CPUQuota(),
+		MEMORY_MAX:        state.ResourceLimits().MemoryMax(),
 		INTERNAL_IP:       state.IP,
 		PUP_PORTS: []struct {
 			PORT   int
@@ -115,6 +159,49 @@ func (nm nixManager) WritePupFile(
 
 		IS_DEV_MODE:       state.IsDevModeEnabled,
 		DEV_MODE_SERVICES: state.DevModeServices,
+
+		STOP_SIGNAL:            state.StopSignal(),
+		STOP_TIMEOUT:           state.StopTimeoutSeconds(),
+		CONTAINER_STOP_TIMEOUT: state.ContainerStopTimeout(),
+
+		CONTAINER_START_TIMEOUT: state.ContainerStartTimeout(),
+	}
+
+	for _, ca := range dbxState.TrustedCAsFor(state) {
+		values.TRUSTED_CAS = append(values.TRUSTED_CAS, ca.PEM)
+	}
+
+	for _, migration := range state.PendingMigrations {
+		values.MIGRATIONS = append(values.MIGRATIONS, dogeboxd.NixPupContainerMigrationValues{
+			ID:      migration.ID,
+			SERVICE: migration.Service,
+			EXEC:    migration.Exec,
+		})
+	}
+
+	for _, device := range state.PassedDevices() {
+		// Binding a missing device stops the container starting, so
+		// optional ones are left out until they're plugged in.
+		if _, err := os.Stat(device.Path); err != nil && device.Optional {
+			continue
+		}
+		values.DEVICES = append(values.DEVICES, dogeboxd.NixPupContainerDeviceValues{
+			PATH:      device.Path,
+			NODE:      device.DeviceNode(),
+			MODIFIER:  device.DeviceModifier(),
+			READ_ONLY: device.ReadOnly,
+		})
+	}
+
+	// Dev mode always builds from the local source.
+	if !state.IsDevModeEnabled {
+		for _, closure := range state.PrebuiltClosures {
+			values.CLOSURES = append(values.CLOSURES, dogeboxd.NixPupContainerClosureValues{
+				SERVICE:    closure.Service,
+				STORE_PATH: closure.StorePath,
+				FROM_STORE: closure.FromStore,
+			})
+		}
 	}
 
 	rebuildFW := false
@@ -158,6 +245,12 @@ func (nm nixManager) UpdateSystem(nixPatch dogeboxd.NixPatch, values dogeboxd.Ni
 		values.TIMEZONE = "UTC"
 	}
 
+	// rootd only takes ops from us, see _dbxroot serve.
+	values.ROOTD_SOCKET = nm.config.RootdSocketPath
+	values.DATA_DIR = nm.config.DataDir
+	values.DBX_UID = os.Getuid()
+	values.DBX_GID = os.Getgid()
+
 	nixPatch.UpdateSystem(values)
 }
 
@@ -310,26 +403,61 @@ func (nm nixManager) UpdateNetwork(nixPatch dogeboxd.NixPatch, values dogeboxd.N
 	nixPatch.UpdateNetwork(values)
 }
 
-func (nm nixManager) UpdateStorageOverlay(nixPatch dogeboxd.NixPatch, partitionName string) {
+func (nm nixManager) UpdateRecoveryAP(nixPatch dogeboxd.NixPatch, iface string, ssid string, dbxState dogeboxd.DogeboxState) {
+	values := dogeboxd.NixRecoveryAPTemplateValues{
+		INTERFACE: iface,
+		SSID:      ssid,
+		GATEWAY:   RecoveryAPGateway,
+		SUBNET:    RecoveryAPSubnet,
+		COUNTRY:   dbxState.WifiRegulatoryDomain,
+		UI_PORT:   nm.config.UiPort,
+
+		AP_INTERFACE: RecoveryAPInterface,
+		PASSPHRASE:   dbxState.APMode.Passphrase,
+	}
+
+	nixPatch.UpdateRecoveryAP(values)
+}
+
+func (nm nixManager) RemoveRecoveryAP(nixPatch dogeboxd.NixPatch) {
+	nixPatch.RemoveRecoveryAP()
+}
+
+func (nm nixManager) UpdateStorageOverlay(nixPatch dogeboxd.NixPatch, volume dogeboxd.StorageVolume) {
 	currentUID := os.Getuid()
 	uidStr := strconv.Itoa(currentUID)
 
 	values := dogeboxd.NixStorageOverlayTemplateValues{
-		STORAGE_DEVICE: partitionName,
-		DATA_DIR:       nm.config.DataDir,
-		DBX_UID:        uidStr,
+		STORAGE_DEVICE:        volume.Partition,
+		STORAGE_UUID:          volume.UUID,
+		STORAGE_MIRROR_DEVICE: volume.MirrorPartition,
+		STORAGE_STATUS_DIR:    dogeboxd.StorageStatusDir,
+		DATA_DIR:              nm.config.DataDir,
+		DBX_UID:               uidStr,
 	}
 
 	nixPatch.UpdateStorageOverlay(values)
 }
 
-func (nm nixManager) RebuildBoot(log dogeboxd.SubLogger) error {
-	cmdArgs := []string{"_dbxroot", "nix", "rb"}
+// rebuild runs nixos-rebuild, keeping its output to attribute failures
+// and splitting out each pup's build log.
+func (nm nixManager) rebuild(log dogeboxd.SubLogger, op rootd.RebuildSystem) error {
+	output := &rebuildOutput{}
+	buildLogs := nm.newPupBuildLogs()
+	defer buildLogs.Close()
+
+	tee := teeLogger{SubLogger: log, lines: func(line string) {
+		output.add(line)
+		buildLogs.add(line)
+	}}
+	if err := nm.runner.Run(tee, op); err != nil {
+		return nm.rebuildError(err, output)
+	}
+	return nil
+}
 
-	md := exec.Command("sudo", cmdArgs...)
-	log.LogCmd(md)
-	err := md.Run()
-	if err != nil {
+func (nm nixManager) RebuildBoot(log dogeboxd.SubLogger) error {
+	if err := nm.rebuild(log, rootd.RebuildSystem{Boot: true}); err != nil {
 		log.Errf("Error executing nix rebuild boot: %v\n", err)
 		return err
 	}
@@ -337,16 +465,15 @@ func (nm nixManager) RebuildBoot(log dogeboxd.SubLogger) error {
 }
 
 func (nm nixManager) Rebuild(log dogeboxd.SubLogger) error {
-	cmdArgs := []string{"_dbxroot", "nix", "rs"}
-
-	cmd := exec.Command("sudo", cmdArgs...)
-	log.LogCmd(cmd)
-
-	if err := cmd.Run(); err != nil {
+	started := time.Now()
+	if err := nm.rebuild(log, rootd.RebuildSystem{}); err != nil {
 		log.Errf("Error executing nix rebuild: %v\n", err)
 		return err
 	}
 
+	if nm.switched != nil {
+		nm.switched(started)
+	}
 	return nil
 }
 
@@ -0,0 +1,121 @@
+package nix
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+var (
+	// eg: building '/nix/store/<hash>-dogecoind-1.14.9.drv'...
+	buildingDrvPattern = regexp.MustCompile(`building '/nix/store/[0-9a-z]{32}-([^']+)\.drv'`)
+	// With --print-build-logs, nix prefixes build output with the derivation name.
+	// eg: dogecoind-1.14.9> checking for gcc... gcc
+	buildLogLinePattern = regexp.MustCompile(`^([A-Za-z0-9+._-]+)> `)
+	// Container systems are named after the container, eg: nixos-system-pup-abc123-24.11
+	pupContainerDrvPattern = regexp.MustCompile(`pup-([0-9a-f]+)`)
+)
+
+/* pupBuildLogs splits the output of a rebuild into a build log per pup,
+ * for any pup whose derivations are built. Each pup's log is replaced
+ * the first time one of its derivations builds during a rebuild, so it
+ * always holds that pup's most recent build.
+ */
+type pupBuildLogs struct {
+	mu     sync.Mutex
+	config dogeboxd.ServerConfig
+	pups   map[string]dogeboxd.PupState
+	files  map[string]*os.File
+	// Derivation names we've already attributed, "" if not a pup's.
+	owners map[string]string
+}
+
+func (nm nixManager) newPupBuildLogs() *pupBuildLogs {
+	return &pupBuildLogs{
+		config: nm.config,
+		pups:   nm.pupStates(),
+		files:  map[string]*os.File{},
+		owners: map[string]string{},
+	}
+}
+
+func (l *pupBuildLogs) add(line string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var drvName string
+	if m := buildingDrvPattern.FindStringSubmatch(line); m != nil {
+		drvName = m[1]
+	} else if m := failedDrvPattern.FindStringSubmatch(line); m != nil {
+		drvName = m[1]
+	} else if m := buildLogLinePattern.FindStringSubmatch(line); m != nil {
+		drvName = m[1]
+	} else {
+		return
+	}
+
+	pupID := l.ownerOf(drvName)
+	if pupID == "" {
+		return
+	}
+
+	f, err := l.fileFor(pupID)
+	if err != nil {
+		log.Printf("Failed to open build log for pup %s: %v", pupID, err)
+		return
+	}
+	fmt.Fprintln(f, line)
+}
+
+func (l *pupBuildLogs) ownerOf(drvName string) string {
+	if owner, ok := l.owners[drvName]; ok {
+		return owner
+	}
+
+	owner := pupForDerivation(drvName, l.pups)
+	if m := pupContainerDrvPattern.FindStringSubmatch(drvName); owner == "" && m != nil {
+		if _, ok := l.pups[m[1]]; ok {
+			owner = m[1]
+		}
+	}
+
+	l.owners[drvName] = owner
+	return owner
+}
+
+func (l *pupBuildLogs) fileFor(pupID string) (*os.File, error) {
+	if f, ok := l.files[pupID]; ok {
+		return f, nil
+	}
+
+	f, err := os.Create(l.config.PupBuildLogPath(pupID))
+	if err != nil {
+		return nil, err
+	}
+
+	name := pupID
+	if pup, ok := l.pups[pupID]; ok {
+		name = fmt.Sprintf("%s %s (%s)", pup.Manifest.Meta.Name, pup.Version, pupID)
+	}
+	fmt.Fprintf(f, "# Build log for %s, %s\n", name, time.Now().Format(time.RFC3339))
+
+	l.files[pupID] = f
+	return f, nil
+}
+
+func (l *pupBuildLogs) Close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for id, f := range l.files {
+		if err := f.Close(); err != nil {
+			log.Printf("Failed to close build log for pup %s: %v", id, err)
+		}
+	}
+	l.files = map[string]*os.File{}
+}
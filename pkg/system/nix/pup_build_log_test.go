@@ -0,0 +1,70 @@
+package nix
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPupBuildLogsSplitsOutputPerPup(t *testing.T) {
+	dataDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dataDir, "pups"), 0755))
+
+	config := dogeboxd.ServerConfig{DataDir: dataDir}
+	logs := &pupBuildLogs{
+		config: config,
+		pups:   testRebuildPups(),
+		files:  map[string]*os.File{},
+		owners: map[string]string{},
+	}
+
+	lines := []string{
+		"building the system configuration...",
+		"building '/nix/store/0123456789abcdfghijklmnpqrsvwxyz-dogecoind-1.14.9.drv'...",
+		"dogecoind-1.14.9> checking for gcc... gcc",
+		"openssl-3.0.0> unrelated build output",
+		"building '/nix/store/0123456789abcdfghijklmnpqrsvwxyz-nixos-system-pup-abc123-24.11.drv'...",
+		"error: builder for '/nix/store/0123456789abcdfghijklmnpqrsvwxyz-dogecoind-1.14.9.drv' failed with exit code 1",
+	}
+	for _, line := range lines {
+		logs.add(line)
+	}
+	logs.Close()
+
+	contents, err := os.ReadFile(config.PupBuildLogPath("abc123"))
+	require.NoError(t, err)
+
+	log := string(contents)
+	assert.Contains(t, log, "# Build log for Dogecoin Core")
+	assert.Contains(t, log, "dogecoind-1.14.9> checking for gcc... gcc")
+	assert.Contains(t, log, "nixos-system-pup-abc123-24.11.drv")
+	assert.Contains(t, log, "failed with exit code 1")
+	assert.NotContains(t, log, "openssl")
+	assert.NotContains(t, log, "building the system configuration")
+}
+
+func TestPupBuildLogsLeavesUnbuiltPupsAlone(t *testing.T) {
+	dataDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dataDir, "pups"), 0755))
+
+	config := dogeboxd.ServerConfig{DataDir: dataDir}
+	previous := []byte("previous build\n")
+	require.NoError(t, os.WriteFile(config.PupBuildLogPath("abc123"), previous, 0644))
+
+	logs := &pupBuildLogs{
+		config: config,
+		pups:   testRebuildPups(),
+		files:  map[string]*os.File{},
+		owners: map[string]string{},
+	}
+	logs.add("openssl-3.0.0> unrelated build output")
+	logs.Close()
+
+	contents, err := os.ReadFile(config.PupBuildLogPath("abc123"))
+	require.NoError(t, err)
+	assert.Equal(t, previous, contents)
+}
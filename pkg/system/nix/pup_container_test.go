@@ -0,0 +1,114 @@
+package nix
+
+import (
+	"bytes"
+	"testing"
+	"text/template"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func renderPupContainer(t *testing.T, values dogeboxd.NixPupContainerTemplateValues) string {
+	tmpl, err := template.New("pup_abc.nix").Funcs(tmplFuncs).Parse(string(rawPupContainerTemplate))
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, tmpl.Execute(&out, values))
+	return out.String()
+}
+
+func TestPupContainerResourceLimits(t *testing.T) {
+	values := dogeboxd.NixPupContainerTemplateValues{PUP_ID: "abc"}
+
+	out := renderPupContainer(t, values)
+	assert.NotContains(t, out, "CPUQuota")
+	assert.NotContains(t, out, "MemoryMax")
+
+	limits := dogeboxd.PupResourceLimits{CPUPercent: 150, MemoryMB: 512}
+	values.CPU_QUOTA = limits.CPUQuota()
+	values.MEMORY_MAX = limits.MemoryMax()
+
+	out = renderPupContainer(t, values)
+	assert.Contains(t, out, `systemd.services."container@pup-abc".serviceConfig.CPUQuota = "150%";`)
+	assert.Contains(t, out, `systemd.services."container@pup-abc".serviceConfig.MemoryMax = "512M";`)
+}
+
+func TestPupContainerTrustedCAs(t *testing.T) {
+	values := dogeboxd.NixPupContainerTemplateValues{PUP_ID: "abc"}
+
+	out := renderPupContainer(t, values)
+	assert.NotContains(t, out, "security.pki.certificates")
+
+	values.TRUSTED_CAS = []string{"-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n"}
+	out = renderPupContainer(t, values)
+	assert.Contains(t, out, "security.pki.certificates = [")
+	assert.Contains(t, out, "''\n-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n''")
+}
+
+func TestPupContainerStopTimeout(t *testing.T) {
+	values := dogeboxd.NixPupContainerTemplateValues{
+		PUP_ID:   "abc",
+		SERVICES: []dogeboxd.NixPupContainerServiceValues{{NAME: "dogecoind", EXEC: "/bin/dogecoind"}},
+	}
+
+	out := renderPupContainer(t, values)
+	assert.NotContains(t, out, "KillSignal")
+	assert.NotContains(t, out, "TimeoutStopSec")
+
+	p := dogeboxd.PupState{}
+	p.Manifest.Container.Stop = &dogeboxd.PupManifestStop{Signal: "SIGINT", TimeoutSeconds: 600}
+	values.STOP_SIGNAL = p.StopSignal()
+	values.STOP_TIMEOUT = p.StopTimeoutSeconds()
+	values.CONTAINER_STOP_TIMEOUT = p.ContainerStopTimeout()
+
+	out = renderPupContainer(t, values)
+	assert.Contains(t, out, `KillSignal = "SIGINT";`)
+	assert.Contains(t, out, `TimeoutStopSec = 600;`)
+	assert.Contains(t, out, `systemd.services."container@pup-abc".serviceConfig.TimeoutStopSec = "630s";`)
+}
+
+func TestPupContainerDevices(t *testing.T) {
+	values := dogeboxd.NixPupContainerTemplateValues{PUP_ID: "abc"}
+
+	out := renderPupContainer(t, values)
+	assert.NotContains(t, out, "/dev/dri")
+	assert.NotContains(t, out, "extraGroups")
+
+	values.DEVICES = []dogeboxd.NixPupContainerDeviceValues{
+		{PATH: "/dev/dri", NODE: "char-drm", MODIFIER: "rw"},
+		{PATH: "/dev/ttyUSB0", NODE: "/dev/ttyUSB0", MODIFIER: "r", READ_ONLY: true},
+	}
+	out = renderPupContainer(t, values)
+	assert.Contains(t, out, `"/dev/dri" = { mountPoint = "/dev/dri"; hostPath = "/dev/dri"; isReadOnly = false; };`)
+	assert.Contains(t, out, `"/dev/ttyUSB0" = { mountPoint = "/dev/ttyUSB0"; hostPath = "/dev/ttyUSB0"; isReadOnly = true; };`)
+	assert.Contains(t, out, `{ node = "char-drm"; modifier = "rw"; }`)
+	assert.Contains(t, out, `{ node = "/dev/ttyUSB0"; modifier = "r"; }`)
+	assert.Contains(t, out, `extraGroups = [ "dialout" "video" "render" ];`)
+}
+
+func TestPupContainerNotifyServices(t *testing.T) {
+	values := dogeboxd.NixPupContainerTemplateValues{
+		PUP_ID:   "abc",
+		SERVICES: []dogeboxd.NixPupContainerServiceValues{{NAME: "dogecoind", EXEC: "/bin/dogecoind"}},
+	}
+
+	out := renderPupContainer(t, values)
+	assert.NotContains(t, out, `Type = "notify";`)
+	assert.NotContains(t, out, "timeoutStartSec")
+
+	p := dogeboxd.PupState{}
+	p.Manifest.Container.Services = []dogeboxd.PupManifestService{{
+		Name:      "dogecoind",
+		Readiness: &dogeboxd.PupManifestServiceReadiness{Type: dogeboxd.PUP_SERVICE_READINESS_NOTIFY, TimeoutSeconds: 600},
+	}}
+	values.SERVICES[0].NOTIFY = p.Manifest.Container.Services[0].Notifies()
+	values.SERVICES[0].READY_TIMEOUT = p.Manifest.Container.Services[0].ReadyTimeoutSeconds()
+	values.CONTAINER_START_TIMEOUT = p.ContainerStartTimeout()
+
+	out = renderPupContainer(t, values)
+	assert.Contains(t, out, `Type = "notify";`)
+	assert.Contains(t, out, `TimeoutStartSec = 600;`)
+	assert.Contains(t, out, `containers.pup-abc.timeoutStartSec = "630s";`)
+}
@@ -0,0 +1,188 @@
+package nix
+
+import (
+	"io"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+// How many trailing lines of rebuild output we keep for failure analysis.
+const rebuildOutputHistory = 500
+
+var (
+	// eg: error: ... at /etc/nixos/dogebox/pup_abc123.nix:12:3
+	pupNixFilePattern = regexp.MustCompile(`pup_([0-9a-f]+)\.nix`)
+	// eg: container@pup-abc123.service, container-log-forwarder@pup-abc123.service
+	pupUnitPattern = regexp.MustCompile(`((?:container|container-log-forwarder)@pup-([0-9a-f]+)\.service)`)
+	// eg: error: builder for '/nix/store/<hash>-foo-1.0.drv' failed with exit code 1
+	//     error: Cannot build '/nix/store/<hash>-foo-1.0.drv'.
+	failedDrvPattern = regexp.MustCompile(`(?:builder for|[Cc]annot build) '?/nix/store/[0-9a-z]{32}-([^'\s]+)\.drv'?`)
+	// eg: warning: the following units failed: dkm.service
+	//     Job for dkm.service failed because the control process exited with error code.
+	failedUnitPattern = regexp.MustCompile(`([A-Za-z0-9@._-]+\.service)`)
+)
+
+// Keeps the last N lines written by a command.
+type rebuildOutput struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (o *rebuildOutput) add(line string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.lines = append(o.lines, line)
+	if len(o.lines) > rebuildOutputHistory {
+		o.lines = o.lines[len(o.lines)-rebuildOutputHistory:]
+	}
+}
+
+func (o *rebuildOutput) Lines() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return append([]string{}, o.lines...)
+}
+
+/* teeLogger passes each line of an op's output to lines as well as the
+ * SubLogger, whether the op runs via sudo, which wires the command up
+ * with LogCmd, or rootd, which logs each line it's sent.
+ */
+type teeLogger struct {
+	dogeboxd.SubLogger
+	lines func(line string)
+}
+
+func (l teeLogger) Log(msg string) {
+	l.lines(msg)
+	l.SubLogger.Log(msg)
+}
+
+func (l teeLogger) LogCmd(cmd *exec.Cmd) {
+	l.SubLogger.LogCmd(cmd)
+	cmd.Stdout = teeWriter(cmd.Stdout, dogeboxd.NewLineWriter(l.lines))
+	cmd.Stderr = teeWriter(cmd.Stderr, dogeboxd.NewLineWriter(l.lines))
+}
+
+func teeWriter(existing io.Writer, capture io.Writer) io.Writer {
+	if existing == nil {
+		return capture
+	}
+	return io.MultiWriter(existing, capture)
+}
+
+// Wraps a rebuild error with attribution derived from the rebuild output.
+func (nm nixManager) rebuildError(err error, output *rebuildOutput) error {
+	return &dogeboxd.NixRebuildError{
+		Err:          err,
+		Attributions: analyzeRebuildOutput(output.Lines(), nm.pupStates()),
+	}
+}
+
+func (nm nixManager) pupStates() map[string]dogeboxd.PupState {
+	if nm.pups == nil {
+		return map[string]dogeboxd.PupState{}
+	}
+	return nm.pups.GetStateMap()
+}
+
+// Parses nixos-rebuild output for failing nix files, units and derivations,
+// mapping each back to the pup (or system component) responsible.
+func analyzeRebuildOutput(lines []string, pups map[string]dogeboxd.PupState) []dogeboxd.NixRebuildAttribution {
+	attributions := []dogeboxd.NixRebuildAttribution{}
+	seen := map[string]bool{}
+
+	add := func(a dogeboxd.NixRebuildAttribution) {
+		if seen[a.Component] {
+			return
+		}
+		seen[a.Component] = true
+		if pup, ok := pups[a.PupID]; ok {
+			a.PupName = pup.Manifest.Meta.Name
+		}
+		attributions = append(attributions, a)
+	}
+
+	for _, line := range lines {
+		lower := strings.ToLower(line)
+		isError := strings.Contains(lower, "error") || strings.Contains(lower, "failed")
+		if !isError {
+			continue
+		}
+
+		if m := pupNixFilePattern.FindStringSubmatch(line); m != nil {
+			add(dogeboxd.NixRebuildAttribution{
+				PupID:     m[1],
+				Component: m[0],
+				Reason:    "error evaluating pup configuration",
+			})
+			continue
+		}
+
+		if m := failedDrvPattern.FindStringSubmatch(line); m != nil {
+			add(dogeboxd.NixRebuildAttribution{
+				PupID:     pupForDerivation(m[1], pups),
+				Component: m[1],
+				Reason:    "derivation failed to build",
+			})
+			continue
+		}
+
+		if !strings.Contains(lower, "failed") {
+			continue
+		}
+
+		if matches := pupUnitPattern.FindAllStringSubmatch(line, -1); matches != nil {
+			for _, m := range matches {
+				add(dogeboxd.NixRebuildAttribution{
+					PupID:     m[2],
+					Component: m[1],
+					Reason:    "unit failed to start",
+				})
+			}
+			continue
+		}
+
+		for _, unit := range failedUnitPattern.FindAllString(line, -1) {
+			add(dogeboxd.NixRebuildAttribution{
+				Component: unit,
+				Reason:    "unit failed to start",
+			})
+		}
+	}
+
+	return attributions
+}
+
+/* Derivations for pup services are named after the services in their
+ * manifest, optionally followed by -<version>. Only a whole name counts,
+ * so a "node" service isn't blamed for nodejs, and if more than one pup
+ * has the service we can't say which it was.
+ */
+func pupForDerivation(drvName string, pups map[string]dogeboxd.PupState) string {
+	ids := make([]string, 0, len(pups))
+	for id := range pups {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	match := ""
+	for _, id := range ids {
+		for _, service := range pups[id].Manifest.Container.Services {
+			if service.Name == "" || (drvName != service.Name && !strings.HasPrefix(drvName, service.Name+"-")) {
+				continue
+			}
+			if match != "" && match != id {
+				return ""
+			}
+			match = id
+		}
+	}
+	return match
+}
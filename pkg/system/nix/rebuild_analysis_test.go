@@ -0,0 +1,124 @@
+package nix
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testRebuildPups() map[string]dogeboxd.PupState {
+	return map[string]dogeboxd.PupState{
+		"abc123": {
+			ID: "abc123",
+			Manifest: dogeboxd.PupManifest{
+				Meta: dogeboxd.PupManifestMeta{Name: "Dogecoin Core"},
+				Container: dogeboxd.PupManifestContainer{
+					Services: []dogeboxd.PupManifestService{{Name: "dogecoind"}},
+				},
+			},
+		},
+	}
+}
+
+func TestAnalyzeRebuildOutputAttributesFailedPupUnit(t *testing.T) {
+	lines := []string{
+		"building the system configuration...",
+		"warning: the following units failed: container@pup-abc123.service",
+	}
+
+	attributions := analyzeRebuildOutput(lines, testRebuildPups())
+
+	require.Len(t, attributions, 1)
+	assert.Equal(t, "abc123", attributions[0].PupID)
+	assert.Equal(t, "Dogecoin Core", attributions[0].PupName)
+	assert.Equal(t, "container@pup-abc123.service", attributions[0].Component)
+}
+
+func TestAnalyzeRebuildOutputAttributesPupFileAndDerivation(t *testing.T) {
+	lines := []string{
+		"error: undefined variable 'foo' at /etc/nixos/dogebox/pup_abc123.nix:12:3",
+		"error: builder for '/nix/store/0123456789abcdfghijklmnpqrsvwxyz-dogecoind-1.14.9.drv' failed with exit code 2",
+		"error: builder for '/nix/store/0123456789abcdfghijklmnpqrsvwxyz-openssl-3.0.drv' failed with exit code 2",
+	}
+
+	attributions := analyzeRebuildOutput(lines, testRebuildPups())
+
+	require.Len(t, attributions, 3)
+	assert.Equal(t, "pup_abc123.nix", attributions[0].Component)
+	assert.Equal(t, "abc123", attributions[0].PupID)
+	assert.Equal(t, "dogecoind-1.14.9", attributions[1].Component)
+	assert.Equal(t, "abc123", attributions[1].PupID)
+	assert.Equal(t, "openssl-3.0", attributions[2].Component)
+	assert.Equal(t, "", attributions[2].PupID)
+}
+
+func TestPupForDerivationOnlyMatchesWholeServiceNames(t *testing.T) {
+	pups := map[string]dogeboxd.PupState{
+		"abc123": {ID: "abc123", Manifest: dogeboxd.PupManifest{Container: dogeboxd.PupManifestContainer{
+			Services: []dogeboxd.PupManifestService{{Name: "node"}, {Name: "core"}},
+		}}},
+	}
+
+	assert.Equal(t, "abc123", pupForDerivation("node", pups))
+	assert.Equal(t, "abc123", pupForDerivation("node-1.2.3", pups))
+	assert.Equal(t, "", pupForDerivation("nodejs-20.11.1", pups))
+	assert.Equal(t, "", pupForDerivation("coreutils-9.4", pups))
+}
+
+func TestPupForDerivationIsAmbiguousWhenPupsShareAService(t *testing.T) {
+	pups := map[string]dogeboxd.PupState{
+		"abc123": {ID: "abc123", Manifest: dogeboxd.PupManifest{Container: dogeboxd.PupManifestContainer{
+			Services: []dogeboxd.PupManifestService{{Name: "dogecoind"}},
+		}}},
+		"def456": {ID: "def456", Manifest: dogeboxd.PupManifest{Container: dogeboxd.PupManifestContainer{
+			Services: []dogeboxd.PupManifestService{{Name: "dogecoind"}},
+		}}},
+	}
+
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, "", pupForDerivation("dogecoind-1.14.9", pups))
+	}
+}
+
+func TestAnalyzeRebuildOutputAttributesSystemUnit(t *testing.T) {
+	lines := []string{
+		"Job for dkm.service failed because the control process exited with error code.",
+		"warning: the following units failed: dkm.service",
+	}
+
+	attributions := analyzeRebuildOutput(lines, testRebuildPups())
+
+	require.Len(t, attributions, 1)
+	assert.Equal(t, "dkm.service", attributions[0].Component)
+	assert.Equal(t, "", attributions[0].PupID)
+}
+
+func TestTeeLoggerCapturesCommandsAndLines(t *testing.T) {
+	output := &rebuildOutput{}
+	log := teeLogger{SubLogger: dogeboxd.NewConsoleSubLogger("", "test"), lines: output.add}
+
+	// As the sudo runner runs it.
+	cmd := exec.Command("sh", "-c", "echo out; echo err >&2")
+	log.LogCmd(cmd)
+	require.NoError(t, cmd.Run())
+	// As rootd streams it.
+	log.Log("streamed")
+
+	assert.ElementsMatch(t, []string{"out", "err", "streamed"}, output.Lines())
+}
+
+func TestDescribeJobErrorIncludesAttribution(t *testing.T) {
+	err := &dogeboxd.NixRebuildError{
+		Err:          errors.New("exit status 1"),
+		Attributions: analyzeRebuildOutput([]string{"the following units failed: container@pup-abc123.service"}, testRebuildPups()),
+	}
+
+	assert.Equal(t,
+		"Failed to enable pup: rebuild failed due to pup Dogecoin Core (container@pup-abc123.service: unit failed to start)",
+		dogeboxd.DescribeJobError("Failed to enable pup", err))
+	assert.Equal(t, "Failed to enable pup", dogeboxd.DescribeJobError("Failed to enable pup", errors.New("boom")))
+}
@@ -0,0 +1,75 @@
+package nix
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+// Where the system profile and its generation links live, and the
+// links to what's running and what was booted. Swappable for tests.
+var (
+	systemProfileDir  = "/nix/var/nix/profiles"
+	currentSystemLink = "/run/current-system"
+	bootedSystemLink  = "/run/booted-system"
+)
+
+var systemGenerationLinkRegex = regexp.MustCompile(`^system-([0-9]+)-link$`)
+
+/* ListSystemGenerations reads the generations straight from the profile
+ * links, as nixos-rebuild list-generations does, without needing root.
+ */
+func (nm nixManager) ListSystemGenerations() ([]dogeboxd.SystemGeneration, error) {
+	entries, err := os.ReadDir(systemProfileDir)
+	if err != nil {
+		return nil, err
+	}
+
+	current, _ := os.Readlink(filepath.Join(systemProfileDir, "system"))
+	running, _ := filepath.EvalSymlinks(currentSystemLink)
+	booted, _ := filepath.EvalSymlinks(bootedSystemLink)
+
+	generations := []dogeboxd.SystemGeneration{}
+	for _, entry := range entries {
+		m := systemGenerationLinkRegex.FindStringSubmatch(entry.Name())
+		if m == nil {
+			continue
+		}
+		number, err := strconv.Atoi(m[1])
+		if err != nil {
+			continue
+		}
+
+		link := filepath.Join(systemProfileDir, entry.Name())
+		info, err := os.Lstat(link)
+		if err != nil {
+			continue
+		}
+		storePath, err := filepath.EvalSymlinks(link)
+		if err != nil {
+			// Collected out from under its link.
+			continue
+		}
+		version, _ := os.ReadFile(filepath.Join(storePath, "nixos-version"))
+
+		generations = append(generations, dogeboxd.SystemGeneration{
+			Number:       number,
+			Date:         info.ModTime(),
+			StorePath:    storePath,
+			NixOSVersion: strings.TrimSpace(string(version)),
+			Current:      entry.Name() == current,
+			Running:      storePath == running,
+			Booted:       storePath == booted,
+		})
+	}
+
+	sort.Slice(generations, func(i, j int) bool {
+		return generations[i].Number < generations[j].Number
+	})
+	return generations, nil
+}
@@ -0,0 +1,49 @@
+package nix
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListSystemGenerations(t *testing.T) {
+	root := t.TempDir()
+	store := filepath.Join(root, "store")
+	profiles := filepath.Join(root, "profiles")
+	require.NoError(t, os.MkdirAll(profiles, 0755))
+
+	for _, name := range []string{"gen-9", "gen-10", "gen-11"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(store, name), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(store, name, "nixos-version"), []byte(name+"\n"), 0644))
+	}
+	require.NoError(t, os.Symlink(filepath.Join(store, "gen-9"), filepath.Join(profiles, "system-9-link")))
+	require.NoError(t, os.Symlink(filepath.Join(store, "gen-10"), filepath.Join(profiles, "system-10-link")))
+	require.NoError(t, os.Symlink(filepath.Join(store, "gen-11"), filepath.Join(profiles, "system-11-link")))
+	// Garbage collected from under its link.
+	require.NoError(t, os.Symlink(filepath.Join(store, "gone"), filepath.Join(profiles, "system-8-link")))
+	require.NoError(t, os.Symlink("system-11-link", filepath.Join(profiles, "system")))
+	require.NoError(t, os.Symlink(filepath.Join(store, "gen-10"), filepath.Join(root, "current-system")))
+	require.NoError(t, os.Symlink(filepath.Join(store, "gen-9"), filepath.Join(root, "booted-system")))
+
+	origProfiles, origCurrent, origBooted := systemProfileDir, currentSystemLink, bootedSystemLink
+	defer func() { systemProfileDir, currentSystemLink, bootedSystemLink = origProfiles, origCurrent, origBooted }()
+	systemProfileDir = profiles
+	currentSystemLink = filepath.Join(root, "current-system")
+	bootedSystemLink = filepath.Join(root, "booted-system")
+
+	generations, err := nixManager{}.ListSystemGenerations()
+	require.NoError(t, err)
+	require.Len(t, generations, 3)
+
+	assert.Equal(t, 9, generations[0].Number)
+	assert.Equal(t, "gen-9", generations[0].NixOSVersion)
+	assert.True(t, generations[0].Booted)
+	assert.True(t, generations[1].Running)
+	assert.False(t, generations[1].Current)
+	assert.Equal(t, 11, generations[2].Number)
+	assert.True(t, generations[2].Current)
+	assert.False(t, generations[2].Running)
+}
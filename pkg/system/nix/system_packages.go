@@ -0,0 +1,66 @@
+package nix
+
+import (
+	"os/exec"
+	"sort"
+	"strings"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+// The running system's profile, whose direct references are the
+// packages from environment.systemPackages.
+const currentSystemProfile = "/run/current-system/sw"
+
+// Swappable for tests.
+var querySystemProfileReferences = func() (string, error) {
+	out, err := exec.Command("nix-store", "--query", "--references", currentSystemProfile).Output()
+	return string(out), err
+}
+
+func (nm nixManager) ListSystemPackages() ([]dogeboxd.SystemPackage, error) {
+	out, err := querySystemProfileReferences()
+	if err != nil {
+		return nil, err
+	}
+	return parseSystemPackages(out), nil
+}
+
+func parseSystemPackages(out string) []dogeboxd.SystemPackage {
+	packages := []dogeboxd.SystemPackage{}
+	for _, line := range strings.Split(out, "\n") {
+		path := strings.TrimSpace(line)
+		if path == "" {
+			continue
+		}
+		name, version := splitStorePathName(path)
+		packages = append(packages, dogeboxd.SystemPackage{
+			Name:      name,
+			Version:   version,
+			StorePath: path,
+		})
+	}
+
+	sort.Slice(packages, func(i, j int) bool {
+		return packages[i].Name < packages[j].Name
+	})
+	return packages
+}
+
+// splitStorePathName splits /nix/store/<hash>-<name>-<version> the same way
+// nix's parseDrvName does: the version starts at the first dash that isn't
+// followed by a letter.
+func splitStorePathName(path string) (string, string) {
+	base := path[strings.LastIndex(path, "/")+1:]
+	if _, rest, ok := strings.Cut(base, "-"); ok {
+		base = rest
+	}
+
+	for i := 0; i < len(base)-1; i++ {
+		c := base[i+1]
+		if base[i] == '-' && !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') {
+			return base[:i], base[i+1:]
+		}
+	}
+	return base, ""
+}
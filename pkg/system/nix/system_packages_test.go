@@ -0,0 +1,37 @@
+package nix
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitStorePathName(t *testing.T) {
+	cases := map[string][2]string{
+		"/nix/store/0c7c4h3p6ps6qfbhfqdjpdlm2vgkdxwz-git-2.44.0":           {"git", "2.44.0"},
+		"/nix/store/0c7c4h3p6ps6qfbhfqdjpdlm2vgkdxwz-python3-3.11.8-env":   {"python3", "3.11.8-env"},
+		"/nix/store/0c7c4h3p6ps6qfbhfqdjpdlm2vgkdxwz-nixos-rebuild":        {"nixos-rebuild", ""},
+		"/nix/store/0c7c4h3p6ps6qfbhfqdjpdlm2vgkdxwz-e2fsprogs-1.47.0-bin": {"e2fsprogs", "1.47.0-bin"},
+	}
+
+	for path, want := range cases {
+		name, version := splitStorePathName(path)
+		assert.Equal(t, want[0], name, path)
+		assert.Equal(t, want[1], version, path)
+	}
+}
+
+func TestListSystemPackages(t *testing.T) {
+	orig := querySystemProfileReferences
+	defer func() { querySystemProfileReferences = orig }()
+	querySystemProfileReferences = func() (string, error) {
+		return "/nix/store/0c7c4h3p6ps6qfbhfqdjpdlm2vgkdxwz-vim-9.1.0\n/nix/store/1c7c4h3p6ps6qfbhfqdjpdlm2vgkdxwz-curl-8.6.0\n\n", nil
+	}
+
+	packages, err := nixManager{}.ListSystemPackages()
+	assert.NoError(t, err)
+	assert.Len(t, packages, 2)
+	assert.Equal(t, "curl", packages[0].Name)
+	assert.Equal(t, "8.6.0", packages[0].Version)
+	assert.Equal(t, "vim", packages[1].Name)
+}
@@ -9,15 +9,22 @@
       ./network.nix
       ./system_container_config.nix
     ]
-    # Optional custom configuration (only if it has been created)
-    ++ lib.optionals (builtins.pathExists {{ .DATA_DIR }}/custom.nix) [
-      {{ .DATA_DIR }}/custom.nix
-    ]
+    # Optional custom configuration (only if it has been created).
+    # A dry build validating an unsaved custom.nix points DBX_CUSTOM_NIX at it.
+    ++ (let
+          override = builtins.getEnv "DBX_CUSTOM_NIX";
+          custom = if override != "" then /. + override else {{ .DATA_DIR }}/custom.nix;
+        in lib.optionals (builtins.pathExists custom) [ custom ])
     # Optional storage overlay (only if present in the nix dir)
     ++ lib.optionals (builtins.pathExists "{{ .NIX_DIR }}/storage-overlay.nix") [
       {{ .NIX_DIR }}/storage-overlay.nix
     ]
-    # Optional pup containers (only if their nix files exist)
+    # Optional recovery access point (only while AP mode is enabled)
+    ++ lib.optionals (builtins.pathExists "{{ .NIX_DIR }}/recovery_ap.nix") [
+      {{ .NIX_DIR }}/recovery_ap.nix
+    ]
+    # Optional pup containers (only if their nix files exist){{ if .SAFE_MODE }}
+    # Safe mode is enabled, so all pup containers have been left out.{{ end }}
     {{range .PUP_IDS}}++ lib.optionals (builtins.pathExists ./pup_{{.}}.nix) [ ./pup_{{.}}.nix ]
     {{end}}
     ;
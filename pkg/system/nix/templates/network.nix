@@ -4,13 +4,28 @@
   services.create_ap.enable = lib.mkForce false; # Disable create_ap in case it was enabled by the T6 Installer.
 
   networking = {
-    {{if .USE_ETHERNET}}
+    {{if or .USE_ETHERNET .STATIC_ADDRESS}}
     interfaces = {
       {{.INTERFACE}} = {
+        {{if .STATIC_ADDRESS}}
+        useDHCP = false;
+        ipv4.addresses = [
+          { address = "{{.STATIC_ADDRESS}}"; prefixLength = {{.STATIC_PREFIX_LENGTH}}; }
+        ];
+        {{else}}
         useDHCP = true;
+        {{end}}
       };
     };
-    {{else if .USE_WIRELESS}}
+    {{end}}
+    {{if .STATIC_ADDRESS}}
+    defaultGateway = {
+      address = "{{.GATEWAY}}";
+      interface = "{{.INTERFACE}}";
+    };
+    nameservers = [ {{range .DNS}}"{{.}}" {{end}}];
+    {{end}}
+    {{if .USE_WIRELESS}}
     wireless = {
       iwd = {
         enable = true;
@@ -23,5 +38,12 @@
       };
     };
     {{end}}
+    {{if or .HTTP_PROXY .HTTPS_PROXY}}
+    proxy = {
+      {{if .HTTP_PROXY}}httpProxy = "{{.HTTP_PROXY}}";{{end}}
+      {{if .HTTPS_PROXY}}httpsProxy = "{{.HTTPS_PROXY}}";{{end}}
+      noProxy = "{{.NO_PROXY}}";
+    };
+    {{end}}
   };
 }
@@ -2,7 +2,13 @@
 
 let
   pupOverlay = self: super: {
-    pup = import {{.NIX_FILE}} { inherit pkgs; };
+    pup = (import {{.NIX_FILE}} { inherit pkgs; }) // {
+      {{ range .CLOSURES }}"{{.SERVICE}}" = builtins.fetchClosure {
+        fromStore = "{{.FROM_STORE}}";
+        fromPath = {{.STORE_PATH}};
+      };
+      {{ end }}
+    };
   };
 
   pupConfig = import {{.NIX_FILE}} { inherit pkgs; };
@@ -24,7 +30,7 @@ in
     partOf = [ "container@pup-{{.PUP_ID}}.service" ];
     requires = [ "container@pup-{{.PUP_ID}}.service" ];
     serviceConfig = {
-      ExecStart = "${pkgs.bash}/bin/bash -c '${pkgs.systemd}/bin/journalctl -M pup-{{.PUP_ID}} -f --no-hostname -o short-iso >> {{.CONTAINER_LOG_DIR}}/pup-{{.PUP_ID}}'";
+      ExecStart = "${pkgs.bash}/bin/bash -c '${pkgs.systemd}/bin/journalctl -M pup-{{.PUP_ID}} -f --no-hostname -o short-iso --cursor-file={{.CONTAINER_LOG_DIR}}/.pup-{{.PUP_ID}}.cursor >> {{.CONTAINER_LOG_DIR}}/pup-{{.PUP_ID}}'";
       Restart = "always";
       User = "root";
       StandardOutput = "null";
@@ -35,8 +41,8 @@ in
 
   containers.pup-{{.PUP_ID}} = {
 
-    # If our pup is enabled, we set it to autostart on boot.
-    autoStart = {{.PUP_ENABLED}};
+    # If our pup is enabled (and hasn't been set to start by hand), we set it to autostart on boot.
+    autoStart = {{.PUP_AUTO_START}};
 
     # Set up private networking. This will ensure the pup gets an internal IP
     # in the range of 10.69.0.0/8, be able to to dogeboxd at 10.69.0.1, but not
@@ -75,13 +81,21 @@ in
         "hidraw0"  = { mountPoint = "/dev/hidraw0";  hostPath = "/dev/hidraw0";  isReadOnly = false; };
         "hidraw1"  = { mountPoint = "/dev/hidraw1";  hostPath = "/dev/hidraw1";  isReadOnly = false; };
       })
+      {
+        # Devices the user approved passing through to this pup.
+        {{ range .DEVICES }}"{{.PATH}}" = { mountPoint = "{{.PATH}}"; hostPath = "{{.PATH}}"; isReadOnly = {{.READ_ONLY}}; };
+        {{ end }}
+      }
     ];
 
-    allowedDevices = lib.optionals pupEnclave [
+    allowedDevices = (lib.optionals pupEnclave [
       { node = "/dev/tee0";     modifier = "rwm"; }
       { node = "/dev/teepriv0"; modifier = "rwm"; }
       { node = "char-usb_device"; modifier = "rwm"; }
       { node = "char-hidraw";     modifier = "rwm"; }
+    ]) ++ [
+      {{ range .DEVICES }}{ node = "{{.NODE}}"; modifier = "{{.MODIFIER}}"; }
+      {{ end }}
     ];
 
     ephemeral = true;
@@ -116,6 +130,15 @@ in
         };
       };
 
+      {{ if .TRUSTED_CAS }}
+      # CA certificates the user has chosen for this pup to trust.
+      security.pki.certificates = [
+        {{ range .TRUSTED_CAS }}''
+{{ . }}''
+        {{ end }}
+      ];
+      {{ end }}
+
       # Create a group & user for running the pup executable as.
       # Explicitly set IDs so that bind mounts can be chown'd on the host.
       users.groups.pup = {
@@ -127,6 +150,11 @@ in
         isSystemUser = true;
         isNormalUser = false;
         group =  "pup";
+        {{ if .DEVICES }}
+        # Passed through devices keep the host's groups, which have the same
+        # static IDs in here.
+        extraGroups = [ "dialout" "video" "render" ];
+        {{ end }}
       };
 
       environment.systemPackages = with pkgs; (
@@ -153,6 +181,35 @@ in
         }
       ];
 
+      {{ if .MIGRATIONS }}
+      # Run any migrations from the last upgrade before the pup's services start.
+      # Each one leaves a marker in storage, so it only ever runs once.
+      systemd.services.dbx-migrations = {
+        description = "Run pending pup upgrade migrations";
+        after = [ "network.target" ];
+        wantedBy = [ "multi-user.target" ];
+
+        script = ''
+          mkdir -p /storage/.migrations
+          {{ range .MIGRATIONS }}
+          if [ ! -e "/storage/.migrations/{{.ID}}.done" ]; then
+            echo "Running migration {{.ID}}"
+            ${pkgs.pup.{{.SERVICE}}}{{.EXEC}}
+            touch "/storage/.migrations/{{.ID}}.done"
+          fi
+          {{ end }}
+        '';
+
+        serviceConfig = {
+          Type = "oneshot";
+          RemainAfterExit = true;
+          User = "pup";
+          Group = "pup";
+          EnvironmentFile = "-/storage/.dbx/config.env";
+        };
+      };
+      {{ end }}
+
       # Create a systemd service for any unmanaged binary the pup wants to start.
       {{range .SERVICES}}
 
@@ -165,12 +222,25 @@ in
 
       # We keep this as the base service name, even if we're in development mode.
       systemd.services.{{.NAME}} = {
-        after = [ "network.target" ];
+        after = [ "network.target" {{ if $.MIGRATIONS }}"dbx-migrations.service" {{ end }}];
+        {{ if $.MIGRATIONS }}requires = [ "dbx-migrations.service" ];{{ end }}
         wantedBy = [ "multi-user.target" ];
 
         serviceConfig = {
           ExecStart = "${pkgs.pup.{{$SERVICE_NAME}}}{{.EXEC}}";
           Restart = "always";
+          {{ if .NOTIFY }}
+          # The service sends READY=1 over sd_notify once it's ready to serve.
+          # Anything it launches may send it, for pups started from a script.
+          Type = "notify";
+          NotifyAccess = "all";
+          TimeoutStartSec = {{.READY_TIMEOUT}};
+          {{ end }}
+          {{ if $.STOP_TIMEOUT }}
+          # Give the pup time to shut down cleanly before it's killed.
+          KillSignal = "{{$.STOP_SIGNAL}}";
+          TimeoutStopSec = {{$.STOP_TIMEOUT}};
+          {{ end }}
           User = "pup";
           Group = "pup";
 
@@ -203,4 +273,46 @@ in
 
   # Add a start condition to this container so it will only start in non-recovery mode.
   systemd.services."container@pup-{{.PUP_ID}}".serviceConfig.ExecCondition = "/run/wrappers/bin/dbx can-pup-start --data-dir {{.DATA_DIR}} --systemd --pup-id {{.PUP_ID}}";
+  {{if .CPU_QUOTA}}
+
+  # Limit the CPU time the whole container can use.
+  systemd.services."container@pup-{{.PUP_ID}}".serviceConfig.CPUQuota = "{{.CPU_QUOTA}}";
+  {{end}}
+  {{if .CONTAINER_STOP_TIMEOUT}}
+
+  # Wait for the pup's services to stop cleanly before killing the container.
+  systemd.services."container@pup-{{.PUP_ID}}".serviceConfig.TimeoutStopSec = "{{.CONTAINER_STOP_TIMEOUT}}";
+  {{end}}
+  {{if .CONTAINER_START_TIMEOUT}}
+
+  # The container only finishes starting once the pup's notify services
+  # report ready, so give it as long as they have.
+  containers.pup-{{.PUP_ID}}.timeoutStartSec = "{{.CONTAINER_START_TIMEOUT}}";
+  {{end}}
+  {{if .MEMORY_MAX}}
+
+  # Limit the memory the whole container can use, the kernel OOM kills
+  # within the container past this.
+  systemd.services."container@pup-{{.PUP_ID}}".serviceConfig.MemoryMax = "{{.MEMORY_MAX}}";
+  {{end}}
+  {{if and .PUP_ENABLED .RESTART_SCHEDULE}}
+
+  # Periodically restart this pup. try-restart leaves the container alone if
+  # it has been stopped by hand, rather than bringing it back up.
+  systemd.services."pup-restart-{{.PUP_ID}}" = {
+    description = "Scheduled restart of pup-{{.PUP_ID}}";
+    serviceConfig = {
+      Type = "oneshot";
+      ExecStart = "${pkgs.systemd}/bin/systemctl try-restart container@pup-{{.PUP_ID}}.service";
+    };
+  };
+
+  systemd.timers."pup-restart-{{.PUP_ID}}" = {
+    wantedBy = [ "timers.target" ];
+    timerConfig = {
+      OnCalendar = "{{.RESTART_SCHEDULE}}";
+      Persistent = false;
+    };
+  };
+  {{end}}
 }
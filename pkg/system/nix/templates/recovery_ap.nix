@@ -1,18 +1,34 @@
-{ config, pkgs, ... }:
+{ config, pkgs, lib, ... }:
 
 {
   services.create_ap = {
-    enable = {{ .AP_ENABLED }};
+    # network.nix forces create_ap off, so we need a (slightly) higher priority than mkForce.
+    enable = lib.mkOverride 49 true;
     settings = {
       FREQ_BAND = "2.4";
-      GATEWAY = "10.0.0.69";
+      GATEWAY = "{{ .GATEWAY }}";
       ISOLATE_CLIENTS = 1;
       WPA_VERSION = 2;
+      PASSPHRASE = "{{ .PASSPHRASE }}";
+      # AP clients only ever need to reach the setup UI, never our uplink.
+      SHARE_METHOD = "none";
       # Bug in this service. This needs to be passed.
-      INTERNET_IFACE = {{ .INTERFACE }};
-      WIFI_IFACE = {{ .INTERFACE }};
-      SSID = {{ .SSID }};
-      PASSPHRASE = {{ .PASSWORD }};
+      INTERNET_IFACE = "{{ .INTERFACE }}";
+      WIFI_IFACE = "{{ .INTERFACE }}";
+      SSID = "{{ .SSID }}";
+      {{ if .COUNTRY }}COUNTRY = "{{ .COUNTRY }}";{{ end }}
     };
   };
+
+  # Only open the UI on the AP's own interface, not on {{ .INTERFACE }}.
+  networking.firewall.interfaces."{{ .AP_INTERFACE }}".allowedTCPPorts = [ {{ .UI_PORT }} ];
+
+  # Send any plain HTTP request from an AP client to the setup UI, so
+  # captive portal checks on phones & laptops open it automatically.
+  networking.firewall.extraCommands = ''
+    iptables -t nat -A PREROUTING -i {{ .AP_INTERFACE }} -s {{ .SUBNET }} -p tcp --dport 80 -j DNAT --to-destination {{ .GATEWAY }}:{{ .UI_PORT }}
+  '';
+  networking.firewall.extraStopCommands = ''
+    iptables -t nat -D PREROUTING -i {{ .AP_INTERFACE }} -s {{ .SUBNET }} -p tcp --dport 80 -j DNAT --to-destination {{ .GATEWAY }}:{{ .UI_PORT }} || true
+  '';
 }
@@ -1,6 +1,11 @@
 { pkgs, ... }:
 
 {
+{{- if .STORAGE_MIRROR_DEVICE }}
+  # The data volume is a btrfs raid1 mirrored across two disks.
+  boot.supportedFilesystems = [ "btrfs" ];
+{{- end }}
+
   # Ideally we'd use nix .fileSystems.<name> here, but it doesn't seem to work?
 
   systemd.services.mount-data-overlay = {
@@ -12,8 +17,21 @@
       RemainAfterExit = "yes";
     };
     script = ''
+      ${pkgs.coreutils}/bin/mkdir -p {{ .STORAGE_STATUS_DIR }}
       if ! ${pkgs.util-linux}/bin/mountpoint -q {{ .DATA_DIR }}; then
-        ${pkgs.util-linux}/bin/mount {{ .STORAGE_DEVICE }} {{ .DATA_DIR }}
+        ${pkgs.coreutils}/bin/rm -f {{ .STORAGE_STATUS_DIR }}/degraded
+{{- if .STORAGE_MIRROR_DEVICE }}
+        ${pkgs.btrfs-progs}/bin/btrfs device scan
+        # With one disk missing the mirror only mounts degraded. Carry on with
+        # the disk we have, and leave a note so dogeboxd can warn about it.
+        if ! ${pkgs.util-linux}/bin/mount -t btrfs {{ if .STORAGE_UUID }}UUID={{ .STORAGE_UUID }}{{ else }}{{ .STORAGE_DEVICE }}{{ end }} {{ .DATA_DIR }}; then
+          echo "Mounting {{ .DATA_DIR }} failed, retrying degraded"
+          ${pkgs.util-linux}/bin/mount -t btrfs -o degraded {{ if .STORAGE_UUID }}UUID={{ .STORAGE_UUID }}{{ else }}{{ .STORAGE_DEVICE }}{{ end }} {{ .DATA_DIR }}
+          echo "mounted degraded at $(${pkgs.coreutils}/bin/date -Is)" > {{ .STORAGE_STATUS_DIR }}/degraded
+        fi
+{{- else }}
+        ${pkgs.util-linux}/bin/mount {{ if .STORAGE_UUID }}UUID={{ .STORAGE_UUID }}{{ else }}{{ .STORAGE_DEVICE }}{{ end }} {{ .DATA_DIR }}
+{{- end }}
         ${pkgs.coreutils}/bin/chown {{.DBX_UID}}:{{.DBX_UID}} {{.DATA_DIR}}
         ${pkgs.coreutils}/bin/chmod u+rwX,g+rwX,o-rwx {{ .DATA_DIR }}
       else
@@ -21,4 +39,57 @@
       fi
     '';
   };
+{{- if .STORAGE_MIRROR_DEVICE }}
+
+  # Hourly, note which disks the mirror can see and their error counters,
+  # so a disk dropping out after boot is noticed too.
+  systemd.services.dbx-storage-status = {
+    description = "Records the health of the data volume at {{.DATA_DIR}}";
+    after = [ "mount-data-overlay.service" ];
+    requires = [ "mount-data-overlay.service" ];
+    serviceConfig.Type = "oneshot";
+    script = ''
+      ${pkgs.btrfs-progs}/bin/btrfs filesystem show {{ .DATA_DIR }} > {{ .STORAGE_STATUS_DIR }}/filesystem.tmp
+      ${pkgs.coreutils}/bin/mv {{ .STORAGE_STATUS_DIR }}/filesystem.tmp {{ .STORAGE_STATUS_DIR }}/filesystem
+      ${pkgs.btrfs-progs}/bin/btrfs device stats {{ .DATA_DIR }} > {{ .STORAGE_STATUS_DIR }}/device-stats.tmp || true
+      ${pkgs.coreutils}/bin/mv {{ .STORAGE_STATUS_DIR }}/device-stats.tmp {{ .STORAGE_STATUS_DIR }}/device-stats
+    '';
+  };
+
+  systemd.timers.dbx-storage-status = {
+    wantedBy = [ "timers.target" ];
+    timerConfig = {
+      OnBootSec = "2min";
+      OnUnitActiveSec = "1h";
+    };
+  };
+
+  # Scrub monthly, so a bad copy is found (and repaired from the other
+  # disk) before the good one fails too.
+  systemd.services.dbx-storage-scrub = {
+    description = "Scrubs the data volume at {{.DATA_DIR}}";
+    after = [ "mount-data-overlay.service" ];
+    requires = [ "mount-data-overlay.service" ];
+    serviceConfig = {
+      Type = "oneshot";
+      Nice = 19;
+      IOSchedulingClass = "idle";
+    };
+    script = ''
+      # -B waits for the scrub to finish, so the status we keep is final.
+      ${pkgs.btrfs-progs}/bin/btrfs scrub start -B {{ .DATA_DIR }} || true
+      ${pkgs.btrfs-progs}/bin/btrfs scrub status {{ .DATA_DIR }} > {{ .STORAGE_STATUS_DIR }}/scrub.tmp
+      ${pkgs.coreutils}/bin/mv {{ .STORAGE_STATUS_DIR }}/scrub.tmp {{ .STORAGE_STATUS_DIR }}/scrub
+    '';
+  };
+
+  systemd.timers.dbx-storage-scrub = {
+    wantedBy = [ "timers.target" ];
+    timerConfig = {
+      OnCalendar = "monthly";
+      Persistent = true;
+      RandomizedDelaySec = "6h";
+    };
+  };
+{{- end }}
 }
@@ -38,6 +38,39 @@
     };
   };
 
+  {{ if .WIFI_REGULATORY_DOMAIN }}
+  hardware.wirelessRegulatoryDatabase = true;
+  boot.extraModprobeConfig = ''
+    options cfg80211 ieee80211_regdom="{{ .WIFI_REGULATORY_DOMAIN }}"
+  '';
+  {{ end }}
+
+  {{ if .ROOTD_SOCKET }}
+  # rootd runs dogeboxd's privileged operations, see `_dbxroot serve`. Only
+  # dogeboxd's group can reach its socket, and only dogeboxd's user may
+  # run anything. It isn't restarted by a switch, which it may be running.
+  systemd.tmpfiles.rules = [
+    "d ${dirOf "{{ .ROOTD_SOCKET }}"} 0750 root {{ .DBX_GID }} -"
+  ];
+
+  systemd.services.dbx-rootd = {
+    description = "Dogebox privileged operations";
+    wantedBy = [ "multi-user.target" ];
+    before = [ "dogeboxd.service" ];
+    after = [ "systemd-tmpfiles-setup.service" ];
+    path = [ "/run/wrappers" "/run/current-system/sw" ];
+    restartIfChanged = false;
+    serviceConfig = {
+      ExecStart = "/run/wrappers/bin/_dbxroot serve --socket {{ .ROOTD_SOCKET }} --gid {{ .DBX_GID }} --allow-uid {{ .DBX_UID }} --data-dir {{ .DATA_DIR }}";
+      Restart = "always";
+      RestartSec = 2;
+    };
+  };
+  {{ end }}
+
+  # Lets pups install prebuilt, content-addressed closures instead of building.
+  nix.settings.experimental-features = [ "fetch-closure" ];
+
   {{ if gt (len .BINARY_CACHE_SUBS) 0 }}
   nix.settings.substituters = [
     {{ range .BINARY_CACHE_SUBS }}"{{.}}"{{ end }}
@@ -0,0 +1,39 @@
+package system
+
+import (
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+func (t SystemUpdater) restoreNixConfigBackup(a dogeboxd.RestoreNixConfigBackup, log dogeboxd.SubLogger) error {
+	log.Logf("Restoring nix config backup %s", a.BackupID)
+
+	patch := t.nix.NewPatch(log)
+	if err := t.nix.RestoreConfigBackup(patch, a.BackupID); err != nil {
+		log.Errf("Failed to find nix config backup: %v", err)
+		patch.Cancel()
+		return err
+	}
+
+	log.Progress(5).Log("Applying restored system configuration...")
+
+	// Backing up the current config and extracting the restored one gets
+	// 5-40%, the rest is the rebuild.
+	if err := patch.ApplyCustom(dogeboxd.NixPatchApplyOptions{ProgressFrom: 5, ProgressTo: 40}); err != nil {
+		log.Errf("Failed to apply restored nix config: %v", err)
+		return err
+	}
+
+	log.Progress(100).Logf("Restored nix config backup %s", a.BackupID)
+	return nil
+}
+
+func (t SystemUpdater) backupNixConfig(log dogeboxd.SubLogger) error {
+	backup, err := t.nix.CreateConfigBackup(log)
+	if err != nil {
+		log.Errf("Failed to back up nix config: %v", err)
+		return err
+	}
+
+	log.Progress(100).Logf("Backed up nix config to %s", backup.ID)
+	return nil
+}
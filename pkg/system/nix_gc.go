@@ -0,0 +1,68 @@
+package system
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/Dogebox-WG/dogeboxd/pkg/rootd"
+)
+
+var errSystemUpdateRunning = errors.New("a system update is still rebuilding, try again once it has finished")
+
+// systemUpdateRunning is whether a system update, or rollback, is still
+// switching in its own unit, which keeps going if dogeboxd is restarted
+// so isn't covered by the job queue.
+func (t SystemUpdater) systemUpdateRunning() bool {
+	output, _ := t.runner.CombinedOutput(nil, rootd.UnitIsActive{Unit: dogeboxd.NIX_SYSTEM_UPDATE_UNIT})
+	switch strings.TrimSpace(string(output)) {
+	case "active", "activating", "reloading":
+		return true
+	}
+	return false
+}
+
+/* collectNixGarbage deletes old system generations and collects the
+ * store, recording the store's usage before and after in the box's
+ * NixGCSettings.
+ *
+ * Jobs run one at a time, so no other job is rebuilding, but a system
+ * update may still be switching, so that's checked first.
+ */
+func (t SystemUpdater) collectNixGarbage(j dogeboxd.Job, a dogeboxd.CollectNixGarbage) error {
+	log := j.Logger.Step("collect nix garbage")
+
+	if t.systemUpdateRunning() {
+		return errSystemUpdateRunning
+	}
+
+	keep := t.sm.Get().Dogebox.NixGC.KeepGenerationsFor(a)
+	before, err := dogeboxd.GetNixStoreUsage()
+	if err != nil {
+		log.Errf("Failed to measure nix store: %v", err)
+	} else {
+		log.Logf("Nix store disk has %d MB used, %d MB free", before.UsedBytes/1024/1024, before.FreeBytes/1024/1024)
+	}
+
+	started := time.Now()
+	log.Logf("Keeping the newest %d system generations", keep)
+	gcErr := t.runner.Run(log, rootd.CollectNixGarbage{KeepGenerations: keep})
+
+	after, err := dogeboxd.GetNixStoreUsage()
+	if err != nil {
+		log.Errf("Failed to measure nix store: %v", err)
+	}
+	run := dogeboxd.NewNixGCRun(j.ID, keep, started, before, after, gcErr)
+	if gcErr == nil {
+		log.Logf("Freed %d MB, %d MB now free", run.FreedBytes/1024/1024, after.FreeBytes/1024/1024)
+	}
+
+	dbxState := t.sm.Get().Dogebox
+	dbxState.NixGC.LastRun = &run
+	if err := t.sm.SetDogebox(dbxState); err != nil {
+		log.Errf("Failed to save garbage collection result: %v", err)
+	}
+
+	return gcErr
+}
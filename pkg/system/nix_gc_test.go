@@ -0,0 +1,60 @@
+package system
+
+import (
+	"testing"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func stubNixStoreUsage(t *testing.T, usages ...dogeboxd.NixStoreUsage) {
+	orig := dogeboxd.GetNixStoreUsage
+	t.Cleanup(func() { dogeboxd.GetNixStoreUsage = orig })
+	dogeboxd.GetNixStoreUsage = func() (dogeboxd.NixStoreUsage, error) {
+		u := usages[0]
+		usages = usages[1:]
+		return u, nil
+	}
+}
+
+func TestCollectNixGarbageRecordsUsage(t *testing.T) {
+	stubNixStoreUsage(t,
+		dogeboxd.NixStoreUsage{TotalBytes: 1000, UsedBytes: 900, FreeBytes: 100},
+		dogeboxd.NixStoreUsage{TotalBytes: 1000, UsedBytes: 600, FreeBytes: 400},
+	)
+	sm := newSafeModeTestStateManager(t)
+	dbxState := sm.Get().Dogebox
+	dbxState.NixGC.KeepGenerations = 3
+	require.NoError(t, sm.SetDogebox(dbxState))
+
+	runner := NewRecordingCommandRunner()
+	runner.Results["systemctl is-active"] = CommandResult{Output: []byte("inactive\n")}
+	updater := SystemUpdater{sm: sm, runner: runner}
+	job := testRunnerJob(dogeboxd.PupState{})
+
+	require.NoError(t, updater.collectNixGarbage(job, dogeboxd.CollectNixGarbage{}))
+
+	assert.Equal(t, []string{
+		"systemctl is-active dogebox-system-update.service",
+		"_dbxroot nix collect-garbage --keep-generations 3",
+	}, runner.Commands)
+	run := sm.Get().Dogebox.NixGC.LastRun
+	require.NotNil(t, run)
+	assert.Equal(t, job.ID, run.JobID)
+	assert.Equal(t, 3, run.KeepGenerations)
+	assert.Equal(t, uint64(300), run.FreedBytes)
+}
+
+func TestCollectNixGarbageRefusesDuringSystemUpdate(t *testing.T) {
+	sm := newSafeModeTestStateManager(t)
+	runner := NewRecordingCommandRunner()
+	runner.Results["systemctl is-active"] = CommandResult{Output: []byte("active\n")}
+	updater := SystemUpdater{sm: sm, runner: runner}
+
+	err := updater.collectNixGarbage(testRunnerJob(dogeboxd.PupState{}), dogeboxd.CollectNixGarbage{KeepGenerations: 2})
+
+	assert.ErrorIs(t, err, errSystemUpdateRunning)
+	assert.Equal(t, []string{"systemctl is-active dogebox-system-update.service"}, runner.Commands)
+	assert.Nil(t, sm.Get().Dogebox.NixGC.LastRun)
+}
@@ -0,0 +1,306 @@
+package system
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/Dogebox-WG/dogeboxd/pkg/rootd"
+	"github.com/Dogebox-WG/dogeboxd/pkg/tarball"
+	"github.com/Dogebox-WG/dogeboxd/pkg/version"
+	"golang.org/x/mod/semver"
+)
+
+// Limits on the bundle entries we read into memory.
+const (
+	maxBundleManifestSize  = 16 << 20
+	maxBundleSignatureSize = 4 << 10
+)
+
+// A bundle staged for switching to, see stageSystemUpdateBundle.
+type stagedSystemUpdateBundle struct {
+	Bundle dogeboxd.SystemUpdateBundle
+	// The release flake, like stageReleaseFlake's clone.
+	FlakeDir string
+	// The unpacked store export, "" if the bundle has none.
+	CacheDir string
+	// Stands in for a commit hash when naming the staged dir and unit.
+	Rev string
+	// The key the bundle was signed with.
+	SignedBy string
+}
+
+/* stageSystemUpdateBundle verifies an offline update bundle and unpacks
+ * it into tmpDir. The manifest's signature is checked before anything
+ * else is read, then each file is hashed as it's unpacked and must match
+ * the manifest, so nothing unsigned is ever built or imported.
+ */
+func stageSystemUpdateBundle(bundlePath string, tmpDir string, trustedKeys []string) (stagedSystemUpdateBundle, error) {
+	staged := stagedSystemUpdateBundle{}
+	if tmpDir == "" {
+		tmpDir = os.TempDir()
+	}
+
+	f, err := os.Open(bundlePath)
+	if err != nil {
+		return staged, fmt.Errorf("failed to open system update bundle: %w", err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return staged, fmt.Errorf("system update bundle isn't a gzipped tarball: %w", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+
+	bundle, manifest, signedBy, err := readSystemUpdateBundleManifest(tr, trustedKeys)
+	if err != nil {
+		return staged, err
+	}
+
+	rev := bundle.Rev
+	if rev == "" {
+		sum := sha256.Sum256(manifest)
+		rev = hex.EncodeToString(sum[:])
+	}
+
+	unpackDir, err := os.MkdirTemp(tmpDir, "os-upgrade-*")
+	if err != nil {
+		return staged, fmt.Errorf("failed to create temp dir for OS release %s: %w", bundle.Version, err)
+	}
+	defer os.RemoveAll(unpackDir)
+
+	if err := unpackBundleFiles(tr, bundle, unpackDir); err != nil {
+		return staged, err
+	}
+	flakeDir := filepath.Join(unpackDir, dogeboxd.SystemUpdateBundleFlakeDir)
+	cacheDir := filepath.Join(unpackDir, dogeboxd.SystemUpdateBundleStoreDir)
+
+	finalDir := buildStagedReleaseDirPath(tmpDir, bundle.Version, rev)
+	if err := os.RemoveAll(finalDir); err != nil {
+		return staged, fmt.Errorf("failed to clear existing staged OS release dir %s: %w", finalDir, err)
+	}
+	if err := os.Rename(flakeDir, finalDir); err != nil {
+		return staged, fmt.Errorf("failed to move staged OS release into %s: %w", finalDir, err)
+	}
+
+	if len(bundle.StorePaths) > 0 {
+		storeDir, err := os.MkdirTemp(tmpDir, "os-upgrade-store-*")
+		if err != nil {
+			_ = os.RemoveAll(finalDir)
+			return staged, fmt.Errorf("failed to create temp dir for OS release %s store: %w", bundle.Version, err)
+		}
+		// Only for a unique name, as Rename won't replace a directory.
+		if err := os.Remove(storeDir); err != nil {
+			_ = os.RemoveAll(finalDir)
+			return staged, err
+		}
+		if err := os.Rename(cacheDir, storeDir); err != nil {
+			_ = os.RemoveAll(finalDir)
+			_ = os.RemoveAll(storeDir)
+			return staged, fmt.Errorf("failed to move OS release %s store: %w", bundle.Version, err)
+		}
+		cacheDir = storeDir
+	} else {
+		cacheDir = ""
+	}
+
+	staged = stagedSystemUpdateBundle{
+		Bundle:   bundle,
+		FlakeDir: finalDir,
+		CacheDir: cacheDir,
+		Rev:      rev,
+		SignedBy: signedBy,
+	}
+	return staged, nil
+}
+
+// InspectSystemUpdateBundle verifies a bundle's manifest without unpacking
+// the rest of it, returning the manifest and the key that signed it.
+func InspectSystemUpdateBundle(bundlePath string, trustedKeys []string) (dogeboxd.SystemUpdateBundle, string, error) {
+	f, err := os.Open(bundlePath)
+	if err != nil {
+		return dogeboxd.SystemUpdateBundle{}, "", fmt.Errorf("failed to open system update bundle: %w", err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return dogeboxd.SystemUpdateBundle{}, "", fmt.Errorf("system update bundle isn't a gzipped tarball: %w", err)
+	}
+	defer gz.Close()
+
+	bundle, _, signedBy, err := readSystemUpdateBundleManifest(tar.NewReader(gz), trustedKeys)
+	return bundle, signedBy, err
+}
+
+// readSystemUpdateBundleManifest reads and verifies the manifest and
+// signature at the start of a bundle.
+func readSystemUpdateBundleManifest(tr *tar.Reader, trustedKeys []string) (dogeboxd.SystemUpdateBundle, []byte, string, error) {
+	manifest, err := readBundleEntry(tr, dogeboxd.SystemUpdateBundleManifestName, maxBundleManifestSize)
+	if err != nil {
+		return dogeboxd.SystemUpdateBundle{}, nil, "", err
+	}
+	signature, err := readBundleEntry(tr, dogeboxd.SystemUpdateBundleSignatureName, maxBundleSignatureSize)
+	if err != nil {
+		return dogeboxd.SystemUpdateBundle{}, nil, "", err
+	}
+
+	signedBy, err := dogeboxd.VerifySystemUpdateBundle(manifest, string(signature), trustedKeys)
+	if err != nil {
+		return dogeboxd.SystemUpdateBundle{}, nil, "", err
+	}
+	bundle, err := dogeboxd.ParseSystemUpdateBundle(manifest)
+	if err != nil {
+		return dogeboxd.SystemUpdateBundle{}, nil, "", err
+	}
+	return bundle, manifest, signedBy, nil
+}
+
+// readBundleEntry reads the next entry, which must be the file name.
+func readBundleEntry(tr *tar.Reader, name string, limit int64) ([]byte, error) {
+	header, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("system update bundle is missing %s: %w", name, err)
+	}
+	if path.Clean(strings.TrimPrefix(header.Name, "./")) != name || header.Typeflag != tar.TypeReg {
+		return nil, fmt.Errorf("system update bundle must start with %s, found %s", name, header.Name)
+	}
+	if header.Size > limit {
+		return nil, fmt.Errorf("system update bundle %s is too large", name)
+	}
+	return io.ReadAll(io.LimitReader(tr, limit))
+}
+
+// unpackBundleFiles unpacks the rest of a verified bundle into dir, as
+// laid out in the bundle, checking each file against the manifest.
+func unpackBundleFiles(tr *tar.Reader, bundle dogeboxd.SystemUpdateBundle, dir string) error {
+	seen := map[string]bool{}
+	err := tarball.Extract(tr, dir, tarball.Options{
+		Name: func(header *tar.Header, name string) (string, error) {
+			switch header.Typeflag {
+			case tar.TypeDir:
+				// Only directories of files the manifest lists are made,
+				// checked here as they aren't in Files themselves.
+				if name != dogeboxd.SystemUpdateBundleFlakeDir && name != dogeboxd.SystemUpdateBundleStoreDir && !bundleHasFilesUnder(bundle, name) {
+					return "", fmt.Errorf("system update bundle has unexpected directory %s", name)
+				}
+			case tar.TypeReg:
+				if _, ok := bundle.Files[name]; !ok || seen[name] {
+					return "", fmt.Errorf("system update bundle has unexpected file %s", name)
+				}
+				seen[name] = true
+			}
+			return name, nil
+		},
+		Copy: func(name string, w io.Writer, r io.Reader) error {
+			hash := sha256.New()
+			if _, err := io.Copy(io.MultiWriter(w, hash), r); err != nil {
+				return err
+			}
+			if hex.EncodeToString(hash.Sum(nil)) != bundle.Files[name] {
+				return fmt.Errorf("system update bundle file %s doesn't match the signed manifest", name)
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to unpack system update bundle: %w", err)
+	}
+
+	for name := range bundle.Files {
+		if !seen[name] {
+			return fmt.Errorf("system update bundle is missing %s", name)
+		}
+	}
+	for _, sub := range []string{dogeboxd.SystemUpdateBundleFlakeDir, dogeboxd.SystemUpdateBundleStoreDir} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func bundleHasFilesUnder(bundle dogeboxd.SystemUpdateBundle, dir string) bool {
+	for name := range bundle.Files {
+		if strings.HasPrefix(name, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Where _dbxroot reads which store paths to import, see rootd.ImportNixStore.
+func writeBundleStorePaths(cacheDir string, paths []string) error {
+	return os.WriteFile(filepath.Join(cacheDir, "store-paths"), []byte(strings.Join(paths, "\n")+"\n"), 0644)
+}
+
+/* doSystemUpdateFromFileWithDependencies stages an offline update bundle,
+ * imports its store export and switches to it, like
+ * doSystemUpdateWithDependencies but without fetching anything.
+ */
+func doSystemUpdateFromFileWithDependencies(
+	bundlePath string,
+	tmpDir string,
+	trustedKeys []string,
+	logger dogeboxd.SubLogger,
+	runner CommandRunner,
+	execCommand func(string, ...string) *exec.Cmd,
+) error {
+	logger.Logf("Verifying system update bundle %s", bundlePath)
+	staged, err := stageSystemUpdateBundle(bundlePath, tmpDir, trustedKeys)
+	if err != nil {
+		return err
+	}
+	if staged.CacheDir != "" {
+		defer os.RemoveAll(staged.CacheDir)
+	}
+
+	currentRelease := version.GetDBXRelease().Release
+	if semver.Compare(staged.Bundle.Version, currentRelease) <= 0 {
+		_ = os.RemoveAll(staged.FlakeDir)
+		return fmt.Errorf("system update bundle is for %s, which isn't newer than %s", staged.Bundle.Version, currentRelease)
+	}
+
+	logger.Progress(10).Logf("Starting system update to %s from a bundle signed by %s", staged.Bundle.Version, staged.SignedBy)
+
+	if staged.CacheDir != "" {
+		logger.Progress(20).Logf("Importing %d store paths from the bundle", len(staged.Bundle.StorePaths))
+		if err := writeBundleStorePaths(staged.CacheDir, staged.Bundle.StorePaths); err != nil {
+			_ = os.RemoveAll(staged.FlakeDir)
+			return fmt.Errorf("failed to write store path list: %w", err)
+		}
+		if err := runner.Run(logger, rootd.ImportNixStore{CacheDir: staged.CacheDir}); err != nil {
+			_ = os.RemoveAll(staged.FlakeDir)
+			return fmt.Errorf("failed to import the bundle's store paths: %w", err)
+		}
+	}
+
+	logger.Progress(50).Log("System pups are left for the next online update")
+
+	args := append(buildSystemUpdateCommandArgs(staged.FlakeDir, staged.Bundle.Version, buildSystemUpdateUnitName(staged.Bundle.Version, staged.Rev)), "--offline")
+	return runSystemUpdateCommand(execCommand, args, logger)
+}
+
+func (t SystemUpdater) DoSystemUpdateFromFile(a dogeboxd.SystemUpdateFromFile, logger dogeboxd.SubLogger) error {
+	if err := MigrateLegacyCustomNix(t.config); err != nil {
+		return err
+	}
+
+	// Uploads can be several GB, they're no use once unpacked or rejected.
+	if a.BundleID != "" {
+		defer os.Remove(a.Path)
+	}
+
+	return doSystemUpdateFromFileWithDependencies(a.Path, t.config.TmpDir, dogeboxd.RELEASE_SIGNING_KEYS, logger, t.runner, exec.Command)
+}
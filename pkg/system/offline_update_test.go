@@ -0,0 +1,198 @@
+package system
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testBundleStorePath = "/nix/store/0123456789abcdfghijklmnpqrsvwxyz-dogeboxd-0.9.1"
+
+type testBundleFile struct {
+	name string
+	data string
+}
+
+var testBundleFiles = []testBundleFile{
+	{"flake/flake.nix", "{ outputs = _: {}; }"},
+	{"flake/flake.lock", `{"nodes":{}}`},
+	{"store/nix-cache-info", "StoreDir: /nix/store\n"},
+	{"store/0123456789abcdfghijklmnpqrsvwxyz.narinfo", "NarSize: 10\n"},
+}
+
+type testBundleKey struct {
+	priv ed25519.PrivateKey
+	key  string
+}
+
+func newTestBundleKey(t *testing.T) testBundleKey {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return testBundleKey{priv: priv, key: "test-1:" + base64.StdEncoding.EncodeToString(pub)}
+}
+
+// writeTestBundle signs a manifest for files, then writes the bundle with
+// contents, which may differ from what was signed.
+func writeTestBundle(t *testing.T, key testBundleKey, signed []testBundleFile, contents []testBundleFile) string {
+	bundle := dogeboxd.SystemUpdateBundle{
+		FormatVersion: dogeboxd.SYSTEM_UPDATE_BUNDLE_FORMAT_VERSION,
+		Version:       "v1.2.0",
+		Rev:           "abcdef1234567890",
+		System:        dogeboxd.CurrentNixSystem(),
+		StorePaths:    []string{testBundleStorePath},
+		Files:         map[string]string{},
+	}
+	for _, f := range signed {
+		sum := sha256.Sum256([]byte(f.data))
+		bundle.Files[f.name] = hex.EncodeToString(sum[:])
+	}
+	manifest, err := json.Marshal(bundle)
+	require.NoError(t, err)
+	signature := "test-1:" + base64.StdEncoding.EncodeToString(ed25519.Sign(key.priv, manifest))
+
+	path := filepath.Join(t.TempDir(), "dogebox-v1.2.0"+dogeboxd.SYSTEM_UPDATE_BUNDLE_EXT)
+	out, err := os.Create(path)
+	require.NoError(t, err)
+	defer out.Close()
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+
+	entries := append([]testBundleFile{
+		{dogeboxd.SystemUpdateBundleManifestName, string(manifest)},
+		{dogeboxd.SystemUpdateBundleSignatureName, signature},
+	}, contents...)
+	for _, f := range entries {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0644, Size: int64(len(f.data)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(f.data))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return path
+}
+
+func TestStageSystemUpdateBundle(t *testing.T) {
+	key := newTestBundleKey(t)
+	path := writeTestBundle(t, key, testBundleFiles, testBundleFiles)
+	tmpDir := t.TempDir()
+
+	staged, err := stageSystemUpdateBundle(path, tmpDir, []string{key.key})
+	require.NoError(t, err)
+
+	assert.Equal(t, "v1.2.0", staged.Bundle.Version)
+	assert.Equal(t, "test-1", staged.SignedBy)
+	assert.Equal(t, buildStagedReleaseDirPath(tmpDir, "v1.2.0", "abcdef1234567890"), staged.FlakeDir)
+
+	flake, err := os.ReadFile(filepath.Join(staged.FlakeDir, "flake.nix"))
+	require.NoError(t, err)
+	assert.Equal(t, "{ outputs = _: {}; }", string(flake))
+	assert.FileExists(t, filepath.Join(staged.CacheDir, "nix-cache-info"))
+	assert.NoFileExists(t, filepath.Join(staged.FlakeDir, "nix-cache-info"))
+}
+
+func TestStageSystemUpdateBundleRejectsUntrustedBundles(t *testing.T) {
+	key := newTestBundleKey(t)
+	tampered := append([]testBundleFile{}, testBundleFiles...)
+	tampered[1] = testBundleFile{"flake/flake.lock", `{"nodes":{"evil":{}}}`}
+	extra := append(append([]testBundleFile{}, testBundleFiles...), testBundleFile{"flake/extra.nix", "{}"})
+
+	for name, tc := range map[string]struct {
+		path    string
+		keys    []string
+		wantErr string
+	}{
+		"untrusted key":  {writeTestBundle(t, key, testBundleFiles, testBundleFiles), []string{dogeboxd.FOUNDATION_OS_BINARY_CACHE_KEY}, "trusted key"},
+		"tampered file":  {writeTestBundle(t, key, testBundleFiles, tampered), []string{key.key}, "signed manifest"},
+		"unsigned file":  {writeTestBundle(t, key, testBundleFiles, extra), []string{key.key}, "unexpected file flake/extra.nix"},
+		"missing file":   {writeTestBundle(t, key, testBundleFiles, testBundleFiles[:3]), []string{key.key}, "is missing"},
+		"no bundle":      {filepath.Join(t.TempDir(), "missing.dbxupdate"), []string{key.key}, "failed to open"},
+		"unsigned names": {writeTestBundle(t, key, testBundleFiles, []testBundleFile{{"../escape", "x"}}), []string{key.key}, "outside the archive"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			_, err := stageSystemUpdateBundle(tc.path, tmpDir, tc.keys)
+			require.ErrorContains(t, err, tc.wantErr)
+
+			// Nothing is left behind from a rejected bundle.
+			entries, _ := os.ReadDir(tmpDir)
+			assert.Empty(t, entries)
+		})
+	}
+}
+
+func TestDoSystemUpdateFromFileImportsStoreAndSwitchesOffline(t *testing.T) {
+	tempDir := setupMockVersioning(t, "v1.1.0")
+	defer os.RemoveAll(tempDir)
+
+	key := newTestBundleKey(t)
+	path := writeTestBundle(t, key, testBundleFiles, testBundleFiles)
+	tmpDir := t.TempDir()
+	runner := NewRecordingCommandRunner()
+
+	var capturedArgs []string
+	execCommand := func(name string, args ...string) *exec.Cmd {
+		capturedArgs = append([]string{}, args...)
+		return exec.Command("sh", "-c", "exit 0")
+	}
+
+	logger := dogeboxd.NewConsoleSubLogger("", "system update")
+	require.NoError(t, doSystemUpdateFromFileWithDependencies(path, tmpDir, []string{key.key}, logger, runner, execCommand))
+
+	assert.True(t, runner.Ran("_dbxroot nix import-store --cache-dir "+tmpDir))
+	stagedDir := buildStagedReleaseDirPath(tmpDir, "v1.2.0", "abcdef1234567890")
+	expected := append(buildSystemUpdateCommandArgs(stagedDir, "v1.2.0", buildSystemUpdateUnitName("v1.2.0", "abcdef1234567890")), "--offline")
+	assert.Equal(t, expected, capturedArgs)
+
+	// The store export is only needed for the import.
+	matches, _ := filepath.Glob(filepath.Join(tmpDir, "os-upgrade-store-*"))
+	assert.Empty(t, matches)
+}
+
+func TestDoSystemUpdateFromFileStopsIfImportFails(t *testing.T) {
+	tempDir := setupMockVersioning(t, "v1.1.0")
+	defer os.RemoveAll(tempDir)
+
+	key := newTestBundleKey(t)
+	path := writeTestBundle(t, key, testBundleFiles, testBundleFiles)
+	runner := NewRecordingCommandRunner()
+	runner.Results["_dbxroot nix import-store"] = CommandResult{Err: errors.New("nix copy failed")}
+
+	ran := false
+	execCommand := func(name string, args ...string) *exec.Cmd {
+		ran = true
+		return exec.Command("sh", "-c", "exit 0")
+	}
+
+	logger := dogeboxd.NewConsoleSubLogger("", "system update")
+	err := doSystemUpdateFromFileWithDependencies(path, t.TempDir(), []string{key.key}, logger, runner, execCommand)
+	assert.ErrorContains(t, err, "nix copy failed")
+	assert.False(t, ran)
+}
+
+func TestDoSystemUpdateFromFileRefusesOlderReleases(t *testing.T) {
+	tempDir := setupMockVersioning(t, "v1.2.0")
+	defer os.RemoveAll(tempDir)
+
+	key := newTestBundleKey(t)
+	path := writeTestBundle(t, key, testBundleFiles, testBundleFiles)
+	runner := NewRecordingCommandRunner()
+
+	logger := dogeboxd.NewConsoleSubLogger("", "system update")
+	err := doSystemUpdateFromFileWithDependencies(path, t.TempDir(), []string{key.key}, logger, runner, exec.Command)
+	assert.ErrorContains(t, err, "isn't newer than v1.2.0")
+	assert.Empty(t, runner.Commands)
+}
@@ -0,0 +1,188 @@
+package system
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/Dogebox-WG/dogeboxd/pkg/rootd"
+)
+
+// deferPupChange writes a pup's config without rebuilding, recording it
+// as a pending change.
+func (t SystemUpdater) deferPupChange(nixPatch dogeboxd.NixPatch, state dogeboxd.PupState, log dogeboxd.SubLogger) error {
+	return t.deferPupChanges(nixPatch, []dogeboxd.PupState{state}, log)
+}
+
+// deferPupChanges writes the config of several pups without rebuilding,
+// recording a pending change for each.
+func (t SystemUpdater) deferPupChanges(nixPatch dogeboxd.NixPatch, states []dogeboxd.PupState, log dogeboxd.SubLogger) error {
+	if err := nixPatch.ApplyCustom(dogeboxd.NixPatchApplyOptions{DangerousNoRebuild: true}); err != nil {
+		log.Errf("Failed to write nix config: %v", err)
+		return err
+	}
+
+	dbxState := t.sm.Get().Dogebox
+	for _, state := range states {
+		dbxState.RecordPupPendingChange(state, state.Enabled)
+	}
+	if err := t.sm.SetDogebox(dbxState); err != nil {
+		return fmt.Errorf("failed to save pending change: %w", err)
+	}
+
+	for _, state := range states {
+		log.Logf("Rebuilds are deferred, %s will change once pending changes are applied", state.Manifest.Meta.Name)
+	}
+	return nil
+}
+
+/* applyPendingChanges rebuilds once for everything pending. Pups being
+ * disabled are stopped first, and enabled pups that don't start on boot
+ * are started after, as disablePup and enablePup would have.
+ */
+func (t SystemUpdater) applyPendingChanges(log dogeboxd.SubLogger) error {
+	pending := t.sm.Get().Dogebox.PendingChanges
+	if len(pending) == 0 {
+		log.Log("No pending changes to apply")
+		return nil
+	}
+
+	for _, c := range pending {
+		log.Logf("Applying: %s", c.Summary)
+		if c.Kind == dogeboxd.PENDING_CHANGE_PUP_DISABLED {
+			stop := rootd.PupStop{PupID: c.PupID}
+			if s, _, err := t.pupManager.GetPup(c.PupID); err == nil {
+				stop = pupStopOp(s)
+			}
+			if err := t.runner.Run(log, stop); err != nil {
+				log.Errf("Error executing _dbxroot pup stop: %v", err)
+				return err
+			}
+		}
+	}
+
+	if err := t.nix.Rebuild(log); err != nil {
+		log.Errf("Rebuild failed, pending changes were kept: %v", err)
+		return err
+	}
+
+	for _, c := range pending {
+		if c.Kind != dogeboxd.PENDING_CHANGE_PUP_ENABLED {
+			continue
+		}
+		state, _, err := t.pupManager.GetPup(c.PupID)
+		if err != nil {
+			log.Errf("Failed to find pup %s: %v", c.PupID, err)
+			continue
+		}
+		if err := t.startManualPup(state, log); err != nil {
+			return err
+		}
+	}
+
+	return t.clearPendingChanges(pending)
+}
+
+// diffPendingChanges builds the system with everything pending and logs
+// how its closure differs from the running system's, line by line, so it
+// streams to anyone following the job's log.
+func (t SystemUpdater) diffPendingChanges(log dogeboxd.SubLogger) error {
+	pending := t.sm.Get().Dogebox.PendingChanges
+	if len(pending) == 0 {
+		log.Log("No pending changes, building the current configuration")
+	}
+	for _, c := range pending {
+		log.Logf("Pending: %s", c.Summary)
+	}
+
+	if err := t.runner.Run(log, rootd.DiffSystem{}); err != nil {
+		log.Errf("Failed to build and diff the system: %v", err)
+		return err
+	}
+	return nil
+}
+
+// discardPendingChanges puts every pending change back as it was, and
+// rewrites the nix config to match what is running, without a rebuild.
+func (t SystemUpdater) discardPendingChanges(log dogeboxd.SubLogger) error {
+	pending := t.sm.Get().Dogebox.PendingChanges
+	if len(pending) == 0 {
+		log.Log("No pending changes to discard")
+		return nil
+	}
+
+	nixPatch := t.nix.NewPatch(log)
+	for _, c := range pending {
+		log.Logf("Discarding: %s", c.Summary)
+		switch c.Kind {
+		case dogeboxd.PENDING_CHANGE_PUP_ENABLED, dogeboxd.PENDING_CHANGE_PUP_DISABLED:
+			state, err := t.pupManager.UpdatePup(c.PupID, dogeboxd.PupEnabled(c.PreviousEnabled), dogeboxd.WithPupdateReason(dogeboxd.PupdateReasonFromLog(log)))
+			if err != nil {
+				log.Errf("Failed to restore %s: %v", c.PupID, err)
+				return err
+			}
+			t.nix.WritePupFile(nixPatch, state, t.sm.Get().Dogebox)
+
+		case dogeboxd.PENDING_CHANGE_CUSTOM_NIX:
+			if err := restoreCustomNix(t.config, c.PreviousContent); err != nil {
+				log.Errf("Failed to restore custom.nix: %v", err)
+				return err
+			}
+		}
+	}
+
+	if err := nixPatch.ApplyCustom(dogeboxd.NixPatchApplyOptions{DangerousNoRebuild: true}); err != nil {
+		log.Errf("Failed to write nix config: %v", err)
+		return err
+	}
+
+	return t.clearPendingChanges(pending)
+}
+
+// clearPendingChanges drops the given changes, keeping any recorded while
+// they were being applied or discarded.
+func (t SystemUpdater) clearPendingChanges(done []dogeboxd.PendingChange) error {
+	dbxState := t.sm.Get().Dogebox
+	remaining := []dogeboxd.PendingChange{}
+	for _, c := range dbxState.PendingChanges {
+		handled := false
+		for _, d := range done {
+			if c.ID == d.ID && c.Created.Equal(d.Created) {
+				handled = true
+				break
+			}
+		}
+		if !handled {
+			remaining = append(remaining, c)
+		}
+	}
+	dbxState.PendingChanges = remaining
+	return t.sm.SetDogebox(dbxState)
+}
+
+// ClearAppliedPendingChanges drops the pending changes a successful
+// switch that started at started has built, whichever job ran it.
+// Reports whether any were dropped.
+func ClearAppliedPendingChanges(sm dogeboxd.StateManager, started time.Time) (bool, error) {
+	cleared := false
+	err := sm.UpdateDogebox(func(dbxState *dogeboxd.DogeboxState) bool {
+		cleared = dbxState.ClearPendingChangesBefore(started)
+		return cleared
+	})
+	return cleared, err
+}
+
+// restoreCustomNix writes back custom.nix as it was, removing it if it
+// didn't exist.
+func restoreCustomNix(config dogeboxd.ServerConfig, content string) error {
+	path := GetCustomNixPath(config)
+	if content == "" {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	return os.WriteFile(path, []byte(content), 0644)
+}
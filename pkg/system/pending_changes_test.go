@@ -0,0 +1,121 @@
+package system
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type pendingNixPatch struct {
+	dogeboxd.NixPatch
+	applied []dogeboxd.NixPatchApplyOptions
+}
+
+func (p *pendingNixPatch) ApplyCustom(options dogeboxd.NixPatchApplyOptions) error {
+	p.applied = append(p.applied, options)
+	return nil
+}
+
+type pendingNixManager struct {
+	testNixManager
+	patch  *pendingNixPatch
+	runner *RecordingCommandRunner
+	// commands run before the rebuild
+	ranBeforeRebuild []string
+}
+
+func (m *pendingNixManager) NewPatch(log dogeboxd.SubLogger) dogeboxd.NixPatch { return m.patch }
+
+func (m *pendingNixManager) Rebuild(log dogeboxd.SubLogger) error {
+	m.ranBeforeRebuild = append([]string{}, m.runner.Commands...)
+	return nil
+}
+
+type pendingPupManager struct {
+	dogeboxd.PupManager
+	states map[string]dogeboxd.PupState
+}
+
+func (m *pendingPupManager) GetPup(id string) (dogeboxd.PupState, dogeboxd.PupStats, error) {
+	return m.states[id], dogeboxd.PupStats{}, nil
+}
+
+func (m *pendingPupManager) UpdatePup(id string, updates ...func(*dogeboxd.PupState, *[]dogeboxd.Pupdate)) (dogeboxd.PupState, error) {
+	state := m.states[id]
+	pupdates := []dogeboxd.Pupdate{}
+	for _, u := range updates {
+		u(&state, &pupdates)
+	}
+	m.states[id] = state
+	return state, nil
+}
+
+func newPendingTestUpdater(t *testing.T, pups map[string]dogeboxd.PupState, changes func(*dogeboxd.DogeboxState)) (SystemUpdater, *pendingNixManager, *RecordingCommandRunner) {
+	sm := newSafeModeTestStateManager(t)
+	dbxState := sm.Get().Dogebox
+	dbxState.DeferRebuilds = true
+	changes(&dbxState)
+	require.NoError(t, sm.SetDogebox(dbxState))
+
+	runner := NewRecordingCommandRunner()
+	nix := &pendingNixManager{patch: &pendingNixPatch{}, runner: runner}
+	updater := SystemUpdater{
+		config:     dogeboxd.ServerConfig{DataDir: t.TempDir()},
+		sm:         sm,
+		nix:        nix,
+		runner:     runner,
+		pupManager: &pendingPupManager{states: pups},
+	}
+	return updater, nix, runner
+}
+
+func TestApplyPendingChangesStopsBeforeRebuildAndStartsAfter(t *testing.T) {
+	manual := false
+	pups := map[string]dogeboxd.PupState{
+		"aaa": {ID: "aaa", Enabled: false},
+		"bbb": {ID: "bbb", Enabled: true, AutoStart: &manual},
+	}
+	updater, nix, runner := newPendingTestUpdater(t, pups, func(s *dogeboxd.DogeboxState) {
+		s.RecordPupPendingChange(pups["aaa"], false)
+		s.RecordPupPendingChange(pups["bbb"], true)
+	})
+
+	job := testRunnerJob(pups["aaa"])
+	require.NoError(t, updater.applyPendingChanges(job.Logger.Step("test")))
+
+	assert.Equal(t, []string{"_dbxroot pup stop --pupId aaa"}, nix.ranBeforeRebuild)
+	assert.Equal(t, []string{
+		"_dbxroot pup stop --pupId aaa",
+		"systemctl start container@pup-bbb.service",
+	}, runner.Commands)
+	assert.Empty(t, updater.sm.Get().Dogebox.PendingChanges)
+}
+
+func TestDiscardPendingChangesRestoresPreviousState(t *testing.T) {
+	pups := map[string]dogeboxd.PupState{
+		"aaa": {ID: "aaa", Enabled: true},
+	}
+	updater, nix, runner := newPendingTestUpdater(t, pups, func(s *dogeboxd.DogeboxState) {
+		s.RecordPupPendingChange(pups["aaa"], true)
+		s.RecordCustomNixPendingChange("{ }", "{ services.foo.enable = true; }")
+	})
+	customNixPath := GetCustomNixPath(updater.config)
+	require.NoError(t, os.MkdirAll(filepath.Dir(customNixPath), 0755))
+	require.NoError(t, os.WriteFile(customNixPath, []byte("{ services.foo.enable = true; }"), 0644))
+
+	job := testRunnerJob(pups["aaa"])
+	require.NoError(t, updater.discardPendingChanges(job.Logger.Step("test")))
+
+	assert.False(t, updater.pupManager.(*pendingPupManager).states["aaa"].Enabled)
+	content, err := os.ReadFile(customNixPath)
+	require.NoError(t, err)
+	assert.Equal(t, "{ }", string(content))
+
+	assert.Equal(t, []dogeboxd.NixPatchApplyOptions{{DangerousNoRebuild: true}}, nix.patch.applied)
+	assert.Empty(t, runner.Commands)
+	assert.Empty(t, updater.sm.Get().Dogebox.PendingChanges)
+}
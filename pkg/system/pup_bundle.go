@@ -0,0 +1,72 @@
+package system
+
+import (
+	"fmt"
+	"os"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/Dogebox-WG/dogeboxd/pkg/rootd"
+)
+
+/* installPupBundle installs a pup the dispatcher created from a bundle,
+ * see dogeboxd.PupBundle, unpacking its files in place of a download.
+ * The bundle's store export is imported first, so the nix build finds
+ * the pup's closure already in the store. The uploaded bundle is
+ * removed once it's installed.
+ */
+func (t SystemUpdater) installPupBundle(j dogeboxd.Job, a dogeboxd.InstallPupBundle) error {
+	info, err := dogeboxd.GetPupBundle(t.config, a.BundleID)
+	if err != nil {
+		return err
+	}
+
+	err = t.installPupWith(j, a.SessionToken, "", func(pupPath string, log dogeboxd.SubLogger) (dogeboxd.PupManifest, error) {
+		log.Logf("Installing pup from bundle %s: %s @ %s", a.BundleID, info.Bundle.PupName, info.Bundle.PupVersion)
+		if err := t.unpackPupBundle(info, pupPath, log); err != nil {
+			return dogeboxd.PupManifest{}, err
+		}
+		return info.Manifest, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := dogeboxd.DeletePupBundle(t.config, a.BundleID); err != nil {
+		j.Logger.Step("install").Errf("Warning: failed to remove installed bundle %s: %v", a.BundleID, err)
+	}
+	return nil
+}
+
+func (t SystemUpdater) unpackPupBundle(info dogeboxd.PupBundleInfo, pupPath string, log dogeboxd.SubLogger) error {
+	if err := os.MkdirAll(pupPath, 0755); err != nil {
+		return fmt.Errorf("failed to create pup directory: %w", err)
+	}
+
+	storeDir, err := os.MkdirTemp(t.config.TmpDir, "pup-bundle-*-store")
+	if err != nil {
+		return fmt.Errorf("failed to create temp dir for the bundle's store export: %w", err)
+	}
+	defer os.RemoveAll(storeDir)
+
+	log.Logf("Unpacking bundle to %s", pupPath)
+	hasStore, err := dogeboxd.ExtractPupBundle(t.config.PupBundlePath(info.ID), pupPath, storeDir)
+	if err != nil {
+		return err
+	}
+
+	if !hasStore || len(info.Bundle.StorePaths) == 0 {
+		log.Log("Bundle has no store export, the pup will be built from its nix file")
+		return nil
+	}
+
+	log.Logf("Importing %d store paths from the bundle", len(info.Bundle.StorePaths))
+	if err := writeBundleStorePaths(storeDir, info.Bundle.StorePaths); err != nil {
+		return fmt.Errorf("failed to write store path list: %w", err)
+	}
+	// Unlike an offline system update, nothing's vouched for the bundle,
+	// so nix checks each path was signed by a key it trusts.
+	if err := t.runner.Run(log, rootd.ImportNixStore{CacheDir: storeDir, RequireSigs: true}); err != nil {
+		return fmt.Errorf("failed to import the bundle's store paths: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package system
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+// How long we wait on nix to answer for a single closure check.
+var prebuiltClosureCheckTimeout = 30 * time.Second
+
+// nixPathInfo is the subset of `nix path-info --json` we care about.
+type nixPathInfo struct {
+	Path string `json:"path"`
+	CA   string `json:"ca"`
+}
+
+// Swapped out in tests.
+var (
+	nixSupportsFetchClosure = defaultNixSupportsFetchClosure
+	queryPrebuiltClosure    = defaultQueryPrebuiltClosure
+)
+
+/* resolvePrebuiltClosures works out which of a manifest's prebuilt closures
+ * we can use on this system. Anything that isn't available, or that we
+ * can't verify is content-addressed, is left out so that service is built
+ * from the pup's nix file instead.
+ */
+func resolvePrebuiltClosures(manifest dogeboxd.PupManifest, isDevMode bool, log dogeboxd.SubLogger) []dogeboxd.PupManifestClosure {
+	if len(manifest.Container.Build.Closures) == 0 {
+		return nil
+	}
+
+	if isDevMode {
+		log.Log("Dev mode enabled, building from source instead of using prebuilt closures")
+		return nil
+	}
+
+	system := dogeboxd.CurrentNixSystem()
+	closures := manifest.Container.Build.ClosuresForSystem(system)
+	if len(closures) == 0 {
+		log.Logf("No prebuilt closures for %q, building from source", system)
+		return nil
+	}
+
+	if !nixSupportsFetchClosure() {
+		log.Log("This system can't fetch closures yet, building from source")
+		return nil
+	}
+
+	usable := []dogeboxd.PupManifestClosure{}
+	for _, c := range closures {
+		if err := verifyPrebuiltClosure(c); err != nil {
+			log.Errf("Prebuilt closure for %s unavailable, building from source: %v", c.Service, err)
+			continue
+		}
+		log.Logf("Using prebuilt closure for %s: %s", c.Service, c.StorePath)
+		usable = append(usable, c)
+	}
+
+	return usable
+}
+
+// verifyPrebuiltClosure checks the closure exists in its binary cache and is
+// content-addressed, so nix will check its hash when fetching it.
+func verifyPrebuiltClosure(c dogeboxd.PupManifestClosure) error {
+	info, err := queryPrebuiltClosure(c.FromStore, c.StorePath)
+	if err != nil {
+		return err
+	}
+
+	if info.Path != c.StorePath {
+		return fmt.Errorf("store returned %q for %q", info.Path, c.StorePath)
+	}
+
+	if info.CA == "" {
+		return fmt.Errorf("%s is not content-addressed", c.StorePath)
+	}
+
+	return nil
+}
+
+func defaultNixSupportsFetchClosure() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), prebuiltClosureCheckTimeout)
+	defer cancel()
+
+	// fetchClosure only exists as a builtin when its experimental feature is enabled.
+	out, err := exec.CommandContext(ctx, "nix", "eval", "--json", "--expr", "builtins ? fetchClosure").Output()
+	if err != nil {
+		return false
+	}
+
+	return strings.TrimSpace(string(out)) == "true"
+}
+
+func defaultQueryPrebuiltClosure(fromStore string, storePath string) (nixPathInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), prebuiltClosureCheckTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "nix", "--extra-experimental-features", "nix-command", "path-info", "--json", "--store", fromStore, storePath)
+	out, err := cmd.Output()
+	if err != nil {
+		return nixPathInfo{}, fmt.Errorf("failed to query %s: %w", fromStore, err)
+	}
+
+	return parseNixPathInfo(out, storePath)
+}
+
+// parseNixPathInfo handles both the list output of older nix versions and
+// the object keyed by store path that newer versions print.
+func parseNixPathInfo(out []byte, storePath string) (nixPathInfo, error) {
+	var list []nixPathInfo
+	if err := json.Unmarshal(out, &list); err == nil {
+		for _, info := range list {
+			if info.Path == storePath {
+				return info, nil
+			}
+		}
+		return nixPathInfo{}, fmt.Errorf("%s not found", storePath)
+	}
+
+	var byPath map[string]*nixPathInfo
+	if err := json.Unmarshal(out, &byPath); err != nil {
+		return nixPathInfo{}, fmt.Errorf("failed to parse path info: %w", err)
+	}
+
+	info, ok := byPath[storePath]
+	if !ok || info == nil {
+		return nixPathInfo{}, fmt.Errorf("%s not found", storePath)
+	}
+
+	info.Path = storePath
+	return *info, nil
+}
@@ -0,0 +1,86 @@
+package system
+
+import (
+	"errors"
+	"testing"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func stubPrebuiltClosureChecks(t *testing.T, supported bool, infos map[string]nixPathInfo) {
+	origSupported, origQuery := nixSupportsFetchClosure, queryPrebuiltClosure
+	t.Cleanup(func() {
+		nixSupportsFetchClosure, queryPrebuiltClosure = origSupported, origQuery
+	})
+
+	nixSupportsFetchClosure = func() bool { return supported }
+	queryPrebuiltClosure = func(fromStore string, storePath string) (nixPathInfo, error) {
+		info, ok := infos[storePath]
+		if !ok {
+			return nixPathInfo{}, errors.New("not found")
+		}
+		return info, nil
+	}
+}
+
+func closureTestManifest() dogeboxd.PupManifest {
+	system := dogeboxd.CurrentNixSystem()
+	return dogeboxd.PupManifest{Container: dogeboxd.PupManifestContainer{
+		Build: dogeboxd.PupManifestBuild{Closures: []dogeboxd.PupManifestClosure{
+			{System: system, Service: "dogecoind", StorePath: "/nix/store/aaaa-dogecoind", FromStore: "https://cache.example.org"},
+			{System: system, Service: "monitor", StorePath: "/nix/store/bbbb-monitor", FromStore: "https://cache.example.org"},
+		}},
+	}}
+}
+
+func TestResolvePrebuiltClosuresFallsBackPerService(t *testing.T) {
+	if dogeboxd.CurrentNixSystem() == "" {
+		t.Skip("no nix system for this platform")
+	}
+
+	log := dogeboxd.NewConsoleSubLogger("test", "closures")
+	stubPrebuiltClosureChecks(t, true, map[string]nixPathInfo{
+		"/nix/store/aaaa-dogecoind": {Path: "/nix/store/aaaa-dogecoind", CA: "fixed:r:sha256:abc"},
+		// Input-addressed, so nix can't verify it against the path: build instead.
+		"/nix/store/bbbb-monitor": {Path: "/nix/store/bbbb-monitor"},
+	})
+
+	closures := resolvePrebuiltClosures(closureTestManifest(), false, log)
+	require.Len(t, closures, 1)
+	assert.Equal(t, "dogecoind", closures[0].Service)
+}
+
+func TestResolvePrebuiltClosuresSkipsUnsupportedSystems(t *testing.T) {
+	if dogeboxd.CurrentNixSystem() == "" {
+		t.Skip("no nix system for this platform")
+	}
+
+	log := dogeboxd.NewConsoleSubLogger("test", "closures")
+	infos := map[string]nixPathInfo{
+		"/nix/store/aaaa-dogecoind": {Path: "/nix/store/aaaa-dogecoind", CA: "fixed:r:sha256:abc"},
+	}
+
+	stubPrebuiltClosureChecks(t, false, infos)
+	assert.Empty(t, resolvePrebuiltClosures(closureTestManifest(), false, log))
+
+	stubPrebuiltClosureChecks(t, true, infos)
+	assert.Empty(t, resolvePrebuiltClosures(closureTestManifest(), true, log), "dev mode should build from source")
+}
+
+func TestParseNixPathInfo(t *testing.T) {
+	path := "/nix/store/aaaa-dogecoind"
+
+	info, err := parseNixPathInfo([]byte(`[{"path":"/nix/store/aaaa-dogecoind","ca":"fixed:r:sha256:abc"}]`), path)
+	require.NoError(t, err)
+	assert.Equal(t, "fixed:r:sha256:abc", info.CA)
+
+	info, err = parseNixPathInfo([]byte(`{"/nix/store/aaaa-dogecoind":{"ca":"fixed:r:sha256:abc"}}`), path)
+	require.NoError(t, err)
+	assert.Equal(t, path, info.Path)
+	assert.Equal(t, "fixed:r:sha256:abc", info.CA)
+
+	_, err = parseNixPathInfo([]byte(`{"/nix/store/aaaa-dogecoind":null}`), path)
+	assert.Error(t, err)
+}
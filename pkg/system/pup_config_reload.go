@@ -0,0 +1,43 @@
+package system
+
+import (
+	"fmt"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/Dogebox-WG/dogeboxd/pkg/rootd"
+)
+
+/* ReloadPupConfig tells a running pup that declares a config reload to
+ * pick up its rewritten config.env, see dogeboxd.PupManifestConfigReload.
+ * If the reload fails the container is restarted instead, so the new
+ * config still takes effect.
+ */
+func (t SystemUpdater) ReloadPupConfig(state dogeboxd.PupState, log dogeboxd.SubLogger) error {
+	reload := state.Manifest.Config.Reload
+	if reload == nil {
+		return fmt.Errorf("%s doesn't declare a config reload", state.Manifest.Meta.Name)
+	}
+
+	var op rootd.Op
+	if reload.Signal != "" {
+		log.Logf("Sending %s to %s to reload its config", reload.Signal, reload.Service)
+		op = rootd.PupSignalService{PupID: state.ID, Service: reload.Service, Signal: reload.Signal}
+	} else {
+		log.Logf("Running %s's config reload command", state.Manifest.Meta.Name)
+		op = rootd.PupReloadCommand{PupID: state.ID, Command: reload.Command, TimeoutSeconds: int(reload.TimeoutDuration().Seconds())}
+	}
+
+	err := t.runner.Run(log, op)
+	if err == nil {
+		log.Logf("Config reloaded without a restart")
+		return nil
+	}
+
+	log.Errf("Config reload failed, restarting instead: %v", err)
+	serviceName := fmt.Sprintf("container@pup-%s.service", state.ID)
+	if err := t.runner.Run(log, rootd.RestartPupUnit{Unit: serviceName}); err != nil {
+		log.Errf("Failed to restart container: %v", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package system
+
+import (
+	"errors"
+	"testing"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReloadPupConfig(t *testing.T) {
+	tests := map[string]struct {
+		reload   dogeboxd.PupManifestConfigReload
+		results  map[string]CommandResult
+		commands []string
+	}{
+		"signal": {
+			reload:   dogeboxd.PupManifestConfigReload{Service: "core", Signal: "SIGHUP"},
+			commands: []string{"systemctl -M pup-abc kill --kill-whom=main --signal=SIGHUP core.service"},
+		},
+		"command": {
+			reload:   dogeboxd.PupManifestConfigReload{Command: "core-cli reload"},
+			commands: []string{"systemd-run --machine=pup-abc --wait --pipe --quiet --collect --property=RuntimeMaxSec=30 /bin/sh -c core-cli reload"},
+		},
+		"failed reload restarts": {
+			reload:  dogeboxd.PupManifestConfigReload{Service: "core", Signal: "SIGHUP"},
+			results: map[string]CommandResult{"systemctl -M pup-abc kill": {Err: errors.New("no such unit")}},
+			commands: []string{
+				"systemctl -M pup-abc kill --kill-whom=main --signal=SIGHUP core.service",
+				"systemctl try-restart container@pup-abc.service",
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			pup := dogeboxd.PupState{ID: "abc", Enabled: true}
+			pup.Manifest.Config.Reload = &tc.reload
+
+			runner := NewRecordingCommandRunner()
+			for prefix, result := range tc.results {
+				runner.Results[prefix] = result
+			}
+			updater := SystemUpdater{runner: runner}
+
+			require.NoError(t, updater.ReloadPupConfig(pup, testRunnerJob(pup).Logger.Step("config")))
+			assert.Equal(t, tc.commands, runner.Commands)
+		})
+	}
+}
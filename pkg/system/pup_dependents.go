@@ -0,0 +1,61 @@
+package system
+
+import (
+	"fmt"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/Dogebox-WG/dogeboxd/pkg/rootd"
+)
+
+// startPup starts an enabled pup's container, whether or not it starts on
+// boot. It's a no-op if the pup is already running.
+func (t SystemUpdater) startPup(j dogeboxd.Job, a dogeboxd.StartPup) error {
+	log := j.Logger.Step("start")
+
+	state, _, err := t.pupManager.GetPup(a.PupID)
+	if err != nil {
+		return err
+	}
+	if !state.Enabled {
+		return fmt.Errorf("%s isn't enabled, enable it instead", state.DisplayName())
+	}
+	if state.InMaintenance() {
+		return fmt.Errorf("%s is in maintenance", state.DisplayName())
+	}
+
+	log.Logf("Starting %s", state.DisplayName())
+	serviceName := fmt.Sprintf("container@pup-%s.service", state.ID)
+	if err := t.runner.Run(log, rootd.StartPupUnit{Unit: serviceName}); err != nil {
+		log.Errf("Failed to start container: %v", err)
+		return err
+	}
+	return nil
+}
+
+/* restartPup restarts a pup's container, see dogeboxd.UpgradePup.RestartDependents.
+ * Pups that have been stopped since the restart was queued are left alone.
+ */
+func (t SystemUpdater) restartPup(j dogeboxd.Job, a dogeboxd.RestartPup) error {
+	log := j.Logger.Step("restart")
+
+	state, _, err := t.pupManager.GetPup(a.PupID)
+	if err != nil {
+		return err
+	}
+	if !state.Enabled {
+		log.Logf("%s is stopped, skipping restart", state.DisplayName())
+		return nil
+	}
+	if state.InMaintenance() {
+		log.Logf("%s is in maintenance, skipping restart", state.DisplayName())
+		return nil
+	}
+
+	log.Logf("Restarting %s", state.DisplayName())
+	serviceName := fmt.Sprintf("container@pup-%s.service", state.ID)
+	if err := t.runner.Run(log, rootd.RestartPupUnit{Unit: serviceName}); err != nil {
+		log.Errf("Failed to restart container: %v", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package system
+
+import (
+	"testing"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestartPupRestartsContainer(t *testing.T) {
+	dependent := dogeboxd.PupState{ID: "abc", Enabled: true}
+	pups := &pendingPupManager{states: map[string]dogeboxd.PupState{"abc": dependent}}
+	runner := NewRecordingCommandRunner()
+	updater := SystemUpdater{runner: runner, pupManager: pups}
+
+	require.NoError(t, updater.restartPup(testRunnerJob(dependent), dogeboxd.RestartPup{PupID: "abc"}))
+
+	assert.Equal(t, []string{"systemctl try-restart container@pup-abc.service"}, runner.Commands)
+}
+
+func TestRestartPupSkipsStoppedPups(t *testing.T) {
+	dependent := dogeboxd.PupState{ID: "abc", Enabled: false}
+	pups := &pendingPupManager{states: map[string]dogeboxd.PupState{"abc": dependent}}
+	runner := NewRecordingCommandRunner()
+	updater := SystemUpdater{runner: runner, pupManager: pups}
+
+	require.NoError(t, updater.restartPup(testRunnerJob(dependent), dogeboxd.RestartPup{PupID: "abc"}))
+
+	assert.Empty(t, runner.Commands)
+}
+
+func TestStartPupStartsEnabledPupsOnly(t *testing.T) {
+	autoStart := false
+	manual := dogeboxd.PupState{ID: "abc", Enabled: true, AutoStart: &autoStart}
+	disabled := dogeboxd.PupState{ID: "def", Enabled: false}
+	pups := &pendingPupManager{states: map[string]dogeboxd.PupState{"abc": manual, "def": disabled}}
+	runner := NewRecordingCommandRunner()
+	updater := SystemUpdater{runner: runner, pupManager: pups}
+
+	require.NoError(t, updater.startPup(testRunnerJob(manual), dogeboxd.StartPup{PupID: "abc"}))
+	assert.Error(t, updater.startPup(testRunnerJob(disabled), dogeboxd.StartPup{PupID: "def"}))
+
+	assert.Equal(t, []string{"systemctl start container@pup-abc.service"}, runner.Commands)
+}
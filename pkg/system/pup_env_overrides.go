@@ -0,0 +1,49 @@
+package system
+
+import (
+	"fmt"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/Dogebox-WG/dogeboxd/pkg/rootd"
+)
+
+/* setPupEnvOverrides rewrites a pup's config.env with its new environment
+ * overrides and restarts it so they're picked up. Like the log level, no
+ * rebuild is needed.
+ */
+func (t SystemUpdater) setPupEnvOverrides(j dogeboxd.Job, a dogeboxd.SetPupEnvOverrides) error {
+	log := j.Logger.Step("env-overrides")
+
+	state, _, err := t.pupManager.GetPup(a.PupID)
+	if err != nil {
+		return err
+	}
+	if err := dogeboxd.ValidatePupEnvOverrides(state.Manifest, a.Env); err != nil {
+		return err
+	}
+
+	config, keep, err := t.secrets.PupConfig(state)
+	if err != nil {
+		return err
+	}
+
+	if err := writePupConfig(t.config.DataDir, state.ID, config, keep, a.Env, log); err != nil {
+		return err
+	}
+
+	newState, err := t.pupManager.UpdatePup(state.ID, dogeboxd.PupEnvOverrides(a.Env), dogeboxd.WithPupdateReason(dogeboxd.PupdateReasonFor(j)))
+	if err != nil {
+		return err
+	}
+	log.Logf("Set %d environment overrides for %s", len(newState.EnvOverrides), newState.Manifest.Meta.Name)
+
+	if !newState.Enabled {
+		return nil
+	}
+	serviceName := fmt.Sprintf("container@pup-%s.service", newState.ID)
+	if err := t.runner.Run(log, rootd.RestartPupUnit{Unit: serviceName}); err != nil {
+		log.Errf("Failed to restart container: %v", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package system
+
+import (
+	"testing"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetPupEnvOverridesRewritesConfigAndRestarts(t *testing.T) {
+	written := stubWritePupConfig(t)
+	pup := dogeboxd.PupState{ID: "abc", Enabled: true, Config: map[string]string{"RPC_USER": "doge"}}
+	pups := &pendingPupManager{states: map[string]dogeboxd.PupState{"abc": pup}}
+
+	runner := NewRecordingCommandRunner()
+	updater := SystemUpdater{runner: runner, pupManager: pups}
+	job := testRunnerJob(pup)
+
+	require.NoError(t, updater.setPupEnvOverrides(job, dogeboxd.SetPupEnvOverrides{PupID: "abc", Env: map[string]string{"EXTRA_FLAGS": "-v"}}))
+
+	assert.Equal(t, map[string]string{"RPC_USER": "doge", "EXTRA_FLAGS": "-v"}, *written)
+	assert.Equal(t, map[string]string{"EXTRA_FLAGS": "-v"}, pups.states["abc"].EnvOverrides)
+	assert.Equal(t, map[string]string{"RPC_USER": "doge"}, pups.states["abc"].Config)
+	assert.Equal(t, []string{"systemctl try-restart container@pup-abc.service"}, runner.Commands)
+
+	require.NoError(t, updater.setPupEnvOverrides(job, dogeboxd.SetPupEnvOverrides{PupID: "abc"}))
+
+	assert.Equal(t, map[string]string{"RPC_USER": "doge"}, *written)
+	assert.Nil(t, pups.states["abc"].EnvOverrides)
+}
+
+func TestSetPupEnvOverridesRejectsReservedNames(t *testing.T) {
+	written := stubWritePupConfig(t)
+	pup := dogeboxd.PupState{ID: "abc", Enabled: true}
+	pups := &pendingPupManager{states: map[string]dogeboxd.PupState{"abc": pup}}
+
+	runner := NewRecordingCommandRunner()
+	updater := SystemUpdater{runner: runner, pupManager: pups}
+
+	err := updater.setPupEnvOverrides(testRunnerJob(pup), dogeboxd.SetPupEnvOverrides{PupID: "abc", Env: map[string]string{"DBX_PUP_ID": "x"}})
+	assert.ErrorContains(t, err, "reserved")
+	assert.Empty(t, *written)
+	assert.Empty(t, runner.Commands)
+}
+
+// Signing in unlocks the secrets, before then they're kept as they are.
+func TestSetPupEnvOverridesKeepsLockedSecrets(t *testing.T) {
+	var kept []string
+	orig := writePupConfig
+	t.Cleanup(func() { writePupConfig = orig })
+	writePupConfig = func(dataDir string, pupID string, config map[string]string, keep []string, envOverrides map[string]string, log dogeboxd.SubLogger) error {
+		kept = keep
+		return nil
+	}
+
+	pup := dogeboxd.PupState{ID: "abc", Config: map[string]string{"RPC_USER": "doge"}, SecretConfig: map[string]string{"RPC_PASS": "sealed"}}
+	pups := &pendingPupManager{states: map[string]dogeboxd.PupState{"abc": pup}}
+	updater := SystemUpdater{runner: NewRecordingCommandRunner(), pupManager: pups, secrets: dogeboxd.NewConfigSecretStore(nil)}
+
+	require.NoError(t, updater.setPupEnvOverrides(testRunnerJob(pup), dogeboxd.SetPupEnvOverrides{PupID: "abc", Env: map[string]string{"EXTRA_FLAGS": "-v"}}))
+	assert.Equal(t, []string{"RPC_PASS"}, kept)
+	assert.Empty(t, pups.states["abc"].BrokenReason)
+}
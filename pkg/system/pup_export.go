@@ -0,0 +1,117 @@
+package system
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/Dogebox-WG/dogeboxd/pkg/rootd"
+)
+
+/* exportPup writes a pup export for ImportPup on another box, see
+ * dogeboxd.PupExport. A running pup is stopped while its storage is
+ * archived, so it isn't copied mid-write, and started again after.
+ */
+func (t SystemUpdater) exportPup(j dogeboxd.Job, a dogeboxd.ExportPup) (dogeboxd.PupExportInfo, error) {
+	s := *j.State
+	log := j.Logger.Step("export")
+
+	if err := os.MkdirAll(t.config.PupExportDir(), 0750); err != nil {
+		return dogeboxd.PupExportInfo{}, fmt.Errorf("failed to create export directory: %w", err)
+	}
+
+	exportID, err := dogeboxd.NewPupExportID()
+	if err != nil {
+		return dogeboxd.PupExportInfo{}, err
+	}
+	export := dogeboxd.NewPupExport(s, t.pupManager.GetStateMap(), a.IncludeStorage, time.Now())
+
+	storagePath := t.config.PupExportStoragePath(exportID)
+	defer os.Remove(storagePath)
+
+	if a.IncludeStorage {
+		if err := t.exportPupStorage(s, exportID, log); err != nil {
+			return dogeboxd.PupExportInfo{}, err
+		}
+	}
+
+	log.Logf("Writing export %s of %s %s", exportID, s.DisplayName(), s.Version)
+	exportPath := t.config.PupExportPath(exportID)
+	if err := dogeboxd.WritePupExport(exportPath, export, storagePath); err != nil {
+		os.Remove(exportPath)
+		return dogeboxd.PupExportInfo{}, err
+	}
+
+	return dogeboxd.GetPupExport(t.config, exportID)
+}
+
+func (t SystemUpdater) exportPupStorage(s dogeboxd.PupState, exportID string, log dogeboxd.SubLogger) error {
+	// A pup in maintenance is already stopped, and stays that way.
+	if s.Enabled && !s.InMaintenance() {
+		log.Logf("Stopping %s while its storage is archived", s.DisplayName())
+		if err := t.runner.Run(log, pupStopOp(s)); err != nil {
+			return fmt.Errorf("failed to stop pup: %w", err)
+		}
+		defer func() {
+			serviceName := fmt.Sprintf("container@pup-%s.service", s.ID)
+			if err := t.runner.Run(log, rootd.StartPupUnit{Unit: serviceName}); err != nil {
+				log.Errf("Failed to start %s again: %v", s.DisplayName(), err)
+			}
+		}()
+	}
+
+	log.Logf("Archiving storage")
+	if err := t.runner.Run(log, rootd.PupExportStorage{PupID: s.ID, DataDir: t.config.DataDir, ExportID: exportID}); err != nil {
+		return fmt.Errorf("failed to archive pup storage: %w", err)
+	}
+	return nil
+}
+
+/* importPup installs a pup the dispatcher created from an export, with
+ * the export's config and providers already set, and restores the
+ * export's storage. The uploaded export is removed once it's installed.
+ */
+func (t SystemUpdater) importPup(j dogeboxd.Job, a dogeboxd.ImportPup) error {
+	s := *j.State
+	log := j.Logger.Step("import")
+
+	info, err := dogeboxd.GetPupExport(t.config, a.ExportID)
+	if err != nil {
+		return err
+	}
+
+	storageExportID := ""
+	if info.Export.HasStorage {
+		storageExportID = a.ExportID
+	}
+
+	install := dogeboxd.InstallPup{
+		PupName:      info.Export.PupName,
+		PupVersion:   info.Export.PupVersion,
+		SourceId:     s.Source.ID,
+		SessionToken: a.SessionToken,
+	}
+	if err := t.installPupFrom(install, j, storageExportID); err != nil {
+		return err
+	}
+
+	if err := dogeboxd.DeletePupExport(t.config, a.ExportID); err != nil {
+		log.Errf("Warning: failed to remove imported export %s: %v", a.ExportID, err)
+	}
+	return nil
+}
+
+// importPupStorage unpacks an export's storage into the pup's storage,
+// which has to exist already.
+func (t SystemUpdater) importPupStorage(s dogeboxd.PupState, exportID string, log dogeboxd.SubLogger) error {
+	storagePath := t.config.PupExportStoragePath(exportID)
+	defer os.Remove(storagePath)
+
+	log.Logf("Restoring storage from export %s", exportID)
+	if err := dogeboxd.ExtractPupExportStorage(t.config.PupExportPath(exportID), storagePath); err != nil {
+		return err
+	}
+
+	return t.runner.Run(log, rootd.PupImportStorage{PupID: s.ID, DataDir: t.config.DataDir, ExportID: exportID})
+}
@@ -0,0 +1,44 @@
+package system
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/Dogebox-WG/dogeboxd/pkg/rootd"
+)
+
+// PupHealthCommandRunner runs pups' health check commands in their
+// containers with runner, see pup.PupManager.SetHealthCommandRunner.
+func PupHealthCommandRunner(runner CommandRunner) func(pupID string, command string, timeout time.Duration) error {
+	return func(pupID string, command string, timeout time.Duration) error {
+		out, err := runner.CombinedOutput(nil, rootd.PupHealthCommand{
+			PupID:          pupID,
+			Command:        command,
+			TimeoutSeconds: int(math.Ceil(timeout.Seconds())),
+		})
+		if err != nil {
+			if output := strings.TrimSpace(string(out)); output != "" {
+				return fmt.Errorf("%w: %s", err, output)
+			}
+			return err
+		}
+		return nil
+	}
+}
+
+// PupLogReopener restarts a pup's log follower with runner once its log
+// has been rotated, see dogeboxd.PupLogRotator.SetLogReopener.
+func PupLogReopener(runner CommandRunner) func(pupID string) error {
+	return func(pupID string) error {
+		out, err := runner.CombinedOutput(nil, rootd.RestartPupLogForwarder{PupID: pupID})
+		if err != nil {
+			if output := strings.TrimSpace(string(out)); output != "" {
+				return fmt.Errorf("%w: %s", err, output)
+			}
+			return err
+		}
+		return nil
+	}
+}
@@ -0,0 +1,72 @@
+package system
+
+import (
+	"fmt"
+	"time"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/Dogebox-WG/dogeboxd/pkg/rootd"
+)
+
+// Swappable for tests, writing config.env goes through sudo _dbxroot.
+var writePupConfig = dogeboxd.WritePupConfigToStorage
+
+/* setPupLogLevel switches a pup's debug logging on or off by rewriting
+ * its config.env and restarting it, as pups only read config.env when
+ * they start. No rebuild is needed. Turning it on again while it's on
+ * just moves the expiry.
+ */
+func (t SystemUpdater) setPupLogLevel(j dogeboxd.Job, a dogeboxd.SetPupLogLevel) error {
+	log := j.Logger.Step("log-level")
+
+	state, _, err := t.pupManager.GetPup(a.PupID)
+	if err != nil {
+		return err
+	}
+
+	if !a.Debug && state.LogLevelOverride == nil {
+		log.Logf("Debug logging isn't on for %s, nothing to do", state.Manifest.Meta.Name)
+		return nil
+	}
+
+	duration := a.Duration
+	if duration == 0 {
+		duration = dogeboxd.DefaultPupDebugLogDuration
+	}
+	config, override, err := dogeboxd.PupLogLevelConfig(state, a.Debug, time.Now().Add(duration))
+	if err != nil {
+		return err
+	}
+
+	withConfig := state
+	withConfig.Config = config
+	fullConfig, keep, err := t.secrets.PupConfig(withConfig)
+	if err != nil {
+		return err
+	}
+
+	if err := writePupConfig(t.config.DataDir, state.ID, fullConfig, keep, state.EnvOverrides, log); err != nil {
+		return err
+	}
+
+	newState, err := t.pupManager.UpdatePup(state.ID, dogeboxd.PupLogLevel(config, override), dogeboxd.WithPupdateReason(dogeboxd.PupdateReasonFor(j)))
+	if err != nil {
+		return err
+	}
+
+	if override != nil {
+		log.Logf("Debug logging on for %s until %s", newState.Manifest.Meta.Name, override.Until.Format(time.RFC3339))
+	} else {
+		log.Logf("Debug logging off for %s", newState.Manifest.Meta.Name)
+	}
+
+	if !newState.Enabled {
+		return nil
+	}
+	serviceName := fmt.Sprintf("container@pup-%s.service", newState.ID)
+	if err := t.runner.Run(log, rootd.RestartPupUnit{Unit: serviceName}); err != nil {
+		log.Errf("Failed to restart container: %v", err)
+		return err
+	}
+	return nil
+}
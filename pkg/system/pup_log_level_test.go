@@ -0,0 +1,62 @@
+package system
+
+import (
+	"testing"
+	"time"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func stubWritePupConfig(t *testing.T) *map[string]string {
+	written := map[string]string{}
+	orig := writePupConfig
+	t.Cleanup(func() { writePupConfig = orig })
+	writePupConfig = func(dataDir string, pupID string, config map[string]string, keep []string, envOverrides map[string]string, log dogeboxd.SubLogger) error {
+		written = dogeboxd.PupEnvironment(config, envOverrides)
+		return nil
+	}
+	return &written
+}
+
+func TestSetPupLogLevelRewritesConfigAndRestarts(t *testing.T) {
+	written := stubWritePupConfig(t)
+	pup := dogeboxd.PupState{ID: "abc", Enabled: true, Config: map[string]string{"LOG_LEVEL": "info"}}
+	pup.Manifest.Config.LogLevel = &dogeboxd.PupManifestLogLevel{Env: "LOG_LEVEL", Debug: "trace"}
+	pups := &pendingPupManager{states: map[string]dogeboxd.PupState{"abc": pup}}
+
+	runner := NewRecordingCommandRunner()
+	updater := SystemUpdater{runner: runner, pupManager: pups}
+	job := testRunnerJob(pup)
+
+	require.NoError(t, updater.setPupLogLevel(job, dogeboxd.SetPupLogLevel{PupID: "abc", Debug: true}))
+
+	assert.Equal(t, "trace", (*written)["LOG_LEVEL"])
+	override := pups.states["abc"].LogLevelOverride
+	require.NotNil(t, override)
+	assert.Equal(t, "info", override.Previous)
+	assert.WithinDuration(t, time.Now().Add(dogeboxd.DefaultPupDebugLogDuration), override.Until, time.Minute)
+	assert.Equal(t, []string{"systemctl try-restart container@pup-abc.service"}, runner.Commands)
+
+	require.NoError(t, updater.setPupLogLevel(job, dogeboxd.SetPupLogLevel{PupID: "abc", Debug: false}))
+
+	assert.Equal(t, "info", (*written)["LOG_LEVEL"])
+	assert.Equal(t, "info", pups.states["abc"].Config["LOG_LEVEL"])
+	assert.Nil(t, pups.states["abc"].LogLevelOverride)
+	assert.Len(t, runner.Commands, 2)
+}
+
+func TestSetPupLogLevelOffWithoutOverride(t *testing.T) {
+	written := stubWritePupConfig(t)
+	pup := dogeboxd.PupState{ID: "abc", Enabled: true}
+	pups := &pendingPupManager{states: map[string]dogeboxd.PupState{"abc": pup}}
+
+	runner := NewRecordingCommandRunner()
+	updater := SystemUpdater{runner: runner, pupManager: pups}
+
+	require.NoError(t, updater.setPupLogLevel(testRunnerJob(pup), dogeboxd.SetPupLogLevel{PupID: "abc"}))
+
+	assert.Empty(t, *written)
+	assert.Empty(t, runner.Commands)
+}
@@ -0,0 +1,45 @@
+package system
+
+import (
+	"fmt"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/Dogebox-WG/dogeboxd/pkg/rootd"
+)
+
+/* setPupMaintenance stops or starts a pup put in or taken out of
+ * maintenance by the dispatcher. Its container stays in the nix config,
+ * only whether it starts on boot changes, and that's written before
+ * stopping it so applying the config can't bring it back.
+ */
+func (t SystemUpdater) setPupMaintenance(j dogeboxd.Job, a dogeboxd.SetPupMaintenance) error {
+	if err := t.rewritePupContainer(j, "maintenance"); err != nil {
+		return err
+	}
+
+	log := j.Logger.Step("maintenance")
+	s, _, err := t.pupManager.GetPup(a.PupID)
+	if err != nil {
+		return err
+	}
+
+	if s.InMaintenance() {
+		log.Logf("Stopping %s for maintenance", s.DisplayName())
+		if err := t.runner.Run(log, pupStopOp(s)); err != nil {
+			log.Errf("Error executing _dbxroot pup stop: %v", err)
+			return err
+		}
+		return nil
+	}
+
+	if !s.Enabled {
+		return nil
+	}
+	log.Logf("Starting %s after maintenance", s.DisplayName())
+	serviceName := fmt.Sprintf("container@pup-%s.service", s.ID)
+	if err := t.runner.Run(log, rootd.StartPupUnit{Unit: serviceName}); err != nil {
+		log.Errf("Failed to start container: %v", err)
+		return err
+	}
+	return nil
+}
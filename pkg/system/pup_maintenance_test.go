@@ -0,0 +1,53 @@
+package system
+
+import (
+	"testing"
+	"time"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type maintenanceNixManager struct {
+	trustedCANixManager
+	startsOnBoot []bool
+}
+
+func (m *maintenanceNixManager) WritePupFile(patch dogeboxd.NixPatch, state dogeboxd.PupState, dbxState dogeboxd.DogeboxState) {
+	m.startsOnBoot = append(m.startsOnBoot, state.StartsOnBoot())
+}
+
+func TestSetPupMaintenanceStopsAndStartsPup(t *testing.T) {
+	sm := newSafeModeTestStateManager(t)
+	pup := dogeboxd.PupState{ID: "abc", Enabled: true, Maintenance: &dogeboxd.PupMaintenance{Since: time.Now()}}
+	pups := &pendingPupManager{states: map[string]dogeboxd.PupState{"abc": pup}}
+	nix := &maintenanceNixManager{trustedCANixManager: trustedCANixManager{patch: &trustedCANixPatch{}}}
+	runner := NewRecordingCommandRunner()
+	updater := SystemUpdater{sm: sm, nix: nix, runner: runner, pupManager: pups}
+
+	// The dispatcher has already marked the pup, so it's rewritten not to
+	// start on boot before it's stopped.
+	require.NoError(t, updater.setPupMaintenance(testRunnerJob(pup), dogeboxd.SetPupMaintenance{PupID: "abc", Enabled: true}))
+	assert.Equal(t, []bool{false}, nix.startsOnBoot)
+	assert.Equal(t, []string{"_dbxroot pup stop --pupId abc"}, runner.Commands)
+
+	pup.Maintenance = nil
+	pups.states["abc"] = pup
+	runner.Commands = nil
+	require.NoError(t, updater.setPupMaintenance(testRunnerJob(pup), dogeboxd.SetPupMaintenance{PupID: "abc"}))
+	assert.Equal(t, []bool{false, true}, nix.startsOnBoot)
+	assert.Equal(t, []string{"systemctl start container@pup-abc.service"}, runner.Commands)
+	assert.Equal(t, 2, nix.patch.applied)
+}
+
+func TestRestartPupSkipsPupsInMaintenance(t *testing.T) {
+	dependent := dogeboxd.PupState{ID: "abc", Enabled: true, Maintenance: &dogeboxd.PupMaintenance{}}
+	pups := &pendingPupManager{states: map[string]dogeboxd.PupState{"abc": dependent}}
+	runner := NewRecordingCommandRunner()
+	updater := SystemUpdater{runner: runner, pupManager: pups}
+
+	require.NoError(t, updater.restartPup(testRunnerJob(dependent), dogeboxd.RestartPup{PupID: "abc"}))
+
+	assert.Empty(t, runner.Commands)
+}
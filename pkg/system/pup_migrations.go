@@ -0,0 +1,135 @@
+package system
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/Dogebox-WG/dogeboxd/pkg/rootd"
+)
+
+const pupMigrationsUnit = "dbx-migrations.service"
+
+// How long we wait for command migrations to finish inside the container.
+var pupMigrationTimeout = 30 * time.Minute
+
+var unsafeMigrationIDChars = regexp.MustCompile(`[^A-Za-z0-9.-]`)
+
+/* runPupMigrations applies any config migrations straight away, and returns
+ * the command migrations that need to run inside the pup's container. All
+ * config migrations are applied before any command migration runs.
+ */
+func runPupMigrations(config map[string]string, migrations []dogeboxd.PupManifestMigration, log dogeboxd.SubLogger) (map[string]string, []dogeboxd.PupPendingMigration, error) {
+	migrated := make(map[string]string, len(config))
+	for k, v := range config {
+		migrated[k] = v
+	}
+
+	pending := []dogeboxd.PupPendingMigration{}
+
+	for _, m := range migrations {
+		if m.Type == dogeboxd.MIGRATION_TYPE_COMMAND {
+			log.Logf("Migration %s: will run before the pup's services start", m.Name())
+			pending = append(pending, dogeboxd.PupPendingMigration{
+				ID:      pupMigrationID(m),
+				Name:    m.Name(),
+				Service: m.Service,
+				Exec:    m.Exec,
+			})
+			continue
+		}
+
+		if err := dogeboxd.ApplyConfigMigration(migrated, m); err != nil {
+			log.Errf("Migration %s: failed: %v", m.Name(), err)
+			return nil, nil, fmt.Errorf("migration %s failed: %w", m.Name(), err)
+		}
+		log.Logf("Migration %s: done", m.Name())
+	}
+
+	return migrated, pending, nil
+}
+
+// A stable ID for a command migration, used for its marker file in pup storage.
+func pupMigrationID(m dogeboxd.PupManifestMigration) string {
+	sum := sha256.Sum256([]byte(m.Service + "\x00" + m.Exec))
+	return fmt.Sprintf("%s-%x", unsafeMigrationIDChars.ReplaceAllString(m.Version, "_"), sum[:4])
+}
+
+// mergePendingMigrations adds next to any migrations that still haven't run.
+func mergePendingMigrations(existing []dogeboxd.PupPendingMigration, next []dogeboxd.PupPendingMigration) []dogeboxd.PupPendingMigration {
+	merged := append([]dogeboxd.PupPendingMigration{}, existing...)
+
+outer:
+	for _, n := range next {
+		for _, e := range existing {
+			if e.ID == n.ID {
+				continue outer
+			}
+		}
+		merged = append(merged, n)
+	}
+
+	return merged
+}
+
+// waitForPupMigrations waits for the migration unit inside a pup container to finish.
+func (t SystemUpdater) waitForPupMigrations(pupID string, log dogeboxd.SubLogger) error {
+	machine := fmt.Sprintf("pup-%s", pupID)
+	deadline := time.Now().Add(pupMigrationTimeout)
+
+	log.Log("Waiting for migrations to run inside the container...")
+
+	for time.Now().Before(deadline) {
+		output, _ := t.runner.CombinedOutput(log, rootd.UnitIsActive{Unit: pupMigrationsUnit, Machine: machine})
+		state := strings.TrimSpace(string(output))
+
+		switch state {
+		case "active":
+			log.Log("Migrations completed successfully")
+			return nil
+		case "failed":
+			if logsOutput, err := t.runner.CombinedOutput(log, rootd.UnitLogs{Unit: pupMigrationsUnit, Machine: machine, Lines: 50}); err == nil {
+				for _, line := range strings.Split(strings.TrimSpace(string(logsOutput)), "\n") {
+					log.Errf("  %s", line)
+				}
+			}
+			return fmt.Errorf("migrations failed inside container %s", machine)
+		}
+
+		time.Sleep(2 * time.Second)
+	}
+
+	return fmt.Errorf("migrations did not finish within %s", pupMigrationTimeout)
+}
+
+func logConfigMergeReport(report dogeboxd.PupConfigMergeReport, log dogeboxd.SubLogger) {
+	for _, c := range report.Added {
+		if c.Applied {
+			log.Logf("Config %s: new field, using default %q", c.Name, c.NewDefault)
+		} else {
+			log.Logf("Config %s: new field", c.Name)
+		}
+	}
+
+	for _, c := range report.Changed {
+		switch {
+		case c.Applied:
+			log.Logf("Config %s: default changed from %q to %q, using new default", c.Name, c.OldDefault, c.NewDefault)
+		case c.OldType != c.NewType:
+			log.Logf("Config %s: type changed from %s to %s, keeping %q", c.Name, c.OldType, c.NewType, c.Value)
+		default:
+			log.Logf("Config %s: default changed from %q to %q, keeping %q", c.Name, c.OldDefault, c.NewDefault, c.Value)
+		}
+	}
+
+	for _, c := range report.Removed {
+		log.Logf("Config %s: removed in this version", c.Name)
+	}
+
+	for _, c := range report.Conflicts {
+		log.Errf("Config %s: removed in this version, but had a custom value %q which has been dropped", c.Name, c.Value)
+	}
+}
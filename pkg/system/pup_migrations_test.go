@@ -0,0 +1,49 @@
+package system
+
+import (
+	"testing"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunPupMigrationsSplitsConfigAndCommands(t *testing.T) {
+	log := dogeboxd.NewConsoleSubLogger("test", "migrations")
+	config := map[string]string{"old": "value"}
+
+	migrations := []dogeboxd.PupManifestMigration{
+		{Version: "1.1.0", Type: dogeboxd.MIGRATION_TYPE_RENAME_CONFIG_KEY, From: "old", To: "new"},
+		{Version: "1.1.0", Type: dogeboxd.MIGRATION_TYPE_COMMAND, Service: "pup", Exec: "/bin/migrate"},
+	}
+
+	migrated, pending, err := runPupMigrations(config, migrations, log)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{"new": "value"}, migrated)
+	assert.Equal(t, map[string]string{"old": "value"}, config, "original config should be untouched")
+
+	require.Len(t, pending, 1)
+	assert.Equal(t, "pup", pending[0].Service)
+	assert.Equal(t, "/bin/migrate", pending[0].Exec)
+	assert.Equal(t, pending[0].ID, pupMigrationID(migrations[1]))
+}
+
+func TestRunPupMigrationsStopsOnFailure(t *testing.T) {
+	log := dogeboxd.NewConsoleSubLogger("test", "migrations")
+	config := map[string]string{"old": "a", "new": "b"}
+
+	_, _, err := runPupMigrations(config, []dogeboxd.PupManifestMigration{
+		{Version: "1.1.0", Type: dogeboxd.MIGRATION_TYPE_RENAME_CONFIG_KEY, From: "old", To: "new"},
+	}, log)
+	assert.Error(t, err)
+}
+
+func TestMergePendingMigrationsSkipsDuplicates(t *testing.T) {
+	existing := []dogeboxd.PupPendingMigration{{ID: "1.1.0-aaaa"}}
+	next := []dogeboxd.PupPendingMigration{{ID: "1.1.0-aaaa"}, {ID: "1.2.0-bbbb"}}
+
+	merged := mergePendingMigrations(existing, next)
+	require.Len(t, merged, 2)
+	assert.Equal(t, "1.2.0-bbbb", merged[1].ID)
+}
@@ -0,0 +1,22 @@
+package system
+
+import (
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/Dogebox-WG/dogeboxd/pkg/rootd"
+)
+
+/* setPupStorageQuota applies a pup's new storage quota, and only saves
+ * it once it has been applied, so a failure leaves the pup's state
+ * showing the quota it still has.
+ */
+func (t SystemUpdater) setPupStorageQuota(j dogeboxd.Job, a dogeboxd.SetPupStorageQuota) error {
+	log := j.Logger.Step("storage-quota")
+
+	if err := t.runner.Run(log, rootd.PupCreateStorage{PupID: a.PupID, DataDir: t.config.DataDir, QuotaMB: a.QuotaMB}); err != nil {
+		log.Errf("Failed to apply storage quota: %v", err)
+		return err
+	}
+
+	_, err := t.pupManager.UpdatePup(a.PupID, dogeboxd.PupStorageQuota(a.QuotaMB), dogeboxd.WithPupdateReason(dogeboxd.PupdateReasonFor(j)))
+	return err
+}
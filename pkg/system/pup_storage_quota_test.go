@@ -0,0 +1,25 @@
+package system
+
+import (
+	"errors"
+	"testing"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetPupStorageQuotaSavesOnceApplied(t *testing.T) {
+	pup := dogeboxd.PupState{ID: "abc", StorageQuotaMB: 1024}
+	pups := &pendingPupManager{states: map[string]dogeboxd.PupState{"abc": pup}}
+	runner := NewRecordingCommandRunner()
+	runner.Results["_dbxroot pup create-storage"] = CommandResult{Err: errors.New("exit status 1")}
+	updater := SystemUpdater{runner: runner, pupManager: pups, config: dogeboxd.ServerConfig{DataDir: "/opt/dogebox"}}
+
+	require.Error(t, updater.setPupStorageQuota(testRunnerJob(pup), dogeboxd.SetPupStorageQuota{PupID: "abc", QuotaMB: 2048}))
+	assert.Equal(t, 1024, pups.states["abc"].StorageQuotaMB, "not saved when it couldn't be applied")
+
+	delete(runner.Results, "_dbxroot pup create-storage")
+	require.NoError(t, updater.setPupStorageQuota(testRunnerJob(pup), dogeboxd.SetPupStorageQuota{PupID: "abc", QuotaMB: 2048}))
+	assert.Equal(t, 2048, pups.states["abc"].StorageQuotaMB)
+}
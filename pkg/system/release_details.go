@@ -0,0 +1,381 @@
+package system
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+var RELEASE_API_URL = "https://api.github.com/repos/dogebox-wg/os/releases?per_page=100"
+
+// Where we look for store paths that are already installed.
+var NIX_STORE_DIR = "/nix/store"
+
+// NixOS always substitutes from here, alongside any caches we add.
+const DEFAULT_BINARY_CACHE = "https://cache.nixos.org"
+
+// How long the release list is reused for before asking GitHub again.
+var releaseDetailsTTL = 10 * time.Minute
+
+// How many narinfos we fetch at once when sizing a release.
+const narInfoWorkers = 8
+
+/* ReleaseSizeEstimate is what upgrading to a release should fetch: every
+ * store path in the closure of the system its signed manifest names for
+ * us (ReleaseManifest.Toplevels) that we don't already have, sized from
+ * the first binary cache with its narinfo. The closure is walked through
+ * each narinfo's References. Paths no cache has would be built locally,
+ * so aren't counted in the byte totals, nor is anything only they refer to.
+ */
+type ReleaseSizeEstimate struct {
+	DownloadBytes  int64
+	InstalledBytes int64
+	Paths          int
+	UncachedPaths  int
+}
+
+type githubOSReleaseAsset struct {
+	Name               string `json:"name"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+}
+
+type githubOSRelease struct {
+	TagName     string                 `json:"tag_name"`
+	Body        string                 `json:"body"`
+	HTMLURL     string                 `json:"html_url"`
+	PublishedAt string                 `json:"published_at"`
+	Assets      []githubOSReleaseAsset `json:"assets"`
+}
+
+type narInfo struct {
+	fileSize   int64
+	narSize    int64
+	references []string // store path names, ie. <hash>-<name>
+}
+
+// A release's size, worked out in the background, see releaseSize.
+type releaseSizeResult struct {
+	estimate *ReleaseSizeEstimate
+	err      error
+	done     bool
+	at       time.Time
+}
+
+type releaseDescriber struct {
+	client *http.Client
+
+	lock            sync.Mutex
+	releases        map[string]githubOSRelease
+	releasesFetched time.Time
+	sizes           map[string]*releaseSizeResult // by version
+	// Store paths are immutable, so these are kept for good.
+	narInfos map[string]narInfo
+}
+
+func newReleaseDescriber() *releaseDescriber {
+	return &releaseDescriber{
+		client:   &http.Client{Timeout: 10 * time.Second},
+		sizes:    map[string]*releaseSizeResult{},
+		narInfos: map[string]narInfo{},
+	}
+}
+
+var osReleaseDescriber = newReleaseDescriber()
+
+/* DescribeUpgradableReleases adds release notes to releases, and size
+ * estimates where EstimateReleaseSize has already worked them out, as
+ * sizing a release is too slow to do for every release on every check.
+ * Anything we can't find out is logged and left empty, the releases are
+ * still upgradable without it.
+ */
+func DescribeUpgradableReleases(releases []UpgradableRelease) []UpgradableRelease {
+	return osReleaseDescriber.describe(releases)
+}
+
+/* EstimateReleaseSize returns what upgrading to version should fetch from
+ * caches, once it's been worked out. The first call starts working it out
+ * in the background and returns done false, as walking a whole system's
+ * closure through the binary caches takes a while. Results are reused for
+ * a while, as what's in our store changes. A nil estimate with done set
+ * means the release doesn't tell us enough to size it.
+ */
+func EstimateReleaseSize(version string, caches []string) (estimate *ReleaseSizeEstimate, done bool, err error) {
+	return osReleaseDescriber.releaseSize(version, caches, dogeboxd.RELEASE_SIGNING_KEYS)
+}
+
+func (d *releaseDescriber) describe(releases []UpgradableRelease) []UpgradableRelease {
+	if len(releases) == 0 {
+		return releases
+	}
+
+	details, err := d.getReleases()
+	if err != nil {
+		log.Printf("Failed to fetch OS release details: %v", err)
+		return releases
+	}
+
+	described := make([]UpgradableRelease, len(releases))
+	for i, release := range releases {
+		described[i] = release
+
+		gh, ok := details[release.Version]
+		if !ok {
+			continue
+		}
+		described[i].ReleaseNotes = strings.TrimSpace(gh.Body)
+		if gh.HTMLURL != "" {
+			described[i].ReleaseURL = gh.HTMLURL
+		}
+		if t, err := time.Parse(time.RFC3339, gh.PublishedAt); err == nil {
+			described[i].PublishedAt = &t
+		}
+		described[i].Size = d.cachedSize(release.Version)
+	}
+	return described
+}
+
+// cachedSize returns the estimate for version if one's been worked out.
+func (d *releaseDescriber) cachedSize(version string) *ReleaseSizeEstimate {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if r, ok := d.sizes[version]; ok && r.done && time.Since(r.at) < releaseDetailsTTL {
+		return r.estimate
+	}
+	return nil
+}
+
+func (d *releaseDescriber) releaseSize(version string, caches []string, trustedKeys []string) (*ReleaseSizeEstimate, bool, error) {
+	d.lock.Lock()
+	r, ok := d.sizes[version]
+	if ok && (!r.done || time.Since(r.at) < releaseDetailsTTL) {
+		defer d.lock.Unlock()
+		return r.estimate, r.done, r.err
+	}
+	r = &releaseSizeResult{}
+	d.sizes[version] = r
+	d.lock.Unlock()
+
+	go func() {
+		estimate, err := d.estimateSize(version, caches, trustedKeys)
+		if err != nil {
+			log.Printf("Failed to estimate size of OS release %s: %v", version, err)
+		}
+		d.lock.Lock()
+		defer d.lock.Unlock()
+		r.estimate, r.err, r.done, r.at = estimate, err, true, time.Now()
+	}()
+	return nil, false, nil
+}
+
+func (d *releaseDescriber) getReleases() (map[string]githubOSRelease, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.releases != nil && time.Since(d.releasesFetched) < releaseDetailsTTL {
+		return d.releases, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, RELEASE_API_URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", "dogeboxd")
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("github release api returned %s", resp.Status)
+	}
+
+	var list []githubOSRelease
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, err
+	}
+
+	releases := make(map[string]githubOSRelease, len(list))
+	for _, r := range list {
+		releases[r.TagName] = r
+	}
+	d.releases = releases
+	d.releasesFetched = time.Now()
+	return releases, nil
+}
+
+// estimateSize returns nil if the release's manifest has no system for us.
+func (d *releaseDescriber) estimateSize(version string, caches []string, trustedKeys []string) (*ReleaseSizeEstimate, error) {
+	m, err := d.getManifest(version, trustedKeys)
+	if err != nil {
+		return nil, err
+	}
+	toplevel := m.Toplevels[dogeboxd.CurrentNixSystem()]
+	if toplevel == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(toplevel, "/nix/store/") || storePathHash(toplevel) == "" {
+		return nil, fmt.Errorf("release manifest has invalid store path %q", toplevel)
+	}
+
+	estimate := &ReleaseSizeEstimate{}
+	seen := map[string]bool{}
+	todo := []string{filepath.Base(toplevel)}
+	for len(todo) > 0 {
+		// Anything we already have, we have the whole closure of too.
+		missing := []string{}
+		for _, name := range todo {
+			if seen[name] || storePathHash(name) == "" {
+				continue
+			}
+			seen[name] = true
+			if _, err := os.Stat(filepath.Join(NIX_STORE_DIR, name)); err == nil {
+				continue
+			}
+			missing = append(missing, name)
+		}
+
+		infos := d.getNarInfos(missing, caches)
+		todo = nil
+		for _, name := range missing {
+			estimate.Paths++
+			info, ok := infos[storePathHash(name)]
+			if !ok {
+				estimate.UncachedPaths++
+				continue
+			}
+			estimate.DownloadBytes += info.fileSize
+			estimate.InstalledBytes += info.narSize
+			todo = append(todo, info.references...)
+		}
+	}
+	return estimate, nil
+}
+
+func storePathHash(path string) string {
+	hash, _, ok := strings.Cut(filepath.Base(path), "-")
+	if !ok || len(hash) != 32 {
+		return ""
+	}
+	return hash
+}
+
+// getNarInfos returns the narinfos of whichever store paths a cache has,
+// keyed by store path hash.
+func (d *releaseDescriber) getNarInfos(paths []string, caches []string) map[string]narInfo {
+	infos := map[string]narInfo{}
+	todo := make(chan string)
+	var wg sync.WaitGroup
+	var infosLock sync.Mutex
+
+	for i := 0; i < narInfoWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for hash := range todo {
+				info, ok := d.getNarInfo(hash, caches)
+				if !ok {
+					continue
+				}
+				infosLock.Lock()
+				infos[hash] = info
+				infosLock.Unlock()
+			}
+		}()
+	}
+
+	for _, path := range paths {
+		todo <- storePathHash(path)
+	}
+	close(todo)
+	wg.Wait()
+	return infos
+}
+
+func (d *releaseDescriber) getNarInfo(hash string, caches []string) (narInfo, bool) {
+	d.lock.Lock()
+	info, ok := d.narInfos[hash]
+	d.lock.Unlock()
+	if ok {
+		return info, true
+	}
+
+	for _, cache := range caches {
+		info, err := d.fetchNarInfo(cache, hash)
+		if err != nil {
+			continue
+		}
+		d.lock.Lock()
+		d.narInfos[hash] = info
+		d.lock.Unlock()
+		return info, true
+	}
+	return narInfo{}, false
+}
+
+func (d *releaseDescriber) fetchNarInfo(cache string, hash string) (narInfo, error) {
+	resp, err := d.client.Get(fmt.Sprintf("%s/%s.narinfo", strings.TrimSuffix(cache, "/"), hash))
+	if err != nil {
+		return narInfo{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return narInfo{}, fmt.Errorf("narinfo returned %s", resp.Status)
+	}
+
+	return parseNarInfo(io.LimitReader(resp.Body, 64<<10))
+}
+
+func parseNarInfo(r io.Reader) (narInfo, error) {
+	info := narInfo{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ": ")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "FileSize":
+			info.fileSize, _ = strconv.ParseInt(value, 10, 64)
+		case "NarSize":
+			info.narSize, _ = strconv.ParseInt(value, 10, 64)
+		case "References":
+			info.references = strings.Fields(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return narInfo{}, err
+	}
+	if info.narSize == 0 {
+		return narInfo{}, fmt.Errorf("narinfo has no NarSize")
+	}
+	// Uncompressed caches may leave FileSize out.
+	if info.fileSize == 0 {
+		info.fileSize = info.narSize
+	}
+	return info, nil
+}
+
+// ReleaseBinaryCaches is where a release's store paths would be fetched
+// from: the caches we've added, then the NixOS cache.
+func ReleaseBinaryCaches(dbxState dogeboxd.DogeboxState) []string {
+	caches := []string{}
+	for _, cache := range dbxState.BinaryCaches {
+		caches = append(caches, cache.Host)
+	}
+	return append(caches, DEFAULT_BINARY_CACHE)
+}
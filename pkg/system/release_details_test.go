@@ -0,0 +1,167 @@
+package system
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testHashA = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	testHashB = "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+	testHashC = "cccccccccccccccccccccccccccccccc"
+	testHashD = "dddddddddddddddddddddddddddddddd"
+)
+
+// withReleaseServer serves a v1.2.0 release whose system is testHashB,
+// which refers to testHashA (installed), testHashC (in the second cache)
+// and testHashD (in no cache). It returns the key the manifest is signed with.
+func withReleaseServer(t *testing.T, releasesCalls *int32) (*httptest.Server, string) {
+	manifest := fmt.Sprintf(`{"version":"v1.2.0","rev":%q,"toplevels":{%q:"/nix/store/%s-nixos-system"}}`,
+		testReleaseRev, dogeboxd.CurrentNixSystem(), testHashB)
+	key, signature := signTestReleaseManifest(t, manifest)
+
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/releases":
+			atomic.AddInt32(releasesCalls, 1)
+			fmt.Fprintf(w, `[
+				{"tag_name": "v1.2.0", "body": " ## Changes\n- faster ", "html_url": "https://example/v1.2.0", "published_at": "2026-09-01T10:00:00Z",
+				 "assets": [
+					{"name": %q, "browser_download_url": "%s/manifest"},
+					{"name": %q, "browser_download_url": "%s/manifest.sig"}
+				 ]},
+				{"tag_name": "v1.1.0", "body": "old"}
+			]`, RELEASE_MANIFEST_ASSET, srv.URL, RELEASE_MANIFEST_SIGNATURE_ASSET, srv.URL)
+		case "/manifest":
+			fmt.Fprint(w, manifest)
+		case "/manifest.sig":
+			fmt.Fprint(w, signature)
+		case "/cache1/" + testHashB + ".narinfo":
+			fmt.Fprintf(w, "StorePath: /nix/store/x\nCompression: xz\nFileSize: 100\nNarSize: 400\nReferences: %s-installed %s-nixos-system %s-second %s-nowhere\n",
+				testHashA, testHashB, testHashC, testHashD)
+		case "/cache2/" + testHashC + ".narinfo":
+			fmt.Fprint(w, "StorePath: /nix/store/y\nNarSize: 50\nReferences: \n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	oldURL, oldStore := RELEASE_API_URL, NIX_STORE_DIR
+	RELEASE_API_URL = srv.URL + "/releases"
+	NIX_STORE_DIR = t.TempDir()
+	t.Cleanup(func() { RELEASE_API_URL, NIX_STORE_DIR = oldURL, oldStore })
+
+	require.NoError(t, os.Mkdir(filepath.Join(NIX_STORE_DIR, testHashA+"-installed"), 0755))
+	return srv, key
+}
+
+func TestDescribeUpgradableReleases(t *testing.T) {
+	if dogeboxd.CurrentNixSystem() == "" {
+		t.Skip("no nix system for this platform")
+	}
+
+	var calls int32
+	withReleaseServer(t, &calls)
+	d := newReleaseDescriber()
+
+	releases := []UpgradableRelease{
+		{Version: "v1.2.0", ReleaseURL: "https://github.com/dogebox-wg/os/releases/tag/v1.2.0"},
+		{Version: "v1.1.0"},
+		{Version: "v1.0.1"},
+	}
+
+	described := d.describe(releases)
+	require.Len(t, described, 3)
+
+	assert.Equal(t, "## Changes\n- faster", described[0].ReleaseNotes)
+	assert.Equal(t, "https://example/v1.2.0", described[0].ReleaseURL)
+	require.NotNil(t, described[0].PublishedAt)
+	assert.Equal(t, 2026, described[0].PublishedAt.Year())
+	assert.Nil(t, described[0].Size, "not sized until asked for")
+
+	assert.Equal(t, "old", described[1].ReleaseNotes)
+	assert.Equal(t, UpgradableRelease{Version: "v1.0.1"}, described[2])
+
+	// The release list is reused.
+	d.describe(releases)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestEstimateReleaseSize(t *testing.T) {
+	if dogeboxd.CurrentNixSystem() == "" {
+		t.Skip("no nix system for this platform")
+	}
+
+	var calls int32
+	srv, key := withReleaseServer(t, &calls)
+	d := newReleaseDescriber()
+	caches := []string{srv.URL + "/cache1/", srv.URL + "/cache2"}
+
+	size, done, err := d.releaseSize("v1.2.0", caches, []string{key})
+	require.NoError(t, err)
+	assert.False(t, done, "sized in the background")
+	assert.Nil(t, size)
+
+	require.Eventually(t, func() bool {
+		size, done, err = d.releaseSize("v1.2.0", caches, []string{key})
+		return done
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, err)
+	expected := &ReleaseSizeEstimate{DownloadBytes: 150, InstalledBytes: 450, Paths: 3, UncachedPaths: 1}
+	assert.Equal(t, expected, size)
+	assert.Len(t, d.narInfos, 2)
+
+	// Once sized, releases are described with it.
+	described := d.describe([]UpgradableRelease{{Version: "v1.2.0"}})
+	assert.Equal(t, expected, described[0].Size)
+
+	// Releases without a manifest can't be sized.
+	require.Eventually(t, func() bool {
+		_, done, err = d.releaseSize("v1.1.0", caches, []string{key})
+		return done
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.ErrorContains(t, err, "manifest")
+}
+
+func TestDescribeUpgradableReleasesGitHubDown(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "rate limited", http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	oldURL := RELEASE_API_URL
+	RELEASE_API_URL = srv.URL
+	defer func() { RELEASE_API_URL = oldURL }()
+
+	releases := []UpgradableRelease{{Version: "v1.2.0", Summary: "Update"}}
+	assert.Equal(t, releases, newReleaseDescriber().describe(releases))
+}
+
+func TestParseNarInfo(t *testing.T) {
+	info, err := parseNarInfo(strings.NewReader("FileSize: 10\nNarSize: 20\nReferences: " + testHashA + "-a " + testHashB + "-b\n"))
+	require.NoError(t, err)
+	assert.Equal(t, narInfo{fileSize: 10, narSize: 20, references: []string{testHashA + "-a", testHashB + "-b"}}, info)
+
+	_, err = parseNarInfo(strings.NewReader("StorePath: /nix/store/x\n"))
+	assert.Error(t, err)
+}
+
+func TestReleaseBinaryCaches(t *testing.T) {
+	caches := ReleaseBinaryCaches(dogeboxd.DogeboxState{
+		BinaryCaches: []dogeboxd.DogeboxStateBinaryCache{{Host: "https://dbx.nix.dogecoin.org"}},
+	})
+	assert.Equal(t, []string{"https://dbx.nix.dogecoin.org", DEFAULT_BINARY_CACHE}, caches)
+}
@@ -0,0 +1,114 @@
+package system
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+/*
+ * Release tags alone say nothing about who made them, so every OS release
+ * carries a manifest pinning its version to a commit, signed by the
+ * foundation. We won't switch to a release unless the manifest is signed
+ * by one of dogeboxd.RELEASE_SIGNING_KEYS and the tag we cloned is that commit, so
+ * a compromised repo or release channel can't hand us its own system.
+ */
+
+const (
+	RELEASE_MANIFEST_ASSET           = "release-manifest.json"
+	RELEASE_MANIFEST_SIGNATURE_ASSET = "release-manifest.json.sig"
+
+	maxReleaseManifestBytes = 64 << 10
+)
+
+type ReleaseManifest struct {
+	Version string `json:"version"`
+	// The full commit hash the release's tag points at.
+	Rev string `json:"rev"`
+	// The system store path the release boots, by nix system, ie.
+	// "aarch64-linux": "/nix/store/<hash>-nixos-system-dogebox-<version>".
+	// Optional, it's only used to size upgrades, see ReleaseSizeEstimate.
+	Toplevels map[string]string `json:"toplevels,omitempty"`
+}
+
+// VerifyReleaseManifest checks manifest was signed by one of trustedKeys,
+// and is for version.
+func VerifyReleaseManifest(manifest []byte, signature string, version string, trustedKeys []string) (ReleaseManifest, error) {
+	var m ReleaseManifest
+	if _, err := dogeboxd.VerifyNixSignature(manifest, signature, trustedKeys); err != nil {
+		return m, fmt.Errorf("release manifest for %s %w", version, err)
+	}
+	if err := json.Unmarshal(manifest, &m); err != nil {
+		return m, fmt.Errorf("failed to read release manifest for %s: %w", version, err)
+	}
+	if m.Version != version {
+		return m, fmt.Errorf("release manifest is for %s, not %s", m.Version, version)
+	}
+	if len(m.Rev) != 40 {
+		return m, fmt.Errorf("release manifest for %s has no commit", version)
+	}
+	return m, nil
+}
+
+// checkReleaseRev fails unless the commit we staged is the one the
+// release manifest was signed for.
+func checkReleaseRev(m ReleaseManifest, commitHash string) error {
+	if !strings.EqualFold(m.Rev, commitHash) {
+		return fmt.Errorf("OS release %s is at commit %s, but its signed manifest is for %s", m.Version, commitHash, m.Rev)
+	}
+	return nil
+}
+
+// GetReleaseManifest fetches and verifies the signed manifest for a
+// release, failing if it has none.
+func GetReleaseManifest(version string) (ReleaseManifest, error) {
+	return osReleaseDescriber.getManifest(version, dogeboxd.RELEASE_SIGNING_KEYS)
+}
+
+func (d *releaseDescriber) getManifest(version string, trustedKeys []string) (ReleaseManifest, error) {
+	releases, err := d.getReleases()
+	if err != nil {
+		return ReleaseManifest{}, fmt.Errorf("failed to fetch OS release details: %w", err)
+	}
+
+	release, ok := releases[version]
+	if !ok {
+		return ReleaseManifest{}, fmt.Errorf("OS release %s isn't published", version)
+	}
+
+	assets := map[string]string{}
+	for _, asset := range release.Assets {
+		assets[asset.Name] = asset.BrowserDownloadURL
+	}
+	if assets[RELEASE_MANIFEST_ASSET] == "" || assets[RELEASE_MANIFEST_SIGNATURE_ASSET] == "" {
+		return ReleaseManifest{}, fmt.Errorf("OS release %s has no signed manifest", version)
+	}
+
+	manifest, err := d.getAsset(assets[RELEASE_MANIFEST_ASSET])
+	if err != nil {
+		return ReleaseManifest{}, fmt.Errorf("failed to fetch release manifest for %s: %w", version, err)
+	}
+	signature, err := d.getAsset(assets[RELEASE_MANIFEST_SIGNATURE_ASSET])
+	if err != nil {
+		return ReleaseManifest{}, fmt.Errorf("failed to fetch release manifest signature for %s: %w", version, err)
+	}
+
+	return VerifyReleaseManifest(manifest, string(signature), version, trustedKeys)
+}
+
+func (d *releaseDescriber) getAsset(url string) ([]byte, error) {
+	resp, err := d.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("asset returned %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxReleaseManifestBytes))
+}
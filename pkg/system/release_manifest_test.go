@@ -0,0 +1,96 @@
+package system
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testReleaseRev = "0123456789abcdef0123456789abcdef01234567"
+
+func signTestReleaseManifest(t *testing.T, manifest string) (string, string) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	key := "release-1:" + base64.StdEncoding.EncodeToString(pub)
+	signature := "release-1:" + base64.StdEncoding.EncodeToString(ed25519.Sign(priv, []byte(manifest)))
+	return key, signature
+}
+
+func TestVerifyReleaseManifest(t *testing.T) {
+	manifest := fmt.Sprintf(`{"version":"v1.2.0","rev":%q}`, testReleaseRev)
+	key, signature := signTestReleaseManifest(t, manifest)
+
+	m, err := VerifyReleaseManifest([]byte(manifest), signature+"\n", "v1.2.0", []string{key})
+	require.NoError(t, err)
+	assert.Equal(t, ReleaseManifest{Version: "v1.2.0", Rev: testReleaseRev}, m)
+	assert.NoError(t, checkReleaseRev(m, strings.ToUpper(testReleaseRev)))
+	assert.ErrorContains(t, checkReleaseRev(m, strings.Repeat("f", 40)), "signed manifest")
+
+	_, err = VerifyReleaseManifest([]byte(manifest), signature, "v1.2.0", dogeboxd.RELEASE_SIGNING_KEYS)
+	assert.ErrorContains(t, err, "trusted key")
+
+	_, err = VerifyReleaseManifest([]byte(manifest), signature, "v1.3.0", []string{key})
+	assert.ErrorContains(t, err, "not v1.3.0")
+
+	tampered := strings.Replace(manifest, "0123", "4567", 1)
+	_, err = VerifyReleaseManifest([]byte(tampered), signature, "v1.2.0", []string{key})
+	assert.ErrorContains(t, err, "trusted key")
+
+	noRev := `{"version":"v1.2.0"}`
+	key, signature = signTestReleaseManifest(t, noRev)
+	_, err = VerifyReleaseManifest([]byte(noRev), signature, "v1.2.0", []string{key})
+	assert.ErrorContains(t, err, "no commit")
+}
+
+func TestGetReleaseManifest(t *testing.T) {
+	manifest := fmt.Sprintf(`{"version":"v1.2.0","rev":%q}`, testReleaseRev)
+	key, signature := signTestReleaseManifest(t, manifest)
+
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/releases":
+			fmt.Fprintf(w, `[
+				{"tag_name": "v1.2.0", "assets": [
+					{"name": %q, "browser_download_url": "%s/manifest"},
+					{"name": %q, "browser_download_url": "%s/manifest.sig"}
+				]},
+				{"tag_name": "v1.1.0", "assets": []}
+			]`, RELEASE_MANIFEST_ASSET, srv.URL, RELEASE_MANIFEST_SIGNATURE_ASSET, srv.URL)
+		case "/manifest":
+			fmt.Fprint(w, manifest)
+		case "/manifest.sig":
+			fmt.Fprint(w, signature)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	oldURL := RELEASE_API_URL
+	RELEASE_API_URL = srv.URL + "/releases"
+	defer func() { RELEASE_API_URL = oldURL }()
+
+	d := newReleaseDescriber()
+	m, err := d.getManifest("v1.2.0", []string{key})
+	require.NoError(t, err)
+	assert.Equal(t, testReleaseRev, m.Rev)
+
+	_, err = d.getManifest("v1.1.0", []string{key})
+	assert.ErrorContains(t, err, "no signed manifest")
+
+	_, err = d.getManifest("v9.9.9", []string{key})
+	assert.ErrorContains(t, err, "isn't published")
+
+	_, err = d.getManifest("v1.2.0", dogeboxd.RELEASE_SIGNING_KEYS)
+	assert.ErrorContains(t, err, "trusted key")
+}
@@ -0,0 +1,87 @@
+package system
+
+import (
+	"fmt"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+// How many rebuilds in a row can fail before we automatically enter safe mode.
+const SafeModeRebuildFailureThreshold = 3
+
+func IsSafeMode(sm dogeboxd.StateManager) bool {
+	return sm.Get().Dogebox.Flags.IsSafeMode
+}
+
+/* RecordRebuildFailure counts a failed nix rebuild, and returns true if
+ * this failure is the one that crosses the threshold where safe mode
+ * should automatically be enabled. Later failures don't return true
+ * again, so a SetSafeMode is only queued once.
+ *
+ * Rebuilds report from whichever goroutine ran them, so this goes via
+ * UpdateDogebox.
+ */
+func RecordRebuildFailure(sm dogeboxd.StateManager) (bool, error) {
+	shouldEnter := false
+	err := sm.UpdateDogebox(func(dbxState *dogeboxd.DogeboxState) bool {
+		dbxState.SafeMode.ConsecutiveRebuildFailures++
+		shouldEnter = !dbxState.Flags.IsSafeMode && dbxState.SafeMode.ConsecutiveRebuildFailures == SafeModeRebuildFailureThreshold
+		return true
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to record rebuild failure: %w", err)
+	}
+	return shouldEnter, nil
+}
+
+// ResetRebuildFailures clears our failed rebuild count after a successful rebuild.
+func ResetRebuildFailures(sm dogeboxd.StateManager) error {
+	return sm.UpdateDogebox(func(dbxState *dogeboxd.DogeboxState) bool {
+		if dbxState.SafeMode.ConsecutiveRebuildFailures == 0 {
+			return false
+		}
+		dbxState.SafeMode.ConsecutiveRebuildFailures = 0
+		return true
+	})
+}
+
+func (t SystemUpdater) setSafeMode(a dogeboxd.SetSafeMode, log dogeboxd.SubLogger) error {
+	dbxState := t.sm.Get().Dogebox
+	previous := dbxState.SafeMode.Reason
+
+	if a.Enabled {
+		log.Logf("Enabling safe mode: %s", a.Reason)
+		dbxState.SafeMode.Reason = a.Reason
+	} else {
+		log.Log("Disabling safe mode, pups will be added back to the system")
+		dbxState.SafeMode.Reason = ""
+		dbxState.SafeMode.ConsecutiveRebuildFailures = 0
+	}
+	dbxState.Flags.IsSafeMode = a.Enabled
+
+	if err := t.sm.SetDogebox(dbxState); err != nil {
+		log.Errf("Failed to save safe mode state: %v", err)
+		return err
+	}
+
+	log.Progress(20).Log("Applying system configuration...")
+
+	patch := t.nix.NewPatch(log)
+	t.nix.UpdateIncludesFile(patch, t.pupManager, dbxState)
+
+	if err := patch.Apply(); err != nil {
+		log.Errf("Failed to apply nix patch: %v", err)
+
+		// Our nix config has been rolled back, so put our state back to match.
+		restored := t.sm.Get().Dogebox
+		restored.Flags.IsSafeMode = !a.Enabled
+		restored.SafeMode.Reason = previous
+		if err := t.sm.SetDogebox(restored); err != nil {
+			log.Errf("Failed to restore safe mode state: %v", err)
+		}
+		return err
+	}
+
+	log.Progress(100).Logf("Safe mode enabled: %t", a.Enabled)
+	return nil
+}
@@ -0,0 +1,59 @@
+package system
+
+import (
+	"testing"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSafeModeTestStateManager(t *testing.T) dogeboxd.StateManager {
+	store, err := dogeboxd.NewStoreManager(":memory:")
+	require.NoError(t, err)
+	return NewStateManager(store)
+}
+
+func TestRecordRebuildFailureTriggersSafeModeAtThreshold(t *testing.T) {
+	sm := newSafeModeTestStateManager(t)
+
+	for i := 1; i < SafeModeRebuildFailureThreshold; i++ {
+		shouldEnter, err := RecordRebuildFailure(sm)
+		require.NoError(t, err)
+		assert.False(t, shouldEnter, "should not enter safe mode after %d failures", i)
+	}
+
+	shouldEnter, err := RecordRebuildFailure(sm)
+	require.NoError(t, err)
+	assert.True(t, shouldEnter)
+	assert.Equal(t, SafeModeRebuildFailureThreshold, sm.Get().Dogebox.SafeMode.ConsecutiveRebuildFailures)
+
+	// Only crossing the threshold queues safe mode, not every failure after.
+	shouldEnter, err = RecordRebuildFailure(sm)
+	require.NoError(t, err)
+	assert.False(t, shouldEnter)
+}
+
+func TestRecordRebuildFailureDoesNotRetriggerWhileInSafeMode(t *testing.T) {
+	sm := newSafeModeTestStateManager(t)
+
+	dbxState := sm.Get().Dogebox
+	dbxState.Flags.IsSafeMode = true
+	dbxState.SafeMode.ConsecutiveRebuildFailures = SafeModeRebuildFailureThreshold
+	require.NoError(t, sm.SetDogebox(dbxState))
+
+	shouldEnter, err := RecordRebuildFailure(sm)
+	require.NoError(t, err)
+	assert.False(t, shouldEnter)
+	assert.True(t, IsSafeMode(sm))
+}
+
+func TestResetRebuildFailuresClearsCount(t *testing.T) {
+	sm := newSafeModeTestStateManager(t)
+
+	_, err := RecordRebuildFailure(sm)
+	require.NoError(t, err)
+	require.NoError(t, ResetRebuildFailures(sm))
+
+	assert.Equal(t, 0, sm.Get().Dogebox.SafeMode.ConsecutiveRebuildFailures)
+}
@@ -28,6 +28,7 @@ func (t SystemUpdater) sshUpdate(dbxState dogeboxd.DogeboxState, log dogeboxd.Su
 func (t SystemUpdater) EnableSSH(l dogeboxd.SubLogger) error {
 	state := t.sm.Get().Dogebox
 	state.SSH.Enabled = true
+	state.SSH.EnabledUntil = nil
 
 	if err := t.sm.SetDogebox(state); err != nil {
 		return err
@@ -36,9 +37,29 @@ func (t SystemUpdater) EnableSSH(l dogeboxd.SubLogger) error {
 	return t.sshUpdate(state, l)
 }
 
+// EnableSSHTemporary enables SSH until hours from now, when Dogeboxd
+// queues a DisableSSH. Enabling it again moves the expiry.
+func (t SystemUpdater) EnableSSHTemporary(hours int, l dogeboxd.SubLogger) error {
+	if err := dogeboxd.ValidateTemporarySSHHours(hours); err != nil {
+		return err
+	}
+
+	state := t.sm.Get().Dogebox
+	until := time.Now().Add(time.Duration(hours) * time.Hour)
+	state.SSH.Enabled = true
+	state.SSH.EnabledUntil = &until
+	if err := t.sm.SetDogebox(state); err != nil {
+		return err
+	}
+	l.Logf("SSH enabled until %s", until.Format(time.RFC3339))
+
+	return t.sshUpdate(state, l)
+}
+
 func (t SystemUpdater) DisableSSH(l dogeboxd.SubLogger) error {
 	state := t.sm.Get().Dogebox
 	state.SSH.Enabled = false
+	state.SSH.EnabledUntil = nil
 	if err := t.sm.SetDogebox(state); err != nil {
 		return err
 	}
@@ -5,6 +5,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"log"
+	"sync"
 
 	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
 )
@@ -86,6 +87,7 @@ func generateSetupSessionID() string {
 }
 
 type StateManager struct {
+	mu           sync.RWMutex
 	storeManager *dogeboxd.StoreManager
 	netStore     *dogeboxd.TypeStore[dogeboxd.NetworkState]
 	dbxStore     *dogeboxd.TypeStore[dogeboxd.DogeboxState]
@@ -96,6 +98,8 @@ type StateManager struct {
 }
 
 func (s *StateManager) Get() dogeboxd.State {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	return dogeboxd.State{
 		Network: s.network,
 		Dogebox: s.dogebox,
@@ -112,16 +116,33 @@ func (s *StateManager) OpenDB() error {
 }
 
 func (s *StateManager) SetNetwork(ns dogeboxd.NetworkState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.network = ns
 	return s.netStore.Set(current, s.network)
 }
 
 func (s *StateManager) SetDogebox(dbs dogeboxd.DogeboxState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dogebox = dbs
+	return s.dbxStore.Set(current, s.dogebox)
+}
+
+func (s *StateManager) UpdateDogebox(update func(dbs *dogeboxd.DogeboxState) bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dbs := s.dogebox
+	if !update(&dbs) {
+		return nil
+	}
 	s.dogebox = dbs
 	return s.dbxStore.Set(current, s.dogebox)
 }
 
 func (s *StateManager) SetSources(state dogeboxd.SourceState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.source = state
 	return s.srcStore.Set(current, s.source)
 }
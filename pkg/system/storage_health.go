@@ -0,0 +1,103 @@
+package system
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+/* The data volume's nix services (see storage-overlay.nix) run as root,
+ * so rather than asking rootd for btrfs output on every request they
+ * leave it in dogeboxd.StorageStatusDir:
+ *
+ *   degraded      the mirror was mounted with -o degraded this boot
+ *   filesystem    btrfs filesystem show, hourly
+ *   device-stats  btrfs device stats, hourly
+ *   scrub         btrfs scrub status after the monthly scrub
+ */
+
+// CheckStorageHealth reads what the data volume's services last recorded
+// in dir. A single disk volume only has its mount to report on.
+func CheckStorageHealth(dir string, mirrored bool) dogeboxd.StorageHealthReport {
+	report := dogeboxd.StorageHealthReport{Mirrored: mirrored}
+
+	if b, err := os.ReadFile(filepath.Join(dir, "degraded")); err == nil {
+		report.Degraded = true
+		report.DegradedAt = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(string(b)), "mounted degraded at"))
+	} else if !os.IsNotExist(err) {
+		report.Errors = append(report.Errors, fmt.Sprintf("degraded: %v", err))
+	}
+
+	if mirrored {
+		read := func(name string) string {
+			b, err := os.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				// Not there until the status timer or first scrub has run.
+				if !os.IsNotExist(err) {
+					report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", name, err))
+				}
+				return ""
+			}
+			return string(b)
+		}
+
+		report.MissingDevices = strings.Contains(read("filesystem"), "devices missing")
+		report.DeviceErrors = parseDeviceStats(read("device-stats"))
+		if scrub := read("scrub"); scrub != "" {
+			report.Scrub = parseScrubStatus(scrub)
+		}
+	}
+
+	report.Healthy = !report.Degraded && !report.MissingDevices && len(report.DeviceErrors) == 0 &&
+		(report.Scrub == nil || report.Scrub.Uncorrected == 0)
+	return report
+}
+
+// parseDeviceStats keeps the non-zero counters from btrfs device stats,
+// whose lines look like "[/dev/sdb1].read_io_errs    0".
+func parseDeviceStats(output string) map[string]int {
+	var errs map[string]int
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		n, err := strconv.Atoi(fields[1])
+		if err != nil || n == 0 {
+			continue
+		}
+		if errs == nil {
+			errs = map[string]int{}
+		}
+		errs[fields[0]] = n
+	}
+	return errs
+}
+
+// parseScrubStatus reads btrfs scrub status. Errors the scrub repaired
+// from the other disk aren't counted as uncorrected.
+func parseScrubStatus(output string) *dogeboxd.StorageScrub {
+	scrub := &dogeboxd.StorageScrub{}
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "Scrub started":
+			scrub.Started = value
+		case "Status":
+			scrub.Status = value
+		case "Error summary":
+			scrub.ErrorSummary = value
+		case "Uncorrectable":
+			scrub.Uncorrected, _ = strconv.Atoi(value)
+		}
+	}
+	return scrub
+}
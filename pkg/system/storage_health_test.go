@@ -0,0 +1,55 @@
+package system
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckStorageHealthHealthyMirror(t *testing.T) {
+	dir := t.TempDir()
+	writeStatus(t, dir, "filesystem", "Label: 'dogebox-storage'  uuid: 0b1c\n\tTotal devices 2 FS bytes used 1.00GiB\n")
+	writeStatus(t, dir, "device-stats", "[/dev/sdb1].write_io_errs    0\n[/dev/sdc1].read_io_errs     0\n")
+	writeStatus(t, dir, "scrub", "UUID:             0b1c\nScrub started:    Sun Oct  6 10:00:01 2024\nStatus:           finished\nError summary:    no errors found\n")
+
+	report := CheckStorageHealth(dir, true)
+	assert.True(t, report.Healthy)
+	assert.False(t, report.Degraded)
+	assert.Empty(t, report.DeviceErrors)
+	require.NotNil(t, report.Scrub)
+	assert.Equal(t, "finished", report.Scrub.Status)
+	assert.Equal(t, "no errors found", report.Scrub.ErrorSummary)
+}
+
+func TestCheckStorageHealthDegradedMirror(t *testing.T) {
+	dir := t.TempDir()
+	writeStatus(t, dir, "degraded", "mounted degraded at 2024-10-06T10:00:00+00:00\n")
+	writeStatus(t, dir, "filesystem", "\tdevid    1 size 100GiB used 1GiB path /dev/sdb1\n\t*** Some devices missing\n")
+	writeStatus(t, dir, "device-stats", "[/dev/sdb1].read_io_errs     0\n[/dev/sdc1].read_io_errs     3\n")
+	writeStatus(t, dir, "scrub", "Status:           finished\nError summary:    csum=4\n  Corrected:      2\n  Uncorrectable:  2\n")
+
+	report := CheckStorageHealth(dir, true)
+	assert.False(t, report.Healthy)
+	assert.True(t, report.Degraded)
+	assert.Equal(t, "2024-10-06T10:00:00+00:00", report.DegradedAt)
+	assert.True(t, report.MissingDevices)
+	assert.Equal(t, map[string]int{"[/dev/sdc1].read_io_errs": 3}, report.DeviceErrors)
+	require.NotNil(t, report.Scrub)
+	assert.Equal(t, "csum=4", report.Scrub.ErrorSummary)
+	assert.Equal(t, 2, report.Scrub.Uncorrected)
+}
+
+func TestCheckStorageHealthNothingRecordedYet(t *testing.T) {
+	report := CheckStorageHealth(t.TempDir(), true)
+	assert.True(t, report.Healthy)
+	assert.Nil(t, report.Scrub)
+	assert.Empty(t, report.Errors)
+}
+
+func writeStatus(t *testing.T, dir, name, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+}
@@ -0,0 +1,179 @@
+package system
+
+import (
+	"fmt"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+/* updateSystemPups brings the system pups up to an OS release being
+ * switched to, see dogeboxd.SystemPupPin. New ones are installed and the
+ * rest upgraded through the usual pipeline, as part of the SystemUpdate
+ * job, failing it if one can't be.
+ *
+ * Pups a release no longer ships are handed back to the user, who can
+ * then uninstall them like any other pup.
+ *
+ * What was changed is returned, even on failure, so it can be undone if
+ * the switch doesn't happen, see restoreSystemPups.
+ */
+func (t SystemUpdater) updateSystemPups(releaseDir string, sessionToken string, j dogeboxd.Job) ([]systemPupChange, error) {
+	pins, err := dogeboxd.ReadSystemPupRelease(releaseDir)
+	if err != nil {
+		return nil, err
+	}
+	log := j.Logger.Step("system pups")
+
+	changes := []systemPupChange{}
+	pinned := map[string]bool{}
+	for _, pin := range pins {
+		change, err := t.updateSystemPup(pin, sessionToken, j, log)
+		if change.Installed || change.Upgraded {
+			changes = append(changes, change)
+		}
+		if err != nil {
+			return changes, fmt.Errorf("failed to update system pup %s: %w", pin.Name, err)
+		}
+		pinned[change.PupID] = true
+	}
+
+	for id, s := range t.pupManager.GetStateMap() {
+		if !s.SystemManaged || pinned[id] {
+			continue
+		}
+		log.Logf("%s is no longer a system pup", s.DisplayName())
+		if _, err := t.pupManager.UpdatePup(id, dogeboxd.PupSystemManaged(false), dogeboxd.WithPupdateReason(dogeboxd.PupdateReasonFor(j))); err != nil {
+			log.Errf("Warning: failed to release %s: %v", s.DisplayName(), err)
+			continue
+		}
+		changes = append(changes, systemPupChange{PupID: id, Released: true})
+	}
+	return changes, nil
+}
+
+// systemPupChange is something updateSystemPups did to a pup.
+type systemPupChange struct {
+	PupID     string
+	Installed bool
+	// Upgraded pups have a snapshot to roll back to.
+	Upgraded bool
+	Released bool
+}
+
+/* restoreSystemPups undoes updateSystemPups when the OS isn't switched
+ * after all, so the system pups match the release still running:
+ * upgrades are rolled back to their snapshot, new installs uninstalled
+ * and released pups marked as system pups again. It carries on past
+ * failures, which leave the pup broken for the user to sort out.
+ */
+func (t SystemUpdater) restoreSystemPups(changes []systemPupChange, j dogeboxd.Job) {
+	log := j.Logger.Step("system pups")
+	for i := len(changes) - 1; i >= 0; i-- {
+		change := changes[i]
+		s, _, err := t.pupManager.GetPup(change.PupID)
+		if err != nil {
+			log.Errf("Warning: can't restore system pup %s: %v", change.PupID, err)
+			continue
+		}
+		pupJob := j
+		pupJob.State = &s
+
+		switch {
+		case change.Upgraded:
+			log.Logf("Rolling %s back, as the OS wasn't updated", s.DisplayName())
+			err = t.rollbackPupUpgrade(pupJob)
+		case change.Installed:
+			log.Logf("Uninstalling %s, as the OS wasn't updated", s.DisplayName())
+			err = t.uninstallPup(pupJob)
+		case change.Released:
+			_, err = t.pupManager.UpdatePup(s.ID, dogeboxd.PupSystemManaged(true), dogeboxd.WithPupdateReason(dogeboxd.PupdateReasonFor(j)))
+		}
+		if err != nil {
+			log.Errf("Warning: failed to restore %s: %v", s.DisplayName(), err)
+		}
+	}
+}
+
+// updateSystemPup installs or upgrades one system pup, returning what it
+// did, with no PupID if it couldn't be installed yet.
+func (t SystemUpdater) updateSystemPup(pin dogeboxd.SystemPupPin, sessionToken string, j dogeboxd.Job, log dogeboxd.SubLogger) (systemPupChange, error) {
+	sourceID := ""
+	for _, config := range t.sources.GetAllSourceConfigurations() {
+		if config.Location == pin.Source {
+			sourceID = config.ID
+			break
+		}
+	}
+	if sourceID == "" {
+		log.Logf("Adding source %s for %s", pin.Source, pin.Name)
+		source, err := t.sources.AddSource(pin.Source, nil)
+		if err != nil {
+			return systemPupChange{}, fmt.Errorf("failed to add source: %w", err)
+		}
+		sourceID = source.Config().ID
+	}
+
+	manifest, source, err := t.sources.GetSourceManifest(sourceID, pin.Name, pin.Version)
+	if err != nil {
+		return systemPupChange{}, err
+	}
+	if !manifest.Meta.System {
+		return systemPupChange{}, fmt.Errorf("%s %s isn't marked as a system pup in its manifest", pin.Name, pin.Version)
+	}
+
+	s, ok := dogeboxd.FindSystemPup(t.pupManager.GetStateMap(), pin)
+	if !ok {
+		// Installing needs a DKM session for the pup's keys, which an update
+		// queued without one (ie. by a migration) doesn't have.
+		if sessionToken == "" {
+			log.Errf("Warning: can't install %s without a login session, it will be installed by the next update", pin.Name)
+			return systemPupChange{}, nil
+		}
+
+		log.Logf("Installing %s %s", pin.Name, pin.Version)
+		pupID, err := t.pupManager.AdoptPup(manifest, source, dogeboxd.AdoptPupOptions{})
+		if err != nil {
+			return systemPupChange{}, err
+		}
+		s, err = t.pupManager.UpdatePup(pupID, dogeboxd.PupSystemManaged(true), dogeboxd.WithPupdateReason(dogeboxd.PupdateReasonFor(j)))
+		if err != nil {
+			return systemPupChange{}, err
+		}
+
+		install := dogeboxd.InstallPup{
+			PupName:      pin.Name,
+			PupVersion:   pin.Version,
+			SourceId:     sourceID,
+			SessionToken: sessionToken,
+		}
+		pupJob := j
+		pupJob.State = &s
+		return systemPupChange{PupID: pupID, Installed: true}, t.installPup(install, pupJob)
+	}
+
+	if !s.SystemManaged {
+		s, err = t.pupManager.UpdatePup(s.ID, dogeboxd.PupSystemManaged(true), dogeboxd.WithPupdateReason(dogeboxd.PupdateReasonFor(j)))
+		if err != nil {
+			return systemPupChange{}, err
+		}
+	}
+
+	if s.Version == pin.Version {
+		log.Logf("%s is already at %s", s.DisplayName(), pin.Version)
+		return systemPupChange{PupID: s.ID}, nil
+	}
+
+	log.Logf("Upgrading %s from %s to %s", s.DisplayName(), s.Version, pin.Version)
+	upgrade := dogeboxd.UpgradePup{
+		PupID:         s.ID,
+		TargetVersion: pin.Version,
+		SourceId:      sourceID,
+	}
+	pupJob := j
+	pupJob.State = &s
+	if err := t.upgradePup(upgrade, pupJob); err != nil {
+		// It may not have got as far as its snapshot, so is left broken.
+		return systemPupChange{PupID: s.ID}, err
+	}
+	return systemPupChange{PupID: s.ID, Upgraded: true}, nil
+}
@@ -0,0 +1,32 @@
+package system
+
+import (
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/Dogebox-WG/dogeboxd/pkg/rootd"
+)
+
+// rollbackSystem switches to an earlier system generation, see
+// dogeboxd.ValidateSystemRollback.
+func (t SystemUpdater) rollbackSystem(a dogeboxd.RollbackSystem, log dogeboxd.SubLogger) error {
+	if t.systemUpdateRunning() {
+		return errSystemUpdateRunning
+	}
+
+	generations, err := t.nix.ListSystemGenerations()
+	if err != nil {
+		return err
+	}
+	generation, err := dogeboxd.ValidateSystemRollback(generations, a.Generation)
+	if err != nil {
+		return err
+	}
+
+	log.Logf("Rolling back to generation %d (%s) from %s", generation.Number, generation.NixOSVersion, generation.Date.Format("2006-01-02 15:04"))
+	if err := t.runner.Run(log, rootd.SwitchSystemGeneration{Generation: generation.Number}); err != nil {
+		log.Errf("Failed to switch generation: %v", err)
+		return err
+	}
+
+	log.Logf("Rolled back, the nix config is unchanged so the next rebuild builds it again")
+	return nil
+}
@@ -0,0 +1,44 @@
+package system
+
+import (
+	"testing"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type rollbackNixManager struct {
+	testNixManager
+	generations []dogeboxd.SystemGeneration
+}
+
+func (m *rollbackNixManager) ListSystemGenerations() ([]dogeboxd.SystemGeneration, error) {
+	return m.generations, nil
+}
+
+func TestRollbackSystem(t *testing.T) {
+	nix := &rollbackNixManager{generations: []dogeboxd.SystemGeneration{{Number: 41}, {Number: 42, Current: true}}}
+	runner := NewRecordingCommandRunner()
+	runner.Results["systemctl is-active"] = CommandResult{Output: []byte("inactive\n")}
+	updater := SystemUpdater{nix: nix, runner: runner}
+	log := testRunnerJob(dogeboxd.PupState{}).Logger.Step("rollback system")
+
+	require.NoError(t, updater.rollbackSystem(dogeboxd.RollbackSystem{Generation: 41}, log))
+	assert.Equal(t, []string{
+		"systemctl is-active dogebox-system-update.service",
+		"_dbxroot nix switch-generation --generation 41 --systemd-run",
+	}, runner.Commands)
+
+	assert.Error(t, updater.rollbackSystem(dogeboxd.RollbackSystem{Generation: 42}, log))
+	assert.Len(t, runner.Commands, 3, "the current generation isn't switched to")
+}
+
+func TestRollbackSystemRefusesDuringSystemUpdate(t *testing.T) {
+	runner := NewRecordingCommandRunner()
+	runner.Results["systemctl is-active"] = CommandResult{Output: []byte("activating\n")}
+	updater := SystemUpdater{nix: &rollbackNixManager{}, runner: runner}
+
+	err := updater.rollbackSystem(dogeboxd.RollbackSystem{Generation: 41}, testRunnerJob(dogeboxd.PupState{}).Logger.Step("rollback system"))
+	assert.ErrorIs(t, err, errSystemUpdateRunning)
+}
@@ -0,0 +1,51 @@
+package system
+
+import (
+	"fmt"
+	"slices"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+// removeTrustedCA forgets a TrustedCA, taking it out of the trust store
+// of every pup that trusted it in one rebuild.
+func (t SystemUpdater) removeTrustedCA(a dogeboxd.RemoveTrustedCA, log dogeboxd.SubLogger) error {
+	dbxState := t.sm.Get().Dogebox
+
+	i := slices.IndexFunc(dbxState.TrustedCAs, func(ca dogeboxd.TrustedCA) bool { return ca.ID == a.ID })
+	if i < 0 {
+		return fmt.Errorf("trusted CA with ID %s not found", a.ID)
+	}
+	dbxState.TrustedCAs = slices.Delete(dbxState.TrustedCAs, i, i+1)
+
+	updated := []dogeboxd.PupState{}
+	for id, p := range t.pupManager.GetStateMap() {
+		if !slices.Contains(p.TrustedCAs, a.ID) {
+			continue
+		}
+		trusted := slices.DeleteFunc(slices.Clone(p.TrustedCAs), func(caID string) bool { return caID == a.ID })
+		newState, err := t.pupManager.UpdatePup(id, dogeboxd.PupTrustedCAs(trusted), dogeboxd.WithPupdateReason(dogeboxd.PupdateReasonFromLog(log)))
+		if err != nil {
+			return err
+		}
+		updated = append(updated, newState)
+	}
+
+	if err := t.sm.SetDogebox(dbxState); err != nil {
+		return err
+	}
+	if len(updated) == 0 {
+		return nil
+	}
+
+	log.Logf("Removing CA from %d pups", len(updated))
+	nixPatch := t.nix.NewPatch(log)
+	for _, p := range updated {
+		t.nix.WritePupFile(nixPatch, p, dbxState)
+	}
+	if err := nixPatch.Apply(); err != nil {
+		log.Errf("Failed to apply nix patch: %v", err)
+		return err
+	}
+	return nil
+}
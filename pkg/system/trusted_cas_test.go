@@ -0,0 +1,62 @@
+package system
+
+import (
+	"testing"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type trustedCANixPatch struct {
+	dogeboxd.NixPatch
+	applied int
+}
+
+func (p *trustedCANixPatch) Apply() error {
+	p.applied++
+	return nil
+}
+
+type trustedCANixManager struct {
+	testNixManager
+	patch   *trustedCANixPatch
+	written []string
+}
+
+func (m *trustedCANixManager) NewPatch(log dogeboxd.SubLogger) dogeboxd.NixPatch { return m.patch }
+
+func (m *trustedCANixManager) WritePupFile(patch dogeboxd.NixPatch, state dogeboxd.PupState, dbxState dogeboxd.DogeboxState) {
+	m.written = append(m.written, state.ID)
+}
+
+type trustedCAPupManager struct {
+	pendingPupManager
+}
+
+func (m *trustedCAPupManager) GetStateMap() map[string]dogeboxd.PupState { return m.states }
+
+func TestRemoveTrustedCARewritesPupsTrustingIt(t *testing.T) {
+	sm := newSafeModeTestStateManager(t)
+	dbxState := sm.Get().Dogebox
+	dbxState.TrustedCAs = []dogeboxd.TrustedCA{{ID: "ca1"}, {ID: "ca2"}}
+	require.NoError(t, sm.SetDogebox(dbxState))
+
+	pups := &trustedCAPupManager{pendingPupManager{states: map[string]dogeboxd.PupState{
+		"aaa": {ID: "aaa", TrustedCAs: []string{"ca1", "ca2"}},
+		"bbb": {ID: "bbb", TrustedCAs: []string{"ca2"}},
+	}}}
+	nix := &trustedCANixManager{patch: &trustedCANixPatch{}}
+	updater := SystemUpdater{sm: sm, nix: nix, pupManager: pups}
+
+	job := testRunnerJob(pups.states["aaa"])
+	require.NoError(t, updater.removeTrustedCA(dogeboxd.RemoveTrustedCA{ID: "ca1"}, job.Logger.Step("test")))
+
+	assert.Equal(t, []dogeboxd.TrustedCA{{ID: "ca2"}}, sm.Get().Dogebox.TrustedCAs)
+	assert.Equal(t, []string{"ca2"}, pups.states["aaa"].TrustedCAs)
+	assert.Equal(t, []string{"ca2"}, pups.states["bbb"].TrustedCAs)
+	assert.Equal(t, []string{"aaa"}, nix.written)
+	assert.Equal(t, 1, nix.patch.applied)
+
+	assert.Error(t, updater.removeTrustedCA(dogeboxd.RemoveTrustedCA{ID: "ca1"}, job.Logger.Step("test")))
+}
@@ -5,15 +5,16 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	_ "embed"
+	"errors"
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
 
 	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/Dogebox-WG/dogeboxd/pkg/rootd"
 	"github.com/Dogebox-WG/dogeboxd/pkg/utils"
 )
 
@@ -37,6 +38,7 @@ func NewSystemUpdater(config dogeboxd.ServerConfig, networkManager dogeboxd.Netw
 		sm:         stateManager,
 		lifecycle:  lifecycle,
 		dkm:        dkm,
+		runner:     NewCommandRunner(config),
 	}
 }
 
@@ -51,10 +53,28 @@ type SystemUpdater struct {
 	sm         dogeboxd.StateManager
 	lifecycle  dogeboxd.LifecycleManager
 	dkm        dogeboxd.DKMManager
+	runner     CommandRunner
+	snapshots  *dogeboxd.StateSnapshotter
+	secrets    *dogeboxd.ConfigSecretStore
+}
+
+// SetStateSnapshotter turns on automatic state snapshots before risky
+// jobs, see dogeboxd.TakesStateSnapshot.
+func (t *SystemUpdater) SetStateSnapshotter(s *dogeboxd.StateSnapshotter) {
+	t.snapshots = s
+}
+
+// SetConfigSecrets lets pups' secret config fields be written to their
+// config.env, see dogeboxd.ConfigSecretStore.
+func (t *SystemUpdater) SetConfigSecrets(s *dogeboxd.ConfigSecretStore) {
+	t.secrets = s
 }
 
 var nixCacheUpdateTimeout = 60 * time.Second
 
+// Swappable for tests.
+var getJobTimeout = dogeboxd.GetJobTimeout
+
 func (t SystemUpdater) Run(started, stopped chan bool, stop chan context.Context) error {
 	go func() {
 		go func() {
@@ -68,152 +88,7 @@ func (t SystemUpdater) Run(started, stopped chan bool, stop chan context.Context
 					if !ok {
 						break dance
 					}
-					switch a := j.A.(type) {
-					case dogeboxd.InstallPup:
-						err := t.installPup(a, j)
-						if err != nil {
-							j.Err = "Failed to install pup"
-						}
-						t.done <- j
-					case dogeboxd.UninstallPup:
-						err := t.uninstallPup(j)
-						if err != nil {
-							j.Err = "Failed to uninstall pup"
-						}
-						t.done <- j
-					case dogeboxd.PurgePup:
-						err := t.purgePup(j)
-						if err != nil {
-							j.Err = "Failed to purge pup"
-						}
-						t.done <- j
-					case dogeboxd.EnablePup:
-						err := t.enablePup(j)
-						if err != nil {
-							j.Err = "Failed to enable pup"
-						}
-						t.done <- j
-					case dogeboxd.DisablePup:
-						err := t.disablePup(j)
-						if err != nil {
-							j.Err = "Failed to disable pup"
-						}
-						t.done <- j
-					case dogeboxd.UpgradePup:
-						err := t.upgradePup(a, j)
-						if err != nil {
-							j.Err = "Failed to upgrade pup"
-						}
-						t.done <- j
-					case dogeboxd.RollbackPupUpgrade:
-						err := t.rollbackPupUpgrade(j)
-						if err != nil {
-							j.Err = "Failed to rollback pup"
-						}
-						t.done <- j
-					case dogeboxd.ImportBlockchainData:
-						err := t.importBlockchainData(j)
-						if err != nil {
-							j.Err = "Failed to import blockchain data"
-						}
-						t.done <- j
-					case dogeboxd.UpdatePendingSystemNetwork:
-						err := t.network.SetPendingNetwork(a.Network, j)
-						if err != nil {
-							j.Err = "Failed to set system network"
-						}
-						t.done <- j
-
-					case dogeboxd.InitialBootstrap:
-						err := t.initialBootstrap(a, j)
-						if err != nil {
-							j.Err = err.Error()
-						}
-						t.done <- j
-
-					case dogeboxd.EnableSSH:
-						err := t.EnableSSH(j.Logger.Step("enable SSH"))
-						if err != nil {
-							j.Err = "Failed to enable SSH"
-						}
-						t.done <- j
-					case dogeboxd.DisableSSH:
-						err := t.DisableSSH(j.Logger.Step("disable SSH"))
-						if err != nil {
-							j.Err = "Failed to disable SSH"
-						}
-						t.done <- j
-
-					case dogeboxd.AddSSHKey:
-						err := t.AddSSHKey(a.Key, j.Logger.Step("add SSH key"))
-						if err != nil {
-							j.Err = "Failed to add SSH key"
-						}
-						t.done <- j
-
-					case dogeboxd.RemoveSSHKey:
-						err := t.RemoveSSHKey(a.ID, j.Logger.Step("remove SSH key"))
-						if err != nil {
-							j.Err = "Failed to remove SSH key"
-						}
-						t.done <- j
-
-					case dogeboxd.SaveCustomNix:
-						err := t.SaveCustomNix(a.Content, j.Logger.Step("save custom nix"))
-						if err != nil {
-							j.Err = "Failed to save custom configuration"
-						}
-						t.done <- j
-
-					case dogeboxd.AddBinaryCache:
-						err := t.AddBinaryCache(a, j.Logger.Step("Add binary cache"))
-						if err != nil {
-							j.Err = "Failed to add binary cache"
-						}
-						t.done <- j
-
-					case dogeboxd.RemoveBinaryCache:
-						err := t.removeBinaryCache(a)
-						if err != nil {
-							j.Err = "Failed to remove binary cache"
-						}
-						t.done <- j
-
-					case dogeboxd.SystemUpdate:
-						logger := j.Logger.Step("system update")
-						logger.Progress(5).Logf("Starting system update to %s", a.Version)
-						if err := t.DoSystemUpdate(a.Package, a.Version, logger); err != nil {
-							logger.Errf("System update failed: %v", err)
-							j.Err = err.Error()
-						} else {
-							logger.Progress(100).Logf("System update to %s completed", a.Version)
-						}
-						t.done <- j
-
-					case dogeboxd.UpdateTimezone:
-						err := t.updateTimezone(a, j.Logger.Step("update timezone"))
-						if err != nil {
-							j.Err = "Failed to update timezone"
-						}
-						t.done <- j
-
-					case dogeboxd.UpdateKeymap:
-						err := t.updateKeymap(a, j.Logger.Step("update keymap"))
-						if err != nil {
-							j.Err = "Failed to update keyboard layout"
-						}
-						t.done <- j
-
-					case dogeboxd.UpdateNixCache:
-						err := t.updateNixCache(j)
-						if err != nil {
-							j.Err = err.Error()
-						}
-						t.done <- j
-
-					default:
-						fmt.Printf("Unknown action type: %v\n", a)
-					}
+					t.runJob(j)
 				}
 			}
 		}()
@@ -225,6 +100,411 @@ func (t SystemUpdater) Run(started, stopped chan bool, stop chan context.Context
 	return nil
 }
 
+/* runJob runs a job, giving up on it after its timeout (see
+ * dogeboxd.GetJobTimeout) so one hung command can't block the queue.
+ * A timed out job's commands are killed and it's failed, whatever it
+ * was doing, once its goroutine has returned, so the next job never
+ * runs alongside it.
+ */
+func (t SystemUpdater) runJob(j dogeboxd.Job) {
+	timeout := getJobTimeout(j.A)
+	finished := make(chan dogeboxd.Job, 1)
+	go func() {
+		finished <- t.process(j)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case result := <-finished:
+		t.done <- result
+	case <-timer.C:
+		log := j.Logger.Step("timeout")
+		killed := j.Logger.KillCommands()
+		log.Errf("Job timed out after %s, killed %d running command(s)", timeout, killed)
+		result := <-finished
+		result.Err = fmt.Sprintf("Timed out after %s", timeout)
+		result.TimedOut = true
+		t.done <- result
+	}
+}
+
+// process runs a job to completion, returning it with Err set if it failed.
+func (t SystemUpdater) process(j dogeboxd.Job) dogeboxd.Job {
+	if dogeboxd.TakesStateSnapshot(j.A) {
+		t.snapshotState(j)
+	}
+
+	switch a := j.A.(type) {
+	case dogeboxd.InstallPup:
+		err := t.installPup(a, j)
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to install pup", err)
+			j.Transient = dogeboxd.IsTransientError(err)
+		}
+		return j
+	case dogeboxd.UninstallPup:
+		err := t.uninstallPup(j)
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to uninstall pup", err)
+		}
+		return j
+	case dogeboxd.PurgePup:
+		err := t.purgePup(j)
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to purge pup", err)
+		}
+		return j
+	case dogeboxd.EnablePup:
+		err := t.enablePup(j)
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to enable pup", err)
+		}
+		return j
+	case dogeboxd.DisablePup:
+		err := t.disablePup(j)
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to disable pup", err)
+		}
+		return j
+	case dogeboxd.SetPupAutoStart:
+		err := t.rewritePupContainer(j, "autostart")
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to update pup auto-start", err)
+		}
+		return j
+	case dogeboxd.SetPupMaintenance:
+		err := t.setPupMaintenance(j, a)
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to update pup maintenance mode", err)
+		}
+		return j
+	case dogeboxd.SetPupRestartSchedule:
+		err := t.rewritePupContainer(j, "restart-schedule")
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to update pup restart schedule", err)
+		}
+		return j
+	case dogeboxd.SetPupResourceLimits:
+		err := t.rewritePupContainer(j, "resource-limits")
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to update pup resource limits", err)
+		}
+		return j
+	case dogeboxd.SetPupTrustedCAs:
+		err := t.rewritePupContainer(j, "trusted-cas")
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to update pup trusted CAs", err)
+		}
+		return j
+	case dogeboxd.SetPupDevices:
+		err := t.rewritePupContainer(j, "devices")
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to update pup devices", err)
+		}
+		return j
+	case dogeboxd.SetPupStorageQuota:
+		err := t.setPupStorageQuota(j, a)
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to update pup storage quota", err)
+		}
+		return j
+	case dogeboxd.SetPupLogLevel:
+		err := t.setPupLogLevel(j, a)
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to update pup log level", err)
+		}
+		return j
+	case dogeboxd.SetPupEnvOverrides:
+		err := t.setPupEnvOverrides(j, a)
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to update pup environment overrides", err)
+		}
+		return j
+	case dogeboxd.UpgradePup:
+		err := t.upgradePup(a, j)
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to upgrade pup", err)
+		}
+		return j
+	case dogeboxd.BulkPupAction:
+		err := t.bulkPupAction(a, j)
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError(fmt.Sprintf("Failed to %s pups", a.Operation), err)
+		}
+		return j
+	case dogeboxd.RollbackPupUpgrade:
+		err := t.rollbackPupUpgrade(j)
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to rollback pup", err)
+		}
+		return j
+	case dogeboxd.StartPup:
+		err := t.startPup(j, a)
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to start pup", err)
+		}
+		return j
+	case dogeboxd.RestartPup:
+		err := t.restartPup(j, a)
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to restart pup", err)
+		}
+		return j
+	case dogeboxd.RebuildDevPup:
+		err := t.rebuildDevPup(j)
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to rebuild pup", err)
+		}
+		return j
+	case dogeboxd.ExportPup:
+		info, err := t.exportPup(j, a)
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to export pup", err)
+		}
+		j.Success = info
+		return j
+	case dogeboxd.ImportPup:
+		err := t.importPup(j, a)
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to import pup", err)
+		}
+		return j
+	case dogeboxd.InstallPupBundle:
+		err := t.installPupBundle(j, a)
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to install pup bundle", err)
+		}
+		return j
+	case dogeboxd.ImportBlockchainData:
+		err := t.importBlockchainData(j)
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to import blockchain data", err)
+		}
+		return j
+	case dogeboxd.UpdatePendingSystemNetwork:
+		err := t.network.SetPendingNetwork(a.Network, j)
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to set system network", err)
+		}
+		return j
+
+	case dogeboxd.InitialBootstrap:
+		err := t.initialBootstrap(a, j)
+		if err != nil {
+			j.Err = err.Error()
+		}
+		return j
+
+	case dogeboxd.EnableSSH:
+		err := t.EnableSSH(j.Logger.Step("enable SSH"))
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to enable SSH", err)
+		}
+		return j
+	case dogeboxd.EnableSSHTemporary:
+		err := t.EnableSSHTemporary(a.Hours, j.Logger.Step("enable SSH"))
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to enable SSH", err)
+		}
+		return j
+	case dogeboxd.DisableSSH:
+		err := t.DisableSSH(j.Logger.Step("disable SSH"))
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to disable SSH", err)
+		}
+		return j
+
+	case dogeboxd.AddSSHKey:
+		err := t.AddSSHKey(a.Key, j.Logger.Step("add SSH key"))
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to add SSH key", err)
+		}
+		return j
+
+	case dogeboxd.RemoveSSHKey:
+		err := t.RemoveSSHKey(a.ID, j.Logger.Step("remove SSH key"))
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to remove SSH key", err)
+		}
+		return j
+
+	case dogeboxd.SaveCustomNix:
+		err := t.SaveCustomNix(a.Content, j.Logger.Step("save custom nix"))
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to save custom configuration", err)
+		}
+		return j
+
+	case dogeboxd.ValidateCustomNix:
+		j.Success = t.validateCustomNix(a.Content, j.Logger.Step("validate custom nix"))
+		return j
+
+	case dogeboxd.RestoreNixConfigBackup:
+		err := t.restoreNixConfigBackup(a, j.Logger.Step("restore nix backup"))
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to restore configuration backup", err)
+		}
+		return j
+
+	case dogeboxd.BackupNixConfig:
+		err := t.backupNixConfig(j.Logger.Step("backup nix config"))
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to back up configuration", err)
+		}
+		return j
+
+	case dogeboxd.AddBinaryCache:
+		err := t.AddBinaryCache(a, j.Logger.Step("Add binary cache"))
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to add binary cache", err)
+		}
+		return j
+
+	case dogeboxd.RemoveBinaryCache:
+		err := t.removeBinaryCache(a)
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to remove binary cache", err)
+		}
+		return j
+
+	case dogeboxd.RemoveTrustedCA:
+		err := t.removeTrustedCA(a, j.Logger.Step("remove trusted ca"))
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to remove trusted CA", err)
+		}
+		return j
+
+	case dogeboxd.SystemUpdate:
+		logger := j.Logger.Step("system update")
+		logger.Progress(5).Logf("Starting system update to %s", a.Version)
+		if err := t.DoSystemUpdate(a, j, logger); err != nil {
+			logger.Errf("System update failed: %v", err)
+			j.Err = err.Error()
+			j.Transient = dogeboxd.IsTransientError(err)
+		} else {
+			logger.Progress(100).Logf("System update to %s completed", a.Version)
+		}
+		return j
+
+	case dogeboxd.SystemUpdateFromFile:
+		logger := j.Logger.Step("system update")
+		if err := t.DoSystemUpdateFromFile(a, logger.Progress(5)); err != nil {
+			logger.Errf("Offline system update failed: %v", err)
+			j.Err = err.Error()
+		} else {
+			logger.Progress(100).Log("Offline system update completed")
+		}
+		return j
+
+	case dogeboxd.ApplyPendingChanges:
+		err := t.applyPendingChanges(j.Logger.Step("apply pending changes"))
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to apply pending changes", err)
+		}
+		return j
+
+	case dogeboxd.DiscardPendingChanges:
+		err := t.discardPendingChanges(j.Logger.Step("discard pending changes"))
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to discard pending changes", err)
+		}
+		return j
+
+	case dogeboxd.DiffPendingChanges:
+		err := t.diffPendingChanges(j.Logger.Step("diff pending changes"))
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to diff pending changes", err)
+		}
+		return j
+
+	case dogeboxd.ReapplySystemVersion:
+		err := t.reapplySystemVersion(j.Logger.Step("reapply system version"))
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to re-apply system version", err)
+		}
+		return j
+
+	case dogeboxd.SetSafeMode:
+		err := t.setSafeMode(a, j.Logger.Step("safe mode"))
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to update safe mode", err)
+		}
+		return j
+
+	case dogeboxd.SetAPMode:
+		err := t.setAPMode(a, j.Logger.Step("ap mode"))
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to update AP mode", err)
+		}
+		return j
+
+	case dogeboxd.UpdateWifiRegulatoryDomain:
+		err := t.updateWifiRegulatoryDomain(a, j.Logger.Step("update wifi region"))
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to update WiFi region", err)
+		}
+		return j
+
+	case dogeboxd.UpdateTimezone:
+		err := t.updateTimezone(a, j.Logger.Step("update timezone"))
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to update timezone", err)
+		}
+		return j
+
+	case dogeboxd.UpdateKeymap:
+		err := t.updateKeymap(a, j.Logger.Step("update keymap"))
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to update keyboard layout", err)
+		}
+		return j
+
+	case dogeboxd.UpdateNixCache:
+		err := t.updateNixCache(j)
+		if err != nil {
+			j.Err = err.Error()
+		}
+		return j
+
+	case dogeboxd.CollectNixGarbage:
+		err := t.collectNixGarbage(j, a)
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to collect nix garbage", err)
+		}
+		return j
+
+	case dogeboxd.RollbackSystem:
+		err := t.rollbackSystem(a, j.Logger.Step("rollback system"))
+		if err != nil {
+			j.Err = dogeboxd.DescribeJobError("Failed to roll back system", err)
+		}
+		return j
+
+	default:
+		fmt.Printf("Unknown action type: %v\n", a)
+		j.Err = fmt.Sprintf("Unknown action %s", j.A.ActionName())
+		return j
+	}
+}
+
+// snapshotState takes a StateSnapshot before a risky job. Failing to is
+// logged, but doesn't stop the job.
+func (t SystemUpdater) snapshotState(j dogeboxd.Job) {
+	if t.snapshots == nil {
+		return
+	}
+
+	log := j.Logger.Step("snapshot")
+	snapshot, err := t.snapshots.Create(j.A.ActionName(), j.ID, log)
+	if err != nil {
+		log.Errf("Warning: failed to snapshot state before %s: %v", j.A.ActionName(), err)
+		return
+	}
+	log.Logf("Snapshotted state as %s", snapshot.ID)
+}
+
 func (t SystemUpdater) AddJob(j dogeboxd.Job) {
 	t.jobs <- j
 }
@@ -244,7 +524,7 @@ func (t SystemUpdater) GetSnapshot(pupID string) (*dogeboxd.PupVersionSnapshot,
 }
 
 func (t SystemUpdater) markPupBroken(s dogeboxd.PupState, reason string, upstreamError error) error {
-	_, err := t.pupManager.UpdatePup(s.ID, dogeboxd.SetPupBrokenReason(reason), dogeboxd.SetPupInstallation(dogeboxd.STATE_BROKEN))
+	_, err := t.pupManager.UpdatePup(s.ID, dogeboxd.SetPupBrokenReason(reason), dogeboxd.SetPupInstallation(dogeboxd.STATE_BROKEN), dogeboxd.WithPupdateReason(dogeboxd.PupdateReason{ErrorCode: reason}))
 	if err != nil {
 		log.Printf("Failed to even mark pup as broken after issue: %v", err)
 		return err
@@ -275,7 +555,7 @@ func (t SystemUpdater) cleanupSidebarPreferences(pupID string) {
 }
 
 // verifyNixFileHash verifies that the nix file matches the expected hash from the manifest
-func (t SystemUpdater) verifyNixFileHash(pupPath string, manifest dogeboxd.PupManifest, isDevMode bool, logger dogeboxd.SubLogger) error {
+func (t SystemUpdater) verifyNixFileHash(pupPath string, manifest dogeboxd.PupManifest, allowMismatch bool, logger dogeboxd.SubLogger) error {
 	nixFile, err := os.ReadFile(filepath.Join(pupPath, manifest.Container.Build.NixFile))
 	if err != nil {
 		return fmt.Errorf("failed to read nix file: %w", err)
@@ -286,20 +566,57 @@ func (t SystemUpdater) verifyNixFileHash(pupPath string, manifest dogeboxd.PupMa
 
 	if actualHash != manifest.Container.Build.NixFileSha256 {
 		logger.Errf("Nix file hash mismatch! Manifest Hash: %s, Computed Hash: %s", manifest.Container.Build.NixFileSha256, actualHash)
-		if !isDevMode {
+		if !allowMismatch {
 			return fmt.Errorf("nix file hash mismatch")
 		}
-		logger.Log("Warning: Nix hash mismatch ignored in dev mode")
+		logger.Log("Warning: Nix hash mismatch ignored")
 	}
 
 	return nil
 }
 
+// verifyPupSignature checks the pup in pupPath was signed by a trusted
+// key, refusing it on production boxes and warning otherwise.
+func (t SystemUpdater) verifyPupSignature(pupPath string, profile dogeboxd.BoxProfile, logger dogeboxd.SubLogger) error {
+	keyName, err := dogeboxd.VerifyPupSignature(pupPath, dogeboxd.TrustedPupKeys(t.sm.Get().Dogebox))
+	switch {
+	case err == nil:
+		logger.Logf("Pup signed by %s", keyName)
+	case profile.RequiresPupSignatures():
+		logger.Errf("Refusing pup on a production box: %v", err)
+		return err
+	case errors.Is(err, dogeboxd.ErrPupUnsigned):
+		if profile.IsDevelopment() {
+			logger.Log("Warning: pup isn't signed")
+		}
+	default:
+		logger.Logf("Warning: %v", err)
+	}
+	return nil
+}
+
 /* InstallPup takes a PupManifest and ensures a nix config
  * is written and any packages installed so that the Pup can
  * be started.
  */
 func (t SystemUpdater) installPup(pupSelection dogeboxd.InstallPup, j dogeboxd.Job) error {
+	return t.installPupFrom(pupSelection, j, "")
+}
+
+// installPupFrom installs a pup, restoring its storage from the export
+// with exportID first if there is one, see importPup.
+func (t SystemUpdater) installPupFrom(pupSelection dogeboxd.InstallPup, j dogeboxd.Job, exportID string) error {
+	return t.installPupWith(j, pupSelection.SessionToken, exportID, func(pupPath string, log dogeboxd.SubLogger) (dogeboxd.PupManifest, error) {
+		log.Logf("Installing pup from %s: %s @ %s", pupSelection.SourceId, pupSelection.PupName, pupSelection.PupVersion)
+		log.Logf("Downloading pup to %s", pupPath)
+		return t.sources.DownloadPupAt(pupPath, pupSelection.SourceId, j.State.Source.Commit, pupSelection.PupName, pupSelection.PupVersion, log)
+	})
+}
+
+// A pupFetcher puts a pup's files in pupPath, returning its manifest.
+type pupFetcher func(pupPath string, log dogeboxd.SubLogger) (dogeboxd.PupManifest, error)
+
+func (t SystemUpdater) installPupWith(j dogeboxd.Job, sessionToken string, exportID string, fetch pupFetcher) error {
 	s := *j.State
 	log := j.Logger.Step("install")
 
@@ -307,54 +624,63 @@ func (t SystemUpdater) installPup(pupSelection dogeboxd.InstallPup, j dogeboxd.J
 		s.Manifest.Meta.Name, s.Version, s.Manifest.Meta.Version)
 	nixPatch := t.nix.NewPatch(log)
 
-	if _, err := t.pupManager.UpdatePup(s.ID, dogeboxd.SetPupInstallation(dogeboxd.STATE_INSTALLING)); err != nil {
+	if _, err := t.pupManager.UpdatePup(s.ID, dogeboxd.SetPupInstallation(dogeboxd.STATE_INSTALLING), dogeboxd.WithPupdateReason(dogeboxd.PupdateReasonFor(j))); err != nil {
 		log.Errf("Failed to update pup installation state: %v", err)
 		return t.markPupBroken(s, dogeboxd.BROKEN_REASON_STATE_UPDATE_FAILED, err)
 	}
 
-	log.Logf("Installing pup from %s: %s @ %s", pupSelection.SourceId, pupSelection.PupName, pupSelection.PupVersion)
 	pupPath := filepath.Join(t.config.DataDir, "pups", s.ID)
 
-	log.Logf("Downloading pup to %s", pupPath)
-	downloadedManifest, err := t.sources.DownloadPup(pupPath, pupSelection.SourceId, pupSelection.PupName, pupSelection.PupVersion)
+	downloadedManifest, err := fetch(pupPath, log)
 	if err != nil {
 		log.Errf("Failed to download pup: %v", err)
 		return t.markPupBroken(s, dogeboxd.BROKEN_REASON_DOWNLOAD_FAILED, err)
 	}
 
+	profile := t.sm.Get().Dogebox.Profile
+	if err := t.verifyPupSignature(pupPath, profile, log); err != nil {
+		return t.markPupBroken(s, dogeboxd.BROKEN_REASON_SIGNATURE_INVALID, err)
+	}
+
 	// Verify nix file hash using the downloaded manifest
-	if err := t.verifyNixFileHash(pupPath, downloadedManifest, s.IsDevModeEnabled, log); err != nil {
+	if err := t.verifyNixFileHash(pupPath, downloadedManifest, profile.AllowsHashMismatch(s.IsDevModeEnabled), log); err != nil {
 		return t.markPupBroken(s, dogeboxd.BROKEN_REASON_NIX_HASH_MISMATCH, err)
 	}
 
+	// Use prebuilt closures where we can, falling back to building from source.
+	closures := resolvePrebuiltClosures(downloadedManifest, s.IsDevModeEnabled, log)
+
 	// create the storage dir
-	cmd := exec.Command("sudo", "_dbxroot", "pup", "create-storage", "--data-dir", t.config.DataDir, "--pupId", s.ID)
-	log.LogCmd(cmd)
-	err = cmd.Run()
+	err = t.runner.Run(log, rootd.PupCreateStorage{PupID: s.ID, DataDir: t.config.DataDir, QuotaMB: s.StorageQuotaMB})
 	if err != nil {
 		// TODO : Do we need command output here?
 		log.Errf("Failed to create pup storage: %v", err)
 		return t.markPupBroken(s, dogeboxd.BROKEN_REASON_STORAGE_CREATION_FAILED, err)
 	}
 
+	// Restore storage before the keys and config are written, so they
+	// replace whatever the export had.
+	if exportID != "" {
+		if err := t.importPupStorage(s, exportID, log); err != nil {
+			log.Errf("Failed to restore pup storage: %v", err)
+			return t.markPupBroken(s, dogeboxd.BROKEN_REASON_STORAGE_IMPORT_FAILED, err)
+		}
+	}
+
 	// write delegate key to storage dir
-	keyData, err := t.dkm.MakeDelegate(s.ID, pupSelection.SessionToken)
+	keyData, err := t.dkm.MakeDelegate(s.ID, sessionToken)
 	if err != nil {
 		return t.markPupBroken(s, dogeboxd.BROKEN_REASON_DELEGATE_KEY_CREATION_FAILED, err)
 	}
 
-	cmd = exec.Command("sudo", "_dbxroot", "pup", "write-key", "--data-dir", t.config.DataDir, "--pupId", s.ID, "--key-file", "delegated.key", "--data", keyData.Priv)
-	log.LogCmd(cmd)
-	err = cmd.Run()
+	err = t.runner.Run(log, rootd.PupWriteKey{PupID: s.ID, DataDir: t.config.DataDir, KeyFile: "delegated.key", Data: keyData.Priv})
 	if err != nil {
 		// TODO : Do we need command output here?
 		log.Errf("Failed to create delegate key in storage: %v", err)
 		return t.markPupBroken(s, dogeboxd.BROKEN_REASON_DELEGATE_KEY_WRITE_FAILED, err)
 	}
 
-	cmd = exec.Command("sudo", "_dbxroot", "pup", "write-key", "--data-dir", t.config.DataDir, "--pupId", s.ID, "--key-file", "delegated.extended.key", "--data", keyData.Wif)
-	log.LogCmd(cmd)
-	err = cmd.Run()
+	err = t.runner.Run(log, rootd.PupWriteKey{PupID: s.ID, DataDir: t.config.DataDir, KeyFile: "delegated.extended.key", Data: keyData.Wif})
 	if err != nil {
 		// TODO : Do we need command output here?
 		log.Errf("Failed to create extended delegate key in storage: %v", err)
@@ -363,13 +689,18 @@ func (t SystemUpdater) installPup(pupSelection dogeboxd.InstallPup, j dogeboxd.J
 
 	// Write initial config to secure storage (includes defaults from manifest)
 	// This ensures config.env exists before the container starts
-	if err := dogeboxd.WritePupConfigToStorage(t.config.DataDir, s.ID, s.Config, log); err != nil {
+	initialConfig, keep, err := t.secrets.PupConfig(s)
+	if err != nil {
+		log.Errf("Failed to read secret config: %v", err)
+		return t.markPupBroken(s, dogeboxd.BROKEN_REASON_STORAGE_CREATION_FAILED, err)
+	}
+	if err := dogeboxd.WritePupConfigToStorage(t.config.DataDir, s.ID, initialConfig, keep, s.EnvOverrides, log); err != nil {
 		log.Errf("Failed to write initial config to storage: %v", err)
 		return t.markPupBroken(s, dogeboxd.BROKEN_REASON_STORAGE_CREATION_FAILED, err)
 	}
 
 	// Now that we're mostly installed, enable it.
-	newState, err := t.pupManager.UpdatePup(s.ID, dogeboxd.PupEnabled(true))
+	newState, err := t.pupManager.UpdatePup(s.ID, dogeboxd.PupEnabled(true), dogeboxd.SetPupPrebuiltClosures(closures), dogeboxd.WithPupdateReason(dogeboxd.PupdateReasonFor(j)))
 	if err != nil {
 		log.Errf("Failed to update pup enabled state: %v", err)
 		return t.markPupBroken(s, dogeboxd.BROKEN_REASON_ENABLE_FAILED, err)
@@ -378,7 +709,7 @@ func (t SystemUpdater) installPup(pupSelection dogeboxd.InstallPup, j dogeboxd.J
 	dbxState := t.sm.Get().Dogebox
 
 	t.nix.WritePupFile(nixPatch, newState, dbxState)
-	t.nix.UpdateIncludesFile(nixPatch, t.pupManager)
+	t.nix.UpdateIncludesFile(nixPatch, t.pupManager, dbxState)
 
 	// Do a nix rebuild before we mark the pup as installed, this way
 	// the frontend will get a much longer "Installing.." state, as opposed
@@ -388,7 +719,7 @@ func (t SystemUpdater) installPup(pupSelection dogeboxd.InstallPup, j dogeboxd.J
 		return t.markPupBroken(s, dogeboxd.BROKEN_REASON_NIX_APPLY_FAILED, err)
 	}
 
-	if _, err := t.pupManager.UpdatePup(s.ID, dogeboxd.SetPupInstallation(dogeboxd.STATE_READY)); err != nil {
+	if _, err := t.pupManager.UpdatePup(s.ID, dogeboxd.SetPupInstallation(dogeboxd.STATE_READY), dogeboxd.WithPupdateReason(dogeboxd.PupdateReasonFor(j))); err != nil {
 		log.Errf("Failed to update pup installation state: %v", err)
 		return t.markPupBroken(s, dogeboxd.BROKEN_REASON_STATE_UPDATE_FAILED, err)
 	}
@@ -399,7 +730,7 @@ func (t SystemUpdater) installPup(pupSelection dogeboxd.InstallPup, j dogeboxd.J
 }
 
 func (t SystemUpdater) uninstallPup(j dogeboxd.Job) error {
-	// TODO: uninstall deps if they're not needed by another pup.
+	// Providers left unused are uninstalled after, if asked, see UninstallPup.RemoveProviders.
 	s := *j.State
 	log := j.Logger.Step("uninstall")
 	nixPatch := t.nix.NewPatch(log)
@@ -410,20 +741,21 @@ func (t SystemUpdater) uninstallPup(j dogeboxd.Job) error {
 		s.ID,
 		dogeboxd.SetPupInstallation(dogeboxd.STATE_UNINSTALLING),
 		dogeboxd.PupEnabled(false),
+		dogeboxd.WithPupdateReason(dogeboxd.PupdateReasonFor(j)),
 	); err != nil {
 		log.Errf("Failed to update pup uninstalling state: %v", err)
 		return t.markPupBroken(s, dogeboxd.BROKEN_REASON_STATE_UPDATE_FAILED, err)
 	}
 
 	t.nix.RemovePupFile(nixPatch, s.ID)
-	t.nix.UpdateIncludesFile(nixPatch, t.pupManager)
+	t.nix.UpdateIncludesFile(nixPatch, t.pupManager, t.sm.Get().Dogebox)
 
 	if err := nixPatch.Apply(); err != nil {
 		log.Errf("Failed to apply nix patch: %v", err)
 		return t.markPupBroken(s, dogeboxd.BROKEN_REASON_NIX_APPLY_FAILED, err)
 	}
 
-	if _, err := t.pupManager.UpdatePup(s.ID, dogeboxd.SetPupInstallation(dogeboxd.STATE_UNINSTALLED)); err != nil {
+	if _, err := t.pupManager.UpdatePup(s.ID, dogeboxd.SetPupInstallation(dogeboxd.STATE_UNINSTALLED), dogeboxd.WithPupdateReason(dogeboxd.PupdateReasonFor(j))); err != nil {
 		log.Errf("Failed to update pup installation state: %v", err)
 		return t.markPupBroken(s, dogeboxd.BROKEN_REASON_STATE_UPDATE_FAILED, err)
 	}
@@ -447,6 +779,7 @@ func (t SystemUpdater) purgePup(j dogeboxd.Job) error {
 		s.ID,
 		dogeboxd.SetPupInstallation(dogeboxd.STATE_PURGING),
 		dogeboxd.PupEnabled(false),
+		dogeboxd.WithPupdateReason(dogeboxd.PupdateReasonFor(j)),
 	); err != nil {
 		log.Errf("Failed to update pup purging state: %v", err)
 		return t.markPupBroken(s, dogeboxd.BROKEN_REASON_STATE_UPDATE_FAILED, err)
@@ -468,11 +801,12 @@ func (t SystemUpdater) purgePup(j dogeboxd.Job) error {
 		// Keep going if we fail.
 	}
 
-	// Delete pup storage directory
-	cmd := exec.Command("sudo", "_dbxroot", "pup", "delete-storage", "--pupId", s.ID, "--data-dir", t.config.DataDir)
-	log.LogCmd(cmd)
+	if err := os.Remove(t.config.PupBuildLogPath(s.ID)); err != nil && !os.IsNotExist(err) {
+		log.Errf("Failed to remove pup build log %v", err)
+	}
 
-	if err := cmd.Run(); err != nil {
+	// Delete pup storage directory
+	if err := t.runner.Run(log, rootd.PupDeleteStorage{PupID: s.ID, DataDir: t.config.DataDir}); err != nil {
 		log.Errf("Failed to remove pup storage: %v", err)
 		// Keep going if we fail.
 	}
@@ -482,6 +816,13 @@ func (t SystemUpdater) purgePup(j dogeboxd.Job) error {
 		// Keep going if we fail.
 	}
 
+	// Its delegate key is gone with its storage, make sure DKM won't
+	// honour it either. The DelegateReconciler retries if this fails.
+	if err := t.dkm.RevokeDelegate(s.ID); err != nil {
+		log.Errf("Failed to revoke pup delegate key: %v", err)
+		// Keep going if we fail.
+	}
+
 	// Clean up sidebar preferences
 	t.cleanupSidebarPreferences(s.ID)
 
@@ -493,7 +834,7 @@ func (t SystemUpdater) enablePup(j dogeboxd.Job) error {
 	log := j.Logger.Step("enable")
 
 	// Enabled flag should already be set by dispatcher, but verify/set for idempotency
-	newState, err := t.pupManager.UpdatePup(s.ID, dogeboxd.PupEnabled(true))
+	newState, err := t.pupManager.UpdatePup(s.ID, dogeboxd.PupEnabled(true), dogeboxd.WithPupdateReason(dogeboxd.PupdateReasonFor(j)))
 	if err != nil {
 		log.Errf("Failed to update pup enabled state: %v", err)
 		return err
@@ -504,6 +845,36 @@ func (t SystemUpdater) enablePup(j dogeboxd.Job) error {
 	nixPatch := t.nix.NewPatch(log)
 	t.nix.WritePupFile(nixPatch, newState, dbxState)
 
+	if dbxState.DeferRebuilds {
+		return t.deferPupChange(nixPatch, newState, log)
+	}
+
+	if err := nixPatch.Apply(); err != nil {
+		log.Errf("Failed to apply nix patch: %v", err)
+		return err
+	}
+
+	return t.startManualPup(newState, log)
+}
+
+// rewritePupContainer rewrites the pup's container config from its current
+// state, for settings like auto-start, the restart schedule or resource
+// limits that only change how the container is managed. It doesn't start
+// or stop the pup now.
+func (t SystemUpdater) rewritePupContainer(j dogeboxd.Job, step string) error {
+	s := *j.State
+	log := j.Logger.Step(step)
+
+	newState, _, err := t.pupManager.GetPup(s.ID)
+	if err != nil {
+		return err
+	}
+
+	log.Logf("Updating container config for %s (starts on boot: %t, restart schedule: %q)", newState.Manifest.Meta.Name, newState.StartsOnBoot(), newState.RestartSchedule)
+
+	nixPatch := t.nix.NewPatch(log)
+	t.nix.WritePupFile(nixPatch, newState, t.sm.Get().Dogebox)
+
 	if err := nixPatch.Apply(); err != nil {
 		log.Errf("Failed to apply nix patch: %v", err)
 		return err
@@ -512,20 +883,47 @@ func (t SystemUpdater) enablePup(j dogeboxd.Job) error {
 	return nil
 }
 
+// pupStopOp stops s, giving its container as long to shut down cleanly
+// as systemd would before it's terminated.
+func pupStopOp(s dogeboxd.PupState) rootd.PupStop {
+	return rootd.PupStop{PupID: s.ID, TimeoutSeconds: s.ContainerStopTimeoutSeconds()}
+}
+
+// startManualPup starts an enabled pup that isn't set to start on boot,
+// as applying its config won't have started it. Pups in maintenance stay
+// stopped.
+func (t SystemUpdater) startManualPup(state dogeboxd.PupState, log dogeboxd.SubLogger) error {
+	if !state.Enabled || state.InMaintenance() || state.StartsOnBoot() {
+		return nil
+	}
+
+	serviceName := fmt.Sprintf("container@pup-%s.service", state.ID)
+	if err := t.runner.Run(log, rootd.StartPupUnit{Unit: serviceName}); err != nil {
+		log.Errf("Failed to start container: %v", err)
+		return err
+	}
+
+	return nil
+}
+
 func (t SystemUpdater) disablePup(j dogeboxd.Job) error {
 	s := *j.State
 	log := j.Logger.Step("disable")
 
 	// Enabled flag should already be set to false by dispatcher, but verify/set for idempotency
-	newState, err := t.pupManager.UpdatePup(s.ID, dogeboxd.PupEnabled(false))
+	newState, err := t.pupManager.UpdatePup(s.ID, dogeboxd.PupEnabled(false), dogeboxd.WithPupdateReason(dogeboxd.PupdateReasonFor(j)))
 	if err != nil {
 		return err
 	}
 
-	cmd := exec.Command("sudo", "_dbxroot", "pup", "stop", "--pupId", s.ID)
-	log.LogCmd(cmd)
+	// Leave the pup running until the change is applied.
+	if t.sm != nil && t.sm.Get().Dogebox.DeferRebuilds {
+		nixPatch := t.nix.NewPatch(log)
+		t.nix.WritePupFile(nixPatch, newState, t.sm.Get().Dogebox)
+		return t.deferPupChange(nixPatch, newState, log)
+	}
 
-	if err := cmd.Run(); err != nil {
+	if err := t.runner.Run(log, pupStopOp(s)); err != nil {
 		log.Errf("Error executing _dbxroot pup stop: %v", err)
 		return err
 	}
@@ -565,29 +963,24 @@ func (t SystemUpdater) importBlockchainData(j dogeboxd.Job) error {
 		// If the pup is enabled, disable it to prevent auto-restart during import
 		if wasEnabled {
 			log.Log("Dogecoin Core pup is enabled, temporarily disabling during import...")
-			_, err := t.pupManager.UpdatePup(dogecoinPup.ID, dogeboxd.PupEnabled(false))
+			_, err := t.pupManager.UpdatePup(dogecoinPup.ID, dogeboxd.PupEnabled(false), dogeboxd.WithPupdateReason(dogeboxd.PupdateReasonFor(j)))
 			if err != nil {
 				log.Errf("Failed to disable pup: %v", err)
 				return err
 			}
 
 			// Stop the pup if it's running
-			stopCmd := exec.Command("sudo", "_dbxroot", "pup", "stop", "--pupId", dogecoinPup.ID)
-			log.LogCmd(stopCmd)
-			if err := stopCmd.Run(); err != nil {
+			if err := t.runner.Run(log, pupStopOp(*dogecoinPup)); err != nil {
 				log.Errf("Error stopping pup: %v", err)
 				// Re-enable the pup if we failed to stop it
-				t.pupManager.UpdatePup(dogecoinPup.ID, dogeboxd.PupEnabled(true))
+				t.pupManager.UpdatePup(dogecoinPup.ID, dogeboxd.PupEnabled(true), dogeboxd.WithPupdateReason(dogeboxd.PupdateReasonFor(j)))
 				return err
 			}
 		}
 	}
 
 	// Run the blockchain data import command
-	cmd := exec.Command("sudo", "_dbxroot", "import-blockchain-data", "--data-dir", t.config.DataDir)
-	log.LogCmd(cmd)
-
-	err := cmd.Run()
+	err := t.runner.Run(log, rootd.ImportBlockchainData{DataDir: t.config.DataDir})
 	if err != nil {
 		log.Errf("Failed to import blockchain data: %v", err)
 	}
@@ -595,7 +988,7 @@ func (t SystemUpdater) importBlockchainData(j dogeboxd.Job) error {
 	// Re-enable the pup if it was originally enabled
 	if dogecoinPup != nil && wasEnabled {
 		log.Log("Re-enabling Dogecoin Core pup...")
-		_, enableErr := t.pupManager.UpdatePup(dogecoinPup.ID, dogeboxd.PupEnabled(true))
+		_, enableErr := t.pupManager.UpdatePup(dogecoinPup.ID, dogeboxd.PupEnabled(true), dogeboxd.WithPupdateReason(dogeboxd.PupdateReasonFor(j)))
 		if enableErr != nil {
 			log.Errf("Failed to re-enable pup: %v", enableErr)
 			if err == nil {
@@ -760,15 +1153,13 @@ func (t SystemUpdater) updateNixCache(j dogeboxd.Job) error {
 }
 
 // getServiceStatus returns detailed status information about a systemd service
-func getServiceStatus(serviceName string) (status string, recentLogs []string, err error) {
+func (t SystemUpdater) getServiceStatus(serviceName string) (status string, recentLogs []string, err error) {
 	// Get service status
-	statusCmd := exec.Command("sudo", "systemctl", "status", serviceName, "--no-pager", "--lines=0")
-	statusOutput, statusErr := statusCmd.CombinedOutput()
+	statusOutput, statusErr := t.runner.CombinedOutput(nil, rootd.UnitStatus{Unit: serviceName})
 	status = strings.TrimSpace(string(statusOutput))
 
 	// Get recent logs (last 20 lines)
-	logsCmd := exec.Command("sudo", "journalctl", "-u", serviceName, "-n", "20", "--no-pager")
-	logsOutput, logsErr := logsCmd.CombinedOutput()
+	logsOutput, logsErr := t.runner.CombinedOutput(nil, rootd.UnitLogs{Unit: serviceName, Lines: 20})
 	if logsErr == nil {
 		logLines := strings.Split(strings.TrimSpace(string(logsOutput)), "\n")
 		recentLogs = logLines
@@ -784,20 +1175,18 @@ func getServiceStatus(serviceName string) (status string, recentLogs []string, e
 
 // waitForContainerRunning polls systemctl until container is active and running
 // This replaces manual systemctl start - we let NixOS autoStart handle it
-func waitForContainerRunning(serviceName string, timeout time.Duration, log dogeboxd.SubLogger) error {
+func (t SystemUpdater) waitForContainerRunning(serviceName string, timeout time.Duration, log dogeboxd.SubLogger) error {
 	deadline := time.Now().Add(timeout)
 	checkInterval := 2 * time.Second
 
 	for time.Now().Before(deadline) {
 		// Check if service is active and running
-		cmd := exec.Command("sudo", "systemctl", "is-active", serviceName)
-		output, _ := cmd.CombinedOutput()
+		output, _ := t.runner.CombinedOutput(log, rootd.UnitIsActive{Unit: serviceName})
 		state := strings.TrimSpace(string(output))
 
 		if state == "active" {
 			// Double-check it's actually running (not just activated)
-			cmd = exec.Command("sudo", "systemctl", "show", serviceName, "--property=SubState")
-			output, _ = cmd.CombinedOutput()
+			output, _ = t.runner.CombinedOutput(log, rootd.UnitSubState{Unit: serviceName})
 			subState := strings.TrimSpace(strings.TrimPrefix(string(output), "SubState="))
 
 			if subState == "running" {
@@ -822,13 +1211,110 @@ func waitForContainerRunning(serviceName string, timeout time.Duration, log doge
 func (t SystemUpdater) upgradePup(upgrade dogeboxd.UpgradePup, j dogeboxd.Job) error {
 	s := *j.State
 	log := j.Logger.Step("upgrade")
-	nixPatch := t.nix.NewPatch(log)
 
 	log.Logf("Upgrading pup %s (%s) from %s to %s", s.Manifest.Meta.Name, s.ID, s.Version, upgrade.TargetVersion)
 
 	// Record if pup was enabled
 	wasEnabled := s.Enabled
 
+	updatedState, err := t.prepareUpgrade(upgrade, s, log)
+	if err != nil {
+		return err
+	}
+
+	// Rebuild nix configuration
+	dbxState := t.sm.Get().Dogebox
+	nixPatch := t.nix.NewPatch(log)
+	t.nix.WritePupFile(nixPatch, updatedState, dbxState)
+	t.nix.UpdateIncludesFile(nixPatch, t.pupManager, dbxState)
+
+	if err := nixPatch.Apply(); err != nil {
+		log.Errf("Failed to apply nix patch: %v", err)
+		return t.markPupBroken(s, dogeboxd.BROKEN_REASON_NIX_APPLY_FAILED, err)
+	}
+
+	// For ephemeral containers, completely remove from NixOS config before re-adding
+	// This forces NixOS to treat it as a NEW container and rebuild its system
+	if wasEnabled {
+		log.Log("Removing pup from NixOS config (will re-add as new)...")
+		removeNixPatch := t.nix.NewPatch(log)
+		removeNixPatch.RemovePupFile(s.ID)
+		if err := removeNixPatch.Apply(); err != nil {
+			log.Errf("Failed to remove pup from config: %v", err)
+			return t.markPupBroken(s, dogeboxd.BROKEN_REASON_NIX_APPLY_FAILED, err)
+		}
+		cleanContainerDir(s.ID, log)
+	}
+
+	newState, err := t.markUpgradeReady(s, log)
+	if err != nil {
+		return err
+	}
+
+	// Write pup file with updated state (including Enabled=true if re-enabling) and rebuild
+	// Since we removed it completely, NixOS will treat this as a NEW container
+	if wasEnabled {
+		log.Log("Adding pup back to NixOS config as new container...")
+		nixPatch := t.nix.NewPatch(log)
+		t.nix.WritePupFile(nixPatch, newState, dbxState)
+		t.nix.UpdateIncludesFile(nixPatch, t.pupManager, dbxState)
+		if err := nixPatch.Apply(); err != nil {
+			log.Errf("Failed to add pup back to config: %v", err)
+			return t.markPupBroken(s, dogeboxd.BROKEN_REASON_NIX_APPLY_FAILED, err)
+		}
+	}
+
+	return t.awaitUpgradedPup(s, newState, upgrade, log)
+}
+
+// checkUpgradeSource makes sure a pup is upgraded from the source it was
+// installed from, and not another providing a pup by the same name.
+func (t SystemUpdater) checkUpgradeSource(upgrade dogeboxd.UpgradePup, s dogeboxd.PupState) error {
+	if upgrade.SourceId != s.Source.ID {
+		return fmt.Errorf("pup was installed from source %s, not %s", s.Source.ID, upgrade.SourceId)
+	}
+	source, err := t.sources.GetSource(upgrade.SourceId)
+	if err != nil {
+		return err
+	}
+	if !dogeboxd.SameSource(source.Config(), s.Source) {
+		return fmt.Errorf("source %s is no longer at %s, where the pup was installed from", s.Source.ID, s.Source.Location)
+	}
+	return nil
+}
+
+// upgradeCommit is the commit to upgrade a pup from, "" for the source's
+// tags. A pinned pup stays pinned, so it needs a commit to upgrade to.
+func upgradeCommit(upgrade dogeboxd.UpgradePup, s dogeboxd.PupState) (string, error) {
+	if err := dogeboxd.ValidateGitCommit(upgrade.Commit); err != nil {
+		return "", err
+	}
+	if upgrade.Commit == "" && s.Source.Commit != "" {
+		return "", fmt.Errorf("pup is pinned to commit %s, upgrade it to another commit", s.Source.Commit)
+	}
+	return upgrade.Commit, nil
+}
+
+// prepareUpgrade does everything an upgrade needs before the nix config
+// is rewritten: stopping the pup, snapshotting it for rollback, fetching
+// the new version and carrying its config across. It returns the pup's
+// state at the new version, the pup is marked broken if it fails once
+// it's been stopped.
+func (t SystemUpdater) prepareUpgrade(upgrade dogeboxd.UpgradePup, s dogeboxd.PupState, log dogeboxd.SubLogger) (dogeboxd.PupState, error) {
+	// Refuse before touching the pup, it's fine as it is.
+	if err := t.checkUpgradeSource(upgrade, s); err != nil {
+		log.Errf("Can't upgrade: %v", err)
+		return dogeboxd.PupState{}, err
+	}
+	commit, err := upgradeCommit(upgrade, s)
+	if err != nil {
+		log.Errf("Can't upgrade: %v", err)
+		return dogeboxd.PupState{}, err
+	}
+	if commit != "" {
+		log.Logf("Upgrading from commit %s", commit)
+	}
+
 	// Stop the pup if it's running
 	if s.Enabled {
 		log.Log("Stopping pup before upgrade...")
@@ -842,16 +1328,16 @@ func (t SystemUpdater) upgradePup(upgrade dogeboxd.UpgradePup, j dogeboxd.Job) e
 	log.Log("Creating snapshot for rollback...")
 	if err := t.pupManager.CreateSnapshot(s); err != nil {
 		log.Errf("Failed to create snapshot: %v", err)
-		return fmt.Errorf("cannot proceed with upgrade without rollback capability: %w", err)
+		return dogeboxd.PupState{}, fmt.Errorf("cannot proceed with upgrade without rollback capability: %w", err)
 	}
 
 	// Fetch the new manifest FIRST (before downloading files)
 	// This allows us to update state before modifying files on disk
 	log.Logf("Fetching manifest for version %s", upgrade.TargetVersion)
-	newManifest, _, err := t.sources.GetSourceManifest(upgrade.SourceId, s.Manifest.Meta.Name, upgrade.TargetVersion)
+	newManifest, newSource, err := t.sources.GetSourceManifestAt(upgrade.SourceId, commit, s.Manifest.Meta.Name, upgrade.TargetVersion)
 	if err != nil {
 		log.Errf("Failed to fetch manifest for target version: %v", err)
-		return t.markPupBroken(s, "manifest_fetch_failed", err)
+		return dogeboxd.PupState{}, t.markPupBroken(s, "manifest_fetch_failed", err)
 	}
 
 	// Update state with new version/manifest BEFORE downloading files
@@ -862,10 +1348,12 @@ func (t SystemUpdater) upgradePup(upgrade dogeboxd.UpgradePup, j dogeboxd.Job) e
 		dogeboxd.SetPupInstallation(dogeboxd.STATE_UPGRADING),
 		dogeboxd.SetPupVersion(upgrade.TargetVersion),
 		dogeboxd.SetPupManifest(newManifest),
+		dogeboxd.SetPupSourceCommit(newSource.Config().Commit),
+		dogeboxd.WithPupdateReason(dogeboxd.PupdateReasonFromLog(log)),
 	)
 	if err != nil {
 		log.Errf("Failed to update pup state: %v", err)
-		return t.markPupBroken(s, dogeboxd.BROKEN_REASON_STATE_UPDATE_FAILED, err)
+		return dogeboxd.PupState{}, t.markPupBroken(s, dogeboxd.BROKEN_REASON_STATE_UPDATE_FAILED, err)
 	}
 
 	// Clear the update cache entry now that version has changed
@@ -876,94 +1364,160 @@ func (t SystemUpdater) upgradePup(upgrade dogeboxd.UpgradePup, j dogeboxd.Job) e
 	pupPath := filepath.Join(t.config.DataDir, "pups", s.ID)
 	log.Logf("Downloading new version to %s", pupPath)
 
-	_, err = t.sources.DownloadPup(pupPath, upgrade.SourceId, s.Manifest.Meta.Name, upgrade.TargetVersion)
+	_, err = t.sources.DownloadPupAt(pupPath, upgrade.SourceId, commit, s.Manifest.Meta.Name, upgrade.TargetVersion, log)
 	if err != nil {
 		log.Errf("Failed to download new version: %v", err)
-		return t.markPupBroken(s, dogeboxd.BROKEN_REASON_DOWNLOAD_FAILED, err)
+		return dogeboxd.PupState{}, t.markPupBroken(s, dogeboxd.BROKEN_REASON_DOWNLOAD_FAILED, err)
+	}
+
+	profile := t.sm.Get().Dogebox.Profile
+	if err := t.verifyPupSignature(pupPath, profile, log); err != nil {
+		return dogeboxd.PupState{}, t.markPupBroken(s, dogeboxd.BROKEN_REASON_SIGNATURE_INVALID, err)
 	}
 
 	// Verify nix file hash
-	if err := t.verifyNixFileHash(pupPath, newManifest, s.IsDevModeEnabled, log); err != nil {
-		return t.markPupBroken(s, dogeboxd.BROKEN_REASON_NIX_HASH_MISMATCH, err)
+	if err := t.verifyNixFileHash(pupPath, newManifest, profile.AllowsHashMismatch(s.IsDevModeEnabled), log); err != nil {
+		return dogeboxd.PupState{}, t.markPupBroken(s, dogeboxd.BROKEN_REASON_NIX_HASH_MISMATCH, err)
 	}
 
 	// Write updated config to storage (in case manifest has new config fields)
 	updatedState, _, err := t.pupManager.GetPup(s.ID)
 	if err != nil {
 		log.Errf("Failed to get updated pup state: %v", err)
-		return t.markPupBroken(s, dogeboxd.BROKEN_REASON_STATE_UPDATE_FAILED, err)
+		return dogeboxd.PupState{}, t.markPupBroken(s, dogeboxd.BROKEN_REASON_STATE_UPDATE_FAILED, err)
 	}
 
-	if err := dogeboxd.WritePupConfigToStorage(t.config.DataDir, s.ID, updatedState.Config, log); err != nil {
-		log.Errf("Failed to write config to storage: %v", err)
-		return t.markPupBroken(s, dogeboxd.BROKEN_REASON_STORAGE_CREATION_FAILED, err)
+	// Run any migrations the new manifest ships between our old and new version.
+	migrations, err := dogeboxd.PupMigrationsBetween(newManifest, s.Version, upgrade.TargetVersion)
+	if err != nil {
+		log.Errf("Failed to work out pup migrations: %v", err)
+		return dogeboxd.PupState{}, t.markPupBroken(s, dogeboxd.BROKEN_REASON_MIGRATION_FAILED, err)
 	}
 
-	// Rebuild nix configuration
-	dbxState := t.sm.Get().Dogebox
-	t.nix.WritePupFile(nixPatch, updatedState, dbxState)
-	t.nix.UpdateIncludesFile(nixPatch, t.pupManager)
+	if len(migrations) > 0 {
+		log.Logf("Running %d migration(s) from %s to %s", len(migrations), s.Version, upgrade.TargetVersion)
 
-	if err := nixPatch.Apply(); err != nil {
-		log.Errf("Failed to apply nix patch: %v", err)
-		return t.markPupBroken(s, dogeboxd.BROKEN_REASON_NIX_APPLY_FAILED, err)
-	}
-
-	// For ephemeral containers, completely remove from NixOS config before re-adding
-	// This forces NixOS to treat it as a NEW container and rebuild its system
-	if wasEnabled {
-		log.Log("Removing pup from NixOS config (will re-add as new)...")
-		removeNixPatch := t.nix.NewPatch(log)
-		removeNixPatch.RemovePupFile(s.ID)
-		if err := removeNixPatch.Apply(); err != nil {
-			log.Errf("Failed to remove pup from config: %v", err)
-			return t.markPupBroken(s, dogeboxd.BROKEN_REASON_NIX_APPLY_FAILED, err)
+		migratedConfig, pending, err := runPupMigrations(updatedState.Config, migrations, log)
+		if err != nil {
+			return dogeboxd.PupState{}, t.markPupBroken(s, dogeboxd.BROKEN_REASON_MIGRATION_FAILED, err)
 		}
 
-		// Delete container directory to ensure clean slate
-		containerDir := fmt.Sprintf("/var/lib/nixos-containers/pup-%s", s.ID)
-		log.Logf("Cleaning container directory: %s", containerDir)
-		if err := os.RemoveAll(containerDir); err != nil {
-			log.Errf("Warning: failed to remove container directory: %v", err)
-			// Not fatal, continue
+		updatedState, err = t.pupManager.UpdatePup(s.ID,
+			dogeboxd.ReplacePupConfig(migratedConfig),
+			dogeboxd.SetPupPendingMigrations(mergePendingMigrations(updatedState.PendingMigrations, pending)),
+			dogeboxd.WithPupdateReason(dogeboxd.PupdateReasonFromLog(log)),
+		)
+		if err != nil {
+			log.Errf("Failed to save migrated pup state: %v", err)
+			return dogeboxd.PupState{}, t.markPupBroken(s, dogeboxd.BROKEN_REASON_STATE_UPDATE_FAILED, err)
 		}
 	}
 
+	// Carry our config across to the new manifest's fields.
+	mergedConfig, mergeReport, err := dogeboxd.MergePupConfigForUpgrade(s.Manifest.Config, newManifest.Config, updatedState.Config)
+	if err != nil {
+		log.Errf("Failed to merge pup config: %v", err)
+		return dogeboxd.PupState{}, t.markPupBroken(s, dogeboxd.BROKEN_REASON_STATE_UPDATE_FAILED, err)
+	}
+
+	var reportUpdate *dogeboxd.PupConfigMergeReport
+	if mergeReport.HasChanges() {
+		mergeReport.FromVersion = s.Version
+		mergeReport.ToVersion = upgrade.TargetVersion
+		logConfigMergeReport(mergeReport, log)
+		reportUpdate = &mergeReport
+	}
+
+	// Fields the new manifest marks secret are moved out of our config.
+	// While the store is locked they stay put, until they're next set.
+	plainConfig, newSecrets := dogeboxd.SplitSecretConfig(newManifest.Config, mergedConfig)
+	sealedSecrets, err := t.secrets.Seal(s.ID, newSecrets)
+	switch {
+	case errors.Is(err, dogeboxd.ErrConfigSecretsLocked):
+		log.Logf("Config secrets are locked, leaving %d newly secret field(s) unsealed", len(newSecrets))
+		sealedSecrets = nil
+	case err != nil:
+		log.Errf("Failed to seal config fields that are now secret: %v", err)
+		return dogeboxd.PupState{}, t.markPupBroken(s, dogeboxd.BROKEN_REASON_STATE_UPDATE_FAILED, err)
+	default:
+		mergedConfig = plainConfig
+	}
+
+	closures := resolvePrebuiltClosures(newManifest, s.IsDevModeEnabled, log)
+
+	updatedState, err = t.pupManager.UpdatePup(s.ID,
+		dogeboxd.ReplacePupConfig(mergedConfig),
+		dogeboxd.SetPupSecretConfig(sealedSecrets),
+		dogeboxd.SetPupConfigMergeReport(reportUpdate),
+		dogeboxd.SetPupPrebuiltClosures(closures),
+		dogeboxd.WithPupdateReason(dogeboxd.PupdateReasonFromLog(log)),
+	)
+	if err != nil {
+		log.Errf("Failed to save merged pup config: %v", err)
+		return dogeboxd.PupState{}, t.markPupBroken(s, dogeboxd.BROKEN_REASON_STATE_UPDATE_FAILED, err)
+	}
+
+	upgradedConfig, keep, err := t.secrets.PupConfig(updatedState)
+	if err != nil {
+		log.Errf("Failed to read secret config: %v", err)
+		return dogeboxd.PupState{}, t.markPupBroken(s, dogeboxd.BROKEN_REASON_STORAGE_CREATION_FAILED, err)
+	}
+
+	if err := dogeboxd.WritePupConfigToStorage(t.config.DataDir, s.ID, upgradedConfig, keep, updatedState.EnvOverrides, log); err != nil {
+		log.Errf("Failed to write config to storage: %v", err)
+		return dogeboxd.PupState{}, t.markPupBroken(s, dogeboxd.BROKEN_REASON_STORAGE_CREATION_FAILED, err)
+	}
+
+	return updatedState, nil
+}
+
+// cleanContainerDir deletes an upgraded pup's container directory, so it
+// comes back with a clean slate.
+func cleanContainerDir(pupID string, log dogeboxd.SubLogger) {
+	containerDir := fmt.Sprintf("/var/lib/nixos-containers/pup-%s", pupID)
+	log.Logf("Cleaning container directory: %s", containerDir)
+	if err := os.RemoveAll(containerDir); err != nil {
+		log.Errf("Warning: failed to remove container directory: %v", err)
+		// Not fatal, continue
+	}
+}
+
+// markUpgradeReady marks an upgraded pup ready, re-enabling it if it was
+// enabled before, s being its state from before the upgrade.
+func (t SystemUpdater) markUpgradeReady(s dogeboxd.PupState, log dogeboxd.SubLogger) (dogeboxd.PupState, error) {
 	// Mark as ready and re-enable if it was enabled before
 	updates := []func(*dogeboxd.PupState, *[]dogeboxd.Pupdate){dogeboxd.SetPupInstallation(dogeboxd.STATE_READY)}
-	if wasEnabled {
+	if s.Enabled {
 		log.Log("Re-enabling pup after upgrade...")
 		updates = append(updates, dogeboxd.PupEnabled(true))
 	}
 
-	newState, err := t.pupManager.UpdatePup(s.ID, updates...)
+	newState, err := t.pupManager.UpdatePup(s.ID, append(updates, dogeboxd.WithPupdateReason(dogeboxd.PupdateReasonFromLog(log)))...)
 	if err != nil {
 		log.Errf("Failed to update pup state: %v", err)
-		return t.markPupBroken(s, dogeboxd.BROKEN_REASON_STATE_UPDATE_FAILED, err)
+		return dogeboxd.PupState{}, t.markPupBroken(s, dogeboxd.BROKEN_REASON_STATE_UPDATE_FAILED, err)
 	}
+	return newState, nil
+}
 
-	// Write pup file with updated state (including Enabled=true if re-enabling) and rebuild
-	// Since we removed it completely, NixOS will treat this as a NEW container
-	if wasEnabled {
-		log.Log("Adding pup back to NixOS config as new container...")
-		nixPatch := t.nix.NewPatch(log)
-		t.nix.WritePupFile(nixPatch, newState, dbxState)
-		t.nix.UpdateIncludesFile(nixPatch, t.pupManager)
-		if err := nixPatch.Apply(); err != nil {
-			log.Errf("Failed to add pup back to config: %v", err)
-			return t.markPupBroken(s, dogeboxd.BROKEN_REASON_NIX_APPLY_FAILED, err)
-		}
-
+// awaitUpgradedPup waits for an upgraded pup that was enabled to come
+// back up, running its pending migrations, once its config is applied.
+func (t SystemUpdater) awaitUpgradedPup(s dogeboxd.PupState, newState dogeboxd.PupState, upgrade dogeboxd.UpgradePup, log dogeboxd.SubLogger) error {
+	if s.Enabled {
 		// Container should start automatically via autoStart=true
 		// NixOS will build the container system and start it because it's "new"
+		if err := t.startManualPup(newState, log); err != nil {
+			log.Errf("Warning: failed to start container after upgrade: %v", err)
+		}
+
 		serviceName := fmt.Sprintf("container@pup-%s.service", s.ID)
 		log.Logf("Waiting for container to start (NixOS treating as new container)...")
 
-		if err := waitForContainerRunning(serviceName, 60*time.Second, log); err != nil {
+		if err := t.waitForContainerRunning(serviceName, 60*time.Second, log); err != nil {
 			log.Errf("Container did not start within timeout: %v", err)
 
 			// Get detailed service status and logs for debugging
-			status, logs, statusErr := getServiceStatus(serviceName)
+			status, logs, statusErr := t.getServiceStatus(serviceName)
 			if statusErr != nil {
 				log.Errf("Failed to get service status: %v", statusErr)
 			} else {
@@ -980,9 +1534,25 @@ func (t SystemUpdater) upgradePup(upgrade dogeboxd.UpgradePup, j dogeboxd.Job) e
 
 			log.Errf("Check container logs: journalctl -u %s", serviceName)
 			log.Errf("Container may still start in background, but upgrade workflow completing")
+			if len(newState.PendingMigrations) > 0 {
+				log.Errf("%d migration(s) will run once the container starts", len(newState.PendingMigrations))
+			}
 		} else {
 			log.Logf("Container started successfully")
+
+			if len(newState.PendingMigrations) > 0 {
+				if err := t.waitForPupMigrations(s.ID, log); err != nil {
+					log.Errf("Pup migrations failed: %v", err)
+					return t.markPupBroken(s, dogeboxd.BROKEN_REASON_MIGRATION_FAILED, err)
+				}
+
+				if _, err := t.pupManager.UpdatePup(s.ID, dogeboxd.SetPupPendingMigrations(nil), dogeboxd.WithPupdateReason(dogeboxd.PupdateReasonFromLog(log))); err != nil {
+					log.Errf("Failed to clear pending migrations: %v", err)
+				}
+			}
 		}
+	} else if len(newState.PendingMigrations) > 0 {
+		log.Logf("%d migration(s) will run the next time the pup is started", len(newState.PendingMigrations))
 	}
 
 	log.Logf("Successfully upgraded pup %s to version %s", s.Manifest.Meta.Name, upgrade.TargetVersion)
@@ -996,6 +1566,9 @@ func (t SystemUpdater) rollbackPupUpgrade(j dogeboxd.Job) error {
 	nixPatch := t.nix.NewPatch(log)
 
 	log.Logf("Rolling back pup %s (%s)", s.Manifest.Meta.Name, s.ID)
+	if a, ok := j.A.(dogeboxd.RollbackPupUpgrade); ok && a.Reason != "" {
+		log.Logf("Rolling back as the %s", a.Reason)
+	}
 
 	snapshot, err := t.pupManager.GetSnapshot(s.ID)
 	if err != nil {
@@ -1010,12 +1583,10 @@ func (t SystemUpdater) rollbackPupUpgrade(j dogeboxd.Job) error {
 	log.Logf("Found snapshot: rolling back to version %s", snapshot.Version)
 
 	// Stop the pup if running
-	cmd := exec.Command("sudo", "_dbxroot", "pup", "stop", "--pupId", s.ID)
-	log.LogCmd(cmd)
-	_ = cmd.Run() // Ignore error, might not be running
+	_ = t.runner.Run(log, pupStopOp(s)) // Ignore error, might not be running
 
 	// Update state to indicate rollback in progress
-	if _, err := t.pupManager.UpdatePup(s.ID, dogeboxd.SetPupInstallation(dogeboxd.STATE_UPGRADING)); err != nil {
+	if _, err := t.pupManager.UpdatePup(s.ID, dogeboxd.SetPupInstallation(dogeboxd.STATE_UPGRADING), dogeboxd.WithPupdateReason(dogeboxd.PupdateReasonFor(j))); err != nil {
 		log.Errf("Failed to update state: %v", err)
 		return t.markPupBroken(s, dogeboxd.BROKEN_REASON_STATE_UPDATE_FAILED, err)
 	}
@@ -1024,18 +1595,24 @@ func (t SystemUpdater) rollbackPupUpgrade(j dogeboxd.Job) error {
 	pupPath := filepath.Join(t.config.DataDir, "pups", s.ID)
 	log.Logf("Downloading previous version %s", snapshot.Version)
 
-	_, err = t.sources.DownloadPup(pupPath, snapshot.SourceID, s.Manifest.Meta.Name, snapshot.Version)
+	_, err = t.sources.DownloadPupAt(pupPath, snapshot.SourceID, snapshot.SourceCommit, s.Manifest.Meta.Name, snapshot.Version, log)
 	if err != nil {
 		log.Errf("Failed to download previous version: %v", err)
 		return t.markPupBroken(s, dogeboxd.BROKEN_REASON_DOWNLOAD_FAILED, err)
 	}
 
-	// Restore state from snapshot (using manifest from snapshot, not downloaded one)
-	_, err = t.pupManager.UpdatePup(s.ID,
+	// Restore state from snapshot (using manifest from snapshot, not downloaded one).
+	// Secrets are merged back, older snapshots don't have them.
+	restored, err := t.pupManager.UpdatePup(s.ID,
 		dogeboxd.SetPupVersion(snapshot.Version),
 		dogeboxd.SetPupManifest(snapshot.Manifest),
-		dogeboxd.SetPupConfig(snapshot.Config),
+		dogeboxd.SetPupSourceCommit(snapshot.SourceCommit),
+		dogeboxd.ReplacePupConfig(snapshot.Config),
+		dogeboxd.SetPupSecretConfig(snapshot.SecretConfig),
 		dogeboxd.SetPupProviders(snapshot.Providers),
+		dogeboxd.SetPupPendingMigrations(nil),
+		dogeboxd.SetPupPrebuiltClosures(resolvePrebuiltClosures(snapshot.Manifest, s.IsDevModeEnabled, log)),
+		dogeboxd.WithPupdateReason(dogeboxd.PupdateReasonFor(j)),
 	)
 	if err != nil {
 		log.Errf("Failed to restore pup state: %v", err)
@@ -1043,7 +1620,12 @@ func (t SystemUpdater) rollbackPupUpgrade(j dogeboxd.Job) error {
 	}
 
 	// Write config to storage
-	if err := dogeboxd.WritePupConfigToStorage(t.config.DataDir, s.ID, snapshot.Config, log); err != nil {
+	restoredConfig, keep, err := t.secrets.PupConfig(restored)
+	if err != nil {
+		log.Errf("Failed to read secret config: %v", err)
+		return t.markPupBroken(s, dogeboxd.BROKEN_REASON_STORAGE_CREATION_FAILED, err)
+	}
+	if err := dogeboxd.WritePupConfigToStorage(t.config.DataDir, s.ID, restoredConfig, keep, s.EnvOverrides, log); err != nil {
 		log.Errf("Failed to write config to storage: %v", err)
 		return t.markPupBroken(s, dogeboxd.BROKEN_REASON_STORAGE_CREATION_FAILED, err)
 	}
@@ -1055,7 +1637,7 @@ func (t SystemUpdater) rollbackPupUpgrade(j dogeboxd.Job) error {
 		updates = append(updates, dogeboxd.PupEnabled(true))
 	}
 
-	if _, err := t.pupManager.UpdatePup(s.ID, updates...); err != nil {
+	if _, err := t.pupManager.UpdatePup(s.ID, append(updates, dogeboxd.WithPupdateReason(dogeboxd.PupdateReasonFor(j)))...); err != nil {
 		log.Errf("Failed to update pup state: %v", err)
 		return t.markPupBroken(s, dogeboxd.BROKEN_REASON_STATE_UPDATE_FAILED, err)
 	}
@@ -1064,7 +1646,7 @@ func (t SystemUpdater) rollbackPupUpgrade(j dogeboxd.Job) error {
 	restoredState, _, _ := t.pupManager.GetPup(s.ID)
 	dbxState := t.sm.Get().Dogebox
 	t.nix.WritePupFile(nixPatch, restoredState, dbxState)
-	t.nix.UpdateIncludesFile(nixPatch, t.pupManager)
+	t.nix.UpdateIncludesFile(nixPatch, t.pupManager, dbxState)
 
 	if err := nixPatch.Apply(); err != nil {
 		log.Errf("Failed to apply nix patch: %v", err)
@@ -1073,11 +1655,9 @@ func (t SystemUpdater) rollbackPupUpgrade(j dogeboxd.Job) error {
 
 	// Explicitly start the container if it was enabled - NixOS autoStart only starts
 	// NEW containers, not containers that were previously stopped
-	if snapshot.Enabled {
+	if snapshot.Enabled && !s.InMaintenance() {
 		serviceName := fmt.Sprintf("container@pup-%s.service", s.ID)
-		cmd := exec.Command("sudo", "systemctl", "start", serviceName)
-		log.LogCmd(cmd)
-		if err := cmd.Run(); err != nil {
+		if err := t.runner.Run(log, rootd.StartPupUnit{Unit: serviceName}); err != nil {
 			log.Errf("Warning: failed to start container after rollback: %v", err)
 			// Not fatal - container may start via other means
 		}
@@ -18,7 +18,7 @@ type testNixManager struct {
 
 func (t *testNixManager) InitSystem(patch dogeboxd.NixPatch, dbxState dogeboxd.DogeboxState) {}
 
-func (t *testNixManager) UpdateIncludesFile(patch dogeboxd.NixPatch, pups dogeboxd.PupManager) {}
+func (t *testNixManager) UpdateIncludesFile(patch dogeboxd.NixPatch, pups dogeboxd.PupManager, dbxState dogeboxd.DogeboxState) {}
 
 func (t *testNixManager) WritePupFile(patch dogeboxd.NixPatch, state dogeboxd.PupState, dbxState dogeboxd.DogeboxState) {}
 
@@ -32,7 +32,27 @@ func (t *testNixManager) UpdateNetwork(patch dogeboxd.NixPatch, values dogeboxd.
 
 func (t *testNixManager) UpdateSystem(patch dogeboxd.NixPatch, values dogeboxd.NixSystemTemplateValues) {}
 
-func (t *testNixManager) UpdateStorageOverlay(patch dogeboxd.NixPatch, partitionName string) {}
+func (t *testNixManager) UpdateStorageOverlay(patch dogeboxd.NixPatch, volume dogeboxd.StorageVolume) {}
+
+func (t *testNixManager) UpdateRecoveryAP(patch dogeboxd.NixPatch, iface string, ssid string, dbxState dogeboxd.DogeboxState) {}
+
+func (t *testNixManager) RemoveRecoveryAP(patch dogeboxd.NixPatch) {}
+
+func (t *testNixManager) RestoreConfigBackup(patch dogeboxd.NixPatch, backupID string) error {
+	return nil
+}
+
+func (t *testNixManager) CreateConfigBackup(log dogeboxd.SubLogger) (dogeboxd.NixConfigBackup, error) {
+	return dogeboxd.NixConfigBackup{}, nil
+}
+
+func (t *testNixManager) ListConfigBackups() ([]dogeboxd.NixConfigBackup, error) { return nil, nil }
+
+func (t *testNixManager) ListConfigBackupFiles(backupID string) ([]dogeboxd.NixConfigBackupFile, error) {
+	return nil, nil
+}
+
+func (t *testNixManager) DeleteConfigBackup(backupID string) error { return nil }
 
 func (t *testNixManager) RebuildBoot(log dogeboxd.SubLogger) error { return nil }
 
@@ -40,6 +60,12 @@ func (t *testNixManager) Rebuild(log dogeboxd.SubLogger) error { return nil }
 
 func (t *testNixManager) NewPatch(log dogeboxd.SubLogger) dogeboxd.NixPatch { return nil }
 
+func (t *testNixManager) ListSystemPackages() ([]dogeboxd.SystemPackage, error) { return nil, nil }
+
+func (t *testNixManager) ListSystemGenerations() ([]dogeboxd.SystemGeneration, error) {
+	return nil, nil
+}
+
 func (t *testNixManager) GetConfigValue(configItem string) (string, error) {
 	return t.GetConfigValueContext(context.Background(), configItem)
 }
@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
 	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
 	"github.com/Dogebox-WG/dogeboxd/pkg/version"
@@ -80,6 +81,11 @@ type UpgradableRelease struct {
 	Version    string
 	ReleaseURL string
 	Summary    string
+
+	// Filled in by DescribeUpgradableReleases, when we can.
+	ReleaseNotes string
+	PublishedAt  *time.Time
+	Size         *ReleaseSizeEstimate
 }
 
 type InvalidUpdatePackageError struct {
@@ -276,17 +282,23 @@ func stageReleaseFlakeWithClone(tmpDir, updateVersion string, logger dogeboxd.Su
 	return finalDir, commitHash, nil
 }
 
-func doSystemUpdate(pkg string, updateVersion string, tmpDir string, logger dogeboxd.SubLogger) error {
-	return doSystemUpdateWithDependencies(pkg, updateVersion, tmpDir, logger, cloneReleaseRepository, exec.Command)
+func doSystemUpdate(pkg string, updateVersion string, tmpDir string, logger dogeboxd.SubLogger, prepare func(releaseDir string) error) error {
+	return doSystemUpdateWithDependencies(pkg, updateVersion, tmpDir, logger, GetReleaseManifest, cloneReleaseRepository, exec.Command, prepare)
 }
 
+/* doSystemUpdateWithDependencies stages the release and switches to it,
+ * once it's checked against the release's signed manifest. prepare, if set, is run against the staged release before switching,
+ * failing the update if it fails, see SystemUpdater.updateSystemPups.
+ */
 func doSystemUpdateWithDependencies(
 	pkg string,
 	updateVersion string,
 	tmpDir string,
 	logger dogeboxd.SubLogger,
+	getManifest func(version string) (ReleaseManifest, error),
 	cloneFunc func(string, string) error,
 	execCommand func(string, ...string) *exec.Cmd,
+	prepare func(releaseDir string) error,
 ) error {
 	upgradableReleases, err := GetUpgradableReleases(true)
 	if err != nil {
@@ -310,12 +322,36 @@ func doSystemUpdateWithDependencies(
 		return UpdateVersionUnavailableError{Package: pkg, Version: updateVersion}
 	}
 
+	manifest, err := getManifest(updateVersion)
+	if err != nil {
+		return err
+	}
+	if logger != nil {
+		logger.Logf("Verified signed manifest for OS release %s at %s", updateVersion, manifest.Rev)
+	}
+
 	stagedFlakeDir, commitHash, err := stageReleaseFlakeWithClone(tmpDir, updateVersion, logger, cloneFunc)
 	if err != nil {
 		return err
 	}
 
-	cmd := execCommand(SUDO_COMMAND, buildSystemUpdateCommandArgs(stagedFlakeDir, updateVersion, buildSystemUpdateUnitName(updateVersion, commitHash))...)
+	if err := checkReleaseRev(manifest, commitHash); err != nil {
+		_ = os.RemoveAll(stagedFlakeDir)
+		return err
+	}
+
+	if prepare != nil {
+		if err := prepare(stagedFlakeDir); err != nil {
+			return err
+		}
+	}
+
+	args := buildSystemUpdateCommandArgs(stagedFlakeDir, updateVersion, buildSystemUpdateUnitName(updateVersion, commitHash))
+	return runSystemUpdateCommand(execCommand, args, logger)
+}
+
+func runSystemUpdateCommand(execCommand func(string, ...string) *exec.Cmd, args []string, logger dogeboxd.SubLogger) error {
+	cmd := execCommand(SUDO_COMMAND, args...)
 	if logger != nil {
 		logger.Logf("Running command: %s %s", cmd.Path, strings.Join(cmd.Args[1:], " "))
 		cmd.Stdout = io.MultiWriter(os.Stdout, dogeboxd.NewLineWriter(func(s string) {
@@ -333,17 +369,29 @@ func doSystemUpdateWithDependencies(
 	}
 
 	// We probably won't even get here if dogeboxd is restarted/upgraded during this process.
-	return err
+	return nil
 }
 
-func (t SystemUpdater) DoSystemUpdate(pkg string, updateVersion string, logger dogeboxd.SubLogger) error {
+func (t SystemUpdater) DoSystemUpdate(a dogeboxd.SystemUpdate, j dogeboxd.Job, logger dogeboxd.SubLogger) error {
 	if err := MigrateLegacyCustomNix(t.config); err != nil {
 		return err
 	}
 
-	return doSystemUpdate(pkg, updateVersion, t.config.TmpDir, logger)
+	// System pups are brought up to the release before we switch to it,
+	// as dbx-upgrade restarts us, and put back if we don't.
+	var changes []systemPupChange
+	prepare := func(releaseDir string) error {
+		var err error
+		changes, err = t.updateSystemPups(releaseDir, a.SessionToken, j)
+		return err
+	}
+	err := doSystemUpdate(a.Package, a.Version, t.config.TmpDir, logger, prepare)
+	if err != nil && len(changes) > 0 {
+		t.restoreSystemPups(changes, j)
+	}
+	return err
 }
 
 func DoSystemUpdate(pkg string, updateVersion string, logger dogeboxd.SubLogger) error {
-	return doSystemUpdate(pkg, updateVersion, "", logger)
+	return doSystemUpdate(pkg, updateVersion, "", logger, nil)
 }
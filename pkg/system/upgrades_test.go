@@ -1,10 +1,12 @@
 package system
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -520,7 +522,7 @@ func TestSystemUpdaterDoSystemUpdateUsesStagedFlakeDir(t *testing.T) {
 		},
 	}
 
-	if err := doSystemUpdateWithDependencies("os", "v1.2.0", updater.config.TmpDir, nil, cloneFunc, execCommand); err != nil {
+	if err := doSystemUpdateWithDependencies("os", "v1.2.0", updater.config.TmpDir, nil, signedManifestFor(t, cloneFunc), cloneFunc, execCommand, nil); err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
 
@@ -559,6 +561,115 @@ func TestSystemUpdaterDoSystemUpdateUsesStagedFlakeDir(t *testing.T) {
 	}
 }
 
+func TestDoSystemUpdateStopsIfPrepareFails(t *testing.T) {
+	originalFetcher := repoTagsFetcher
+	defer func() {
+		repoTagsFetcher = originalFetcher
+	}()
+
+	repoTagsFetcher = &MockRepoTagsFetcher{
+		tags: []RepositoryTag{{Tag: "v1.2.0"}},
+		err:  nil,
+	}
+
+	tempDir := setupMockVersioning(t, "v1.1.0")
+	defer os.RemoveAll(tempDir)
+
+	cloneFunc := func(destination, version string) error {
+		return createTestReleaseRepo(t, destination, version)
+	}
+
+	ran := false
+	execCommand := func(name string, args ...string) *exec.Cmd {
+		ran = true
+		return exec.Command("sh", "-c", "exit 0")
+	}
+
+	var preparedDir string
+	prepare := func(releaseDir string) error {
+		preparedDir = releaseDir
+		return errors.New("system pup failed")
+	}
+
+	err := doSystemUpdateWithDependencies("os", "v1.2.0", t.TempDir(), nil, signedManifestFor(t, cloneFunc), cloneFunc, execCommand, prepare)
+	if err == nil || err.Error() != "system pup failed" {
+		t.Fatalf("expected prepare error, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(preparedDir, "flake.nix")); err != nil {
+		t.Fatalf("expected prepare to get the staged release, stat err: %v", err)
+	}
+	if ran {
+		t.Fatal("expected dbx-upgrade not to run after prepare failed")
+	}
+}
+
+func TestDoSystemUpdateRefusesReleasesNotMatchingTheirManifest(t *testing.T) {
+	originalFetcher := repoTagsFetcher
+	defer func() {
+		repoTagsFetcher = originalFetcher
+	}()
+
+	repoTagsFetcher = &MockRepoTagsFetcher{
+		tags: []RepositoryTag{{Tag: "v1.2.0"}},
+		err:  nil,
+	}
+
+	tempDir := setupMockVersioning(t, "v1.1.0")
+	defer os.RemoveAll(tempDir)
+
+	cloneFunc := func(destination, version string) error {
+		return createTestReleaseRepo(t, destination, version)
+	}
+
+	ran := false
+	execCommand := func(name string, args ...string) *exec.Cmd {
+		ran = true
+		return exec.Command("sh", "-c", "exit 0")
+	}
+
+	// The tag was moved since the manifest was signed.
+	getManifest := func(version string) (ReleaseManifest, error) {
+		return ReleaseManifest{Version: version, Rev: strings.Repeat("0", 40)}, nil
+	}
+
+	updateTmpDir := t.TempDir()
+	err := doSystemUpdateWithDependencies("os", "v1.2.0", updateTmpDir, nil, getManifest, cloneFunc, execCommand, nil)
+	if err == nil || !strings.Contains(err.Error(), "signed manifest") {
+		t.Fatalf("expected manifest mismatch error, got %v", err)
+	}
+	if ran {
+		t.Fatal("expected dbx-upgrade not to run for an unverified release")
+	}
+	if entries, _ := os.ReadDir(updateTmpDir); len(entries) != 0 {
+		t.Fatalf("expected the unverified release to be removed, found %v", entries)
+	}
+
+	// Without a manifest we don't even clone the release.
+	cloned := false
+	noManifest := func(version string) (ReleaseManifest, error) {
+		return ReleaseManifest{}, errors.New("OS release v1.2.0 has no signed manifest")
+	}
+	cloneSpy := func(destination, version string) error {
+		cloned = true
+		return cloneFunc(destination, version)
+	}
+	err = doSystemUpdateWithDependencies("os", "v1.2.0", t.TempDir(), nil, noManifest, cloneSpy, execCommand, nil)
+	if err == nil || !strings.Contains(err.Error(), "no signed manifest") {
+		t.Fatalf("expected missing manifest error, got %v", err)
+	}
+	if cloned || ran {
+		t.Fatal("expected nothing to be staged or run without a signed manifest")
+	}
+}
+
+// signedManifestFor is a getManifest that vouches for whatever cloneFunc
+// stages.
+func signedManifestFor(t *testing.T, cloneFunc func(string, string) error) func(string) (ReleaseManifest, error) {
+	return func(version string) (ReleaseManifest, error) {
+		return ReleaseManifest{Version: version, Rev: stagedCommitHash(t, cloneFunc, version)}, nil
+	}
+}
+
 func createTestReleaseRepo(t *testing.T, destination string, version string) error {
 	t.Helper()
 
@@ -841,3 +952,65 @@ func TestPreReleaseFiltering(t *testing.T) {
 		}
 	}
 }
+
+func TestUpgradeCommitKeepsPinnedPupsPinned(t *testing.T) {
+	commit := "0123456789abcdef0123456789abcdef01234567"
+	pinned := dogeboxd.PupState{Source: dogeboxd.ManifestSourceConfiguration{Commit: commit}}
+
+	if _, err := upgradeCommit(dogeboxd.UpgradePup{TargetVersion: "2.0.0"}, pinned); err == nil {
+		t.Fatal("expected a pinned pup to need a commit to upgrade to")
+	}
+	got, err := upgradeCommit(dogeboxd.UpgradePup{TargetVersion: "2.0.0", Commit: commit}, pinned)
+	if err != nil || got != commit {
+		t.Fatalf("expected commit %s, got %q, %v", commit, got, err)
+	}
+	got, err = upgradeCommit(dogeboxd.UpgradePup{TargetVersion: "2.0.0"}, dogeboxd.PupState{})
+	if err != nil || got != "" {
+		t.Fatalf("expected no commit, got %q, %v", got, err)
+	}
+	if _, err := upgradeCommit(dogeboxd.UpgradePup{Commit: "abc"}, pinned); err == nil {
+		t.Fatal("expected a short commit to be refused")
+	}
+}
+
+type upgradeSourceManager struct {
+	dogeboxd.SourceManager
+	sources map[string]dogeboxd.ManifestSourceConfiguration
+}
+
+type configuredSource struct {
+	dogeboxd.ManifestSource
+	config dogeboxd.ManifestSourceConfiguration
+}
+
+func (s configuredSource) Config() dogeboxd.ManifestSourceConfiguration { return s.config }
+
+func (m upgradeSourceManager) GetSource(id string) (dogeboxd.ManifestSource, error) {
+	c, ok := m.sources[id]
+	if !ok {
+		return nil, fmt.Errorf("no source found with id %s", id)
+	}
+	return configuredSource{config: c}, nil
+}
+
+func TestCheckUpgradeSourceStaysWithTheInstallSource(t *testing.T) {
+	core := dogeboxd.ManifestSourceConfiguration{ID: "core", Location: "https://example.com/core.git"}
+	updater := SystemUpdater{sources: upgradeSourceManager{sources: map[string]dogeboxd.ManifestSourceConfiguration{
+		"core":  core,
+		"other": {ID: "other", Location: "https://example.com/other.git"},
+	}}}
+	pup := dogeboxd.PupState{Source: core}
+
+	if err := updater.checkUpgradeSource(dogeboxd.UpgradePup{SourceId: "core"}, pup); err != nil {
+		t.Fatalf("expected an upgrade from the install source, got %v", err)
+	}
+	if err := updater.checkUpgradeSource(dogeboxd.UpgradePup{SourceId: "other"}, pup); err == nil {
+		t.Fatal("expected an upgrade from another source to be refused")
+	}
+
+	// The source was removed and another added under the same ID.
+	pup.Source.Location = "https://example.com/old.git"
+	if err := updater.checkUpgradeSource(dogeboxd.UpgradePup{SourceId: "core"}, pup); err == nil {
+		t.Fatal("expected an upgrade from a moved source to be refused")
+	}
+}
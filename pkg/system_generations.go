@@ -0,0 +1,24 @@
+package dogeboxd
+
+import "fmt"
+
+/* RollbackSystem switches the box back to an earlier generation of the
+ * system profile, ie: after a custom.nix edit or an update that broke
+ * something, without needing SSH.
+ *
+ * Only the running system is rolled back. The nix config in /etc/nixos
+ * is left as it is, so the next rebuild builds whatever broke again
+ * unless it's fixed first, ie: by restoring a config backup.
+ */
+func ValidateSystemRollback(generations []SystemGeneration, number int) (SystemGeneration, error) {
+	for _, g := range generations {
+		if g.Number != number {
+			continue
+		}
+		if g.Current {
+			return g, fmt.Errorf("generation %d is already the current one", number)
+		}
+		return g, nil
+	}
+	return SystemGeneration{}, fmt.Errorf("generation %d doesn't exist, or has been garbage collected", number)
+}
@@ -0,0 +1,25 @@
+package dogeboxd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateSystemRollback(t *testing.T) {
+	generations := []SystemGeneration{
+		{Number: 40},
+		{Number: 41},
+		{Number: 42, Current: true, Running: true},
+	}
+
+	g, err := ValidateSystemRollback(generations, 41)
+	require.NoError(t, err)
+	assert.Equal(t, 41, g.Number)
+
+	_, err = ValidateSystemRollback(generations, 42)
+	assert.Error(t, err, "can't roll back to the current generation")
+	_, err = ValidateSystemRollback(generations, 7)
+	assert.Error(t, err, "collected generations are gone")
+}
@@ -0,0 +1,82 @@
+package dogeboxd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Lists the system pups an OS release ships with, at the top of its flake.
+const SystemPupsFileName = "system-pups.json"
+
+var ErrSystemPup = errors.New("system pups are managed by OS updates")
+
+/* A SystemPupPin is a pup an OS release installs, at the version it was
+ * released with. System pups go through the usual install and upgrade
+ * pipeline, but only as part of a SystemUpdate, and can't be uninstalled
+ * or upgraded by hand, see PupState.SystemManaged.
+ *
+ * The pup's manifest has to agree, by setting meta.system, so a release
+ * can't turn an ordinary pup into one the user can't remove.
+ */
+type SystemPupPin struct {
+	// The location of the source the pup is installed from, it's added
+	// if this box doesn't have it yet.
+	Source  string `json:"source"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+func (p SystemPupPin) Validate() error {
+	if p.Source == "" {
+		return fmt.Errorf("system pup %q has no source", p.Name)
+	}
+	if p.Name == "" {
+		return errors.New("system pup has no name")
+	}
+	if p.Version == "" {
+		return fmt.Errorf("system pup %q has no version", p.Name)
+	}
+	return nil
+}
+
+// ReadSystemPupRelease reads the system pups of a staged OS release, a
+// release without any has no SystemPupsFileName.
+func ReadSystemPupRelease(releaseDir string) ([]SystemPupPin, error) {
+	data, err := os.ReadFile(filepath.Join(releaseDir, SystemPupsFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var pins []SystemPupPin
+	if err := json.Unmarshal(data, &pins); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", SystemPupsFileName, err)
+	}
+	seen := map[string]bool{}
+	for _, pin := range pins {
+		if err := pin.Validate(); err != nil {
+			return nil, err
+		}
+		if seen[pin.Name] {
+			return nil, fmt.Errorf("system pup %q is listed twice", pin.Name)
+		}
+		seen[pin.Name] = true
+	}
+	return pins, nil
+}
+
+// FindSystemPup finds the installed pup for a pin, there's only ever one
+// instance of a system pup.
+func FindSystemPup(states map[string]PupState, pin SystemPupPin) (PupState, bool) {
+	for _, s := range states {
+		if s.Manifest.Meta.Name == pin.Name && s.Source.Location == pin.Source && s.InstanceName == "" {
+			return s, true
+		}
+	}
+	return PupState{}, false
+}
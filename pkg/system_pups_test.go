@@ -0,0 +1,57 @@
+package dogeboxd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadSystemPupRelease(t *testing.T) {
+	dir := t.TempDir()
+
+	pins, err := ReadSystemPupRelease(dir)
+	require.NoError(t, err)
+	assert.Empty(t, pins)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, SystemPupsFileName), []byte(`[
+		{"source": "https://github.com/dogeorg/pups.git", "name": "Dogecoin Core", "version": "1.14.9"}
+	]`), 0644))
+	pins, err = ReadSystemPupRelease(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []SystemPupPin{{Source: "https://github.com/dogeorg/pups.git", Name: "Dogecoin Core", Version: "1.14.9"}}, pins)
+}
+
+func TestReadSystemPupReleaseRejectsBadPins(t *testing.T) {
+	for name, content := range map[string]string{
+		"no version": `[{"source": "https://example.com/pups.git", "name": "Core"}]`,
+		"no source":  `[{"name": "Core", "version": "1.0.0"}]`,
+		"duplicate":  `[{"source": "a", "name": "Core", "version": "1.0.0"}, {"source": "b", "name": "Core", "version": "1.0.0"}]`,
+		"not json":   `nope`,
+	} {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, SystemPupsFileName), []byte(content), 0644))
+		_, err := ReadSystemPupRelease(dir)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestFindSystemPup(t *testing.T) {
+	official := ManifestSourceConfiguration{Location: "https://github.com/dogeorg/pups.git"}
+	fork := ManifestSourceConfiguration{Location: "https://example.com/pups.git"}
+	core := PupManifest{Meta: PupManifestMeta{Name: "Dogecoin Core"}}
+	states := map[string]PupState{
+		"fork":     {ID: "fork", Source: fork, Manifest: core},
+		"instance": {ID: "instance", Source: official, Manifest: core, InstanceName: "Testnet"},
+		"core":     {ID: "core", Source: official, Manifest: core},
+	}
+
+	s, ok := FindSystemPup(states, SystemPupPin{Source: official.Location, Name: "Dogecoin Core", Version: "1.0.0"})
+	require.True(t, ok)
+	assert.Equal(t, "core", s.ID)
+
+	_, ok = FindSystemPup(states, SystemPupPin{Source: official.Location, Name: "Indexer", Version: "1.0.0"})
+	assert.False(t, ok)
+}
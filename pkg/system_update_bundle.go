@@ -0,0 +1,229 @@
+package dogeboxd
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/semver"
+)
+
+// Bumped when a bundle's layout changes in a way older boxes can't read.
+const SYSTEM_UPDATE_BUNDLE_FORMAT_VERSION = 1
+
+// Offline update bundles are gzipped tarballs with this extension, so
+// they can be picked out of a USB drive.
+const SYSTEM_UPDATE_BUNDLE_EXT = ".dbxupdate"
+
+// Signs the foundation OS binary cache.
+const FOUNDATION_OS_BINARY_CACHE_KEY = "dbx.nix.dogecoin.org:ODXaHC+9DNqXQ8ZTijaCT4JpieqmOatZeZBbdN51Obc="
+
+// Signs OS releases, see RELEASE_SIGNING_KEYS. The foundation uses its
+// binary cache key for now, it's named apart so it can be rotated on its
+// own.
+const FOUNDATION_RELEASE_SIGNING_KEY = FOUNDATION_OS_BINARY_CACHE_KEY
+
+/* Only these may sign OS releases: their release manifests and offline
+ * update bundles. Unlike the binary cache keys nix trusts, there's
+ * deliberately no way to add to them at runtime, so a cache the user
+ * adds can provide store paths but never choose the system we run.
+ */
+var RELEASE_SIGNING_KEYS = []string{FOUNDATION_RELEASE_SIGNING_KEY}
+
+/* What's in a bundle's tarball. The manifest and its signature must be
+ * the first two entries, so a bundle is verified before anything else in
+ * it is unpacked. Everything after is either part of the OS release
+ * flake or a nix binary cache (ie: from `nix copy --to file://...`)
+ * holding the store paths the release needs.
+ */
+const (
+	SystemUpdateBundleManifestName  = "update.json"
+	SystemUpdateBundleSignatureName = "update.json.sig"
+	SystemUpdateBundleFlakeDir      = "flake"
+	SystemUpdateBundleStoreDir      = "store"
+)
+
+var (
+	bundleStorePathRegex = regexp.MustCompile(`^/nix/store/[0-9a-z]{32}-[A-Za-z0-9+._?=-]+$`)
+	bundleFileHashRegex  = regexp.MustCompile(`^[a-f0-9]{64}$`)
+	bundleRevRegex       = regexp.MustCompile(`^[a-f0-9]{7,40}$`)
+)
+
+/* A SystemUpdateBundle is the signed manifest of an offline OS update,
+ * see SystemUpdateFromFile. Files pins the sha256 of every other file
+ * in the bundle, so the signature covers the whole release: the flake
+ * and its flake.lock (which pins each package's rev), and the store
+ * export, if there is one.
+ */
+type SystemUpdateBundle struct {
+	FormatVersion int       `json:"formatVersion"`
+	Created       time.Time `json:"created"`
+	// The OS release this bundle switches to, ie: v0.9.1
+	Version string `json:"version"`
+	// Optional. The os repository commit the flake was taken from.
+	Rev string `json:"rev,omitempty"`
+	// The nix system the store export was built for, ie: aarch64-linux
+	System string `json:"system"`
+	// The release's closure, imported from the store export before
+	// switching. Empty if the bundle has no store export.
+	StorePaths []string `json:"storePaths,omitempty"`
+	// Path within the bundle to hex sha256.
+	Files map[string]string `json:"files"`
+}
+
+func (b SystemUpdateBundle) Validate() error {
+	if b.FormatVersion < 1 || b.FormatVersion > SYSTEM_UPDATE_BUNDLE_FORMAT_VERSION {
+		return fmt.Errorf("unsupported system update bundle format %d", b.FormatVersion)
+	}
+	if !semver.IsValid(b.Version) {
+		return fmt.Errorf("system update bundle has invalid version %q", b.Version)
+	}
+	if b.Rev != "" && !bundleRevRegex.MatchString(b.Rev) {
+		return fmt.Errorf("system update bundle has invalid rev %q", b.Rev)
+	}
+	if b.System != CurrentNixSystem() {
+		return fmt.Errorf("system update bundle is for %s, this Dogebox is %s", b.System, CurrentNixSystem())
+	}
+
+	hasStore := false
+	for name, hash := range b.Files {
+		if err := validateBundleFileName(name); err != nil {
+			return err
+		}
+		if !bundleFileHashRegex.MatchString(hash) {
+			return fmt.Errorf("system update bundle has invalid hash for %s", name)
+		}
+		if strings.HasPrefix(name, SystemUpdateBundleStoreDir+"/") {
+			hasStore = true
+		}
+	}
+	for _, required := range []string{"flake.nix", "flake.lock"} {
+		if _, ok := b.Files[path.Join(SystemUpdateBundleFlakeDir, required)]; !ok {
+			return fmt.Errorf("system update bundle is missing %s", required)
+		}
+	}
+
+	for _, p := range b.StorePaths {
+		if !bundleStorePathRegex.MatchString(p) {
+			return fmt.Errorf("system update bundle has invalid store path %q", p)
+		}
+	}
+	if len(b.StorePaths) > 0 && !hasStore {
+		return errors.New("system update bundle lists store paths but has no store export")
+	}
+	return nil
+}
+
+// Bundle files must be clean relative paths under the flake or store
+// directories, anything else could be unpacked outside of them.
+func validateBundleFileName(name string) error {
+	if name == "" || path.IsAbs(name) || path.Clean(name) != name || strings.HasPrefix(name, "../") || !isNixSafe(name) {
+		return fmt.Errorf("system update bundle has invalid file %q", name)
+	}
+	dir, _, _ := strings.Cut(name, "/")
+	if name == dir || (dir != SystemUpdateBundleFlakeDir && dir != SystemUpdateBundleStoreDir) {
+		return fmt.Errorf("system update bundle has unexpected file %q", name)
+	}
+	return nil
+}
+
+// ParseSystemUpdateBundle reads and validates a bundle's manifest, which
+// should have been verified with VerifySystemUpdateBundle first.
+func ParseSystemUpdateBundle(manifest []byte) (SystemUpdateBundle, error) {
+	var b SystemUpdateBundle
+	if err := json.Unmarshal(manifest, &b); err != nil {
+		return b, fmt.Errorf("failed to read system update bundle manifest: %w", err)
+	}
+	return b, b.Validate()
+}
+
+/* VerifySystemUpdateBundle checks a bundle manifest's signature against
+ * trustedKeys, returning the name of the key that signed it. Keys and
+ * signatures are in nix's format, ie: "name:base64", so a bundle is
+ * signed with the same key as the binary cache it was built for.
+ */
+func VerifySystemUpdateBundle(manifest []byte, signature string, trustedKeys []string) (string, error) {
+	keyName, err := VerifyNixSignature(manifest, signature, trustedKeys)
+	if err != nil {
+		return "", fmt.Errorf("system update bundle %w", err)
+	}
+	return keyName, nil
+}
+
+// VerifyNixSignature checks an ed25519 signature over data, with keys and
+// signature in nix's "name:base64" format, returning the signing key's name.
+func VerifyNixSignature(data []byte, signature string, trustedKeys []string) (string, error) {
+	sigName, sigData, ok := strings.Cut(strings.TrimSpace(signature), ":")
+	if !ok || sigName == "" {
+		return "", errors.New("signature is malformed")
+	}
+	sig, err := base64.StdEncoding.DecodeString(sigData)
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return "", errors.New("signature is malformed")
+	}
+
+	for _, key := range trustedKeys {
+		keyName, keyData, ok := strings.Cut(key, ":")
+		if !ok || keyName != sigName {
+			continue
+		}
+		pub, err := base64.StdEncoding.DecodeString(keyData)
+		if err != nil || len(pub) != ed25519.PublicKeySize {
+			continue
+		}
+		if ed25519.Verify(ed25519.PublicKey(pub), data, sig) {
+			return keyName, nil
+		}
+	}
+	return "", fmt.Errorf("isn't signed by a trusted key (signed by %s)", sigName)
+}
+
+// The largest bundle that can be uploaded, see SystemUpdateBundleDir.
+const MaxSystemUpdateBundleSize = 16 << 30
+
+// Uploaded bundles wait here until they're applied.
+func (c ServerConfig) SystemUpdateBundleDir() string {
+	return filepath.Join(c.DataDir, "system-updates")
+}
+
+func (c ServerConfig) SystemUpdateBundlePath(bundleID string) string {
+	return filepath.Join(c.SystemUpdateBundleDir(), bundleID+SYSTEM_UPDATE_BUNDLE_EXT)
+}
+
+// NewSystemUpdateBundleID makes an ID for an uploaded bundle.
+func NewSystemUpdateBundleID() (string, error) {
+	return newID(16)
+}
+
+// Where removable drives are mounted, see FindSystemUpdateBundles.
+var SystemUpdateBundleMediaRoots = []string{"/media", "/mnt", "/run/media"}
+
+/* FindSystemUpdateBundles lists the bundles at the top of each mounted
+ * drive under roots, ie: /media/usb/dogebox-v0.9.1.dbxupdate. Drives may
+ * be mounted a level deeper, per user, as under /run/media.
+ */
+func FindSystemUpdateBundles(roots []string) []string {
+	found := []string{}
+	for _, root := range roots {
+		for _, pattern := range []string{"*", "*/*", "*/*/*"} {
+			matches, err := filepath.Glob(filepath.Join(root, pattern+SYSTEM_UPDATE_BUNDLE_EXT))
+			if err != nil {
+				continue
+			}
+			for _, match := range matches {
+				if info, err := os.Stat(match); err == nil && info.Mode().IsRegular() {
+					found = append(found, match)
+				}
+			}
+		}
+	}
+	return found
+}
@@ -0,0 +1,114 @@
+package dogeboxd
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testBundle() SystemUpdateBundle {
+	return SystemUpdateBundle{
+		FormatVersion: SYSTEM_UPDATE_BUNDLE_FORMAT_VERSION,
+		Version:       "v0.9.1",
+		System:        CurrentNixSystem(),
+		StorePaths:    []string{"/nix/store/0123456789abcdfghijklmnpqrsvwxyz-dogeboxd-0.9.1"},
+		Files: map[string]string{
+			"flake/flake.nix":       strings.Repeat("a", 64),
+			"flake/flake.lock":      strings.Repeat("b", 64),
+			"store/nix-cache-info":  strings.Repeat("c", 64),
+			"store/nar/abc.nar.xz":  strings.Repeat("d", 64),
+			"flake/modules/dbx.nix": strings.Repeat("e", 64),
+		},
+	}
+}
+
+func TestSystemUpdateBundleValidate(t *testing.T) {
+	assert.NoError(t, testBundle().Validate())
+
+	b := testBundle()
+	b.Version = "latest"
+	assert.Error(t, b.Validate())
+
+	b = testBundle()
+	b.System = "riscv64-linux"
+	assert.ErrorContains(t, b.Validate(), "riscv64-linux")
+
+	b = testBundle()
+	delete(b.Files, "flake/flake.lock")
+	assert.ErrorContains(t, b.Validate(), "flake.lock")
+
+	for _, name := range []string{"../etc/shadow", "/etc/shadow", "flake/../../x", "flake", "other/file", "flake/$x"} {
+		b = testBundle()
+		b.Files[name] = strings.Repeat("f", 64)
+		assert.Error(t, b.Validate(), name)
+	}
+
+	b = testBundle()
+	b.Files["flake/flake.nix"] = "not-a-hash"
+	assert.Error(t, b.Validate())
+
+	b = testBundle()
+	b.StorePaths = []string{"/etc/passwd"}
+	assert.Error(t, b.Validate())
+
+	b = testBundle()
+	delete(b.Files, "store/nix-cache-info")
+	delete(b.Files, "store/nar/abc.nar.xz")
+	assert.ErrorContains(t, b.Validate(), "no store export")
+}
+
+func TestVerifySystemUpdateBundle(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	key := "test-1:" + base64.StdEncoding.EncodeToString(pub)
+	manifest := []byte(`{"version":"v0.9.1"}`)
+	signature := "test-1:" + base64.StdEncoding.EncodeToString(ed25519.Sign(priv, manifest))
+
+	signedBy, err := VerifySystemUpdateBundle(manifest, signature+"\n", []string{FOUNDATION_OS_BINARY_CACHE_KEY, key})
+	require.NoError(t, err)
+	assert.Equal(t, "test-1", signedBy)
+
+	_, err = VerifySystemUpdateBundle([]byte(`{"version":"v9.9.9"}`), signature, []string{key})
+	assert.ErrorContains(t, err, "trusted key")
+
+	_, err = VerifySystemUpdateBundle(manifest, signature, []string{FOUNDATION_OS_BINARY_CACHE_KEY})
+	assert.ErrorContains(t, err, "trusted key")
+
+	_, err = VerifySystemUpdateBundle(manifest, "not a signature", []string{key})
+	assert.ErrorContains(t, err, "malformed")
+}
+
+func TestTrustedPupKeys(t *testing.T) {
+	keys := TrustedPupKeys(DogeboxState{BinaryCaches: []DogeboxStateBinaryCache{
+		{Host: "https://cache.example.com", Key: "cache.example.com:abc="},
+		{Host: "https://nokey.example.com"},
+	}})
+	assert.Equal(t, []string{FOUNDATION_OS_BINARY_CACHE_KEY, "cache.example.com:abc="}, keys)
+}
+
+func TestFindSystemUpdateBundles(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{
+		"usb/dogebox-v0.9.1.dbxupdate",
+		"user/usb/dogebox-v0.9.2.dbxupdate",
+		"usb/notes.txt",
+	} {
+		path := filepath.Join(root, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte("x"), 0644))
+	}
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "usb", "dir.dbxupdate"), 0755))
+
+	found := FindSystemUpdateBundles([]string{root, filepath.Join(root, "missing")})
+	assert.ElementsMatch(t, []string{
+		filepath.Join(root, "usb/dogebox-v0.9.1.dbxupdate"),
+		filepath.Join(root, "user/usb/dogebox-v0.9.2.dbxupdate"),
+	}, found)
+}
@@ -0,0 +1,195 @@
+// Package tarball unpacks tar archives we don't trust, ie: uploads, pup
+// sources and exports from other Dogeboxes, without anything in them
+// being written outside the directory they're unpacked into.
+package tarball
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// Options changes what Extract unpacks and how. The zero value unpacks
+// plain files and directories, owned by the current user.
+type Options struct {
+	// Name maps an entry's cleaned, slash separated name to where it's
+	// unpacked under dest, or "" to skip it. Entries are unpacked at
+	// their own name by default.
+	Name func(header *tar.Header, name string) (string, error)
+	// Copy writes a file's contents, ie: to check them against a hash.
+	Copy func(name string, w io.Writer, r io.Reader) error
+	// Symlinks are created, once everything else is in place, rather
+	// than rejected. Their targets aren't checked, they're only ever
+	// followed inside a pup's container.
+	Symlinks bool
+	// SkipOther skips devices, fifos and hard links rather than
+	// rejecting the archive.
+	SkipOther bool
+	// Chown gives everything unpacked to Uid and Gid.
+	Chown    bool
+	Uid, Gid int
+}
+
+/* Extract unpacks the rest of tr into dest, which it creates. Names must
+ * be local (see filepath.IsLocal), nothing is written through a symlink,
+ * and only permission bits are kept, so setuid and setgid are dropped.
+ */
+func Extract(tr *tar.Reader, dest string, opts Options) error {
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return err
+	}
+	root, err := filepath.EvalSymlinks(dest)
+	if err != nil {
+		return err
+	}
+
+	links := []*tar.Header{}
+	targets := map[*tar.Header]string{}
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		name, err := entryName(header, opts)
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			continue
+		}
+		target := filepath.Join(root, filepath.FromSlash(name))
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := mkdirs(root, target, header.FileInfo().Mode().Perm()|0700, opts); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := mkdirs(root, filepath.Dir(target), 0755, opts); err != nil {
+				return err
+			}
+			if err := writeFile(target, name, tr, header.FileInfo().Mode().Perm(), opts); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if !opts.Symlinks {
+				return fmt.Errorf("entry %s isn't a file or directory", header.Name)
+			}
+			links = append(links, header)
+			targets[header] = target
+			continue
+		default:
+			if opts.SkipOther {
+				continue
+			}
+			return fmt.Errorf("entry %s isn't a file or directory", header.Name)
+		}
+		if err := chown(target, opts); err != nil {
+			return err
+		}
+	}
+
+	for _, header := range links {
+		target := targets[header]
+		if err := mkdirs(root, filepath.Dir(target), 0755, opts); err != nil {
+			return err
+		}
+		if err := os.Symlink(header.Linkname, target); err != nil {
+			return err
+		}
+		if err := chown(target, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func entryName(header *tar.Header, opts Options) (string, error) {
+	name := strings.TrimPrefix(header.Name, "./")
+	if name == "" || path.Clean(name) == "." {
+		return "", nil
+	}
+	if !filepath.IsLocal(filepath.FromSlash(name)) {
+		return "", fmt.Errorf("entry %s is outside the archive", header.Name)
+	}
+	name = path.Clean(name)
+
+	if opts.Name == nil {
+		return name, nil
+	}
+	mapped, err := opts.Name(header, name)
+	if err != nil || mapped == "" {
+		return "", err
+	}
+	if !filepath.IsLocal(filepath.FromSlash(mapped)) {
+		return "", fmt.Errorf("entry %s is outside the archive", header.Name)
+	}
+	return path.Clean(mapped), nil
+}
+
+// mkdirs creates dir under root, refusing to go through a symlink, which
+// an earlier entry or whatever was already in root could have made.
+func mkdirs(root string, dir string, perm os.FileMode, opts Options) error {
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		return err
+	}
+	if rel == "." {
+		return nil
+	}
+
+	current := root
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		current = filepath.Join(current, part)
+		info, err := os.Lstat(current)
+		switch {
+		case os.IsNotExist(err):
+			if err := os.Mkdir(current, perm); err != nil {
+				return err
+			}
+			if err := chown(current, opts); err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		case info.Mode()&os.ModeSymlink != 0:
+			return fmt.Errorf("%s is a symlink", current)
+		case !info.IsDir():
+			return fmt.Errorf("%s isn't a directory", current)
+		}
+	}
+	return nil
+}
+
+func writeFile(target string, name string, r io.Reader, perm os.FileMode, opts Options) error {
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY|syscall.O_NOFOLLOW, perm)
+	if err != nil {
+		return err
+	}
+	if opts.Copy != nil {
+		err = opts.Copy(name, out, r)
+	} else {
+		_, err = io.Copy(out, r)
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func chown(target string, opts Options) error {
+	if !opts.Chown {
+		return nil
+	}
+	return os.Lchown(target, opts.Uid, opts.Gid)
+}
@@ -0,0 +1,112 @@
+package tarball
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type entry struct {
+	header tar.Header
+	body   string
+}
+
+func archive(t *testing.T, entries ...entry) *tar.Reader {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		header := e.header
+		header.Size = int64(len(e.body))
+		if header.Mode == 0 {
+			header.Mode = 0644
+		}
+		require.NoError(t, tw.WriteHeader(&header))
+		_, err := tw.Write([]byte(e.body))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	return tar.NewReader(&buf)
+}
+
+func file(name string, body string) entry {
+	return entry{header: tar.Header{Name: name, Typeflag: tar.TypeReg}, body: body}
+}
+
+func symlink(name string, target string) entry {
+	return entry{header: tar.Header{Name: name, Typeflag: tar.TypeSymlink, Linkname: target}}
+}
+
+func TestExtract(t *testing.T) {
+	dest := filepath.Join(t.TempDir(), "out")
+	tr := archive(t,
+		entry{header: tar.Header{Name: "./dir/", Typeflag: tar.TypeDir, Mode: 0755}},
+		file("./dir/a.txt", "a"),
+		entry{header: tar.Header{Name: "bin/run", Typeflag: tar.TypeReg, Mode: 0o6755}, body: "#!"},
+	)
+	require.NoError(t, Extract(tr, dest, Options{}))
+
+	data, err := os.ReadFile(filepath.Join(dest, "dir", "a.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "a", string(data))
+
+	info, err := os.Stat(filepath.Join(dest, "bin", "run"))
+	require.NoError(t, err)
+	assert.Zero(t, info.Mode()&(os.ModeSetuid|os.ModeSetgid), "setuid and setgid are dropped")
+}
+
+func TestExtractRejectsEscapes(t *testing.T) {
+	for _, name := range []string{"../evil", "/etc/evil", "a/../../evil"} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			err := Extract(archive(t, file(name, "x")), filepath.Join(dir, "out"), Options{})
+			assert.Error(t, err)
+			_, statErr := os.Stat(filepath.Join(dir, "evil"))
+			assert.True(t, os.IsNotExist(statErr))
+		})
+	}
+}
+
+func TestExtractSymlinks(t *testing.T) {
+	dest := t.TempDir()
+	assert.Error(t, Extract(archive(t, symlink("link", "a")), dest, Options{}), "symlinks must be allowed")
+
+	outside := t.TempDir()
+	tr := archive(t, symlink("out", outside), symlink("out/evil", "x"))
+	assert.Error(t, Extract(tr, filepath.Join(t.TempDir(), "a"), Options{Symlinks: true}), "no links through links")
+	_, err := os.Lstat(filepath.Join(outside, "evil"))
+	assert.True(t, os.IsNotExist(err))
+
+	// Nor through ones already there.
+	dest = t.TempDir()
+	require.NoError(t, os.Symlink(outside, filepath.Join(dest, "out")))
+	assert.Error(t, Extract(archive(t, file("out/evil", "x")), dest, Options{}))
+	_, err = os.Stat(filepath.Join(outside, "evil"))
+	assert.True(t, os.IsNotExist(err))
+
+	dest = t.TempDir()
+	require.NoError(t, Extract(archive(t, symlink("link", "a.txt"), file("a.txt", "a")), dest, Options{Symlinks: true}))
+	data, err := os.ReadFile(filepath.Join(dest, "link"))
+	require.NoError(t, err)
+	assert.Equal(t, "a", string(data))
+}
+
+func TestExtractName(t *testing.T) {
+	dest := t.TempDir()
+	opts := Options{Name: func(header *tar.Header, name string) (string, error) {
+		if name == "skip.txt" {
+			return "", nil
+		}
+		return "renamed/" + name, nil
+	}}
+	require.NoError(t, Extract(archive(t, file("skip.txt", "b"), file("a.txt", "a")), dest, opts))
+
+	_, err := os.Stat(filepath.Join(dest, "renamed", "a.txt"))
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(dest, "renamed", "skip.txt"))
+	assert.True(t, os.IsNotExist(err))
+}
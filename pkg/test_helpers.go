@@ -88,6 +88,36 @@ func createTestJob(actionType string) Job {
 		job.A = EnablePup{PupID: "test-pup-id"}
 	case "DisablePup":
 		job.A = DisablePup{PupID: "test-pup-id"}
+	case "StartPup":
+		job.A = StartPup{PupID: "test-pup-id"}
+	case "SetPupAutoStart":
+		job.A = SetPupAutoStart{PupID: "test-pup-id", AutoStart: false}
+	case "SetPupMaintenance":
+		job.A = SetPupMaintenance{PupID: "test-pup-id", Enabled: true, Reason: "Reindexing"}
+	case "SetPupRestartSchedule":
+		job.A = SetPupRestartSchedule{PupID: "test-pup-id", Schedule: "weekly"}
+	case "SetPupResourceLimits":
+		job.A = SetPupResourceLimits{PupID: "test-pup-id", Limits: &PupResourceLimits{CPUPercent: 50}}
+	case "SetPupTrustedCAs":
+		job.A = SetPupTrustedCAs{PupID: "test-pup-id", CAIDs: []string{"test-ca-id"}}
+	case "SetPupStorageQuota":
+		job.A = SetPupStorageQuota{PupID: "test-pup-id", QuotaMB: 2048}
+	case "BulkPupAction":
+		job.A = BulkPupAction{Operation: BULK_PUP_ENABLE, PupIDs: []string{"test-pup-id", "test-pup-id-2"}}
+	case "RestartPup":
+		job.A = RestartPup{PupID: "test-pup-id", ParentJobID: "test-upgrade-job"}
+	case "RebuildDevPup":
+		job.A = RebuildDevPup{PupID: "test-pup-id"}
+	case "SetPupAutoUpdate":
+		job.A = SetPupAutoUpdate{PupID: "test-pup-id", AutoUpdate: &PupAutoUpdate{Policy: AUTO_UPDATE_PATCH}}
+	case "SetPupLogRetention":
+		job.A = SetPupLogRetention{PupID: "test-pup-id", Retention: &PupLogRetention{MaxSizeMB: 100}}
+	case "SetPupLogLevel":
+		job.A = SetPupLogLevel{PupID: "test-pup-id", Debug: true}
+	case "SetPupDevices":
+		job.A = SetPupDevices{PupID: "test-pup-id", Paths: []string{"/dev/dri"}}
+	case "SetPupEnvOverrides":
+		job.A = SetPupEnvOverrides{PupID: "test-pup-id", Env: map[string]string{"EXTRA_FLAGS": "-v"}}
 	case "UpdatePupConfig":
 		job.A = UpdatePupConfig{PupID: "test-pup-id"}
 	case "UpdatePupProviders":
@@ -98,6 +128,8 @@ func createTestJob(actionType string) Job {
 		job.A = UpdatePendingSystemNetwork{}
 	case "EnableSSH":
 		job.A = EnableSSH{}
+	case "EnableSSHTemporary":
+		job.A = EnableSSHTemporary{Hours: 4}
 	case "DisableSSH":
 		job.A = DisableSSH{}
 	case "AddSSHKey":
@@ -108,8 +140,26 @@ func createTestJob(actionType string) Job {
 		job.A = AddBinaryCache{Host: "cache.example.com", Key: "test-key"}
 	case "RemoveBinaryCache":
 		job.A = RemoveBinaryCache{ID: "test-cache-id"}
+	case "RemoveTrustedCA":
+		job.A = RemoveTrustedCA{ID: "test-ca-id"}
+	case "RefreshSource":
+		job.A = RefreshSource{SourceID: "test-source-id"}
 	case "UpdateMetrics":
 		job.A = UpdateMetrics{}
+	case "SystemUpdateFromFile":
+		job.A = SystemUpdateFromFile{Path: "/media/usb/dogebox-v1.0.0.dbxupdate"}
+	case "ReapplySystemVersion":
+		job.A = ReapplySystemVersion{}
+	case "ApplyPendingChanges":
+		job.A = ApplyPendingChanges{}
+	case "DiffPendingChanges":
+		job.A = DiffPendingChanges{}
+	case "CollectNixGarbage":
+		job.A = CollectNixGarbage{KeepGenerations: 3}
+	case "RollbackSystem":
+		job.A = RollbackSystem{Generation: 41}
+	case "ValidateCustomNix":
+		job.A = ValidateCustomNix{Content: "{ ... }: { }"}
 	default:
 		job.A = InstallPup{PupName: "test-app"}
 	}
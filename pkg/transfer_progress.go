@@ -0,0 +1,155 @@
+package dogeboxd
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// How often a TransferProgress logs, at most.
+var transferProgressInterval = time.Second
+
+/* TransferProgress reports a job's progress through copying a known
+ * number of bytes, ie: writing or extracting a backup. The bytes done
+ * are mapped onto the job's progress between from and to, and logged
+ * with an ETA as they change, at most every transferProgressInterval.
+ * With to <= from it only logs, leaving the job's progress alone.
+ */
+type TransferProgress struct {
+	log   SubLogger
+	what  string
+	total int64
+	from  int
+	to    int
+
+	mu       sync.Mutex
+	done     int64
+	start    time.Time
+	lastLog  time.Time
+	lastPct  int
+	finished bool
+	now      func() time.Time
+}
+
+func NewTransferProgress(log SubLogger, what string, total int64, from int, to int) *TransferProgress {
+	p := &TransferProgress{log: log, what: what, total: total, from: from, to: to, lastPct: -1, now: time.Now}
+	p.start = p.now()
+	return p
+}
+
+// Add records n more bytes copied.
+func (p *TransferProgress) Add(n int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.done += n
+	if p.finished {
+		return
+	}
+
+	now := p.now()
+	pct := p.percent()
+	if pct == p.lastPct || now.Sub(p.lastLog) < transferProgressInterval {
+		return
+	}
+	p.lastPct, p.lastLog = pct, now
+
+	msg := fmt.Sprintf("%s: %s of %s (%d%%)", p.what, formatBytes(p.done), formatBytes(p.total), pct)
+	var eta *time.Time
+	if elapsed := now.Sub(p.start); p.done > 0 && p.done < p.total && elapsed > 0 {
+		remaining := time.Duration(float64(elapsed) * float64(p.total-p.done) / float64(p.done))
+		at := now.Add(remaining)
+		eta = &at
+		msg += fmt.Sprintf(", about %s left", remaining.Round(time.Second))
+	}
+	if l, ok := p.log.(etaLogger); ok {
+		l.setETA(eta)
+	}
+	p.logger(p.from + (p.to-p.from)*pct/100).Log(msg)
+}
+
+// Done logs the transfer as finished, at To.
+func (p *TransferProgress) Done() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.finished {
+		return
+	}
+	p.finished = true
+	if l, ok := p.log.(etaLogger); ok {
+		l.setETA(nil)
+	}
+	p.logger(p.to).Logf("%s: %s done in %s", p.what, formatBytes(p.done), p.now().Sub(p.start).Round(time.Millisecond))
+}
+
+// percent is how far through the transfer we are, 0-100.
+func (p *TransferProgress) percent() int {
+	if p.total <= 0 {
+		return 0
+	}
+	done := p.done
+	if done > p.total {
+		done = p.total
+	}
+	return int(100 * done / p.total)
+}
+
+func (p *TransferProgress) logger(progress int) SubLogger {
+	if p.to <= p.from {
+		return p.log
+	}
+	return p.log.Progress(progress)
+}
+
+// Writer counts what's written to w.
+func (p *TransferProgress) Writer(w io.Writer) io.Writer {
+	return &transferWriter{w: w, p: p}
+}
+
+// Reader counts what's read from r.
+func (p *TransferProgress) Reader(r io.Reader) io.Reader {
+	return &transferReader{r: r, p: p}
+}
+
+type transferWriter struct {
+	w io.Writer
+	p *TransferProgress
+}
+
+func (t *transferWriter) Write(b []byte) (int, error) {
+	n, err := t.w.Write(b)
+	t.p.Add(int64(n))
+	return n, err
+}
+
+type transferReader struct {
+	r io.Reader
+	p *TransferProgress
+}
+
+func (t *transferReader) Read(b []byte) (int, error) {
+	n, err := t.r.Read(b)
+	t.p.Add(int64(n))
+	return n, err
+}
+
+// etaLogger is a SubLogger that can pass an ETA on with its progress,
+// see ActionProgress.ETA.
+type etaLogger interface {
+	setETA(eta *time.Time)
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
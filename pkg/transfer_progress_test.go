@@ -0,0 +1,98 @@
+package dogeboxd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingSubLogger struct {
+	progress   int
+	progresses []int
+	msgs       []string
+	etas       []*time.Time
+	eta        *time.Time
+}
+
+func (l *recordingSubLogger) Log(msg string) {
+	l.msgs = append(l.msgs, msg)
+	l.progresses = append(l.progresses, l.progress)
+	l.etas = append(l.etas, l.eta)
+}
+func (l *recordingSubLogger) Logf(msg string, a ...any) { l.Log(fmt.Sprintf(msg, a...)) }
+func (l *recordingSubLogger) Err(msg string)            { l.Log(msg) }
+func (l *recordingSubLogger) Errf(msg string, a ...any) { l.Logf(msg, a...) }
+func (l *recordingSubLogger) Progress(p int) SubLogger  { l.progress = p; return l }
+func (l *recordingSubLogger) LogCmd(cmd *exec.Cmd)      {}
+func (l *recordingSubLogger) OnKill(kill func()) func() { return func() {} }
+func (l *recordingSubLogger) setETA(eta *time.Time)     { l.eta = eta }
+
+func TestTransferProgressMapsBytesOntoJobProgress(t *testing.T) {
+	log := &recordingSubLogger{}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := start
+
+	p := NewTransferProgress(log, "Backing up", 4096, 20, 60)
+	p.now = func() time.Time { return clock }
+	p.start = start
+
+	for i := 0; i < 4; i++ {
+		clock = clock.Add(2 * time.Second)
+		p.Add(1024)
+	}
+	p.Done()
+
+	assert.Equal(t, []int{30, 40, 50, 60, 60}, log.progresses)
+	assert.Equal(t, "Backing up: 1.0 KiB of 4.0 KiB (25%), about 6s left", log.msgs[0])
+	require.NotNil(t, log.etas[0])
+	assert.Equal(t, start.Add(8*time.Second), *log.etas[0], "the first quarter took 2s, so 6s more")
+	assert.Nil(t, log.etas[3], "nothing is left once everything is copied")
+	assert.Nil(t, log.etas[4])
+	assert.True(t, strings.HasPrefix(log.msgs[4], "Backing up: 4.0 KiB done"))
+}
+
+func TestTransferProgressThrottlesLogs(t *testing.T) {
+	log := &recordingSubLogger{}
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	p := NewTransferProgress(log, "Restoring", 100, 0, 100)
+	p.now = func() time.Time { return clock }
+
+	_, err := io.Copy(io.Discard, p.Reader(iotestOneByteReader(bytes.Repeat([]byte("x"), 100))))
+	require.NoError(t, err)
+
+	// The clock doesn't move, so only the first byte is logged.
+	assert.Len(t, log.msgs, 1)
+}
+
+func TestTransferProgressWithoutRangeOnlyLogs(t *testing.T) {
+	log := &recordingSubLogger{progress: 70}
+
+	p := NewTransferProgress(log, "Backing up", 10, 0, 0)
+	p.Add(10)
+	p.Done()
+
+	assert.Equal(t, []int{70, 70}, log.progresses)
+}
+
+func iotestOneByteReader(b []byte) io.Reader {
+	return &oneByteReader{b: b}
+}
+
+type oneByteReader struct{ b []byte }
+
+func (r *oneByteReader) Read(p []byte) (int, error) {
+	if len(r.b) == 0 {
+		return 0, io.EOF
+	}
+	p[0] = r.b[0]
+	r.b = r.b[1:]
+	return 1, nil
+}
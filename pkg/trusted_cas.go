@@ -0,0 +1,110 @@
+package dogeboxd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+/* A TrustedCA is a CA certificate the user has uploaded, for pups that
+ * talk to services signed by a private CA. Pups don't trust it until
+ * they're opted in with PupState.TrustedCAs, then it's added to their
+ * container's trust store.
+ */
+type TrustedCA struct {
+	// The start of the certificate's SHA-256 fingerprint.
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	PEM         string    `json:"pem"`
+	Subject     string    `json:"subject"`
+	Fingerprint string    `json:"fingerprint"`
+	NotAfter    time.Time `json:"notAfter"`
+	AddedAt     time.Time `json:"addedAt"`
+}
+
+// ParseTrustedCA checks pemData is a single CA certificate.
+func ParseTrustedCA(name string, pemData string) (TrustedCA, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return TrustedCA{}, errors.New("name is required")
+	}
+
+	block, rest := pem.Decode([]byte(strings.TrimSpace(pemData)))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return TrustedCA{}, errors.New("expected a PEM encoded certificate")
+	}
+	if len(bytes.TrimSpace(rest)) > 0 {
+		return TrustedCA{}, errors.New("expected a single certificate, add each CA separately")
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return TrustedCA{}, fmt.Errorf("invalid certificate: %w", err)
+	}
+	if !cert.BasicConstraintsValid || !cert.IsCA {
+		return TrustedCA{}, errors.New("certificate is not a CA certificate")
+	}
+
+	sum := sha256.Sum256(cert.Raw)
+	fingerprint := hex.EncodeToString(sum[:])
+	return TrustedCA{
+		ID:          fingerprint[:16],
+		Name:        name,
+		PEM:         string(pem.EncodeToMemory(block)),
+		Subject:     cert.Subject.String(),
+		Fingerprint: fingerprint,
+		NotAfter:    cert.NotAfter,
+		AddedAt:     time.Now(),
+	}, nil
+}
+
+func (s DogeboxState) TrustedCA(id string) (TrustedCA, bool) {
+	for _, ca := range s.TrustedCAs {
+		if ca.ID == id {
+			return ca, true
+		}
+	}
+	return TrustedCA{}, false
+}
+
+// TrustedCAsFor returns the CAs p trusts, skipping any since removed.
+func (s DogeboxState) TrustedCAsFor(p PupState) []TrustedCA {
+	cas := []TrustedCA{}
+	for _, id := range p.TrustedCAs {
+		if ca, ok := s.TrustedCA(id); ok {
+			cas = append(cas, ca)
+		}
+	}
+	return cas
+}
+
+// A TrustedCAUsage is a TrustedCA and the pups trusting it.
+type TrustedCAUsage struct {
+	TrustedCA
+	PupIDs []string `json:"pupIds"`
+}
+
+// TrustedCAInventory lists every TrustedCA with the pups trusting it.
+func TrustedCAInventory(s DogeboxState, pups map[string]PupState) []TrustedCAUsage {
+	inventory := []TrustedCAUsage{}
+	for _, ca := range s.TrustedCAs {
+		usage := TrustedCAUsage{TrustedCA: ca, PupIDs: []string{}}
+		for id, p := range pups {
+			for _, trusted := range p.TrustedCAs {
+				if trusted == ca.ID {
+					usage.PupIDs = append(usage.PupIDs, id)
+				}
+			}
+		}
+		sort.Strings(usage.PupIDs)
+		inventory = append(inventory, usage)
+	}
+	return inventory
+}
@@ -0,0 +1,68 @@
+package dogeboxd
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testCertificatePEM(t *testing.T, isCA bool) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Internal Root"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestParseTrustedCA(t *testing.T) {
+	caPEM := testCertificatePEM(t, true)
+
+	ca, err := ParseTrustedCA(" Office ", "\n"+caPEM)
+	require.NoError(t, err)
+	assert.Equal(t, "Office", ca.Name)
+	assert.Equal(t, "CN=Internal Root", ca.Subject)
+	assert.Len(t, ca.Fingerprint, 64)
+	assert.Equal(t, ca.Fingerprint[:16], ca.ID)
+	assert.Equal(t, caPEM, ca.PEM)
+
+	_, err = ParseTrustedCA("", caPEM)
+	assert.Error(t, err)
+	_, err = ParseTrustedCA("Office", "not a certificate")
+	assert.Error(t, err)
+	_, err = ParseTrustedCA("Office", caPEM+caPEM)
+	assert.Error(t, err, "one CA at a time")
+	_, err = ParseTrustedCA("Leaf", testCertificatePEM(t, false))
+	assert.Error(t, err, "not a CA")
+}
+
+func TestTrustedCAInventory(t *testing.T) {
+	s := DogeboxState{TrustedCAs: []TrustedCA{{ID: "ca1"}, {ID: "ca2"}}}
+	pups := map[string]PupState{
+		"bbb": {ID: "bbb", TrustedCAs: []string{"ca1"}},
+		"aaa": {ID: "aaa", TrustedCAs: []string{"ca1", "gone"}},
+	}
+
+	inventory := TrustedCAInventory(s, pups)
+	require.Len(t, inventory, 2)
+	assert.Equal(t, []string{"aaa", "bbb"}, inventory[0].PupIDs)
+	assert.Equal(t, []string{}, inventory[1].PupIDs)
+
+	assert.Equal(t, []TrustedCA{{ID: "ca1"}}, s.TrustedCAsFor(pups["aaa"]))
+}
@@ -0,0 +1,169 @@
+package dogeboxd
+
+import (
+	"reflect"
+	"sort"
+)
+
+const (
+	CONFIG_FIELD_ADDED   = "added"
+	CONFIG_FIELD_REMOVED = "removed"
+	CONFIG_FIELD_CHANGED = "changed"
+)
+
+/* An UpgradePreview is what upgrading a pup to a version would change,
+ * worked out from the two manifests without touching the pup, so the
+ * user can decide before they commit to it.
+ */
+type UpgradePreview struct {
+	PupID       string `json:"pupId"`
+	FromVersion string `json:"fromVersion"`
+	ToVersion   string `json:"toVersion"`
+	// Config fields the new version adds, removes or redefines.
+	Config []ConfigFieldChange `json:"config"`
+	// Interfaces whose version goes up, and the pups that depend on them.
+	Interfaces []PupInterfaceVersion `json:"interfaces"`
+	// Interfaces the new version no longer provides.
+	RemovedInterfaces []string `json:"removedInterfaces"`
+	// Dependencies the new version adds, which may need installing, or drops.
+	NewDependencies     []PupManifestDependency `json:"newDependencies"`
+	RemovedDependencies []PupManifestDependency `json:"removedDependencies"`
+	// Whether the nix file the pup is built from changes, the hashes are
+	// the same when only the manifest does.
+	NixChanged bool   `json:"nixChanged"`
+	OldNixHash string `json:"oldNixHash"`
+	NewNixHash string `json:"newNixHash"`
+}
+
+// A ConfigFieldChange is a config field added, removed or redefined.
+type ConfigFieldChange struct {
+	Section string                  `json:"section"`
+	Name    string                  `json:"name"`
+	Change  string                  `json:"change"`
+	Old     *PupManifestConfigField `json:"old,omitempty"`
+	New     *PupManifestConfigField `json:"new,omitempty"`
+	// A required field the user will have to fill in before the pup can
+	// start, it has no default and they haven't set it.
+	NeedsValue bool `json:"needsValue,omitempty"`
+	// A removed field the user had set, their value will be ignored.
+	DropsValue bool `json:"dropsValue,omitempty"`
+}
+
+// NewUpgradePreview compares a pup's current manifest to next, the
+// interfaces having been compared with DetectInterfaceChanges, which
+// knows which pups are affected.
+func NewUpgradePreview(s PupState, next PupManifest, interfaces []PupInterfaceVersion) UpgradePreview {
+	current := s.Manifest
+	preview := UpgradePreview{
+		PupID:               s.ID,
+		FromVersion:         current.Meta.Version,
+		ToVersion:           next.Meta.Version,
+		Config:              diffConfigFields(current.Config, next.Config, s.Config),
+		Interfaces:          interfaces,
+		RemovedInterfaces:   []string{},
+		NewDependencies:     []PupManifestDependency{},
+		RemovedDependencies: []PupManifestDependency{},
+		OldNixHash:          current.Container.Build.NixFileSha256,
+		NewNixHash:          next.Container.Build.NixFileSha256,
+	}
+	if preview.Interfaces == nil {
+		preview.Interfaces = []PupInterfaceVersion{}
+	}
+	preview.NixChanged = preview.OldNixHash != preview.NewNixHash || current.Container.Build.NixFile != next.Container.Build.NixFile
+
+	provided := map[string]bool{}
+	for _, iface := range next.Interfaces {
+		provided[iface.Name] = true
+	}
+	for _, iface := range current.Interfaces {
+		if !provided[iface.Name] {
+			preview.RemovedInterfaces = append(preview.RemovedInterfaces, iface.Name)
+		}
+	}
+
+	oldDeps := map[string]bool{}
+	for _, dep := range current.Dependencies {
+		oldDeps[dep.InterfaceName] = true
+	}
+	newDeps := map[string]bool{}
+	for _, dep := range next.Dependencies {
+		newDeps[dep.InterfaceName] = true
+		if !oldDeps[dep.InterfaceName] {
+			preview.NewDependencies = append(preview.NewDependencies, dep)
+		}
+	}
+	for _, dep := range current.Dependencies {
+		if !newDeps[dep.InterfaceName] {
+			preview.RemovedDependencies = append(preview.RemovedDependencies, dep)
+		}
+	}
+
+	return preview
+}
+
+func diffConfigFields(current, next PupManifestConfigFields, values map[string]string) []ConfigFieldChange {
+	type field struct {
+		section string
+		def     PupManifestConfigField
+	}
+	index := func(c PupManifestConfigFields) map[string]field {
+		fields := map[string]field{}
+		for _, section := range c.Sections {
+			for _, f := range section.Fields {
+				fields[f.Name] = field{section: section.Name, def: f}
+			}
+		}
+		return fields
+	}
+	oldFields, newFields := index(current), index(next)
+
+	changes := []ConfigFieldChange{}
+	for name, n := range newFields {
+		def := n.def
+		o, existed := oldFields[name]
+		if !existed {
+			_, set := values[name]
+			changes = append(changes, ConfigFieldChange{
+				Section:    n.section,
+				Name:       name,
+				Change:     CONFIG_FIELD_ADDED,
+				New:        &def,
+				NeedsValue: def.Required && def.Default == nil && !set,
+			})
+			continue
+		}
+		if o.section == n.section && reflect.DeepEqual(o.def, n.def) {
+			continue
+		}
+		old := o.def
+		changes = append(changes, ConfigFieldChange{
+			Section: n.section,
+			Name:    name,
+			Change:  CONFIG_FIELD_CHANGED,
+			Old:     &old,
+			New:     &def,
+		})
+	}
+	for name, o := range oldFields {
+		if _, kept := newFields[name]; kept {
+			continue
+		}
+		old := o.def
+		_, set := values[name]
+		changes = append(changes, ConfigFieldChange{
+			Section:    o.section,
+			Name:       name,
+			Change:     CONFIG_FIELD_REMOVED,
+			Old:        &old,
+			DropsValue: set,
+		})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Section != changes[j].Section {
+			return changes[i].Section < changes[j].Section
+		}
+		return changes[i].Name < changes[j].Name
+	})
+	return changes
+}
@@ -0,0 +1,87 @@
+package dogeboxd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func previewManifest(version string, nixHash string, fields []PupManifestConfigField, ifaces []string, deps []string) PupManifest {
+	m := PupManifest{
+		Meta:      PupManifestMeta{Name: "core", Version: version},
+		Config:    PupManifestConfigFields{Sections: []PupManifestConfigSection{{Name: "main", Fields: fields}}},
+		Container: PupManifestContainer{Build: PupManifestBuild{NixFile: "pup.nix", NixFileSha256: nixHash}},
+	}
+	for _, name := range ifaces {
+		m.Interfaces = append(m.Interfaces, PupManifestInterface{Name: name, Version: "1.0.0"})
+	}
+	for _, name := range deps {
+		m.Dependencies = append(m.Dependencies, PupManifestDependency{InterfaceName: name, InterfaceVersion: "^1"})
+	}
+	return m
+}
+
+func TestNewUpgradePreview(t *testing.T) {
+	current := previewManifest("1.0.0", "aaa",
+		[]PupManifestConfigField{
+			{Name: "rpcPort", Type: "number", Default: 22555},
+			{Name: "legacyFlag", Type: "toggle"},
+			{Name: "label", Type: "text"},
+		},
+		[]string{"core-rpc", "core-zmq"},
+		[]string{"dogenet"},
+	)
+	next := previewManifest("2.0.0", "bbb",
+		[]PupManifestConfigField{
+			{Name: "rpcPort", Type: "number", Default: 22556},
+			{Name: "label", Type: "text"},
+			{Name: "apiKey", Type: "password", Required: true},
+			{Name: "prune", Type: "toggle", Required: true, Default: false},
+		},
+		[]string{"core-rpc"},
+		[]string{"dogenet", "identity"},
+	)
+	s := PupState{ID: "p1", Manifest: current, Config: map[string]string{"legacyFlag": "true"}}
+	interfaces := []PupInterfaceVersion{{InterfaceName: "core-rpc", OldVersion: "1.0.0", NewVersion: "2.0.0", ChangeType: "major", AffectedPups: []string{"p2"}}}
+
+	preview := NewUpgradePreview(s, next, interfaces)
+
+	assert.Equal(t, "1.0.0", preview.FromVersion)
+	assert.Equal(t, "2.0.0", preview.ToVersion)
+	assert.Equal(t, interfaces, preview.Interfaces)
+	assert.Equal(t, []string{"core-zmq"}, preview.RemovedInterfaces)
+	require.Len(t, preview.NewDependencies, 1)
+	assert.Equal(t, "identity", preview.NewDependencies[0].InterfaceName)
+	assert.Empty(t, preview.RemovedDependencies)
+	assert.True(t, preview.NixChanged)
+	assert.Equal(t, "aaa", preview.OldNixHash)
+	assert.Equal(t, "bbb", preview.NewNixHash)
+
+	require.Len(t, preview.Config, 4)
+	byName := map[string]ConfigFieldChange{}
+	for _, c := range preview.Config {
+		byName[c.Name] = c
+	}
+	assert.Equal(t, CONFIG_FIELD_ADDED, byName["apiKey"].Change)
+	assert.True(t, byName["apiKey"].NeedsValue)
+	assert.Equal(t, CONFIG_FIELD_ADDED, byName["prune"].Change)
+	assert.False(t, byName["prune"].NeedsValue)
+	assert.Equal(t, CONFIG_FIELD_REMOVED, byName["legacyFlag"].Change)
+	assert.True(t, byName["legacyFlag"].DropsValue)
+	assert.Equal(t, CONFIG_FIELD_CHANGED, byName["rpcPort"].Change)
+	assert.Equal(t, 22555, byName["rpcPort"].Old.Default)
+	assert.Equal(t, 22556, byName["rpcPort"].New.Default)
+}
+
+func TestNewUpgradePreviewOfSameManifest(t *testing.T) {
+	m := previewManifest("1.0.0", "aaa", []PupManifestConfigField{{Name: "label", Type: "text"}}, []string{"core-rpc"}, []string{"dogenet"})
+
+	preview := NewUpgradePreview(PupState{ID: "p1", Manifest: m}, m, nil)
+
+	assert.Empty(t, preview.Config)
+	assert.Empty(t, preview.Interfaces)
+	assert.Empty(t, preview.RemovedInterfaces)
+	assert.Empty(t, preview.NewDependencies)
+	assert.False(t, preview.NixChanged)
+}
@@ -0,0 +1,379 @@
+package dogeboxd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+var ErrUsageSummaryNotFound = errors.New("usage summary not found")
+
+// What a UsageSummary's pups can be ranked by, see UsageSummary.Leaderboard.
+const (
+	USAGE_METRIC_CPU       = "cpu"
+	USAGE_METRIC_MEMORY    = "memory"
+	USAGE_METRIC_DISK      = "disk"
+	USAGE_METRIC_BANDWIDTH = "bandwidth"
+	USAGE_METRIC_FAILURES  = "failures"
+)
+
+var usageMetrics = []string{USAGE_METRIC_CPU, USAGE_METRIC_MEMORY, USAGE_METRIC_DISK, USAGE_METRIC_BANDWIDTH, USAGE_METRIC_FAILURES}
+
+// How long weekly summaries are kept for.
+const usageSummaryRetention = 365 * 24 * time.Hour
+
+// Gaps between stats samples longer than this, ie: while dogeboxd was
+// down, aren't counted as CPU time.
+const maxUsageSampleGap = 2 * time.Minute
+
+/* A PupUsage is what a pup has used since the PupManager started its
+ * current usage period, accumulated from its stats, see UsageReporter.
+ */
+type PupUsage struct {
+	CPUSeconds   float64 `json:"cpuSeconds"`
+	MemoryPeakMB float64 `json:"memoryPeakMb"`
+	// The pup's storage at its first and latest measurement in the period.
+	DiskStartBytes int64 `json:"diskStartBytes"`
+	DiskEndBytes   int64 `json:"diskEndBytes"`
+	DiskMeasured   bool  `json:"diskMeasured"`
+	// Traffic to and from the pup's container.
+	NetworkInBytes  uint64 `json:"networkInBytes"`
+	NetworkOutBytes uint64 `json:"networkOutBytes"`
+	// The last readings of the container's interface counters, which
+	// reset whenever the container restarts.
+	LastInCounter  uint64 `json:"lastInCounter,omitempty"`
+	LastOutCounter uint64 `json:"lastOutCounter,omitempty"`
+	CountersRead   bool   `json:"countersRead,omitempty"`
+}
+
+// AddProcSample counts a stats sample, cpuPercent being of a single core,
+// taken elapsed after the previous one.
+func (u *PupUsage) AddProcSample(cpuPercent, memMB float64, elapsed time.Duration) {
+	if elapsed > 0 && elapsed <= maxUsageSampleGap {
+		u.CPUSeconds += cpuPercent / 100 * elapsed.Seconds()
+	}
+	if memMB > u.MemoryPeakMB {
+		u.MemoryPeakMB = memMB
+	}
+}
+
+func (u *PupUsage) AddDiskSample(usedBytes int64) {
+	if !u.DiskMeasured {
+		u.DiskStartBytes = usedBytes
+		u.DiskMeasured = true
+	}
+	u.DiskEndBytes = usedBytes
+}
+
+// AddNetworkCounters counts the traffic since the last reading of the
+// container's interface counters, a counter that went backwards having
+// been reset.
+func (u *PupUsage) AddNetworkCounters(in, out uint64) {
+	if u.CountersRead {
+		u.NetworkInBytes += counterDelta(u.LastInCounter, in)
+		u.NetworkOutBytes += counterDelta(u.LastOutCounter, out)
+	}
+	u.LastInCounter, u.LastOutCounter, u.CountersRead = in, out, true
+}
+
+func counterDelta(last, current uint64) uint64 {
+	if current < last {
+		return current
+	}
+	return current - last
+}
+
+// Next returns the usage to carry on from in the next period, which
+// starts from the latest disk measurement and counter readings.
+func (u PupUsage) Next() PupUsage {
+	next := PupUsage{
+		LastInCounter:  u.LastInCounter,
+		LastOutCounter: u.LastOutCounter,
+		CountersRead:   u.CountersRead,
+	}
+	if u.DiskMeasured {
+		next.AddDiskSample(u.DiskEndBytes)
+	}
+	return next
+}
+
+type PupUsageSummary struct {
+	PupID        string  `json:"pupId"`
+	PupName      string  `json:"pupName"`
+	CPUHours     float64 `json:"cpuHours"`
+	MemoryPeakMB float64 `json:"memoryPeakMb"`
+	// Nil if the pup's storage wasn't measured during the period.
+	DiskUsedBytes   *int64 `json:"diskUsedBytes,omitempty"`
+	DiskGrowthBytes int64  `json:"diskGrowthBytes"`
+	NetworkInBytes  uint64 `json:"networkInBytes"`
+	NetworkOutBytes uint64 `json:"networkOutBytes"`
+	JobFailures     int    `json:"jobFailures"`
+}
+
+func (p PupUsageSummary) BandwidthBytes() uint64 {
+	return p.NetworkInBytes + p.NetworkOutBytes
+}
+
+/* A UsageSummary reports what each pup used over a week, and the jobs
+ * that failed, for owners of boxes that are left to run. They're made by
+ * the UsageReporter as each week ends, weeks starting on the user's
+ * DisplayPreferences.FirstDayOfWeek.
+ */
+type UsageSummary struct {
+	// The date the summary's week started on, ie: 2026-10-12.
+	ID      string    `json:"id"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Created time.Time `json:"created"`
+	// Ordered by CPU hours, see Leaderboard for other orders.
+	Pups []PupUsageSummary `json:"pups"`
+	// Every job that failed in the week, including those not for a pup.
+	JobFailures int `json:"jobFailures"`
+}
+
+// NewUsageSummary summarises the pups' usage between start and end, and
+// the jobs that failed in that time.
+func NewUsageSummary(start, end time.Time, usage map[string]PupUsage, states map[string]PupState, jobs []JobRecord, now time.Time) UsageSummary {
+	s := UsageSummary{
+		ID:      start.Format("2006-01-02"),
+		Start:   start,
+		End:     end,
+		Created: now,
+		Pups:    []PupUsageSummary{},
+	}
+
+	failures := map[string]int{}
+	for _, j := range jobs {
+		if j.Status != JobStatusFailed || j.Finished == nil || j.Finished.Before(start) || !j.Finished.Before(end) {
+			continue
+		}
+		s.JobFailures++
+		if j.PupID != "" {
+			failures[j.PupID]++
+		}
+	}
+
+	for id, u := range usage {
+		p := PupUsageSummary{
+			PupID:           id,
+			CPUHours:        u.CPUSeconds / 3600,
+			MemoryPeakMB:    u.MemoryPeakMB,
+			NetworkInBytes:  u.NetworkInBytes,
+			NetworkOutBytes: u.NetworkOutBytes,
+			JobFailures:     failures[id],
+		}
+		if state, ok := states[id]; ok {
+			p.PupName = state.DisplayName()
+		}
+		if u.DiskMeasured {
+			used := u.DiskEndBytes
+			p.DiskUsedBytes = &used
+			p.DiskGrowthBytes = u.DiskEndBytes - u.DiskStartBytes
+		}
+		s.Pups = append(s.Pups, p)
+	}
+
+	s.Pups, _ = s.Leaderboard(USAGE_METRIC_CPU)
+	return s
+}
+
+// Leaderboard returns the summary's pups, heaviest first by metric.
+func (s UsageSummary) Leaderboard(metric string) ([]PupUsageSummary, error) {
+	var less func(a, b PupUsageSummary) bool
+	switch metric {
+	case USAGE_METRIC_CPU:
+		less = func(a, b PupUsageSummary) bool { return a.CPUHours > b.CPUHours }
+	case USAGE_METRIC_MEMORY:
+		less = func(a, b PupUsageSummary) bool { return a.MemoryPeakMB > b.MemoryPeakMB }
+	case USAGE_METRIC_DISK:
+		less = func(a, b PupUsageSummary) bool { return a.DiskGrowthBytes > b.DiskGrowthBytes }
+	case USAGE_METRIC_BANDWIDTH:
+		less = func(a, b PupUsageSummary) bool { return a.BandwidthBytes() > b.BandwidthBytes() }
+	case USAGE_METRIC_FAILURES:
+		less = func(a, b PupUsageSummary) bool { return a.JobFailures > b.JobFailures }
+	default:
+		return nil, fmt.Errorf("unknown usage metric %q, expected one of: %s", metric, strings.Join(usageMetrics, ", "))
+	}
+
+	pups := append([]PupUsageSummary{}, s.Pups...)
+	sort.SliceStable(pups, func(i, j int) bool {
+		if less(pups[i], pups[j]) {
+			return true
+		}
+		if less(pups[j], pups[i]) {
+			return false
+		}
+		return pups[i].PupID < pups[j].PupID
+	})
+	return pups, nil
+}
+
+// Describe writes the summary out as a few lines, for people, ie: in a
+// webhook message.
+func (s UsageSummary) Describe(f DisplayFormat) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Pup usage for %s to %s", f.Date(s.Start), f.Date(s.End))
+	if s.JobFailures == 1 {
+		b.WriteString(", 1 job failed")
+	} else if s.JobFailures > 1 {
+		fmt.Fprintf(&b, ", %d jobs failed", s.JobFailures)
+	}
+
+	for _, p := range s.Pups {
+		name := p.PupName
+		if name == "" {
+			name = p.PupID
+		}
+		fmt.Fprintf(&b, "\n%s: %s CPU hours, %s peak memory, %s network",
+			name, f.Number(p.CPUHours, 1), f.Bytes(int64(p.MemoryPeakMB*1024*1024)), f.Bytes(int64(p.BandwidthBytes())))
+		if p.DiskUsedBytes != nil {
+			fmt.Fprintf(&b, ", %s storage", f.Bytes(*p.DiskUsedBytes))
+		}
+		if p.JobFailures > 0 {
+			fmt.Fprintf(&b, ", %d failed jobs", p.JobFailures)
+		}
+	}
+	return b.String()
+}
+
+/* UsageReporter turns the PupManager's usage into a UsageSummary as
+ * each week ends, storing it and sending it to any webhooks that asked
+ * for them. It checks hourly, so a box that was off when the week ended
+ * reports the weeks it missed as one.
+ */
+type UsageReporter struct {
+	store    *TypeStore[UsageSummary]
+	pups     PupManager
+	jobs     *JobManager
+	webhooks *WebhookNotifier
+	now      func() time.Time
+	format   DisplayFormatSource
+}
+
+func NewUsageReporter(sm *StoreManager, pups PupManager, jobs *JobManager, webhooks *WebhookNotifier) *UsageReporter {
+	return &UsageReporter{
+		store:    GetTypeStore[UsageSummary](sm),
+		pups:     pups,
+		jobs:     jobs,
+		webhooks: webhooks,
+		now:      time.Now,
+	}
+}
+
+func (r *UsageReporter) Run(started, stopped chan bool, stop chan context.Context) error {
+	go func() {
+		go func() {
+			r.tick()
+			ticker := time.NewTicker(time.Hour)
+			defer ticker.Stop()
+		mainloop:
+			for {
+				select {
+				case <-stop:
+					break mainloop
+				case <-ticker.C:
+					r.tick()
+				}
+			}
+		}()
+
+		started <- true
+		<-stop
+		stopped <- true
+	}()
+	return nil
+}
+
+// tick reports the usage so far if its week has ended.
+func (r *UsageReporter) tick() {
+	now := r.now()
+	format := r.format.Get()
+	weekStart := format.WeekStart(now)
+	if _, since := r.pups.GetUsage(); !since.Before(weekStart) {
+		return
+	}
+
+	usage, since := r.pups.RollUsage(weekStart)
+	if since.IsZero() {
+		// Nothing was being tracked yet, there's no week to report.
+		return
+	}
+
+	jobs := []JobRecord{}
+	if r.jobs != nil {
+		all, err := r.jobs.GetAllJobs()
+		if err != nil {
+			fmt.Println("UsageReporter: failed to load jobs:", err)
+		}
+		jobs = all
+	}
+
+	summary := NewUsageSummary(format.WeekStart(since), weekStart, usage, r.pups.GetStateMap(), jobs, now)
+	if err := r.store.Set(summary.ID, summary); err != nil {
+		fmt.Printf("UsageReporter: failed to save summary %s: %v\n", summary.ID, err)
+	}
+	r.prune(now)
+
+	if r.webhooks != nil {
+		r.webhooks.UsageSummary(summary)
+	}
+}
+
+func (r *UsageReporter) prune(now time.Time) {
+	summaries, err := r.List()
+	if err != nil {
+		fmt.Println("UsageReporter: failed to load summaries:", err)
+		return
+	}
+	for _, s := range summaries {
+		if now.Sub(s.End) <= usageSummaryRetention {
+			continue
+		}
+		if err := r.store.Del(s.ID); err != nil {
+			fmt.Printf("UsageReporter: failed to remove summary %s: %v\n", s.ID, err)
+		}
+	}
+}
+
+// List returns the stored summaries, newest first.
+func (r *UsageReporter) List() ([]UsageSummary, error) {
+	query := fmt.Sprintf("SELECT value FROM %s ORDER BY json_extract(value, '$.start') DESC", r.store.Table)
+	summaries, err := r.store.Exec(query)
+	if err != nil {
+		return nil, err
+	}
+	if summaries == nil {
+		summaries = []UsageSummary{}
+	}
+	return summaries, nil
+}
+
+func (r *UsageReporter) Get(id string) (UsageSummary, error) {
+	s, err := r.store.Get(id)
+	if err != nil {
+		return UsageSummary{}, ErrUsageSummaryNotFound
+	}
+	return s, nil
+}
+
+// Current summarises the week so far, it isn't stored.
+func (r *UsageReporter) Current() UsageSummary {
+	now := r.now()
+	usage, since := r.pups.GetUsage()
+	format := r.format.Get()
+	start := format.WeekStart(now)
+	if !since.IsZero() && since.Before(start) {
+		start = format.WeekStart(since)
+	}
+
+	jobs := []JobRecord{}
+	if r.jobs != nil {
+		if all, err := r.jobs.GetAllJobs(); err == nil {
+			jobs = all
+		}
+	}
+	return NewUsageSummary(start, now, usage, r.pups.GetStateMap(), jobs, now)
+}
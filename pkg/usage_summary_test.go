@@ -0,0 +1,159 @@
+package dogeboxd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPupUsageAccumulates(t *testing.T) {
+	var u PupUsage
+	u.AddProcSample(50, 100, 0)
+	u.AddProcSample(50, 300, time.Minute)
+	u.AddProcSample(200, 200, time.Minute)
+	u.AddProcSample(100, 50, time.Hour) // a gap, ie: we were down
+	assert.InDelta(t, 150.0, u.CPUSeconds, 0.001)
+	assert.Equal(t, 300.0, u.MemoryPeakMB)
+
+	u.AddNetworkCounters(1000, 500)
+	u.AddNetworkCounters(1500, 700)
+	u.AddNetworkCounters(200, 100) // the container restarted
+	assert.Equal(t, uint64(700), u.NetworkInBytes)
+	assert.Equal(t, uint64(300), u.NetworkOutBytes)
+
+	u.AddDiskSample(1000)
+	u.AddDiskSample(4000)
+	next := u.Next()
+	assert.Equal(t, PupUsage{
+		DiskStartBytes: 4000,
+		DiskEndBytes:   4000,
+		DiskMeasured:   true,
+		LastInCounter:  200,
+		LastOutCounter: 100,
+		CountersRead:   true,
+	}, next)
+}
+
+func TestWeekStart(t *testing.T) {
+	loc := time.FixedZone("test", 2*60*60)
+	monday := time.Date(2026, 10, 12, 0, 0, 0, 0, loc)
+
+	assert.Equal(t, monday, WeekStart(time.Date(2026, 10, 17, 15, 4, 5, 0, loc), time.Monday))
+	assert.Equal(t, monday, WeekStart(time.Date(2026, 10, 18, 23, 59, 0, 0, loc), time.Monday))
+	assert.Equal(t, monday, WeekStart(monday, time.Monday))
+	assert.Equal(t, monday.AddDate(0, 0, 7), WeekStart(time.Date(2026, 10, 19, 0, 0, 1, 0, loc), time.Monday))
+	assert.Equal(t, monday.AddDate(0, 0, -1), WeekStart(time.Date(2026, 10, 17, 15, 4, 5, 0, loc), time.Sunday))
+	assert.Equal(t, monday.AddDate(0, 0, 6), WeekStart(time.Date(2026, 10, 18, 0, 0, 0, 0, loc), time.Sunday))
+}
+
+func TestNewUsageSummary(t *testing.T) {
+	start := time.Date(2026, 10, 5, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 7)
+	inWeek := start.Add(48 * time.Hour)
+	before := start.Add(-time.Hour)
+
+	usage := map[string]PupUsage{
+		"core":  {CPUSeconds: 7200, MemoryPeakMB: 900, DiskStartBytes: 1000, DiskEndBytes: 5000, DiskMeasured: true},
+		"index": {CPUSeconds: 3600, MemoryPeakMB: 1200, NetworkInBytes: 10, NetworkOutBytes: 20},
+	}
+	states := map[string]PupState{
+		"core": {ID: "core", Manifest: PupManifest{Meta: PupManifestMeta{Name: "Dogecoin Core"}}},
+	}
+	jobs := []JobRecord{
+		{ID: "1", PupID: "index", Status: JobStatusFailed, Finished: &inWeek},
+		{ID: "2", PupID: "index", Status: JobStatusFailed, Finished: &before},
+		{ID: "3", PupID: "core", Status: JobStatusCompleted, Finished: &inWeek},
+		{ID: "4", Status: JobStatusFailed, Finished: &inWeek},
+	}
+
+	s := NewUsageSummary(start, end, usage, states, jobs, end)
+	assert.Equal(t, "2026-10-05", s.ID)
+	assert.Equal(t, 2, s.JobFailures)
+	require.Len(t, s.Pups, 2)
+
+	core := s.Pups[0]
+	assert.Equal(t, "core", core.PupID)
+	assert.Equal(t, "Dogecoin Core", core.PupName)
+	assert.Equal(t, 2.0, core.CPUHours)
+	require.NotNil(t, core.DiskUsedBytes)
+	assert.Equal(t, int64(5000), *core.DiskUsedBytes)
+	assert.Equal(t, int64(4000), core.DiskGrowthBytes)
+
+	index := s.Pups[1]
+	assert.Nil(t, index.DiskUsedBytes)
+	assert.Equal(t, 1, index.JobFailures)
+	assert.Equal(t, uint64(30), index.BandwidthBytes())
+
+	for metric, first := range map[string]string{
+		USAGE_METRIC_CPU:       "core",
+		USAGE_METRIC_MEMORY:    "index",
+		USAGE_METRIC_DISK:      "core",
+		USAGE_METRIC_BANDWIDTH: "index",
+		USAGE_METRIC_FAILURES:  "index",
+	} {
+		pups, err := s.Leaderboard(metric)
+		require.NoError(t, err, metric)
+		assert.Equal(t, first, pups[0].PupID, metric)
+	}
+	_, err := s.Leaderboard("uptime")
+	assert.Error(t, err)
+}
+
+type usagePupManager struct {
+	PupManager
+	usage map[string]PupUsage
+	since time.Time
+}
+
+func (m *usagePupManager) GetUsage() (map[string]PupUsage, time.Time) {
+	return m.usage, m.since
+}
+
+func (m *usagePupManager) RollUsage(start time.Time) (map[string]PupUsage, time.Time) {
+	usage, since := m.usage, m.since
+	m.usage, m.since = map[string]PupUsage{}, start
+	return usage, since
+}
+
+func (m *usagePupManager) GetStateMap() map[string]PupState {
+	return map[string]PupState{}
+}
+
+func TestUsageReporterReportsEndedWeeks(t *testing.T) {
+	sm, err := NewStoreManager(":memory:")
+	require.NoError(t, err)
+
+	since := time.Date(2026, 10, 7, 9, 0, 0, 0, time.UTC)
+	pups := &usagePupManager{usage: map[string]PupUsage{"core": {CPUSeconds: 3600}}, since: since}
+	r := NewUsageReporter(sm, pups, nil, nil)
+	r.format = func() DisplayFormat {
+		return DisplayFormat{Preferences: DisplayPreferences{}.WithDefaults(), Location: time.UTC}
+	}
+
+	// Still the same week, nothing to report.
+	r.now = func() time.Time { return time.Date(2026, 10, 11, 23, 0, 0, 0, time.UTC) }
+	r.tick()
+	summaries, err := r.List()
+	require.NoError(t, err)
+	assert.Empty(t, summaries)
+
+	r.now = func() time.Time { return time.Date(2026, 10, 12, 0, 30, 0, 0, time.UTC) }
+	r.tick()
+	r.tick()
+	summaries, err = r.List()
+	require.NoError(t, err)
+	require.Len(t, summaries, 1)
+	assert.Equal(t, "2026-10-05", summaries[0].ID)
+	assert.Equal(t, time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC), summaries[0].End.UTC())
+	require.Len(t, summaries[0].Pups, 1)
+	assert.Equal(t, 1.0, summaries[0].Pups[0].CPUHours)
+	assert.Equal(t, time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC), pups.since)
+
+	got, err := r.Get("2026-10-05")
+	require.NoError(t, err)
+	assert.Equal(t, summaries[0].ID, got.ID)
+	_, err = r.Get("2026-09-28")
+	assert.ErrorIs(t, err, ErrUsageSummaryNotFound)
+}
@@ -154,5 +154,7 @@ func GetNixSystemTemplateValues(dbxState dogeboxd.DogeboxState) dogeboxd.NixSyst
 		TIMEZONE:          dbxState.Timezone,
 		BINARY_CACHE_SUBS: binaryCacheSubs,
 		BINARY_CACHE_KEYS: binaryCacheKeys,
+
+		WIFI_REGULATORY_DOMAIN: dbxState.WifiRegulatoryDomain,
 	}
 }
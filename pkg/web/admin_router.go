@@ -7,14 +7,16 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"sync/atomic"
 
 	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
 	"github.com/Dogebox-WG/dogeboxd/pkg/conductor"
 )
 
-func NewAdminRouter(config dogeboxd.ServerConfig, pm dogeboxd.PupManager) conductor.Service {
+func NewAdminRouter(config dogeboxd.ServerConfig, dbx dogeboxd.Dogeboxd, pm dogeboxd.PupManager) conductor.Service {
 	return AdminRouter{
 		config: config,
+		dbx:    dbx,
 		pm:     pm,
 		prx:    map[string]*adminProxy{},
 	}
@@ -22,6 +24,7 @@ func NewAdminRouter(config dogeboxd.ServerConfig, pm dogeboxd.PupManager) conduc
 
 type AdminRouter struct {
 	config dogeboxd.ServerConfig
+	dbx    dogeboxd.Dogeboxd
 	pm     dogeboxd.PupManager
 	prx    map[string]*adminProxy
 }
@@ -39,9 +42,13 @@ func (t *AdminRouter) updateProxies() {
 					bindPort: ui.Port,
 					destHost: pup.IP,
 					destPort: ui.Internal,
+					pupID:    pupid,
+					sso:      t.dbx.WebUISSO,
 				}
 				t.prx[id].Start()
 			}
+			// An upgrade can opt the WebUI in or out.
+			t.prx[id].ssoEnabled.Store(pup.WebUISSO(ui.Internal))
 		}
 	}
 	// close any that no longer exist
@@ -92,7 +99,11 @@ type adminProxy struct {
 	bindPort int
 	destHost string
 	destPort int
-	stop     context.CancelFunc
+	pupID    string
+	sso      *dogeboxd.WebUISSO
+	// Whether the pup opted this WebUI in to single sign-on.
+	ssoEnabled atomic.Bool
+	stop       context.CancelFunc
 }
 
 func (t *adminProxy) Start() {
@@ -107,13 +118,13 @@ func (t *adminProxy) Start() {
 		return
 	}
 
+	proxy := httputil.NewSingleHostReverseProxy(proxyURL)
 	httpServer := &http.Server{
 		Addr:    fmt.Sprintf("0.0.0.0:%d", t.bindPort),
-		Handler: httputil.NewSingleHostReverseProxy(proxyURL),
+		Handler: t.ssoHandler(proxy),
 	}
 
 	// Custom Director to set Cache-Control header
-	proxy := httpServer.Handler.(*httputil.ReverseProxy)
 	proxy.Director = func(req *http.Request) {
 		req.URL.Scheme = proxyURL.Scheme
 		req.URL.Host = proxyURL.Host
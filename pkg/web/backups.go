@@ -0,0 +1,67 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+func (t api) listBackups(w http.ResponseWriter, r *http.Request) {
+	backups, err := t.dbx.Backups.List()
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to list backups")
+		return
+	}
+
+	sendResponse(w, map[string]any{"backups": backups})
+}
+
+// getBackup returns a backup along with the files and pups it contains.
+func (t api) getBackup(w http.ResponseWriter, r *http.Request) {
+	backup, err := t.dbx.Backups.Get(r.PathValue("id"))
+	if errors.Is(err, dogeboxd.ErrBackupNotFound) {
+		sendErrorResponse(w, http.StatusNotFound, "Backup not found")
+		return
+	}
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to get backup")
+		return
+	}
+
+	sendResponse(w, map[string]any{"backup": backup})
+}
+
+func (t api) deleteBackup(w http.ResponseWriter, r *http.Request) {
+	backupID := r.PathValue("id")
+	err := t.dbx.Backups.Delete(backupID)
+	if errors.Is(err, dogeboxd.ErrBackupNotFound) {
+		sendErrorResponse(w, http.StatusNotFound, "Backup not found")
+		return
+	}
+	if errors.Is(err, dogeboxd.ErrBackupInUse) {
+		sendErrorResponse(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to delete backup")
+		return
+	}
+
+	sendResponse(w, map[string]any{
+		"success": true,
+		"deleted": backupID,
+	})
+}
+
+// listStateSnapshots lists the automatic snapshots taken before risky
+// jobs, see dogeboxd.StateSnapshot.
+func (t api) listStateSnapshots(w http.ResponseWriter, r *http.Request) {
+	snapshots, err := t.dbx.StateSnapshots.List()
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to list state snapshots")
+		return
+	}
+
+	sendResponse(w, map[string]any{"snapshots": snapshots})
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"sync"
 
 	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
 	"github.com/Masterminds/semver/v3"
@@ -47,23 +48,48 @@ var FoundationPupCollections = map[string][]CollectionPup{
 			SourceId: "dogeorg.pups",
 		},
 	},
-	"custom": {},
+	// Dogecoin Core with a block explorer on top, offered by initial setup.
+	"core-explorer": {
+		{
+			Name:     "Dogecoin Core",
+			Version:  "latest",
+			SourceId: "dogeorg.pups",
+		},
+		{
+			Name:     "Dogecoin Explorer",
+			Version:  "latest",
+			SourceId: "dogeorg.pups",
+		},
+	},
+	"custom":  {},
+	"minimal": {},
 }
 
-// processPupCollections handles the installation of pups for a given collection
-func processPupCollections(sourceManager dogeboxd.SourceManager, dbx dogeboxd.Dogeboxd, sessionToken string, collectionName string) {
+// InitialPupCollections are the collections offered by initial setup, see
+// InitialSystemBootstrapRequestBody.InitialPupCollection.
+var InitialPupCollections = []string{"core", "core-explorer", "minimal"}
+
+// Held while a pending pup collection is being queued, so signing in
+// again meanwhile doesn't queue it twice.
+var installingPendingPupCollection sync.Mutex
+
+// processPupCollections queues installing the pups of a given collection,
+// returning the job ID, or "" if the collection has no pups. It fails if
+// none of the collection's pups can be resolved.
+func processPupCollections(sourceManager dogeboxd.SourceManager, dbx dogeboxd.Dogeboxd, sessionToken string, collectionName string) (string, error) {
 	// Get the list of pups for the selected collection
 	pupsToInstall, exists := FoundationPupCollections[collectionName]
 	if !exists {
-		log.Printf("Unknown collection: %s", collectionName)
-		return
+		return "", fmt.Errorf("unknown collection: %s", collectionName)
+	}
+	if len(pupsToInstall) == 0 {
+		return "", nil
 	}
 
 	// Create a batch installation request
 	allSources, err := sourceManager.GetAll(false)
 	if err != nil {
-		log.Printf("Failed to fetch sources for collection %q: %v", collectionName, err)
-		return
+		return "", fmt.Errorf("failed to fetch sources for collection %q: %w", collectionName, err)
 	}
 
 	installRequests := []dogeboxd.InstallPup{}
@@ -86,12 +112,58 @@ func processPupCollections(sourceManager dogeboxd.SourceManager, dbx dogeboxd.Do
 	}
 
 	if len(installRequests) == 0 {
-		log.Printf("No pups from collection %q resolved successfully", collectionName)
-		return
+		return "", fmt.Errorf("no pups from collection %q resolved successfully", collectionName)
 	}
 
 	// Add the batch installation action
-	dbx.AddAction(dogeboxd.InstallPups(installRequests))
+	return dbx.AddAction(dogeboxd.InstallPups(installRequests)), nil
+}
+
+// installPendingPupCollection queues the collection picked during initial
+// setup. Installing needs a DKM token, which we only have once the user
+// signs in after the post-bootstrap reboot. It's only cleared once queued,
+// so if queueing fails it's tried again at the next sign in.
+func (t api) installPendingPupCollection(sessionToken string) {
+	if t.config.Recovery {
+		return
+	}
+
+	dbxState := t.sm.Get().Dogebox
+	collectionName := dbxState.InitialState.PendingPupCollection
+	if collectionName == "" || !dbxState.InitialState.HasFullyConfigured {
+		return
+	}
+
+	if !installingPendingPupCollection.TryLock() {
+		return
+	}
+
+	// Resolving versions can mean fetching sources, so don't hold up sign in.
+	go func() {
+		defer installingPendingPupCollection.Unlock()
+
+		if _, err := processPupCollections(t.sources, t.dbx, sessionToken, collectionName); err != nil {
+			log.Printf("Failed to install pup collection %q, will retry at next sign in: %v", collectionName, err)
+			t.dbx.SendChange(dogeboxd.Change{
+				ID:     "internal",
+				Type:   "pup-collection-failed",
+				Error:  err.Error(),
+				Update: map[string]string{"collection": collectionName},
+			})
+			return
+		}
+
+		err := t.sm.UpdateDogebox(func(s *dogeboxd.DogeboxState) bool {
+			if s.InitialState.PendingPupCollection != collectionName {
+				return false
+			}
+			s.InitialState.PendingPupCollection = ""
+			return true
+		})
+		if err != nil {
+			log.Printf("Failed to clear pending pup collection %q: %v", collectionName, err)
+		}
+	}()
 }
 
 // resolveVersionConstraint resolves a version constraint against available pups.
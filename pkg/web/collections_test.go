@@ -1,6 +1,7 @@
 package web
 
 import (
+	"errors"
 	"strings"
 	"testing"
 
@@ -76,3 +77,34 @@ func TestResolveVersionConstraint_UnsupportedFormat(t *testing.T) {
 		})
 	}
 }
+
+func TestInitialPupCollectionsExist(t *testing.T) {
+	for _, name := range InitialPupCollections {
+		if _, ok := FoundationPupCollections[name]; !ok {
+			t.Errorf("initial pup collection %q is not a foundation collection", name)
+		}
+	}
+	if len(FoundationPupCollections["minimal"]) != 0 {
+		t.Errorf("minimal collection should install nothing")
+	}
+}
+
+type failingSources struct {
+	dogeboxd.SourceManager
+}
+
+func (failingSources) GetAll(bool) (map[string]dogeboxd.ManifestSourceList, error) {
+	return nil, errors.New("offline")
+}
+
+func TestProcessPupCollectionsFailures(t *testing.T) {
+	if _, err := processPupCollections(failingSources{}, dogeboxd.Dogeboxd{}, "", "core"); err == nil || !strings.Contains(err.Error(), "offline") {
+		t.Fatalf("expected source failure to be returned, got: %v", err)
+	}
+	if _, err := processPupCollections(failingSources{}, dogeboxd.Dogeboxd{}, "", "nonexistent"); err == nil {
+		t.Fatal("expected error for unknown collection")
+	}
+	if id, err := processPupCollections(failingSources{}, dogeboxd.Dogeboxd{}, "", "minimal"); err != nil || id != "" {
+		t.Fatalf("expected nothing queued for minimal collection, got %q, %v", id, err)
+	}
+}
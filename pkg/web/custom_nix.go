@@ -116,3 +116,53 @@ func (t api) validateCustomNix(w http.ResponseWriter, r *http.Request) {
 		Valid: true,
 	})
 }
+
+// dryBuildCustomNix queues a ValidateCustomNix job, which checks the
+// content against the whole system without saving it, unlike
+// validateCustomNix which only checks it parses.
+func (t api) dryBuildCustomNix(w http.ResponseWriter, r *http.Request) {
+	var req ValidateCustomNixRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error decoding request body")
+		return
+	}
+
+	id := t.dbx.AddAction(dogeboxd.ValidateCustomNix{Content: req.Content})
+	sendResponse(w, map[string]string{"id": id})
+}
+
+func (t api) listNixConfigBackups(w http.ResponseWriter, r *http.Request) {
+	backups, err := t.nix.ListConfigBackups()
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to list configuration backups")
+		return
+	}
+
+	sendResponse(w, map[string]any{"backups": backups})
+}
+
+func (t api) restoreNixConfigBackup(w http.ResponseWriter, r *http.Request) {
+	backupID := r.PathValue("id")
+
+	backups, err := t.nix.ListConfigBackups()
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to list configuration backups")
+		return
+	}
+
+	found := false
+	for _, backup := range backups {
+		if backup.ID == backupID {
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		sendErrorResponse(w, http.StatusNotFound, "Configuration backup not found")
+		return
+	}
+
+	id := t.dbx.AddAction(dogeboxd.RestoreNixConfigBackup{BackupID: backupID})
+	sendResponse(w, map[string]string{"id": id})
+}
@@ -0,0 +1,70 @@
+package web
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+type ResolveDeepLinkRequest struct {
+	Link string `json:"link"`
+}
+
+type ResolveDeepLinkResponse struct {
+	dogeboxd.ResolvedPupDeepLink
+	// Add this source first (PUT /source) if it isn't known yet.
+	AddSource string `json:"addSource,omitempty"`
+	// What to send to PUT /pup once the user confirms. sourceId is left
+	// empty when the source still needs adding, as it's only known after.
+	Install InstallPupRequest `json:"install"`
+}
+
+// Resolves a dogebox:// install link into an install the user can confirm.
+func (t api) resolveDeepLink(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error reading request body")
+		return
+	}
+	defer r.Body.Close()
+
+	var req ResolveDeepLinkRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error unmarshalling JSON")
+		return
+	}
+
+	link, err := dogeboxd.ParsePupDeepLink(req.Link)
+	if err != nil {
+		sendAPIError(w, http.StatusBadRequest, dogeboxd.NewAPIError(dogeboxd.ERROR_INVALID_DEEP_LINK, err.Error()))
+		return
+	}
+
+	resolved, err := dogeboxd.ResolvePupDeepLink(link, t.sources, t.pups)
+	if err != nil {
+		log.Printf("Failed to resolve deep link %q: %v", req.Link, err)
+		sendAPIError(w, http.StatusUnprocessableEntity, dogeboxd.NewAPIError(dogeboxd.ERROR_INVALID_DEEP_LINK, err.Error()).
+			WithRemediation("Check the link with the site that gave it to you."))
+		return
+	}
+
+	response := ResolveDeepLinkResponse{
+		ResolvedPupDeepLink: resolved,
+		Install: InstallPupRequest{
+			PupName:                 link.PupName,
+			PupVersion:              resolved.PupVersion,
+			AutoInstallDependencies: true,
+		},
+	}
+
+	if resolved.SourceKnown {
+		response.Install.SourceId = resolved.Source.ID
+	} else {
+		response.AddSource = link.SourceLocation
+	}
+
+	sendResponse(w, response)
+}
@@ -0,0 +1,95 @@
+package web
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/Dogebox-WG/dogeboxd/pkg/devtemplate"
+)
+
+// The names dbx-dev allows for dev pups, which are also their directory.
+var devPupName = regexp.MustCompile(`^[a-z0-9_-]{3,30}$`)
+
+// devPupDir finds a dev pup's directory, a template cloned into DevDir
+// by the dPanel's dev page or dbx-dev. Dev pups only exist in dev mode.
+func (t api) devPupDir(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if !t.devModeActionsAllowed() {
+		sendErrorResponse(w, http.StatusForbidden, "This endpoint is only available in dev mode")
+		return "", false
+	}
+
+	name := r.PathValue("name")
+	if !devPupName.MatchString(name) || t.config.DevDir == "" {
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid dev pup name")
+		return "", false
+	}
+
+	dir := filepath.Join(t.config.DevDir, name)
+	if _, err := os.Stat(dir); err != nil {
+		sendErrorResponse(w, http.StatusNotFound, "Dev pup not found")
+		return "", false
+	}
+	return dir, true
+}
+
+// Lists the variables a dev pup's template asks for.
+func (t api) getDevPupTemplate(w http.ResponseWriter, r *http.Request) {
+	dir, ok := t.devPupDir(w, r)
+	if !ok {
+		return
+	}
+
+	tmpl, err := devtemplate.Load(dir)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sendResponse(w, tmpl)
+}
+
+type ApplyDevPupTemplateRequest struct {
+	// The pup-templates directory the pup was cloned from.
+	Template string            `json:"template"`
+	Values   map[string]string `json:"values"`
+}
+
+// Fills in a dev pup's template with its name and the values given.
+func (t api) applyDevPupTemplate(w http.ResponseWriter, r *http.Request) {
+	dir, ok := t.devPupDir(w, r)
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error reading request body")
+		return
+	}
+	defer r.Body.Close()
+
+	var req ApplyDevPupTemplateRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error unmarshalling JSON")
+		return
+	}
+	if req.Template == "" {
+		sendErrorResponse(w, http.StatusBadRequest, "Missing template")
+		return
+	}
+
+	values, err := devtemplate.Apply(dir, req.Template, filepath.Base(dir), req.Values)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sendResponse(w, map[string]any{
+		"success": true,
+		"values":  values,
+	})
+}
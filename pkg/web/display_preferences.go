@@ -0,0 +1,34 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+func (t api) getDisplayPreferences(w http.ResponseWriter, r *http.Request) {
+	sendResponse(w, t.sm.Get().Dogebox.DisplayPreferences.WithDefaults())
+}
+
+func (t api) setDisplayPreferences(w http.ResponseWriter, r *http.Request) {
+	var req dogeboxd.DisplayPreferences
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error decoding request body")
+		return
+	}
+	if err := req.Validate(); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	dbxState := t.sm.Get().Dogebox
+	dbxState.DisplayPreferences = req
+	if err := t.sm.SetDogebox(dbxState); err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Error saving display preferences")
+		return
+	}
+
+	t.dbx.SendChange(dogeboxd.Change{ID: "internal", Type: "display-preferences", Update: req.WithDefaults()})
+	sendResponse(w, req.WithDefaults())
+}
@@ -6,6 +6,8 @@ import (
 	"log"
 	"net/http"
 	"strings"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
 )
 
 func sendResponse(w http.ResponseWriter, payload any) {
@@ -21,14 +23,21 @@ func sendResponse(w http.ResponseWriter, payload any) {
 }
 
 func sendErrorResponse(w http.ResponseWriter, code int, message string) {
-	log.Printf("[!] %d: %s\n", code, message)
-	// would prefer to use json.Marshal, but this avoids the need
-	// to handle encoding errors arising from json.Marshal itself!
-	payload := fmt.Sprintf("{\"error\":{\"code\":%q,\"message\":%q}}", code, message)
+	sendAPIError(w, code, dogeboxd.NewAPIError(dogeboxd.ErrorCodeForStatus(code), message))
+}
+
+// sendAPIError responds with a structured error, for when a handler
+// can say more than its HTTP status does.
+func sendAPIError(w http.ResponseWriter, status int, apiErr *dogeboxd.APIError) {
+	log.Printf("[!] %d %s: %s\n", status, apiErr.Code, apiErr.Message)
+	payload, err := json.Marshal(map[string]any{"error": apiErr})
+	if err != nil {
+		payload = []byte(fmt.Sprintf("{\"error\":{\"code\":%q,\"message\":%q}}", dogeboxd.ERROR_INTERNAL, apiErr.Message))
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Cache-Control", "no-store") // do not cache (Browsers cache GET forever by default)
-	w.WriteHeader(code)
-	w.Write([]byte(payload))
+	w.WriteHeader(status)
+	w.Write(payload)
 }
 
 func getOriginIP(r *http.Request) string {
@@ -0,0 +1,38 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendErrorResponseIsStructured(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	sendErrorResponse(recorder, http.StatusNotFound, "Log file not found")
+
+	require.Equal(t, http.StatusNotFound, recorder.Code)
+
+	var response struct {
+		Error dogeboxd.APIError `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.Equal(t, dogeboxd.ERROR_NOT_FOUND, response.Error.Code)
+	assert.Equal(t, "Log file not found", response.Error.Message)
+}
+
+func TestSendAPIErrorIncludesRelatedIDs(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	sendAPIError(recorder, http.StatusNotFound, dogeboxd.NewAPIError(dogeboxd.ERROR_PUP_NOT_FOUND, "Pup not found").ForPup("abc"))
+
+	var response struct {
+		Error dogeboxd.APIError `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.Equal(t, dogeboxd.ERROR_PUP_NOT_FOUND, response.Error.Code)
+	assert.Equal(t, "abc", response.Error.PupID)
+}
@@ -0,0 +1,29 @@
+package web
+
+import (
+	"net/http"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/Dogebox-WG/dogeboxd/pkg/system"
+)
+
+func (t api) getSystemInventory(w http.ResponseWriter, r *http.Request) {
+	sendResponse(w, system.GetSystemInventory(t.nix))
+}
+
+func (t api) getVersionDrift(w http.ResponseWriter, r *http.Request) {
+	sendResponse(w, system.CheckVersionDrift(t.config))
+}
+
+func (t api) getStorageHealth(w http.ResponseWriter, r *http.Request) {
+	mirrored := t.sm.Get().Dogebox.StorageMirrorDevice != ""
+	sendResponse(w, system.CheckStorageHealth(dogeboxd.StorageStatusDir, mirrored))
+}
+
+func (t api) reapplySystemVersion(w http.ResponseWriter, r *http.Request) {
+	id := t.dbx.AddAction(dogeboxd.ReapplySystemVersion{})
+	sendResponse(w, map[string]any{
+		"success": true,
+		"id":      id,
+	})
+}
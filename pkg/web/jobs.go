@@ -38,7 +38,7 @@ func (t api) getJob(w http.ResponseWriter, r *http.Request) {
 
 	job, err := t.dbx.JobManager.GetJob(jobID)
 	if err != nil {
-		sendErrorResponse(w, http.StatusNotFound, "Job not found")
+		sendAPIError(w, http.StatusNotFound, dogeboxd.NewAPIError(dogeboxd.ERROR_JOB_NOT_FOUND, "Job not found").ForJob(jobID))
 		return
 	}
 
@@ -84,6 +84,66 @@ func (t api) getRecentJobs(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+var jobStatuses = map[dogeboxd.JobStatus]struct{}{
+	dogeboxd.JobStatusQueued:     {},
+	dogeboxd.JobStatusInProgress: {},
+	dogeboxd.JobStatusCompleted:  {},
+	dogeboxd.JobStatusFailed:     {},
+	dogeboxd.JobStatusCancelled:  {},
+	dogeboxd.JobStatusOrphaned:   {},
+}
+
+const maxPupJobsLimit = 200
+
+// Get a page of a single pup's job history, optionally filtered by a
+// comma separated list of statuses, ie: ?status=failed,orphaned&limit=20&offset=40
+func (t api) getPupJobs(w http.ResponseWriter, r *http.Request) {
+	pupID := r.PathValue("ID")
+	q := r.URL.Query()
+
+	query := dogeboxd.PupJobsQuery{Limit: 50}
+	if v := q.Get("limit"); v != "" {
+		l, err := strconv.Atoi(v)
+		if err != nil || l < 1 || l > maxPupJobsLimit {
+			sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxPupJobsLimit))
+			return
+		}
+		query.Limit = l
+	}
+	if v := q.Get("offset"); v != "" {
+		o, err := strconv.Atoi(v)
+		if err != nil || o < 0 {
+			sendErrorResponse(w, http.StatusBadRequest, "offset must be a non-negative number")
+			return
+		}
+		query.Offset = o
+	}
+	if v := q.Get("status"); v != "" {
+		for _, status := range strings.Split(v, ",") {
+			status := dogeboxd.JobStatus(strings.TrimSpace(status))
+			if _, ok := jobStatuses[status]; !ok {
+				sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("unknown job status %q", status))
+				return
+			}
+			query.Statuses = append(query.Statuses, status)
+		}
+	}
+
+	jobs, total, err := t.dbx.JobManager.GetJobsForPup(pupID, query)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to retrieve pup jobs")
+		return
+	}
+
+	sendResponse(w, map[string]interface{}{
+		"success": true,
+		"jobs":    jobs,
+		"total":   total,
+		"limit":   query.Limit,
+		"offset":  query.Offset,
+	})
+}
+
 // Clear old completed jobs
 func (t api) clearCompletedJobs(w http.ResponseWriter, r *http.Request) {
 	var req struct {
@@ -111,6 +171,46 @@ func (t api) clearCompletedJobs(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+func (t api) getJobLogRetention(w http.ResponseWriter, r *http.Request) {
+	sendResponse(w, t.dbx.JobManager.JobLogRetention())
+}
+
+// setJobLogRetention updates how long archived job logs are kept, pruning
+// the archive straight away so the change is visible.
+func (t api) setJobLogRetention(w http.ResponseWriter, r *http.Request) {
+	var req dogeboxd.JobLogRetention
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error decoding request body")
+		return
+	}
+	if err := req.Validate(); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err := t.sm.UpdateDogebox(func(s *dogeboxd.DogeboxState) bool {
+		s.JobLogRetention = req
+		return true
+	})
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Error saving job log retention")
+		return
+	}
+
+	retention := t.dbx.JobManager.JobLogRetention()
+	removed, err := dogeboxd.PruneJobLogArchive(t.config, retention, time.Now())
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Error pruning job log archive")
+		return
+	}
+
+	sendResponse(w, map[string]any{
+		"success":   true,
+		"retention": retention,
+		"pruned":    removed,
+	})
+}
+
 // Get job statistics
 func (t api) getJobStats(w http.ResponseWriter, r *http.Request) {
 	allJobs, err := t.dbx.JobManager.GetAllJobs()
@@ -161,7 +261,7 @@ func (t api) deleteJob(w http.ResponseWriter, r *http.Request) {
 
 	job, err := t.dbx.JobManager.GetJob(jobID)
 	if err != nil {
-		sendErrorResponse(w, http.StatusNotFound, "Job not found")
+		sendAPIError(w, http.StatusNotFound, dogeboxd.NewAPIError(dogeboxd.ERROR_JOB_NOT_FOUND, "Job not found").ForJob(jobID))
 		return
 	}
 
@@ -197,7 +297,7 @@ func (t api) deleteJob(w http.ResponseWriter, r *http.Request) {
 }
 
 func (t api) createOrphanCandidateJob(w http.ResponseWriter, r *http.Request) {
-	if !t.config.DevMode {
+	if !t.devModeActionsAllowed() {
 		sendErrorResponse(w, http.StatusForbidden, "This endpoint is only available in dev mode")
 		return
 	}
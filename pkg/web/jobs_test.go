@@ -1,6 +1,8 @@
 package web
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -121,3 +123,75 @@ func TestDeleteJobAllowsQueuedJobs(t *testing.T) {
 	_, err = jm.GetJob(job.ID)
 	assert.Error(t, err)
 }
+
+func TestGetPupJobsFiltersAndPages(t *testing.T) {
+	sm, err := dogeboxd.NewStoreManager(":memory:")
+	require.NoError(t, err)
+
+	dbx := dogeboxd.NewDogeboxd(nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, &dogeboxd.ServerConfig{
+		ContainerLogDir: "",
+	})
+	jm := dogeboxd.NewJobManager(sm, &dbx)
+	dbx.SetJobManager(jm)
+
+	start := time.Now().Add(-time.Hour)
+	actions := []dogeboxd.Action{
+		dogeboxd.EnablePup{PupID: "pup1"},
+		dogeboxd.UpdatePupConfig{PupID: "pup1"},
+		dogeboxd.UpgradePup{PupID: "pup1"},
+		dogeboxd.DisablePup{PupID: "pup1"},
+		dogeboxd.EnablePup{PupID: "pup2"},
+	}
+	for i, a := range actions {
+		state := &dogeboxd.PupState{ID: "pup1"}
+		if i == len(actions)-1 {
+			state.ID = "pup2"
+		}
+		state.Manifest.Meta.Name = "Test Pup"
+		job := dogeboxd.Job{ID: fmt.Sprintf("job-%d", i), Start: start.Add(time.Duration(i) * time.Minute), A: a, State: state}
+		_, err := jm.CreateJobRecord(job)
+		require.NoError(t, err)
+	}
+	require.NoError(t, jm.CompleteJob("job-0", ""))
+	require.NoError(t, jm.CompleteJob("job-2", "upgrade failed"))
+
+	get := func(query string) (int, map[string]any) {
+		req := httptest.NewRequest(http.MethodGet, "/pup/pup1/jobs"+query, nil)
+		req.SetPathValue("ID", "pup1")
+		rec := httptest.NewRecorder()
+		api{dbx: dbx}.getPupJobs(rec, req)
+
+		var body map[string]any
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		return rec.Code, body
+	}
+
+	jobIDs := func(body map[string]any) []string {
+		ids := []string{}
+		for _, j := range body["jobs"].([]any) {
+			ids = append(ids, j.(map[string]any)["id"].(string))
+		}
+		return ids
+	}
+
+	code, body := get("")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"job-3", "job-2", "job-1", "job-0"}, jobIDs(body))
+	assert.Equal(t, float64(4), body["total"])
+
+	_, body = get("?limit=2&offset=1")
+	assert.Equal(t, []string{"job-2", "job-1"}, jobIDs(body))
+	assert.Equal(t, float64(4), body["total"])
+
+	_, body = get("?status=failed,completed")
+	assert.Equal(t, []string{"job-2", "job-0"}, jobIDs(body))
+
+	_, body = get("?offset=10")
+	assert.Empty(t, jobIDs(body))
+
+	code, _ = get("?status=exploded")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = get("?limit=0")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
@@ -0,0 +1,91 @@
+package web
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+type AddJournalUnitRequest struct {
+	ID          string `json:"id"`
+	Unit        string `json:"unit"`
+	Description string `json:"description"`
+}
+
+// getJournalUnits lists the units whose journals can be streamed from
+// /ws/log/pup/{id} and /log/pup/{id}/tail, by ID.
+func (t api) getJournalUnits(w http.ResponseWriter, r *http.Request) {
+	sendResponse(w, map[string]any{
+		"units": dogeboxd.JournalUnits(t.sm.Get().Dogebox, t.pups.GetStateMap()),
+	})
+}
+
+func (t api) addJournalUnit(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error reading request body")
+		return
+	}
+	defer r.Body.Close()
+
+	var req AddJournalUnitRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error unmarshalling JSON")
+		return
+	}
+
+	unit := dogeboxd.JournalUnit{ID: req.ID, Unit: req.Unit, Description: req.Description}
+	if err := dogeboxd.ValidateJournalUnit(unit); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	// Units are streamed through the pup log endpoints, so mustn't
+	// shadow a pup.
+	if _, _, err := t.pups.GetPup(unit.ID); err == nil {
+		sendErrorResponse(w, http.StatusBadRequest, "A pup already has the ID "+unit.ID)
+		return
+	}
+
+	dbxState := t.sm.Get().Dogebox
+	if _, ok := dbxState.JournalUnit(unit.ID); ok {
+		sendErrorResponse(w, http.StatusConflict, "Journal unit with this ID already exists")
+		return
+	}
+	dbxState.JournalUnits = append(dbxState.JournalUnits, unit)
+	if err := t.sm.SetDogebox(dbxState); err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Error saving journal unit")
+		return
+	}
+
+	sendResponse(w, unit)
+}
+
+func (t api) removeJournalUnit(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	dbxState := t.sm.Get().Dogebox
+	unit, ok := dbxState.JournalUnit(id)
+	if !ok {
+		sendErrorResponse(w, http.StatusNotFound, "Journal unit with this ID does not exist")
+		return
+	}
+	if unit.BuiltIn {
+		sendErrorResponse(w, http.StatusBadRequest, "Built in journal units cannot be removed")
+		return
+	}
+
+	units := []dogeboxd.JournalUnit{}
+	for _, u := range dbxState.JournalUnits {
+		if u.ID != id {
+			units = append(units, u)
+		}
+	}
+	dbxState.JournalUnits = units
+	if err := t.sm.SetDogebox(dbxState); err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Error saving journal units")
+		return
+	}
+
+	sendResponse(w, map[string]string{"status": "ok"})
+}
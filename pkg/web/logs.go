@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"time"
 
 	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
 )
@@ -33,12 +34,57 @@ func (t api) downloadPupLog(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	pup, _, err := t.pups.GetPup(pupID)
+	if err != nil {
+		sendAPIError(w, http.StatusBadRequest, dogeboxd.NewAPIError(dogeboxd.ERROR_PUP_NOT_FOUND, "Cannot find pup").ForPup(pupID))
+		return
+	}
+
+	// Downloaded logs usually end up in a bug report, so they're redacted
+	// unless the user asks for ?raw=true. Logs rotated out are included,
+	// oldest first.
+	redactor, err := dogeboxd.NewLogRedactor(pup.Manifest.Config.LogRedactions)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, fmt.Sprintf("Error reading log redactions: %v", err))
+		return
+	}
+
+	logFile, err := dogeboxd.OpenPupLog(t.config, pupID)
+	if err != nil {
+		if os.IsNotExist(err) {
+			sendErrorResponse(w, http.StatusNotFound, "Log file not found")
+			return
+		}
+		sendErrorResponse(w, http.StatusInternalServerError, "Error opening log file")
+		return
+	}
+	defer logFile.Close()
+
+	logPath := t.config.PupLogPath(pupID)
+	if r.URL.Query().Get("raw") == "true" {
+		writeLogDownload(w, logFile, logPath, t.config.PupLogFileName(pupID)+".log")
+		return
+	}
+
+	setLogDownloadHeaders(w, t.config.PupLogFileName(pupID)+".log")
+	if err := redactor.Copy(w, logFile); err != nil {
+		log.Printf("Error streaming log file %s: %v", logPath, err)
+	}
+}
+
+func (t api) downloadPupBuildLog(w http.ResponseWriter, r *http.Request) {
+	pupID := r.PathValue("PupID")
+	if pupID == "" {
+		sendErrorResponse(w, http.StatusBadRequest, "Missing pup id")
+		return
+	}
+
 	if _, _, err := t.pups.GetPup(pupID); err != nil {
-		sendErrorResponse(w, http.StatusBadRequest, "Cannot find pup")
+		sendAPIError(w, http.StatusBadRequest, dogeboxd.NewAPIError(dogeboxd.ERROR_PUP_NOT_FOUND, "Cannot find pup").ForPup(pupID))
 		return
 	}
 
-	t.streamLogDownload(w, t.config.PupLogPath(pupID), t.config.PupLogFileName(pupID)+".log")
+	t.streamLogDownload(w, t.config.PupBuildLogPath(pupID), t.config.PupLogFileName(pupID)+"-build.log")
 }
 
 func (t api) downloadJobLog(w http.ResponseWriter, r *http.Request) {
@@ -97,6 +143,57 @@ func (t api) getLogTail(
 	})
 }
 
+/* queryPupLogs searches a pup's past container logs, ie:
+ *
+ *	GET /log/pup/{PupID}/query?since=2026-10-17T03:00:00Z&until=2026-10-17T04:00:00Z&severity=warning
+ *
+ * since and until are RFC 3339 times, severity is the least severe level
+ * to include. Pass a page's nextCursor back as cursor for the next page.
+ */
+func (t api) queryPupLogs(w http.ResponseWriter, r *http.Request) {
+	pupID := r.PathValue("PupID")
+	query := r.URL.Query()
+
+	limit, err := parseLogTailLimit(r)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	q := dogeboxd.LogQuery{
+		MinSeverity: query.Get("severity"),
+		Limit:       limit,
+	}
+
+	for name, target := range map[string]**time.Time{"since": &q.Since, "until": &q.Until} {
+		raw := query.Get(name)
+		if raw == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid %s time, expected RFC 3339", name))
+			return
+		}
+		*target = &parsed
+	}
+	if cursor := query.Get("cursor"); cursor != "" {
+		q.Cursor = &cursor
+	}
+
+	if err := q.Validate(); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page, err := t.dbx.QueryLogs(pupID, q)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sendResponse(w, page)
+}
+
 func parseLogTailLimit(r *http.Request) (int, error) {
 	rawLimit := r.URL.Query().Get("limit")
 	if rawLimit == "" {
@@ -125,6 +222,37 @@ func parseLogTailBefore(r *http.Request) (*string, error) {
 
 	return &before, nil
 }
+
+// Downloads the full archived log for a finished job, falling
+// back to the live log file if the job hasn't been archived yet.
+func (t api) downloadArchivedJobLog(w http.ResponseWriter, r *http.Request) {
+	jobID := r.PathValue("jobID")
+	if jobID == "" {
+		sendErrorResponse(w, http.StatusBadRequest, "Job ID required")
+		return
+	}
+
+	if _, err := t.dbx.JobManager.GetJob(jobID); err != nil {
+		sendAPIError(w, http.StatusNotFound, dogeboxd.NewAPIError(dogeboxd.ERROR_JOB_NOT_FOUND, "Job not found").ForJob(jobID))
+		return
+	}
+
+	downloadName := t.config.JobLogFileName(jobID) + ".log"
+
+	archive, err := dogeboxd.OpenJobLogArchive(t.config, jobID)
+	if err != nil {
+		if os.IsNotExist(err) {
+			t.streamLogDownload(w, t.config.JobLogPath(jobID), downloadName)
+			return
+		}
+		sendErrorResponse(w, http.StatusInternalServerError, "Error opening archived job log")
+		return
+	}
+	defer archive.Close()
+
+	writeLogDownload(w, archive, t.config.JobLogArchivePath(jobID), downloadName)
+}
+
 func (t api) streamLogDownload(w http.ResponseWriter, logPath string, downloadName string) {
 	logFile, err := os.Open(logPath)
 	if err != nil {
@@ -137,11 +265,19 @@ func (t api) streamLogDownload(w http.ResponseWriter, logPath string, downloadNa
 	}
 	defer logFile.Close()
 
-	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", downloadName))
-	w.Header().Set("Cache-Control", "no-store")
+	writeLogDownload(w, logFile, logPath, downloadName)
+}
+
+func writeLogDownload(w http.ResponseWriter, logReader io.Reader, logPath string, downloadName string) {
+	setLogDownloadHeaders(w, downloadName)
 
-	if _, err := io.Copy(w, logFile); err != nil {
+	if _, err := io.Copy(w, logReader); err != nil {
 		log.Printf("Error streaming log file %s: %v", logPath, err)
 	}
 }
+
+func setLogDownloadHeaders(w http.ResponseWriter, downloadName string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", downloadName))
+	w.Header().Set("Cache-Control", "no-store")
+}
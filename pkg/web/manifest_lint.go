@@ -0,0 +1,65 @@
+package web
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+type LintPupManifestRequest struct {
+	// The contents of manifest.json, as a string so a manifest that isn't
+	// valid JSON can still be linted.
+	Manifest string `json:"manifest"`
+	// The contents of the manifest's nixFile, null to skip checking its hash.
+	NixFile *string `json:"nixFile"`
+}
+
+/* lintPupManifest checks a pup's manifest.json and nix file before its
+ * source is added, against the pups already installed here, returning
+ * every problem found as PupManifestDiagnostics.
+ */
+func (t api) lintPupManifest(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error reading request body")
+		return
+	}
+	defer r.Body.Close()
+
+	var req LintPupManifestRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error unmarshalling JSON")
+		return
+	}
+	if req.Manifest == "" {
+		sendErrorResponse(w, http.StatusBadRequest, "Missing manifest")
+		return
+	}
+
+	// Upgrading a pup re-uses its own ports, so don't count them.
+	var meta struct {
+		Meta struct {
+			Name string `json:"name"`
+		} `json:"meta"`
+	}
+	_ = json.Unmarshal([]byte(req.Manifest), &meta)
+
+	hostPorts := dogeboxd.PupHostPorts(t.pups.GetStateMap(), meta.Meta.Name)
+	for port, owner := range map[int]string{
+		t.config.Port:         "the dogeboxd API",
+		t.config.UiPort:       "the dPanel",
+		t.config.InternalPort: "the internal router",
+	} {
+		if port != 0 {
+			hostPorts[port] = owner
+		}
+	}
+
+	in := dogeboxd.PupManifestLintInput{Manifest: []byte(req.Manifest), HostPorts: hostPorts}
+	if req.NixFile != nil {
+		in.NixFile = []byte(*req.NixFile)
+	}
+	sendResponse(w, dogeboxd.LintPupManifest(in))
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 
 	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/prometheus/common/expfmt"
 )
 
 func (t api) getPupMetrics(w http.ResponseWriter, r *http.Request) {
@@ -73,3 +74,71 @@ func (t InternalRouter) recordMetrics(w http.ResponseWriter, r *http.Request) {
 	id := t.dbx.AddAction(update)
 	sendResponse(w, map[string]string{"id": id})
 }
+
+type recordPupStatusRequest struct {
+	Message  string   `json:"message"`
+	Progress *float64 `json:"progress"`
+}
+
+// Allows a pup to report a domain-specific status, eg: "syncing 42%"
+func (t InternalRouter) recordPupStatus(w http.ResponseWriter, r *http.Request) {
+	originPup, ok := t.getOriginPup(r)
+	if !ok {
+		// you must be a pup!
+		forbidden(w, "You are not a Pup we know about")
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error reading request body")
+		return
+	}
+	defer r.Body.Close()
+
+	var req recordPupStatusRequest
+	err = json.Unmarshal(body, &req)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error unmarshalling JSON")
+		return
+	}
+
+	if req.Progress != nil && (*req.Progress < 0 || *req.Progress > 100) {
+		sendErrorResponse(w, http.StatusBadRequest, "Progress must be between 0 and 100")
+		return
+	}
+
+	update := dogeboxd.UpdatePupStatus{
+		PupID:    originPup.ID,
+		Message:  req.Message,
+		Progress: req.Progress,
+	}
+
+	id := t.dbx.AddAction(update)
+	sendResponse(w, map[string]string{"id": id})
+}
+
+// Prometheus metrics about dogeboxd itself.
+func (t api) getInternalMetrics(w http.ResponseWriter, r *http.Request) {
+	families, err := dogeboxd.InternalMetricsRegistry.Gather()
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Error gathering metrics")
+		return
+	}
+
+	format := expfmt.Negotiate(r.Header)
+	w.Header().Set("Content-Type", string(format))
+
+	enc := expfmt.NewEncoder(w, format)
+	for _, family := range families {
+		if err := enc.Encode(family); err != nil {
+			log.Printf("Failed to encode metric %s: %v", family.GetName(), err)
+			return
+		}
+	}
+}
+
+// A readable summary of dogeboxd's queues and timings, for troubleshooting slow boxes.
+func (t api) getInternalDebug(w http.ResponseWriter, r *http.Request) {
+	sendResponse(w, t.dbx.GetInternalMetrics())
+}
@@ -5,8 +5,10 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"strings"
 
 	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/Dogebox-WG/dogeboxd/pkg/system"
 )
 
 func (t api) getNetwork(w http.ResponseWriter, r *http.Request) {
@@ -87,6 +89,20 @@ func (t api) setPendingNetwork(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	static, proxy := dogeboxd.SelectedNetworkOptions(selectedNetwork)
+	if static != nil {
+		if err := static.Validate(); err != nil {
+			sendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if proxy != nil {
+		if err := proxy.Validate(); err != nil {
+			sendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
 	dbxs := t.sm.Get().Dogebox
 
 	// TODO: this shouldn't live in here.
@@ -103,10 +119,98 @@ func (t api) setPendingNetwork(w http.ResponseWriter, r *http.Request) {
 }
 
 func (t api) getSources(w http.ResponseWriter, r *http.Request) {
-	sources := t.sources.GetAllSourceConfigurations()
+	status := map[string]dogeboxd.SourceRefreshStatus{}
+	if t.dbx.SourceRefresher != nil {
+		status = t.dbx.SourceRefresher.Status()
+	}
+
+	sources := []SourceResponse{}
+	for _, c := range t.sources.GetAllSourceConfigurations() {
+		s := SourceResponse{ManifestSourceConfiguration: c}
+		if st, ok := status[c.ID]; ok {
+			s.Refresh = &st
+		}
+		sources = append(sources, s)
+	}
 
 	sendResponse(w, map[string]any{
 		"success": true,
 		"sources": sources,
 	})
 }
+
+type SetAPModeRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+type APModeStateResponse struct {
+	Enabled bool   `json:"enabled"`
+	Reason  string `json:"reason"`
+	SSID    string `json:"ssid"`
+	// Needed to join the access point, set once it's first been enabled.
+	Passphrase string `json:"passphrase"`
+}
+
+func (t api) getAPModeState(w http.ResponseWriter, r *http.Request) {
+	apMode := t.sm.Get().Dogebox.APMode
+	sendResponse(w, APModeStateResponse{
+		Enabled: apMode.Enabled,
+		Reason:  apMode.Reason,
+		SSID:    apMode.SSID,
+
+		Passphrase: apMode.Passphrase,
+	})
+}
+
+func (t api) setAPModeState(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error reading request body")
+		return
+	}
+
+	var req SetAPModeRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error unmarshalling JSON")
+		return
+	}
+
+	reason := ""
+	if req.Enabled {
+		reason = "Enabled by user"
+	}
+
+	id := t.dbx.AddAction(dogeboxd.SetAPMode{Enabled: req.Enabled, Reason: reason})
+	sendResponse(w, map[string]string{"id": id})
+}
+
+type SetWifiRegulatoryDomainRequest struct {
+	Country string `json:"country"`
+}
+
+func (t api) getWifiRegulatoryDomain(w http.ResponseWriter, r *http.Request) {
+	sendResponse(w, map[string]string{"country": t.sm.Get().Dogebox.WifiRegulatoryDomain})
+}
+
+func (t api) setWifiRegulatoryDomain(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error reading request body")
+		return
+	}
+
+	var req SetWifiRegulatoryDomainRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error unmarshalling JSON")
+		return
+	}
+
+	country := strings.ToUpper(strings.TrimSpace(req.Country))
+	if !system.IsValidWifiRegulatoryDomain(country) {
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid country code")
+		return
+	}
+
+	id := t.dbx.AddAction(dogeboxd.UpdateWifiRegulatoryDomain{Country: country})
+	sendResponse(w, map[string]string{"id": id})
+}
@@ -0,0 +1,76 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+type NixGCResponse struct {
+	dogeboxd.NixGCSettings
+	// What a collection run now keeps, with the default filled in.
+	EffectiveKeepGenerations int                     `json:"effectiveKeepGenerations"`
+	Usage                    *dogeboxd.NixStoreUsage `json:"usage"`
+}
+
+type SetNixGCRequest struct {
+	KeepGenerations int `json:"keepGenerations"`
+}
+
+type CollectNixGarbageRequest struct {
+	// Optional, the box's setting is used when 0.
+	KeepGenerations int `json:"keepGenerations"`
+}
+
+func (t api) getNixGC(w http.ResponseWriter, r *http.Request) {
+	settings := t.sm.Get().Dogebox.NixGC
+	resp := NixGCResponse{
+		NixGCSettings:            settings,
+		EffectiveKeepGenerations: settings.KeepGenerationsFor(dogeboxd.CollectNixGarbage{}),
+	}
+	if usage, err := dogeboxd.GetNixStoreUsage(); err == nil {
+		resp.Usage = &usage
+	}
+	sendResponse(w, resp)
+}
+
+func (t api) setNixGC(w http.ResponseWriter, r *http.Request) {
+	var req SetNixGCRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error decoding request body")
+		return
+	}
+	if err := dogeboxd.ValidateNixGCKeepGenerations(req.KeepGenerations); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	dbxState := t.sm.Get().Dogebox
+	dbxState.NixGC.KeepGenerations = req.KeepGenerations
+	if err := t.sm.SetDogebox(dbxState); err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Error saving garbage collection settings")
+		return
+	}
+
+	t.getNixGC(w, r)
+}
+
+func (t api) collectNixGarbage(w http.ResponseWriter, r *http.Request) {
+	var req CollectNixGarbageRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			sendErrorResponse(w, http.StatusBadRequest, "Error decoding request body")
+			return
+		}
+	}
+	if req.KeepGenerations != 0 {
+		if err := dogeboxd.ValidateNixGCKeepGenerations(req.KeepGenerations); err != nil {
+			sendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	id := t.dbx.AddAction(dogeboxd.CollectNixGarbage{KeepGenerations: req.KeepGenerations})
+	sendResponse(w, map[string]string{"id": id})
+}
@@ -0,0 +1,165 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"time"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/Dogebox-WG/dogeboxd/pkg/system"
+	"github.com/shirou/gopsutil/v4/disk"
+)
+
+// diskFree is the free space on the filesystem holding path.
+var diskFree = func(path string) (uint64, error) {
+	d, err := disk.Usage(path)
+	if err != nil {
+		return 0, err
+	}
+	return d.Free, nil
+}
+
+type SystemUpdateBundleInfo struct {
+	Path       string    `json:"path"`
+	Size       int64     `json:"size"`
+	Version    string    `json:"version,omitempty"`
+	Created    time.Time `json:"created,omitempty"`
+	SignedBy   string    `json:"signedBy,omitempty"`
+	StorePaths int       `json:"storePaths"`
+	// Why the bundle can't be applied, if it can't.
+	Error string `json:"error,omitempty"`
+}
+
+type ApplySystemUpdateBundleRequest struct {
+	Path string `json:"path"`
+}
+
+func (t api) inspectSystemUpdateBundle(path string) SystemUpdateBundleInfo {
+	info := SystemUpdateBundleInfo{Path: path}
+	if stat, err := os.Stat(path); err == nil {
+		info.Size = stat.Size()
+	}
+
+	bundle, signedBy, err := system.InspectSystemUpdateBundle(path, dogeboxd.RELEASE_SIGNING_KEYS)
+	if err != nil {
+		info.Error = err.Error()
+		return info
+	}
+	info.Version = bundle.Version
+	info.Created = bundle.Created
+	info.SignedBy = signedBy
+	info.StorePaths = len(bundle.StorePaths)
+	return info
+}
+
+// getSystemUpdateBundles lists the offline update bundles on mounted
+// drives, ie: a USB stick carried over from a connected machine.
+func (t api) getSystemUpdateBundles(w http.ResponseWriter, r *http.Request) {
+	bundles := []SystemUpdateBundleInfo{}
+	for _, path := range dogeboxd.FindSystemUpdateBundles(dogeboxd.SystemUpdateBundleMediaRoots) {
+		bundles = append(bundles, t.inspectSystemUpdateBundle(path))
+	}
+	sendResponse(w, map[string]any{"bundles": bundles})
+}
+
+// applySystemUpdateBundle queues an update from a bundle found by
+// getSystemUpdateBundles.
+func (t api) applySystemUpdateBundle(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error reading request body")
+		return
+	}
+
+	var req ApplySystemUpdateBundleRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error unmarshalling JSON")
+		return
+	}
+
+	// Only bundles on removable drives, not any file dogeboxd can read.
+	if !slices.Contains(dogeboxd.FindSystemUpdateBundles(dogeboxd.SystemUpdateBundleMediaRoots), req.Path) {
+		sendErrorResponse(w, http.StatusNotFound, "System update bundle not found")
+		return
+	}
+
+	info := t.inspectSystemUpdateBundle(req.Path)
+	if info.Error != "" {
+		sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid system update bundle: %s", info.Error))
+		return
+	}
+
+	id := t.dbx.AddAction(dogeboxd.SystemUpdateFromFile{Path: req.Path})
+	sendResponse(w, map[string]any{"id": id, "bundle": info})
+}
+
+/* uploadSystemUpdateBundle takes an offline update bundle as the request
+ * body, and queues applying it, ie:
+ *
+ *	curl -X POST --data-binary @dogebox-v0.9.1.dbxupdate .../system/update/bundle
+ */
+func (t api) uploadSystemUpdateBundle(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	bundleID, err := dogeboxd.NewSystemUpdateBundleID()
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to create system update bundle ID")
+		return
+	}
+
+	if err := os.MkdirAll(t.config.SystemUpdateBundleDir(), 0750); err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to create system update bundle directory")
+		return
+	}
+
+	free, err := diskFree(t.config.SystemUpdateBundleDir())
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to check free disk space")
+		return
+	}
+	// Applying the bundle copies what's in it into the nix store, so leave
+	// room for it twice.
+	limit := min(int64(free/2), dogeboxd.MaxSystemUpdateBundleSize)
+	if r.ContentLength > limit {
+		sendErrorResponse(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("System update bundle is too large, at most %dMB can be uploaded", limit/1024/1024))
+		return
+	}
+	// Uploads that don't say how big they are are cut off at the limit.
+	body := http.MaxBytesReader(w, r.Body, limit)
+
+	path := t.config.SystemUpdateBundlePath(bundleID)
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0640)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to save system update bundle")
+		return
+	}
+	_, err = io.Copy(out, body)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			sendErrorResponse(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("System update bundle is too large, at most %dMB can be uploaded", limit/1024/1024))
+			return
+		}
+		sendErrorResponse(w, http.StatusBadRequest, "Failed to receive system update bundle")
+		return
+	}
+
+	info := t.inspectSystemUpdateBundle(path)
+	if info.Error != "" {
+		os.Remove(path)
+		sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid system update bundle: %s", info.Error))
+		return
+	}
+
+	id := t.dbx.AddAction(dogeboxd.SystemUpdateFromFile{Path: path, BundleID: bundleID})
+	sendResponse(w, map[string]any{"id": id, "bundle": info})
+}
@@ -0,0 +1,40 @@
+package web
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadSystemUpdateBundleLimitsSize(t *testing.T) {
+	oldDiskFree := diskFree
+	diskFree = func(string) (uint64, error) { return 2000, nil }
+	t.Cleanup(func() { diskFree = oldDiskFree })
+
+	api := api{config: dogeboxd.ServerConfig{DataDir: t.TempDir()}}
+	bundle := strings.Repeat("x", 1500)
+
+	// Refused up front when it says how big it is.
+	req := httptest.NewRequest(http.MethodPost, "/system/update/bundle", strings.NewReader(bundle))
+	rec := httptest.NewRecorder()
+	api.uploadSystemUpdateBundle(rec, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+	// Cut off at the limit when it doesn't.
+	req = httptest.NewRequest(http.MethodPost, "/system/update/bundle", io.NopCloser(strings.NewReader(bundle)))
+	req.ContentLength = -1
+	rec = httptest.NewRecorder()
+	api.uploadSystemUpdateBundle(rec, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+	entries, err := os.ReadDir(api.config.SystemUpdateBundleDir())
+	require.NoError(t, err)
+	assert.Empty(t, entries, "partial upload is removed")
+}
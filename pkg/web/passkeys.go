@@ -0,0 +1,397 @@
+package web
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/Dogebox-WG/dogeboxd/pkg/webauthn"
+)
+
+/* Passkey registration and sign-in are two step: begin hands out the
+ * options for navigator.credentials, and the PublicKeyCredential it
+ * returns (as credential.toJSON()) comes back to finish.
+ */
+
+const passkeyChallengeExpiry = 5 * time.Minute
+
+// WebAuthn user handles must be opaque, there's only the one user.
+var passkeyUserID = []byte("dogebox-admin")
+
+type passkeyChallenge struct {
+	session webauthn.Session
+	rp      *webauthn.RelyingParty
+	rpID    string
+	// Registrations finish in the session that began them.
+	sessionToken string
+	expires      time.Time
+}
+
+var passkeyChallenges = struct {
+	sync.Mutex
+	m map[string]passkeyChallenge
+}{m: map[string]passkeyChallenge{}}
+
+func storePasskeyChallenge(c passkeyChallenge) (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	c.expires = time.Now().Add(passkeyChallengeExpiry)
+	challengeID := base64.RawURLEncoding.EncodeToString(id)
+
+	passkeyChallenges.Lock()
+	defer passkeyChallenges.Unlock()
+	for k, v := range passkeyChallenges.m {
+		if time.Now().After(v.expires) {
+			delete(passkeyChallenges.m, k)
+		}
+	}
+	passkeyChallenges.m[challengeID] = c
+	return challengeID, nil
+}
+
+// takePasskeyChallenge returns a challenge, which can only be used once.
+func takePasskeyChallenge(id string) (passkeyChallenge, bool) {
+	passkeyChallenges.Lock()
+	defer passkeyChallenges.Unlock()
+	c, ok := passkeyChallenges.m[id]
+	delete(passkeyChallenges.m, id)
+	if !ok || time.Now().After(c.expires) {
+		return passkeyChallenge{}, false
+	}
+	return c, true
+}
+
+// passkeyOrigin works out the site dpanel is being used from. Browsers
+// only allow passkeys on https (or localhost), and not on IP addresses.
+func passkeyOrigin(r *http.Request) (string, string, error) {
+	origin := r.Header.Get("Origin")
+	u, err := url.Parse(origin)
+	if origin == "" || err != nil || u.Host == "" {
+		return "", "", errors.New("Passkeys need a browser Origin header")
+	}
+	host := u.Hostname()
+	if net.ParseIP(host) != nil {
+		return "", "", errors.New("Passkeys need dpanel to be opened on a hostname, not an IP address")
+	}
+	if u.Scheme != "https" && host != "localhost" {
+		return "", "", errors.New("Passkeys need dpanel to be opened over https")
+	}
+	return origin, host, nil
+}
+
+func passkeyRelyingParty(r *http.Request) (*webauthn.RelyingParty, string, error) {
+	origin, rpID, err := passkeyOrigin(r)
+	if err != nil {
+		return nil, "", err
+	}
+	rp, err := webauthn.NewRelyingParty(rpID, origin)
+	if err != nil {
+		return nil, "", err
+	}
+	return rp, rpID, nil
+}
+
+func passkeyCredentials(keys []dogeboxd.Passkey) []webauthn.Credential {
+	out := []webauthn.Credential{}
+	for _, k := range keys {
+		id, err := base64.RawURLEncoding.DecodeString(k.ID)
+		if err != nil {
+			continue
+		}
+		out = append(out, webauthn.Credential{
+			ID:             id,
+			PublicKey:      k.PublicKey,
+			Algorithm:      k.Algorithm,
+			SignCount:      k.SignCount,
+			BackupEligible: k.BackupEligible,
+		})
+	}
+	return out
+}
+
+func (t api) getPasskeys(w http.ResponseWriter, r *http.Request) {
+	auth := t.sm.Get().Dogebox.Auth
+	passkeys := auth.Passkeys
+	if passkeys == nil {
+		passkeys = []dogeboxd.Passkey{}
+	}
+	sendResponse(w, map[string]any{
+		"passkeys":       passkeys,
+		"requirePasskey": auth.RequirePasskey,
+	})
+}
+
+func (t api) beginPasskeyRegistration(w http.ResponseWriter, r *http.Request) {
+	session, ok := getSession(r, getBearerToken)
+	if !ok {
+		sendErrorResponse(w, http.StatusUnauthorized, "Not signed in")
+		return
+	}
+	rp, rpID, err := passkeyRelyingParty(r)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	auth := t.sm.Get().Dogebox.Auth
+	if len(auth.Passkeys) >= dogeboxd.MAX_PASSKEYS {
+		sendErrorResponse(w, http.StatusBadRequest, "Too many passkeys, remove one first")
+		return
+	}
+
+	options, ceremony, err := rp.BeginRegistration(passkeyUserID, passkeyCredentials(auth.PasskeysFor(rpID)))
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Error creating challenge")
+		return
+	}
+	challengeID, err := storePasskeyChallenge(passkeyChallenge{session: ceremony, rp: rp, rpID: rpID, sessionToken: session.Token})
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Error creating challenge")
+		return
+	}
+
+	sendResponse(w, map[string]any{
+		"challengeId": challengeID,
+		"publicKey":   options,
+	})
+}
+
+type FinishPasskeyRegistrationRequest struct {
+	ChallengeID string `json:"challengeId"`
+	Name        string `json:"name"`
+	// The PublicKeyCredential from navigator.credentials.create.
+	Credential json.RawMessage `json:"credential"`
+}
+
+func (t api) finishPasskeyRegistration(w http.ResponseWriter, r *http.Request) {
+	session, ok := getSession(r, getBearerToken)
+	if !ok {
+		sendErrorResponse(w, http.StatusUnauthorized, "Not signed in")
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error reading request body")
+		return
+	}
+	defer r.Body.Close()
+
+	var req FinishPasskeyRegistrationRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error unmarshalling JSON")
+		return
+	}
+	if err := dogeboxd.ValidatePasskeyName(req.Name); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	c, ok := takePasskeyChallenge(req.ChallengeID)
+	if !ok || c.sessionToken != session.Token {
+		sendErrorResponse(w, http.StatusBadRequest, "Passkey challenge expired, try again")
+		return
+	}
+
+	cred, err := c.rp.FinishRegistration(passkeyUserID, c.session, req.Credential)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid passkey: "+err.Error())
+		return
+	}
+
+	dbxState := t.sm.Get().Dogebox
+	passkey := dogeboxd.Passkey{
+		ID:             base64.RawURLEncoding.EncodeToString(cred.ID),
+		Name:           req.Name,
+		PublicKey:      cred.PublicKey,
+		Algorithm:      cred.Algorithm,
+		SignCount:      cred.SignCount,
+		BackupEligible: cred.BackupEligible,
+		RPID:           c.rpID,
+		Created:        time.Now(),
+	}
+	if _, exists := dbxState.Auth.FindPasskey(passkey.ID); exists {
+		sendErrorResponse(w, http.StatusBadRequest, "This passkey is already registered")
+		return
+	}
+	if len(dbxState.Auth.Passkeys) >= dogeboxd.MAX_PASSKEYS {
+		sendErrorResponse(w, http.StatusBadRequest, "Too many passkeys, remove one first")
+		return
+	}
+	dbxState.Auth.Passkeys = append(dbxState.Auth.Passkeys, passkey)
+	if err := t.sm.SetDogebox(dbxState); err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Error saving passkey")
+		return
+	}
+
+	sendResponse(w, map[string]any{
+		"success": true,
+		"passkey": passkey,
+	})
+}
+
+func (t api) deletePasskey(w http.ResponseWriter, r *http.Request) {
+	// Without an Origin we don't know which passkeys the caller can use,
+	// RemovePasskey still keeps the last one.
+	rpID := ""
+	if _, host, err := passkeyOrigin(r); err == nil {
+		rpID = host
+	}
+
+	dbxState := t.sm.Get().Dogebox
+	if err := dbxState.Auth.RemovePasskey(r.PathValue("id"), rpID); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := t.sm.SetDogebox(dbxState); err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Error saving passkeys")
+		return
+	}
+	sendResponse(w, map[string]any{"success": true})
+}
+
+type SetPasskeyPolicyRequest struct {
+	RequirePasskey bool `json:"requirePasskey"`
+}
+
+func (t api) setPasskeyPolicy(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error reading request body")
+		return
+	}
+	defer r.Body.Close()
+
+	var req SetPasskeyPolicyRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error unmarshalling JSON")
+		return
+	}
+
+	rpID := ""
+	if req.RequirePasskey {
+		_, host, err := passkeyOrigin(r)
+		if err != nil {
+			sendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		rpID = host
+	}
+
+	dbxState := t.sm.Get().Dogebox
+	if err := dbxState.Auth.SetRequirePasskey(req.RequirePasskey, rpID); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := t.sm.SetDogebox(dbxState); err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Error saving passkey policy")
+		return
+	}
+	sendResponse(w, map[string]any{"success": true, "requirePasskey": req.RequirePasskey})
+}
+
+func (t api) beginPasskeyAuthentication(w http.ResponseWriter, r *http.Request) {
+	rp, rpID, err := passkeyRelyingParty(r)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	keys := t.sm.Get().Dogebox.Auth.PasskeysFor(rpID)
+	if len(keys) == 0 {
+		sendErrorResponse(w, http.StatusBadRequest, "No passkeys are registered for "+rpID)
+		return
+	}
+
+	options, ceremony, err := rp.BeginLogin(passkeyUserID, passkeyCredentials(keys))
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Error creating challenge")
+		return
+	}
+	challengeID, err := storePasskeyChallenge(passkeyChallenge{session: ceremony, rp: rp, rpID: rpID})
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Error creating challenge")
+		return
+	}
+
+	sendResponse(w, map[string]any{
+		"challengeId": challengeID,
+		"publicKey":   options,
+	})
+}
+
+// PasskeyAssertion is a navigator.credentials.get response.
+type PasskeyAssertion struct {
+	ChallengeID string `json:"challengeId"`
+	// The PublicKeyCredential from navigator.credentials.get.
+	Credential json.RawMessage `json:"credential"`
+}
+
+// verifyPasskeyAssertion checks a passkey sign-in and records its use.
+func (t api) verifyPasskeyAssertion(a PasskeyAssertion) error {
+	c, ok := takePasskeyChallenge(a.ChallengeID)
+	if !ok || c.sessionToken != "" {
+		return errors.New("Passkey challenge expired, try again")
+	}
+
+	dbxState := t.sm.Get().Dogebox
+	cred, err := c.rp.FinishLogin(passkeyUserID, c.session, passkeyCredentials(dbxState.Auth.PasskeysFor(c.rpID)), a.Credential)
+	if err != nil {
+		log.Printf("Passkey failed to sign in: %v", err)
+		return errors.New("Invalid passkey")
+	}
+
+	i, ok := dbxState.Auth.FindPasskey(base64.RawURLEncoding.EncodeToString(cred.ID))
+	if !ok {
+		return errors.New("Unknown passkey")
+	}
+	now := time.Now()
+	dbxState.Auth.Passkeys[i].SignCount = cred.SignCount
+	dbxState.Auth.Passkeys[i].BackupEligible = cred.BackupEligible
+	dbxState.Auth.Passkeys[i].LastUsed = &now
+	if err := t.sm.SetDogebox(dbxState); err != nil {
+		log.Printf("Failed to save passkey %s use: %v", dbxState.Auth.Passkeys[i].Name, err)
+	}
+	return nil
+}
+
+// authenticatePasskey signs in with just a passkey. The session can't
+// unlock keys, as only the password can do that.
+func (t api) authenticatePasskey(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error reading request body")
+		return
+	}
+	defer r.Body.Close()
+
+	var req PasskeyAssertion
+	if err := json.Unmarshal(body, &req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error unmarshalling JSON")
+		return
+	}
+
+	if err := t.verifyPasskeyAssertion(req); err != nil {
+		sendErrorResponse(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	token, session := newSession()
+	storeSession(session, t.config)
+
+	sendResponse(w, map[string]any{
+		"success":      true,
+		"token":        token,
+		"keysUnlocked": false,
+	})
+}
@@ -0,0 +1,71 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+type PendingChangesResponse struct {
+	DeferRebuilds bool                     `json:"deferRebuilds"`
+	Changes       []dogeboxd.PendingChange `json:"changes"`
+}
+
+type SetDeferRebuildsRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+func (t api) getPendingChanges(w http.ResponseWriter, r *http.Request) {
+	dbxState := t.sm.Get().Dogebox
+	changes := dbxState.PendingChanges
+	if changes == nil {
+		changes = []dogeboxd.PendingChange{}
+	}
+	sendResponse(w, PendingChangesResponse{DeferRebuilds: dbxState.DeferRebuilds, Changes: changes})
+}
+
+// setDeferRebuilds turns batching on or off. Turning it off leaves any
+// pending changes in place, to be applied or discarded.
+func (t api) setDeferRebuilds(w http.ResponseWriter, r *http.Request) {
+	var req SetDeferRebuildsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error decoding request body")
+		return
+	}
+
+	dbxState := t.sm.Get().Dogebox
+	dbxState.DeferRebuilds = req.Enabled
+	if err := t.sm.SetDogebox(dbxState); err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Error saving preference")
+		return
+	}
+
+	t.getPendingChanges(w, r)
+}
+
+func (t api) applyPendingChanges(w http.ResponseWriter, r *http.Request) {
+	id := t.dbx.AddAction(dogeboxd.ApplyPendingChanges{})
+	sendResponse(w, map[string]any{
+		"success": true,
+		"id":      id,
+	})
+}
+
+// diffPendingChanges starts a job that builds everything pending and
+// diffs it against the running system, follow /ws/log/job/{id} for it.
+func (t api) diffPendingChanges(w http.ResponseWriter, r *http.Request) {
+	id := t.dbx.AddAction(dogeboxd.DiffPendingChanges{})
+	sendResponse(w, map[string]any{
+		"success": true,
+		"id":      id,
+	})
+}
+
+func (t api) discardPendingChanges(w http.ResponseWriter, r *http.Request) {
+	id := t.dbx.AddAction(dogeboxd.DiscardPendingChanges{})
+	sendResponse(w, map[string]any{
+		"success": true,
+		"id":      id,
+	})
+}
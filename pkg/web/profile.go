@@ -0,0 +1,60 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+type BoxProfileResponse struct {
+	Profile dogeboxd.BoxProfile `json:"profile"`
+	// What the profile means for this box, so clients (ie: dbx-ssh)
+	// don't need to know.
+	RequirePupSignatures bool `json:"requirePupSignatures"`
+	AllowHashMismatch    bool `json:"allowHashMismatch"`
+	AllowDbxSSH          bool `json:"allowDbxSSH"`
+	AllowDevModeActions  bool `json:"allowDevModeActions"`
+}
+
+type SetBoxProfileRequest struct {
+	Profile dogeboxd.BoxProfile `json:"profile"`
+}
+
+// devModeActionsAllowed is whether development endpoints are on: dogeboxd
+// was started with --danger-dev, and the box isn't a production one.
+func (t api) devModeActionsAllowed() bool {
+	return t.config.DevMode && t.sm.Get().Dogebox.Profile.AllowsDevModeActions()
+}
+
+func (t api) getBoxProfile(w http.ResponseWriter, r *http.Request) {
+	profile := t.sm.Get().Dogebox.Profile
+	sendResponse(w, BoxProfileResponse{
+		Profile:              profile,
+		RequirePupSignatures: profile.RequiresPupSignatures(),
+		AllowHashMismatch:    profile.AllowsHashMismatch(false),
+		AllowDbxSSH:          profile.AllowsDbxSSH(),
+		AllowDevModeActions:  profile.AllowsDevModeActions(),
+	})
+}
+
+func (t api) setBoxProfile(w http.ResponseWriter, r *http.Request) {
+	var req SetBoxProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error decoding request body")
+		return
+	}
+	if err := dogeboxd.ValidateBoxProfile(req.Profile); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	dbxState := t.sm.Get().Dogebox
+	dbxState.Profile = req.Profile
+	if err := t.sm.SetDogebox(dbxState); err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Error saving profile")
+		return
+	}
+
+	t.getBoxProfile(w, r)
+}
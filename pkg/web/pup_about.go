@@ -0,0 +1,45 @@
+package web
+
+import (
+	"net/http"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+// PupAboutResponse is what dpanel shows on a pup's about page. It leaves
+// out the source's credentials, and everything else in the pup's state.
+type PupAboutResponse struct {
+	ID               string                    `json:"id"`
+	Name             string                    `json:"name"`
+	InstanceName     string                    `json:"instanceName,omitempty"`
+	Version          string                    `json:"version"`
+	ShortDescription string                    `json:"shortDescription"`
+	LongDescription  string                    `json:"longDescription"`
+	UpstreamVersions map[string]string         `json:"upstreamVersions"`
+	Links            dogeboxd.PupManifestLinks `json:"links"`
+	SourceName       string                    `json:"sourceName"`
+	SourceLocation   string                    `json:"sourceLocation"`
+}
+
+func (t api) getPupAbout(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("ID")
+	pup, _, err := t.pups.GetPup(id)
+	if err != nil {
+		sendAPIError(w, http.StatusNotFound, dogeboxd.NewAPIError(dogeboxd.ERROR_PUP_NOT_FOUND, "Pup not found").ForPup(id))
+		return
+	}
+
+	meta := pup.Manifest.Meta
+	sendResponse(w, PupAboutResponse{
+		ID:               pup.ID,
+		Name:             meta.Name,
+		InstanceName:     pup.InstanceName,
+		Version:          meta.Version,
+		ShortDescription: meta.ShortDescription,
+		LongDescription:  meta.LongDescription,
+		UpstreamVersions: meta.UpstreamVersions,
+		Links:            meta.Links,
+		SourceName:       pup.Source.Name,
+		SourceLocation:   pup.Source.Location,
+	})
+}
@@ -0,0 +1,61 @@
+package web
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+/* installPupBundle takes a pup bundle as the request body, and queues
+ * installing it without fetching anything, see dogeboxd.PupBundle, ie:
+ *
+ *	curl -X POST --data-binary @core-1.0.0-bundle.tar.gz .../pup/install-bundle
+ */
+func (t api) installPupBundle(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	session, sessionOK := getKeySession(w, r)
+	if !sessionOK {
+		return
+	}
+
+	bundleID, err := dogeboxd.NewPupBundleID()
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to create pup bundle ID")
+		return
+	}
+
+	if err := os.MkdirAll(t.config.PupBundleDir(), 0750); err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to create pup bundle directory")
+		return
+	}
+
+	path := t.config.PupBundlePath(bundleID)
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0640)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to save pup bundle")
+		return
+	}
+	_, err = io.Copy(out, r.Body)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		sendErrorResponse(w, http.StatusBadRequest, "Failed to receive pup bundle")
+		return
+	}
+
+	info, err := dogeboxd.GetPupBundle(t.config, bundleID)
+	if err != nil {
+		os.Remove(path)
+		sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid pup bundle: %v", err))
+		return
+	}
+
+	id := t.dbx.AddAction(dogeboxd.InstallPupBundle{BundleID: bundleID, SessionToken: session.DKM_TOKEN})
+	sendResponse(w, map[string]any{"id": id, "bundle": info})
+}
@@ -0,0 +1,69 @@
+package web
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+type PupDeviceResponse struct {
+	dogeboxd.PupManifestDevice
+	// What the device is, from dogeboxd rather than the pup.
+	Kind     string `json:"kind"`
+	Approved bool   `json:"approved"`
+}
+
+type SetPupDevicesRequest struct {
+	Paths []string `json:"paths"` // the devices to approve, empty to revoke them all
+}
+
+// Lists the devices a pup's manifest requests, and which are approved.
+func (t api) getPupDevices(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("ID")
+	pup, _, err := t.pups.GetPup(id)
+	if err != nil {
+		sendAPIError(w, http.StatusNotFound, dogeboxd.NewAPIError(dogeboxd.ERROR_PUP_NOT_FOUND, "Pup not found").ForPup(id))
+		return
+	}
+
+	devices := []PupDeviceResponse{}
+	for _, d := range pup.Manifest.Container.Devices {
+		devices = append(devices, PupDeviceResponse{PupManifestDevice: d, Kind: d.Kind(), Approved: pup.DeviceApproved(d)})
+	}
+
+	sendResponse(w, map[string]any{
+		"devices":      devices,
+		"needsDevices": pup.DevicesNeedApproval(),
+	})
+}
+
+func (t api) setPupDevices(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("ID")
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error reading request body")
+		return
+	}
+	defer r.Body.Close()
+
+	var req SetPupDevicesRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error unmarshalling JSON")
+		return
+	}
+
+	pup, _, err := t.pups.GetPup(id)
+	if err != nil {
+		sendAPIError(w, http.StatusNotFound, dogeboxd.NewAPIError(dogeboxd.ERROR_PUP_NOT_FOUND, "Pup not found").ForPup(id))
+		return
+	}
+
+	if err := dogeboxd.ValidateApprovedDevices(pup.Manifest, req.Paths); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sendResponse(w, map[string]string{"id": t.dbx.AddAction(dogeboxd.SetPupDevices{PupID: id, Paths: req.Paths})})
+}
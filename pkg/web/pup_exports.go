@@ -0,0 +1,153 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+type ExportPupRequest struct {
+	IncludeStorage bool `json:"includeStorage"`
+}
+
+// exportPup queues an export of a pup, the finished job's result is the
+// export to download, see dogeboxd.PupExportInfo.
+func (t api) exportPup(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("ID")
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error reading request body")
+		return
+	}
+	defer r.Body.Close()
+
+	var req ExportPupRequest
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &req); err != nil {
+			sendErrorResponse(w, http.StatusBadRequest, "Error unmarshalling JSON")
+			return
+		}
+	}
+
+	if _, _, err := t.pups.GetPup(id); err != nil {
+		sendAPIError(w, http.StatusNotFound, dogeboxd.NewAPIError(dogeboxd.ERROR_PUP_NOT_FOUND, "Pup not found").ForPup(id))
+		return
+	}
+
+	sendResponse(w, map[string]string{"id": t.dbx.AddAction(dogeboxd.ExportPup{PupID: id, IncludeStorage: req.IncludeStorage})})
+}
+
+func (t api) listPupExports(w http.ResponseWriter, r *http.Request) {
+	exports, err := dogeboxd.ListPupExports(t.config)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to list pup exports")
+		return
+	}
+
+	sendResponse(w, map[string]any{"exports": exports})
+}
+
+func (t api) downloadPupExport(w http.ResponseWriter, r *http.Request) {
+	info, err := dogeboxd.GetPupExport(t.config, r.PathValue("id"))
+	if errors.Is(err, dogeboxd.ErrPupExportNotFound) {
+		sendErrorResponse(w, http.StatusNotFound, "Pup export not found")
+		return
+	}
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to read pup export")
+		return
+	}
+
+	path := t.config.PupExportPath(info.ID)
+	f, err := os.Open(path)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to open pup export")
+		return
+	}
+	defer f.Close()
+
+	downloadName := fmt.Sprintf("%s-%s-%s.tar.gz", info.Export.PupName, info.Export.PupVersion, info.ID)
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", downloadName))
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", info.Size))
+	w.Header().Set("Cache-Control", "no-store")
+
+	if _, err := io.Copy(w, f); err != nil {
+		log.Printf("Error streaming pup export %s: %v", path, err)
+	}
+}
+
+func (t api) deletePupExport(w http.ResponseWriter, r *http.Request) {
+	exportID := r.PathValue("id")
+	err := dogeboxd.DeletePupExport(t.config, exportID)
+	if errors.Is(err, dogeboxd.ErrPupExportNotFound) {
+		sendErrorResponse(w, http.StatusNotFound, "Pup export not found")
+		return
+	}
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to delete pup export")
+		return
+	}
+
+	sendResponse(w, map[string]any{
+		"success": true,
+		"deleted": exportID,
+	})
+}
+
+/* importPup takes an export downloaded from another Dogebox as the
+ * request body, and queues installing it, ie:
+ *
+ *	curl -X POST --data-binary @core-1.0.0.tar.gz .../pup/import
+ */
+func (t api) importPup(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	session, sessionOK := getKeySession(w, r)
+	if !sessionOK {
+		return
+	}
+
+	exportID, err := dogeboxd.NewPupExportID()
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to create pup export ID")
+		return
+	}
+
+	if err := os.MkdirAll(t.config.PupExportDir(), 0750); err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to create pup export directory")
+		return
+	}
+
+	path := t.config.PupExportPath(exportID)
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0640)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to save pup export")
+		return
+	}
+	_, err = io.Copy(out, r.Body)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		sendErrorResponse(w, http.StatusBadRequest, "Failed to receive pup export")
+		return
+	}
+
+	info, err := dogeboxd.GetPupExport(t.config, exportID)
+	if err != nil {
+		os.Remove(path)
+		sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Invalid pup export: %v", err))
+		return
+	}
+
+	id := t.dbx.AddAction(dogeboxd.ImportPup{ExportID: exportID, SessionToken: session.DKM_TOKEN})
+	sendResponse(w, map[string]any{"id": id, "export": info})
+}
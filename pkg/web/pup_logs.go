@@ -0,0 +1,105 @@
+package web
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+func (t api) getPupLogRetention(w http.ResponseWriter, r *http.Request) {
+	sendResponse(w, map[string]any{
+		"retention": t.sm.Get().Dogebox.PupLogRetention,
+		"defaults":  dogeboxd.PupLogRetention{}.WithDefaults(),
+	})
+}
+
+// setGlobalPupLogRetention updates the retention for pups that don't set
+// their own, rotating and pruning straight away so the change is visible.
+func (t api) setGlobalPupLogRetention(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error reading request body")
+		return
+	}
+	defer r.Body.Close()
+
+	var req dogeboxd.PupLogRetention
+	if err := json.Unmarshal(body, &req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error unmarshalling JSON")
+		return
+	}
+	if err := req.Validate(); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	dbxState := t.sm.Get().Dogebox
+	dbxState.PupLogRetention = req
+	if err := t.sm.SetDogebox(dbxState); err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Error saving pup log retention")
+		return
+	}
+
+	pruned := 0
+	if t.dbx.PupLogRotator != nil {
+		pruned = t.dbx.PupLogRotator.Rotate()
+	}
+
+	sendResponse(w, map[string]any{
+		"success":   true,
+		"retention": req,
+		"pruned":    pruned,
+	})
+}
+
+type SetPupLogRetentionRequest struct {
+	Retention *dogeboxd.PupLogRetention `json:"retention"` // null to use the global retention
+}
+
+func (t api) setPupLogRetention(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("ID")
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error reading request body")
+		return
+	}
+	defer r.Body.Close()
+
+	var req SetPupLogRetentionRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error unmarshalling JSON")
+		return
+	}
+
+	if req.Retention != nil {
+		if err := req.Retention.Validate(); err != nil {
+			sendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	if _, _, err := t.pups.GetPup(id); err != nil {
+		sendAPIError(w, http.StatusNotFound, dogeboxd.NewAPIError(dogeboxd.ERROR_PUP_NOT_FOUND, "Pup not found").ForPup(id))
+		return
+	}
+
+	sendResponse(w, map[string]string{"id": t.dbx.AddAction(dogeboxd.SetPupLogRetention{PupID: id, Retention: req.Retention})})
+}
+
+// getPupLogUsage reports how much disk each installed pup's logs are
+// using, largest first.
+func (t api) getPupLogUsage(w http.ResponseWriter, r *http.Request) {
+	if t.dbx.PupLogRotator == nil {
+		sendResponse(w, map[string]any{"usage": []dogeboxd.PupLogUsage{}})
+		return
+	}
+
+	usage, err := t.dbx.PupLogRotator.Usage()
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Error reading pup log usage")
+		return
+	}
+	sendResponse(w, map[string]any{"usage": usage})
+}
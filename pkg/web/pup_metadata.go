@@ -0,0 +1,24 @@
+package web
+
+import (
+	"net/http"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/Dogebox-WG/dogeboxd/pkg/version"
+)
+
+// GET /dbx/metadata - The calling pup's metadata, see dogeboxd.PupMetadata.
+func (t InternalRouter) getPupMetadata(w http.ResponseWriter, r *http.Request) {
+	originPup, ok := t.getOriginPup(r)
+	if !ok {
+		// you must be a pup!
+		forbidden(w, "You are not a Pup we know about")
+		return
+	}
+
+	box := dogeboxd.PupMetadataBox{
+		Architecture: dogeboxd.CurrentArchitecture(),
+		Version:      version.GetDBXRelease().Release,
+	}
+	sendResponse(w, dogeboxd.BuildPupMetadata(originPup, t.pm.GetStateMap(), box))
+}
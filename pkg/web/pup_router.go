@@ -33,7 +33,10 @@ type InternalRouter struct {
 
 func (t InternalRouter) routes() {
 	t.dbxmux.HandleFunc("POST /dbx/metrics", t.recordMetrics)
+	t.dbxmux.HandleFunc("POST /dbx/status", t.recordPupStatus)
 	t.dbxmux.HandleFunc("/dbx/hook/{hookID}", t.hookHandler)
+	t.dbxmux.HandleFunc("POST /dbx/sso/verify", t.verifyWebUISSO)
+	t.dbxmux.HandleFunc("GET /dbx/metadata", t.getPupMetadata)
 	// TODO: this api needs rethinking
 	// t.dbxmux.HandleFunc("POST /dbx/keys/getDelegatedKeys", t.getDelegatedPupKeys)
 }
@@ -2,6 +2,7 @@ package web
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"strings"
@@ -55,6 +56,15 @@ func (t api) checkPupUpdates(w http.ResponseWriter, r *http.Request) {
 // UpgradePupRequest is the request body for the upgrade endpoint
 type UpgradePupRequest struct {
 	TargetVersion string `json:"targetVersion"`
+	// Restart pups that depend on this one once it's back up.
+	RestartDependents bool `json:"restartDependents,omitempty"`
+	// Roll the upgrade back unless the pup is healthy for this long, see PupCanary.
+	CanaryMinutes int `json:"canaryMinutes,omitempty"`
+	// Upgrade the pup's other instances once it passes its canary.
+	UpgradeInstances bool `json:"upgradeInstances,omitempty"`
+	// Upgrade from, and pin the pup to, a git commit, which
+	// targetVersion must be at. Pinned pups can only be upgraded so.
+	Commit string `json:"commit,omitempty"`
 }
 
 // POST /pup/:pupId/upgrade - Trigger pup upgrade
@@ -74,31 +84,52 @@ func (t api) upgradePup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := dogeboxd.ValidatePupCanaryMinutes(req.CanaryMinutes); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.UpgradeInstances && req.CanaryMinutes == 0 {
+		sendErrorResponse(w, http.StatusBadRequest, "upgradeInstances needs canaryMinutes")
+		return
+	}
+	if err := dogeboxd.ValidateGitCommit(req.Commit); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	// Get the pup to find its source
 	pup, _, err := t.pups.GetPup(pupID)
 	if err != nil {
-		sendErrorResponse(w, http.StatusNotFound, "Pup not found")
+		sendAPIError(w, http.StatusNotFound, dogeboxd.NewAPIError(dogeboxd.ERROR_PUP_NOT_FOUND, "Pup not found").ForPup(pupID))
 		return
 	}
 
-	// Verify the target version is available
-	updateInfo, ok := t.dbx.PupUpdateChecker.GetCachedUpdateInfo(pupID)
-	if !ok {
-		sendErrorResponse(w, http.StatusBadRequest, "No update information available. Check for updates first.")
+	if pup.Source.Commit != "" && req.Commit == "" {
+		sendErrorResponse(w, http.StatusBadRequest, "Pup is pinned to a commit, upgrade it with another commit")
 		return
 	}
 
-	// Check that target version exists in available versions
-	versionFound := false
-	for _, v := range updateInfo.AvailableVersions {
-		if v.Version == req.TargetVersion {
-			versionFound = true
-			break
+	// Verify the target version is available, at a commit that's
+	// checked once the manifest is fetched.
+	if req.Commit == "" {
+		updateInfo, ok := t.dbx.PupUpdateChecker.GetCachedUpdateInfo(pupID)
+		if !ok {
+			sendErrorResponse(w, http.StatusBadRequest, "No update information available. Check for updates first.")
+			return
+		}
+
+		// Check that target version exists in available versions
+		versionFound := false
+		for _, v := range updateInfo.AvailableVersions {
+			if v.Version == req.TargetVersion {
+				versionFound = true
+				break
+			}
+		}
+		if !versionFound {
+			sendErrorResponse(w, http.StatusBadRequest, "Target version not available")
+			return
 		}
-	}
-	if !versionFound {
-		sendErrorResponse(w, http.StatusBadRequest, "Target version not available")
-		return
 	}
 
 	// Trigger upgrade action
@@ -106,12 +137,43 @@ func (t api) upgradePup(w http.ResponseWriter, r *http.Request) {
 		PupID:         pupID,
 		TargetVersion: req.TargetVersion,
 		SourceId:      pup.Source.ID,
+		Commit:        req.Commit,
+
+		RestartDependents: req.RestartDependents,
+		CanaryMinutes:     req.CanaryMinutes,
+		UpgradeInstances:  req.UpgradeInstances,
 	})
 
 	log.Printf("upgradePup: triggered upgrade for pup %s to version %s (jobId: %s)", pupID, req.TargetVersion, jobID)
 	sendResponse(w, map[string]string{"jobId": jobID})
 }
 
+// GET /pup/:pupId/upgrade-preview?version= - What upgrading to a version
+// would change, without changing anything
+func (t api) previewPupUpgrade(w http.ResponseWriter, r *http.Request) {
+	pupID := r.PathValue("pupId")
+	version := r.URL.Query().Get("version")
+	if version == "" {
+		sendErrorResponse(w, http.StatusBadRequest, "version is required")
+		return
+	}
+
+	pup, _, err := t.pups.GetPup(pupID)
+	if err != nil {
+		sendAPIError(w, http.StatusNotFound, dogeboxd.NewAPIError(dogeboxd.ERROR_PUP_NOT_FOUND, "Pup not found").ForPup(pupID))
+		return
+	}
+
+	manifest, _, err := t.sources.GetSourceManifest(pup.Source.ID, pup.Manifest.Meta.Name, version)
+	if err != nil {
+		sendAPIError(w, http.StatusNotFound, dogeboxd.NewAPIError(dogeboxd.ERROR_NOT_FOUND, fmt.Sprintf("Version %s of %s not found in its source", version, pup.Manifest.Meta.Name)).ForPup(pupID))
+		return
+	}
+
+	interfaces := t.dbx.PupUpdateChecker.DetectInterfaceChanges(pup.Manifest, manifest)
+	sendResponse(w, dogeboxd.NewUpgradePreview(pup, manifest, interfaces))
+}
+
 // POST /pup/:pupId/rollback - Rollback to previous version
 func (t api) rollbackPup(w http.ResponseWriter, r *http.Request) {
 	pupID := strings.TrimPrefix(r.URL.Path, "/pup/")
@@ -120,7 +182,7 @@ func (t api) rollbackPup(w http.ResponseWriter, r *http.Request) {
 	// Verify pup exists
 	_, _, err := t.pups.GetPup(pupID)
 	if err != nil {
-		sendErrorResponse(w, http.StatusNotFound, "Pup not found")
+		sendAPIError(w, http.StatusNotFound, dogeboxd.NewAPIError(dogeboxd.ERROR_PUP_NOT_FOUND, "Pup not found").ForPup(pupID))
 		return
 	}
 
@@ -141,7 +203,7 @@ func (t api) getPreviousVersion(w http.ResponseWriter, r *http.Request) {
 	// Verify pup exists
 	pup, _, err := t.pups.GetPup(pupID)
 	if err != nil {
-		sendErrorResponse(w, http.StatusNotFound, "Pup not found")
+		sendAPIError(w, http.StatusNotFound, dogeboxd.NewAPIError(dogeboxd.ERROR_PUP_NOT_FOUND, "Pup not found").ForPup(pupID))
 		return
 	}
 
@@ -204,7 +266,7 @@ func (t api) skipPupUpdate(w http.ResponseWriter, r *http.Request) {
 	// Verify pup exists
 	_, _, err := t.pups.GetPup(pupID)
 	if err != nil {
-		sendErrorResponse(w, http.StatusNotFound, "Pup not found")
+		sendAPIError(w, http.StatusNotFound, dogeboxd.NewAPIError(dogeboxd.ERROR_PUP_NOT_FOUND, "Pup not found").ForPup(pupID))
 		return
 	}
 
@@ -216,7 +278,7 @@ func (t api) skipPupUpdate(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Skip the update by storing the latest version in the pup state
-	_, err = t.pups.UpdatePup(pupID, dogeboxd.SetPupSkippedVersion(updateInfo.LatestVersion))
+	_, err = t.pups.UpdatePup(pupID, dogeboxd.SetPupSkippedVersion(updateInfo.LatestVersion), dogeboxd.WithPupdateReason(dogeboxd.PupdateReason{Actor: dogeboxd.JOB_ACTOR_USER}))
 	if err != nil {
 		sendErrorResponse(w, http.StatusInternalServerError, "Failed to skip update")
 		return
@@ -232,7 +294,7 @@ func (t api) clearSkippedUpdate(w http.ResponseWriter, r *http.Request) {
 	pupID = strings.TrimSuffix(pupID, "/skip-update")
 
 	// Clear the skip status by setting SkippedVersion to empty
-	_, err := t.pups.UpdatePup(pupID, dogeboxd.SetPupSkippedVersion(""))
+	_, err := t.pups.UpdatePup(pupID, dogeboxd.SetPupSkippedVersion(""), dogeboxd.WithPupdateReason(dogeboxd.PupdateReason{Actor: dogeboxd.JOB_ACTOR_USER}))
 	if err != nil {
 		sendErrorResponse(w, http.StatusInternalServerError, "Failed to clear skip status")
 		return
@@ -241,3 +303,46 @@ func (t api) clearSkippedUpdate(w http.ResponseWriter, r *http.Request) {
 	log.Printf("clearSkippedUpdate: cleared skip status for pup %s", pupID)
 	sendResponse(w, map[string]string{"status": "success"})
 }
+
+// POST /pup/:pupId/hold - Hold a pup at its version, until released
+func (t api) holdPupUpdates(w http.ResponseWriter, r *http.Request) {
+	pupID := strings.TrimPrefix(r.URL.Path, "/pup/")
+	pupID = strings.TrimSuffix(pupID, "/hold")
+
+	// Verify pup exists
+	_, _, err := t.pups.GetPup(pupID)
+	if err != nil {
+		sendAPIError(w, http.StatusNotFound, dogeboxd.NewAPIError(dogeboxd.ERROR_PUP_NOT_FOUND, "Pup not found").ForPup(pupID))
+		return
+	}
+
+	_, err = t.pups.UpdatePup(pupID, dogeboxd.SetPupUpdateHold(true), dogeboxd.WithPupdateReason(dogeboxd.PupdateReason{Actor: dogeboxd.JOB_ACTOR_USER}))
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to hold pup")
+		return
+	}
+
+	// Drop any update we'd already found so the badge goes away
+	t.dbx.PupUpdateChecker.ClearCacheEntry(pupID)
+
+	log.Printf("holdPupUpdates: holding pup %s at its current version", pupID)
+	sendResponse(w, map[string]string{"status": "success"})
+}
+
+// DELETE /pup/:pupId/hold - Release a held pup
+func (t api) releasePupHold(w http.ResponseWriter, r *http.Request) {
+	pupID := strings.TrimPrefix(r.URL.Path, "/pup/")
+	pupID = strings.TrimSuffix(pupID, "/hold")
+
+	_, err := t.pups.UpdatePup(pupID, dogeboxd.SetPupUpdateHold(false), dogeboxd.WithPupdateReason(dogeboxd.PupdateReason{Actor: dogeboxd.JOB_ACTOR_USER}))
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to release hold")
+		return
+	}
+
+	// Pick up whatever was released while it was held
+	go t.dbx.PupUpdateChecker.CheckForUpdates(pupID)
+
+	log.Printf("releasePupHold: released hold on pup %s", pupID)
+	sendResponse(w, map[string]string{"status": "success"})
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 
 	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
 )
@@ -27,7 +28,7 @@ func (t api) updateConfig(w http.ResponseWriter, r *http.Request) {
 
 	pupState, _, err := t.pups.GetPup(pupid)
 	if err != nil {
-		sendErrorResponse(w, http.StatusBadRequest, "Cannot find pup")
+		sendAPIError(w, http.StatusBadRequest, dogeboxd.NewAPIError(dogeboxd.ERROR_PUP_NOT_FOUND, "Cannot find pup").ForPup(pupid))
 		return
 	}
 
@@ -42,6 +43,24 @@ func (t api) updateConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := dogeboxd.ValidateConfigValues(pupState.Manifest.Config, normalized); err != nil {
+		fields, _ := err.(dogeboxd.ConfigFieldErrors)
+		sendAPIError(w, http.StatusBadRequest, dogeboxd.NewAPIError(dogeboxd.ERROR_INVALID_CONFIG, err.Error()).WithFields(fields).ForPup(pupid))
+		return
+	}
+
+	// Sessions from before a restart haven't unlocked the secrets yet.
+	if _, secrets := dogeboxd.SplitSecretConfig(pupState.Manifest.Config, normalized); len(secrets) > 0 && !t.dbx.ConfigSecrets.Unlocked() {
+		session, ok := getKeySession(w, r)
+		if !ok {
+			return
+		}
+		if err := t.dbx.ConfigSecrets.Unlock(session.DKM_TOKEN); err != nil {
+			sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
 	id := t.dbx.AddAction(dogeboxd.UpdatePupConfig{PupID: pupid, Payload: normalized})
 	sendResponse(w, map[string]string{"id": id})
 }
@@ -83,30 +102,10 @@ type InstallPupRequest struct {
 	SessionToken            string
 	AutoInstallDependencies bool `json:"autoInstallDependencies"`
 	EnableDevMode           bool `json:"installWithDevModeEnabled"`
-}
-
-// calculateDependencies creates a temporary pup state and calculates its dependencies
-func (t api) calculateDependencies(sourceId, pupName, pupVersion string) (*dogeboxd.PupState, []dogeboxd.PupDependencyReport, error) {
-	// Create a temporary pup - this will be used to calculate dependencies
-	manifest, source, err := t.sources.GetSourceManifest(sourceId, pupName, pupVersion)
-	if err != nil {
-		return nil, nil, fmt.Errorf("couldn't get manifest: %s", err)
-	}
-
-	// Create a temporary pup state to calculate dependencies
-	tempPup := &dogeboxd.PupState{
-		ID:       fmt.Sprintf("%s-%s", manifest.Meta.Name, manifest.Meta.Version),
-		Manifest: manifest,
-		Source:   source.Config(),
-	}
-
-	// Get all dependencies that need to be installed
-	deps, err := t.pups.CalculateDeps(tempPup.ID)
-	if err != nil {
-		return nil, nil, fmt.Errorf("couldn't calculate dependencies: %s", err)
-	}
-
-	return tempPup, deps, nil
+	// Set to install another instance of a pup that's already installed.
+	InstanceName string `json:"instanceName,omitempty"`
+	// Set to install from, and pin the pup to, a git commit.
+	Commit string `json:"commit,omitempty"`
 }
 
 func (t api) installPup(w http.ResponseWriter, r *http.Request) {
@@ -124,60 +123,34 @@ func (t api) installPup(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	session, sessionOK := getSession(r, getBearerToken)
+	session, sessionOK := getKeySession(w, r)
 	if !sessionOK {
-		sendErrorResponse(w, http.StatusBadRequest, "Failed to fetch session")
 		return
 	}
 	req.SessionToken = session.DKM_TOKEN
 
-	// If auto-install is enabled, determine dependencies
-	if req.AutoInstallDependencies {
-		_, deps, err := t.calculateDependencies(req.SourceId, req.PupName, req.PupVersion)
-		if err != nil {
-			sendErrorResponse(w, http.StatusBadRequest, err.Error())
-			return
-		}
-
-		// Add the batch installation action
-		id := t.dbx.AddAction(dogeboxd.InstallPup{
-			PupName:    req.PupName,
-			PupVersion: req.PupVersion,
-			SourceId:   req.SourceId,
-			Options: dogeboxd.AdoptPupOptions{
-				DevMode: req.EnableDevMode,
-			},
-			SessionToken: req.SessionToken,
-		})
-
-		// Add installation actions for dependencies
-		for _, dep := range deps {
-			if dep.CurrentProvider == "" {
-				// Use the first available provider for each dependency
-				if len(dep.InstallableProviders) > 0 {
-					provider := dep.InstallableProviders[0]
-					// Use the same source ID as the main pup for dependencies
-					t.dbx.AddAction(dogeboxd.InstallPup{
-						PupName:      provider.PupName,
-						PupVersion:   provider.PupVersion,
-						SourceId:     req.SourceId, // Use the same source ID as the main pup
-						SessionToken: req.SessionToken,
-					})
-				}
-			}
-		}
-
-		sendResponse(w, map[string]string{"id": id})
+	if req.EnableDevMode && !t.sm.Get().Dogebox.Profile.AllowsDevModeActions() {
+		sendErrorResponse(w, http.StatusForbidden, "Development mode isn't available on production boxes")
+		return
+	}
+	if err := dogeboxd.ValidatePupInstanceName(req.InstanceName); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := dogeboxd.ValidateGitCommit(req.Commit); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	// If auto-install is disabled, just install the main pup
 	id := t.dbx.AddAction(dogeboxd.InstallPup{
 		PupName:    req.PupName,
 		PupVersion: req.PupVersion,
 		SourceId:   req.SourceId,
+		Commit:     req.Commit,
 		Options: dogeboxd.AdoptPupOptions{
-			DevMode: req.EnableDevMode,
+			DevMode:             req.EnableDevMode,
+			InstanceName:        req.InstanceName,
+			InstallDependencies: req.AutoInstallDependencies,
 		},
 		SessionToken: req.SessionToken,
 	})
@@ -196,13 +169,29 @@ func (t api) pupAction(w http.ResponseWriter, r *http.Request) {
 	var a dogeboxd.Action
 	switch action {
 	case "uninstall":
-		a = dogeboxd.UninstallPup{PupID: id}
+		uninstall, ok := t.uninstallPupAction(w, r, id)
+		if !ok {
+			return
+		}
+		a = uninstall
 	case "purge":
 		a = dogeboxd.PurgePup{PupID: id}
 	case "enable":
 		a = dogeboxd.EnablePup{PupID: id}
 	case "disable":
 		a = dogeboxd.DisablePup{PupID: id}
+	case "start":
+		a = dogeboxd.StartPup{PupID: id}
+	case "enable-autostart":
+		a = dogeboxd.SetPupAutoStart{PupID: id, AutoStart: true}
+	case "disable-autostart":
+		a = dogeboxd.SetPupAutoStart{PupID: id, AutoStart: false}
+	case "rebuild":
+		if !t.sm.Get().Dogebox.Profile.AllowsDevModeActions() {
+			sendErrorResponse(w, http.StatusForbidden, "Development mode isn't available on production boxes")
+			return
+		}
+		a = dogeboxd.RebuildDevPup{PupID: id}
 	default:
 		sendErrorResponse(w, http.StatusNotFound, fmt.Sprintf("No pup action %s", action))
 		return
@@ -211,6 +200,383 @@ func (t api) pupAction(w http.ResponseWriter, r *http.Request) {
 	sendResponse(w, map[string]string{"id": t.dbx.AddAction(a)})
 }
 
+type UninstallPupRequest struct {
+	// Providers to uninstall too, from GET /pup/{ID}/orphaned-providers.
+	RemoveProviders []string `json:"removeProviders"`
+}
+
+// uninstallPupAction reads the optional body of an uninstall, checking
+// the providers to remove with it are ones it orphans.
+func (t api) uninstallPupAction(w http.ResponseWriter, r *http.Request, id string) (dogeboxd.UninstallPup, bool) {
+	a := dogeboxd.UninstallPup{PupID: id}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error reading request body")
+		return a, false
+	}
+	defer r.Body.Close()
+	if len(body) == 0 {
+		return a, true
+	}
+
+	var req UninstallPupRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error unmarshalling JSON")
+		return a, false
+	}
+
+	orphans := map[string]bool{}
+	for _, orphan := range dogeboxd.FindOrphanedProviders(t.pups.GetStateMap(), id) {
+		orphans[orphan] = true
+	}
+	for _, provider := range req.RemoveProviders {
+		if !orphans[provider] {
+			sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Pup %s isn't left unused by this uninstall", provider))
+			return a, false
+		}
+	}
+	a.RemoveProviders = req.RemoveProviders
+	return a, true
+}
+
+type OrphanedProvider struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Lists the providers left unused if a pup is uninstalled, for the
+// dPanel to ask about removing them too.
+func (t api) getOrphanedProviders(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("ID")
+	states := t.pups.GetStateMap()
+	if _, ok := states[id]; !ok {
+		sendAPIError(w, http.StatusNotFound, dogeboxd.NewAPIError(dogeboxd.ERROR_PUP_NOT_FOUND, "Pup not found").ForPup(id))
+		return
+	}
+
+	providers := []OrphanedProvider{}
+	for _, orphan := range dogeboxd.FindOrphanedProviders(states, id) {
+		providers = append(providers, OrphanedProvider{ID: orphan, Name: states[orphan].DisplayName()})
+	}
+	sendResponse(w, map[string]any{"providers": providers})
+}
+
+type BulkPupActionRequest struct {
+	Operation string   `json:"operation"` // enable, disable or upgrade
+	PupIDs    []string `json:"pupIds"`
+	// For upgrades, the version to take each pup to by ID, pups without
+	// one go to the latest version found by the last update check.
+	TargetVersions map[string]string `json:"targetVersions,omitempty"`
+}
+
+// bulkPupAction enables, disables or upgrades several pups in one job,
+// with a single rebuild, see dogeboxd.BulkPupAction.
+func (t api) bulkPupAction(w http.ResponseWriter, r *http.Request) {
+	var req BulkPupActionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error decoding request body")
+		return
+	}
+
+	a := dogeboxd.BulkPupAction{Operation: req.Operation, PupIDs: req.PupIDs}
+	if req.Operation == dogeboxd.BULK_PUP_UPGRADE {
+		a.TargetVersions = map[string]string{}
+		for _, id := range req.PupIDs {
+			version := req.TargetVersions[id]
+			if version == "" {
+				info, ok := t.dbx.PupUpdateChecker.GetCachedUpdateInfo(id)
+				if !ok || !info.UpdateAvailable {
+					sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("No update available for pup %s", id))
+					return
+				}
+				version = info.LatestVersion
+			}
+			a.TargetVersions[id] = version
+		}
+	}
+
+	if err := a.Validate(); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	for _, id := range req.PupIDs {
+		if _, _, err := t.pups.GetPup(id); err != nil {
+			sendAPIError(w, http.StatusNotFound, dogeboxd.NewAPIError(dogeboxd.ERROR_PUP_NOT_FOUND, "Pup not found").ForPup(id))
+			return
+		}
+	}
+
+	sendResponse(w, map[string]string{"id": t.dbx.AddAction(a)})
+}
+
+type SetPupRestartScheduleRequest struct {
+	Schedule string `json:"schedule"` // systemd calendar expression, empty to clear
+}
+
+func (t api) setPupRestartSchedule(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("ID")
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error reading request body")
+		return
+	}
+	defer r.Body.Close()
+
+	var req SetPupRestartScheduleRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error unmarshalling JSON")
+		return
+	}
+
+	if err := dogeboxd.ValidateRestartSchedule(req.Schedule); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if _, _, err := t.pups.GetPup(id); err != nil {
+		sendAPIError(w, http.StatusNotFound, dogeboxd.NewAPIError(dogeboxd.ERROR_PUP_NOT_FOUND, "Pup not found").ForPup(id))
+		return
+	}
+
+	sendResponse(w, map[string]string{"id": t.dbx.AddAction(dogeboxd.SetPupRestartSchedule{PupID: id, Schedule: req.Schedule})})
+}
+
+type SetPupMaintenanceRequest struct {
+	Enabled bool   `json:"enabled"`
+	Reason  string `json:"reason"`
+}
+
+func (t api) setPupMaintenance(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("ID")
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error reading request body")
+		return
+	}
+	defer r.Body.Close()
+
+	var req SetPupMaintenanceRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error unmarshalling JSON")
+		return
+	}
+
+	if _, _, err := t.pups.GetPup(id); err != nil {
+		sendAPIError(w, http.StatusNotFound, dogeboxd.NewAPIError(dogeboxd.ERROR_PUP_NOT_FOUND, "Pup not found").ForPup(id))
+		return
+	}
+
+	sendResponse(w, map[string]string{"id": t.dbx.AddAction(dogeboxd.SetPupMaintenance{PupID: id, Enabled: req.Enabled, Reason: req.Reason})})
+}
+
+// getPupResourceLimits shows what a pup's manifest asks for, the user's
+// override if any, and what the pup actually runs with.
+func (t api) getPupResourceLimits(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("ID")
+	pup, _, err := t.pups.GetPup(id)
+	if err != nil {
+		sendAPIError(w, http.StatusNotFound, dogeboxd.NewAPIError(dogeboxd.ERROR_PUP_NOT_FOUND, "Pup not found").ForPup(id))
+		return
+	}
+
+	sendResponse(w, map[string]any{
+		"manifest":  pup.Manifest.Container.ResourceLimits,
+		"override":  pup.ResourceLimitsOverride,
+		"effective": pup.ResourceLimits(),
+	})
+}
+
+type SetPupResourceLimitsRequest struct {
+	Limits *dogeboxd.PupResourceLimits `json:"limits"` // null to go back to the manifest's limits
+}
+
+func (t api) setPupResourceLimits(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("ID")
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error reading request body")
+		return
+	}
+	defer r.Body.Close()
+
+	var req SetPupResourceLimitsRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error unmarshalling JSON")
+		return
+	}
+
+	if req.Limits != nil {
+		if err := req.Limits.Validate(); err != nil {
+			sendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	if _, _, err := t.pups.GetPup(id); err != nil {
+		sendAPIError(w, http.StatusNotFound, dogeboxd.NewAPIError(dogeboxd.ERROR_PUP_NOT_FOUND, "Pup not found").ForPup(id))
+		return
+	}
+
+	sendResponse(w, map[string]string{"id": t.dbx.AddAction(dogeboxd.SetPupResourceLimits{PupID: id, Limits: req.Limits})})
+}
+
+type SetPupStorageQuotaRequest struct {
+	QuotaMB int `json:"quotaMb"` // 0 to remove the quota
+}
+
+func (t api) setPupStorageQuota(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("ID")
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error reading request body")
+		return
+	}
+	defer r.Body.Close()
+
+	var req SetPupStorageQuotaRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error unmarshalling JSON")
+		return
+	}
+
+	if err := dogeboxd.ValidatePupStorageQuota(req.QuotaMB); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if _, _, err := t.pups.GetPup(id); err != nil {
+		sendAPIError(w, http.StatusNotFound, dogeboxd.NewAPIError(dogeboxd.ERROR_PUP_NOT_FOUND, "Pup not found").ForPup(id))
+		return
+	}
+
+	sendResponse(w, map[string]string{"id": t.dbx.AddAction(dogeboxd.SetPupStorageQuota{PupID: id, QuotaMB: req.QuotaMB})})
+}
+
+type SetPupAutoUpdateRequest struct {
+	AutoUpdate *dogeboxd.PupAutoUpdate `json:"autoUpdate"` // null to stop auto-updating
+}
+
+func (t api) setPupAutoUpdate(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("ID")
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error reading request body")
+		return
+	}
+	defer r.Body.Close()
+
+	var req SetPupAutoUpdateRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error unmarshalling JSON")
+		return
+	}
+
+	if req.AutoUpdate != nil {
+		if err := req.AutoUpdate.Validate(); err != nil {
+			sendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	if _, _, err := t.pups.GetPup(id); err != nil {
+		sendAPIError(w, http.StatusNotFound, dogeboxd.NewAPIError(dogeboxd.ERROR_PUP_NOT_FOUND, "Pup not found").ForPup(id))
+		return
+	}
+
+	sendResponse(w, map[string]string{"id": t.dbx.AddAction(dogeboxd.SetPupAutoUpdate{PupID: id, AutoUpdate: req.AutoUpdate})})
+}
+
+type SetPupLogLevelRequest struct {
+	Debug bool `json:"debug"`
+	// How long to keep debug logging on, defaults to an hour.
+	DurationMinutes int `json:"durationMinutes,omitempty"`
+}
+
+func (t api) setPupLogLevel(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("ID")
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error reading request body")
+		return
+	}
+	defer r.Body.Close()
+
+	var req SetPupLogLevelRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error unmarshalling JSON")
+		return
+	}
+
+	duration := time.Duration(req.DurationMinutes) * time.Minute
+	if err := dogeboxd.ValidatePupDebugLogDuration(duration); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	pup, _, err := t.pups.GetPup(id)
+	if err != nil {
+		sendAPIError(w, http.StatusNotFound, dogeboxd.NewAPIError(dogeboxd.ERROR_PUP_NOT_FOUND, "Pup not found").ForPup(id))
+		return
+	}
+	if pup.Manifest.Config.LogLevel == nil {
+		sendErrorResponse(w, http.StatusBadRequest, dogeboxd.ErrNoLogLevelKnob.Error())
+		return
+	}
+
+	sendResponse(w, map[string]string{"id": t.dbx.AddAction(dogeboxd.SetPupLogLevel{PupID: id, Debug: req.Debug, Duration: duration})})
+}
+
+type SetPupEnvOverridesRequest struct {
+	// Replaces all of the pup's overrides, empty clearing them.
+	Env map[string]string `json:"env"`
+}
+
+// Lists a pup's environment overrides, kept apart from its config.
+func (t api) getPupEnvOverrides(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("ID")
+	pup, _, err := t.pups.GetPup(id)
+	if err != nil {
+		sendAPIError(w, http.StatusNotFound, dogeboxd.NewAPIError(dogeboxd.ERROR_PUP_NOT_FOUND, "Pup not found").ForPup(id))
+		return
+	}
+
+	env := pup.EnvOverrides
+	if env == nil {
+		env = map[string]string{}
+	}
+	sendResponse(w, map[string]any{"env": env})
+}
+
+func (t api) setPupEnvOverrides(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("ID")
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error reading request body")
+		return
+	}
+	defer r.Body.Close()
+
+	var req SetPupEnvOverridesRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error unmarshalling JSON")
+		return
+	}
+
+	pup, _, err := t.pups.GetPup(id)
+	if err != nil {
+		sendAPIError(w, http.StatusNotFound, dogeboxd.NewAPIError(dogeboxd.ERROR_PUP_NOT_FOUND, "Pup not found").ForPup(id))
+		return
+	}
+	if err := dogeboxd.ValidatePupEnvOverrides(pup.Manifest, req.Env); err != nil {
+		fields, _ := err.(dogeboxd.ConfigFieldErrors)
+		sendAPIError(w, http.StatusBadRequest, dogeboxd.NewAPIError(dogeboxd.ERROR_INVALID_CONFIG, err.Error()).WithFields(fields).ForPup(id))
+		return
+	}
+
+	sendResponse(w, map[string]string{"id": t.dbx.AddAction(dogeboxd.SetPupEnvOverrides{PupID: id, Env: req.Env})})
+}
+
 func (t api) updateHooks(w http.ResponseWriter, r *http.Request) {
 	pupid := r.PathValue("PupID")
 	body, err := io.ReadAll(r.Body)
@@ -235,7 +601,7 @@ func (t api) getMissingDeps(w http.ResponseWriter, r *http.Request) {
 	pupid := r.PathValue("PupID")
 	deps, err := t.pups.CalculateDeps(pupid)
 	if err != nil {
-		sendErrorResponse(w, http.StatusBadRequest, "Cannot find pup")
+		sendAPIError(w, http.StatusBadRequest, dogeboxd.NewAPIError(dogeboxd.ERROR_PUP_NOT_FOUND, "Cannot find pup").ForPup(pupid))
 		return
 	}
 	// Only include dependencies that are not currently satisfied (no installed provider)
@@ -277,61 +643,24 @@ func (t api) installPups(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	session, sessionOK := getSession(r, getBearerToken)
+	session, sessionOK := getKeySession(w, r)
 	if !sessionOK {
-		sendErrorResponse(w, http.StatusBadRequest, "Failed to fetch session")
 		return
 	}
 
 	// Create batch installation requests
 	installRequests := make([]dogeboxd.InstallPup, 0)
 
-	// Process each pup and its dependencies if auto-install is enabled
 	for _, pup := range req.Pups {
-		pup.SessionToken = session.DKM_TOKEN
-
-		if pup.AutoInstallDependencies {
-			_, deps, err := t.calculateDependencies(pup.SourceId, pup.PupName, pup.PupVersion)
-			if err != nil {
-				sendErrorResponse(w, http.StatusBadRequest, err.Error())
-				return
-			}
-
-			// Add the main pup
-			installRequests = append(installRequests, dogeboxd.InstallPup{
-				PupName:      pup.PupName,
-				PupVersion:   pup.PupVersion,
-				SourceId:     pup.SourceId,
-				Options:      dogeboxd.AdoptPupOptions{},
-				SessionToken: pup.SessionToken,
-			})
-
-			// Add all required dependencies
-			for _, dep := range deps {
-				if dep.CurrentProvider == "" {
-					// Use the first available provider for each dependency
-					if len(dep.InstallableProviders) > 0 {
-						provider := dep.InstallableProviders[0]
-						// Use the same source ID as the main pup for dependencies
-						installRequests = append(installRequests, dogeboxd.InstallPup{
-							PupName:      provider.PupName,
-							PupVersion:   provider.PupVersion,
-							SourceId:     pup.SourceId, // Use same source for dependencies
-							Options:      dogeboxd.AdoptPupOptions{},
-							SessionToken: pup.SessionToken,
-						})
-					}
-				}
-			}
-		} else {
-			// Add just the main pup if auto-install is disabled
-			installRequests = append(installRequests, dogeboxd.InstallPup{
-				PupName:      pup.PupName,
-				PupVersion:   pup.PupVersion,
-				SourceId:     pup.SourceId,
-				SessionToken: pup.SessionToken,
-			})
-		}
+		installRequests = append(installRequests, dogeboxd.InstallPup{
+			PupName:    pup.PupName,
+			PupVersion: pup.PupVersion,
+			SourceId:   pup.SourceId,
+			Options: dogeboxd.AdoptPupOptions{
+				InstallDependencies: pup.AutoInstallDependencies,
+			},
+			SessionToken: session.DKM_TOKEN,
+		})
 	}
 
 	id := t.dbx.AddAction(dogeboxd.InstallPups(installRequests))
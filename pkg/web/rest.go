@@ -60,9 +60,17 @@ func RESTAPI(
 
 	// Recovery routes are the _only_ routes loaded in recovery mode.
 	recoveryRoutes := map[string]http.HandlerFunc{
-		"POST /authenticate":    a.authenticate,
-		"POST /logout":          a.logout,
-		"POST /change-password": a.changePassword,
+		"POST /authenticate":               a.authenticate,
+		"POST /authenticate/passkey/begin": a.beginPasskeyAuthentication,
+		"POST /authenticate/passkey":       a.authenticatePasskey,
+		"POST /authenticate/unlock":        a.unlockKeys,
+		"POST /logout":                     a.logout,
+		"POST /change-password":            a.changePassword,
+
+		// So lost passkeys can be removed after a password sign in.
+		"GET /passkeys":         a.getPasskeys,
+		"DELETE /passkeys/{id}": a.deletePasskey,
+		"PUT /passkeys/policy":  a.setPasskeyPolicy,
 
 		"GET /system/bootstrap":          a.getBootstrap,
 		"GET /system/recovery-bootstrap": a.getRecoveryBootstrap,
@@ -82,33 +90,92 @@ func RESTAPI(
 		"PUT /system/network/set-pending": a.setPendingNetwork,
 		"POST /system/network/test":       a.testConnectNetwork,
 		"POST /system/network/connect":    a.connectNetwork,
+		"GET /system/network/ap-mode":     a.getAPModeState,
+		"PUT /system/network/ap-mode":     a.setAPModeState,
+		"GET /system/network/wifi-region": a.getWifiRegulatoryDomain,
+		"PUT /system/network/wifi-region": a.setWifiRegulatoryDomain,
 		"POST /system/host/shutdown":      a.hostShutdown,
 		"POST /system/host/reboot":        a.hostReboot,
 		"POST /keys/create-master":        a.createMasterKey,
 		"GET /keys":                       a.listKeys,
 		"POST /system/bootstrap":          a.initialBootstrap,
 
-		"GET /system/ssh/state":               a.getSSHState,
-		"PUT /system/ssh/state":               a.setSSHState,
-		"GET /system/ssh/keys":                a.listSSHKeys,
-		"PUT /system/ssh/key":                 a.addSSHKey,
-		"DELETE /system/ssh/key/{id}":         a.removeSSHKey,
-		"GET /system/custom-nix":              a.getCustomNix,
-		"PUT /system/custom-nix":              a.saveCustomNix,
-		"POST /system/custom-nix/validate":    a.validateCustomNix,
-		"POST /system/import-blockchain-data": a.importBlockchainData,
-		"/ws/state/":                          a.getUpdateSocket,
-		"/ws/jobs":                            a.getJobsSocket,
-		"/ws/log/job/{JobID}":                 a.getJobLogSocket,
+		"GET /system/ssh/state":                 a.getSSHState,
+		"GET /system/safe-mode":                 a.getSafeModeState,
+		"PUT /system/safe-mode":                 a.setSafeModeState,
+		"PUT /system/ssh/state":                 a.setSSHState,
+		"GET /system/ssh/keys":                  a.listSSHKeys,
+		"PUT /system/ssh/key":                   a.addSSHKey,
+		"DELETE /system/ssh/key/{id}":           a.removeSSHKey,
+		"GET /system/custom-nix":                a.getCustomNix,
+		"GET /system/pending-changes":           a.getPendingChanges,
+		"PUT /system/pending-changes/defer":     a.setDeferRebuilds,
+		"POST /system/pending-changes/apply":    a.applyPendingChanges,
+		"POST /system/pending-changes/discard":  a.discardPendingChanges,
+		"POST /system/pending-changes/diff":     a.diffPendingChanges,
+		"PUT /system/custom-nix":                a.saveCustomNix,
+		"POST /system/custom-nix/validate":      a.validateCustomNix,
+		"POST /system/custom-nix/dry-build":     a.dryBuildCustomNix,
+		"GET /system/nix-backups":               a.listNixConfigBackups,
+		"POST /system/nix-backups/{id}/restore": a.restoreNixConfigBackup,
+		"GET /system/generations":               a.listSystemGenerations,
+		"POST /system/rollback":                 a.rollbackSystem,
+		"GET /system/backups":                   a.listBackups,
+		"GET /system/backups/{id}":              a.getBackup,
+		"DELETE /system/backups/{id}":           a.deleteBackup,
+		"GET /system/state-snapshots":           a.listStateSnapshots,
+		"POST /system/import-blockchain-data":   a.importBlockchainData,
+		"GET /system/drift":                     a.getVersionDrift,
+		"POST /system/drift/reapply":            a.reapplySystemVersion,
+		"GET /system/storage-health":            a.getStorageHealth,
+		"/ws/state/":                            a.getUpdateSocket,
+		"/ws/jobs":                              a.getJobsSocket,
+		"/ws/log/job/{JobID}":                   a.getJobLogSocket,
 	}
 
 	// Normal routes are used when we are not in recovery mode.
 	// nb. These are used in _addition_ to recovery routes.
 	normalRoutes := map[string]http.HandlerFunc{
 		"GET /pup/{ID}/metrics":               a.getPupMetrics,
+		"GET /pup/{ID}/jobs":                  a.getPupJobs,
+		"GET /pup/{ID}/orphaned-providers":    a.getOrphanedProviders,
 		"POST /pup/{ID}/{action}":             a.pupAction,
+		"PUT /pup/{ID}/restart-schedule":      a.setPupRestartSchedule,
+		"PUT /pup/{ID}/maintenance":           a.setPupMaintenance,
+		"GET /pup/{ID}/resource-limits":       a.getPupResourceLimits,
+		"PUT /pup/{ID}/resource-limits":       a.setPupResourceLimits,
+		"PUT /pup/{ID}/storage-quota":         a.setPupStorageQuota,
+		"PUT /pup/{ID}/auto-update":           a.setPupAutoUpdate,
+		"PUT /pup/{ID}/log-retention":         a.setPupLogRetention,
+		"GET /pup/log-retention":              a.getPupLogRetention,
+		"PUT /pup/log-retention":              a.setGlobalPupLogRetention,
+		"GET /pup/log-usage":                  a.getPupLogUsage,
+		"PUT /pup/{ID}/log-level":             a.setPupLogLevel,
+		"GET /pup/{ID}/env":                   a.getPupEnvOverrides,
+		"PUT /pup/{ID}/env":                   a.setPupEnvOverrides,
+		"PUT /pup/{ID}/trusted-cas":           a.setPupTrustedCAs,
+		"GET /pup/{ID}/devices":               a.getPupDevices,
+		"GET /pup/{ID}/about":                 a.getPupAbout,
+		"PUT /pup/{ID}/devices":               a.setPupDevices,
+		"POST /passkeys/register/begin":       a.beginPasskeyRegistration,
+		"POST /passkeys/register":             a.finishPasskeyRegistration,
+		"GET /system/status-page":             a.getStatusPageSettings,
+		"PUT /system/status-page":             a.setStatusPageSettings,
+		"GET /system/profile":                 a.getBoxProfile,
+		"PUT /system/profile":                 a.setBoxProfile,
+		"GET /public/status":                  a.getPublicStatus,
+		"GET /public/status.html":             a.getPublicStatusPage,
+		"POST /pup/{ID}/export":               a.exportPup,
+		"POST /pup/import":                    a.importPup,
+		"POST /pup/install-bundle":            a.installPupBundle,
+		"GET /pup-exports":                    a.listPupExports,
+		"GET /pup-exports/{id}/download":      a.downloadPupExport,
+		"DELETE /pup-exports/{id}":            a.deletePupExport,
 		"PUT /pup":                            a.installPup,
 		"PUT /pups":                           a.installPups,
+		"POST /pups/bulk":                     a.bulkPupAction,
+		"POST /pup/{ID}/webui/{port}/sso":     a.createWebUISSOTicket,
+		"POST /pup/resolve-link":              a.resolveDeepLink,
 		"POST /config/{PupID}":                a.updateConfig,
 		"POST /providers/{PupID}":             a.updateProviders,
 		"GET /providers/{PupID}":              a.getPupProviders,
@@ -116,11 +183,23 @@ func RESTAPI(
 		"GET /sources":                        a.getSources,
 		"PUT /source":                         a.createSource,
 		"GET /sources/store":                  a.getStoreList,
+		"GET /sources/search":                 a.searchSources,
+		"GET /sources/cache":                  a.getSourceCacheStats,
 		"DELETE /source/{id}":                 a.deleteSource,
+		"POST /source/{id}/refresh":           a.refreshSource,
+		"GET /dev/pup/{name}/template":        a.getDevPupTemplate,
+		"POST /dev/pup/{name}/template":       a.applyDevPupTemplate,
+		"POST /dev/pup/lint":                  a.lintPupManifest,
+		"PUT /source/{id}/refresh-interval":   a.setSourceRefreshInterval,
+		"PUT /source/{id}/pin":                a.pinSource,
+		"PUT /source/{id}/priority":           a.setSourcePriority,
+		"PUT /source/{id}/auto-rebuild":       a.setSourceAutoRebuild,
 		"GET /log/pup/{PupID}/download":       a.downloadPupLog,
+		"GET /log/pup/{PupID}/build":          a.downloadPupBuildLog,
 		"GET /log/job/{JobID}/download":       a.downloadJobLog,
 		"GET /log/pup/{PupID}/tail":           a.getPupLogTail,
 		"GET /log/job/{JobID}/tail":           a.getJobLogTail,
+		"GET /log/pup/{PupID}/query":          a.queryPupLogs,
 		"/ws/log/pup/{PupID}":                 a.getPupLogSocket,
 		"/ws/log/job/{JobID}":                 a.getJobLogSocket,
 		"POST /system/welcome-complete":       a.setWelcomeComplete,
@@ -132,38 +211,85 @@ func RESTAPI(
 		"POST /system/sidebar-preferences/pups/add":    a.addSidebarPup,
 		"POST /system/sidebar-preferences/pups/remove": a.removeSidebarPup,
 
+		"GET /system/display-preferences": a.getDisplayPreferences,
+		"PUT /system/display-preferences": a.setDisplayPreferences,
+
 		"GET /system/binary-caches":        a.getBinaryCaches,
 		"PUT /system/binary-cache":         a.addBinaryCache,
 		"DELETE /system/binary-cache/{id}": a.removeBinaryCache,
 
+		"GET /system/nix-gc":      a.getNixGC,
+		"PUT /system/nix-gc":      a.setNixGC,
+		"POST /system/nix-gc/run": a.collectNixGarbage,
+
+		"GET /system/journal-units":         a.getJournalUnits,
+		"POST /system/journal-units":        a.addJournalUnit,
+		"DELETE /system/journal-units/{id}": a.removeJournalUnit,
+
+		"GET /system/trusted-cas":         a.getTrustedCAs,
+		"POST /system/trusted-cas":        a.addTrustedCA,
+		"DELETE /system/trusted-cas/{id}": a.removeTrustedCA,
+
+		"GET /system/inventory": a.getSystemInventory,
+
+		"GET /system/metrics":         a.getInternalMetrics,
+		"GET /system/debug/internals": a.getInternalDebug,
+
+		"GET /system/usage-summaries":         a.listUsageSummaries,
+		"GET /system/usage-summaries/current": a.getCurrentUsageSummary,
+		"GET /system/usage-summaries/{id}":    a.getUsageSummary,
+
 		// Pup update routes
 		"GET /pup/updates":                    a.getAllPupUpdates,
 		"GET /pup/{pupId}/updates":            a.getPupUpdates,
 		"POST /pup/{pupId}/check-pup-updates": a.checkPupUpdates,
 		"POST /pup/{pupId}/upgrade":           a.upgradePup,
+		"GET /pup/{pupId}/upgrade-preview":    a.previewPupUpgrade,
 		"POST /pup/{pupId}/update":            a.updatePup, // Legacy, redirects to upgrade
 		"POST /pup/{pupId}/rollback":          a.rollbackPup,
 		"GET /pup/{pupId}/previous-version":   a.getPreviousVersion,
 		"GET /pup/skipped-updates":            a.getAllSkippedUpdates,
 		"POST /pup/{pupId}/skip-update":       a.skipPupUpdate,
 		"DELETE /pup/{pupId}/skip-update":     a.clearSkippedUpdate,
+		"POST /pup/{pupId}/hold":              a.holdPupUpdates,
+		"DELETE /pup/{pupId}/hold":            a.releasePupHold,
+
+		"GET /system/updates":                a.checkForUpdates,
+		"GET /system/updates/{version}/size": a.getReleaseSize,
+		"POST /system/update":                a.commenceUpdate,
 
-		"GET /system/updates": a.checkForUpdates,
-		"POST /system/update": a.commenceUpdate,
+		// Offline system updates
+		"GET /system/update/bundles":        a.getSystemUpdateBundles,
+		"POST /system/update/bundles/apply": a.applySystemUpdateBundle,
+		"POST /system/update/bundle":        a.uploadSystemUpdateBundle,
 
 		"GET /system/stats":    a.getSystemStats,
 		"GET /system/services": a.getSystemServices,
 
 		// Job management routes
-		"GET /jobs":                  a.getJobs,
-		"GET /jobs/active":           a.getActiveJobs,
-		"GET /jobs/recent":           a.getRecentJobs,
-		"GET /jobs/stats":            a.getJobStats,
-		"GET /jobs/{jobID}":          a.getJob,
-		"DELETE /jobs/{jobID}":       a.deleteJob,
+		"GET /jobs":                              a.getJobs,
+		"GET /jobs/active":                       a.getActiveJobs,
+		"GET /jobs/recent":                       a.getRecentJobs,
+		"GET /jobs/stats":                        a.getJobStats,
+		"GET /jobs/{jobID}":                      a.getJob,
+		"GET /jobs/{jobID}/logs/download":        a.downloadArchivedJobLog,
+		"DELETE /jobs/{jobID}":                   a.deleteJob,
 		"POST /jobs/dev/create-orphan-candidate": a.createOrphanCandidateJob,
-		"POST /jobs/clear-completed": a.clearCompletedJobs,
-		"POST /jobs/clear-all":       a.clearAllJobs,
+		"POST /jobs/clear-completed":             a.clearCompletedJobs,
+		"GET /jobs/log-retention":                a.getJobLogRetention,
+		"PUT /jobs/log-retention":                a.setJobLogRetention,
+		"POST /jobs/clear-all":                   a.clearAllJobs,
+		"GET /jobs/schedules":                    a.getSchedules,
+		"POST /jobs/schedules":                   a.createSchedule,
+		"GET /jobs/schedules/{scheduleID}":       a.getSchedule,
+		"PUT /jobs/schedules/{scheduleID}":       a.updateSchedule,
+		"DELETE /jobs/schedules/{scheduleID}":    a.deleteSchedule,
+		"GET /jobs/webhooks":                     a.getWebhooks,
+		"POST /jobs/webhooks":                    a.createWebhook,
+		"GET /jobs/webhooks/{webhookID}":         a.getWebhook,
+		"PUT /jobs/webhooks/{webhookID}":         a.updateWebhook,
+		"DELETE /jobs/webhooks/{webhookID}":      a.deleteWebhook,
+		"POST /jobs/webhooks/{webhookID}/test":   a.testWebhook,
 	}
 
 	// We always want to load recovery routes.
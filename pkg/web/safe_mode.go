@@ -0,0 +1,51 @@
+package web
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+type SetSafeModeRequest struct {
+	Enabled bool   `json:"enabled"`
+	Reason  string `json:"reason"`
+}
+
+type SafeModeStateResponse struct {
+	Enabled                    bool   `json:"enabled"`
+	Reason                     string `json:"reason"`
+	ConsecutiveRebuildFailures int    `json:"consecutiveRebuildFailures"`
+}
+
+func (t api) getSafeModeState(w http.ResponseWriter, r *http.Request) {
+	dbxState := t.sm.Get().Dogebox
+	sendResponse(w, SafeModeStateResponse{
+		Enabled:                    dbxState.Flags.IsSafeMode,
+		Reason:                     dbxState.SafeMode.Reason,
+		ConsecutiveRebuildFailures: dbxState.SafeMode.ConsecutiveRebuildFailures,
+	})
+}
+
+func (t api) setSafeModeState(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error reading request body")
+		return
+	}
+
+	var req SetSafeModeRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error unmarshalling JSON")
+		return
+	}
+
+	reason := req.Reason
+	if req.Enabled && reason == "" {
+		reason = "Enabled by user"
+	}
+
+	id := t.dbx.AddAction(dogeboxd.SetSafeMode{Enabled: req.Enabled, Reason: reason})
+	sendResponse(w, map[string]string{"id": id})
+}
@@ -0,0 +1,128 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+type ScheduleRequest struct {
+	Name     string            `json:"name"`
+	Schedule string            `json:"schedule"`
+	Action   string            `json:"action"`
+	Params   map[string]string `json:"params"`
+	Enabled  bool              `json:"enabled"`
+}
+
+func (r ScheduleRequest) toScheduledJob() dogeboxd.ScheduledJob {
+	return dogeboxd.ScheduledJob{
+		Name:     r.Name,
+		Schedule: r.Schedule,
+		Action:   r.Action,
+		Params:   r.Params,
+		Enabled:  r.Enabled,
+	}
+}
+
+func readScheduleRequest(w http.ResponseWriter, r *http.Request) (ScheduleRequest, bool) {
+	var req ScheduleRequest
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error reading request body")
+		return req, false
+	}
+	defer r.Body.Close()
+
+	if err := json.Unmarshal(body, &req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error unmarshalling JSON")
+		return req, false
+	}
+	return req, true
+}
+
+func (t api) getSchedules(w http.ResponseWriter, r *http.Request) {
+	schedules, err := t.dbx.JobScheduler.List()
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to retrieve schedules")
+		return
+	}
+
+	sendResponse(w, map[string]interface{}{
+		"success":   true,
+		"schedules": schedules,
+	})
+}
+
+func (t api) getSchedule(w http.ResponseWriter, r *http.Request) {
+	schedule, err := t.dbx.JobScheduler.Get(r.PathValue("scheduleID"))
+	if err != nil {
+		sendErrorResponse(w, http.StatusNotFound, "Schedule not found")
+		return
+	}
+
+	sendResponse(w, map[string]interface{}{
+		"success":  true,
+		"schedule": schedule,
+	})
+}
+
+func (t api) createSchedule(w http.ResponseWriter, r *http.Request) {
+	req, ok := readScheduleRequest(w, r)
+	if !ok {
+		return
+	}
+
+	schedule, err := t.dbx.JobScheduler.Create(req.toScheduledJob())
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sendResponse(w, map[string]interface{}{
+		"success":  true,
+		"schedule": schedule,
+	})
+}
+
+func (t api) updateSchedule(w http.ResponseWriter, r *http.Request) {
+	req, ok := readScheduleRequest(w, r)
+	if !ok {
+		return
+	}
+
+	schedule, err := t.dbx.JobScheduler.Update(r.PathValue("scheduleID"), req.toScheduledJob())
+	if errors.Is(err, dogeboxd.ErrScheduleNotFound) {
+		sendErrorResponse(w, http.StatusNotFound, "Schedule not found")
+		return
+	}
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sendResponse(w, map[string]interface{}{
+		"success":  true,
+		"schedule": schedule,
+	})
+}
+
+func (t api) deleteSchedule(w http.ResponseWriter, r *http.Request) {
+	scheduleID := r.PathValue("scheduleID")
+	err := t.dbx.JobScheduler.Delete(scheduleID)
+	if errors.Is(err, dogeboxd.ErrScheduleNotFound) {
+		sendErrorResponse(w, http.StatusNotFound, "Schedule not found")
+		return
+	}
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to delete schedule")
+		return
+	}
+
+	sendResponse(w, map[string]interface{}{
+		"success": true,
+		"deleted": scheduleID,
+	})
+}
@@ -8,9 +8,11 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
@@ -25,7 +27,11 @@ type Session struct {
 	DKM_TOKEN  string
 }
 
-var sessions []Session
+var (
+	sessions []Session
+	// Guards sessions, which every request and pup WebUI proxy reads.
+	sessionsMu sync.Mutex
+)
 
 func getBearerToken(r *http.Request) (bool, string) {
 	authHeader := r.Header.Get("authorization")
@@ -57,6 +63,9 @@ func getSession(r *http.Request, tokenExtractor func(r *http.Request) (bool, str
 		return Session{}, false
 	}
 
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+
 	for i, session := range sessions {
 		if session.Token == token {
 
@@ -73,7 +82,66 @@ func getSession(r *http.Request, tokenExtractor func(r *http.Request) (bool, str
 	return Session{}, false
 }
 
+// getKeySession is getSession for routes that need the DKM unlocked,
+// which only the password can do. Passkey sessions are told to enter it.
+func getKeySession(w http.ResponseWriter, r *http.Request) (Session, bool) {
+	session, ok := getSession(r, getBearerToken)
+	if !ok {
+		sendErrorResponse(w, http.StatusUnauthorized, "Not signed in")
+		return Session{}, false
+	}
+	if session.DKM_TOKEN == "" {
+		sendErrorResponse(w, http.StatusForbidden, "Enter your password to unlock your keys first")
+		return Session{}, false
+	}
+	return session, true
+}
+
+// setSessionDKMToken unlocks keys for a signed in session.
+func setSessionDKMToken(token, dkmToken string) bool {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+
+	for i := range sessions {
+		if sessions[i].Token == token && time.Now().Before(sessions[i].Expiration) {
+			sessions[i].DKM_TOKEN = dkmToken
+			return true
+		}
+	}
+	return false
+}
+
+// hasLiveSession reports whether the dPanel session with the
+// WebUISSOSessionID id is still signed in.
+func hasLiveSession(id string) bool {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+
+	for _, session := range sessions {
+		if dogeboxd.WebUISSOSessionID(session.Token) == id {
+			return time.Now().Before(session.Expiration)
+		}
+	}
+	return false
+}
+
+// isSessionToken reports whether token is a live dPanel session's token.
+func isSessionToken(token string) bool {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+
+	for _, session := range sessions {
+		if session.Token == token {
+			return time.Now().Before(session.Expiration)
+		}
+	}
+	return false
+}
+
 func storeSession(session Session, config dogeboxd.ServerConfig) {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+
 	sessions = append(sessions, session)
 
 	if config.DevMode {
@@ -109,6 +177,9 @@ func delSession(r *http.Request) error {
 		return errors.New("failed to fetch bearer token")
 	}
 
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+
 	for i, session := range sessions {
 		if session.Token == token {
 			sessions = append(sessions[:i], sessions[i+1:]...)
@@ -120,7 +191,11 @@ func delSession(r *http.Request) error {
 }
 
 func authReq(dbx dogeboxd.Dogeboxd, sm dogeboxd.StateManager, route string, next http.HandlerFunc) http.HandlerFunc {
-	if route == "POST /authenticate" {
+	if route == "POST /authenticate" ||
+		route == "POST /authenticate/passkey/begin" ||
+		route == "POST /authenticate/passkey" ||
+		route == "GET /public/status" ||
+		route == "GET /public/status.html" {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			next.ServeHTTP(w, r)
 		})
@@ -175,6 +250,8 @@ func authReq(dbx dogeboxd.Dogeboxd, sm dogeboxd.StateManager, route string, next
 		route == "POST /system/install" ||
 		route == "GET /system/network/list" ||
 		route == "PUT /system/network/set-pending" ||
+		route == "GET /system/network/wifi-region" ||
+		route == "PUT /system/network/wifi-region" ||
 		route == "GET /keys" ||
 		route == "POST /keys/create-master" ||
 		route == "POST /system/host/shutdown" ||
@@ -190,6 +267,8 @@ func authReq(dbx dogeboxd.Dogeboxd, sm dogeboxd.StateManager, route string, next
 
 type AuthenticateRequestBody struct {
 	Password string `json:"password"`
+	// Needed as well as the password when passkeys are required.
+	Passkey *PasskeyAssertion `json:"passkey,omitempty"`
 }
 
 func (t api) authenticate(w http.ResponseWriter, r *http.Request) {
@@ -206,6 +285,17 @@ func (t api) authenticate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if t.sm.Get().Dogebox.Auth.RequirePasskey && !t.passkeyExempt(r) {
+		if requestBody.Passkey == nil {
+			sendErrorResponse(w, 403, "A passkey is required")
+			return
+		}
+		if err := t.verifyPasskeyAssertion(*requestBody.Passkey); err != nil {
+			sendErrorResponse(w, 403, err.Error())
+			return
+		}
+	}
+
 	dkmToken, dkmError, err := t.dkm.Authenticate(requestBody.Password)
 	if err != nil {
 		sendErrorResponse(w, 500, err.Error())
@@ -228,12 +318,93 @@ func (t api) authenticate(w http.ResponseWriter, r *http.Request) {
 	session.DKM_TOKEN = dkmToken
 	storeSession(session, t.config)
 
+	// Signing in is when we can unlock secret pup config.
+	if t.dbx.ConfigSecrets != nil {
+		if err := t.dbx.ConfigSecrets.Unlock(dkmToken); err != nil {
+			log.Printf("Failed to unlock config secrets: %v", err)
+		}
+	}
+
+	t.installPendingPupCollection(dkmToken)
+
 	sendResponse(w, map[string]any{
-		"success": true,
-		"token":   token,
+		"success":      true,
+		"token":        token,
+		"keysUnlocked": true,
 	})
 }
 
+// passkeyExempt is whether signing in here only needs the password even
+// when passkeys are required: on the box itself, over the unix socket,
+// or in recovery mode, so lost passkeys can be removed.
+func (t api) passkeyExempt(r *http.Request) bool {
+	if t.config.Recovery {
+		return true
+	}
+	_, local := r.Context().Value(http.LocalAddrContextKey).(*net.UnixAddr)
+	return local
+}
+
+type UnlockKeysRequestBody struct {
+	Password string `json:"password"`
+}
+
+// unlockKeys lets a passkey session enter the password, so it can do
+// things needing keys (installing pups, secret config) too.
+func (t api) unlockKeys(w http.ResponseWriter, r *http.Request) {
+	session, ok := getSession(r, getBearerToken)
+	if !ok {
+		sendErrorResponse(w, http.StatusUnauthorized, "Not signed in")
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error reading request body")
+		return
+	}
+	defer r.Body.Close()
+
+	var requestBody UnlockKeysRequestBody
+	if err := json.Unmarshal(body, &requestBody); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error parsing payload")
+		return
+	}
+
+	dkmToken, dkmError, err := t.dkm.Authenticate(requestBody.Password)
+	if err != nil {
+		sendErrorResponse(w, 500, err.Error())
+		return
+	}
+	if dkmError != nil {
+		sendErrorResponse(w, 403, dkmError.Error())
+		return
+	}
+	if dkmToken == "" {
+		sendErrorResponse(w, 403, "Invalid password")
+		return
+	}
+
+	if !setSessionDKMToken(session.Token, dkmToken) {
+		t.dkm.InvalidateToken(dkmToken)
+		sendErrorResponse(w, http.StatusUnauthorized, "Not signed in")
+		return
+	}
+	if session.DKM_TOKEN != "" {
+		t.dkm.InvalidateToken(session.DKM_TOKEN)
+	}
+
+	if t.dbx.ConfigSecrets != nil {
+		if err := t.dbx.ConfigSecrets.Unlock(dkmToken); err != nil {
+			log.Printf("Failed to unlock config secrets: %v", err)
+		}
+	}
+
+	t.installPendingPupCollection(dkmToken)
+
+	sendResponse(w, map[string]any{"success": true, "keysUnlocked": true})
+}
+
 func (t api) logout(w http.ResponseWriter, r *http.Request) {
 	session, sessionOK := getSession(r, getBearerToken)
 	if !sessionOK {
@@ -244,17 +415,20 @@ func (t api) logout(w http.ResponseWriter, r *http.Request) {
 	// Clear our DKM token first. This ensures we can still convey an error
 	// to the user if this fails for whatever reason. UI should tell them to
 	// reboot their box or something to clear all authed sessions.
-	ok, err := t.dkm.InvalidateToken(session.DKM_TOKEN)
-	if err != nil {
-		log.Println("failed to invalidate token with DKM:", err)
-		sendErrorResponse(w, 500, err.Error())
-		return
-	}
+	// Passkey sessions never had one.
+	if session.DKM_TOKEN != "" {
+		ok, err := t.dkm.InvalidateToken(session.DKM_TOKEN)
+		if err != nil {
+			log.Println("failed to invalidate token with DKM:", err)
+			sendErrorResponse(w, 500, err.Error())
+			return
+		}
 
-	if !ok {
-		log.Println("DKM returned ok=false when invalidating token")
-		sendErrorResponse(w, 500, "Failed to invalidate token")
-		return
+		if !ok {
+			log.Println("DKM returned ok=false when invalidating token")
+			sendErrorResponse(w, 500, "Failed to invalidate token")
+			return
+		}
 	}
 
 	delSession(r)
@@ -317,10 +491,14 @@ func (t api) changePassword(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Invalidate all existing sessions since they're using the old password
+	sessionsMu.Lock()
 	for _, session := range sessions {
-		t.dkm.InvalidateToken(session.DKM_TOKEN)
+		if session.DKM_TOKEN != "" {
+			t.dkm.InvalidateToken(session.DKM_TOKEN)
+		}
 	}
 	sessions = nil
+	sessionsMu.Unlock()
 
 	sendResponse(w, map[string]any{
 		"success": true,
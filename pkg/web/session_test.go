@@ -0,0 +1,50 @@
+package web
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Passkey sessions can't do anything needing keys until the password
+// is entered.
+func TestGetKeySessionNeedsThePassword(t *testing.T) {
+	token, session := newSession()
+	sessions = append(sessions, session)
+	t.Cleanup(func() { sessions = nil })
+
+	req := httptest.NewRequest("POST", "/pup", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	recorder := httptest.NewRecorder()
+	_, ok := getKeySession(recorder, req)
+	assert.False(t, ok)
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+
+	require.True(t, setSessionDKMToken(token, "dkm"))
+	recorder = httptest.NewRecorder()
+	keySession, ok := getKeySession(recorder, req)
+	require.True(t, ok)
+	assert.Equal(t, "dkm", keySession.DKM_TOKEN)
+
+	recorder = httptest.NewRecorder()
+	_, ok = getKeySession(recorder, httptest.NewRequest("POST", "/pup", nil))
+	assert.False(t, ok)
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+}
+
+// Required passkeys can always be turned off from the box itself.
+func TestPasskeyExempt(t *testing.T) {
+	req := httptest.NewRequest("POST", "/authenticate", nil)
+	assert.False(t, api{}.passkeyExempt(req))
+	assert.True(t, api{config: dogeboxd.ServerConfig{Recovery: true}}.passkeyExempt(req))
+
+	local := req.WithContext(context.WithValue(req.Context(), http.LocalAddrContextKey, &net.UnixAddr{Name: "/run/dogeboxd.sock", Net: "unix"}))
+	assert.True(t, api{}.passkeyExempt(local))
+}
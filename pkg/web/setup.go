@@ -2,9 +2,11 @@ package web
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 
@@ -19,6 +21,8 @@ type InitialSystemBootstrapRequestBody struct {
 	InitialSSHKey               string `json:"initialSSHKey"`
 	UseFoundationOSBinaryCache  bool   `json:"useFoundationOSBinaryCache"`
 	UseFoundationPupBinaryCache bool   `json:"useFoundationPupBinaryCache"`
+	// One of InitialPupCollections, installed once bootstrapped. Optional.
+	InitialPupCollection string `json:"initialPupCollection"`
 }
 
 type BootstrapFacts struct {
@@ -34,6 +38,9 @@ type BootstrapFacts struct {
 type BootstrapFlags struct {
 	IsFirstTimeWelcomeComplete bool `json:"isFirstTimeWelcomeComplete"`
 	IsDeveloperMode            bool `json:"isDeveloperMode"`
+	IsSafeMode                 bool `json:"isSafeMode"`
+	// Unset until the box has picked one, see dogeboxd.BoxProfile.
+	Profile dogeboxd.BoxProfile `json:"profile,omitempty"`
 }
 
 type SidebarPreferencesResponse struct {
@@ -50,6 +57,7 @@ type BootstrapResponse struct {
 	Flags              BootstrapFlags               `json:"flags"`
 	SetupFacts         BootstrapFacts               `json:"setupFacts"`
 	SidebarPreferences SidebarPreferencesResponse   `json:"sidebarPreferences"`
+	DisplayPreferences dogeboxd.DisplayPreferences  `json:"displayPreferences"`
 }
 
 func (t api) getRawBS() BootstrapResponse {
@@ -84,13 +92,15 @@ func (t api) getRawBS() BootstrapResponse {
 	return BootstrapResponse{
 		TS:      time.Now().UnixMilli(),
 		Version: version.GetDBXRelease(),
-		DevMode: t.config.DevMode,
+		DevMode: t.devModeActionsAllowed(),
 		Assets:  t.pups.GetAssetsMap(),
 		States:  t.pups.GetStateMap(),
 		Stats:   t.pups.GetStatsMap(),
 		Flags: BootstrapFlags{
 			IsFirstTimeWelcomeComplete: dbxState.Flags.IsFirstTimeWelcomeComplete,
 			IsDeveloperMode:            dbxState.Flags.IsDeveloperMode,
+			IsSafeMode:                 dbxState.Flags.IsSafeMode,
+			Profile:                    dbxState.Profile,
 		},
 		SetupFacts: BootstrapFacts{
 			HasGeneratedKey:                  dbxState.InitialState.HasGeneratedKey,
@@ -102,6 +112,7 @@ func (t api) getRawBS() BootstrapResponse {
 			ActiveSystemUpdateStatus:         activeSystemUpdateStatus,
 		},
 		SidebarPreferences: SidebarPreferencesResponse{SidebarPups: sidebarPups},
+		DisplayPreferences: dbxState.DisplayPreferences.WithDefaults(),
 	}
 }
 
@@ -174,17 +185,25 @@ func (t api) installPupCollection(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if _, ok := FoundationPupCollections[requestBody.CollectionName]; !ok {
+		sendErrorResponse(w, http.StatusBadRequest, "Unknown collection")
+		return
+	}
+
 	// Get the session token for authentication
-	session, sessionOK := getSession(r, getBearerToken)
+	session, sessionOK := getKeySession(w, r)
 	if !sessionOK {
-		sendErrorResponse(w, http.StatusUnauthorized, "Failed to fetch session")
 		return
 	}
 
 	// Process the collection installation
-	processPupCollections(t.sources, t.dbx, session.DKM_TOKEN, requestBody.CollectionName)
+	id, err := processPupCollections(t.sources, t.dbx, session.DKM_TOKEN, requestBody.CollectionName)
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 
-	sendResponse(w, map[string]any{"success": true})
+	sendResponse(w, map[string]any{"success": true, "id": id})
 }
 
 func (t api) hostReboot(w http.ResponseWriter, r *http.Request) {
@@ -428,6 +447,8 @@ func (t api) setTimezone(w http.ResponseWriter, r *http.Request) {
 
 type SetStorageDeviceRequestBody struct {
 	StorageDevice string `json:"storageDevice"`
+	// Optional second disk to mirror StorageDevice onto.
+	MirrorDevice string `json:"mirrorDevice,omitempty"`
 }
 
 func (t api) setStorageDevice(w http.ResponseWriter, r *http.Request) {
@@ -467,6 +488,13 @@ func (t api) setStorageDevice(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if foundDisk != nil && requestBody.MirrorDevice != "" {
+		if err := system.CheckStorageMirrorDisk(disks, *foundDisk, requestBody.MirrorDevice); err != nil {
+			sendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
 	// If the disk selected is actually our boot drive, allow it, and don't set StorageDevice.
 	if foundDisk != nil && foundDisk.BootMedia {
 		sendResponse(w, map[string]any{"status": "OK"})
@@ -480,6 +508,7 @@ func (t api) setStorageDevice(w http.ResponseWriter, r *http.Request) {
 
 	dbxState = t.sm.Get().Dogebox
 	dbxState.StorageDevice = requestBody.StorageDevice
+	dbxState.StorageMirrorDevice = requestBody.MirrorDevice
 
 	if err := t.sm.SetDogebox(dbxState); err != nil {
 		sendErrorResponse(w, http.StatusInternalServerError, "Error saving state")
@@ -520,12 +549,18 @@ func (t api) initialBootstrap(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if requestBody.InitialPupCollection != "" && !slices.Contains(InitialPupCollections, requestBody.InitialPupCollection) {
+		sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Unknown pup collection %q", requestBody.InitialPupCollection))
+		return
+	}
+
 	id := t.dbx.AddAction(dogeboxd.InitialBootstrap{
 		ReflectorToken:              requestBody.ReflectorToken,
 		ReflectorHost:               requestBody.ReflectorHost,
 		InitialSSHKey:               requestBody.InitialSSHKey,
 		UseFoundationOSBinaryCache:  requestBody.UseFoundationOSBinaryCache,
 		UseFoundationPupBinaryCache: requestBody.UseFoundationPupBinaryCache,
+		InitialPupCollection:        requestBody.InitialPupCollection,
 	})
 
 	sendResponse(w, map[string]any{"jobId": id})
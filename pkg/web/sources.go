@@ -2,13 +2,20 @@ package web
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
 )
 
 type CreateSourceRequest struct {
 	Location string `json:"location"`
+	// For private git sources, the password is a secret reference rather
+	// than the password itself, ie: "vault:secret/data/dogebox#github".
+	Auth *dogeboxd.ManifestSourceAuth `json:"auth,omitempty"`
 }
 
 func (t api) createSource(w http.ResponseWriter, r *http.Request) {
@@ -25,12 +32,22 @@ func (t api) createSource(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if _, err := t.sources.AddSource(req.Location); err != nil {
+	source, err := t.sources.AddSource(req.Location, req.Auth)
+	if err != nil {
 		log.Printf("Error adding source: %v", err)
+		if errors.Is(err, dogeboxd.ErrSecretProviderNotConfigured) {
+			sendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
 		sendErrorResponse(w, http.StatusInternalServerError, "Error adding source")
 		return
 	}
 
+	// Pups installed from a bundle packed from this source can now follow it.
+	if _, err := dogeboxd.ReconnectBundledPups(t.pups, source.Config()); err != nil {
+		log.Printf("Error reconnecting bundled pups: %v", err)
+	}
+
 	sendResponse(w, map[string]any{
 		"success": true,
 	})
@@ -49,7 +66,212 @@ func (t api) deleteSource(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if t.dbx.SourceRefresher != nil {
+		t.dbx.SourceRefresher.Forget(id)
+	}
+
+	sendResponse(w, map[string]any{
+		"success": true,
+	})
+}
+
+// SourceResponse is a source and how its last refresh went, Refresh being
+// nil until the SourceRefresher has got to it.
+type SourceResponse struct {
+	dogeboxd.ManifestSourceConfiguration
+	Refresh *dogeboxd.SourceRefreshStatus `json:"refresh"`
+}
+
+func (t api) refreshSource(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	if _, err := t.sources.GetSource(id); err != nil {
+		sendErrorResponse(w, http.StatusNotFound, "Source not found")
+		return
+	}
+
+	sendResponse(w, map[string]string{"id": t.dbx.AddAction(dogeboxd.RefreshSource{SourceID: id})})
+}
+
+type SetSourceRefreshIntervalRequest struct {
+	// 0 for the default interval.
+	Minutes int `json:"minutes"`
+}
+
+type SetSourcePriorityRequest struct {
+	// Lower wins, 0 by default.
+	Priority int `json:"priority"`
+}
+
+type PinSourceRequest struct {
+	// A full git commit hash, or "" to unpin.
+	Commit string `json:"commit"`
+}
+
+func (t api) pinSource(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error reading request body")
+		return
+	}
+	defer r.Body.Close()
+
+	var req PinSourceRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error unmarshalling JSON")
+		return
+	}
+
+	if err := dogeboxd.ValidateGitCommit(req.Commit); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if _, err := t.sources.GetSource(id); err != nil {
+		sendErrorResponse(w, http.StatusNotFound, "Source not found")
+		return
+	}
+
+	if err := t.sources.PinSource(id, req.Commit); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("Error pinning source: %v", err))
+		return
+	}
+
+	sendResponse(w, map[string]any{
+		"success": true,
+	})
+}
+
+func (t api) setSourceRefreshInterval(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error reading request body")
+		return
+	}
+	defer r.Body.Close()
+
+	var req SetSourceRefreshIntervalRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error unmarshalling JSON")
+		return
+	}
+
+	if err := dogeboxd.ValidateSourceRefreshInterval(req.Minutes); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if _, err := t.sources.GetSource(id); err != nil {
+		sendErrorResponse(w, http.StatusNotFound, "Source not found")
+		return
+	}
+
+	if err := t.sources.SetRefreshInterval(id, req.Minutes); err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, fmt.Sprintf("Error setting refresh interval: %v", err))
+		return
+	}
+
+	sendResponse(w, map[string]any{
+		"success": true,
+	})
+}
+
+func (t api) setSourcePriority(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error reading request body")
+		return
+	}
+	defer r.Body.Close()
+
+	var req SetSourcePriorityRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error unmarshalling JSON")
+		return
+	}
+
+	if err := dogeboxd.ValidateSourcePriority(req.Priority); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if _, err := t.sources.GetSource(id); err != nil {
+		sendErrorResponse(w, http.StatusNotFound, "Source not found")
+		return
+	}
+
+	if err := t.sources.SetPriority(id, req.Priority); err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, fmt.Sprintf("Error setting priority: %v", err))
+		return
+	}
+
+	sendResponse(w, map[string]any{
+		"success": true,
+	})
+}
+
+type SetSourceAutoRebuildRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+func (t api) setSourceAutoRebuild(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	var req SetSourceAutoRebuildRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error decoding request body")
+		return
+	}
+
+	source, err := t.sources.GetSource(id)
+	if err != nil {
+		sendErrorResponse(w, http.StatusNotFound, "Source not found")
+		return
+	}
+	if source.Config().Type != "disk" {
+		sendErrorResponse(w, http.StatusBadRequest, "Only disk sources can be rebuilt automatically")
+		return
+	}
+
+	if err := t.sources.SetAutoRebuild(id, req.Enabled); err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, fmt.Sprintf("Error setting auto rebuild: %v", err))
+		return
+	}
+
+	sendResponse(w, map[string]any{
+		"success": true,
+	})
+}
+
+func (t api) searchSources(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	if query == "" {
+		sendErrorResponse(w, http.StatusBadRequest, "Missing search query")
+		return
+	}
+
+	available, err := t.sources.GetAll(false)
+	if err != nil {
+		log.Println("Error fetching sources:", err)
+		sendErrorResponse(w, http.StatusInternalServerError, "Error fetching sources")
+		return
+	}
+
+	sendResponse(w, map[string]any{
+		"success": true,
+		"results": dogeboxd.SearchPups(available, t.pups.GetStateMap(), query),
+	})
+}
+
+func (t api) getSourceCacheStats(w http.ResponseWriter, r *http.Request) {
 	sendResponse(w, map[string]any{
 		"success": true,
+		"stats":   t.sources.GetCacheStats(),
 	})
 }
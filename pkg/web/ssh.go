@@ -11,6 +11,8 @@ import (
 
 type SetSSHStateRequest struct {
 	Enabled bool `json:"enabled"`
+	// When enabling, disable SSH again after this many hours.
+	Hours int `json:"hours,omitempty"`
 }
 
 type AddSSHKeyRequest struct {
@@ -20,7 +22,10 @@ type AddSSHKeyRequest struct {
 func (t api) getSSHState(w http.ResponseWriter, r *http.Request) {
 	dbxState := t.sm.Get().Dogebox
 
-	sendResponse(w, map[string]bool{"enabled": dbxState.SSH.Enabled})
+	sendResponse(w, map[string]any{
+		"enabled":      dbxState.SSH.Enabled,
+		"enabledUntil": dbxState.SSH.EnabledUntil,
+	})
 }
 
 func (t api) setSSHState(w http.ResponseWriter, r *http.Request) {
@@ -37,7 +42,13 @@ func (t api) setSSHState(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var action dogeboxd.Action
-	if req.Enabled {
+	if req.Enabled && req.Hours != 0 {
+		if err := dogeboxd.ValidateTemporarySSHHours(req.Hours); err != nil {
+			sendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		action = dogeboxd.EnableSSHTemporary{Hours: req.Hours}
+	} else if req.Enabled {
 		action = dogeboxd.EnableSSH{}
 	} else {
 		action = dogeboxd.DisableSSH{}
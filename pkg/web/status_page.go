@@ -0,0 +1,189 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/Dogebox-WG/dogeboxd/pkg/version"
+	"github.com/shirou/gopsutil/v4/host"
+)
+
+// Public status requests allowed per client IP, per window.
+const (
+	statusPageRateLimit  = 30
+	statusPageRateWindow = time.Minute
+)
+
+var statusPageLimiter = newIPRateLimiter(statusPageRateLimit, statusPageRateWindow)
+
+/* ipRateLimiter allows limit requests per window from each client IP,
+ * using fixed windows. It goes by the connecting address, as headers
+ * like X-Forwarded-For are up to the client.
+ */
+type ipRateLimiter struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	hits   map[string]*ipRateWindow
+}
+
+type ipRateWindow struct {
+	start time.Time
+	count int
+}
+
+func newIPRateLimiter(limit int, window time.Duration) *ipRateLimiter {
+	return &ipRateLimiter{limit: limit, window: window, hits: map[string]*ipRateWindow{}}
+}
+
+// Allow reports whether ip can make another request, and if not, how
+// long until it can.
+func (l *ipRateLimiter) Allow(ip string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Forget finished windows now and then, so the map can't grow forever.
+	if len(l.hits) > 1000 {
+		for k, w := range l.hits {
+			if now.Sub(w.start) >= l.window {
+				delete(l.hits, k)
+			}
+		}
+	}
+
+	w, ok := l.hits[ip]
+	if !ok || now.Sub(w.start) >= l.window {
+		l.hits[ip] = &ipRateWindow{start: now, count: 1}
+		return true, 0
+	}
+	if w.count >= l.limit {
+		return false, w.start.Add(l.window).Sub(now)
+	}
+	w.count++
+	return true, 0
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// publicStatus returns the status to show, false if the page is off or
+// the client has been rate limited, in which case it's replied to.
+func (t api) publicStatus(w http.ResponseWriter, r *http.Request) (dogeboxd.PublicStatus, bool) {
+	page := t.sm.Get().Dogebox.StatusPage
+	if !page.Enabled {
+		sendErrorResponse(w, http.StatusNotFound, "Status page is not enabled")
+		return dogeboxd.PublicStatus{}, false
+	}
+
+	if ok, retry := statusPageLimiter.Allow(clientIP(r), time.Now()); !ok {
+		w.Header().Set("Retry-After", fmt.Sprintf("%d", int(retry.Seconds())+1))
+		sendErrorResponse(w, http.StatusTooManyRequests, "Too many requests")
+		return dogeboxd.PublicStatus{}, false
+	}
+
+	var uptime time.Duration
+	if page.ShowUptime {
+		if secs, err := host.Uptime(); err == nil {
+			uptime = time.Duration(secs) * time.Second
+		}
+	}
+
+	return dogeboxd.BuildPublicStatus(page, t.pups.GetStateMap(), t.pups.GetStatsMap(), version.GetDBXRelease().Release, uptime, time.Now()), true
+}
+
+func (t api) getPublicStatus(w http.ResponseWriter, r *http.Request) {
+	status, ok := t.publicStatus(w, r)
+	if !ok {
+		return
+	}
+	sendResponse(w, status)
+}
+
+var publicStatusTemplate = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta http-equiv="refresh" content="60">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; max-width: 32em; margin: 2em auto; padding: 0 1em; color: #222; }
+h2 { font-size: 1.1em; margin-bottom: .3em; }
+dl { display: grid; grid-template-columns: auto 1fr; gap: .2em 1em; margin: 0; }
+dt { color: #666; }
+footer { margin-top: 2em; font-size: .8em; color: #888; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<dl>
+{{if .Version}}<dt>Version</dt><dd>{{.Version}}</dd>{{end}}
+{{if .UptimeHours}}<dt>Uptime</dt><dd>{{.UptimeHours}} hours</dd>{{end}}
+</dl>
+{{range .Pups}}
+<h2>{{.Name}}</h2>
+<dl>
+{{if .Status}}<dt>Status</dt><dd>{{.Status}}</dd>{{end}}
+{{range .Metrics}}<dt>{{.Label}}</dt><dd>{{if .Value}}{{.Value}}{{else}}-{{end}}</dd>{{end}}
+</dl>
+{{end}}
+<footer>Updated {{.Updated.Format "2006-01-02 15:04 MST"}}</footer>
+</body>
+</html>
+`))
+
+func (t api) getPublicStatusPage(w http.ResponseWriter, r *http.Request) {
+	status, ok := t.publicStatus(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := publicStatusTemplate.Execute(w, status); err != nil {
+		log.Printf("Error rendering status page: %v", err)
+	}
+}
+
+func (t api) getStatusPageSettings(w http.ResponseWriter, r *http.Request) {
+	sendResponse(w, t.sm.Get().Dogebox.StatusPage)
+}
+
+func (t api) setStatusPageSettings(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error reading request body")
+		return
+	}
+	defer r.Body.Close()
+
+	var req dogeboxd.PublicStatusPage
+	if err := json.Unmarshal(body, &req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error unmarshalling JSON")
+		return
+	}
+	if err := req.Validate(t.pups.GetStateMap()); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	dbxState := t.sm.Get().Dogebox
+	dbxState.StatusPage = req
+	if err := t.sm.SetDogebox(dbxState); err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Error saving status page settings")
+		return
+	}
+	sendResponse(w, req)
+}
@@ -0,0 +1,28 @@
+package web
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIPRateLimiter(t *testing.T) {
+	l := newIPRateLimiter(2, time.Minute)
+	now := time.Now()
+
+	ok, _ := l.Allow("192.0.2.1", now)
+	assert.True(t, ok)
+	ok, _ = l.Allow("192.0.2.1", now.Add(time.Second))
+	assert.True(t, ok)
+	ok, retry := l.Allow("192.0.2.1", now.Add(20*time.Second))
+	assert.False(t, ok)
+	assert.Equal(t, 40*time.Second, retry)
+
+	// Others have their own allowance.
+	ok, _ = l.Allow("192.0.2.2", now.Add(20*time.Second))
+	assert.True(t, ok)
+
+	ok, _ = l.Allow("192.0.2.1", now.Add(time.Minute))
+	assert.True(t, ok)
+}
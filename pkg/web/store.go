@@ -15,6 +15,20 @@ type StoreListSourceEntryPup struct {
 	LogoBase64       string                          `json:"logoBase64"`
 	Versions         map[string]dogeboxd.PupManifest `json:"versions"`
 	DevModeAvailable bool                            `json:"devModeAvailable"`
+	// Whether each version can be installed on this dogebox, see dogeboxd.CheckCompatibility
+	Compatibility map[string]dogeboxd.CompatibilityReport `json:"compatibility"`
+	// The interfaces the latest version provides and depends on.
+	Provides []StoreListInterface `json:"provides"`
+	Consumes []StoreListInterface `json:"consumes"`
+}
+
+type StoreListInterface struct {
+	Name string `json:"name"`
+	// A version for Provides, a semver constraint for Consumes.
+	Version string `json:"version"`
+	// From an interfaces source that defines it, if any does.
+	Description string `json:"description,omitempty"`
+	Optional    bool   `json:"optional,omitempty"`
 }
 
 type StoreListSourceEntry struct {
@@ -22,11 +36,35 @@ type StoreListSourceEntry struct {
 	Description string                             `json:"description"`
 	Location    string                             `json:"location"`
 	Type        string                             `json:"type"`
+	Priority    int                                `json:"priority"`
+	AutoRebuild bool                               `json:"autoRebuild,omitempty"`
 	LastChecked string                             `json:"lastChecked"`
 	Pups        map[string]StoreListSourceEntryPup `json:"pups"`
+	Interfaces  []dogeboxd.InterfaceDefinition     `json:"interfaces,omitempty"`
 	Error       string                             `json:"error,omitempty"`
 }
 
+func storeListInterfaces(m dogeboxd.PupManifest, defs []dogeboxd.InterfaceDefinition) ([]StoreListInterface, []StoreListInterface) {
+	provides := []StoreListInterface{}
+	for _, iface := range m.Interfaces {
+		provides = append(provides, StoreListInterface{
+			Name:        iface.Name,
+			Version:     iface.Version,
+			Description: dogeboxd.DescribeInterface(defs, iface.Name),
+		})
+	}
+	consumes := []StoreListInterface{}
+	for _, dep := range m.Dependencies {
+		consumes = append(consumes, StoreListInterface{
+			Name:        dep.InterfaceName,
+			Version:     dep.InterfaceVersion,
+			Description: dogeboxd.DescribeInterface(defs, dep.InterfaceName),
+			Optional:    dep.Optional,
+		})
+	}
+	return provides, consumes
+}
+
 func (t api) getStoreList(w http.ResponseWriter, r *http.Request) {
 	forceRefresh := r.URL.Query().Get("refresh") == "true"
 
@@ -44,7 +82,14 @@ func (t api) getStoreList(w http.ResponseWriter, r *http.Request) {
 		log.Printf("getStoreList: queued CheckPupUpdates for store refresh (jobID: %s)", jobID)
 	}
 
+	interfaces := []dogeboxd.InterfaceDefinition{}
+	for _, entry := range available {
+		interfaces = append(interfaces, entry.Interfaces...)
+	}
+
 	response := map[string]StoreListSourceEntry{}
+	platform := dogeboxd.GetPlatformInfo(t.config.DataDir)
+	devModeAllowed := t.sm.Get().Dogebox.Profile.AllowsDevModeActions()
 
 	for k, entry := range available {
 		pups := map[string]StoreListSourceEntryPup{}
@@ -56,7 +101,7 @@ func (t api) getStoreList(w http.ResponseWriter, r *http.Request) {
 
 				isDevModeAvailable := false
 
-				if entry.Config.Type == "disk" {
+				if devModeAllowed && entry.Config.Type == "disk" {
 					devModeServices, err := utils.GetPupNixDevelopmentModeServices(t.config, entry.Config.Location, availablePup.Name, availablePup.Manifest)
 					if err != nil {
 						log.Println("Error getting dev mode services:", err)
@@ -70,12 +115,14 @@ func (t api) getStoreList(w http.ResponseWriter, r *http.Request) {
 					LogoBase64:       availablePup.LogoBase64,
 					Versions:         versions,
 					DevModeAvailable: isDevModeAvailable,
+					Compatibility:    map[string]dogeboxd.CompatibilityReport{},
 				}
 			}
 
 			// Retrieve the struct, modify it, and store it back in the map
 			pupEntry := pups[availablePup.Name]
 			pupEntry.Versions[availablePup.Version] = availablePup.Manifest
+			pupEntry.Compatibility[availablePup.Version] = dogeboxd.CheckCompatibility(availablePup.Manifest, platform)
 
 			if semver.Compare("v"+availablePup.Version, "v"+pupEntry.LatestVersion) > 0 {
 				pupEntry.LatestVersion = availablePup.Version
@@ -85,13 +132,21 @@ func (t api) getStoreList(w http.ResponseWriter, r *http.Request) {
 			pups[availablePup.Name] = pupEntry
 		}
 
+		for name, pupEntry := range pups {
+			pupEntry.Provides, pupEntry.Consumes = storeListInterfaces(pupEntry.Versions[pupEntry.LatestVersion], interfaces)
+			pups[name] = pupEntry
+		}
+
 		response[k] = StoreListSourceEntry{
 			Name:        entry.Config.Name,
 			Description: entry.Config.Description,
 			Location:    entry.Config.Location,
 			Type:        entry.Config.Type,
+			Priority:    entry.Config.Priority,
+			AutoRebuild: entry.Config.AutoRebuild,
 			LastChecked: entry.LastChecked.Format(time.RFC3339),
 			Pups:        pups,
+			Interfaces:  entry.Interfaces,
 			Error:       entry.Error,
 		}
 	}
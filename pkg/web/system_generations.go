@@ -0,0 +1,43 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+type RollbackSystemRequest struct {
+	Generation int `json:"generation"`
+}
+
+func (t api) listSystemGenerations(w http.ResponseWriter, r *http.Request) {
+	generations, err := t.nix.ListSystemGenerations()
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to list system generations")
+		return
+	}
+
+	sendResponse(w, map[string]any{"generations": generations})
+}
+
+func (t api) rollbackSystem(w http.ResponseWriter, r *http.Request) {
+	var req RollbackSystemRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error decoding request body")
+		return
+	}
+
+	generations, err := t.nix.ListSystemGenerations()
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to list system generations")
+		return
+	}
+	if _, err := dogeboxd.ValidateSystemRollback(generations, req.Generation); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	id := t.dbx.AddAction(dogeboxd.RollbackSystem{Generation: req.Generation})
+	sendResponse(w, map[string]string{"id": id})
+}
@@ -0,0 +1,105 @@
+package web
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+type AddTrustedCARequest struct {
+	Name string `json:"name"`
+	PEM  string `json:"pem"`
+}
+
+type SetPupTrustedCAsRequest struct {
+	CAIDs []string `json:"caIds"`
+}
+
+// Lists the trusted CAs, with which pups trust each.
+func (t api) getTrustedCAs(w http.ResponseWriter, r *http.Request) {
+	sendResponse(w, dogeboxd.TrustedCAInventory(t.sm.Get().Dogebox, t.pups.GetStateMap()))
+}
+
+// Adding a CA needs no rebuild, no pup trusts it yet.
+func (t api) addTrustedCA(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error reading request body")
+		return
+	}
+	defer r.Body.Close()
+
+	var req AddTrustedCARequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error unmarshalling JSON")
+		return
+	}
+
+	ca, err := dogeboxd.ParseTrustedCA(req.Name, req.PEM)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	dbxState := t.sm.Get().Dogebox
+	if existing, ok := dbxState.TrustedCA(ca.ID); ok {
+		sendErrorResponse(w, http.StatusConflict, "This CA has already been added as "+existing.Name)
+		return
+	}
+	dbxState.TrustedCAs = append(dbxState.TrustedCAs, ca)
+	if err := t.sm.SetDogebox(dbxState); err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Error saving trusted CA")
+		return
+	}
+
+	sendResponse(w, ca)
+}
+
+func (t api) removeTrustedCA(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, ok := t.sm.Get().Dogebox.TrustedCA(id); !ok {
+		sendErrorResponse(w, http.StatusNotFound, "Trusted CA with this ID does not exist")
+		return
+	}
+
+	sendResponse(w, map[string]string{"id": t.dbx.AddAction(dogeboxd.RemoveTrustedCA{ID: id})})
+}
+
+func (t api) setPupTrustedCAs(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("ID")
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error reading request body")
+		return
+	}
+	defer r.Body.Close()
+
+	var req SetPupTrustedCAsRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error unmarshalling JSON")
+		return
+	}
+
+	if _, _, err := t.pups.GetPup(id); err != nil {
+		sendAPIError(w, http.StatusNotFound, dogeboxd.NewAPIError(dogeboxd.ERROR_PUP_NOT_FOUND, "Pup not found").ForPup(id))
+		return
+	}
+
+	dbxState := t.sm.Get().Dogebox
+	seen := map[string]bool{}
+	for _, caID := range req.CAIDs {
+		if _, ok := dbxState.TrustedCA(caID); !ok {
+			sendErrorResponse(w, http.StatusBadRequest, "Trusted CA "+caID+" does not exist")
+			return
+		}
+		if seen[caID] {
+			sendErrorResponse(w, http.StatusBadRequest, "Trusted CA "+caID+" is given twice")
+			return
+		}
+		seen[caID] = true
+	}
+
+	sendResponse(w, map[string]string{"id": t.dbx.AddAction(dogeboxd.SetPupTrustedCAs{PupID: id, CAIDs: req.CAIDs})})
+}
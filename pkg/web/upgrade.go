@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"time"
 
 	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
 	"github.com/Dogebox-WG/dogeboxd/pkg/system"
@@ -16,9 +17,19 @@ type CommenceUpdateRequest struct {
 }
 
 type UpgradableRelease struct {
-	Version    string `json:"version"`
-	ReleaseURL string `json:"releaseURL"`
-	Summary    string `json:"summary"`
+	Version      string               `json:"version"`
+	ReleaseURL   string               `json:"releaseURL"`
+	Summary      string               `json:"summary"`
+	ReleaseNotes string               `json:"releaseNotes,omitempty"`
+	PublishedAt  *time.Time           `json:"publishedAt,omitempty"`
+	Size         *ReleaseSizeEstimate `json:"size,omitempty"`
+}
+
+type ReleaseSizeEstimate struct {
+	DownloadBytes  int64 `json:"downloadBytes"`
+	InstalledBytes int64 `json:"installedBytes"`
+	Paths          int   `json:"paths"`
+	UncachedPaths  int   `json:"uncachedPaths"`
 }
 
 type PackageInfo struct {
@@ -50,13 +61,18 @@ func (t api) checkForUpdates(w http.ResponseWriter, r *http.Request) {
 		currentVersion := version.GetDBXRelease().Release
 		latestVersion := releases[0].Version
 
+		releases = system.DescribeUpgradableReleases(releases)
+
 		// Convert system.UpgradableRelease to our local UpgradableRelease
 		updates := make([]UpgradableRelease, len(releases))
 		for i, release := range releases {
 			updates[i] = UpgradableRelease{
-				Version:    release.Version,
-				ReleaseURL: release.ReleaseURL,
-				Summary:    release.Summary,
+				Version:      release.Version,
+				ReleaseURL:   release.ReleaseURL,
+				Summary:      release.Summary,
+				ReleaseNotes: release.ReleaseNotes,
+				PublishedAt:  release.PublishedAt,
+				Size:         toReleaseSizeEstimate(release.Size),
 			}
 		}
 
@@ -75,6 +91,47 @@ func (t api) checkForUpdates(w http.ResponseWriter, r *http.Request) {
 	sendResponse(w, response)
 }
 
+type ReleaseSizeResponse struct {
+	Version string `json:"version"`
+	// False while the size is still being worked out, poll again.
+	Done bool                 `json:"done"`
+	Size *ReleaseSizeEstimate `json:"size,omitempty"`
+}
+
+// getReleaseSize sizes the release the user is looking at upgrading to,
+// in the background, so it's polled until done.
+func (t api) getReleaseSize(w http.ResponseWriter, r *http.Request) {
+	version := r.PathValue("version")
+	if version == "" {
+		sendErrorResponse(w, http.StatusBadRequest, "Version is required")
+		return
+	}
+
+	size, done, err := system.EstimateReleaseSize(version, system.ReleaseBinaryCaches(t.sm.Get().Dogebox))
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Error estimating release size: "+err.Error())
+		return
+	}
+
+	sendResponse(w, ReleaseSizeResponse{
+		Version: version,
+		Done:    done,
+		Size:    toReleaseSizeEstimate(size),
+	})
+}
+
+func toReleaseSizeEstimate(size *system.ReleaseSizeEstimate) *ReleaseSizeEstimate {
+	if size == nil {
+		return nil
+	}
+	return &ReleaseSizeEstimate{
+		DownloadBytes:  size.DownloadBytes,
+		InstalledBytes: size.InstalledBytes,
+		Paths:          size.Paths,
+		UncachedPaths:  size.UncachedPaths,
+	}
+}
+
 func (t api) commenceUpdate(w http.ResponseWriter, r *http.Request) {
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
@@ -104,7 +161,13 @@ func (t api) commenceUpdate(w http.ResponseWriter, r *http.Request) {
 		packageName = "os"
 	}
 
-	id := t.dbx.AddAction(dogeboxd.SystemUpdate{Package: packageName, Version: req.Version})
+	// Only needed for installing new system pups, so carry on without.
+	sessionToken := ""
+	if session, ok := getSession(r, getBearerToken); ok {
+		sessionToken = session.DKM_TOKEN
+	}
+
+	id := t.dbx.AddAction(dogeboxd.SystemUpdate{Package: packageName, Version: req.Version, SessionToken: sessionToken})
 
 	sendResponse(w, map[string]any{
 		"success": true,
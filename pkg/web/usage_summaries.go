@@ -0,0 +1,52 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+func (t api) listUsageSummaries(w http.ResponseWriter, r *http.Request) {
+	summaries, err := t.dbx.UsageReports.List()
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to list usage summaries")
+		return
+	}
+
+	sendResponse(w, map[string]any{"summaries": summaries})
+}
+
+// getCurrentUsageSummary returns the week so far, ?sort= as for getUsageSummary.
+func (t api) getCurrentUsageSummary(w http.ResponseWriter, r *http.Request) {
+	sendUsageSummary(w, r, t.dbx.UsageReports.Current())
+}
+
+// getUsageSummary returns a week's summary, with its pups ordered by
+// ?sort=cpu|memory|disk|bandwidth|failures, cpu by default.
+func (t api) getUsageSummary(w http.ResponseWriter, r *http.Request) {
+	summary, err := t.dbx.UsageReports.Get(r.PathValue("id"))
+	if errors.Is(err, dogeboxd.ErrUsageSummaryNotFound) {
+		sendErrorResponse(w, http.StatusNotFound, "Usage summary not found")
+		return
+	}
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to get usage summary")
+		return
+	}
+
+	sendUsageSummary(w, r, summary)
+}
+
+func sendUsageSummary(w http.ResponseWriter, r *http.Request, summary dogeboxd.UsageSummary) {
+	if metric := r.URL.Query().Get("sort"); metric != "" {
+		pups, err := summary.Leaderboard(metric)
+		if err != nil {
+			sendErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		summary.Pups = pups
+	}
+
+	sendResponse(w, map[string]any{"summary": summary})
+}
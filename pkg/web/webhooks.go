@@ -0,0 +1,151 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+type WebhookRequest struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// Optional, one is generated on create if empty. On update an empty
+	// secret keeps the existing one.
+	Secret string `json:"secret"`
+	// A reference to the secret in an external secret store, used instead
+	// of secret, ie: "sops:webhooks.alerts".
+	SecretRef string   `json:"secretRef"`
+	Events    []string `json:"events"`
+	Actions   []string `json:"actions"`
+	Enabled   bool     `json:"enabled"`
+}
+
+func (r WebhookRequest) toWebhook() dogeboxd.Webhook {
+	return dogeboxd.Webhook{
+		Name:      r.Name,
+		URL:       r.URL,
+		Secret:    r.Secret,
+		SecretRef: r.SecretRef,
+		Events:    r.Events,
+		Actions:   r.Actions,
+		Enabled:   r.Enabled,
+	}
+}
+
+func readWebhookRequest(w http.ResponseWriter, r *http.Request) (WebhookRequest, bool) {
+	var req WebhookRequest
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error reading request body")
+		return req, false
+	}
+	defer r.Body.Close()
+
+	if err := json.Unmarshal(body, &req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error unmarshalling JSON")
+		return req, false
+	}
+	return req, true
+}
+
+func (t api) getWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := t.dbx.Webhooks.List()
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to retrieve webhooks")
+		return
+	}
+
+	sendResponse(w, map[string]interface{}{
+		"success":  true,
+		"webhooks": webhooks,
+	})
+}
+
+func (t api) getWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook, err := t.dbx.Webhooks.Get(r.PathValue("webhookID"))
+	if err != nil {
+		sendErrorResponse(w, http.StatusNotFound, "Webhook not found")
+		return
+	}
+
+	sendResponse(w, map[string]interface{}{
+		"success": true,
+		"webhook": webhook,
+	})
+}
+
+func (t api) createWebhook(w http.ResponseWriter, r *http.Request) {
+	req, ok := readWebhookRequest(w, r)
+	if !ok {
+		return
+	}
+
+	webhook, err := t.dbx.Webhooks.Create(req.toWebhook())
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sendResponse(w, map[string]interface{}{
+		"success": true,
+		"webhook": webhook,
+	})
+}
+
+func (t api) updateWebhook(w http.ResponseWriter, r *http.Request) {
+	req, ok := readWebhookRequest(w, r)
+	if !ok {
+		return
+	}
+
+	webhook, err := t.dbx.Webhooks.Update(r.PathValue("webhookID"), req.toWebhook())
+	if errors.Is(err, dogeboxd.ErrWebhookNotFound) {
+		sendErrorResponse(w, http.StatusNotFound, "Webhook not found")
+		return
+	}
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sendResponse(w, map[string]interface{}{
+		"success": true,
+		"webhook": webhook,
+	})
+}
+
+func (t api) deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	webhookID := r.PathValue("webhookID")
+	err := t.dbx.Webhooks.Delete(webhookID)
+	if errors.Is(err, dogeboxd.ErrWebhookNotFound) {
+		sendErrorResponse(w, http.StatusNotFound, "Webhook not found")
+		return
+	}
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, "Failed to delete webhook")
+		return
+	}
+
+	sendResponse(w, map[string]interface{}{
+		"success": true,
+		"deleted": webhookID,
+	})
+}
+
+// testWebhook sends a test event, so the user can check their receiver
+// and its signature checking before a real job comes along.
+func (t api) testWebhook(w http.ResponseWriter, r *http.Request) {
+	delivery, err := t.dbx.Webhooks.Test(r.PathValue("webhookID"))
+	if errors.Is(err, dogeboxd.ErrWebhookNotFound) {
+		sendErrorResponse(w, http.StatusNotFound, "Webhook not found")
+		return
+	}
+
+	sendResponse(w, map[string]interface{}{
+		"success":  delivery.Error == "",
+		"delivery": delivery,
+	})
+}
@@ -0,0 +1,121 @@
+package web
+
+import (
+	"sync"
+	"time"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+// How many changes a single websocket client can fall behind by before
+// we start dropping the ones it can live without.
+const changeQueueLimit = 256
+
+// How many times changeQueueLimit job changes alone can fill a queue to
+// before the client is disconnected, see changeQueue.
+const changeQueueOverflow = 4
+
+// How long writing one change to a client may take before it's treated
+// as gone. Without it a client that stops reading holds its writer, and
+// everything queued for it, forever.
+const changeWriteTimeout = 30 * time.Second
+
+/* changeQueue buffers changes for one websocket client, so a slow client
+ * can't hold up dogeboxd or any other client. When it fills up:
+ *
+ *  - stats and pup state changes are coalesced, only the newest is kept,
+ *    except pup changes with a Reason, which explain the transition
+ *  - job changes are never dropped, the queue grows past its limit instead
+ *  - anything else is dropped, oldest first
+ *
+ * A client so far behind that job changes alone overflow the queue is
+ * disconnected instead, it'll get a fresh bootstrap when it reconnects.
+ */
+type changeQueue struct {
+	lock    sync.Mutex
+	changes []dogeboxd.Change
+	limit   int
+	ready   chan struct{} // signalled whenever there's something to send
+}
+
+func newChangeQueue(limit int) *changeQueue {
+	return &changeQueue{
+		limit: limit,
+		ready: make(chan struct{}, 1),
+	}
+}
+
+// coalesceKey returns a key for changes where only the latest matters.
+func coalesceKey(c dogeboxd.Change) (string, bool) {
+	switch c.Type {
+	case "stats":
+		return "stats", true
+	case "pup":
+		if c.Reason != nil {
+			return "", false
+		}
+		if p, ok := c.Update.(dogeboxd.PupState); ok {
+			return "pup:" + p.ID, true
+		}
+	}
+	return "", false
+}
+
+// push queues c, reporting false if the client has fallen too far
+// behind and should be disconnected.
+func (q *changeQueue) push(c dogeboxd.Change) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if key, ok := coalesceKey(c); ok {
+		for i, queued := range q.changes {
+			if k, _ := coalesceKey(queued); k == key {
+				// Move to the back so it still arrives after anything sent before it.
+				q.changes = append(q.changes[:i], q.changes[i+1:]...)
+				dogeboxd.ObserveWebsocketChangeDropped(queued.Type, "coalesced")
+				break
+			}
+		}
+	}
+
+	if len(q.changes) >= q.limit && !q.dropOldest() && !dogeboxd.IsJobChange(c) {
+		// Full of job changes, which we can't drop, so drop this one instead.
+		dogeboxd.ObserveWebsocketChangeDropped(c.Type, "dropped")
+		return true
+	}
+	if len(q.changes) >= q.limit*changeQueueOverflow {
+		dogeboxd.ObserveWebsocketChangeDropped(c.Type, "disconnected")
+		return false
+	}
+
+	q.changes = append(q.changes, c)
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// dropOldest removes the oldest change that isn't a job change.
+func (q *changeQueue) dropOldest() bool {
+	for i, queued := range q.changes {
+		if dogeboxd.IsJobChange(queued) {
+			continue
+		}
+		q.changes = append(q.changes[:i], q.changes[i+1:]...)
+		dogeboxd.ObserveWebsocketChangeDropped(queued.Type, "dropped")
+		return true
+	}
+	return false
+}
+
+// take empties the queue, returning everything waiting to be sent.
+func (q *changeQueue) take() []dogeboxd.Change {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	changes := q.changes
+	q.changes = nil
+	return changes
+}
@@ -0,0 +1,87 @@
+package web
+
+import (
+	"testing"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func changeTypes(changes []dogeboxd.Change) []string {
+	types := []string{}
+	for _, c := range changes {
+		types = append(types, c.Type)
+	}
+	return types
+}
+
+func TestChangeQueueCoalescesStatsAndPupState(t *testing.T) {
+	q := newChangeQueue(10)
+
+	q.push(dogeboxd.Change{Type: "stats", Seq: 1})
+	q.push(dogeboxd.Change{Type: "pup", Seq: 2, Update: dogeboxd.PupState{ID: "a"}})
+	q.push(dogeboxd.Change{Type: "pup", Seq: 3, Update: dogeboxd.PupState{ID: "b"}})
+	q.push(dogeboxd.Change{Type: "stats", Seq: 4})
+	q.push(dogeboxd.Change{Type: "pup", Seq: 5, Update: dogeboxd.PupState{ID: "a"}})
+
+	changes := q.take()
+	require.Len(t, changes, 3)
+	assert.Equal(t, uint64(3), changes[0].Seq)
+	assert.Equal(t, uint64(4), changes[1].Seq)
+	assert.Equal(t, uint64(5), changes[2].Seq)
+
+	assert.Empty(t, q.take())
+}
+
+func TestChangeQueueKeepsPupChangesWithAReason(t *testing.T) {
+	q := newChangeQueue(10)
+
+	reason := &dogeboxd.PupdateReason{JobID: "job1", Action: "enable-pup", Actor: dogeboxd.JOB_ACTOR_USER}
+	q.push(dogeboxd.Change{Type: "pup", Seq: 1, Update: dogeboxd.PupState{ID: "a"}, Reason: reason})
+	q.push(dogeboxd.Change{Type: "pup", Seq: 2, Update: dogeboxd.PupState{ID: "a"}})
+	q.push(dogeboxd.Change{Type: "pup", Seq: 3, Update: dogeboxd.PupState{ID: "a"}})
+
+	changes := q.take()
+	require.Len(t, changes, 2)
+	assert.Equal(t, uint64(1), changes[0].Seq)
+	assert.Equal(t, reason, changes[0].Reason)
+	assert.Equal(t, uint64(3), changes[1].Seq)
+}
+
+func TestChangeQueueDropsOldestWhenFull(t *testing.T) {
+	q := newChangeQueue(2)
+
+	q.push(dogeboxd.Change{Type: "progress", Seq: 1})
+	q.push(dogeboxd.Change{Type: "progress", Seq: 2})
+	q.push(dogeboxd.Change{Type: "progress", Seq: 3})
+
+	changes := q.take()
+	require.Len(t, changes, 2)
+	assert.Equal(t, uint64(2), changes[0].Seq)
+	assert.Equal(t, uint64(3), changes[1].Seq)
+}
+
+func TestChangeQueueNeverDropsJobChanges(t *testing.T) {
+	q := newChangeQueue(2)
+
+	q.push(dogeboxd.Change{Type: "job:created"})
+	q.push(dogeboxd.Change{Type: "progress"})
+	q.push(dogeboxd.Change{Type: "action"})
+	q.push(dogeboxd.Change{Type: "job_completed"})
+	// Only job changes left, so this is the one to go.
+	q.push(dogeboxd.Change{Type: "progress"})
+
+	assert.Equal(t, []string{"job:created", "action", "job_completed"}, changeTypes(q.take()))
+}
+
+func TestChangeQueueOverflowsOnJobChanges(t *testing.T) {
+	q := newChangeQueue(1)
+
+	for i := 0; i < changeQueueOverflow; i++ {
+		assert.True(t, q.push(dogeboxd.Change{Type: "job:created"}))
+	}
+	// Nothing left to drop, and the client is hopelessly behind.
+	assert.False(t, q.push(dogeboxd.Change{Type: "job_completed"}))
+	assert.Len(t, q.take(), changeQueueOverflow)
+}
@@ -3,6 +3,7 @@ package web
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
@@ -11,17 +12,68 @@ import (
 
 type WSRelay struct {
 	config dogeboxd.ServerConfig
-	socks  []*WSCONN
+	socks  []*changeSubscriber
 	relay  chan dogeboxd.Change
-	newWs  chan *WSCONN
+	newWs  chan *changeSubscriber
 }
 
 func NewWSRelay(config dogeboxd.ServerConfig, relay chan dogeboxd.Change) WSRelay {
 	return WSRelay{
 		config: config,
-		socks:  []*WSCONN{},        // all current connections
-		relay:  relay,              // recieve Change messages from Dogeboxd to broadcast
-		newWs:  make(chan *WSCONN), // recieve new WSCONNs
+		socks:  []*changeSubscriber{},        // all current connections
+		relay:  relay,                        // recieve Change messages from Dogeboxd to broadcast
+		newWs:  make(chan *changeSubscriber), // recieve new subscribers
+	}
+}
+
+// A websocket client receiving changes, with its own queue so it
+// can be written to at its own pace.
+type changeSubscriber struct {
+	ws    *websocket.Conn
+	queue *changeQueue
+	done  chan struct{}
+	once  sync.Once
+}
+
+func newChangeSubscriber(ws *websocket.Conn) *changeSubscriber {
+	return &changeSubscriber{
+		ws:    ws,
+		queue: newChangeQueue(changeQueueLimit),
+		done:  make(chan struct{}),
+	}
+}
+
+func (s *changeSubscriber) IsClosed() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *changeSubscriber) Close() {
+	s.once.Do(func() { close(s.done) })
+}
+
+// run writes queued changes to the client until it goes away.
+func (s *changeSubscriber) run() {
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-s.queue.ready:
+			for _, c := range s.queue.take() {
+				if err := s.ws.SetWriteDeadline(time.Now().Add(changeWriteTimeout)); err != nil {
+					s.Close()
+					return
+				}
+				if err := websocket.JSON.Send(s.ws, c); err != nil {
+					s.Close()
+					return
+				}
+			}
+		}
 	}
 }
 
@@ -57,7 +109,7 @@ func (t WSRelay) Run(started, stopped chan bool, stop chan context.Context) erro
 }
 
 func (t *WSRelay) cleanupSocks() {
-	remaining := []*WSCONN{}
+	remaining := []*changeSubscriber{}
 	for _, s := range t.socks {
 		if s.IsClosed() {
 			continue
@@ -67,19 +119,21 @@ func (t *WSRelay) cleanupSocks() {
 	t.socks = remaining
 }
 
-func (t *WSRelay) broadcast(v any) {
+// broadcast queues v for every client, it never waits on a client.
+// Clients that have fallen too far behind are disconnected.
+func (t *WSRelay) broadcast(v dogeboxd.Change) {
 	for _, ws := range t.socks {
 		if ws.IsClosed() {
 			continue
 		}
-		err := websocket.JSON.Send(ws.WS, v)
-		if err != nil {
+		if !ws.queue.push(v) {
+			fmt.Println("websocket client fell too far behind, disconnecting it")
 			ws.Close()
 		}
 	}
 }
 
-func (t *WSRelay) addSock(ws *WSCONN) {
+func (t *WSRelay) addSock(ws *changeSubscriber) {
 	t.socks = append(t.socks, ws)
 }
 
@@ -89,14 +143,18 @@ func (t WSRelay) GetWSHandler(initialPayloader func() any) *websocket.Server {
 	}
 	h := websocket.Server{
 		Handler: func(ws *websocket.Conn) {
-			stop := make(chan bool)
-			t.newWs <- &WSCONN{ws, stop}
+			sub := newChangeSubscriber(ws)
+			t.newWs <- sub
+
+			dogeboxd.WebsocketClientConnected()
+			defer dogeboxd.WebsocketClientDisconnected()
 
+			ws.SetWriteDeadline(time.Now().Add(changeWriteTimeout))
 			err := websocket.JSON.Send(ws, initialPayloader())
 			if err != nil {
 				fmt.Println("failed to send initial payload", err)
 			}
-			<-stop // hold the connection until stopper closes
+			sub.run() // hold the connection until the client goes away
 		},
 		Config: *config,
 	}
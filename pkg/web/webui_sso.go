@@ -0,0 +1,189 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+)
+
+type WebUISSOTicketResponse struct {
+	Ticket string `json:"ticket"`
+	// Open the WebUI with the ticket in this query parameter.
+	Param     string    `json:"param"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// Mints a ticket for opening a pup's WebUI signed in as the dPanel user.
+func (t api) createWebUISSOTicket(w http.ResponseWriter, r *http.Request) {
+	pupID := r.PathValue("ID")
+	port, err := strconv.Atoi(r.PathValue("port"))
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Invalid WebUI port")
+		return
+	}
+
+	session, ok := getSession(r, getBearerToken)
+	if !ok {
+		sendErrorResponse(w, http.StatusUnauthorized, "No dPanel session to sign in with")
+		return
+	}
+
+	pup, _, err := t.pups.GetPup(pupID)
+	if err != nil {
+		sendAPIError(w, http.StatusNotFound, dogeboxd.NewAPIError(dogeboxd.ERROR_PUP_NOT_FOUND, "Pup not found").ForPup(pupID))
+		return
+	}
+
+	internal := 0
+	for _, ui := range pup.WebUIs {
+		if ui.Port == port {
+			internal = ui.Internal
+		}
+	}
+	if internal == 0 {
+		sendAPIError(w, http.StatusNotFound, dogeboxd.NewAPIError(dogeboxd.ERROR_NOT_FOUND, fmt.Sprintf("Pup has no WebUI on port %d", port)).ForPup(pupID))
+		return
+	}
+	if !pup.WebUISSO(internal) {
+		sendAPIError(w, http.StatusConflict, dogeboxd.NewAPIError(dogeboxd.ERROR_CONFLICT, "This WebUI doesn't support single sign-on").ForPup(pupID))
+		return
+	}
+	if t.dbx.WebUISSO == nil {
+		sendErrorResponse(w, http.StatusServiceUnavailable, "Single sign-on isn't available")
+		return
+	}
+
+	ticket, err := t.dbx.WebUISSO.Ticket(pupID, port, dogeboxd.WebUISSOSessionID(session.Token))
+	if err != nil {
+		sendErrorResponse(w, http.StatusInternalServerError, fmt.Sprintf("Failed to mint ticket: %v", err))
+		return
+	}
+
+	sendResponse(w, WebUISSOTicketResponse{
+		Ticket:    ticket,
+		Param:     dogeboxd.WEBUI_SSO_QUERY_PARAM,
+		ExpiresAt: time.Now().Add(dogeboxd.WebUISSOTicketTTL),
+	})
+}
+
+type VerifyWebUISSORequest struct {
+	Token string `json:"token"`
+}
+
+// Lets a pup check the X-Dogebox-SSO header it was sent, answering with
+// who is signed in.
+func (t InternalRouter) verifyWebUISSO(w http.ResponseWriter, r *http.Request) {
+	pup, ok := t.getOriginPup(r)
+	if !ok {
+		forbidden(w, "You are not a Pup we know about")
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error reading request body")
+		return
+	}
+	defer r.Body.Close()
+
+	var req VerifyWebUISSORequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		sendErrorResponse(w, http.StatusBadRequest, "Error unmarshalling JSON")
+		return
+	}
+
+	if t.dbx.WebUISSO == nil {
+		sendErrorResponse(w, http.StatusServiceUnavailable, "Single sign-on isn't available")
+		return
+	}
+
+	claims, err := t.dbx.WebUISSO.VerifyAssertion(req.Token, pup.ID)
+	if err != nil || !hasLiveSession(claims.SessionID) {
+		sendErrorResponse(w, http.StatusUnauthorized, "Invalid or expired token")
+		return
+	}
+
+	sendResponse(w, claims)
+}
+
+const webUISSOCookiePrefix = "dogebox_sso_"
+
+func webUISSOCookie(port int) string {
+	return fmt.Sprintf("%s%d", webUISSOCookiePrefix, port)
+}
+
+// stripDogeboxCookies removes our cookies from r before it reaches a pup.
+// Browsers don't scope cookies by port, so every WebUI is sent the SSO
+// cookies of every other WebUI, and of the dPanel, on the same host.
+func stripDogeboxCookies(r *http.Request) {
+	cookies := r.Cookies()
+	r.Header.Del("Cookie")
+	for _, c := range cookies {
+		if strings.HasPrefix(c.Name, webUISSOCookiePrefix) || isSessionToken(c.Value) {
+			continue
+		}
+		r.AddCookie(c)
+	}
+}
+
+/* ssoHandler signs requests to a WebUI in, when the pup has opted in.
+ * Opening the WebUI with a ticket swaps it for a session cookie, and
+ * requests with the cookie, while the dPanel session it came from lasts,
+ * are sent on with a fresh assertion in WEBUI_SSO_HEADER. Only this
+ * WebUI's cookie is read. The header is always dropped from what the
+ * client sent, and every WebUI's SSO cookie, and any dPanel session
+ * cookie, from what the pup sees, whether or not it has opted in.
+ */
+func (t *adminProxy) ssoHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Del(dogeboxd.WEBUI_SSO_HEADER)
+		if t.sso == nil || !t.ssoEnabled.Load() {
+			stripDogeboxCookies(r)
+			next.ServeHTTP(w, r)
+			return
+		}
+		cookie := webUISSOCookie(t.bindPort)
+
+		query := r.URL.Query()
+		if ticket := query.Get(dogeboxd.WEBUI_SSO_QUERY_PARAM); ticket != "" {
+			session, _, err := t.sso.Redeem(ticket, t.pupID, t.bindPort)
+			if err != nil {
+				// Carry on signed out, the pup can ask for its own login.
+				log.Printf("Refused WebUI sso ticket for %s: %v", t.pupID, err)
+			} else {
+				http.SetCookie(w, &http.Cookie{
+					Name:     cookie,
+					Value:    session,
+					Path:     "/",
+					MaxAge:   int(dogeboxd.WebUISSOSessionTTL.Seconds()),
+					HttpOnly: true,
+					SameSite: http.SameSiteLaxMode,
+				})
+			}
+			query.Del(dogeboxd.WEBUI_SSO_QUERY_PARAM)
+			target := *r.URL
+			target.RawQuery = query.Encode()
+			http.Redirect(w, r, target.RequestURI(), http.StatusFound)
+			return
+		}
+
+		if c, err := r.Cookie(cookie); err == nil {
+			claims, err := t.sso.Session(c.Value, t.pupID, t.bindPort)
+			if err == nil && hasLiveSession(claims.SessionID) {
+				if assertion, err := t.sso.Assertion(claims); err == nil {
+					r.Header.Set(dogeboxd.WEBUI_SSO_HEADER, assertion)
+				}
+			}
+		}
+		stripDogeboxCookies(r)
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,96 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	dogeboxd "github.com/Dogebox-WG/dogeboxd/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminProxySSOHandler(t *testing.T) {
+	sso, err := dogeboxd.NewWebUISSO()
+	require.NoError(t, err)
+
+	token, session := newSession()
+	sessions = append(sessions, session)
+	t.Cleanup(func() { sessions = nil })
+
+	var seen *http.Request
+	prx := &adminProxy{bindPort: 10000, pupID: "p1", sso: sso}
+	prx.ssoEnabled.Store(true)
+	handler := prx.ssoHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { seen = r }))
+
+	// Opening the WebUI with a ticket sets the cookie and drops the ticket.
+	ticket, err := sso.Ticket("p1", 10000, dogeboxd.WebUISSOSessionID(token))
+	require.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/app?tab=1&dogebox_sso="+ticket, nil))
+	require.Equal(t, http.StatusFound, recorder.Code)
+	assert.Equal(t, "/app?tab=1", recorder.Header().Get("Location"))
+	cookies := recorder.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, "dogebox_sso_10000", cookies[0].Name)
+
+	// Requests with the cookie are signed in, without the pup seeing it.
+	req := httptest.NewRequest("GET", "/app", nil)
+	req.AddCookie(cookies[0])
+	req.AddCookie(&http.Cookie{Name: "pup", Value: "mine"})
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	require.NotNil(t, seen)
+	claims, err := sso.VerifyAssertion(seen.Header.Get(dogeboxd.WEBUI_SSO_HEADER), "p1")
+	require.NoError(t, err)
+	assert.Equal(t, dogeboxd.WEBUI_SSO_USER, claims.User)
+	_, err = seen.Cookie("dogebox_sso_10000")
+	assert.ErrorIs(t, err, http.ErrNoCookie)
+	pupCookie, err := seen.Cookie("pup")
+	require.NoError(t, err)
+	assert.Equal(t, "mine", pupCookie.Value)
+
+	// Signing out of the dPanel signs out of the WebUI.
+	sessionsMu.Lock()
+	sessions[0].Expiration = time.Now().Add(-time.Second)
+	sessionsMu.Unlock()
+	seen = nil
+	req = httptest.NewRequest("GET", "/app", nil)
+	req.AddCookie(cookies[0])
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	require.NotNil(t, seen)
+	assert.Empty(t, seen.Header.Get(dogeboxd.WEBUI_SSO_HEADER))
+}
+
+func TestAdminProxyDropsClientSSOHeader(t *testing.T) {
+	var seen *http.Request
+	prx := &adminProxy{bindPort: 10000, pupID: "p1"}
+	handler := prx.ssoHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { seen = r }))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(dogeboxd.WEBUI_SSO_HEADER, "forged")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	require.NotNil(t, seen)
+	assert.Empty(t, seen.Header.Get(dogeboxd.WEBUI_SSO_HEADER))
+}
+
+func TestAdminProxyStripsOtherDogeboxCookies(t *testing.T) {
+	token, session := newSession()
+	sessions = append(sessions, session)
+	t.Cleanup(func() { sessions = nil })
+
+	var seen *http.Request
+	prx := &adminProxy{bindPort: 10000, pupID: "p1"}
+	handler := prx.ssoHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { seen = r }))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: "dogebox_sso_10001", Value: "another pup's"})
+	req.AddCookie(&http.Cookie{Name: "dpanel", Value: token})
+	req.AddCookie(&http.Cookie{Name: "pup", Value: "mine"})
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	require.NotNil(t, seen)
+
+	cookies := seen.Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, "pup", cookies[0].Name)
+}
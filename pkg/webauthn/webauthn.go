@@ -0,0 +1,196 @@
+package webauthn
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	gowebauthn "github.com/go-webauthn/webauthn/webauthn"
+)
+
+/* Package webauthn checks passkey registrations and sign-ins, the server
+ * half of WebAuthn (https://www.w3.org/TR/webauthn-2/), using
+ * github.com/go-webauthn/webauthn for the protocol itself.
+ *
+ * We ask for "none" attestation, as we only care that the same
+ * authenticator comes back, not who made it. User verification (a PIN
+ * or biometric) is required, as a passkey can stand in for the password.
+ */
+
+var ErrSignCount = errors.New("passkey signature counter went backwards, it may have been cloned")
+
+// Credential is what's kept of a registered passkey.
+type Credential struct {
+	ID []byte
+	// The COSE_Key, as CBOR.
+	PublicKey []byte
+	Algorithm int
+	SignCount uint32
+	// Whether the passkey can be synced between devices, which can't
+	// change once it's registered. Nil for passkeys registered before
+	// this was kept, which take it from their next sign-in.
+	BackupEligible *bool
+}
+
+// Session is a ceremony in progress, to be kept until it's finished.
+type Session = gowebauthn.SessionData
+
+// RelyingParty checks passkeys for dpanel on one origin (eg.
+// https://dogebox.local:8080), with rpID its hostname.
+type RelyingParty struct {
+	w *gowebauthn.WebAuthn
+}
+
+func NewRelyingParty(rpID, origin string) (*RelyingParty, error) {
+	w, err := gowebauthn.New(&gowebauthn.Config{
+		RPID:                  rpID,
+		RPDisplayName:         "Dogebox",
+		RPOrigins:             []string{origin},
+		AttestationPreference: protocol.PreferNoAttestation,
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			ResidentKey:      protocol.ResidentKeyRequirementPreferred,
+			UserVerification: protocol.VerificationRequired,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &RelyingParty{w: w}, nil
+}
+
+// user is dpanel's one (admin) user, with its passkeys for this site.
+type user struct {
+	id          []byte
+	credentials []gowebauthn.Credential
+}
+
+func (u user) WebAuthnID() []byte                           { return u.id }
+func (u user) WebAuthnName() string                         { return "admin" }
+func (u user) WebAuthnDisplayName() string                  { return "Dogebox admin" }
+func (u user) WebAuthnCredentials() []gowebauthn.Credential { return u.credentials }
+
+func newUser(userID []byte, creds []Credential) user {
+	u := user{id: userID}
+	for _, c := range creds {
+		flags := gowebauthn.CredentialFlags{}
+		if c.BackupEligible != nil {
+			flags.BackupEligible = *c.BackupEligible
+		}
+		u.credentials = append(u.credentials, gowebauthn.Credential{
+			ID:              c.ID,
+			PublicKey:       c.PublicKey,
+			AttestationType: "none",
+			Flags:           flags,
+			Authenticator:   gowebauthn.Authenticator{SignCount: c.SignCount},
+		})
+	}
+	return u
+}
+
+// BeginRegistration returns the options for navigator.credentials.create,
+// excluding the passkeys already registered.
+func (rp *RelyingParty) BeginRegistration(userID []byte, existing []Credential) (protocol.PublicKeyCredentialCreationOptions, Session, error) {
+	u := newUser(userID, existing)
+	creation, session, err := rp.w.BeginRegistration(u, gowebauthn.WithExclusions(gowebauthn.Credentials(u.credentials).CredentialDescriptors()))
+	if err != nil {
+		return protocol.PublicKeyCredentialCreationOptions{}, Session{}, err
+	}
+	return creation.Response, *session, nil
+}
+
+// FinishRegistration checks the PublicKeyCredential JSON the browser
+// created, and returns the new passkey.
+func (rp *RelyingParty) FinishRegistration(userID []byte, session Session, credentialJSON []byte) (Credential, error) {
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(credentialJSON)
+	if err != nil {
+		return Credential{}, describe(err)
+	}
+	cred, err := rp.w.CreateCredential(newUser(userID, nil), session, parsed)
+	if err != nil {
+		return Credential{}, describe(err)
+	}
+
+	alg, err := keyAlgorithm(cred.PublicKey)
+	if err != nil {
+		return Credential{}, err
+	}
+	backupEligible := cred.Flags.BackupEligible
+	return Credential{
+		ID:             cred.ID,
+		PublicKey:      cred.PublicKey,
+		Algorithm:      alg,
+		SignCount:      cred.Authenticator.SignCount,
+		BackupEligible: &backupEligible,
+	}, nil
+}
+
+// BeginLogin returns the options for navigator.credentials.get, allowing
+// any of creds.
+func (rp *RelyingParty) BeginLogin(userID []byte, creds []Credential) (protocol.PublicKeyCredentialRequestOptions, Session, error) {
+	assertion, session, err := rp.w.BeginLogin(newUser(userID, creds), gowebauthn.WithUserVerification(protocol.VerificationRequired))
+	if err != nil {
+		return protocol.PublicKeyCredentialRequestOptions{}, Session{}, err
+	}
+	return assertion.Response, *session, nil
+}
+
+// FinishLogin checks the PublicKeyCredential JSON the browser signed
+// with, returning which of creds it was, with its new signature counter.
+func (rp *RelyingParty) FinishLogin(userID []byte, session Session, creds []Credential, assertionJSON []byte) (Credential, error) {
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(assertionJSON)
+	if err != nil {
+		return Credential{}, describe(err)
+	}
+
+	var used *Credential
+	for i := range creds {
+		if string(creds[i].ID) == string(parsed.RawID) {
+			used = &creds[i]
+		}
+	}
+	if used == nil {
+		return Credential{}, errors.New("unknown passkey")
+	}
+	if used.BackupEligible == nil {
+		backupEligible := parsed.Response.AuthenticatorData.Flags.HasBackupEligible()
+		used.BackupEligible = &backupEligible
+	}
+
+	cred, err := rp.w.ValidateLogin(newUser(userID, creds), session, parsed)
+	if err != nil {
+		return Credential{}, describe(err)
+	}
+	if cred.Authenticator.CloneWarning {
+		return Credential{}, ErrSignCount
+	}
+
+	out := *used
+	out.SignCount = cred.Authenticator.SignCount
+	return out, nil
+}
+
+func keyAlgorithm(publicKey []byte) (int, error) {
+	key, err := webauthncose.ParsePublicKey(publicKey)
+	if err != nil {
+		return 0, fmt.Errorf("invalid credential public key: %w", err)
+	}
+	switch k := key.(type) {
+	case webauthncose.EC2PublicKeyData:
+		return int(k.Algorithm), nil
+	case webauthncose.OKPPublicKeyData:
+		return int(k.Algorithm), nil
+	case webauthncose.RSAPublicKeyData:
+		return int(k.Algorithm), nil
+	}
+	return 0, errors.New("unsupported credential public key")
+}
+
+// describe includes the library's details, which say what was wrong.
+func describe(err error) error {
+	var protoErr *protocol.Error
+	if errors.As(err, &protoErr) && protoErr.Details != "" {
+		return fmt.Errorf("%s: %s", protoErr.Type, protoErr.Details)
+	}
+	return err
+}
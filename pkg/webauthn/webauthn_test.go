@@ -0,0 +1,273 @@
+package webauthn
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"sort"
+	"testing"
+
+	"github.com/go-webauthn/webauthn/protocol"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testRPID   = "dogebox.local"
+	testOrigin = "https://dogebox.local:8080"
+
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttestedData = 0x40
+
+	algES256 = -7
+	algEdDSA = -8
+)
+
+var testUserID = []byte("dogebox-admin")
+
+// encodeCBOR builds the CBOR an authenticator would, for test responses.
+func encodeCBOR(v any) []byte {
+	head := func(major byte, n uint64) []byte {
+		switch {
+		case n < 24:
+			return []byte{major<<5 | byte(n)}
+		case n < 1<<8:
+			return []byte{major<<5 | 24, byte(n)}
+		case n < 1<<16:
+			return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(n))
+		default:
+			return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(n))
+		}
+	}
+	switch x := v.(type) {
+	case int:
+		if x < 0 {
+			return head(1, uint64(-1-x))
+		}
+		return head(0, uint64(x))
+	case []byte:
+		return append(head(2, uint64(len(x))), x...)
+	case string:
+		return append(head(3, uint64(len(x))), x...)
+	case map[any]any:
+		keys := make([]any, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool { return string(encodeCBOR(keys[i])) < string(encodeCBOR(keys[j])) })
+		out := head(5, uint64(len(x)))
+		for _, k := range keys {
+			out = append(out, encodeCBOR(k)...)
+			out = append(out, encodeCBOR(x[k])...)
+		}
+		return out
+	}
+	panic("unsupported type")
+}
+
+type testAuthenticator struct {
+	credID []byte
+	cose   []byte
+	sign   func(data []byte) []byte
+	count  uint32
+}
+
+func newES256Authenticator(t *testing.T) *testAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return &testAuthenticator{
+		credID: []byte("es256-credential"),
+		cose: encodeCBOR(map[any]any{
+			1: 2, 3: algES256, -1: 1,
+			-2: key.X.FillBytes(make([]byte, 32)),
+			-3: key.Y.FillBytes(make([]byte, 32)),
+		}),
+		sign: func(data []byte) []byte {
+			hash := sha256.Sum256(data)
+			sig, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+			require.NoError(t, err)
+			return sig
+		},
+	}
+}
+
+func newEdDSAAuthenticator(t *testing.T) *testAuthenticator {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return &testAuthenticator{
+		credID: []byte("eddsa-credential"),
+		cose:   encodeCBOR(map[any]any{1: 1, 3: algEdDSA, -1: 6, -2: []byte(pub)}),
+		sign:   func(data []byte) []byte { return ed25519.Sign(priv, data) },
+	}
+}
+
+func b64(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+func clientDataFor(typ string, challenge string, origin string) []byte {
+	b, _ := json.Marshal(map[string]string{
+		"type":      typ,
+		"challenge": challenge,
+		"origin":    origin,
+	})
+	return b
+}
+
+func (a *testAuthenticator) authData(rpID string, flags byte, attested bool) []byte {
+	hash := sha256.Sum256([]byte(rpID))
+	data := append(hash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, a.count)
+	if attested {
+		data = append(data, make([]byte, 16)...)
+		data = binary.BigEndian.AppendUint16(data, uint16(len(a.credID)))
+		data = append(data, a.credID...)
+		data = append(data, a.cose...)
+	}
+	return data
+}
+
+// register is the PublicKeyCredential JSON from navigator.credentials.create.
+func (a *testAuthenticator) register(challenge string, origin string) []byte {
+	attestation := encodeCBOR(map[any]any{
+		"fmt":      "none",
+		"attStmt":  map[any]any{},
+		"authData": a.authData(testRPID, flagUserPresent|flagUserVerified|flagAttestedData, true),
+	})
+	b, _ := json.Marshal(map[string]any{
+		"id":    b64(a.credID),
+		"rawId": b64(a.credID),
+		"type":  "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    b64(clientDataFor("webauthn.create", challenge, origin)),
+			"attestationObject": b64(attestation),
+		},
+	})
+	return b
+}
+
+// assert is the PublicKeyCredential JSON from navigator.credentials.get.
+func (a *testAuthenticator) assert(challenge string, flags byte) []byte {
+	a.count++
+	clientData := clientDataFor("webauthn.get", challenge, testOrigin)
+	authData := a.authData(testRPID, flags, false)
+	clientHash := sha256.Sum256(clientData)
+	b, _ := json.Marshal(map[string]any{
+		"id":    b64(a.credID),
+		"rawId": b64(a.credID),
+		"type":  "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    b64(clientData),
+			"authenticatorData": b64(authData),
+			"signature":         b64(a.sign(append(append([]byte{}, authData...), clientHash[:]...))),
+		},
+	})
+	return b
+}
+
+func registerTestCredential(t *testing.T, rp *RelyingParty, a *testAuthenticator) Credential {
+	_, session, err := rp.BeginRegistration(testUserID, nil)
+	require.NoError(t, err)
+	cred, err := rp.FinishRegistration(testUserID, session, a.register(session.Challenge, testOrigin))
+	require.NoError(t, err)
+	return cred
+}
+
+func newTestRelyingParty(t *testing.T) *RelyingParty {
+	rp, err := NewRelyingParty(testRPID, testOrigin)
+	require.NoError(t, err)
+	return rp
+}
+
+func TestRegisterAndSignIn(t *testing.T) {
+	for name, tc := range map[string]struct {
+		newAuthenticator func(*testing.T) *testAuthenticator
+		alg              int
+	}{
+		"ES256": {newES256Authenticator, algES256},
+		"EdDSA": {newEdDSAAuthenticator, algEdDSA},
+	} {
+		t.Run(name, func(t *testing.T) {
+			rp := newTestRelyingParty(t)
+			a := tc.newAuthenticator(t)
+
+			cred := registerTestCredential(t, rp, a)
+			assert.Equal(t, a.credID, cred.ID)
+			assert.Equal(t, a.cose, cred.PublicKey)
+			assert.Equal(t, tc.alg, cred.Algorithm)
+			require.NotNil(t, cred.BackupEligible)
+			assert.False(t, *cred.BackupEligible)
+
+			options, session, err := rp.BeginLogin(testUserID, []Credential{cred})
+			require.NoError(t, err)
+			assert.Equal(t, protocol.VerificationRequired, options.UserVerification)
+
+			used, err := rp.FinishLogin(testUserID, session, []Credential{cred}, a.assert(session.Challenge, flagUserPresent|flagUserVerified))
+			require.NoError(t, err)
+			assert.Equal(t, uint32(1), used.SignCount)
+		})
+	}
+}
+
+func TestRegistrationIsChecked(t *testing.T) {
+	rp := newTestRelyingParty(t)
+	a := newES256Authenticator(t)
+	_, session, err := rp.BeginRegistration(testUserID, nil)
+	require.NoError(t, err)
+
+	_, err = rp.FinishRegistration(testUserID, session, a.register(b64([]byte("other")), testOrigin))
+	assert.Error(t, err)
+	_, err = rp.FinishRegistration(testUserID, session, a.register(session.Challenge, "https://evil.example"))
+	assert.Error(t, err)
+
+	other, err := NewRelyingParty("evil.example", testOrigin)
+	require.NoError(t, err)
+	_, session, err = other.BeginRegistration(testUserID, nil)
+	require.NoError(t, err)
+	_, err = other.FinishRegistration(testUserID, session, a.register(session.Challenge, testOrigin))
+	assert.Error(t, err)
+}
+
+func TestAssertionIsChecked(t *testing.T) {
+	rp := newTestRelyingParty(t)
+	a := newES256Authenticator(t)
+	cred := registerTestCredential(t, rp, a)
+
+	signIn := func(a *testAuthenticator, flags byte, creds ...Credential) error {
+		_, session, err := rp.BeginLogin(testUserID, creds)
+		require.NoError(t, err)
+		_, err = rp.FinishLogin(testUserID, session, creds, a.assert(session.Challenge, flags))
+		return err
+	}
+
+	// Not user verified.
+	assert.Error(t, signIn(a, flagUserPresent, cred))
+
+	// Signed by another key.
+	other := newES256Authenticator(t)
+	assert.Error(t, signIn(other, flagUserPresent|flagUserVerified, cred))
+
+	// A counter that doesn't go up.
+	cloned := cred
+	cloned.SignCount = 10
+	assert.ErrorIs(t, signIn(a, flagUserPresent|flagUserVerified, cloned), ErrSignCount)
+}
+
+func TestPasskeysFromBeforeBackupEligibilityWasKept(t *testing.T) {
+	rp := newTestRelyingParty(t)
+	a := newES256Authenticator(t)
+	cred := registerTestCredential(t, rp, a)
+	cred.BackupEligible = nil
+
+	_, session, err := rp.BeginLogin(testUserID, []Credential{cred})
+	require.NoError(t, err)
+	used, err := rp.FinishLogin(testUserID, session, []Credential{cred}, a.assert(session.Challenge, flagUserPresent|flagUserVerified))
+	require.NoError(t, err)
+	require.NotNil(t, used.BackupEligible)
+	assert.False(t, *used.BackupEligible)
+}
@@ -0,0 +1,438 @@
+package dogeboxd
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+const (
+	WEBHOOK_EVENT_JOB_COMPLETED = "job.completed"
+	WEBHOOK_EVENT_JOB_FAILED    = "job.failed"
+	WEBHOOK_EVENT_TEST          = "webhook.test"
+	// Only sent to webhooks that ask for it by name, see UsageReporter.
+	WEBHOOK_EVENT_USAGE_SUMMARY = "usage.summary"
+)
+
+var webhookEvents = []string{WEBHOOK_EVENT_JOB_COMPLETED, WEBHOOK_EVENT_JOB_FAILED, WEBHOOK_EVENT_USAGE_SUMMARY}
+
+// WebhookSignatureHeader carries the hex HMAC-SHA256 of the request body,
+// keyed with the webhook's secret, ie: "sha256=<hex>".
+const WebhookSignatureHeader = "X-Dogebox-Signature"
+
+// A Webhook is a URL that's POSTed a WebhookPayload when a job finishes.
+type Webhook struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Secret string `json:"secret"`
+	// A reference to a secret kept in an external store, used instead of
+	// Secret so it's never stored here, see SecretProvider.
+	SecretRef string `json:"secretRef,omitempty"`
+	// Which of webhookEvents to send, empty meaning all the job events.
+	Events []string `json:"events,omitempty"`
+	// The ActionNames of jobs to send, ie: install, upgrade, system-update.
+	// Empty means every job.
+	Actions      []string         `json:"actions,omitempty"`
+	Enabled      bool             `json:"enabled"`
+	CreatedAt    time.Time        `json:"createdAt"`
+	LastDelivery *WebhookDelivery `json:"lastDelivery,omitempty"`
+}
+
+// The outcome of the last attempt to POST to a webhook.
+type WebhookDelivery struct {
+	At         time.Time `json:"at"`
+	Event      string    `json:"event"`
+	JobID      string    `json:"jobId,omitempty"`
+	StatusCode int       `json:"statusCode,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// WebhookView is a Webhook as returned by the API, the secret is only
+// shown when it's set, ie: on create.
+type WebhookView struct {
+	Webhook
+	Secret    string `json:"secret,omitempty"`
+	HasSecret bool   `json:"hasSecret"`
+}
+
+type WebhookPayload struct {
+	Event string    `json:"event"`
+	Sent  time.Time `json:"sent"`
+	// What happened as a sentence, written following the user's
+	// DisplayPreferences, for webhooks that post to a chat.
+	Message      string        `json:"message,omitempty"`
+	Job          *JobRecord    `json:"job,omitempty"`
+	UsageSummary *UsageSummary `json:"usageSummary,omitempty"`
+}
+
+// Validate checks the webhook's URL, events and actions.
+func (h Webhook) Validate() error {
+	if strings.TrimSpace(h.Name) == "" {
+		return errors.New("webhook name is required")
+	}
+	u, err := url.Parse(h.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("webhook url %q must be an absolute http or https URL", h.URL)
+	}
+	if h.Secret != "" && h.SecretRef != "" {
+		return errors.New("webhook can't have both a secret and a secretRef")
+	}
+	for _, e := range h.Events {
+		if !containsString(webhookEvents, e) {
+			return fmt.Errorf("unknown webhook event %q, expected one of: %s", e, strings.Join(webhookEvents, ", "))
+		}
+	}
+	for _, a := range h.Actions {
+		if strings.TrimSpace(a) == "" {
+			return errors.New("webhook actions can't be empty")
+		}
+	}
+	return nil
+}
+
+// wants reports whether a finished job should be sent to this webhook.
+func (h Webhook) wants(event string, job JobRecord) bool {
+	if !h.Enabled {
+		return false
+	}
+	if len(h.Events) > 0 && !containsString(h.Events, event) {
+		return false
+	}
+	return len(h.Actions) == 0 || containsString(h.Actions, job.Action)
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// SignWebhookPayload returns the WebhookSignatureHeader value for body.
+func SignWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// How many times, and how far apart, a delivery is tried.
+var (
+	webhookAttempts   = 3
+	webhookRetryDelay = 5 * time.Second
+)
+
+/* WebhookNotifier keeps the configured Webhooks and delivers job
+ * completions to them. Deliveries happen in the background and are
+ * retried a couple of times, a webhook being down never holds up or
+ * fails a job.
+ */
+type WebhookNotifier struct {
+	store   *TypeStore[Webhook]
+	secrets *SecretResolver
+	client  *http.Client
+	mu      sync.Mutex
+	now     func() time.Time
+	format  DisplayFormatSource
+	// Tracks deliveries in flight, for tests.
+	wg sync.WaitGroup
+}
+
+func NewWebhookNotifier(sm *StoreManager, secrets *SecretResolver) *WebhookNotifier {
+	return &WebhookNotifier{
+		store:   GetTypeStore[Webhook](sm),
+		secrets: secrets,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		now:     time.Now,
+	}
+}
+
+// JobFinished sends a finished job to every webhook that wants it.
+func (n *WebhookNotifier) JobFinished(job JobRecord) {
+	event := WEBHOOK_EVENT_JOB_COMPLETED
+	if job.Status == JobStatusFailed {
+		event = WEBHOOK_EVENT_JOB_FAILED
+	}
+
+	hooks, err := n.list()
+	if err != nil {
+		fmt.Println("WebhookNotifier: failed to load webhooks:", err)
+		return
+	}
+
+	message := jobMessage(job, n.format.Get())
+	for _, h := range hooks {
+		if !h.wants(event, job) {
+			continue
+		}
+		n.wg.Add(1)
+		go func(h Webhook) {
+			defer n.wg.Done()
+			n.deliver(h, WebhookPayload{Event: event, Sent: n.now(), Message: message, Job: &job}, webhookAttempts)
+		}(h)
+	}
+}
+
+// UsageSummary sends a weekly usage summary to every enabled webhook
+// that asked for them.
+func (n *WebhookNotifier) UsageSummary(summary UsageSummary) {
+	hooks, err := n.list()
+	if err != nil {
+		fmt.Println("WebhookNotifier: failed to load webhooks:", err)
+		return
+	}
+
+	message := summary.Describe(n.format.Get())
+	for _, h := range hooks {
+		if !h.Enabled || !containsString(h.Events, WEBHOOK_EVENT_USAGE_SUMMARY) {
+			continue
+		}
+		n.wg.Add(1)
+		go func(h Webhook) {
+			defer n.wg.Done()
+			n.deliver(h, WebhookPayload{Event: WEBHOOK_EVENT_USAGE_SUMMARY, Sent: n.now(), Message: message, UsageSummary: &summary}, webhookAttempts)
+		}(h)
+	}
+}
+
+// jobMessage describes a finished job, ie: "Install Dogecoin Core failed
+// at 15:04: out of space".
+func jobMessage(job JobRecord, f DisplayFormat) string {
+	at := ""
+	if job.Finished != nil {
+		at = " at " + f.Time(*job.Finished)
+	}
+	if job.Status == JobStatusFailed {
+		msg := fmt.Sprintf("%s failed%s", job.DisplayName, at)
+		if job.ErrorMessage != "" {
+			msg += ": " + job.ErrorMessage
+		}
+		return msg
+	}
+	return fmt.Sprintf("%s completed%s", job.DisplayName, at)
+}
+
+// Test sends a test event to a webhook straight away, without retries.
+func (n *WebhookNotifier) Test(id string) (WebhookDelivery, error) {
+	h, err := n.store.Get(id)
+	if err != nil {
+		return WebhookDelivery{}, ErrWebhookNotFound
+	}
+	return n.deliver(h, WebhookPayload{Event: WEBHOOK_EVENT_TEST, Sent: n.now()}, 1), nil
+}
+
+func (n *WebhookNotifier) deliver(h Webhook, payload WebhookPayload, attempts int) WebhookDelivery {
+	delivery := WebhookDelivery{Event: payload.Event}
+	if payload.Job != nil {
+		delivery.JobID = payload.Job.ID
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		delivery.At = n.now()
+		delivery.Error = err.Error()
+		n.recordDelivery(h.ID, delivery)
+		return delivery
+	}
+
+	for attempt := 1; attempt <= attempts; attempt++ {
+		delivery.StatusCode, delivery.Error = 0, ""
+		delivery.At = n.now()
+		status, err := n.post(h, payload.Event, delivery.JobID, body)
+		delivery.StatusCode = status
+		if err == nil {
+			break
+		}
+		delivery.Error = err.Error()
+		if attempt < attempts {
+			time.Sleep(webhookRetryDelay)
+		}
+	}
+
+	n.recordDelivery(h.ID, delivery)
+	return delivery
+}
+
+// signingSecret returns the webhook's secret, fetching it from the secret
+// store if it's kept there.
+func (n *WebhookNotifier) signingSecret(h Webhook) (string, error) {
+	if h.SecretRef == "" {
+		return h.Secret, nil
+	}
+	return n.secrets.Resolve(h.SecretRef)
+}
+
+func (n *WebhookNotifier) post(h Webhook, event string, jobID string, body []byte) (int, error) {
+	secret, err := n.signingSecret(h)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "dogeboxd")
+	req.Header.Set("X-Dogebox-Event", event)
+	if jobID != "" {
+		req.Header.Set("X-Dogebox-Job", jobID)
+	}
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(secret, body))
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// recordDelivery saves the outcome on the webhook, unless it was deleted
+// in the meantime.
+func (n *WebhookNotifier) recordDelivery(id string, delivery WebhookDelivery) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	h, err := n.store.Get(id)
+	if err != nil {
+		return
+	}
+	h.LastDelivery = &delivery
+	if err := n.store.Set(id, h); err != nil {
+		fmt.Printf("WebhookNotifier: failed to record delivery to %s: %v\n", id, err)
+	}
+}
+
+func (n *WebhookNotifier) list() ([]Webhook, error) {
+	query := fmt.Sprintf("SELECT value FROM %s ORDER BY json_extract(value, '$.createdAt') ASC", n.store.Table)
+	return n.store.Exec(query)
+}
+
+func (n *WebhookNotifier) view(h Webhook, showSecret bool) WebhookView {
+	v := WebhookView{Webhook: h, HasSecret: h.Secret != "" || h.SecretRef != ""}
+	if showSecret {
+		v.Secret = h.Secret
+	}
+	return v
+}
+
+func (n *WebhookNotifier) List() ([]WebhookView, error) {
+	hooks, err := n.list()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]WebhookView, 0, len(hooks))
+	for _, h := range hooks {
+		out = append(out, n.view(h, false))
+	}
+	return out, nil
+}
+
+func (n *WebhookNotifier) Get(id string) (WebhookView, error) {
+	h, err := n.store.Get(id)
+	if err != nil {
+		return WebhookView{}, ErrWebhookNotFound
+	}
+	return n.view(h, false), nil
+}
+
+// validate checks the webhook, and that its secretRef can be resolved.
+func (n *WebhookNotifier) validate(h Webhook) error {
+	if err := h.Validate(); err != nil {
+		return err
+	}
+	if h.SecretRef != "" {
+		return n.secrets.Validate(h.SecretRef)
+	}
+	return nil
+}
+
+// Create validates and stores a new webhook, generating a secret if one
+// isn't given. The secret is only returned here.
+func (n *WebhookNotifier) Create(h Webhook) (WebhookView, error) {
+	if err := n.validate(h); err != nil {
+		return WebhookView{}, err
+	}
+
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return WebhookView{}, err
+	}
+	h.ID = fmt.Sprintf("%x", b)
+	if h.Secret == "" && h.SecretRef == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return WebhookView{}, err
+		}
+		h.Secret = hex.EncodeToString(secret)
+	}
+	h.CreatedAt = n.now()
+	h.LastDelivery = nil
+
+	if err := n.store.Set(h.ID, h); err != nil {
+		return WebhookView{}, err
+	}
+	return n.view(h, true), nil
+}
+
+// Update replaces a webhook's name, URL, filters and enabled flag. The
+// secret is kept unless a new one, or a secretRef, is given.
+func (n *WebhookNotifier) Update(id string, h Webhook) (WebhookView, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	existing, err := n.store.Get(id)
+	if err != nil {
+		return WebhookView{}, ErrWebhookNotFound
+	}
+	if err := n.validate(h); err != nil {
+		return WebhookView{}, err
+	}
+
+	existing.Name = h.Name
+	existing.URL = h.URL
+	existing.Events = h.Events
+	existing.Actions = h.Actions
+	existing.Enabled = h.Enabled
+	rotated := h.Secret != ""
+	if rotated {
+		existing.Secret = h.Secret
+		existing.SecretRef = ""
+	} else if h.SecretRef != "" {
+		existing.Secret = ""
+		existing.SecretRef = h.SecretRef
+	}
+
+	if err := n.store.Set(id, existing); err != nil {
+		return WebhookView{}, err
+	}
+	return n.view(existing, rotated), nil
+}
+
+func (n *WebhookNotifier) Delete(id string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if _, err := n.store.Get(id); err != nil {
+		return ErrWebhookNotFound
+	}
+	return n.store.Del(id)
+}
@@ -0,0 +1,215 @@
+package dogeboxd
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type webhookReceiver struct {
+	mu       sync.Mutex
+	payloads []WebhookPayload
+	headers  []http.Header
+	bodies   [][]byte
+	status   int
+}
+
+func newWebhookReceiver(t *testing.T) (*webhookReceiver, string) {
+	rcv := &webhookReceiver{status: http.StatusOK}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var p WebhookPayload
+		json.Unmarshal(body, &p)
+
+		rcv.mu.Lock()
+		defer rcv.mu.Unlock()
+		rcv.payloads = append(rcv.payloads, p)
+		rcv.headers = append(rcv.headers, r.Header.Clone())
+		rcv.bodies = append(rcv.bodies, body)
+		w.WriteHeader(rcv.status)
+	}))
+	t.Cleanup(srv.Close)
+	return rcv, srv.URL
+}
+
+func setupTestWebhookNotifier(t *testing.T) *WebhookNotifier {
+	sm, err := NewStoreManager(":memory:")
+	require.NoError(t, err)
+
+	origDelay := webhookRetryDelay
+	webhookRetryDelay = 0
+	t.Cleanup(func() { webhookRetryDelay = origDelay })
+
+	return NewWebhookNotifier(sm, nil)
+}
+
+func TestWebhookCreateValidates(t *testing.T) {
+	n := setupTestWebhookNotifier(t)
+
+	_, err := n.Create(Webhook{Name: "relative", URL: "/hook"})
+	assert.Error(t, err)
+	_, err = n.Create(Webhook{Name: "ftp", URL: "ftp://example.com/hook"})
+	assert.Error(t, err)
+	_, err = n.Create(Webhook{Name: "bad event", URL: "https://example.com/hook", Events: []string{"job.started"}})
+	assert.Error(t, err)
+
+	view, err := n.Create(Webhook{Name: "chat", URL: "https://example.com/hook", Enabled: true})
+	require.NoError(t, err)
+	assert.NotEmpty(t, view.ID)
+	assert.Len(t, view.Secret, 64, "a secret should be generated and shown on create")
+
+	got, err := n.Get(view.ID)
+	require.NoError(t, err)
+	assert.Empty(t, got.Secret, "the secret shouldn't be shown again")
+	assert.True(t, got.HasSecret)
+
+	encoded, err := json.Marshal(got)
+	require.NoError(t, err)
+	assert.NotContains(t, string(encoded), view.Secret)
+}
+
+func TestWebhookJobFinishedSendsSignedPayload(t *testing.T) {
+	n := setupTestWebhookNotifier(t)
+	rcv, url := newWebhookReceiver(t)
+
+	hook, err := n.Create(Webhook{Name: "failures", URL: url, Secret: "s3cret", Events: []string{WEBHOOK_EVENT_JOB_FAILED}, Actions: []string{"install"}, Enabled: true})
+	require.NoError(t, err)
+	_, err = n.Create(Webhook{Name: "disabled", URL: url, Enabled: false})
+	require.NoError(t, err)
+
+	n.JobFinished(JobRecord{ID: "job-1", Action: "install", Status: JobStatusCompleted})
+	n.JobFinished(JobRecord{ID: "job-2", Action: "upgrade", Status: JobStatusFailed})
+	n.JobFinished(JobRecord{ID: "job-3", Action: "install", Status: JobStatusFailed, ErrorMessage: "boom"})
+	n.wg.Wait()
+
+	require.Len(t, rcv.payloads, 1)
+	assert.Equal(t, WEBHOOK_EVENT_JOB_FAILED, rcv.payloads[0].Event)
+	require.NotNil(t, rcv.payloads[0].Job)
+	assert.Equal(t, "job-3", rcv.payloads[0].Job.ID)
+	assert.Equal(t, "boom", rcv.payloads[0].Job.ErrorMessage)
+	assert.Equal(t, "job-3", rcv.headers[0].Get("X-Dogebox-Job"))
+	assert.Equal(t, SignWebhookPayload("s3cret", rcv.bodies[0]), rcv.headers[0].Get(WebhookSignatureHeader))
+
+	got, err := n.Get(hook.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got.LastDelivery)
+	assert.Equal(t, http.StatusOK, got.LastDelivery.StatusCode)
+	assert.Empty(t, got.LastDelivery.Error)
+}
+
+func TestWebhookRetriesFailedDeliveries(t *testing.T) {
+	n := setupTestWebhookNotifier(t)
+	rcv, url := newWebhookReceiver(t)
+	rcv.status = http.StatusBadGateway
+
+	hook, err := n.Create(Webhook{Name: "down", URL: url, Enabled: true})
+	require.NoError(t, err)
+
+	n.JobFinished(JobRecord{ID: "job-1", Action: "system-update", Status: JobStatusCompleted})
+	n.wg.Wait()
+
+	assert.Len(t, rcv.payloads, webhookAttempts)
+	got, err := n.Get(hook.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got.LastDelivery)
+	assert.Equal(t, http.StatusBadGateway, got.LastDelivery.StatusCode)
+	assert.NotEmpty(t, got.LastDelivery.Error)
+}
+
+func TestWebhookUpdateKeepsSecret(t *testing.T) {
+	n := setupTestWebhookNotifier(t)
+	rcv, url := newWebhookReceiver(t)
+
+	hook, err := n.Create(Webhook{Name: "chat", URL: "https://example.com/hook", Secret: "first"})
+	require.NoError(t, err)
+
+	updated, err := n.Update(hook.ID, Webhook{Name: "chat", URL: url, Enabled: true})
+	require.NoError(t, err)
+	assert.Empty(t, updated.Secret)
+
+	delivery, err := n.Test(hook.ID)
+	require.NoError(t, err)
+	assert.Empty(t, delivery.Error)
+	require.Len(t, rcv.payloads, 1)
+	assert.Equal(t, WEBHOOK_EVENT_TEST, rcv.payloads[0].Event)
+	assert.Equal(t, SignWebhookPayload("first", rcv.bodies[0]), rcv.headers[0].Get(WebhookSignatureHeader))
+
+	_, err = n.Update("missing", Webhook{Name: "chat", URL: url})
+	assert.ErrorIs(t, err, ErrWebhookNotFound)
+}
+
+func TestWebhookSecretRefIsResolvedOnDelivery(t *testing.T) {
+	n := setupTestWebhookNotifier(t)
+	rcv, url := newWebhookReceiver(t)
+	store := &stubSecretProvider{name: "sops", secrets: map[string]string{"webhooks.chat": "from-store"}}
+
+	_, err := n.Create(Webhook{Name: "chat", URL: url, SecretRef: "sops:webhooks.chat"})
+	assert.ErrorIs(t, err, ErrSecretProviderNotConfigured)
+
+	n.secrets = NewSecretResolver(store)
+	_, err = n.Create(Webhook{Name: "chat", URL: url, Secret: "inline", SecretRef: "sops:webhooks.chat"})
+	assert.Error(t, err)
+
+	hook, err := n.Create(Webhook{Name: "chat", URL: url, SecretRef: "sops:webhooks.chat", Enabled: true})
+	require.NoError(t, err)
+	assert.Empty(t, hook.Secret, "no secret should be generated when using a secretRef")
+	assert.True(t, hook.HasSecret)
+
+	// Rotated in the store after the webhook was created.
+	store.secrets["webhooks.chat"] = "rotated"
+	delivery, err := n.Test(hook.ID)
+	require.NoError(t, err)
+	assert.Empty(t, delivery.Error)
+	require.Len(t, rcv.payloads, 1)
+	assert.Equal(t, SignWebhookPayload("rotated", rcv.bodies[0]), rcv.headers[0].Get(WebhookSignatureHeader))
+
+	delete(store.secrets, "webhooks.chat")
+	delivery, err = n.Test(hook.ID)
+	require.NoError(t, err)
+	assert.Contains(t, delivery.Error, "sops:webhooks.chat")
+	assert.Len(t, rcv.payloads, 1, "nothing should be sent without the secret")
+}
+
+func TestWebhookUsageSummaryIsOptIn(t *testing.T) {
+	n := setupTestWebhookNotifier(t)
+	rcv, url := newWebhookReceiver(t)
+
+	_, err := n.Create(Webhook{Name: "everything", URL: url, Enabled: true})
+	require.NoError(t, err)
+	_, err = n.Create(Webhook{Name: "weekly", URL: url, Events: []string{WEBHOOK_EVENT_USAGE_SUMMARY}, Enabled: true})
+	require.NoError(t, err)
+
+	n.UsageSummary(UsageSummary{ID: "2026-10-05", JobFailures: 2})
+	n.wg.Wait()
+
+	require.Len(t, rcv.payloads, 1)
+	assert.Equal(t, WEBHOOK_EVENT_USAGE_SUMMARY, rcv.payloads[0].Event)
+	require.NotNil(t, rcv.payloads[0].UsageSummary)
+	assert.Equal(t, "2026-10-05", rcv.payloads[0].UsageSummary.ID)
+	assert.Nil(t, rcv.payloads[0].Job)
+}
+
+func TestWebhookMessageFollowsDisplayPreferences(t *testing.T) {
+	n := setupTestWebhookNotifier(t)
+	n.format = func() DisplayFormat {
+		return DisplayFormat{Preferences: DisplayPreferences{ClockFormat: CLOCK_FORMAT_12H}.WithDefaults(), Location: time.UTC}
+	}
+	rcv, url := newWebhookReceiver(t)
+
+	_, err := n.Create(Webhook{Name: "failures", URL: url, Events: []string{WEBHOOK_EVENT_JOB_FAILED}, Enabled: true})
+	require.NoError(t, err)
+
+	finished := time.Date(2026, 10, 17, 15, 4, 0, 0, time.UTC)
+	n.JobFinished(JobRecord{ID: "job-1", DisplayName: "Install Dogecoin Core", Status: JobStatusFailed, Finished: &finished, ErrorMessage: "out of space"})
+	n.wg.Wait()
+
+	require.Len(t, rcv.payloads, 1)
+	assert.Equal(t, "Install Dogecoin Core failed at 3:04 PM: out of space", rcv.payloads[0].Message)
+}
@@ -0,0 +1,196 @@
+package dogeboxd
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// The header the admin proxy passes the signed-in user through in, to
+	// pups whose WebUI opts in with the manifest's sso flag. Anything a
+	// client sends in it is dropped.
+	WEBUI_SSO_HEADER = "X-Dogebox-SSO"
+	// The query parameter a WebUI is opened with to sign in, carrying a
+	// ticket from POST /pup/{ID}/webui/{port}/sso.
+	WEBUI_SSO_QUERY_PARAM = "dogebox_sso"
+	// Who the tokens say is signed in, a Dogebox has the one user.
+	WEBUI_SSO_USER = "dogebox"
+
+	// A ticket for opening a WebUI, redeemed once by the admin proxy.
+	WEBUI_SSO_TICKET = "ticket"
+	// The admin proxy's cookie for a signed-in WebUI.
+	WEBUI_SSO_SESSION = "session"
+	// What the pup is sent in WEBUI_SSO_HEADER, on every request.
+	WEBUI_SSO_ASSERTION = "assertion"
+
+	WebUISSOTicketTTL    = time.Minute
+	WebUISSOSessionTTL   = time.Hour
+	WebUISSOAssertionTTL = time.Minute
+)
+
+var ErrWebUISSOInvalid = errors.New("invalid or expired sso token")
+
+// WebUISSOClaims are what a WebUISSO token vouches for.
+type WebUISSOClaims struct {
+	Purpose string `json:"purpose"`
+	User    string `json:"sub"`
+	PupID   string `json:"pupId"`
+	// The WebUI's port on the box, tickets and sessions are for one WebUI.
+	Port int `json:"port,omitempty"`
+	// Identifies the dPanel session signed in, so signing out of the
+	// dPanel signs out of the WebUIs too.
+	SessionID string `json:"sid"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+	Nonce     string `json:"nonce"`
+}
+
+/* WebUISSO mints and checks the short-lived tokens that sign the dPanel
+ * user into pup WebUIs. Tokens are HMAC signed with a key made when
+ * dogeboxd starts, so a restart signs everyone out, and only dogeboxd
+ * can check them: pups post what they're sent to /dbx/sso/verify on the
+ * internal router, which only accepts assertions meant for the pup
+ * asking.
+ */
+type WebUISSO struct {
+	key      []byte
+	now      func() time.Time
+	lock     sync.Mutex
+	redeemed map[string]time.Time
+}
+
+func NewWebUISSO() (*WebUISSO, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate sso key: %w", err)
+	}
+	return &WebUISSO{key: key, now: time.Now, redeemed: map[string]time.Time{}}, nil
+}
+
+// WebUISSOSessionID identifies a dPanel session in tokens without
+// giving away its bearer token.
+func WebUISSOSessionID(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:16])
+}
+
+func (s *WebUISSO) mint(c WebUISSOClaims, ttl time.Duration) (string, error) {
+	nonce := make([]byte, 12)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	now := s.now()
+	c.User = WEBUI_SSO_USER
+	c.IssuedAt = now.Unix()
+	c.ExpiresAt = now.Add(ttl).Unix()
+	c.Nonce = hex.EncodeToString(nonce)
+
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	body := base64.RawURLEncoding.EncodeToString(payload)
+	return body + "." + s.sign(body), nil
+}
+
+func (s *WebUISSO) sign(body string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(body))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verify checks a token's signature and expiry, and that it's for purpose
+// and pupID.
+func (s *WebUISSO) verify(token string, purpose string, pupID string) (WebUISSOClaims, error) {
+	body, sig, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(s.sign(body))) {
+		return WebUISSOClaims{}, ErrWebUISSOInvalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return WebUISSOClaims{}, ErrWebUISSOInvalid
+	}
+	var c WebUISSOClaims
+	if err := json.Unmarshal(payload, &c); err != nil {
+		return WebUISSOClaims{}, ErrWebUISSOInvalid
+	}
+	if c.Purpose != purpose || c.PupID != pupID || s.now().Unix() >= c.ExpiresAt {
+		return WebUISSOClaims{}, ErrWebUISSOInvalid
+	}
+	return c, nil
+}
+
+// Ticket mints a ticket for opening a pup's WebUI signed in.
+func (s *WebUISSO) Ticket(pupID string, port int, sessionID string) (string, error) {
+	return s.mint(WebUISSOClaims{Purpose: WEBUI_SSO_TICKET, PupID: pupID, Port: port, SessionID: sessionID}, WebUISSOTicketTTL)
+}
+
+// Redeem swaps a ticket for a session with the WebUI it's for. A ticket
+// ends up in the browser's history, so it only works the once.
+func (s *WebUISSO) Redeem(ticket string, pupID string, port int) (string, WebUISSOClaims, error) {
+	c, err := s.verify(ticket, WEBUI_SSO_TICKET, pupID)
+	if err != nil || c.Port != port {
+		return "", WebUISSOClaims{}, ErrWebUISSOInvalid
+	}
+
+	s.lock.Lock()
+	now := s.now()
+	for nonce, expires := range s.redeemed {
+		if now.After(expires) {
+			delete(s.redeemed, nonce)
+		}
+	}
+	if _, used := s.redeemed[c.Nonce]; used {
+		s.lock.Unlock()
+		return "", WebUISSOClaims{}, ErrWebUISSOInvalid
+	}
+	s.redeemed[c.Nonce] = time.Unix(c.ExpiresAt, 0)
+	s.lock.Unlock()
+
+	session, err := s.mint(WebUISSOClaims{Purpose: WEBUI_SSO_SESSION, PupID: pupID, Port: port, SessionID: c.SessionID}, WebUISSOSessionTTL)
+	if err != nil {
+		return "", WebUISSOClaims{}, err
+	}
+	return session, c, nil
+}
+
+// Session checks a WebUI's session cookie.
+func (s *WebUISSO) Session(session string, pupID string, port int) (WebUISSOClaims, error) {
+	c, err := s.verify(session, WEBUI_SSO_SESSION, pupID)
+	if err != nil || c.Port != port {
+		return WebUISSOClaims{}, ErrWebUISSOInvalid
+	}
+	return c, nil
+}
+
+// Assertion mints what the admin proxy sends a pup for a signed-in
+// request, fresh each time so a leaked one is soon useless.
+func (s *WebUISSO) Assertion(session WebUISSOClaims) (string, error) {
+	return s.mint(WebUISSOClaims{Purpose: WEBUI_SSO_ASSERTION, PupID: session.PupID, Port: session.Port, SessionID: session.SessionID}, WebUISSOAssertionTTL)
+}
+
+// VerifyAssertion checks an assertion a pup was sent, pupID being the pup
+// asking, so one pup can't replay what it was sent to another.
+func (s *WebUISSO) VerifyAssertion(assertion string, pupID string) (WebUISSOClaims, error) {
+	return s.verify(assertion, WEBUI_SSO_ASSERTION, pupID)
+}
+
+// WebUISSO reports whether the pup's WebUI listening on internalPort
+// opted in to single sign-on in the manifest.
+func (p PupState) WebUISSO(internalPort int) bool {
+	for _, ex := range p.Manifest.Container.Exposes {
+		if ex.WebUI && ex.Port == internalPort {
+			return ex.SSO
+		}
+	}
+	return false
+}
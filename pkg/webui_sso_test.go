@@ -0,0 +1,76 @@
+package dogeboxd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebUISSOTicketRedeemsOnce(t *testing.T) {
+	sso, err := NewWebUISSO()
+	require.NoError(t, err)
+
+	ticket, err := sso.Ticket("p1", 10000, "sid")
+	require.NoError(t, err)
+
+	_, _, err = sso.Redeem(ticket, "p1", 10001)
+	assert.ErrorIs(t, err, ErrWebUISSOInvalid, "ticket is for another WebUI")
+	_, _, err = sso.Redeem(ticket, "p2", 10000)
+	assert.ErrorIs(t, err, ErrWebUISSOInvalid, "ticket is for another pup")
+
+	session, claims, err := sso.Redeem(ticket, "p1", 10000)
+	require.NoError(t, err)
+	assert.Equal(t, "sid", claims.SessionID)
+	_, _, err = sso.Redeem(ticket, "p1", 10000)
+	assert.ErrorIs(t, err, ErrWebUISSOInvalid, "ticket was already redeemed")
+
+	_, err = sso.Session(ticket, "p1", 10000)
+	assert.ErrorIs(t, err, ErrWebUISSOInvalid, "a ticket isn't a session")
+	sessionClaims, err := sso.Session(session, "p1", 10000)
+	require.NoError(t, err)
+
+	assertion, err := sso.Assertion(sessionClaims)
+	require.NoError(t, err)
+	_, err = sso.VerifyAssertion(session, "p1")
+	assert.ErrorIs(t, err, ErrWebUISSOInvalid, "a session isn't an assertion")
+	_, err = sso.VerifyAssertion(assertion, "p2")
+	assert.ErrorIs(t, err, ErrWebUISSOInvalid, "assertion is for another pup")
+	verified, err := sso.VerifyAssertion(assertion, "p1")
+	require.NoError(t, err)
+	assert.Equal(t, WEBUI_SSO_USER, verified.User)
+	assert.Equal(t, "sid", verified.SessionID)
+}
+
+func TestWebUISSORejectsExpiredAndTampered(t *testing.T) {
+	sso, err := NewWebUISSO()
+	require.NoError(t, err)
+	now := time.Now()
+	sso.now = func() time.Time { return now }
+
+	assertion, err := sso.Assertion(WebUISSOClaims{PupID: "p1", SessionID: "sid"})
+	require.NoError(t, err)
+	_, err = sso.VerifyAssertion(assertion[:len(assertion)-2]+"xx", "p1")
+	assert.ErrorIs(t, err, ErrWebUISSOInvalid)
+
+	other, err := NewWebUISSO()
+	require.NoError(t, err)
+	_, err = other.VerifyAssertion(assertion, "p1")
+	assert.ErrorIs(t, err, ErrWebUISSOInvalid, "signed with another key")
+
+	now = now.Add(WebUISSOAssertionTTL)
+	_, err = sso.VerifyAssertion(assertion, "p1")
+	assert.ErrorIs(t, err, ErrWebUISSOInvalid)
+}
+
+func TestPupStateWebUISSO(t *testing.T) {
+	p := PupState{Manifest: PupManifest{Container: PupManifestContainer{Exposes: []PupManifestExposeConfig{
+		{Port: 8080, WebUI: true, SSO: true},
+		{Port: 8081, WebUI: true},
+		{Port: 8082, SSO: true},
+	}}}}
+	assert.True(t, p.WebUISSO(8080))
+	assert.False(t, p.WebUISSO(8081))
+	assert.False(t, p.WebUISSO(8082))
+}